@@ -0,0 +1,39 @@
+package bastionclient
+
+import (
+	"bastion/models"
+	"context"
+	"net/url"
+)
+
+// ListAgents lists the agents that run workspaces of the daemon, with their last report
+func (c *Client) ListAgents(ctx context.Context) ([]models.Agent, error) {
+	var agents []models.Agent
+	if err := c.Do(ctx, "GET", "/api/v2/agents", nil, &agents); err != nil {
+		return nil, err
+	}
+	return agents, nil
+}
+
+// ReportAgent registers an agent with the daemon, or refreshes it, with the state of its mappings
+func (c *Client) ReportAgent(ctx context.Context, report models.AgentReport) (*models.Agent, error) {
+	var agent models.Agent
+	if err := c.Do(ctx, "POST", "/api/v2/agents/report", report, &agent); err != nil {
+		return nil, err
+	}
+	return &agent, nil
+}
+
+// DeleteAgent forgets an agent; the daemon auto-starts its workspace again
+func (c *Client) DeleteAgent(ctx context.Context, name string) error {
+	return c.Do(ctx, "DELETE", "/api/v2/agents/"+url.PathEscape(name), nil, nil)
+}
+
+// IssueAgentToken issues an agent a token that only reports it and reads its workspace
+func (c *Client) IssueAgentToken(ctx context.Context, req models.AgentTokenRequest) (*models.AgentToken, error) {
+	var token models.AgentToken
+	if err := c.Do(ctx, "POST", "/api/v2/agents", req, &token); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
@@ -0,0 +1,126 @@
+package bastionclient
+
+import (
+	"bastion/core"
+	"bastion/models"
+	"context"
+	"fmt"
+	"net/url"
+)
+
+// CredentialRotation is the outcome of RotateBastionCredentials (service.BastionRotation).
+type CredentialRotation struct {
+	ID                uint               `json:"id"`
+	Version           int                `json:"version"`
+	Verification      *core.DryRunReport `json:"verification"`
+	RecycledChains    []string           `json:"recycled_chains"`
+	RestartedMappings []string           `json:"restarted_mappings"`
+	RestartErrors     map[string]string  `json:"restart_errors,omitempty"`
+}
+
+// ListBastions lists all bastions
+func (c *Client) ListBastions(ctx context.Context) ([]models.Bastion, error) {
+	return c.SearchBastions(ctx, models.ListFilter{})
+}
+
+// SearchBastions lists the bastions matching filter
+func (c *Client) SearchBastions(ctx context.Context, filter models.ListFilter) ([]models.Bastion, error) {
+	var bastions []models.Bastion
+	if err := c.Do(ctx, "GET", "/api/v2/bastions"+listFilterQuery(filter), nil, &bastions); err != nil {
+		return nil, err
+	}
+	return bastions, nil
+}
+
+// GetBastion fetches a single bastion
+func (c *Client) GetBastion(ctx context.Context, id uint) (*models.Bastion, error) {
+	var bastion models.Bastion
+	if err := c.Do(ctx, "GET", fmt.Sprintf("/api/v2/bastions/%d", id), nil, &bastion); err != nil {
+		return nil, err
+	}
+	return &bastion, nil
+}
+
+// CreateBastion creates a bastion
+func (c *Client) CreateBastion(ctx context.Context, req models.BastionCreate) (*models.Bastion, error) {
+	var bastion models.Bastion
+	if err := c.Do(ctx, "POST", "/api/v2/bastions", req, &bastion); err != nil {
+		return nil, err
+	}
+	return &bastion, nil
+}
+
+// UpdateBastion updates a bastion
+func (c *Client) UpdateBastion(ctx context.Context, id uint, req models.BastionCreate) (*models.Bastion, error) {
+	var bastion models.Bastion
+	if err := c.Do(ctx, "PUT", fmt.Sprintf("/api/v2/bastions/%d", id), req, &bastion); err != nil {
+		return nil, err
+	}
+	return &bastion, nil
+}
+
+// DeleteBastion deletes a bastion
+func (c *Client) DeleteBastion(ctx context.Context, id uint) error {
+	return c.Do(ctx, "DELETE", fmt.Sprintf("/api/v2/bastions/%d", id), nil, nil)
+}
+
+// RotateBastionCredentials replaces a bastion's credentials once a live SSH test with them
+// succeeds; a failed test is a BAD_GATEWAY *APIError and changes nothing.
+func (c *Client) RotateBastionCredentials(ctx context.Context, id uint, req models.BastionCredentials) (*CredentialRotation, error) {
+	var rotation CredentialRotation
+	if err := c.Do(ctx, "POST", fmt.Sprintf("/api/v2/bastions/%d/rotate-credentials", id), req, &rotation); err != nil {
+		return nil, err
+	}
+	return &rotation, nil
+}
+
+// BenchmarkBastion measures latency and throughput of the chain to a bastion.
+func (c *Client) BenchmarkBastion(ctx context.Context, id uint, req models.BastionBenchmarkRequest) (*core.BenchmarkReport, error) {
+	var report core.BenchmarkReport
+	if err := c.Do(ctx, "POST", fmt.Sprintf("/api/v2/bastions/%d/benchmark", id), req, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// DockerContainers lists the Docker containers on a bastion host with a ready-to-create mapping for
+// each published port.
+func (c *Client) DockerContainers(ctx context.Context, id uint, req models.BastionDockerRequest) (*core.DockerListing, error) {
+	values := url.Values{}
+	if req.Source != "" {
+		values.Set("source", req.Source)
+	}
+	if req.Host != "" {
+		values.Set("host", req.Host)
+	}
+	if req.All {
+		values.Set("all", "true")
+	}
+	for _, name := range req.Via {
+		values.Add("via", name)
+	}
+	path := fmt.Sprintf("/api/v2/bastions/%d/docker/containers", id)
+	if len(values) > 0 {
+		path += "?" + values.Encode()
+	}
+	var listing core.DockerListing
+	if err := c.Do(ctx, "GET", path, nil, &listing); err != nil {
+		return nil, err
+	}
+	return &listing, nil
+}
+
+// listFilterQuery encodes filter as the q/tag query parameters of the list endpoints.
+func listFilterQuery(filter models.ListFilter) string {
+	if filter.Empty() {
+		return ""
+	}
+	values := url.Values{}
+	if filter.Query != "" {
+		values.Set("q", filter.Query)
+	}
+	for _, tag := range filter.Tags {
+		values.Add("tag", tag)
+	}
+	return "?" + values.Encode()
+}
@@ -0,0 +1,162 @@
+// Package bastionclient drives a Bastion daemon over its /api/v2 HTTP API. It is what the bastion
+// CLI uses in client mode, and lets other tools manage bastions and mappings, read logs and follow
+// traffic without shelling out to the CLI.
+//
+//	client := bastionclient.New("http://127.0.0.1:7788")
+//	mappings, err := client.ListMappings(ctx)
+//
+// Every call takes a context. Errors reported by the server are *APIError values carrying the
+// error code, hint and request ID; transport failures are returned as is.
+package bastionclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// WorkspaceHeader selects the workspace of a request (handlers.WorkspaceHeader).
+const WorkspaceHeader = "X-Bastion-Workspace"
+
+// Client talks to one Bastion daemon. Its fields may be changed between calls, not during them.
+type Client struct {
+	// BaseURL is the daemon's address, e.g. http://127.0.0.1:7788.
+	BaseURL string
+	// HTTPClient sends the requests; streaming calls reuse its transport without its timeout.
+	HTTPClient *http.Client
+	// Workspace is sent with every request; empty means the server default.
+	Workspace string
+	// Language selects the language of messages and hints (en, zh); empty leaves it to the server.
+	Language string
+	// AdminToken authenticates clients that are not on the daemon's host (setup's admin token).
+	AdminToken string
+}
+
+// New returns a client for the daemon at baseURL with a 30s request timeout.
+func New(baseURL string) *Client {
+	return &Client{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// apiEnvelope is the canonical JSON response wrapper returned by the server APIs.
+type apiEnvelope struct {
+	Code      string          `json:"code"`
+	Message   string          `json:"message"`
+	Data      json.RawMessage `json:"data"`
+	RequestID string          `json:"request_id"`
+}
+
+// Do sends a request to path (e.g. /api/v2/stats) with body encoded as JSON (none when nil) and
+// decodes the response data into result (ignored when nil). It reaches the endpoints this package
+// has no method for.
+func (c *Client) Do(ctx context.Context, method, path string, body, result interface{}) error {
+	resp, err := c.doRequest(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	return handleResponse(resp, result)
+}
+
+// doRequest executes an HTTP request with a JSON body
+func (c *Client) doRequest(ctx context.Context, method, path string, body interface{}) (*http.Response, error) {
+	if body == nil {
+		return c.send(ctx, method, path, nil, "")
+	}
+	jsonData, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %v", err)
+	}
+	return c.send(ctx, method, path, bytes.NewBuffer(jsonData), "application/json")
+}
+
+// send executes an HTTP request with a body of contentType (none when body is nil)
+func (c *Client) send(ctx context.Context, method, path string, body io.Reader, contentType string) (*http.Response, error) {
+	req, err := c.newRequest(ctx, method, path, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	return resp, nil
+}
+
+// newRequest builds a request carrying the client's workspace, language and admin token.
+func (c *Client) newRequest(ctx context.Context, method, path string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	if c.Workspace != "" {
+		req.Header.Set(WorkspaceHeader, c.Workspace)
+	}
+	if c.Language != "" {
+		req.Header.Set("Accept-Language", c.Language)
+	}
+	if c.AdminToken != "" {
+		req.Header.Set("X-Admin-Token", c.AdminToken)
+	}
+	return req, nil
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient == nil {
+		return http.DefaultClient
+	}
+	return c.HTTPClient
+}
+
+// handleResponse reads and decodes an HTTP response
+func handleResponse(resp *http.Response, result interface{}) error {
+	defer resp.Body.Close()
+
+	bodyBytes, readErr := io.ReadAll(resp.Body)
+	if readErr != nil {
+		preview := bodyBytes
+		if len(preview) > 4096 {
+			preview = preview[:4096]
+		}
+		if len(preview) > 0 {
+			return fmt.Errorf("failed to read response body: %w (partial body: %s)", readErr, string(preview))
+		}
+		return fmt.Errorf("failed to read response body: %w", readErr)
+	}
+	return decodeResponse(resp.StatusCode, bodyBytes, result)
+}
+
+// decodeResponse decodes a response body into result, unwrapping the API envelope
+func decodeResponse(statusCode int, bodyBytes []byte, result interface{}) error {
+	var env apiEnvelope
+	isEnvelope := json.Unmarshal(bodyBytes, &env) == nil && env.Code != "" && env.Message != "" && env.Data != nil
+
+	if isEnvelope && env.Code != "OK" {
+		return newAPIError(statusCode, env)
+	}
+	if statusCode < 200 || statusCode >= 300 {
+		return &APIError{StatusCode: statusCode, Message: string(bodyBytes)}
+	}
+	if len(bodyBytes) == 0 || result == nil {
+		return nil
+	}
+
+	data := json.RawMessage(bodyBytes)
+	if isEnvelope {
+		data = env.Data
+	}
+	if err := json.Unmarshal(data, result); err != nil {
+		return fmt.Errorf("failed to decode response data: %v", err)
+	}
+	return nil
+}
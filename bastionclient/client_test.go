@@ -0,0 +1,139 @@
+package bastionclient
+
+import (
+	"bastion/core"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func writeEnvelope(w http.ResponseWriter, code, message string, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"code": code, "message": message, "data": data, "request_id": "req-1",
+	})
+}
+
+func TestDo_DecodesAPIError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get(WorkspaceHeader); got != "team" {
+			t.Errorf("workspace header = %q, want team", got)
+		}
+		if got := r.Header.Get("X-Admin-Token"); got != "secret" {
+			t.Errorf("admin token = %q, want secret", got)
+		}
+		writeEnvelope(w, CodeNotFound, "Mapping not found", map[string]interface{}{
+			"detail": "mapping web not found",
+			"error":  map[string]string{"code": "MAPPING_NOT_FOUND", "hint": "Check the ID with mapping list"},
+		})
+	}))
+	defer srv.Close()
+
+	client := New(srv.URL)
+	client.Workspace, client.AdminToken = "team", "secret"
+	_, err := client.StartMapping(context.Background(), "web")
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("err = %v, want *APIError", err)
+	}
+	if apiErr.Code != CodeNotFound || apiErr.ErrorCode != "MAPPING_NOT_FOUND" || apiErr.RequestID != "req-1" {
+		t.Fatalf("unexpected error fields: %+v", apiErr)
+	}
+	if !IsNotFound(err) || ErrorCode(err) != "MAPPING_NOT_FOUND" {
+		t.Fatalf("IsNotFound/ErrorCode do not see the API error: %v", err)
+	}
+	want := "Mapping not found (MAPPING_NOT_FOUND, NOT_FOUND, request req-1): mapping web not found\n  Hint: Check the ID with mapping list"
+	if err.Error() != want {
+		t.Fatalf("Error() = %q, want %q", err.Error(), want)
+	}
+}
+
+func TestDo_StructuredDetail(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeEnvelope(w, CodeConflict, "Port in use", map[string]interface{}{
+			"detail": map[string]interface{}{"reason": "in_use", "hints": []string{"stop the other process"}},
+			"error":  map[string]string{"code": "PORT_IN_USE"},
+		})
+	}))
+	defer srv.Close()
+
+	_, err := New(srv.URL).StartMapping(context.Background(), "web")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("err = %v, want *APIError", err)
+	}
+	var detail core.PortInUseDetail
+	if err := apiErr.DecodeDetail(&detail); err != nil || detail.Reason != "in_use" || len(detail.Hints) != 1 {
+		t.Fatalf("DecodeDetail = %+v, %v", detail, err)
+	}
+}
+
+func TestDo_NonEnvelopeError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad gateway", http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	err := New(srv.URL).HealthCheck(context.Background())
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadGateway || apiErr.Code != "" {
+		t.Fatalf("err = %#v, want a bare HTTP 502 *APIError", err)
+	}
+}
+
+func TestEachHTTPLog_WalksAllPages(t *testing.T) {
+	const total = 5
+	var pages []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("host") != "example.com" {
+			t.Errorf("filter lost: %s", r.URL.RawQuery)
+		}
+		pages = append(pages, q.Get("page"))
+		page, _ := strconv.Atoi(q.Get("page"))
+		pageSize := 2 // the server caps the page size
+		var items []core.HTTPLog
+		for id := (page-1)*pageSize + 1; id <= total && id <= page*pageSize; id++ {
+			items = append(items, core.HTTPLog{ID: id})
+		}
+		writeEnvelope(w, "OK", "success", map[string]interface{}{
+			"items": items, "page": page, "page_size": pageSize, "total": total,
+		})
+	}))
+	defer srv.Close()
+
+	var ids []int
+	err := New(srv.URL).EachHTTPLog(context.Background(), map[string][]string{"host": {"example.com"}}, func(log *core.HTTPLog) error {
+		ids = append(ids, log.ID)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("EachHTTPLog: %v", err)
+	}
+	if len(ids) != total || strings.Join(pages, ",") != "1,2,3" {
+		t.Fatalf("ids = %v over pages %v", ids, pages)
+	}
+}
+
+func TestPaginate_StopsOnCallbackError(t *testing.T) {
+	stop := errors.New("stop")
+	calls := 0
+	err := Paginate(context.Background(), 1, func(ctx context.Context, page, pageSize int) (*Page[int], error) {
+		calls++
+		return &Page[int]{Items: []int{page}, Page: page, PageSize: pageSize, Total: 10}, nil
+	}, func(item int) error {
+		if item == 3 {
+			return stop
+		}
+		return nil
+	})
+	if !errors.Is(err, stop) || calls != 3 {
+		t.Fatalf("err = %v after %d pages, want stop after 3", err, calls)
+	}
+}
@@ -0,0 +1,111 @@
+package bastionclient
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Envelope codes of failed responses (APIError.Code), as in handlers/response_v2.go
+const (
+	CodeInvalidRequest = "INVALID_REQUEST"
+	CodeUnauthorized   = "UNAUTHORIZED"
+	CodeNotFound       = "NOT_FOUND"
+	CodeConflict       = "CONFLICT"
+	CodeResourceBusy   = "RESOURCE_BUSY"
+	CodeRateLimited    = "RATE_LIMITED"
+	CodeBadGateway     = "BAD_GATEWAY"
+	CodeInternal       = "INTERNAL_ERROR"
+)
+
+// ErrStreamUnsupported means the server predates the HTTP log stream endpoint.
+var ErrStreamUnsupported = errors.New("server does not support HTTP log streaming")
+
+// APIError is a request the server refused or failed. Code is the envelope code and ErrorCode the
+// specific reason from the error catalog (GET /api/v2/error-codes), e.g. MAPPING_NOT_FOUND or
+// PORT_IN_USE; the two are equal when there is no specific reason. A response that is not an API
+// envelope (a proxy's error page, say) has only StatusCode and Message.
+type APIError struct {
+	StatusCode int
+	Code       string
+	ErrorCode  string
+	Message    string
+	Field      string // request field the error is about, when known
+	Hint       string // remediation, translated like Message
+	RequestID  string // finds the call in the server's access and error logs
+	// Detail is data.detail: a string, or structured detail such as a port conflict.
+	Detail json.RawMessage
+}
+
+func newAPIError(statusCode int, env apiEnvelope) *APIError {
+	e := &APIError{StatusCode: statusCode, Code: env.Code, ErrorCode: env.Code, Message: env.Message, RequestID: env.RequestID}
+	var d struct {
+		Detail json.RawMessage `json:"detail"`
+		Error  struct {
+			Code  string `json:"code"`
+			Field string `json:"field"`
+			Hint  string `json:"hint"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(env.Data, &d); err == nil {
+		if d.Error.Code != "" {
+			e.ErrorCode = d.Error.Code
+		}
+		e.Field, e.Hint, e.Detail = d.Error.Field, d.Error.Hint, d.Detail
+	}
+	return e
+}
+
+// DetailString is the detail as text: a string detail as is, structured detail as JSON.
+func (e *APIError) DetailString() string {
+	if len(e.Detail) == 0 || string(e.Detail) == "null" {
+		return ""
+	}
+	var s string
+	if err := json.Unmarshal(e.Detail, &s); err == nil {
+		return s
+	}
+	return string(e.Detail)
+}
+
+// DecodeDetail decodes structured detail into v (e.g. a core.PortInUseDetail of a PORT_IN_USE error).
+func (e *APIError) DecodeDetail(v interface{}) error {
+	return json.Unmarshal(e.Detail, v)
+}
+
+func (e *APIError) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("HTTP %d: %s", e.StatusCode, e.Message)
+	}
+	code, hint := e.Code, ""
+	if e.RequestID != "" {
+		code += ", request " + e.RequestID
+	}
+	// Generic codes add nothing to the envelope's; specific ones come with a remedy.
+	if e.ErrorCode != e.Code {
+		code = e.ErrorCode + ", " + code
+		if e.Hint != "" {
+			hint = "\n  Hint: " + e.Hint
+		}
+	}
+	if detail := e.DetailString(); detail != "" {
+		return fmt.Sprintf("%s (%s): %s%s", e.Message, code, detail, hint)
+	}
+	return fmt.Sprintf("%s (%s)%s", e.Message, code, hint)
+}
+
+// ErrorCode returns the specific error code of an *APIError in err's chain, "" when there is none.
+func ErrorCode(err error) string {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.ErrorCode
+	}
+	return ""
+}
+
+// IsNotFound reports whether err is a server's NOT_FOUND response (an unknown mapping, bastion,
+// log, ...).
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.Code == CodeNotFound
+}
@@ -0,0 +1,42 @@
+package bastionclient_test
+
+import (
+	"bastion/bastionclient"
+	"bastion/core"
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+func Example() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client := bastionclient.New("http://127.0.0.1:7788")
+	client.Workspace = "staging"
+
+	mappings, err := client.ListMappings(ctx)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	for _, m := range mappings {
+		if _, err := client.StartMapping(ctx, m.ID); bastionclient.ErrorCode(err) == "PORT_IN_USE" {
+			fmt.Printf("%s: port %d is taken\n", m.ID, m.LocalPort)
+		}
+	}
+}
+
+func ExampleClient_EachHTTPLog() {
+	client := bastionclient.New("http://127.0.0.1:7788")
+
+	query := url.Values{"host": {"api.example.com"}}
+	err := client.EachHTTPLog(context.Background(), query, func(log *core.HTTPLog) error {
+		fmt.Println(log.ID, log.Method, log.URL, log.StatusCode)
+		return nil
+	})
+	if err != nil {
+		fmt.Println(err)
+	}
+}
@@ -0,0 +1,192 @@
+package bastionclient
+
+import (
+	"bastion/core"
+	"bastion/models"
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// HTTPLogMatch is an HTTP log found by SearchHTTPLogs with the occurrences of the keyword in it.
+type HTTPLogMatch struct {
+	core.HTTPLog
+	Matches []core.HTTPLogMatch `json:"matches"`
+}
+
+// GetHTTPLogs fetches a page of HTTP logs, newest first, filtered by query (bastion, local_port,
+// method, host, url, q, regex; nil for none).
+func (c *Client) GetHTTPLogs(ctx context.Context, query url.Values, page, pageSize int) (*Page[*core.HTTPLog], error) {
+	return getPage[*core.HTTPLog](ctx, c, "/api/v2/http-logs", query, page, pageSize)
+}
+
+// SearchHTTPLogs is GetHTTPLogs returning up to maxMatches occurrences of query's keyword per log.
+func (c *Client) SearchHTTPLogs(ctx context.Context, query url.Values, page, pageSize, maxMatches int) (*Page[HTTPLogMatch], error) {
+	q := pageQuery(query, page, pageSize)
+	q.Set("matches", strconv.Itoa(maxMatches))
+	var result Page[HTTPLogMatch]
+	if err := c.Do(ctx, "GET", "/api/v2/http-logs?"+q.Encode(), nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// EachHTTPLog calls fn for every HTTP log matching query, newest first.
+func (c *Client) EachHTTPLog(ctx context.Context, query url.Values, fn func(*core.HTTPLog) error) error {
+	return Paginate(ctx, DefaultPageSize, func(ctx context.Context, page, pageSize int) (*Page[*core.HTTPLog], error) {
+		return c.GetHTTPLogs(ctx, query, page, pageSize)
+	}, fn)
+}
+
+// GetHTTPLog fetches a single HTTP log entry
+func (c *Client) GetHTTPLog(ctx context.Context, id int) (*core.HTTPLog, error) {
+	var log core.HTTPLog
+	if err := c.Do(ctx, "GET", fmt.Sprintf("/api/v2/http-logs/%d", id), nil, &log); err != nil {
+		return nil, err
+	}
+	return &log, nil
+}
+
+// GetHTTPLogCurl renders an HTTP log's request as a curl command
+func (c *Client) GetHTTPLogCurl(ctx context.Context, id int) (*core.HTTPLogCurl, error) {
+	var result core.HTTPLogCurl
+	if err := c.Do(ctx, "GET", fmt.Sprintf("/api/v2/http-logs/%d/curl", id), nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ClearHTTPLogs deletes all HTTP logs
+func (c *Client) ClearHTTPLogs(ctx context.Context) error {
+	return c.Do(ctx, "DELETE", "/api/v2/http-logs", nil, nil)
+}
+
+// StreamHTTPLogs follows new HTTP logs matching query until ctx is done or the server closes the
+// stream, calling onLog for each summary and onDropped when the server skipped some. A server
+// without the stream endpoint gives ErrStreamUnsupported.
+func (c *Client) StreamHTTPLogs(ctx context.Context, query url.Values, onLog func(core.HTTPLogSummary), onDropped func(uint64)) error {
+	req, err := c.newRequest(ctx, "GET", "/api/v2/http-logs/stream?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	// The stream is long-lived, so it cannot use the client's request timeout.
+	resp, err := (&http.Client{Transport: c.httpClient().Transport}).Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrStreamUnsupported
+	}
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		return handleResponse(resp, nil)
+	}
+
+	var event string
+	var data strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if err := dispatchHTTPLogEvent(event, data.String(), onLog, onDropped); err != nil {
+				return err
+			}
+			event = ""
+			data.Reset()
+		case strings.HasPrefix(line, ":"):
+			// comment (keepalive)
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	if err := scanner.Err(); err != nil && ctx.Err() == nil {
+		return fmt.Errorf("stream interrupted: %w", err)
+	}
+	return nil
+}
+
+func dispatchHTTPLogEvent(event, data string, onLog func(core.HTTPLogSummary), onDropped func(uint64)) error {
+	switch event {
+	case "http_log":
+		var summary core.HTTPLogSummary
+		if err := json.Unmarshal([]byte(data), &summary); err != nil {
+			return fmt.Errorf("failed to decode stream event: %v", err)
+		}
+		onLog(summary)
+	case "dropped":
+		var d struct {
+			Dropped uint64 `json:"dropped"`
+		}
+		if err := json.Unmarshal([]byte(data), &d); err == nil && d.Dropped > 0 && onDropped != nil {
+			onDropped(d.Dropped)
+		}
+	}
+	return nil
+}
+
+// GetErrorLogs fetches a page of error logs, newest first, filtered by query (level, min_level,
+// component, q, since, until; nil for none).
+func (c *Client) GetErrorLogs(ctx context.Context, query url.Values, page, pageSize int) (*Page[*models.ErrorLog], error) {
+	return getPage[*models.ErrorLog](ctx, c, "/api/v2/error-logs", query, page, pageSize)
+}
+
+// EachErrorLog calls fn for every error log matching query, newest first.
+func (c *Client) EachErrorLog(ctx context.Context, query url.Values, fn func(*models.ErrorLog) error) error {
+	return Paginate(ctx, DefaultPageSize, func(ctx context.Context, page, pageSize int) (*Page[*models.ErrorLog], error) {
+		return c.GetErrorLogs(ctx, query, page, pageSize)
+	}, fn)
+}
+
+// ClearErrorLogs deletes all error logs
+func (c *Client) ClearErrorLogs(ctx context.Context) error {
+	return c.Do(ctx, "DELETE", "/api/v2/error-logs", nil, nil)
+}
+
+// GetAccessLogs fetches a page of the API access log, newest first, filtered by query (method,
+// path, status, code, client_ip, request_id, errors, min_latency_ms, since, until; nil for none).
+func (c *Client) GetAccessLogs(ctx context.Context, query url.Values, page, pageSize int) (*Page[*models.AccessLog], error) {
+	return getPage[*models.AccessLog](ctx, c, "/api/v2/access-logs", query, page, pageSize)
+}
+
+// EachAccessLog calls fn for every access log entry matching query, newest first.
+func (c *Client) EachAccessLog(ctx context.Context, query url.Values, fn func(*models.AccessLog) error) error {
+	return Paginate(ctx, DefaultPageSize, func(ctx context.Context, page, pageSize int) (*Page[*models.AccessLog], error) {
+		return c.GetAccessLogs(ctx, query, page, pageSize)
+	}, fn)
+}
+
+// ClearAccessLogs deletes the API access log
+func (c *Client) ClearAccessLogs(ctx context.Context) error {
+	return c.Do(ctx, "DELETE", "/api/v2/access-logs", nil, nil)
+}
+
+// GetConfigAudit fetches a page of the workspace's configuration changes, latest first, filtered by
+// query (resource, resource_id, action, actor, since, until; nil for none).
+func (c *Client) GetConfigAudit(ctx context.Context, query url.Values, page, pageSize int) (*Page[models.ConfigAudit], error) {
+	return getPage[models.ConfigAudit](ctx, c, "/api/v2/config-audit", query, page, pageSize)
+}
+
+// EachConfigAudit calls fn for every configuration change matching query, latest first.
+func (c *Client) EachConfigAudit(ctx context.Context, query url.Values, fn func(models.ConfigAudit) error) error {
+	return Paginate(ctx, DefaultPageSize, func(ctx context.Context, page, pageSize int) (*Page[models.ConfigAudit], error) {
+		return c.GetConfigAudit(ctx, query, page, pageSize)
+	}, fn)
+}
@@ -0,0 +1,255 @@
+package bastionclient
+
+import (
+	"bastion/core"
+	"bastion/models"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// MappingVersion identifies a mapping and its version after an update or rename.
+type MappingVersion struct {
+	ID      string `json:"id"`
+	Version int    `json:"version"`
+}
+
+// UpdateOptions change how UpdateMapping treats a running mapping.
+type UpdateOptions struct {
+	// Force updates a running mapping; Restart restarts it with the new settings.
+	Force   bool
+	Restart bool
+}
+
+// ListMappings lists all mappings
+func (c *Client) ListMappings(ctx context.Context) ([]models.MappingRead, error) {
+	return c.SearchMappings(ctx, models.ListFilter{})
+}
+
+// SearchMappings lists the mappings matching filter
+func (c *Client) SearchMappings(ctx context.Context, filter models.ListFilter) ([]models.MappingRead, error) {
+	var mappings []models.MappingRead
+	if err := c.Do(ctx, "GET", "/api/v2/mappings"+listFilterQuery(filter), nil, &mappings); err != nil {
+		return nil, err
+	}
+	return mappings, nil
+}
+
+// ListMappingsWithSecrets lists all mappings with the upstream proxy passwords that list responses
+// otherwise mask, to copy them
+func (c *Client) ListMappingsWithSecrets(ctx context.Context) ([]models.MappingRead, error) {
+	var mappings []models.MappingRead
+	if err := c.Do(ctx, "GET", "/api/v2/mappings?secrets=true", nil, &mappings); err != nil {
+		return nil, err
+	}
+	return mappings, nil
+}
+
+// GetMapping fetches a single mapping
+func (c *Client) GetMapping(ctx context.Context, id string) (*models.Mapping, error) {
+	var mapping models.Mapping
+	if err := c.Do(ctx, "GET", "/api/v2/mappings/"+url.PathEscape(id), nil, &mapping); err != nil {
+		return nil, err
+	}
+	return &mapping, nil
+}
+
+// CreateMapping creates a mapping
+func (c *Client) CreateMapping(ctx context.Context, req models.MappingCreate) (*models.Mapping, error) {
+	var mapping models.Mapping
+	if err := c.Do(ctx, "POST", "/api/v2/mappings", req, &mapping); err != nil {
+		return nil, err
+	}
+	return &mapping, nil
+}
+
+// UpdateMapping replaces the settings of a mapping. A stale req.Version is a VERSION_CONFLICT.
+func (c *Client) UpdateMapping(ctx context.Context, id string, req models.MappingCreate, opts UpdateOptions) (*MappingVersion, error) {
+	query := url.Values{}
+	if opts.Force {
+		query.Set("force", "true")
+	}
+	if opts.Restart {
+		query.Set("restart", "true")
+	}
+	path := "/api/v2/mappings/" + url.PathEscape(id)
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	var result MappingVersion
+	if err := c.Do(ctx, "PUT", path, req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// RenameMapping changes a mapping's ID; a running mapping is restarted under the new ID. version
+// 0 skips the version check.
+func (c *Client) RenameMapping(ctx context.Context, id, newID string, version int) (*MappingVersion, error) {
+	body := map[string]interface{}{"id": newID, "version": version}
+	var result MappingVersion
+	if err := c.Do(ctx, "POST", "/api/v2/mappings/"+url.PathEscape(id)+"/rename", body, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// DeleteMapping deletes a mapping
+func (c *Client) DeleteMapping(ctx context.Context, id string) error {
+	return c.Do(ctx, "DELETE", "/api/v2/mappings/"+url.PathEscape(id), nil, nil)
+}
+
+// DryRunMapping checks a mapping's bastion chain (and optionally a target) without starting it
+func (c *Client) DryRunMapping(ctx context.Context, id string, dialTarget bool, target string) (*core.DryRunReport, error) {
+	body := map[string]interface{}{"dial_target": dialTarget, "target": target}
+	var report core.DryRunReport
+	if err := c.Do(ctx, "POST", "/api/v2/mappings/"+url.PathEscape(id)+"/dry-run", body, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// StartMapping starts a mapping and returns the local port it is bound to
+func (c *Client) StartMapping(ctx context.Context, id string) (int, error) {
+	var result struct {
+		LocalPort int `json:"local_port"`
+	}
+	if err := c.Do(ctx, "POST", "/api/v2/mappings/"+url.PathEscape(id)+"/start", nil, &result); err != nil {
+		return 0, err
+	}
+	return result.LocalPort, nil
+}
+
+// StopMapping stops a mapping
+func (c *Client) StopMapping(ctx context.Context, id string) error {
+	return c.Do(ctx, "POST", "/api/v2/mappings/"+url.PathEscape(id)+"/stop", nil, nil)
+}
+
+// StopAllMappings stops every running mapping in all workspaces
+func (c *Client) StopAllMappings(ctx context.Context) (*models.MappingStopAllResponse, error) {
+	var result models.MappingStopAllResponse
+	if err := c.Do(ctx, "POST", "/api/v2/mappings/stop-all", nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ExposeMapping rebinds a mapping to a non-loopback interface address
+func (c *Client) ExposeMapping(ctx context.Context, id, addr, by string, confirm bool) (*models.Mapping, error) {
+	body := map[string]interface{}{"address": addr, "confirm": confirm, "by": by}
+	var mapping models.Mapping
+	if err := c.Do(ctx, "POST", "/api/v2/mappings/"+url.PathEscape(id)+"/expose", body, &mapping); err != nil {
+		return nil, err
+	}
+	return &mapping, nil
+}
+
+// UnexposeMapping binds a mapping back to its local host
+func (c *Client) UnexposeMapping(ctx context.Context, id string) error {
+	return c.Do(ctx, "DELETE", "/api/v2/mappings/"+url.PathEscape(id)+"/expose", nil, nil)
+}
+
+// GetMappingEvents lists the latest lifecycle events of a mapping (limit <= 0: the server default).
+func (c *Client) GetMappingEvents(ctx context.Context, id string, limit int) ([]models.MappingEvent, error) {
+	path := "/api/v2/mappings/" + url.PathEscape(id) + "/events"
+	if limit > 0 {
+		path += "?limit=" + strconv.Itoa(limit)
+	}
+	var events []models.MappingEvent
+	if err := c.Do(ctx, "GET", path, nil, &events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// GetStats fetches the statistics of the running mappings, by mapping ID
+func (c *Client) GetStats(ctx context.Context) (map[string]core.SessionStats, error) {
+	var stats map[string]core.SessionStats
+	if err := c.Do(ctx, "GET", "/api/v2/stats", nil, &stats); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// ListTargetVariables lists the variables mapping target templates read with {{setting "name"}}.
+func (c *Client) ListTargetVariables(ctx context.Context) ([]models.TargetVariable, error) {
+	var vars []models.TargetVariable
+	if err := c.Do(ctx, "GET", "/api/v2/target-variables", nil, &vars); err != nil {
+		return nil, err
+	}
+	return vars, nil
+}
+
+// SetTargetVariable stores a target variable.
+func (c *Client) SetTargetVariable(ctx context.Context, name, value string) error {
+	body := map[string]string{"value": value}
+	return c.Do(ctx, "PUT", "/api/v2/target-variables/"+url.PathEscape(name), body, nil)
+}
+
+// DeleteTargetVariable removes a target variable.
+func (c *Client) DeleteTargetVariable(ctx context.Context, name string) error {
+	return c.Do(ctx, "DELETE", "/api/v2/target-variables/"+url.PathEscape(name), nil, nil)
+}
+
+// Apply reconciles the workspace with an apply document (YAML or JSON). When a change fails, the
+// result listing every change is returned along with the error.
+func (c *Client) Apply(ctx context.Context, doc []byte, prune, dryRun bool) (*models.ApplyResult, error) {
+	query := url.Values{}
+	if prune {
+		query.Set("prune", "true")
+	}
+	if dryRun {
+		query.Set("dry_run", "true")
+	}
+	path := "/api/v2/apply"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	resp, err := c.send(ctx, "POST", path, bytes.NewReader(doc), "application/yaml")
+	if err != nil {
+		return nil, err
+	}
+	return decodeApplyResult(resp)
+}
+
+// DiscoverSSH lists the ssh clients forwarding ports on the server host
+func (c *Client) DiscoverSSH(ctx context.Context) (*models.SSHDiscovery, error) {
+	var discovery models.SSHDiscovery
+	if err := c.Do(ctx, "GET", "/api/v2/discovery/ssh", nil, &discovery); err != nil {
+		return nil, err
+	}
+	return &discovery, nil
+}
+
+// ImportSSH converts discovered ssh processes into bastions and mappings, like Apply.
+func (c *Client) ImportSSH(ctx context.Context, req models.SSHImportRequest) (*models.ApplyResult, error) {
+	resp, err := c.doRequest(ctx, "POST", "/api/v2/discovery/ssh/import", req)
+	if err != nil {
+		return nil, err
+	}
+	return decodeApplyResult(resp)
+}
+
+// decodeApplyResult reads the result of an apply, which comes with the error of a failed change.
+func decodeApplyResult(resp *http.Response) (*models.ApplyResult, error) {
+	defer resp.Body.Close()
+
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	var result models.ApplyResult
+	if err := decodeResponse(resp.StatusCode, bodyBytes, &result); err != nil {
+		var env apiEnvelope
+		if json.Unmarshal(bodyBytes, &env) == nil && json.Unmarshal(env.Data, &result) == nil && result.Changes != nil {
+			return &result, err
+		}
+		return nil, err
+	}
+	return &result, nil
+}
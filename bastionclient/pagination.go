@@ -0,0 +1,69 @@
+package bastionclient
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+)
+
+// DefaultPageSize is the page size of the Each* helpers when none is given.
+const DefaultPageSize = 100
+
+// Page is one page of a paginated list (HTTP logs, error logs, access logs, config audit).
+type Page[T any] struct {
+	Items    []T `json:"items"`
+	Page     int `json:"page"`
+	PageSize int `json:"page_size"`
+	Total    int `json:"total"`
+}
+
+// HasMore reports whether pages follow this one.
+func (p *Page[T]) HasMore() bool {
+	return p.Page*p.PageSize < p.Total && len(p.Items) > 0
+}
+
+// Paginate calls fetch for page 1, 2, ... until the last page, handing every item to fn. It stops
+// at the first error of fetch or fn, or when ctx is done. Items added while paging may shift later
+// pages; the newest-first lists of the server can then repeat an item.
+func Paginate[T any](ctx context.Context, pageSize int, fetch func(ctx context.Context, page, pageSize int) (*Page[T], error), fn func(T) error) error {
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+	for page := 1; ; page++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		p, err := fetch(ctx, page, pageSize)
+		if err != nil {
+			return err
+		}
+		for _, item := range p.Items {
+			if err := fn(item); err != nil {
+				return err
+			}
+		}
+		if !p.HasMore() {
+			return nil
+		}
+	}
+}
+
+// pageQuery copies query and sets page and page_size.
+func pageQuery(query url.Values, page, pageSize int) url.Values {
+	q := url.Values{}
+	for k, v := range query {
+		q[k] = v
+	}
+	q.Set("page", strconv.Itoa(page))
+	q.Set("page_size", strconv.Itoa(pageSize))
+	return q
+}
+
+// getPage fetches one page of the list at path.
+func getPage[T any](ctx context.Context, c *Client, path string, query url.Values, page, pageSize int) (*Page[T], error) {
+	var result Page[T]
+	if err := c.Do(ctx, "GET", path+"?"+pageQuery(query, page, pageSize).Encode(), nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
@@ -0,0 +1,145 @@
+package bastionclient
+
+import (
+	"bastion/core"
+	"bastion/models"
+	"context"
+	"net/url"
+)
+
+// ErrorCodeInfo is an entry of the error catalog: an APIError.ErrorCode with the envelope code of
+// its responses, the field it is about and how to fix it.
+type ErrorCodeInfo struct {
+	Code     string `json:"code"`
+	Category string `json:"category"`
+	Field    string `json:"field,omitempty"`
+	Hint     string `json:"hint"`
+}
+
+// Health is the state reported by the health endpoint.
+type Health struct {
+	Status    string `json:"status"`
+	Timestamp int64  `json:"timestamp"`
+	Sessions  int    `json:"sessions"`
+	DBHealthy bool   `json:"db_healthy"`
+	DBBackend string `json:"db_backend"`
+	HARole    string `json:"ha_role,omitempty"` // set when the daemon is one of an active/standby pair
+}
+
+// HealthCheck pings the health endpoint
+func (c *Client) HealthCheck(ctx context.Context) error {
+	return c.Do(ctx, "GET", "/api/v2/health", nil, nil)
+}
+
+// GetHealth fetches the health endpoint; a degraded daemon gives an *APIError.
+func (c *Client) GetHealth(ctx context.Context) (*Health, error) {
+	var health Health
+	if err := c.Do(ctx, "GET", "/api/v2/health", nil, &health); err != nil {
+		return nil, err
+	}
+	return &health, nil
+}
+
+// ListWorkspaces lists workspaces with their bastion, mapping and running counts
+func (c *Client) ListWorkspaces(ctx context.Context) ([]models.WorkspaceSummary, error) {
+	var workspaces []models.WorkspaceSummary
+	if err := c.Do(ctx, "GET", "/api/v2/workspaces", nil, &workspaces); err != nil {
+		return nil, err
+	}
+	return workspaces, nil
+}
+
+// ListInterfaces lists the daemon host's network interfaces
+func (c *Client) ListInterfaces(ctx context.Context) ([]core.NetInterface, error) {
+	var ifaces []core.NetInterface
+	if err := c.Do(ctx, "GET", "/api/v2/interfaces", nil, &ifaces); err != nil {
+		return nil, err
+	}
+	return ifaces, nil
+}
+
+// ListErrorCodes fetches the error catalog, with hints in the client's language
+func (c *Client) ListErrorCodes(ctx context.Context) ([]ErrorCodeInfo, error) {
+	var codes []ErrorCodeInfo
+	if err := c.Do(ctx, "GET", "/api/v2/error-codes", nil, &codes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// PoolChains lists the pooled SSH chains with their connections and keepalive latency
+func (c *Client) PoolChains(ctx context.Context) ([]models.SSHPoolChain, error) {
+	var chains []models.SSHPoolChain
+	if err := c.Do(ctx, "GET", "/api/v2/pool/chains", nil, &chains); err != nil {
+		return nil, err
+	}
+	return chains, nil
+}
+
+// SelfCheck runs the daemon's environment self-check
+func (c *Client) SelfCheck(ctx context.Context) (*models.SelfCheckReport, error) {
+	var report models.SelfCheckReport
+	if err := c.Do(ctx, "GET", "/api/v2/selfcheck", nil, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// GetSetupStatus fetches the first-run wizard state
+func (c *Client) GetSetupStatus(ctx context.Context) (*models.SetupStatus, error) {
+	var status models.SetupStatus
+	if err := c.Do(ctx, "GET", "/api/v2/setup", nil, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// GetSetupSSHConfig lists hosts importable from the server user's ~/.ssh/config
+func (c *Client) GetSetupSSHConfig(ctx context.Context) ([]core.SSHConfigHost, error) {
+	var hosts []core.SSHConfigHost
+	if err := c.Do(ctx, "GET", "/api/v2/setup/ssh-config", nil, &hosts); err != nil {
+		return nil, err
+	}
+	return hosts, nil
+}
+
+// ApplySetupStep applies or skips a wizard step
+func (c *Client) ApplySetupStep(ctx context.Context, step string, req models.SetupStepRequest) (*models.SetupStepResult, error) {
+	var result models.SetupStepResult
+	if err := c.Do(ctx, "POST", "/api/v2/setup/steps/"+url.PathEscape(step), req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GetHAStatus fetches the daemon's state in its active/standby pair
+func (c *Client) GetHAStatus(ctx context.Context) (*models.HAStatus, error) {
+	var status models.HAStatus
+	if err := c.Do(ctx, "GET", "/api/v2/ha", nil, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// HAFailover moves the active role to the other instance of the pair, whichever one it is sent to
+func (c *Client) HAFailover(ctx context.Context) (*models.HAStatus, error) {
+	return c.haAction(ctx, "failover")
+}
+
+// HAPromote makes the daemon the active instance of its pair
+func (c *Client) HAPromote(ctx context.Context) (*models.HAStatus, error) {
+	return c.haAction(ctx, "promote")
+}
+
+// HADemote makes the daemon the standby instance of its pair, stopping its mappings
+func (c *Client) HADemote(ctx context.Context) (*models.HAStatus, error) {
+	return c.haAction(ctx, "demote")
+}
+
+func (c *Client) haAction(ctx context.Context, action string) (*models.HAStatus, error) {
+	var status models.HAStatus
+	if err := c.Do(ctx, "POST", "/api/v2/ha/"+action, nil, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
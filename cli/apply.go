@@ -0,0 +1,116 @@
+package cli
+
+import (
+	"bastion/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// applyArgs are the parsed arguments of `apply -f <file> [--prune] [--dry-run]`.
+type applyArgs struct {
+	file   string
+	prune  bool
+	dryRun bool
+}
+
+func parseApplyArgs(args []string) (applyArgs, error) {
+	var parsed applyArgs
+	for i := 0; i < len(args); i++ {
+		token := args[i]
+		switch {
+		case token == "--prune":
+			parsed.prune = true
+		case token == "--dry-run":
+			parsed.dryRun = true
+		case token == "-f" || token == "--file":
+			if i+1 >= len(args) {
+				return parsed, fmt.Errorf("%s requires a file (- reads stdin)", token)
+			}
+			i++
+			parsed.file = args[i]
+		case strings.HasPrefix(token, "--file="):
+			parsed.file = strings.TrimPrefix(token, "--file=")
+		case strings.HasPrefix(token, "-"):
+			return parsed, fmt.Errorf("unknown flag: %s", token)
+		default:
+			return parsed, fmt.Errorf("unexpected argument: %s", token)
+		}
+	}
+	if parsed.file == "" {
+		return parsed, errors.New("missing -f <file>")
+	}
+	return parsed, nil
+}
+
+// readApplyFile reads an apply document from path, or from stdin for "-".
+func readApplyFile(path string) ([]byte, error) {
+	if path == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(path)
+}
+
+var applySymbols = map[string]string{
+	models.ApplyActionCreate:    "+",
+	models.ApplyActionUpdate:    "~",
+	models.ApplyActionDelete:    "-",
+	models.ApplyActionUnchanged: "=",
+	models.ApplyActionFailed:    "✗",
+}
+
+// printApplyResult renders the changes of an apply, leaving out unchanged resources.
+func printApplyResult(file string, result *models.ApplyResult) {
+	fmt.Println()
+	title := "Apply: " + file
+	if result.DryRun {
+		title += " (dry run)"
+	}
+	PrintBanner(title)
+	fmt.Println()
+
+	for _, change := range result.Changes {
+		if change.Action == models.ApplyActionUnchanged {
+			continue
+		}
+		fmt.Printf("  %s %-8s %s\n", applySymbols[change.Action], change.Resource, change.Name)
+		for _, diff := range change.Diff {
+			fmt.Printf("      %s: %s → %s\n", diff.Field, formatApplyValue(diff.Before), formatApplyValue(diff.After))
+		}
+		if change.Error != "" {
+			fmt.Printf("      error: %s\n", change.Error)
+		}
+	}
+	if result.Created+result.Updated+result.Deleted+result.Failed == 0 {
+		fmt.Println("  No changes: the workspace matches the file.")
+	}
+
+	fmt.Println()
+	fmt.Printf("%d to create, %d to update, %d to delete, %d unchanged, %d failed\n",
+		result.Created, result.Updated, result.Deleted, result.Unchanged, result.Failed)
+	switch {
+	case result.Committed:
+		fmt.Println("✓ Applied.")
+	case result.Failed > 0:
+		fmt.Println("✗ Nothing was changed.")
+	default:
+		fmt.Println("Dry run: nothing was changed.")
+	}
+}
+
+func formatApplyValue(v interface{}) string {
+	if v == nil {
+		return "(none)"
+	}
+	if s, ok := v.(string); ok {
+		return s
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
@@ -5,10 +5,10 @@ import (
 	"bastion/core"
 	"bastion/models"
 	"bastion/service"
-	"bastion/state"
 	"bufio"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
@@ -19,24 +19,32 @@ import (
 
 // CLI command line interactive interface
 type CLI struct {
-	scanner *bufio.Scanner
-	running bool
+	scanner   *bufio.Scanner
+	running   bool
+	workspace string
+	output    string // output format of the current command (--output)
 }
 
 // NewCLI creates a new CLI instance
 func NewCLI() *CLI {
 	return &CLI{
-		scanner: bufio.NewScanner(os.Stdin),
-		running: true,
+		scanner:   bufio.NewScanner(os.Stdin),
+		running:   true,
+		workspace: models.DefaultWorkspace,
 	}
 }
 
+// services returns the services scoped to the current workspace
+func (c *CLI) services() *service.Services {
+	return service.GlobalServices.InWorkspace(c.workspace).As(service.Actor{Name: exposeActor(), Auth: "cli"})
+}
+
 // Start starts the CLI main loop
 func (c *CLI) Start() {
 	c.printWelcome()
 
 	for c.running {
-		fmt.Print("\n> ")
+		fmt.Print("\n" + workspacePrompt(c.workspace))
 		if !c.scanner.Scan() {
 			break
 		}
@@ -64,7 +72,12 @@ func (c *CLI) handleCommand(input string) {
 	}
 
 	cmd := strings.ToLower(parts[0])
-	args := parts[1:]
+	output, args, err := parseOutputFlag(parts[1:])
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	c.output = output
 
 	switch cmd {
 	case "help", "h", "?":
@@ -83,6 +96,14 @@ func (c *CLI) handleCommand(input string) {
 		c.handleStatsCommand()
 	case "http", "logs":
 		c.handleHTTPCommand(args)
+	case "workspace", "ws":
+		c.handleWorkspaceCommand(args)
+	case "interfaces", "ifaces":
+		c.handleInterfacesCommand()
+	case "expose":
+		c.handleExposeCommand(args)
+	case "unexpose":
+		c.handleUnexposeCommand(args)
 	case "clear":
 		c.clearScreen()
 	case "exit", "quit", "q":
@@ -92,6 +113,13 @@ func (c *CLI) handleCommand(input string) {
 	}
 }
 
+// printStructured prints the result of a list or show command as JSON or CSV.
+func (c *CLI) printStructured(v interface{}, columns []column, rows [][]string) {
+	if err := printStructured(c.output, v, columns, rows); err != nil {
+		fmt.Printf("Error: %v\n", err)
+	}
+}
+
 // showHelp displays help information
 func (c *CLI) showHelp() {
 	fmt.Println()
@@ -102,29 +130,45 @@ func (c *CLI) showHelp() {
 		{"help, h, ?", "Show this help message"},
 		{"", ""},
 		{"BASTION MANAGEMENT:", ""},
-		{"bastion list", "List all bastions"},
+		{"bastion list [text] [--tag <tag>]", "List bastions (filtered by text and tags)"},
 		{"bastion add", "Add a new bastion (interactive)"},
 		{"bastion delete <id>", "Delete a bastion by ID"},
 		{"bastion show <id>", "Show bastion details"},
 		{"", ""},
 		{"MAPPING MANAGEMENT:", ""},
-		{"mapping list", "List all mappings"},
+		{"mapping list [text] [--tag <tag>]", "List mappings (filtered by text and tags)"},
 		{"mapping add", "Add a new mapping (interactive)"},
 		{"mapping delete <id>", "Delete a mapping by ID"},
 		{"mapping show <id>", "Show mapping details"},
 		{"", ""},
 		{"SESSION CONTROL:", ""},
 		{"start <mapping_id>", "Start a mapping session"},
+		{"start <mapping_id> --dry-run [--target host:port]", "Check the bastion chain (and target) without starting"},
 		{"stop <mapping_id>", "Stop a mapping session"},
+		{"stop --all", "Stop every running mapping in all workspaces"},
 		{"status", "Show all sessions status"},
 		{"stats", "Show traffic statistics"},
 		{"", ""},
+		{"NETWORK EXPOSURE:", ""},
+		{"interfaces", "List network interfaces"},
+		{"expose <mapping_id> <address> --yes", "Listen on a LAN/Tailscale interface address (reachable by other machines)"},
+		{"unexpose <mapping_id>", "Listen on local_host again"},
+		{"", ""},
+		{"WORKSPACES:", ""},
+		{"workspace", "Show the current workspace"},
+		{"workspace list", "List workspaces"},
+		{"workspace use <name>", "Switch to a workspace (created on first use)"},
+		{"", ""},
 		{"HTTP AUDIT:", ""},
 		{"http list [page]", "List HTTP logs (paginated)"},
 		{"http search [keyword] [--local-port <port>] [--bastion <name>] [--url <url>] [page]", "Search HTTP logs (multi-dimensional filters)"},
 		{"http show <id>", "Show HTTP request/response details"},
+		{"http curl <id>", "Print the request as a curl command"},
 		{"http clear", "Clear all HTTP logs"},
 		{"", ""},
+		{"OUTPUT:", ""},
+		{"<command> -o, --output <format>", "List/show/status/stats output: table (default), wide (untruncated), json, csv"},
+		{"", ""},
 		{"SYSTEM:", ""},
 		{"clear", "Clear screen"},
 		{"exit, quit, q", "Exit the program"},
@@ -149,7 +193,13 @@ func (c *CLI) handleBastionCommand(args []string) {
 
 	switch args[0] {
 	case "list", "ls":
-		c.listBastions()
+		filter, err := parseListFilterArgs(args[1:])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			fmt.Println("Usage: bastion list [text] [--tag <tag>]...")
+			return
+		}
+		c.listBastions(filter)
 	case "add", "create":
 		c.addBastion()
 	case "delete", "del", "rm":
@@ -170,15 +220,24 @@ func (c *CLI) handleBastionCommand(args []string) {
 }
 
 // listBastions lists all bastions
-func (c *CLI) listBastions() {
-	bastions, err := service.GlobalServices.Bastion.List()
+func (c *CLI) listBastions(filter models.ListFilter) {
+	bastions, err := c.services().Bastion.Search(filter)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 		return
 	}
 
+	if isStructured(c.output) {
+		c.printStructured(redactBastions(bastions), bastionColumns, bastionRows(bastions))
+		return
+	}
+
 	if len(bastions) == 0 {
-		fmt.Println("No bastions configured.")
+		if !filter.Empty() {
+			fmt.Println(tr("No bastions match the filter."))
+			return
+		}
+		fmt.Println(tr("No bastions configured."))
 		return
 	}
 
@@ -186,23 +245,7 @@ func (c *CLI) listBastions() {
 	PrintBanner(fmt.Sprintf("Total Bastions: %d", len(bastions)))
 	fmt.Println()
 
-	fmt.Printf("%-4s %-20s %-20s %-6s %-15s %-10s\n", "ID", "Name", "Host", "Port", "Username", "Auth")
-	fmt.Println(strings.Repeat("-", 80))
-
-	for _, b := range bastions {
-		auth := "Password"
-		if b.PkeyPath != "" {
-			auth = "Key"
-		}
-		fmt.Printf("%-4d %-20s %-20s %-6d %-15s %-10s\n",
-			b.ID,
-			truncate(b.Name, 20),
-			truncate(b.Host, 20),
-			b.Port,
-			truncate(b.Username, 15),
-			auth,
-		)
-	}
+	_ = writeTable(os.Stdout, c.output, bastionColumns, bastionRows(bastions))
 }
 
 // addBastion adds a bastion interactively
@@ -291,13 +334,13 @@ func (c *CLI) addBastion() {
 	// Normalize and create
 	bastion.Normalize()
 
-	createdBastion, err := service.GlobalServices.Bastion.Create(bastion)
+	createdBastion, err := c.services().Bastion.Create(bastion)
 	if err != nil {
 		fmt.Printf("Error creating bastion: %v\n", err)
 		return
 	}
 
-	fmt.Printf("\n✓ Bastion created successfully! ID: %d\n", createdBastion.ID)
+	fmt.Print(trf("\n✓ Bastion created successfully! ID: %d\n", createdBastion.ID))
 }
 
 // deleteBastion removes a bastion
@@ -308,22 +351,22 @@ func (c *CLI) deleteBastion(idStr string) {
 		return
 	}
 
-	bastion, err := service.GlobalServices.Bastion.Get(uint(id))
+	bastion, err := c.services().Bastion.Get(uint(id))
 	if err != nil {
 		fmt.Printf("Bastion not found: %d\n", id)
 		return
 	}
 
 	// Check if in use - get all mappings to check
-	mappings, _ := service.GlobalServices.Mapping.List()
+	mappings, _ := c.services().Mapping.List()
 	runningMap := make(map[string]bool)
 	for _, m := range mappings {
 		if m.Running {
-			runningMap[m.ID] = true
+			runningMap[models.WorkspaceKey(m.Workspace, m.ID)] = true
 		}
 	}
 
-	inUse, runningMappings, totalMappings, err := service.GlobalServices.Bastion.CheckInUse(bastion.Name, runningMap)
+	inUse, runningMappings, totalMappings, err := c.services().Bastion.CheckInUse(bastion.Name, runningMap)
 	if err != nil {
 		fmt.Printf("Error checking bastion usage: %v\n", err)
 		return
@@ -339,16 +382,16 @@ func (c *CLI) deleteBastion(idStr string) {
 
 	confirm := c.readInput(fmt.Sprintf("Delete bastion '%s'? (yes/no)", bastion.Name), "no")
 	if strings.ToLower(confirm) != "yes" && strings.ToLower(confirm) != "y" {
-		fmt.Println("Cancelled.")
+		fmt.Println(tr("Cancelled."))
 		return
 	}
 
-	if err := service.GlobalServices.Bastion.Delete(uint(id)); err != nil {
+	if err := c.services().Bastion.Delete(uint(id)); err != nil {
 		fmt.Printf("Error deleting bastion: %v\n", err)
 		return
 	}
 
-	fmt.Println("✓ Bastion deleted successfully!")
+	fmt.Println(tr("✓ Bastion deleted successfully!"))
 }
 
 // showBastion displays bastion details
@@ -359,12 +402,18 @@ func (c *CLI) showBastion(idStr string) {
 		return
 	}
 
-	bastion, err := service.GlobalServices.Bastion.Get(uint(id))
+	bastion, err := c.services().Bastion.Get(uint(id))
 	if err != nil {
 		fmt.Printf("Bastion not found: %d\n", id)
 		return
 	}
 
+	if isStructured(c.output) {
+		redacted := redactBastions([]models.Bastion{*bastion})
+		c.printStructured(redacted[0], bastionColumns, bastionRows(redacted))
+		return
+	}
+
 	fmt.Println()
 	PrintBanner(fmt.Sprintf("Bastion Details: %s", bastion.Name))
 	fmt.Println()
@@ -380,6 +429,21 @@ func (c *CLI) showBastion(idStr string) {
 	} else {
 		fmt.Printf("Auth:       Password\n")
 	}
+	if bastion.Ciphers != "" {
+		fmt.Printf("Ciphers:    %s\n", bastion.Ciphers)
+	}
+	if bastion.MACs != "" {
+		fmt.Printf("MACs:       %s\n", bastion.MACs)
+	}
+	if bastion.KeyExchanges != "" {
+		fmt.Printf("KEX:        %s\n", bastion.KeyExchanges)
+	}
+	if tags := bastion.GetTags(); len(tags) > 0 {
+		fmt.Printf("Tags:       %s\n", strings.Join(tags, ", "))
+	}
+	if bastion.Description != "" {
+		fmt.Printf("Notes:      %s\n", bastion.Description)
+	}
 }
 
 // handleMappingCommand handles mapping-related commands
@@ -391,7 +455,13 @@ func (c *CLI) handleMappingCommand(args []string) {
 
 	switch args[0] {
 	case "list", "ls":
-		c.listMappings()
+		filter, err := parseListFilterArgs(args[1:])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			fmt.Println("Usage: mapping list [text] [--tag <tag>]...")
+			return
+		}
+		c.listMappings(filter)
 	case "add", "create":
 		c.addMapping()
 	case "delete", "del", "rm":
@@ -412,15 +482,27 @@ func (c *CLI) handleMappingCommand(args []string) {
 }
 
 // listMappings lists all mappings
-func (c *CLI) listMappings() {
-	mappingsWithStatus, err := service.GlobalServices.Mapping.List()
+func (c *CLI) listMappings(filter models.ListFilter) {
+	mappingsWithStatus, err := c.services().Mapping.Search(filter)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 		return
 	}
 
+	if isStructured(c.output) {
+		if mappingsWithStatus == nil {
+			mappingsWithStatus = []models.MappingRead{}
+		}
+		c.printStructured(mappingsWithStatus, mappingColumns, mappingRows(mappingsWithStatus))
+		return
+	}
+
 	if len(mappingsWithStatus) == 0 {
-		fmt.Println("No mappings configured.")
+		if !filter.Empty() {
+			fmt.Println(tr("No mappings match the filter."))
+			return
+		}
+		fmt.Println(tr("No mappings configured."))
 		return
 	}
 
@@ -428,26 +510,8 @@ func (c *CLI) listMappings() {
 	PrintBanner(fmt.Sprintf("Total Mappings: %d", len(mappingsWithStatus)))
 	fmt.Println()
 
-	fmt.Printf("%-20s %-18s %-18s %-8s %-25s %-10s\n", "ID", "Local", "Remote", "Type", "Chain", "Status")
-	fmt.Println(strings.Repeat("-", 110))
-
-	for _, mws := range mappingsWithStatus {
-		status := "Stopped"
-		if mws.Running {
-			status = "Running"
-		}
-
-		chain := strings.Join(mws.Chain, " → ")
-
-		fmt.Printf("%-20s %-18s %-18s %-8s %-25s %-10s\n",
-			truncate(mws.ID, 20),
-			fmt.Sprintf("%s:%d", mws.LocalHost, mws.LocalPort),
-			fmt.Sprintf("%s:%d", mws.RemoteHost, mws.RemotePort),
-			mws.Type,
-			truncate(chain, 25),
-			status,
-		)
-	}
+	_ = writeTable(os.Stdout, c.output, mappingColumns, mappingRows(mappingsWithStatus))
+	printExposedWarning(mappingsWithStatus)
 }
 
 // addMapping adds a mapping interactively
@@ -470,21 +534,21 @@ func (c *CLI) addMapping() {
 	}
 
 	// Local Port
-	localPortStr := c.readInput("Local Port (required)", "")
+	localPortStr := c.readInput("Local Port (required, 0 = auto)", "")
 	if localPortStr == "cancel" {
 		fmt.Println("\n❌ Operation cancelled")
 		return
 	}
 	for localPortStr == "" {
 		fmt.Println("Local Port is required!")
-		localPortStr = c.readInput("Local Port (required)", "")
+		localPortStr = c.readInput("Local Port (required, 0 = auto)", "")
 		if localPortStr == "cancel" {
 			fmt.Println("\n❌ Operation cancelled")
 			return
 		}
 	}
-	localPort, _ := strconv.Atoi(localPortStr)
-	if localPort == 0 {
+	localPort, ok := parseLocalPort(localPortStr)
+	if !ok {
 		fmt.Println("Invalid port number!")
 		return
 	}
@@ -545,10 +609,10 @@ func (c *CLI) addMapping() {
 	}
 
 	// Chain
-	fmt.Println("\nAvailable Bastions:")
-	bastions, _ := service.GlobalServices.Bastion.List()
+	fmt.Println(tr("\nAvailable Bastions:"))
+	bastions, _ := c.services().Bastion.List()
 	for i, b := range bastions {
-		fmt.Printf("  %d. %s (%s:%d)\n", i+1, b.Name, b.Host, b.Port)
+		fmt.Printf("  %d. %s (%s)\n", i+1, b.Name, net.JoinHostPort(b.Host, strconv.Itoa(b.Port)))
 	}
 
 	chainStr := c.readInput("Bastion chain (comma-separated names or numbers)", "")
@@ -576,41 +640,58 @@ func (c *CLI) addMapping() {
 		fmt.Println("\n❌ Operation cancelled")
 		return
 	}
-	if mapping.ID == "" {
-		mapping.ID = fmt.Sprintf("%s:%d", mapping.LocalHost, mapping.LocalPort)
+	if mapping.ID == "" && mapping.LocalPort != 0 {
+		// Auto-port mappings get a generated ID from the service
+		mapping.ID = net.JoinHostPort(mapping.LocalHost, strconv.Itoa(mapping.LocalPort))
 	}
 
 	// Normalize and create
 	mapping.Normalize()
 
-	createdMapping, err := service.GlobalServices.Mapping.Create(mapping)
+	createdMapping, err := c.services().Mapping.Create(mapping)
 	if err != nil {
 		fmt.Printf("Error creating mapping: %v\n", err)
 		return
 	}
 
-	fmt.Printf("\n✓ Mapping created successfully! ID: %s\n", createdMapping.ID)
+	fmt.Print(trf("\n✓ Mapping created successfully! ID: %s\n", createdMapping.ID))
 }
 
 // deleteMapping removes a mapping
 func (c *CLI) deleteMapping(id string) {
-	if err := service.GlobalServices.Mapping.Delete(id); err != nil {
+	if err := c.services().Mapping.Delete(id); err != nil {
 		fmt.Printf("Error deleting mapping: %v\n", err)
 		return
 	}
 
-	fmt.Println("✓ Mapping deleted successfully!")
+	fmt.Println(tr("✓ Mapping deleted successfully!"))
 }
 
 // showMapping displays mapping details
 func (c *CLI) showMapping(id string) {
-	mapping, err := service.GlobalServices.Mapping.Get(id)
+	mapping, err := c.services().Mapping.Get(id)
 	if err != nil {
 		fmt.Printf("Mapping not found: %s\n", id)
 		return
 	}
 
-	running := state.Global.SessionExists(id)
+	running := c.services().Mapping.IsRunning(id)
+
+	if isStructured(c.output) {
+		mappings, err := c.services().Mapping.List()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		for _, m := range mappings {
+			if m.ID == id {
+				c.printStructured(m, mappingColumns, mappingRows([]models.MappingRead{m}))
+				return
+			}
+		}
+		fmt.Printf("Mapping not found: %s\n", id)
+		return
+	}
 
 	fmt.Println()
 	PrintBanner(fmt.Sprintf("Mapping Details: %s", mapping.ID))
@@ -618,22 +699,54 @@ func (c *CLI) showMapping(id string) {
 
 	fmt.Printf("ID:          %s\n", mapping.ID)
 	fmt.Printf("Type:        %s\n", mapping.Type)
-	fmt.Printf("Local:       %s:%d\n", mapping.LocalHost, mapping.LocalPort)
+	fmt.Printf("Local:       %s\n", formatLocal(mapping.ListenHost(), mapping.LocalPort, c.services().Mapping.RuntimePort(id)))
+	if mapping.ExposeAddr != "" {
+		fmt.Printf("Exposed:     ⚠️  instead of %s, by %s\n", mapping.LocalHost, mapping.ExposedBy)
+	}
+	if mapping.ListenFamily != "" {
+		fmt.Printf("Listen:      %s\n", mapping.ListenFamily)
+	}
+	if mapping.FTPHelper {
+		fmt.Printf("FTP helper:  on (passive data channels forwarded)\n")
+	}
+	if mapping.TLSMode != "" {
+		fmt.Printf("TLS:         %s\n", mapping.TLSMode)
+	}
 
 	if mapping.Type == "tcp" {
-		fmt.Printf("Remote:      %s:%d\n", mapping.RemoteHost, mapping.RemotePort)
+		fmt.Printf("Remote:      %s\n", net.JoinHostPort(mapping.RemoteHost, strconv.Itoa(mapping.RemotePort)))
+	}
+	if mapping.Type == "k8s" {
+		fmt.Printf("Kubernetes:  %s port %d\n", mapping.RemoteHost, mapping.RemotePort)
+		if mapping.K8sContext != "" {
+			fmt.Printf("Context:     %s\n", mapping.K8sContext)
+		}
+		if mapping.K8sNamespace != "" {
+			fmt.Printf("Namespace:   %s\n", mapping.K8sNamespace)
+		}
 	}
 
 	chain := mapping.GetChain()
 	if len(chain) > 0 {
 		fmt.Printf("Chain:       %s\n", strings.Join(chain, " → "))
 	}
+	if deps := mapping.GetDependsOn(); len(deps) > 0 {
+		fmt.Printf("Depends on:  %s\n", strings.Join(deps, ", "))
+	}
+	if rules := mapping.GetRequestHeaders(); len(rules) > 0 {
+		fmt.Printf("Headers:     %s\n", strings.Join(rules, "; "))
+	}
+	if tags := mapping.GetTags(); len(tags) > 0 {
+		fmt.Printf("Tags:        %s\n", strings.Join(tags, ", "))
+	}
+	if mapping.Description != "" {
+		fmt.Printf("Notes:       %s\n", mapping.Description)
+	}
 
 	if running {
 		fmt.Printf("Status:      Running ✓\n")
 
-		if session, exists := state.Global.GetSession(id); exists {
-			stats := session.GetStats()
+		if stats, exists := c.services().Mapping.GetStats()[id]; exists {
 			fmt.Printf("\nStatistics:\n")
 			fmt.Printf("  Active Connections: %d\n", stats.ActiveConns)
 			fmt.Printf("  Bytes Up:           %s\n", formatBytes(stats.BytesUp))
@@ -646,100 +759,94 @@ func (c *CLI) showMapping(id string) {
 
 // handleStartCommand starts a mapping
 func (c *CLI) handleStartCommand(args []string) {
-	if len(args) == 0 {
-		fmt.Println("Usage: start <mapping_id>")
+	parsed, err := parseStartArgs(args)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		fmt.Println("Usage: start <mapping_id> [--dry-run [--target host:port]]")
 		return
 	}
+	id := parsed.id
 
-	id := args[0]
+	if parsed.dryRun {
+		mapping, err := c.services().Mapping.Get(id)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		fmt.Printf("Checking mapping %s...\n", id)
+		report, err := c.services().Mapping.DryRun(id, mapping.Type == "tcp", parsed.target)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		printDryRunReport(report)
+		return
+	}
 
 	fmt.Printf("Starting mapping %s...\n", id)
-	if err := service.GlobalServices.Mapping.Start(id); err != nil {
+	if err := c.services().Mapping.Start(id); err != nil {
 		fmt.Printf("Error starting mapping: %v\n", err)
 		return
 	}
 
 	// Get mapping details to display
-	mapping, _ := service.GlobalServices.Mapping.Get(id)
+	mapping, _ := c.services().Mapping.Get(id)
 
 	fmt.Printf("✓ Mapping started successfully!\n")
-	fmt.Printf("  Local endpoint: %s:%d\n", mapping.LocalHost, mapping.LocalPort)
+	fmt.Printf("  Local endpoint: %s\n", formatLocal(mapping.LocalHost, mapping.LocalPort, c.services().Mapping.RuntimePort(id)))
 }
 
 // handleStopCommand stops a mapping
 func (c *CLI) handleStopCommand(args []string) {
 	if len(args) == 0 {
-		fmt.Println("Usage: stop <mapping_id>")
+		fmt.Println("Usage: stop <mapping_id> | stop --all")
+		return
+	}
+	if args[0] == "--all" {
+		fmt.Println("Stopping all running mappings...")
+		printStopAllResult(c.services().Mapping.StopAll())
 		return
 	}
 
 	id := args[0]
 
 	fmt.Printf("Stopping mapping %s...\n", id)
-	if err := service.GlobalServices.Mapping.Stop(id); err != nil {
+	if err := c.services().Mapping.Stop(id); err != nil {
 		fmt.Printf("Error stopping mapping: %v\n", err)
 		return
 	}
-	fmt.Println("✓ Mapping stopped successfully!")
+	fmt.Println(tr("✓ Mapping stopped successfully!"))
 }
 
 // handleStatusCommand shows all session states
 func (c *CLI) handleStatusCommand() {
-	state.Global.RLock()
-	sessionCount := len(state.Global.Sessions)
-	sessionIDs := make([]string, 0, sessionCount)
-	for id := range state.Global.Sessions {
-		sessionIDs = append(sessionIDs, id)
+	statsMap := c.services().Mapping.GetStats()
+
+	if isStructured(c.output) {
+		c.printStructured(summarizeStats(statsMap).Sessions, sessionColumns, sessionRows(statsMap, c.output))
+		return
 	}
-	state.Global.RUnlock()
 
 	fmt.Println()
-	PrintBanner(fmt.Sprintf("Active Sessions: %d", sessionCount))
+	PrintBanner(fmt.Sprintf("Active Sessions: %d", len(statsMap)))
 	fmt.Println()
 
-	if sessionCount == 0 {
-		fmt.Println("No active sessions.")
+	if len(statsMap) == 0 {
+		fmt.Println(tr("No active sessions."))
 		return
 	}
 
-	fmt.Printf("%-20s %-15s %-15s %-15s\n", "Mapping ID", "Connections", "Bytes Up", "Bytes Down")
-	fmt.Println(strings.Repeat("-", 70))
-
-	for _, id := range sessionIDs {
-		if session, exists := state.Global.GetSession(id); exists {
-			stats := session.GetStats()
-			fmt.Printf("%-20s %-15d %-15s %-15s\n",
-				truncate(id, 20),
-				stats.ActiveConns,
-				formatBytes(stats.BytesUp),
-				formatBytes(stats.BytesDown),
-			)
-		}
-	}
+	_ = writeTable(os.Stdout, c.output, sessionColumns, sessionRows(statsMap, c.output))
 }
 
 // handleStatsCommand shows traffic statistics
 func (c *CLI) handleStatsCommand() {
-	statsMap := service.GlobalServices.Mapping.GetStats()
-
-	var totalConns int32
-	var totalUp, totalDown int64
-
-	for _, stat := range statsMap {
-		totalConns += stat.ActiveConns
-		totalUp += stat.BytesUp
-		totalDown += stat.BytesDown
+	summary := summarizeStats(c.services().Mapping.GetStats())
+	if isStructured(c.output) {
+		c.printStructured(summary, statsColumns, summary.rows())
+		return
 	}
-
-	fmt.Println()
-	PrintBanner("Traffic Statistics")
-	fmt.Println()
-
-	fmt.Printf("Active Sessions:     %d\n", len(statsMap))
-	fmt.Printf("Total Connections:   %d\n", totalConns)
-	fmt.Printf("Total Bytes Up:      %s\n", formatBytes(totalUp))
-	fmt.Printf("Total Bytes Down:    %s\n", formatBytes(totalDown))
-	fmt.Printf("Total Traffic:       %s\n", formatBytes(totalUp+totalDown))
+	printStatsSummary(summary)
 }
 
 // handleHTTPCommand handles HTTP log commands
@@ -770,6 +877,12 @@ func (c *CLI) handleHTTPCommand(args []string) {
 			return
 		}
 		c.showHTTPLog(args[1])
+	case "curl":
+		if len(args) < 2 {
+			fmt.Println("Usage: http curl <id>")
+			return
+		}
+		c.showHTTPLogCurl(args[1])
 	case "clear":
 		c.clearHTTPLogs()
 	default:
@@ -787,8 +900,13 @@ func (c *CLI) listHTTPLogs(page int) {
 	pageSize := 20
 	logs, total := service.GlobalServices.Audit.GetHTTPLogs(page, pageSize)
 
+	if isStructured(c.output) {
+		c.printStructured(newHTTPLogPage(logs, page, pageSize, total), httpLogColumns, httpLogRows(logs, c.output))
+		return
+	}
+
 	if total == 0 {
-		fmt.Println("No HTTP logs available.")
+		fmt.Println(tr("No HTTP logs available."))
 		return
 	}
 
@@ -798,23 +916,9 @@ func (c *CLI) listHTTPLogs(page int) {
 	PrintBanner(fmt.Sprintf("HTTP Logs (Page %d/%d, Total: %d)", page, totalPages, total))
 	fmt.Println()
 
-	fmt.Printf("%-6s %-6s %-8s %-25s %-35s %-10s\n", "ID", "Code", "Method", "Host", "URL", "Time")
-	fmt.Println(strings.Repeat("-", 100))
-
-	for _, log := range logs {
-		timestamp := log.Timestamp.Format("15:04:05")
-
-		fmt.Printf("%-6d %-6d %-8s %-25s %-35s %-10s\n",
-			log.ID,
-			log.StatusCode,
-			log.Method,
-			truncate(log.Host, 25),
-			truncate(log.URL, 35),
-			timestamp,
-		)
-	}
+	_ = writeTable(os.Stdout, c.output, httpLogColumns, httpLogRows(logs, c.output))
 
-	fmt.Printf("\nUse 'http show <id>' to view details\n")
+	fmt.Print(tr("\nUse 'http show <id>' to view details\n"))
 }
 
 func (c *CLI) searchHTTPLogs(values url.Values, page int) {
@@ -836,8 +940,13 @@ func (c *CLI) searchHTTPLogs(values url.Values, page int) {
 
 	pageSize := 20
 	logs, total := service.GlobalServices.Audit.QueryHTTPLogs(filter, page, pageSize)
+	if isStructured(c.output) {
+		c.printStructured(newHTTPLogPage(logs, page, pageSize, total), httpLogColumns, httpLogRows(logs, c.output))
+		return
+	}
+
 	if total == 0 {
-		fmt.Println("No HTTP logs available.")
+		fmt.Println(tr("No HTTP logs available."))
 		return
 	}
 
@@ -847,22 +956,9 @@ func (c *CLI) searchHTTPLogs(values url.Values, page int) {
 	PrintBanner(fmt.Sprintf("HTTP Logs Search (Page %d/%d, Total: %d)", page, totalPages, total))
 	fmt.Println()
 
-	fmt.Printf("%-6s %-6s %-8s %-25s %-35s %-10s\n", "ID", "Code", "Method", "Host", "URL", "Time")
-	fmt.Println(strings.Repeat("-", 100))
-
-	for _, log := range logs {
-		timestamp := log.Timestamp.Format("15:04:05")
-		fmt.Printf("%-6d %-6d %-8s %-25s %-35s %-10s\n",
-			log.ID,
-			log.StatusCode,
-			log.Method,
-			truncate(log.Host, 25),
-			truncate(log.URL, 35),
-			timestamp,
-		)
-	}
+	_ = writeTable(os.Stdout, c.output, httpLogColumns, httpLogRows(logs, c.output))
 
-	fmt.Printf("\nUse 'http show <id>' to view details\n")
+	fmt.Print(tr("\nUse 'http show <id>' to view details\n"))
 }
 
 // showHTTPLog shows HTTP log details
@@ -879,6 +975,11 @@ func (c *CLI) showHTTPLog(idStr string) {
 		return
 	}
 
+	if isStructured(c.output) {
+		c.printStructured(log, httpLogColumns, httpLogRows([]*core.HTTPLog{log}, c.output))
+		return
+	}
+
 	fmt.Println()
 	PrintBanner(fmt.Sprintf("HTTP Log #%d", log.ID))
 	fmt.Println()
@@ -890,6 +991,10 @@ func (c *CLI) showHTTPLog(idStr string) {
 	fmt.Printf("Protocol:    %s\n", log.Protocol)
 	fmt.Printf("Req Size:    %d bytes\n", log.ReqSize)
 	fmt.Printf("Resp Size:   %d bytes\n", log.RespSize)
+	fmt.Printf("Latency:     ttfb %d ms, total %d ms\n", log.TTFBMs, log.TTLBMs)
+	if log.ConnectionReused {
+		fmt.Printf("Connection:  reused (request #%d)\n", log.RequestSeq)
+	}
 
 	if log.Request != "" {
 		fmt.Printf("\nRequest:\n")
@@ -907,16 +1012,115 @@ func (c *CLI) showHTTPLog(idStr string) {
 	}
 }
 
+// showHTTPLogCurl prints a logged request as a curl command
+func (c *CLI) showHTTPLogCurl(idStr string) {
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		fmt.Printf("Invalid ID: %s\n", idStr)
+		return
+	}
+
+	result, err := service.GlobalServices.Audit.HTTPLogCurl(id)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	fmt.Println(result.Command)
+	if result.BodyTruncated {
+		fmt.Println("# Warning: the stored request body is truncated")
+	}
+}
+
 // clearHTTPLogs clears HTTP logs
 func (c *CLI) clearHTTPLogs() {
 	confirm := c.readInput("Clear all HTTP logs? (yes/no)", "no")
 	if strings.ToLower(confirm) != "yes" && strings.ToLower(confirm) != "y" {
-		fmt.Println("Cancelled.")
+		fmt.Println(tr("Cancelled."))
 		return
 	}
 
 	service.GlobalServices.Audit.ClearHTTPLogs()
-	fmt.Println("✓ HTTP logs cleared successfully!")
+	fmt.Println(tr("✓ HTTP logs cleared successfully!"))
+}
+
+// handleWorkspaceCommand shows, lists or switches workspaces
+func (c *CLI) handleWorkspaceCommand(args []string) {
+	if len(args) == 0 {
+		fmt.Printf("Current workspace: %s\n", c.workspace)
+		return
+	}
+
+	switch args[0] {
+	case "list", "ls":
+		workspaces, err := service.GlobalServices.Workspace.List()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		printWorkspaces(workspaces, c.workspace)
+	case "use", "switch":
+		if len(args) < 2 {
+			fmt.Println("Usage: workspace use <name>")
+			return
+		}
+		workspace, err := parseWorkspaceArg(args[1])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		c.workspace = workspace
+		fmt.Print(trf("✓ Switched to workspace %s\n", workspace))
+	default:
+		fmt.Println("Usage: workspace [list|use <name>]")
+	}
+}
+
+// handleInterfacesCommand lists local network interfaces
+func (c *CLI) handleInterfacesCommand() {
+	ifaces, err := core.ListInterfaces()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	printInterfaces(ifaces)
+}
+
+// handleExposeCommand rebinds a mapping to a non-loopback interface address
+func (c *CLI) handleExposeCommand(args []string) {
+	parsed, err := parseExposeArgs(args)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		fmt.Println("Usage: expose <mapping_id> <address> --yes")
+		return
+	}
+	if !parsed.confirm {
+		fmt.Printf("⚠️  %s would be reachable by every machine that can reach %s.\n", parsed.id, parsed.addr)
+		fmt.Println(tr("Re-run with --yes to confirm."))
+		return
+	}
+
+	mapping, err := c.services().Mapping.Expose(parsed.id, parsed.addr, exposeActor(), true)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	fmt.Printf("✓ Mapping %s now listens on %s\n", mapping.ID, formatLocal(mapping.ExposeAddr, mapping.LocalPort, c.services().Mapping.RuntimePort(mapping.ID)))
+}
+
+// handleUnexposeCommand binds a mapping back to its local host
+func (c *CLI) handleUnexposeCommand(args []string) {
+	if len(args) == 0 {
+		fmt.Println("Usage: unexpose <mapping_id>")
+		return
+	}
+
+	mapping, err := c.services().Mapping.Unexpose(args[0])
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	fmt.Printf("✓ Mapping %s now listens on %s\n", mapping.ID, formatLocal(mapping.LocalHost, mapping.LocalPort, c.services().Mapping.RuntimePort(mapping.ID)))
 }
 
 // clearScreen clears the console
@@ -932,6 +1136,7 @@ func (c *CLI) handleExit() {
 
 // readInput reads user input with an optional default
 func (c *CLI) readInput(prompt, defaultValue string) string {
+	prompt = tr(prompt)
 	if defaultValue != "" {
 		fmt.Printf("%s [%s]: ", prompt, defaultValue)
 	} else {
@@ -949,6 +1154,7 @@ func (c *CLI) readInput(prompt, defaultValue string) string {
 
 // readInputPassword reads a password (not echoed)
 func (c *CLI) readInputPassword(prompt string) string {
+	prompt = tr(prompt)
 	fmt.Printf("%s: ", prompt)
 	// Note: For true password hiding, use golang.org/x/term
 	// For simplicity, using regular input here
@@ -1,10 +1,14 @@
 package cli
 
 import (
+	"bastion/config"
 	"bastion/core"
 	"bastion/models"
+	"context"
 	"fmt"
+	"net"
 	"net/url"
+	"os"
 	"strconv"
 	"strings"
 
@@ -13,9 +17,11 @@ import (
 
 // CLIHttp is the CLI for HTTP client mode
 type CLIHttp struct {
-	rl      *readline.Instance
-	running bool
-	client  *Client
+	rl       *readline.Instance
+	running  bool
+	client   *Client
+	exitCode int    // status of the last command, see Exec
+	output   string // output format of the current command (--output)
 }
 
 // NewCLIHttp creates a new HTTP client CLI instance
@@ -24,15 +30,19 @@ func NewCLIHttp(serverURL string) (*CLIHttp, error) {
 	client := NewClient(serverURL)
 
 	// Test connectivity
-	if err := client.HealthCheck(); err != nil {
+	if err := client.HealthCheck(context.Background()); err != nil {
 		return nil, fmt.Errorf("cannot connect to server: %v", err)
 	}
 
-	// Create readline instance; ignore Ctrl+C
+	// Create readline instance; ignore Ctrl+C. Only commands are saved to history (see Start),
+	// not answers to interactive prompts.
 	rl, err := readline.NewEx(&readline.Config{
-		Prompt:          "> ",
-		InterruptPrompt: "^C",
-		EOFPrompt:       "exit",
+		Prompt:                 "> ",
+		InterruptPrompt:        "^C",
+		EOFPrompt:              "exit",
+		AutoComplete:           newHTTPCompleter(&completionSource{client: client}),
+		HistoryFile:            historyFilePath(config.Settings.CLIHistoryFile),
+		DisableAutoSaveHistory: true,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create readline: %v", err)
@@ -55,7 +65,7 @@ func (c *CLIHttp) Start() {
 		if err != nil {
 			if err == readline.ErrInterrupt {
 				// Ctrl+C pressed
-				fmt.Println("\n⚠ Ctrl+C detected. Please use 'exit' or 'quit' command to exit gracefully.")
+				fmt.Println(tr("\n⚠ Ctrl+C detected. Please use 'exit' or 'quit' command to exit gracefully."))
 				continue
 			}
 			// EOF or other error; exit
@@ -67,15 +77,61 @@ func (c *CLIHttp) Start() {
 			continue
 		}
 
+		_ = c.rl.SaveHistory(input)
 		c.handleCommand(input)
 	}
 }
 
+// Exit statuses of one-shot commands (see Exec)
+const (
+	ExitOK     = 0
+	ExitFailed = 1 // the command ran but failed (server error, not found, cancelled)
+	ExitUsage  = 2 // unknown command or bad arguments
+)
+
+// Exec runs a single command without the REPL and returns its exit status.
+func (c *CLIHttp) Exec(input string) int {
+	defer c.rl.Close()
+
+	input = strings.TrimSpace(input)
+	if input == "" {
+		c.usage("No command given. Type 'help' for available commands.\n")
+		return c.exitCode
+	}
+	c.exitCode = ExitOK
+	c.handleCommand(input)
+	return c.exitCode
+}
+
+// fail reports a command failure on stderr.
+func (c *CLIHttp) fail(format string, args ...interface{}) {
+	if c.exitCode == ExitOK {
+		c.exitCode = ExitFailed
+	}
+	fmt.Fprintf(os.Stderr, format, args...)
+}
+
+// printStructured prints the result of a list or show command as JSON or CSV.
+func (c *CLIHttp) printStructured(v interface{}, columns []column, rows [][]string) {
+	if err := printStructured(c.output, v, columns, rows); err != nil {
+		c.fail("Error: %v\n", err)
+	}
+}
+
+// usage reports a usage error on stderr; it takes precedence over failures.
+func (c *CLIHttp) usage(format string, args ...interface{}) {
+	c.exitCode = ExitUsage
+	fmt.Fprintf(os.Stderr, format, args...)
+}
+
 // printWelcome prints initial banner
 func (c *CLIHttp) printWelcome() {
 	PrintBanner("Bastion - CLI Mode (HTTP Client)")
-	fmt.Printf("\nConnected to: %s\n", c.client.baseURL)
-	fmt.Println("Type 'help' for available commands")
+	fmt.Printf("\nConnected to: %s\n", c.client.BaseURL)
+	if status, err := c.client.GetSetupStatus(context.Background()); err == nil && status.Needed {
+		fmt.Println(tr("First run detected: type 'setup' to configure Bastion step by step"))
+	}
+	fmt.Println(tr("Type 'help' for available commands"))
 }
 
 // handleCommand routes user commands
@@ -86,7 +142,12 @@ func (c *CLIHttp) handleCommand(input string) {
 	}
 
 	cmd := strings.ToLower(parts[0])
-	args := parts[1:]
+	output, args, err := parseOutputFlag(parts[1:])
+	if err != nil {
+		c.usage("Error: %v\n", err)
+		return
+	}
+	c.output = output
 
 	switch cmd {
 	case "help", "h", "?":
@@ -105,12 +166,26 @@ func (c *CLIHttp) handleCommand(input string) {
 		c.handleStatsCommand()
 	case "http", "logs":
 		c.handleHTTPCommand(args)
+	case "workspace", "ws":
+		c.handleWorkspaceCommand(args)
+	case "interfaces", "ifaces":
+		c.handleInterfacesCommand()
+	case "expose":
+		c.handleExposeCommand(args)
+	case "unexpose":
+		c.handleUnexposeCommand(args)
+	case "setup":
+		c.handleSetupCommand()
+	case "apply":
+		c.handleApplyCommand(args)
+	case "import-ssh":
+		c.handleImportSSHCommand(args)
 	case "clear":
 		c.clearScreen()
 	case "exit", "quit", "q":
 		c.handleExit()
 	default:
-		fmt.Printf("Unknown command: %s. Type 'help' for available commands.\n", cmd)
+		c.usage("Unknown command: %s. Type 'help' for available commands.\n", cmd)
 	}
 }
 
@@ -124,30 +199,52 @@ func (c *CLIHttp) showHelp() {
 		{"help, h, ?", "Show this help message"},
 		{"", ""},
 		{"BASTION MANAGEMENT:", ""},
-		{"bastion list", "List all bastions"},
+		{"bastion list [text] [--tag <tag>]", "List bastions (filtered by text and tags)"},
 		{"bastion add", "Add a new bastion (interactive)"},
 		{"bastion delete <id>", "Delete a bastion by ID"},
 		{"bastion show <id>", "Show bastion details"},
 		{"", ""},
 		{"MAPPING MANAGEMENT:", ""},
-		{"mapping list", "List all mappings"},
+		{"mapping list [text] [--tag <tag>]", "List mappings (filtered by text and tags)"},
 		{"mapping add", "Add a new mapping (interactive)"},
 		{"mapping delete <id>", "Delete a mapping by ID"},
 		{"mapping show <id>", "Show mapping details"},
 		{"", ""},
 		{"SESSION CONTROL:", ""},
 		{"start <mapping_id>", "Start a mapping session"},
+		{"start <mapping_id> --dry-run [--target host:port]", "Check the bastion chain (and target) without starting"},
 		{"stop <mapping_id>", "Stop a mapping session"},
+		{"stop --all", "Stop every running mapping in all workspaces"},
 		{"status", "Show all sessions status"},
 		{"stats", "Show traffic statistics"},
 		{"", ""},
+		{"NETWORK EXPOSURE:", ""},
+		{"interfaces", "List network interfaces"},
+		{"expose <mapping_id> <address> --yes", "Listen on a LAN/Tailscale interface address (reachable by other machines)"},
+		{"unexpose <mapping_id>", "Listen on local_host again"},
+		{"", ""},
+		{"WORKSPACES:", ""},
+		{"workspace", "Show the current workspace"},
+		{"workspace list", "List workspaces"},
+		{"workspace use <name>", "Switch to a workspace (created on first use)"},
+		{"", ""},
 		{"HTTP AUDIT:", ""},
 		{"http list [page]", "List HTTP logs (paginated)"},
-		{"http search [keyword] [--local-port <port>] [--bastion <name>] [--url <url>] [page]", "Search HTTP logs (multi-dimensional filters)"},
+		{"http search [keyword] [--local-port <port>] [--bastion <name>] [--url <url>] [--method <method>] [--host <host>] [--status <code>] [page]", "Search HTTP logs (multi-dimensional filters)"},
+		{"http tail [keyword] [--method <method>] [--host <host>] [--status <code>]", "Follow new HTTP logs as they are captured (Ctrl+C to stop)"},
 		{"http show <id>", "Show HTTP request/response details"},
+		{"http curl <id>", "Print the request as a curl command"},
 		{"http clear", "Clear all HTTP logs"},
 		{"", ""},
+		{"DECLARATIVE CONFIG:", ""},
+		{"apply -f <file> [--prune] [--dry-run]", "Create/update the bastions and mappings of a YAML file (- reads stdin); --prune deletes the others"},
+		{"import-ssh [--pid <pid>]... [--yes]", "Convert running ssh -L/-D processes into bastions and mappings (dry run without --yes)"},
+		{"", ""},
+		{"OUTPUT:", ""},
+		{"<command> -o, --output <format>", "List/show/status/stats output: table (default), wide (untruncated), json, csv"},
+		{"", ""},
 		{"SYSTEM:", ""},
+		{"setup", "Run the first-run setup wizard"},
 		{"clear", "Clear screen"},
 		{"exit, quit, q", "Exit the program"},
 	}
@@ -164,42 +261,57 @@ func (c *CLIHttp) showHelp() {
 // handleBastionCommand handles bastion-related commands
 func (c *CLIHttp) handleBastionCommand(args []string) {
 	if len(args) == 0 {
-		fmt.Println("Usage: bastion <list|add|delete|show> [args]")
+		c.usage("Usage: bastion <list|add|delete|show> [args]\n")
 		return
 	}
 
 	switch args[0] {
 	case "list", "ls":
-		c.listBastions()
+		filter, err := parseListFilterArgs(args[1:])
+		if err != nil {
+			c.fail("Error: %v\n", err)
+			c.usage("Usage: bastion list [text] [--tag <tag>]...\n")
+			return
+		}
+		c.listBastions(filter)
 	case "add", "create":
 		c.addBastion()
 	case "delete", "del", "rm":
 		if len(args) < 2 {
-			fmt.Println("Usage: bastion delete <id>")
+			c.usage("Usage: bastion delete <id>\n")
 			return
 		}
 		c.deleteBastion(args[1])
 	case "show", "get":
 		if len(args) < 2 {
-			fmt.Println("Usage: bastion show <id>")
+			c.usage("Usage: bastion show <id>\n")
 			return
 		}
 		c.showBastion(args[1])
 	default:
-		fmt.Printf("Unknown bastion command: %s\n", args[0])
+		c.usage("Unknown bastion command: %s\n", args[0])
 	}
 }
 
 // listBastions lists all bastions
-func (c *CLIHttp) listBastions() {
-	bastions, err := c.client.ListBastions()
+func (c *CLIHttp) listBastions(filter models.ListFilter) {
+	bastions, err := c.client.SearchBastions(context.Background(), filter)
 	if err != nil {
-		fmt.Printf("Error: %v\n", err)
+		c.fail("Error: %v\n", err)
+		return
+	}
+
+	if isStructured(c.output) {
+		c.printStructured(redactBastions(bastions), bastionColumns, bastionRows(bastions))
 		return
 	}
 
 	if len(bastions) == 0 {
-		fmt.Println("No bastions configured.")
+		if !filter.Empty() {
+			fmt.Println(tr("No bastions match the filter."))
+			return
+		}
+		fmt.Println(tr("No bastions configured."))
 		return
 	}
 
@@ -207,31 +319,15 @@ func (c *CLIHttp) listBastions() {
 	PrintBanner(fmt.Sprintf("Total Bastions: %d", len(bastions)))
 	fmt.Println()
 
-	fmt.Printf("%-4s %-20s %-20s %-6s %-15s %-10s\n", "ID", "Name", "Host", "Port", "Username", "Auth")
-	fmt.Println(strings.Repeat("-", 80))
-
-	for _, b := range bastions {
-		auth := "Password"
-		if b.PkeyPath != "" {
-			auth = "Key"
-		}
-		fmt.Printf("%-4d %-20s %-20s %-6d %-15s %-10s\n",
-			b.ID,
-			truncate(b.Name, 20),
-			truncate(b.Host, 20),
-			b.Port,
-			truncate(b.Username, 15),
-			auth,
-		)
-	}
+	_ = writeTable(os.Stdout, c.output, bastionColumns, bastionRows(bastions))
 }
 
 // addBastion adds a bastion interactively
 func (c *CLIHttp) addBastion() {
 	fmt.Println()
 	PrintBanner("Add New Bastion (Interactive)")
-	fmt.Println("\nTip: Don't worry about mistakes, you can review and modify at the confirmation step")
-	fmt.Println("     Press Ctrl+C anytime to cancel")
+	fmt.Println(tr("\nTip: Don't worry about mistakes, you can review and modify at the confirmation step"))
+	fmt.Println(tr("     Press Ctrl+C anytime to cancel"))
 
 	bastion := models.BastionCreate{}
 	var authMethod string // 1=Password, 2=SSH Key
@@ -239,7 +335,7 @@ func (c *CLIHttp) addBastion() {
 	// Step 1: Collect all inputs
 	input, cancelled := c.readInputWithCancel("Name (optional, will auto-generate if empty)", "")
 	if cancelled {
-		fmt.Println("\n❌ Operation cancelled")
+		c.fail("\n❌ Operation cancelled\n")
 		return
 	}
 	bastion.Name = input
@@ -247,21 +343,21 @@ func (c *CLIHttp) addBastion() {
 	for {
 		input, cancelled := c.readInputWithCancel("Host (required)", "")
 		if cancelled {
-			fmt.Println("\n❌ Operation cancelled")
+			c.fail("\n❌ Operation cancelled\n")
 			return
 		}
 		if !validateHost(input) {
-			fmt.Println("❌ Invalid host format! Please enter a valid IPv4 address (e.g., 192.168.1.1) or domain name (e.g., example.com).")
+			fmt.Println("❌ Invalid host format! Please enter a valid IPv4/IPv6 address (e.g., 192.168.1.1, 2001:db8::1) or domain name (e.g., example.com).")
 			continue
 		}
-		bastion.Host = input
+		bastion.Host = models.NormalizeHost(input)
 		break
 	}
 
 	for {
 		input, cancelled := c.readInputWithCancel("Port (1-65535)", "22")
 		if cancelled {
-			fmt.Println("\n❌ Operation cancelled")
+			c.fail("\n❌ Operation cancelled\n")
 			return
 		}
 		port, _ := strconv.Atoi(input)
@@ -269,7 +365,7 @@ func (c *CLIHttp) addBastion() {
 			port = 22
 		}
 		if !validatePort(port) {
-			fmt.Println("❌ Invalid port! Port must be between 1 and 65535.")
+			fmt.Println(tr("❌ Invalid port! Port must be between 1 and 65535."))
 			continue
 		}
 		bastion.Port = port
@@ -279,7 +375,7 @@ func (c *CLIHttp) addBastion() {
 	for {
 		input, cancelled := c.readInputWithCancel("Username (required)", "")
 		if cancelled {
-			fmt.Println("\n❌ Operation cancelled")
+			c.fail("\n❌ Operation cancelled\n")
 			return
 		}
 		if !validateUsername(input) {
@@ -292,7 +388,7 @@ func (c *CLIHttp) addBastion() {
 
 	input, cancelled = c.readInputWithCancel("Auth method (1=Password, 2=SSH Key)", "1")
 	if cancelled {
-		fmt.Println("\n❌ Operation cancelled")
+		c.fail("\n❌ Operation cancelled\n")
 		return
 	}
 	authMethod = input
@@ -300,21 +396,21 @@ func (c *CLIHttp) addBastion() {
 	if authMethod == "2" {
 		input, cancelled = c.readInputWithCancel("SSH Key Path", "")
 		if cancelled {
-			fmt.Println("\n❌ Operation cancelled")
+			c.fail("\n❌ Operation cancelled\n")
 			return
 		}
 		bastion.PkeyPath = input
 
 		input, cancelled = c.readInputWithCancel("Key Passphrase (optional)", "")
 		if cancelled {
-			fmt.Println("\n❌ Operation cancelled")
+			c.fail("\n❌ Operation cancelled\n")
 			return
 		}
 		bastion.PkeyPassphrase = input
 	} else {
 		input, cancelled = c.readInputPasswordWithCancel("Password")
 		if cancelled {
-			fmt.Println("\n❌ Operation cancelled")
+			c.fail("\n❌ Operation cancelled\n")
 			return
 		}
 		bastion.Password = input
@@ -337,14 +433,14 @@ func (c *CLIHttp) addBastion() {
 			fmt.Printf("5. Auth:       Password (****)\n")
 		}
 
-		fmt.Println("\nOptions:")
-		fmt.Println("  - Press Enter to confirm and create")
-		fmt.Println("  - Enter field number (1-6) to modify")
-		fmt.Println("  - Press Ctrl+C to abort")
+		fmt.Println(tr("\nOptions:"))
+		fmt.Println(tr("  - Press Enter to confirm and create"))
+		fmt.Println(tr("  - Enter field number (1-6) to modify"))
+		fmt.Println(tr("  - Press Ctrl+C to abort"))
 
 		choice, cancelled := c.readInputWithCancel("Your choice", "")
 		if cancelled {
-			fmt.Println("\n❌ Operation cancelled")
+			c.fail("\n❌ Operation cancelled\n")
 			return
 		}
 
@@ -353,12 +449,12 @@ func (c *CLIHttp) addBastion() {
 		if choice == "" {
 			// Confirm and create
 			bastion.Normalize()
-			createdBastion, err := c.client.CreateBastion(bastion)
+			createdBastion, err := c.client.CreateBastion(context.Background(), bastion)
 			if err != nil {
-				fmt.Printf("\n❌ Error creating bastion: %v\n", err)
+				c.fail("\n❌ Error creating bastion: %v\n", err)
 				return
 			}
-			fmt.Printf("\n✓ Bastion created successfully! ID: %d\n", createdBastion.ID)
+			fmt.Print(trf("\n✓ Bastion created successfully! ID: %d\n", createdBastion.ID))
 			return
 		}
 
@@ -376,10 +472,10 @@ func (c *CLIHttp) addBastion() {
 					break
 				}
 				if !validateHost(input) {
-					fmt.Println("❌ Invalid host format! Please enter a valid IPv4 address or domain name.")
+					fmt.Println(tr("❌ Invalid host format! Please enter a valid IPv4/IPv6 address or domain name."))
 					continue
 				}
-				bastion.Host = input
+				bastion.Host = models.NormalizeHost(input)
 				break
 			}
 		case "3":
@@ -390,7 +486,7 @@ func (c *CLIHttp) addBastion() {
 				}
 				port, _ := strconv.Atoi(input)
 				if !validatePort(port) {
-					fmt.Println("❌ Invalid port! Port must be between 1 and 65535.")
+					fmt.Println(tr("❌ Invalid port! Port must be between 1 and 65535."))
 					continue
 				}
 				bastion.Port = port
@@ -403,7 +499,7 @@ func (c *CLIHttp) addBastion() {
 					break
 				}
 				if !validateUsername(input) {
-					fmt.Println("❌ Invalid username!")
+					fmt.Println(tr("❌ Invalid username!"))
 					continue
 				}
 				bastion.Username = input
@@ -442,7 +538,7 @@ func (c *CLIHttp) addBastion() {
 				}
 			}
 		default:
-			fmt.Println("❌ Invalid choice. Please try again.")
+			fmt.Println(tr("❌ Invalid choice. Please try again."))
 		}
 	}
 }
@@ -451,41 +547,47 @@ func (c *CLIHttp) addBastion() {
 func (c *CLIHttp) deleteBastion(idStr string) {
 	id, err := strconv.ParseUint(idStr, 10, 32)
 	if err != nil {
-		fmt.Printf("Invalid ID: %s\n", idStr)
+		c.usage("Invalid ID: %s\n", idStr)
 		return
 	}
 
-	bastion, err := c.client.GetBastion(uint(id))
+	bastion, err := c.client.GetBastion(context.Background(), uint(id))
 	if err != nil {
-		fmt.Printf("Bastion not found: %d\n", id)
+		c.fail("Bastion not found: %d\n", id)
 		return
 	}
 
 	confirm := c.readInput(fmt.Sprintf("Delete bastion '%s'? (yes/no)", bastion.Name), "no")
 	if strings.ToLower(confirm) != "yes" && strings.ToLower(confirm) != "y" {
-		fmt.Println("Cancelled.")
+		fmt.Println(tr("Cancelled."))
 		return
 	}
 
-	if err := c.client.DeleteBastion(uint(id)); err != nil {
-		fmt.Printf("Error deleting bastion: %v\n", err)
+	if err := c.client.DeleteBastion(context.Background(), uint(id)); err != nil {
+		c.fail("Error deleting bastion: %v\n", err)
 		return
 	}
 
-	fmt.Println("✓ Bastion deleted successfully!")
+	fmt.Println(tr("✓ Bastion deleted successfully!"))
 }
 
 // showBastion prints bastion details
 func (c *CLIHttp) showBastion(idStr string) {
 	id, err := strconv.ParseUint(idStr, 10, 32)
 	if err != nil {
-		fmt.Printf("Invalid ID: %s\n", idStr)
+		c.usage("Invalid ID: %s\n", idStr)
 		return
 	}
 
-	bastion, err := c.client.GetBastion(uint(id))
+	bastion, err := c.client.GetBastion(context.Background(), uint(id))
 	if err != nil {
-		fmt.Printf("Bastion not found: %d\n", id)
+		c.fail("Bastion not found: %d\n", id)
+		return
+	}
+
+	if isStructured(c.output) {
+		redacted := redactBastions([]models.Bastion{*bastion})
+		c.printStructured(redacted[0], bastionColumns, bastionRows(redacted))
 		return
 	}
 
@@ -504,47 +606,80 @@ func (c *CLIHttp) showBastion(idStr string) {
 	} else {
 		fmt.Printf("Auth:       Password\n")
 	}
+	if bastion.Ciphers != "" {
+		fmt.Printf("Ciphers:    %s\n", bastion.Ciphers)
+	}
+	if bastion.MACs != "" {
+		fmt.Printf("MACs:       %s\n", bastion.MACs)
+	}
+	if bastion.KeyExchanges != "" {
+		fmt.Printf("KEX:        %s\n", bastion.KeyExchanges)
+	}
+	if tags := bastion.GetTags(); len(tags) > 0 {
+		fmt.Printf("Tags:       %s\n", strings.Join(tags, ", "))
+	}
+	if bastion.Description != "" {
+		fmt.Printf("Notes:      %s\n", bastion.Description)
+	}
 }
 
 // handleMappingCommand handles mapping-related commands
 func (c *CLIHttp) handleMappingCommand(args []string) {
 	if len(args) == 0 {
-		fmt.Println("Usage: mapping <list|add|delete|show> [args]")
+		c.usage("Usage: mapping <list|add|delete|show> [args]\n")
 		return
 	}
 
 	switch args[0] {
 	case "list", "ls":
-		c.listMappings()
+		filter, err := parseListFilterArgs(args[1:])
+		if err != nil {
+			c.fail("Error: %v\n", err)
+			c.usage("Usage: mapping list [text] [--tag <tag>]...\n")
+			return
+		}
+		c.listMappings(filter)
 	case "add", "create":
 		c.addMapping()
 	case "delete", "del", "rm":
 		if len(args) < 2 {
-			fmt.Println("Usage: mapping delete <id>")
+			c.usage("Usage: mapping delete <id>\n")
 			return
 		}
 		c.deleteMapping(args[1])
 	case "show", "get":
 		if len(args) < 2 {
-			fmt.Println("Usage: mapping show <id>")
+			c.usage("Usage: mapping show <id>\n")
 			return
 		}
 		c.showMapping(args[1])
 	default:
-		fmt.Printf("Unknown mapping command: %s\n", args[0])
+		c.usage("Unknown mapping command: %s\n", args[0])
 	}
 }
 
 // listMappings lists all mappings
-func (c *CLIHttp) listMappings() {
-	mappings, err := c.client.ListMappings()
+func (c *CLIHttp) listMappings(filter models.ListFilter) {
+	mappings, err := c.client.SearchMappings(context.Background(), filter)
 	if err != nil {
-		fmt.Printf("Error: %v\n", err)
+		c.fail("Error: %v\n", err)
+		return
+	}
+
+	if isStructured(c.output) {
+		if mappings == nil {
+			mappings = []models.MappingRead{}
+		}
+		c.printStructured(mappings, mappingColumns, mappingRows(mappings))
 		return
 	}
 
 	if len(mappings) == 0 {
-		fmt.Println("No mappings configured.")
+		if !filter.Empty() {
+			fmt.Println(tr("No mappings match the filter."))
+			return
+		}
+		fmt.Println(tr("No mappings configured."))
 		return
 	}
 
@@ -552,34 +687,16 @@ func (c *CLIHttp) listMappings() {
 	PrintBanner(fmt.Sprintf("Total Mappings: %d", len(mappings)))
 	fmt.Println()
 
-	fmt.Printf("%-20s %-18s %-18s %-8s %-25s %-10s\n", "ID", "Local", "Remote", "Type", "Chain", "Status")
-	fmt.Println(strings.Repeat("-", 110))
-
-	for _, m := range mappings {
-		status := "Stopped"
-		if m.Running {
-			status = "Running"
-		}
-
-		chain := strings.Join(m.Chain, " → ")
-
-		fmt.Printf("%-20s %-18s %-18s %-8s %-25s %-10s\n",
-			truncate(m.ID, 20),
-			fmt.Sprintf("%s:%d", m.LocalHost, m.LocalPort),
-			fmt.Sprintf("%s:%d", m.RemoteHost, m.RemotePort),
-			m.Type,
-			truncate(chain, 25),
-			status,
-		)
-	}
+	_ = writeTable(os.Stdout, c.output, mappingColumns, mappingRows(mappings))
+	printExposedWarning(mappings)
 }
 
 // addMapping adds a mapping interactively
 func (c *CLIHttp) addMapping() {
 	fmt.Println()
 	PrintBanner("Add New Mapping (Interactive)")
-	fmt.Println("\nTip: Don't worry about mistakes, you can review and modify at the confirmation step")
-	fmt.Println("     Press Ctrl+C anytime to cancel")
+	fmt.Println(tr("\nTip: Don't worry about mistakes, you can review and modify at the confirmation step"))
+	fmt.Println(tr("     Press Ctrl+C anytime to cancel"))
 
 	mapping := models.MappingCreate{}
 	var bastions []models.Bastion
@@ -588,33 +705,33 @@ func (c *CLIHttp) addMapping() {
 	for {
 		input, cancelled := c.readInputWithCancel("Local Host", "127.0.0.1")
 		if cancelled {
-			fmt.Println("\n❌ Operation cancelled")
+			c.fail("\n❌ Operation cancelled\n")
 			return
 		}
 		if input == "" {
 			input = "127.0.0.1"
 		}
 		if !validateHost(input) {
-			fmt.Println("❌ Invalid host format! Please enter a valid IPv4 address or domain name.")
+			fmt.Println(tr("❌ Invalid host format! Please enter a valid IPv4/IPv6 address or domain name."))
 			continue
 		}
-		mapping.LocalHost = input
+		mapping.LocalHost = models.NormalizeHost(input)
 		break
 	}
 
 	for {
-		input, cancelled := c.readInputWithCancel("Local Port (1-65535, required)", "")
+		input, cancelled := c.readInputWithCancel("Local Port (1-65535, 0 = auto, required)", "")
 		if cancelled {
-			fmt.Println("\n❌ Operation cancelled")
+			c.fail("\n❌ Operation cancelled\n")
 			return
 		}
 		if input == "" {
-			fmt.Println("❌ Local Port is required!")
+			fmt.Println(tr("❌ Local Port is required!"))
 			continue
 		}
-		localPort, _ := strconv.Atoi(input)
-		if !validatePort(localPort) {
-			fmt.Println("❌ Invalid port! Port must be between 1 and 65535.")
+		localPort, ok := parseLocalPort(input)
+		if !ok {
+			fmt.Println(tr("❌ Invalid port! Port must be between 1 and 65535, or 0 to pick one at start."))
 			continue
 		}
 		mapping.LocalPort = localPort
@@ -623,7 +740,7 @@ func (c *CLIHttp) addMapping() {
 
 	input, cancelled := c.readInputWithCancel("Type (1=TCP, 2=SOCKS5, 3=HTTP, 4=Mixed)", "1")
 	if cancelled {
-		fmt.Println("\n❌ Operation cancelled")
+		c.fail("\n❌ Operation cancelled\n")
 		return
 	}
 	switch strings.TrimSpace(input) {
@@ -641,30 +758,30 @@ func (c *CLIHttp) addMapping() {
 		for {
 			input, cancelled := c.readInputWithCancel("Remote Host (required)", "")
 			if cancelled {
-				fmt.Println("\n❌ Operation cancelled")
+				c.fail("\n❌ Operation cancelled\n")
 				return
 			}
 			if !validateHost(input) {
-				fmt.Println("❌ Invalid host format! Please enter a valid IPv4 address or domain name.")
+				fmt.Println(tr("❌ Invalid host format! Please enter a valid IPv4/IPv6 address or domain name."))
 				continue
 			}
-			mapping.RemoteHost = input
+			mapping.RemoteHost = models.NormalizeHost(input)
 			break
 		}
 
 		for {
 			input, cancelled := c.readInputWithCancel("Remote Port (1-65535, required)", "")
 			if cancelled {
-				fmt.Println("\n❌ Operation cancelled")
+				c.fail("\n❌ Operation cancelled\n")
 				return
 			}
 			if input == "" {
-				fmt.Println("❌ Remote Port is required!")
+				fmt.Println(tr("❌ Remote Port is required!"))
 				continue
 			}
 			remotePort, _ := strconv.Atoi(input)
 			if !validatePort(remotePort) {
-				fmt.Println("❌ Invalid port! Port must be between 1 and 65535.")
+				fmt.Println(tr("❌ Invalid port! Port must be between 1 and 65535."))
 				continue
 			}
 			mapping.RemotePort = remotePort
@@ -673,16 +790,16 @@ func (c *CLIHttp) addMapping() {
 	}
 
 	// Bastion chain
-	fmt.Println("\nAvailable Bastions:")
-	bastions, _ = c.client.ListBastions()
+	fmt.Println(tr("\nAvailable Bastions:"))
+	bastions, _ = c.client.ListBastions(context.Background())
 	for i, b := range bastions {
-		fmt.Printf("  %d. %s (%s:%d)\n", i+1, b.Name, b.Host, b.Port)
+		fmt.Printf("  %d. %s (%s)\n", i+1, b.Name, net.JoinHostPort(b.Host, strconv.Itoa(b.Port)))
 	}
 
 	for {
 		input, cancelled := c.readInputWithCancel("Bastion chain (comma-separated names or numbers, optional)", "")
 		if cancelled {
-			fmt.Println("\n❌ Operation cancelled")
+			c.fail("\n❌ Operation cancelled\n")
 			return
 		}
 		validChain, ok := validateBastionChain(input, bastions)
@@ -696,11 +813,12 @@ func (c *CLIHttp) addMapping() {
 
 	input, cancelled = c.readInputWithCancel("Mapping ID (optional, will auto-generate)", "")
 	if cancelled {
-		fmt.Println("\n❌ Operation cancelled")
+		c.fail("\n❌ Operation cancelled\n")
 		return
 	}
-	if input == "" {
-		input = fmt.Sprintf("%s:%d", mapping.LocalHost, mapping.LocalPort)
+	if input == "" && mapping.LocalPort != 0 {
+		// Auto-port mappings get a generated ID from the server
+		input = net.JoinHostPort(mapping.LocalHost, strconv.Itoa(mapping.LocalPort))
 	}
 	mapping.ID = input
 
@@ -709,10 +827,10 @@ func (c *CLIHttp) addMapping() {
 		fmt.Println()
 		PrintBanner("Review Your Input")
 		fmt.Printf("\n1. ID:          %s\n", mapping.ID)
-		fmt.Printf("2. Local:       %s:%d\n", mapping.LocalHost, mapping.LocalPort)
+		fmt.Printf("2. Local:       %s\n", formatLocal(mapping.LocalHost, mapping.LocalPort, 0))
 		fmt.Printf("3. Type:        %s\n", mapping.Type)
 		if mapping.Type == "tcp" {
-			fmt.Printf("4. Remote:      %s:%d\n", mapping.RemoteHost, mapping.RemotePort)
+			fmt.Printf("4. Remote:      %s\n", net.JoinHostPort(mapping.RemoteHost, strconv.Itoa(mapping.RemotePort)))
 		}
 		if len(mapping.Chain) > 0 {
 			fmt.Printf("5. Chain:       %s\n", strings.Join(mapping.Chain, " → "))
@@ -720,14 +838,14 @@ func (c *CLIHttp) addMapping() {
 			fmt.Printf("5. Chain:       (none)\n")
 		}
 
-		fmt.Println("\nOptions:")
-		fmt.Println("  - Press Enter to confirm and create")
-		fmt.Println("  - Enter field number (1-5) to modify")
-		fmt.Println("  - Press Ctrl+C to abort")
+		fmt.Println(tr("\nOptions:"))
+		fmt.Println(tr("  - Press Enter to confirm and create"))
+		fmt.Println(tr("  - Enter field number (1-5) to modify"))
+		fmt.Println(tr("  - Press Ctrl+C to abort"))
 
 		choice, cancelled := c.readInputWithCancel("Your choice", "")
 		if cancelled {
-			fmt.Println("\n❌ Operation cancelled")
+			c.fail("\n❌ Operation cancelled\n")
 			return
 		}
 
@@ -736,12 +854,12 @@ func (c *CLIHttp) addMapping() {
 		if choice == "" {
 			// Confirm and create
 			mapping.Normalize()
-			createdMapping, err := c.client.CreateMapping(mapping)
+			createdMapping, err := c.client.CreateMapping(context.Background(), mapping)
 			if err != nil {
-				fmt.Printf("\n❌ Error creating mapping: %v\n", err)
+				c.fail("\n❌ Error creating mapping: %v\n", err)
 				return
 			}
-			fmt.Printf("\n✓ Mapping created successfully! ID: %s\n", createdMapping.ID)
+			fmt.Print(trf("\n✓ Mapping created successfully! ID: %s\n", createdMapping.ID))
 			return
 		}
 
@@ -759,20 +877,20 @@ func (c *CLIHttp) addMapping() {
 					break
 				}
 				if !validateHost(host) {
-					fmt.Println("❌ Invalid host format! Please enter a valid IPv4 address or domain name.")
+					fmt.Println(tr("❌ Invalid host format! Please enter a valid IPv4/IPv6 address or domain name."))
 					continue
 				}
-				mapping.LocalHost = host
+				mapping.LocalHost = models.NormalizeHost(host)
 				break
 			}
 			for {
-				portStr, cancelled := c.readInputWithCancel("Local Port (1-65535)", strconv.Itoa(mapping.LocalPort))
+				portStr, cancelled := c.readInputWithCancel("Local Port (1-65535, 0 = auto)", strconv.Itoa(mapping.LocalPort))
 				if cancelled {
 					break
 				}
-				port, _ := strconv.Atoi(portStr)
-				if !validatePort(port) {
-					fmt.Println("❌ Invalid port! Port must be between 1 and 65535.")
+				port, ok := parseLocalPort(portStr)
+				if !ok {
+					fmt.Println(tr("❌ Invalid port! Port must be between 1 and 65535, or 0 to pick one at start."))
 					continue
 				}
 				mapping.LocalPort = port
@@ -801,10 +919,10 @@ func (c *CLIHttp) addMapping() {
 							break
 						}
 						if !validateHost(host) {
-							fmt.Println("❌ Invalid host format! Please enter a valid IPv4 address or domain name.")
+							fmt.Println(tr("❌ Invalid host format! Please enter a valid IPv4/IPv6 address or domain name."))
 							continue
 						}
-						mapping.RemoteHost = host
+						mapping.RemoteHost = models.NormalizeHost(host)
 						break
 					}
 					for {
@@ -814,7 +932,7 @@ func (c *CLIHttp) addMapping() {
 						}
 						port, _ := strconv.Atoi(portStr)
 						if !validatePort(port) {
-							fmt.Println("❌ Invalid port! Port must be between 1 and 65535.")
+							fmt.Println(tr("❌ Invalid port! Port must be between 1 and 65535."))
 							continue
 						}
 						mapping.RemotePort = port
@@ -830,10 +948,10 @@ func (c *CLIHttp) addMapping() {
 						break
 					}
 					if !validateHost(host) {
-						fmt.Println("❌ Invalid host format! Please enter a valid IPv4 address or domain name.")
+						fmt.Println(tr("❌ Invalid host format! Please enter a valid IPv4/IPv6 address or domain name."))
 						continue
 					}
-					mapping.RemoteHost = host
+					mapping.RemoteHost = models.NormalizeHost(host)
 					break
 				}
 				for {
@@ -843,7 +961,7 @@ func (c *CLIHttp) addMapping() {
 					}
 					port, _ := strconv.Atoi(portStr)
 					if !validatePort(port) {
-						fmt.Println("❌ Invalid port! Port must be between 1 and 65535.")
+						fmt.Println(tr("❌ Invalid port! Port must be between 1 and 65535."))
 						continue
 					}
 					mapping.RemotePort = port
@@ -851,10 +969,10 @@ func (c *CLIHttp) addMapping() {
 				}
 			}
 		case "5":
-			fmt.Println("\nAvailable Bastions:")
-			bastions, _ = c.client.ListBastions()
+			fmt.Println(tr("\nAvailable Bastions:"))
+			bastions, _ = c.client.ListBastions(context.Background())
 			for i, b := range bastions {
-				fmt.Printf("  %d. %s (%s:%d)\n", i+1, b.Name, b.Host, b.Port)
+				fmt.Printf("  %d. %s (%s)\n", i+1, b.Name, net.JoinHostPort(b.Host, strconv.Itoa(b.Port)))
 			}
 			currentChain := strings.Join(mapping.Chain, ",")
 			for {
@@ -871,55 +989,104 @@ func (c *CLIHttp) addMapping() {
 				break
 			}
 		default:
-			fmt.Println("❌ Invalid choice. Please try again.")
+			fmt.Println(tr("❌ Invalid choice. Please try again."))
 		}
 	}
 }
 
 // deleteMapping deletes a mapping
 func (c *CLIHttp) deleteMapping(id string) {
-	if err := c.client.DeleteMapping(id); err != nil {
-		fmt.Printf("Error deleting mapping: %v\n", err)
+	if err := c.client.DeleteMapping(context.Background(), id); err != nil {
+		c.fail("Error deleting mapping: %v\n", err)
 		return
 	}
 
-	fmt.Println("✓ Mapping deleted successfully!")
+	fmt.Println(tr("✓ Mapping deleted successfully!"))
 }
 
 // showMapping displays mapping details
 func (c *CLIHttp) showMapping(id string) {
-	mapping, err := c.client.GetMapping(id)
+	mapping, err := c.client.GetMapping(context.Background(), id)
 	if err != nil {
-		fmt.Printf("Mapping not found: %s\n", id)
+		c.fail("Mapping not found: %s\n", id)
 		return
 	}
 
 	// Get running status from list
-	mappings, _ := c.client.ListMappings()
-	running := false
-	for _, m := range mappings {
-		if m.ID == id {
-			running = m.Running
+	mappings, _ := c.client.ListMappings(context.Background())
+	var read *models.MappingRead
+	for i := range mappings {
+		if mappings[i].ID == id {
+			read = &mappings[i]
 			break
 		}
 	}
 
+	if isStructured(c.output) {
+		if read == nil {
+			c.fail("Mapping not found: %s\n", id)
+			return
+		}
+		c.printStructured(read, mappingColumns, mappingRows([]models.MappingRead{*read}))
+		return
+	}
+
+	running := false
+	runtimePort := 0
+	if read != nil {
+		running = read.Running
+		runtimePort = read.RuntimePort
+	}
+
 	fmt.Println()
 	PrintBanner(fmt.Sprintf("Mapping Details: %s", mapping.ID))
 	fmt.Println()
 
 	fmt.Printf("ID:          %s\n", mapping.ID)
 	fmt.Printf("Type:        %s\n", mapping.Type)
-	fmt.Printf("Local:       %s:%d\n", mapping.LocalHost, mapping.LocalPort)
+	fmt.Printf("Local:       %s\n", formatLocal(mapping.ListenHost(), mapping.LocalPort, runtimePort))
+	if mapping.ExposeAddr != "" {
+		fmt.Printf("Exposed:     ⚠️  instead of %s, by %s\n", mapping.LocalHost, mapping.ExposedBy)
+	}
+	if mapping.ListenFamily != "" {
+		fmt.Printf("Listen:      %s\n", mapping.ListenFamily)
+	}
+	if mapping.FTPHelper {
+		fmt.Printf("FTP helper:  on (passive data channels forwarded)\n")
+	}
+	if mapping.TLSMode != "" {
+		fmt.Printf("TLS:         %s\n", mapping.TLSMode)
+	}
 
 	if mapping.Type == "tcp" {
-		fmt.Printf("Remote:      %s:%d\n", mapping.RemoteHost, mapping.RemotePort)
+		fmt.Printf("Remote:      %s\n", net.JoinHostPort(mapping.RemoteHost, strconv.Itoa(mapping.RemotePort)))
+	}
+	if mapping.Type == "k8s" {
+		fmt.Printf("Kubernetes:  %s port %d\n", mapping.RemoteHost, mapping.RemotePort)
+		if mapping.K8sContext != "" {
+			fmt.Printf("Context:     %s\n", mapping.K8sContext)
+		}
+		if mapping.K8sNamespace != "" {
+			fmt.Printf("Namespace:   %s\n", mapping.K8sNamespace)
+		}
 	}
 
 	chain := mapping.GetChain()
 	if len(chain) > 0 {
 		fmt.Printf("Chain:       %s\n", strings.Join(chain, " → "))
 	}
+	if deps := mapping.GetDependsOn(); len(deps) > 0 {
+		fmt.Printf("Depends on:  %s\n", strings.Join(deps, ", "))
+	}
+	if rules := mapping.GetRequestHeaders(); len(rules) > 0 {
+		fmt.Printf("Headers:     %s\n", strings.Join(rules, "; "))
+	}
+	if tags := mapping.GetTags(); len(tags) > 0 {
+		fmt.Printf("Tags:        %s\n", strings.Join(tags, ", "))
+	}
+	if mapping.Description != "" {
+		fmt.Printf("Notes:       %s\n", mapping.Description)
+	}
 
 	if running {
 		fmt.Printf("Status:      Running ✓\n")
@@ -930,44 +1097,83 @@ func (c *CLIHttp) showMapping(id string) {
 
 // handleStartCommand starts a mapping
 func (c *CLIHttp) handleStartCommand(args []string) {
-	if len(args) == 0 {
-		fmt.Println("Usage: start <mapping_id>")
+	parsed, err := parseStartArgs(args)
+	if err != nil {
+		c.fail("Error: %v\n", err)
+		c.usage("Usage: start <mapping_id> [--dry-run [--target host:port]]\n")
 		return
 	}
+	id := parsed.id
 
-	id := args[0]
+	if parsed.dryRun {
+		mapping, err := c.client.GetMapping(context.Background(), id)
+		if err != nil {
+			c.fail("Error: %v\n", err)
+			return
+		}
+		fmt.Printf("Checking mapping %s...\n", id)
+		report, err := c.client.DryRunMapping(context.Background(), id, mapping.Type == "tcp", parsed.target)
+		if err != nil {
+			c.fail("Error: %v\n", err)
+			return
+		}
+		printDryRunReport(report)
+		return
+	}
 
 	fmt.Printf("Starting mapping %s...\n", id)
-	if err := c.client.StartMapping(id); err != nil {
-		fmt.Printf("Error starting mapping: %v\n", err)
+	localPort, err := c.client.StartMapping(context.Background(), id)
+	if err != nil {
+		c.fail("Error starting mapping: %v\n", err)
 		return
 	}
 
-	fmt.Println("✓ Mapping started successfully!")
+	fmt.Println(tr("✓ Mapping started successfully!"))
+	if localPort != 0 {
+		fmt.Printf("  Local port: %d\n", localPort)
+	}
 }
 
 // handleStopCommand stops a mapping
 func (c *CLIHttp) handleStopCommand(args []string) {
 	if len(args) == 0 {
-		fmt.Println("Usage: stop <mapping_id>")
+		c.usage("Usage: stop <mapping_id> | stop --all\n")
+		return
+	}
+	if args[0] == "--all" {
+		fmt.Println("Stopping all running mappings...")
+		resp, err := c.client.StopAllMappings(context.Background())
+		if err != nil {
+			c.fail("Error stopping mappings: %v\n", err)
+			return
+		}
+		printStopAllResult(resp)
+		if resp.Failed > 0 {
+			c.fail("%d mapping(s) failed to stop\n", resp.Failed)
+		}
 		return
 	}
 
 	id := args[0]
 
 	fmt.Printf("Stopping mapping %s...\n", id)
-	if err := c.client.StopMapping(id); err != nil {
-		fmt.Printf("Error stopping mapping: %v\n", err)
+	if err := c.client.StopMapping(context.Background(), id); err != nil {
+		c.fail("Error stopping mapping: %v\n", err)
 		return
 	}
-	fmt.Println("✓ Mapping stopped successfully!")
+	fmt.Println(tr("✓ Mapping stopped successfully!"))
 }
 
 // handleStatusCommand shows all session states
 func (c *CLIHttp) handleStatusCommand() {
-	stats, err := c.client.GetStats()
+	stats, err := c.client.GetStats(context.Background())
 	if err != nil {
-		fmt.Printf("Error: %v\n", err)
+		c.fail("Error: %v\n", err)
+		return
+	}
+
+	if isStructured(c.output) {
+		c.printStructured(summarizeStats(stats).Sessions, sessionColumns, sessionRows(stats, c.output))
 		return
 	}
 
@@ -976,49 +1182,27 @@ func (c *CLIHttp) handleStatusCommand() {
 	fmt.Println()
 
 	if len(stats) == 0 {
-		fmt.Println("No active sessions.")
+		fmt.Println(tr("No active sessions."))
 		return
 	}
 
-	fmt.Printf("%-20s %-15s %-15s %-15s\n", "Mapping ID", "Connections", "Bytes Up", "Bytes Down")
-	fmt.Println(strings.Repeat("-", 70))
-
-	for id, stat := range stats {
-		fmt.Printf("%-20s %-15d %-15s %-15s\n",
-			truncate(id, 20),
-			stat.ActiveConns,
-			formatBytes(stat.BytesUp),
-			formatBytes(stat.BytesDown),
-		)
-	}
+	_ = writeTable(os.Stdout, c.output, sessionColumns, sessionRows(stats, c.output))
 }
 
 // handleStatsCommand shows traffic statistics
 func (c *CLIHttp) handleStatsCommand() {
-	statsMap, err := c.client.GetStats()
+	statsMap, err := c.client.GetStats(context.Background())
 	if err != nil {
-		fmt.Printf("Error: %v\n", err)
+		c.fail("Error: %v\n", err)
 		return
 	}
 
-	var totalConns int32
-	var totalUp, totalDown int64
-
-	for _, stat := range statsMap {
-		totalConns += stat.ActiveConns
-		totalUp += stat.BytesUp
-		totalDown += stat.BytesDown
+	summary := summarizeStats(statsMap)
+	if isStructured(c.output) {
+		c.printStructured(summary, statsColumns, summary.rows())
+		return
 	}
-
-	fmt.Println()
-	PrintBanner("Traffic Statistics")
-	fmt.Println()
-
-	fmt.Printf("Active Sessions:     %d\n", len(statsMap))
-	fmt.Printf("Total Connections:   %d\n", totalConns)
-	fmt.Printf("Total Bytes Up:      %s\n", formatBytes(totalUp))
-	fmt.Printf("Total Bytes Down:    %s\n", formatBytes(totalDown))
-	fmt.Printf("Total Traffic:       %s\n", formatBytes(totalUp+totalDown))
+	printStatsSummary(summary)
 }
 
 // handleHTTPCommand routes HTTP log commands
@@ -1039,34 +1223,53 @@ func (c *CLIHttp) handleHTTPCommand(args []string) {
 	case "search", "find":
 		page, values, err := parseHTTPLogSearchArgs(args[1:])
 		if err != nil {
-			fmt.Println("Usage: http search [keyword] [--local-port <port>] [--bastion <name>] [--url <url>] [page]")
+			c.usage("Usage: http search [keyword] [--local-port <port>] [--bastion <name>] [--url <url>] [--method <method>] [--host <host>] [--status <code>] [--header <name>[:<value>]] [page]\n")
 			return
 		}
 		c.searchHTTPLogs(values, page)
+	case "tail", "follow":
+		values, err := parseHTTPLogFilterArgs(args[1:])
+		if err != nil {
+			c.usage("Usage: http tail [keyword] [--method <method>] [--host <host>] [--status <code>] [--url <url>] [--local-port <port>] [--bastion <name>] [--header <name>[:<value>]]\n")
+			return
+		}
+		c.tailHTTPLogs(values)
 	case "show", "get":
 		if len(args) < 2 {
-			fmt.Println("Usage: http show <id>")
+			c.usage("Usage: http show <id>\n")
 			return
 		}
 		c.showHTTPLog(args[1])
+	case "curl":
+		if len(args) < 2 {
+			c.usage("Usage: http curl <id>\n")
+			return
+		}
+		c.showHTTPLogCurl(args[1])
 	case "clear":
 		c.clearHTTPLogs()
 	default:
-		fmt.Printf("Unknown http command: %s\n", args[0])
+		c.usage("Unknown http command: %s\n", args[0])
 	}
 }
 
 // listHTTPLogs lists HTTP logs
 func (c *CLIHttp) listHTTPLogs(page int) {
 	pageSize := 20
-	logs, total, err := c.client.GetHTTPLogs(page, pageSize)
+	result, err := c.client.GetHTTPLogs(context.Background(), nil, page, pageSize)
 	if err != nil {
-		fmt.Printf("Error: %v\n", err)
+		c.fail("Error: %v\n", err)
+		return
+	}
+	logs, total := result.Items, result.Total
+
+	if isStructured(c.output) {
+		c.printStructured(newHTTPLogPage(logs, page, pageSize, total), httpLogColumns, httpLogRows(logs, c.output))
 		return
 	}
 
 	if total == 0 {
-		fmt.Println("No HTTP logs available.")
+		fmt.Println(tr("No HTTP logs available."))
 		return
 	}
 
@@ -1076,36 +1279,37 @@ func (c *CLIHttp) listHTTPLogs(page int) {
 	PrintBanner(fmt.Sprintf("HTTP Logs (Page %d/%d, Total: %d)", page, totalPages, total))
 	fmt.Println()
 
-	fmt.Printf("%-6s %-6s %-8s %-25s %-35s %-10s\n", "ID", "Code", "Method", "Host", "URL", "Time")
-	fmt.Println(strings.Repeat("-", 100))
-
-	for _, log := range logs {
-		timestamp := log.Timestamp.Format("15:04:05")
+	_ = writeTable(os.Stdout, c.output, httpLogColumns, httpLogRows(logs, c.output))
 
-		fmt.Printf("%-6d %-6d %-8s %-25s %-35s %-10s\n",
-			log.ID,
-			log.StatusCode,
-			log.Method,
-			truncate(log.Host, 25),
-			truncate(log.URL, 35),
-			timestamp,
-		)
-	}
-
-	fmt.Printf("\nUse 'http show <id>' to view details\n")
+	fmt.Print(tr("\nUse 'http show <id>' to view details\n"))
 }
 
 func (c *CLIHttp) searchHTTPLogs(values url.Values, page int) {
 	pageSize := 20
 
-	logs, total, err := c.client.GetHTTPLogsFiltered(page, pageSize, values)
+	result, err := c.client.SearchHTTPLogs(context.Background(), values, page, pageSize, 2)
 	if err != nil {
-		fmt.Printf("Error: %v\n", err)
+		c.fail("Error: %v\n", err)
+		return
+	}
+	total := result.Total
+	logs := make([]*core.HTTPLog, 0, len(result.Items))
+	matches := make(map[int][]core.HTTPLogMatch)
+	for i := range result.Items {
+		item := &result.Items[i]
+		logs = append(logs, &item.HTTPLog)
+		if len(item.Matches) > 0 {
+			matches[item.ID] = item.Matches
+		}
+	}
+
+	if isStructured(c.output) {
+		c.printStructured(newHTTPLogPage(logs, page, pageSize, total), httpLogColumns, httpLogRows(logs, c.output))
 		return
 	}
 
 	if total == 0 {
-		fmt.Println("No HTTP logs available.")
+		fmt.Println(tr("No HTTP logs available."))
 		return
 	}
 
@@ -1115,36 +1319,28 @@ func (c *CLIHttp) searchHTTPLogs(values url.Values, page int) {
 	PrintBanner(fmt.Sprintf("HTTP Logs Search (Page %d/%d, Total: %d)", page, totalPages, total))
 	fmt.Println()
 
-	fmt.Printf("%-6s %-6s %-8s %-25s %-35s %-10s\n", "ID", "Code", "Method", "Host", "URL", "Time")
-	fmt.Println(strings.Repeat("-", 100))
-
-	for _, log := range logs {
-		timestamp := log.Timestamp.Format("15:04:05")
+	_ = writeTable(os.Stdout, c.output, httpLogColumns, httpLogRows(logs, c.output))
+	printHTTPLogMatches(os.Stdout, logs, matches, useColor(os.Stdout))
 
-		fmt.Printf("%-6d %-6d %-8s %-25s %-35s %-10s\n",
-			log.ID,
-			log.StatusCode,
-			log.Method,
-			truncate(log.Host, 25),
-			truncate(log.URL, 35),
-			timestamp,
-		)
-	}
-
-	fmt.Printf("\nUse 'http show <id>' to view details\n")
+	fmt.Print(tr("\nUse 'http show <id>' to view details\n"))
 }
 
 // showHTTPLog shows HTTP log details
 func (c *CLIHttp) showHTTPLog(idStr string) {
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		fmt.Printf("Invalid ID: %s\n", idStr)
+		c.usage("Invalid ID: %s\n", idStr)
 		return
 	}
 
-	log, err := c.client.GetHTTPLogByID(id)
+	log, err := c.client.GetHTTPLog(context.Background(), id)
 	if err != nil {
-		fmt.Printf("HTTP log not found: %d\n", id)
+		c.fail("HTTP log not found: %d\n", id)
+		return
+	}
+
+	if isStructured(c.output) {
+		c.printStructured(log, httpLogColumns, httpLogRows([]*core.HTTPLog{log}, c.output))
 		return
 	}
 
@@ -1159,6 +1355,10 @@ func (c *CLIHttp) showHTTPLog(idStr string) {
 	fmt.Printf("Protocol:    %s\n", log.Protocol)
 	fmt.Printf("Req Size:    %d bytes\n", log.ReqSize)
 	fmt.Printf("Resp Size:   %d bytes\n", log.RespSize)
+	fmt.Printf("Latency:     ttfb %d ms, total %d ms\n", log.TTFBMs, log.TTLBMs)
+	if log.ConnectionReused {
+		fmt.Printf("Connection:  reused (request #%d)\n", log.RequestSeq)
+	}
 
 	if log.Request != "" {
 		fmt.Printf("\nRequest:\n")
@@ -1176,19 +1376,205 @@ func (c *CLIHttp) showHTTPLog(idStr string) {
 	}
 }
 
+// showHTTPLogCurl prints a logged request as a curl command
+func (c *CLIHttp) showHTTPLogCurl(idStr string) {
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		c.usage("Invalid ID: %s\n", idStr)
+		return
+	}
+
+	result, err := c.client.GetHTTPLogCurl(context.Background(), id)
+	if err != nil {
+		c.fail("Error: %v\n", err)
+		return
+	}
+
+	fmt.Println(result.Command)
+	if result.BodyTruncated {
+		fmt.Println("# Warning: the stored request body is truncated")
+	}
+}
+
 // clearHTTPLogs clears HTTP logs
 func (c *CLIHttp) clearHTTPLogs() {
 	confirm := c.readInput("Clear all HTTP logs? (yes/no)", "no")
 	if strings.ToLower(confirm) != "yes" && strings.ToLower(confirm) != "y" {
-		fmt.Println("Cancelled.")
+		fmt.Println(tr("Cancelled."))
 		return
 	}
 
-	if err := c.client.ClearHTTPLogs(); err != nil {
-		fmt.Printf("Error clearing logs: %v\n", err)
+	if err := c.client.ClearHTTPLogs(context.Background()); err != nil {
+		c.fail("Error clearing logs: %v\n", err)
 		return
 	}
-	fmt.Println("✓ HTTP logs cleared successfully!")
+	fmt.Println(tr("✓ HTTP logs cleared successfully!"))
+}
+
+// handleInterfacesCommand lists the daemon host's network interfaces
+func (c *CLIHttp) handleInterfacesCommand() {
+	ifaces, err := c.client.ListInterfaces(context.Background())
+	if err != nil {
+		c.fail("Error: %v\n", err)
+		return
+	}
+	printInterfaces(ifaces)
+}
+
+// handleExposeCommand rebinds a mapping to a non-loopback interface address
+func (c *CLIHttp) handleExposeCommand(args []string) {
+	parsed, err := parseExposeArgs(args)
+	if err != nil {
+		c.fail("Error: %v\n", err)
+		c.usage("Usage: expose <mapping_id> <address> --yes\n")
+		return
+	}
+	if !parsed.confirm {
+		fmt.Printf("⚠️  %s would be reachable by every machine that can reach %s.\n", parsed.id, parsed.addr)
+		fmt.Println(tr("Re-run with --yes to confirm."))
+		return
+	}
+
+	mapping, err := c.client.ExposeMapping(context.Background(), parsed.id, parsed.addr, exposeActor(), true)
+	if err != nil {
+		c.fail("Error: %v\n", err)
+		return
+	}
+	fmt.Printf("✓ Mapping %s now listens on %s\n", mapping.ID, mapping.ExposeAddr)
+}
+
+// handleApplyCommand reconciles the workspace with a YAML file
+func (c *CLIHttp) handleApplyCommand(args []string) {
+	parsed, err := parseApplyArgs(args)
+	if err != nil {
+		c.fail("Error: %v\n", err)
+		c.usage("Usage: apply -f <file> [--prune] [--dry-run]\n")
+		return
+	}
+	doc, err := readApplyFile(parsed.file)
+	if err != nil {
+		c.fail("Error: %v\n", err)
+		return
+	}
+
+	result, err := c.client.Apply(context.Background(), doc, parsed.prune, parsed.dryRun)
+	if result != nil {
+		if isStructured(c.output) {
+			rows := make([][]string, 0, len(result.Changes))
+			for _, change := range result.Changes {
+				rows = append(rows, []string{change.Resource, change.Name, change.Action, change.Error})
+			}
+			c.printStructured(result, []column{{title: "Resource"}, {title: "Name"}, {title: "Action"}, {title: "Error"}}, rows)
+		} else {
+			printApplyResult(parsed.file, result)
+		}
+	}
+	if err != nil {
+		c.fail("Error: %v\n", err)
+	}
+}
+
+// handleImportSSHCommand converts running ssh port forwards into bastions and mappings
+func (c *CLIHttp) handleImportSSHCommand(args []string) {
+	parsed, err := parseImportSSHArgs(args)
+	if err != nil {
+		c.fail("Error: %v\n", err)
+		c.usage("Usage: import-ssh [--pid <pid>]... [--yes]\n")
+		return
+	}
+	discovery, err := c.client.DiscoverSSH(context.Background())
+	if err != nil {
+		c.fail("Error: %v\n", err)
+		return
+	}
+	if isStructured(c.output) && !parsed.confirm {
+		rows := make([][]string, 0, len(discovery.Processes))
+		for _, p := range discovery.Processes {
+			ids := make([]string, 0, len(p.Mappings))
+			for _, m := range p.Mappings {
+				ids = append(ids, m.ID)
+			}
+			rows = append(rows, []string{strconv.Itoa(p.PID), sshEndpointString(p.Destination), strings.Join(ids, " "), strings.Join(p.Notes, "; ")})
+		}
+		c.printStructured(discovery, []column{{title: "PID"}, {title: "Destination"}, {title: "Mappings"}, {title: "Notes"}}, rows)
+		return
+	}
+	if !isStructured(c.output) {
+		printSSHDiscovery(discovery)
+		if len(discovery.Processes) == 0 {
+			return
+		}
+	}
+
+	result, err := c.client.ImportSSH(context.Background(), models.SSHImportRequest{PIDs: parsed.pids, DryRun: !parsed.confirm})
+	if result != nil {
+		if isStructured(c.output) {
+			rows := make([][]string, 0, len(result.Changes))
+			for _, change := range result.Changes {
+				rows = append(rows, []string{change.Resource, change.Name, change.Action, change.Error})
+			}
+			c.printStructured(result, []column{{title: "Resource"}, {title: "Name"}, {title: "Action"}, {title: "Error"}}, rows)
+		} else {
+			printApplyResult("ssh processes", result)
+		}
+	}
+	if err != nil {
+		c.fail("Error: %v\n", err)
+		return
+	}
+	if !parsed.confirm {
+		fmt.Println("Re-run with --yes to import.")
+	} else if !isStructured(c.output) {
+		fmt.Println("Stop the ssh processes before starting the new mappings: they still hold the local ports.")
+	}
+}
+
+// handleUnexposeCommand binds a mapping back to its local host
+func (c *CLIHttp) handleUnexposeCommand(args []string) {
+	if len(args) == 0 {
+		c.usage("Usage: unexpose <mapping_id>\n")
+		return
+	}
+
+	if err := c.client.UnexposeMapping(context.Background(), args[0]); err != nil {
+		c.fail("Error: %v\n", err)
+		return
+	}
+	fmt.Printf("✓ Mapping %s listens on its local host again\n", args[0])
+}
+
+// handleWorkspaceCommand shows, lists or switches workspaces
+func (c *CLIHttp) handleWorkspaceCommand(args []string) {
+	current := models.NormalizeWorkspace(c.client.Workspace)
+	if len(args) == 0 {
+		fmt.Printf("Current workspace: %s\n", current)
+		return
+	}
+
+	switch args[0] {
+	case "list", "ls":
+		workspaces, err := c.client.ListWorkspaces(context.Background())
+		if err != nil {
+			c.fail("Error: %v\n", err)
+			return
+		}
+		printWorkspaces(workspaces, current)
+	case "use", "switch":
+		if len(args) < 2 {
+			c.usage("Usage: workspace use <name>\n")
+			return
+		}
+		workspace, err := parseWorkspaceArg(args[1])
+		if err != nil {
+			c.fail("Error: %v\n", err)
+			return
+		}
+		c.client.Workspace = workspace
+		c.rl.SetPrompt(workspacePrompt(workspace))
+		fmt.Print(trf("✓ Switched to workspace %s\n", workspace))
+	default:
+		c.usage("Usage: workspace [list|use <name>]\n")
+	}
 }
 
 // clearScreen clears the console
@@ -1199,7 +1585,7 @@ func (c *CLIHttp) clearScreen() {
 // handleExit exits the CLI
 func (c *CLIHttp) handleExit() {
 	// Fetch running sessions
-	stats, err := c.client.GetStats()
+	stats, err := c.client.GetStats(context.Background())
 	if err != nil {
 		fmt.Printf("Error getting sessions: %v\n", err)
 		c.running = false
@@ -1207,49 +1593,49 @@ func (c *CLIHttp) handleExit() {
 	}
 
 	if len(stats) == 0 {
-		fmt.Println("\nGoodbye!")
+		fmt.Println(tr("\nGoodbye!"))
 		c.running = false
 		return
 	}
 
 	// Active sessions exist; ask the user what to do
-	fmt.Printf("\n⚠ You have %d active session(s).\n", len(stats))
-	fmt.Println("\nOptions:")
-	fmt.Println("  1. Exit directly (keep sessions running)")
-	fmt.Println("  2. Stop all sessions and exit")
-	fmt.Println("  3. Stop sessions individually")
-	fmt.Println("  0. Cancel (return to CLI)")
+	fmt.Print(trf("\n⚠ You have %d active session(s).\n", len(stats)))
+	fmt.Println(tr("\nOptions:"))
+	fmt.Println(tr("  1. Exit directly (keep sessions running)"))
+	fmt.Println(tr("  2. Stop all sessions and exit"))
+	fmt.Println(tr("  3. Stop sessions individually"))
+	fmt.Println(tr("  0. Cancel (return to CLI)"))
 
 	choice := c.readInput("\nYour choice", "1")
 
 	switch choice {
 	case "0":
-		fmt.Println("Exit cancelled.")
+		fmt.Println(tr("Exit cancelled."))
 		return
 	case "1":
-		fmt.Println("\nGoodbye! (Sessions still running)")
+		fmt.Println(tr("\nGoodbye! (Sessions still running)"))
 		c.running = false
 	case "2":
 		c.stopAllSessions(stats)
-		fmt.Println("\nGoodbye!")
+		fmt.Println(tr("\nGoodbye!"))
 		c.running = false
 	case "3":
 		c.stopSessionsInteractively(stats)
-		fmt.Println("\nGoodbye!")
+		fmt.Println(tr("\nGoodbye!"))
 		c.running = false
 	default:
-		fmt.Println("Invalid choice. Exit cancelled.")
+		fmt.Println(tr("Invalid choice. Exit cancelled."))
 	}
 }
 
 // stopAllSessions stops every session
 func (c *CLIHttp) stopAllSessions(stats map[string]core.SessionStats) {
-	fmt.Println("\nStopping all sessions...")
+	fmt.Println(tr("\nStopping all sessions..."))
 	for id := range stats {
-		if err := c.client.StopMapping(id); err != nil {
-			fmt.Printf("❌ Failed to stop %s: %v\n", id, err)
+		if err := c.client.StopMapping(context.Background(), id); err != nil {
+			fmt.Print(trf("❌ Failed to stop %s: %v\n", id, err))
 		} else {
-			fmt.Printf("✓ Stopped %s\n", id)
+			fmt.Print(trf("✓ Stopped %s\n", id))
 		}
 	}
 }
@@ -1289,7 +1675,7 @@ func (c *CLIHttp) stopSessionsInteractively(stats map[string]core.SessionStats)
 		}
 
 		if start >= len(sessions) {
-			fmt.Println("\nAll sessions reviewed.")
+			fmt.Println(tr("\nAll sessions reviewed."))
 			break
 		}
 
@@ -1318,10 +1704,10 @@ func (c *CLIHttp) stopSessionsInteractively(stats map[string]core.SessionStats)
 			}
 			if num >= 1 && num <= end-start {
 				idx := start + num - 1
-				if err := c.client.StopMapping(sessions[idx].id); err != nil {
-					fmt.Printf("❌ Failed to stop %s: %v\n", sessions[idx].id, err)
+				if err := c.client.StopMapping(context.Background(), sessions[idx].id); err != nil {
+					fmt.Print(trf("❌ Failed to stop %s: %v\n", sessions[idx].id, err))
 				} else {
-					fmt.Printf("✓ Stopped %s\n", sessions[idx].id)
+					fmt.Print(trf("✓ Stopped %s\n", sessions[idx].id))
 					// Remove from list
 					sessions = append(sessions[:idx], sessions[idx+1:]...)
 					if idx >= start+pageSize {
@@ -1329,17 +1715,17 @@ func (c *CLIHttp) stopSessionsInteractively(stats map[string]core.SessionStats)
 					}
 				}
 			} else {
-				fmt.Println("Invalid selection. Please try again.")
+				fmt.Println(tr("Invalid selection. Please try again."))
 			}
 		} else {
 			// Input might be a port
 			found := false
 			for i, s := range sessions {
 				if strings.Contains(s.id, input) {
-					if err := c.client.StopMapping(s.id); err != nil {
-						fmt.Printf("❌ Failed to stop %s: %v\n", s.id, err)
+					if err := c.client.StopMapping(context.Background(), s.id); err != nil {
+						fmt.Print(trf("❌ Failed to stop %s: %v\n", s.id, err))
 					} else {
-						fmt.Printf("✓ Stopped %s\n", s.id)
+						fmt.Print(trf("✓ Stopped %s\n", s.id))
 						sessions = append(sessions[:i], sessions[i+1:]...)
 						found = true
 					}
@@ -1347,7 +1733,7 @@ func (c *CLIHttp) stopSessionsInteractively(stats map[string]core.SessionStats)
 				}
 			}
 			if !found {
-				fmt.Println("Session not found. Please try again.")
+				fmt.Println(tr("Session not found. Please try again."))
 			}
 		}
 	}
@@ -1355,10 +1741,9 @@ func (c *CLIHttp) stopSessionsInteractively(stats map[string]core.SessionStats)
 
 // extractPort pulls the port from a mapping ID
 func extractPort(id string) int {
-	// ID format is typically "127.0.0.1:8080"
-	parts := strings.Split(id, ":")
-	if len(parts) == 2 {
-		if port, err := strconv.Atoi(parts[1]); err == nil {
+	// ID format is typically "127.0.0.1:8080" or "[::1]:8080"
+	if _, portStr, err := net.SplitHostPort(id); err == nil {
+		if port, err := strconv.Atoi(portStr); err == nil {
 			return port
 		}
 	}
@@ -1408,6 +1793,7 @@ func validateBastionChain(input string, bastions []models.Bastion) ([]string, bo
 
 // readInput reads user input with an optional default
 func (c *CLIHttp) readInput(prompt, defaultValue string) string {
+	prompt = tr(prompt)
 	if defaultValue != "" {
 		c.rl.SetPrompt(fmt.Sprintf("%s [%s]: ", prompt, defaultValue))
 	} else {
@@ -1430,6 +1816,7 @@ func (c *CLIHttp) readInput(prompt, defaultValue string) string {
 
 // readInputWithCancel reads input and supports cancellation
 func (c *CLIHttp) readInputWithCancel(prompt, defaultValue string) (string, bool) {
+	prompt = tr(prompt)
 	if defaultValue != "" {
 		c.rl.SetPrompt(fmt.Sprintf("%s [%s]: ", prompt, defaultValue))
 	} else {
@@ -1457,6 +1844,7 @@ func (c *CLIHttp) readInputWithCancel(prompt, defaultValue string) (string, bool
 
 // readInputPasswordWithCancel reads a password without echo and supports cancel
 func (c *CLIHttp) readInputPasswordWithCancel(prompt string) (string, bool) {
+	prompt = tr(prompt)
 	c.rl.SetPrompt(fmt.Sprintf("%s: ", prompt))
 	line, err := c.rl.ReadPassword("")
 	c.rl.SetPrompt("> ") // Restore default prompt
@@ -1476,8 +1864,10 @@ func validatePort(port int) bool {
 	return port > 0 && port <= 65535
 }
 
-// validateHost checks host or IP format
+// validateHost checks host or IP format. IPv6 literals may be bracketed ("[::1]"); store hosts
+// through models.NormalizeHost.
 func validateHost(host string) bool {
+	host = models.NormalizeHost(host)
 	if host == "" {
 		return false
 	}
@@ -1492,6 +1882,11 @@ func validateHost(host string) bool {
 		return true
 	}
 
+	// Check for valid IPv6 (link-local addresses may carry a %zone)
+	if isValidIPv6(host) {
+		return true
+	}
+
 	// Check for valid domain format
 	if isValidDomain(host) {
 		return true
@@ -1532,6 +1927,13 @@ func isValidIPv4(ip string) bool {
 	return true
 }
 
+// isValidIPv6 validates IPv6 address format, with an optional %zone
+func isValidIPv6(host string) bool {
+	addr, _, _ := strings.Cut(host, "%")
+	ip := net.ParseIP(addr)
+	return ip != nil && strings.Contains(addr, ":")
+}
+
 // isValidDomain validates domain format
 func isValidDomain(domain string) bool {
 	// Domain length check
@@ -0,0 +1,152 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chzyer/readline"
+)
+
+// completionCacheTTL bounds how often tab completion asks the server for IDs and names.
+const completionCacheTTL = 5 * time.Second
+
+// completionSource serves mapping IDs, bastions and workspaces to tab completion, cached briefly per
+// workspace so repeated tab presses do not hit the server.
+type completionSource struct {
+	client *Client
+
+	mu           sync.Mutex
+	workspace    string
+	fetchedAt    time.Time
+	mappingIDs   []string
+	bastionIDs   []string
+	bastionNames []string
+	workspaces   []string
+}
+
+func (s *completionSource) refreshLocked() {
+	if s.workspace == s.client.Workspace && time.Since(s.fetchedAt) < completionCacheTTL {
+		return
+	}
+	s.workspace = s.client.Workspace
+	s.fetchedAt = time.Now()
+
+	s.mappingIDs, s.bastionIDs, s.bastionNames, s.workspaces = nil, nil, nil, nil
+	if mappings, err := s.client.ListMappings(context.Background()); err == nil {
+		for _, m := range mappings {
+			s.mappingIDs = append(s.mappingIDs, m.ID)
+		}
+	}
+	if bastions, err := s.client.ListBastions(context.Background()); err == nil {
+		for _, b := range bastions {
+			s.bastionIDs = append(s.bastionIDs, fmt.Sprint(b.ID))
+			s.bastionNames = append(s.bastionNames, b.Name)
+		}
+	}
+	if workspaces, err := s.client.ListWorkspaces(context.Background()); err == nil {
+		for _, ws := range workspaces {
+			s.workspaces = append(s.workspaces, ws.Name)
+		}
+	}
+}
+
+func (s *completionSource) list(pick func(*completionSource) []string) readline.DynamicCompleteFunc {
+	return func(string) []string {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.refreshLocked()
+		return append([]string(nil), pick(s)...)
+	}
+}
+
+// newHTTPCompleter builds the context-aware completer of the HTTP client CLI.
+func newHTTPCompleter(src *completionSource) *readline.PrefixCompleter {
+	mappingIDs := src.list(func(s *completionSource) []string { return s.mappingIDs })
+	bastionIDs := src.list(func(s *completionSource) []string { return s.bastionIDs })
+	bastionNames := src.list(func(s *completionSource) []string { return s.bastionNames })
+	workspaces := src.list(func(s *completionSource) []string { return s.workspaces })
+
+	return readline.NewPrefixCompleter(
+		readline.PcItem("help"),
+		readline.PcItem("bastion",
+			readline.PcItem("list"),
+			readline.PcItem("add"),
+			readline.PcItem("delete", readline.PcItemDynamic(bastionIDs)),
+			readline.PcItem("show", readline.PcItemDynamic(bastionIDs)),
+		),
+		readline.PcItem("mapping",
+			readline.PcItem("list"),
+			readline.PcItem("add"),
+			readline.PcItem("delete", readline.PcItemDynamic(mappingIDs)),
+			readline.PcItem("show", readline.PcItemDynamic(mappingIDs)),
+		),
+		readline.PcItem("start", readline.PcItemDynamic(mappingIDs,
+			readline.PcItem("--dry-run", readline.PcItem("--target")),
+		)),
+		readline.PcItem("stop", readline.PcItem("--all"), readline.PcItemDynamic(mappingIDs)),
+		readline.PcItem("status"),
+		readline.PcItem("stats"),
+		readline.PcItem("interfaces"),
+		readline.PcItem("expose", readline.PcItemDynamic(mappingIDs)),
+		readline.PcItem("unexpose", readline.PcItemDynamic(mappingIDs)),
+		readline.PcItem("workspace",
+			readline.PcItem("list"),
+			readline.PcItem("use", readline.PcItemDynamic(workspaces)),
+		),
+		readline.PcItem("http",
+			readline.PcItem("list"),
+			readline.PcItem("search",
+				readline.PcItem("--local-port"),
+				readline.PcItem("--bastion", readline.PcItemDynamic(bastionNames)),
+				readline.PcItem("--url"),
+				readline.PcItem("--method"),
+				readline.PcItem("--host"),
+				readline.PcItem("--status"),
+				readline.PcItem("--header"),
+			),
+			readline.PcItem("tail",
+				readline.PcItem("--method"),
+				readline.PcItem("--host"),
+				readline.PcItem("--status"),
+				readline.PcItem("--url"),
+				readline.PcItem("--local-port"),
+				readline.PcItem("--bastion", readline.PcItemDynamic(bastionNames)),
+				readline.PcItem("--header"),
+			),
+			readline.PcItem("show"),
+			readline.PcItem("curl"),
+			readline.PcItem("clear"),
+		),
+		readline.PcItem("setup"),
+		readline.PcItem("apply",
+			readline.PcItem("-f"),
+			readline.PcItem("--prune"),
+			readline.PcItem("--dry-run"),
+		),
+		readline.PcItem("import-ssh",
+			readline.PcItem("--pid"),
+			readline.PcItem("--yes"),
+		),
+		readline.PcItem("clear"),
+		readline.PcItem("exit"),
+	)
+}
+
+// historyFilePath resolves the configured history file ("" when persistence is off) and makes sure
+// its directory exists.
+func historyFilePath(path string) string {
+	path = strings.TrimSpace(path)
+	if path == "" || strings.EqualFold(path, "off") {
+		return ""
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		fmt.Printf("Warning: command history disabled: %v\n", err)
+		return ""
+	}
+	return path
+}
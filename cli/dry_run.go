@@ -0,0 +1,85 @@
+package cli
+
+import (
+	"bastion/core"
+	"fmt"
+	"strings"
+)
+
+// startArgs are the parsed arguments of `start`.
+type startArgs struct {
+	id     string
+	dryRun bool
+	target string
+}
+
+// parseStartArgs parses `start <mapping_id> [--dry-run] [--target host:port]`.
+func parseStartArgs(args []string) (startArgs, error) {
+	var parsed startArgs
+	for i := 0; i < len(args); i++ {
+		token := args[i]
+		switch {
+		case token == "--dry-run":
+			parsed.dryRun = true
+		case token == "--target":
+			if i+1 >= len(args) {
+				return parsed, fmt.Errorf("--target requires host:port")
+			}
+			i++
+			parsed.target = args[i]
+		case strings.HasPrefix(token, "--target="):
+			parsed.target = strings.TrimPrefix(token, "--target=")
+		case strings.HasPrefix(token, "--"):
+			return parsed, fmt.Errorf("unknown flag: %s", token)
+		case parsed.id == "":
+			parsed.id = token
+		default:
+			return parsed, fmt.Errorf("unexpected argument: %s", token)
+		}
+	}
+	if parsed.id == "" {
+		return parsed, fmt.Errorf("missing mapping id")
+	}
+	if parsed.target != "" && !parsed.dryRun {
+		return parsed, fmt.Errorf("--target is only valid with --dry-run")
+	}
+	return parsed, nil
+}
+
+// printDryRunReport renders a dry-run report hop by hop.
+func printDryRunReport(report *core.DryRunReport) {
+	fmt.Println()
+	PrintBanner(fmt.Sprintf("Dry Run: %s", report.MappingID))
+	fmt.Println()
+
+	fmt.Printf("Route:   %s\n", report.Route)
+	if report.Error != "" {
+		fmt.Printf("✗ %s\n", report.Error)
+		return
+	}
+
+	for i, hop := range report.Hops {
+		printDryRunStep(fmt.Sprintf("Hop %d", i+1), hop.Name+" ("+hop.Addr+")", hop)
+	}
+	if report.Target != nil {
+		printDryRunStep("Target", report.Target.Addr, *report.Target)
+	}
+
+	fmt.Println()
+	if report.OK {
+		fmt.Println("✓ Dry run passed; the mapping was not started.")
+	} else {
+		fmt.Println("✗ Dry run failed; the mapping was not started.")
+	}
+}
+
+func printDryRunStep(label, what string, step core.DryRunStep) {
+	switch step.Status {
+	case core.DryRunOK:
+		fmt.Printf("  ✓ %-7s %s  %dms\n", label, what, step.DurationMS)
+	case core.DryRunFailed:
+		fmt.Printf("  ✗ %-7s %s  %dms: %s\n", label, what, step.DurationMS, step.Error)
+	default:
+		fmt.Printf("  - %-7s %s  (skipped)\n", label, what)
+	}
+}
@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"bastion/core"
+	"bastion/models"
+	"errors"
+	"fmt"
+	"os/user"
+	"strings"
+)
+
+// exposeArgs are the parsed arguments of `expose <mapping_id> <address> --yes`.
+type exposeArgs struct {
+	id      string
+	addr    string
+	confirm bool
+}
+
+func parseExposeArgs(args []string) (exposeArgs, error) {
+	var parsed exposeArgs
+	var positional []string
+	for _, arg := range args {
+		switch arg {
+		case "--yes", "-y":
+			parsed.confirm = true
+		default:
+			if strings.HasPrefix(arg, "-") {
+				return parsed, fmt.Errorf("unknown flag %s", arg)
+			}
+			positional = append(positional, arg)
+		}
+	}
+	if len(positional) != 2 {
+		return parsed, errors.New("mapping id and interface address are required")
+	}
+	parsed.id, parsed.addr = positional[0], positional[1]
+	return parsed, nil
+}
+
+// exposeActor names the local user for the expose audit record.
+func exposeActor() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return "cli:" + u.Username
+	}
+	return "cli"
+}
+
+// listenHost returns the address a listed mapping is bound to.
+func listenHost(m models.MappingRead) string {
+	if m.ExposeAddr != "" {
+		return m.ExposeAddr
+	}
+	return m.LocalHost
+}
+
+// printExposedWarning lists mappings reachable from other machines, if any.
+func printExposedWarning(mappings []models.MappingRead) {
+	var exposed []string
+	for _, m := range mappings {
+		if m.ExposeAddr != "" {
+			exposed = append(exposed, fmt.Sprintf("%s on %s (by %s)", m.ID, m.ExposeAddr, m.ExposedBy))
+		}
+	}
+	if len(exposed) == 0 {
+		return
+	}
+	fmt.Println()
+	fmt.Println("⚠️  Exposed beyond localhost: " + strings.Join(exposed, ", "))
+}
+
+// printInterfaces renders local interfaces; exposable ones are up and not loopback.
+func printInterfaces(ifaces []core.NetInterface) {
+	fmt.Println()
+	PrintBanner(fmt.Sprintf("Network Interfaces: %d", len(ifaces)))
+	fmt.Println()
+
+	fmt.Printf("%-16s %-6s %-10s %s\n", "Name", "Up", "Exposable", "Addresses")
+	fmt.Println(strings.Repeat("-", 80))
+	for _, iface := range ifaces {
+		up, exposable := "no", "no"
+		if iface.Up {
+			up = "yes"
+		}
+		if iface.Up && !iface.Loopback && len(iface.Addrs) > 0 {
+			exposable = "yes"
+		}
+		fmt.Printf("%-16s %-6s %-10s %s\n", truncate(iface.Name, 16), up, exposable, strings.Join(iface.Addrs, ", "))
+	}
+}
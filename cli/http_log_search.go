@@ -1,23 +1,18 @@
 package cli
 
 import (
+	"bastion/core"
 	"fmt"
+	"io"
 	"net/url"
 	"strconv"
 	"strings"
 )
 
 // parseHTTPLogSearchArgs parses `http search` arguments into query params and page number.
-//
-// Supported flags:
-// - --local-port <port> / --local-port=<port>
-// - --bastion <name> / --bastion=<name>
-// - --url <url> / --url=<url>
-//
-// The last argument may be a page number.
+// The last argument may be a page number; the others are filters (see parseHTTPLogFilterArgs).
 func parseHTTPLogSearchArgs(args []string) (page int, values url.Values, err error) {
 	page = 1
-	values = url.Values{}
 
 	if len(args) == 0 {
 		return 0, nil, fmt.Errorf("missing args")
@@ -32,6 +27,31 @@ func parseHTTPLogSearchArgs(args []string) (page int, values url.Values, err err
 		page = 1
 	}
 
+	values, err = parseHTTPLogFilterArgs(args)
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(values) == 0 {
+		return 0, nil, fmt.Errorf("missing search keyword or filters")
+	}
+
+	return page, values, nil
+}
+
+// parseHTTPLogFilterArgs parses HTTP log filter arguments into query params; words that are not
+// flags form the keyword.
+//
+// Supported flags (also as --flag=value):
+// - --local-port <port>
+// - --bastion <name>
+// - --url <url>
+// - --method <method>
+// - --host <host>
+// - --status <code>
+// - --header <name>[:<value>] (repeatable)
+func parseHTTPLogFilterArgs(args []string) (url.Values, error) {
+	values := url.Values{}
+
 	var keywordParts []string
 	for i := 0; i < len(args); i++ {
 		token := args[i]
@@ -42,7 +62,7 @@ func parseHTTPLogSearchArgs(args []string) (page int, values url.Values, err err
 			flagName := parts[0]
 			flagValue := strings.TrimSpace(parts[1])
 			if err := applyHTTPLogSearchFlag(values, flagName, flagValue); err != nil {
-				return 0, nil, err
+				return nil, err
 			}
 			continue
 		}
@@ -51,12 +71,12 @@ func parseHTTPLogSearchArgs(args []string) (page int, values url.Values, err err
 		if strings.HasPrefix(token, "--") {
 			flagName := token
 			if i+1 >= len(args) {
-				return 0, nil, fmt.Errorf("missing value for %s", flagName)
+				return nil, fmt.Errorf("missing value for %s", flagName)
 			}
 			flagValue := strings.TrimSpace(args[i+1])
 			i++
 			if err := applyHTTPLogSearchFlag(values, flagName, flagValue); err != nil {
-				return 0, nil, err
+				return nil, err
 			}
 			continue
 		}
@@ -68,11 +88,7 @@ func parseHTTPLogSearchArgs(args []string) (page int, values url.Values, err err
 		values.Set("q", keyword)
 	}
 
-	if values.Get("q") == "" && values.Get("local_port") == "" && values.Get("bastion") == "" && values.Get("url") == "" {
-		return 0, nil, fmt.Errorf("missing search keyword or filters")
-	}
-
-	return page, values, nil
+	return values, nil
 }
 
 func applyHTTPLogSearchFlag(values url.Values, name, value string) error {
@@ -98,7 +114,68 @@ func applyHTTPLogSearchFlag(values url.Values, name, value string) error {
 		}
 		values.Set("url", value)
 		return nil
+	case "--method":
+		value = strings.ToUpper(strings.TrimSpace(value))
+		if value == "" {
+			return fmt.Errorf("invalid --method: empty")
+		}
+		values.Set("method", value)
+		return nil
+	case "--host":
+		value = strings.TrimSpace(value)
+		if value == "" {
+			return fmt.Errorf("invalid --host: empty")
+		}
+		values.Set("host", value)
+		return nil
+	case "--status":
+		code, err := strconv.Atoi(value)
+		if err != nil || code < 100 || code > 599 {
+			return fmt.Errorf("invalid --status: %q", value)
+		}
+		values.Set("status", strconv.Itoa(code))
+		return nil
+	case "--header":
+		name, _, _ := strings.Cut(value, ":")
+		if strings.TrimSpace(name) == "" {
+			return fmt.Errorf("invalid --header: %q", value)
+		}
+		values.Add("header", value)
+		return nil
 	default:
 		return fmt.Errorf("unknown flag: %s", name)
 	}
 }
+
+// ansiReverse highlights search matches.
+const ansiReverse = "\033[7m"
+
+// printHTTPLogMatches prints the keyword matches of a search below its table, one snippet per line
+// with the match highlighted (or bracketed without color).
+func printHTTPLogMatches(w io.Writer, logs []*core.HTTPLog, matches map[int][]core.HTTPLogMatch, color bool) {
+	if len(matches) == 0 {
+		return
+	}
+	open, closing := "[", "]"
+	if color {
+		open, closing = ansiReverse, ansiReset
+	}
+
+	fmt.Fprintln(w, "\nMatches:")
+	for _, log := range logs {
+		for _, m := range matches[log.ID] {
+			snippet := oneLine(m.Snippet[:m.MatchStart]) + open + oneLine(m.Snippet[m.MatchStart:m.MatchEnd]) + closing + oneLine(m.Snippet[m.MatchEnd:])
+			fmt.Fprintf(w, "  #%-6d %s@%d: %s\n", log.ID, m.Field, m.Offset, snippet)
+		}
+	}
+}
+
+// oneLine replaces line breaks and other control characters with spaces.
+func oneLine(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return ' '
+		}
+		return r
+	}, s)
+}
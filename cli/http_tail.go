@@ -0,0 +1,160 @@
+package cli
+
+import (
+	"bastion/bastionclient"
+	"bastion/core"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/chzyer/readline"
+)
+
+// httpTailPollInterval is how often `http tail` polls servers without the stream endpoint.
+const httpTailPollInterval = 2 * time.Second
+
+// ANSI colors of status codes in `http tail`.
+const (
+	ansiReset  = "\033[0m"
+	ansiRed    = "\033[31m"
+	ansiGreen  = "\033[32m"
+	ansiYellow = "\033[33m"
+	ansiCyan   = "\033[36m"
+)
+
+// tailHTTPLogs prints new HTTP logs matching values as they are captured until Ctrl+C, using the
+// stream endpoint and falling back to polling on servers without it.
+func (c *CLIHttp) tailHTTPLogs(values url.Values) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	p := &httpTailPrinter{w: os.Stdout, format: c.output, color: useColor(os.Stdout)}
+	if !isStructured(c.output) {
+		fmt.Println("Following HTTP logs (Ctrl+C to stop)...")
+	}
+
+	err := c.client.StreamHTTPLogs(ctx, values, p.print, func(n uint64) {
+		fmt.Fprintf(os.Stderr, "... %d entries skipped (client too slow)\n", n)
+	})
+	if errors.Is(err, bastionclient.ErrStreamUnsupported) {
+		err = c.pollHTTPLogs(ctx, values, p)
+	}
+	if err != nil {
+		c.fail("Error: %v\n", err)
+	}
+}
+
+// pollHTTPLogs is the tail fallback for servers without the stream endpoint: it lists the newest
+// matching logs periodically and prints those it has not seen yet.
+func (c *CLIHttp) pollHTTPLogs(ctx context.Context, values url.Values, p *httpTailPrinter) error {
+	const pageSize = 100
+	lastID := -1
+
+	ticker := time.NewTicker(httpTailPollInterval)
+	defer ticker.Stop()
+	for {
+		result, err := c.client.GetHTTPLogs(ctx, values, 1, pageSize)
+		if err != nil {
+			return err
+		}
+		logs := result.Items
+
+		// Logs come newest first; IDs restart from 1 after the logs are cleared.
+		sort.Slice(logs, func(i, j int) bool { return logs[i].ID < logs[j].ID })
+		newest := 0
+		if len(logs) > 0 {
+			newest = logs[len(logs)-1].ID
+		}
+		if lastID >= 0 {
+			if newest < lastID {
+				lastID = 0
+			}
+			for _, log := range logs {
+				if log.ID > lastID {
+					p.print(log.Summary())
+				}
+			}
+		}
+		lastID = newest
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// httpTailPrinter prints one line per HTTP log, like an access log, one JSON object per line with
+// --output json, or CSV rows with --output csv.
+type httpTailPrinter struct {
+	w      io.Writer
+	format string
+	color  bool
+	csv    *csv.Writer
+}
+
+func (p *httpTailPrinter) print(s core.HTTPLogSummary) {
+	switch p.format {
+	case outputJSON:
+		line, err := json.Marshal(s)
+		if err == nil {
+			fmt.Fprintf(p.w, "%s\n", line)
+		}
+	case outputCSV:
+		if p.csv == nil {
+			p.csv = csv.NewWriter(p.w)
+			_ = p.csv.Write([]string{"ID", "Time", "Mapping", "Method", "Code", "Host", "URL", "Duration (ms)", "Response Bytes"})
+		}
+		_ = p.csv.Write([]string{strconv.Itoa(s.ID), s.Timestamp.Format(time.RFC3339), s.MappingID, s.Method,
+			strconv.Itoa(s.StatusCode), s.Host, s.URL, strconv.FormatInt(s.DurationMs, 10), strconv.Itoa(s.RespSize)})
+		p.csv.Flush()
+	default:
+		fmt.Fprintln(p.w, formatHTTPTailLine(s, p.format == outputWide, p.color))
+	}
+}
+
+// formatHTTPTailLine renders "time mapping method status host+url duration size".
+func formatHTTPTailLine(s core.HTTPLogSummary, wide, color bool) string {
+	status := strconv.Itoa(s.StatusCode)
+	if s.StatusCode == 0 {
+		status = "---"
+	}
+	if color {
+		status = statusColor(s.StatusCode) + status + ansiReset
+	}
+	mapping := s.MappingID
+	if !wide {
+		mapping = truncate(mapping, 16)
+	}
+	return fmt.Sprintf("%s %-16s %-7s %s %s%s %dms %s",
+		s.Timestamp.Local().Format("15:04:05"), mapping, s.Method, status,
+		s.Host, s.URL, s.DurationMs, formatBytes(int64(s.RespSize)))
+}
+
+func statusColor(code int) string {
+	switch {
+	case code >= 500 || code == 0:
+		return ansiRed
+	case code >= 400:
+		return ansiYellow
+	case code >= 300:
+		return ansiCyan
+	default:
+		return ansiGreen
+	}
+}
+
+// useColor reports whether f is a terminal and NO_COLOR is not set.
+func useColor(f *os.File) bool {
+	return os.Getenv("NO_COLOR") == "" && readline.IsTerminal(int(f.Fd()))
+}
@@ -0,0 +1,27 @@
+package cli
+
+import (
+	"bastion/config"
+	"bastion/i18n"
+)
+
+// language is the language of CLI output: BASTION_LANG / --lang, else the process locale, else English.
+func language() string {
+	if lang := i18n.Normalize(config.Settings.Language); lang != "" {
+		return lang
+	}
+	if lang := i18n.FromEnvironment(); lang != "" {
+		return lang
+	}
+	return i18n.English
+}
+
+// tr translates a CLI message.
+func tr(msg string) string {
+	return i18n.T(language(), msg)
+}
+
+// trf translates a CLI format string and formats it.
+func trf(format string, args ...interface{}) string {
+	return i18n.Tf(language(), format, args...)
+}
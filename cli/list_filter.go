@@ -0,0 +1,40 @@
+package cli
+
+import (
+	"bastion/models"
+	"fmt"
+	"strings"
+)
+
+// parseListFilterArgs parses `bastion list` / `mapping list` arguments: free text (matched against
+// names, addresses, descriptions and tags) and any number of --tag <tag> / --tag=<tag>, which must
+// all be present.
+func parseListFilterArgs(args []string) (models.ListFilter, error) {
+	var filter models.ListFilter
+	var text []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case strings.HasPrefix(arg, "--tag="):
+			filter.Tags = append(filter.Tags, strings.Split(strings.TrimPrefix(arg, "--tag="), ",")...)
+		case arg == "--tag":
+			if i+1 >= len(args) {
+				return filter, fmt.Errorf("--tag requires a value")
+			}
+			i++
+			filter.Tags = append(filter.Tags, strings.Split(args[i], ",")...)
+		case strings.HasPrefix(arg, "--"):
+			return filter, fmt.Errorf("unknown flag: %s", arg)
+		default:
+			text = append(text, arg)
+		}
+	}
+	filter.Query = strings.Join(text, " ")
+	filter.Tags = models.NormalizeTags(filter.Tags)
+	return filter, nil
+}
+
+// formatTags renders tags for list columns.
+func formatTags(tags []string) string {
+	return strings.Join(tags, ",")
+}
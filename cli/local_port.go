@@ -0,0 +1,28 @@
+package cli
+
+import (
+	"net"
+	"strconv"
+	"strings"
+)
+
+// formatLocal renders a mapping's local address. Auto-port mappings (local_port 0) show the port
+// they are bound to while running and "auto" otherwise.
+func formatLocal(host string, port, runtimePort int) string {
+	if port == 0 {
+		if runtimePort == 0 {
+			return net.JoinHostPort(host, "auto")
+		}
+		port = runtimePort
+	}
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+// parseLocalPort accepts 1-65535, or 0 to pick a free port each time the mapping starts.
+func parseLocalPort(input string) (int, bool) {
+	port, err := strconv.Atoi(strings.TrimSpace(input))
+	if err != nil || (port != 0 && !validatePort(port)) {
+		return 0, false
+	}
+	return port, true
+}
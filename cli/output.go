@@ -0,0 +1,145 @@
+package cli
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// Output formats of list and show commands (--output / -o)
+const (
+	outputTable = "table" // fixed-width columns, long values truncated (default)
+	outputWide  = "wide"  // columns sized to their content, nothing truncated
+	outputJSON  = "json"
+	outputCSV   = "csv"
+)
+
+var outputFormats = []string{outputTable, outputWide, outputJSON, outputCSV}
+
+// parseOutputFlag removes --output <format>, --output=<format>, -o <format> and -o=<format> from
+// args and returns the requested format (outputTable when absent).
+func parseOutputFlag(args []string) (string, []string, error) {
+	format := outputTable
+	rest := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		name, value, hasValue := strings.Cut(arg, "=")
+		if name != "--output" && name != "-o" {
+			rest = append(rest, arg)
+			continue
+		}
+		if !hasValue {
+			if i+1 >= len(args) {
+				return "", nil, fmt.Errorf("%s requires a value (%s)", name, strings.Join(outputFormats, ", "))
+			}
+			i++
+			value = args[i]
+		}
+		value = strings.ToLower(strings.TrimSpace(value))
+		if !containsString(outputFormats, value) {
+			return "", nil, fmt.Errorf("unknown output format %q (supported: %s)", value, strings.Join(outputFormats, ", "))
+		}
+		format = value
+	}
+	return format, rest, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// column is one column of a table; width is its size in the default table format.
+type column struct {
+	title    string
+	width    int
+	truncate bool // cut longer values to width in the table format
+}
+
+// isStructured reports whether format is meant for other programs: no banners, hints or
+// humanized values.
+func isStructured(format string) bool {
+	return format == outputJSON || format == outputCSV
+}
+
+// writeTable renders rows as CSV, or as a table with a header line in the table and wide formats.
+func writeTable(w io.Writer, format string, columns []column, rows [][]string) error {
+	if format == outputCSV {
+		cw := csv.NewWriter(w)
+		header := make([]string, len(columns))
+		for i, col := range columns {
+			header[i] = col.title
+		}
+		if err := cw.Write(header); err != nil {
+			return err
+		}
+		if err := cw.WriteAll(rows); err != nil {
+			return err
+		}
+		return cw.Error()
+	}
+
+	widths := make([]int, len(columns))
+	for i, col := range columns {
+		widths[i] = col.width
+		if format == outputWide {
+			widths[i] = utf8.RuneCountInString(col.title)
+			for _, row := range rows {
+				if n := utf8.RuneCountInString(row[i]); n > widths[i] {
+					widths[i] = n
+				}
+			}
+		}
+	}
+
+	total := 0
+	cells := make([]string, len(columns))
+	for i, col := range columns {
+		cells[i] = padRight(col.title, widths[i])
+		total += widths[i] + 1
+	}
+	fmt.Fprintln(w, strings.TrimRight(strings.Join(cells, " "), " "))
+	fmt.Fprintln(w, strings.Repeat("-", total-1))
+	for _, row := range rows {
+		for i, col := range columns {
+			value := row[i]
+			if format == outputTable && col.truncate {
+				value = truncate(value, col.width)
+			}
+			cells[i] = padRight(value, widths[i])
+		}
+		fmt.Fprintln(w, strings.TrimRight(strings.Join(cells, " "), " "))
+	}
+	return nil
+}
+
+// padRight pads s with spaces to width runes (fmt's %-*s pads by bytes).
+func padRight(s string, width int) string {
+	if n := utf8.RuneCountInString(s); n < width {
+		return s + strings.Repeat(" ", width-n)
+	}
+	return s
+}
+
+// writeJSON prints v as indented JSON.
+func writeJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// printStructured prints v as JSON, or the table as CSV/table, on stdout.
+func printStructured(format string, v interface{}, columns []column, rows [][]string) error {
+	if format == outputJSON {
+		return writeJSON(os.Stdout, v)
+	}
+	return writeTable(os.Stdout, format, columns, rows)
+}
@@ -0,0 +1,160 @@
+package cli
+
+import (
+	"bastion/models"
+	"context"
+	"fmt"
+	"strings"
+)
+
+// handleSetupCommand drives the server's first-run wizard step by step
+func (c *CLIHttp) handleSetupCommand() {
+	status, err := c.client.GetSetupStatus(context.Background())
+	if err != nil {
+		fmt.Printf("❌ Failed to load setup status: %v\n", err)
+		return
+	}
+	if status.Completed {
+		fmt.Println("✓ Setup already completed")
+		return
+	}
+
+	fmt.Println()
+	PrintBanner("First-Run Setup")
+	fmt.Println("\nPress Ctrl+C anytime to stop; progress is saved on the server")
+
+	for status.Step != models.SetupStepDone {
+		var (
+			req       models.SetupStepRequest
+			cancelled bool
+		)
+
+		switch status.Step {
+		case models.SetupStepImportSSHConfig:
+			req, cancelled = c.promptSetupImport()
+		case models.SetupStepBastion:
+			cancelled = c.promptSetupAdd("bastion", status.BastionCount, c.addBastion)
+			req.Skip = true
+		case models.SetupStepMapping:
+			cancelled = c.promptSetupAdd("mapping", status.MappingCount, c.addMapping)
+			req.Skip = true
+		case models.SetupStepAdminToken:
+			req, cancelled = c.promptSetupAdminToken()
+		case models.SetupStepBindAddress:
+			req, cancelled = c.promptSetupBindAddress()
+		default:
+			fmt.Printf("❌ Unknown setup step: %s\n", status.Step)
+			return
+		}
+		if cancelled {
+			fmt.Println("\n❌ Setup paused. Run 'setup' again to continue.")
+			return
+		}
+
+		result, err := c.client.ApplySetupStep(context.Background(), status.Step, req)
+		if err != nil {
+			fmt.Printf("❌ %v\n", err)
+			continue
+		}
+		if len(result.ImportedBastions) > 0 {
+			fmt.Printf("✓ Imported bastions: %s\n", strings.Join(result.ImportedBastions, ", "))
+		}
+		if result.AdminToken != "" {
+			fmt.Printf("✓ Admin token (shown once): %s\n", result.AdminToken)
+		}
+		if result.RestartRequired {
+			fmt.Println("⚠ Restart the server to apply the new bind address")
+		}
+		status = &result.Status
+	}
+
+	fmt.Println("\n✓ Setup completed")
+}
+
+func (c *CLIHttp) promptSetupImport() (models.SetupStepRequest, bool) {
+	req := models.SetupStepRequest{}
+
+	hosts, err := c.client.GetSetupSSHConfig(context.Background())
+	if err != nil || len(hosts) == 0 {
+		if err != nil {
+			fmt.Printf("⚠ Cannot read ssh config: %v\n", err)
+		}
+		fmt.Println("No ssh config hosts found, skipping import")
+		req.Skip = true
+		return req, false
+	}
+
+	fmt.Println("\nHosts found in ~/.ssh/config:")
+	for _, h := range hosts {
+		user := h.User
+		if user == "" {
+			user = "(no user, cannot import)"
+		}
+		fmt.Printf("  %-20s %s@%s:%d\n", h.Alias, user, h.HostName, h.Port)
+	}
+
+	input, cancelled := c.readInputWithCancel("Aliases to import (space separated, 'all' or 'none')", "all")
+	if cancelled {
+		return req, true
+	}
+	switch strings.ToLower(input) {
+	case "none", "skip":
+		req.Skip = true
+	case "all":
+	default:
+		req.Hosts = strings.Fields(input)
+	}
+	return req, false
+}
+
+func (c *CLIHttp) promptSetupAdd(kind string, existing int64, add func()) bool {
+	def := "y"
+	if existing > 0 {
+		def = "n"
+	}
+	input, cancelled := c.readInputWithCancel(fmt.Sprintf("Add a %s now? (%d configured) [y/n]", kind, existing), def)
+	if cancelled {
+		return true
+	}
+	if strings.EqualFold(input, "y") || strings.EqualFold(input, "yes") {
+		add()
+	}
+	return false
+}
+
+func (c *CLIHttp) promptSetupAdminToken() (models.SetupStepRequest, bool) {
+	req := models.SetupStepRequest{}
+	fmt.Println("\nThe admin token is required for API clients that are not on this machine.")
+	input, cancelled := c.readInputWithCancel("Admin token: (g)enerate, (e)nter, (s)kip", "g")
+	if cancelled {
+		return req, true
+	}
+	switch strings.ToLower(input) {
+	case "s", "skip":
+		req.Skip = true
+	case "e", "enter":
+		token, cancelled := c.readInputPasswordWithCancel("Token (min 12 characters)")
+		if cancelled {
+			return req, true
+		}
+		req.AdminToken = token
+	default:
+		req.GenerateToken = true
+	}
+	return req, false
+}
+
+func (c *CLIHttp) promptSetupBindAddress() (models.SetupStepRequest, bool) {
+	req := models.SetupStepRequest{}
+	fmt.Println("\nUse 127.0.0.1 to allow local access only, or 0.0.0.0 to listen on all interfaces.")
+	input, cancelled := c.readInputWithCancel("Bind address ('skip' keeps the current one)", "127.0.0.1")
+	if cancelled {
+		return req, true
+	}
+	if strings.EqualFold(input, "skip") {
+		req.Skip = true
+	} else {
+		req.BindAddress = input
+	}
+	return req, false
+}
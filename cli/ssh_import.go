@@ -0,0 +1,91 @@
+package cli
+
+import (
+	"bastion/models"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// importSSHArgs are the parsed arguments of `import-ssh [--pid <pid>]... [--yes]`.
+type importSSHArgs struct {
+	pids    []int
+	confirm bool
+}
+
+func parseImportSSHArgs(args []string) (importSSHArgs, error) {
+	var parsed importSSHArgs
+	for i := 0; i < len(args); i++ {
+		token := args[i]
+		value := ""
+		switch {
+		case token == "--yes" || token == "-y":
+			parsed.confirm = true
+			continue
+		case token == "--pid":
+			if i+1 >= len(args) {
+				return parsed, fmt.Errorf("%s requires a process ID", token)
+			}
+			i++
+			value = args[i]
+		case strings.HasPrefix(token, "--pid="):
+			value = strings.TrimPrefix(token, "--pid=")
+		case strings.HasPrefix(token, "-"):
+			return parsed, fmt.Errorf("unknown flag: %s", token)
+		default:
+			return parsed, fmt.Errorf("unexpected argument: %s", token)
+		}
+		pid, err := strconv.Atoi(value)
+		if err != nil || pid <= 0 {
+			return parsed, fmt.Errorf("invalid process ID: %s", value)
+		}
+		parsed.pids = append(parsed.pids, pid)
+	}
+	return parsed, nil
+}
+
+// sshEndpointString renders an ssh destination or jump host as user@host:port (alias).
+func sshEndpointString(e models.SSHEndpoint) string {
+	s := e.User + "@" + net.JoinHostPort(e.Host, strconv.Itoa(e.Port))
+	if e.Alias != "" {
+		s += " (" + e.Alias + ")"
+	}
+	return s
+}
+
+// printSSHDiscovery lists the discovered ssh processes with the bastions and mappings replacing them.
+func printSSHDiscovery(discovery *models.SSHDiscovery) {
+	fmt.Println()
+	PrintBanner(fmt.Sprintf("SSH Processes: %d", len(discovery.Processes)))
+	fmt.Println()
+	if discovery.Error != "" {
+		fmt.Printf("⚠️  Listing processes (%s) failed: %s\n", discovery.Source, discovery.Error)
+	}
+	if len(discovery.Processes) == 0 {
+		fmt.Println("No ssh processes with -L/-D forwards found.")
+		return
+	}
+
+	for _, p := range discovery.Processes {
+		fmt.Printf("PID %d: %s\n", p.PID, truncate(p.CommandLine, 100))
+		fmt.Printf("  destination: %s\n", sshEndpointString(p.Destination))
+		for _, hop := range p.JumpHosts {
+			fmt.Printf("  via:         %s\n", sshEndpointString(hop))
+		}
+		for _, b := range p.Bastions {
+			fmt.Printf("  + bastion %s\n", b.Name)
+		}
+		for _, m := range p.Mappings {
+			target := m.Type
+			if m.Type == "tcp" {
+				target = net.JoinHostPort(m.RemoteHost, strconv.Itoa(m.RemotePort))
+			}
+			fmt.Printf("  + mapping %s → %s via %s\n", m.ID, target, strings.Join(m.Chain, " → "))
+		}
+		for _, note := range p.Notes {
+			fmt.Printf("  ! %s\n", note)
+		}
+		fmt.Println()
+	}
+}
@@ -0,0 +1,23 @@
+package cli
+
+import (
+	"bastion/models"
+	"fmt"
+)
+
+// printStopAllResult lists the outcome of `stop --all` per mapping.
+func printStopAllResult(resp *models.MappingStopAllResponse) {
+	if len(resp.Results) == 0 {
+		fmt.Println("No running mappings.")
+		return
+	}
+	for _, r := range resp.Results {
+		id := models.WorkspaceKey(r.Workspace, r.ID)
+		if r.Stopped {
+			fmt.Printf("  ✓ %s  %dms\n", id, r.DurationMS)
+		} else {
+			fmt.Printf("  ✗ %s  %s\n", id, r.Error)
+		}
+	}
+	fmt.Printf("Stopped %d mapping(s), %d failed.\n", resp.Stopped, resp.Failed)
+}
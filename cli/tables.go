@@ -0,0 +1,204 @@
+package cli
+
+import (
+	"bastion/core"
+	"bastion/models"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var bastionColumns = []column{
+	{title: "ID", width: 4},
+	{title: "Name", width: 20, truncate: true},
+	{title: "Host", width: 20, truncate: true},
+	{title: "Port", width: 6},
+	{title: "Username", width: 15, truncate: true},
+	{title: "Auth", width: 10},
+	{title: "Tags", width: 20, truncate: true},
+}
+
+func bastionRows(bastions []models.Bastion) [][]string {
+	rows := make([][]string, 0, len(bastions))
+	for _, b := range bastions {
+		auth := "Password"
+		if b.PkeyPath != "" {
+			auth = "Key"
+		}
+		rows = append(rows, []string{strconv.FormatUint(uint64(b.ID), 10), b.Name, b.Host, strconv.Itoa(b.Port), b.Username, auth, formatTags(b.GetTags())})
+	}
+	return rows
+}
+
+// redactBastions returns copies of bastions without passwords and key passphrases, for output that
+// tends to end up in files and pipelines.
+func redactBastions(bastions []models.Bastion) []models.Bastion {
+	redacted := make([]models.Bastion, len(bastions))
+	for i, b := range bastions {
+		b.Password = ""
+		b.PkeyPassphrase = ""
+		redacted[i] = b
+	}
+	return redacted
+}
+
+var mappingColumns = []column{
+	{title: "ID", width: 20, truncate: true},
+	{title: "Local", width: 18},
+	{title: "Remote", width: 18},
+	{title: "Type", width: 8},
+	{title: "Chain", width: 25, truncate: true},
+	{title: "Status", width: 10},
+	{title: "Tags", width: 20, truncate: true},
+}
+
+func mappingRows(mappings []models.MappingRead) [][]string {
+	rows := make([][]string, 0, len(mappings))
+	for _, m := range mappings {
+		status := "Stopped"
+		if m.Running {
+			status = "Running"
+		}
+		rows = append(rows, []string{
+			m.ID,
+			formatLocal(listenHost(m), m.LocalPort, m.RuntimePort),
+			formatRemote(m),
+			m.Type,
+			strings.Join(m.Chain, " → "),
+			status,
+			formatTags(m.Tags),
+		})
+	}
+	return rows
+}
+
+var httpLogColumns = []column{
+	{title: "ID", width: 6},
+	{title: "Code", width: 6},
+	{title: "Method", width: 8},
+	{title: "Host", width: 25, truncate: true},
+	{title: "URL", width: 35, truncate: true},
+	{title: "Time", width: 10},
+}
+
+// httpLogRows shows the time of day in the table format and the full timestamp otherwise.
+func httpLogRows(logs []*core.HTTPLog, format string) [][]string {
+	rows := make([][]string, 0, len(logs))
+	for _, log := range logs {
+		timestamp := log.Timestamp.Format("15:04:05")
+		if format != outputTable {
+			timestamp = log.Timestamp.Format(time.RFC3339)
+		}
+		rows = append(rows, []string{strconv.Itoa(log.ID), strconv.Itoa(log.StatusCode), log.Method, log.Host, log.URL, timestamp})
+	}
+	return rows
+}
+
+// httpLogPage is the JSON output of http list and http search.
+type httpLogPage struct {
+	Page     int             `json:"page"`
+	PageSize int             `json:"page_size"`
+	Total    int             `json:"total"`
+	Logs     []*core.HTTPLog `json:"logs"`
+}
+
+func newHTTPLogPage(logs []*core.HTTPLog, page, pageSize, total int) httpLogPage {
+	if logs == nil {
+		logs = []*core.HTTPLog{}
+	}
+	return httpLogPage{Page: page, PageSize: pageSize, Total: total, Logs: logs}
+}
+
+var sessionColumns = []column{
+	{title: "Mapping ID", width: 20, truncate: true},
+	{title: "Connections", width: 15},
+	{title: "Bytes Up", width: 15},
+	{title: "Bytes Down", width: 15},
+	{title: "Up/s (10s)", width: 12},
+	{title: "Down/s (10s)", width: 12},
+}
+
+// sessionRows sorts sessions by mapping ID; byte counts and rates are humanized except in CSV.
+func sessionRows(stats map[string]core.SessionStats, format string) [][]string {
+	ids := make([]string, 0, len(stats))
+	for id := range stats {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	bytes := formatBytes
+	rate := func(bps int64) string { return formatBytes(bps) + "/s" }
+	if format == outputCSV {
+		bytes = func(n int64) string { return strconv.FormatInt(n, 10) }
+		rate = bytes
+	}
+	rows := make([][]string, 0, len(ids))
+	for _, id := range ids {
+		s := stats[id]
+		rows = append(rows, []string{id, strconv.Itoa(int(s.ActiveConns)), bytes(s.BytesUp), bytes(s.BytesDown),
+			rate(s.Throughput.UpBps10s), rate(s.Throughput.DownBps10s)})
+	}
+	return rows
+}
+
+// statsSummary is the JSON output of stats.
+type statsSummary struct {
+	ActiveSessions   int                          `json:"active_sessions"`
+	TotalConnections int32                        `json:"total_connections"`
+	BytesUp          int64                        `json:"up_bytes"`
+	BytesDown        int64                        `json:"down_bytes"`
+	Sessions         map[string]core.SessionStats `json:"sessions"`
+}
+
+func summarizeStats(stats map[string]core.SessionStats) statsSummary {
+	summary := statsSummary{ActiveSessions: len(stats), Sessions: stats}
+	if summary.Sessions == nil {
+		summary.Sessions = map[string]core.SessionStats{}
+	}
+	for _, s := range stats {
+		summary.TotalConnections += s.ActiveConns
+		summary.BytesUp += s.BytesUp
+		summary.BytesDown += s.BytesDown
+	}
+	return summary
+}
+
+var statsColumns = []column{
+	{title: "Active Sessions"},
+	{title: "Total Connections"},
+	{title: "Up Bytes"},
+	{title: "Down Bytes"},
+}
+
+func (s statsSummary) rows() [][]string {
+	return [][]string{{
+		strconv.Itoa(s.ActiveSessions),
+		strconv.Itoa(int(s.TotalConnections)),
+		strconv.FormatInt(s.BytesUp, 10),
+		strconv.FormatInt(s.BytesDown, 10),
+	}}
+}
+
+// printStatsSummary prints the stats command in the table and wide formats.
+func printStatsSummary(s statsSummary) {
+	fmt.Println()
+	PrintBanner("Traffic Statistics")
+	fmt.Println()
+
+	fmt.Printf("Active Sessions:     %d\n", s.ActiveSessions)
+	fmt.Printf("Total Connections:   %d\n", s.TotalConnections)
+	fmt.Printf("Total Bytes Up:      %s\n", formatBytes(s.BytesUp))
+	fmt.Printf("Total Bytes Down:    %s\n", formatBytes(s.BytesDown))
+	fmt.Printf("Total Traffic:       %s\n", formatBytes(s.BytesUp+s.BytesDown))
+}
+
+// formatRemote formats the remote of a mapping, showing an unresolved port template as is.
+func formatRemote(m models.MappingRead) string {
+	if m.RemotePortTemplate != "" {
+		return net.JoinHostPort(m.RemoteHost, m.RemotePortTemplate)
+	}
+	return net.JoinHostPort(m.RemoteHost, strconv.Itoa(m.RemotePort))
+}
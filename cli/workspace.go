@@ -0,0 +1,41 @@
+package cli
+
+import (
+	"bastion/models"
+	"fmt"
+	"strings"
+)
+
+// workspacePrompt returns the input prompt, prefixed with the workspace unless it is the default.
+func workspacePrompt(workspace string) string {
+	if workspace == "" || workspace == models.DefaultWorkspace {
+		return "> "
+	}
+	return fmt.Sprintf("[%s]> ", workspace)
+}
+
+// parseWorkspaceArg validates the workspace given to `workspace use`.
+func parseWorkspaceArg(arg string) (string, error) {
+	workspace := models.NormalizeWorkspace(arg)
+	if err := models.ValidateWorkspaceName(workspace); err != nil {
+		return "", err
+	}
+	return workspace, nil
+}
+
+// printWorkspaces renders workspaces, marking the current one.
+func printWorkspaces(workspaces []models.WorkspaceSummary, current string) {
+	fmt.Println()
+	PrintBanner(fmt.Sprintf("Workspaces: %d", len(workspaces)))
+	fmt.Println()
+
+	fmt.Printf("  %-24s %-10s %-10s %-10s\n", "Name", "Bastions", "Mappings", "Running")
+	fmt.Println(strings.Repeat("-", 60))
+	for _, ws := range workspaces {
+		marker := " "
+		if ws.Name == current {
+			marker = "*"
+		}
+		fmt.Printf("%s %-24s %-10d %-10d %-10d\n", marker, truncate(ws.Name, 24), ws.Bastions, ws.Mappings, ws.Running)
+	}
+}
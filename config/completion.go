@@ -0,0 +1,89 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// completionShells are the shells CompletionScript supports.
+var completionShells = []string{"bash", "zsh", "powershell"}
+
+// flagValueChoices lists fixed values offered after flags that take one.
+var flagValueChoices = map[string][]string{
+	"api-compat": {APICompatV1, APICompatNone},
+	"completion": completionShells,
+	"log-level":  {"DEBUG", "INFO", "WARN", "ERROR"},
+}
+
+// CompletionScript returns a completion script for the command-line flags of program.
+func CompletionScript(shell, program string) (string, error) {
+	program = strings.TrimSuffix(filepath.Base(program), ".exe")
+
+	type flagInfo struct{ name, usage string }
+	var flags []flagInfo
+	flag.VisitAll(func(f *flag.Flag) {
+		flags = append(flags, flagInfo{name: "--" + f.Name, usage: f.Usage})
+	})
+	sort.Slice(flags, func(i, j int) bool { return flags[i].name < flags[j].name })
+
+	var b strings.Builder
+	fn := "_" + strings.NewReplacer("-", "_", ".", "_").Replace(program)
+	switch shell {
+	case "bash":
+		names := make([]string, len(flags))
+		for i, f := range flags {
+			names[i] = f.name
+		}
+		fmt.Fprintf(&b, "# bash completion for %s; load with: source <(%s --completion bash)\n", program, program)
+		fmt.Fprintf(&b, "%s() {\n", fn)
+		b.WriteString("  local cur=\"${COMP_WORDS[COMP_CWORD]}\" prev=\"${COMP_WORDS[COMP_CWORD-1]}\"\n")
+		b.WriteString("  case \"$prev\" in\n")
+		for _, name := range sortedChoiceFlags() {
+			fmt.Fprintf(&b, "    --%s) COMPREPLY=( $(compgen -W \"%s\" -- \"$cur\") ); return ;;\n", name, strings.Join(flagValueChoices[name], " "))
+		}
+		b.WriteString("  esac\n")
+		fmt.Fprintf(&b, "  COMPREPLY=( $(compgen -W \"%s\" -- \"$cur\") )\n", strings.Join(names, " "))
+		b.WriteString("}\n")
+		fmt.Fprintf(&b, "complete -o default -F %s %s\n", fn, program)
+	case "zsh":
+		fmt.Fprintf(&b, "#compdef %s\n# zsh completion for %s; load with: source <(%s --completion zsh)\n", program, program, program)
+		fmt.Fprintf(&b, "%s() {\n  _arguments \\\n", fn)
+		for _, f := range flags {
+			usage := strings.NewReplacer("[", "(", "]", ")", "'", "").Replace(f.usage)
+			action := ""
+			if choices, ok := flagValueChoices[strings.TrimPrefix(f.name, "--")]; ok {
+				action = fmt.Sprintf(":value:(%s)", strings.Join(choices, " "))
+			}
+			fmt.Fprintf(&b, "    '%s[%s]%s' \\\n", f.name, usage, action)
+		}
+		b.WriteString("    '*:file:_files'\n}\n")
+		fmt.Fprintf(&b, "compdef %s %s\n", fn, program)
+	case "powershell":
+		fmt.Fprintf(&b, "# PowerShell completion for %s; load with: %s --completion powershell | Out-String | Invoke-Expression\n", program, program)
+		fmt.Fprintf(&b, "Register-ArgumentCompleter -Native -CommandName @('%s', '%s.exe') -ScriptBlock {\n", program, program)
+		b.WriteString("    param($wordToComplete, $commandAst, $cursorPosition)\n")
+		b.WriteString("    $flags = @{\n")
+		for _, f := range flags {
+			fmt.Fprintf(&b, "        '%s' = '%s'\n", f.name, strings.ReplaceAll(f.usage, "'", "''"))
+		}
+		b.WriteString("    }\n")
+		b.WriteString("    $flags.Keys | Where-Object { $_ -like \"$wordToComplete*\" } | Sort-Object | ForEach-Object {\n")
+		b.WriteString("        [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $flags[$_])\n")
+		b.WriteString("    }\n}\n")
+	default:
+		return "", fmt.Errorf("unsupported shell %q (supported: %s)", shell, strings.Join(completionShells, ", "))
+	}
+	return b.String(), nil
+}
+
+func sortedChoiceFlags() []string {
+	names := make([]string, 0, len(flagValueChoices))
+	for name := range flagValueChoices {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
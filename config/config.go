@@ -5,14 +5,22 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 )
 
 // Config holds Bastion runtime configuration.
 type Config struct {
 	LogLevel                        string
 	LogFilePath                     string
+	LogMaxSizeMB                    int    // rotate the log beyond this size, 0 = only at startup
+	LogMaxBackups                   int    // rotated logs kept (at least 1)
+	LogMaxAgeDays                   int    // rotated logs older than this are deleted, 0 = no age limit
+	LogCompress                     bool   // gzip rotated logs except the newest
+	Language                        string // en or zh for API messages and CLI output, empty = per request / locale
 	Port                            int
+	BindAddress                     string // empty: use the address chosen in setup, else 0.0.0.0
 	DatabaseURL                     string
 	SQLitePragmasEnabled            bool
 	SQLiteBusyTimeoutMS             int
@@ -23,23 +31,52 @@ type Config struct {
 	SQLiteMaxIdleConns              int
 	SQLiteConnMaxIdleSec            int
 	SQLiteConnMaxLifeSec            int
+	DBMaxOpenConns                  int // connection pool of Postgres and MySQL databases
+	DBMaxIdleConns                  int
+	DBConnMaxIdleSec                int
+	DBConnMaxLifeSec                int
 	SSHConnectTimeout               int
+	SSHHandshakeTimeoutSeconds      int // SSH handshake and authentication of one hop
 	SSHKeepaliveInterval            int
 	SSHPoolMaxConns                 int
 	SSHPoolIdleTimeoutSeconds       int
 	SSHPoolKeepaliveIntervalSeconds int
 	SSHPoolKeepaliveTimeoutMS       int
-	AuditEnabled                    bool
-	CLIMode                         bool
-	CLIServer                       string // Server URL for CLI mode
+	SSHChainPrefixReuse             bool // build chains on pooled prefixes and pool the prefixes they build
+	SSHPoolMaxClientsPerChain       int  // clients a chain may open when jump hosts refuse more channels
+	// Chain build limits (0 = unlimited): chains built at once, connections opened to one bastion at
+	// once, and how long a build waits for a slot (0 = no timeout).
+	SSHChainBuildConcurrency         int
+	SSHChainBuildPerBastion          int
+	SSHChainBuildQueueTimeoutSeconds int
+	AuditEnabled                     bool
+	CLIMode                          bool
+	CLIServer                        string // Server URL for CLI mode
+	CLIHistoryFile                   string // CLI command history file; "off" disables persistence
+	CLIExec                          string // one-shot CLI command (-e or `bastion cli <command>`); empty runs the REPL
+	MigrateStatus                    bool   // print database migration status and exit
+	SelfCheck                        bool   // run the startup self-checks, print the report and exit
 
 	// Tunable limits and timeouts
 	MaxSessionConnections              int
+	MaxConnsPerIP                      int     // per-client-IP concurrent connections per session, 0 = unlimited
+	ConnRatePerIP                      float64 // new connections per second per client IP, 0 = unlimited
+	ConnBurstPerIP                     int     // token bucket burst for ConnRatePerIP
 	ForwardBufferSize                  int
 	AuditQueueSize                     int
+	AuditWorkers                       int
+	AuditBatchSize                     int
+	AuditQueueBlocking                 bool    // block forwarding instead of dropping when the audit queue is full
+	AuditSampleRate                    float64 // fraction of connections audited (more than 0, at most 1); mappings may override
 	MaxHTTPLogs                        int
+	MaxHTTPLogBytes                    int64 // estimated bytes retained by in-memory HTTP logs, 0 = unlimited
 	HTTPPairCleanupIntervalMinutes     int
 	HTTPPairMaxAgeMinutes              int
+	HTTPPairStrategy                   string // "sequence" (default) or "fifo"
+	HTTPPairMaxPending                 int    // pending requests kept per connection, 0 = unlimited
+	AuditTrustedProxies                string // comma-separated proxy IPs/CIDRs whose X-Forwarded-For/Forwarded headers name the client in HTTP logs
+	AuditSkipBodyTypes                 string // comma-separated response media types (image/* style) whose bodies HTTP logs leave out; "off" keeps all
+	AuditSkipBodyBytes                 int    // response bodies larger than this are left out of HTTP logs, 0 = no limit
 	GoroutineMonitorIntervalSeconds    int
 	GoroutineWarnThreshold             int
 	Socks5HandshakeTimeoutSeconds      int
@@ -50,16 +87,125 @@ type Config struct {
 	TransferWriteTimeoutSeconds        int
 	SSHConnectMaxRetries               int
 	SSHConnectRetryDelaySeconds        int
+	DialTimeoutSeconds                 int    // per-attempt timeout for remote dials
+	DialRetries                        int    // dial attempts through the bastion chain
+	DialRetryDelayMS                   int    // delay before the second dial attempt
+	DialBackoff                        string // fixed or exponential
+	DialHoldSeconds                    int    // how long clients wait for a chain that is down; 0 disables
+	DialHappyEyeballs                  bool   // resolve remote names locally and race their addresses through the chain
 
 	// HTTP audit log gzip decode (on-demand)
 	HTTPGzipDecodeMaxBytes     int
 	HTTPGzipDecodeTimeoutMS    int
 	HTTPGzipDecodeCacheSeconds int
+
+	// HTTP log compare: max body bytes per side fed into the line diff
+	HTTPCompareMaxBodyBytes int
+
+	// Persisted error log retention
+	ErrorLogRetentionDays int
+	ErrorLogMaxRows       int
+
+	// Management API requests kept in memory for GET /api/v2/access-logs, 0 disables
+	AccessLogMax int
+
+	// Database query audit (mappings with query_audit): statements kept in memory for
+	// GET /api/v2/query-logs (0 disables), the size a statement is cut at, and what is masked
+	// (none, secrets or literals)
+	QueryLogMax        int
+	QueryAuditMaxBytes int
+	QueryAuditRedact   string
+
+	// Log the ClientHello (SNI, ALPN, version) of TLS forwarded through tcp mappings, SOCKS5 and
+	// HTTP CONNECT, and export it as a tls_client_hello event
+	TLSSNILog bool
+
+	// Per-mapping lifecycle event history
+	MappingEventsMax int
+
+	// Lifetime per-mapping traffic counters
+	UsageFlushIntervalSeconds int
+
+	// Standby mappings close their SSH chain after this many idle seconds (mappings may override)
+	StandbyIdleSeconds int
+
+	// Per-mapping daily traffic quotas
+	QuotaResetHour   int // local hour (0-23) at which daily quota counters reset
+	QuotaThrottleBps int // bytes per second per connection once a "throttle" quota is used up (mappings may override)
+
+	// Scheduled SQLite backups
+	DBBackupDir             string // directory for scheduled backups (relative save paths resolve here too)
+	DBBackupIntervalMinutes int    // 0 disables scheduled backups
+	DBBackupKeep            int    // scheduled backups kept; older ones are deleted
+
+	// Security event export (SIEM)
+	EventSinkURL         string // http(s):// endpoint, syslog://host:port (UDP) or syslog+tcp://host:port; empty disables
+	EventSinkToken       string // bearer token sent to an HTTP sink
+	EventTypes           string // comma-separated event types to export, empty exports all
+	EventBatchSize       int
+	EventFlushIntervalMS int
+	EventQueueSize       int
+
+	// mDNS advertisement of exposed mappings
+	MDNSAdvertise bool // announce running exposed mappings as _bastion._tcp services on the LAN
+
+	// Active/standby pair
+	HARole             string // "active" or "standby"; empty runs a standalone instance
+	HAPeerURL          string // base URL of the other instance, e.g. http://10.0.0.2:7788
+	HAPeerToken        string // admin token of the other instance
+	HACheckIntervalSec int    // seconds between the standby's health checks of the active
+	HAFailoverAfter    int    // consecutive failed health checks before the standby takes over
+	HASyncIntervalSec  int    // seconds between configuration syncs from the active, 0 disables (shared database)
+
+	// Agent mode
+	AgentCentralURL  string // base URL of the central instance; empty runs a standalone instance
+	AgentToken       string // agent token issued by the central instance (POST /api/v2/agents), or its admin token
+	AgentName        string // name the agent registers under (default: host name)
+	AgentWorkspace   string // workspace of the central instance the agent runs (default: agent name)
+	AgentIntervalSec int    // seconds between syncs with the central instance
+
+	// Alerting (webhook / SMTP)
+	AlertWebhookURLs               string // comma-separated
+	AlertWebhookTemplate           string // optional text/template for the webhook body
+	AlertSMTPHost                  string
+	AlertSMTPPort                  int
+	AlertSMTPUsername              string
+	AlertSMTPPassword              string
+	AlertSMTPFrom                  string
+	AlertSMTPTo                    string // comma-separated
+	AlertCooldownSeconds           int
+	AlertMaxPerMinute              int
+	AlertKeepaliveFailureThreshold int
+	AlertAuditDropsPerMinute       int
+
+	// Self-update
+	UpdateCheckIntervalMinutes int    // background update checks, 0 disables
+	UpdateRequireChecksum      bool   // refuse releases without a SHA256SUMS asset
+	UpdateMinisignPublicKey    string // when set, SHA256SUMS must carry a valid minisign signature by this key
+
+	// Management API rate limits per client IP, 0 disables
+	APIRatePerIP         float64 // mutating API requests per second
+	APIBurstPerIP        int     // token bucket burst for APIRatePerIP
+	APICodeRatePerMinute int     // confirmation code generation/verification requests per minute
+
+	// Metrics endpoint protection, separate from the admin token
+	MetricsToken string // bearer token required by /metrics and /api/metrics
+	MetricsAllow string // comma-separated client IPs/CIDRs allowed to read the metrics
+
+	DebugEndpoints bool // serve net/http/pprof under /debug/pprof (admin token required)
+
+	APICompat string // "v1" also mounts the deprecated /api routes, "none" serves only /api/v2
 }
 
 // Settings is the global configuration instance populated from environment variables and flags.
 var Settings *Config
 
+// API_COMPAT values
+const (
+	APICompatV1   = "v1"
+	APICompatNone = "none"
+)
+
 // init initializes the package-level Settings with default configuration values sourced from environment variables.
 // It sets logging, server, SQLite pragmas and connection parameters, SSH timeouts, audit/CLI flags, and various tunable limits using environment overrides or sensible defaults.
 func init() {
@@ -68,34 +214,64 @@ func init() {
 	transferTimeoutSeconds := sessionIdleTimeoutHours * 3600
 
 	Settings = &Config{
-		LogLevel:                        getEnv("LOG_LEVEL", "INFO"),
-		LogFilePath:                     getEnv("LOG_FILE", "./bastion.log"),
-		Port:                            getEnvInt("PORT", 7788),
-		DatabaseURL:                     getEnv("DATABASE_URL", "bastion.db"),
-		SQLitePragmasEnabled:            getEnvBool("SQLITE_PRAGMAS_ENABLED", true),
-		SQLiteBusyTimeoutMS:             getEnvInt("SQLITE_BUSY_TIMEOUT_MS", 5000),
-		SQLiteJournalMode:               getEnv("SQLITE_JOURNAL_MODE", "WAL"),
-		SQLiteSynchronous:               getEnv("SQLITE_SYNCHRONOUS", "NORMAL"),
-		SQLiteForeignKeys:               getEnvBool("SQLITE_FOREIGN_KEYS", true),
-		SQLiteMaxOpenConns:              getEnvInt("SQLITE_MAX_OPEN_CONNS", 1),
-		SQLiteMaxIdleConns:              getEnvInt("SQLITE_MAX_IDLE_CONNS", 1),
-		SQLiteConnMaxIdleSec:            getEnvInt("SQLITE_CONN_MAX_IDLE_SECONDS", 300),
-		SQLiteConnMaxLifeSec:            getEnvInt("SQLITE_CONN_MAX_LIFETIME_SECONDS", 0),
-		SSHConnectTimeout:               getEnvInt("SSH_CONNECT_TIMEOUT", 15),
-		SSHKeepaliveInterval:            getEnvInt("SSH_KEEPALIVE_INTERVAL", 30),
-		SSHPoolMaxConns:                 getEnvInt("SSH_POOL_MAX_CONNS", 64),
-		SSHPoolIdleTimeoutSeconds:       getEnvInt("SSH_POOL_IDLE_TIMEOUT_SECONDS", 900),
-		SSHPoolKeepaliveIntervalSeconds: getEnvInt("SSH_POOL_KEEPALIVE_INTERVAL_SECONDS", 30),
-		SSHPoolKeepaliveTimeoutMS:       getEnvInt("SSH_POOL_KEEPALIVE_TIMEOUT_MS", 500),
-		AuditEnabled:                    getEnvBool("AUDIT_ENABLED", true),
-		CLIMode:                         getEnvBool("CLI_MODE", false),
+		LogLevel:                         getEnv("LOG_LEVEL", "INFO"),
+		LogFilePath:                      getEnv("LOG_FILE", "./bastion.log"),
+		LogMaxSizeMB:                     getEnvInt("LOG_MAX_SIZE_MB", 100),
+		LogMaxBackups:                    getEnvInt("LOG_MAX_BACKUPS", 5),
+		LogMaxAgeDays:                    getEnvInt("LOG_MAX_AGE_DAYS", 30),
+		LogCompress:                      getEnvBool("LOG_COMPRESS", false),
+		Language:                         getEnv("BASTION_LANG", ""),
+		Port:                             getEnvInt("PORT", 7788),
+		BindAddress:                      getEnv("BIND_ADDRESS", ""),
+		DatabaseURL:                      getEnv("DATABASE_URL", "bastion.db"),
+		SQLitePragmasEnabled:             getEnvBool("SQLITE_PRAGMAS_ENABLED", true),
+		SQLiteBusyTimeoutMS:              getEnvInt("SQLITE_BUSY_TIMEOUT_MS", 5000),
+		SQLiteJournalMode:                getEnv("SQLITE_JOURNAL_MODE", "WAL"),
+		SQLiteSynchronous:                getEnv("SQLITE_SYNCHRONOUS", "NORMAL"),
+		SQLiteForeignKeys:                getEnvBool("SQLITE_FOREIGN_KEYS", true),
+		SQLiteMaxOpenConns:               getEnvInt("SQLITE_MAX_OPEN_CONNS", 1),
+		SQLiteMaxIdleConns:               getEnvInt("SQLITE_MAX_IDLE_CONNS", 1),
+		SQLiteConnMaxIdleSec:             getEnvInt("SQLITE_CONN_MAX_IDLE_SECONDS", 300),
+		SQLiteConnMaxLifeSec:             getEnvInt("SQLITE_CONN_MAX_LIFETIME_SECONDS", 0),
+		DBMaxOpenConns:                   getEnvInt("DB_MAX_OPEN_CONNS", 10),
+		DBMaxIdleConns:                   getEnvInt("DB_MAX_IDLE_CONNS", 5),
+		DBConnMaxIdleSec:                 getEnvInt("DB_CONN_MAX_IDLE_SECONDS", 300),
+		DBConnMaxLifeSec:                 getEnvInt("DB_CONN_MAX_LIFETIME_SECONDS", 1800),
+		SSHConnectTimeout:                getEnvInt("SSH_CONNECT_TIMEOUT", 15),
+		SSHHandshakeTimeoutSeconds:       getEnvInt("SSH_HANDSHAKE_TIMEOUT_SECONDS", 30),
+		SSHKeepaliveInterval:             getEnvInt("SSH_KEEPALIVE_INTERVAL", 30),
+		SSHPoolMaxConns:                  getEnvInt("SSH_POOL_MAX_CONNS", 64),
+		SSHPoolIdleTimeoutSeconds:        getEnvInt("SSH_POOL_IDLE_TIMEOUT_SECONDS", 900),
+		SSHPoolKeepaliveIntervalSeconds:  getEnvInt("SSH_POOL_KEEPALIVE_INTERVAL_SECONDS", 30),
+		SSHPoolKeepaliveTimeoutMS:        getEnvInt("SSH_POOL_KEEPALIVE_TIMEOUT_MS", 500),
+		SSHChainPrefixReuse:              getEnvBool("SSH_CHAIN_PREFIX_REUSE", true),
+		SSHPoolMaxClientsPerChain:        getEnvInt("SSH_POOL_MAX_CLIENTS_PER_CHAIN", 4),
+		SSHChainBuildConcurrency:         getEnvInt("SSH_CHAIN_BUILD_CONCURRENCY", 8),
+		SSHChainBuildPerBastion:          getEnvInt("SSH_CHAIN_BUILD_PER_BASTION", 2),
+		SSHChainBuildQueueTimeoutSeconds: getEnvInt("SSH_CHAIN_BUILD_QUEUE_TIMEOUT_SECONDS", 30),
+		AuditEnabled:                     getEnvBool("AUDIT_ENABLED", true),
+		CLIMode:                          getEnvBool("CLI_MODE", false),
+		CLIHistoryFile:                   getEnv("CLI_HISTORY_FILE", defaultCLIHistoryFile()),
 
 		MaxSessionConnections:              getEnvInt("MAX_SESSION_CONNECTIONS", 1000),
-		ForwardBufferSize:                  getEnvInt("FORWARD_BUFFER_SIZE", 32768),
+		MaxConnsPerIP:                      getEnvInt("MAX_CONNS_PER_IP", 0),
+		ConnRatePerIP:                      getEnvFloat("CONN_RATE_PER_IP", 0),
+		ConnBurstPerIP:                     getEnvInt("CONN_BURST_PER_IP", 10),
+		ForwardBufferSize:                  getEnvInt("FORWARD_BUFFER_SIZE", 262144),
 		AuditQueueSize:                     getEnvInt("AUDIT_QUEUE_SIZE", 1000),
+		AuditWorkers:                       getEnvInt("AUDIT_WORKERS", 2),
+		AuditBatchSize:                     getEnvInt("AUDIT_BATCH_SIZE", 64),
+		AuditQueueBlocking:                 getEnvBool("AUDIT_QUEUE_BLOCKING", false),
+		AuditSampleRate:                    getEnvFloat("AUDIT_SAMPLE_RATE", 1),
 		MaxHTTPLogs:                        getEnvInt("MAX_HTTP_LOGS", 1000),
+		MaxHTTPLogBytes:                    int64(getEnvInt("MAX_HTTP_LOG_BYTES", 268435456)),
 		HTTPPairCleanupIntervalMinutes:     getEnvInt("HTTP_PAIR_CLEANUP_INTERVAL_MINUTES", 5),
 		HTTPPairMaxAgeMinutes:              getEnvInt("HTTP_PAIR_MAX_AGE_MINUTES", 10),
+		HTTPPairStrategy:                   getEnv("HTTP_PAIR_STRATEGY", "sequence"),
+		HTTPPairMaxPending:                 getEnvInt("HTTP_PAIR_MAX_PENDING", 32),
+		AuditTrustedProxies:                getEnv("AUDIT_TRUSTED_PROXIES", ""),
+		AuditSkipBodyTypes:                 getEnv("AUDIT_SKIP_BODY_TYPES", "image/*,video/*,audio/*,font/*"),
+		AuditSkipBodyBytes:                 getEnvInt("AUDIT_SKIP_BODY_BYTES", 0),
 		GoroutineMonitorIntervalSeconds:    getEnvInt("GOROUTINE_MONITOR_INTERVAL_SECONDS", 30),
 		GoroutineWarnThreshold:             getEnvInt("GOROUTINE_WARN_THRESHOLD", 1000),
 		Socks5HandshakeTimeoutSeconds:      socks5HandshakeTimeoutSeconds,
@@ -106,10 +282,91 @@ func init() {
 		TransferWriteTimeoutSeconds:        getEnvInt("TRANSFER_WRITE_TIMEOUT_SECONDS", transferTimeoutSeconds),
 		SSHConnectMaxRetries:               getEnvInt("SSH_CONNECT_MAX_RETRIES", 3),
 		SSHConnectRetryDelaySeconds:        getEnvInt("SSH_CONNECT_RETRY_DELAY_SECONDS", 2),
+		DialTimeoutSeconds:                 getEnvInt("DIAL_TIMEOUT_SECONDS", 10),
+		DialRetries:                        getEnvInt("DIAL_RETRIES", 3),
+		DialRetryDelayMS:                   getEnvInt("DIAL_RETRY_DELAY_MS", 1000),
+		DialBackoff:                        getEnv("DIAL_BACKOFF", "fixed"),
+		DialHoldSeconds:                    getEnvInt("DIAL_HOLD_SECONDS", 0),
+		DialHappyEyeballs:                  getEnvBool("DIAL_HAPPY_EYEBALLS", false),
 
 		HTTPGzipDecodeMaxBytes:     getEnvInt("HTTP_GZIP_DECODE_MAX_BYTES", 1048576),
 		HTTPGzipDecodeTimeoutMS:    getEnvInt("HTTP_GZIP_DECODE_TIMEOUT_MS", 500),
 		HTTPGzipDecodeCacheSeconds: getEnvInt("HTTP_GZIP_DECODE_CACHE_SECONDS", 60),
+		HTTPCompareMaxBodyBytes:    getEnvInt("HTTP_COMPARE_MAX_BODY_BYTES", 65536),
+
+		ErrorLogRetentionDays: getEnvInt("ERROR_LOG_RETENTION_DAYS", 30),
+		ErrorLogMaxRows:       getEnvInt("ERROR_LOG_MAX_ROWS", 10000),
+
+		AccessLogMax: getEnvInt("ACCESS_LOG_MAX", 2000),
+
+		QueryLogMax:        getEnvInt("QUERY_LOG_MAX", 5000),
+		QueryAuditMaxBytes: getEnvInt("QUERY_AUDIT_MAX_BYTES", 4096),
+		QueryAuditRedact:   getEnv("QUERY_AUDIT_REDACT", "secrets"),
+
+		TLSSNILog: getEnvBool("TLS_SNI_LOG", true),
+
+		MappingEventsMax: getEnvInt("MAPPING_EVENTS_MAX", 50),
+
+		UsageFlushIntervalSeconds: getEnvInt("USAGE_FLUSH_INTERVAL_SECONDS", 60),
+
+		StandbyIdleSeconds: getEnvInt("STANDBY_IDLE_SECONDS", 300),
+
+		QuotaResetHour:   getEnvInt("QUOTA_RESET_HOUR", 0),
+		QuotaThrottleBps: getEnvInt("QUOTA_THROTTLE_BPS", 65536),
+
+		DBBackupDir:             getEnv("DB_BACKUP_DIR", "backups"),
+		DBBackupIntervalMinutes: getEnvInt("DB_BACKUP_INTERVAL_MINUTES", 0),
+		DBBackupKeep:            getEnvInt("DB_BACKUP_KEEP", 7),
+
+		EventSinkURL:         getEnv("EVENT_SINK_URL", ""),
+		EventSinkToken:       getEnv("EVENT_SINK_TOKEN", ""),
+		EventTypes:           getEnv("EVENT_TYPES", ""),
+		EventBatchSize:       getEnvInt("EVENT_BATCH_SIZE", 100),
+		EventFlushIntervalMS: getEnvInt("EVENT_FLUSH_INTERVAL_MS", 2000),
+		EventQueueSize:       getEnvInt("EVENT_QUEUE_SIZE", 10000),
+
+		MDNSAdvertise: getEnvBool("MDNS_ADVERTISE", false),
+
+		HARole:             strings.ToLower(strings.TrimSpace(getEnv("HA_ROLE", ""))),
+		HAPeerURL:          getEnv("HA_PEER_URL", ""),
+		HAPeerToken:        getEnv("HA_PEER_TOKEN", ""),
+		HACheckIntervalSec: getEnvInt("HA_CHECK_INTERVAL_SECONDS", 5),
+		HAFailoverAfter:    getEnvInt("HA_FAILOVER_THRESHOLD", 3),
+		HASyncIntervalSec:  getEnvInt("HA_SYNC_INTERVAL_SECONDS", 60),
+
+		AgentCentralURL:  getEnv("AGENT_CENTRAL_URL", ""),
+		AgentToken:       getEnv("AGENT_TOKEN", ""),
+		AgentName:        strings.TrimSpace(getEnv("AGENT_NAME", "")),
+		AgentWorkspace:   strings.TrimSpace(getEnv("AGENT_WORKSPACE", "")),
+		AgentIntervalSec: getEnvInt("AGENT_INTERVAL_SECONDS", 15),
+
+		AlertWebhookURLs:               getEnv("ALERT_WEBHOOK_URLS", ""),
+		AlertWebhookTemplate:           getEnv("ALERT_WEBHOOK_TEMPLATE", ""),
+		AlertSMTPHost:                  getEnv("ALERT_SMTP_HOST", ""),
+		AlertSMTPPort:                  getEnvInt("ALERT_SMTP_PORT", 587),
+		AlertSMTPUsername:              getEnv("ALERT_SMTP_USERNAME", ""),
+		AlertSMTPPassword:              getEnv("ALERT_SMTP_PASSWORD", ""),
+		AlertSMTPFrom:                  getEnv("ALERT_SMTP_FROM", ""),
+		AlertSMTPTo:                    getEnv("ALERT_SMTP_TO", ""),
+		AlertCooldownSeconds:           getEnvInt("ALERT_COOLDOWN_SECONDS", 300),
+		AlertMaxPerMinute:              getEnvInt("ALERT_MAX_PER_MINUTE", 10),
+		AlertKeepaliveFailureThreshold: getEnvInt("ALERT_KEEPALIVE_FAILURE_THRESHOLD", 3),
+		AlertAuditDropsPerMinute:       getEnvInt("ALERT_AUDIT_DROPS_PER_MINUTE", 100),
+
+		UpdateCheckIntervalMinutes: getEnvInt("UPDATE_CHECK_INTERVAL_MINUTES", 0),
+		UpdateRequireChecksum:      getEnvBool("UPDATE_REQUIRE_CHECKSUM", true),
+		UpdateMinisignPublicKey:    getEnv("UPDATE_MINISIGN_PUBKEY", ""),
+
+		APIRatePerIP:         getEnvFloat("API_RATE_PER_IP", 10),
+		APIBurstPerIP:        getEnvInt("API_BURST_PER_IP", 30),
+		APICodeRatePerMinute: getEnvInt("API_CODE_RATE_PER_MINUTE", 10),
+
+		MetricsToken: getEnv("METRICS_TOKEN", ""),
+		MetricsAllow: getEnv("METRICS_ALLOW", ""),
+
+		DebugEndpoints: getEnvBool("DEBUG_ENDPOINTS", false),
+
+		APICompat: getEnv("API_COMPAT", APICompatV1),
 	}
 }
 
@@ -119,13 +376,21 @@ func ParseFlags() {
 	flag.Usage = func() {
 		out := flag.CommandLine.Output()
 		fmt.Fprintf(out, "Bastion V3 - Go implementation\n\n")
-		fmt.Fprintf(out, "Usage: %s [options]\n\n", os.Args[0])
+		fmt.Fprintf(out, "Usage: %s [options]\n", os.Args[0])
+		fmt.Fprintf(out, "       %s [options] cli [command...]\n", os.Args[0])
+		fmt.Fprintf(out, "       %s [options] apply -f <file> [--prune] [--dry-run]\n\n", os.Args[0])
 		fmt.Fprintln(out, "Options:")
 		flag.PrintDefaults()
 		fmt.Fprintln(out, "\nEnvironment variables:")
 		fmt.Fprintln(out, "  LOG_LEVEL                         Log level (DEBUG, INFO, WARN, ERROR)")
+		fmt.Fprintln(out, "  LOG_MAX_SIZE_MB                   Rotate the log file beyond this size (default 100; 0 = only at startup)")
+		fmt.Fprintln(out, "  LOG_MAX_BACKUPS                   Rotated log files kept (default 5)")
+		fmt.Fprintln(out, "  LOG_MAX_AGE_DAYS                  Delete rotated log files older than this (default 30; 0 = keep)")
+		fmt.Fprintln(out, "  LOG_COMPRESS                      Gzip rotated log files except the newest (default false)")
+		fmt.Fprintln(out, "  BASTION_LANG                      Language of API messages and CLI output: en, zh (default: Accept-Language / locale)")
 		fmt.Fprintln(out, "  PORT                              HTTP server port (default 7788)")
-		fmt.Fprintln(out, "  DATABASE_URL                      SQLite database path (default bastion.db)")
+		fmt.Fprintln(out, "  BIND_ADDRESS                      HTTP server bind address (default: setup choice, else 0.0.0.0)")
+		fmt.Fprintln(out, "  DATABASE_URL                      SQLite database path, or postgres:// / mysql:// URL (default bastion.db)")
 		fmt.Fprintln(out, "  SQLITE_PRAGMAS_ENABLED            Enable SQLite PRAGMAs (true/false, default true)")
 		fmt.Fprintln(out, "  SQLITE_BUSY_TIMEOUT_MS            SQLite busy_timeout in milliseconds (default 5000)")
 		fmt.Fprintln(out, "  SQLITE_JOURNAL_MODE               SQLite journal_mode (default WAL)")
@@ -135,15 +400,31 @@ func ParseFlags() {
 		fmt.Fprintln(out, "  SQLITE_MAX_IDLE_CONNS             SQLite MaxIdleConns (default 1)")
 		fmt.Fprintln(out, "  SQLITE_CONN_MAX_IDLE_SECONDS      SQLite ConnMaxIdleTime in seconds (default 300)")
 		fmt.Fprintln(out, "  SQLITE_CONN_MAX_LIFETIME_SECONDS  SQLite ConnMaxLifetime in seconds (default 0)")
+		fmt.Fprintln(out, "  DB_MAX_OPEN_CONNS                 Postgres/MySQL MaxOpenConns, at least 2 (default 10)")
+		fmt.Fprintln(out, "  DB_MAX_IDLE_CONNS                 Postgres/MySQL MaxIdleConns (default 5)")
+		fmt.Fprintln(out, "  DB_CONN_MAX_IDLE_SECONDS          Postgres/MySQL ConnMaxIdleTime in seconds (default 300)")
+		fmt.Fprintln(out, "  DB_CONN_MAX_LIFETIME_SECONDS      Postgres/MySQL ConnMaxLifetime in seconds (default 1800)")
 		fmt.Fprintln(out, "  SSH_CONNECT_TIMEOUT               SSH connect timeout in seconds (default 15)")
+		fmt.Fprintln(out, "  SSH_HANDSHAKE_TIMEOUT_SECONDS     SSH handshake and authentication timeout per hop in seconds, 0 disables (default 30)")
 		fmt.Fprintln(out, "  SSH_KEEPALIVE_INTERVAL            SSH keepalive interval in seconds (default 30)")
 		fmt.Fprintln(out, "  AUDIT_ENABLED                     Enable HTTP audit logging (true/false, default true)")
 		fmt.Fprintln(out, "  MAX_SESSION_CONNECTIONS           Maximum concurrent connections per session (default 1000)")
-		fmt.Fprintln(out, "  FORWARD_BUFFER_SIZE               TCP forward buffer size in bytes (default 32768)")
+		fmt.Fprintln(out, "  MAX_CONNS_PER_IP                  Default concurrent connections per client IP per session, 0 means unlimited (default 0)")
+		fmt.Fprintln(out, "  CONN_RATE_PER_IP                  Default new connections per second per client IP, 0 means unlimited (default 0)")
+		fmt.Fprintln(out, "  CONN_BURST_PER_IP                 Default burst size for CONN_RATE_PER_IP (default 10)")
+		fmt.Fprintln(out, "  FORWARD_BUFFER_SIZE               Maximum adaptive forward buffer size in bytes (default 262144)")
 		fmt.Fprintln(out, "  AUDIT_QUEUE_SIZE                  HTTP audit queue size (default 1000)")
+		fmt.Fprintln(out, "  AUDIT_WORKERS                     HTTP audit worker goroutines; the queue is sharded per worker (default 2)")
+		fmt.Fprintln(out, "  AUDIT_BATCH_SIZE                  Max audit events pair-matched per lock acquisition (default 64)")
+		fmt.Fprintln(out, "  AUDIT_QUEUE_BLOCKING              Block forwarding instead of dropping audit events when full (default false)")
+		fmt.Fprintln(out, "  AUDIT_SAMPLE_RATE                 Fraction of connections audited, more than 0 and at most 1 (default 1; mappings may override)")
 		fmt.Fprintln(out, "  MAX_HTTP_LOGS                     Maximum in-memory HTTP logs (default 1000)")
+		fmt.Fprintln(out, "  MAX_HTTP_LOG_BYTES                Byte budget of in-memory HTTP logs, oldest evicted first (default 268435456; 0 = unlimited)")
 		fmt.Fprintln(out, "  HTTP_PAIR_CLEANUP_INTERVAL_MINUTES  Interval minutes to cleanup stale HTTP pairs (default 5)")
 		fmt.Fprintln(out, "  HTTP_PAIR_MAX_AGE_MINUTES        Max age minutes before HTTP pair is considered stale (default 10)")
+		fmt.Fprintln(out, "  AUDIT_TRUSTED_PROXIES            Comma-separated proxy IPs/CIDRs whose X-Forwarded-For/Forwarded headers name the client in HTTP logs")
+		fmt.Fprintln(out, "  AUDIT_SKIP_BODY_TYPES            Response media types whose bodies HTTP logs leave out, off keeps all (default image/*,video/*,audio/*,font/*)")
+		fmt.Fprintln(out, "  AUDIT_SKIP_BODY_BYTES            Response bodies larger than this are left out of HTTP logs, 0 = no limit (default 0)")
 		fmt.Fprintln(out, "  GOROUTINE_MONITOR_INTERVAL_SECONDS Interval seconds for goroutine monitor (default 30)")
 		fmt.Fprintln(out, "  GOROUTINE_WARN_THRESHOLD         Goroutine count warning threshold (default 1000)")
 		fmt.Fprintln(out, "  SOCKS5_HANDSHAKE_TIMEOUT_SECONDS SOCKS5 handshake timeout in seconds (default 30)")
@@ -154,17 +435,86 @@ func ParseFlags() {
 		fmt.Fprintln(out, "  TRANSFER_WRITE_TIMEOUT_SECONDS   Data transfer write timeout in seconds (default 86400)")
 		fmt.Fprintln(out, "  SSH_CONNECT_MAX_RETRIES          Max SSH connect retries per hop (default 3)")
 		fmt.Fprintln(out, "  SSH_CONNECT_RETRY_DELAY_SECONDS  Delay between SSH connect retries in seconds (default 2)")
+		fmt.Fprintln(out, "  DIAL_TIMEOUT_SECONDS             Default per-attempt timeout for remote dials in seconds, 0 disables (default 10)")
+		fmt.Fprintln(out, "  DIAL_RETRIES                     Default dial attempts through the bastion chain (default 3)")
+		fmt.Fprintln(out, "  DIAL_RETRY_DELAY_MS              Default delay between dial attempts in milliseconds (default 1000)")
+		fmt.Fprintln(out, "  DIAL_BACKOFF                     Default retry backoff: fixed or exponential (default fixed)")
+		fmt.Fprintln(out, "  DIAL_HOLD_SECONDS                Default seconds a client waits for a bastion chain that is down, 0 disables (default 0)")
+		fmt.Fprintln(out, "  DIAL_HAPPY_EYEBALLS              Resolve remote host names locally and race their addresses through the chain (default false)")
 		fmt.Fprintln(out, "  SSH_POOL_MAX_CONNS              Maximum pooled SSH connections (default 64)")
 		fmt.Fprintln(out, "  SSH_POOL_IDLE_TIMEOUT_SECONDS   Idle seconds before closing pooled SSH connections (default 900)")
 		fmt.Fprintln(out, "  SSH_POOL_KEEPALIVE_INTERVAL_SECONDS Interval seconds for pooled SSH keepalive probes (default 30)")
 		fmt.Fprintln(out, "  SSH_POOL_KEEPALIVE_TIMEOUT_MS   Timeout for pooled SSH keepalive probe in ms (default 500)")
+		fmt.Fprintln(out, "  SSH_CHAIN_PREFIX_REUSE          Build multi-hop chains on pooled shorter chains sharing their first hops (default true)")
+		fmt.Fprintln(out, "  SSH_POOL_MAX_CLIENTS_PER_CHAIN  SSH clients a chain may open when a jump host refuses more channels (default 4)")
+		fmt.Fprintln(out, "  SSH_CHAIN_BUILD_CONCURRENCY     Maximum SSH chains built at once, 0 = unlimited (default 8)")
+		fmt.Fprintln(out, "  SSH_CHAIN_BUILD_PER_BASTION     Maximum SSH connections opened to one bastion at once, 0 = unlimited (default 2)")
+		fmt.Fprintln(out, "  SSH_CHAIN_BUILD_QUEUE_TIMEOUT_SECONDS Seconds a chain build waits for a slot, 0 = no timeout (default 30)")
 		fmt.Fprintln(out, "  HTTP_GZIP_DECODE_MAX_BYTES       Max decompressed bytes for on-demand gzip decode (default 1048576)")
 		fmt.Fprintln(out, "  HTTP_GZIP_DECODE_TIMEOUT_MS      Timeout for on-demand gzip decode in ms (default 500)")
 		fmt.Fprintln(out, "  HTTP_GZIP_DECODE_CACHE_SECONDS   Sliding cache TTL seconds for decoded results (default 60)")
+		fmt.Fprintln(out, "  HTTP_COMPARE_MAX_BODY_BYTES      Max body bytes per side diffed by http-logs/compare (default 65536)")
+		fmt.Fprintln(out, "  ERROR_LOG_RETENTION_DAYS         Days to keep persisted error logs, 0 keeps forever (default 30)")
+		fmt.Fprintln(out, "  ERROR_LOG_MAX_ROWS               Maximum persisted error log rows, 0 means unlimited (default 10000)")
+		fmt.Fprintln(out, "  ACCESS_LOG_MAX                   API requests kept in memory for /api/v2/access-logs, 0 disables (default 2000)")
+		fmt.Fprintln(out, "  QUERY_LOG_MAX                    Audited database statements kept in memory for /api/v2/query-logs, 0 disables (default 5000)")
+		fmt.Fprintln(out, "  QUERY_AUDIT_MAX_BYTES            Size audited statements are cut at (default 4096)")
+		fmt.Fprintln(out, "  QUERY_AUDIT_REDACT               What audited statements mask: none, secrets or literals (default secrets)")
+		fmt.Fprintln(out, "  TLS_SNI_LOG                      Log SNI, ALPN and TLS version of forwarded TLS streams (default true)")
+		fmt.Fprintln(out, "  MAPPING_EVENTS_MAX               Start/stop/failure events kept per mapping (default 50)")
+		fmt.Fprintln(out, "  USAGE_FLUSH_INTERVAL_SECONDS     How often lifetime traffic counters are saved (default 60)")
+		fmt.Fprintln(out, "  STANDBY_IDLE_SECONDS             Idle seconds before a standby mapping closes its SSH chain (default 300)")
+		fmt.Fprintln(out, "  QUOTA_RESET_HOUR                 Local hour (0-23) at which daily mapping quotas reset (default 0)")
+		fmt.Fprintln(out, "  QUOTA_THROTTLE_BPS               Bytes per second per connection once a throttle quota is used up (default 65536)")
+		fmt.Fprintln(out, "  CLI_HISTORY_FILE                 CLI command history file, off disables (default ~/.bastion/history)")
+		fmt.Fprintln(out, "  DB_BACKUP_DIR                    Directory for database backups (default backups)")
+		fmt.Fprintln(out, "  DB_BACKUP_INTERVAL_MINUTES       Minutes between automatic database backups, 0 disables (default 0)")
+		fmt.Fprintln(out, "  DB_BACKUP_KEEP                   Automatic backups kept before the oldest is deleted (default 7)")
+		fmt.Fprintln(out, "  EVENT_SINK_URL                   Security event sink: http(s):// URL, syslog://host:port (UDP) or syslog+tcp://host:port")
+		fmt.Fprintln(out, "  EVENT_SINK_TOKEN                 Bearer token sent to an HTTP event sink")
+		fmt.Fprintln(out, "  EVENT_TYPES                      Comma-separated event types to export (default: all)")
+		fmt.Fprintln(out, "  EVENT_BATCH_SIZE                 Maximum events per delivered batch (default 100)")
+		fmt.Fprintln(out, "  EVENT_FLUSH_INTERVAL_MS          Maximum delay before queued events are delivered in ms (default 2000)")
+		fmt.Fprintln(out, "  EVENT_QUEUE_SIZE                 Events buffered while the sink is slow; further events are dropped (default 10000)")
+		fmt.Fprintln(out, "  MDNS_ADVERTISE                   Advertise running exposed mappings via mDNS as _bastion._tcp services (default false)")
+		fmt.Fprintln(out, "  HA_ROLE                          active or standby to run as one of an active/standby pair (default: standalone)")
+		fmt.Fprintln(out, "  HA_PEER_URL                      Base URL of the other instance of the pair, e.g. http://10.0.0.2:7788")
+		fmt.Fprintln(out, "  HA_PEER_TOKEN                    Admin token of the other instance")
+		fmt.Fprintln(out, "  HA_CHECK_INTERVAL_SECONDS        Seconds between the standby's health checks of the active (default 5)")
+		fmt.Fprintln(out, "  HA_FAILOVER_THRESHOLD            Consecutive failed health checks before the standby takes over (default 3)")
+		fmt.Fprintln(out, "  HA_SYNC_INTERVAL_SECONDS         Seconds between syncs of bastions and mappings from the active, 0 disables (default 60)")
+		fmt.Fprintln(out, "  AGENT_CENTRAL_URL                Base URL of a central instance to run one of its workspaces as an agent")
+		fmt.Fprintln(out, "  AGENT_TOKEN                      Agent token issued by the central instance (POST /api/v2/agents), or its admin token")
+		fmt.Fprintln(out, "  AGENT_NAME                       Name the agent registers under (default: host name)")
+		fmt.Fprintln(out, "  AGENT_WORKSPACE                  Workspace of the central instance the agent runs (default: agent name)")
+		fmt.Fprintln(out, "  AGENT_INTERVAL_SECONDS           Seconds between syncs and reports to the central instance (default 15)")
+		fmt.Fprintln(out, "  ALERT_WEBHOOK_URLS               Comma-separated webhook URLs for alerts")
+		fmt.Fprintln(out, "  ALERT_WEBHOOK_TEMPLATE           Go text/template for the webhook body (default: JSON event)")
+		fmt.Fprintln(out, "  ALERT_SMTP_HOST                  SMTP host for email alerts (disabled when empty)")
+		fmt.Fprintln(out, "  ALERT_SMTP_PORT                  SMTP port (default 587)")
+		fmt.Fprintln(out, "  ALERT_SMTP_USERNAME              SMTP username (PLAIN auth when set)")
+		fmt.Fprintln(out, "  ALERT_SMTP_PASSWORD              SMTP password")
+		fmt.Fprintln(out, "  ALERT_SMTP_FROM                  Sender address for email alerts")
+		fmt.Fprintln(out, "  ALERT_SMTP_TO                    Comma-separated recipient addresses")
+		fmt.Fprintln(out, "  ALERT_COOLDOWN_SECONDS           Minimum seconds between identical alerts (default 300)")
+		fmt.Fprintln(out, "  ALERT_MAX_PER_MINUTE             Maximum alerts delivered per minute, 0 means unlimited (default 10)")
+		fmt.Fprintln(out, "  ALERT_KEEPALIVE_FAILURE_THRESHOLD Consecutive SSH keepalive failures before alerting (default 3)")
+		fmt.Fprintln(out, "  ALERT_AUDIT_DROPS_PER_MINUTE     Audit queue drops per minute before alerting (default 100)")
+		fmt.Fprintln(out, "  UPDATE_CHECK_INTERVAL_MINUTES    Minutes between background update checks (min 5), 0 disables (default 0)")
+		fmt.Fprintln(out, "  UPDATE_REQUIRE_CHECKSUM          Refuse self-updates whose release has no SHA256SUMS (default true)")
+		fmt.Fprintln(out, "  UPDATE_MINISIGN_PUBKEY           minisign public key; when set, SHA256SUMS must be signed by it")
+		fmt.Fprintln(out, "  API_RATE_PER_IP                  Mutating API requests per second per client IP, 0 disables (default 10)")
+		fmt.Fprintln(out, "  API_BURST_PER_IP                 Burst size for API_RATE_PER_IP (default 30)")
+		fmt.Fprintln(out, "  API_CODE_RATE_PER_MINUTE         Confirmation code requests per minute per client IP, 0 disables (default 10)")
+		fmt.Fprintln(out, "  METRICS_TOKEN                    Bearer token required by /metrics and /api/metrics (instead of the admin token)")
+		fmt.Fprintln(out, "  METRICS_ALLOW                    Comma-separated client IPs/CIDRs allowed to read the metrics endpoints")
+		fmt.Fprintln(out, "  DEBUG_ENDPOINTS                  Serve Go pprof profiles under /debug/pprof/ (default false)")
+		fmt.Fprintln(out, "  API_COMPAT                       v1 also serves the deprecated /api routes, none only /api/v2 (default v1)")
 	}
 
 	port := flag.Int("port", Settings.Port, "HTTP server port (overrides PORT)")
-	db := flag.String("db", Settings.DatabaseURL, "SQLite database path (overrides DATABASE_URL)")
+	bind := flag.String("bind", Settings.BindAddress, "HTTP server bind address (overrides BIND_ADDRESS)")
+	db := flag.String("db", Settings.DatabaseURL, "SQLite database path or postgres:// / mysql:// URL (overrides DATABASE_URL)")
 	sqlitePragmasEnabled := flag.Bool("sqlite-pragmas", Settings.SQLitePragmasEnabled, "Enable SQLite PRAGMAs (overrides SQLITE_PRAGMAS_ENABLED)")
 	sqliteBusyTimeoutMS := flag.Int("sqlite-busy-timeout-ms", Settings.SQLiteBusyTimeoutMS, "SQLite busy_timeout in milliseconds (overrides SQLITE_BUSY_TIMEOUT_MS)")
 	sqliteJournalMode := flag.String("sqlite-journal-mode", Settings.SQLiteJournalMode, "SQLite journal_mode (overrides SQLITE_JOURNAL_MODE)")
@@ -176,13 +526,24 @@ func ParseFlags() {
 	sqliteConnMaxLifeSec := flag.Int("sqlite-conn-max-lifetime-seconds", Settings.SQLiteConnMaxLifeSec, "SQLite ConnMaxLifetime in seconds (overrides SQLITE_CONN_MAX_LIFETIME_SECONDS)")
 	logLevel := flag.String("log-level", Settings.LogLevel, "Log level: DEBUG, INFO, WARN, ERROR (overrides LOG_LEVEL)")
 	logFile := flag.String("log-file", Settings.LogFilePath, "Log file path (overrides LOG_FILE)")
+	logMaxSize := flag.Int("log-max-size-mb", Settings.LogMaxSizeMB, "Rotate the log file beyond this size in MB, 0 = only at startup (overrides LOG_MAX_SIZE_MB)")
+	logMaxBackups := flag.Int("log-max-backups", Settings.LogMaxBackups, "Rotated log files kept (overrides LOG_MAX_BACKUPS)")
+	logMaxAge := flag.Int("log-max-age-days", Settings.LogMaxAgeDays, "Delete rotated log files older than this many days, 0 = keep (overrides LOG_MAX_AGE_DAYS)")
+	logCompress := flag.Bool("log-compress", Settings.LogCompress, "Gzip rotated log files except the newest (overrides LOG_COMPRESS)")
+	lang := flag.String("lang", Settings.Language, "Language of API messages and CLI output: en, zh (overrides BASTION_LANG)")
 	auditEnabled := flag.Bool("audit", Settings.AuditEnabled, "Enable HTTP traffic auditing (overrides AUDIT_ENABLED)")
 	sshPoolMaxConns := flag.Int("ssh-pool-max-conns", Settings.SSHPoolMaxConns, "Maximum pooled SSH connections (overrides SSH_POOL_MAX_CONNS)")
 	sshPoolIdleTimeout := flag.Int("ssh-pool-idle-timeout-seconds", Settings.SSHPoolIdleTimeoutSeconds, "Idle seconds before closing pooled SSH connections (overrides SSH_POOL_IDLE_TIMEOUT_SECONDS)")
 	sshPoolKeepaliveInt := flag.Int("ssh-pool-keepalive-interval-seconds", Settings.SSHPoolKeepaliveIntervalSeconds, "Interval seconds for pooled SSH keepalive probes (overrides SSH_POOL_KEEPALIVE_INTERVAL_SECONDS)")
+	sshChainPrefixReuse := flag.Bool("ssh-chain-prefix-reuse", Settings.SSHChainPrefixReuse, "Build multi-hop chains on pooled shorter chains sharing their first hops (overrides SSH_CHAIN_PREFIX_REUSE)")
 	sshPoolKeepaliveMS := flag.Int("ssh-pool-keepalive-timeout-ms", Settings.SSHPoolKeepaliveTimeoutMS, "Timeout for pooled SSH keepalive probe in ms (overrides SSH_POOL_KEEPALIVE_TIMEOUT_MS)")
 	cliMode := flag.Bool("cli", Settings.CLIMode, "Run in CLI mode (HTTP client only, no database)")
 	cliServer := flag.String("server", "http://localhost:7788", "Server URL for CLI mode")
+	cliHistoryFile := flag.String("cli-history-file", Settings.CLIHistoryFile, "CLI command history file, \"off\" disables (overrides CLI_HISTORY_FILE)")
+	cliExec := flag.String("e", "", "Run one CLI command (e.g. \"mapping list\") against --server and exit; implies --cli")
+	completion := flag.String("completion", "", "Print a shell completion script for these flags (bash, zsh, powershell) and exit")
+	migrateStatus := flag.Bool("migrate-status", false, "Print database schema migration status and exit")
+	selfCheck := flag.Bool("selfcheck", false, "Check the database, log file, key files, autostart ports and first hops, print the report and exit")
 
 	maxSessionConns := flag.Int("max-session-connections", Settings.MaxSessionConnections, "Maximum concurrent connections per mapping session")
 	maxHTTPLogs := flag.Int("max-http-logs", Settings.MaxHTTPLogs, "Maximum number of HTTP logs kept in memory")
@@ -190,6 +551,10 @@ func ParseFlags() {
 	socks5HandshakeWriteTimeout := flag.Int("socks5-handshake-write-timeout-seconds", Settings.Socks5HandshakeWriteTimeoutSeconds, "SOCKS5 handshake write timeout in seconds (overrides SOCKS5_HANDSHAKE_WRITE_TIMEOUT_SECONDS)")
 	transferReadTimeout := flag.Int("transfer-read-timeout-seconds", Settings.TransferReadTimeoutSeconds, "Data transfer read timeout in seconds (overrides TRANSFER_READ_TIMEOUT_SECONDS)")
 	transferWriteTimeout := flag.Int("transfer-write-timeout-seconds", Settings.TransferWriteTimeoutSeconds, "Data transfer write timeout in seconds (overrides TRANSFER_WRITE_TIMEOUT_SECONDS)")
+	metricsToken := flag.String("metrics-token", Settings.MetricsToken, "Bearer token required by the metrics endpoints (overrides METRICS_TOKEN)")
+	metricsAllow := flag.String("metrics-allow", Settings.MetricsAllow, "Comma-separated client IPs/CIDRs allowed to read the metrics endpoints (overrides METRICS_ALLOW)")
+	debugEndpoints := flag.Bool("debug-endpoints", Settings.DebugEndpoints, "Serve Go pprof profiles under /debug/pprof/ (overrides DEBUG_ENDPOINTS)")
+	apiCompat := flag.String("api-compat", Settings.APICompat, "Mount the deprecated v1 routes under /api (v1) or serve only /api/v2 (none) (overrides API_COMPAT)")
 
 	showHelp := flag.Bool("help", false, "Show help and exit")
 	showVersion := flag.Bool("version", false, "Show version and exit")
@@ -206,7 +571,18 @@ func ParseFlags() {
 		os.Exit(0)
 	}
 
+	if *completion != "" {
+		script, err := CompletionScript(*completion, os.Args[0])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		fmt.Print(script)
+		os.Exit(0)
+	}
+
 	Settings.Port = *port
+	Settings.BindAddress = *bind
 	Settings.DatabaseURL = *db
 	Settings.SQLitePragmasEnabled = *sqlitePragmasEnabled
 	Settings.SQLiteBusyTimeoutMS = *sqliteBusyTimeoutMS
@@ -219,19 +595,59 @@ func ParseFlags() {
 	Settings.SQLiteConnMaxLifeSec = *sqliteConnMaxLifeSec
 	Settings.LogLevel = *logLevel
 	Settings.LogFilePath = *logFile
+	Settings.LogMaxSizeMB = *logMaxSize
+	Settings.LogMaxBackups = *logMaxBackups
+	Settings.LogMaxAgeDays = *logMaxAge
+	Settings.LogCompress = *logCompress
+	Settings.Language = *lang
 	Settings.AuditEnabled = *auditEnabled
 	Settings.SSHPoolMaxConns = *sshPoolMaxConns
 	Settings.SSHPoolIdleTimeoutSeconds = *sshPoolIdleTimeout
 	Settings.SSHPoolKeepaliveIntervalSeconds = *sshPoolKeepaliveInt
 	Settings.SSHPoolKeepaliveTimeoutMS = *sshPoolKeepaliveMS
+	Settings.SSHChainPrefixReuse = *sshChainPrefixReuse
 	Settings.CLIMode = *cliMode
 	Settings.CLIServer = *cliServer
+	Settings.CLIHistoryFile = *cliHistoryFile
+	Settings.CLIExec = strings.TrimSpace(*cliExec)
+	if Settings.CLIExec != "" {
+		Settings.CLIMode = true
+	}
+	// `bastion [flags] cli [command...]` is the same as --cli [-e "command..."], and
+	// `bastion [flags] apply ...` is the same as --cli -e "apply ..."
+	if args := flag.Args(); len(args) > 0 {
+		switch args[0] {
+		case "cli":
+			Settings.CLIMode = true
+			if len(args) > 1 {
+				Settings.CLIExec = strings.Join(args[1:], " ")
+			}
+		case "apply":
+			Settings.CLIMode = true
+			Settings.CLIExec = strings.Join(args, " ")
+		}
+	}
+	Settings.MigrateStatus = *migrateStatus
+	Settings.SelfCheck = *selfCheck
 	Settings.MaxSessionConnections = *maxSessionConns
 	Settings.MaxHTTPLogs = *maxHTTPLogs
 	Settings.Socks5HandshakeReadTimeoutSeconds = *socks5HandshakeReadTimeout
 	Settings.Socks5HandshakeWriteTimeoutSeconds = *socks5HandshakeWriteTimeout
 	Settings.TransferReadTimeoutSeconds = *transferReadTimeout
 	Settings.TransferWriteTimeoutSeconds = *transferWriteTimeout
+	Settings.MetricsToken = *metricsToken
+	Settings.MetricsAllow = *metricsAllow
+	Settings.DebugEndpoints = *debugEndpoints
+	Settings.APICompat = strings.ToLower(strings.TrimSpace(*apiCompat))
+}
+
+// defaultCLIHistoryFile keeps CLI history in the user's home directory (disabled when it is unknown).
+func defaultCLIHistoryFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil || home == "" {
+		return "off"
+	}
+	return filepath.Join(home, ".bastion", "history")
 }
 
 func getEnv(key, defaultValue string) string {
@@ -250,6 +666,15 @@ func getEnvInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if floatVal, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatVal
+		}
+	}
+	return defaultValue
+}
+
 func getEnvBool(key string, defaultValue bool) bool {
 	if value := os.Getenv(key); value != "" {
 		if boolVal, err := strconv.ParseBool(value); err == nil {
@@ -0,0 +1,62 @@
+package config
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+)
+
+// RedactedValue replaces secrets in redacted output.
+const RedactedValue = "[redacted]"
+
+// Redacted returns the settings by field name with secrets removed, for sharing in bug reports:
+// tokens and passwords are replaced by RedactedValue when set, and URL settings keep only their
+// scheme and host, since webhook paths and queries often embed credentials.
+func (c *Config) Redacted() map[string]interface{} {
+	out := make(map[string]interface{})
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name := t.Field(i).Name
+		value := v.Field(i).Interface()
+		if s, ok := value.(string); ok && s != "" {
+			switch {
+			case isSecretSetting(name):
+				value = RedactedValue
+			case strings.HasSuffix(name, "URL") || strings.HasSuffix(name, "URLs"):
+				value = RedactURLList(s)
+			}
+		}
+		out[name] = value
+	}
+	return out
+}
+
+func isSecretSetting(name string) bool {
+	for _, word := range []string{"Token", "Password", "Passphrase", "Secret"} {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactURLList reduces each URL of a comma-separated list to scheme://host; values that are not
+// URLs (such as a file path) are kept.
+func RedactURLList(list string) string {
+	parts := strings.Split(list, ",")
+	for i, part := range parts {
+		part = strings.TrimSpace(part)
+		u, err := url.Parse(part)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			parts[i] = part
+			continue
+		}
+		redacted := u.Scheme + "://" + u.Host
+		if u.User != nil || (u.Path != "" && u.Path != "/") || u.RawQuery != "" {
+			redacted += "/" + RedactedValue
+		}
+		parts[i] = redacted
+	}
+	return strings.Join(parts, ",")
+}
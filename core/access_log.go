@@ -0,0 +1,95 @@
+package core
+
+import (
+	"bastion/config"
+	"bastion/models"
+	"sync"
+)
+
+// AccessLogStore keeps the most recent management API requests in a bounded ring, so API issues
+// can be looked into without reading the text log.
+type AccessLogStore struct {
+	mu     sync.RWMutex
+	max    int
+	nextID int64
+	logs   []*models.AccessLog // oldest first
+}
+
+var AccessLogs *AccessLogStore
+
+func init() {
+	AccessLogs = NewAccessLogStore(config.Settings.AccessLogMax)
+}
+
+// NewAccessLogStore constructs a store keeping up to max entries; max <= 0 disables it.
+func NewAccessLogStore(max int) *AccessLogStore {
+	return &AccessLogStore{max: max}
+}
+
+// Enabled reports whether entries are kept.
+func (s *AccessLogStore) Enabled() bool {
+	return s.max > 0
+}
+
+// Record stores entry, assigning its ID, and evicts the oldest entries beyond the limit.
+func (s *AccessLogStore) Record(entry *models.AccessLog) {
+	if !s.Enabled() {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID++
+	entry.ID = s.nextID
+	s.logs = append(s.logs, entry)
+	if over := len(s.logs) - s.max; over > 0 {
+		copy(s.logs, s.logs[over:])
+		for i := len(s.logs) - over; i < len(s.logs); i++ {
+			s.logs[i] = nil
+		}
+		s.logs = s.logs[:len(s.logs)-over]
+	}
+}
+
+// Query returns a page of entries (latest first) matching filter, plus the total match count.
+func (s *AccessLogStore) Query(filter models.AccessLogFilter, page, pageSize int) ([]*models.AccessLog, int) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = 20
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	matched := make([]*models.AccessLog, 0)
+	for i := len(s.logs) - 1; i >= 0; i-- {
+		if filter.Matches(s.logs[i]) {
+			matched = append(matched, s.logs[i])
+		}
+	}
+
+	start := (page - 1) * pageSize
+	if start >= len(matched) {
+		return []*models.AccessLog{}, len(matched)
+	}
+	end := start + pageSize
+	if end > len(matched) {
+		end = len(matched)
+	}
+	return matched[start:end], len(matched)
+}
+
+// Len returns the number of stored entries.
+func (s *AccessLogStore) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.logs)
+}
+
+// Clear removes all entries.
+func (s *AccessLogStore) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.logs = nil
+}
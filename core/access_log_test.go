@@ -0,0 +1,56 @@
+package core
+
+import (
+	"bastion/models"
+	"testing"
+)
+
+func TestAccessLogStore_EvictsOldest(t *testing.T) {
+	s := NewAccessLogStore(3)
+	for _, path := range []string{"/api/v2/a", "/api/v2/b", "/api/v2/c", "/api/v2/d"} {
+		s.Record(&models.AccessLog{Method: "GET", Path: path, Status: 200, Code: "OK"})
+	}
+	if s.Len() != 3 {
+		t.Fatalf("expected 3 entries, got %d", s.Len())
+	}
+
+	logs, total := s.Query(models.AccessLogFilter{}, 1, 2)
+	if total != 3 || len(logs) != 2 || logs[0].Path != "/api/v2/d" || logs[0].ID != 4 || logs[1].Path != "/api/v2/c" {
+		t.Fatalf("unexpected first page: total=%d %+v", total, logs)
+	}
+	logs, _ = s.Query(models.AccessLogFilter{}, 2, 2)
+	if len(logs) != 1 || logs[0].Path != "/api/v2/b" {
+		t.Fatalf("unexpected second page: %+v", logs)
+	}
+}
+
+func TestAccessLogStore_Filters(t *testing.T) {
+	s := NewAccessLogStore(10)
+	s.Record(&models.AccessLog{Method: "GET", Path: "/api/v2/mappings", Status: 200, Code: "OK", LatencyMS: 2})
+	s.Record(&models.AccessLog{Method: "POST", Path: "/api/v2/mappings/db/start", Status: 200, Code: "BAD_GATEWAY", LatencyMS: 900})
+	s.Record(&models.AccessLog{Method: "GET", Path: "/api/v2/nope", Status: 404})
+
+	tests := []struct {
+		name   string
+		filter models.AccessLogFilter
+		want   int
+	}{
+		{"method", models.AccessLogFilter{Method: "post"}, 1},
+		{"path", models.AccessLogFilter{Path: "MAPPINGS"}, 2},
+		{"code", models.AccessLogFilter{Code: "bad_gateway"}, 1},
+		{"errors", models.AccessLogFilter{ErrorsOnly: true}, 2},
+		{"latency", models.AccessLogFilter{MinLatencyMS: 100}, 1},
+		{"status", models.AccessLogFilter{Status: 404}, 1},
+	}
+	for _, tt := range tests {
+		if _, total := s.Query(tt.filter, 1, 20); total != tt.want {
+			t.Fatalf("%s: %d matches, want %d", tt.name, total, tt.want)
+		}
+	}
+
+	disabled := NewAccessLogStore(0)
+	disabled.Record(&models.AccessLog{Path: "/api/v2/x"})
+	if disabled.Len() != 0 {
+		t.Fatalf("disabled store kept an entry")
+	}
+}
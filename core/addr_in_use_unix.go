@@ -10,3 +10,9 @@ import (
 func isAddrInUse(err error) bool {
 	return errors.Is(err, syscall.EADDRINUSE)
 }
+
+// isBindForbidden is Windows-only (see addr_in_use_windows.go); elsewhere an access error is
+// reported as is.
+func isBindForbidden(err error) bool {
+	return false
+}
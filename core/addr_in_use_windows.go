@@ -12,3 +12,9 @@ import (
 func isAddrInUse(err error) bool {
 	return errors.Is(err, windows.WSAEADDRINUSE) || errors.Is(err, syscall.EADDRINUSE)
 }
+
+// isBindForbidden reports a bind refused with an access error: the port lies in an excluded port
+// range, another process holds it exclusively, or security software blocks it.
+func isBindForbidden(err error) bool {
+	return errors.Is(err, windows.WSAEACCES)
+}
@@ -0,0 +1,389 @@
+package core
+
+import (
+	"bastion/config"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template"
+	"time"
+)
+
+// Alert event types
+const (
+	AlertMappingStartFailed  = "mapping_start_failed"
+	AlertSSHKeepaliveFailure = "ssh_keepalive_failure"
+	AlertAuditDrops          = "audit_drops"
+	AlertGoroutineWarning    = "goroutine_warning"
+	AlertUpdateAvailable     = "update_available"
+	AlertTest                = "test"
+)
+
+// ErrNoAlertTargets is returned when a test alert is requested without any configured target.
+var ErrNoAlertTargets = errors.New("no alert targets configured")
+
+// AlertEvent is the payload delivered to webhooks and email.
+type AlertEvent struct {
+	Type      string                 `json:"type"`
+	Severity  string                 `json:"severity"` // INFO, WARN, ERROR
+	Key       string                 `json:"key,omitempty"`
+	Message   string                 `json:"message"`
+	Detail    string                 `json:"detail,omitempty"`
+	Fields    map[string]interface{} `json:"fields,omitempty"`
+	Hostname  string                 `json:"hostname"`
+	Timestamp time.Time              `json:"timestamp"`
+}
+
+// AlertTestResult reports the outcome of a synchronous (test-fire) delivery.
+type AlertTestResult struct {
+	Webhooks int      `json:"webhooks"`
+	Email    bool     `json:"email"`
+	Errors   []string `json:"errors,omitempty"`
+}
+
+// AlertStats exposes alerting counters.
+type AlertStats struct {
+	WebhookTargets int    `json:"webhook_targets"`
+	EmailEnabled   bool   `json:"email_enabled"`
+	Sent           uint64 `json:"sent"`
+	Suppressed     uint64 `json:"suppressed"`
+	Dropped        uint64 `json:"dropped"`
+	Failed         uint64 `json:"failed"`
+}
+
+// Alerter delivers alert events to configured webhooks and SMTP with cooldown-based rate limiting.
+type Alerter struct {
+	queue     chan AlertEvent
+	startOnce sync.Once
+	client    *http.Client
+
+	mu         sync.Mutex
+	lastFired  map[string]time.Time // type|key -> last delivery
+	windowFrom time.Time
+	windowSent int
+
+	keepaliveMu       sync.Mutex
+	keepaliveFailures map[string]int // chain key -> consecutive failures
+
+	sentTotal       uint64
+	suppressedTotal uint64
+	droppedTotal    uint64
+	failedTotal     uint64
+}
+
+var AlerterInstance *Alerter
+
+func init() {
+	AlerterInstance = NewAlerter()
+}
+
+// NewAlerter constructs an alerter; call Start to begin delivering queued events.
+func NewAlerter() *Alerter {
+	return &Alerter{
+		queue:             make(chan AlertEvent, 100),
+		client:            &http.Client{Timeout: 10 * time.Second},
+		lastFired:         make(map[string]time.Time),
+		keepaliveFailures: make(map[string]int),
+	}
+}
+
+// Start launches the delivery worker and the audit drop monitor. It is safe to call multiple times.
+func (a *Alerter) Start() {
+	a.startOnce.Do(func() {
+		go a.deliverLoop()
+		go a.monitorAuditDrops()
+	})
+}
+
+// Enabled reports whether any alert target is configured.
+func (a *Alerter) Enabled() bool {
+	return len(alertWebhookURLs()) > 0 || alertEmailEnabled()
+}
+
+// Fire queues ev for asynchronous delivery. It returns false when the event was rate limited,
+// dropped because the queue is full, or no targets are configured.
+func (a *Alerter) Fire(ev AlertEvent) bool {
+	if !a.Enabled() {
+		return false
+	}
+
+	fillAlertDefaults(&ev)
+	if !a.allow(ev, ev.Timestamp) {
+		atomic.AddUint64(&a.suppressedTotal, 1)
+		return false
+	}
+
+	select {
+	case a.queue <- ev:
+		return true
+	default:
+		atomic.AddUint64(&a.droppedTotal, 1)
+		return false
+	}
+}
+
+// SendNow delivers ev synchronously to all targets, bypassing rate limiting (used by the test-fire endpoint).
+func (a *Alerter) SendNow(ev AlertEvent) (AlertTestResult, error) {
+	if !a.Enabled() {
+		return AlertTestResult{}, ErrNoAlertTargets
+	}
+	fillAlertDefaults(&ev)
+	return a.deliver(ev), nil
+}
+
+// Stats returns alerting counters.
+func (a *Alerter) Stats() AlertStats {
+	return AlertStats{
+		WebhookTargets: len(alertWebhookURLs()),
+		EmailEnabled:   alertEmailEnabled(),
+		Sent:           atomic.LoadUint64(&a.sentTotal),
+		Suppressed:     atomic.LoadUint64(&a.suppressedTotal),
+		Dropped:        atomic.LoadUint64(&a.droppedTotal),
+		Failed:         atomic.LoadUint64(&a.failedTotal),
+	}
+}
+
+// ObserveKeepalive tracks consecutive keepalive failures per chain and fires once the threshold is reached.
+func (a *Alerter) ObserveKeepalive(chainKey string, err error) {
+	threshold := config.Settings.AlertKeepaliveFailureThreshold
+	if threshold <= 0 {
+		return
+	}
+
+	a.keepaliveMu.Lock()
+	if err == nil {
+		delete(a.keepaliveFailures, chainKey)
+		a.keepaliveMu.Unlock()
+		return
+	}
+	a.keepaliveFailures[chainKey]++
+	failures := a.keepaliveFailures[chainKey]
+	a.keepaliveMu.Unlock()
+
+	if failures == threshold {
+		a.Fire(AlertEvent{
+			Type:     AlertSSHKeepaliveFailure,
+			Severity: "ERROR",
+			Key:      chainKey,
+			Message:  fmt.Sprintf("SSH keepalive failed %d times in a row for chain %s", failures, chainKey),
+			Detail:   err.Error(),
+			Fields:   map[string]interface{}{"chain": chainKey, "failures": failures},
+		})
+	}
+}
+
+// allow applies the per-event cooldown and the global per-minute cap.
+func (a *Alerter) allow(ev AlertEvent, now time.Time) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	key := ev.Type + "|" + ev.Key
+	if cooldown := time.Duration(config.Settings.AlertCooldownSeconds) * time.Second; cooldown > 0 {
+		if last, ok := a.lastFired[key]; ok && now.Sub(last) < cooldown {
+			return false
+		}
+	}
+
+	if limit := config.Settings.AlertMaxPerMinute; limit > 0 {
+		if now.Sub(a.windowFrom) >= time.Minute {
+			a.windowFrom = now
+			a.windowSent = 0
+		}
+		if a.windowSent >= limit {
+			return false
+		}
+		a.windowSent++
+	}
+
+	a.lastFired[key] = now
+	return true
+}
+
+func (a *Alerter) deliverLoop() {
+	for ev := range a.queue {
+		result := a.deliver(ev)
+		if len(result.Errors) > 0 {
+			log.Printf("Alert %s delivery errors: %s", ev.Type, strings.Join(result.Errors, "; "))
+		}
+	}
+}
+
+func (a *Alerter) deliver(ev AlertEvent) AlertTestResult {
+	var result AlertTestResult
+
+	for _, u := range alertWebhookURLs() {
+		if err := a.postWebhook(u, ev); err != nil {
+			atomic.AddUint64(&a.failedTotal, 1)
+			result.Errors = append(result.Errors, fmt.Sprintf("webhook %s: %v", redactURL(u), err))
+			continue
+		}
+		atomic.AddUint64(&a.sentTotal, 1)
+		result.Webhooks++
+	}
+
+	if alertEmailEnabled() {
+		if err := sendAlertEmail(ev); err != nil {
+			atomic.AddUint64(&a.failedTotal, 1)
+			result.Errors = append(result.Errors, fmt.Sprintf("email: %v", err))
+		} else {
+			atomic.AddUint64(&a.sentTotal, 1)
+			result.Email = true
+		}
+	}
+
+	return result
+}
+
+func (a *Alerter) postWebhook(target string, ev AlertEvent) error {
+	body, err := renderAlert(config.Settings.AlertWebhookTemplate, ev)
+	if err != nil {
+		return err
+	}
+
+	resp, err := a.client.Post(target, "application/json", bytes.NewReader(body))
+	if err != nil {
+		// url.Error embeds the full URL, which may carry a secret token.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return urlErr.Err
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// monitorAuditDrops fires when the audit queue drops more than the configured number of messages per minute.
+func (a *Alerter) monitorAuditDrops() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	last := AuditorInstance.AuditDroppedTotal()
+	for range ticker.C {
+		current := AuditorInstance.AuditDroppedTotal()
+		delta := current - last
+		last = current
+
+		threshold := config.Settings.AlertAuditDropsPerMinute
+		if threshold > 0 && delta > uint64(threshold) {
+			a.Fire(AlertEvent{
+				Type:     AlertAuditDrops,
+				Severity: "WARN",
+				Message:  fmt.Sprintf("Audit queue dropped %d messages in the last minute", delta),
+				Fields:   map[string]interface{}{"dropped": delta, "dropped_total": current},
+			})
+		}
+	}
+}
+
+// renderAlert renders the payload template, or JSON-encodes the event when no template is set.
+// Templates use text/template with the AlertEvent as data and a "json" helper for escaping values.
+func renderAlert(tmpl string, ev AlertEvent) ([]byte, error) {
+	if strings.TrimSpace(tmpl) == "" {
+		return json.Marshal(ev)
+	}
+
+	t, err := template.New("alert").Funcs(template.FuncMap{
+		"json": func(v interface{}) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+	}).Parse(tmpl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid alert template: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, ev); err != nil {
+		return nil, fmt.Errorf("render alert template: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func sendAlertEmail(ev AlertEvent) error {
+	s := config.Settings
+	to := splitAlertList(s.AlertSMTPTo)
+
+	subject := fmt.Sprintf("[Bastion] %s: %s", ev.Severity, ev.Type)
+	text := fmt.Sprintf("%s\n\nHost: %s\nTime: %s\n", ev.Message, ev.Hostname, ev.Timestamp.Format(time.RFC3339))
+	if ev.Detail != "" {
+		text += "\nDetail:\n" + ev.Detail + "\n"
+	}
+	if len(ev.Fields) > 0 {
+		if b, err := json.MarshalIndent(ev.Fields, "", "  "); err == nil {
+			text += "\nFields:\n" + string(b) + "\n"
+		}
+	}
+
+	msg := "From: " + s.AlertSMTPFrom + "\r\n" +
+		"To: " + strings.Join(to, ", ") + "\r\n" +
+		"Subject: " + subject + "\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n\r\n" +
+		strings.ReplaceAll(text, "\n", "\r\n")
+
+	addr := net.JoinHostPort(s.AlertSMTPHost, strconv.Itoa(s.AlertSMTPPort))
+	var auth smtp.Auth
+	if s.AlertSMTPUsername != "" {
+		auth = smtp.PlainAuth("", s.AlertSMTPUsername, s.AlertSMTPPassword, s.AlertSMTPHost)
+	}
+	return smtp.SendMail(addr, auth, s.AlertSMTPFrom, to, []byte(msg))
+}
+
+func fillAlertDefaults(ev *AlertEvent) {
+	if ev.Timestamp.IsZero() {
+		ev.Timestamp = time.Now()
+	}
+	if ev.Severity == "" {
+		ev.Severity = "ERROR"
+	}
+	if ev.Hostname == "" {
+		ev.Hostname, _ = os.Hostname()
+	}
+}
+
+func alertWebhookURLs() []string {
+	return splitAlertList(config.Settings.AlertWebhookURLs)
+}
+
+func alertEmailEnabled() bool {
+	s := config.Settings
+	return s.AlertSMTPHost != "" && s.AlertSMTPFrom != "" && len(splitAlertList(s.AlertSMTPTo)) > 0
+}
+
+func splitAlertList(raw string) []string {
+	var out []string
+	for _, part := range strings.Split(raw, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+// redactURL hides credentials and query tokens (common in webhook URLs) in error messages.
+func redactURL(raw string) string {
+	if i := strings.IndexAny(raw, "?#"); i >= 0 {
+		raw = raw[:i]
+	}
+	if at := strings.Index(raw, "@"); at >= 0 {
+		if scheme := strings.Index(raw, "://"); scheme >= 0 && scheme < at {
+			raw = raw[:scheme+3] + "***" + raw[at:]
+		}
+	}
+	return raw
+}
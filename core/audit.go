@@ -2,6 +2,7 @@ package core
 
 import (
 	"bastion/config"
+	"hash/fnv"
 	"log"
 	"strconv"
 	"strings"
@@ -18,18 +19,27 @@ type Auditor struct {
 	httpMu       sync.RWMutex
 	maxLogs      int
 	logIDCounter int
-	pairMatcher  *HTTPPairMatcher
-	stateMu      sync.RWMutex
+
+	maxBytes           int64  // MAX_HTTP_LOG_BYTES, 0 = unlimited
+	retainedBytes      int64  // estimated bytes held by httpLogs
+	budgetEvictedTotal uint64 // logs evicted for the byte budget
+	pairMatcher        *HTTPPairMatcher
+	stateMu            sync.RWMutex
 
 	gzipDecodeMu         sync.Mutex
 	gzipDecodedBodyCache map[int]*gzipDecodedBodyCacheEntry
 	gzipCacheLastSweep   time.Time
 
 	auditQueueMu      sync.Mutex
-	auditQueue        chan auditEvent
+	auditQueues       []chan auditEvent // one shard per worker; a connection always maps to the same shard
 	auditQueueStop    chan struct{}
 	auditQueueWg      sync.WaitGroup
 	auditDroppedTotal uint64
+	auditQueued       int64 // events currently buffered across all shards
+	auditHighWater    int64 // highest auditQueued observed
+
+	subMu       sync.Mutex
+	subscribers map[*HTTPLogSubscription]struct{}
 }
 
 type auditEvent struct {
@@ -58,6 +68,101 @@ type HTTPLog struct {
 	RespSize        int       `json:"resp_size"`        // Response size
 	IsGzipped       bool      `json:"is_gzipped"`       // Whether response was gzip-compressed
 	DurationMs      int64     `json:"duration_ms"`      // Request/response latency in ms
+
+	// Latency breakdown, measured from the moment the request was completely seen.
+	TTFBMs           int64 `json:"ttfb_ms"`           // until the first response byte
+	TTLBMs           int64 `json:"ttlb_ms"`           // until the last response byte
+	RequestSeq       int   `json:"request_seq"`       // 1-based position of the request on its connection
+	ConnectionReused bool  `json:"connection_reused"` // an earlier request already used this connection
+	Orphan           bool  `json:"orphan,omitempty"`  // response stored without its request
+
+	// ResponseBodyOmitted is BodyOmittedType or BodyOmittedSize when only the response headers
+	// were kept (AUDIT_SKIP_BODY_TYPES, AUDIT_SKIP_BODY_BYTES); RespSize is still the full size.
+	ResponseBodyOmitted string `json:"response_body_omitted,omitempty"`
+
+	// HeaderRewrites lists what the mapping's request_headers changed ("set X-Env", "override
+	// X-Env", "remove X-Tracking-Id"); Request is the request as forwarded.
+	HeaderRewrites []string `json:"header_rewrites,omitempty"`
+
+	// ClientIP is the original client: the socket peer, or behind a trusted proxy (see
+	// Auditor.SetTrustedProxies) the address its forwarding headers name, with the proxy in ProxyIP.
+	ClientIP string `json:"client_ip,omitempty"`
+	ProxyIP  string `json:"proxy_ip,omitempty"`
+
+	// Header maps keyed by lowercase name, parsed once when the pair is matched.
+	RequestHeaders  map[string][]string `json:"request_headers,omitempty"`
+	ResponseHeaders map[string][]string `json:"response_headers,omitempty"`
+
+	search   *httpLogSearch // precomputed lowercase fields, set when the log is saved
+	retained int64          // httpLogRetainedBytes, set when the log is saved
+}
+
+// httpLogSearch holds lowercase copies of the searchable fields of an HTTP log so keyword queries do not
+// re-lowercase every request/response body on each search. strings.ToLower returns its input unchanged
+// only when it has no uppercase letter; a body with a single one (an HTTP start line or header name
+// is enough) is copied in full, and httpLogRetainedBytes counts the copy against the memory budget.
+type httpLogSearch struct {
+	host string
+	url  string
+	text []string // keyword-searchable fields
+}
+
+func newHTTPLogSearch(httpLog *HTTPLog) *httpLogSearch {
+	return &httpLogSearch{
+		host: strings.ToLower(httpLog.Host),
+		url:  strings.ToLower(httpLog.URL),
+		text: []string{
+			strings.ToLower(httpLog.Method),
+			strings.ToLower(httpLog.MappingID),
+			strings.ToLower(strings.Join(httpLog.BastionChain, " ")),
+			strconv.Itoa(httpLog.LocalPort),
+			strings.ToLower(httpLog.Host),
+			strings.ToLower(httpLog.URL),
+			strings.ToLower(httpLog.Protocol),
+			strings.ToLower(httpLog.ConnID),
+			strings.ToLower(httpLog.Request),
+			strings.ToLower(httpLog.Response),
+			strings.ToLower(httpLog.ResponseDecoded),
+		},
+	}
+}
+
+// searchFields returns the precomputed search fields, computing them for logs that were never saved.
+func (l *HTTPLog) searchFields() *httpLogSearch {
+	if l.search != nil {
+		return l.search
+	}
+	return newHTTPLogSearch(l)
+}
+
+// headerMaps returns the parsed request and response headers, parsing them for logs that were not
+// built by the pair matcher.
+func (l *HTTPLog) headerMaps() (req, resp map[string][]string) {
+	req, resp = l.RequestHeaders, l.ResponseHeaders
+	if req == nil {
+		req = httpMessageHeaderMap([]byte(l.Request))
+	}
+	if resp == nil && l.Response != "" {
+		resp = httpMessageHeaderMap([]byte(l.Response))
+	}
+	return req, resp
+}
+
+// httpHeaderMapMatches expects f to be lowercased.
+func httpHeaderMapMatches(headers map[string][]string, f HTTPHeaderFilter) bool {
+	values, ok := headers[f.Name]
+	if !ok {
+		return false
+	}
+	if f.Value == "" {
+		return true
+	}
+	for _, v := range values {
+		if strings.Contains(strings.ToLower(v), f.Value) {
+			return true
+		}
+	}
+	return false
 }
 
 // AuditContext carries session-level metadata to attach to HTTP audit logs.
@@ -65,6 +170,8 @@ type AuditContext struct {
 	MappingID    string
 	LocalPort    int
 	BastionChain []string
+
+	HeaderRewrites []string // changes the mapping's request_headers made to this request
 }
 
 var AuditorInstance *Auditor
@@ -74,6 +181,7 @@ func init() {
 		httpLogs:             make([]*HTTPLog, 0, config.Settings.MaxHTTPLogs),
 		httpLogsMap:          make(map[int]*HTTPLog),
 		maxLogs:              config.Settings.MaxHTTPLogs,
+		maxBytes:             config.Settings.MaxHTTPLogBytes,
 		gzipDecodedBodyCache: make(map[int]*gzipDecodedBodyCacheEntry),
 	}
 
@@ -123,8 +231,8 @@ func (a *Auditor) isRunning() bool {
 
 // EnqueueHTTPMessage enqueues a full HTTP message for asynchronous audit processing.
 //
-// This method is non-blocking by design: when the audit queue is full, the message is dropped and false is returned
-// to prioritize forwarding performance.
+// By default this method is non-blocking: when the connection's queue shard is full, the message is dropped and
+// false is returned to prioritize forwarding performance. With AUDIT_QUEUE_BLOCKING the caller waits for room instead.
 func (a *Auditor) EnqueueHTTPMessage(ctx AuditContext, connID string, msg *HTTPMessage) bool {
 	if !config.Settings.AuditEnabled {
 		return false
@@ -136,33 +244,66 @@ func (a *Auditor) EnqueueHTTPMessage(ctx AuditContext, connID string, msg *HTTPM
 		return false
 	}
 
-	q := a.getAuditQueue()
-	if q == nil {
+	queues, stop := a.getAuditQueues()
+	if len(queues) == 0 {
 		atomic.AddUint64(&a.auditDroppedTotal, 1)
 		return false
 	}
+	q := queues[auditShard(connID, len(queues))]
 
 	ev := auditEvent{ctx: ctx, connID: connID, msg: msg}
-	select {
-	case q <- ev:
-		return true
-	default:
-		atomic.AddUint64(&a.auditDroppedTotal, 1)
-		return false
+	if config.Settings.AuditQueueBlocking {
+		select {
+		case q <- ev:
+			a.noteQueued()
+			return true
+		case <-stop:
+		}
+	} else {
+		select {
+		case q <- ev:
+			a.noteQueued()
+			return true
+		default:
+		}
 	}
+	atomic.AddUint64(&a.auditDroppedTotal, 1)
+	return false
 }
 
-func (a *Auditor) getAuditQueue() chan auditEvent {
+// noteQueued counts an event that entered a queue and updates the high-water mark. It runs after the
+// send so senders blocked on a full queue are not counted as buffered.
+func (a *Auditor) noteQueued() {
+	n := atomic.AddInt64(&a.auditQueued, 1)
+	for {
+		hw := atomic.LoadInt64(&a.auditHighWater)
+		if n <= hw || atomic.CompareAndSwapInt64(&a.auditHighWater, hw, n) {
+			return
+		}
+	}
+}
+
+// auditShard maps a connection to a queue shard so its requests and responses are processed in order.
+func auditShard(connID string, shards int) int {
+	if shards <= 1 {
+		return 0
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(connID))
+	return int(h.Sum32() % uint32(shards))
+}
+
+func (a *Auditor) getAuditQueues() ([]chan auditEvent, chan struct{}) {
 	a.auditQueueMu.Lock()
 	defer a.auditQueueMu.Unlock()
-	return a.auditQueue
+	return a.auditQueues, a.auditQueueStop
 }
 
 func (a *Auditor) startAuditQueue() {
 	a.auditQueueMu.Lock()
 	defer a.auditQueueMu.Unlock()
 
-	if a.auditQueue != nil {
+	if a.auditQueues != nil {
 		return
 	}
 
@@ -170,14 +311,26 @@ func (a *Auditor) startAuditQueue() {
 	if capacity <= 0 {
 		capacity = 1
 	}
+	workers := config.Settings.AuditWorkers
+	if workers <= 0 {
+		workers = 1
+	}
+	if workers > capacity {
+		workers = capacity
+	}
+	shardCap := (capacity + workers - 1) / workers
 
-	a.auditQueue = make(chan auditEvent, capacity)
+	a.auditQueues = make([]chan auditEvent, workers)
 	a.auditQueueStop = make(chan struct{})
-	a.auditQueueWg.Add(1)
-	go func() {
-		defer a.auditQueueWg.Done()
-		a.processAuditQueue(a.auditQueueStop, a.auditQueue)
-	}()
+	for i := range a.auditQueues {
+		q := make(chan auditEvent, shardCap)
+		a.auditQueues[i] = q
+		a.auditQueueWg.Add(1)
+		go func(stop <-chan struct{}) {
+			defer a.auditQueueWg.Done()
+			a.processAuditQueue(stop, q)
+		}(a.auditQueueStop)
+	}
 }
 
 func (a *Auditor) stopAuditQueue() {
@@ -193,46 +346,70 @@ func (a *Auditor) stopAuditQueue() {
 	a.auditQueueWg.Wait()
 
 	a.auditQueueMu.Lock()
-	a.auditQueue = nil
+	a.auditQueues = nil
+	atomic.StoreInt64(&a.auditQueued, 0)
 	a.auditQueueMu.Unlock()
 }
 
+// processAuditQueue drains one queue shard, handing events to the pair matcher in batches.
 func (a *Auditor) processAuditQueue(stop <-chan struct{}, q <-chan auditEvent) {
+	batchSize := config.Settings.AuditBatchSize
+	if batchSize <= 0 {
+		batchSize = 1
+	}
+	batch := make([]auditEvent, 0, batchSize)
+
 	for {
 		select {
 		case <-stop:
 			return
 		case ev := <-q:
-			if ev.msg == nil {
-				continue
-			}
-			if ev.msg.Type == HTTPRequest {
-				a.pairMatcher.AddRequest(ev.ctx, ev.connID, ev.msg)
-			} else {
-				a.pairMatcher.MatchResponse(ev.connID, ev.msg)
+			batch = append(batch[:0], ev)
+		drain:
+			for len(batch) < batchSize {
+				select {
+				case ev := <-q:
+					batch = append(batch, ev)
+				default:
+					break drain
+				}
 			}
+			atomic.AddInt64(&a.auditQueued, -int64(len(batch)))
+			a.pairMatcher.ProcessBatch(batch)
 		}
 	}
 }
 
-// AuditQueueLen returns the current audit queue length.
+// AuditQueueLen returns the current audit queue length across all shards.
 func (a *Auditor) AuditQueueLen() int {
-	a.auditQueueMu.Lock()
-	defer a.auditQueueMu.Unlock()
-	if a.auditQueue == nil {
-		return 0
+	// A worker may take an event before its sender counted it.
+	if n := atomic.LoadInt64(&a.auditQueued); n > 0 {
+		return int(n)
 	}
-	return len(a.auditQueue)
+	return 0
 }
 
-// AuditQueueCap returns the configured audit queue capacity.
+// AuditQueueCap returns the configured audit queue capacity across all shards.
 func (a *Auditor) AuditQueueCap() int {
 	a.auditQueueMu.Lock()
 	defer a.auditQueueMu.Unlock()
-	if a.auditQueue == nil {
-		return 0
+	total := 0
+	for _, q := range a.auditQueues {
+		total += cap(q)
 	}
-	return cap(a.auditQueue)
+	return total
+}
+
+// AuditQueueHighWater returns the highest audit queue length observed since start.
+func (a *Auditor) AuditQueueHighWater() int {
+	return int(atomic.LoadInt64(&a.auditHighWater))
+}
+
+// AuditWorkers returns the number of running audit workers.
+func (a *Auditor) AuditWorkers() int {
+	a.auditQueueMu.Lock()
+	defer a.auditQueueMu.Unlock()
+	return len(a.auditQueues)
 }
 
 // AuditDroppedTotal returns the total number of dropped audit messages.
@@ -242,22 +419,22 @@ func (a *Auditor) AuditDroppedTotal() uint64 {
 
 // saveHTTPLog stores an HTTP log entry
 func (a *Auditor) saveHTTPLog(httpLog *HTTPLog) {
+	a.storeHTTPLog(httpLog)
+	a.publishHTTPLog(httpLog)
+}
+
+func (a *Auditor) storeHTTPLog(httpLog *HTTPLog) {
 	a.httpMu.Lock()
 	defer a.httpMu.Unlock()
 
-	// LRU eviction
-	if len(a.httpLogs) >= a.maxLogs {
-		oldLog := a.httpLogs[0]
-		delete(a.httpLogsMap, oldLog.ID)
-		a.httpLogs = a.httpLogs[1:]
-
-		a.gzipDecodeMu.Lock()
-		delete(a.gzipDecodedBodyCache, oldLog.ID)
-		a.gzipDecodeMu.Unlock()
-	}
-
 	a.logIDCounter++
 	httpLog.ID = a.logIDCounter
+	httpLog.search = newHTTPLogSearch(httpLog)
+	httpLog.retained = httpLogRetainedBytes(httpLog)
+
+	// LRU eviction, by count and by the byte budget
+	a.evictHTTPLogsLocked(httpLog.retained)
+	atomic.AddInt64(&a.retainedBytes, httpLog.retained)
 
 	a.httpLogs = append(a.httpLogs, httpLog)
 	a.httpLogsMap[httpLog.ID] = httpLog
@@ -284,6 +461,7 @@ func (a *Auditor) ClearHTTPLogs() {
 	a.httpLogs = make([]*HTTPLog, 0, a.maxLogs)
 	a.httpLogsMap = make(map[int]*HTTPLog)
 	a.logIDCounter = 0
+	atomic.StoreInt64(&a.retainedBytes, 0)
 
 	a.gzipDecodeMu.Lock()
 	a.gzipDecodedBodyCache = make(map[int]*gzipDecodedBodyCacheEntry)
@@ -302,6 +480,30 @@ type HTTPLogFilter struct {
 	StatusCode int
 	Since      *time.Time
 	Until      *time.Time
+	Headers    []HTTPHeaderFilter // all must match
+}
+
+// HTTPHeaderFilter matches logs whose request or response has header Name (case-insensitive)
+// with a value containing Value; an empty Value only requires the header to be present.
+type HTTPHeaderFilter struct {
+	Name  string
+	Value string
+}
+
+// lowered returns the filter with its case-insensitive needles lowercased, as
+// httpLogMatchesFilter expects.
+func (f HTTPLogFilter) lowered() HTTPLogFilter {
+	f.Query = strings.ToLower(f.Query)
+	f.Host = strings.ToLower(f.Host)
+	f.URL = strings.ToLower(f.URL)
+	if len(f.Headers) > 0 {
+		headers := make([]HTTPHeaderFilter, len(f.Headers))
+		for i, h := range f.Headers {
+			headers[i] = HTTPHeaderFilter{Name: strings.ToLower(h.Name), Value: strings.ToLower(h.Value)}
+		}
+		f.Headers = headers
+	}
+	return f
 }
 
 // QueryHTTPLogs returns paginated HTTP logs filtered by optional criteria.
@@ -317,6 +519,9 @@ func (a *Auditor) QueryHTTPLogs(filter HTTPLogFilter, page, pageSize int) ([]*HT
 		pageSize = 20
 	}
 
+	// Lowercase the needles once; log fields are precomputed in lowercase.
+	filter = filter.lowered()
+
 	matched := make([]*HTTPLog, 0, len(a.httpLogs))
 	for i := len(a.httpLogs) - 1; i >= 0; i-- {
 		httpLog := a.httpLogs[i]
@@ -342,16 +547,11 @@ func (a *Auditor) QueryHTTPLogs(filter HTTPLogFilter, page, pageSize int) ([]*HT
 	return matched[start:end], total
 }
 
+// httpLogMatchesFilter expects filter to be lowered by the caller.
 func httpLogMatchesFilter(httpLog *HTTPLog, filter HTTPLogFilter) bool {
 	if filter.Method != "" && !strings.EqualFold(httpLog.Method, filter.Method) {
 		return false
 	}
-	if filter.Host != "" && !strings.Contains(strings.ToLower(httpLog.Host), strings.ToLower(filter.Host)) {
-		return false
-	}
-	if filter.URL != "" && !strings.Contains(strings.ToLower(httpLog.URL), strings.ToLower(filter.URL)) {
-		return false
-	}
 	if filter.Bastion != "" {
 		matched := false
 		for _, name := range httpLog.BastionChain {
@@ -377,6 +577,27 @@ func httpLogMatchesFilter(httpLog *HTTPLog, filter HTTPLogFilter) bool {
 		return false
 	}
 
+	if len(filter.Headers) > 0 {
+		reqHeaders, respHeaders := httpLog.headerMaps()
+		for _, h := range filter.Headers {
+			if !httpHeaderMapMatches(reqHeaders, h) && !httpHeaderMapMatches(respHeaders, h) {
+				return false
+			}
+		}
+	}
+
+	if filter.Host == "" && filter.URL == "" && filter.Query == "" {
+		return true
+	}
+
+	search := httpLog.searchFields()
+	if filter.Host != "" && !strings.Contains(search.host, filter.Host) {
+		return false
+	}
+	if filter.URL != "" && !strings.Contains(search.url, filter.URL) {
+		return false
+	}
+
 	if filter.Query == "" {
 		return true
 	}
@@ -394,18 +615,20 @@ func httpLogMatchesFilter(httpLog *HTTPLog, filter HTTPLogFilter) bool {
 			filter.QueryRegex.MatchString(httpLog.ResponseDecoded)
 	}
 
-	q := strings.ToLower(filter.Query)
-	return strings.Contains(strings.ToLower(httpLog.Method), q) ||
-		strings.Contains(strings.ToLower(httpLog.MappingID), q) ||
-		strings.Contains(strings.ToLower(strings.Join(httpLog.BastionChain, " ")), q) ||
-		strings.Contains(strconv.Itoa(httpLog.LocalPort), q) ||
-		strings.Contains(strings.ToLower(httpLog.Host), q) ||
-		strings.Contains(strings.ToLower(httpLog.URL), q) ||
-		strings.Contains(strings.ToLower(httpLog.Protocol), q) ||
-		strings.Contains(strings.ToLower(httpLog.ConnID), q) ||
-		strings.Contains(strings.ToLower(httpLog.Request), q) ||
-		strings.Contains(strings.ToLower(httpLog.Response), q) ||
-		strings.Contains(strings.ToLower(httpLog.ResponseDecoded), q)
+	for _, field := range search.text {
+		if strings.Contains(field, filter.Query) {
+			return true
+		}
+	}
+	return false
+}
+
+// HTTPPairStats returns the request/response pairing counters.
+func (a *Auditor) HTTPPairStats() HTTPPairStats {
+	if a.pairMatcher == nil {
+		return HTTPPairStats{}
+	}
+	return a.pairMatcher.Stats()
 }
 
 // cleanupStalePairs periodically clears unfinished HTTP pairs to avoid leaks
@@ -0,0 +1,139 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// HTTP log aggregation dimensions.
+const (
+	HTTPLogGroupByHost   = "host"
+	HTTPLogGroupByURL    = "url"
+	HTTPLogGroupByStatus = "status"
+)
+
+// HTTPLogGroup summarizes the HTTP logs sharing one value of the grouping dimension.
+type HTTPLogGroup struct {
+	Key          string  `json:"key"`
+	Count        int     `json:"count"`
+	ClientErrors int     `json:"client_errors"` // 4xx responses
+	ServerErrors int     `json:"server_errors"` // 5xx responses
+	AvgMs        float64 `json:"avg_ms"`
+	P50Ms        int64   `json:"p50_ms"`
+	P95Ms        int64   `json:"p95_ms"`
+	P99Ms        int64   `json:"p99_ms"`
+	MaxMs        int64   `json:"max_ms"`
+	ReqBytes     int64   `json:"req_bytes"`
+	RespBytes    int64   `json:"resp_bytes"`
+}
+
+// HTTPLogAggregate is the result of AggregateHTTPLogs.
+type HTTPLogAggregate struct {
+	GroupBy string          `json:"group_by"`
+	Total   int             `json:"total"`  // logs matching the filter
+	Groups  []*HTTPLogGroup `json:"groups"` // busiest first
+}
+
+// ValidateHTTPLogGroupBy checks an aggregation dimension.
+func ValidateHTTPLogGroupBy(groupBy string) error {
+	switch groupBy {
+	case HTTPLogGroupByHost, HTTPLogGroupByURL, HTTPLogGroupByStatus:
+		return nil
+	}
+	return fmt.Errorf("invalid group_by %q (use %s, %s or %s)", groupBy, HTTPLogGroupByHost, HTTPLogGroupByURL, HTTPLogGroupByStatus)
+}
+
+// AggregateHTTPLogs groups the logs matching filter by host, URL path (without query string) or
+// status code, returning at most limit groups (0 = all) ordered by request count.
+func (a *Auditor) AggregateHTTPLogs(filter HTTPLogFilter, groupBy string, limit int) (*HTTPLogAggregate, error) {
+	if err := ValidateHTTPLogGroupBy(groupBy); err != nil {
+		return nil, err
+	}
+
+	filter = filter.lowered()
+
+	type bucket struct {
+		group     *HTTPLogGroup
+		durations []int64
+		totalMs   int64
+	}
+	buckets := make(map[string]*bucket)
+	result := &HTTPLogAggregate{GroupBy: groupBy}
+
+	a.httpMu.RLock()
+	for _, httpLog := range a.httpLogs {
+		if httpLog == nil || !httpLogMatchesFilter(httpLog, filter) {
+			continue
+		}
+		result.Total++
+
+		key := httpLogGroupKey(httpLog, groupBy)
+		b := buckets[key]
+		if b == nil {
+			b = &bucket{group: &HTTPLogGroup{Key: key}}
+			buckets[key] = b
+		}
+		g := b.group
+		g.Count++
+		switch {
+		case httpLog.StatusCode >= 500:
+			g.ServerErrors++
+		case httpLog.StatusCode >= 400:
+			g.ClientErrors++
+		}
+		g.ReqBytes += int64(httpLog.ReqSize)
+		g.RespBytes += int64(httpLog.RespSize)
+		b.durations = append(b.durations, httpLog.DurationMs)
+		b.totalMs += httpLog.DurationMs
+	}
+	a.httpMu.RUnlock()
+
+	result.Groups = make([]*HTTPLogGroup, 0, len(buckets))
+	for _, b := range buckets {
+		sort.Slice(b.durations, func(i, j int) bool { return b.durations[i] < b.durations[j] })
+		g := b.group
+		g.AvgMs = float64(b.totalMs) / float64(g.Count)
+		g.P50Ms = durationPercentile(b.durations, 50)
+		g.P95Ms = durationPercentile(b.durations, 95)
+		g.P99Ms = durationPercentile(b.durations, 99)
+		g.MaxMs = b.durations[len(b.durations)-1]
+		result.Groups = append(result.Groups, g)
+	}
+	sort.Slice(result.Groups, func(i, j int) bool {
+		gi, gj := result.Groups[i], result.Groups[j]
+		if gi.Count != gj.Count {
+			return gi.Count > gj.Count
+		}
+		return gi.Key < gj.Key
+	})
+	if limit > 0 && len(result.Groups) > limit {
+		result.Groups = result.Groups[:limit]
+	}
+	return result, nil
+}
+
+func httpLogGroupKey(httpLog *HTTPLog, groupBy string) string {
+	switch groupBy {
+	case HTTPLogGroupByHost:
+		return strings.ToLower(httpLog.Host)
+	case HTTPLogGroupByURL:
+		path := httpLog.URL
+		if i := strings.IndexByte(path, '?'); i >= 0 {
+			path = path[:i]
+		}
+		return path
+	default:
+		return strconv.Itoa(httpLog.StatusCode)
+	}
+}
+
+// durationPercentile returns the nearest-rank percentile p of sorted, which must not be empty.
+func durationPercentile(sorted []int64, p int) int64 {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
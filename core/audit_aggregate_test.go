@@ -0,0 +1,58 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAuditor_AggregateHTTPLogs(t *testing.T) {
+	a := &Auditor{
+		httpLogs:    make([]*HTTPLog, 0, 20),
+		httpLogsMap: make(map[int]*HTTPLog),
+		maxLogs:     20,
+	}
+
+	now := time.Now()
+	for i := 1; i <= 10; i++ {
+		a.saveHTTPLog(&HTTPLog{Timestamp: now, Host: "API.example.com", URL: "/items?page=1", StatusCode: 200, DurationMs: int64(i * 10), ReqSize: 10, RespSize: 100})
+	}
+	a.saveHTTPLog(&HTTPLog{Timestamp: now, Host: "api.example.com", URL: "/items", StatusCode: 503, DurationMs: 1000})
+	a.saveHTTPLog(&HTTPLog{Timestamp: now, Host: "cdn.example.com", URL: "/a.js", StatusCode: 404, DurationMs: 5})
+	a.saveHTTPLog(&HTTPLog{Timestamp: now.Add(-2 * time.Hour), Host: "old.example.com", URL: "/", StatusCode: 200})
+
+	since := now.Add(-time.Hour)
+	res, err := a.AggregateHTTPLogs(HTTPLogFilter{Since: &since}, HTTPLogGroupByHost, 0)
+	if err != nil {
+		t.Fatalf("AggregateHTTPLogs: %v", err)
+	}
+	if res.Total != 12 || len(res.Groups) != 2 {
+		t.Fatalf("unexpected result: total %d, %d groups", res.Total, len(res.Groups))
+	}
+	g := res.Groups[0]
+	if g.Key != "api.example.com" || g.Count != 11 || g.ServerErrors != 1 || g.ClientErrors != 0 {
+		t.Fatalf("unexpected busiest group: %+v", g)
+	}
+	if g.P50Ms != 60 || g.P95Ms != 1000 || g.MaxMs != 1000 || g.ReqBytes != 100 || g.RespBytes != 1000 {
+		t.Fatalf("unexpected group stats: %+v", g)
+	}
+	if res.Groups[1].Key != "cdn.example.com" || res.Groups[1].ClientErrors != 1 {
+		t.Fatalf("unexpected second group: %+v", res.Groups[1])
+	}
+
+	res, err = a.AggregateHTTPLogs(HTTPLogFilter{}, HTTPLogGroupByURL, 1)
+	if err != nil {
+		t.Fatalf("AggregateHTTPLogs: %v", err)
+	}
+	if len(res.Groups) != 1 || res.Groups[0].Key != "/items" || res.Groups[0].Count != 11 {
+		t.Fatalf("expected URLs grouped without query string, got %+v", res.Groups)
+	}
+
+	res, _ = a.AggregateHTTPLogs(HTTPLogFilter{}, HTTPLogGroupByStatus, 0)
+	if res.Groups[0].Key != "200" || res.Groups[0].Count != 11 {
+		t.Fatalf("unexpected status groups: %+v", res.Groups[0])
+	}
+
+	if _, err := a.AggregateHTTPLogs(HTTPLogFilter{}, "method", 0); err == nil {
+		t.Fatal("expected unknown group_by to be rejected")
+	}
+}
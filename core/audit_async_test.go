@@ -1,6 +1,8 @@
 package core
 
 import (
+	"strconv"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -20,7 +22,7 @@ func TestAuditor_EnqueueHTTPMessage_DropsWhenQueueFull(t *testing.T) {
 		httpLogsMap: make(map[int]*HTTPLog),
 		maxLogs:     10,
 	}
-	a.auditQueue = make(chan auditEvent, 1)
+	a.auditQueues = []chan auditEvent{make(chan auditEvent, 1)}
 
 	msg := &HTTPMessage{Type: HTTPRequest, Timestamp: time.Now(), Data: []byte("GET / HTTP/1.1\r\n\r\n")}
 	if ok := a.EnqueueHTTPMessage(AuditContext{}, "c", msg); !ok {
@@ -99,3 +101,95 @@ func TestAuditor_AuditQueueProcessesMessages(t *testing.T) {
 
 	t.Fatalf("timeout waiting for audit processing")
 }
+
+func TestAuditor_BlockingQueueWaitsForRoom(t *testing.T) {
+	oldEnabled, oldBlocking := config.Settings.AuditEnabled, config.Settings.AuditQueueBlocking
+	t.Cleanup(func() {
+		config.Settings.AuditEnabled = oldEnabled
+		config.Settings.AuditQueueBlocking = oldBlocking
+	})
+	config.Settings.AuditEnabled = true
+	config.Settings.AuditQueueBlocking = true
+
+	q := make(chan auditEvent, 1)
+	a := &Auditor{running: true}
+	a.auditQueues = []chan auditEvent{q}
+	a.auditQueueStop = make(chan struct{})
+
+	msg := &HTTPMessage{Type: HTTPRequest, Timestamp: time.Now(), Data: []byte("GET / HTTP/1.1\r\n\r\n")}
+	if ok := a.EnqueueHTTPMessage(AuditContext{}, "c", msg); !ok {
+		t.Fatalf("expected enqueue ok")
+	}
+
+	done := make(chan bool, 1)
+	go func() { done <- a.EnqueueHTTPMessage(AuditContext{}, "c", msg) }()
+	select {
+	case <-done:
+		t.Fatalf("expected enqueue to block while the queue is full")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if got := a.AuditQueueHighWater(); got != 1 {
+		t.Fatalf("expected the blocked sender not to count, high water %d", got)
+	}
+
+	<-q
+	atomic.AddInt64(&a.auditQueued, -1) // as processAuditQueue does
+	if ok := <-done; !ok {
+		t.Fatalf("expected blocked enqueue to succeed once room is available")
+	}
+	if got := a.AuditDroppedTotal(); got != 0 {
+		t.Fatalf("expected no drops in blocking mode, got %d", got)
+	}
+	if got := a.AuditQueueHighWater(); got != 1 {
+		t.Fatalf("expected high water 1, got %d", got)
+	}
+}
+
+func TestAuditor_ShardingKeepsConnectionOrder(t *testing.T) {
+	oldEnabled, oldQueue, oldWorkers := config.Settings.AuditEnabled, config.Settings.AuditQueueSize, config.Settings.AuditWorkers
+	t.Cleanup(func() {
+		config.Settings.AuditEnabled = oldEnabled
+		config.Settings.AuditQueueSize = oldQueue
+		config.Settings.AuditWorkers = oldWorkers
+	})
+	config.Settings.AuditEnabled = true
+	config.Settings.AuditQueueSize = 400
+	config.Settings.AuditWorkers = 4
+
+	a := &Auditor{
+		httpLogs:             make([]*HTTPLog, 0, 100),
+		httpLogsMap:          make(map[int]*HTTPLog),
+		maxLogs:              100,
+		gzipDecodedBodyCache: make(map[int]*gzipDecodedBodyCacheEntry),
+	}
+	a.pairMatcher = NewHTTPPairMatcher(func(httpLog *HTTPLog) {
+		a.saveHTTPLog(httpLog)
+	})
+	a.running = true
+	a.startAuditQueue()
+	t.Cleanup(func() { a.Stop() })
+
+	if got := a.AuditWorkers(); got != 4 {
+		t.Fatalf("expected 4 workers, got %d", got)
+	}
+
+	const conns = 50
+	for i := 0; i < conns; i++ {
+		connID := "127.0.0.1:" + strconv.Itoa(1000+i) + "->127.0.0.1:80"
+		req := &HTTPMessage{Type: HTTPRequest, Timestamp: time.Now(), Data: []byte("GET / HTTP/1.1\r\nHost: x\r\n\r\n")}
+		resp := &HTTPMessage{Type: HTTPResponse, Timestamp: time.Now(), Data: []byte("HTTP/1.1 204 No Content\r\n\r\n")}
+		if !a.EnqueueHTTPMessage(AuditContext{}, connID, req) || !a.EnqueueHTTPMessage(AuditContext{}, connID, resp) {
+			t.Fatalf("unexpected drop")
+		}
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, total := a.GetHTTPLogs(1, 100); total == conns {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	_, total := a.GetHTTPLogs(1, 100)
+	t.Fatalf("expected %d paired logs, got %d", conns, total)
+}
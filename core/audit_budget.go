@@ -0,0 +1,75 @@
+package core
+
+import (
+	"sync/atomic"
+)
+
+// httpLogRetainedBytes estimates the memory an HTTP log keeps alive: its request/response copies and
+// the lowercase search copies that differ from them (see newHTTPLogSearch).
+func httpLogRetainedBytes(httpLog *HTTPLog) int64 {
+	n := len(httpLog.Request) + len(httpLog.Response) + len(httpLog.ResponseDecoded) +
+		len(httpLog.URL) + len(httpLog.Host) + len(httpLog.ConnID)
+	if s := httpLog.search; s != nil && len(s.text) >= 3 {
+		// The bodies are the last three search fields; comparing a string with itself is a pointer check.
+		bodies := s.text[len(s.text)-3:]
+		for i, original := range []string{httpLog.Request, httpLog.Response, httpLog.ResponseDecoded} {
+			if bodies[i] != original {
+				n += len(bodies[i])
+			}
+		}
+	}
+	for _, values := range httpLog.RequestHeaders {
+		for _, v := range values {
+			n += len(v)
+		}
+	}
+	for _, values := range httpLog.ResponseHeaders {
+		for _, v := range values {
+			n += len(v)
+		}
+	}
+	return int64(n)
+}
+
+// evictHTTPLogsLocked drops the least recently stored logs until one more log of size incoming fits
+// both MAX_HTTP_LOGS and MAX_HTTP_LOG_BYTES. The incoming log itself is always kept, so a single
+// log larger than the budget evicts everything else. Callers hold httpMu.
+func (a *Auditor) evictHTTPLogsLocked(incoming int64) {
+	evicted := 0
+	for len(a.httpLogs) > evicted {
+		overCount := len(a.httpLogs)-evicted >= a.maxLogs
+		overBytes := a.maxBytes > 0 && atomic.LoadInt64(&a.retainedBytes)+incoming > a.maxBytes
+		if !overCount && !overBytes {
+			break
+		}
+		if !overCount {
+			atomic.AddUint64(&a.budgetEvictedTotal, 1)
+		}
+
+		oldLog := a.httpLogs[evicted]
+		a.httpLogs[evicted] = nil
+		evicted++
+		delete(a.httpLogsMap, oldLog.ID)
+		atomic.AddInt64(&a.retainedBytes, -oldLog.retained)
+
+		a.gzipDecodeMu.Lock()
+		delete(a.gzipDecodedBodyCache, oldLog.ID)
+		a.gzipDecodeMu.Unlock()
+	}
+	a.httpLogs = a.httpLogs[evicted:]
+}
+
+// RetainedBytes returns the estimated bytes held by the in-memory HTTP logs.
+func (a *Auditor) RetainedBytes() int64 {
+	return atomic.LoadInt64(&a.retainedBytes)
+}
+
+// RetainedBytesBudget returns the MAX_HTTP_LOG_BYTES budget, 0 when unlimited.
+func (a *Auditor) RetainedBytesBudget() int64 {
+	return a.maxBytes
+}
+
+// BudgetEvictedTotal returns how many HTTP logs were evicted to stay within the byte budget.
+func (a *Auditor) BudgetEvictedTotal() uint64 {
+	return atomic.LoadUint64(&a.budgetEvictedTotal)
+}
@@ -0,0 +1,65 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAuditor_ByteBudgetEviction(t *testing.T) {
+	a := &Auditor{
+		httpLogs:    make([]*HTTPLog, 0, 10),
+		httpLogsMap: make(map[int]*HTTPLog),
+		maxLogs:     10,
+		maxBytes:    2500,
+	}
+	body := func(n int) string { return "http/1.1 200 ok\r\n\r\n" + strings.Repeat("x", n) }
+
+	for i := 0; i < 3; i++ {
+		a.saveHTTPLog(&HTTPLog{Request: "get / http/1.1", Response: body(500)})
+	}
+	small := a.RetainedBytes()
+	if small <= 1500 || small > 2500 {
+		t.Fatalf("unexpected retained bytes %d for three ~500 byte logs", small)
+	}
+
+	// A ~1000 byte log no longer fits: the oldest log makes room for it.
+	a.saveHTTPLog(&HTTPLog{Request: "get / http/1.1", Response: body(1000)})
+	if got := len(a.httpLogs); got != 3 {
+		t.Fatalf("expected 3 logs after eviction, got %d", got)
+	}
+	if a.GetHTTPLogByID(1) != nil || a.GetHTTPLogByID(2) == nil {
+		t.Fatal("expected the oldest log to be evicted first")
+	}
+	if got := a.BudgetEvictedTotal(); got != 1 {
+		t.Fatalf("expected 1 budget eviction, got %d", got)
+	}
+	if a.RetainedBytes() > a.RetainedBytesBudget() {
+		t.Fatalf("retained %d exceeds the budget %d", a.RetainedBytes(), a.RetainedBytesBudget())
+	}
+
+	// A log larger than the whole budget is kept alone.
+	a.saveHTTPLog(&HTTPLog{Request: "get / http/1.1", Response: body(5000)})
+	if got := len(a.httpLogs); got != 1 || a.httpLogs[0].ID != 5 {
+		t.Fatalf("expected only the oversized log to remain, got %d logs", got)
+	}
+
+	a.ClearHTTPLogs()
+	if got := a.RetainedBytes(); got != 0 {
+		t.Fatalf("expected no retained bytes after clear, got %d", got)
+	}
+}
+
+func TestHTTPLogRetainedBytes_CountsLoweredCopies(t *testing.T) {
+	lower := &HTTPLog{Request: "get / http/1.1", Response: "http/1.1 200 ok"}
+	lower.search = newHTTPLogSearch(lower)
+	upper := &HTTPLog{Request: "GET / HTTP/1.1", Response: "HTTP/1.1 200 OK"}
+	upper.search = newHTTPLogSearch(upper)
+
+	n := int64(len(upper.Request) + len(upper.Response))
+	if got := httpLogRetainedBytes(lower); got != n {
+		t.Fatalf("expected %d bytes for a lowercase log, got %d", n, got)
+	}
+	if got := httpLogRetainedBytes(upper); got != 2*n {
+		t.Fatalf("expected %d bytes with lowercase search copies, got %d", 2*n, got)
+	}
+}
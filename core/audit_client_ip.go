@@ -0,0 +1,103 @@
+package core
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// SetTrustedProxies sets the proxies (comma-separated IPs/CIDRs, AUDIT_TRUSTED_PROXIES) whose
+// X-Forwarded-For and Forwarded headers are believed when HTTP logs record the client IP. An
+// empty list trusts no headers, so logs keep the socket peer.
+func (a *Auditor) SetTrustedProxies(list string) error {
+	var entries []string
+	for _, raw := range strings.Split(list, ",") {
+		if raw = strings.TrimSpace(raw); raw != "" {
+			entries = append(entries, raw)
+		}
+	}
+	acl, err := NewIPAccessControl(entries, nil)
+	if err != nil {
+		return fmt.Errorf("AUDIT_TRUSTED_PROXIES: %w", err)
+	}
+	if a.pairMatcher != nil {
+		a.pairMatcher.trustedProxies.Store(acl)
+	}
+	return nil
+}
+
+// resolveClientIP returns the original client of a request that reached us from peer (host:port),
+// following its forwarding headers while each hop is a trusted proxy. proxy is the peer IP when the
+// client was taken from the headers, empty otherwise.
+func resolveClientIP(peer string, headers map[string][]string, trusted *IPAccessControl) (client, proxy string) {
+	host, _, err := net.SplitHostPort(peer)
+	if err != nil {
+		host = peer
+	}
+	peerIP := net.ParseIP(host)
+	if peerIP == nil {
+		return host, ""
+	}
+	if trusted == nil || !trusted.Allows(peerIP) {
+		return peerIP.String(), ""
+	}
+
+	// Walk the hops from the nearest one back while they are trusted proxies.
+	clientIP := peerIP
+	hops := forwardedHops(headers)
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := hops[i]
+		if ip == nil {
+			break // "unknown" or an obfuscated identifier: nothing further can be trusted
+		}
+		clientIP = ip
+		if !trusted.Allows(ip) {
+			break
+		}
+	}
+	if clientIP.Equal(peerIP) {
+		return peerIP.String(), ""
+	}
+	return clientIP.String(), peerIP.String()
+}
+
+// forwardedHops lists the addresses of a request's forwarding chain, client first: the for=
+// parameters of Forwarded (RFC 7239) when present, else X-Forwarded-For. Entries that are not IPs
+// are nil.
+func forwardedHops(headers map[string][]string) []net.IP {
+	var hops []net.IP
+	if values := headers["forwarded"]; len(values) > 0 {
+		for _, value := range values {
+			for _, element := range strings.Split(value, ",") {
+				for _, pair := range strings.Split(element, ";") {
+					k, v, ok := strings.Cut(strings.TrimSpace(pair), "=")
+					if ok && strings.EqualFold(k, "for") {
+						hops = append(hops, parseForwardedNode(v))
+					}
+				}
+			}
+		}
+		return hops
+	}
+	for _, value := range headers["x-forwarded-for"] {
+		for _, entry := range strings.Split(value, ",") {
+			if entry = strings.TrimSpace(entry); entry != "" {
+				hops = append(hops, parseForwardedNode(entry))
+			}
+		}
+	}
+	return hops
+}
+
+// parseForwardedNode parses a hop such as 192.0.2.43, "192.0.2.43:80" or "[2001:db8::1]:4711".
+func parseForwardedNode(node string) net.IP {
+	node = strings.Trim(strings.TrimSpace(node), `"`)
+	if strings.HasPrefix(node, "[") {
+		if end := strings.IndexByte(node, ']'); end > 0 {
+			node = node[1:end]
+		}
+	} else if strings.Count(node, ":") == 1 {
+		node, _, _ = strings.Cut(node, ":")
+	}
+	return net.ParseIP(node)
+}
@@ -0,0 +1,68 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+func TestResolveClientIP(t *testing.T) {
+	trusted, err := NewIPAccessControl([]string{"127.0.0.1", "10.0.0.0/8"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name          string
+		peer          string
+		headers       map[string][]string
+		client, proxy string
+	}{
+		{"no headers", "127.0.0.1:5000", nil, "127.0.0.1", ""},
+		{"xff from trusted proxy", "127.0.0.1:5000", map[string][]string{"x-forwarded-for": {"203.0.113.7"}}, "203.0.113.7", "127.0.0.1"},
+		{"xff from untrusted peer", "192.0.2.1:5000", map[string][]string{"x-forwarded-for": {"203.0.113.7"}}, "192.0.2.1", ""},
+		{"spoofed leftmost entry", "127.0.0.1:5000", map[string][]string{"x-forwarded-for": {"1.1.1.1, 203.0.113.7, 10.1.2.3"}}, "203.0.113.7", "127.0.0.1"},
+		{"repeated header lines", "127.0.0.1:5000", map[string][]string{"x-forwarded-for": {"203.0.113.7", "10.1.2.3"}}, "203.0.113.7", "127.0.0.1"},
+		{"all hops trusted", "127.0.0.1:5000", map[string][]string{"x-forwarded-for": {"10.0.0.9"}}, "10.0.0.9", "127.0.0.1"},
+		{"forwarded wins", "127.0.0.1:5000", map[string][]string{
+			"forwarded":       {`for="[2001:db8::1]:4711";proto=https, for=10.0.0.2`},
+			"x-forwarded-for": {"198.51.100.1"},
+		}, "2001:db8::1", "127.0.0.1"},
+		{"obfuscated hop stops the walk", "127.0.0.1:5000", map[string][]string{"forwarded": {"for=203.0.113.7, for=_hidden"}}, "127.0.0.1", ""},
+		{"forwarded with port", "127.0.0.1:5000", map[string][]string{"forwarded": {`for="203.0.113.7:80"`}}, "203.0.113.7", "127.0.0.1"},
+	}
+	for _, c := range cases {
+		client, proxy := resolveClientIP(c.peer, c.headers, trusted)
+		if client != c.client || proxy != c.proxy {
+			t.Errorf("%s: got client %q proxy %q, want %q %q", c.name, client, proxy, c.client, c.proxy)
+		}
+	}
+
+	if client, proxy := resolveClientIP("127.0.0.1:5000", map[string][]string{"x-forwarded-for": {"203.0.113.7"}}, nil); client != "127.0.0.1" || proxy != "" {
+		t.Fatalf("expected headers to be ignored without trusted proxies, got %q %q", client, proxy)
+	}
+}
+
+func TestHTTPPairMatcher_TrustedProxyClientIP(t *testing.T) {
+	a := &Auditor{pairMatcher: NewHTTPPairMatcher(nil)}
+	if err := a.SetTrustedProxies("bogus"); err == nil {
+		t.Fatal("expected an invalid entry to be rejected")
+	}
+	if err := a.SetTrustedProxies("127.0.0.1, 10.0.0.0/8"); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	req := &HTTPMessage{Data: []byte("GET / HTTP/1.1\r\nHost: a\r\nX-Forwarded-For: 203.0.113.7\r\n\r\n"), Timestamp: now}
+	httpLog := a.pairMatcher.createHTTPLog(AuditContext{}, "127.0.0.1:5000->10.0.0.1:80", req, nil)
+	if httpLog.ClientIP != "203.0.113.7" || httpLog.ProxyIP != "127.0.0.1" {
+		t.Fatalf("unexpected client %q via %q", httpLog.ClientIP, httpLog.ProxyIP)
+	}
+	if want := "127.0.0.1:5000->10.0.0.1:80 [client=203.0.113.7 via 127.0.0.1:5000]"; httpLog.ConnID != want {
+		t.Fatalf("unexpected conn id %q", httpLog.ConnID)
+	}
+
+	httpLog = a.pairMatcher.createHTTPLog(AuditContext{}, "192.0.2.1:5000->10.0.0.1:80", req, nil)
+	if httpLog.ClientIP != "192.0.2.1" || httpLog.ProxyIP != "" || httpLog.ConnID != "192.0.2.1:5000->10.0.0.1:80 [client=192.0.2.1:5000]" {
+		t.Fatalf("expected an untrusted peer to keep its address, got %+v", httpLog)
+	}
+}
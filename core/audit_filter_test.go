@@ -1,6 +1,8 @@
 package core
 
 import (
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 )
@@ -130,6 +132,58 @@ func TestAuditor_QueryHTTPLogs_FilterAndPagination(t *testing.T) {
 	}
 }
 
+func TestAuditor_QueryHTTPLogs_CaseInsensitive(t *testing.T) {
+	a := &Auditor{
+		httpLogs:    make([]*HTTPLog, 0, 10),
+		httpLogsMap: make(map[int]*HTTPLog),
+		maxLogs:     10,
+	}
+	a.saveHTTPLog(&HTTPLog{
+		Host:     "API.Example.com",
+		URL:      "/Users/42",
+		Request:  "GET /Users/42 HTTP/1.1\r\nX-Trace: AbC\r\n\r\n",
+		Response: "HTTP/1.1 200 OK",
+	})
+
+	for _, filter := range []HTTPLogFilter{
+		{Query: "x-trace: abc"},
+		{Query: "X-TRACE"},
+		{Host: "api.EXAMPLE"},
+		{URL: "/users"},
+	} {
+		if _, total := a.QueryHTTPLogs(filter, 1, 20); total != 1 {
+			t.Fatalf("expected match for %+v, got %d", filter, total)
+		}
+	}
+	if _, total := a.QueryHTTPLogs(HTTPLogFilter{Query: "missing"}, 1, 20); total != 0 {
+		t.Fatalf("expected no match, got %d", total)
+	}
+}
+
+func BenchmarkAuditor_QueryHTTPLogs100k(b *testing.B) {
+	const n = 100000
+	a := &Auditor{
+		httpLogs:    make([]*HTTPLog, 0, n),
+		httpLogsMap: make(map[int]*HTTPLog, n),
+		maxLogs:     n,
+	}
+	body := strings.Repeat("Lorem Ipsum Dolor Sit Amet ", 40)
+	for i := 0; i < n; i++ {
+		a.saveHTTPLog(&HTTPLog{
+			Method:   "GET",
+			Host:     "Service.Internal",
+			URL:      "/api/v1/items/" + strconv.Itoa(i),
+			Request:  "GET /api/v1/items HTTP/1.1\r\nHost: Service.Internal\r\n\r\n",
+			Response: "HTTP/1.1 200 OK\r\n\r\n" + body,
+		})
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		a.QueryHTTPLogs(HTTPLogFilter{Query: "needle-not-present"}, 1, 20)
+	}
+}
+
 func TestHTTPPairMatcher_StatusCodeParsed(t *testing.T) {
 	matcher := NewHTTPPairMatcher(nil)
 	now := time.Now()
@@ -150,3 +204,43 @@ func TestHTTPPairMatcher_StatusCodeParsed(t *testing.T) {
 		t.Fatalf("expected status_code=404, got %d", httpLog.StatusCode)
 	}
 }
+
+func TestAuditor_QueryHTTPLogs_HeaderFilter(t *testing.T) {
+	a := &Auditor{
+		httpLogs:    make([]*HTTPLog, 0, 10),
+		httpLogsMap: make(map[int]*HTTPLog),
+		maxLogs:     10,
+	}
+	matcher := NewHTTPPairMatcher(nil)
+	now := time.Now()
+
+	httpLog := matcher.createHTTPLog(AuditContext{}, "127.0.0.1:1->127.0.0.1:2",
+		&HTTPMessage{Type: HTTPRequest, Timestamp: now, Data: []byte("GET /a HTTP/1.1\r\nHost: example.com\r\nX-Request-ID: Abc-123\r\nAccept: */*\r\nAccept: text/html\r\n\r\n")},
+		&HTTPMessage{Type: HTTPResponse, Timestamp: now, Data: []byte("HTTP/1.1 200 OK\r\nContent-Type: application/json\r\n\r\n{}")})
+	if got := httpLog.RequestHeaders["x-request-id"]; len(got) != 1 || got[0] != "Abc-123" {
+		t.Fatalf("unexpected request headers: %+v", httpLog.RequestHeaders)
+	}
+	if got := httpLog.RequestHeaders["accept"]; len(got) != 2 {
+		t.Fatalf("expected repeated headers to be kept, got %+v", got)
+	}
+	a.saveHTTPLog(httpLog)
+	// Built without the pair matcher: headers are parsed from the raw text when filtering.
+	a.saveHTTPLog(&HTTPLog{Request: "GET /b HTTP/1.1\r\nHost: example.com\r\n\r\n", Response: "HTTP/1.1 200 OK\r\nContent-Type: text/html\r\n\r\n"})
+
+	cases := []struct {
+		headers []HTTPHeaderFilter
+		want    int
+	}{
+		{[]HTTPHeaderFilter{{Name: "X-Request-Id"}}, 1},
+		{[]HTTPHeaderFilter{{Name: "x-request-id", Value: "abc"}}, 1},
+		{[]HTTPHeaderFilter{{Name: "x-request-id", Value: "xyz"}}, 0},
+		{[]HTTPHeaderFilter{{Name: "content-type", Value: "html"}}, 1},
+		{[]HTTPHeaderFilter{{Name: "host"}}, 2},
+		{[]HTTPHeaderFilter{{Name: "host"}, {Name: "content-type", Value: "JSON"}}, 1},
+	}
+	for _, tc := range cases {
+		if _, total := a.QueryHTTPLogs(HTTPLogFilter{Headers: tc.headers}, 1, 20); total != tc.want {
+			t.Fatalf("headers %+v: expected %d logs, got %d", tc.headers, tc.want, total)
+		}
+	}
+}
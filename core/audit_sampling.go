@@ -0,0 +1,58 @@
+package core
+
+import (
+	"bastion/config"
+	"bastion/models"
+	"fmt"
+	"hash/fnv"
+)
+
+// AuditSampling decides which connections of a session are audited.
+type AuditSampling struct {
+	Disabled bool    // mapping opted out of auditing
+	Rate     float64 // fraction of connections audited, more than 0 and at most 1
+}
+
+// NewAuditSampling resolves the mapping's audit overrides, falling back to AUDIT_SAMPLE_RATE when the
+// mapping does not set a rate.
+func NewAuditSampling(mapping *models.Mapping) AuditSampling {
+	p := AuditSampling{Rate: config.Settings.AuditSampleRate}
+	if mapping != nil {
+		p.Disabled = mapping.AuditDisabled
+		if mapping.AuditSampleRate > 0 {
+			p.Rate = mapping.AuditSampleRate
+		}
+	}
+	return p
+}
+
+// Audits reports whether the connection should be audited. The decision is a hash of connID, so both
+// directions of a connection agree without sharing state.
+func (p AuditSampling) Audits(connID string) bool {
+	if !config.Settings.AuditEnabled || p.Disabled {
+		return false
+	}
+	if p.Rate >= 1 {
+		return true
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(connID))
+	return float64(h.Sum32()) < p.Rate*(1<<32)
+}
+
+// ValidateGlobalAuditSampleRate checks AUDIT_SAMPLE_RATE. Unlike the per-mapping rate it has no
+// "unset" value: auditing is turned off with AUDIT_ENABLED.
+func ValidateGlobalAuditSampleRate(rate float64) error {
+	if !(rate > 0 && rate <= 1) {
+		return fmt.Errorf("invalid AUDIT_SAMPLE_RATE: %v (expected more than 0 and at most 1; set AUDIT_ENABLED=false to stop auditing)", rate)
+	}
+	return nil
+}
+
+// ValidateAuditSampleRate checks a per-mapping sample rate (0 = use the global default).
+func ValidateAuditSampleRate(rate float64) error {
+	if rate < 0 || rate > 1 {
+		return fmt.Errorf("invalid audit_sample_rate: %v (expected 0-1)", rate)
+	}
+	return nil
+}
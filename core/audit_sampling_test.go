@@ -0,0 +1,84 @@
+package core
+
+import (
+	"bastion/config"
+	"bastion/models"
+	"math"
+	"strconv"
+	"testing"
+)
+
+func TestAuditSampling(t *testing.T) {
+	prevEnabled, prevRate := config.Settings.AuditEnabled, config.Settings.AuditSampleRate
+	t.Cleanup(func() {
+		config.Settings.AuditEnabled, config.Settings.AuditSampleRate = prevEnabled, prevRate
+	})
+	config.Settings.AuditEnabled = true
+	config.Settings.AuditSampleRate = 1
+
+	if !NewAuditSampling(&models.Mapping{}).Audits("a->b") {
+		t.Fatalf("expected mapping without overrides to be audited")
+	}
+	if NewAuditSampling(&models.Mapping{AuditDisabled: true}).Audits("a->b") {
+		t.Fatalf("expected disabled mapping not to be audited")
+	}
+
+	config.Settings.AuditSampleRate = 0.5
+	if p := NewAuditSampling(&models.Mapping{AuditSampleRate: 0.1}); p.Rate != 0.1 {
+		t.Fatalf("expected mapping rate to override the global one, got %v", p.Rate)
+	}
+	if p := NewAuditSampling(&models.Mapping{}); p.Rate != 0.5 {
+		t.Fatalf("expected global rate, got %v", p.Rate)
+	}
+
+	p := AuditSampling{Rate: 0.1}
+	audited := 0
+	for i := 0; i < 10000; i++ {
+		connID := "10.0.0.1:" + strconv.Itoa(20000+i) + "->127.0.0.1:80"
+		if p.Audits(connID) {
+			audited++
+		}
+		if p.Audits(connID) != p.Audits(connID) {
+			t.Fatalf("sampling decision is not stable for %s", connID)
+		}
+	}
+	if audited < 800 || audited > 1200 {
+		t.Fatalf("expected ~10%% of connections audited, got %d/10000", audited)
+	}
+
+	// 0 is only "unset" for a mapping: a rate of 0 audits nothing rather than everything.
+	if (AuditSampling{Rate: 0}).Audits("a->b") {
+		t.Fatalf("expected rate 0 to audit no connection")
+	}
+
+	config.Settings.AuditEnabled = false
+	if (AuditSampling{Rate: 1}).Audits("a->b") {
+		t.Fatalf("expected AUDIT_ENABLED=false to win")
+	}
+}
+
+func TestValidateAuditSampleRate(t *testing.T) {
+	for _, rate := range []float64{0, 0.25, 1} {
+		if err := ValidateAuditSampleRate(rate); err != nil {
+			t.Fatalf("rate %v: %v", rate, err)
+		}
+	}
+	for _, rate := range []float64{-0.1, 1.5} {
+		if err := ValidateAuditSampleRate(rate); err == nil {
+			t.Fatalf("expected rate %v to be rejected", rate)
+		}
+	}
+}
+
+func TestValidateGlobalAuditSampleRate(t *testing.T) {
+	for _, rate := range []float64{0.001, 0.25, 1} {
+		if err := ValidateGlobalAuditSampleRate(rate); err != nil {
+			t.Fatalf("rate %v: %v", rate, err)
+		}
+	}
+	for _, rate := range []float64{0, -0.1, 1.5, math.NaN()} {
+		if err := ValidateGlobalAuditSampleRate(rate); err == nil {
+			t.Fatalf("expected rate %v to be rejected", rate)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package core
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// httpLogSubscriptionBuffer is how many summaries a slow subscriber may fall behind before new
+// ones are dropped for it.
+const httpLogSubscriptionBuffer = 256
+
+// HTTPLogSummary is an HTTP log without its request and response contents, as pushed to live
+// subscribers.
+type HTTPLogSummary struct {
+	ID               int       `json:"id"`
+	Timestamp        time.Time `json:"timestamp"`
+	ConnID           string    `json:"conn_id"`
+	MappingID        string    `json:"mapping_id"`
+	LocalPort        int       `json:"local_port"`
+	BastionChain     []string  `json:"bastion_chain,omitempty"`
+	Method           string    `json:"method"`
+	URL              string    `json:"url"`
+	Host             string    `json:"host"`
+	Protocol         string    `json:"protocol"`
+	StatusCode       int       `json:"status_code"`
+	ReqSize          int       `json:"req_size"`
+	RespSize         int       `json:"resp_size"`
+	IsGzipped        bool      `json:"is_gzipped"`
+	DurationMs       int64     `json:"duration_ms"`
+	TTFBMs           int64     `json:"ttfb_ms"`
+	TTLBMs           int64     `json:"ttlb_ms"`
+	RequestSeq       int       `json:"request_seq"`
+	ConnectionReused bool      `json:"connection_reused"`
+	ClientIP         string    `json:"client_ip,omitempty"`
+}
+
+// Summary returns the log without its request and response contents.
+func (l *HTTPLog) Summary() HTTPLogSummary {
+	return HTTPLogSummary{
+		ID:               l.ID,
+		Timestamp:        l.Timestamp,
+		ConnID:           l.ConnID,
+		MappingID:        l.MappingID,
+		LocalPort:        l.LocalPort,
+		BastionChain:     l.BastionChain,
+		Method:           l.Method,
+		URL:              l.URL,
+		Host:             l.Host,
+		Protocol:         l.Protocol,
+		StatusCode:       l.StatusCode,
+		ReqSize:          l.ReqSize,
+		RespSize:         l.RespSize,
+		IsGzipped:        l.IsGzipped,
+		DurationMs:       l.DurationMs,
+		TTFBMs:           l.TTFBMs,
+		TTLBMs:           l.TTLBMs,
+		RequestSeq:       l.RequestSeq,
+		ConnectionReused: l.ConnectionReused,
+		ClientIP:         l.ClientIP,
+	}
+}
+
+// HTTPLogSubscription receives summaries of newly captured HTTP logs matching its filter.
+type HTTPLogSubscription struct {
+	C <-chan HTTPLogSummary
+
+	ch      chan HTTPLogSummary
+	filter  HTTPLogFilter
+	dropped uint64
+	auditor *Auditor
+	once    sync.Once
+}
+
+// TakeDropped returns how many summaries were dropped because the subscriber fell behind since
+// the last call, and resets the count.
+func (s *HTTPLogSubscription) TakeDropped() uint64 {
+	return atomic.SwapUint64(&s.dropped, 0)
+}
+
+// Close unsubscribes and closes C.
+func (s *HTTPLogSubscription) Close() {
+	s.once.Do(func() {
+		a := s.auditor
+		a.subMu.Lock()
+		delete(a.subscribers, s)
+		a.subMu.Unlock()
+		close(s.ch)
+	})
+}
+
+// SubscribeHTTPLogs follows HTTP logs saved from now on that match filter. Delivery never blocks
+// the audit pipeline: summaries a subscriber cannot take in time are dropped and counted.
+func (a *Auditor) SubscribeHTTPLogs(filter HTTPLogFilter) *HTTPLogSubscription {
+	filter = filter.lowered()
+
+	ch := make(chan HTTPLogSummary, httpLogSubscriptionBuffer)
+	sub := &HTTPLogSubscription{C: ch, ch: ch, filter: filter, auditor: a}
+
+	a.subMu.Lock()
+	if a.subscribers == nil {
+		a.subscribers = make(map[*HTTPLogSubscription]struct{})
+	}
+	a.subscribers[sub] = struct{}{}
+	a.subMu.Unlock()
+	return sub
+}
+
+// HTTPLogSubscribers returns the number of live HTTP log subscribers.
+func (a *Auditor) HTTPLogSubscribers() int {
+	a.subMu.Lock()
+	defer a.subMu.Unlock()
+	return len(a.subscribers)
+}
+
+func (a *Auditor) publishHTTPLog(httpLog *HTTPLog) {
+	a.subMu.Lock()
+	defer a.subMu.Unlock()
+	if len(a.subscribers) == 0 {
+		return
+	}
+
+	summary := httpLog.Summary()
+	for sub := range a.subscribers {
+		if !httpLogMatchesFilter(httpLog, sub.filter) {
+			continue
+		}
+		select {
+		case sub.ch <- summary:
+		default:
+			atomic.AddUint64(&sub.dropped, 1)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package core
+
+import (
+	"testing"
+)
+
+func TestAuditor_SubscribeHTTPLogs_Filter(t *testing.T) {
+	a := &Auditor{
+		httpLogs:    make([]*HTTPLog, 0, 10),
+		httpLogsMap: make(map[int]*HTTPLog),
+		maxLogs:     10,
+	}
+
+	sub := a.SubscribeHTTPLogs(HTTPLogFilter{Method: "get", Host: "API.Example.com", StatusCode: 500})
+	a.saveHTTPLog(&HTTPLog{Method: "GET", Host: "api.example.com", URL: "/ok", StatusCode: 200})
+	a.saveHTTPLog(&HTTPLog{Method: "POST", Host: "api.example.com", URL: "/post", StatusCode: 500})
+	a.saveHTTPLog(&HTTPLog{Method: "GET", Host: "api.example.com", URL: "/boom", StatusCode: 500, Response: "secret"})
+
+	select {
+	case summary := <-sub.C:
+		if summary.URL != "/boom" || summary.ID != 3 {
+			t.Fatalf("unexpected summary: %+v", summary)
+		}
+	default:
+		t.Fatal("expected the matching log to be delivered")
+	}
+	select {
+	case summary := <-sub.C:
+		t.Fatalf("unexpected extra summary: %+v", summary)
+	default:
+	}
+
+	sub.Close()
+	sub.Close()
+	if _, ok := <-sub.C; ok {
+		t.Fatal("expected the channel to be closed")
+	}
+	if n := a.HTTPLogSubscribers(); n != 0 {
+		t.Fatalf("expected no subscribers after Close, got %d", n)
+	}
+	a.saveHTTPLog(&HTTPLog{Method: "GET", StatusCode: 500})
+}
+
+func TestAuditor_SubscribeHTTPLogs_DropsWhenBehind(t *testing.T) {
+	a := &Auditor{
+		httpLogs:    make([]*HTTPLog, 0, 10),
+		httpLogsMap: make(map[int]*HTTPLog),
+		maxLogs:     10,
+	}
+	sub := a.SubscribeHTTPLogs(HTTPLogFilter{})
+	defer sub.Close()
+
+	for i := 0; i < httpLogSubscriptionBuffer+5; i++ {
+		a.saveHTTPLog(&HTTPLog{Method: "GET"})
+	}
+	if got := sub.TakeDropped(); got != 5 {
+		t.Fatalf("expected 5 dropped summaries, got %d", got)
+	}
+	if got := sub.TakeDropped(); got != 0 {
+		t.Fatalf("expected the dropped count to reset, got %d", got)
+	}
+}
@@ -0,0 +1,247 @@
+package core
+
+import (
+	"bastion/models"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Benchmark limits
+const (
+	DefaultBenchmarkBytes = 8 << 20
+	MaxBenchmarkBytes     = 256 << 20
+	DefaultBenchmarkPings = 5
+	MaxBenchmarkPings     = 50
+	benchmarkTimeout      = 2 * time.Minute
+	benchmarkChunkSize    = 32 << 10
+)
+
+// Benchmark modes
+const (
+	BenchmarkModeRemote = "remote" // commands on the last bastion sink and source the data
+	BenchmarkModeEcho   = "echo"   // an echo endpoint behind the chain returns the data
+)
+
+// BenchmarkOptions configures Benchmark.
+type BenchmarkOptions struct {
+	Bytes  int64  // data sent each way
+	Pings  int    // latency samples
+	Target string // echo endpoint (host:port) dialed through the chain; empty uses BenchmarkModeRemote
+}
+
+// BenchmarkLatency is the round-trip time to the last bastion, measured with SSH keepalive requests.
+type BenchmarkLatency struct {
+	Samples int     `json:"samples"`
+	MinMS   float64 `json:"min_ms"`
+	AvgMS   float64 `json:"avg_ms"`
+	MaxMS   float64 `json:"max_ms"`
+	Error   string  `json:"error,omitempty"`
+}
+
+// BenchmarkTransfer is the outcome of one timed data transfer.
+type BenchmarkTransfer struct {
+	Bytes       int64   `json:"bytes"`
+	DurationMS  int64   `json:"duration_ms"`
+	BytesPerSec float64 `json:"bytes_per_sec"`
+	Mbps        float64 `json:"mbps"`
+	Error       string  `json:"error,omitempty"`
+}
+
+// BenchmarkReport describes the speed of a bastion chain: how long each hop took to connect, the
+// latency to the last bastion and the throughput of the transfers.
+type BenchmarkReport struct {
+	OK         bool               `json:"ok"`
+	Route      string             `json:"route"`
+	Mode       string             `json:"mode"`
+	Target     string             `json:"target,omitempty"`
+	Hops       []DryRunStep       `json:"hops"`
+	Latency    *BenchmarkLatency  `json:"latency,omitempty"`
+	Upload     *BenchmarkTransfer `json:"upload,omitempty"`
+	Download   *BenchmarkTransfer `json:"download,omitempty"`
+	Echo       *BenchmarkTransfer `json:"echo,omitempty"`
+	DurationMS int64              `json:"duration_ms"`
+}
+
+// Benchmark connects through bastions with fresh SSH clients, like DryRun, and measures the chain:
+// keepalive round trips to the last bastion, then either an upload into `cat > /dev/null` and a
+// download from `head -c N /dev/urandom` run on the last bastion, or, with a target, an echo of
+// the data through a connection to the target. Pooled chains and running mappings are not used.
+func Benchmark(bastions []models.Bastion, opts BenchmarkOptions) *BenchmarkReport {
+	start := time.Now()
+	report := &BenchmarkReport{Route: "bastion chain [" + getBastionChainNames(bastions) + "]", Mode: BenchmarkModeRemote, Target: opts.Target}
+	if opts.Target != "" {
+		report.Mode = BenchmarkModeEcho
+	}
+	defer func() { report.DurationMS = time.Since(start).Milliseconds() }()
+
+	clients, hops, ok := connectHops(bastions)
+	defer closeClients(clients)
+	report.Hops = hops
+	if !ok || len(clients) == 0 {
+		return report
+	}
+	last := clients[len(clients)-1]
+
+	// A stalled transfer is ended by closing the chain under it.
+	timer := time.AfterFunc(benchmarkTimeout, func() { closeClients(clients) })
+	defer timer.Stop()
+
+	report.Latency = benchmarkLatency(last, opts.Pings)
+	report.OK = report.Latency.Error == ""
+	if opts.Target == "" {
+		report.Upload = benchmarkUpload(last, opts.Bytes)
+		report.Download = benchmarkDownload(last, opts.Bytes)
+		report.OK = report.OK && report.Upload.Error == "" && report.Download.Error == ""
+	} else {
+		report.Echo = benchmarkEcho(func() (net.Conn, error) { return last.Dial("tcp", opts.Target) }, opts.Bytes)
+		report.OK = report.OK && report.Echo.Error == ""
+	}
+	return report
+}
+
+// benchmarkLatency times pings keepalive requests; the reply (usually a refusal) is a full round trip.
+func benchmarkLatency(client sshClient, pings int) *BenchmarkLatency {
+	latency := &BenchmarkLatency{}
+	var total time.Duration
+	for i := 0; i < pings; i++ {
+		start := time.Now()
+		if _, _, err := client.SendRequest("keepalive@openssh.com", true, nil); err != nil {
+			latency.Error = err.Error()
+			break
+		}
+		rtt := time.Since(start)
+		ms := durationMS(rtt)
+		if latency.Samples == 0 || ms < latency.MinMS {
+			latency.MinMS = ms
+		}
+		if ms > latency.MaxMS {
+			latency.MaxMS = ms
+		}
+		total += rtt
+		latency.Samples++
+	}
+	if latency.Samples > 0 {
+		latency.AvgMS = durationMS(total / time.Duration(latency.Samples))
+	}
+	return latency
+}
+
+// benchmarkUpload streams n bytes into `cat > /dev/null` on the server of client.
+func benchmarkUpload(client *ssh.Client, n int64) *BenchmarkTransfer {
+	return timedTransfer(func() (int64, error) {
+		session, err := client.NewSession()
+		if err != nil {
+			return 0, err
+		}
+		defer session.Close()
+		stdin, err := session.StdinPipe()
+		if err != nil {
+			return 0, err
+		}
+		if err := session.Start("cat > /dev/null"); err != nil {
+			return 0, err
+		}
+		written, err := io.CopyN(stdin, newBenchmarkPayload(), n)
+		_ = stdin.Close()
+		if err != nil {
+			return written, err
+		}
+		return written, session.Wait()
+	})
+}
+
+// benchmarkDownload reads n bytes of `head -c n /dev/urandom` run on the server of client.
+func benchmarkDownload(client *ssh.Client, n int64) *BenchmarkTransfer {
+	return timedTransfer(func() (int64, error) {
+		session, err := client.NewSession()
+		if err != nil {
+			return 0, err
+		}
+		defer session.Close()
+		stdout, err := session.StdoutPipe()
+		if err != nil {
+			return 0, err
+		}
+		if err := session.Start(fmt.Sprintf("head -c %d /dev/urandom", n)); err != nil {
+			return 0, err
+		}
+		read, err := io.Copy(io.Discard, stdout)
+		if err != nil {
+			return read, err
+		}
+		if err := session.Wait(); err != nil {
+			return read, err
+		}
+		if read != n {
+			return read, fmt.Errorf("received %d of %d bytes", read, n)
+		}
+		return read, nil
+	})
+}
+
+// benchmarkEcho sends n bytes through a connection from dial and reads them back from the echo
+// endpoint at the same time; the throughput is that of each direction.
+func benchmarkEcho(dial func() (net.Conn, error), n int64) *BenchmarkTransfer {
+	return timedTransfer(func() (int64, error) {
+		conn, err := dial()
+		if err != nil {
+			return 0, err
+		}
+		defer conn.Close()
+
+		writeErr := make(chan error, 1)
+		go func() {
+			_, err := io.CopyN(conn, newBenchmarkPayload(), n)
+			writeErr <- err
+		}()
+		read, err := io.CopyN(io.Discard, conn, n)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				err = fmt.Errorf("echo endpoint returned %d of %d bytes", read, n)
+			}
+			return read, err
+		}
+		return read, <-writeErr
+	})
+}
+
+// timedTransfer runs transfer and reports its throughput.
+func timedTransfer(transfer func() (int64, error)) *BenchmarkTransfer {
+	start := time.Now()
+	n, err := transfer()
+	elapsed := time.Since(start)
+
+	result := &BenchmarkTransfer{Bytes: n, DurationMS: elapsed.Milliseconds()}
+	if secs := elapsed.Seconds(); secs > 0 {
+		result.BytesPerSec = float64(n) / secs
+		result.Mbps = result.BytesPerSec * 8 / 1e6
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// benchmarkPayload repeats a block of random bytes forever.
+type benchmarkPayload struct {
+	block []byte
+	off   int
+}
+
+func newBenchmarkPayload() *benchmarkPayload {
+	block := make([]byte, benchmarkChunkSize)
+	_, _ = rand.Read(block)
+	return &benchmarkPayload{block: block}
+}
+
+func (p *benchmarkPayload) Read(b []byte) (int, error) {
+	n := copy(b, p.block[p.off:])
+	p.off = (p.off + n) % len(p.block)
+	return n, nil
+}
@@ -0,0 +1,67 @@
+package core
+
+import (
+	"io"
+	"net"
+	"testing"
+
+	"bastion/models"
+)
+
+func TestBenchmarkEcho(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_, _ = io.Copy(conn, conn)
+			}()
+		}
+	}()
+	dial := func() (net.Conn, error) { return net.Dial("tcp", ln.Addr().String()) }
+
+	const n = 3*benchmarkChunkSize + 123
+	result := benchmarkEcho(dial, n)
+	if result.Error != "" || result.Bytes != n || result.BytesPerSec <= 0 {
+		t.Fatalf("unexpected echo result: %+v", result)
+	}
+
+	// An endpoint that closes early returns fewer bytes than were sent.
+	closer, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer closer.Close()
+	go func() {
+		conn, err := closer.Accept()
+		if err == nil {
+			_ = conn.Close()
+		}
+	}()
+	result = benchmarkEcho(func() (net.Conn, error) { return net.Dial("tcp", closer.Addr().String()) }, n)
+	if result.Error == "" {
+		t.Fatalf("expected short echo to fail: %+v", result)
+	}
+}
+
+func TestBenchmark_StopsAtFirstFailedHop(t *testing.T) {
+	bastions := []models.Bastion{
+		{Name: "jump", Host: "127.0.0.1", Port: 22, Username: "u"}, // no auth method
+	}
+
+	report := Benchmark(bastions, BenchmarkOptions{Bytes: 1024, Pings: 1})
+	if report.OK || len(report.Hops) != 1 || report.Hops[0].Status != DryRunFailed {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if report.Mode != BenchmarkModeRemote || report.Latency != nil || report.Upload != nil || report.Download != nil {
+		t.Fatalf("expected no measurements after a failed hop: %+v", report)
+	}
+}
@@ -0,0 +1,258 @@
+package core
+
+import (
+	"bastion/config"
+	"bastion/models"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// clientLimiterSweepInterval bounds how often idle per-IP entries are reclaimed.
+const clientLimiterSweepInterval = time.Minute
+
+// ClientLimiter enforces per-source-IP concurrent connection caps and a token-bucket
+// rate limit on new connections for a single session.
+type ClientLimiter struct {
+	maxConns int     // 0 = unlimited
+	rate     float64 // tokens per second; 0 = unlimited
+	burst    float64
+
+	mu        sync.Mutex
+	clients   map[string]*clientLimitState
+	lastSweep time.Time
+}
+
+type clientLimitState struct {
+	active int
+	tokens float64
+	last   time.Time
+}
+
+// NewClientLimiter builds a limiter from the mapping's overrides, falling back to the global settings.
+// Mapping values of 0 use the global default, negative values disable the limit. Returns nil when no limit applies.
+func NewClientLimiter(mapping *models.Mapping) *ClientLimiter {
+	maxConns := resolveClientLimit(mapping.MaxConnsPerIP, config.Settings.MaxConnsPerIP)
+	burst := resolveClientLimit(mapping.ConnBurstPerIP, config.Settings.ConnBurstPerIP)
+
+	rate := mapping.ConnRatePerIP
+	if rate == 0 {
+		rate = config.Settings.ConnRatePerIP
+	}
+	if rate < 0 {
+		rate = 0
+	}
+	if rate > 0 && burst <= 0 {
+		burst = 1
+	}
+
+	if maxConns == 0 && rate == 0 {
+		return nil
+	}
+	return &ClientLimiter{
+		maxConns: maxConns,
+		rate:     rate,
+		burst:    float64(burst),
+		clients:  make(map[string]*clientLimitState),
+	}
+}
+
+// NewRateLimiter builds a per-IP token bucket of rate tokens per second holding up to burst, with
+// no concurrency cap; use Allow. Returns nil when rate is not positive.
+func NewRateLimiter(rate float64, burst int) *ClientLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	if burst <= 0 {
+		burst = 1
+	}
+	return &ClientLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		clients: make(map[string]*clientLimitState),
+	}
+}
+
+// ValidateClientLimits checks per-mapping client limit overrides.
+func ValidateClientLimits(maxConns int, rate float64, burst int) error {
+	if maxConns < -1 {
+		return fmt.Errorf("invalid max_conns_per_ip: %d (use -1 for unlimited)", maxConns)
+	}
+	if rate < 0 && rate != -1 {
+		return fmt.Errorf("invalid conn_rate_per_ip: %g (use -1 for unlimited)", rate)
+	}
+	if burst < -1 {
+		return fmt.Errorf("invalid conn_burst_per_ip: %d", burst)
+	}
+	return nil
+}
+
+func resolveClientLimit(override, global int) int {
+	if override == 0 {
+		override = global
+	}
+	if override < 0 {
+		return 0
+	}
+	return override
+}
+
+// Acquire admits a new connection from ip. On success the returned release func must be called exactly once
+// when the connection closes. reason describes why a connection was rejected.
+func (l *ClientLimiter) Acquire(ip string, now time.Time) (release func(), reason string, ok bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweepLocked(now)
+
+	st := l.clients[ip]
+	if st == nil {
+		st = &clientLimitState{tokens: l.burst, last: now}
+		l.clients[ip] = st
+	}
+
+	if l.maxConns > 0 && st.active >= l.maxConns {
+		return nil, "per-IP connection limit reached", false
+	}
+
+	if l.rate > 0 && l.takeTokenLocked(st, now) > 0 {
+		return nil, "per-IP connection rate exceeded", false
+	}
+
+	st.active++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			st.active--
+			l.mu.Unlock()
+		})
+	}, "", true
+}
+
+// Allow takes a token from ip's bucket, for limiters built by NewRateLimiter. When the bucket is
+// empty it returns false and how long until the next token.
+func (l *ClientLimiter) Allow(ip string, now time.Time) (retryAfter time.Duration, ok bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweepLocked(now)
+
+	st := l.clients[ip]
+	if st == nil {
+		st = &clientLimitState{tokens: l.burst, last: now}
+		l.clients[ip] = st
+	}
+	if wait := l.takeTokenLocked(st, now); wait > 0 {
+		return wait, false
+	}
+	return 0, true
+}
+
+// takeTokenLocked refills st's bucket and takes a token. It returns 0 on success, otherwise the
+// time until a token is available.
+func (l *ClientLimiter) takeTokenLocked(st *clientLimitState, now time.Time) time.Duration {
+	st.tokens += now.Sub(st.last).Seconds() * l.rate
+	if st.tokens > l.burst {
+		st.tokens = l.burst
+	}
+	st.last = now
+	if st.tokens < 1 {
+		return time.Duration((1 - st.tokens) / l.rate * float64(time.Second))
+	}
+	st.tokens--
+	return 0
+}
+
+// sweepLocked drops entries with no active connections whose token bucket has refilled.
+func (l *ClientLimiter) sweepLocked(now time.Time) {
+	if now.Sub(l.lastSweep) < clientLimiterSweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	for ip, st := range l.clients {
+		if st.active > 0 {
+			continue
+		}
+		if l.rate > 0 && st.tokens+now.Sub(st.last).Seconds()*l.rate < l.burst {
+			continue
+		}
+		delete(l.clients, ip)
+	}
+}
+
+// limitedConn releases its per-IP slot when closed.
+type limitedConn struct {
+	net.Conn
+	release func()
+}
+
+func (c *limitedConn) Close() error {
+	err := c.Conn.Close()
+	c.release()
+	return err
+}
+
+func (c *limitedConn) CloseWrite() error {
+	if cw, ok := c.Conn.(interface{ CloseWrite() error }); ok {
+		return cw.CloseWrite()
+	}
+	return nil
+}
+
+// admitClient applies the IP ACL, the session-wide connection cap, the mapping's quota and the per-IP
+// limits to a newly accepted connection. Rejected connections are closed. The returned conn must be used in place of conn.
+func (s *BaseSession) admitClient(conn net.Conn, tag string) (net.Conn, bool) {
+	if !s.shouldAcceptClient(conn) {
+		s.emitEvent(EventACLReject, tag, conn.RemoteAddr().String(), "", "ip_acl")
+		if config.Settings.LogLevel == "DEBUG" {
+			log.Printf("[%s] Rejected client %s by IP ACL", tag, conn.RemoteAddr().String())
+		}
+		_ = conn.Close()
+		return nil, false
+	}
+
+	// Enforce connection limit
+	if atomic.LoadInt32(&s.activeConns) >= s.maxConnections {
+		log.Printf("Connection limit reached (%d), rejecting new connection", s.maxConnections)
+		s.emitEvent(EventLimitReject, tag, conn.RemoteAddr().String(), "", "session connection limit reached")
+		_ = conn.Close()
+		return nil, false
+	}
+
+	conn, ok := s.admitQuota(conn, tag)
+	if !ok {
+		return nil, false
+	}
+
+	if s.clientLimiter == nil {
+		return conn, true
+	}
+	ip := clientIP(conn)
+	release, reason, ok := s.clientLimiter.Acquire(ip, time.Now())
+	if !ok {
+		s.emitEvent(EventLimitReject, tag, conn.RemoteAddr().String(), "", reason)
+		if config.Settings.LogLevel == "DEBUG" {
+			log.Printf("[%s] Rejected client %s: %s", tag, conn.RemoteAddr().String(), reason)
+		}
+		_ = conn.Close()
+		return nil, false
+	}
+	return &limitedConn{Conn: conn, release: release}, true
+}
+
+// clientIP extracts the source IP of a connection.
+func clientIP(conn net.Conn) string {
+	if tcpAddr, ok := conn.RemoteAddr().(*net.TCPAddr); ok {
+		return tcpAddr.IP.String()
+	}
+	host, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+	if err != nil {
+		return conn.RemoteAddr().String()
+	}
+	return host
+}
@@ -0,0 +1,130 @@
+package core
+
+import (
+	"bastion/config"
+	"bastion/models"
+	"testing"
+	"time"
+)
+
+func withClientLimitDefaults(t *testing.T, maxConns int, rate float64, burst int) {
+	t.Helper()
+	prevMax, prevRate, prevBurst := config.Settings.MaxConnsPerIP, config.Settings.ConnRatePerIP, config.Settings.ConnBurstPerIP
+	t.Cleanup(func() {
+		config.Settings.MaxConnsPerIP = prevMax
+		config.Settings.ConnRatePerIP = prevRate
+		config.Settings.ConnBurstPerIP = prevBurst
+	})
+	config.Settings.MaxConnsPerIP = maxConns
+	config.Settings.ConnRatePerIP = rate
+	config.Settings.ConnBurstPerIP = burst
+}
+
+func TestNewClientLimiter_Resolution(t *testing.T) {
+	withClientLimitDefaults(t, 0, 0, 10)
+	if l := NewClientLimiter(&models.Mapping{}); l != nil {
+		t.Fatalf("expected no limiter without limits, got %+v", l)
+	}
+
+	withClientLimitDefaults(t, 5, 0, 10)
+	if l := NewClientLimiter(&models.Mapping{}); l == nil || l.maxConns != 5 {
+		t.Fatalf("expected global max of 5, got %+v", l)
+	}
+	if l := NewClientLimiter(&models.Mapping{MaxConnsPerIP: 2}); l == nil || l.maxConns != 2 {
+		t.Fatalf("expected mapping override of 2, got %+v", l)
+	}
+	if l := NewClientLimiter(&models.Mapping{MaxConnsPerIP: -1}); l != nil {
+		t.Fatalf("expected -1 to disable the global limit, got %+v", l)
+	}
+}
+
+func TestClientLimiter_ConcurrentCap(t *testing.T) {
+	withClientLimitDefaults(t, 0, 0, 0)
+	l := NewClientLimiter(&models.Mapping{MaxConnsPerIP: 2})
+	now := time.Now()
+
+	r1, _, ok := l.Acquire("10.0.0.1", now)
+	if !ok {
+		t.Fatalf("first connection rejected")
+	}
+	if _, _, ok := l.Acquire("10.0.0.1", now); !ok {
+		t.Fatalf("second connection rejected")
+	}
+	if _, reason, ok := l.Acquire("10.0.0.1", now); ok || reason == "" {
+		t.Fatalf("expected third connection to be rejected")
+	}
+	if _, _, ok := l.Acquire("10.0.0.2", now); !ok {
+		t.Fatalf("other IP should not be affected")
+	}
+
+	r1()
+	r1() // release is idempotent
+	if _, _, ok := l.Acquire("10.0.0.1", now); !ok {
+		t.Fatalf("expected slot to be released")
+	}
+	if _, _, ok := l.Acquire("10.0.0.1", now); ok {
+		t.Fatalf("double release must not free two slots")
+	}
+}
+
+func TestClientLimiter_RateLimit(t *testing.T) {
+	withClientLimitDefaults(t, 0, 0, 0)
+	l := NewClientLimiter(&models.Mapping{ConnRatePerIP: 1, ConnBurstPerIP: 2})
+	now := time.Now()
+
+	for i := 0; i < 2; i++ {
+		release, _, ok := l.Acquire("10.0.0.1", now)
+		if !ok {
+			t.Fatalf("burst connection %d rejected", i)
+		}
+		release()
+	}
+	if _, _, ok := l.Acquire("10.0.0.1", now); ok {
+		t.Fatalf("expected rate limit after burst")
+	}
+	if _, _, ok := l.Acquire("10.0.0.1", now.Add(time.Second)); !ok {
+		t.Fatalf("expected a token after refill")
+	}
+}
+
+func TestRateLimiter_Allow(t *testing.T) {
+	if NewRateLimiter(0, 5) != nil {
+		t.Fatalf("expected nil limiter for rate 0")
+	}
+	l := NewRateLimiter(0.5, 2)
+	now := time.Now()
+
+	for i := 0; i < 2; i++ {
+		if _, ok := l.Allow("10.0.0.1", now); !ok {
+			t.Fatalf("burst request %d rejected", i)
+		}
+	}
+	wait, ok := l.Allow("10.0.0.1", now)
+	if ok || wait != 2*time.Second {
+		t.Fatalf("after burst: ok=%v wait=%v, want rejected with 2s", ok, wait)
+	}
+	if _, ok := l.Allow("10.0.0.2", now); !ok {
+		t.Fatalf("other IPs have their own bucket")
+	}
+	if _, ok := l.Allow("10.0.0.1", now.Add(2*time.Second)); !ok {
+		t.Fatalf("expected a token after refill")
+	}
+}
+
+func TestClientLimiter_SweepsIdleEntries(t *testing.T) {
+	withClientLimitDefaults(t, 0, 0, 0)
+	l := NewClientLimiter(&models.Mapping{MaxConnsPerIP: 1})
+	now := time.Now()
+
+	release, _, _ := l.Acquire("10.0.0.1", now)
+	release()
+	l.Acquire("10.0.0.2", now) // still active
+
+	l.Acquire("10.0.0.3", now.Add(2*clientLimiterSweepInterval))
+	if _, ok := l.clients["10.0.0.1"]; ok {
+		t.Fatalf("expected idle entry to be swept")
+	}
+	if _, ok := l.clients["10.0.0.2"]; !ok {
+		t.Fatalf("active entry must be kept")
+	}
+}
@@ -0,0 +1,114 @@
+package core
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// minHoldReconnectInterval bounds how often a held chain is reconnected.
+const minHoldReconnectInterval = time.Second
+
+// chainHold holds the clients of a session whose bastion chain is down while one background loop
+// re-establishes the chain, so a jump host restart delays clients instead of failing them and the
+// held clients do not each hammer the jump host.
+type chainHold struct {
+	mu   sync.Mutex
+	up   chan struct{} // closed when the running reconnect loop succeeds; nil when none runs
+	held int
+}
+
+// wait holds a client until connect succeeds, timeout passes or stop is closed, and reports whether
+// the chain is back. connect is retried every interval by a loop shared by all held clients; the
+// loop ends once it succeeds or no client is held anymore.
+func (h *chainHold) wait(timeout, interval time.Duration, stop <-chan struct{}, connect func() error) bool {
+	if interval < minHoldReconnectInterval {
+		interval = minHoldReconnectInterval
+	}
+
+	h.mu.Lock()
+	h.held++
+	up := h.up
+	if up == nil {
+		up = make(chan struct{})
+		h.up = up
+		go h.reconnect(up, interval, stop, connect)
+	}
+	h.mu.Unlock()
+	defer func() {
+		h.mu.Lock()
+		h.held--
+		h.mu.Unlock()
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-up:
+		return true
+	case <-timer.C:
+		return false
+	case <-stop:
+		return false
+	}
+}
+
+func (h *chainHold) reconnect(up chan struct{}, interval time.Duration, stop <-chan struct{}, connect func() error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			h.end(up, false)
+			return
+		case <-ticker.C:
+		}
+		if err := connect(); err == nil {
+			h.end(up, true)
+			return
+		}
+		h.mu.Lock()
+		idle := h.held == 0
+		if idle {
+			h.up = nil
+		}
+		h.mu.Unlock()
+		if idle {
+			return
+		}
+	}
+}
+
+func (h *chainHold) end(up chan struct{}, ok bool) {
+	h.mu.Lock()
+	h.up = nil
+	h.mu.Unlock()
+	if ok {
+		close(up)
+	}
+}
+
+// Held returns the number of clients currently waiting for the chain.
+func (h *chainHold) Held() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.held
+}
+
+// holdForChain waits up to the dial policy's hold for the session's bastion chain to be
+// re-established in the background, and reports whether it was.
+func (s *BaseSession) holdForChain(clientAddr, bastionChain string) bool {
+	policy := s.dialPolicy
+	log.Printf("[Hold] Bastion chain [%s] is down, holding client %s up to %s", bastionChain, clientAddr, policy.Hold)
+	start := time.Now()
+	ok := s.hold.wait(policy.Hold, policy.RetryDelay, s.stopChan, func() error {
+		_, err := Pool.GetConnection(s.dialContext(), s.Bastions)
+		return err
+	})
+	if ok {
+		log.Printf("[Hold] Bastion chain [%s] is back, client %s proceeds after %s", bastionChain, clientAddr, time.Since(start).Round(time.Millisecond))
+	} else {
+		log.Printf("[Hold] Bastion chain [%s] still down, giving up on client %s after %s", bastionChain, clientAddr, time.Since(start).Round(time.Millisecond))
+	}
+	return ok
+}
@@ -0,0 +1,71 @@
+package core
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestChainHold_SharesOneReconnectLoop(t *testing.T) {
+	var h chainHold
+	var attempts int32
+	connect := func() error {
+		if atomic.AddInt32(&attempts, 1) < 2 {
+			return errors.New("chain down")
+		}
+		return nil
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	var wg sync.WaitGroup
+	results := make([]bool, 5)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = h.wait(10*time.Second, 0, stop, connect)
+		}(i)
+	}
+	wg.Wait()
+
+	for i, ok := range results {
+		if !ok {
+			t.Fatalf("client %d was not released", i)
+		}
+	}
+	if n := atomic.LoadInt32(&attempts); n != 2 {
+		t.Fatalf("reconnect attempts = %d, want 2 shared by all clients", n)
+	}
+	if h.Held() != 0 {
+		t.Fatalf("held = %d after release", h.Held())
+	}
+}
+
+func TestChainHold_TimesOutAndStops(t *testing.T) {
+	var h chainHold
+	down := func() error { return errors.New("chain down") }
+
+	stop := make(chan struct{})
+	start := time.Now()
+	if h.wait(50*time.Millisecond, 0, stop, down) {
+		t.Fatalf("expected the hold to time out")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("hold took %s", elapsed)
+	}
+
+	released := make(chan bool, 1)
+	go func() { released <- h.wait(time.Minute, 0, stop, down) }()
+	close(stop)
+	select {
+	case ok := <-released:
+		if ok {
+			t.Fatalf("expected a stopped session to release the client unsuccessfully")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("client still held after the session stopped")
+	}
+}
@@ -0,0 +1,148 @@
+package core
+
+import (
+	"bastion/config"
+	"bastion/models"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// Dial backoff strategies
+const (
+	DialBackoffFixed       = "fixed"
+	DialBackoffExponential = "exponential"
+)
+
+// maxDialRetryDelay caps exponential backoff between dial attempts.
+const maxDialRetryDelay = 30 * time.Second
+
+// DialPolicy controls how a session dials its remote targets.
+type DialPolicy struct {
+	Timeout     time.Duration // per-attempt timeout; 0 = no timeout
+	MaxAttempts int           // total attempts through the bastion chain (direct dials are not retried)
+	RetryDelay  time.Duration // delay before the second attempt
+	Backoff     string        // DialBackoffFixed or DialBackoffExponential
+	Hold        time.Duration // how long a client waits for a bastion chain that is down; 0 = fail right away
+	// HappyEyeballs resolves remote host names locally and races their addresses through the chain.
+	HappyEyeballs bool
+}
+
+// NewDialPolicy resolves the mapping's dial overrides, falling back to the global settings for unset (zero) values.
+func NewDialPolicy(mapping *models.Mapping) DialPolicy {
+	p := DialPolicy{
+		Timeout:     time.Duration(config.Settings.DialTimeoutSeconds) * time.Second,
+		MaxAttempts: config.Settings.DialRetries,
+		RetryDelay:  time.Duration(config.Settings.DialRetryDelayMS) * time.Millisecond,
+		Backoff:     strings.ToLower(strings.TrimSpace(config.Settings.DialBackoff)),
+		Hold:        time.Duration(config.Settings.DialHoldSeconds) * time.Second,
+
+		HappyEyeballs: config.Settings.DialHappyEyeballs,
+	}
+	if mapping != nil {
+		if mapping.DialTimeoutSeconds > 0 {
+			p.Timeout = time.Duration(mapping.DialTimeoutSeconds) * time.Second
+		}
+		if mapping.DialRetries > 0 {
+			p.MaxAttempts = mapping.DialRetries
+		}
+		if mapping.DialRetryDelayMS > 0 {
+			p.RetryDelay = time.Duration(mapping.DialRetryDelayMS) * time.Millisecond
+		}
+		if mapping.DialBackoff != "" {
+			p.Backoff = mapping.DialBackoff
+		}
+		if mapping.DialHappyEyeballs {
+			p.HappyEyeballs = true
+		}
+		if mapping.DialHoldSeconds > 0 {
+			p.Hold = time.Duration(mapping.DialHoldSeconds) * time.Second
+		} else if mapping.DialHoldSeconds < 0 {
+			p.Hold = 0
+		}
+	}
+	if p.MaxAttempts < 1 {
+		p.MaxAttempts = 1
+	}
+	if p.Backoff != DialBackoffExponential {
+		p.Backoff = DialBackoffFixed
+	}
+	if p.Hold < 0 {
+		p.Hold = 0
+	}
+	return p
+}
+
+// Delay returns how long to wait before the given attempt (attempt >= 2).
+func (p DialPolicy) Delay(attempt int) time.Duration {
+	if attempt < 2 || p.RetryDelay <= 0 {
+		return 0
+	}
+	if p.Backoff != DialBackoffExponential {
+		return p.RetryDelay
+	}
+	d := p.RetryDelay
+	for i := 2; i < attempt; i++ {
+		d *= 2
+		if d >= maxDialRetryDelay {
+			return maxDialRetryDelay
+		}
+	}
+	return d
+}
+
+// ValidateDialPolicy checks per-mapping dial overrides.
+func ValidateDialPolicy(timeoutSeconds, retries, retryDelayMS, holdSeconds int, backoff string) error {
+	if timeoutSeconds < 0 {
+		return fmt.Errorf("invalid dial_timeout_seconds: %d", timeoutSeconds)
+	}
+	if retries < 0 {
+		return fmt.Errorf("invalid dial_retries: %d", retries)
+	}
+	if retryDelayMS < 0 {
+		return fmt.Errorf("invalid dial_retry_delay_ms: %d", retryDelayMS)
+	}
+	if holdSeconds < -1 {
+		return fmt.Errorf("invalid dial_hold_seconds: %d (-1 disables holding)", holdSeconds)
+	}
+	switch backoff {
+	case "", DialBackoffFixed, DialBackoffExponential:
+	default:
+		return fmt.Errorf("invalid dial_backoff: %s (expected %s or %s)", backoff, DialBackoffFixed, DialBackoffExponential)
+	}
+	return nil
+}
+
+// dialWithTimeout runs dial and gives up after timeout. A connection that completes after the
+// timeout is closed in the background.
+func dialWithTimeout(timeout time.Duration, dial func() (net.Conn, error)) (net.Conn, error) {
+	if timeout <= 0 {
+		return dial()
+	}
+
+	type result struct {
+		conn net.Conn
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		conn, err := dial()
+		done <- result{conn: conn, err: err}
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case r := <-done:
+		return r.conn, r.err
+	case <-timer.C:
+		go func() {
+			if r := <-done; r.conn != nil {
+				_ = r.conn.Close()
+			}
+		}()
+		return nil, fmt.Errorf("dial timed out after %s", timeout)
+	}
+}
@@ -0,0 +1,111 @@
+package core
+
+import (
+	"bastion/config"
+	"bastion/models"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestNewDialPolicy_FallsBackToGlobals(t *testing.T) {
+	prevTimeout, prevRetries, prevDelay, prevBackoff := config.Settings.DialTimeoutSeconds, config.Settings.DialRetries,
+		config.Settings.DialRetryDelayMS, config.Settings.DialBackoff
+	prevSSHRetries, prevSSHDelay := config.Settings.SSHConnectMaxRetries, config.Settings.SSHConnectRetryDelaySeconds
+	t.Cleanup(func() {
+		config.Settings.DialTimeoutSeconds = prevTimeout
+		config.Settings.DialRetries = prevRetries
+		config.Settings.DialRetryDelayMS = prevDelay
+		config.Settings.DialBackoff = prevBackoff
+		config.Settings.SSHConnectMaxRetries, config.Settings.SSHConnectRetryDelaySeconds = prevSSHRetries, prevSSHDelay
+	})
+	config.Settings.DialTimeoutSeconds = 10
+	config.Settings.DialRetries = 3
+	config.Settings.DialRetryDelayMS = 1000
+	config.Settings.DialBackoff = "fixed"
+	// The SSH connect retry settings are not the dial defaults.
+	config.Settings.SSHConnectMaxRetries = 7
+	config.Settings.SSHConnectRetryDelaySeconds = 5
+
+	p := NewDialPolicy(&models.Mapping{})
+	if p.Timeout != 10*time.Second || p.MaxAttempts != 3 || p.RetryDelay != time.Second || p.Backoff != DialBackoffFixed {
+		t.Fatalf("unexpected default policy: %+v", p)
+	}
+
+	p = NewDialPolicy(&models.Mapping{DialTimeoutSeconds: 1, DialRetries: 6, DialRetryDelayMS: 100, DialBackoff: DialBackoffExponential})
+	if p.Timeout != time.Second || p.MaxAttempts != 6 || p.RetryDelay != 100*time.Millisecond || p.Backoff != DialBackoffExponential {
+		t.Fatalf("unexpected overridden policy: %+v", p)
+	}
+
+	prevHold := config.Settings.DialHoldSeconds
+	t.Cleanup(func() { config.Settings.DialHoldSeconds = prevHold })
+	config.Settings.DialHoldSeconds = 30
+	if p = NewDialPolicy(&models.Mapping{}); p.Hold != 30*time.Second {
+		t.Fatalf("hold = %s, want the global 30s", p.Hold)
+	}
+	if p = NewDialPolicy(&models.Mapping{DialHoldSeconds: -1}); p.Hold != 0 {
+		t.Fatalf("hold = %s, want disabled", p.Hold)
+	}
+}
+
+func TestDialPolicy_Delay(t *testing.T) {
+	fixed := DialPolicy{RetryDelay: time.Second, Backoff: DialBackoffFixed}
+	if d := fixed.Delay(4); d != time.Second {
+		t.Fatalf("fixed delay = %s", d)
+	}
+
+	exp := DialPolicy{RetryDelay: time.Second, Backoff: DialBackoffExponential}
+	for attempt, want := range map[int]time.Duration{1: 0, 2: time.Second, 3: 2 * time.Second, 4: 4 * time.Second, 20: maxDialRetryDelay} {
+		if d := exp.Delay(attempt); d != want {
+			t.Fatalf("exponential delay for attempt %d = %s, want %s", attempt, d, want)
+		}
+	}
+}
+
+func TestValidateDialPolicy(t *testing.T) {
+	if err := ValidateDialPolicy(0, 0, 0, 0, ""); err != nil {
+		t.Fatalf("zero values should be valid: %v", err)
+	}
+	if err := ValidateDialPolicy(-1, 0, 0, 0, ""); err == nil {
+		t.Fatalf("expected negative timeout to be rejected")
+	}
+	if err := ValidateDialPolicy(0, 0, 0, 0, "linear"); err == nil {
+		t.Fatalf("expected unknown backoff to be rejected")
+	}
+	if err := ValidateDialPolicy(0, 0, 0, -1, ""); err != nil {
+		t.Fatalf("dial_hold_seconds -1 should disable holding: %v", err)
+	}
+	if err := ValidateDialPolicy(0, 0, 0, -2, ""); err == nil {
+		t.Fatalf("expected dial_hold_seconds below -1 to be rejected")
+	}
+}
+
+func TestDialWithTimeout(t *testing.T) {
+	release := make(chan struct{})
+	closed := make(chan struct{})
+	_, err := dialWithTimeout(20*time.Millisecond, func() (net.Conn, error) {
+		<-release
+		c1, c2 := net.Pipe()
+		go func() {
+			buf := make([]byte, 1)
+			_, _ = c2.Read(buf)
+			close(closed)
+		}()
+		return c1, nil
+	})
+	if err == nil {
+		t.Fatalf("expected timeout error")
+	}
+	close(release)
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatalf("late connection was not closed")
+	}
+
+	wantErr := errors.New("boom")
+	if _, err := dialWithTimeout(time.Second, func() (net.Conn, error) { return nil, wantErr }); !errors.Is(err, wantErr) {
+		t.Fatalf("expected dial error to pass through, got %v", err)
+	}
+}
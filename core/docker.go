@@ -0,0 +1,341 @@
+package core
+
+import (
+	"bastion/models"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Docker discovery sources
+const (
+	DockerSourceCLI = "cli" // `docker ps` run on the last bastion
+	DockerSourceAPI = "api" // the Docker Engine API dialed through the chain
+)
+
+// DefaultDockerHost is the Docker Engine API address used by DockerSourceAPI when none is given.
+const DefaultDockerHost = "unix:///var/run/docker.sock"
+
+const dockerTimeout = 30 * time.Second
+
+// DockerOptions configures ListDockerContainers.
+type DockerOptions struct {
+	Source string // DockerSourceCLI (default) or DockerSourceAPI
+	// Host is the Docker Engine API address on the last bastion's side, unix:///path or
+	// tcp://host:port; DockerSourceAPI only.
+	Host string
+	All  bool // include stopped containers
+}
+
+// DockerPort is a port of a container and where it is published on the Docker host.
+type DockerPort struct {
+	ContainerPort int    `json:"container_port"`
+	Protocol      string `json:"protocol"`
+	HostIP        string `json:"host_ip,omitempty"`   // empty when published on every address
+	HostPort      int    `json:"host_port,omitempty"` // 0 when not published
+	// Mapping is a mapping to the published port through the chain, ready to be created.
+	Mapping *models.MappingCreate `json:"mapping,omitempty"`
+}
+
+// DockerContainer is a container on the Docker host.
+type DockerContainer struct {
+	ID     string       `json:"id"`
+	Name   string       `json:"name"`
+	Image  string       `json:"image"`
+	State  string       `json:"state"`  // e.g. running, exited
+	Status string       `json:"status"` // e.g. Up 2 hours
+	Ports  []DockerPort `json:"ports"`
+}
+
+// DockerListing is the outcome of ListDockerContainers.
+type DockerListing struct {
+	Route      string            `json:"route"`
+	Source     string            `json:"source"`
+	Host       string            `json:"host,omitempty"`
+	Hops       []DryRunStep      `json:"hops"`
+	Containers []DockerContainer `json:"containers"`
+}
+
+// ListDockerContainers connects through bastions with fresh SSH clients, like DryRun, and lists the
+// containers of the Docker host: the last bastion itself, through `docker ps` run there or the
+// Docker Engine API at opts.Host dialed through the chain. Pooled chains are not used.
+func ListDockerContainers(bastions []models.Bastion, opts DockerOptions) (*DockerListing, error) {
+	listing := &DockerListing{Route: "bastion chain [" + getBastionChainNames(bastions) + "]", Source: opts.Source, Containers: []DockerContainer{}}
+	if listing.Source == "" {
+		listing.Source = DockerSourceCLI
+	}
+	if listing.Source == DockerSourceAPI {
+		listing.Host = opts.Host
+		if listing.Host == "" {
+			listing.Host = DefaultDockerHost
+		}
+	}
+
+	clients, hops, ok := connectHops(bastions)
+	defer closeClients(clients)
+	listing.Hops = hops
+	if !ok || len(clients) == 0 {
+		for _, hop := range hops {
+			if hop.Status == DryRunFailed {
+				return listing, fmt.Errorf("hop %s: %s", hop.Name, hop.Error)
+			}
+		}
+		return listing, fmt.Errorf("no bastion to connect to")
+	}
+	last := clients[len(clients)-1]
+
+	// A stalled command or request is ended by closing the chain under it.
+	timer := time.AfterFunc(dockerTimeout, func() { closeClients(clients) })
+	defer timer.Stop()
+
+	var containers []DockerContainer
+	var err error
+	if listing.Source == DockerSourceAPI {
+		containers, err = dockerAPIContainers(last, listing.Host, opts.All)
+	} else {
+		containers, err = dockerCLIContainers(last, opts.All)
+	}
+	if err != nil {
+		return listing, err
+	}
+	sort.Slice(containers, func(i, j int) bool { return containers[i].Name < containers[j].Name })
+	listing.Containers = containers
+	return listing, nil
+}
+
+// ParseDockerHost splits a Docker Engine API address into the network and address to dial.
+func ParseDockerHost(host string) (network, addr string, err error) {
+	switch {
+	case strings.HasPrefix(host, "unix://"):
+		network, addr = "unix", strings.TrimPrefix(host, "unix://")
+	case strings.HasPrefix(host, "tcp://"):
+		network, addr = "tcp", strings.TrimPrefix(host, "tcp://")
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			return "", "", fmt.Errorf("invalid docker host %q: %v", host, err)
+		}
+	default:
+		return "", "", fmt.Errorf("invalid docker host %q: use unix:///path or tcp://host:port", host)
+	}
+	if addr == "" {
+		return "", "", fmt.Errorf("invalid docker host %q: the address is empty", host)
+	}
+	return network, addr, nil
+}
+
+// dockerCLIContainers runs `docker ps` on the server of client.
+func dockerCLIContainers(client *ssh.Client, all bool) ([]DockerContainer, error) {
+	session, err := client.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+
+	cmd := "docker ps --no-trunc --format '{{json .}}'"
+	if all {
+		cmd += " --all"
+	}
+	var stderr bytes.Buffer
+	session.Stderr = &stderr
+	out, err := session.Output(cmd)
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("docker ps: %s", msg)
+		}
+		return nil, fmt.Errorf("docker ps: %w", err)
+	}
+	return parseDockerPS(out)
+}
+
+// dockerPSLine is a line of `docker ps --format '{{json .}}'`.
+type dockerPSLine struct {
+	ID     string `json:"ID"`
+	Names  string `json:"Names"`
+	Image  string `json:"Image"`
+	State  string `json:"State"`
+	Status string `json:"Status"`
+	Ports  string `json:"Ports"`
+}
+
+func parseDockerPS(out []byte) ([]DockerContainer, error) {
+	containers := []DockerContainer{}
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var ps dockerPSLine
+		if err := json.Unmarshal(line, &ps); err != nil {
+			return nil, fmt.Errorf("unexpected docker ps output %q: %v", line, err)
+		}
+		containers = append(containers, DockerContainer{
+			ID:     ps.ID,
+			Name:   strings.Split(ps.Names, ",")[0],
+			Image:  ps.Image,
+			State:  ps.State,
+			Status: ps.Status,
+			Ports:  parseDockerPSPorts(ps.Ports),
+		})
+	}
+	return containers, scanner.Err()
+}
+
+// parseDockerPSPorts parses the Ports column of docker ps, e.g.
+// "0.0.0.0:8080->80/tcp, :::8080->80/tcp, 443/tcp". Port ranges are expanded.
+func parseDockerPSPorts(s string) []DockerPort {
+	var ports []DockerPort
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		published, private, found := strings.Cut(entry, "->")
+		if !found {
+			published, private = "", entry
+		}
+		portRange, proto, _ := strings.Cut(private, "/")
+		first, last, ok := parsePortRange(portRange)
+		if !ok {
+			continue
+		}
+
+		hostIP, hostFirst := "", 0
+		if published != "" {
+			i := strings.LastIndex(published, ":")
+			if i < 0 {
+				continue
+			}
+			hostIP = strings.Trim(published[:i], "[]")
+			hostLast := 0
+			if hostFirst, hostLast, ok = parsePortRange(published[i+1:]); !ok || hostLast-hostFirst != last-first {
+				continue
+			}
+		}
+		for p := first; p <= last; p++ {
+			port := DockerPort{ContainerPort: p, Protocol: proto, HostIP: hostIP}
+			if published != "" {
+				port.HostPort = hostFirst + p - first
+			}
+			ports = append(ports, port)
+		}
+	}
+	return normalizeDockerPorts(ports)
+}
+
+func parsePortRange(s string) (first, last int, ok bool) {
+	from, to, isRange := strings.Cut(s, "-")
+	first, err := strconv.Atoi(from)
+	if err != nil {
+		return 0, 0, false
+	}
+	last = first
+	if isRange {
+		if last, err = strconv.Atoi(to); err != nil || last < first {
+			return 0, 0, false
+		}
+	}
+	return first, last, true
+}
+
+// dockerAPIContainer is an item of the Docker Engine API's GET /containers/json.
+type dockerAPIContainer struct {
+	ID     string   `json:"Id"`
+	Names  []string `json:"Names"`
+	Image  string   `json:"Image"`
+	State  string   `json:"State"`
+	Status string   `json:"Status"`
+	Ports  []struct {
+		IP          string `json:"IP"`
+		PrivatePort int    `json:"PrivatePort"`
+		PublicPort  int    `json:"PublicPort"`
+		Type        string `json:"Type"`
+	} `json:"Ports"`
+}
+
+// dockerAPIContainers lists the containers of the Docker Engine API at host, dialed through client.
+func dockerAPIContainers(client *ssh.Client, host string, all bool) ([]DockerContainer, error) {
+	network, addr, err := ParseDockerHost(host)
+	if err != nil {
+		return nil, err
+	}
+	httpClient := &http.Client{
+		Timeout: dockerTimeout,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return client.Dial(network, addr)
+			},
+			DisableKeepAlives: true,
+		},
+	}
+	url := "http://docker/containers/json"
+	if all {
+		url += "?all=1"
+	}
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("docker API at %s: %w", host, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("docker API at %s: %s", host, resp.Status)
+	}
+	var items []dockerAPIContainer
+	if err := json.NewDecoder(resp.Body).Decode(&items); err != nil {
+		return nil, fmt.Errorf("docker API at %s: %w", host, err)
+	}
+
+	containers := make([]DockerContainer, 0, len(items))
+	for _, item := range items {
+		container := DockerContainer{ID: item.ID, Image: item.Image, State: item.State, Status: item.Status}
+		if len(item.Names) > 0 {
+			container.Name = strings.TrimPrefix(item.Names[0], "/")
+		}
+		for _, p := range item.Ports {
+			container.Ports = append(container.Ports, DockerPort{ContainerPort: p.PrivatePort, Protocol: p.Type, HostIP: p.IP, HostPort: p.PublicPort})
+		}
+		container.Ports = normalizeDockerPorts(container.Ports)
+		containers = append(containers, container)
+	}
+	return containers, nil
+}
+
+// normalizeDockerPorts reports a port published on every IPv4 and every IPv6 address once, with
+// an empty HostIP, and sorts the ports.
+func normalizeDockerPorts(ports []DockerPort) []DockerPort {
+	seen := make(map[DockerPort]bool, len(ports))
+	normalized := make([]DockerPort, 0, len(ports))
+	for _, p := range ports {
+		if p.HostIP == "0.0.0.0" || p.HostIP == "::" {
+			p.HostIP = ""
+		}
+		if !seen[p] {
+			seen[p] = true
+			normalized = append(normalized, p)
+		}
+	}
+	sort.Slice(normalized, func(i, j int) bool {
+		a, b := normalized[i], normalized[j]
+		if a.ContainerPort != b.ContainerPort {
+			return a.ContainerPort < b.ContainerPort
+		}
+		if a.Protocol != b.Protocol {
+			return a.Protocol < b.Protocol
+		}
+		if a.HostPort != b.HostPort {
+			return a.HostPort < b.HostPort
+		}
+		return a.HostIP < b.HostIP
+	})
+	return normalized
+}
@@ -0,0 +1,63 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseDockerPSPorts(t *testing.T) {
+	got := parseDockerPSPorts("0.0.0.0:8080->80/tcp, :::8080->80/tcp, 127.0.0.1:5432->5432/tcp, 443/tcp, [::]:9000-9001->7000-7001/udp")
+	want := []DockerPort{
+		{ContainerPort: 80, Protocol: "tcp", HostPort: 8080},
+		{ContainerPort: 443, Protocol: "tcp"},
+		{ContainerPort: 5432, Protocol: "tcp", HostIP: "127.0.0.1", HostPort: 5432},
+		{ContainerPort: 7000, Protocol: "udp", HostPort: 9000},
+		{ContainerPort: 7001, Protocol: "udp", HostPort: 9001},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("ports = %+v, want %+v", got, want)
+	}
+}
+
+func TestParseDockerPS(t *testing.T) {
+	out := []byte(`{"ID":"abc","Image":"postgres:16","Names":"db","Ports":"127.0.0.1:5432->5432/tcp","State":"running","Status":"Up 2 hours"}
+
+{"ID":"def","Image":"redis","Names":"cache,app/cache","Ports":"","State":"exited","Status":"Exited (0) 1 hour ago"}
+`)
+	containers, err := parseDockerPS(out)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(containers) != 2 {
+		t.Fatalf("got %d containers, want 2", len(containers))
+	}
+	if c := containers[0]; c.Name != "db" || c.Image != "postgres:16" || c.State != "running" || len(c.Ports) != 1 || c.Ports[0].HostPort != 5432 {
+		t.Fatalf("first container = %+v", c)
+	}
+	if c := containers[1]; c.Name != "cache" || c.State != "exited" || len(c.Ports) != 0 {
+		t.Fatalf("second container = %+v", c)
+	}
+
+	if _, err := parseDockerPS([]byte("permission denied\n")); err == nil {
+		t.Fatal("expected an error for output that is not JSON")
+	}
+}
+
+func TestParseDockerHost(t *testing.T) {
+	cases := []struct {
+		host, network, addr string
+		ok                  bool
+	}{
+		{"unix:///var/run/docker.sock", "unix", "/var/run/docker.sock", true},
+		{"tcp://10.0.0.5:2375", "tcp", "10.0.0.5:2375", true},
+		{"tcp://10.0.0.5", "", "", false},
+		{"unix://", "", "", false},
+		{"/var/run/docker.sock", "", "", false},
+	}
+	for _, tc := range cases {
+		network, addr, err := ParseDockerHost(tc.host)
+		if (err == nil) != tc.ok || network != tc.network || addr != tc.addr {
+			t.Errorf("ParseDockerHost(%q) = %q, %q, %v", tc.host, network, addr, err)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package core
+
+import (
+	"bastion/models"
+	"context"
+	"net"
+	"strconv"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Dry-run step statuses
+const (
+	DryRunOK      = "ok"
+	DryRunFailed  = "failed"
+	DryRunSkipped = "skipped"
+)
+
+// dryRunTargetTimeout bounds the target dial when the mapping has no dial timeout.
+const dryRunTargetTimeout = 10 * time.Second
+
+// DryRunStep is the outcome of one connection attempt of a dry run.
+type DryRunStep struct {
+	Name       string `json:"name,omitempty"`
+	Addr       string `json:"addr"`
+	Status     string `json:"status"` // ok, failed, skipped
+	DurationMS int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+}
+
+// DryRunReport describes whether a mapping could start: each SSH hop in chain order and, when
+// requested, the target dialed through the chain (and the upstream proxy, if configured).
+type DryRunReport struct {
+	MappingID string       `json:"mapping_id"`
+	OK        bool         `json:"ok"`
+	Route     string       `json:"route"`
+	Error     string       `json:"error,omitempty"` // chain resolution failure; no hop was attempted
+	Hops      []DryRunStep `json:"hops"`
+	Target    *DryRunStep  `json:"target,omitempty"`
+}
+
+// DryRun connects through the bastion chain hop by hop with fresh SSH clients (the pool is neither
+// used nor modified) and, when target is non-empty, dials it through the chain. No listener is
+// bound and no session is registered; every connection is closed before returning.
+func DryRun(mapping *models.Mapping, bastions []models.Bastion, target string) *DryRunReport {
+	s := newBaseSession(mapping, bastions)
+	report := &DryRunReport{
+		MappingID: mapping.Key(),
+		Route:     s.routeDescription(),
+	}
+
+	clients, hops, ok := connectHops(bastions)
+	defer closeClients(clients)
+	report.Hops, report.OK = hops, ok
+	var last *ssh.Client
+	if len(clients) > 0 {
+		last = clients[len(clients)-1]
+	}
+
+	if target == "" {
+		return report
+	}
+	step := DryRunStep{Addr: target}
+	if !report.OK {
+		step.Status = DryRunSkipped
+		report.Target = &step
+		return report
+	}
+	if s.upstreamErr != nil {
+		step.Status, step.Error = DryRunFailed, s.upstreamErr.Error()
+		report.OK, report.Target = false, &step
+		return report
+	}
+
+	timeout := s.dialPolicy.Timeout
+	if timeout <= 0 {
+		timeout = dryRunTargetTimeout
+	}
+	var forward dialFunc
+	if last == nil {
+		forward = func(network, addr string) (net.Conn, error) {
+			return net.DialTimeout(network, addr, timeout)
+		}
+	} else {
+		forward = func(network, addr string) (net.Conn, error) {
+			return dialWithTimeout(timeout, func() (net.Conn, error) { return last.Dial(network, addr) })
+		}
+	}
+
+	start := time.Now()
+	var conn net.Conn
+	var err error
+	if s.upstreamProxy == nil {
+		conn, err = forward("tcp", target)
+	} else {
+		conn, err = dialViaUpstreamProxy(forward, s.upstreamProxy, target)
+	}
+	step.DurationMS = time.Since(start).Milliseconds()
+	if err != nil {
+		step.Status = DryRunFailed
+		step.Error = err.Error()
+		report.OK = false
+	} else {
+		step.Status = DryRunOK
+		_ = conn.Close()
+	}
+	report.Target = &step
+	return report
+}
+
+// connectHops connects through bastions hop by hop with fresh SSH clients, reporting each hop;
+// the hops after a failed one are skipped. ok tells whether every hop connected; clients holds the
+// connected clients in chain order and must be closed by the caller (see closeClients).
+func connectHops(bastions []models.Bastion) (clients []*ssh.Client, hops []DryRunStep, ok bool) {
+	ok = true
+	hops = make([]DryRunStep, 0, len(bastions))
+	var last *ssh.Client
+	for _, b := range bastions {
+		step := DryRunStep{Name: b.Name, Addr: net.JoinHostPort(b.Host, strconv.Itoa(b.Port))}
+		if !ok {
+			step.Status = DryRunSkipped
+			hops = append(hops, step)
+			continue
+		}
+
+		start := time.Now()
+		sshConfig, err := bastionClientConfig(b)
+		if err == nil {
+			var prev sshClient
+			if last != nil {
+				prev = last
+			}
+			last, err = dialSSHHop(context.Background(), prev, step.Addr, sshConfig)
+		}
+		step.DurationMS = time.Since(start).Milliseconds()
+		if err != nil {
+			step.Status = DryRunFailed
+			step.Error = err.Error()
+			ok = false
+		} else {
+			step.Status = DryRunOK
+			clients = append(clients, last)
+		}
+		hops = append(hops, step)
+	}
+	return clients, hops, ok
+}
+
+// closeClients closes the clients of a chain, last hop first.
+func closeClients(clients []*ssh.Client) {
+	for i := len(clients) - 1; i >= 0; i-- {
+		_ = clients[i].Close()
+	}
+}
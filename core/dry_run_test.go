@@ -0,0 +1,60 @@
+package core
+
+import (
+	"net"
+	"testing"
+
+	"bastion/models"
+)
+
+func TestDryRun_DirectTarget(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.Close()
+		}
+	}()
+
+	mapping := &models.Mapping{ID: "direct", Type: "tcp"}
+	report := DryRun(mapping, nil, ln.Addr().String())
+	if !report.OK || report.Target == nil || report.Target.Status != DryRunOK || len(report.Hops) != 0 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+
+	closed := ln.Addr().String()
+	_ = ln.Close()
+	report = DryRun(mapping, nil, closed)
+	if report.OK || report.Target.Status != DryRunFailed || report.Target.Error == "" {
+		t.Fatalf("expected failed target: %+v", report.Target)
+	}
+}
+
+func TestDryRun_StopsAtFirstFailedHop(t *testing.T) {
+	mapping := &models.Mapping{ID: "db", Type: "tcp"}
+	bastions := []models.Bastion{
+		{Name: "jump", Host: "127.0.0.1", Port: 22, Username: "u"}, // no auth method
+		{Name: "inner", Host: "10.0.0.2", Port: 22, Username: "u", Password: "p"},
+	}
+
+	report := DryRun(mapping, bastions, "10.0.0.3:5432")
+	if report.OK || len(report.Hops) != 2 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if report.Hops[0].Status != DryRunFailed || report.Hops[0].Error == "" {
+		t.Fatalf("expected first hop to fail: %+v", report.Hops[0])
+	}
+	if report.Hops[1].Status != DryRunSkipped || report.Target.Status != DryRunSkipped {
+		t.Fatalf("expected later steps to be skipped: %+v %+v", report.Hops[1], report.Target)
+	}
+	if report.Route != "bastion chain [jump->inner]" {
+		t.Fatalf("unexpected route: %q", report.Route)
+	}
+}
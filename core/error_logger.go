@@ -1,21 +1,37 @@
 package core
 
 import (
+	"bastion/config"
 	"bastion/models"
 	"encoding/json"
 	"fmt"
+	"log"
 	"runtime"
+	"strings"
 	"sync"
 	"time"
 )
 
-// ErrorLogger records error logs (in-memory KV-style store)
+// ErrorLogStore persists error logs. It is implemented by database.ErrorLogStore.
+type ErrorLogStore interface {
+	Insert(entry *models.ErrorLog) error
+	Query(filter models.ErrorLogFilter, page, pageSize int) ([]*models.ErrorLog, int, error)
+	Get(id int) (*models.ErrorLog, error)
+	Clear() error
+	Prune(olderThan time.Time, maxRows int) (int64, error)
+}
+
+// ErrorLogger records error logs. Entries are persisted through the configured store;
+// without a store (or when a write fails) they are kept in a small in-memory ring.
 type ErrorLogger struct {
 	logs      []*models.ErrorLog
 	logsMap   map[int]*models.ErrorLog
 	mu        sync.RWMutex
 	maxLogs   int
 	idCounter int
+
+	store         ErrorLogStore
+	retentionOnce sync.Once
 }
 
 var ErrorLoggerInstance *ErrorLogger
@@ -28,11 +44,21 @@ func init() {
 	}
 }
 
-// LogError records an error log entry
-func (e *ErrorLogger) LogError(level, source, message, detail string, contextData map[string]interface{}) {
+// SetStore enables persistent storage for subsequent error logs.
+func (e *ErrorLogger) SetStore(store ErrorLogStore) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
+	e.store = store
+}
+
+func (e *ErrorLogger) getStore() ErrorLogStore {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.store
+}
 
+// LogError records an error log entry
+func (e *ErrorLogger) LogError(level, source, message, detail string, contextData map[string]interface{}) {
 	// Capture stack trace (skip first 3 frames)
 	stack := e.getStackTrace(3)
 
@@ -44,18 +70,9 @@ func (e *ErrorLogger) LogError(level, source, message, detail string, contextDat
 		}
 	}
 
-	// LRU eviction
-	if len(e.logs) >= e.maxLogs {
-		oldLog := e.logs[0]
-		delete(e.logsMap, oldLog.ID)
-		e.logs = e.logs[1:]
-	}
-
-	e.idCounter++
 	errorLog := &models.ErrorLog{
-		ID:        e.idCounter,
 		Timestamp: time.Now(),
-		Level:     level,
+		Level:     strings.ToUpper(level),
 		Source:    source,
 		Message:   message,
 		Detail:    detail,
@@ -63,28 +80,96 @@ func (e *ErrorLogger) LogError(level, source, message, detail string, contextDat
 		Context:   contextJSON,
 	}
 
+	// Persist outside the lock; SQLite writes can block briefly.
+	if store := e.getStore(); store != nil {
+		err := store.Insert(errorLog)
+		if err == nil {
+			return
+		}
+		log.Printf("Failed to persist error log: %v", err)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	// LRU eviction
+	if len(e.logs) >= e.maxLogs {
+		oldLog := e.logs[0]
+		delete(e.logsMap, oldLog.ID)
+		e.logs = e.logs[1:]
+	}
+
+	e.idCounter++
+	errorLog.ID = e.idCounter
+
 	e.logs = append(e.logs, errorLog)
 	e.logsMap[errorLog.ID] = errorLog
 }
 
 // GetErrorLogs returns recent error logs (up to 100)
 func (e *ErrorLogger) GetErrorLogs() []*models.ErrorLog {
+	logs, _, err := e.QueryErrorLogs(models.ErrorLogFilter{}, 1, e.maxLogs)
+	if err != nil {
+		log.Printf("Failed to query error logs: %v", err)
+		return e.queryMemory(models.ErrorLogFilter{}, 1, e.maxLogs, nil)
+	}
+	return logs
+}
+
+// QueryErrorLogs returns a page of error logs (latest first) matching filter, plus the total match count.
+func (e *ErrorLogger) QueryErrorLogs(filter models.ErrorLogFilter, page, pageSize int) ([]*models.ErrorLog, int, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = 20
+	}
+
+	if store := e.getStore(); store != nil {
+		return store.Query(filter, page, pageSize)
+	}
+
+	var total int
+	logs := e.queryMemory(filter, page, pageSize, &total)
+	return logs, total, nil
+}
+
+// queryMemory pages through the in-memory ring (latest first).
+func (e *ErrorLogger) queryMemory(filter models.ErrorLogFilter, page, pageSize int, total *int) []*models.ErrorLog {
 	e.mu.RLock()
 	defer e.mu.RUnlock()
 
-	total := len(e.logs)
-	result := make([]*models.ErrorLog, total)
-
-	// Return in reverse order (latest first)
-	for i := 0; i < total; i++ {
-		result[i] = e.logs[total-1-i]
+	matched := make([]*models.ErrorLog, 0, len(e.logs))
+	for i := len(e.logs) - 1; i >= 0; i-- {
+		if filter.Matches(e.logs[i]) {
+			matched = append(matched, e.logs[i])
+		}
+	}
+	if total != nil {
+		*total = len(matched)
 	}
 
-	return result
+	start := (page - 1) * pageSize
+	if start >= len(matched) {
+		return []*models.ErrorLog{}
+	}
+	end := start + pageSize
+	if end > len(matched) {
+		end = len(matched)
+	}
+	return matched[start:end]
 }
 
 // GetErrorLogByID returns a single error log by ID
 func (e *ErrorLogger) GetErrorLogByID(id int) *models.ErrorLog {
+	if store := e.getStore(); store != nil {
+		entry, err := store.Get(id)
+		if err != nil {
+			log.Printf("Failed to load error log %d: %v", id, err)
+		}
+		return entry
+	}
+
 	e.mu.RLock()
 	defer e.mu.RUnlock()
 	return e.logsMap[id]
@@ -92,6 +177,12 @@ func (e *ErrorLogger) GetErrorLogByID(id int) *models.ErrorLog {
 
 // ClearErrorLogs removes all error logs
 func (e *ErrorLogger) ClearErrorLogs() {
+	if store := e.getStore(); store != nil {
+		if err := store.Clear(); err != nil {
+			log.Printf("Failed to clear persisted error logs: %v", err)
+		}
+	}
+
 	e.mu.Lock()
 	defer e.mu.Unlock()
 	e.logs = make([]*models.ErrorLog, 0, e.maxLogs)
@@ -99,6 +190,43 @@ func (e *ErrorLogger) ClearErrorLogs() {
 	e.idCounter = 0
 }
 
+// StartRetention prunes persisted error logs hourly according to ERROR_LOG_RETENTION_DAYS
+// and ERROR_LOG_MAX_ROWS. It is safe to call multiple times.
+func (e *ErrorLogger) StartRetention() {
+	e.retentionOnce.Do(func() {
+		go func() {
+			e.pruneOnce(time.Now())
+
+			ticker := time.NewTicker(time.Hour)
+			defer ticker.Stop()
+			for now := range ticker.C {
+				e.pruneOnce(now)
+			}
+		}()
+	})
+}
+
+func (e *ErrorLogger) pruneOnce(now time.Time) {
+	store := e.getStore()
+	if store == nil {
+		return
+	}
+
+	var olderThan time.Time
+	if days := config.Settings.ErrorLogRetentionDays; days > 0 {
+		olderThan = now.Add(-time.Duration(days) * 24 * time.Hour)
+	}
+
+	deleted, err := store.Prune(olderThan, config.Settings.ErrorLogMaxRows)
+	if err != nil {
+		log.Printf("Failed to prune error logs: %v", err)
+		return
+	}
+	if deleted > 0 && config.Settings.LogLevel == "DEBUG" {
+		log.Printf("Pruned %d error logs", deleted)
+	}
+}
+
 // getStackTrace captures stack trace information
 func (e *ErrorLogger) getStackTrace(skip int) string {
 	const maxDepth = 10
@@ -139,6 +267,11 @@ func LogErrorWithContext(source, message, detail string, context map[string]inte
 	ErrorLoggerInstance.LogError("ERROR", source, message, detail, context)
 }
 
+// LogInfo records an informational entry
+func LogInfo(source, message, detail string) {
+	ErrorLoggerInstance.LogError("INFO", source, message, detail, nil)
+}
+
 // LogWarn records a warning
 func LogWarn(source, message, detail string) {
 	ErrorLoggerInstance.LogError("WARN", source, message, detail, nil)
@@ -0,0 +1,442 @@
+package core
+
+import (
+	"bastion/config"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Security event types exported to the event sink
+const (
+	EventConnOpen       = "conn_open"
+	EventConnClose      = "conn_close"
+	EventACLReject      = "acl_reject"       // client IP ACL or target rules
+	EventLimitReject    = "limit_reject"     // connection caps, per-IP limits and quotas
+	EventAuthFailure    = "auth_failure"     // admin/metrics token or bastion SSH authentication
+	EventTLSClientHello = "tls_client_hello" // SNI, ALPN and version of TLS forwarded as an opaque stream
+	EventEventsDropped  = "events_dropped"
+)
+
+// Delivery attempts of one batch, and the delay before the first retry (doubled after each).
+const (
+	eventExportAttempts   = 3
+	eventExportRetryDelay = time.Second
+)
+
+// SecurityEvent is one structured record of connection and access activity, for SIEM ingestion.
+type SecurityEvent struct {
+	Type       string    `json:"type"`
+	Timestamp  time.Time `json:"timestamp"`
+	Hostname   string    `json:"hostname"`
+	MappingID  string    `json:"mapping_id,omitempty"`
+	Protocol   string    `json:"protocol,omitempty"` // TCP, SOCKS5, HTTP, MIXED, API, SSH
+	ClientAddr string    `json:"client_addr,omitempty"`
+	Target     string    `json:"target,omitempty"`
+	Address    string    `json:"address,omitempty"` // conn_open, conn_close: address of the target that served the connection
+	Reason     string    `json:"reason,omitempty"`
+	DurationMS int64     `json:"duration_ms,omitempty"` // conn_close
+	SNI        string    `json:"sni,omitempty"`         // tls_client_hello
+	ALPN       []string  `json:"alpn,omitempty"`        // tls_client_hello
+	TLSVersion string    `json:"tls_version,omitempty"` // tls_client_hello
+	Dropped    uint64    `json:"dropped,omitempty"`     // events_dropped
+}
+
+// EventExportStats exposes event export counters.
+type EventExportStats struct {
+	Enabled  bool   `json:"enabled"`
+	Sink     string `json:"sink,omitempty"` // redacted
+	QueueLen int    `json:"queue_len"`
+	Sent     uint64 `json:"sent"`
+	Dropped  uint64 `json:"dropped"` // queue full
+	Failed   uint64 `json:"failed"`  // given up after retries
+}
+
+// eventSink delivers a batch of events.
+type eventSink interface {
+	Send(batch []SecurityEvent) error
+	Close()
+}
+
+// EventExporter queues security events and ships them in batches to an HTTP endpoint or a syslog
+// server. Emitting never blocks forwarding: when the sink falls behind and the queue fills up, new
+// events are dropped and counted, and the next batch reports how many in an events_dropped event.
+type EventExporter struct {
+	startOnce sync.Once
+	queue     chan SecurityEvent
+	done      chan struct{}
+	stopped   chan struct{}
+	sink      eventSink
+	sinkName  string
+	types     map[string]bool // nil exports every type
+	hostname  string
+
+	sentTotal     uint64
+	droppedTotal  uint64
+	failedTotal   uint64
+	reportedDrops uint64 // owned by the export loop
+}
+
+var EventExport *EventExporter
+
+func init() {
+	EventExport = &EventExporter{}
+}
+
+// Start configures the sink from EVENT_SINK_URL and launches the export loop. It is a no-op without
+// a sink and safe to call multiple times.
+func (e *EventExporter) Start() error {
+	var startErr error
+	e.startOnce.Do(func() {
+		s := config.Settings
+		raw := strings.TrimSpace(s.EventSinkURL)
+		if raw == "" {
+			return
+		}
+		sink, err := newEventSink(raw, s.EventSinkToken)
+		if err != nil {
+			startErr = err
+			return
+		}
+		queueSize := s.EventQueueSize
+		if queueSize <= 0 {
+			queueSize = 10000
+		}
+		e.queue = make(chan SecurityEvent, queueSize)
+		e.done = make(chan struct{})
+		e.stopped = make(chan struct{})
+		e.sinkName = redactURL(raw)
+		e.types = parseEventTypes(s.EventTypes)
+		e.hostname, _ = os.Hostname()
+		e.sink = sink
+		go e.loop()
+		log.Printf("Exporting security events to %s", e.sinkName)
+	})
+	return startErr
+}
+
+// Stop delivers the queued events and closes the sink, waiting at most timeout.
+func (e *EventExporter) Stop(timeout time.Duration) {
+	if e.sink == nil {
+		return
+	}
+	select {
+	case <-e.done:
+	default:
+		close(e.done)
+	}
+	select {
+	case <-e.stopped:
+	case <-time.After(timeout):
+		log.Printf("Timed out delivering the remaining security events")
+	}
+}
+
+// Emit queues ev for export. It does nothing without a sink or when ev's type is filtered out.
+func (e *EventExporter) Emit(ev SecurityEvent) {
+	if e.sink == nil || (e.types != nil && !e.types[ev.Type]) {
+		return
+	}
+	if ev.Timestamp.IsZero() {
+		ev.Timestamp = time.Now()
+	}
+	ev.Hostname = e.hostname
+	select {
+	case e.queue <- ev:
+	default:
+		atomic.AddUint64(&e.droppedTotal, 1)
+	}
+}
+
+// Stats returns event export counters.
+func (e *EventExporter) Stats() EventExportStats {
+	st := EventExportStats{
+		Enabled: e.sink != nil,
+		Sink:    e.sinkName,
+		Sent:    atomic.LoadUint64(&e.sentTotal),
+		Dropped: atomic.LoadUint64(&e.droppedTotal),
+		Failed:  atomic.LoadUint64(&e.failedTotal),
+	}
+	if e.queue != nil {
+		st.QueueLen = len(e.queue)
+	}
+	return st
+}
+
+func (e *EventExporter) loop() {
+	defer close(e.stopped)
+	defer e.sink.Close()
+
+	batchSize := config.Settings.EventBatchSize
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+	interval := time.Duration(config.Settings.EventFlushIntervalMS) * time.Millisecond
+	if interval <= 0 {
+		interval = 2 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	batch := make([]SecurityEvent, 0, batchSize)
+	for {
+		select {
+		case ev := <-e.queue:
+			batch = append(batch, ev)
+			if len(batch) >= batchSize {
+				batch = e.flush(batch, true)
+			}
+		case <-ticker.C:
+			batch = e.flush(batch, true)
+		case <-e.done:
+			for {
+				select {
+				case ev := <-e.queue:
+					batch = append(batch, ev)
+					if len(batch) >= batchSize {
+						batch = e.flush(batch, false)
+					}
+				default:
+					e.flush(batch, false)
+					return
+				}
+			}
+		}
+	}
+}
+
+// flush sends batch, plus an events_dropped event for drops not reported yet, and returns the
+// emptied batch for reuse. Failed batches are retried with backoff unless retry is false.
+func (e *EventExporter) flush(batch []SecurityEvent, retry bool) []SecurityEvent {
+	if dropped := atomic.LoadUint64(&e.droppedTotal); dropped > e.reportedDrops {
+		batch = append(batch, SecurityEvent{
+			Type:      EventEventsDropped,
+			Timestamp: time.Now(),
+			Hostname:  e.hostname,
+			Reason:    "event queue full",
+			Dropped:   dropped - e.reportedDrops,
+		})
+		e.reportedDrops = dropped
+	}
+	if len(batch) == 0 {
+		return batch
+	}
+
+	attempts := 1
+	if retry {
+		attempts = eventExportAttempts
+	}
+	delay := eventExportRetryDelay
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if attempt > 1 {
+			select {
+			case <-time.After(delay):
+			case <-e.done:
+			}
+			delay *= 2
+		}
+		if err = e.sink.Send(batch); err == nil {
+			atomic.AddUint64(&e.sentTotal, uint64(len(batch)))
+			return batch[:0]
+		}
+	}
+	atomic.AddUint64(&e.failedTotal, uint64(len(batch)))
+	log.Printf("Failed to export %d security events to %s: %v", len(batch), e.sinkName, err)
+	return batch[:0]
+}
+
+func parseEventTypes(raw string) map[string]bool {
+	list := splitAlertList(raw)
+	if len(list) == 0 {
+		return nil
+	}
+	types := map[string]bool{EventEventsDropped: true}
+	for _, t := range list {
+		types[strings.ToLower(t)] = true
+	}
+	return types
+}
+
+// newEventSink parses EVENT_SINK_URL: http(s)://... posts JSON arrays, syslog://host:port (UDP) and
+// syslog+tcp://host:port send RFC 5424 messages with the event as JSON.
+func newEventSink(raw, token string) (eventSink, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid EVENT_SINK_URL: %w", err)
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https":
+		return &httpEventSink{url: raw, token: token, client: &http.Client{Timeout: 10 * time.Second}}, nil
+	case "syslog", "syslog+udp":
+		return newSyslogEventSink("udp", u.Host)
+	case "syslog+tcp":
+		return newSyslogEventSink("tcp", u.Host)
+	default:
+		return nil, fmt.Errorf("invalid EVENT_SINK_URL %q: use http(s)://, syslog:// or syslog+tcp://", redactURL(raw))
+	}
+}
+
+// httpEventSink posts each batch as a JSON array.
+type httpEventSink struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+func (h *httpEventSink) Send(batch []SecurityEvent) error {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, h.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if h.token != "" {
+		req.Header.Set("Authorization", "Bearer "+h.token)
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		// url.Error embeds the full URL, which may carry a secret token.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return urlErr.Err
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+func (h *httpEventSink) Close() {}
+
+// syslogEventSink writes one RFC 5424 message per event (facility local0), framed by octet counting
+// over TCP. The connection is redialed after a write error.
+type syslogEventSink struct {
+	network string
+	addr    string
+	conn    net.Conn
+}
+
+func newSyslogEventSink(network, addr string) (*syslogEventSink, error) {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return nil, fmt.Errorf("invalid EVENT_SINK_URL: syslog target must be host:port")
+	}
+	return &syslogEventSink{network: network, addr: addr}, nil
+}
+
+func (s *syslogEventSink) Send(batch []SecurityEvent) error {
+	if s.conn == nil {
+		conn, err := net.DialTimeout(s.network, s.addr, 10*time.Second)
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+	}
+	_ = s.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	for _, ev := range batch {
+		msg := formatSyslogEvent(ev)
+		if s.network == "tcp" {
+			msg = fmt.Sprintf("%d %s", len(msg), msg)
+		}
+		if _, err := s.conn.Write([]byte(msg)); err != nil {
+			s.Close()
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *syslogEventSink) Close() {
+	if s.conn != nil {
+		_ = s.conn.Close()
+		s.conn = nil
+	}
+}
+
+// formatSyslogEvent renders ev as an RFC 5424 message with its type as MSGID and JSON as MSG.
+func formatSyslogEvent(ev SecurityEvent) string {
+	const facilityLocal0 = 16
+	severity := 6 // informational
+	switch ev.Type {
+	case EventACLReject, EventLimitReject, EventEventsDropped:
+		severity = 4 // warning
+	case EventAuthFailure:
+		severity = 3 // error
+	}
+	host := ev.Hostname
+	if host == "" {
+		host = "-"
+	}
+	body, _ := json.Marshal(ev)
+	return fmt.Sprintf("<%d>1 %s %s bastion %d %s - %s",
+		facilityLocal0*8+severity, ev.Timestamp.UTC().Format(time.RFC3339Nano), host, os.Getpid(), ev.Type, body)
+}
+
+// emitEvent exports an event about the session's mapping.
+func (s *BaseSession) emitEvent(eventType, proto, clientAddr, target, reason string) {
+	EventExport.Emit(SecurityEvent{
+		Type:       eventType,
+		MappingID:  s.Mapping.Key(),
+		Protocol:   proto,
+		ClientAddr: clientAddr,
+		Target:     target,
+		Reason:     reason,
+	})
+}
+
+// trackConnEvents exports conn_open for a forwarded connection and returns the func exporting its
+// conn_close, to be deferred. remote is the connection dialRemote returned for target.
+func (s *BaseSession) trackConnEvents(proto, clientAddr, target string, remote net.Conn) func() {
+	start := time.Now()
+	address := s.servedAddress(remote)
+	EventExport.Emit(SecurityEvent{
+		Type:       EventConnOpen,
+		MappingID:  s.Mapping.Key(),
+		Protocol:   proto,
+		ClientAddr: clientAddr,
+		Target:     target,
+		Address:    address,
+	})
+	return func() {
+		EventExport.Emit(SecurityEvent{
+			Type:       EventConnClose,
+			MappingID:  s.Mapping.Key(),
+			Protocol:   proto,
+			ClientAddr: clientAddr,
+			Target:     target,
+			Address:    address,
+			DurationMS: time.Since(start).Milliseconds(),
+		})
+	}
+}
+
+// servedAddress returns the target address conn is connected to, when it is known: a direct TCP
+// connection or a happy eyeballs dial through the chain. Behind an upstream proxy it is unknown.
+func (s *BaseSession) servedAddress(conn net.Conn) string {
+	if s.upstreamProxy != nil {
+		return ""
+	}
+	switch c := conn.(type) {
+	case *resolvedConn:
+		return c.raddr.String()
+	case *net.TCPConn:
+		return c.RemoteAddr().String()
+	}
+	return ""
+}
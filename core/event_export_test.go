@@ -0,0 +1,144 @@
+package core
+
+import (
+	"bastion/config"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func withEventSettings(t *testing.T, sinkURL, types string, batchSize, queueSize int) {
+	t.Helper()
+	old := *config.Settings
+	t.Cleanup(func() { *config.Settings = old })
+	config.Settings.EventSinkURL = sinkURL
+	config.Settings.EventSinkToken = "secret"
+	config.Settings.EventTypes = types
+	config.Settings.EventBatchSize = batchSize
+	config.Settings.EventFlushIntervalMS = 50
+	config.Settings.EventQueueSize = queueSize
+}
+
+func TestEventExporter_PostsBatches(t *testing.T) {
+	var mu sync.Mutex
+	var batches [][]SecurityEvent
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var batch []SecurityEvent
+		if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		batches = append(batches, batch)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+	withEventSettings(t, srv.URL, "conn_open,acl_reject", 2, 100)
+
+	e := &EventExporter{}
+	if err := e.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	e.Emit(SecurityEvent{Type: EventConnOpen, MappingID: "db", ClientAddr: "127.0.0.1:5000"})
+	e.Emit(SecurityEvent{Type: EventConnClose, MappingID: "db"}) // filtered out
+	e.Emit(SecurityEvent{Type: EventACLReject, MappingID: "db", Reason: "ip_acl"})
+	e.Emit(SecurityEvent{Type: EventConnOpen, MappingID: "web"})
+	e.Stop(5 * time.Second)
+
+	mu.Lock()
+	defer mu.Unlock()
+	var types []string
+	for _, b := range batches {
+		for _, ev := range b {
+			types = append(types, ev.Type)
+		}
+	}
+	if got := strings.Join(types, ","); got != "conn_open,acl_reject,conn_open" {
+		t.Fatalf("unexpected exported events: %s (batches %d)", got, len(batches))
+	}
+	if len(batches[0]) != 2 {
+		t.Fatalf("expected the first batch to be full, got %d events", len(batches[0]))
+	}
+	if st := e.Stats(); st.Sent != 3 || st.Dropped != 0 || st.Failed != 0 {
+		t.Fatalf("unexpected stats: %+v", st)
+	}
+}
+
+func TestEventExporter_ReportsDrops(t *testing.T) {
+	unblock := make(chan struct{})
+	var mu sync.Mutex
+	var requests int
+	var dropped uint64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		first := requests == 1
+		mu.Unlock()
+		if first {
+			<-unblock
+		}
+		var batch []SecurityEvent
+		_ = json.NewDecoder(r.Body).Decode(&batch)
+		mu.Lock()
+		for _, ev := range batch {
+			if ev.Type == EventEventsDropped {
+				dropped += ev.Dropped
+			}
+		}
+		mu.Unlock()
+	}))
+	defer srv.Close()
+	withEventSettings(t, srv.URL, "", 1, 1)
+
+	e := &EventExporter{}
+	if err := e.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	// The first event blocks the sink, the second fills the queue, the rest are dropped.
+	for i := 0; i < 5; i++ {
+		e.Emit(SecurityEvent{Type: EventConnOpen})
+		time.Sleep(10 * time.Millisecond)
+	}
+	total := e.Stats().Dropped
+	if total == 0 {
+		t.Fatalf("expected drops while the sink is blocked: %+v", e.Stats())
+	}
+	close(unblock)
+	e.Stop(5 * time.Second)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if dropped != total {
+		t.Fatalf("expected events_dropped to report %d drops, got %d", total, dropped)
+	}
+}
+
+func TestFormatSyslogEvent(t *testing.T) {
+	ev := SecurityEvent{
+		Type:      EventAuthFailure,
+		Timestamp: time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC),
+		Hostname:  "gw",
+		Protocol:  "API",
+	}
+	msg := formatSyslogEvent(ev)
+	if !strings.HasPrefix(msg, "<131>1 2026-03-10T12:00:00Z gw bastion ") || !strings.Contains(msg, " auth_failure - {") {
+		t.Fatalf("unexpected syslog message: %s", msg)
+	}
+}
+
+func TestNewEventSink_RejectsUnknownScheme(t *testing.T) {
+	if _, err := newEventSink("ftp://siem.example.com", ""); err == nil {
+		t.Fatal("expected unsupported scheme to be rejected")
+	}
+	if _, err := newEventSink("syslog://siem.example.com", ""); err == nil {
+		t.Fatal("expected syslog target without port to be rejected")
+	}
+}
@@ -0,0 +1,102 @@
+package core
+
+import (
+	"bastion/config"
+	"io"
+	"log"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// spliceChunkSize bounds each kernel-side copy so deadlines and byte counters are refreshed periodically.
+const spliceChunkSize = 1 << 20
+
+// copyFast forwards src to dst without feeding the HTTP parser. When both ends are plain TCP connections
+// it lets the kernel move the data (splice on Linux); otherwise it falls back to the pooled-buffer copy.
+func (s *BaseSession) copyFast(dst, src net.Conn, direction, connID string) {
+	dstTCP, _, dstWriteTimeout := unwrapTCPConn(writeSide(dst))
+	srcTCP, srcReadTimeout, _ := unwrapTCPConn(src)
+	if dstTCP == nil || srcTCP == nil {
+		s.copyRaw(dst, src, direction, connID)
+		return
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			s.recordPanic("copyFast", r, map[string]interface{}{"direction": direction, "conn_id": connID})
+		}
+		if err := dstTCP.CloseWrite(); err != nil && config.Settings.LogLevel == "DEBUG" {
+			log.Printf("Failed to close write end of connection: %v", err)
+		}
+	}()
+
+	for {
+		_ = srcTCP.SetReadDeadline(deadlineFor(srcReadTimeout))
+		_ = dstTCP.SetWriteDeadline(deadlineFor(dstWriteTimeout))
+
+		n, err := dstTCP.ReadFrom(&io.LimitedReader{R: srcTCP, N: spliceChunkSize})
+		if n > 0 {
+			if direction == "request" {
+				atomic.AddInt64(&s.bytesUp, n)
+			} else {
+				atomic.AddInt64(&s.bytesDown, n)
+			}
+		}
+		if err != nil {
+			if config.Settings.LogLevel == "DEBUG" {
+				log.Printf("Copy fast error [%s] (%s): %v", direction, connID, err)
+			}
+			return
+		}
+		if n < spliceChunkSize {
+			return // source reached EOF
+		}
+	}
+}
+
+func deadlineFor(timeout time.Duration) time.Time {
+	if timeout <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(timeout)
+}
+
+// unwrapTCPConn returns the underlying *net.TCPConn of a forwarding connection together with the
+// per-operation timeouts of any DeadlineConn wrapper. It returns nil when conn is not backed by TCP
+// or a wrapper holds buffered data (or still inspects the stream) that must not be bypassed.
+func unwrapTCPConn(conn net.Conn) (tcp *net.TCPConn, readTimeout, writeTimeout time.Duration) {
+	for {
+		switch c := conn.(type) {
+		case *net.TCPConn:
+			return c, readTimeout, writeTimeout
+		case *DeadlineConn:
+			readTimeout, writeTimeout = c.readTimeout, c.writeTimeout
+			conn = c.conn
+		case *limitedConn:
+			conn = c.Conn
+		case *tlsSniffConn:
+			if !c.Sniffed() {
+				return nil, 0, 0
+			}
+			conn = c.Conn
+		default:
+			return nil, 0, 0
+		}
+	}
+}
+
+// spliceable reports whether copyFast can hand the copy from src to dst to the kernel.
+func spliceable(dst, src net.Conn) bool {
+	dstTCP, _, _ := unwrapTCPConn(writeSide(dst))
+	srcTCP, _, _ := unwrapTCPConn(src)
+	return dstTCP != nil && srcTCP != nil
+}
+
+// writeSide strips the wrappers of conn that only look at what is read from it.
+func writeSide(conn net.Conn) net.Conn {
+	if c, ok := conn.(*tlsSniffConn); ok {
+		return c.Conn
+	}
+	return conn
+}
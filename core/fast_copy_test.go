@@ -0,0 +1,90 @@
+package core
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// tcpPair returns both ends of a loopback TCP connection.
+func tcpPair(t *testing.T) (*net.TCPConn, *net.TCPConn) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		c, _ := ln.Accept()
+		accepted <- c
+	}()
+	dialed, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	server := <-accepted
+	if server == nil {
+		t.Fatalf("accept failed")
+	}
+	t.Cleanup(func() {
+		_ = dialed.Close()
+		_ = server.Close()
+	})
+	return dialed.(*net.TCPConn), server.(*net.TCPConn)
+}
+
+func TestCopyFast_TCPSplice(t *testing.T) {
+	clientSide, srcConn := tcpPair(t)
+	dstConn, remoteSide := tcpPair(t)
+
+	s := &BaseSession{}
+	payload := bytes.Repeat([]byte("0123456789abcdef"), spliceChunkSize/8) // two chunks
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		src := NewDeadlineConn(srcConn, time.Minute, time.Minute)
+		dst := NewDeadlineConn(&limitedConn{Conn: dstConn, release: func() {}}, time.Minute, time.Minute)
+		s.copyFast(dst, src, "request", "test")
+	}()
+
+	go func() {
+		_, _ = clientSide.Write(payload)
+		_ = clientSide.CloseWrite()
+	}()
+
+	got, err := io.ReadAll(remoteSide)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	<-done
+
+	if !bytes.Equal(got, payload) {
+		t.Fatalf("payload mismatch: got %d bytes, want %d", len(got), len(payload))
+	}
+	if stats := s.GetStats(); stats.BytesUp != int64(len(payload)) {
+		t.Fatalf("expected %d bytes up, got %d", len(payload), stats.BytesUp)
+	}
+}
+
+func TestUnwrapTCPConn(t *testing.T) {
+	a, b := net.Pipe()
+	defer a.Close()
+	defer b.Close()
+	if tcp, _, _ := unwrapTCPConn(NewDeadlineConn(a, time.Second, time.Second)); tcp != nil {
+		t.Fatalf("pipe must not unwrap to TCP")
+	}
+
+	c, _ := tcpPair(t)
+	if tcp, _, _ := unwrapTCPConn(newPrefixedConn(c, []byte("x"))); tcp != nil {
+		t.Fatalf("prefixed conn must not be bypassed")
+	}
+	tcp, rt, wt := unwrapTCPConn(NewDeadlineConn(c, time.Second, 2*time.Second))
+	if tcp != c || rt != time.Second || wt != 2*time.Second {
+		t.Fatalf("unexpected unwrap result: %v %s %s", tcp, rt, wt)
+	}
+}
@@ -3,10 +3,13 @@ package core
 import (
 	"bastion/config"
 	"bastion/models"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net"
+	"net/url"
 	"strconv"
 	"strings"
 	"sync"
@@ -33,9 +36,26 @@ type Session interface {
 
 // SessionStats holds session metrics
 type SessionStats struct {
-	BytesUp     int64
-	BytesDown   int64
-	ActiveConns int32
+	BytesUp     int64           `json:"up_bytes"`
+	BytesDown   int64           `json:"down_bytes"`
+	ActiveConns int32           `json:"connections"`
+	LocalPort   int             `json:"local_port"` // port actually bound (differs from the mapping's when it is 0)
+	Throughput  ThroughputStats `json:"throughput"`
+	// ChannelLimitHits counts the dials that failed on the jump hosts' channel limit (SSHChannelLimitCode).
+	ChannelLimitHits int64            `json:"channel_limit_hits"`
+	LastDialError    *DialErrorStatus `json:"last_dial_error,omitempty"`
+	// HeldConns is the number of clients waiting for the bastion chain to come back (DialPolicy.Hold).
+	HeldConns int `json:"held_connections"`
+}
+
+// DialFailedCode is the DialErrorStatus code of dials that failed for another reason than a channel limit.
+const DialFailedCode = "DIAL_FAILED"
+
+// DialErrorStatus is the last client connection a session could not forward to its remote.
+type DialErrorStatus struct {
+	Code  string    `json:"code"` // SSHChannelLimitCode or DialFailedCode
+	Error string    `json:"error"`
+	At    time.Time `json:"at"`
 }
 
 // BaseSession shared state for sessions
@@ -47,12 +67,36 @@ type BaseSession struct {
 	bytesUp        int64
 	bytesDown      int64
 	stopChan       chan struct{}
+	ctx            context.Context // canceled by Stop, aborting the dials in progress
+	cancel         context.CancelFunc
 	wg             sync.WaitGroup
+	conns          sessionConns                 // client connections in progress, aborted by Stop
 	maxConnections int32                        // Concurrency limit
 	httpParsers    map[string]*HTTPStreamParser // connID:direction -> parser
+	nonHTTPConns   map[string]struct{}          // connIDs sniffed as non-HTTP; guarded by parserMu
 	parserMu       sync.Mutex
 	ipACL          *IPAccessControl
+	targetACL      *TargetAccessControl // optional destination rules of proxy mappings
+	headerRewriter *HeaderRewriter      // optional request header rules of http/mixed mappings
+	upstreamProxy  *url.URL             // optional proxy hop after the bastion chain
+	upstreamErr    error                // why upstream_proxy could not be parsed; fails Start
+	clientLimiter  *ClientLimiter       // optional per-client-IP limits
+	quota          QuotaPolicy
+	quotaNotified  int64 // unix nanos of the quota period already logged as exceeded
+	dialPolicy     DialPolicy
+	hold           chainHold
+	mdns           *MDNSService // advertisement of an exposed mapping (MDNS_ADVERTISE)
+	auditSampling  AuditSampling
 	auditCtx       AuditContext
+	standby        StandbyPolicy
+	lastActivity   int64 // unix nanos of the last client connection open/close
+	peakConns      int32 // most concurrent client connections since start
+	throughput     throughputMeter
+	resources      sessionResources
+
+	channelLimitHits int64
+	dialErrMu        sync.Mutex
+	lastDialError    *DialErrorStatus
 }
 
 func (s *BaseSession) shouldAcceptClient(conn net.Conn) bool {
@@ -77,6 +121,11 @@ func (s *BaseSession) shouldAcceptClient(conn net.Conn) bool {
 // TunnelSession TCP tunnel session
 type TunnelSession struct {
 	BaseSession
+	tls MappingTLS
+	// k8s, loaded at Start for k8s mappings, opens the remote connections as Kubernetes
+	// port-forward streams.
+	k8s   *k8sPortForward
+	isK8s bool
 }
 
 // Socks5Session SOCKS5 proxy session
@@ -84,84 +133,105 @@ type Socks5Session struct {
 	BaseSession
 }
 
-// NewTunnelSession creates a TCP tunnel session
-func NewTunnelSession(mapping *models.Mapping, bastions []models.Bastion) *TunnelSession {
+// newBaseSession builds the shared session state for a mapping and its resolved bastion chain.
+func newBaseSession(mapping *models.Mapping, bastions []models.Bastion) BaseSession {
 	ipACL, _ := NewIPAccessControl(mapping.GetAllowCIDRs(), mapping.GetDenyCIDRs())
+	targetACL, _ := NewTargetAccessControl(mapping.GetTargetAllow(), mapping.GetTargetDeny())
+	headerRewriter, _ := NewHeaderRewriter(mapping.GetRequestHeaders())
+	upstreamProxy, upstreamErr := ParseUpstreamProxy(mapping.UpstreamProxy)
 	chain := make([]string, 0, len(bastions))
 	for _, b := range bastions {
 		chain = append(chain, b.Name)
 	}
-	return &TunnelSession{
-		BaseSession: BaseSession{
-			Mapping:        mapping,
-			Bastions:       bastions,
-			stopChan:       make(chan struct{}),
-			maxConnections: int32(config.Settings.MaxSessionConnections),
-			httpParsers:    make(map[string]*HTTPStreamParser),
-			ipACL:          ipACL,
-			auditCtx: AuditContext{
-				MappingID:    mapping.ID,
-				LocalPort:    mapping.LocalPort,
-				BastionChain: chain,
-			},
+	ctx, cancel := context.WithCancel(context.Background())
+	return BaseSession{
+		Mapping:        mapping,
+		Bastions:       bastions,
+		stopChan:       make(chan struct{}),
+		ctx:            ctx,
+		cancel:         cancel,
+		maxConnections: int32(config.Settings.MaxSessionConnections),
+		httpParsers:    make(map[string]*HTTPStreamParser),
+		nonHTTPConns:   make(map[string]struct{}),
+		ipACL:          ipACL,
+		targetACL:      targetACL,
+		headerRewriter: headerRewriter,
+		upstreamProxy:  upstreamProxy,
+		upstreamErr:    upstreamErr,
+		clientLimiter:  NewClientLimiter(mapping),
+		dialPolicy:     NewDialPolicy(mapping),
+		auditSampling:  NewAuditSampling(mapping),
+		standby:        NewStandbyPolicy(mapping),
+		quota:          NewQuotaPolicy(mapping),
+		auditCtx: AuditContext{
+			MappingID:    mapping.Key(),
+			LocalPort:    mapping.LocalPort,
+			BastionChain: chain,
 		},
 	}
 }
 
+// NewTunnelSession creates a TCP tunnel session
+func NewTunnelSession(mapping *models.Mapping, bastions []models.Bastion) *TunnelSession {
+	return &TunnelSession{BaseSession: newBaseSession(mapping, bastions)}
+}
+
+// NewK8sSession creates a TCP tunnel whose remote is the pod or service of a k8s mapping, reached
+// by port-forward streams through the API server of its kubeconfig (dialed through the chain).
+func NewK8sSession(mapping *models.Mapping, bastions []models.Bastion) *TunnelSession {
+	return &TunnelSession{BaseSession: newBaseSession(mapping, bastions), isK8s: true}
+}
+
 // NewSocks5Session creates a SOCKS5 session
 func NewSocks5Session(mapping *models.Mapping, bastions []models.Bastion) *Socks5Session {
-	ipACL, _ := NewIPAccessControl(mapping.GetAllowCIDRs(), mapping.GetDenyCIDRs())
-	chain := make([]string, 0, len(bastions))
-	for _, b := range bastions {
-		chain = append(chain, b.Name)
-	}
-	return &Socks5Session{
-		BaseSession: BaseSession{
-			Mapping:        mapping,
-			Bastions:       bastions,
-			stopChan:       make(chan struct{}),
-			maxConnections: int32(config.Settings.MaxSessionConnections),
-			httpParsers:    make(map[string]*HTTPStreamParser),
-			ipACL:          ipACL,
-			auditCtx: AuditContext{
-				MappingID:    mapping.ID,
-				LocalPort:    mapping.LocalPort,
-				BastionChain: chain,
-			},
-		},
-	}
+	return &Socks5Session{BaseSession: newBaseSession(mapping, bastions)}
 }
 
 // Start launches the TCP tunnel session
 func (s *TunnelSession) Start() error {
-	addr := net.JoinHostPort(s.Mapping.LocalHost, strconv.Itoa(s.Mapping.LocalPort))
-	listener, err := listenTCPWithDiagnostics(s.Mapping)
+	mappingTLS, err := NewMappingTLS(s.Mapping)
 	if err != nil {
 		return err
 	}
+	s.tls = mappingTLS
+	if s.isK8s {
+		if s.k8s, err = newK8sPortForward(s.Mapping); err != nil {
+			return err
+		}
+	}
 
-	s.listener = listener
-	log.Printf("TCP Tunnel started: %s -> %s:%d", addr, s.Mapping.RemoteHost, s.Mapping.RemotePort)
+	addr, err := s.listen()
+	if err != nil {
+		return err
+	}
+
+	if s.k8s != nil {
+		log.Printf("Kubernetes port-forward started: %s -> %s port %d in namespace %s via %s", addr, s.k8s.target, s.k8s.port, s.k8s.namespace, s.k8s.api.server.Host)
+	} else {
+		log.Printf("TCP Tunnel started: %s -> %s", addr, net.JoinHostPort(s.Mapping.RemoteHost, strconv.Itoa(s.Mapping.RemotePort)))
+	}
 
 	s.wg.Add(1)
-	go s.acceptLoop()
+	s.spawn(s.acceptLoop)
+	s.startStandbyWatcher()
+	s.startThroughputSampler()
 
 	return nil
 }
 
 // Start launches the SOCKS5 session
 func (s *Socks5Session) Start() error {
-	addr := net.JoinHostPort(s.Mapping.LocalHost, strconv.Itoa(s.Mapping.LocalPort))
-	listener, err := listenTCPWithDiagnostics(s.Mapping)
+	addr, err := s.listen()
 	if err != nil {
 		return err
 	}
 
-	s.listener = listener
 	log.Printf("SOCKS5 Proxy started: %s", addr)
 
 	s.wg.Add(1)
-	go s.acceptLoop()
+	s.spawn(s.acceptLoop)
+	s.startStandbyWatcher()
+	s.startThroughputSampler()
 
 	return nil
 }
@@ -188,23 +258,13 @@ func (s *TunnelSession) acceptLoop() {
 			}
 		}
 
-		if !s.shouldAcceptClient(conn) {
-			if config.Settings.LogLevel == "DEBUG" {
-				log.Printf("[TCP] Rejected client %s by IP ACL", conn.RemoteAddr().String())
-			}
-			conn.Close()
-			continue
-		}
-
-		// Enforce connection limit
-		if atomic.LoadInt32(&s.activeConns) >= s.maxConnections {
-			log.Printf("Connection limit reached (%d), rejecting new connection", s.maxConnections)
-			conn.Close()
+		conn, ok := s.admitClient(conn, "TCP")
+		if !ok {
 			continue
 		}
 
 		s.wg.Add(1)
-		go s.handleTCPClientWithRecover(conn)
+		s.spawn(func() { s.handleTCPClientWithRecover(conn) })
 	}
 }
 
@@ -212,7 +272,7 @@ func (s *TunnelSession) acceptLoop() {
 func (s *TunnelSession) handleTCPClientWithRecover(conn net.Conn) {
 	defer func() {
 		if r := recover(); r != nil {
-			log.Printf("Recovered from panic in handleTCPClient: %v", r)
+			s.recordPanic("handleTCPClient", r, map[string]interface{}{"client": conn.RemoteAddr().String()})
 		}
 	}()
 	s.handleTCPClient(conn)
@@ -240,23 +300,13 @@ func (s *Socks5Session) acceptLoop() {
 			}
 		}
 
-		if !s.shouldAcceptClient(conn) {
-			if config.Settings.LogLevel == "DEBUG" {
-				log.Printf("[SOCKS5] Rejected client %s by IP ACL", conn.RemoteAddr().String())
-			}
-			conn.Close()
-			continue
-		}
-
-		// Enforce connection limit
-		if atomic.LoadInt32(&s.activeConns) >= s.maxConnections {
-			log.Printf("Connection limit reached (%d), rejecting new connection", s.maxConnections)
-			conn.Close()
+		conn, ok := s.admitClient(conn, "SOCKS5")
+		if !ok {
 			continue
 		}
 
 		s.wg.Add(1)
-		go s.handleSocks5ClientWithRecover(conn)
+		s.spawn(func() { s.handleSocks5ClientWithRecover(conn) })
 	}
 }
 
@@ -264,7 +314,7 @@ func (s *Socks5Session) acceptLoop() {
 func (s *Socks5Session) handleSocks5ClientWithRecover(conn net.Conn) {
 	defer func() {
 		if r := recover(); r != nil {
-			log.Printf("Recovered from panic in handleSocks5Client: %v", r)
+			s.recordPanic("handleSocks5Client", r, map[string]interface{}{"client": conn.RemoteAddr().String()})
 		}
 	}()
 	s.handleSocks5Client(conn)
@@ -274,9 +324,10 @@ func (s *Socks5Session) handleSocks5ClientWithRecover(conn net.Conn) {
 func (s *TunnelSession) handleTCPClient(clientConn net.Conn) {
 	defer s.wg.Done()
 	defer clientConn.Close()
+	defer s.trackConn(clientConn)()
 
-	atomic.AddInt32(&s.activeConns, 1)
-	defer atomic.AddInt32(&s.activeConns, -1)
+	s.connOpened()
+	defer s.connClosed()
 
 	clientAddr := clientConn.RemoteAddr().String()
 	localAddr := clientConn.LocalAddr().String()
@@ -290,46 +341,59 @@ func (s *TunnelSession) handleTCPClient(clientConn net.Conn) {
 	// Detailed logging: record connection source
 	if config.Settings.LogLevel == "DEBUG" {
 		log.Printf("[TCP] New connection: client=%s, local=%s, target=%s, mapping_id=%s",
-			clientAddr, localAddr, remoteTarget, s.Mapping.ID)
+			clientAddr, localAddr, remoteTarget, s.Mapping.Key())
 	}
 
-	remoteAddr := remoteTarget
-	var remoteConn net.Conn
-	var err error
-
-	// Check for bastion chain
-	if len(s.Bastions) == 0 {
-		// Direct connection (no bastions)
-		remoteConn, err = net.DialTimeout("tcp", remoteAddr, 10*time.Second)
+	if s.tls.Server != nil {
+		tlsConn, err := s.tls.serverConn(clientConn)
 		if err != nil {
-			log.Printf("[TCP] Failed to dial remote %s directly from client %s: %v", remoteAddr, clientAddr, err)
+			log.Printf("[TCP] TLS handshake with client %s failed: %v", clientAddr, err)
 			return
 		}
-	} else {
-		// Connect via bastion chain with retry
-		bastionChain := getBastionChainNames(s.Bastions)
-		remoteConn, err = s.dialWithRetry(remoteAddr, clientAddr, bastionChain)
+		clientConnWithTimeout = NewDeadlineConn(tlsConn, transferReadTimeout, transferWriteTimeout)
+	}
+
+	remoteAddr := remoteTarget
+	remoteConn, err := s.dialTarget(remoteAddr, clientAddr)
+	if err != nil {
+		log.Printf("[TCP] Failed to dial remote %s via %s from client %s: %v", remoteAddr, s.routeDescription(), clientAddr, err)
+		return
+	}
+	defer remoteConn.Close()
+	defer s.trackConnEvents("TCP", clientAddr, remoteAddr, remoteConn)()
+
+	if s.tls.Client != nil {
+		tlsConn, err := s.tls.clientConn(remoteConn)
 		if err != nil {
-			log.Printf("[TCP] Failed to dial remote %s via bastion chain [%s] from client %s: %v",
-				remoteAddr, bastionChain, clientAddr, err)
+			log.Printf("[TCP] TLS handshake with remote %s failed: %v", remoteAddr, err)
 			return
 		}
+		remoteConn = tlsConn
 	}
-	defer remoteConn.Close()
 
 	remoteConnWithTimeout := NewDeadlineConn(remoteConn, transferReadTimeout, transferWriteTimeout)
 
+	client := s.sniffTLS(clientConnWithTimeout, "TCP", clientAddr, remoteAddr)
+	if s.Mapping.QueryAudit != "" && QueryLogs.Enabled() {
+		client = newQueryAuditConn(client, s.Mapping, connID, clientAddr)
+	}
+
 	// Bidirectional forwarding
-	s.pipe(clientConnWithTimeout, remoteConnWithTimeout, connID)
+	if s.Mapping.FTPHelper {
+		s.pipeFTP(clientConnWithTimeout, remoteConnWithTimeout, connID)
+		return
+	}
+	s.pipe(client, remoteConnWithTimeout, connID)
 }
 
 // handleSocks5Client processes a SOCKS5 client connection
 func (s *BaseSession) handleSocks5Client(clientConn net.Conn) {
 	defer s.wg.Done()
 	defer clientConn.Close()
+	defer s.trackConn(clientConn)()
 
-	atomic.AddInt32(&s.activeConns, 1)
-	defer atomic.AddInt32(&s.activeConns, -1)
+	s.connOpened()
+	defer s.connClosed()
 
 	clientAddr := clientConn.RemoteAddr().String()
 	localAddr := clientConn.LocalAddr().String()
@@ -354,6 +418,13 @@ func (s *BaseSession) handleSocks5Client(clientConn net.Conn) {
 	remoteTarget := net.JoinHostPort(targetHost, strconv.Itoa(targetPort))
 	connID := fmt.Sprintf("%s->%s", clientAddr, remoteTarget)
 
+	if !s.allowTarget("SOCKS5", clientAddr, targetHost, targetPort) {
+		if err := handshake.SendReplyCode(clientConnWithTimeout, socks5RepNotAllowed); err != nil {
+			log.Printf("[SOCKS5] Failed to send failure reply: %v", err)
+		}
+		return
+	}
+
 	// Detailed logging: record source and destination
 	if config.Settings.LogLevel == "DEBUG" {
 		log.Printf("[SOCKS5] New connection: client=%s, local=%s, target=%s",
@@ -361,33 +432,16 @@ func (s *BaseSession) handleSocks5Client(clientConn net.Conn) {
 	}
 
 	remoteAddr := remoteTarget
-	var remoteConn net.Conn
-
-	// Check for bastion chain
-	if len(s.Bastions) == 0 {
-		// Direct connection (no bastions)
-		remoteConn, err = net.DialTimeout("tcp", remoteAddr, 10*time.Second)
-		if err != nil {
-			log.Printf("[SOCKS5] Failed to dial remote %s directly from client %s: %v", remoteAddr, clientAddr, err)
-			if err := handshake.SendReply(clientConn, false); err != nil {
-				log.Printf("[SOCKS5] Failed to send failure reply: %v", err)
-			}
-			return
-		}
-	} else {
-		// Connect via bastion chain with retry
-		bastionChain := getBastionChainNames(s.Bastions)
-		remoteConn, err = s.dialWithRetry(remoteAddr, clientAddr, bastionChain)
-		if err != nil {
-			log.Printf("[SOCKS5] Failed to dial remote %s via bastion chain [%s] from client %s: %v",
-				remoteAddr, bastionChain, clientAddr, err)
-			if err := handshake.SendReply(clientConn, false); err != nil {
-				log.Printf("[SOCKS5] Failed to send failure reply: %v", err)
-			}
-			return
+	remoteConn, err := s.dialRemote(remoteAddr, clientAddr)
+	if err != nil {
+		log.Printf("[SOCKS5] Failed to dial remote %s via %s from client %s: %v", remoteAddr, s.routeDescription(), clientAddr, err)
+		if err := handshake.SendReply(clientConn, false); err != nil {
+			log.Printf("[SOCKS5] Failed to send failure reply: %v", err)
 		}
+		return
 	}
 	defer remoteConn.Close()
+	defer s.trackConnEvents("SOCKS5", clientAddr, remoteAddr, remoteConn)()
 
 	remoteConnWithTimeout := NewDeadlineConn(remoteConn, transferReadTimeout, transferWriteTimeout)
 
@@ -399,7 +453,7 @@ func (s *BaseSession) handleSocks5Client(clientConn net.Conn) {
 
 	// Bidirectional forwarding
 	clientConnWithTimeout.SetTimeouts(transferReadTimeout, transferWriteTimeout)
-	s.pipe(clientConnWithTimeout, remoteConnWithTimeout, connID)
+	s.pipe(s.sniffTLS(clientConnWithTimeout, "SOCKS5", clientAddr, remoteAddr), remoteConnWithTimeout, connID)
 }
 
 // pipe handles bidirectional data forwarding
@@ -415,23 +469,23 @@ func (s *BaseSession) pipe(client, remote net.Conn, connID string) {
 	}
 
 	// Client -> Remote (Request)
-	go func() {
+	s.spawn(func() {
 		defer wg.Done()
 		defer once.Do(closeConns) // Ensure connections are closed when this goroutine exits
 		s.copyData(remote, client, "request", connID)
-	}()
+	})
 
 	// Remote -> Client (Response)
-	go func() {
+	s.spawn(func() {
 		defer wg.Done()
 		defer once.Do(closeConns) // Ensure connections are closed when this goroutine exits
 		s.copyData(client, remote, "response", connID)
-	}()
+	})
 
 	wg.Wait() // Wait for both copyData goroutines to finish
 
 	// Connection closed, flush any remaining HTTP data
-	if config.Settings.AuditEnabled {
+	if s.auditSampling.Audits(connID) {
 		s.flushHTTPParser("request", connID)
 		s.flushHTTPParser("response", connID)
 	}
@@ -439,15 +493,19 @@ func (s *BaseSession) pipe(client, remote net.Conn, connID string) {
 
 // copyData copies data between connections
 func (s *BaseSession) copyData(dst, src net.Conn, direction, connID string) {
-	pool := getForwardBufferPool()
-	bufPtr := pool.Get(pool.InitialSize())
-	buf := *bufPtr
-	defer pool.Put(bufPtr)
+	// Without auditing (globally, for this mapping, or sampled out) there is nothing to parse; take the zero-copy path.
+	if !s.auditSampling.Audits(connID) {
+		s.copyFast(dst, src, direction, connID)
+		return
+	}
+
+	buf := s.newForwardBuffer()
+	defer buf.Release()
 
 	// Ensure the write end of the destination connection is closed when this goroutine exits
 	defer func() {
 		if r := recover(); r != nil {
-			log.Printf("Recovered from panic in copyData [%s]: %v", direction, r)
+			s.recordPanic("copyData", r, map[string]interface{}{"direction": direction, "conn_id": connID})
 		}
 		if cw, ok := dst.(interface{ CloseWrite() error }); ok {
 			if err := cw.CloseWrite(); err != nil && config.Settings.LogLevel == "DEBUG" {
@@ -457,8 +515,12 @@ func (s *BaseSession) copyData(dst, src net.Conn, direction, connID string) {
 	}()
 
 	for {
-		n, err := src.Read(buf)
+		readStart := time.Now()
+		n, err := src.Read(buf.Bytes())
+		waited := time.Since(readStart)
 		if n > 0 {
+			data := buf.Bytes()[:n]
+
 			// Update statistics
 			if direction == "request" {
 				atomic.AddInt64(&s.bytesUp, int64(n))
@@ -466,29 +528,27 @@ func (s *BaseSession) copyData(dst, src net.Conn, direction, connID string) {
 				atomic.AddInt64(&s.bytesDown, int64(n))
 			}
 
-			// HTTP Auditing
-			if config.Settings.AuditEnabled {
-				s.feedHTTPParser(buf[:n], direction, connID)
-			}
+			// HTTP Auditing; non-HTTP streams are handed to the fast path once the current chunk is written.
+			bypass := !s.feedHTTPParser(data, direction, connID)
 
 			// Write to destination
 			written := 0
 			for written < n {
-				w, writeErr := dst.Write(buf[written:n])
+				w, writeErr := dst.Write(data[written:])
 				if writeErr != nil {
 					return // Exit on write error
 				}
 				written += w
 			}
 
-			// If we regularly fill the buffer, upgrade to the next size class for better throughput.
-			if n == len(buf) {
-				if next, ok := pool.NextSize(len(buf)); ok && next > len(buf) {
-					pool.Put(bufPtr)
-					bufPtr = pool.Get(next)
-					buf = *bufPtr
-				}
+			if bypass && err == nil {
+				buf.Release()
+				s.copyFast(dst, src, direction, connID)
+				return
 			}
+
+			// Resize for the next read: grow while reads keep filling the buffer, shrink after idling.
+			buf.Observe(n, waited)
 		}
 		if err != nil {
 			if err != io.EOF && config.Settings.LogLevel == "DEBUG" {
@@ -499,10 +559,21 @@ func (s *BaseSession) copyData(dst, src net.Conn, direction, connID string) {
 	}
 }
 
-// feedHTTPParser feeds data into the HTTP parser
-func (s *BaseSession) feedHTTPParser(data []byte, direction, connID string) {
+// feedHTTPParser feeds data into the HTTP parser. It returns false when the connection was sniffed as
+// non-HTTP, after which the caller should stop auditing it.
+func (s *BaseSession) feedHTTPParser(data []byte, direction, connID string) bool {
+	return s.feedHTTPParserCtx(s.auditCtx, data, direction, connID)
+}
+
+// feedHTTPParserCtx is feedHTTPParser with the audit context of the messages completed by data.
+func (s *BaseSession) feedHTTPParserCtx(ctx AuditContext, data []byte, direction, connID string) bool {
 	s.parserMu.Lock()
 
+	if _, bypass := s.nonHTTPConns[connID]; bypass {
+		s.parserMu.Unlock()
+		return false
+	}
+
 	parserKey := connID + ":" + direction
 	parser, exists := s.httpParsers[parserKey]
 	if !exists {
@@ -512,13 +583,28 @@ func (s *BaseSession) feedHTTPParser(data []byte, direction, connID string) {
 
 	s.parserMu.Unlock()
 
+	if !parser.Sniff(data) {
+		s.parserMu.Lock()
+		s.nonHTTPConns[connID] = struct{}{}
+		delete(s.httpParsers, connID+":request")
+		delete(s.httpParsers, connID+":response")
+		s.parserMu.Unlock()
+
+		if config.Settings.LogLevel == "DEBUG" {
+			log.Printf("[AUDIT] Non-HTTP %s stream on %s, bypassing HTTP parser", direction, connID)
+		}
+		return false
+	}
+
 	// Feed data and fetch complete messages
+	atomic.AddInt64(&s.resources.auditedBytes, int64(len(data)))
 	messages := parser.Feed(data)
 
 	// Send complete messages to the auditor
 	for _, msg := range messages {
-		AuditorInstance.EnqueueHTTPMessage(s.auditCtx, connID, msg)
+		AuditorInstance.EnqueueHTTPMessage(ctx, connID, msg)
 	}
+	return true
 }
 
 // flushHTTPParser flushes the HTTP parser
@@ -530,6 +616,7 @@ func (s *BaseSession) flushHTTPParser(direction, connID string) {
 	if exists {
 		delete(s.httpParsers, parserKey)
 	}
+	delete(s.nonHTTPConns, connID)
 
 	s.parserMu.Unlock()
 
@@ -540,12 +627,18 @@ func (s *BaseSession) flushHTTPParser(direction, connID string) {
 	}
 }
 
-// Stop stops the session
+// Stop stops the session. The client connections in progress are closed, aborting their
+// handshakes, dials and transfers, so the session winds down promptly.
 func (s *BaseSession) Stop() {
 	close(s.stopChan)
+	if s.cancel != nil {
+		s.cancel()
+	}
 	if s.listener != nil {
 		s.listener.Close()
 	}
+	s.withdraw()
+	s.abortConns()
 
 	// Wait with timeout to avoid blocking indefinitely
 	done := make(chan struct{})
@@ -556,30 +649,104 @@ func (s *BaseSession) Stop() {
 
 	select {
 	case <-done:
-		log.Printf("Session stopped for mapping: %s", s.Mapping.ID)
+		log.Printf("Session stopped for mapping: %s", s.Mapping.Key())
 	case <-time.After(5 * time.Second):
-		log.Printf("Session stop timeout for mapping: %s (forced)", s.Mapping.ID)
+		log.Printf("Session stop timeout for mapping: %s (forced)", s.Mapping.Key())
 	}
 }
 
-// dialWithRetry dials via bastion chain with retries
-func (s *BaseSession) dialWithRetry(remoteAddr, clientAddr, bastionChain string) (net.Conn, error) {
-	maxRetries := 3
-	retryDelay := 1 * time.Second
+// dialRemote connects to remoteAddr through the bastion chain (or directly when the chain is empty),
+// then through the mapping's upstream proxy when one is configured.
+func (s *BaseSession) dialRemote(remoteAddr, clientAddr string) (net.Conn, error) {
+	var forward dialFunc
+	if len(s.Bastions) == 0 {
+		forward = func(network, addr string) (net.Conn, error) {
+			return net.DialTimeout(network, addr, s.dialPolicy.Timeout)
+		}
+	} else {
+		bastionChain := getBastionChainNames(s.Bastions)
+		forward = func(network, addr string) (net.Conn, error) {
+			return s.dialWithRetry(addr, clientAddr, bastionChain)
+		}
+	}
 
-	var lastErr error
+	var (
+		conn net.Conn
+		err  error
+	)
+	if s.upstreamProxy == nil {
+		conn, err = forward("tcp", remoteAddr)
+	} else {
+		conn, err = dialViaUpstreamProxy(forward, s.upstreamProxy, remoteAddr)
+	}
+	if err != nil && s.Mapping != nil {
+		MappingEvents.RecordDialFailure(s.Mapping.Key(), remoteAddr, err)
+	}
+	return conn, err
+}
+
+// dialTarget connects to the mapping's remote: through dialRemote, or for k8s mappings as a
+// port-forward stream whose API server connections go through dialRemote.
+func (s *TunnelSession) dialTarget(remoteAddr, clientAddr string) (net.Conn, error) {
+	if s.k8s == nil {
+		return s.dialRemote(remoteAddr, clientAddr)
+	}
+	conn, err := s.k8s.Dial(func(addr string) (net.Conn, error) {
+		return s.dialRemote(addr, clientAddr)
+	})
+	if err != nil {
+		s.recordDialError(err)
+	}
+	return conn, err
+}
+
+// routeDescription describes how remote connections are routed, for logging.
+func (s *BaseSession) routeDescription() string {
+	route := "direct"
+	if len(s.Bastions) > 0 {
+		route = "bastion chain [" + getBastionChainNames(s.Bastions) + "]"
+	}
+	if s.upstreamProxy != nil {
+		route += " + upstream proxy " + s.upstreamProxy.Scheme + "://" + s.upstreamProxy.Host
+	}
+	return route
+}
+
+// dialWithRetry dials via bastion chain, retrying according to the session's dial policy
+func (s *BaseSession) dialWithRetry(remoteAddr, clientAddr, bastionChain string) (net.Conn, error) {
+	policy := s.dialPolicy
+	maxRetries := policy.MaxAttempts
+	ctx := s.dialContext()
+
+	var (
+		lastErr  error
+		limitErr *ChannelLimitError
+	)
 	for attempt := 1; attempt <= maxRetries; attempt++ {
 		if attempt > 1 {
 			if config.Settings.LogLevel == "DEBUG" {
 				log.Printf("[Retry] Attempt %d/%d to dial %s via bastion chain [%s] for client %s",
 					attempt, maxRetries, remoteAddr, bastionChain, clientAddr)
 			}
-			time.Sleep(retryDelay)
+			select {
+			case <-ctx.Done():
+				return nil, fmt.Errorf("dial to %s canceled: %w", remoteAddr, ctx.Err())
+			case <-time.After(policy.Delay(attempt)):
+			}
 		}
 
-		remoteConn, err := Pool.Dial(s.Bastions, "tcp", remoteAddr)
+		remoteConn, err := dialWithTimeout(policy.Timeout, func() (net.Conn, error) {
+			return s.dialChain(remoteAddr)
+		})
 		if err != nil {
+			if errors.As(err, &limitErr) {
+				atomic.AddInt64(&s.channelLimitHits, 1)
+			}
 			lastErr = fmt.Errorf("dial failed: %w", err)
+			if ctx.Err() != nil {
+				// The session stopped: nothing to retry or record.
+				return nil, lastErr
+			}
 			continue
 		}
 
@@ -590,7 +757,43 @@ func (s *BaseSession) dialWithRetry(remoteAddr, clientAddr, bastionChain string)
 		return remoteConn, nil
 	}
 
-	return nil, fmt.Errorf("all %d attempts failed, last error: %w", maxRetries, lastErr)
+	// A chain that is down (rather than a target that refuses) may be back shortly, e.g. after a
+	// jump host restart: hold the client while it is re-established, then try once more.
+	if policy.Hold > 0 && !errors.As(lastErr, &limitErr) {
+		if _, err := Pool.GetConnection(ctx, s.Bastions); err != nil && s.holdForChain(clientAddr, bastionChain) {
+			remoteConn, err := dialWithTimeout(policy.Timeout, func() (net.Conn, error) {
+				return s.dialChain(remoteAddr)
+			})
+			if err == nil {
+				return remoteConn, nil
+			}
+			lastErr = fmt.Errorf("dial failed after holding: %w", err)
+		}
+	}
+
+	err := fmt.Errorf("all %d attempts failed, last error: %w", maxRetries, lastErr)
+	s.recordDialError(err)
+	return nil, err
+}
+
+// dialContext returns the session's context, canceled by Stop.
+func (s *BaseSession) dialContext() context.Context {
+	if s.ctx == nil {
+		return context.Background()
+	}
+	return s.ctx
+}
+
+// recordDialError keeps the last failed dial for the session stats.
+func (s *BaseSession) recordDialError(err error) {
+	status := &DialErrorStatus{Code: DialFailedCode, Error: err.Error(), At: time.Now()}
+	var limitErr *ChannelLimitError
+	if errors.As(err, &limitErr) {
+		status.Code = SSHChannelLimitCode
+	}
+	s.dialErrMu.Lock()
+	s.lastDialError = status
+	s.dialErrMu.Unlock()
 }
 
 // getBastionChainNames returns bastion chain names for logging
@@ -608,5 +811,21 @@ func (s *BaseSession) GetStats() SessionStats {
 		BytesUp:     atomic.LoadInt64(&s.bytesUp),
 		BytesDown:   atomic.LoadInt64(&s.bytesDown),
 		ActiveConns: atomic.LoadInt32(&s.activeConns),
+		LocalPort:   s.LocalPort(),
+		Throughput:  s.throughputStats(),
+
+		ChannelLimitHits: atomic.LoadInt64(&s.channelLimitHits),
+		LastDialError:    s.lastDialErrorStatus(),
+		HeldConns:        s.hold.Held(),
+	}
+}
+
+func (s *BaseSession) lastDialErrorStatus() *DialErrorStatus {
+	s.dialErrMu.Lock()
+	defer s.dialErrMu.Unlock()
+	if s.lastDialError == nil {
+		return nil
 	}
+	status := *s.lastDialError
+	return &status
 }
@@ -0,0 +1,298 @@
+package core
+
+import (
+	"bastion/config"
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// ftpDataAcceptTimeout is how long a data-channel forward announced in a PASV/EPSV reply waits for
+	// the client to connect.
+	ftpDataAcceptTimeout = 30 * time.Second
+	// ftpMaxReplyLine bounds the reply lines the FTP helper inspects; longer lines are forwarded as is.
+	ftpMaxReplyLine = 4096
+)
+
+// pipeFTP forwards an FTP control connection like pipe, but rewrites PASV (227) and EPSV (229) replies
+// so the client opens its data connections to short-lived local listeners, which are forwarded through
+// the same route to the server. Active mode (PORT/EPRT) and encrypted control connections (AUTH TLS)
+// are passed through untouched.
+func (s *TunnelSession) pipeFTP(client, remote net.Conn, connID string) {
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	// done ends the pending data-channel listeners together with the control connection
+	done := make(chan struct{})
+	once := sync.Once{}
+	closeConns := func() {
+		client.Close()
+		remote.Close()
+		close(done)
+	}
+
+	s.spawn(func() {
+		defer wg.Done()
+		defer once.Do(closeConns)
+		s.copyRaw(remote, client, "request", connID)
+	})
+
+	s.spawn(func() {
+		defer wg.Done()
+		defer once.Do(closeConns)
+		s.copyFTPReplies(client, remote, connID, done)
+	})
+
+	wg.Wait()
+}
+
+// copyFTPReplies copies the server's control replies to the client line by line, rewriting passive
+// mode replies for the client connection dst.
+func (s *TunnelSession) copyFTPReplies(dst, src net.Conn, connID string, done <-chan struct{}) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.recordPanic("copyFTPReplies", r, map[string]interface{}{"conn_id": connID})
+		}
+		if cw, ok := dst.(interface{ CloseWrite() error }); ok {
+			_ = cw.CloseWrite()
+		}
+	}()
+
+	r := bufio.NewReaderSize(src, ftpMaxReplyLine)
+	lineStart := true
+	for {
+		line, err := r.ReadSlice('\n')
+		if len(line) > 0 {
+			atomic.AddInt64(&s.bytesDown, int64(len(line)))
+
+			out := line
+			if lineStart && err == nil {
+				if reply, ok := s.openFTPDataForward(string(line), dst, connID, done); ok {
+					out = []byte(reply)
+				}
+			}
+			if _, werr := dst.Write(out); werr != nil {
+				return
+			}
+		}
+		if err != nil && !errors.Is(err, bufio.ErrBufferFull) {
+			if err != io.EOF && config.Settings.LogLevel == "DEBUG" {
+				log.Printf("FTP control copy error (%s): %v", connID, err)
+			}
+			return
+		}
+		lineStart = err == nil
+	}
+}
+
+// openFTPDataForward handles one reply line: for a PASV/EPSV reply it starts a data-channel forward
+// to the announced port and returns the reply pointing the client at it.
+func (s *TunnelSession) openFTPDataForward(line string, clientConn net.Conn, connID string, done <-chan struct{}) (string, bool) {
+	port, extended, ok := parseFTPPassiveReply(line)
+	if !ok {
+		return "", false
+	}
+
+	// Listen on the address the client reached the control connection at, so it can reach the data
+	// listener too.
+	localHost, _, err := net.SplitHostPort(clientConn.LocalAddr().String())
+	if err != nil {
+		return "", false
+	}
+	localIP := net.ParseIP(localHost)
+	if localIP == nil || (!extended && localIP.To4() == nil) {
+		log.Printf("[FTP] Cannot rewrite passive reply for %s: PASV needs an IPv4 listener, client connected to %s", connID, localHost)
+		return "", false
+	}
+
+	ln, err := net.Listen("tcp", net.JoinHostPort(localHost, "0"))
+	if err != nil {
+		log.Printf("[FTP] Failed to open data listener for %s: %v", connID, err)
+		return "", false
+	}
+	dataPort := ln.Addr().(*net.TCPAddr).Port
+
+	s.wg.Add(1)
+	tcpLn, clientAddr := ln.(*net.TCPListener), clientConn.RemoteAddr().String()
+	s.spawn(func() { s.forwardFTPData(tcpLn, clientAddr, port, connID, done) })
+
+	return formatFTPPassiveReply(extended, localIP, dataPort), true
+}
+
+// forwardFTPData accepts one data connection from the control connection's client on ln and forwards
+// it to the server's data port through the mapping's route. The server's announced address is
+// ignored (as most clients do): data goes to the mapping's remote host, which the server can only
+// reach through the chain anyway.
+func (s *TunnelSession) forwardFTPData(ln *net.TCPListener, clientAddr string, port int, connID string, done <-chan struct{}) {
+	defer s.wg.Done()
+
+	accepted := make(chan struct{})
+	s.spawn(func() {
+		select {
+		case <-done:
+		case <-s.stopChan:
+		case <-accepted:
+		}
+		ln.Close()
+	})
+
+	conn, err := acceptFTPDataConn(ln, clientAddr, time.Now().Add(ftpDataAcceptTimeout))
+	close(accepted)
+	if err != nil {
+		if config.Settings.LogLevel == "DEBUG" {
+			log.Printf("[FTP] No data connection for %s: %v", connID, err)
+		}
+		return
+	}
+	defer conn.Close()
+	defer s.trackConn(conn)()
+
+	s.connOpened()
+	defer s.connClosed()
+
+	remoteAddr := net.JoinHostPort(s.Mapping.RemoteHost, strconv.Itoa(port))
+	remoteConn, err := s.dialRemote(remoteAddr, clientAddr)
+	if err != nil {
+		log.Printf("[FTP] Failed to dial data channel %s via %s from client %s: %v", remoteAddr, s.routeDescription(), clientAddr, err)
+		return
+	}
+	defer remoteConn.Close()
+	defer s.trackConnEvents("FTP-DATA", clientAddr, remoteAddr, remoteConn)()
+
+	transferReadTimeout := time.Duration(config.Settings.TransferReadTimeoutSeconds) * time.Second
+	transferWriteTimeout := time.Duration(config.Settings.TransferWriteTimeoutSeconds) * time.Second
+	client := NewDeadlineConn(conn, transferReadTimeout, transferWriteTimeout)
+	remote := NewDeadlineConn(remoteConn, transferReadTimeout, transferWriteTimeout)
+	dataConnID := fmt.Sprintf("%s->%s", conn.RemoteAddr(), remoteAddr)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	once := sync.Once{}
+	closeConns := func() {
+		client.Close()
+		remote.Close()
+	}
+	s.spawn(func() {
+		defer wg.Done()
+		defer once.Do(closeConns)
+		s.copyFast(remote, client, "request", dataConnID)
+	})
+	s.spawn(func() {
+		defer wg.Done()
+		defer once.Do(closeConns)
+		s.copyFast(client, remote, "response", dataConnID)
+	})
+	wg.Wait()
+}
+
+// acceptFTPDataConn returns the first connection on ln from the host of clientAddr before deadline;
+// connections from other hosts are refused so nobody else can grab the transfer.
+func acceptFTPDataConn(ln *net.TCPListener, clientAddr string, deadline time.Time) (net.Conn, error) {
+	clientHost, _, err := net.SplitHostPort(clientAddr)
+	if err != nil {
+		return nil, err
+	}
+	if err := ln.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return nil, err
+		}
+		host, _, _ := net.SplitHostPort(conn.RemoteAddr().String())
+		if host == clientHost {
+			return conn, nil
+		}
+		log.Printf("[FTP] Refused data connection from %s (control connection is from %s)", conn.RemoteAddr(), clientHost)
+		conn.Close()
+	}
+}
+
+// parseFTPPassiveReply extracts the data port from a "227 Entering Passive Mode (h1,h2,h3,h4,p1,p2)"
+// or "229 Entering Extended Passive Mode (|||port|)" reply; extended reports the latter.
+func parseFTPPassiveReply(line string) (port int, extended bool, ok bool) {
+	line = strings.TrimRight(line, "\r\n")
+	switch {
+	case strings.HasPrefix(line, "227 "):
+		port, ok = parsePASVAddress(line[4:])
+		return port, false, ok
+	case strings.HasPrefix(line, "229 "):
+		port, ok = parseEPSVAddress(line[4:])
+		return port, true, ok
+	}
+	return 0, false, false
+}
+
+// parsePASVAddress finds the first "h1,h2,h3,h4,p1,p2" in text; servers differ on the parentheses.
+func parsePASVAddress(text string) (int, bool) {
+	start := strings.IndexAny(text, "0123456789")
+	for start >= 0 {
+		end := start
+		for end < len(text) && (text[end] == ',' || (text[end] >= '0' && text[end] <= '9')) {
+			end++
+		}
+		if parts := strings.Split(text[start:end], ","); len(parts) == 6 {
+			var nums [6]int
+			valid := true
+			for i, p := range parts {
+				n, err := strconv.Atoi(p)
+				if err != nil || n > 255 {
+					valid = false
+					break
+				}
+				nums[i] = n
+			}
+			if valid {
+				port := nums[4]<<8 | nums[5]
+				return port, port > 0
+			}
+		}
+		next := strings.IndexAny(text[end:], "0123456789")
+		if next < 0 {
+			break
+		}
+		start = end + next
+	}
+	return 0, false
+}
+
+// parseEPSVAddress parses "(<d><d><d>port<d>)", where <d> is any delimiter (RFC 2428).
+func parseEPSVAddress(text string) (int, bool) {
+	open := strings.IndexByte(text, '(')
+	if open < 0 || len(text) < open+6 {
+		return 0, false
+	}
+	body := text[open+1:]
+	d := body[0]
+	if body[1] != d || body[2] != d {
+		return 0, false
+	}
+	end := strings.IndexByte(body[3:], d)
+	if end <= 0 || len(body) <= 3+end+1 || body[3+end+1] != ')' {
+		return 0, false
+	}
+	port, err := strconv.Atoi(body[3 : 3+end])
+	if err != nil || port <= 0 || port > 65535 {
+		return 0, false
+	}
+	return port, true
+}
+
+// formatFTPPassiveReply renders the reply announcing ip:port to the client.
+func formatFTPPassiveReply(extended bool, ip net.IP, port int) string {
+	if extended {
+		return fmt.Sprintf("229 Entering Extended Passive Mode (|||%d|)\r\n", port)
+	}
+	v4 := ip.To4()
+	return fmt.Sprintf("227 Entering Passive Mode (%d,%d,%d,%d,%d,%d).\r\n", v4[0], v4[1], v4[2], v4[3], port>>8, port&0xff)
+}
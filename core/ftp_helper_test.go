@@ -0,0 +1,139 @@
+package core
+
+import (
+	"bastion/models"
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseFTPPassiveReply(t *testing.T) {
+	cases := []struct {
+		line     string
+		port     int
+		extended bool
+		ok       bool
+	}{
+		{"227 Entering Passive Mode (10,0,0,5,195,80).\r\n", 195*256 + 80, false, true},
+		{"227 Entering Passive Mode 10,0,0,5,4,1\r\n", 1025, false, true},
+		{"227 =10,0,0,5,0,21\r\n", 21, false, true},
+		{"229 Entering Extended Passive Mode (|||50000|)\r\n", 50000, true, true},
+		{"229 Entering Extended Passive Mode (!!!2121!)\r\n", 2121, true, true},
+		{"227 Entering Passive Mode (10,0,0,5,300,1).\r\n", 0, false, false},
+		{"229 Entering Extended Passive Mode (|||70000|)\r\n", 0, true, false},
+		{"229 Entering Extended Passive Mode (||50000|)\r\n", 0, true, false},
+		{"227-multi-line\r\n", 0, false, false},
+		{"150 Opening data connection (10,0,0,5,195,80)\r\n", 0, false, false},
+	}
+	for _, c := range cases {
+		port, extended, ok := parseFTPPassiveReply(c.line)
+		if ok != c.ok || (ok && (port != c.port || extended != c.extended)) {
+			t.Errorf("parseFTPPassiveReply(%q) = %d, %v, %v; want %d, %v, %v", c.line, port, extended, ok, c.port, c.extended, c.ok)
+		}
+	}
+
+	if got := formatFTPPassiveReply(false, net.ParseIP("127.0.0.1"), 50001); got != "227 Entering Passive Mode (127,0,0,1,195,81).\r\n" {
+		t.Fatalf("unexpected PASV reply %q", got)
+	}
+	if got := formatFTPPassiveReply(true, net.ParseIP("::1"), 50001); got != "229 Entering Extended Passive Mode (|||50001|)\r\n" {
+		t.Fatalf("unexpected EPSV reply %q", got)
+	}
+}
+
+func TestTunnelSession_FTPHelperForwardsPassiveData(t *testing.T) {
+	// Fake FTP server: announces an unreachable address for its data port, which the helper must ignore.
+	dataLn, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dataLn.Close()
+	dataPort := dataLn.Addr().(*net.TCPAddr).Port
+
+	ctrlLn, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctrlLn.Close()
+
+	go func() {
+		conn, err := ctrlLn.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		fmt.Fprint(conn, "220 ready\r\n")
+		if line, _ := r.ReadString('\n'); strings.HasPrefix(line, "PASV") {
+			fmt.Fprintf(conn, "227 Entering Passive Mode (192,0,2,1,%d,%d).\r\n", dataPort>>8, dataPort&0xff)
+		}
+		data, err := dataLn.Accept()
+		if err != nil {
+			return
+		}
+		fmt.Fprint(data, "file contents")
+		data.Close()
+		fmt.Fprint(conn, "226 done\r\n")
+		_, _ = r.ReadString('\n')
+	}()
+
+	serverPort := ctrlLn.Addr().(*net.TCPAddr).Port
+	mapping := &models.Mapping{ID: "ftp", Type: "tcp", RemoteHost: "127.0.0.1", RemotePort: serverPort, FTPHelper: true}
+	s := &TunnelSession{BaseSession: newBaseSession(mapping, nil)}
+
+	clientSide, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer clientSide.Close()
+	go func() {
+		conn, err := clientSide.Accept()
+		if err != nil {
+			return
+		}
+		s.wg.Add(1)
+		s.handleTCPClient(conn)
+	}()
+
+	ctrl, err := net.Dial("tcp", clientSide.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctrl.Close()
+	_ = ctrl.SetDeadline(time.Now().Add(5 * time.Second))
+	r := bufio.NewReader(ctrl)
+
+	if line, _ := r.ReadString('\n'); !strings.HasPrefix(line, "220") {
+		t.Fatalf("unexpected greeting %q", line)
+	}
+	fmt.Fprint(ctrl, "PASV\r\n")
+	reply, _ := r.ReadString('\n')
+	port, extended, ok := parseFTPPassiveReply(reply)
+	if !ok || extended || !strings.Contains(reply, "(127,0,0,1,") || port == dataPort {
+		t.Fatalf("expected PASV reply rewritten to a local listener, got %q", reply)
+	}
+
+	data, err := net.Dial("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+	if err != nil {
+		t.Fatalf("dial data listener: %v", err)
+	}
+	_ = data.SetDeadline(time.Now().Add(5 * time.Second))
+	got, _ := io.ReadAll(data)
+	data.Close()
+	if string(got) != "file contents" {
+		t.Fatalf("unexpected data %q", got)
+	}
+	if line, _ := r.ReadString('\n'); !strings.HasPrefix(line, "226") {
+		t.Fatalf("unexpected transfer reply %q", line)
+	}
+
+	// The listener served its one connection.
+	if c, err := net.DialTimeout("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)), time.Second); err == nil {
+		c.Close()
+		t.Fatal("expected the data listener to be closed after its connection")
+	}
+}
@@ -0,0 +1,161 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxGoroutineDumpBytes bounds the buffer used to capture all goroutine stacks.
+const maxGoroutineDumpBytes = 64 << 20
+
+// goroutineGroupIDs is how many goroutine IDs a group lists as examples.
+const goroutineGroupIDs = 10
+
+// GoroutineGroup is a set of goroutines in the same state with the same stack.
+type GoroutineGroup struct {
+	Count int    `json:"count"`
+	State string `json:"state"` // e.g. "IO wait", "select", "chan receive"
+	// MaxWaitMinutes is the longest time a goroutine of the group has been blocked, as reported
+	// by the runtime (only for waits of a minute or more).
+	MaxWaitMinutes int      `json:"max_wait_minutes,omitempty"`
+	IDs            []int64  `json:"ids"`   // up to 10 examples
+	Stack          []string `json:"stack"` // "function file:line", innermost call first
+	CreatedBy      string   `json:"created_by,omitempty"`
+}
+
+// GoroutineDump is the stacks of all goroutines grouped by state and stack, largest groups first.
+type GoroutineDump struct {
+	Total  int              `json:"total"`
+	Groups []GoroutineGroup `json:"groups"`
+}
+
+// DumpGoroutines captures and groups the stacks of all goroutines. A leak shows up as one group
+// whose count keeps growing, and its stack and creator point at the code that started it.
+func DumpGoroutines() GoroutineDump {
+	buf := make([]byte, 1<<20)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) || len(buf) >= maxGoroutineDumpBytes {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+	return parseGoroutineDump(buf)
+}
+
+// parseGoroutineDump groups a dump in the format of runtime.Stack(buf, true).
+func parseGoroutineDump(data []byte) GoroutineDump {
+	groups := make(map[string]*GoroutineGroup)
+	var order []string
+	dump := GoroutineDump{Groups: []GoroutineGroup{}}
+
+	var cur *GoroutineGroup
+	var id int64
+	wait := 0
+	var pendingFunc string
+	flush := func() {
+		if cur == nil {
+			return
+		}
+		key := cur.State + "\n" + strings.Join(cur.Stack, "\n") + "\n" + cur.CreatedBy
+		g, ok := groups[key]
+		if !ok {
+			g = cur
+			g.IDs = []int64{}
+			groups[key] = g
+			order = append(order, key)
+		}
+		g.Count++
+		if len(g.IDs) < goroutineGroupIDs {
+			g.IDs = append(g.IDs, id)
+		}
+		if wait > g.MaxWaitMinutes {
+			g.MaxWaitMinutes = wait
+		}
+		dump.Total++
+		cur = nil
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "goroutine ") && strings.HasSuffix(line, ":"):
+			flush()
+			cur = &GoroutineGroup{Stack: []string{}}
+			id, cur.State, wait = parseGoroutineHeader(line)
+			pendingFunc = ""
+		case cur == nil || line == "":
+			flush()
+		case strings.HasPrefix(line, "\t"):
+			location := strings.TrimSpace(line)
+			if i := strings.Index(location, " +0x"); i >= 0 {
+				location = location[:i]
+			}
+			switch {
+			case strings.HasPrefix(pendingFunc, "created by "):
+				cur.CreatedBy = strings.TrimPrefix(pendingFunc, "created by ") + " " + location
+			case pendingFunc != "":
+				cur.Stack = append(cur.Stack, pendingFunc+" "+location)
+			}
+			pendingFunc = ""
+		case strings.HasPrefix(line, "created by "):
+			// "created by pkg.fn in goroutine 7": the creating goroutine differs between members.
+			name := line
+			if i := strings.Index(name, " in goroutine "); i >= 0 {
+				name = name[:i]
+			}
+			pendingFunc = name
+		case strings.HasPrefix(line, "..."):
+			cur.Stack = append(cur.Stack, line) // "...additional frames elided..."
+		default:
+			pendingFunc = trimCallArgs(line)
+		}
+	}
+	flush()
+
+	for _, key := range order {
+		dump.Groups = append(dump.Groups, *groups[key])
+	}
+	sort.SliceStable(dump.Groups, func(i, j int) bool {
+		return dump.Groups[i].Count > dump.Groups[j].Count
+	})
+	return dump
+}
+
+// parseGoroutineHeader parses "goroutine 7 [chan receive, 12 minutes]:".
+func parseGoroutineHeader(line string) (id int64, state string, waitMinutes int) {
+	rest := strings.TrimPrefix(line, "goroutine ")
+	idText, rest, _ := strings.Cut(rest, " ")
+	id, _ = strconv.ParseInt(idText, 10, 64)
+
+	rest = strings.TrimSuffix(strings.TrimPrefix(rest, "["), "]:")
+	for i, part := range strings.Split(rest, ", ") {
+		if i == 0 {
+			state = part
+			continue
+		}
+		if n, unit, ok := strings.Cut(part, " "); ok && strings.HasPrefix(unit, "minute") {
+			waitMinutes, _ = strconv.Atoi(n)
+		}
+	}
+	return id, state, waitMinutes
+}
+
+// trimCallArgs removes the argument words from a stack frame, "pkg.(*T).fn(0xc000..., 0x1)"
+// becoming "pkg.(*T).fn", so goroutines differing only in arguments group together.
+func trimCallArgs(frame string) string {
+	if !strings.HasSuffix(frame, ")") {
+		return frame
+	}
+	if i := strings.LastIndex(frame, "("); i > 0 {
+		return frame[:i]
+	}
+	return frame
+}
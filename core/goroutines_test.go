@@ -0,0 +1,78 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+const sampleGoroutineDump = `goroutine 1 [running]:
+main.main()
+	/src/main.go:10 +0x1d
+
+goroutine 7 [chan receive, 12 minutes]:
+bastion/core.(*BaseSession).acceptLoop(0xc000123000)
+	/src/core/forwarder.go:180 +0x45
+created by bastion/core.(*TunnelSession).Start in goroutine 1
+	/src/core/forwarder.go:145 +0x99
+
+goroutine 9 [chan receive, 3 minutes]:
+bastion/core.(*BaseSession).acceptLoop(0xc000456000)
+	/src/core/forwarder.go:180 +0x45
+created by bastion/core.(*TunnelSession).Start in goroutine 5
+	/src/core/forwarder.go:145 +0x99
+
+goroutine 11 [chan receive]:
+bastion/core.(*BaseSession).acceptLoop(0xc000789000)
+	/src/core/forwarder.go:180 +0x45
+created by bastion/core.(*TunnelSession).Start in goroutine 1
+	/src/core/forwarder.go:145 +0x99
+
+goroutine 12 [select, locked to thread]:
+runtime.ensureSigM.func1()
+	/go/src/runtime/signal_unix.go:1004 +0x1c5
+...additional frames elided...
+`
+
+func TestParseGoroutineDump(t *testing.T) {
+	dump := parseGoroutineDump([]byte(sampleGoroutineDump))
+	if dump.Total != 5 || len(dump.Groups) != 3 {
+		t.Fatalf("total %d, %d groups: %+v", dump.Total, len(dump.Groups), dump.Groups)
+	}
+
+	g := dump.Groups[0]
+	if g.Count != 3 || g.State != "chan receive" || g.MaxWaitMinutes != 12 {
+		t.Fatalf("largest group: %+v", g)
+	}
+	if len(g.IDs) != 3 || g.IDs[0] != 7 || g.IDs[2] != 11 {
+		t.Fatalf("ids: %v", g.IDs)
+	}
+	if len(g.Stack) != 1 || g.Stack[0] != "bastion/core.(*BaseSession).acceptLoop /src/core/forwarder.go:180" {
+		t.Fatalf("stack: %q", g.Stack)
+	}
+	if g.CreatedBy != "bastion/core.(*TunnelSession).Start /src/core/forwarder.go:145" {
+		t.Fatalf("created by: %q", g.CreatedBy)
+	}
+
+	if g := dump.Groups[1]; g.State != "running" || g.Stack[0] != "main.main /src/main.go:10" {
+		t.Fatalf("running group: %+v", g)
+	}
+	if g := dump.Groups[2]; g.State != "select" || len(g.Stack) != 2 || !strings.HasPrefix(g.Stack[1], "...") {
+		t.Fatalf("elided group: %+v", g)
+	}
+}
+
+func TestDumpGoroutines(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+	for i := 0; i < 3; i++ {
+		go func() { <-block }()
+	}
+
+	dump := DumpGoroutines()
+	for _, g := range dump.Groups {
+		if g.Count >= 3 && strings.Contains(g.CreatedBy, "TestDumpGoroutines") {
+			return
+		}
+	}
+	t.Fatalf("blocked goroutines not grouped: %+v", dump.Groups)
+}
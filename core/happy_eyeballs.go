@@ -0,0 +1,188 @@
+package core
+
+import (
+	"bastion/config"
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/netip"
+	"strconv"
+	"time"
+)
+
+const (
+	// happyEyeballsDelay is how long an address attempt runs before the next one starts alongside
+	// it (RFC 8305 recommends 250ms).
+	happyEyeballsDelay = 250 * time.Millisecond
+	// targetResolveTimeout bounds the local lookup of a remote host name.
+	targetResolveTimeout = 5 * time.Second
+)
+
+// resolvedConn is a connection through the chain to a locally resolved address. SSH channels
+// report a zero remote address, so it carries the address that served the connection.
+type resolvedConn struct {
+	net.Conn
+	raddr *net.TCPAddr
+}
+
+func (c *resolvedConn) RemoteAddr() net.Addr { return c.raddr }
+
+func (c *resolvedConn) CloseWrite() error {
+	if cw, ok := c.Conn.(interface{ CloseWrite() error }); ok {
+		return cw.CloseWrite()
+	}
+	return nil
+}
+
+// dialChain dials remoteAddr through the bastion chain once. With happy eyeballs the host name is
+// resolved here and its addresses are raced through the chain; a name that does not resolve
+// locally (e.g. an internal name known only beyond the chain) is passed to the chain as before.
+func (s *BaseSession) dialChain(remoteAddr string) (net.Conn, error) {
+	dial := func(addr string) (net.Conn, error) {
+		return Pool.Dial(s.dialContext(), s.Bastions, "tcp", addr)
+	}
+	if !s.dialPolicy.HappyEyeballs {
+		return dial(remoteAddr)
+	}
+
+	addrs, err := resolveTargetAddrs(remoteAddr)
+	if err != nil {
+		if config.Settings.LogLevel == "DEBUG" {
+			log.Printf("[Dial] %s does not resolve locally, leaving it to the bastion chain: %v", remoteAddr, err)
+		}
+		return dial(remoteAddr)
+	}
+	if addrs = s.deniedAddrsRemoved(addrs); len(addrs) == 0 {
+		return nil, fmt.Errorf("every address of %s is denied by target_deny", remoteAddr)
+	}
+	conn, err := dialHappyEyeballs(addrs, happyEyeballsDelay, dial)
+	if err == nil && config.Settings.LogLevel == "DEBUG" && len(addrs) > 1 {
+		log.Printf("[Dial] %s served by %s (%d addresses)", remoteAddr, conn.RemoteAddr(), len(addrs))
+	}
+	return conn, err
+}
+
+// deniedAddrsRemoved drops the resolved addresses a target_deny rule matches, so a name cannot lead
+// to a denied address. Allow rules were already checked against the name.
+func (s *BaseSession) deniedAddrsRemoved(addrs []string) []string {
+	if s.targetACL == nil {
+		return addrs
+	}
+	kept := addrs[:0:0]
+	for _, addr := range addrs {
+		host, portStr, _ := net.SplitHostPort(addr)
+		port, _ := strconv.Atoi(portStr)
+		if allowed, rule := s.targetACL.Allows(host, port); !allowed && rule != "" {
+			continue
+		}
+		kept = append(kept, addr)
+	}
+	return kept
+}
+
+// resolveTargetAddrs resolves the host of addr (host:port) to its addresses, ordered for happy
+// eyeballs: IPv6 and IPv4 interleaved, starting with the family the resolver listed first.
+func resolveTargetAddrs(addr string) ([]string, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if ip, err := netip.ParseAddr(host); err == nil {
+		return []string{net.JoinHostPort(ip.String(), port)}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), targetResolveTimeout)
+	defer cancel()
+	ips, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return nil, err
+	}
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("no addresses for %s", host)
+	}
+
+	var first, second []netip.Addr
+	firstIs4 := ips[0].Unmap().Is4()
+	for _, ip := range ips {
+		ip = ip.Unmap()
+		if ip.Is4() == firstIs4 {
+			first = append(first, ip)
+		} else {
+			second = append(second, ip)
+		}
+	}
+	addrs := make([]string, 0, len(ips))
+	for i := 0; i < len(first) || i < len(second); i++ {
+		if i < len(first) {
+			addrs = append(addrs, net.JoinHostPort(first[i].String(), port))
+		}
+		if i < len(second) {
+			addrs = append(addrs, net.JoinHostPort(second[i].String(), port))
+		}
+	}
+	return addrs, nil
+}
+
+// dialHappyEyeballs dials addrs (ip:port) in order, starting the next attempt when the previous one
+// fails or has run for delay without connecting, and returns the first connection established.
+// Connections that complete after the winner are closed. The returned connection reports the address
+// that served it as its remote address.
+func dialHappyEyeballs(addrs []string, delay time.Duration, dial func(addr string) (net.Conn, error)) (net.Conn, error) {
+	type result struct {
+		conn net.Conn
+		addr string
+		err  error
+	}
+	results := make(chan result, len(addrs))
+	next, pending := 0, 0
+	var nextAttempt <-chan time.Time
+	start := func() {
+		addr := addrs[next]
+		next++
+		pending++
+		go func() {
+			conn, err := dial(addr)
+			results <- result{conn: conn, addr: addr, err: err}
+		}()
+		nextAttempt = nil
+		if next < len(addrs) {
+			nextAttempt = time.After(delay)
+		}
+	}
+
+	var firstErr error
+	start()
+	for pending > 0 {
+		select {
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				go func(n int) {
+					for i := 0; i < n; i++ {
+						if late := <-results; late.conn != nil {
+							_ = late.conn.Close()
+						}
+					}
+				}(pending)
+				raddr, err := net.ResolveTCPAddr("tcp", r.addr)
+				if err != nil {
+					return r.conn, nil
+				}
+				return &resolvedConn{Conn: r.conn, raddr: raddr}, nil
+			}
+			if firstErr == nil {
+				firstErr = r.err
+			}
+			if next < len(addrs) {
+				start()
+			}
+		case <-nextAttempt:
+			start()
+		}
+	}
+	if len(addrs) == 1 {
+		return nil, firstErr
+	}
+	return nil, fmt.Errorf("all %d addresses failed: %w", len(addrs), firstErr)
+}
@@ -0,0 +1,61 @@
+package core
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestDialHappyEyeballs_StaggersAndPicksFirstConnected(t *testing.T) {
+	release := make(chan struct{})
+	dial := func(addr string) (net.Conn, error) {
+		c1, c2 := net.Pipe()
+		go func() { _ = c2.Close() }()
+		if addr == "[2001:db8::1]:443" {
+			<-release // a black-holed address
+		}
+		return c1, nil
+	}
+
+	start := time.Now()
+	conn, err := dialHappyEyeballs([]string{"[2001:db8::1]:443", "192.0.2.1:443"}, 20*time.Millisecond, dial)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("second address was not tried in parallel: %s", elapsed)
+	}
+	if got := conn.RemoteAddr().String(); got != "192.0.2.1:443" {
+		t.Fatalf("served by %s, want 192.0.2.1:443", got)
+	}
+	close(release)
+}
+
+func TestDialHappyEyeballs_FailsOverImmediately(t *testing.T) {
+	var attempts []string
+	dial := func(addr string) (net.Conn, error) {
+		attempts = append(attempts, addr)
+		return nil, errors.New("connection refused")
+	}
+
+	start := time.Now()
+	_, err := dialHappyEyeballs([]string{"192.0.2.1:80", "192.0.2.2:80", "192.0.2.3:80"}, time.Minute, dial)
+	if err == nil {
+		t.Fatalf("expected every address to fail")
+	}
+	if len(attempts) != 3 || time.Since(start) > time.Second {
+		t.Fatalf("attempts %v took %s; failures should start the next address right away", attempts, time.Since(start))
+	}
+}
+
+func TestResolveTargetAddrs_Literal(t *testing.T) {
+	addrs, err := resolveTargetAddrs("[::ffff:10.0.0.1]:22")
+	if err != nil || len(addrs) != 1 || addrs[0] != "[::ffff:10.0.0.1]:22" {
+		t.Fatalf("addrs = %v, err = %v", addrs, err)
+	}
+	if _, err := resolveTargetAddrs("no-port"); err == nil {
+		t.Fatalf("expected an address without port to be rejected")
+	}
+}
@@ -0,0 +1,94 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+// HeaderRewriter changes the headers of the plain HTTP requests an http/mixed mapping forwards
+// (CONNECT tunnels are opaque). A rule is "Name: value", which sets the header and replaces any
+// value the client sent, or "-Name", which strips it. Rules apply in order.
+type HeaderRewriter struct {
+	rules []headerRule
+}
+
+type headerRule struct {
+	name   string // canonical
+	value  string
+	remove bool
+}
+
+// Headers net/http writes itself from the request: rewriting them would not reach the upstream
+// or would break the message framing.
+var reservedRewriteHeaders = map[string]bool{
+	"Host":              true,
+	"Content-Length":    true,
+	"Transfer-Encoding": true,
+	"Connection":        true,
+	"Trailer":           true,
+}
+
+// NewHeaderRewriter parses the request_headers rules of a mapping. It returns nil when there are none.
+func NewHeaderRewriter(rules []string) (*HeaderRewriter, error) {
+	rw := &HeaderRewriter{}
+	for _, raw := range rules {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		var rule headerRule
+		if name, ok := strings.CutPrefix(raw, "-"); ok {
+			rule = headerRule{name: strings.TrimSpace(name), remove: true}
+		} else {
+			name, value, ok := strings.Cut(raw, ":")
+			if !ok {
+				return nil, fmt.Errorf("invalid request header rule %q: use \"Name: value\" or \"-Name\"", raw)
+			}
+			rule = headerRule{name: strings.TrimSpace(name), value: strings.TrimSpace(value)}
+			if !httpguts.ValidHeaderFieldValue(rule.value) {
+				return nil, fmt.Errorf("invalid request header rule %q: invalid value", raw)
+			}
+		}
+		if !httpguts.ValidHeaderFieldName(rule.name) {
+			return nil, fmt.Errorf("invalid request header rule %q: invalid header name", raw)
+		}
+		rule.name = http.CanonicalHeaderKey(rule.name)
+		if reservedRewriteHeaders[rule.name] {
+			return nil, fmt.Errorf("invalid request header rule %q: %s cannot be rewritten", raw, rule.name)
+		}
+		rw.rules = append(rw.rules, rule)
+	}
+	if len(rw.rules) == 0 {
+		return nil, nil
+	}
+	return rw, nil
+}
+
+// Apply rewrites header and returns what changed, e.g. "set X-Env", "override X-Env" (the client
+// sent another value) or "remove X-Tracking-Id". Rules that change nothing are left out.
+func (rw *HeaderRewriter) Apply(header http.Header) []string {
+	if rw == nil {
+		return nil
+	}
+	var changes []string
+	for _, rule := range rw.rules {
+		current, present := header[rule.name]
+		switch {
+		case rule.remove:
+			if present {
+				header.Del(rule.name)
+				changes = append(changes, "remove "+rule.name)
+			}
+		case !present:
+			header.Set(rule.name, rule.value)
+			changes = append(changes, "set "+rule.name)
+		case len(current) != 1 || current[0] != rule.value:
+			header.Set(rule.name, rule.value)
+			changes = append(changes, "override "+rule.name)
+		}
+	}
+	return changes
+}
@@ -0,0 +1,113 @@
+package core
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"bastion/config"
+	"bastion/models"
+)
+
+func TestNewHeaderRewriter(t *testing.T) {
+	if rw, err := NewHeaderRewriter([]string{"", "  "}); rw != nil || err != nil {
+		t.Fatalf("empty rules = %v, %v; want nil, nil", rw, err)
+	}
+	for _, rule := range []string{"X-Env", "Bad Name: x", "-", "X-Env: a\nb", "host: example.com", "-Content-Length", "transfer-encoding: chunked"} {
+		if _, err := NewHeaderRewriter([]string{rule}); err == nil {
+			t.Errorf("rule %q accepted", rule)
+		}
+	}
+	rw, err := NewHeaderRewriter([]string{"x-env: staging", "- X-Tracking-Id", "X-Empty:"})
+	if err != nil {
+		t.Fatalf("NewHeaderRewriter: %v", err)
+	}
+	want := []headerRule{{name: "X-Env", value: "staging"}, {name: "X-Tracking-Id", remove: true}, {name: "X-Empty"}}
+	if !reflect.DeepEqual(rw.rules, want) {
+		t.Fatalf("rules = %+v, want %+v", rw.rules, want)
+	}
+}
+
+func TestHeaderRewriterApply(t *testing.T) {
+	rw, err := NewHeaderRewriter([]string{"X-Env: staging", "X-Team: core", "-X-Tracking-Id", "-X-Absent"})
+	if err != nil {
+		t.Fatalf("NewHeaderRewriter: %v", err)
+	}
+	header := http.Header{}
+	header.Set("X-Team", "core")
+	header.Add("X-Env", "prod")
+	header.Add("X-Env", "dev")
+	header.Set("X-Tracking-Id", "abc")
+
+	changes := rw.Apply(header)
+	if want := []string{"override X-Env", "remove X-Tracking-Id"}; !reflect.DeepEqual(changes, want) {
+		t.Fatalf("changes = %v, want %v", changes, want)
+	}
+	if got := header.Values("X-Env"); !reflect.DeepEqual(got, []string{"staging"}) {
+		t.Fatalf("X-Env = %v", got)
+	}
+	if header.Get("X-Tracking-Id") != "" || header.Get("X-Team") != "core" {
+		t.Fatalf("header = %v", header)
+	}
+
+	if changes := rw.Apply(http.Header{}); !reflect.DeepEqual(changes, []string{"set X-Env", "set X-Team"}) {
+		t.Fatalf("changes = %v", changes)
+	}
+	var none *HeaderRewriter
+	if changes := none.Apply(header); changes != nil {
+		t.Fatalf("nil rewriter changed %v", changes)
+	}
+}
+
+func TestHTTPProxy_RewritesRequestHeaders(t *testing.T) {
+	prevAudit, prevLogLevel := config.Settings.AuditEnabled, config.Settings.LogLevel
+	t.Cleanup(func() {
+		config.Settings.AuditEnabled, config.Settings.LogLevel = prevAudit, prevLogLevel
+	})
+	config.Settings.AuditEnabled = false
+	config.Settings.LogLevel = "ERROR"
+
+	received := make(chan http.Header, 1)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Clone()
+	}))
+	t.Cleanup(backend.Close)
+
+	mapping := &models.Mapping{ID: "test-http-proxy-headers", LocalHost: "127.0.0.1", Type: "http"}
+	mapping.SetRequestHeaders([]string{"X-Env: staging", "-X-Tracking-Id"})
+	session := NewHTTPProxySession(mapping, nil)
+	if err := session.Start(); err != nil {
+		t.Fatalf("Start HTTP proxy session: %v", err)
+	}
+	t.Cleanup(session.Stop)
+
+	proxyURL, _ := url.Parse("http://" + session.listener.Addr().String())
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
+	req, _ := http.NewRequest(http.MethodGet, backend.URL+"/", nil)
+	req.Header.Set("X-Tracking-Id", "abc")
+	req.Header.Set("X-Env", "prod")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("request via proxy: %v", err)
+	}
+	resp.Body.Close()
+
+	header := <-received
+	if header.Get("X-Env") != "staging" || header.Get("X-Tracking-Id") != "" {
+		t.Fatalf("upstream headers = %v", header)
+	}
+}
+
+func TestHTTPPairMatcher_RecordsHeaderRewrites(t *testing.T) {
+	m, logs := newTestPairMatcher(t, HTTPPairStrategySequence, 0)
+	ctx := AuditContext{MappingID: "m", HeaderRewrites: []string{"set X-Env"}}
+
+	m.AddRequest(ctx, "c", pairReq("/", 1))
+	m.MatchResponse(AuditContext{MappingID: "m"}, "c", pairResp("200 OK", 1))
+	if len(*logs) != 1 || strings.Join((*logs)[0].HeaderRewrites, ",") != "set X-Env" {
+		t.Fatalf("logs = %+v", *logs)
+	}
+}
@@ -3,12 +3,21 @@ package core
 import (
 	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 const (
 	minForwardBufferSize = 4 * 1024
-	midForwardBufferSize = 16 * 1024
-	maxPooledBufferSize  = 64 * 1024
+	midForwardBufferSize = 32 * 1024
+	maxPooledBufferSize  = 256 * 1024
+)
+
+// Adaptive sizing: a connection's buffer grows one size class after this many consecutive full reads,
+// and drops back to the initial class when a read had to wait at least forwardBufferShrinkIdle.
+const (
+	forwardBufferGrowAfterFullReads = 3
+	forwardBufferShrinkIdle         = 5 * time.Second
 )
 
 // HierarchicalBufferPool provides reusable buffers with multiple size classes.
@@ -16,56 +25,73 @@ const (
 type HierarchicalBufferPool struct {
 	classes []int
 	pools   map[int]*sync.Pool
+
+	inUse      int64
+	inUseBytes int64
+	gets       int64
+	allocs     int64
+	grows      int64
+	shrinks    int64
+}
+
+// BufferPoolStats is a snapshot of forwarding buffer usage.
+type BufferPoolStats struct {
+	Classes    []int `json:"classes"`
+	InUse      int64 `json:"in_use"`       // buffers currently checked out
+	InUseBytes int64 `json:"in_use_bytes"` // bytes held by checked-out buffers
+	Gets       int64 `json:"gets_total"`
+	Allocs     int64 `json:"allocs_total"` // gets that had to allocate a new buffer
+	Grows      int64 `json:"grows_total"`
+	Shrinks    int64 `json:"shrinks_total"`
 }
 
 func NewHierarchicalBufferPool(maxSize int) *HierarchicalBufferPool {
 	maxSize = normalizeForwardBufferSize(maxSize)
 
+	// Tiers: 4K / 32K / 256K, capped at maxSize.
 	classes := []int{minForwardBufferSize}
-	if maxSize >= midForwardBufferSize {
-		classes = append(classes, midForwardBufferSize)
-	}
-	pooledMax := maxSize
-	if pooledMax > maxPooledBufferSize {
-		pooledMax = maxPooledBufferSize
-	}
-	if pooledMax > midForwardBufferSize {
-		classes = append(classes, pooledMax)
-	}
-	if maxSize > pooledMax {
-		// Allow growth beyond pooled sizes, but do not pool these oversized buffers.
-		classes = append(classes, maxSize)
+	for _, tier := range []int{midForwardBufferSize, maxPooledBufferSize} {
+		if tier <= maxSize {
+			classes = append(classes, tier)
+		}
 	}
+	// A max size between tiers (or beyond the largest pooled tier) becomes the top class.
+	// Buffers larger than maxPooledBufferSize are allowed but never pooled.
+	classes = append(classes, maxSize)
 	classes = uniqueSortedInts(classes)
 
-	pools := make(map[int]*sync.Pool, len(classes))
+	p := &HierarchicalBufferPool{
+		classes: classes,
+		pools:   make(map[int]*sync.Pool, len(classes)),
+	}
 	for _, size := range classes {
 		if size > maxPooledBufferSize {
 			continue
 		}
 		sz := size
-		pools[sz] = &sync.Pool{
+		p.pools[sz] = &sync.Pool{
 			New: func() any {
+				atomic.AddInt64(&p.allocs, 1)
 				b := make([]byte, sz)
 				return &b
 			},
 		}
 	}
-
-	return &HierarchicalBufferPool{
-		classes: classes,
-		pools:   pools,
-	}
+	return p
 }
 
 func (p *HierarchicalBufferPool) Get(size int) *[]byte {
 	size = normalizeForwardBufferSize(size)
+	atomic.AddInt64(&p.gets, 1)
+	atomic.AddInt64(&p.inUse, 1)
+	atomic.AddInt64(&p.inUseBytes, int64(size))
 	if cls, ok := p.pools[size]; ok {
 		v := cls.Get()
 		if b, ok := v.(*[]byte); ok && b != nil {
 			return b
 		}
 	}
+	atomic.AddInt64(&p.allocs, 1)
 	b := make([]byte, size)
 	return &b
 }
@@ -75,6 +101,8 @@ func (p *HierarchicalBufferPool) Put(buf *[]byte) {
 		return
 	}
 	sz := cap(*buf)
+	atomic.AddInt64(&p.inUse, -1)
+	atomic.AddInt64(&p.inUseBytes, -int64(len(*buf)))
 	if sz <= 0 || sz > maxPooledBufferSize {
 		return
 	}
@@ -97,6 +125,96 @@ func (p *HierarchicalBufferPool) NextSize(cur int) (int, bool) {
 	return cur, false
 }
 
+// Stats returns a snapshot of the pool counters.
+func (p *HierarchicalBufferPool) Stats() BufferPoolStats {
+	return BufferPoolStats{
+		Classes:    append([]int(nil), p.classes...),
+		InUse:      atomic.LoadInt64(&p.inUse),
+		InUseBytes: atomic.LoadInt64(&p.inUseBytes),
+		Gets:       atomic.LoadInt64(&p.gets),
+		Allocs:     atomic.LoadInt64(&p.allocs),
+		Grows:      atomic.LoadInt64(&p.grows),
+		Shrinks:    atomic.LoadInt64(&p.shrinks),
+	}
+}
+
+// ForwardBufferStats reports usage of the shared forwarding buffer pool.
+func ForwardBufferStats() BufferPoolStats {
+	return getForwardBufferPool().Stats()
+}
+
+// adaptiveBuffer is a per-connection forwarding buffer that moves between the pool's size classes:
+// it grows while reads keep filling it and falls back to the smallest class after the stream idles.
+type adaptiveBuffer struct {
+	pool      *HierarchicalBufferPool
+	ptr       *[]byte
+	fullReads int
+	held      *int64 // optional counter of the bytes held, e.g. a session's buffer bytes
+}
+
+func newAdaptiveBuffer(pool *HierarchicalBufferPool) *adaptiveBuffer {
+	return &adaptiveBuffer{pool: pool, ptr: pool.Get(pool.InitialSize())}
+}
+
+// trackHeld makes the buffer keep *held up to date with its size until it is released.
+func (b *adaptiveBuffer) trackHeld(held *int64) *adaptiveBuffer {
+	b.held = held
+	if b.ptr != nil {
+		atomic.AddInt64(held, int64(len(*b.ptr)))
+	}
+	return b
+}
+
+// Bytes returns the current buffer.
+func (b *adaptiveBuffer) Bytes() []byte {
+	return *b.ptr
+}
+
+// Observe records a read of n bytes that waited for waited, resizing the buffer for the next read.
+func (b *adaptiveBuffer) Observe(n int, waited time.Duration) {
+	size := len(*b.ptr)
+
+	if waited >= forwardBufferShrinkIdle && size > b.pool.InitialSize() && n < size {
+		b.swap(b.pool.InitialSize())
+		atomic.AddInt64(&b.pool.shrinks, 1)
+		return
+	}
+
+	if n < size {
+		b.fullReads = 0
+		return
+	}
+	b.fullReads++
+	if b.fullReads < forwardBufferGrowAfterFullReads {
+		return
+	}
+	if next, ok := b.pool.NextSize(size); ok && next > size {
+		b.swap(next)
+		atomic.AddInt64(&b.pool.grows, 1)
+	}
+}
+
+func (b *adaptiveBuffer) swap(size int) {
+	old := len(*b.ptr)
+	b.pool.Put(b.ptr)
+	b.ptr = b.pool.Get(size)
+	b.fullReads = 0
+	if b.held != nil {
+		atomic.AddInt64(b.held, int64(len(*b.ptr)-old))
+	}
+}
+
+// Release returns the buffer to the pool.
+func (b *adaptiveBuffer) Release() {
+	if b.ptr != nil {
+		if b.held != nil {
+			atomic.AddInt64(b.held, -int64(len(*b.ptr)))
+		}
+		b.pool.Put(b.ptr)
+		b.ptr = nil
+	}
+}
+
 func normalizeForwardBufferSize(size int) int {
 	if size <= 0 {
 		return midForwardBufferSize
@@ -21,6 +21,71 @@ func TestHierarchicalBufferPoolClasses(t *testing.T) {
 	}
 }
 
+func TestHierarchicalBufferPoolTiers(t *testing.T) {
+	t.Parallel()
+
+	p := NewHierarchicalBufferPool(256 * 1024)
+	want := []int{4 * 1024, 32 * 1024, 256 * 1024}
+	if len(p.classes) != len(want) {
+		t.Fatalf("unexpected classes: %v", p.classes)
+	}
+	for i := range want {
+		if p.classes[i] != want[i] {
+			t.Fatalf("unexpected classes: %v", p.classes)
+		}
+	}
+
+	// A max size between tiers becomes the top class.
+	p = NewHierarchicalBufferPool(64 * 1024)
+	if top := p.classes[len(p.classes)-1]; top != 64*1024 {
+		t.Fatalf("expected top class 64K, got %v", p.classes)
+	}
+}
+
+func TestAdaptiveBufferGrowsAndShrinks(t *testing.T) {
+	t.Parallel()
+
+	p := NewHierarchicalBufferPool(256 * 1024)
+	b := newAdaptiveBuffer(p)
+
+	// A single full read is not enough to grow.
+	b.Observe(len(b.Bytes()), 0)
+	if len(b.Bytes()) != 4*1024 {
+		t.Fatalf("grew too early: %d", len(b.Bytes()))
+	}
+
+	for _, want := range []int{32 * 1024, 256 * 1024} {
+		for i := 0; i < forwardBufferGrowAfterFullReads; i++ {
+			b.Observe(len(b.Bytes()), 0)
+		}
+		if len(b.Bytes()) != want {
+			t.Fatalf("expected %d after consistent full reads, got %d", want, len(b.Bytes()))
+		}
+	}
+
+	// A partial read resets the streak.
+	b.Observe(10, 0)
+	if b.fullReads != 0 {
+		t.Fatalf("expected streak reset, got %d", b.fullReads)
+	}
+
+	b.Observe(10, forwardBufferShrinkIdle)
+	if len(b.Bytes()) != 4*1024 {
+		t.Fatalf("expected shrink after idling, got %d", len(b.Bytes()))
+	}
+
+	stats := p.Stats()
+	if stats.Grows != 2 || stats.Shrinks != 1 || stats.InUse != 1 || stats.InUseBytes != 4*1024 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+
+	b.Release()
+	b.Release()
+	if stats := p.Stats(); stats.InUse != 0 || stats.InUseBytes != 0 {
+		t.Fatalf("expected buffer to be returned, got %+v", stats)
+	}
+}
+
 func BenchmarkForwardingBufferPoolMixed(b *testing.B) {
 	pool := NewHierarchicalBufferPool(32 * 1024)
 	sizes := []int{4 * 1024, 16 * 1024, 32 * 1024}
@@ -0,0 +1,84 @@
+package core
+
+import (
+	"bytes"
+	"strings"
+)
+
+// Reasons a response body was left out of an HTTP log (HTTPLog.ResponseBodyOmitted).
+const (
+	BodyOmittedType = "type" // Content-Type matched AUDIT_SKIP_BODY_TYPES
+	BodyOmittedSize = "size" // body larger than AUDIT_SKIP_BODY_BYTES
+)
+
+// httpBodySkipRules decides which response bodies are not worth keeping in HTTP logs: images,
+// video, fonts and other large binaries take audit memory without helping anyone read the log.
+type httpBodySkipRules struct {
+	prefixes []string // "image/" for an "image/*" pattern
+	types    []string // exact media types
+	maxBytes int      // 0 = no size limit
+}
+
+// newHTTPBodySkipRules parses a comma-separated list of media types ("image/*" matches every
+// image type; "off" for none) and a body size limit. It returns nil when neither skips anything.
+func newHTTPBodySkipRules(types string, maxBytes int) *httpBodySkipRules {
+	rules := &httpBodySkipRules{maxBytes: max(maxBytes, 0)}
+	if strings.EqualFold(strings.TrimSpace(types), "off") {
+		types = ""
+	}
+	for _, t := range splitAlertList(types) {
+		t = strings.ToLower(t)
+		if prefix, ok := strings.CutSuffix(t, "/*"); ok {
+			rules.prefixes = append(rules.prefixes, prefix+"/")
+		} else {
+			rules.types = append(rules.types, t)
+		}
+	}
+	if len(rules.prefixes) == 0 && len(rules.types) == 0 && rules.maxBytes == 0 {
+		return nil
+	}
+	return rules
+}
+
+// match returns why the body of a response with these headers is skipped (BodyOmittedType or
+// BodyOmittedSize), or "" to keep it.
+func (r *httpBodySkipRules) match(headers, body []byte) string {
+	if r == nil || len(body) == 0 {
+		return ""
+	}
+	if mediaType := httpHeaderMediaType(headers); mediaType != "" {
+		for _, prefix := range r.prefixes {
+			if strings.HasPrefix(mediaType, prefix) {
+				return BodyOmittedType
+			}
+		}
+		for _, t := range r.types {
+			if mediaType == t {
+				return BodyOmittedType
+			}
+		}
+	}
+	if r.maxBytes > 0 && len(body) > r.maxBytes {
+		return BodyOmittedSize
+	}
+	return ""
+}
+
+// httpHeaderMediaType returns the lower-cased media type of the Content-Type header, without
+// parameters.
+func httpHeaderMediaType(headers []byte) string {
+	for _, line := range bytes.Split(headers, []byte("\r\n")) {
+		name, value, ok := bytes.Cut(line, []byte(":"))
+		if !ok || !bytes.EqualFold(bytes.TrimSpace(name), []byte("content-type")) {
+			continue
+		}
+		value, _, _ = bytes.Cut(value, []byte(";"))
+		return strings.ToLower(string(bytes.TrimSpace(value)))
+	}
+	return ""
+}
+
+// omitBody returns the header block of an HTTP message with an empty body.
+func omitBody(headers []byte) string {
+	return string(headers) + "\r\n\r\n"
+}
@@ -30,6 +30,8 @@ const (
 type HTTPLogPartOptions struct {
 	// DecodeGzip enables on-demand gzip decoding for response bodies only.
 	DecodeGzip bool
+	// Format pretty-prints body parts (json, xml or auto); header parts ignore it.
+	Format HTTPLogBodyFormat
 }
 
 // HTTPLogPartResult is the response payload for a single log part.
@@ -37,6 +39,13 @@ type HTTPLogPartResult struct {
 	Data            string `json:"data"`
 	Truncated       bool   `json:"truncated"`
 	TruncatedReason string `json:"truncated_reason,omitempty"`
+
+	// Body parts only.
+	ContentType string `json:"content_type,omitempty"` // media type from the message's Content-Type
+	Charset     string `json:"charset,omitempty"`      // source charset when Data was transcoded to UTF-8
+	Binary      bool   `json:"binary,omitempty"`       // Data holds non-text content, base64-encoded
+	Encoding    string `json:"encoding,omitempty"`     // "base64" when Binary
+	Format      string `json:"format,omitempty"`       // pretty-printing applied to Data (json or xml)
 }
 
 var (
@@ -109,16 +118,27 @@ func (a *Auditor) GetHTTPLogPart(id int, part HTTPLogPart, opts HTTPLogPartOptio
 		headers, _, _ := splitHTTPMessage([]byte(log.Request))
 		return &HTTPLogPartResult{Data: string(headers)}, nil
 	case HTTPLogPartRequestBody:
-		_, body, ok := splitHTTPMessage([]byte(log.Request))
+		headers, body, ok := splitHTTPMessage([]byte(log.Request))
 		if !ok {
 			return &HTTPLogPartResult{Data: ""}, nil
 		}
-		return &HTTPLogPartResult{Data: string(body)}, nil
+		result := &HTTPLogPartResult{Data: string(body)}
+		formatHTTPLogBody(result, headers, opts.Format, false)
+		return result, nil
 	case HTTPLogPartResponseHeader:
 		headers, _, _ := splitHTTPMessage([]byte(log.Response))
 		return &HTTPLogPartResult{Data: string(headers)}, nil
 	case HTTPLogPartResponseBody:
-		return a.getHTTPLogResponseBody(id, []byte(log.Response), opts)
+		if log.ResponseBodyOmitted != "" {
+			return &HTTPLogPartResult{Data: "", Truncated: true, TruncatedReason: "omitted_" + log.ResponseBodyOmitted}, nil
+		}
+		result, err := a.getHTTPLogResponseBody(id, []byte(log.Response), opts)
+		if err != nil {
+			return nil, err
+		}
+		headers, _, _ := splitHTTPMessage([]byte(log.Response))
+		formatHTTPLogBody(result, headers, opts.Format, opts.DecodeGzip)
+		return result, nil
 	default:
 		return nil, ErrInvalidHTTPLogPart
 	}
@@ -0,0 +1,132 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"bastion/models"
+)
+
+// ErrHTTPLogRequestInvalid indicates the stored request cannot be parsed as HTTP.
+var ErrHTTPLogRequestInvalid = errors.New("stored request cannot be parsed")
+
+// HTTPLogCurl is a curl command reproducing a logged request.
+type HTTPLogCurl struct {
+	Command string `json:"command"`
+	// BodyTruncated reports that the stored body is shorter than its Content-Length (or chunked framing).
+	BodyTruncated bool `json:"body_truncated"`
+}
+
+// curlSkippedHeaders are computed by curl itself or do not apply once the body is decoded.
+var curlSkippedHeaders = map[string]bool{
+	"Content-Length":    true,
+	"Transfer-Encoding": true,
+	"Connection":        true,
+	"Proxy-Connection":  true,
+}
+
+// RenderHTTPLogCurl renders the request of httpLog as a curl command that goes through mapping's local
+// endpoint: proxy mappings become --proxy, tunnel mappings are addressed directly with the original
+// Host header. A nil mapping (deleted since the request was logged) targets the original host.
+func RenderHTTPLogCurl(httpLog *HTTPLog, mapping *models.Mapping) (*HTTPLogCurl, error) {
+	req, err := http.ReadRequest(bufio.NewReader(strings.NewReader(httpLog.Request)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrHTTPLogRequestInvalid, err)
+	}
+	body, readErr := io.ReadAll(req.Body)
+	result := &HTTPLogCurl{BodyTruncated: readErr != nil}
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	target := "http://" + host + req.URL.RequestURI()
+
+	// Each element is one option with its value; they are joined one per line.
+	args := []string{"curl"}
+	keepHost := false
+	if mapping != nil {
+		local := curlLocalEndpoint(mapping)
+		switch mapping.Type {
+		case "http", "mixed":
+			args = append(args, "--proxy "+shellQuote("http://"+local))
+		case "socks5":
+			args = append(args, "--proxy "+shellQuote("socks5h://"+local))
+		default:
+			// Tunnel: curl connects to the local port; the server still sees the original Host.
+			target = "http://" + local + req.URL.RequestURI()
+			keepHost = true
+		}
+	}
+
+	if req.Method != http.MethodGet && !(req.Method == http.MethodPost && len(body) > 0) {
+		args = append(args, "-X "+shellQuote(req.Method))
+	}
+
+	if keepHost && host != "" {
+		args = append(args, "-H "+shellQuote("Host: "+host))
+	}
+
+	names := make([]string, 0, len(req.Header))
+	for name := range req.Header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if curlSkippedHeaders[name] {
+			continue
+		}
+		if name == "Accept-Encoding" && strings.Contains(strings.ToLower(req.Header.Get(name)), "gzip") {
+			// Let curl negotiate and decode the compressed response.
+			args = append(args, "--compressed")
+			continue
+		}
+		for _, value := range req.Header[name] {
+			args = append(args, "-H "+shellQuote(name+": "+value))
+		}
+	}
+
+	prefix := ""
+	if len(body) > 0 {
+		// A leading '@' would make curl read a file, so such bodies take the piped path too.
+		if utf8.Valid(body) && bytes.IndexByte(body, 0) < 0 && body[0] != '@' {
+			args = append(args, "--data-binary "+shellQuote(string(body)))
+		} else {
+			// Binary (e.g. gzip-encoded) bodies are piped in through base64 so the command stays printable.
+			prefix = "printf %s " + shellQuote(base64.StdEncoding.EncodeToString(body)) + " | base64 -d | "
+			args = append(args, "--data-binary @-")
+		}
+	}
+
+	args = append(args, shellQuote(target))
+	result.Command = prefix + strings.Join(args, " \\\n  ")
+	return result, nil
+}
+
+func curlLocalEndpoint(mapping *models.Mapping) string {
+	host := mapping.ListenHost()
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "127.0.0.1"
+	}
+	return net.JoinHostPort(host, strconv.Itoa(mapping.LocalPort))
+}
+
+// shellQuote quotes s for POSIX shells.
+func shellQuote(s string) string {
+	if s != "" && strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./:@=,+", r))
+	}) < 0 {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
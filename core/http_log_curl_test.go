@@ -0,0 +1,46 @@
+package core
+
+import (
+	"bastion/models"
+	"strings"
+	"testing"
+)
+
+func TestRenderHTTPLogCurl_ProxyMapping(t *testing.T) {
+	httpLog := &HTTPLog{Request: "POST /api/items?x=1 HTTP/1.1\r\nHost: svc.internal:8080\r\nX-Note: it's\r\n" +
+		"Accept-Encoding: gzip\r\nTransfer-Encoding: chunked\r\n\r\n5\r\nhello\r\n0\r\n\r\n"}
+	mapping := &models.Mapping{Type: "http", LocalHost: "0.0.0.0", LocalPort: 8888}
+
+	res, err := RenderHTTPLogCurl(httpLog, mapping)
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	want := "curl \\\n  --proxy http://127.0.0.1:8888 \\\n  --compressed \\\n  -H 'X-Note: it'\\''s' \\\n" +
+		"  --data-binary hello \\\n  'http://svc.internal:8080/api/items?x=1'"
+	if res.Command != want || res.BodyTruncated {
+		t.Fatalf("unexpected command:\n%s\nwant:\n%s", res.Command, want)
+	}
+}
+
+func TestRenderHTTPLogCurl_TunnelAndBinaryBody(t *testing.T) {
+	httpLog := &HTTPLog{Request: "PUT /blob HTTP/1.1\r\nHost: files\r\nContent-Length: 4\r\n\r\n\x1f\x8b\x00\x01"}
+	mapping := &models.Mapping{Type: "tcp", LocalHost: "127.0.0.1", LocalPort: 9000}
+
+	res, err := RenderHTTPLogCurl(httpLog, mapping)
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	if !strings.HasPrefix(res.Command, "printf %s H4sAAQ== | base64 -d | curl") {
+		t.Fatalf("expected base64 pipeline, got:\n%s", res.Command)
+	}
+	for _, part := range []string{"-X PUT", "-H 'Host: files'", "--data-binary @-", "http://127.0.0.1:9000/blob"} {
+		if !strings.Contains(res.Command, part) {
+			t.Fatalf("missing %q in:\n%s", part, res.Command)
+		}
+	}
+
+	truncated := &HTTPLog{Request: "POST / HTTP/1.1\r\nHost: x\r\nContent-Length: 10\r\n\r\nabc"}
+	if res, err := RenderHTTPLogCurl(truncated, nil); err != nil || !res.BodyTruncated {
+		t.Fatalf("expected truncated body to be flagged: %+v %v", res, err)
+	}
+}
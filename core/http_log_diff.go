@@ -0,0 +1,444 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"bastion/config"
+)
+
+const (
+	// httpDiffContextLines is the number of unchanged lines shown around each hunk.
+	httpDiffContextLines = 3
+	// httpDiffMaxEdits bounds the diff search; bodies that differ in more lines are only summarized.
+	httpDiffMaxEdits = 1000
+)
+
+// HTTPLogDiff is a structured comparison of two logged HTTP exchanges.
+type HTTPLogDiff struct {
+	A               HTTPLogDiffSide    `json:"a"`
+	B               HTTPLogDiffSide    `json:"b"`
+	Fields          []HTTPLogFieldDiff `json:"fields"`
+	RequestHeaders  []HTTPHeaderDiff   `json:"request_headers"`
+	ResponseHeaders []HTTPHeaderDiff   `json:"response_headers"`
+	RequestBody     HTTPBodyDiff       `json:"request_body"`
+	ResponseBody    HTTPBodyDiff       `json:"response_body"`
+}
+
+// HTTPLogDiffSide identifies one of the compared exchanges.
+type HTTPLogDiffSide struct {
+	ID         int       `json:"id"`
+	Timestamp  time.Time `json:"timestamp"`
+	MappingID  string    `json:"mapping_id"`
+	LocalPort  int       `json:"local_port"`
+	Method     string    `json:"method"`
+	URL        string    `json:"url"`
+	Host       string    `json:"host"`
+	StatusCode int       `json:"status_code"`
+	DurationMs int64     `json:"duration_ms"`
+}
+
+// HTTPLogFieldDiff is a differing summary field (method, url, host, status_code, ...).
+type HTTPLogFieldDiff struct {
+	Field string `json:"field"`
+	A     string `json:"a"`
+	B     string `json:"b"`
+}
+
+// HTTPHeaderDiff describes a header that is only present on one side or has different values.
+// Header names are compared case-insensitively; repeated headers are joined with ", ".
+type HTTPHeaderDiff struct {
+	Name   string `json:"name"`
+	Change string `json:"change"` // added, removed or changed (from A to B)
+	A      string `json:"a,omitempty"`
+	B      string `json:"b,omitempty"`
+}
+
+// HTTPBodyDiff compares two message bodies.
+type HTTPBodyDiff struct {
+	Equal           bool   `json:"equal"`
+	ASize           int    `json:"a_size"`
+	BSize           int    `json:"b_size"`
+	Binary          bool   `json:"binary,omitempty"`
+	Diff            string `json:"diff,omitempty"` // unified diff of the (possibly truncated) bodies
+	Truncated       bool   `json:"truncated"`
+	TruncatedReason string `json:"truncated_reason,omitempty"`
+}
+
+// CompareHTTPLogs returns a structured diff of two stored HTTP logs.
+func (a *Auditor) CompareHTTPLogs(idA, idB int) (*HTTPLogDiff, error) {
+	a.httpMu.RLock()
+	logA := a.httpLogsMap[idA]
+	logB := a.httpLogsMap[idB]
+	a.httpMu.RUnlock()
+
+	if logA == nil || logB == nil {
+		return nil, ErrHTTPLogNotFound
+	}
+	return diffHTTPLogs(logA, logB), nil
+}
+
+func diffHTTPLogs(a, b *HTTPLog) *HTTPLogDiff {
+	maxBytes := config.Settings.HTTPCompareMaxBodyBytes
+	if maxBytes <= 0 {
+		maxBytes = 65536
+	}
+
+	reqHeadersA, reqBodyA := httpLogRequestBody(a)
+	reqHeadersB, reqBodyB := httpLogRequestBody(b)
+	respHeadersA, respBodyA, respReasonA := httpLogResponseBody(a, maxBytes)
+	respHeadersB, respBodyB, respReasonB := httpLogResponseBody(b, maxBytes)
+
+	label := func(l *HTTPLog, part string) string { return fmt.Sprintf("#%d %s", l.ID, part) }
+
+	respDiff := diffHTTPBodies(respBodyA, respBodyB, label(a, "response body"), label(b, "response body"), maxBytes)
+	if !respDiff.Truncated {
+		if respReasonA != "" {
+			respDiff.Truncated, respDiff.TruncatedReason = true, respReasonA
+		} else if respReasonB != "" {
+			respDiff.Truncated, respDiff.TruncatedReason = true, respReasonB
+		}
+	}
+
+	return &HTTPLogDiff{
+		A:               httpLogDiffSide(a),
+		B:               httpLogDiffSide(b),
+		Fields:          diffHTTPLogFields(a, b),
+		RequestHeaders:  diffHTTPHeaders(reqHeadersA, reqHeadersB),
+		ResponseHeaders: diffHTTPHeaders(respHeadersA, respHeadersB),
+		RequestBody:     diffHTTPBodies(reqBodyA, reqBodyB, label(a, "request body"), label(b, "request body"), maxBytes),
+		ResponseBody:    respDiff,
+	}
+}
+
+func httpLogDiffSide(l *HTTPLog) HTTPLogDiffSide {
+	return HTTPLogDiffSide{
+		ID:         l.ID,
+		Timestamp:  l.Timestamp,
+		MappingID:  l.MappingID,
+		LocalPort:  l.LocalPort,
+		Method:     l.Method,
+		URL:        l.URL,
+		Host:       l.Host,
+		StatusCode: l.StatusCode,
+		DurationMs: l.DurationMs,
+	}
+}
+
+func diffHTTPLogFields(a, b *HTTPLog) []HTTPLogFieldDiff {
+	pairs := []HTTPLogFieldDiff{
+		{Field: "method", A: a.Method, B: b.Method},
+		{Field: "url", A: a.URL, B: b.URL},
+		{Field: "host", A: a.Host, B: b.Host},
+		{Field: "protocol", A: a.Protocol, B: b.Protocol},
+		{Field: "status_code", A: fmt.Sprint(a.StatusCode), B: fmt.Sprint(b.StatusCode)},
+	}
+	out := []HTTPLogFieldDiff{}
+	for _, p := range pairs {
+		if p.A != p.B {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+func httpLogRequestBody(l *HTTPLog) (headers, body []byte) {
+	headers, body, _ = splitHTTPMessage([]byte(l.Request))
+	if httpHeadersContain(headers, "transfer-encoding", "chunked") {
+		if dechunked := dechunkBodyData(body); dechunked != nil {
+			body = dechunked
+		}
+	}
+	return headers, body
+}
+
+// httpLogResponseBody returns the response body, gzip-decoded when the response was compressed.
+// A non-empty reason reports that the decoded body is incomplete.
+func httpLogResponseBody(l *HTTPLog, maxBytes int) (headers, body []byte, reason string) {
+	headers, body, _ = splitHTTPMessage([]byte(l.Response))
+	if httpHeadersContain(headers, "transfer-encoding", "chunked") {
+		if dechunked := dechunkBodyData(body); dechunked != nil {
+			body = dechunked
+		}
+	}
+	if l.ResponseDecoded != "" {
+		return headers, []byte(l.ResponseDecoded), ""
+	}
+	if httpHeadersContain(headers, "content-encoding", "gzip") {
+		timeout := time.Duration(config.Settings.HTTPGzipDecodeTimeoutMS) * time.Millisecond
+		// Decode one byte past the limit so diffHTTPBodies still notices the oversize.
+		decoded, _, r := decodeGzipBodyPreview(body, maxBytes+1, timeout)
+		switch r {
+		case "", "max_bytes":
+			return headers, decoded, ""
+		case "invalid_gzip":
+			// Not actually gzip; compare the raw bytes.
+		default:
+			return headers, decoded, r
+		}
+	}
+	return headers, body, ""
+}
+
+func parseHTTPHeaderMap(headers []byte) map[string][]string {
+	out := make(map[string][]string)
+	lines := bytes.Split(headers, []byte("\r\n"))
+	// The first line is the request or status line.
+	for _, line := range lines[1:] {
+		idx := bytes.IndexByte(line, ':')
+		if idx <= 0 {
+			continue
+		}
+		name := strings.ToLower(strings.TrimSpace(string(line[:idx])))
+		out[name] = append(out[name], strings.TrimSpace(string(line[idx+1:])))
+	}
+	return out
+}
+
+func diffHTTPHeaders(a, b []byte) []HTTPHeaderDiff {
+	ha, hb := parseHTTPHeaderMap(a), parseHTTPHeaderMap(b)
+
+	names := make([]string, 0, len(ha)+len(hb))
+	for name := range ha {
+		names = append(names, name)
+	}
+	for name := range hb {
+		if _, ok := ha[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	out := []HTTPHeaderDiff{}
+	for _, name := range names {
+		va, okA := ha[name]
+		vb, okB := hb[name]
+		joinedA, joinedB := strings.Join(va, ", "), strings.Join(vb, ", ")
+		switch {
+		case okA && !okB:
+			out = append(out, HTTPHeaderDiff{Name: name, Change: "removed", A: joinedA})
+		case !okA && okB:
+			out = append(out, HTTPHeaderDiff{Name: name, Change: "added", B: joinedB})
+		case joinedA != joinedB:
+			out = append(out, HTTPHeaderDiff{Name: name, Change: "changed", A: joinedA, B: joinedB})
+		}
+	}
+	return out
+}
+
+func diffHTTPBodies(a, b []byte, labelA, labelB string, maxBytes int) HTTPBodyDiff {
+	result := HTTPBodyDiff{Equal: bytes.Equal(a, b), ASize: len(a), BSize: len(b)}
+	if result.Equal {
+		return result
+	}
+
+	if isBinaryBody(previewBytes(a, maxBytes)) || isBinaryBody(previewBytes(b, maxBytes)) {
+		result.Binary = true
+		return result
+	}
+
+	if len(a) > maxBytes || len(b) > maxBytes {
+		a, b = previewBytes(a, maxBytes), previewBytes(b, maxBytes)
+		result.Truncated, result.TruncatedReason = true, "max_bytes"
+	}
+
+	linesA, linesB := splitDiffLines(string(a)), splitDiffLines(string(b))
+	ops, ok := diffLines(linesA, linesB, httpDiffMaxEdits)
+	if !ok {
+		result.Truncated, result.TruncatedReason = true, "too_many_changes"
+		return result
+	}
+	result.Diff = unifiedDiff(ops, linesA, linesB, labelA, labelB, httpDiffContextLines)
+	return result
+}
+
+func isBinaryBody(data []byte) bool {
+	if bytes.IndexByte(data, 0) >= 0 {
+		return true
+	}
+	// Allow a multi-byte rune cut off by truncation.
+	for i := 0; i < utf8.UTFMax && len(data) > 0 && !utf8.Valid(data); i++ {
+		data = data[:len(data)-1]
+	}
+	return !utf8.Valid(data)
+}
+
+func splitDiffLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+type diffOpKind byte
+
+const (
+	diffEqual  diffOpKind = ' '
+	diffDelete diffOpKind = '-'
+	diffInsert diffOpKind = '+'
+)
+
+type diffOp struct {
+	kind diffOpKind
+	a, b int // line indexes; a is unused for inserts and b for deletes
+}
+
+// diffLines computes a shortest edit script from a to b (Myers' algorithm). It gives up and returns
+// false when more than maxEdits insertions and deletions would be needed.
+func diffLines(a, b []string, maxEdits int) ([]diffOp, bool) {
+	n, m := len(a), len(b)
+	limit := n + m
+	if limit > maxEdits {
+		limit = maxEdits
+	}
+
+	off := limit + 1
+	v := make([]int, 2*limit+3)
+	// trace[d] holds v[-d..d] after step d, which is all the backtrack needs.
+	var trace [][]int
+
+	for d := 0; d <= limit; d++ {
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[off+k-1] < v[off+k+1]) {
+				x = v[off+k+1]
+			} else {
+				x = v[off+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[off+k] = x
+			if x >= n && y >= m {
+				trace = append(trace, nil)
+				return backtrackDiff(trace, n, m), true
+			}
+		}
+		trace = append(trace, append([]int(nil), v[off-d:off+d+1]...))
+	}
+	return nil, false
+}
+
+func backtrackDiff(trace [][]int, n, m int) []diffOp {
+	var ops []diffOp
+	x, y := n, m
+	for d := len(trace) - 1; d > 0; d-- {
+		prev := trace[d-1]
+		at := func(k int) int { return prev[k+d-1] }
+
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			ops = append(ops, diffOp{kind: diffEqual, a: x, b: y})
+		}
+		if x == prevX {
+			ops = append(ops, diffOp{kind: diffInsert, b: prevY})
+		} else {
+			ops = append(ops, diffOp{kind: diffDelete, a: prevX})
+		}
+		x, y = prevX, prevY
+	}
+	for x > 0 && y > 0 {
+		x--
+		y--
+		ops = append(ops, diffOp{kind: diffEqual, a: x, b: y})
+	}
+
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
+
+// unifiedDiff renders an edit script in unified diff format.
+func unifiedDiff(ops []diffOp, a, b []string, labelA, labelB string, context int) string {
+	// Line positions before each op, used for hunk headers.
+	posA := make([]int, len(ops)+1)
+	posB := make([]int, len(ops)+1)
+	for i, op := range ops {
+		posA[i+1], posB[i+1] = posA[i], posB[i]
+		if op.kind != diffInsert {
+			posA[i+1]++
+		}
+		if op.kind != diffDelete {
+			posB[i+1]++
+		}
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", labelA, labelB)
+
+	for i := 0; i < len(ops); {
+		if ops[i].kind == diffEqual {
+			i++
+			continue
+		}
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+		// Extend the hunk while the next change is within 2*context unchanged lines.
+		end := i
+		for end < len(ops) {
+			if ops[end].kind != diffEqual {
+				end++
+				continue
+			}
+			run := end
+			for run < len(ops) && ops[run].kind == diffEqual {
+				run++
+			}
+			if run == len(ops) || run-end > 2*context {
+				end += min(context, run-end)
+				break
+			}
+			end = run
+		}
+
+		countA, countB := posA[end]-posA[start], posB[end]-posB[start]
+		startA, startB := posA[start], posB[start]
+		if countA > 0 {
+			startA++
+		}
+		if countB > 0 {
+			startB++
+		}
+		fmt.Fprintf(&sb, "@@ -%d,%d +%d,%d @@\n", startA, countA, startB, countB)
+
+		for _, op := range ops[start:end] {
+			line := ""
+			if op.kind == diffInsert {
+				line = b[op.b]
+			} else {
+				line = a[op.a]
+			}
+			sb.WriteByte(byte(op.kind))
+			sb.WriteString(line)
+			if !strings.HasSuffix(line, "\n") {
+				sb.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		i = end
+	}
+	return sb.String()
+}
@@ -0,0 +1,110 @@
+package core
+
+import (
+	"bastion/config"
+	"bytes"
+	"compress/gzip"
+	"strings"
+	"testing"
+)
+
+func TestDiffHTTPLogs(t *testing.T) {
+	a := &HTTPLog{
+		ID: 12, Method: "GET", URL: "/api/users", Host: "svc", StatusCode: 200,
+		Request:  "GET /api/users HTTP/1.1\r\nHost: svc\r\nAccept: */*\r\nX-Trace: 1\r\n\r\n",
+		Response: "HTTP/1.1 200 OK\r\nContent-Type: application/json\r\n\r\n{\n\"a\": 1,\n\"b\": 2\n}\n",
+	}
+	b := &HTTPLog{
+		ID: 97, Method: "GET", URL: "/api/users", Host: "svc", StatusCode: 500,
+		Request:  "GET /api/users HTTP/1.1\r\nhost: svc\r\nAccept: text/html\r\nCookie: s=1\r\n\r\n",
+		Response: "HTTP/1.1 500 Internal Server Error\r\nContent-Type: application/json\r\n\r\n{\n\"a\": 1,\n\"b\": 3\n}\n",
+	}
+
+	d := diffHTTPLogs(a, b)
+
+	if len(d.Fields) != 1 || d.Fields[0].Field != "status_code" || d.Fields[0].A != "200" || d.Fields[0].B != "500" {
+		t.Fatalf("unexpected field diff: %+v", d.Fields)
+	}
+
+	want := []HTTPHeaderDiff{
+		{Name: "accept", Change: "changed", A: "*/*", B: "text/html"},
+		{Name: "cookie", Change: "added", B: "s=1"},
+		{Name: "x-trace", Change: "removed", A: "1"},
+	}
+	if len(d.RequestHeaders) != len(want) {
+		t.Fatalf("unexpected request header diff: %+v", d.RequestHeaders)
+	}
+	for i := range want {
+		if d.RequestHeaders[i] != want[i] {
+			t.Fatalf("header diff %d = %+v, want %+v", i, d.RequestHeaders[i], want[i])
+		}
+	}
+	if len(d.ResponseHeaders) != 0 {
+		t.Fatalf("expected equal response headers, got %+v", d.ResponseHeaders)
+	}
+
+	if !d.RequestBody.Equal {
+		t.Fatalf("expected empty request bodies to be equal")
+	}
+	wantDiff := "--- #12 response body\n+++ #97 response body\n@@ -1,4 +1,4 @@\n {\n \"a\": 1,\n-\"b\": 2\n+\"b\": 3\n }\n"
+	if d.ResponseBody.Equal || d.ResponseBody.Diff != wantDiff {
+		t.Fatalf("unexpected body diff:\n%s", d.ResponseBody.Diff)
+	}
+}
+
+func TestDiffHTTPBodies_Limits(t *testing.T) {
+	bin := diffHTTPBodies([]byte("a\x00b"), []byte("a\x00c"), "a", "b", 1024)
+	if !bin.Binary || bin.Diff != "" {
+		t.Fatalf("expected binary bodies to be summarized: %+v", bin)
+	}
+
+	long := strings.Repeat("line\n", 100)
+	cut := diffHTTPBodies([]byte(long+"x\n"), []byte(long+"y\n"), "a", "b", 64)
+	if !cut.Truncated || cut.TruncatedReason != "max_bytes" || strings.Contains(cut.Diff, "x") {
+		t.Fatalf("expected bodies to be cut at the limit: %+v", cut)
+	}
+
+	var sa, sb strings.Builder
+	for i := 0; i < httpDiffMaxEdits; i++ {
+		sa.WriteString("a\n")
+		sb.WriteString("b\n")
+	}
+	many := diffHTTPBodies([]byte(sa.String()), []byte(sb.String()), "a", "b", 1<<20)
+	if !many.Truncated || many.TruncatedReason != "too_many_changes" {
+		t.Fatalf("expected too_many_changes: %+v", many)
+	}
+}
+
+func TestDiffHTTPLogs_DecodesGzipResponse(t *testing.T) {
+	prev := config.Settings.HTTPCompareMaxBodyBytes
+	t.Cleanup(func() { config.Settings.HTTPCompareMaxBodyBytes = prev })
+	config.Settings.HTTPCompareMaxBodyBytes = 1024
+
+	gz := func(s string) string {
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		_, _ = w.Write([]byte(s))
+		_ = w.Close()
+		return "HTTP/1.1 200 OK\r\nContent-Encoding: gzip\r\n\r\n" + buf.String()
+	}
+	d := diffHTTPLogs(&HTTPLog{ID: 1, Response: gz("one\n")}, &HTTPLog{ID: 2, Response: gz("two\n")})
+	if d.ResponseBody.Binary || !strings.Contains(d.ResponseBody.Diff, "-one\n+two\n") {
+		t.Fatalf("expected decoded gzip bodies to be diffed: %+v", d.ResponseBody)
+	}
+}
+
+func TestDiffLines_Hunks(t *testing.T) {
+	a := splitDiffLines("1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n")
+	b := splitDiffLines("1\nX\n3\n4\n5\n6\n7\n8\n9\n10\nY\n12")
+	ops, ok := diffLines(a, b, 100)
+	if !ok {
+		t.Fatalf("diff gave up")
+	}
+	got := unifiedDiff(ops, a, b, "a", "b", 3)
+	want := "--- a\n+++ b\n" +
+		"@@ -1,5 +1,5 @@\n 1\n-2\n+X\n 3\n 4\n 5\n" +
+		"@@ -8,5 +8,5 @@\n 8\n 9\n 10\n-11\n-12\n+Y\n+12\n\\ No newline at end of file\n"
+	if got != want {
+		t.Fatalf("unexpected unified diff:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,268 @@
+package core
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"mime"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding"
+)
+
+// HTTPLogBodyFormat selects server-side pretty-printing of a body part.
+type HTTPLogBodyFormat string
+
+const (
+	HTTPLogBodyFormatNone HTTPLogBodyFormat = ""
+	HTTPLogBodyFormatJSON HTTPLogBodyFormat = "json"
+	HTTPLogBodyFormatXML  HTTPLogBodyFormat = "xml"
+	// HTTPLogBodyFormatAuto picks JSON or XML from the Content-Type, falling back to sniffing the body.
+	HTTPLogBodyFormatAuto HTTPLogBodyFormat = "auto"
+)
+
+// ErrInvalidHTTPLogFormat indicates an unknown format selector.
+var ErrInvalidHTTPLogFormat = errors.New("invalid format (expected json, xml or auto)")
+
+// ParseHTTPLogBodyFormat validates a format query value.
+func ParseHTTPLogBodyFormat(value string) (HTTPLogBodyFormat, error) {
+	switch f := HTTPLogBodyFormat(strings.ToLower(strings.TrimSpace(value))); f {
+	case HTTPLogBodyFormatNone, HTTPLogBodyFormatJSON, HTTPLogBodyFormatXML, HTTPLogBodyFormatAuto:
+		return f, nil
+	default:
+		return "", ErrInvalidHTTPLogFormat
+	}
+}
+
+// binaryContentTypePrefixes are media types rendered as base64 even when the bytes happen to be valid UTF-8.
+var binaryContentTypePrefixes = []string{
+	"image/", "audio/", "video/", "font/",
+	"application/octet-stream", "application/protobuf", "application/x-protobuf", "application/grpc",
+	"application/zip", "application/gzip", "application/pdf", "application/wasm",
+}
+
+// formatHTTPLogBody post-processes a body part for display: it transcodes text to UTF-8, returns binary
+// content as base64, and optionally pretty-prints JSON/XML. headers are the headers of the message the
+// body belongs to; gzipDecoded reports that body is already decoded from a gzip Content-Encoding.
+func formatHTTPLogBody(result *HTTPLogPartResult, headers []byte, format HTTPLogBodyFormat, gzipDecoded bool) {
+	contentType := httpHeaderValue(headers, "content-type")
+	mediaType, params, _ := mime.ParseMediaType(contentType)
+	result.ContentType = mediaType
+
+	data := []byte(result.Data)
+	if len(data) == 0 {
+		return
+	}
+	if format != HTTPLogBodyFormatNone && !gzipDecoded && httpHeadersContain(headers, "transfer-encoding", "chunked") {
+		// Pretty-printing needs the payload without chunk framing (gzip decoding already removed it).
+		if dechunked := dechunkBodyData(data); dechunked != nil {
+			data = dechunked
+		}
+	}
+
+	binary := isBinaryContentType(mediaType) || (!gzipDecoded && httpHeaderValue(headers, "content-encoding") != "")
+	if !binary {
+		if text, name, ok := transcodeToUTF8(data, params["charset"], mediaType, result.Truncated); ok {
+			data = text
+			if name != "utf-8" {
+				result.Charset = name
+			}
+		} else {
+			binary = true
+		}
+	}
+	if binary {
+		result.Data = base64.StdEncoding.EncodeToString(data)
+		result.Binary = true
+		result.Encoding = "base64"
+		return
+	}
+
+	if format == HTTPLogBodyFormatAuto {
+		format = detectBodyFormat(mediaType, data)
+	}
+	switch format {
+	case HTTPLogBodyFormatJSON:
+		var out bytes.Buffer
+		if err := json.Indent(&out, data, "", "  "); err == nil {
+			data = out.Bytes()
+			result.Format = string(HTTPLogBodyFormatJSON)
+		}
+	case HTTPLogBodyFormatXML:
+		if out, err := indentXML(data); err == nil {
+			data = out
+			result.Format = string(HTTPLogBodyFormatXML)
+		}
+	}
+
+	result.Data = string(data)
+}
+
+func httpHeaderValue(headers []byte, name string) string {
+	prefix := []byte(strings.ToLower(name) + ":")
+	for _, line := range bytes.Split(headers, []byte("\r\n")) {
+		if bytes.HasPrefix(bytes.ToLower(line), prefix) {
+			return strings.TrimSpace(string(line[len(prefix):]))
+		}
+	}
+	return ""
+}
+
+func isBinaryContentType(mediaType string) bool {
+	for _, prefix := range binaryContentTypePrefixes {
+		if strings.HasPrefix(mediaType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// looksLikeText reports whether data is valid UTF-8 without control bytes typical of binary formats.
+// A multi-byte rune cut off at the end of a truncated preview is tolerated.
+func looksLikeText(data []byte, truncated bool) bool {
+	for _, b := range data {
+		if b < 0x20 && b != '\n' && b != '\r' && b != '\t' && b != '\f' {
+			return false
+		}
+	}
+	if truncated {
+		for i := 0; i < utf8.UTFMax && len(data) > 0 && !utf8.Valid(data); i++ {
+			data = data[:len(data)-1]
+		}
+	}
+	return utf8.Valid(data)
+}
+
+// transcodeToUTF8 decodes data from its declared charset or, for undeclared text/* bodies that are not
+// UTF-8, from a sniffed one. It fails when the result does not look like text.
+func transcodeToUTF8(data []byte, label, mediaType string, truncated bool) ([]byte, string, bool) {
+	var (
+		enc  encoding.Encoding
+		name string
+	)
+	switch {
+	case label != "":
+		if enc, name = charset.Lookup(label); enc == nil {
+			return nil, "", false
+		}
+	case looksLikeText(data, truncated):
+		return data, "utf-8", true
+	case strings.HasPrefix(mediaType, "text/"):
+		enc, name, _ = charset.DetermineEncoding(data, mediaType)
+	default:
+		return nil, "", false
+	}
+
+	out, err := enc.NewDecoder().Bytes(data)
+	if err != nil || !looksLikeText(out, truncated) {
+		return nil, "", false
+	}
+	return out, name, true
+}
+
+func detectBodyFormat(mediaType string, data []byte) HTTPLogBodyFormat {
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		return HTTPLogBodyFormatJSON
+	case mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml"):
+		return HTTPLogBodyFormatXML
+	}
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		return HTTPLogBodyFormatNone
+	}
+	switch trimmed[0] {
+	case '{', '[':
+		return HTTPLogBodyFormatJSON
+	case '<':
+		if mediaType != "text/html" {
+			return HTTPLogBodyFormatXML
+		}
+	}
+	return HTTPLogBodyFormatNone
+}
+
+// indentXML re-indents an XML document with two spaces per level. It works on raw tokens so namespace
+// prefixes are kept as written; elements holding only text stay on one line.
+func indentXML(data []byte) ([]byte, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	dec.Strict = false
+	// The body has already been transcoded to UTF-8, so any declared encoding can be read as-is.
+	dec.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) { return input, nil }
+
+	var out bytes.Buffer
+	depth := 0
+	inline := false // the last token was a start tag or text, so a closing tag stays on the same line
+	newline := func() {
+		if out.Len() > 0 {
+			out.WriteByte('\n')
+		}
+		out.WriteString(strings.Repeat("  ", depth))
+	}
+	name := func(n xml.Name) string {
+		if n.Space != "" {
+			return n.Space + ":" + n.Local
+		}
+		return n.Local
+	}
+
+	for {
+		tok, err := dec.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			newline()
+			out.WriteString("<" + name(t.Name))
+			for _, attr := range t.Attr {
+				out.WriteString(" " + name(attr.Name) + `="`)
+				_ = xml.EscapeText(&out, []byte(attr.Value))
+				out.WriteByte('"')
+			}
+			out.WriteByte('>')
+			depth++
+			inline = true
+		case xml.EndElement:
+			depth--
+			if !inline {
+				newline()
+			}
+			out.WriteString("</" + name(t.Name) + ">")
+			inline = false
+		case xml.CharData:
+			text := bytes.TrimSpace(t)
+			if len(text) == 0 {
+				continue
+			}
+			if !inline {
+				newline()
+			}
+			_ = xml.EscapeText(&out, text)
+		case xml.Comment:
+			newline()
+			out.WriteString("<!--" + string(t) + "-->")
+			inline = false
+		case xml.ProcInst:
+			newline()
+			out.WriteString("<?" + t.Target + " " + string(t.Inst) + "?>")
+			inline = false
+		case xml.Directive:
+			newline()
+			out.WriteString("<!" + string(t) + ">")
+			inline = false
+		}
+	}
+	if depth != 0 {
+		return nil, errors.New("unbalanced XML elements")
+	}
+	return out.Bytes(), nil
+}
@@ -0,0 +1,96 @@
+package core
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+func formatTestAuditor(request, response string) *Auditor {
+	return &Auditor{
+		httpLogsMap:          map[int]*HTTPLog{1: {ID: 1, Request: request, Response: response}},
+		gzipDecodedBodyCache: make(map[int]*gzipDecodedBodyCacheEntry),
+	}
+}
+
+func TestGetHTTPLogPart_FormatJSON(t *testing.T) {
+	a := formatTestAuditor("", "HTTP/1.1 200 OK\r\nContent-Type: application/json; charset=utf-8\r\n\r\n{\"a\":[1,2],\"b\":\"x\"}")
+
+	got, err := a.GetHTTPLogPart(1, HTTPLogPartResponseBody, HTTPLogPartOptions{Format: HTTPLogBodyFormatAuto})
+	if err != nil {
+		t.Fatalf("GetHTTPLogPart: %v", err)
+	}
+	want := "{\n  \"a\": [\n    1,\n    2\n  ],\n  \"b\": \"x\"\n}"
+	if got.Data != want || got.Format != "json" || got.ContentType != "application/json" || got.Charset != "" {
+		t.Fatalf("unexpected result: %+v", got)
+	}
+
+	// Invalid JSON is returned unchanged.
+	a = formatTestAuditor("", "HTTP/1.1 200 OK\r\nContent-Type: application/json\r\n\r\n{\"a\":")
+	got, _ = a.GetHTTPLogPart(1, HTTPLogPartResponseBody, HTTPLogPartOptions{Format: HTTPLogBodyFormatJSON})
+	if got.Data != "{\"a\":" || got.Format != "" {
+		t.Fatalf("expected invalid JSON to pass through: %+v", got)
+	}
+}
+
+func TestGetHTTPLogPart_FormatXMLChunkedRequest(t *testing.T) {
+	req := "POST /soap HTTP/1.1\r\nContent-Type: text/xml\r\nTransfer-Encoding: chunked\r\n\r\n" +
+		"1d\r\n<s:Env><s:Body a=\"1\">hi</s:Bo\r\nb\r\ndy></s:Env>\r\n0\r\n\r\n"
+	a := formatTestAuditor(req, "")
+
+	got, err := a.GetHTTPLogPart(1, HTTPLogPartRequestBody, HTTPLogPartOptions{Format: HTTPLogBodyFormatAuto})
+	if err != nil {
+		t.Fatalf("GetHTTPLogPart: %v", err)
+	}
+	want := "<s:Env>\n  <s:Body a=\"1\">hi</s:Body>\n</s:Env>"
+	if got.Data != want || got.Format != "xml" {
+		t.Fatalf("unexpected result: %+v", got)
+	}
+}
+
+func TestGetHTTPLogPart_CharsetTranscode(t *testing.T) {
+	// "café" in ISO-8859-1.
+	a := formatTestAuditor("", "HTTP/1.1 200 OK\r\nContent-Type: text/plain; charset=iso-8859-1\r\n\r\ncaf\xe9")
+
+	got, err := a.GetHTTPLogPart(1, HTTPLogPartResponseBody, HTTPLogPartOptions{})
+	if err != nil {
+		t.Fatalf("GetHTTPLogPart: %v", err)
+	}
+	if got.Data != "café" || got.Charset != "windows-1252" || got.Binary {
+		t.Fatalf("unexpected result: %+v", got)
+	}
+}
+
+func TestGetHTTPLogPart_BinaryBody(t *testing.T) {
+	png := "\x89PNG\r\n\x1a\n\x00\x00"
+	a := formatTestAuditor("", "HTTP/1.1 200 OK\r\nContent-Type: image/png\r\n\r\n"+png)
+
+	got, err := a.GetHTTPLogPart(1, HTTPLogPartResponseBody, HTTPLogPartOptions{Format: HTTPLogBodyFormatAuto})
+	if err != nil {
+		t.Fatalf("GetHTTPLogPart: %v", err)
+	}
+	if !got.Binary || got.Encoding != "base64" || got.Data != base64.StdEncoding.EncodeToString([]byte(png)) {
+		t.Fatalf("expected base64 binary result: %+v", got)
+	}
+
+	// Undeclared protobuf-like bytes are detected from the content.
+	a = formatTestAuditor("", "HTTP/1.1 200 OK\r\n\r\n\x08\x96\x01")
+	got, _ = a.GetHTTPLogPart(1, HTTPLogPartResponseBody, HTTPLogPartOptions{})
+	if !got.Binary {
+		t.Fatalf("expected binary detection: %+v", got)
+	}
+
+	// Header parts are never post-processed.
+	got, _ = a.GetHTTPLogPart(1, HTTPLogPartResponseHeader, HTTPLogPartOptions{Format: HTTPLogBodyFormatAuto})
+	if got.Binary || got.Data != "HTTP/1.1 200 OK" {
+		t.Fatalf("unexpected header result: %+v", got)
+	}
+}
+
+func TestParseHTTPLogBodyFormat(t *testing.T) {
+	if f, err := ParseHTTPLogBodyFormat(" JSON "); err != nil || f != HTTPLogBodyFormatJSON {
+		t.Fatalf("unexpected parse: %q %v", f, err)
+	}
+	if _, err := ParseHTTPLogBodyFormat("yaml"); err == nil {
+		t.Fatalf("expected unknown format to be rejected")
+	}
+}
@@ -0,0 +1,98 @@
+package core
+
+import (
+	"regexp"
+	"strings"
+)
+
+const (
+	// httpLogMatchContext is how many bytes of context a match snippet keeps on each side.
+	httpLogMatchContext = 40
+	// MaxHTTPLogMatches caps the matches reported per log.
+	MaxHTTPLogMatches = 20
+)
+
+// HTTPLogMatch locates one occurrence of a search query in an HTTP log.
+type HTTPLogMatch struct {
+	Field      string `json:"field"`       // url, request, response or response_decoded
+	Offset     int    `json:"offset"`      // byte offset of the match in the field
+	Length     int    `json:"length"`      // byte length of the match
+	Snippet    string `json:"snippet"`     // the match with surrounding context
+	MatchStart int    `json:"match_start"` // byte offset of the match in Snippet
+	MatchEnd   int    `json:"match_end"`   // byte offset just past the match in Snippet
+}
+
+// FindHTTPLogMatches returns up to limit occurrences of filter's query (keyword or regex) in the URL,
+// request and response of httpLog, in that order, with snippets for highlighting.
+func FindHTTPLogMatches(httpLog *HTTPLog, filter HTTPLogFilter, limit int) []HTTPLogMatch {
+	if filter.Query == "" || limit <= 0 {
+		return nil
+	}
+	if limit > MaxHTTPLogMatches {
+		limit = MaxHTTPLogMatches
+	}
+
+	var finder interface {
+		FindAllStringIndex(s string, n int) [][]int
+	}
+	if filter.QueryRegex != nil {
+		f, ok := filter.QueryRegex.(interface {
+			FindAllStringIndex(s string, n int) [][]int
+		})
+		if !ok {
+			return nil
+		}
+		finder = f
+	} else {
+		// Keyword search is case-insensitive; matching on a lowercased copy could shift offsets.
+		finder = regexp.MustCompile("(?i)" + regexp.QuoteMeta(filter.Query))
+	}
+
+	fields := []struct{ name, text string }{
+		{"url", httpLog.URL},
+		{"request", httpLog.Request},
+		{"response", httpLog.Response},
+		{"response_decoded", httpLog.ResponseDecoded},
+	}
+	var matches []HTTPLogMatch
+	for _, f := range fields {
+		if f.text == "" {
+			continue
+		}
+		for _, loc := range finder.FindAllStringIndex(f.text, limit-len(matches)) {
+			if loc[1] == loc[0] {
+				continue // empty regex matches highlight nothing
+			}
+			matches = append(matches, newHTTPLogMatch(f.name, f.text, loc[0], loc[1]))
+		}
+		if len(matches) >= limit {
+			break
+		}
+	}
+	return matches
+}
+
+func newHTTPLogMatch(field, text string, start, end int) HTTPLogMatch {
+	from := start - httpLogMatchContext
+	if from < 0 {
+		from = 0
+	}
+	to := end + httpLogMatchContext
+	if to > len(text) {
+		to = len(text)
+	}
+
+	// Invalid UTF-8 (binary bodies, context cut mid-rune) is replaced piecewise so the match bounds
+	// stay exact.
+	before := strings.ToValidUTF8(text[from:start], "�")
+	match := strings.ToValidUTF8(text[start:end], "�")
+	after := strings.ToValidUTF8(text[end:to], "�")
+	return HTTPLogMatch{
+		Field:      field,
+		Offset:     start,
+		Length:     end - start,
+		Snippet:    before + match + after,
+		MatchStart: len(before),
+		MatchEnd:   len(before) + len(match),
+	}
+}
@@ -0,0 +1,54 @@
+package core
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestFindHTTPLogMatches_Keyword(t *testing.T) {
+	httpLog := &HTTPLog{
+		URL:      "/orders/42",
+		Request:  "GET /orders/42 HTTP/1.1\r\nHost: shop\r\n\r\n",
+		Response: "HTTP/1.1 200 OK\r\n\r\n" + strings.Repeat("x", 100) + `{"order":"ORDER-42"}`,
+	}
+
+	matches := FindHTTPLogMatches(httpLog, HTTPLogFilter{Query: "order"}, 10)
+	if len(matches) != 4 {
+		t.Fatalf("expected 4 matches, got %+v", matches)
+	}
+	if matches[0].Field != "url" || matches[0].Offset != 1 || matches[1].Field != "request" {
+		t.Fatalf("unexpected match order: %+v", matches)
+	}
+	last := matches[3]
+	if last.Field != "response" || httpLog.Response[last.Offset:last.Offset+last.Length] != "ORDER" {
+		t.Fatalf("unexpected response match: %+v", last)
+	}
+	if got := last.Snippet[last.MatchStart:last.MatchEnd]; got != "ORDER" {
+		t.Fatalf("snippet bounds point at %q", got)
+	}
+	if len(last.Snippet) > last.Length+2*httpLogMatchContext {
+		t.Fatalf("snippet too long: %q", last.Snippet)
+	}
+
+	if got := FindHTTPLogMatches(httpLog, HTTPLogFilter{Query: "order"}, 2); len(got) != 2 {
+		t.Fatalf("expected the limit to apply, got %d matches", len(got))
+	}
+	if got := FindHTTPLogMatches(httpLog, HTTPLogFilter{}, 5); got != nil {
+		t.Fatalf("expected no matches without a query, got %+v", got)
+	}
+}
+
+func TestFindHTTPLogMatches_RegexAndBinary(t *testing.T) {
+	httpLog := &HTTPLog{Response: "HTTP/1.1 200 OK\r\n\r\n\xff\xfeid=123\xff"}
+	filter := HTTPLogFilter{Query: `id=\d+`, QueryRegex: regexp.MustCompile(`id=\d+`)}
+
+	matches := FindHTTPLogMatches(httpLog, filter, 5)
+	if len(matches) != 1 {
+		t.Fatalf("expected 1 match, got %+v", matches)
+	}
+	m := matches[0]
+	if m.Snippet[m.MatchStart:m.MatchEnd] != "id=123" || !strings.Contains(m.Snippet, "�") {
+		t.Fatalf("unexpected snippet: %+v", m)
+	}
+}
@@ -1,13 +1,24 @@
 package core
 
 import (
+	"bastion/config"
 	"bytes"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// HTTP pair-matching strategies (HTTP_PAIR_STRATEGY).
+const (
+	// HTTPPairStrategySequence pairs a response with the request at the same position on its
+	// connection, so a lost or unparsable message does not shift every later pair.
+	HTTPPairStrategySequence = "sequence"
+	// HTTPPairStrategyFIFO pairs each response with the oldest pending request.
+	HTTPPairStrategyFIFO = "fifo"
+)
+
 type PendingRequest struct {
 	Message   *HTTPMessage
 	Timestamp time.Time
@@ -18,51 +29,203 @@ type HTTPPairMatcher struct {
 	pendingRequests map[string][]*PendingRequest // connID -> pending requests
 	mu              sync.RWMutex
 	onPairComplete  func(*HTTPLog)
+	strategy        string
+	maxPending      int // per connection, 0 = unlimited
+	trustedProxies  atomic.Pointer[IPAccessControl]
+	bodySkip        *httpBodySkipRules // nil keeps every response body
+
+	mismatches uint64 // requests skipped because a later response arrived first
+	orphans    uint64 // responses stored without a request
+	evicted    uint64 // requests dropped from a full pending queue
+	omitted    uint64 // response bodies left out by bodySkip
+}
+
+// HTTPPairStats counts pairing problems since start.
+type HTTPPairStats struct {
+	Pending    int    `json:"pending"`
+	Mismatches uint64 `json:"mismatches_total"`
+	Orphans    uint64 `json:"orphans_total"`
+	Evicted    uint64 `json:"evicted_total"`
+	Omitted    uint64 `json:"bodies_omitted_total"`
 }
 
 func NewHTTPPairMatcher(onComplete func(*HTTPLog)) *HTTPPairMatcher {
+	strategy := HTTPPairStrategySequence
+	if strings.EqualFold(config.Settings.HTTPPairStrategy, HTTPPairStrategyFIFO) {
+		strategy = HTTPPairStrategyFIFO
+	}
 	return &HTTPPairMatcher{
 		pendingRequests: make(map[string][]*PendingRequest),
 		onPairComplete:  onComplete,
+		strategy:        strategy,
+		maxPending:      config.Settings.HTTPPairMaxPending,
+		bodySkip:        newHTTPBodySkipRules(config.Settings.AuditSkipBodyTypes, config.Settings.AuditSkipBodyBytes),
 	}
 }
 
 // AddRequest enqueues a request awaiting response pairing
 func (m *HTTPPairMatcher) AddRequest(ctx AuditContext, connID string, msg *HTTPMessage) {
 	m.mu.Lock()
-	defer m.mu.Unlock()
+	completed := m.addRequestLocked(ctx, connID, msg, nil)
+	m.mu.Unlock()
+	m.deliver(completed)
+}
+
+// MatchResponse pairs a response with its pending request; see HTTPPairStrategySequence.
+func (m *HTTPPairMatcher) MatchResponse(ctx AuditContext, connID string, response *HTTPMessage) {
+	m.mu.Lock()
+	completed := m.matchResponseLocked(ctx, connID, response, nil)
+	m.mu.Unlock()
+	m.deliver(completed)
+}
+
+// ProcessBatch applies a batch of audit events under a single lock acquisition.
+// Completed pairs are delivered after the lock is released.
+func (m *HTTPPairMatcher) ProcessBatch(events []auditEvent) {
+	var completed []*HTTPLog
+
+	m.mu.Lock()
+	for _, ev := range events {
+		if ev.msg == nil {
+			continue
+		}
+		if ev.msg.Type == HTTPRequest {
+			completed = m.addRequestLocked(ev.ctx, ev.connID, ev.msg, completed)
+		} else {
+			completed = m.matchResponseLocked(ev.ctx, ev.connID, ev.msg, completed)
+		}
+	}
+	m.mu.Unlock()
 
+	m.deliver(completed)
+}
+
+// Stats returns the pairing counters and the number of requests awaiting a response.
+func (m *HTTPPairMatcher) Stats() HTTPPairStats {
+	m.mu.RLock()
+	pending := 0
+	for _, queue := range m.pendingRequests {
+		pending += len(queue)
+	}
+	m.mu.RUnlock()
+	return HTTPPairStats{
+		Pending:    pending,
+		Mismatches: atomic.LoadUint64(&m.mismatches),
+		Orphans:    atomic.LoadUint64(&m.orphans),
+		Evicted:    atomic.LoadUint64(&m.evicted),
+		Omitted:    atomic.LoadUint64(&m.omitted),
+	}
+}
+
+func (m *HTTPPairMatcher) deliver(completed []*HTTPLog) {
+	if m.onPairComplete == nil {
+		return
+	}
+	for _, httpLog := range completed {
+		m.onPairComplete(httpLog)
+	}
+}
+
+// addRequestLocked queues msg; when the connection already has maxPending requests waiting, the
+// oldest is stored without a response.
+func (m *HTTPPairMatcher) addRequestLocked(ctx AuditContext, connID string, msg *HTTPMessage, completed []*HTTPLog) []*HTTPLog {
 	pending := &PendingRequest{
 		Message:   msg,
 		Timestamp: msg.Timestamp,
 		Ctx:       ctx,
 	}
 
-	m.pendingRequests[connID] = append(m.pendingRequests[connID], pending)
+	queue := m.pendingRequests[connID]
+	if m.maxPending > 0 && len(queue) >= m.maxPending {
+		oldest := queue[0]
+		queue = queue[1:]
+		atomic.AddUint64(&m.evicted, 1)
+		completed = append(completed, m.createHTTPLog(oldest.Ctx, connID, oldest.Message, nil))
+	}
+	m.pendingRequests[connID] = append(queue, pending)
+	return completed
 }
 
-// MatchResponse pairs a response to the earliest pending request
-func (m *HTTPPairMatcher) MatchResponse(connID string, response *HTTPMessage) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+func (m *HTTPPairMatcher) matchResponseLocked(ctx AuditContext, connID string, response *HTTPMessage, completed []*HTTPLog) []*HTTPLog {
+	if isInterimResponse(response.Data) {
+		// 100 Continue and friends precede the final response to the same request.
+		return completed
+	}
 
 	queue := m.pendingRequests[connID]
-	if len(queue) == 0 {
-		// No pending requests; response arrived first (rare)
-		return
+	sequenced := m.strategy == HTTPPairStrategySequence && response.Seq > 0
+	if sequenced {
+		// Requests before the response's position never got a response of their own.
+		for len(queue) > 0 && queue[0].Message.Seq > 0 && queue[0].Message.Seq < response.Seq {
+			atomic.AddUint64(&m.mismatches, 1)
+			completed = append(completed, m.createHTTPLog(queue[0].Ctx, connID, queue[0].Message, nil))
+			queue = queue[1:]
+		}
+	}
+
+	if len(queue) == 0 || (sequenced && queue[0].Message.Seq > response.Seq) {
+		// The response's request was lost (queue overflow, dropped audit event) or never parsed.
+		m.setQueueLocked(connID, queue)
+		atomic.AddUint64(&m.orphans, 1)
+		return append(completed, m.createOrphanHTTPLog(ctx, connID, response))
 	}
 
-	// FIFO: take earliest request
 	request := queue[0]
-	m.pendingRequests[connID] = queue[1:]
+	m.setQueueLocked(connID, queue[1:])
+	return append(completed, m.createHTTPLog(request.Ctx, connID, request.Message, response))
+}
 
-	// Build full log entry
-	httpLog := m.createHTTPLog(request.Ctx, connID, request.Message, response)
+func (m *HTTPPairMatcher) setQueueLocked(connID string, queue []*PendingRequest) {
+	if len(queue) == 0 {
+		delete(m.pendingRequests, connID)
+		return
+	}
+	m.pendingRequests[connID] = queue
+}
 
-	// Callback to persist
-	if m.onPairComplete != nil {
-		m.onPairComplete(httpLog)
+// createOrphanHTTPLog stores a response whose request is unknown.
+func (m *HTTPPairMatcher) createOrphanHTTPLog(ctx AuditContext, connID string, response *HTTPMessage) *HTTPLog {
+	timestamp := response.FirstByteAt
+	if timestamp.IsZero() {
+		timestamp = response.Timestamp
+	}
+	responseStr, omitted := m.responseContent(response.Data)
+	return &HTTPLog{
+		Timestamp:           timestamp,
+		ConnID:              connID + extractClientInfo(connID),
+		MappingID:           ctx.MappingID,
+		LocalPort:           ctx.LocalPort,
+		BastionChain:        ctx.BastionChain,
+		StatusCode:          parseResponseStatusCode(response.Data),
+		Response:            responseStr,
+		RespSize:            len(response.Data),
+		IsGzipped:           httpMessageHasGzipEncoding(response.Data),
+		RequestSeq:          response.Seq,
+		ResponseHeaders:     httpMessageHeaderMap(response.Data),
+		ResponseBodyOmitted: omitted,
+		Orphan:              true,
+	}
+}
+
+// responseContent returns the response as stored in its HTTP log: the whole message, or only its
+// headers when bodySkip leaves the body out (with the reason). Nil matchers keep everything.
+func (m *HTTPPairMatcher) responseContent(data []byte) (string, string) {
+	if m == nil {
+		return string(data), ""
+	}
+	headers, body, _ := splitHTTPMessage(data)
+	omitted := m.bodySkip.match(headers, body)
+	if omitted == "" {
+		return string(data), ""
 	}
+	atomic.AddUint64(&m.omitted, 1)
+	return omitBody(headers), omitted
+}
+
+// isInterimResponse reports whether data is a 1xx response other than 101 Switching Protocols.
+func isInterimResponse(data []byte) bool {
+	code := parseResponseStatusCode(data)
+	return code >= 100 && code < 200 && code != 101
 }
 
 // createHTTPLog builds an HTTP log entry
@@ -70,25 +233,42 @@ func (m *HTTPPairMatcher) createHTTPLog(ctx AuditContext, connID string, request
 	// Parse request
 	method, url, protocol, host := parseRequest(request.Data)
 
-	// Extract client info from connID
-	clientInfo := extractClientInfo(connID)
-	enhancedConnID := connID + clientInfo
+	reqHeaders := httpMessageHeaderMap(request.Data)
+
+	// Extract client info from connID; behind a trusted proxy the forwarding headers name the client
+	peer, _, _ := strings.Cut(connID, "->")
+	clientIP, proxyIP := resolveClientIP(peer, reqHeaders, m.trusted())
+	enhancedConnID := connID + extractClientInfo(connID)
+	if proxyIP != "" {
+		enhancedConnID = connID + " [client=" + clientIP + " via " + peer + "]"
+	}
 
 	// Parse response
-	responseStr := ""
+	responseStr, omitted := "", ""
 	isGzipped := false
 	respSize := 0
 	statusCode := 0
-	var durationMs int64 = 0
+	var durationMs, ttfbMs int64 = 0, 0
+	var respHeaders map[string][]string
 
 	if response != nil {
-		responseStr = string(response.Data)
+		responseStr, omitted = m.responseContent(response.Data)
+		respHeaders = httpMessageHeaderMap(response.Data)
 		respSize = len(response.Data)
 		isGzipped = httpMessageHasGzipEncoding(response.Data)
 		statusCode = parseResponseStatusCode(response.Data)
 
 		// Compute latency in milliseconds
 		durationMs = response.Timestamp.Sub(request.Timestamp).Milliseconds()
+		if !response.FirstByteAt.IsZero() {
+			ttfbMs = response.FirstByteAt.Sub(request.Timestamp).Milliseconds()
+			if ttfbMs < 0 {
+				// The response started before the request body was fully sent (e.g. 100-continue or early errors).
+				ttfbMs = 0
+			}
+		} else {
+			ttfbMs = durationMs
+		}
 	}
 
 	return &HTTPLog{
@@ -108,7 +288,35 @@ func (m *HTTPPairMatcher) createHTTPLog(ctx AuditContext, connID string, request
 		RespSize:     respSize,
 		IsGzipped:    isGzipped,
 		DurationMs:   durationMs,
+
+		TTFBMs:           ttfbMs,
+		TTLBMs:           durationMs,
+		RequestSeq:       request.Seq,
+		ConnectionReused: request.Seq > 1,
+
+		ClientIP: clientIP,
+		ProxyIP:  proxyIP,
+
+		RequestHeaders:  reqHeaders,
+		ResponseHeaders: respHeaders,
+
+		ResponseBodyOmitted: omitted,
+		HeaderRewrites:      ctx.HeaderRewrites,
+	}
+}
+
+// trusted returns the proxies whose forwarding headers are believed (nil matchers trust none).
+func (m *HTTPPairMatcher) trusted() *IPAccessControl {
+	if m == nil {
+		return nil
 	}
+	return m.trustedProxies.Load()
+}
+
+// httpMessageHeaderMap parses the header block of a raw HTTP message.
+func httpMessageHeaderMap(data []byte) map[string][]string {
+	headers, _, _ := splitHTTPMessage(data)
+	return parseHTTPHeaderMap(headers)
 }
 
 func parseResponseStatusCode(data []byte) int {
@@ -0,0 +1,167 @@
+package core
+
+import (
+	"bastion/config"
+	"testing"
+	"time"
+)
+
+func newTestPairMatcher(t *testing.T, strategy string, maxPending int) (*HTTPPairMatcher, *[]*HTTPLog) {
+	t.Helper()
+	oldStrategy, oldMax := config.Settings.HTTPPairStrategy, config.Settings.HTTPPairMaxPending
+	t.Cleanup(func() {
+		config.Settings.HTTPPairStrategy = oldStrategy
+		config.Settings.HTTPPairMaxPending = oldMax
+	})
+	config.Settings.HTTPPairStrategy = strategy
+	config.Settings.HTTPPairMaxPending = maxPending
+
+	var logs []*HTTPLog
+	m := NewHTTPPairMatcher(func(l *HTTPLog) { logs = append(logs, l) })
+	return m, &logs
+}
+
+func pairReq(path string, seq int) *HTTPMessage {
+	return &HTTPMessage{Type: HTTPRequest, Timestamp: time.Now(), Seq: seq, Data: []byte("GET " + path + " HTTP/1.1\r\nHost: x\r\n\r\n")}
+}
+
+func pairResp(status string, seq int) *HTTPMessage {
+	return &HTTPMessage{Type: HTTPResponse, Timestamp: time.Now(), Seq: seq, Data: []byte("HTTP/1.1 " + status + "\r\nContent-Length: 0\r\n\r\n")}
+}
+
+func TestHTTPPairMatcher_SequenceSkipsLostResponse(t *testing.T) {
+	m, logs := newTestPairMatcher(t, HTTPPairStrategySequence, 0)
+	ctx := AuditContext{MappingID: "m"}
+
+	m.ProcessBatch([]auditEvent{
+		{ctx: ctx, connID: "c", msg: pairReq("/a", 1)},
+		{ctx: ctx, connID: "c", msg: pairReq("/b", 2)},
+		{ctx: ctx, connID: "c", msg: pairReq("/c", 3)},
+		// The response to /a was never parsed; /b's response must not be paired with /a.
+		{ctx: ctx, connID: "c", msg: pairResp("100 Continue", 2)},
+		{ctx: ctx, connID: "c", msg: pairResp("201 Created", 2)},
+		{ctx: ctx, connID: "c", msg: pairResp("204 No Content", 3)},
+	})
+
+	if len(*logs) != 3 {
+		t.Fatalf("expected 3 logs, got %d", len(*logs))
+	}
+	got := map[string]int{}
+	for _, l := range *logs {
+		got[l.URL] = l.StatusCode
+	}
+	if got["/a"] != 0 || got["/b"] != 201 || got["/c"] != 204 {
+		t.Fatalf("unexpected pairs: %v", got)
+	}
+	if st := m.Stats(); st.Mismatches != 1 || st.Orphans != 0 || st.Pending != 0 {
+		t.Fatalf("unexpected stats: %+v", st)
+	}
+}
+
+func TestHTTPPairMatcher_OrphanResponse(t *testing.T) {
+	m, logs := newTestPairMatcher(t, HTTPPairStrategySequence, 0)
+
+	m.MatchResponse(AuditContext{MappingID: "m"}, "c", pairResp("502 Bad Gateway", 1))
+	if len(*logs) != 1 || !(*logs)[0].Orphan || (*logs)[0].StatusCode != 502 || (*logs)[0].MappingID != "m" {
+		t.Fatalf("expected an orphan log, got %+v", *logs)
+	}
+
+	// The request of response 2 was lost (e.g. dropped audit event): response 2 is an orphan and
+	// request 3 still pairs with response 3.
+	m.AddRequest(AuditContext{}, "c", pairReq("/c", 3))
+	m.MatchResponse(AuditContext{}, "c", pairResp("200 OK", 2))
+	m.MatchResponse(AuditContext{}, "c", pairResp("404 Not Found", 3))
+	if len(*logs) != 3 || !(*logs)[1].Orphan || (*logs)[2].URL != "/c" || (*logs)[2].StatusCode != 404 {
+		t.Fatalf("unexpected logs: %+v", *logs)
+	}
+	if st := m.Stats(); st.Orphans != 2 {
+		t.Fatalf("unexpected stats: %+v", st)
+	}
+}
+
+func TestHTTPPairMatcher_MaxPendingAndFIFO(t *testing.T) {
+	m, logs := newTestPairMatcher(t, HTTPPairStrategyFIFO, 2)
+
+	m.AddRequest(AuditContext{}, "c", pairReq("/a", 1))
+	m.AddRequest(AuditContext{}, "c", pairReq("/b", 2))
+	m.AddRequest(AuditContext{}, "c", pairReq("/c", 3))
+	if len(*logs) != 1 || (*logs)[0].URL != "/a" || (*logs)[0].Response != "" {
+		t.Fatalf("expected the oldest request to be evicted, got %+v", *logs)
+	}
+
+	// FIFO ignores positions: the first response pairs with the oldest pending request.
+	m.MatchResponse(AuditContext{}, "c", pairResp("200 OK", 3))
+	if len(*logs) != 2 || (*logs)[1].URL != "/b" {
+		t.Fatalf("expected FIFO pairing, got %+v", (*logs)[1])
+	}
+	if st := m.Stats(); st.Evicted != 1 || st.Pending != 1 {
+		t.Fatalf("unexpected stats: %+v", st)
+	}
+}
+
+func TestHTTPStreamParser_InterimResponseSharesSeq(t *testing.T) {
+	p := NewHTTPStreamParser("c", "out")
+	msgs := p.Feed([]byte("HTTP/1.1 100 Continue\r\n\r\nHTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\nHTTP/1.1 204 No Content\r\n\r\n"))
+	if len(msgs) != 3 {
+		t.Fatalf("expected 3 messages, got %d", len(msgs))
+	}
+	if msgs[0].Seq != 1 || msgs[1].Seq != 1 || msgs[2].Seq != 2 {
+		t.Fatalf("unexpected seqs: %d %d %d", msgs[0].Seq, msgs[1].Seq, msgs[2].Seq)
+	}
+}
+
+func TestHTTPPairMatcher_SkipsResponseBodies(t *testing.T) {
+	oldTypes, oldBytes := config.Settings.AuditSkipBodyTypes, config.Settings.AuditSkipBodyBytes
+	t.Cleanup(func() {
+		config.Settings.AuditSkipBodyTypes, config.Settings.AuditSkipBodyBytes = oldTypes, oldBytes
+	})
+	config.Settings.AuditSkipBodyTypes, config.Settings.AuditSkipBodyBytes = "image/*, application/PDF", 16
+	m, logs := newTestPairMatcher(t, HTTPPairStrategySequence, 0)
+	ctx := AuditContext{MappingID: "m"}
+
+	resp := func(contentType, body string, seq int) *HTTPMessage {
+		return &HTTPMessage{Type: HTTPResponse, Timestamp: time.Now(), Seq: seq,
+			Data: []byte("HTTP/1.1 200 OK\r\nContent-Type: " + contentType + "\r\n\r\n" + body)}
+	}
+	m.ProcessBatch([]auditEvent{
+		{ctx: ctx, connID: "c", msg: pairReq("/logo", 1)},
+		{ctx: ctx, connID: "c", msg: resp("image/png", "\x89PNG", 1)},
+		{ctx: ctx, connID: "c", msg: pairReq("/doc", 2)},
+		{ctx: ctx, connID: "c", msg: resp("application/pdf; name=a.pdf", "%PDF", 2)},
+		{ctx: ctx, connID: "c", msg: pairReq("/api", 3)},
+		{ctx: ctx, connID: "c", msg: resp("application/json", `{"ok":true}`, 3)},
+		{ctx: ctx, connID: "c", msg: pairReq("/export", 4)},
+		{ctx: ctx, connID: "c", msg: resp("text/csv", "a,b\n1,2\n3,4\n5,6\n7,8\n", 4)},
+	})
+	m.MatchResponse(ctx, "c", resp("image/gif", "GIF89a", 6))
+
+	want := map[string]string{"/logo": BodyOmittedType, "/doc": BodyOmittedType, "/api": "", "/export": BodyOmittedSize, "": BodyOmittedType}
+	if len(*logs) != len(want) {
+		t.Fatalf("expected %d logs, got %d", len(want), len(*logs))
+	}
+	for _, l := range *logs {
+		if l.ResponseBodyOmitted != want[l.URL] {
+			t.Fatalf("%s: omitted = %q, want %q", l.URL, l.ResponseBodyOmitted, want[l.URL])
+		}
+		_, body, _ := splitHTTPMessage([]byte(l.Response))
+		if (l.ResponseBodyOmitted != "") != (len(body) == 0) || l.RespSize <= len(l.Response)-len(body) {
+			t.Fatalf("%s: response = %q, size %d", l.URL, l.Response, l.RespSize)
+		}
+		if l.ResponseHeaders["content-type"] == nil {
+			t.Fatalf("%s: headers lost: %+v", l.URL, l.ResponseHeaders)
+		}
+	}
+	if st := m.Stats(); st.Omitted != 4 {
+		t.Fatalf("omitted = %d, want 4", st.Omitted)
+	}
+}
+
+func TestNewHTTPBodySkipRules_Disabled(t *testing.T) {
+	if rules := newHTTPBodySkipRules(" Off ", 0); rules != nil {
+		t.Fatalf("expected no rules, got %+v", rules)
+	}
+	var rules *httpBodySkipRules
+	if got := rules.match([]byte("Content-Type: image/png"), []byte("x")); got != "" {
+		t.Fatalf("nil rules skipped a body: %q", got)
+	}
+}
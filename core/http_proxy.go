@@ -24,41 +24,22 @@ type HTTPProxySession struct {
 
 // NewHTTPProxySession creates an HTTP proxy session
 func NewHTTPProxySession(mapping *models.Mapping, bastions []models.Bastion) *HTTPProxySession {
-	ipACL, _ := NewIPAccessControl(mapping.GetAllowCIDRs(), mapping.GetDenyCIDRs())
-	chain := make([]string, 0, len(bastions))
-	for _, b := range bastions {
-		chain = append(chain, b.Name)
-	}
-	return &HTTPProxySession{
-		BaseSession: BaseSession{
-			Mapping:        mapping,
-			Bastions:       bastions,
-			stopChan:       make(chan struct{}),
-			maxConnections: int32(config.Settings.MaxSessionConnections),
-			httpParsers:    make(map[string]*HTTPStreamParser),
-			ipACL:          ipACL,
-			auditCtx: AuditContext{
-				MappingID:    mapping.ID,
-				LocalPort:    mapping.LocalPort,
-				BastionChain: chain,
-			},
-		},
-	}
+	return &HTTPProxySession{BaseSession: newBaseSession(mapping, bastions)}
 }
 
 // Start starts the HTTP proxy session
 func (s *HTTPProxySession) Start() error {
-	addr := net.JoinHostPort(s.Mapping.LocalHost, strconv.Itoa(s.Mapping.LocalPort))
-	listener, err := listenTCPWithDiagnostics(s.Mapping)
+	addr, err := s.listen()
 	if err != nil {
 		return err
 	}
 
-	s.listener = listener
 	log.Printf("HTTP Proxy started: %s", addr)
 
 	s.wg.Add(1)
-	go s.acceptLoop()
+	s.spawn(s.acceptLoop)
+	s.startStandbyWatcher()
+	s.startThroughputSampler()
 
 	return nil
 }
@@ -85,23 +66,13 @@ func (s *HTTPProxySession) acceptLoop() {
 			}
 		}
 
-		if !s.shouldAcceptClient(conn) {
-			if config.Settings.LogLevel == "DEBUG" {
-				log.Printf("[HTTP] Rejected client %s by IP ACL", conn.RemoteAddr().String())
-			}
-			conn.Close()
-			continue
-		}
-
-		// Enforce connection limit
-		if atomic.LoadInt32(&s.activeConns) >= s.maxConnections {
-			log.Printf("Connection limit reached (%d), rejecting new connection", s.maxConnections)
-			conn.Close()
+		conn, ok := s.admitClient(conn, "HTTP")
+		if !ok {
 			continue
 		}
 
 		s.wg.Add(1)
-		go s.handleHTTPClientWithRecover(conn)
+		s.spawn(func() { s.handleHTTPClientWithRecover(conn) })
 	}
 }
 
@@ -109,7 +80,7 @@ func (s *HTTPProxySession) acceptLoop() {
 func (s *HTTPProxySession) handleHTTPClientWithRecover(conn net.Conn) {
 	defer func() {
 		if r := recover(); r != nil {
-			log.Printf("Recovered from panic in handleHTTPClient: %v", r)
+			s.recordPanic("handleHTTPClient", r, map[string]interface{}{"client": conn.RemoteAddr().String()})
 		}
 	}()
 	s.handleHTTPClient(conn)
@@ -119,9 +90,10 @@ func (s *HTTPProxySession) handleHTTPClientWithRecover(conn net.Conn) {
 func (s *BaseSession) handleHTTPClient(clientConn net.Conn) {
 	defer s.wg.Done()
 	defer clientConn.Close()
+	defer s.trackConn(clientConn)()
 
-	atomic.AddInt32(&s.activeConns, 1)
-	defer atomic.AddInt32(&s.activeConns, -1)
+	s.connOpened()
+	defer s.connClosed()
 
 	clientAddr := clientConn.RemoteAddr().String()
 	localAddr := clientConn.LocalAddr().String()
@@ -148,6 +120,11 @@ func (s *BaseSession) handleHTTPClient(clientConn net.Conn) {
 		return
 	}
 
+	if !s.allowTarget("HTTP", clientAddr, targetHost, targetPort) {
+		sendSimpleHTTPError(clientConnWithTimeout, http.StatusForbidden, "Forbidden")
+		return
+	}
+
 	remoteAddr := net.JoinHostPort(targetHost, strconv.Itoa(targetPort))
 	connID := fmt.Sprintf("%s->%s", clientAddr, remoteAddr)
 
@@ -156,21 +133,15 @@ func (s *BaseSession) handleHTTPClient(clientConn net.Conn) {
 			clientAddr, localAddr, req.Method, remoteAddr)
 	}
 
-	var remoteConn net.Conn
-
-	// Connect via bastion chain or directly
-	if len(s.Bastions) == 0 {
-		remoteConn, err = net.DialTimeout("tcp", remoteAddr, 10*time.Second)
-	} else {
-		bastionChain := getBastionChainNames(s.Bastions)
-		remoteConn, err = s.dialWithRetry(remoteAddr, clientAddr, bastionChain)
-	}
+	// Connect via bastion chain or directly (plus the optional upstream proxy)
+	remoteConn, err := s.dialRemote(remoteAddr, clientAddr)
 	if err != nil {
-		log.Printf("[HTTP] Failed to dial remote %s from client %s: %v", remoteAddr, clientAddr, err)
+		log.Printf("[HTTP] Failed to dial remote %s via %s from client %s: %v", remoteAddr, s.routeDescription(), clientAddr, err)
 		sendSimpleHTTPError(clientConnWithTimeout, http.StatusBadGateway, "Bad Gateway")
 		return
 	}
 	defer remoteConn.Close()
+	defer s.trackConnEvents("HTTP", clientAddr, remoteAddr, remoteConn)()
 
 	clientConnWithTimeout.SetTimeouts(transferReadTimeout, transferWriteTimeout)
 	remoteConnWithTimeout := NewDeadlineConn(remoteConn, transferReadTimeout, transferWriteTimeout)
@@ -179,7 +150,7 @@ func (s *BaseSession) handleHTTPClient(clientConn net.Conn) {
 		if _, err := clientConnWithTimeout.Write([]byte("HTTP/1.1 200 Connection Established\r\n\r\n")); err != nil {
 			return
 		}
-		s.pipe(clientConnWithTimeout, remoteConnWithTimeout, connID)
+		s.pipe(s.sniffTLS(clientConnWithTimeout, "HTTP", clientAddr, remoteAddr), remoteConnWithTimeout, connID)
 		return
 	}
 
@@ -192,6 +163,11 @@ func (s *BaseSession) handleHTTPClient(clientConn net.Conn) {
 	req.RequestURI = ""
 	req.Close = true
 
+	rewrites := s.headerRewriter.Apply(req.Header)
+	if len(rewrites) > 0 && config.Settings.LogLevel == "DEBUG" {
+		log.Printf("[HTTP] Rewrote request headers of %s %s: %s", req.Method, remoteAddr, strings.Join(rewrites, ", "))
+	}
+
 	isWebSocket := isWebSocketUpgradeRequest(req)
 	if isWebSocket {
 		// WebSocket upgrade requires a long-lived full-duplex connection.
@@ -203,6 +179,7 @@ func (s *BaseSession) handleHTTPClient(clientConn net.Conn) {
 		session:   s,
 		direction: "request",
 		connID:    connID,
+		rewrites:  rewrites,
 	}
 
 	if err := req.Write(trackingWriter); err != nil {
@@ -245,6 +222,10 @@ func (s *BaseSession) handleHTTPClient(clientConn net.Conn) {
 
 	// Copy response while updating stats and audit logs
 	s.copyData(clientConnWithTimeout, remoteConnWithTimeout, "response", connID)
+	if s.auditSampling.Audits(connID) {
+		s.flushHTTPParser("request", connID)
+		s.flushHTTPParser("response", connID)
+	}
 }
 
 func isWebSocketUpgradeRequest(req *http.Request) bool {
@@ -268,30 +249,28 @@ func (s *BaseSession) pipeRaw(clientConn net.Conn, clientReader *bufio.Reader, r
 		_ = remoteConn.Close()
 	}
 
-	go func() {
+	s.spawn(func() {
 		defer wg.Done()
 		defer once.Do(closeConns)
 		s.copyRaw(remoteConn, clientReader, "request", connID)
-	}()
+	})
 
-	go func() {
+	s.spawn(func() {
 		defer wg.Done()
 		defer once.Do(closeConns)
 		s.copyRaw(clientConn, remoteReader, "response", connID)
-	}()
+	})
 
 	wg.Wait()
 }
 
 func (s *BaseSession) copyRaw(dst net.Conn, src io.Reader, direction, connID string) {
-	pool := getForwardBufferPool()
-	bufPtr := pool.Get(pool.InitialSize())
-	buf := *bufPtr
-	defer pool.Put(bufPtr)
+	buf := s.newForwardBuffer()
+	defer buf.Release()
 
 	defer func() {
 		if r := recover(); r != nil {
-			log.Printf("Recovered from panic in copyRaw [%s]: %v", direction, r)
+			s.recordPanic("copyRaw", r, map[string]interface{}{"direction": direction, "conn_id": connID})
 		}
 		if cw, ok := dst.(interface{ CloseWrite() error }); ok {
 			_ = cw.CloseWrite()
@@ -299,8 +278,12 @@ func (s *BaseSession) copyRaw(dst net.Conn, src io.Reader, direction, connID str
 	}()
 
 	for {
-		n, err := src.Read(buf)
+		readStart := time.Now()
+		n, err := src.Read(buf.Bytes())
+		waited := time.Since(readStart)
 		if n > 0 {
+			data := buf.Bytes()[:n]
+
 			if direction == "request" {
 				atomic.AddInt64(&s.bytesUp, int64(n))
 			} else {
@@ -309,20 +292,21 @@ func (s *BaseSession) copyRaw(dst net.Conn, src io.Reader, direction, connID str
 
 			written := 0
 			for written < n {
-				w, werr := dst.Write(buf[written:n])
+				w, werr := dst.Write(data[written:])
 				if werr != nil {
 					return
 				}
 				written += w
 			}
 
-			if n == len(buf) {
-				if next, ok := pool.NextSize(len(buf)); ok && next > len(buf) {
-					pool.Put(bufPtr)
-					bufPtr = pool.Get(next)
-					buf = *bufPtr
-				}
+			// Once the TLS sniffer is done with the ClientHello, the rest can be spliced.
+			if sniff, ok := src.(*tlsSniffConn); ok && err == nil && sniff.Sniffed() && spliceable(dst, sniff) {
+				buf.Release()
+				s.copyFast(dst, sniff, direction, connID)
+				return
 			}
+
+			buf.Observe(n, waited)
 		}
 		if err != nil {
 			if err != io.EOF && config.Settings.LogLevel == "DEBUG" {
@@ -339,6 +323,7 @@ type proxyTrackingWriter struct {
 	session   *BaseSession
 	direction string
 	connID    string
+	rewrites  []string // header rule changes, recorded in the HTTP log of the request
 }
 
 func (w *proxyTrackingWriter) Write(p []byte) (int, error) {
@@ -348,8 +333,10 @@ func (w *proxyTrackingWriter) Write(p []byte) (int, error) {
 		} else {
 			atomic.AddInt64(&w.session.bytesDown, int64(len(p)))
 		}
-		if config.Settings.AuditEnabled {
-			w.session.feedHTTPParser(p, w.direction, w.connID)
+		if w.session.auditSampling.Audits(w.connID) {
+			ctx := w.session.auditCtx
+			ctx.HeaderRewrites = w.rewrites
+			w.session.feedHTTPParserCtx(ctx, p, w.direction, w.connID)
 		}
 	}
 	return w.dst.Write(p)
@@ -369,7 +356,7 @@ func parseProxyTarget(req *http.Request) (string, int, error) {
 	if err != nil {
 		// Add default port if missing
 		if strings.Contains(err.Error(), "missing port in address") {
-			host = hostPort
+			host = strings.TrimSuffix(strings.TrimPrefix(hostPort, "["), "]") // "[::1]"
 			if strings.EqualFold(req.Method, http.MethodConnect) || req.URL.Scheme == "https" {
 				return host, 443, nil
 			}
@@ -0,0 +1,154 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"bastion/models"
+)
+
+const (
+	// httpReplayTimeout bounds the whole replayed exchange (write request, read response).
+	httpReplayTimeout = 60 * time.Second
+	// httpReplayMaxResponseBytes caps how much of a replayed response is captured.
+	httpReplayMaxResponseBytes = 16 << 20
+)
+
+var (
+	// ErrHTTPReplayInvalidRequest indicates the stored request cannot be parsed or replayed.
+	ErrHTTPReplayInvalidRequest = errors.New("stored request cannot be replayed")
+	// ErrHTTPReplayUpstream indicates the replayed request could not be delivered or answered.
+	ErrHTTPReplayUpstream = errors.New("replay upstream failed")
+)
+
+// HTTPReplayOptions edits the stored request before it is re-sent.
+type HTTPReplayOptions struct {
+	SetHeaders    map[string]string // replace (or add) these headers
+	RemoveHeaders []string          // drop these headers
+}
+
+// HTTPReplayResult links a replayed exchange to the original log entry.
+type HTTPReplayResult struct {
+	OriginalID int    `json:"original_id"`
+	ReplayID   int    `json:"replay_id"`
+	MappingID  string `json:"mapping_id"`
+	Target     string `json:"target"`
+	Route      string `json:"route"`
+	StatusCode int    `json:"status_code"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+var httpReplaySeq uint64
+
+// ReplayHTTPLog re-sends the request of a stored HTTP log through mapping's route (bastion chain and
+// upstream proxy) and records the new exchange as a fresh log entry. Tunnel mappings send the request
+// to the mapping's remote address; proxy mappings send it to the host named by the request.
+func (a *Auditor) ReplayHTTPLog(id int, mapping *models.Mapping, bastions []models.Bastion, opts HTTPReplayOptions) (*HTTPReplayResult, error) {
+	a.httpMu.RLock()
+	original := a.httpLogsMap[id]
+	a.httpMu.RUnlock()
+	if original == nil {
+		return nil, ErrHTTPLogNotFound
+	}
+
+	req, err := http.ReadRequest(bufio.NewReader(bytes.NewReader([]byte(original.Request))))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrHTTPReplayInvalidRequest, err)
+	}
+	for _, name := range opts.RemoveHeaders {
+		req.Header.Del(name)
+	}
+	for name, value := range opts.SetHeaders {
+		if http.CanonicalHeaderKey(name) == "Host" {
+			req.Host = value
+			continue
+		}
+		req.Header.Set(name, value)
+	}
+	req.Close = true
+
+	// Serialize first so a truncated stored body fails before anything is dialed.
+	var reqBuf bytes.Buffer
+	if err := req.Write(&reqBuf); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrHTTPReplayInvalidRequest, err)
+	}
+
+	var target string
+	switch mapping.Type {
+	case "http", "mixed", "socks5":
+		host, port, err := parseProxyTarget(req)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrHTTPReplayInvalidRequest, err)
+		}
+		target = net.JoinHostPort(host, strconv.Itoa(port))
+	default:
+		target = net.JoinHostPort(mapping.RemoteHost, strconv.Itoa(mapping.RemotePort))
+	}
+
+	s := newBaseSession(mapping, bastions)
+	seq := atomic.AddUint64(&httpReplaySeq, 1)
+	clientAddr := fmt.Sprintf("replay#%d", seq)
+
+	conn, err := s.dialRemote(target, clientAddr)
+	if err != nil {
+		return nil, fmt.Errorf("%w: dial %s via %s: %v", ErrHTTPReplayUpstream, target, s.routeDescription(), err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(httpReplayTimeout))
+
+	sentAt := time.Now()
+	if _, err := conn.Write(reqBuf.Bytes()); err != nil {
+		return nil, fmt.Errorf("%w: send request: %v", ErrHTTPReplayUpstream, err)
+	}
+
+	var respBuf bytes.Buffer
+	first := &firstByteReader{r: conn}
+	tee := io.TeeReader(io.LimitReader(first, httpReplayMaxResponseBytes), &respBuf)
+	resp, err := http.ReadResponse(bufio.NewReader(tee), req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: read response: %v", ErrHTTPReplayUpstream, err)
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+	receivedAt := time.Now()
+
+	httpLog := a.pairMatcher.createHTTPLog(
+		s.auditCtx,
+		fmt.Sprintf("%s->%s", clientAddr, target),
+		&HTTPMessage{Type: HTTPRequest, Data: reqBuf.Bytes(), Timestamp: sentAt, FirstByteAt: sentAt, Seq: 1},
+		&HTTPMessage{Type: HTTPResponse, Data: respBuf.Bytes(), Timestamp: receivedAt, FirstByteAt: first.at, Seq: 1},
+	)
+	a.saveHTTPLog(httpLog)
+
+	return &HTTPReplayResult{
+		OriginalID: id,
+		ReplayID:   httpLog.ID,
+		MappingID:  mapping.Key(),
+		Target:     target,
+		Route:      s.routeDescription(),
+		StatusCode: httpLog.StatusCode,
+		DurationMs: httpLog.DurationMs,
+	}, nil
+}
+
+// firstByteReader records when the first byte was read.
+type firstByteReader struct {
+	r  io.Reader
+	at time.Time
+}
+
+func (f *firstByteReader) Read(p []byte) (int, error) {
+	n, err := f.r.Read(p)
+	if n > 0 && f.at.IsZero() {
+		f.at = time.Now()
+	}
+	return n, err
+}
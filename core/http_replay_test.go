@@ -0,0 +1,75 @@
+package core
+
+import (
+	"bastion/models"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestReplayHTTPLog_DirectTunnel(t *testing.T) {
+	var gotAuth, gotTrace string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth, gotTrace = r.Header.Get("Authorization"), r.Header.Get("X-Trace")
+		w.WriteHeader(http.StatusTeapot)
+		_, _ = w.Write([]byte("short and stout"))
+	}))
+	defer srv.Close()
+
+	host, portStr, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	port, _ := strconv.Atoi(portStr)
+
+	a := &Auditor{
+		httpLogs:    make([]*HTTPLog, 0, 10),
+		httpLogsMap: make(map[int]*HTTPLog),
+		maxLogs:     10,
+	}
+	a.saveHTTPLog(&HTTPLog{
+		MappingID: "m",
+		Request:   "POST /brew HTTP/1.1\r\nHost: pot\r\nAuthorization: old\r\nX-Trace: 1\r\nContent-Length: 3\r\n\r\nabc",
+	})
+
+	mapping := &models.Mapping{ID: "m", Type: "tcp", RemoteHost: host, RemotePort: port}
+	res, err := a.ReplayHTTPLog(1, mapping, nil, HTTPReplayOptions{
+		SetHeaders:    map[string]string{"authorization": "Bearer new"},
+		RemoveHeaders: []string{"X-Trace"},
+	})
+	if err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if res.OriginalID != 1 || res.ReplayID != 2 || res.StatusCode != http.StatusTeapot {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	if gotAuth != "Bearer new" || gotTrace != "" {
+		t.Fatalf("header overrides not applied: auth=%q trace=%q", gotAuth, gotTrace)
+	}
+
+	replayed := a.GetHTTPLogByID(res.ReplayID)
+	if replayed == nil || replayed.Method != "POST" || replayed.URL != "/brew" || replayed.MappingID != "m" {
+		t.Fatalf("unexpected replay log: %+v", replayed)
+	}
+	if _, body, _ := splitHTTPMessage([]byte(replayed.Response)); string(body) != "short and stout" {
+		t.Fatalf("unexpected captured body %q", body)
+	}
+}
+
+func TestReplayHTTPLog_InvalidRequest(t *testing.T) {
+	a := &Auditor{
+		httpLogs:    make([]*HTTPLog, 0, 10),
+		httpLogsMap: make(map[int]*HTTPLog),
+		maxLogs:     10,
+	}
+	// Content-Length promises more than the stored (truncated) body.
+	a.saveHTTPLog(&HTTPLog{Request: "POST / HTTP/1.1\r\nHost: x\r\nContent-Length: 10\r\n\r\nabc"})
+
+	_, err := a.ReplayHTTPLog(1, &models.Mapping{Type: "http"}, nil, HTTPReplayOptions{})
+	if !errors.Is(err, ErrHTTPReplayInvalidRequest) {
+		t.Fatalf("expected invalid request error, got %v", err)
+	}
+	if _, err := a.ReplayHTTPLog(9, &models.Mapping{}, nil, HTTPReplayOptions{}); !errors.Is(err, ErrHTTPLogNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
@@ -19,7 +19,10 @@ const (
 type HTTPMessage struct {
 	Type      HTTPMessageType
 	Data      []byte
-	Timestamp time.Time
+	Timestamp time.Time // when the message was complete (last byte seen)
+
+	FirstByteAt time.Time // when the first byte of the message was seen
+	Seq         int       // 1-based position of the message in its direction of the connection (1xx responses share it with the final response)
 }
 
 type HTTPStreamParser struct {
@@ -30,8 +33,17 @@ type HTTPStreamParser struct {
 	isChunked      bool
 	headerComplete bool
 	mu             sync.Mutex
+
+	sniffBuf []byte
+	sniffed  bool
+
+	msgStart time.Time // first byte of the message currently being buffered
+	seq      int       // messages extracted so far
 }
 
+// maxHTTPMethodLen bounds the request method token accepted by the stream sniffer.
+const maxHTTPMethodLen = 12
+
 func NewHTTPStreamParser(connID, direction string) *HTTPStreamParser {
 	return &HTTPStreamParser{
 		connID:        connID,
@@ -46,6 +58,9 @@ func (p *HTTPStreamParser) Feed(data []byte) []*HTTPMessage {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
+	if p.buffer.Len() == 0 && len(data) > 0 {
+		p.msgStart = time.Now()
+	}
 	p.buffer.Write(data)
 
 	var messages []*HTTPMessage
@@ -62,6 +77,66 @@ func (p *HTTPStreamParser) Feed(data []byte) []*HTTPMessage {
 	return messages
 }
 
+// Sniff classifies the stream from its first bytes. It returns false once the stream is known not to be
+// HTTP/1.x, in which case the caller should stop feeding the parser for the rest of the connection.
+func (p *HTTPStreamParser) Sniff(data []byte) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.sniffed {
+		return true
+	}
+	need := maxHTTPMethodLen + 1 - len(p.sniffBuf)
+	if need > len(data) {
+		need = len(data)
+	}
+	p.sniffBuf = append(p.sniffBuf, data[:need]...)
+
+	isHTTP, decided := sniffHTTPPrefix(p.sniffBuf)
+	if !decided {
+		return true
+	}
+	p.sniffed = true
+	p.sniffBuf = nil
+	return isHTTP
+}
+
+// sniffHTTPPrefix reports whether prefix starts an HTTP/1.x request ("METHOD ") or response ("HTTP/").
+// decided is false while more bytes are needed.
+func sniffHTTPPrefix(prefix []byte) (isHTTP, decided bool) {
+	if len(prefix) == 0 {
+		return false, false
+	}
+
+	statusPending := false
+	const statusPrefix = "HTTP/"
+	n := len(prefix)
+	if n > len(statusPrefix) {
+		n = len(statusPrefix)
+	}
+	if string(prefix[:n]) == statusPrefix[:n] {
+		if n == len(statusPrefix) {
+			return true, true
+		}
+		statusPending = true
+	}
+
+	for i, c := range prefix {
+		if c == ' ' {
+			// HTTP/2 prior-knowledge preface ("PRI * HTTP/2.0") is binary framing after the first line.
+			method := string(prefix[:i])
+			if i >= 3 && method != "PRI" {
+				return true, true
+			}
+			return false, !statusPending
+		}
+		if c < 'A' || c > 'Z' || i >= maxHTTPMethodLen {
+			return false, !statusPending
+		}
+	}
+	return false, false
+}
+
 // tryExtractMessage attempts to pull a complete message from the buffer
 func (p *HTTPStreamParser) tryExtractMessage() *HTTPMessage {
 	data := p.buffer.Bytes()
@@ -112,11 +187,26 @@ func (p *HTTPStreamParser) tryExtractMessage() *HTTPMessage {
 	// Identify message type
 	msgType := p.detectMessageType(messageData)
 
-	return &HTTPMessage{
-		Type:      msgType,
-		Data:      messageData,
-		Timestamp: time.Now(),
+	now := time.Now()
+	msg := &HTTPMessage{
+		Type:        msgType,
+		Data:        messageData,
+		Timestamp:   now,
+		FirstByteAt: p.msgStart,
+		Seq:         p.nextSeq(),
 	}
+	if msgType == HTTPResponse && isInterimResponse(messageData) {
+		// A 1xx response shares its position with the final response that follows it.
+		p.seq--
+	}
+	// Pipelined bytes already buffered belong to the next message.
+	p.msgStart = now
+	return msg
+}
+
+func (p *HTTPStreamParser) nextSeq() int {
+	p.seq++
+	return p.seq
 }
 
 // parseHeaders parses HTTP headers
@@ -205,6 +295,13 @@ func (p *HTTPStreamParser) reset() {
 	p.headerComplete = false
 }
 
+// Buffered returns the memory held by the parser's buffers (capacity, not just pending data).
+func (p *HTTPStreamParser) Buffered() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.buffer.Cap() + len(p.sniffBuf)
+}
+
 // Flush forces out remaining buffered data (e.g., on connection close)
 func (p *HTTPStreamParser) Flush() *HTTPMessage {
 	p.mu.Lock()
@@ -218,9 +315,11 @@ func (p *HTTPStreamParser) Flush() *HTTPMessage {
 	msgType := p.detectMessageType(data)
 
 	msg := &HTTPMessage{
-		Type:      msgType,
-		Data:      make([]byte, len(data)),
-		Timestamp: time.Now(),
+		Type:        msgType,
+		Data:        make([]byte, len(data)),
+		Timestamp:   time.Now(),
+		FirstByteAt: p.msgStart,
+		Seq:         p.nextSeq(),
 	}
 	copy(msg.Data, data)
 
@@ -0,0 +1,109 @@
+package core
+
+import (
+	"bastion/models"
+	"testing"
+	"time"
+)
+
+func TestSniffHTTPPrefix(t *testing.T) {
+	cases := []struct {
+		in      string
+		isHTTP  bool
+		decided bool
+	}{
+		{"", false, false},
+		{"GE", false, false},
+		{"GET / HTTP/1.1\r\n", true, true},
+		{"PROPFIND /x HTTP/1.1", true, true},
+		{"HTTP/1.1 200 OK", true, true},
+		{"HTT", false, false},
+		{"PRI * HTTP/2.0\r\n", false, true},
+		{"\x16\x03\x01\x02\x00", false, true},
+		{"SSH-2.0-OpenSSH_9.6", false, true},
+		{"*1\r\n$4\r\nPING\r\n", false, true},
+		{"220 smtp ready", false, true},
+		{"ABCDEFGHIJKLMNOP", false, true},
+	}
+	for _, tc := range cases {
+		isHTTP, decided := sniffHTTPPrefix([]byte(tc.in))
+		if isHTTP != tc.isHTTP || decided != tc.decided {
+			t.Fatalf("sniffHTTPPrefix(%q) = (%v, %v), want (%v, %v)", tc.in, isHTTP, decided, tc.isHTTP, tc.decided)
+		}
+	}
+}
+
+func TestHTTPStreamParserSniffAcrossChunks(t *testing.T) {
+	p := NewHTTPStreamParser("c", "request")
+	if !p.Sniff([]byte("PO")) {
+		t.Fatalf("undecided prefix must keep the parser")
+	}
+	if !p.Sniff([]byte("ST /api HTTP/1.1\r\n")) {
+		t.Fatalf("expected HTTP after the method completed")
+	}
+	// Once classified, later bytes are not re-sniffed.
+	if !p.Sniff([]byte{0x00, 0x01}) {
+		t.Fatalf("body bytes must not flip the classification")
+	}
+}
+
+func TestFeedHTTPParser_BypassesNonHTTPConnection(t *testing.T) {
+	s := newBaseSession(&models.Mapping{ID: "m"}, nil)
+
+	if s.feedHTTPParser([]byte("SSH-2.0-OpenSSH_9.6\r\n"), "response", "c1") {
+		t.Fatalf("expected non-HTTP stream to be bypassed")
+	}
+	// The other direction of the same connection is bypassed too.
+	if s.feedHTTPParser([]byte("SSH-2.0-Go\r\n"), "request", "c1") {
+		t.Fatalf("expected both directions to be bypassed")
+	}
+	if len(s.httpParsers) != 0 {
+		t.Fatalf("expected parsers to be dropped, got %d", len(s.httpParsers))
+	}
+
+	s.flushHTTPParser("request", "c1")
+	if len(s.nonHTTPConns) != 0 {
+		t.Fatalf("expected bypass state to be cleared on flush")
+	}
+}
+
+func TestHTTPStreamParser_FirstByteAndSeq(t *testing.T) {
+	p := NewHTTPStreamParser("c", "response")
+	if msgs := p.Feed([]byte("HTTP/1.1 200 OK\r\nContent-Length: 4\r\n\r\nab")); len(msgs) != 0 {
+		t.Fatalf("expected incomplete message")
+	}
+	time.Sleep(5 * time.Millisecond)
+	msgs := p.Feed([]byte("cdHTTP/1.1 204 No Content\r\n\r\n"))
+	if len(msgs) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(msgs))
+	}
+	first, second := msgs[0], msgs[1]
+	if first.Seq != 1 || second.Seq != 2 {
+		t.Fatalf("unexpected seq: %d %d", first.Seq, second.Seq)
+	}
+	if first.Timestamp.Sub(first.FirstByteAt) < 5*time.Millisecond {
+		t.Fatalf("first byte time should predate completion: %s -> %s", first.FirstByteAt, first.Timestamp)
+	}
+	if second.FirstByteAt.Before(first.Timestamp) {
+		t.Fatalf("pipelined message must start after the previous one completed")
+	}
+}
+
+func TestCreateHTTPLog_LatencyBreakdown(t *testing.T) {
+	base := time.Now()
+	req := &HTTPMessage{Type: HTTPRequest, Data: []byte("GET / HTTP/1.1\r\n\r\n"), Timestamp: base, Seq: 2}
+	resp := &HTTPMessage{
+		Type:        HTTPResponse,
+		Data:        []byte("HTTP/1.1 200 OK\r\n\r\n"),
+		FirstByteAt: base.Add(30 * time.Millisecond),
+		Timestamp:   base.Add(120 * time.Millisecond),
+	}
+
+	l := (&HTTPPairMatcher{}).createHTTPLog(AuditContext{}, "a->b", req, resp)
+	if l.TTFBMs != 30 || l.TTLBMs != 120 || l.DurationMs != 120 {
+		t.Fatalf("unexpected timings: ttfb=%d ttlb=%d duration=%d", l.TTFBMs, l.TTLBMs, l.DurationMs)
+	}
+	if l.RequestSeq != 2 || !l.ConnectionReused {
+		t.Fatalf("expected reused connection: seq=%d reused=%v", l.RequestSeq, l.ConnectionReused)
+	}
+}
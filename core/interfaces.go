@@ -0,0 +1,77 @@
+package core
+
+import (
+	"fmt"
+	"net"
+)
+
+// NetInterface is a local network interface a mapping can be exposed on.
+type NetInterface struct {
+	Name     string   `json:"name"`
+	Addrs    []string `json:"addrs"` // IP addresses without prefix length
+	Up       bool     `json:"up"`
+	Loopback bool     `json:"loopback"`
+}
+
+// interfaceLister is swapped in tests.
+var interfaceLister = systemInterfaces
+
+// ListInterfaces enumerates local interfaces and their IP addresses.
+func ListInterfaces() ([]NetInterface, error) {
+	return interfaceLister()
+}
+
+func systemInterfaces() ([]NetInterface, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list network interfaces: %w", err)
+	}
+
+	result := make([]NetInterface, 0, len(ifaces))
+	for _, iface := range ifaces {
+		ni := NetInterface{
+			Name:     iface.Name,
+			Addrs:    []string{},
+			Up:       iface.Flags&net.FlagUp != 0,
+			Loopback: iface.Flags&net.FlagLoopback != 0,
+		}
+		addrs, err := iface.Addrs()
+		if err == nil {
+			for _, a := range addrs {
+				if ipNet, ok := a.(*net.IPNet); ok {
+					ni.Addrs = append(ni.Addrs, ipNet.IP.String())
+				}
+			}
+		}
+		result = append(result, ni)
+	}
+	return result, nil
+}
+
+// ValidateExposeAddr checks that addr is a non-loopback IP assigned to a local interface that is up.
+// Wildcard addresses are rejected: exposing is scoped to one interface on purpose.
+func ValidateExposeAddr(addr string) error {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return fmt.Errorf("invalid expose address %q: must be an IP address", addr)
+	}
+	if ip.IsLoopback() || ip.IsUnspecified() {
+		return fmt.Errorf("invalid expose address %q: must be a non-loopback interface address", addr)
+	}
+
+	ifaces, err := ListInterfaces()
+	if err != nil {
+		return err
+	}
+	for _, iface := range ifaces {
+		for _, a := range iface.Addrs {
+			if ip.Equal(net.ParseIP(a)) {
+				if !iface.Up {
+					return fmt.Errorf("invalid expose address %q: interface %s is down", addr, iface.Name)
+				}
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("invalid expose address %q: not assigned to any local interface", addr)
+}
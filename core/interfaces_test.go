@@ -0,0 +1,40 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateExposeAddr(t *testing.T) {
+	old := interfaceLister
+	t.Cleanup(func() { interfaceLister = old })
+	interfaceLister = func() ([]NetInterface, error) {
+		return []NetInterface{
+			{Name: "lo", Addrs: []string{"127.0.0.1", "::1"}, Up: true, Loopback: true},
+			{Name: "tailscale0", Addrs: []string{"100.64.0.5", "fd7a:115c:a1e0::5"}, Up: true},
+			{Name: "eth1", Addrs: []string{"192.168.1.20"}},
+		}, nil
+	}
+
+	for _, addr := range []string{"100.64.0.5", "fd7a:115c:a1e0::5"} {
+		if err := ValidateExposeAddr(addr); err != nil {
+			t.Fatalf("%s: unexpected error: %v", addr, err)
+		}
+	}
+
+	cases := map[string]string{
+		"":             "must be an IP address",
+		"lan.example":  "must be an IP address",
+		"127.0.0.1":    "non-loopback",
+		"::1":          "non-loopback",
+		"0.0.0.0":      "non-loopback",
+		"192.168.1.20": "is down",
+		"10.9.9.9":     "not assigned",
+	}
+	for addr, want := range cases {
+		err := ValidateExposeAddr(addr)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("%q: expected error containing %q, got %v", addr, want, err)
+		}
+	}
+}
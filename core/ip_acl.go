@@ -64,6 +64,10 @@ func (a *IPAccessControl) Allows(ip net.IP) bool {
 	if ip == nil {
 		return false
 	}
+	if ip4 := ip.To4(); ip4 != nil {
+		// IPv4 clients of a dual-stack socket arrive as ::ffff:a.b.c.d
+		ip = ip4
+	}
 
 	for _, n := range a.deny {
 		if n != nil && n.Contains(ip) {
@@ -82,16 +86,29 @@ func (a *IPAccessControl) Allows(ip net.IP) bool {
 	return false
 }
 
+// parseCIDROrIP parses an ACL entry: an IPv4 or IPv6 address or CIDR. IPv6 entries may be
+// bracketed ("[::1]", "[2001:db8::]/32"); a zone ("fe80::1%eth0") is ignored. IPv4-mapped IPv6
+// entries ("::ffff:10.0.0.0/104") are stored as their IPv4 network, so they match IPv4 clients
+// accepted on dual-stack sockets as well.
 func parseCIDROrIP(value string) (net.IP, *net.IPNet, error) {
-	if strings.Contains(value, "/") {
-		ip, ipNet, err := net.ParseCIDR(value)
+	addr, bits, hasBits := strings.Cut(value, "/")
+	addr = strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]")
+	if i := strings.LastIndexByte(addr, '%'); i > 0 {
+		addr = addr[:i]
+	}
+
+	if hasBits {
+		ip, ipNet, err := net.ParseCIDR(addr + "/" + bits)
 		if err != nil {
 			return nil, nil, fmt.Errorf("invalid CIDR %q", value)
 		}
+		if ones, size := ipNet.Mask.Size(); size == net.IPv6len*8 && ones >= 96 && ip.To4() != nil && strings.Contains(addr, ":") {
+			ipNet = &net.IPNet{IP: ipNet.IP.To4(), Mask: net.CIDRMask(ones-96, net.IPv4len*8)}
+		}
 		return ip, ipNet, nil
 	}
 
-	ip := net.ParseIP(value)
+	ip := net.ParseIP(addr)
 	if ip == nil {
 		return nil, nil, fmt.Errorf("invalid IP %q", value)
 	}
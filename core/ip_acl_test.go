@@ -37,3 +37,37 @@ func TestIPAccessControl_NilAllowsAll(t *testing.T) {
 		t.Fatalf("expected allow when acl is nil")
 	}
 }
+
+func TestIPAccessControl_IPv6(t *testing.T) {
+	acl, err := NewIPAccessControl([]string{"[2001:db8::]/32", "::ffff:10.0.0.0/104", "fe80::1%eth0"}, []string{"[2001:db8::bad]"})
+	if err != nil {
+		t.Fatalf("NewIPAccessControl: %v", err)
+	}
+	for ip, want := range map[string]bool{
+		"2001:db8::1":     true,
+		"2001:db8::bad":   false,
+		"2001:db9::1":     false,
+		"10.1.2.3":        true,
+		"::ffff:10.1.2.3": true, // IPv4 client of a dual-stack socket
+		"::ffff:11.1.2.3": false,
+		"fe80::1":         true,
+		"fe80::2":         false,
+		"::1":             false,
+	} {
+		if got := acl.Allows(net.ParseIP(ip)); got != want {
+			t.Fatalf("Allows(%s) = %v, want %v", ip, got, want)
+		}
+	}
+
+	acl, err = NewIPAccessControl([]string{"10.0.0.0/8"}, nil)
+	if err != nil {
+		t.Fatalf("NewIPAccessControl: %v", err)
+	}
+	if !acl.Allows(net.ParseIP("::ffff:10.9.9.9")) {
+		t.Fatalf("expected IPv4-mapped client to match an IPv4 CIDR")
+	}
+
+	if _, err := NewIPAccessControl([]string{"2001:db8::/129"}, nil); err == nil {
+		t.Fatalf("expected an error for an invalid IPv6 prefix")
+	}
+}
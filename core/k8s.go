@@ -0,0 +1,605 @@
+package core
+
+import (
+	"bastion/models"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"gopkg.in/yaml.v3"
+)
+
+// Kubernetes target kinds of k8s mappings
+const (
+	K8sKindPod     = "pod"
+	K8sKindService = "service"
+)
+
+const (
+	// k8sPortForwardProtocol is the websocket port-forward protocol of the API server: channel 0
+	// carries the data and channel 1 the errors of the one forwarded port.
+	k8sPortForwardProtocol = "v4.channel.k8s.io"
+	k8sRequestTimeout      = 30 * time.Second
+)
+
+var (
+	k8sNamePattern  = regexp.MustCompile(`^[a-z0-9]([-a-z0-9.]*[a-z0-9])?$`)
+	k8sLabelPattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+)
+
+// K8sTarget is the pod or service a k8s mapping forwards to.
+type K8sTarget struct {
+	Kind string // K8sKindPod or K8sKindService
+	Name string
+}
+
+func (t K8sTarget) String() string {
+	if t.Kind == K8sKindService {
+		return "svc/" + t.Name
+	}
+	return "pod/" + t.Name
+}
+
+// ParseK8sTarget parses the remote host of a k8s mapping, pod/NAME or svc/NAME like kubectl
+// port-forward; a bare name is a pod.
+func ParseK8sTarget(s string) (K8sTarget, error) {
+	kind, name, found := strings.Cut(s, "/")
+	if !found {
+		kind, name = K8sKindPod, s
+	}
+	switch strings.ToLower(kind) {
+	case "pod", "pods", "po":
+		kind = K8sKindPod
+	case "svc", "service", "services":
+		kind = K8sKindService
+	default:
+		return K8sTarget{}, fmt.Errorf("invalid kubernetes target %q: use pod/NAME or svc/NAME", s)
+	}
+	if len(name) > 253 || !k8sNamePattern.MatchString(name) {
+		return K8sTarget{}, fmt.Errorf("invalid kubernetes target %q: %q is not a valid resource name", s, name)
+	}
+	return K8sTarget{Kind: kind, Name: name}, nil
+}
+
+// ValidateK8sNamespace checks a namespace name (a DNS label).
+func ValidateK8sNamespace(ns string) error {
+	if len(ns) > 63 || !k8sLabelPattern.MatchString(ns) {
+		return fmt.Errorf("invalid kubernetes namespace %q", ns)
+	}
+	return nil
+}
+
+// DefaultKubeconfigPath is the kubeconfig k8s mappings use when they name none: the first file of
+// $KUBECONFIG, else ~/.kube/config.
+func DefaultKubeconfigPath() string {
+	if env := os.Getenv("KUBECONFIG"); env != "" {
+		return filepath.SplitList(env)[0]
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".kube", "config")
+}
+
+// kubeconfig is the subset of a kubeconfig file k8s mappings use.
+type kubeconfig struct {
+	CurrentContext string `yaml:"current-context"`
+	Clusters       []struct {
+		Name    string `yaml:"name"`
+		Cluster struct {
+			Server                   string `yaml:"server"`
+			CertificateAuthority     string `yaml:"certificate-authority"`
+			CertificateAuthorityData string `yaml:"certificate-authority-data"`
+			InsecureSkipTLSVerify    bool   `yaml:"insecure-skip-tls-verify"`
+			TLSServerName            string `yaml:"tls-server-name"`
+		} `yaml:"cluster"`
+	} `yaml:"clusters"`
+	Contexts []struct {
+		Name    string `yaml:"name"`
+		Context struct {
+			Cluster   string `yaml:"cluster"`
+			User      string `yaml:"user"`
+			Namespace string `yaml:"namespace"`
+		} `yaml:"context"`
+	} `yaml:"contexts"`
+	Users []struct {
+		Name string `yaml:"name"`
+		User struct {
+			Token                 string     `yaml:"token"`
+			TokenFile             string     `yaml:"tokenFile"`
+			ClientCertificate     string     `yaml:"client-certificate"`
+			ClientCertificateData string     `yaml:"client-certificate-data"`
+			ClientKey             string     `yaml:"client-key"`
+			ClientKeyData         string     `yaml:"client-key-data"`
+			Username              string     `yaml:"username"`
+			Password              string     `yaml:"password"`
+			Exec                  *yaml.Node `yaml:"exec"`
+			AuthProvider          *yaml.Node `yaml:"auth-provider"`
+		} `yaml:"user"`
+	} `yaml:"users"`
+}
+
+// k8sAPI is how to reach and authenticate to an API server.
+type k8sAPI struct {
+	server    *url.URL
+	tls       *tls.Config
+	header    http.Header
+	namespace string // of the context, "default" when unset
+}
+
+// loadK8sAPI reads the API server of context contextName (the current one when empty) from the
+// kubeconfig at path. Credential plugins (exec, auth-provider) are not supported.
+func loadK8sAPI(path, contextName string) (*k8sAPI, error) {
+	if strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, path[2:])
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("kubeconfig: %w", err)
+	}
+	var cfg kubeconfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("kubeconfig %s: %w", path, err)
+	}
+	dir := filepath.Dir(path)
+	file := func(name string) string {
+		if name == "" || filepath.IsAbs(name) {
+			return name
+		}
+		return filepath.Join(dir, name)
+	}
+
+	if contextName == "" {
+		contextName = cfg.CurrentContext
+	}
+	if contextName == "" {
+		return nil, fmt.Errorf("kubeconfig %s: no context given and no current-context set", path)
+	}
+	api := &k8sAPI{header: http.Header{}, namespace: "default"}
+	var clusterName, userName string
+	found := false
+	for _, c := range cfg.Contexts {
+		if c.Name == contextName {
+			clusterName, userName, found = c.Context.Cluster, c.Context.User, true
+			if c.Context.Namespace != "" {
+				api.namespace = c.Context.Namespace
+			}
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("kubeconfig %s: context %q not found", path, contextName)
+	}
+
+	found = false
+	api.tls = &tls.Config{MinVersion: tls.VersionTLS12}
+	for _, c := range cfg.Clusters {
+		if c.Name != clusterName {
+			continue
+		}
+		found = true
+		if api.server, err = url.Parse(c.Cluster.Server); err != nil || api.server.Host == "" {
+			return nil, fmt.Errorf("kubeconfig %s: cluster %q has an invalid server %q", path, clusterName, c.Cluster.Server)
+		}
+		if api.server.Scheme != "https" && api.server.Scheme != "http" {
+			return nil, fmt.Errorf("kubeconfig %s: cluster %q server must be https:// or http://", path, clusterName)
+		}
+		api.tls.InsecureSkipVerify = c.Cluster.InsecureSkipTLSVerify
+		api.tls.ServerName = c.Cluster.TLSServerName
+		ca, err := kubeconfigData(c.Cluster.CertificateAuthorityData, file(c.Cluster.CertificateAuthority))
+		if err != nil {
+			return nil, fmt.Errorf("kubeconfig %s: cluster %q certificate authority: %w", path, clusterName, err)
+		}
+		if ca != nil {
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(ca) {
+				return nil, fmt.Errorf("kubeconfig %s: cluster %q certificate authority has no PEM certificate", path, clusterName)
+			}
+			api.tls.RootCAs = pool
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("kubeconfig %s: cluster %q not found", path, clusterName)
+	}
+
+	for _, u := range cfg.Users {
+		if u.Name != userName {
+			continue
+		}
+		user := u.User
+		if user.Exec != nil || user.AuthProvider != nil {
+			return nil, fmt.Errorf("kubeconfig %s: user %q uses a credential plugin, which is not supported; use a token or a client certificate", path, userName)
+		}
+		switch {
+		case user.Token != "":
+			api.header.Set("Authorization", "Bearer "+user.Token)
+		case user.TokenFile != "":
+			token, err := os.ReadFile(file(user.TokenFile))
+			if err != nil {
+				return nil, fmt.Errorf("kubeconfig %s: user %q token: %w", path, userName, err)
+			}
+			api.header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+		case user.Username != "":
+			api.header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(user.Username+":"+user.Password)))
+		}
+		cert, err := kubeconfigData(user.ClientCertificateData, file(user.ClientCertificate))
+		if err != nil {
+			return nil, fmt.Errorf("kubeconfig %s: user %q client certificate: %w", path, userName, err)
+		}
+		key, err := kubeconfigData(user.ClientKeyData, file(user.ClientKey))
+		if err != nil {
+			return nil, fmt.Errorf("kubeconfig %s: user %q client key: %w", path, userName, err)
+		}
+		if cert != nil || key != nil {
+			pair, err := tls.X509KeyPair(cert, key)
+			if err != nil {
+				return nil, fmt.Errorf("kubeconfig %s: user %q client certificate: %w", path, userName, err)
+			}
+			api.tls.Certificates = []tls.Certificate{pair}
+		}
+	}
+	return api, nil
+}
+
+// kubeconfigData returns the base64 data of a kubeconfig field, else the content of its file.
+func kubeconfigData(data, file string) ([]byte, error) {
+	if data != "" {
+		return base64.StdEncoding.DecodeString(data)
+	}
+	if file != "" {
+		return os.ReadFile(file)
+	}
+	return nil, nil
+}
+
+// url returns the URL of path on the API server, which may have a path prefix (e.g. a proxy).
+func (a *k8sAPI) url(scheme, path string, query url.Values) string {
+	u := *a.server
+	u.Scheme = scheme
+	u.Path = strings.TrimRight(u.Path, "/") + path
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// k8sPortForward opens port-forward streams to the pod or service of a k8s mapping.
+type k8sPortForward struct {
+	api       *k8sAPI
+	namespace string
+	target    K8sTarget
+	port      int
+}
+
+// newK8sPortForward loads the kubeconfig of a k8s mapping: K8sKubeconfig (DefaultKubeconfigPath
+// when empty), its K8sContext and K8sNamespace (those of the context when empty).
+func newK8sPortForward(mapping *models.Mapping) (*k8sPortForward, error) {
+	target, err := ParseK8sTarget(mapping.RemoteHost)
+	if err != nil {
+		return nil, err
+	}
+	path := mapping.K8sKubeconfig
+	if path == "" {
+		path = DefaultKubeconfigPath()
+	}
+	api, err := loadK8sAPI(path, mapping.K8sContext)
+	if err != nil {
+		return nil, err
+	}
+	f := &k8sPortForward{api: api, namespace: mapping.K8sNamespace, target: target, port: mapping.RemotePort}
+	if f.namespace == "" {
+		f.namespace = api.namespace
+	}
+	return f, nil
+}
+
+// Dial opens a port-forward stream to the target through the API server, whose connections come
+// from dial (the bastion chain). A service is resolved to one of its ready pods on every dial, so
+// new connections follow pods being replaced.
+func (f *k8sPortForward) Dial(dial func(addr string) (net.Conn, error)) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), k8sRequestTimeout)
+	defer cancel()
+	netDial := func(_ context.Context, _, addr string) (net.Conn, error) {
+		return dial(addr)
+	}
+
+	pod, port := f.target.Name, f.port
+	if f.target.Kind == K8sKindService {
+		client := &http.Client{
+			Timeout:   k8sRequestTimeout,
+			Transport: &http.Transport{DialContext: netDial, TLSClientConfig: f.api.tls, DisableKeepAlives: true},
+		}
+		var err error
+		if pod, port, err = f.resolveService(ctx, client); err != nil {
+			return nil, err
+		}
+	}
+
+	scheme := "wss"
+	if f.api.server.Scheme == "http" {
+		scheme = "ws"
+	}
+	path := fmt.Sprintf("/api/v1/namespaces/%s/pods/%s/portforward", url.PathEscape(f.namespace), url.PathEscape(pod))
+	dialer := websocket.Dialer{
+		NetDialContext:   netDial,
+		TLSClientConfig:  f.api.tls,
+		Subprotocols:     []string{k8sPortForwardProtocol},
+		HandshakeTimeout: k8sRequestTimeout,
+	}
+	ws, resp, err := dialer.DialContext(ctx, f.api.url(scheme, path, url.Values{"ports": {strconv.Itoa(port)}}), f.api.header)
+	if err != nil {
+		if resp != nil && resp.StatusCode != http.StatusSwitchingProtocols {
+			defer resp.Body.Close()
+			return nil, fmt.Errorf("port-forward to pod %s port %d: %w", pod, port, k8sStatusError(resp))
+		}
+		return nil, fmt.Errorf("port-forward to pod %s port %d: %w", pod, port, err)
+	}
+	if ws.Subprotocol() != k8sPortForwardProtocol {
+		_ = ws.Close()
+		return nil, fmt.Errorf("port-forward to pod %s: the API server does not support the %s protocol", pod, k8sPortForwardProtocol)
+	}
+	return newK8sStreamConn(ws), nil
+}
+
+// k8sIntOrString is a service targetPort: a port number or the name of a container port.
+type k8sIntOrString struct {
+	port int
+	name string
+}
+
+func (v *k8sIntOrString) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		return json.Unmarshal(data, &v.name)
+	}
+	return json.Unmarshal(data, &v.port)
+}
+
+type k8sService struct {
+	Spec struct {
+		Selector map[string]string `json:"selector"`
+		Ports    []struct {
+			Port       int            `json:"port"`
+			Protocol   string         `json:"protocol"`
+			TargetPort k8sIntOrString `json:"targetPort"`
+		} `json:"ports"`
+	} `json:"spec"`
+}
+
+type k8sPod struct {
+	Metadata struct {
+		Name              string  `json:"name"`
+		DeletionTimestamp *string `json:"deletionTimestamp"`
+	} `json:"metadata"`
+	Spec struct {
+		Containers []struct {
+			Ports []struct {
+				Name          string `json:"name"`
+				ContainerPort int    `json:"containerPort"`
+			} `json:"ports"`
+		} `json:"containers"`
+	} `json:"spec"`
+	Status struct {
+		Phase      string `json:"phase"`
+		Conditions []struct {
+			Type   string `json:"type"`
+			Status string `json:"status"`
+		} `json:"conditions"`
+	} `json:"status"`
+}
+
+func (p *k8sPod) ready() bool {
+	if p.Metadata.DeletionTimestamp != nil || p.Status.Phase != "Running" {
+		return false
+	}
+	for _, c := range p.Status.Conditions {
+		if c.Type == "Ready" {
+			return c.Status == "True"
+		}
+	}
+	return false
+}
+
+// resolveService picks a ready pod of the service, by name, and the pod port behind the service
+// port, like kubectl port-forward svc/NAME.
+func (f *k8sPortForward) resolveService(ctx context.Context, client *http.Client) (string, int, error) {
+	ns := url.PathEscape(f.namespace)
+	var svc k8sService
+	if err := f.get(ctx, client, "/api/v1/namespaces/"+ns+"/services/"+url.PathEscape(f.target.Name), nil, &svc); err != nil {
+		return "", 0, fmt.Errorf("service %s: %w", f.target.Name, err)
+	}
+	found := false
+	target := k8sIntOrString{port: f.port}
+	for _, p := range svc.Spec.Ports {
+		if p.Port == f.port && (p.Protocol == "" || p.Protocol == "TCP") {
+			found = true
+			if p.TargetPort.port != 0 || p.TargetPort.name != "" {
+				target = p.TargetPort
+			}
+		}
+	}
+	if !found {
+		return "", 0, fmt.Errorf("service %s has no TCP port %d", f.target.Name, f.port)
+	}
+	if len(svc.Spec.Selector) == 0 {
+		return "", 0, fmt.Errorf("service %s has no selector", f.target.Name)
+	}
+
+	keys := make([]string, 0, len(svc.Spec.Selector))
+	for k := range svc.Spec.Selector {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	selector := make([]string, len(keys))
+	for i, k := range keys {
+		selector[i] = k + "=" + svc.Spec.Selector[k]
+	}
+	var pods struct {
+		Items []k8sPod `json:"items"`
+	}
+	if err := f.get(ctx, client, "/api/v1/namespaces/"+ns+"/pods", url.Values{"labelSelector": {strings.Join(selector, ",")}}, &pods); err != nil {
+		return "", 0, fmt.Errorf("pods of service %s: %w", f.target.Name, err)
+	}
+	sort.Slice(pods.Items, func(i, j int) bool { return pods.Items[i].Metadata.Name < pods.Items[j].Metadata.Name })
+	for _, pod := range pods.Items {
+		if !pod.ready() {
+			continue
+		}
+		if target.name == "" {
+			return pod.Metadata.Name, target.port, nil
+		}
+		for _, c := range pod.Spec.Containers {
+			for _, p := range c.Ports {
+				if p.Name == target.name {
+					return pod.Metadata.Name, p.ContainerPort, nil
+				}
+			}
+		}
+		return "", 0, fmt.Errorf("pod %s of service %s has no port named %q", pod.Metadata.Name, f.target.Name, target.name)
+	}
+	return "", 0, fmt.Errorf("service %s has no ready pod", f.target.Name)
+}
+
+// get fetches path from the API server into out.
+func (f *k8sPortForward) get(ctx context.Context, client *http.Client, path string, query url.Values, out interface{}) error {
+	scheme := f.api.server.Scheme
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.api.url(scheme, path, query), nil)
+	if err != nil {
+		return err
+	}
+	for k, v := range f.api.header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return k8sStatusError(resp)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// k8sStatusError describes a failed API server response by the message of its Status body.
+func k8sStatusError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+	var status struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body, &status) == nil && status.Message != "" {
+		return fmt.Errorf("%s: %s", resp.Status, status.Message)
+	}
+	if msg := strings.TrimSpace(string(body)); msg != "" {
+		return fmt.Errorf("%s: %s", resp.Status, msg)
+	}
+	return errors.New(resp.Status)
+}
+
+// k8sStreamConn is a port-forward stream as a net.Conn. Every websocket message starts with its
+// channel: 0 for data, 1 for errors. The first message of each channel holds the port number.
+type k8sStreamConn struct {
+	ws       *websocket.Conn
+	data     io.Reader // rest of the data message being read
+	portRead [2]bool
+	writeMu  sync.Mutex
+}
+
+func newK8sStreamConn(ws *websocket.Conn) *k8sStreamConn {
+	return &k8sStreamConn{ws: ws}
+}
+
+func (c *k8sStreamConn) Read(p []byte) (int, error) {
+	for {
+		if c.data != nil {
+			n, err := c.data.Read(p)
+			if err == io.EOF {
+				c.data = nil
+				if n == 0 {
+					continue
+				}
+				err = nil
+			}
+			return n, err
+		}
+
+		_, r, err := c.ws.NextReader()
+		if err != nil {
+			var closeErr *websocket.CloseError
+			if errors.As(err, &closeErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return 0, io.EOF
+			}
+			return 0, err
+		}
+		var channel [1]byte
+		if _, err := io.ReadFull(r, channel[:]); err != nil || channel[0] > 1 {
+			continue
+		}
+		if !c.portRead[channel[0]] {
+			c.portRead[channel[0]] = true
+			if _, err := io.CopyN(io.Discard, r, 2); err != nil {
+				continue
+			}
+		}
+		if channel[0] == 0 {
+			c.data = r
+			continue
+		}
+		msg, _ := io.ReadAll(io.LimitReader(r, 4<<10))
+		if len(msg) > 0 {
+			return 0, fmt.Errorf("port-forward: %s", msg)
+		}
+	}
+}
+
+func (c *k8sStreamConn) Write(p []byte) (int, error) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	w, err := c.ws.NextWriter(websocket.BinaryMessage)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := w.Write([]byte{0}); err != nil {
+		return 0, err
+	}
+	n, err := w.Write(p)
+	if err != nil {
+		return n, err
+	}
+	return n, w.Close()
+}
+
+func (c *k8sStreamConn) Close() error {
+	_ = c.ws.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+	return c.ws.Close()
+}
+
+func (c *k8sStreamConn) LocalAddr() net.Addr  { return c.ws.LocalAddr() }
+func (c *k8sStreamConn) RemoteAddr() net.Addr { return c.ws.RemoteAddr() }
+
+func (c *k8sStreamConn) SetDeadline(t time.Time) error {
+	if err := c.ws.SetReadDeadline(t); err != nil {
+		return err
+	}
+	return c.ws.SetWriteDeadline(t)
+}
+
+func (c *k8sStreamConn) SetReadDeadline(t time.Time) error  { return c.ws.SetReadDeadline(t) }
+func (c *k8sStreamConn) SetWriteDeadline(t time.Time) error { return c.ws.SetWriteDeadline(t) }
@@ -0,0 +1,204 @@
+package core
+
+import (
+	"bastion/models"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestParseK8sTarget(t *testing.T) {
+	cases := []struct {
+		in   string
+		want K8sTarget
+		ok   bool
+	}{
+		{"web-0", K8sTarget{K8sKindPod, "web-0"}, true},
+		{"pod/web-0", K8sTarget{K8sKindPod, "web-0"}, true},
+		{"svc/postgres", K8sTarget{K8sKindService, "postgres"}, true},
+		{"service/postgres", K8sTarget{K8sKindService, "postgres"}, true},
+		{"deploy/web", K8sTarget{}, false},
+		{"svc/Postgres", K8sTarget{}, false},
+		{"svc/", K8sTarget{}, false},
+	}
+	for _, tc := range cases {
+		got, err := ParseK8sTarget(tc.in)
+		if (err == nil) != tc.ok || got != tc.want {
+			t.Errorf("ParseK8sTarget(%q) = %+v, %v", tc.in, got, err)
+		}
+	}
+}
+
+func writeKubeconfig(t *testing.T, dir, server, user string) string {
+	t.Helper()
+	path := filepath.Join(dir, "config")
+	config := `apiVersion: v1
+kind: Config
+current-context: dev
+clusters:
+- name: dev
+  cluster:
+    server: ` + server + `
+    insecure-skip-tls-verify: true
+contexts:
+- name: dev
+  context: {cluster: dev, user: dev, namespace: apps}
+- name: other
+  context: {cluster: dev, user: dev}
+users:
+- name: dev
+  user:
+` + user
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadK8sAPI(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "token"), []byte("secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	path := writeKubeconfig(t, dir, "https://10.0.0.1:6443/prefix", "    tokenFile: token\n")
+
+	api, err := loadK8sAPI(path, "")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if api.namespace != "apps" || api.header.Get("Authorization") != "Bearer secret" || !api.tls.InsecureSkipVerify {
+		t.Fatalf("api = %+v", api)
+	}
+	if got := api.url("wss", "/api/v1/x", nil); got != "wss://10.0.0.1:6443/prefix/api/v1/x" {
+		t.Fatalf("url = %s", got)
+	}
+
+	if api, err = loadK8sAPI(path, "other"); err != nil || api.namespace != "default" {
+		t.Fatalf("other context: %+v, %v", api, err)
+	}
+	if _, err := loadK8sAPI(path, "missing"); err == nil {
+		t.Fatal("expected an error for an unknown context")
+	}
+
+	path = writeKubeconfig(t, dir, "https://10.0.0.1:6443", "    exec: {command: aws}\n")
+	if _, err := loadK8sAPI(path, ""); err == nil || !strings.Contains(err.Error(), "credential plugin") {
+		t.Fatalf("exec credentials: %v", err)
+	}
+}
+
+// fakeK8sAPI serves a service "db" (port 5432 -> container port "pg") selecting the pods "db-0"
+// (not ready) and "db-1", and port-forwards to an echo server prefixed with the pod name.
+func fakeK8sAPI(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/namespaces/apps/services/db", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"kind":"Status","message":"Unauthorized"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"spec":{"selector":{"app":"db"},"ports":[{"port":5432,"protocol":"TCP","targetPort":"pg"}]}}`))
+	})
+	mux.HandleFunc("/api/v1/namespaces/apps/pods", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("labelSelector") != "app=db" {
+			t.Errorf("labelSelector = %q", r.URL.Query().Get("labelSelector"))
+		}
+		pod := func(name, ready string) map[string]interface{} {
+			return map[string]interface{}{
+				"metadata": map[string]interface{}{"name": name},
+				"spec":     map[string]interface{}{"containers": []interface{}{map[string]interface{}{"ports": []interface{}{map[string]interface{}{"name": "pg", "containerPort": 15432}}}}},
+				"status":   map[string]interface{}{"phase": "Running", "conditions": []interface{}{map[string]interface{}{"type": "Ready", "status": ready}}},
+			}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"items": []interface{}{pod("db-1", "True"), pod("db-0", "False")}})
+	})
+	upgrader := websocket.Upgrader{Subprotocols: []string{k8sPortForwardProtocol}}
+	mux.HandleFunc("/api/v1/namespaces/apps/pods/", func(w http.ResponseWriter, r *http.Request) {
+		pod := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/v1/namespaces/apps/pods/"), "/portforward")
+		if r.URL.Query().Get("ports") != "15432" {
+			t.Errorf("ports = %q", r.URL.Query().Get("ports"))
+		}
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer ws.Close()
+		port := make([]byte, 2)
+		binary.LittleEndian.PutUint16(port, 15432)
+		_ = ws.WriteMessage(websocket.BinaryMessage, append([]byte{0}, port...))
+		_ = ws.WriteMessage(websocket.BinaryMessage, append([]byte{1}, port...))
+		for {
+			_, msg, err := ws.ReadMessage()
+			if err != nil {
+				return
+			}
+			if len(msg) == 0 || msg[0] != 0 {
+				continue
+			}
+			reply := append([]byte{0}, []byte(pod+":")...)
+			if err := ws.WriteMessage(websocket.BinaryMessage, append(reply, msg[1:]...)); err != nil {
+				return
+			}
+		}
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestK8sPortForwardService(t *testing.T) {
+	server := fakeK8sAPI(t)
+	defer server.Close()
+	path := writeKubeconfig(t, t.TempDir(), server.URL, "    token: secret\n")
+
+	forward, err := newK8sPortForward(&models.Mapping{RemoteHost: "svc/db", RemotePort: 5432, K8sKubeconfig: path})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	var dialed []string
+	conn, err := forward.Dial(func(addr string) (net.Conn, error) {
+		dialed = append(dialed, addr)
+		return net.Dial("tcp", addr)
+	})
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	// The service, its pods, then the port-forward stream, all through the dial function.
+	if len(dialed) != 3 {
+		t.Fatalf("dialed %v, want 3 connections", dialed)
+	}
+
+	if _, err := conn.Write([]byte("hello")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	buf := make([]byte, len("db-1:hello"))
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if !bytes.Equal(buf, []byte("db-1:hello")) {
+		t.Fatalf("read %q, want the ready pod db-1 to echo", buf)
+	}
+}
+
+func TestK8sPortForwardAPIError(t *testing.T) {
+	server := fakeK8sAPI(t)
+	defer server.Close()
+	path := writeKubeconfig(t, t.TempDir(), server.URL, "    token: wrong\n")
+
+	forward, err := newK8sPortForward(&models.Mapping{RemoteHost: "svc/db", RemotePort: 5432, K8sKubeconfig: path})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	_, err = forward.Dial(func(addr string) (net.Conn, error) { return net.Dial("tcp", addr) })
+	if err == nil || !strings.Contains(err.Error(), "Unauthorized") {
+		t.Fatalf("dial error = %v, want the API server's message", err)
+	}
+}
@@ -2,31 +2,214 @@ package core
 
 import (
 	"bastion/models"
+	"errors"
 	"fmt"
 	"net"
 	"strconv"
+	"sync"
 )
 
+// listen binds the session's local address and returns it. With local_port 0 the OS picks a free
+// port, which LocalPort then reports and audit logs record. Every session type starts with it, so
+// it first refuses an upstream_proxy that could not be parsed rather than dialing without it.
+func (s *BaseSession) listen() (string, error) {
+	if s.upstreamErr != nil {
+		return "", s.upstreamErr
+	}
+	listener, err := listenTCPWithDiagnostics(s.Mapping)
+	if err != nil {
+		return "", err
+	}
+	s.listener = listener
+	s.auditCtx.LocalPort = s.LocalPort()
+	s.advertise()
+	return listener.Addr().String(), nil
+}
+
+// LocalPort returns the port the session listens on (the OS-assigned one when local_port is 0).
+func (s *BaseSession) LocalPort() int {
+	if s.listener != nil {
+		if addr, ok := s.listener.Addr().(*net.TCPAddr); ok {
+			return addr.Port
+		}
+	}
+	if s.Mapping == nil {
+		return 0
+	}
+	return s.Mapping.LocalPort
+}
+
+// listenTarget is one socket a mapping's listener binds.
+type listenTarget struct {
+	network string // tcp, tcp4 or tcp6
+	host    string
+}
+
+// listenTargets returns the sockets a mapping listens on: its listen host in the networks its
+// listen family selects. Dual binds the host's counterpart in the other family as well (::1 for
+// 127.0.0.1, :: for 0.0.0.0, both resolutions of a name), the mapping's own family first.
+func listenTargets(mapping *models.Mapping) []listenTarget {
+	host := mapping.ListenHost()
+	switch mapping.ListenFamily {
+	case models.ListenFamilyIPv4:
+		return []listenTarget{{"tcp4", host}}
+	case models.ListenFamilyIPv6:
+		return []listenTarget{{"tcp6", host}}
+	case models.ListenFamilyDual:
+		ip := net.ParseIP(host)
+		switch {
+		case ip == nil:
+			return []listenTarget{{"tcp4", host}, {"tcp6", host}}
+		case ip.IsLoopback() && ip.To4() != nil:
+			return []listenTarget{{"tcp4", host}, {"tcp6", "::1"}}
+		case ip.IsLoopback():
+			return []listenTarget{{"tcp6", host}, {"tcp4", "127.0.0.1"}}
+		case ip.IsUnspecified() && ip.To4() != nil:
+			return []listenTarget{{"tcp4", host}, {"tcp6", "::"}}
+		case ip.IsUnspecified():
+			return []listenTarget{{"tcp6", host}, {"tcp4", "0.0.0.0"}}
+		}
+	}
+	return []listenTarget{{"tcp", host}}
+}
+
+// ProbeListen binds and releases the mapping's listen sockets, telling whether it could start now.
+// A busy port is reported as a *PortInUseError naming its owner when it can be found.
+func ProbeListen(mapping *models.Mapping) error {
+	listener, err := listenTCPWithDiagnostics(mapping)
+	if err != nil {
+		return err
+	}
+	return listener.Close()
+}
+
 func listenTCPWithDiagnostics(mapping *models.Mapping) (net.Listener, error) {
 	if mapping == nil {
 		return nil, fmt.Errorf("mapping cannot be nil")
 	}
 
-	addr := net.JoinHostPort(mapping.LocalHost, strconv.Itoa(mapping.LocalPort))
-	listener, err := net.Listen("tcp", addr)
+	var listeners []net.Listener
+	closeAll := func() {
+		for _, l := range listeners {
+			_ = l.Close()
+		}
+	}
+	port := mapping.LocalPort
+	for _, target := range listenTargets(mapping) {
+		listener, err := listenTCP(target, port)
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		listeners = append(listeners, listener)
+		if addr, ok := listener.Addr().(*net.TCPAddr); ok && port == 0 {
+			// Every family binds the port the OS picked for the first one.
+			port = addr.Port
+		}
+	}
+	if len(listeners) == 1 {
+		return listeners[0], nil
+	}
+	return newMultiListener(listeners), nil
+}
+
+func listenTCP(target listenTarget, port int) (net.Listener, error) {
+	addr := net.JoinHostPort(target.host, strconv.Itoa(port))
+	listener, err := net.Listen(target.network, addr)
 	if err == nil {
 		return listener, nil
 	}
 
+	if isBindForbidden(err) {
+		detail := DiagnoseForbiddenBind("tcp", target.host, port)
+		detail.ListenError = err.Error()
+		msg := fmt.Sprintf("Port %d cannot be bound: access denied", port)
+		if detail.Reason == PortReasonExcludedRange {
+			msg = fmt.Sprintf("Port %d cannot be bound: it is in a Windows excluded port range", port)
+		}
+		return nil, &PortInUseError{Detail: detail, Cause: NewResourceBusyError(msg)}
+	}
 	if !isAddrInUse(err) {
 		return nil, err
 	}
 
-	detail := DiagnosePortInUse("tcp", mapping.LocalHost, mapping.LocalPort)
+	detail := DiagnosePortInUse("tcp", target.host, port)
 	detail.ListenError = err.Error()
 
 	return nil, &PortInUseError{
 		Detail: detail,
-		Cause:  NewResourceBusyError(fmt.Sprintf("Port %d is already in use", mapping.LocalPort)),
+		Cause:  NewResourceBusyError(fmt.Sprintf("Port %d is already in use", port)),
+	}
+}
+
+// multiListener accepts connections from several listeners (one per address family) as one. Addr
+// is the first listener's address.
+type multiListener struct {
+	listeners []net.Listener
+	conns     chan net.Conn
+	errs      chan error
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newMultiListener(listeners []net.Listener) *multiListener {
+	m := &multiListener{
+		listeners: listeners,
+		conns:     make(chan net.Conn),
+		errs:      make(chan error),
+		done:      make(chan struct{}),
+	}
+	for _, l := range listeners {
+		go m.acceptLoop(l)
 	}
+	return m
+}
+
+func (m *multiListener) acceptLoop(l net.Listener) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			select {
+			case m.errs <- err:
+			case <-m.done:
+				return
+			}
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+		select {
+		case m.conns <- conn:
+		case <-m.done:
+			_ = conn.Close()
+			return
+		}
+	}
+}
+
+func (m *multiListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-m.conns:
+		return conn, nil
+	case err := <-m.errs:
+		return nil, err
+	case <-m.done:
+		return nil, net.ErrClosed
+	}
+}
+
+func (m *multiListener) Close() error {
+	var errs []error
+	m.closeOnce.Do(func() {
+		close(m.done)
+		for _, l := range m.listeners {
+			errs = append(errs, l.Close())
+		}
+	})
+	return errors.Join(errs...)
+}
+
+func (m *multiListener) Addr() net.Addr {
+	return m.listeners[0].Addr()
 }
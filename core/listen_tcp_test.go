@@ -0,0 +1,85 @@
+package core
+
+import (
+	"net"
+	"strconv"
+	"testing"
+
+	"bastion/models"
+)
+
+func TestSession_AutoLocalPort(t *testing.T) {
+	mapping := &models.Mapping{ID: "auto", LocalHost: "127.0.0.1", LocalPort: 0, RemoteHost: "127.0.0.1", RemotePort: 9, Type: "tcp"}
+	session := NewTunnelSession(mapping, nil)
+	if err := session.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(session.Stop)
+
+	port := session.LocalPort()
+	if port == 0 {
+		t.Fatalf("expected an OS-assigned port")
+	}
+	if mapping.LocalPort != 0 {
+		t.Fatalf("mapping config must keep local_port 0, got %d", mapping.LocalPort)
+	}
+	if got := session.GetStats().LocalPort; got != port {
+		t.Fatalf("stats local port = %d, want %d", got, port)
+	}
+	if session.auditCtx.LocalPort != port {
+		t.Fatalf("audit context local port = %d, want %d", session.auditCtx.LocalPort, port)
+	}
+
+	conn, err := net.Dial("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+	if err != nil {
+		t.Fatalf("dial assigned port: %v", err)
+	}
+	_ = conn.Close()
+}
+
+func TestSession_DualStackListen(t *testing.T) {
+	if l, err := net.Listen("tcp6", "[::1]:0"); err != nil {
+		t.Skipf("IPv6 loopback unavailable: %v", err)
+	} else {
+		_ = l.Close()
+	}
+
+	mapping := &models.Mapping{ID: "dual", LocalHost: "127.0.0.1", ListenFamily: models.ListenFamilyDual, RemoteHost: "127.0.0.1", RemotePort: 9, Type: "tcp"}
+	session := NewTunnelSession(mapping, nil)
+	if err := session.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(session.Stop)
+
+	port := strconv.Itoa(session.LocalPort())
+	for _, host := range []string{"127.0.0.1", "::1"} {
+		conn, err := net.Dial("tcp", net.JoinHostPort(host, port))
+		if err != nil {
+			t.Fatalf("dial %s: %v", host, err)
+		}
+		_ = conn.Close()
+	}
+}
+
+func TestListenTargets(t *testing.T) {
+	for _, tc := range []struct {
+		host, family string
+		want         []listenTarget
+	}{
+		{"127.0.0.1", "", []listenTarget{{"tcp", "127.0.0.1"}}},
+		{"::1", models.ListenFamilyDual, []listenTarget{{"tcp6", "::1"}, {"tcp4", "127.0.0.1"}}},
+		{"0.0.0.0", models.ListenFamilyDual, []listenTarget{{"tcp4", "0.0.0.0"}, {"tcp6", "::"}}},
+		{"localhost", models.ListenFamilyDual, []listenTarget{{"tcp4", "localhost"}, {"tcp6", "localhost"}}},
+		{"localhost", models.ListenFamilyIPv6, []listenTarget{{"tcp6", "localhost"}}},
+	} {
+		got := listenTargets(&models.Mapping{LocalHost: tc.host, ListenFamily: tc.family})
+		if len(got) != len(tc.want) {
+			t.Fatalf("%s/%s: %v, want %v", tc.host, tc.family, got, tc.want)
+		}
+		for i := range got {
+			if got[i] != tc.want[i] {
+				t.Fatalf("%s/%s: %v, want %v", tc.host, tc.family, got, tc.want)
+			}
+		}
+	}
+}
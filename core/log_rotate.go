@@ -0,0 +1,254 @@
+package core
+
+import (
+	"bastion/config"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LogRotation is the rotation policy of a log file. Backups are named path.1 (newest) to
+// path.N; with Compress every backup but the newest is gzipped (path.2.gz, ...).
+type LogRotation struct {
+	MaxSizeBytes int64         // rotate once the file would grow beyond this, 0 = never by size
+	MaxBackups   int           // backups kept, at least 1
+	MaxAge       time.Duration // backups older than this are deleted, 0 = no age limit
+	Compress     bool
+}
+
+// LogRotationFromSettings returns the policy configured by LOG_MAX_SIZE_MB, LOG_MAX_BACKUPS,
+// LOG_MAX_AGE_DAYS and LOG_COMPRESS.
+func LogRotationFromSettings() LogRotation {
+	return LogRotation{
+		MaxSizeBytes: int64(config.Settings.LogMaxSizeMB) << 20,
+		MaxBackups:   config.Settings.LogMaxBackups,
+		MaxAge:       time.Duration(config.Settings.LogMaxAgeDays) * 24 * time.Hour,
+		Compress:     config.Settings.LogCompress,
+	}
+}
+
+func (p LogRotation) backups() int {
+	if p.MaxBackups < 1 {
+		return 1
+	}
+	return p.MaxBackups
+}
+
+// RotatingFile is a log file that rotates itself by size. Backups are compressed in the
+// background so writers are not held up.
+type RotatingFile struct {
+	path   string
+	policy LogRotation
+
+	mu          sync.Mutex
+	f           *os.File
+	size        int64
+	compressing sync.WaitGroup
+}
+
+// OpenRotatingFile opens path for appending. With rotateExisting a non-empty file is rotated
+// first, so each run starts a new log.
+func OpenRotatingFile(path string, policy LogRotation, rotateExisting bool) (*RotatingFile, error) {
+	r := &RotatingFile{path: path, policy: policy}
+	if rotateExisting {
+		if info, err := os.Stat(path); err == nil && info.Size() > 0 {
+			if err := r.rotateBackups(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *RotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file %s: %w", r.path, err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	r.f, r.size = f, info.Size()
+	return nil
+}
+
+// Write appends p, rotating first when it would take the file beyond the size limit.
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.policy.MaxSizeBytes > 0 && r.size > 0 && r.size+int64(len(p)) > r.policy.MaxSizeBytes {
+		if err := r.rotateLocked(); err != nil {
+			// Keep logging to the current file rather than losing lines.
+			fmt.Fprintf(os.Stderr, "log rotation failed: %v\n", err)
+		}
+	}
+	if r.f == nil {
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *RotatingFile) rotateLocked() error {
+	if err := r.f.Close(); err != nil {
+		return err
+	}
+	r.f = nil
+	if err := r.rotateBackups(); err != nil {
+		return err
+	}
+	return r.open()
+}
+
+// rotateBackups moves the current file to path.1 and compresses the previous newest backup in
+// the background.
+func (r *RotatingFile) rotateBackups() error {
+	r.compressing.Wait() // the backup being compressed is about to be renamed
+	toCompress, err := shiftLogBackups(r.path, r.policy)
+	if err != nil {
+		return err
+	}
+	if toCompress != "" {
+		r.compressing.Add(1)
+		go func() {
+			defer r.compressing.Done()
+			if err := compressLogFile(toCompress); err != nil {
+				fmt.Fprintf(os.Stderr, "log compression failed: %v\n", err)
+			}
+		}()
+	}
+	return nil
+}
+
+// Close closes the file after pending compressions finish.
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.compressing.Wait()
+	if r.f == nil {
+		return nil
+	}
+	err := r.f.Close()
+	r.f = nil
+	return err
+}
+
+// RotateLogFileIfLarge rotates a log file that other processes append to (such as the update
+// helper log) once it reached the policy's size limit, and prunes its backups.
+func RotateLogFileIfLarge(path string, policy LogRotation) error {
+	info, err := os.Stat(path)
+	if err != nil || policy.MaxSizeBytes <= 0 || info.Size() < policy.MaxSizeBytes {
+		return nil
+	}
+	toCompress, err := shiftLogBackups(path, policy)
+	if err != nil || toCompress == "" {
+		return err
+	}
+	return compressLogFile(toCompress)
+}
+
+// shiftLogBackups renames path.N to path.N+1 (dropping the oldest), path to path.1 and prunes
+// backups beyond the policy. It returns the plain backup to compress, if any.
+func shiftLogBackups(path string, policy LogRotation) (string, error) {
+	keep := policy.backups()
+	for _, suffix := range []string{"", ".gz"} {
+		if err := os.Remove(backupLogName(path, keep) + suffix); err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+	}
+	for n := keep - 1; n >= 1; n-- {
+		for _, suffix := range []string{"", ".gz"} {
+			if err := os.Rename(backupLogName(path, n)+suffix, backupLogName(path, n+1)+suffix); err != nil && !os.IsNotExist(err) {
+				return "", err
+			}
+		}
+	}
+	if err := os.Rename(path, backupLogName(path, 1)); err != nil && !os.IsNotExist(err) {
+		return "", fmt.Errorf("failed to rotate log %s: %w", path, err)
+	}
+	pruneLogBackups(path, policy)
+
+	if policy.Compress && keep >= 2 {
+		if _, err := os.Stat(backupLogName(path, 2)); err == nil {
+			return backupLogName(path, 2), nil
+		}
+	}
+	return "", nil
+}
+
+func backupLogName(path string, n int) string {
+	return path + "." + strconv.Itoa(n)
+}
+
+// pruneLogBackups deletes numbered backups beyond MaxBackups (left by a larger earlier setting)
+// and those older than MaxAge.
+func pruneLogBackups(path string, policy LogRotation) {
+	matches, _ := filepath.Glob(path + ".*")
+	for _, match := range matches {
+		n, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(match, path+"."), ".gz"))
+		if err != nil || n < 1 {
+			continue
+		}
+		expired := false
+		if policy.MaxAge > 0 {
+			if info, err := os.Stat(match); err == nil && time.Since(info.ModTime()) > policy.MaxAge {
+				expired = true
+			}
+		}
+		if n > policy.backups() || expired {
+			_ = os.Remove(match)
+		}
+	}
+}
+
+// compressLogFile gzips path to path.gz, keeping its modification time for age pruning.
+func compressLogFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".gz.tmp"
+	dst, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	_, err = io.Copy(zw, src)
+	if cerr := zw.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	_ = os.Chtimes(tmp, info.ModTime(), info.ModTime())
+	if err := os.Rename(tmp, path+".gz"); err != nil {
+		return err
+	}
+	_ = src.Close()
+	return os.Remove(path)
+}
@@ -0,0 +1,98 @@
+package core
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRotatingFile_SizeRotationAndBackups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bastion.log")
+	if err := os.WriteFile(path, []byte("previous run\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := OpenRotatingFile(path, LogRotation{MaxSizeBytes: 100, MaxBackups: 3, Compress: true}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path + ".1"); string(data) != "previous run\n" {
+		t.Fatalf("expected the previous run's log in .1, got %q", data)
+	}
+
+	line := strings.Repeat("x", 59) + "\n"
+	for i := 0; i < 8; i++ { // two lines fit in 100 bytes, so each second line rotates
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if info, err := os.Stat(path); err != nil || info.Size() != 60 {
+		t.Fatalf("expected the current log to hold one line, got %v %v", info, err)
+	}
+	if _, err := os.Stat(path + ".1"); err != nil {
+		t.Fatalf("expected the newest backup uncompressed: %v", err)
+	}
+	for _, name := range []string{".2.gz", ".3.gz"} {
+		gz, err := os.Open(path + name)
+		if err != nil {
+			t.Fatalf("expected backup %s: %v", name, err)
+		}
+		zr, err := gzip.NewReader(gz)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(zr)
+		gz.Close()
+		if !strings.HasPrefix(string(data), "xxx") {
+			t.Fatalf("unexpected content of %s: %q", name, data)
+		}
+	}
+	for _, name := range []string{".2", ".3", ".4", ".4.gz"} {
+		if _, err := os.Stat(path + name); err == nil {
+			t.Fatalf("unexpected backup %s", name)
+		}
+	}
+}
+
+func TestRotateLogFileIfLarge_PrunesOldBackups(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "helper.log")
+	for name, content := range map[string]string{"": "0123456789", ".1": "old", ".7": "stale"} {
+		if err := os.WriteFile(path+name, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := time.Now().Add(-48 * time.Hour)
+	if err := os.Chtimes(path+".1", old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	policy := LogRotation{MaxSizeBytes: 100, MaxBackups: 3, MaxAge: 24 * time.Hour}
+	if err := RotateLogFileIfLarge(path, policy); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "0123456789" {
+		t.Fatalf("expected a small log to stay in place, got %q", data)
+	}
+
+	policy.MaxSizeBytes = 10
+	if err := RotateLogFileIfLarge(path, policy); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path + ".1"); string(data) != "0123456789" {
+		t.Fatalf("expected the log rotated to .1, got %q", data)
+	}
+	for _, name := range []string{"", ".2", ".7"} {
+		if _, err := os.Stat(path + name); err == nil {
+			t.Fatalf("expected %q to be gone (rotated, expired or beyond max backups)", name)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DependencyCycleError reports mappings whose depends_on lists form a cycle.
+type DependencyCycleError struct {
+	Cycle []string // the cycle, starting and ending with the same mapping
+}
+
+func (e *DependencyCycleError) Error() string {
+	return fmt.Sprintf("dependency cycle: %s", strings.Join(e.Cycle, " -> "))
+}
+
+// OrderByDependencies orders the mappings of deps (mapping ID -> IDs it depends on) so every mapping
+// comes after the mappings it depends on; IDs without an entry are ignored as dependencies. Ties
+// keep ID order. When some mappings depend on a cycle, the others are still ordered and the first
+// cycle found is returned as a *DependencyCycleError.
+func OrderByDependencies(deps map[string][]string) ([]string, error) {
+	ids := make([]string, 0, len(deps))
+	for id := range deps {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	const (
+		unvisited = iota
+		visiting
+		done
+		cyclic
+	)
+	marks := make(map[string]int, len(deps))
+	order := make([]string, 0, len(deps))
+	var cycleErr *DependencyCycleError
+	var path []string
+
+	// visit reports whether id could be ordered (it neither is on nor depends on a cycle).
+	var visit func(id string) bool
+	visit = func(id string) bool {
+		switch marks[id] {
+		case done:
+			return true
+		case cyclic:
+			return false
+		case visiting:
+			if cycleErr == nil {
+				start := 0
+				for i, p := range path {
+					if p == id {
+						start = i
+						break
+					}
+				}
+				cycle := append(append([]string{}, path[start:]...), id)
+				cycleErr = &DependencyCycleError{Cycle: cycle}
+			}
+			return false
+		}
+		marks[id] = visiting
+		path = append(path, id)
+		ok := true
+		for _, dep := range deps[id] {
+			if _, known := deps[dep]; !known {
+				continue
+			}
+			if !visit(dep) {
+				ok = false
+			}
+		}
+		path = path[:len(path)-1]
+		if !ok {
+			marks[id] = cyclic
+			return false
+		}
+		marks[id] = done
+		order = append(order, id)
+		return true
+	}
+
+	for _, id := range ids {
+		visit(id)
+	}
+	if cycleErr != nil {
+		return order, cycleErr
+	}
+	return order, nil
+}
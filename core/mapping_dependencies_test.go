@@ -0,0 +1,56 @@
+package core
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestOrderByDependencies_DependenciesFirst(t *testing.T) {
+	order, err := OrderByDependencies(map[string][]string{
+		"db":     {"jump"},
+		"jump":   nil,
+		"web":    {"db", "jump", "unknown"},
+		"alone":  nil,
+		"cache":  {"jump"},
+		"zz-app": {"cache"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"alone", "jump", "cache", "db", "web", "zz-app"}
+	if !reflect.DeepEqual(order, want) {
+		t.Fatalf("order = %v, want %v", order, want)
+	}
+}
+
+func TestOrderByDependencies_Cycle(t *testing.T) {
+	order, err := OrderByDependencies(map[string][]string{
+		"a":    {"b"},
+		"b":    {"c"},
+		"c":    {"a"},
+		"d":    {"a"},
+		"free": nil,
+	})
+	var cycleErr *DependencyCycleError
+	if !errors.As(err, &cycleErr) {
+		t.Fatalf("expected a cycle error, got %v", err)
+	}
+	if want := []string{"a", "b", "c", "a"}; !reflect.DeepEqual(cycleErr.Cycle, want) {
+		t.Fatalf("cycle = %v, want %v", cycleErr.Cycle, want)
+	}
+	if err.Error() != "dependency cycle: a -> b -> c -> a" {
+		t.Fatalf("unexpected message: %v", err)
+	}
+	if want := []string{"free"}; !reflect.DeepEqual(order, want) {
+		t.Fatalf("order = %v, want %v", order, want)
+	}
+}
+
+func TestOrderByDependencies_SelfDependency(t *testing.T) {
+	_, err := OrderByDependencies(map[string][]string{"a": {"a"}})
+	var cycleErr *DependencyCycleError
+	if !errors.As(err, &cycleErr) || len(cycleErr.Cycle) != 2 {
+		t.Fatalf("expected a self cycle, got %v", err)
+	}
+}
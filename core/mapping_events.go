@@ -0,0 +1,158 @@
+package core
+
+import (
+	"bastion/config"
+	"bastion/models"
+	"log"
+	"sync"
+	"time"
+)
+
+// Mapping event types
+const (
+	MappingEventStart       = "start"
+	MappingEventStartFailed = "start_failed"
+	MappingEventStop        = "stop"
+	MappingEventDialFailed  = "dial_failed"
+	MappingEventExposed     = "exposed"
+	MappingEventUnexposed   = "unexposed"
+	MappingEventRenamed     = "renamed"
+)
+
+// dialFailureEventInterval throttles dial_failed events so a broken chain does not flood the history.
+const dialFailureEventInterval = 30 * time.Second
+
+// MappingEventStore persists mapping events. It is implemented by database.MappingEventStore.
+type MappingEventStore interface {
+	Insert(ev *models.MappingEvent, keep int) error
+	List(mappingID string, limit int) ([]models.MappingEvent, error)
+	DeleteByMapping(mappingID string) error
+}
+
+// MappingEventLog keeps the last N lifecycle events per mapping, persisted through the configured store
+// or kept in memory when no store is set.
+type MappingEventLog struct {
+	mu              sync.Mutex
+	store           MappingEventStore
+	mem             map[string][]models.MappingEvent
+	lastDialFailure map[string]time.Time
+}
+
+var MappingEvents *MappingEventLog
+
+func init() {
+	MappingEvents = NewMappingEventLog()
+}
+
+// NewMappingEventLog constructs an in-memory mapping event log.
+func NewMappingEventLog() *MappingEventLog {
+	return &MappingEventLog{
+		mem:             make(map[string][]models.MappingEvent),
+		lastDialFailure: make(map[string]time.Time),
+	}
+}
+
+// SetStore enables persistent storage for mapping events.
+func (l *MappingEventLog) SetStore(store MappingEventStore) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.store = store
+}
+
+// Record appends an event to the mapping's history.
+func (l *MappingEventLog) Record(mappingID, eventType, reason, detail string) {
+	ev := models.MappingEvent{
+		MappingID: mappingID,
+		Timestamp: time.Now(),
+		Type:      eventType,
+		Reason:    reason,
+		Detail:    detail,
+	}
+	keep := config.Settings.MappingEventsMax
+
+	l.mu.Lock()
+	store := l.store
+	if store == nil {
+		events := append(l.mem[mappingID], ev)
+		if keep > 0 && len(events) > keep {
+			events = events[len(events)-keep:]
+		}
+		l.mem[mappingID] = events
+	}
+	l.mu.Unlock()
+
+	if store != nil {
+		if err := store.Insert(&ev, keep); err != nil {
+			log.Printf("Failed to persist mapping event for %s: %v", mappingID, err)
+		}
+	}
+}
+
+// RecordDialFailure records a dial_failed event, at most once per dialFailureEventInterval per mapping.
+func (l *MappingEventLog) RecordDialFailure(mappingID, target string, err error) {
+	now := time.Now()
+
+	l.mu.Lock()
+	if last, ok := l.lastDialFailure[mappingID]; ok && now.Sub(last) < dialFailureEventInterval {
+		l.mu.Unlock()
+		return
+	}
+	l.lastDialFailure[mappingID] = now
+	l.mu.Unlock()
+
+	l.Record(mappingID, MappingEventDialFailed, "failed to dial "+target, err.Error())
+}
+
+// List returns up to limit events for a mapping, latest first.
+func (l *MappingEventLog) List(mappingID string, limit int) ([]models.MappingEvent, error) {
+	l.mu.Lock()
+	store := l.store
+	if store == nil {
+		events := l.mem[mappingID]
+		result := make([]models.MappingEvent, 0, len(events))
+		for i := len(events) - 1; i >= 0; i-- {
+			if limit > 0 && len(result) >= limit {
+				break
+			}
+			result = append(result, events[i])
+		}
+		l.mu.Unlock()
+		return result, nil
+	}
+	l.mu.Unlock()
+
+	return store.List(mappingID, limit)
+}
+
+// Forget drops the history of a deleted mapping.
+func (l *MappingEventLog) Forget(mappingID string) {
+	l.mu.Lock()
+	store := l.store
+	delete(l.mem, mappingID)
+	delete(l.lastDialFailure, mappingID)
+	l.mu.Unlock()
+
+	if store != nil {
+		if err := store.DeleteByMapping(mappingID); err != nil {
+			log.Printf("Failed to delete mapping events for %s: %v", mappingID, err)
+		}
+	}
+}
+
+// Rename moves the in-memory history of mappingID to newID; persisted events are re-keyed by
+// database.RenameMappingKey together with the mapping.
+func (l *MappingEventLog) Rename(mappingID, newID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if events, ok := l.mem[mappingID]; ok {
+		for i := range events {
+			events[i].MappingID = newID
+		}
+		l.mem[newID] = events
+		delete(l.mem, mappingID)
+	}
+	if last, ok := l.lastDialFailure[mappingID]; ok {
+		l.lastDialFailure[newID] = last
+		delete(l.lastDialFailure, mappingID)
+	}
+}
@@ -0,0 +1,64 @@
+package core
+
+import (
+	"bastion/config"
+	"errors"
+	"testing"
+)
+
+func TestMappingEventLog_MemoryKeepsNewest(t *testing.T) {
+	prev := config.Settings.MappingEventsMax
+	t.Cleanup(func() { config.Settings.MappingEventsMax = prev })
+	config.Settings.MappingEventsMax = 3
+
+	l := NewMappingEventLog()
+	for _, typ := range []string{MappingEventStart, MappingEventStop, MappingEventStart, MappingEventStartFailed} {
+		l.Record("m1", typ, typ, "")
+	}
+	l.Record("m2", MappingEventStart, "started", "")
+
+	events, err := l.List("m1", 0)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(events) != 3 {
+		t.Fatalf("expected 3 events, got %d", len(events))
+	}
+	if events[0].Type != MappingEventStartFailed || events[2].Type != MappingEventStop {
+		t.Fatalf("unexpected order: %+v", events)
+	}
+
+	if events, _ := l.List("m1", 1); len(events) != 1 {
+		t.Fatalf("expected limit to apply, got %d", len(events))
+	}
+
+	l.Forget("m1")
+	if events, _ := l.List("m1", 0); len(events) != 0 {
+		t.Fatalf("expected history to be forgotten, got %d", len(events))
+	}
+}
+
+func TestMappingEventLog_DialFailureThrottled(t *testing.T) {
+	l := NewMappingEventLog()
+	l.RecordDialFailure("m1", "10.0.0.1:22", errors.New("refused"))
+	l.RecordDialFailure("m1", "10.0.0.1:22", errors.New("refused"))
+
+	events, _ := l.List("m1", 0)
+	if len(events) != 1 || events[0].Type != MappingEventDialFailed {
+		t.Fatalf("expected a single dial_failed event, got %+v", events)
+	}
+}
+
+func TestMappingEventLog_Rename(t *testing.T) {
+	l := NewMappingEventLog()
+	l.Record("old", MappingEventStart, "started", "")
+	l.Rename("old", "new")
+
+	events, _ := l.List("new", 0)
+	if len(events) != 1 || events[0].MappingID != "new" {
+		t.Fatalf("expected the history moved to the new ID, got %+v", events)
+	}
+	if events, _ := l.List("old", 0); len(events) != 0 {
+		t.Fatalf("expected no history under the old ID, got %d", len(events))
+	}
+}
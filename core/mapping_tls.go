@@ -0,0 +1,116 @@
+package core
+
+import (
+	"bastion/models"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+	"time"
+)
+
+// tlsHandshakeTimeout bounds the TLS handshakes of a tcp mapping on either side.
+const tlsHandshakeTimeout = 10 * time.Second
+
+// MappingTLS holds the TLS configurations of a tcp mapping: Server terminates TLS from clients and
+// Client originates TLS toward the remote. Either is nil when unused.
+type MappingTLS struct {
+	Server *tls.Config
+	Client *tls.Config
+}
+
+// NewMappingTLS validates the mapping's TLS settings and loads its certificate files.
+func NewMappingTLS(mapping *models.Mapping) (MappingTLS, error) {
+	var t MappingTLS
+	var terminate, originate bool
+	switch mapping.TLSMode {
+	case models.TLSModeNone:
+	case models.TLSModeTerminate:
+		terminate = true
+	case models.TLSModeOriginate:
+		originate = true
+	case models.TLSModeReencrypt:
+		terminate, originate = true, true
+	default:
+		return t, fmt.Errorf("invalid tls_mode %q (use %s, %s or %s)", mapping.TLSMode,
+			models.TLSModeTerminate, models.TLSModeOriginate, models.TLSModeReencrypt)
+	}
+	if mapping.TLSMode != models.TLSModeNone && mapping.Type != "tcp" {
+		return t, fmt.Errorf("tls_mode only applies to tcp mappings")
+	}
+
+	if !terminate && (mapping.TLSCertFile != "" || mapping.TLSKeyFile != "") {
+		return t, fmt.Errorf("tls_cert_file and tls_key_file need tls_mode %s or %s", models.TLSModeTerminate, models.TLSModeReencrypt)
+	}
+	if !originate && (mapping.TLSServerName != "" || mapping.TLSCAFile != "" || mapping.TLSInsecureSkipVerify) {
+		return t, fmt.Errorf("tls_server_name, tls_ca_file and tls_insecure_skip_verify need tls_mode %s or %s", models.TLSModeOriginate, models.TLSModeReencrypt)
+	}
+
+	if terminate {
+		if mapping.TLSCertFile == "" || mapping.TLSKeyFile == "" {
+			return t, fmt.Errorf("tls_cert_file and tls_key_file are required for tls_mode %s", mapping.TLSMode)
+		}
+		cert, err := tls.LoadX509KeyPair(mapping.TLSCertFile, mapping.TLSKeyFile)
+		if err != nil {
+			return t, fmt.Errorf("failed to load TLS certificate: %w", err)
+		}
+		t.Server = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	}
+
+	if originate {
+		serverName := mapping.TLSServerName
+		if serverName == "" {
+			serverName = mapping.RemoteHost
+		}
+		t.Client = &tls.Config{
+			ServerName:         serverName,
+			InsecureSkipVerify: mapping.TLSInsecureSkipVerify,
+			MinVersion:         tls.VersionTLS12,
+		}
+		if mapping.TLSCAFile != "" {
+			pem, err := os.ReadFile(mapping.TLSCAFile)
+			if err != nil {
+				return t, fmt.Errorf("failed to read tls_ca_file: %w", err)
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				return t, fmt.Errorf("tls_ca_file %s contains no PEM certificates", mapping.TLSCAFile)
+			}
+			t.Client.RootCAs = pool
+		}
+	}
+	return t, nil
+}
+
+// serverConn terminates TLS on a client connection (returned as is without termination).
+func (t MappingTLS) serverConn(conn net.Conn) (net.Conn, error) {
+	if t.Server == nil {
+		return conn, nil
+	}
+	tlsConn := tls.Server(conn, t.Server)
+	if err := handshakeTLS(tlsConn); err != nil {
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+// clientConn wraps a remote connection in TLS (returned as is without origination).
+func (t MappingTLS) clientConn(conn net.Conn) (net.Conn, error) {
+	if t.Client == nil {
+		return conn, nil
+	}
+	tlsConn := tls.Client(conn, t.Client)
+	if err := handshakeTLS(tlsConn); err != nil {
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+func handshakeTLS(conn *tls.Conn) error {
+	_ = conn.SetDeadline(time.Now().Add(tlsHandshakeTimeout))
+	if err := conn.Handshake(); err != nil {
+		return err
+	}
+	return conn.SetDeadline(time.Time{})
+}
@@ -0,0 +1,151 @@
+package core
+
+import (
+	"bastion/models"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeTestCert writes a self-signed certificate for host and its key to dir.
+func writeTestCert(t *testing.T, dir, host string) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: host},
+		DNSNames:              []string{host},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile = filepath.Join(dir, host+".crt")
+	keyFile = filepath.Join(dir, host+".key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestNewMappingTLS_Validation(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeTestCert(t, dir, "local.test")
+
+	cases := []struct {
+		name    string
+		mapping models.Mapping
+		ok      bool
+	}{
+		{"none", models.Mapping{Type: "tcp"}, true},
+		{"terminate", models.Mapping{Type: "tcp", TLSMode: "terminate", TLSCertFile: certFile, TLSKeyFile: keyFile}, true},
+		{"originate", models.Mapping{Type: "tcp", TLSMode: "originate", RemoteHost: "db", TLSCAFile: certFile}, true},
+		{"unknown mode", models.Mapping{Type: "tcp", TLSMode: "mtls"}, false},
+		{"proxy mapping", models.Mapping{Type: "socks5", TLSMode: "originate"}, false},
+		{"terminate without cert", models.Mapping{Type: "tcp", TLSMode: "terminate"}, false},
+		{"missing cert file", models.Mapping{Type: "tcp", TLSMode: "terminate", TLSCertFile: filepath.Join(dir, "none"), TLSKeyFile: keyFile}, false},
+		{"cert without terminate", models.Mapping{Type: "tcp", TLSMode: "originate", TLSCertFile: certFile, TLSKeyFile: keyFile}, false},
+		{"sni without originate", models.Mapping{Type: "tcp", TLSServerName: "db"}, false},
+		{"ca not pem", models.Mapping{Type: "tcp", TLSMode: "originate", TLSCAFile: keyFile}, false},
+	}
+	for _, c := range cases {
+		_, err := NewMappingTLS(&c.mapping)
+		if (err == nil) != c.ok {
+			t.Errorf("%s: unexpected error %v", c.name, err)
+		}
+	}
+}
+
+func TestTunnelSession_TLSReencrypt(t *testing.T) {
+	dir := t.TempDir()
+	localCert, localKey := writeTestCert(t, dir, "local.test")
+	remoteCert, remoteKey := writeTestCert(t, dir, "remote.test")
+
+	// Remote: TLS echo server with its own certificate.
+	cert, err := tls.LoadX509KeyPair(remoteCert, remoteKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	remoteLn, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer remoteLn.Close()
+	go func() {
+		conn, err := remoteLn.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_, _ = io.Copy(conn, conn)
+	}()
+
+	mapping := &models.Mapping{
+		ID: "tls", Type: "tcp", RemoteHost: "127.0.0.1", RemotePort: remoteLn.Addr().(*net.TCPAddr).Port,
+		TLSMode: "reencrypt", TLSCertFile: localCert, TLSKeyFile: localKey,
+		TLSServerName: "remote.test", TLSCAFile: remoteCert,
+	}
+	s := &TunnelSession{BaseSession: newBaseSession(mapping, nil)}
+	if s.tls, err = NewMappingTLS(mapping); err != nil {
+		t.Fatal(err)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		s.wg.Add(1)
+		s.handleTCPClient(conn)
+	}()
+
+	pem, _ := os.ReadFile(localCert)
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(pem)
+	conn, err := tls.Dial("tcp", ln.Addr().String(), &tls.Config{RootCAs: roots, ServerName: "local.test"})
+	if err != nil {
+		t.Fatalf("TLS dial to the mapping: %v", err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	if _, err := conn.Write([]byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(conn, buf); err != nil || string(buf) != "ping" {
+		t.Fatalf("expected echo through both TLS legs, got %q (%v)", buf, err)
+	}
+}
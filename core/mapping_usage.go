@@ -0,0 +1,255 @@
+package core
+
+import (
+	"bastion/models"
+	"log"
+	"sync"
+	"time"
+)
+
+// MappingUsageStore persists lifetime traffic counters. It is implemented by database.MappingUsageStore.
+type MappingUsageStore interface {
+	LoadAll() ([]models.MappingUsage, error)
+	Save(usages []models.MappingUsage) error
+	Delete(mappingID string) error
+}
+
+// usageSource is a running session whose counters feed the lifetime totals.
+type usageSource interface {
+	GetStats() SessionStats
+}
+
+// liveUsage tracks how much of a running session's counters were already added to the totals.
+type liveUsage struct {
+	src         usageSource
+	countedUp   int64
+	countedDown int64
+}
+
+// MappingUsageTracker accumulates per-mapping traffic across session restarts. Running sessions are
+// sampled on every flush (and on reads), so a crash loses at most one flush interval of traffic.
+type MappingUsageTracker struct {
+	mu     sync.Mutex
+	store  MappingUsageStore
+	totals map[string]*models.MappingUsage
+	live   map[string]*liveUsage
+	dirty  map[string]bool
+
+	flushMu     sync.Mutex // keeps concurrent flushes from saving snapshots out of order
+	flusherOnce sync.Once
+}
+
+var Usage *MappingUsageTracker
+
+func init() {
+	Usage = NewMappingUsageTracker()
+}
+
+// NewMappingUsageTracker constructs an in-memory usage tracker.
+func NewMappingUsageTracker() *MappingUsageTracker {
+	return &MappingUsageTracker{
+		totals: make(map[string]*models.MappingUsage),
+		live:   make(map[string]*liveUsage),
+		dirty:  make(map[string]bool),
+	}
+}
+
+// SetStore loads persisted totals and enables flushing to store.
+func (t *MappingUsageTracker) SetStore(store MappingUsageStore) {
+	usages, err := store.LoadAll()
+	if err != nil {
+		log.Printf("Failed to load mapping usage: %v", err)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.store = store
+	for i := range usages {
+		u := usages[i]
+		if cur, ok := t.totals[u.MappingID]; ok {
+			// Keep traffic counted before the store was attached.
+			u.BytesUp += cur.BytesUp
+			u.BytesDown += cur.BytesDown
+			if cur.QuotaPeriodStart != nil && u.QuotaPeriodStart != nil && cur.QuotaPeriodStart.Equal(*u.QuotaPeriodStart) {
+				u.QuotaBytes += cur.QuotaBytes
+			} else if cur.QuotaPeriodStart != nil {
+				u.QuotaBytes, u.QuotaPeriodStart = cur.QuotaBytes, cur.QuotaPeriodStart
+			}
+		}
+		t.totals[u.MappingID] = &u
+	}
+}
+
+// Started begins counting a new session of mappingID.
+func (t *MappingUsageTracker) Started(mappingID string, src usageSource) {
+	now := time.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.collectLocked(now)
+	t.live[mappingID] = &liveUsage{src: src}
+	u := t.usageLocked(mappingID)
+	u.LastStartedAt = &now
+	t.dirty[mappingID] = true
+}
+
+// Stopped takes the final reading of mappingID's session and persists its totals.
+func (t *MappingUsageTracker) Stopped(mappingID string) {
+	t.mu.Lock()
+	t.collectLocked(time.Now())
+	delete(t.live, mappingID)
+	t.mu.Unlock()
+
+	t.Flush()
+}
+
+// Get returns the lifetime totals of mappingID, including traffic not yet flushed.
+func (t *MappingUsageTracker) Get(mappingID string) models.MappingUsage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.collectLocked(time.Now())
+	if u, ok := t.totals[mappingID]; ok {
+		return *u
+	}
+	return models.MappingUsage{MappingID: mappingID}
+}
+
+// QuotaUsed returns the traffic of mappingID counted against its quota in the period starting at
+// periodStart, sampling its running session first.
+func (t *MappingUsageTracker) QuotaUsed(mappingID string, periodStart time.Time) int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if l, ok := t.live[mappingID]; ok {
+		t.collectSessionLocked(mappingID, l, time.Now())
+	}
+	u, ok := t.totals[mappingID]
+	if !ok || u.QuotaPeriodStart == nil || !u.QuotaPeriodStart.Equal(periodStart) {
+		return 0
+	}
+	return u.QuotaBytes
+}
+
+// Forget drops the totals of a deleted mapping.
+func (t *MappingUsageTracker) Forget(mappingID string) {
+	t.mu.Lock()
+	store := t.store
+	delete(t.totals, mappingID)
+	delete(t.live, mappingID)
+	delete(t.dirty, mappingID)
+	t.mu.Unlock()
+
+	if store != nil {
+		if err := store.Delete(mappingID); err != nil {
+			log.Printf("Failed to delete mapping usage for %s: %v", mappingID, err)
+		}
+	}
+}
+
+// Rename moves the totals of mappingID to newID after the store rows were re-keyed (see
+// database.RenameMappingKey). The mapping must not be running.
+func (t *MappingUsageTracker) Rename(mappingID, newID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.totals, newID)
+	delete(t.dirty, newID)
+	if u, ok := t.totals[mappingID]; ok {
+		u.MappingID = newID
+		t.totals[newID] = u
+		delete(t.totals, mappingID)
+	}
+	if t.dirty[mappingID] {
+		t.dirty[newID] = true
+		delete(t.dirty, mappingID)
+	}
+}
+
+// Flush samples running sessions and writes changed totals to the store.
+func (t *MappingUsageTracker) Flush() {
+	t.flushMu.Lock()
+	defer t.flushMu.Unlock()
+
+	t.mu.Lock()
+	t.collectLocked(time.Now())
+	store := t.store
+	if store == nil || len(t.dirty) == 0 {
+		t.mu.Unlock()
+		return
+	}
+	batch := make([]models.MappingUsage, 0, len(t.dirty))
+	for id := range t.dirty {
+		batch = append(batch, *t.totals[id])
+	}
+	t.dirty = make(map[string]bool)
+	t.mu.Unlock()
+
+	if err := store.Save(batch); err != nil {
+		log.Printf("Failed to persist mapping usage: %v", err)
+		t.mu.Lock()
+		for _, u := range batch {
+			if _, ok := t.totals[u.MappingID]; ok {
+				t.dirty[u.MappingID] = true
+			}
+		}
+		t.mu.Unlock()
+	}
+}
+
+// StartFlusher flushes every interval until the process exits (interval <= 0 flushes only on stop/shutdown).
+func (t *MappingUsageTracker) StartFlusher(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	t.flusherOnce.Do(func() {
+		go func() {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for range ticker.C {
+				t.Flush()
+			}
+		}()
+	})
+}
+
+// collectLocked adds traffic seen since the last sample of each running session to the totals.
+func (t *MappingUsageTracker) collectLocked(now time.Time) {
+	for id, l := range t.live {
+		t.collectSessionLocked(id, l, now)
+	}
+}
+
+// collectSessionLocked adds the traffic of one running session since its last sample, counting it
+// against the quota period containing now.
+func (t *MappingUsageTracker) collectSessionLocked(id string, l *liveUsage, now time.Time) {
+	stats := l.src.GetStats()
+	up := stats.BytesUp - l.countedUp
+	down := stats.BytesDown - l.countedDown
+	if up <= 0 && down <= 0 && stats.ActiveConns == 0 {
+		return
+	}
+	u := t.usageLocked(id)
+	if start, _ := QuotaPeriod(now); u.QuotaPeriodStart == nil || !u.QuotaPeriodStart.Equal(start) {
+		u.QuotaBytes, u.QuotaPeriodStart = 0, &start
+	}
+	if up > 0 {
+		u.BytesUp += up
+		u.QuotaBytes += up
+		l.countedUp = stats.BytesUp
+	}
+	if down > 0 {
+		u.BytesDown += down
+		u.QuotaBytes += down
+		l.countedDown = stats.BytesDown
+	}
+	active := now
+	u.LastActiveAt = &active
+	t.dirty[id] = true
+}
+
+func (t *MappingUsageTracker) usageLocked(mappingID string) *models.MappingUsage {
+	u, ok := t.totals[mappingID]
+	if !ok {
+		u = &models.MappingUsage{MappingID: mappingID}
+		t.totals[mappingID] = u
+	}
+	return u
+}
@@ -0,0 +1,90 @@
+package core
+
+import (
+	"bastion/models"
+	"testing"
+)
+
+type fakeUsageSource struct{ stats SessionStats }
+
+func (f *fakeUsageSource) GetStats() SessionStats { return f.stats }
+
+type memUsageStore struct {
+	rows map[string]models.MappingUsage
+}
+
+func (m *memUsageStore) LoadAll() ([]models.MappingUsage, error) {
+	out := make([]models.MappingUsage, 0, len(m.rows))
+	for _, u := range m.rows {
+		out = append(out, u)
+	}
+	return out, nil
+}
+
+func (m *memUsageStore) Save(usages []models.MappingUsage) error {
+	for _, u := range usages {
+		m.rows[u.MappingID] = u
+	}
+	return nil
+}
+
+func (m *memUsageStore) Delete(mappingID string) error {
+	delete(m.rows, mappingID)
+	return nil
+}
+
+func TestMappingUsageTracker_AccumulatesAcrossRestarts(t *testing.T) {
+	store := &memUsageStore{rows: map[string]models.MappingUsage{
+		"db": {MappingID: "db", BytesUp: 100, BytesDown: 1000},
+	}}
+	tr := NewMappingUsageTracker()
+	tr.SetStore(store)
+
+	first := &fakeUsageSource{}
+	tr.Started("db", first)
+	first.stats = SessionStats{BytesUp: 10, BytesDown: 20}
+	tr.Flush()
+	first.stats = SessionStats{BytesUp: 15, BytesDown: 30}
+	tr.Stopped("db")
+
+	if got := store.rows["db"]; got.BytesUp != 115 || got.BytesDown != 1030 || got.LastStartedAt == nil || got.LastActiveAt == nil {
+		t.Fatalf("unexpected persisted usage after first session: %+v", got)
+	}
+
+	// A new session starts its counters from zero; totals keep growing.
+	second := &fakeUsageSource{}
+	tr.Started("db", second)
+	second.stats = SessionStats{BytesUp: 5, BytesDown: 5, ActiveConns: 1}
+	if got := tr.Get("db"); got.BytesUp != 120 || got.BytesDown != 1035 {
+		t.Fatalf("unexpected live usage: %+v", got)
+	}
+
+	// Reloading from the store (a daemon restart) keeps the flushed totals.
+	tr.Flush()
+	reloaded := NewMappingUsageTracker()
+	reloaded.SetStore(store)
+	if got := reloaded.Get("db"); got.BytesUp != 120 || got.BytesDown != 1035 {
+		t.Fatalf("unexpected usage after reload: %+v", got)
+	}
+
+	reloaded.Forget("db")
+	if _, ok := store.rows["db"]; ok {
+		t.Fatalf("expected Forget to delete persisted usage")
+	}
+}
+
+func TestMappingUsageTracker_Rename(t *testing.T) {
+	tr := NewMappingUsageTracker()
+	src := &fakeUsageSource{}
+	tr.Started("127.0.0.1:5432", src)
+	src.stats = SessionStats{BytesUp: 7, BytesDown: 9}
+	tr.Stopped("127.0.0.1:5432")
+
+	tr.Rename("127.0.0.1:5432", "db")
+	if got := tr.Get("db"); got.MappingID != "db" || got.BytesUp != 7 || got.BytesDown != 9 {
+		t.Fatalf("expected totals moved to the new ID, got %+v", got)
+	}
+	if got := tr.Get("127.0.0.1:5432"); got.BytesUp != 0 {
+		t.Fatalf("expected nothing left under the old ID, got %+v", got)
+	}
+}
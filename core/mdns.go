@@ -0,0 +1,343 @@
+package core
+
+import (
+	"bastion/config"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	// mdnsServiceType is the DNS-SD service type exposed mappings are advertised under.
+	mdnsServiceType = "_bastion._tcp.local."
+	// mdnsServicesMeta lists the service types of a host (DNS-SD service type enumeration).
+	mdnsServicesMeta = "_services._dns-sd._udp.local."
+	mdnsPort         = 5353
+	// Record TTLs recommended by RFC 6762: host-bound records expire sooner than the others.
+	mdnsHostTTL  = 120
+	mdnsOtherTTL = 4500
+	// mdnsCacheFlush marks a record as the only one of its name and type (RFC 6762 section 10.2).
+	mdnsCacheFlush = 1 << 15
+)
+
+var mdnsGroup = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: mdnsPort}
+
+// MDNSService is a mapping advertised on the local network.
+type MDNSService struct {
+	MappingID string `json:"mapping_id"`
+	Type      string `json:"type"` // tcp, socks5, http or mixed
+	Addr      string `json:"addr"` // the exposed interface address
+	Port      int    `json:"port"`
+}
+
+// MDNSAdvertiser answers mDNS queries for the running exposed mappings, so tools on the LAN can
+// discover shared tunnels (DNS-SD type _bastion._tcp, TXT mapping= and type=). Only mappings
+// deliberately exposed beyond localhost are advertised, and only with MDNS_ADVERTISE.
+type MDNSAdvertiser struct {
+	mu       sync.Mutex
+	services map[string]MDNSService // mapping key -> service
+	conn     *net.UDPConn
+	host     string // <hostname>.local.
+}
+
+var MDNS *MDNSAdvertiser
+
+func init() {
+	MDNS = &MDNSAdvertiser{services: make(map[string]MDNSService), host: mdnsHostName()}
+}
+
+// Start joins the mDNS group and starts answering queries. It is a no-op unless MDNS_ADVERTISE is
+// set or when already started.
+func (a *MDNSAdvertiser) Start() error {
+	if !config.Settings.MDNSAdvertise {
+		return nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.conn != nil {
+		return nil
+	}
+	conn, err := net.ListenMulticastUDP("udp4", nil, mdnsGroup)
+	if err != nil {
+		return fmt.Errorf("join mDNS group: %w", err)
+	}
+	a.conn = conn
+	for _, svc := range a.services {
+		a.announceLocked(svc, false)
+	}
+	go a.serve(conn)
+	log.Printf("Advertising exposed mappings via mDNS as %s on %s", mdnsServiceType, a.host)
+	return nil
+}
+
+// Stop withdraws every advertised mapping and leaves the mDNS group.
+func (a *MDNSAdvertiser) Stop() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.conn == nil {
+		return
+	}
+	for _, svc := range a.services {
+		a.announceLocked(svc, true)
+	}
+	_ = a.conn.Close()
+	a.conn = nil
+}
+
+// Advertise adds or replaces the service of a mapping, announcing it when the advertiser runs.
+func (a *MDNSAdvertiser) Advertise(svc MDNSService) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.services[svc.MappingID] = svc
+	if a.conn != nil {
+		a.announceLocked(svc, false)
+	}
+}
+
+// Withdraw removes svc, announcing its removal when the advertiser runs. A newer service of the same
+// mapping (advertised by a session that replaced the one withdrawing) is kept.
+func (a *MDNSAdvertiser) Withdraw(svc MDNSService) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.services[svc.MappingID] != svc {
+		return
+	}
+	delete(a.services, svc.MappingID)
+	if a.conn != nil {
+		a.announceLocked(svc, true)
+	}
+}
+
+func (a *MDNSAdvertiser) serve(conn *net.UDPConn) {
+	buf := make([]byte, 9000)
+	for {
+		n, from, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			return // closed by Stop
+		}
+		resp := a.respond(buf[:n], from.Port != mdnsPort)
+		if resp == nil {
+			continue
+		}
+		to := mdnsGroup
+		if from.Port != mdnsPort {
+			// A legacy resolver querying from an ephemeral port expects a unicast reply.
+			to = from
+		}
+		_, _ = conn.WriteToUDP(resp, to)
+	}
+}
+
+// respond builds the answer to an mDNS query, nil when it asks for nothing advertised here. A
+// legacy (unicast) query gets its ID and questions echoed back.
+func (a *MDNSAdvertiser) respond(query []byte, legacy bool) []byte {
+	var p dnsmessage.Parser
+	header, err := p.Start(query)
+	if err != nil || header.Response {
+		return nil
+	}
+	questions, err := p.AllQuestions()
+	if err != nil {
+		return nil
+	}
+
+	a.mu.Lock()
+	var answers []dnsmessage.Resource
+	for _, q := range questions {
+		answers = append(answers, a.answerLocked(q)...)
+	}
+	a.mu.Unlock()
+	if len(answers) == 0 {
+		return nil
+	}
+
+	msg := dnsmessage.Message{
+		Header:  dnsmessage.Header{Response: true, Authoritative: true},
+		Answers: answers,
+	}
+	if legacy {
+		msg.Header.ID = header.ID
+		msg.Questions = questions
+	}
+	b, err := msg.Pack()
+	if err != nil {
+		return nil
+	}
+	return b
+}
+
+// answerLocked returns the records answering q.
+func (a *MDNSAdvertiser) answerLocked(q dnsmessage.Question) []dnsmessage.Resource {
+	name := strings.ToLower(q.Name.String())
+	wants := func(t dnsmessage.Type) bool { return q.Type == t || q.Type == dnsmessage.TypeALL }
+	var rs []dnsmessage.Resource
+
+	switch {
+	case name == mdnsServicesMeta:
+		if wants(dnsmessage.TypePTR) && len(a.services) > 0 {
+			rs = append(rs, ptrRecord(mdnsServicesMeta, mdnsServiceType, mdnsOtherTTL))
+		}
+	case name == mdnsServiceType:
+		if wants(dnsmessage.TypePTR) {
+			for _, svc := range a.sortedLocked() {
+				rs = append(rs, ptrRecord(mdnsServiceType, mdnsInstanceName(svc), mdnsOtherTTL))
+			}
+		}
+	case name == strings.ToLower(a.host):
+		for _, svc := range a.sortedLocked() {
+			if r, ok := addrRecord(a.host, svc.Addr, mdnsHostTTL); ok && wants(r.Header.Type) {
+				rs = append(rs, r)
+			}
+		}
+	default:
+		for _, svc := range a.sortedLocked() {
+			if name != strings.ToLower(mdnsInstanceName(svc)) {
+				continue
+			}
+			if wants(dnsmessage.TypeSRV) {
+				rs = append(rs, a.srvRecord(svc, mdnsHostTTL))
+			}
+			if wants(dnsmessage.TypeTXT) {
+				rs = append(rs, txtRecord(svc, mdnsOtherTTL))
+			}
+		}
+	}
+	return rs
+}
+
+// announceLocked multicasts the records of svc unsolicited, or with a zero TTL (a goodbye) when
+// it is withdrawn.
+func (a *MDNSAdvertiser) announceLocked(svc MDNSService, goodbye bool) {
+	hostTTL, otherTTL := uint32(mdnsHostTTL), uint32(mdnsOtherTTL)
+	if goodbye {
+		hostTTL, otherTTL = 0, 0
+	}
+	answers := []dnsmessage.Resource{
+		ptrRecord(mdnsServiceType, mdnsInstanceName(svc), otherTTL),
+		a.srvRecord(svc, hostTTL),
+		txtRecord(svc, otherTTL),
+	}
+	if r, ok := addrRecord(a.host, svc.Addr, hostTTL); ok && !goodbye {
+		answers = append(answers, r)
+	}
+	msg := dnsmessage.Message{Header: dnsmessage.Header{Response: true, Authoritative: true}, Answers: answers}
+	b, err := msg.Pack()
+	if err != nil {
+		log.Printf("[mDNS] Failed to build announcement for %s: %v", svc.MappingID, err)
+		return
+	}
+	if _, err := a.conn.WriteToUDP(b, mdnsGroup); err != nil {
+		log.Printf("[mDNS] Failed to announce %s: %v", svc.MappingID, err)
+	}
+}
+
+func (a *MDNSAdvertiser) sortedLocked() []MDNSService {
+	list := make([]MDNSService, 0, len(a.services))
+	for _, svc := range a.services {
+		list = append(list, svc)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].MappingID < list[j].MappingID })
+	return list
+}
+
+func (a *MDNSAdvertiser) srvRecord(svc MDNSService, ttl uint32) dnsmessage.Resource {
+	return dnsmessage.Resource{
+		Header: mdnsHeader(mdnsInstanceName(svc), dnsmessage.TypeSRV, ttl, true),
+		Body:   &dnsmessage.SRVResource{Port: uint16(svc.Port), Target: dnsmessage.MustNewName(a.host)},
+	}
+}
+
+func ptrRecord(name, target string, ttl uint32) dnsmessage.Resource {
+	return dnsmessage.Resource{
+		Header: mdnsHeader(name, dnsmessage.TypePTR, ttl, false),
+		Body:   &dnsmessage.PTRResource{PTR: dnsmessage.MustNewName(target)},
+	}
+}
+
+func txtRecord(svc MDNSService, ttl uint32) dnsmessage.Resource {
+	return dnsmessage.Resource{
+		Header: mdnsHeader(mdnsInstanceName(svc), dnsmessage.TypeTXT, ttl, true),
+		Body:   &dnsmessage.TXTResource{TXT: []string{"mapping=" + svc.MappingID, "type=" + svc.Type}},
+	}
+}
+
+// addrRecord is the A or AAAA record of host for addr; false when addr is not an IP address.
+func addrRecord(host, addr string, ttl uint32) (dnsmessage.Resource, bool) {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return dnsmessage.Resource{}, false
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		var a [4]byte
+		copy(a[:], ip4)
+		return dnsmessage.Resource{
+			Header: mdnsHeader(host, dnsmessage.TypeA, ttl, true),
+			Body:   &dnsmessage.AResource{A: a},
+		}, true
+	}
+	var aaaa [16]byte
+	copy(aaaa[:], ip.To16())
+	return dnsmessage.Resource{
+		Header: mdnsHeader(host, dnsmessage.TypeAAAA, ttl, true),
+		Body:   &dnsmessage.AAAAResource{AAAA: aaaa},
+	}, true
+}
+
+func mdnsHeader(name string, t dnsmessage.Type, ttl uint32, unique bool) dnsmessage.ResourceHeader {
+	class := dnsmessage.ClassINET
+	if unique {
+		class |= mdnsCacheFlush
+	}
+	return dnsmessage.ResourceHeader{Name: dnsmessage.MustNewName(name), Type: t, Class: class, TTL: ttl}
+}
+
+// mdnsInstanceName is the DNS-SD instance name of a mapping: its key as a single label.
+func mdnsInstanceName(svc MDNSService) string {
+	return mdnsLabel(svc.MappingID) + "." + mdnsServiceType
+}
+
+// mdnsHostName is this host's name in the .local domain.
+func mdnsHostName() string {
+	host, _ := os.Hostname()
+	host, _, _ = strings.Cut(host, ".")
+	if host == "" {
+		host = "bastion"
+	}
+	return mdnsLabel(host) + ".local."
+}
+
+// mdnsLabel makes s a single DNS label: dots become dashes and it is cut to 63 bytes.
+func mdnsLabel(s string) string {
+	s = strings.ReplaceAll(s, ".", "-")
+	if len(s) > 63 {
+		s = s[:63]
+	}
+	return s
+}
+
+// advertise announces the session's listener when its mapping is exposed beyond localhost.
+func (s *BaseSession) advertise() {
+	if !config.Settings.MDNSAdvertise || s.Mapping == nil || s.Mapping.ExposeAddr == "" {
+		return
+	}
+	typ := s.Mapping.Type
+	if typ == "" {
+		typ = "tcp"
+	}
+	svc := MDNSService{MappingID: s.Mapping.Key(), Type: typ, Addr: s.Mapping.ExposeAddr, Port: s.LocalPort()}
+	s.mdns = &svc
+	MDNS.Advertise(svc)
+}
+
+// withdraw removes the session's advertisement, if any.
+func (s *BaseSession) withdraw() {
+	if s.mdns != nil {
+		MDNS.Withdraw(*s.mdns)
+	}
+}
@@ -0,0 +1,89 @@
+package core
+
+import (
+	"testing"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func mdnsQuery(t *testing.T, name string, typ dnsmessage.Type) []byte {
+	t.Helper()
+	msg := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: 7},
+		Questions: []dnsmessage.Question{{Name: dnsmessage.MustNewName(name), Type: typ, Class: dnsmessage.ClassINET}},
+	}
+	b, err := msg.Pack()
+	if err != nil {
+		t.Fatalf("pack query: %v", err)
+	}
+	return b
+}
+
+func mdnsAnswers(t *testing.T, resp []byte) []dnsmessage.Resource {
+	t.Helper()
+	var msg dnsmessage.Message
+	if err := msg.Unpack(resp); err != nil {
+		t.Fatalf("unpack response: %v", err)
+	}
+	return msg.Answers
+}
+
+func TestMDNSAdvertiser_Respond(t *testing.T) {
+	a := &MDNSAdvertiser{services: make(map[string]MDNSService), host: "devbox.local."}
+	svc := MDNSService{MappingID: "team.db", Type: "tcp", Addr: "192.168.1.20", Port: 15432}
+	a.Advertise(svc)
+
+	answers := mdnsAnswers(t, a.respond(mdnsQuery(t, mdnsServiceType, dnsmessage.TypePTR), false))
+	if len(answers) != 1 {
+		t.Fatalf("expected one PTR, got %d", len(answers))
+	}
+	instance := answers[0].Body.(*dnsmessage.PTRResource).PTR.String()
+	if instance != "team-db."+mdnsServiceType {
+		t.Fatalf("instance = %q", instance)
+	}
+
+	answers = mdnsAnswers(t, a.respond(mdnsQuery(t, instance, dnsmessage.TypeALL), false))
+	if len(answers) != 2 {
+		t.Fatalf("expected SRV and TXT, got %d records", len(answers))
+	}
+	srv := answers[0].Body.(*dnsmessage.SRVResource)
+	if srv.Port != 15432 || srv.Target.String() != "devbox.local." {
+		t.Fatalf("SRV = %+v", srv)
+	}
+	txt := answers[1].Body.(*dnsmessage.TXTResource).TXT
+	if len(txt) != 2 || txt[0] != "mapping=team.db" || txt[1] != "type=tcp" {
+		t.Fatalf("TXT = %v", txt)
+	}
+
+	answers = mdnsAnswers(t, a.respond(mdnsQuery(t, "devbox.local.", dnsmessage.TypeA), false))
+	if len(answers) != 1 || answers[0].Body.(*dnsmessage.AResource).A != [4]byte{192, 168, 1, 20} {
+		t.Fatalf("A = %+v", answers)
+	}
+
+	if resp := a.respond(mdnsQuery(t, "_http._tcp.local.", dnsmessage.TypePTR), false); resp != nil {
+		t.Fatal("expected no answer for another service type")
+	}
+
+	// A session that was replaced does not withdraw its successor's advertisement.
+	a.Withdraw(MDNSService{MappingID: "team.db", Type: "tcp", Addr: "192.168.1.20", Port: 15000})
+	if resp := a.respond(mdnsQuery(t, mdnsServiceType, dnsmessage.TypePTR), false); resp == nil {
+		t.Fatal("advertisement withdrawn by a stale session")
+	}
+	a.Withdraw(svc)
+	if resp := a.respond(mdnsQuery(t, mdnsServiceType, dnsmessage.TypePTR), false); resp != nil {
+		t.Fatal("expected no answer after withdrawing")
+	}
+}
+
+func TestMDNSAdvertiser_LegacyQueryEchoesID(t *testing.T) {
+	a := &MDNSAdvertiser{services: make(map[string]MDNSService), host: "devbox.local."}
+	a.Advertise(MDNSService{MappingID: "web", Type: "http", Addr: "10.0.0.5", Port: 8080})
+
+	var msg dnsmessage.Message
+	if err := msg.Unpack(a.respond(mdnsQuery(t, mdnsServiceType, dnsmessage.TypePTR), true)); err != nil {
+		t.Fatalf("unpack: %v", err)
+	}
+	if msg.Header.ID != 7 || len(msg.Questions) != 1 {
+		t.Fatalf("legacy reply must echo ID and question, got id=%d questions=%d", msg.Header.ID, len(msg.Questions))
+	}
+}
@@ -5,9 +5,7 @@ import (
 	"errors"
 	"log"
 	"net"
-	"strconv"
 	"strings"
-	"sync/atomic"
 	"time"
 
 	"bastion/config"
@@ -19,40 +17,21 @@ type MixedProxySession struct {
 }
 
 func NewMixedProxySession(mapping *models.Mapping, bastions []models.Bastion) *MixedProxySession {
-	ipACL, _ := NewIPAccessControl(mapping.GetAllowCIDRs(), mapping.GetDenyCIDRs())
-	chain := make([]string, 0, len(bastions))
-	for _, b := range bastions {
-		chain = append(chain, b.Name)
-	}
-	return &MixedProxySession{
-		BaseSession: BaseSession{
-			Mapping:        mapping,
-			Bastions:       bastions,
-			stopChan:       make(chan struct{}),
-			maxConnections: int32(config.Settings.MaxSessionConnections),
-			httpParsers:    make(map[string]*HTTPStreamParser),
-			ipACL:          ipACL,
-			auditCtx: AuditContext{
-				MappingID:    mapping.ID,
-				LocalPort:    mapping.LocalPort,
-				BastionChain: chain,
-			},
-		},
-	}
+	return &MixedProxySession{BaseSession: newBaseSession(mapping, bastions)}
 }
 
 func (s *MixedProxySession) Start() error {
-	addr := net.JoinHostPort(s.Mapping.LocalHost, strconv.Itoa(s.Mapping.LocalPort))
-	listener, err := listenTCPWithDiagnostics(s.Mapping)
+	addr, err := s.listen()
 	if err != nil {
 		return err
 	}
 
-	s.listener = listener
 	log.Printf("MIXED Proxy started: %s", addr)
 
 	s.wg.Add(1)
-	go s.acceptLoop()
+	s.spawn(s.acceptLoop)
+	s.startStandbyWatcher()
+	s.startThroughputSampler()
 	return nil
 }
 
@@ -77,36 +56,29 @@ func (s *MixedProxySession) acceptLoop() {
 			}
 		}
 
-		if !s.shouldAcceptClient(conn) {
-			if config.Settings.LogLevel == "DEBUG" {
-				log.Printf("[MIXED] Rejected client %s by IP ACL", conn.RemoteAddr().String())
-			}
-			_ = conn.Close()
-			continue
-		}
-
-		if atomic.LoadInt32(&s.activeConns) >= s.maxConnections {
-			log.Printf("Connection limit reached (%d), rejecting new connection", s.maxConnections)
-			_ = conn.Close()
+		conn, ok := s.admitClient(conn, "MIXED")
+		if !ok {
 			continue
 		}
 
 		s.wg.Add(1)
-		go s.handleMixedClientWithRecover(conn)
+		s.spawn(func() { s.handleMixedClientWithRecover(conn) })
 	}
 }
 
 func (s *MixedProxySession) handleMixedClientWithRecover(conn net.Conn) {
 	defer func() {
 		if r := recover(); r != nil {
-			log.Printf("Recovered from panic in handleMixedClient: %v", r)
+			s.recordPanic("handleMixedClient", r, map[string]interface{}{"client": conn.RemoteAddr().String()})
 		}
 	}()
 	s.handleMixedClient(conn)
 }
 
 func (s *MixedProxySession) handleMixedClient(conn net.Conn) {
+	untrack := s.trackConn(conn)
 	proto, wrapped, err := detectMixedProtocol(conn)
+	untrack()
 	if err != nil {
 		if config.Settings.LogLevel == "DEBUG" {
 			log.Printf("[MIXED] Protocol detect failed from %s: %v", conn.RemoteAddr().String(), err)
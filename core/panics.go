@@ -0,0 +1,68 @@
+package core
+
+import (
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sort"
+	"sync"
+)
+
+// PanicSource is the error log source of recovered panics.
+const PanicSource = "Panic"
+
+var (
+	panicsMu        sync.Mutex
+	panicsRecovered = make(map[string]uint64) // component -> count
+)
+
+// RecordPanic reports a panic recovered in component: the value and the stack of the panicking
+// goroutine go to the text log and the error log (with fields as context), and the component's
+// panic counter is incremented. Call it from the deferred function that recovered.
+func RecordPanic(component string, value interface{}, fields map[string]interface{}) {
+	stack := string(debug.Stack())
+
+	panicsMu.Lock()
+	panicsRecovered[component]++
+	panicsMu.Unlock()
+
+	log.Printf("Recovered from panic in %s: %v\n%s", component, value, stack)
+
+	context := map[string]interface{}{"component": component}
+	for k, v := range fields {
+		context[k] = v
+	}
+	ErrorLoggerInstance.LogError("ERROR", PanicSource, fmt.Sprintf("Recovered from panic in %s: %v", component, value), stack, context)
+}
+
+// PanicCount is the number of panics recovered in one component.
+type PanicCount struct {
+	Component string `json:"component"`
+	Count     uint64 `json:"count"`
+}
+
+// PanicsRecovered returns the number of recovered panics per component, sorted by component.
+func PanicsRecovered() []PanicCount {
+	panicsMu.Lock()
+	defer panicsMu.Unlock()
+
+	counts := make([]PanicCount, 0, len(panicsRecovered))
+	for component, n := range panicsRecovered {
+		counts = append(counts, PanicCount{Component: component, Count: n})
+	}
+	sort.Slice(counts, func(i, j int) bool { return counts[i].Component < counts[j].Component })
+	return counts
+}
+
+// recordPanic is RecordPanic with the session's mapping as context.
+func (s *BaseSession) recordPanic(component string, value interface{}, fields map[string]interface{}) {
+	context := map[string]interface{}{}
+	if s.Mapping != nil {
+		context["mapping_id"] = s.Mapping.Key()
+		context["mapping_type"] = s.Mapping.Type
+	}
+	for k, v := range fields {
+		context[k] = v
+	}
+	RecordPanic(component, value, context)
+}
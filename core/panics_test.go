@@ -0,0 +1,48 @@
+package core
+
+import (
+	"bastion/models"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestBaseSessionRecordPanic(t *testing.T) {
+	before := panicCount("copyData")
+	s := &BaseSession{Mapping: &models.Mapping{ID: "m1", Workspace: "default", Type: "tcp"}}
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				s.recordPanic("copyData", r, map[string]interface{}{"direction": "up"})
+			}
+		}()
+		panic("boom")
+	}()
+
+	if got := panicCount("copyData"); got != before+1 {
+		t.Fatalf("copyData panics = %d, want %d", got, before+1)
+	}
+	logs, _, _ := ErrorLoggerInstance.QueryErrorLogs(models.ErrorLogFilter{}, 1, 1)
+	if len(logs) != 1 || logs[0].Source != PanicSource || !strings.Contains(logs[0].Message, "boom") {
+		t.Fatalf("error log: %+v", logs)
+	}
+	if !strings.Contains(logs[0].Detail, "TestBaseSessionRecordPanic") {
+		t.Fatalf("stack does not reach the panic site: %s", logs[0].Detail)
+	}
+	var context map[string]interface{}
+	if err := json.Unmarshal([]byte(logs[0].Context), &context); err != nil {
+		t.Fatalf("context: %v", err)
+	}
+	if context["component"] != "copyData" || context["mapping_id"] != (&models.Mapping{ID: "m1", Workspace: "default"}).Key() || context["direction"] != "up" {
+		t.Fatalf("context: %v", context)
+	}
+}
+
+func panicCount(component string) uint64 {
+	for _, p := range PanicsRecovered() {
+		if p.Component == component {
+			return p.Count
+		}
+	}
+	return 0
+}
@@ -3,11 +3,14 @@ package core
 import (
 	"bastion/config"
 	"bastion/models"
+	"context"
 	"errors"
 	"fmt"
 	"log"
 	"net"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -28,13 +31,29 @@ type pooledSSHClient struct {
 	lastUsedAt      time.Time
 	lastKeepaliveAt time.Time
 	activeConnCount int
+	dependents      int // pooled chains built on this one (see createSSHChain)
+	rtt             rttHistory
+}
+
+// idle reports whether nothing uses the client: no open connections and no chains built on it.
+func (e *pooledSSHClient) idle() bool {
+	return e.activeConnCount == 0 && e.dependents == 0
 }
 
 // SSHConnectionPool maintains reusable SSH connections keyed by bastion chain.
 type SSHConnectionPool struct {
 	mu          sync.Mutex
 	pool        map[string]*pooledSSHClient
-	createChain func([]models.Bastion) (sshClient, error)
+	createChain func(ctx context.Context, bastions []models.Bastion) (sshClient, error)
+
+	// ctx is canceled by CloseAll, aborting the dials and chain builds in progress (see boundContext).
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// dialHop connects one hop of a chain built by createSSHChain; hopRetryDelay separates its attempts.
+	dialHop       func(ctx context.Context, prev sshClient, addr string, sshConfig *ssh.ClientConfig) (sshClient, error)
+	hopRetryDelay time.Duration
+	builds        *chainBuildLimiter
 
 	housekeepingOnce sync.Once
 	stopOnce         sync.Once
@@ -42,6 +61,7 @@ type SSHConnectionPool struct {
 
 	keepaliveFailuresTotal uint64
 	idleClosedTotal        uint64
+	channelLimitHits       uint64
 }
 
 var Pool *SSHConnectionPool
@@ -53,9 +73,13 @@ func init() {
 // NewSSHConnectionPool constructs a pool instance.
 func NewSSHConnectionPool() *SSHConnectionPool {
 	p := &SSHConnectionPool{
-		pool:   make(map[string]*pooledSSHClient),
-		stopCh: make(chan struct{}),
+		pool:          make(map[string]*pooledSSHClient),
+		stopCh:        make(chan struct{}),
+		dialHop:       dialPoolHop,
+		hopRetryDelay: 2 * time.Second,
+		builds:        newChainBuildLimiter(),
 	}
+	p.ctx, p.cancel = context.WithCancel(context.Background())
 	p.createChain = p.createSSHChain
 	return p
 }
@@ -90,48 +114,63 @@ func (p *SSHConnectionPool) stopHousekeeping() {
 func (p *SSHConnectionPool) getChainKey(bastions []models.Bastion) string {
 	names := make([]string, len(bastions))
 	for i, b := range bastions {
-		names[i] = b.Name
+		names[i] = b.Key()
 	}
 	return strings.Join(names, "->")
 }
 
 // Dial opens a tunneled TCP connection through the bastion chain using the pooled SSH client.
 // The returned net.Conn tracks active usage so the pool can safely reclaim idle clients.
-func (p *SSHConnectionPool) Dial(bastions []models.Bastion, network, addr string) (net.Conn, error) {
+// When a client refuses the channel because of a channel limit, the next pooled client of the
+// chain is tried, opening it if needed, up to SSH_POOL_MAX_CLIENTS_PER_CHAIN (ChannelLimitError).
+// Canceling ctx, or closing the pool, aborts the dial and a chain build it waits for.
+func (p *SSHConnectionPool) Dial(ctx context.Context, bastions []models.Bastion, network, addr string) (net.Conn, error) {
+	ctx, done := p.boundContext(ctx)
+	defer done()
 	key := p.getChainKey(bastions)
+	maxClients := maxClientsPerChain()
 
-	entry, err := p.getOrCreateHealthy(key, bastions)
-	if err != nil {
-		return nil, err
-	}
+	var refused error
+	for slot := 1; slot <= maxClients; slot++ {
+		slotKey := chainSlotKey(key, slot)
+		entry, err := p.getOrCreateHealthy(ctx, slotKey, bastions)
+		if err != nil {
+			if refused != nil {
+				return nil, &ChannelLimitError{Chain: key, Clients: slot - 1, Err: fmt.Errorf("%w (opening another client failed: %v)", refused, err)}
+			}
+			return nil, err
+		}
 
-	p.incActive(key, entry, time.Now())
-	conn, dialErr := entry.client.Dial(network, addr)
-	if dialErr != nil {
-		p.decActive(key, entry, time.Now())
-		return nil, dialErr
+		conn, err := p.dialEntry(ctx, slotKey, entry, network, addr)
+		if err == nil {
+			return conn, nil
+		}
+		if !p.channelLimited(entry, err) {
+			return nil, err
+		}
+		atomic.AddUint64(&p.channelLimitHits, 1)
+		refused = err
+		if config.Settings.LogLevel == "DEBUG" {
+			log.Printf("SSH channel refused on chain %s (client %d of %d): %v", key, slot, maxClients, err)
+		}
 	}
-
-	return &pooledConn{
-		Conn: conn,
-		release: func() {
-			p.decActive(key, entry, time.Now())
-		},
-	}, nil
+	return nil, &ChannelLimitError{Chain: key, Clients: maxClients, Err: refused}
 }
 
 // GetConnection returns an SSH chain client, creating it if needed.
 // Prefer Dial for forwarding paths so the pool can track active usage.
-func (p *SSHConnectionPool) GetConnection(bastions []models.Bastion) (sshClient, error) {
+func (p *SSHConnectionPool) GetConnection(ctx context.Context, bastions []models.Bastion) (sshClient, error) {
+	ctx, done := p.boundContext(ctx)
+	defer done()
 	key := p.getChainKey(bastions)
-	entry, err := p.getOrCreateHealthy(key, bastions)
+	entry, err := p.getOrCreateHealthy(ctx, key, bastions)
 	if err != nil {
 		return nil, err
 	}
 	return entry.client, nil
 }
 
-func (p *SSHConnectionPool) getOrCreateHealthy(key string, bastions []models.Bastion) (*pooledSSHClient, error) {
+func (p *SSHConnectionPool) getOrCreateHealthy(ctx context.Context, key string, bastions []models.Bastion) (*pooledSSHClient, error) {
 	if len(bastions) == 0 {
 		return nil, fmt.Errorf("empty bastion chain")
 	}
@@ -154,21 +193,24 @@ func (p *SSHConnectionPool) getOrCreateHealthy(key string, bastions []models.Bas
 
 		if entry != nil {
 			if keepaliveInterval > 0 && now.Sub(lastKeepalive) >= keepaliveInterval && active == 0 {
+				start := time.Now()
 				if err := sendKeepalive(entry.client, keepaliveTimeout); err != nil {
 					atomic.AddUint64(&p.keepaliveFailuresTotal, 1)
+					AlerterInstance.ObserveKeepalive(key, err)
 					if config.Settings.LogLevel == "DEBUG" {
 						log.Printf("SSH keepalive failed for chain %s: %v", key, err)
 					}
 					p.RemoveConnectionByKey(key)
 					continue
 				}
-				p.updateKeepalive(key, entry, now)
+				AlerterInstance.ObserveKeepalive(key, nil)
+				p.updateKeepalive(key, entry, now, time.Since(start))
 			}
 
 			return entry, nil
 		}
 
-		created, err := p.createAndStore(key, bastions, now)
+		created, err := p.createAndStore(ctx, key, bastions, now)
 		if err != nil {
 			return nil, err
 		}
@@ -176,7 +218,7 @@ func (p *SSHConnectionPool) getOrCreateHealthy(key string, bastions []models.Bas
 	}
 }
 
-func (p *SSHConnectionPool) createAndStore(key string, bastions []models.Bastion, now time.Time) (*pooledSSHClient, error) {
+func (p *SSHConnectionPool) createAndStore(ctx context.Context, key string, bastions []models.Bastion, now time.Time) (*pooledSSHClient, error) {
 	maxConns := config.Settings.SSHPoolMaxConns
 	var evicted []sshClient
 
@@ -202,8 +244,13 @@ func (p *SSHConnectionPool) createAndStore(key string, bastions []models.Bastion
 		_ = c.Close()
 	}
 
+	releaseBuild, err := p.builds.acquireBuild(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to establish SSH chain: %w", err)
+	}
 	log.Printf("Creating new SSH tunnel chain for: %s", key)
-	client, err := p.createChain(bastions)
+	client, err := p.createChain(ctx, bastions)
+	releaseBuild()
 	if err != nil {
 		return nil, fmt.Errorf("failed to establish SSH chain: %w", err)
 	}
@@ -240,7 +287,7 @@ func (p *SSHConnectionPool) evictIdleLocked(now time.Time, need int) []sshClient
 
 	candidates := make([]candidate, 0, len(p.pool))
 	for k, e := range p.pool {
-		if e == nil || e.activeConnCount != 0 {
+		if e == nil || !e.idle() {
 			continue
 		}
 		candidates = append(candidates, candidate{key: k, entry: e, lastUse: e.lastUsedAt})
@@ -270,13 +317,15 @@ func (p *SSHConnectionPool) evictIdleLocked(now time.Time, need int) []sshClient
 	return evicted
 }
 
-func (p *SSHConnectionPool) updateKeepalive(key string, entry *pooledSSHClient, now time.Time) {
+// updateKeepalive records a successful keepalive probe and its round-trip time.
+func (p *SSHConnectionPool) updateKeepalive(key string, entry *pooledSSHClient, now time.Time, rtt time.Duration) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 	if p.pool[key] != entry {
 		return
 	}
 	entry.lastKeepaliveAt = now
+	entry.rtt.add(rtt, now)
 }
 
 func (p *SSHConnectionPool) incActive(key string, entry *pooledSSHClient, now time.Time) {
@@ -319,7 +368,7 @@ func (p *SSHConnectionPool) housekeep(now time.Time) {
 			continue
 		}
 
-		if idleTimeout > 0 && entry.activeConnCount == 0 && now.Sub(entry.lastUsedAt) >= idleTimeout {
+		if idleTimeout > 0 && entry.idle() && now.Sub(entry.lastUsedAt) >= idleTimeout {
 			if config.Settings.LogLevel == "DEBUG" {
 				log.Printf("Closing idle SSH connection: %s (idle=%s)", key, now.Sub(entry.lastUsedAt).Truncate(time.Second))
 			}
@@ -344,17 +393,19 @@ func (p *SSHConnectionPool) housekeep(now time.Time) {
 	}
 
 	for _, cand := range keepaliveCandidates {
+		start := time.Now()
 		if err := sendKeepalive(cand.client, keepaliveTimeout); err != nil {
 			atomic.AddUint64(&p.keepaliveFailuresTotal, 1)
+			AlerterInstance.ObserveKeepalive(cand.key, err)
 			if config.Settings.LogLevel == "DEBUG" {
 				log.Printf("SSH keepalive failed for chain %s: %v", cand.key, err)
 			}
 
-			// Only remove/close when no active conns to avoid killing in-flight channels.
+			// Only remove/close when no active conns or chains built on it to avoid killing in-flight channels.
 			var toClose sshClient
 			p.mu.Lock()
 			current := p.pool[cand.key]
-			if current != nil && current == cand.entry && current.activeConnCount == 0 {
+			if current != nil && current == cand.entry && current.idle() {
 				delete(p.pool, cand.key)
 				toClose = current.client
 			} else if current != nil && current == cand.entry {
@@ -368,7 +419,8 @@ func (p *SSHConnectionPool) housekeep(now time.Time) {
 			continue
 		}
 
-		p.updateKeepalive(cand.key, cand.entry, now)
+		AlerterInstance.ObserveKeepalive(cand.key, nil)
+		p.updateKeepalive(cand.key, cand.entry, now, time.Since(start))
 	}
 }
 
@@ -410,6 +462,41 @@ func (c *pooledConn) Close() error {
 	return c.Conn.Close()
 }
 
+// HasConnection reports whether a pooled SSH client exists for the chain.
+func (p *SSHConnectionPool) HasConnection(bastions []models.Bastion) bool {
+	if len(bastions) == 0 {
+		return false
+	}
+	key := p.getChainKey(bastions)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.pool[key] != nil
+}
+
+// ReleaseIdle closes the pooled SSH clients of the chain (see Dial for the additional ones) that
+// carry no active connections. It reports whether a client was closed.
+func (p *SSHConnectionPool) ReleaseIdle(bastions []models.Bastion) bool {
+	if len(bastions) == 0 {
+		return false
+	}
+	key := p.getChainKey(bastions)
+
+	var toClose []sshClient
+	p.mu.Lock()
+	for k, entry := range p.pool {
+		if (k == key || strings.HasPrefix(k, key+"#")) && entry.idle() {
+			delete(p.pool, k)
+			toClose = append(toClose, entry.client)
+		}
+	}
+	p.mu.Unlock()
+
+	for _, c := range toClose {
+		_ = c.Close()
+	}
+	return len(toClose) > 0
+}
+
 // RemoveConnection removes a specific connection by chain.
 func (p *SSHConnectionPool) RemoveConnection(bastions []models.Bastion) {
 	if len(bastions) == 0 {
@@ -418,28 +505,113 @@ func (p *SSHConnectionPool) RemoveConnection(bastions []models.Bastion) {
 	p.RemoveConnectionByKey(p.getChainKey(bastions))
 }
 
+// RemoveConnectionByKey removes the chain with the given key and the pooled chains built on it,
+// which cannot outlive it.
 func (p *SSHConnectionPool) RemoveConnectionByKey(key string) {
-	var toClose sshClient
+	p.mu.Lock()
+	keys := p.removeLocked(key, nil)
+	toClose := make([]sshClient, 0, len(keys))
+	for _, k := range keys {
+		toClose = append(toClose, p.pool[k].client)
+		delete(p.pool, k)
+	}
+	p.mu.Unlock()
 
+	// Close the chains built on a prefix before the prefix itself.
+	for i := len(keys) - 1; i >= 0; i-- {
+		log.Printf("Removing connection: %s", keys[i])
+		_ = toClose[i].Close()
+	}
+}
+
+// RemoveConnectionsWithBastion removes every pooled chain that goes through the bastion with key
+// bastionKey (see models.Bastion.Key), so new dials rebuild them, and returns the removed keys.
+// Connections open on the removed clients are closed.
+func (p *SSHConnectionPool) RemoveConnectionsWithBastion(bastionKey string) []string {
 	p.mu.Lock()
-	entry, exists := p.pool[key]
-	if exists {
-		delete(p.pool, key)
-		toClose = entry.client
+	matched := make([]string, 0)
+	for k := range p.pool {
+		if chainHasBastion(k, bastionKey) {
+			matched = append(matched, k)
+		}
+	}
+	// Shorter chains first, so chains built on a removed prefix are collected with it.
+	sort.Slice(matched, func(i, j int) bool {
+		ni, nj := strings.Count(matched[i], "->"), strings.Count(matched[j], "->")
+		if ni != nj {
+			return ni < nj
+		}
+		return matched[i] < matched[j]
+	})
+	keys := make([]string, 0, len(matched))
+	seen := make(map[string]bool, len(matched))
+	for _, k := range matched {
+		if seen[k] {
+			continue
+		}
+		for _, removed := range p.removeLocked(k, nil) {
+			if !seen[removed] {
+				seen[removed] = true
+				keys = append(keys, removed)
+			}
+		}
+	}
+	toClose := make([]sshClient, 0, len(keys))
+	for _, k := range keys {
+		toClose = append(toClose, p.pool[k].client)
+		delete(p.pool, k)
 	}
 	p.mu.Unlock()
 
-	if exists {
-		log.Printf("Removing connection: %s", key)
-		_ = toClose.Close()
+	for i := len(keys) - 1; i >= 0; i-- {
+		log.Printf("Removing connection: %s", keys[i])
+		_ = toClose[i].Close()
+	}
+	return keys
+}
+
+// chainHasBastion reports whether the pool key of a chain (see getChainKey and chainSlotKey)
+// contains the bastion with key bastionKey.
+func chainHasBastion(key, bastionKey string) bool {
+	if i := strings.LastIndex(key, "#"); i >= 0 {
+		if _, err := strconv.Atoi(key[i+1:]); err == nil {
+			key = key[:i]
+		}
+	}
+	for _, name := range strings.Split(key, "->") {
+		if name == bastionKey {
+			return true
+		}
+	}
+	return false
+}
+
+// removeLocked appends key and, after it, the keys of the pooled chains built on it.
+func (p *SSHConnectionPool) removeLocked(key string, keys []string) []string {
+	entry := p.pool[key]
+	if entry == nil {
+		return keys
+	}
+	keys = append(keys, key)
+	if entry.dependents == 0 {
+		return keys
 	}
+	for k, e := range p.pool {
+		if c, ok := e.client.(*chainClient); ok && c.prefix == key && k != key {
+			keys = p.removeLocked(k, keys)
+		}
+	}
+	return keys
 }
 
-// CloseAll closes all pooled connections and stops housekeeping.
+// CloseAll aborts the dials and chain builds in progress, closes all pooled connections and stops
+// housekeeping.
 func (p *SSHConnectionPool) CloseAll() {
 	p.stopHousekeeping()
 
 	p.mu.Lock()
+	p.cancel()
+	p.ctx, p.cancel = context.WithCancel(context.Background())
 	connsToClose := make(map[string]sshClient, len(p.pool))
 	for key, conn := range p.pool {
 		if conn != nil {
@@ -477,6 +649,19 @@ func (p *SSHConnectionPool) SSHPoolActiveConns() int {
 	return total
 }
 
+// SSHPoolSharedPrefixes returns the number of pooled SSH clients that other pooled chains are built on.
+func (p *SSHConnectionPool) SSHPoolSharedPrefixes() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	total := 0
+	for _, entry := range p.pool {
+		if entry != nil && entry.dependents > 0 {
+			total++
+		}
+	}
+	return total
+}
+
 // SSHKeepaliveFailuresTotal returns the total number of keepalive failures observed.
 func (p *SSHConnectionPool) SSHKeepaliveFailuresTotal() uint64 {
 	return atomic.LoadUint64(&p.keepaliveFailuresTotal)
@@ -487,90 +672,77 @@ func (p *SSHConnectionPool) SSHIdleClosedTotal() uint64 {
 	return atomic.LoadUint64(&p.idleClosedTotal)
 }
 
-// createSSHChain builds the SSH chain with retry logic.
-func (p *SSHConnectionPool) createSSHChain(bastions []models.Bastion) (sshClient, error) {
-	var conn *ssh.Client
-	var err error
-	maxRetries := 3
-	retryDelay := 2 * time.Second
-
-	for i, b := range bastions {
-		sshConfig := &ssh.ClientConfig{
-			User:            b.Username,
-			HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-			Timeout:         time.Duration(config.Settings.SSHConnectTimeout) * time.Second,
-		}
-
-		// Configure auth methods
-		if b.PkeyPath != "" {
-			key, err := loadPrivateKey(b.PkeyPath, b.PkeyPassphrase)
-			if err != nil {
-				log.Printf("Failed to load key for %s: %v", b.Name, err)
-			} else {
-				sshConfig.Auth = append(sshConfig.Auth, ssh.PublicKeys(key))
-			}
-		}
-
-		if b.Password != "" {
-			sshConfig.Auth = append(sshConfig.Auth, ssh.Password(b.Password))
-		}
+// bastionClientConfig builds the SSH client config (auth methods and timeout) for one bastion.
+func bastionClientConfig(b models.Bastion) (*ssh.ClientConfig, error) {
+	sshConfig := &ssh.ClientConfig{
+		User:            b.Username,
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		Timeout:         time.Duration(config.Settings.SSHConnectTimeout) * time.Second,
+	}
 
-		// Ensure at least one auth method
-		if len(sshConfig.Auth) == 0 {
-			if conn != nil {
-				_ = conn.Close()
-			}
-			return nil, fmt.Errorf("no authentication method configured for %s", b.Name)
+	if b.PkeyPath != "" {
+		key, err := loadPrivateKey(b.PkeyPath, b.PkeyPassphrase)
+		if err != nil {
+			log.Printf("Failed to load key for %s: %v", b.Name, err)
+		} else {
+			sshConfig.Auth = append(sshConfig.Auth, ssh.PublicKeys(key))
 		}
+	}
 
-		addr := fmt.Sprintf("%s:%d", b.Host, b.Port)
-
-		// Retry logic
-		var lastErr error
-		for retry := 0; retry < maxRetries; retry++ {
-			if retry > 0 {
-				log.Printf("Retrying connection to %s (attempt %d/%d)", b.Name, retry+1, maxRetries)
-				time.Sleep(retryDelay)
-			}
+	if b.Password != "" {
+		sshConfig.Auth = append(sshConfig.Auth, ssh.Password(b.Password))
+	}
 
-			if i == 0 {
-				// First hop: connect directly
-				conn, err = ssh.Dial("tcp", addr, sshConfig)
-				if err == nil {
-					break
-				}
-				lastErr = err
-			} else {
-				// Subsequent hops: tunnel through previous connection
-				netConn, err := conn.Dial("tcp", addr)
-				if err != nil {
-					lastErr = err
-					continue
-				}
+	if len(sshConfig.Auth) == 0 {
+		return nil, fmt.Errorf("no authentication method configured for %s", b.Name)
+	}
 
-				ncc, chans, reqs, err := ssh.NewClientConn(netConn, addr, sshConfig)
-				if err != nil {
-					_ = netConn.Close()
-					lastErr = err
-					continue
-				}
+	var err error
+	if sshConfig.Ciphers, err = ParseSSHAlgorithms(SSHAlgorithmCiphers, b.Ciphers); err != nil {
+		return nil, fmt.Errorf("bastion %s: %w", b.Name, err)
+	}
+	if sshConfig.MACs, err = ParseSSHAlgorithms(SSHAlgorithmMACs, b.MACs); err != nil {
+		return nil, fmt.Errorf("bastion %s: %w", b.Name, err)
+	}
+	if sshConfig.KeyExchanges, err = ParseSSHAlgorithms(SSHAlgorithmKeyExchanges, b.KeyExchanges); err != nil {
+		return nil, fmt.Errorf("bastion %s: %w", b.Name, err)
+	}
+	return sshConfig, nil
+}
 
-				conn = ssh.NewClient(ncc, chans, reqs)
-				break
-			}
-		}
+// dialSSHHop connects to addr directly (prev == nil) or tunneled through the previous hop. The
+// connection is bounded by sshConfig.Timeout (SSH_CONNECT_TIMEOUT) and the SSH handshake with
+// authentication by SSH_HANDSHAKE_TIMEOUT_SECONDS; canceling ctx aborts either.
+func dialSSHHop(ctx context.Context, prev sshClient, addr string, sshConfig *ssh.ClientConfig) (*ssh.Client, error) {
+	var (
+		netConn net.Conn
+		err     error
+	)
+	if prev == nil {
+		dialer := net.Dialer{Timeout: sshConfig.Timeout}
+		netConn, err = dialer.DialContext(ctx, "tcp", addr)
+	} else {
+		netConn, err = runPhase(ctx, "connect to "+addr, sshConfig.Timeout, func() (net.Conn, error) {
+			return prev.Dial("tcp", addr)
+		}, nil, closeConn)
+	}
+	if err != nil {
+		return nil, err
+	}
 
-		if lastErr != nil {
-			if conn != nil {
-				_ = conn.Close()
-			}
-			return nil, fmt.Errorf("failed to connect to %s after %d attempts: %w", b.Name, maxRetries, lastErr)
+	handshakeTimeout := time.Duration(config.Settings.SSHHandshakeTimeoutSeconds) * time.Second
+	client, err := runPhase(ctx, "ssh handshake with "+addr, handshakeTimeout, func() (*ssh.Client, error) {
+		ncc, chans, reqs, err := ssh.NewClientConn(netConn, addr, sshConfig)
+		if err != nil {
+			return nil, err
 		}
-
-		log.Printf("Connected to bastion: %s", b.Name)
+		return ssh.NewClient(ncc, chans, reqs), nil
+	}, func() { _ = netConn.Close() }, func(c *ssh.Client) { _ = c.Close() })
+	if err != nil {
+		_ = netConn.Close()
+		return nil, err
 	}
-
-	return conn, nil
+	return client, nil
 }
 
 // loadPrivateKey loads a private key (optionally encrypted).
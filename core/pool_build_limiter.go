@@ -0,0 +1,115 @@
+package core
+
+import (
+	"bastion/config"
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// chainBuildLimiter bounds the SSH chains built at once (SSH_CHAIN_BUILD_CONCURRENCY) and the
+// connections being opened to one bastion at once (SSH_CHAIN_BUILD_PER_BASTION), so a burst of
+// starts or reconnects does not trip rate limits or fail2ban on the jump hosts. Callers over a
+// limit wait for a slot up to SSH_CHAIN_BUILD_QUEUE_TIMEOUT_SECONDS.
+type chainBuildLimiter struct {
+	mu      sync.Mutex
+	inUse   map[string]int // by bastion key, globalBuildSlot for whole chain builds
+	changed chan struct{}  // closed and replaced whenever a slot is released
+
+	queued       int64
+	queuedTotal  uint64
+	timeoutTotal uint64
+}
+
+// globalBuildSlot is the inUse key of the global limit; bastion keys are never empty.
+const globalBuildSlot = ""
+
+func newChainBuildLimiter() *chainBuildLimiter {
+	return &chainBuildLimiter{inUse: make(map[string]int), changed: make(chan struct{})}
+}
+
+// acquireBuild waits for a slot to build a chain.
+func (l *chainBuildLimiter) acquireBuild(ctx context.Context) (func(), error) {
+	return l.acquire(ctx, globalBuildSlot, config.Settings.SSHChainBuildConcurrency, "chain builds")
+}
+
+// acquireBastion waits for a slot to connect to the bastion with the given key.
+func (l *chainBuildLimiter) acquireBastion(ctx context.Context, key string) (func(), error) {
+	return l.acquire(ctx, key, config.Settings.SSHChainBuildPerBastion, "connections to "+key)
+}
+
+// acquire takes one of limit slots of key (limit <= 0 is unlimited), waiting in line while all are
+// taken, until the queue timeout passes or ctx is done. The returned func gives the slot back.
+func (l *chainBuildLimiter) acquire(ctx context.Context, key string, limit int, what string) (func(), error) {
+	timeout := time.Duration(config.Settings.SSHChainBuildQueueTimeoutSeconds) * time.Second
+	var expired <-chan time.Time
+	queued := false
+	for {
+		l.mu.Lock()
+		if limit <= 0 || l.inUse[key] < limit {
+			l.inUse[key]++
+			l.mu.Unlock()
+			if queued {
+				atomic.AddInt64(&l.queued, -1)
+			}
+			return func() { l.release(key) }, nil
+		}
+		changed := l.changed
+		l.mu.Unlock()
+
+		if !queued {
+			queued = true
+			atomic.AddInt64(&l.queued, 1)
+			atomic.AddUint64(&l.queuedTotal, 1)
+			if timeout > 0 {
+				timer := time.NewTimer(timeout)
+				defer timer.Stop()
+				expired = timer.C
+			}
+		}
+		select {
+		case <-changed:
+		case <-expired:
+			atomic.AddInt64(&l.queued, -1)
+			atomic.AddUint64(&l.timeoutTotal, 1)
+			return nil, fmt.Errorf("timed out after %s waiting for a slot: %d %s in progress", timeout, limit, what)
+		case <-ctx.Done():
+			atomic.AddInt64(&l.queued, -1)
+			return nil, fmt.Errorf("waiting for a slot for %s: %w", what, ctx.Err())
+		}
+	}
+}
+
+func (l *chainBuildLimiter) release(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.inUse[key]--; l.inUse[key] <= 0 {
+		delete(l.inUse, key)
+	}
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// SSHChainBuildsInProgress returns the number of SSH chains being built.
+func (p *SSHConnectionPool) SSHChainBuildsInProgress() int {
+	p.builds.mu.Lock()
+	defer p.builds.mu.Unlock()
+	return p.builds.inUse[globalBuildSlot]
+}
+
+// SSHChainBuildsQueued returns the number of chain builds and bastion connections waiting for a slot.
+func (p *SSHConnectionPool) SSHChainBuildsQueued() int64 {
+	return atomic.LoadInt64(&p.builds.queued)
+}
+
+// SSHChainBuildsQueuedTotal returns how often a chain build or bastion connection had to wait for a slot.
+func (p *SSHConnectionPool) SSHChainBuildsQueuedTotal() uint64 {
+	return atomic.LoadUint64(&p.builds.queuedTotal)
+}
+
+// SSHChainBuildQueueTimeoutsTotal returns how often waiting for a slot timed out.
+func (p *SSHConnectionPool) SSHChainBuildQueueTimeoutsTotal() uint64 {
+	return atomic.LoadUint64(&p.builds.timeoutTotal)
+}
@@ -0,0 +1,89 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"bastion/config"
+)
+
+func setChainBuildLimits(t *testing.T, global, perBastion, timeoutSeconds int) {
+	t.Helper()
+	old := config.Settings
+	t.Cleanup(func() {
+		config.Settings.SSHChainBuildConcurrency = old.SSHChainBuildConcurrency
+		config.Settings.SSHChainBuildPerBastion = old.SSHChainBuildPerBastion
+		config.Settings.SSHChainBuildQueueTimeoutSeconds = old.SSHChainBuildQueueTimeoutSeconds
+	})
+	config.Settings.SSHChainBuildConcurrency = global
+	config.Settings.SSHChainBuildPerBastion = perBastion
+	config.Settings.SSHChainBuildQueueTimeoutSeconds = timeoutSeconds
+}
+
+func TestChainBuildLimiter_QueuesUntilRelease(t *testing.T) {
+	setChainBuildLimits(t, 1, 0, 0)
+	pool := NewSSHConnectionPool()
+
+	release, err := pool.builds.acquireBuild(context.Background())
+	if err != nil {
+		t.Fatalf("acquireBuild: %v", err)
+	}
+
+	acquired := make(chan func())
+	go func() {
+		second, err := pool.builds.acquireBuild(context.Background())
+		if err != nil {
+			t.Errorf("acquireBuild: %v", err)
+		}
+		acquired <- second
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for pool.SSHChainBuildsQueued() != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the second build queued")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	release()
+
+	select {
+	case second := <-acquired:
+		second()
+	case <-time.After(time.Second):
+		t.Fatal("expected the queued build to get the released slot")
+	}
+	if pool.SSHChainBuildsQueued() != 0 || pool.SSHChainBuildsQueuedTotal() != 1 || pool.SSHChainBuildsInProgress() != 0 {
+		t.Fatalf("unexpected counters: queued=%d total=%d in progress=%d",
+			pool.SSHChainBuildsQueued(), pool.SSHChainBuildsQueuedTotal(), pool.SSHChainBuildsInProgress())
+	}
+}
+
+func TestChainBuildLimiter_PerBastionTimeout(t *testing.T) {
+	setChainBuildLimits(t, 0, 1, 1)
+	pool := NewSSHConnectionPool()
+
+	release, err := pool.builds.acquireBastion(context.Background(), "jump")
+	if err != nil {
+		t.Fatalf("acquireBastion: %v", err)
+	}
+	defer release()
+
+	// Other bastions are not limited by it.
+	other, err := pool.builds.acquireBastion(context.Background(), "other")
+	if err != nil {
+		t.Fatalf("acquireBastion: %v", err)
+	}
+	other()
+
+	if _, err := pool.builds.acquireBastion(context.Background(), "jump"); err == nil {
+		t.Fatal("expected timeout")
+	}
+	if got := pool.SSHChainBuildQueueTimeoutsTotal(); got != 1 {
+		t.Fatalf("expected 1 timeout, got %d", got)
+	}
+	if got := pool.SSHChainBuildsQueued(); got != 0 {
+		t.Fatalf("expected nothing queued, got %d", got)
+	}
+}
@@ -0,0 +1,235 @@
+package core
+
+import (
+	"bastion/config"
+	"bastion/models"
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// chainHopAttempts is how often each hop of a chain is dialed before the chain fails.
+const chainHopAttempts = 3
+
+// chainClient is a chain built by createSSHChain: the client of its last hop, tunneled through the
+// hops before it. Closing it closes the hops built for it, last first, and releases the pooled
+// prefix it was built on.
+type chainClient struct {
+	sshClient
+	owned   []sshClient
+	prefix  string // pool key of the chain it was built on, "" when none
+	release func()
+	once    sync.Once
+}
+
+func (c *chainClient) Close() error {
+	var err error
+	c.once.Do(func() {
+		for i := len(c.owned) - 1; i >= 0; i-- {
+			if cerr := c.owned[i].Close(); cerr != nil && err == nil {
+				err = cerr
+			}
+		}
+		c.release()
+	})
+	return err
+}
+
+// hopConfig is the client config of one hop, built in the background.
+type hopConfig struct {
+	done chan struct{}
+	cfg  *ssh.ClientConfig
+	err  error
+}
+
+func (h *hopConfig) wait() (*ssh.ClientConfig, error) {
+	<-h.done
+	return h.cfg, h.err
+}
+
+// prepareHopConfigs starts building the client configs of all hops at once, so loading and
+// decrypting the keys of later hops overlaps with connecting the earlier ones.
+func prepareHopConfigs(bastions []models.Bastion) []*hopConfig {
+	configs := make([]*hopConfig, len(bastions))
+	for i, b := range bastions {
+		hc := &hopConfig{done: make(chan struct{})}
+		configs[i] = hc
+		go func(b models.Bastion) {
+			defer close(hc.done)
+			hc.cfg, hc.err = bastionClientConfig(b)
+		}(b)
+	}
+	return configs
+}
+
+// createSSHChain connects the hops of a chain in order, retrying each hop. A hop is dialed through
+// the previous one, which must have authenticated first, so only the preparation of the hops
+// (see prepareHopConfigs) runs ahead. With SSH_CHAIN_PREFIX_REUSE the chain is built on the longest
+// chain in the pool that it extends, and the shorter chains it builds on the way are pooled, so
+// chains sharing their first hops connect those hops once. Canceling ctx aborts the build.
+func (p *SSHConnectionPool) createSSHChain(ctx context.Context, bastions []models.Bastion) (sshClient, error) {
+	reuse := config.Settings.SSHChainPrefixReuse
+	if !reuse {
+		return p.buildChain(ctx, bastions, 0, nil, func() {}, false)
+	}
+
+	start, base, release := p.acquirePrefix(bastions)
+	client, err := p.buildChain(ctx, bastions, start, base, release, true)
+	if err != nil && start > 0 && ctx.Err() == nil {
+		timeout := time.Duration(config.Settings.SSHPoolKeepaliveTimeoutMS) * time.Millisecond
+		if sendKeepalive(base, timeout) != nil {
+			// The pooled prefix broke: drop it and connect every hop.
+			p.RemoveConnectionByKey(p.getChainKey(bastions[:start]))
+			return p.buildChain(ctx, bastions, 0, nil, func() {}, true)
+		}
+	}
+	return client, err
+}
+
+// buildChain connects bastions[start:] through base (nil to start with the first hop); release
+// gives base back. With pool set, each shorter chain built on the way is added to the pool.
+func (p *SSHConnectionPool) buildChain(ctx context.Context, bastions []models.Bastion, start int, base sshClient, release func(), pool bool) (sshClient, error) {
+	configs := prepareHopConfigs(bastions[start:])
+	prev := base
+	prefixKey := ""
+	if base != nil {
+		prefixKey = p.getChainKey(bastions[:start])
+	}
+	var owned []sshClient
+	for i := start; i < len(bastions); i++ {
+		b := bastions[i]
+		next, err := p.connectHop(ctx, prev, b, configs[i-start])
+		if err != nil {
+			for j := len(owned) - 1; j >= 0; j-- {
+				_ = owned[j].Close()
+			}
+			release()
+			return nil, err
+		}
+		log.Printf("Connected to bastion: %s", b.Name)
+		owned = append(owned, next)
+		prev = next
+
+		if pool && i < len(bastions)-1 {
+			prefix := &chainClient{sshClient: next, owned: owned, prefix: prefixKey, release: release}
+			if key, prefixRelease, ok := p.storePrefix(bastions[:i+1], prefix); ok {
+				owned, prefixKey, release = nil, key, prefixRelease
+			}
+		}
+	}
+	return &chainClient{sshClient: prev, owned: owned, prefix: prefixKey, release: release}, nil
+}
+
+// connectHop dials one hop through prev, retrying unless authentication failed or ctx is done.
+func (p *SSHConnectionPool) connectHop(ctx context.Context, prev sshClient, b models.Bastion, hc *hopConfig) (sshClient, error) {
+	sshConfig, err := hc.wait()
+	if err != nil {
+		return nil, err
+	}
+	addr := net.JoinHostPort(b.Host, strconv.Itoa(b.Port))
+
+	var lastErr error
+	for attempt := 1; attempt <= chainHopAttempts; attempt++ {
+		if attempt > 1 {
+			log.Printf("Retrying connection to %s (attempt %d/%d)", b.Name, attempt, chainHopAttempts)
+			select {
+			case <-ctx.Done():
+				return nil, fmt.Errorf("connecting to %s: %w", b.Name, ctx.Err())
+			case <-time.After(p.hopRetryDelay):
+			}
+		}
+		releaseSlot, err := p.builds.acquireBastion(ctx, b.Key())
+		if err != nil {
+			return nil, err
+		}
+		next, err := p.dialHop(ctx, prev, addr, sshConfig)
+		releaseSlot()
+		if err == nil {
+			return next, nil
+		}
+		lastErr = err
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("connecting to %s: %w", b.Name, err)
+		}
+		if strings.Contains(err.Error(), "unable to authenticate") {
+			// Retrying will not fix the credentials
+			EventExport.Emit(SecurityEvent{Type: EventAuthFailure, Protocol: "SSH", Target: addr, Reason: err.Error()})
+			break
+		}
+	}
+	return nil, fmt.Errorf("failed to connect to %s after %d attempts: %w", b.Name, chainHopAttempts, lastErr)
+}
+
+// dialPoolHop is the default SSHConnectionPool.dialHop.
+func dialPoolHop(ctx context.Context, prev sshClient, addr string, sshConfig *ssh.ClientConfig) (sshClient, error) {
+	client, err := dialSSHHop(ctx, prev, addr, sshConfig)
+	if err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// acquirePrefix finds the longest pooled chain that bastions extends and counts the chain about to
+// be built on it as its dependent until the returned release is called. It returns the number of
+// hops that chain covers, 0 when none.
+func (p *SSHConnectionPool) acquirePrefix(bastions []models.Bastion) (int, sshClient, func()) {
+	now := time.Now()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for n := len(bastions) - 1; n >= 1; n-- {
+		key := p.getChainKey(bastions[:n])
+		entry := p.pool[key]
+		if entry == nil {
+			continue
+		}
+		entry.dependents++
+		entry.lastUsedAt = now
+		return n, entry.client, func() { p.releaseDependent(key, entry, time.Now()) }
+	}
+	return 0, nil, func() {}
+}
+
+// storePrefix pools a shorter chain built on the way, with the chain being built as its dependent.
+// It is not stored when the pool has one for the same hops or is full.
+func (p *SSHConnectionPool) storePrefix(bastions []models.Bastion, client sshClient) (string, func(), bool) {
+	key := p.getChainKey(bastions)
+	now := time.Now()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.pool[key] != nil {
+		return "", nil, false
+	}
+	if maxConns := config.Settings.SSHPoolMaxConns; maxConns > 0 && len(p.pool) >= maxConns {
+		return "", nil, false
+	}
+	entry := &pooledSSHClient{
+		client:          client,
+		createdAt:       now,
+		lastUsedAt:      now,
+		lastKeepaliveAt: now,
+		dependents:      1,
+	}
+	p.pool[key] = entry
+	return key, func() { p.releaseDependent(key, entry, time.Now()) }, true
+}
+
+// releaseDependent drops a chain built on the pooled entry at key.
+func (p *SSHConnectionPool) releaseDependent(key string, entry *pooledSSHClient, now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.pool[key] != entry {
+		return
+	}
+	if entry.dependents > 0 {
+		entry.dependents--
+	}
+	entry.lastUsedAt = now
+}
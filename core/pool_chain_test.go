@@ -0,0 +1,216 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"bastion/config"
+	"bastion/models"
+
+	"golang.org/x/crypto/ssh"
+)
+
+type hopDialer struct {
+	mu    sync.Mutex
+	dials []string // addr of each dial, prefixed with "via " when tunneled
+	fail  map[string]error
+}
+
+func (d *hopDialer) dial(_ context.Context, prev sshClient, addr string, _ *ssh.ClientConfig) (sshClient, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.fail[addr]; err != nil {
+		return nil, err
+	}
+	if prev != nil {
+		d.dials = append(d.dials, "via "+addr)
+	} else {
+		d.dials = append(d.dials, addr)
+	}
+	return &fakeSSHClient{}, nil
+}
+
+func chainTestPool(t *testing.T, reuse bool) (*SSHConnectionPool, *hopDialer) {
+	t.Helper()
+	old := config.Settings.SSHChainPrefixReuse
+	oldMax := config.Settings.SSHPoolMaxConns
+	t.Cleanup(func() {
+		config.Settings.SSHChainPrefixReuse = old
+		config.Settings.SSHPoolMaxConns = oldMax
+	})
+	config.Settings.SSHChainPrefixReuse = reuse
+	config.Settings.SSHPoolMaxConns = 0
+
+	d := &hopDialer{fail: map[string]error{}}
+	pool := NewSSHConnectionPool()
+	pool.dialHop = d.dial
+	pool.hopRetryDelay = 0
+	return pool, d
+}
+
+func chainHops(names ...string) []models.Bastion {
+	hops := make([]models.Bastion, len(names))
+	for i, n := range names {
+		hops[i] = models.Bastion{Name: n, Host: n, Port: 22, Username: "u", Password: "p"}
+	}
+	return hops
+}
+
+func TestCreateSSHChain_ReusesPooledPrefix(t *testing.T) {
+	pool, d := chainTestPool(t, true)
+
+	if _, err := pool.GetConnection(context.Background(), chainHops("a", "b", "c")); err != nil {
+		t.Fatalf("GetConnection: %v", err)
+	}
+	if len(d.dials) != 3 {
+		t.Fatalf("expected 3 dials, got %v", d.dials)
+	}
+	// a and a->b are pooled alongside a->b->c
+	if got := pool.SSHPoolConnections(); got != 3 {
+		t.Fatalf("expected 3 pooled chains, got %d", got)
+	}
+
+	d.dials = nil
+	client, err := pool.GetConnection(context.Background(), chainHops("a", "b", "d"))
+	if err != nil {
+		t.Fatalf("GetConnection: %v", err)
+	}
+	if len(d.dials) != 1 || d.dials[0] != "via d:22" {
+		t.Fatalf("expected one dial through the pooled prefix, got %v", d.dials)
+	}
+
+	prefix := pool.pool[pool.getChainKey(chainHops("a", "b"))]
+	if prefix.dependents != 2 {
+		t.Fatalf("expected prefix used by both chains, got %d", prefix.dependents)
+	}
+	if got := pool.SSHPoolActiveConns(); got != 0 {
+		t.Fatalf("chains built on a prefix are not active conns, got %d", got)
+	}
+	_ = client.Close()
+	if prefix.dependents != 1 {
+		t.Fatalf("expected prefix released, got %d", prefix.dependents)
+	}
+	if prefix.client.(*chainClient).sshClient.(*fakeSSHClient).closed {
+		t.Fatal("closing a chain must not close its pooled prefix")
+	}
+}
+
+func TestCreateSSHChain_WithoutReuse(t *testing.T) {
+	pool, d := chainTestPool(t, false)
+
+	for _, last := range []string{"c", "d"} {
+		if _, err := pool.GetConnection(context.Background(), chainHops("a", "b", last)); err != nil {
+			t.Fatalf("GetConnection: %v", err)
+		}
+	}
+	if len(d.dials) != 6 {
+		t.Fatalf("expected every hop dialed, got %v", d.dials)
+	}
+	if got := pool.SSHPoolConnections(); got != 2 {
+		t.Fatalf("expected only full chains pooled, got %d", got)
+	}
+}
+
+func TestCreateSSHChain_FailureClosesBuiltHops(t *testing.T) {
+	pool, d := chainTestPool(t, false)
+	d.fail["c:22"] = errors.New("ssh: handshake failed: ssh: unable to authenticate")
+
+	var built []*fakeSSHClient
+	dial := pool.dialHop
+	pool.dialHop = func(ctx context.Context, prev sshClient, addr string, cfg *ssh.ClientConfig) (sshClient, error) {
+		c, err := dial(ctx, prev, addr, cfg)
+		if err == nil {
+			built = append(built, c.(*fakeSSHClient))
+		}
+		return c, err
+	}
+
+	if _, err := pool.createSSHChain(context.Background(), chainHops("a", "b", "c")); err == nil {
+		t.Fatal("expected error")
+	}
+	if len(built) != 2 {
+		t.Fatalf("expected 2 hops built, got %d", len(built))
+	}
+	for _, c := range built {
+		if !c.closed {
+			t.Fatal("expected hops of a failed chain closed")
+		}
+	}
+}
+
+func TestSSHConnectionPool_SharesShorterChain(t *testing.T) {
+	pool, d := chainTestPool(t, true)
+
+	if _, err := pool.GetConnection(context.Background(), chainHops("a", "b")); err != nil {
+		t.Fatalf("GetConnection: %v", err)
+	}
+	d.dials = nil
+	if _, err := pool.GetConnection(context.Background(), chainHops("a", "b", "c")); err != nil {
+		t.Fatalf("GetConnection: %v", err)
+	}
+	if len(d.dials) != 1 || d.dials[0] != "via c:22" {
+		t.Fatalf("expected c dialed through the pooled a->b, got %v", d.dials)
+	}
+	// a carries a->b, which carries a->b->c
+	if got := pool.SSHPoolSharedPrefixes(); got != 2 {
+		t.Fatalf("expected 2 shared prefixes, got %d", got)
+	}
+}
+
+func TestSSHConnectionPool_PrefixOutlivesIdleTimeoutWhileShared(t *testing.T) {
+	pool, _ := chainTestPool(t, true)
+	oldIdle := config.Settings.SSHPoolIdleTimeoutSeconds
+	oldKeepalive := config.Settings.SSHPoolKeepaliveIntervalSeconds
+	t.Cleanup(func() {
+		config.Settings.SSHPoolIdleTimeoutSeconds = oldIdle
+		config.Settings.SSHPoolKeepaliveIntervalSeconds = oldKeepalive
+	})
+	config.Settings.SSHPoolIdleTimeoutSeconds = 10
+	config.Settings.SSHPoolKeepaliveIntervalSeconds = 0
+
+	if _, err := pool.GetConnection(context.Background(), chainHops("a", "b")); err != nil {
+		t.Fatalf("GetConnection: %v", err)
+	}
+	prefixKey := pool.getChainKey(chainHops("a"))
+	now := time.Now()
+	pool.mu.Lock()
+	pool.pool[prefixKey].lastUsedAt = now.Add(-time.Hour)
+	pool.mu.Unlock()
+
+	pool.housekeep(now)
+	if !pool.HasConnection(chainHops("a")) {
+		t.Fatal("a prefix with chains built on it must not be closed as idle")
+	}
+
+	// Once the chain built on it is gone the prefix idles out, counting from its release.
+	pool.mu.Lock()
+	pool.pool[pool.getChainKey(chainHops("a", "b"))].lastUsedAt = now.Add(-time.Hour)
+	pool.mu.Unlock()
+	pool.housekeep(now)
+	later := time.Now().Add(time.Minute)
+	pool.housekeep(later)
+	if got := pool.SSHPoolConnections(); got != 0 {
+		t.Fatalf("expected pool empty, got %d", got)
+	}
+}
+
+func TestSSHConnectionPool_RemovingPrefixRemovesDependents(t *testing.T) {
+	pool, _ := chainTestPool(t, true)
+
+	for _, last := range []string{"c", "d"} {
+		if _, err := pool.GetConnection(context.Background(), chainHops("a", "b", last)); err != nil {
+			t.Fatalf("GetConnection: %v", err)
+		}
+	}
+	if _, err := pool.GetConnection(context.Background(), chainHops("x")); err != nil {
+		t.Fatalf("GetConnection: %v", err)
+	}
+
+	pool.RemoveConnection(chainHops("a"))
+	if got := pool.SSHPoolConnections(); got != 1 || !pool.HasConnection(chainHops("x")) {
+		t.Fatalf("expected only the unrelated chain left, got %d", got)
+	}
+}
@@ -0,0 +1,91 @@
+package core
+
+import (
+	"bastion/config"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// SSHChannelLimitCode is the error code of dials refused because the jump hosts allow no more
+// channels on the chain's clients (see ChannelLimitError).
+const SSHChannelLimitCode = "SSH_CHANNEL_LIMIT"
+
+// ChannelLimitError reports that every pooled client of a chain, up to
+// SSH_POOL_MAX_CLIENTS_PER_CHAIN, refused to open another channel, typically because the jump host
+// caps channels per connection (sshd MaxSessions).
+type ChannelLimitError struct {
+	Chain   string
+	Clients int
+	Err     error // refusal of the last client
+}
+
+func (e *ChannelLimitError) Error() string {
+	return fmt.Sprintf("SSH channel limit reached on chain %s with %d client(s); raise MaxSessions on the jump host or SSH_POOL_MAX_CLIENTS_PER_CHAIN: %v",
+		e.Chain, e.Clients, e.Err)
+}
+
+func (e *ChannelLimitError) Unwrap() error { return e.Err }
+
+// isChannelRefusal reports whether err is a refused channel open that another connection may not
+// hit: "administratively prohibited" (also returned by sshd for MaxSessions) or "resource shortage".
+func isChannelRefusal(err error) bool {
+	var oce *ssh.OpenChannelError
+	return errors.As(err, &oce) && (oce.Reason == ssh.Prohibited || oce.Reason == ssh.ResourceShortage)
+}
+
+// chainSlotKey is the pool key of the slot-th client of a chain; the first one uses the chain key.
+func chainSlotKey(key string, slot int) string {
+	if slot <= 1 {
+		return key
+	}
+	return key + "#" + strconv.Itoa(slot)
+}
+
+// maxClientsPerChain is the number of pooled clients a chain may open, at least 1.
+func maxClientsPerChain() int {
+	if n := config.Settings.SSHPoolMaxClientsPerChain; n > 1 {
+		return n
+	}
+	return 1
+}
+
+// dialEntry opens a channel on one pooled client, counting it as active until it is closed.
+func (p *SSHConnectionPool) dialEntry(ctx context.Context, key string, entry *pooledSSHClient, network, addr string) (net.Conn, error) {
+	p.incActive(key, entry, time.Now())
+	conn, err := runPhase(ctx, "open channel to "+addr, 0, func() (net.Conn, error) {
+		return entry.client.Dial(network, addr)
+	}, nil, closeConn)
+	if err != nil {
+		p.decActive(key, entry, time.Now())
+		return nil, err
+	}
+	return &pooledConn{
+		Conn: conn,
+		release: func() {
+			p.decActive(key, entry, time.Now())
+		},
+	}, nil
+}
+
+// channelLimited reports whether a refused dial on entry looks like a channel limit rather than a
+// forwarding policy: the client already carries other channels.
+func (p *SSHConnectionPool) channelLimited(entry *pooledSSHClient, err error) bool {
+	if !isChannelRefusal(err) {
+		return false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return entry.activeConnCount > 0 || entry.dependents > 0
+}
+
+// SSHChannelLimitHitsTotal returns how often a pooled client refused a channel because of a channel limit.
+func (p *SSHConnectionPool) SSHChannelLimitHitsTotal() uint64 {
+	return atomic.LoadUint64(&p.channelLimitHits)
+}
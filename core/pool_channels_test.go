@@ -0,0 +1,89 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"bastion/config"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// channelCappedClient refuses channels like sshd over MaxSessions.
+type channelCappedClient struct {
+	fakeSSHClient
+	max  int
+	open int
+}
+
+func (c *channelCappedClient) Dial(network, addr string) (net.Conn, error) {
+	if c.open >= c.max {
+		return nil, &ssh.OpenChannelError{Reason: ssh.Prohibited, Message: "open failed"}
+	}
+	c.open++
+	return c.fakeSSHClient.Dial(network, addr)
+}
+
+func channelTestPool(t *testing.T, maxClients int) (*SSHConnectionPool, *[]*channelCappedClient) {
+	t.Helper()
+	pool, _ := chainTestPool(t, false)
+	old := config.Settings.SSHPoolMaxClientsPerChain
+	t.Cleanup(func() { config.Settings.SSHPoolMaxClientsPerChain = old })
+	config.Settings.SSHPoolMaxClientsPerChain = maxClients
+
+	var clients []*channelCappedClient
+	pool.dialHop = func(_ context.Context, prev sshClient, addr string, _ *ssh.ClientConfig) (sshClient, error) {
+		c := &channelCappedClient{max: 1}
+		clients = append(clients, c)
+		return c, nil
+	}
+	return pool, &clients
+}
+
+func TestSSHConnectionPool_Dial_OpensClientOnChannelLimit(t *testing.T) {
+	pool, clients := channelTestPool(t, 2)
+
+	for i := 0; i < 2; i++ {
+		if _, err := pool.Dial(context.Background(), chainHops("a"), "tcp", "db:5432"); err != nil {
+			t.Fatalf("Dial %d: %v", i, err)
+		}
+	}
+	if len(*clients) != 2 {
+		t.Fatalf("expected a second client for the chain, got %d", len(*clients))
+	}
+	if got := pool.SSHPoolConnections(); got != 2 {
+		t.Fatalf("expected 2 pooled clients, got %d", got)
+	}
+	if got := pool.SSHChannelLimitHitsTotal(); got != 1 {
+		t.Fatalf("expected 1 channel limit hit, got %d", got)
+	}
+
+	_, err := pool.Dial(context.Background(), chainHops("a"), "tcp", "db:5432")
+	var limitErr *ChannelLimitError
+	if !errors.As(err, &limitErr) {
+		t.Fatalf("expected ChannelLimitError, got %v", err)
+	}
+	if limitErr.Clients != 2 || len(*clients) != 2 {
+		t.Fatalf("expected the cap of 2 clients honored, got %d (%d built)", limitErr.Clients, len(*clients))
+	}
+}
+
+func TestSSHConnectionPool_Dial_RefusalOnIdleClientIsNotALimit(t *testing.T) {
+	pool, clients := channelTestPool(t, 2)
+	pool.dialHop = func(_ context.Context, prev sshClient, addr string, _ *ssh.ClientConfig) (sshClient, error) {
+		c := &channelCappedClient{max: 0}
+		*clients = append(*clients, c)
+		return c, nil
+	}
+
+	_, err := pool.Dial(context.Background(), chainHops("a"), "tcp", "db:5432")
+	var limitErr *ChannelLimitError
+	if err == nil || errors.As(err, &limitErr) {
+		t.Fatalf("expected the refusal returned as is, got %v", err)
+	}
+	if len(*clients) != 1 {
+		t.Fatalf("expected no additional client, got %d", len(*clients))
+	}
+}
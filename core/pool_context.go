@@ -0,0 +1,68 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+)
+
+// runPhase runs one phase of connecting a chain (opening a channel, an SSH handshake, ...) and waits
+// until it returns, timeout passes (timeout <= 0: no limit) or ctx is done. When it stops waiting it
+// calls abort (if any) to make fn return, and hands whatever fn still returns to discard, so nothing
+// opened late leaks.
+func runPhase[T any](ctx context.Context, what string, timeout time.Duration, fn func() (T, error), abort func(), discard func(T)) (T, error) {
+	type result struct {
+		v   T
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		v, err := fn()
+		done <- result{v: v, err: err}
+	}()
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	var zero T
+	var err error
+	select {
+	case r := <-done:
+		return r.v, r.err
+	case <-ctx.Done():
+		err = fmt.Errorf("%s: %w", what, ctx.Err())
+	case <-expired:
+		err = fmt.Errorf("%s timed out after %s: %w", what, timeout, context.DeadlineExceeded)
+	}
+	if abort != nil {
+		abort()
+	}
+	go func() {
+		if r := <-done; r.err == nil && discard != nil {
+			discard(r.v)
+		}
+	}()
+	return zero, err
+}
+
+func closeConn(c net.Conn) { _ = c.Close() }
+
+// boundContext returns ctx, canceled as well when CloseAll runs, so a dial or chain build never
+// outlives the pool. The returned func releases it.
+func (p *SSHConnectionPool) boundContext(ctx context.Context) (context.Context, func()) {
+	p.mu.Lock()
+	poolCtx := p.ctx
+	p.mu.Unlock()
+
+	ctx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(poolCtx, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+	}
+}
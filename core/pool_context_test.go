@@ -0,0 +1,86 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"bastion/models"
+)
+
+func TestRunPhase_TimeoutAbortsAndDiscardsLateResult(t *testing.T) {
+	release := make(chan struct{})
+	discarded := make(chan int, 1)
+	_, err := runPhase(context.Background(), "handshake", 20*time.Millisecond,
+		func() (int, error) {
+			<-release
+			return 42, nil
+		},
+		func() { close(release) },
+		func(v int) { discarded <- v },
+	)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline error, got %v", err)
+	}
+	select {
+	case v := <-discarded:
+		if v != 42 {
+			t.Fatalf("expected late result 42 discarded, got %d", v)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("late result was not discarded")
+	}
+}
+
+func TestRunPhase_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	block := make(chan struct{})
+	defer close(block)
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+	_, err := runPhase(ctx, "connect", 0, func() (int, error) {
+		<-block
+		return 0, nil
+	}, nil, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected canceled error, got %v", err)
+	}
+}
+
+func TestSSHConnectionPool_CloseAllAbortsChainBuild(t *testing.T) {
+	pool := NewSSHConnectionPool()
+	started := make(chan struct{})
+	pool.createChain = func(ctx context.Context, _ []models.Bastion) (sshClient, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		_, err := pool.GetConnection(context.Background(), []models.Bastion{{Name: "b1"}})
+		errc <- err
+	}()
+	<-started
+	pool.CloseAll()
+
+	select {
+	case err := <-errc:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected canceled error, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("GetConnection did not return after CloseAll")
+	}
+
+	// The pool stays usable after CloseAll.
+	pool.createChain = func(_ context.Context, _ []models.Bastion) (sshClient, error) {
+		return &fakeSSHClient{}, nil
+	}
+	if _, err := pool.GetConnection(context.Background(), []models.Bastion{{Name: "b1"}}); err != nil {
+		t.Fatalf("GetConnection after CloseAll: %v", err)
+	}
+}
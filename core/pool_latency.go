@@ -0,0 +1,77 @@
+package core
+
+import (
+	"bastion/models"
+	"sort"
+	"time"
+)
+
+// sshRTTHistorySize is how many keepalive round trips are kept per pooled chain.
+const sshRTTHistorySize = 30
+
+// rttHistory holds the latest keepalive round-trip times of a pooled chain.
+type rttHistory struct {
+	samples    []time.Duration // ring buffer, next is the oldest once full
+	next       int
+	measuredAt time.Time
+}
+
+func (h *rttHistory) add(rtt time.Duration, now time.Time) {
+	if len(h.samples) < sshRTTHistorySize {
+		h.samples = append(h.samples, rtt)
+	} else {
+		h.samples[h.next] = rtt
+		h.next = (h.next + 1) % sshRTTHistorySize
+	}
+	h.measuredAt = now
+}
+
+func (h *rttHistory) summary() models.SSHLatency {
+	s := models.SSHLatency{HistoryMS: make([]float64, 0, len(h.samples))}
+	if len(h.samples) == 0 {
+		return s
+	}
+	var total time.Duration
+	for i := range h.samples {
+		rtt := h.samples[(h.next+i)%len(h.samples)]
+		total += rtt
+		ms := durationMS(rtt)
+		s.HistoryMS = append(s.HistoryMS, ms)
+		if ms > s.MaxMS {
+			s.MaxMS = ms
+		}
+	}
+	s.Samples = len(h.samples)
+	s.CurrentMS = s.HistoryMS[len(s.HistoryMS)-1]
+	s.AvgMS = durationMS(total / time.Duration(len(h.samples)))
+	measuredAt := h.measuredAt
+	s.MeasuredAt = &measuredAt
+	return s
+}
+
+func durationMS(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// Chains lists the pooled SSH chains by key, with their keepalive latency.
+func (p *SSHConnectionPool) Chains() []models.SSHPoolChain {
+	p.mu.Lock()
+	chains := make([]models.SSHPoolChain, 0, len(p.pool))
+	for key, entry := range p.pool {
+		if entry == nil {
+			continue
+		}
+		chains = append(chains, models.SSHPoolChain{
+			Key:         key,
+			CreatedAt:   entry.createdAt,
+			LastUsedAt:  entry.lastUsedAt,
+			ActiveConns: entry.activeConnCount,
+			Dependents:  entry.dependents,
+			Latency:     entry.rtt.summary(),
+		})
+	}
+	p.mu.Unlock()
+
+	sort.Slice(chains, func(i, j int) bool { return chains[i].Key < chains[j].Key })
+	return chains
+}
@@ -0,0 +1,61 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"bastion/config"
+	"bastion/models"
+)
+
+func TestRTTHistory_Summary(t *testing.T) {
+	var h rttHistory
+	if s := h.summary(); s.Samples != 0 || s.MeasuredAt != nil {
+		t.Fatalf("expected empty summary, got %+v", s)
+	}
+
+	now := time.Now()
+	for i := 1; i <= sshRTTHistorySize+2; i++ {
+		h.add(time.Duration(i)*time.Millisecond, now)
+	}
+	s := h.summary()
+	if s.Samples != sshRTTHistorySize || len(s.HistoryMS) != sshRTTHistorySize {
+		t.Fatalf("expected %d samples, got %d", sshRTTHistorySize, s.Samples)
+	}
+	// The two oldest samples (1ms, 2ms) were dropped.
+	if s.HistoryMS[0] != 3 || s.CurrentMS != float64(sshRTTHistorySize+2) || s.MaxMS != s.CurrentMS {
+		t.Fatalf("unexpected history: %+v", s)
+	}
+	if want := float64(3+sshRTTHistorySize+2) / 2; s.AvgMS != want {
+		t.Fatalf("expected avg %v, got %v", want, s.AvgMS)
+	}
+}
+
+func TestSSHConnectionPool_Housekeep_RecordsKeepaliveRTT(t *testing.T) {
+	oldIdle := config.Settings.SSHPoolIdleTimeoutSeconds
+	oldKeepalive := config.Settings.SSHPoolKeepaliveIntervalSeconds
+	t.Cleanup(func() {
+		config.Settings.SSHPoolIdleTimeoutSeconds = oldIdle
+		config.Settings.SSHPoolKeepaliveIntervalSeconds = oldKeepalive
+	})
+	config.Settings.SSHPoolIdleTimeoutSeconds = 0
+	config.Settings.SSHPoolKeepaliveIntervalSeconds = 1
+
+	pool := NewSSHConnectionPool()
+	pool.createChain = func(_ context.Context, _ []models.Bastion) (sshClient, error) {
+		return &fakeSSHClient{}, nil
+	}
+	if _, err := pool.GetConnection(context.Background(), []models.Bastion{{Name: "b1"}}); err != nil {
+		t.Fatalf("GetConnection: %v", err)
+	}
+
+	pool.housekeep(time.Now().Add(time.Minute))
+	chains := pool.Chains()
+	if len(chains) != 1 || chains[0].Key != "b1" {
+		t.Fatalf("unexpected chains: %+v", chains)
+	}
+	if chains[0].Latency.Samples != 1 || chains[0].Latency.MeasuredAt == nil {
+		t.Fatalf("expected one RTT sample, got %+v", chains[0].Latency)
+	}
+}
@@ -1,6 +1,7 @@
 package core
 
 import (
+	"context"
 	"errors"
 	"net"
 	"testing"
@@ -45,12 +46,12 @@ func TestSSHConnectionPool_Housekeep_IdleClose(t *testing.T) {
 	config.Settings.SSHPoolKeepaliveIntervalSeconds = 0
 
 	pool := NewSSHConnectionPool()
-	pool.createChain = func(_ []models.Bastion) (sshClient, error) {
+	pool.createChain = func(_ context.Context, _ []models.Bastion) (sshClient, error) {
 		return &fakeSSHClient{}, nil
 	}
 
 	now := time.Now()
-	_, err := pool.GetConnection([]models.Bastion{{Name: "b1"}})
+	_, err := pool.GetConnection(context.Background(), []models.Bastion{{Name: "b1"}})
 	if err != nil {
 		t.Fatalf("GetConnection: %v", err)
 	}
@@ -81,12 +82,12 @@ func TestSSHConnectionPool_Housekeep_DoesNotCloseActive(t *testing.T) {
 	config.Settings.SSHPoolKeepaliveIntervalSeconds = 0
 
 	pool := NewSSHConnectionPool()
-	pool.createChain = func(_ []models.Bastion) (sshClient, error) {
+	pool.createChain = func(_ context.Context, _ []models.Bastion) (sshClient, error) {
 		return &fakeSSHClient{}, nil
 	}
 
 	now := time.Now()
-	_, err := pool.GetConnection([]models.Bastion{{Name: "b1"}})
+	_, err := pool.GetConnection(context.Background(), []models.Bastion{{Name: "b1"}})
 	if err != nil {
 		t.Fatalf("GetConnection: %v", err)
 	}
@@ -118,12 +119,12 @@ func TestSSHConnectionPool_Housekeep_KeepaliveFailureRemovesIdle(t *testing.T) {
 	config.Settings.SSHPoolKeepaliveTimeoutMS = 0
 
 	pool := NewSSHConnectionPool()
-	pool.createChain = func(_ []models.Bastion) (sshClient, error) {
+	pool.createChain = func(_ context.Context, _ []models.Bastion) (sshClient, error) {
 		return &fakeSSHClient{sendErr: errors.New("keepalive failed")}, nil
 	}
 
 	now := time.Now()
-	_, err := pool.GetConnection([]models.Bastion{{Name: "b1"}})
+	_, err := pool.GetConnection(context.Background(), []models.Bastion{{Name: "b1"}})
 	if err != nil {
 		t.Fatalf("GetConnection: %v", err)
 	}
@@ -154,11 +155,11 @@ func TestSSHConnectionPool_Capacity_EvictsIdleOrErrorsWhenAllActive(t *testing.T
 	config.Settings.SSHPoolKeepaliveIntervalSeconds = 0
 
 	pool := NewSSHConnectionPool()
-	pool.createChain = func(_ []models.Bastion) (sshClient, error) {
+	pool.createChain = func(_ context.Context, _ []models.Bastion) (sshClient, error) {
 		return &fakeSSHClient{}, nil
 	}
 
-	_, err := pool.GetConnection([]models.Bastion{{Name: "a"}})
+	_, err := pool.GetConnection(context.Background(), []models.Bastion{{Name: "a"}})
 	if err != nil {
 		t.Fatalf("GetConnection a: %v", err)
 	}
@@ -167,7 +168,7 @@ func TestSSHConnectionPool_Capacity_EvictsIdleOrErrorsWhenAllActive(t *testing.T
 	pool.pool["a"].activeConnCount = 1
 	pool.mu.Unlock()
 
-	_, err = pool.GetConnection([]models.Bastion{{Name: "b"}})
+	_, err = pool.GetConnection(context.Background(), []models.Bastion{{Name: "b"}})
 	if err == nil {
 		t.Fatalf("expected capacity error when all conns are active")
 	}
@@ -177,7 +178,7 @@ func TestSSHConnectionPool_Capacity_EvictsIdleOrErrorsWhenAllActive(t *testing.T
 	pool.pool["a"].lastUsedAt = time.Now().Add(-time.Hour)
 	pool.mu.Unlock()
 
-	_, err = pool.GetConnection([]models.Bastion{{Name: "b"}})
+	_, err = pool.GetConnection(context.Background(), []models.Bastion{{Name: "b"}})
 	if err != nil {
 		t.Fatalf("expected eviction to allow new conn: %v", err)
 	}
@@ -185,3 +186,27 @@ func TestSSHConnectionPool_Capacity_EvictsIdleOrErrorsWhenAllActive(t *testing.T
 		t.Fatalf("expected pool size 1, got %d", got)
 	}
 }
+
+func TestSSHConnectionPool_RemoveConnectionsWithBastion(t *testing.T) {
+	pool := NewSSHConnectionPool()
+	clients := map[string]*fakeSSHClient{}
+	for _, key := range []string{"b1", "b1->b2", "b3", "b3->b1#2", "b10"} {
+		clients[key] = &fakeSSHClient{}
+		pool.pool[key] = &pooledSSHClient{client: clients[key]}
+	}
+
+	removed := pool.RemoveConnectionsWithBastion("b1")
+	if len(removed) != 3 {
+		t.Fatalf("expected 3 removed chains, got %v", removed)
+	}
+	for _, key := range []string{"b1", "b1->b2", "b3->b1#2"} {
+		if _, ok := pool.pool[key]; ok || !clients[key].closed {
+			t.Fatalf("expected %s to be removed and closed", key)
+		}
+	}
+	for _, key := range []string{"b3", "b10"} {
+		if _, ok := pool.pool[key]; !ok || clients[key].closed {
+			t.Fatalf("expected %s to be kept", key)
+		}
+	}
+}
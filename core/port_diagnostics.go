@@ -1,14 +1,24 @@
 package core
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"net"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 )
 
+// Reasons a local port could not be bound (PortInUseDetail.Reason)
+const (
+	PortReasonInUse         = "in_use"
+	PortReasonExcludedRange = "excluded_port_range" // Windows: reserved by Hyper-V, WinNAT, ...
+	PortReasonAccessDenied  = "access_denied"
+)
+
 type PortAttempt struct {
 	Network string `json:"network"`
 	Host    string `json:"host"`
@@ -40,6 +50,14 @@ type PortConflict struct {
 	Running   bool   `json:"running"`
 }
 
+// PortRange is a Windows excluded port range (netsh interface ipv4 show excludedportrange).
+type PortRange struct {
+	Protocol     string `json:"protocol"` // ipv4 or ipv6
+	Start        int    `json:"start"`
+	End          int    `json:"end"`
+	Administered bool   `json:"administered,omitempty"` // added by an administrator rather than a service
+}
+
 type DiagnosticsMeta struct {
 	Source string `json:"source"`
 	Error  string `json:"error,omitempty"`
@@ -47,10 +65,13 @@ type DiagnosticsMeta struct {
 
 type PortInUseDetail struct {
 	Attempt           PortAttempt     `json:"attempt"`
+	Reason            string          `json:"reason"`
 	ListenError       string          `json:"listen_error"`
 	Listeners         []PortListener  `json:"listeners,omitempty"`
 	Owners            []ProcessOwner  `json:"owners,omitempty"`
 	InternalConflicts []PortConflict  `json:"internal_conflicts,omitempty"`
+	ExcludedRanges    []PortRange     `json:"excluded_ranges,omitempty"`
+	Hints             []string        `json:"hints,omitempty"`
 	Diag              DiagnosticsMeta `json:"diag"`
 }
 
@@ -98,12 +119,80 @@ func DiagnosePortInUse(network, host string, port int) PortInUseDetail {
 
 	return PortInUseDetail{
 		Attempt:   attempt,
+		Reason:    PortReasonInUse,
 		Listeners: listeners,
 		Owners:    owners,
 		Diag:      meta,
 	}
 }
 
+// DiagnoseForbiddenBind explains a bind that Windows refused with an access error rather than "in
+// use": the port may lie in an excluded port range, another process may hold it exclusively, or the
+// firewall or other security software may block the bind.
+func DiagnoseForbiddenBind(network, host string, port int) PortInUseDetail {
+	detail := DiagnosePortInUse(network, host, port)
+	ranges, err := queryExcludedPortRanges()
+	if err != nil && detail.Diag.Error == "" {
+		detail.Diag.Error = "failed to query excluded port ranges: " + err.Error()
+	}
+	explainForbiddenBind(&detail, ranges)
+	return detail
+}
+
+// explainForbiddenBind sets the reason and hints of a forbidden bind from the excluded port ranges.
+func explainForbiddenBind(detail *PortInUseDetail, ranges []PortRange) {
+	port := detail.Attempt.Port
+	for _, r := range ranges {
+		if port >= r.Start && port <= r.End {
+			detail.ExcludedRanges = append(detail.ExcludedRanges, r)
+		}
+	}
+
+	if len(detail.ExcludedRanges) > 0 {
+		detail.Reason = PortReasonExcludedRange
+		r := detail.ExcludedRanges[0]
+		if r.Administered {
+			detail.Hints = append(detail.Hints, fmt.Sprintf("Port %d is in the excluded port range %d-%d added by an administrator; remove it with netsh int %s delete excludedportrange or choose another local_port.", port, r.Start, r.End, r.Protocol))
+		} else {
+			detail.Hints = append(detail.Hints, fmt.Sprintf("Port %d is in the excluded port range %d-%d reserved by Windows (Hyper-V, WSL or WinNAT); choose another local_port, or restart the winnat service to release the range.", port, r.Start, r.End))
+		}
+		return
+	}
+
+	detail.Reason = PortReasonAccessDenied
+	if len(detail.Owners) > 0 {
+		detail.Hints = append(detail.Hints, fmt.Sprintf("Port %d is held exclusively by another process; stop it or choose another local_port.", port))
+	}
+	detail.Hints = append(detail.Hints, "Windows Defender Firewall or other security software may block binding this port; check its block rules for bastion, or choose another local_port.")
+}
+
+// parseExcludedPortRanges parses the output of netsh interface <protocol> show excludedportrange:
+// "start end" rows, administered ones marked with a trailing "*".
+func parseExcludedPortRanges(protocol string, out []byte) []PortRange {
+	var ranges []PortRange
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) < 2 || len(fields) > 3 {
+			continue
+		}
+		start, err1 := strconv.Atoi(fields[0])
+		end, err2 := strconv.Atoi(fields[1])
+		if err1 != nil || err2 != nil || start > end {
+			continue
+		}
+		r := PortRange{Protocol: protocol, Start: start, End: end}
+		if len(fields) == 3 {
+			if fields[2] != "*" {
+				continue
+			}
+			r.Administered = true
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges
+}
+
 func dedupeOwners(listeners []PortListener) []ProcessOwner {
 	seen := map[int]ProcessOwner{}
 	for _, l := range listeners {
@@ -0,0 +1,55 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+const netshExcludedRanges = `
+Protocol tcp Port Exclusion Ranges
+
+Start Port    End Port
+----------    --------
+      5357        5357
+     50000       50059     *
+     50060       50159
+
+* - Administered port exclusions.
+`
+
+func TestParseExcludedPortRanges(t *testing.T) {
+	got := parseExcludedPortRanges("ipv4", []byte(netshExcludedRanges))
+	want := []PortRange{
+		{Protocol: "ipv4", Start: 5357, End: 5357},
+		{Protocol: "ipv4", Start: 50000, End: 50059, Administered: true},
+		{Protocol: "ipv4", Start: 50060, End: 50159},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("ranges = %+v, want %+v", got, want)
+	}
+}
+
+func TestExplainForbiddenBind(t *testing.T) {
+	ranges := parseExcludedPortRanges("ipv4", []byte(netshExcludedRanges))
+
+	detail := PortInUseDetail{Attempt: PortAttempt{Port: 50100}}
+	explainForbiddenBind(&detail, ranges)
+	if detail.Reason != PortReasonExcludedRange {
+		t.Fatalf("reason = %q, want %q", detail.Reason, PortReasonExcludedRange)
+	}
+	if len(detail.ExcludedRanges) != 1 || detail.ExcludedRanges[0].Start != 50060 {
+		t.Fatalf("excluded ranges = %+v", detail.ExcludedRanges)
+	}
+	if len(detail.Hints) != 1 {
+		t.Fatalf("expected one hint, got %v", detail.Hints)
+	}
+
+	detail = PortInUseDetail{Attempt: PortAttempt{Port: 8080}, Owners: []ProcessOwner{{PID: 42}}}
+	explainForbiddenBind(&detail, ranges)
+	if detail.Reason != PortReasonAccessDenied {
+		t.Fatalf("reason = %q, want %q", detail.Reason, PortReasonAccessDenied)
+	}
+	if len(detail.ExcludedRanges) != 0 || len(detail.Hints) != 2 {
+		t.Fatalf("expected an exclusive-use and a firewall hint, got %+v", detail)
+	}
+}
@@ -37,6 +37,11 @@ func queryPortListeners(network string, port int) ([]PortListener, DiagnosticsMe
 	return listeners, meta
 }
 
+// queryExcludedPortRanges is Windows-only: other systems have no excluded port ranges.
+func queryExcludedPortRanges() ([]PortRange, error) {
+	return nil, nil
+}
+
 func queryPortListenersSS(port int) ([]PortListener, DiagnosticsMeta) {
 	path, err := exec.LookPath("ss")
 	if err != nil {
@@ -3,12 +3,16 @@
 package core
 
 import (
+	"context"
 	"encoding/binary"
 	"errors"
 	"net"
+	"os/exec"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"syscall"
+	"time"
 	"unsafe"
 
 	"golang.org/x/sys/windows"
@@ -202,3 +206,23 @@ func queryPortListeners(network string, port int) ([]PortListener, DiagnosticsMe
 
 	return listeners, DiagnosticsMeta{Source: "winapi"}
 }
+
+// queryExcludedPortRanges lists the TCP port ranges Windows excludes from binding, for IPv4 and IPv6.
+func queryExcludedPortRanges() ([]PortRange, error) {
+	path, err := exec.LookPath("netsh")
+	if err != nil {
+		return nil, errors.New("netsh not found")
+	}
+
+	var ranges []PortRange
+	for _, protocol := range []string{"ipv4", "ipv6"} {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		out, runErr := exec.CommandContext(ctx, path, "interface", protocol, "show", "excludedportrange", "protocol=tcp").CombinedOutput()
+		cancel()
+		if runErr != nil {
+			return ranges, errors.New(strings.TrimSpace(string(out)))
+		}
+		ranges = append(ranges, parseExcludedPortRanges(protocol, out)...)
+	}
+	return ranges, nil
+}
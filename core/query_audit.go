@@ -0,0 +1,422 @@
+package core
+
+import (
+	"bastion/config"
+	"bastion/models"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ValidateQueryAudit checks the query_audit protocol of a mapping: only tcp and k8s mappings
+// have a fixed database server to audit.
+func ValidateQueryAudit(mappingType, protocol string) error {
+	switch protocol {
+	case "":
+		return nil
+	case models.QueryAuditMySQL, models.QueryAuditPostgres:
+	default:
+		return fmt.Errorf("invalid query_audit %q: use %s or %s", protocol, models.QueryAuditMySQL, models.QueryAuditPostgres)
+	}
+	if mappingType != "tcp" && mappingType != "k8s" {
+		return fmt.Errorf("query_audit only applies to tcp and k8s mappings")
+	}
+	return nil
+}
+
+// MySQL capability flags the audit needs
+const (
+	mysqlClientConnectWithDB        = 0x00000008
+	mysqlClientCompress             = 0x00000020
+	mysqlClientProtocol41           = 0x00000200
+	mysqlClientSSL                  = 0x00000800
+	mysqlClientSecureConnection     = 0x00008000
+	mysqlClientPluginAuthLenencData = 0x00200000
+	mysqlClientZstdCompression      = 0x04000000
+	mysqlClientQueryAttributes      = 0x08000000
+)
+
+// MySQL commands the audit records
+const (
+	mysqlComQuit        = 0x01
+	mysqlComInitDB      = 0x02
+	mysqlComQuery       = 0x03
+	mysqlComChangeUser  = 0x11
+	mysqlComStmtPrepare = 0x16
+)
+
+// PostgreSQL startup request codes
+const (
+	pgProtocol3     = 196608
+	pgSSLRequest    = 80877103
+	pgGSSENCRequest = 80877104
+)
+
+// queryAuditor parses what a client sends to a MySQL or PostgreSQL server and records its login
+// and statements in QueryLogs. It only sees the client side: it stops at TLS, compression or
+// anything it cannot frame, and the connection is forwarded unchanged either way.
+type queryAuditor struct {
+	entry    models.QueryLog // the fields every entry of the connection shares
+	store    *QueryLogStore
+	maxBytes int
+	redact   string
+
+	// Framing: the header of the message being read, then its payload up to capture bytes; the
+	// rest of the payload is skipped.
+	header    []byte
+	payload   []byte
+	remaining int // payload bytes still to come
+	size      int // payload size
+	done      bool
+
+	startup      bool   // PostgreSQL: the next message is a startup packet without type byte
+	sslRequested bool   // PostgreSQL: the client asked for TLS or GSS encryption
+	handshake    bool   // MySQL: the next packet is the handshake response
+	capabilities uint32 // MySQL: capabilities of the handshake response
+}
+
+func newQueryAuditor(mapping *models.Mapping, connID, clientAddr string, store *QueryLogStore) *queryAuditor {
+	a := &queryAuditor{
+		entry: models.QueryLog{
+			Workspace:  mapping.Workspace,
+			MappingID:  mapping.ID,
+			Protocol:   mapping.QueryAudit,
+			ConnID:     connID,
+			ClientAddr: clientAddr,
+		},
+		store:    store,
+		maxBytes: config.Settings.QueryAuditMaxBytes,
+		redact:   config.Settings.QueryAuditRedact,
+	}
+	if a.maxBytes <= 0 {
+		a.maxBytes = 4096
+	}
+	if mapping.QueryAudit == models.QueryAuditMySQL {
+		a.handshake = true
+	} else {
+		a.startup = true
+	}
+	return a
+}
+
+// headerSize is the size of the header of the next message.
+func (a *queryAuditor) headerSize() int {
+	switch {
+	case a.entry.Protocol == models.QueryAuditMySQL:
+		return 4 // 3-byte little-endian length, sequence number
+	case a.startup:
+		return 4 // big-endian length including itself
+	default:
+		return 5 // type byte, big-endian length including itself
+	}
+}
+
+// Feed parses the next bytes the client sent.
+func (a *queryAuditor) Feed(data []byte) {
+	for len(data) > 0 && !a.done {
+		if a.remaining == 0 && len(a.header) == 0 && a.sslRequested && data[0] == 0x16 {
+			// A TLS handshake record after the SSLRequest was accepted
+			a.record(models.QueryLogTLS, "", 0)
+			a.done = true
+			return
+		}
+
+		if need := a.headerSize() - len(a.header); need > 0 {
+			n := min(need, len(data))
+			a.header = append(a.header, data[:n]...)
+			data = data[n:]
+			if len(a.header) < a.headerSize() {
+				return
+			}
+			if !a.startPayload() {
+				a.done = true
+				return
+			}
+		}
+
+		n := min(a.remaining, len(data))
+		if room := a.maxBytes + 1024 - len(a.payload); room > 0 {
+			a.payload = append(a.payload, data[:min(n, room)]...)
+		}
+		a.remaining -= n
+		data = data[n:]
+		if a.remaining == 0 {
+			a.handleMessage()
+			a.header, a.payload = a.header[:0], a.payload[:0]
+		}
+	}
+}
+
+// startPayload reads the payload size from the header; false means the stream cannot be framed.
+func (a *queryAuditor) startPayload() bool {
+	switch {
+	case a.entry.Protocol == models.QueryAuditMySQL:
+		a.size = int(a.header[0]) | int(a.header[1])<<8 | int(a.header[2])<<16
+	case a.startup:
+		a.size = int(binary.BigEndian.Uint32(a.header)) - 4
+		if a.size < 4 || a.size > 10000 {
+			return false
+		}
+	default:
+		a.size = int(binary.BigEndian.Uint32(a.header[1:])) - 4
+		if a.size < 0 {
+			return false
+		}
+	}
+	a.remaining = a.size
+	return true
+}
+
+func (a *queryAuditor) handleMessage() {
+	if a.entry.Protocol == models.QueryAuditMySQL {
+		a.handleMySQL(a.header[3], a.payload)
+		return
+	}
+	if a.startup {
+		a.handlePostgresStartup(a.payload)
+		return
+	}
+	a.handlePostgres(a.header[0], a.payload)
+}
+
+func (a *queryAuditor) handlePostgresStartup(p []byte) {
+	if len(p) < 4 {
+		a.done = true
+		return
+	}
+	switch binary.BigEndian.Uint32(p) {
+	case pgSSLRequest, pgGSSENCRequest:
+		a.sslRequested = true
+	case pgProtocol3:
+		a.startup, a.sslRequested = false, false
+		params := p[4:]
+		for len(params) > 0 {
+			key, rest := cString(params)
+			if key == "" {
+				break
+			}
+			value, rest2 := cString(rest)
+			switch key {
+			case "user":
+				a.entry.User = value
+			case "database":
+				a.entry.Database = value
+			}
+			params = rest2
+		}
+		if a.entry.Database == "" {
+			a.entry.Database = a.entry.User
+		}
+		a.record(models.QueryLogConnect, "", 0)
+	default:
+		// A cancel request (80877102) or a protocol this audit does not know
+		a.done = true
+	}
+}
+
+func (a *queryAuditor) handlePostgres(msgType byte, p []byte) {
+	switch msgType {
+	case 'Q':
+		query, _ := cString(p)
+		a.record(models.QueryLogQuery, query, a.size-1)
+	case 'P':
+		name, rest := cString(p)
+		query, after := cString(rest)
+		size := len(query)
+		if after == nil {
+			// Cut before its end: the statement is at most the rest of the message.
+			size = a.size - len(name) - 1
+		}
+		a.record(models.QueryLogPrepare, query, size)
+	case 'X':
+		a.done = true
+	}
+}
+
+func (a *queryAuditor) handleMySQL(seq byte, p []byte) {
+	if a.handshake {
+		a.handleMySQLHandshake(p)
+		return
+	}
+	// Commands start a new sequence; other packets continue authentication or a large command.
+	if seq != 0 || len(p) == 0 {
+		return
+	}
+	switch p[0] {
+	case mysqlComQuery:
+		body := p[1:]
+		if a.capabilities&mysqlClientQueryAttributes != 0 {
+			count, rest, ok := lenencInt(body)
+			if !ok {
+				return
+			}
+			if _, rest, ok = lenencInt(rest); !ok {
+				return
+			}
+			if count > 0 {
+				// The text follows the bound attributes, which are not decoded.
+				a.record(models.QueryLogQuery, "", a.size-1)
+				return
+			}
+			body = rest
+		}
+		a.record(models.QueryLogQuery, string(body), a.size-1-(len(p)-1-len(body)))
+	case mysqlComStmtPrepare:
+		a.record(models.QueryLogPrepare, string(p[1:]), a.size-1)
+	case mysqlComInitDB:
+		a.entry.Database = string(p[1:])
+		a.record(models.QueryLogUse, "", 0)
+	case mysqlComChangeUser:
+		user, rest := cString(p[1:])
+		a.entry.User = user
+		if a.capabilities&mysqlClientSecureConnection != 0 && len(rest) > 0 {
+			if n := int(rest[0]); n+1 <= len(rest) {
+				rest = rest[1+n:]
+			}
+		} else {
+			_, rest = cString(rest)
+		}
+		a.entry.Database, _ = cString(rest)
+		a.record(models.QueryLogConnect, "", 0)
+	case mysqlComQuit:
+		a.done = true
+	}
+}
+
+// handleMySQLHandshake reads the user and database of a HandshakeResponse41.
+func (a *queryAuditor) handleMySQLHandshake(p []byte) {
+	a.handshake = false
+	if len(p) < 32 {
+		a.done = true
+		return
+	}
+	caps := binary.LittleEndian.Uint32(p)
+	a.capabilities = caps
+	if caps&mysqlClientProtocol41 == 0 {
+		a.done = true
+		return
+	}
+	if caps&mysqlClientSSL != 0 && a.size == 32 {
+		a.record(models.QueryLogTLS, "", 0)
+		a.done = true
+		return
+	}
+
+	user, rest := cString(p[32:])
+	a.entry.User = user
+	switch {
+	case caps&mysqlClientPluginAuthLenencData != 0:
+		n, after, ok := lenencInt(rest)
+		if ok && int(n) <= len(after) {
+			rest = after[n:]
+		} else {
+			rest = nil
+		}
+	case caps&mysqlClientSecureConnection != 0:
+		if len(rest) > 0 && int(rest[0])+1 <= len(rest) {
+			rest = rest[1+int(rest[0]):]
+		} else {
+			rest = nil
+		}
+	default:
+		_, rest = cString(rest)
+	}
+	if caps&mysqlClientConnectWithDB != 0 {
+		a.entry.Database, _ = cString(rest)
+	}
+	a.record(models.QueryLogConnect, "", 0)
+	if caps&(mysqlClientCompress|mysqlClientZstdCompression) != 0 {
+		// Compressed packets are not decoded.
+		a.done = true
+	}
+}
+
+// record stores an entry of the connection; text is cut at maxBytes and redacted, size is its
+// size as sent.
+func (a *queryAuditor) record(kind, text string, size int) {
+	entry := a.entry
+	entry.Timestamp = time.Now()
+	entry.Kind = kind
+	if size < len(text) {
+		size = len(text)
+	}
+	entry.Bytes = size
+	if len(text) > a.maxBytes {
+		cut := a.maxBytes
+		for cut > 0 && !utf8.RuneStart(text[cut]) {
+			cut--
+		}
+		text = text[:cut]
+	}
+	if text != "" {
+		entry.Truncated = len(text) < size
+		entry.Query = RedactQuery(strings.ToValidUTF8(text, "\uFFFD"), a.redact, a.entry.Protocol)
+	}
+	a.store.Record(&entry)
+}
+
+// cString splits a NUL-terminated string off p; without NUL the whole of p is the string.
+func cString(p []byte) (string, []byte) {
+	if i := bytes.IndexByte(p, 0); i >= 0 {
+		return string(p[:i]), p[i+1:]
+	}
+	return string(p), nil
+}
+
+// lenencInt reads a MySQL length-encoded integer.
+func lenencInt(p []byte) (uint64, []byte, bool) {
+	if len(p) == 0 {
+		return 0, nil, false
+	}
+	switch p[0] {
+	case 0xfc:
+		if len(p) < 3 {
+			return 0, nil, false
+		}
+		return uint64(binary.LittleEndian.Uint16(p[1:])), p[3:], true
+	case 0xfd:
+		if len(p) < 4 {
+			return 0, nil, false
+		}
+		return uint64(p[1]) | uint64(p[2])<<8 | uint64(p[3])<<16, p[4:], true
+	case 0xfe:
+		if len(p) < 9 {
+			return 0, nil, false
+		}
+		return binary.LittleEndian.Uint64(p[1:]), p[9:], true
+	case 0xfb, 0xff:
+		return 0, nil, false
+	default:
+		return uint64(p[0]), p[1:], true
+	}
+}
+
+// queryAuditConn feeds what the client sends to a queryAuditor on its way to the database.
+type queryAuditConn struct {
+	net.Conn
+	auditor *queryAuditor
+}
+
+// newQueryAuditConn wraps the client connection of a mapping with query_audit.
+func newQueryAuditConn(conn net.Conn, mapping *models.Mapping, connID, clientAddr string) net.Conn {
+	return &queryAuditConn{Conn: conn, auditor: newQueryAuditor(mapping, connID, clientAddr, QueryLogs)}
+}
+
+func (c *queryAuditConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if n > 0 && !c.auditor.done {
+		c.auditor.Feed(p[:n])
+	}
+	return n, err
+}
+
+func (c *queryAuditConn) CloseWrite() error {
+	if cw, ok := c.Conn.(interface{ CloseWrite() error }); ok {
+		return cw.CloseWrite()
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+package core
+
+import (
+	"bastion/config"
+	"bastion/models"
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+func pgStartup(params ...string) []byte {
+	body := binary.BigEndian.AppendUint32(nil, pgProtocol3)
+	for _, p := range params {
+		body = append(append(body, p...), 0)
+	}
+	body = append(body, 0)
+	return append(binary.BigEndian.AppendUint32(nil, uint32(len(body)+4)), body...)
+}
+
+func pgMessage(msgType byte, parts ...string) []byte {
+	var body []byte
+	for _, p := range parts {
+		body = append(append(body, p...), 0)
+	}
+	msg := binary.BigEndian.AppendUint32([]byte{msgType}, uint32(len(body)+4))
+	return append(msg, body...)
+}
+
+func mysqlPacket(seq byte, payload []byte) []byte {
+	n := len(payload)
+	return append([]byte{byte(n), byte(n >> 8), byte(n >> 16), seq}, payload...)
+}
+
+func newTestQueryAuditor(t *testing.T, protocol string) (*queryAuditor, *QueryLogStore) {
+	t.Helper()
+	prevMax, prevRedact := config.Settings.QueryAuditMaxBytes, config.Settings.QueryAuditRedact
+	config.Settings.QueryAuditMaxBytes, config.Settings.QueryAuditRedact = 32, QueryRedactSecrets
+	t.Cleanup(func() {
+		config.Settings.QueryAuditMaxBytes, config.Settings.QueryAuditRedact = prevMax, prevRedact
+	})
+	store := NewQueryLogStore(100)
+	mapping := &models.Mapping{ID: "db", Workspace: "prod", QueryAudit: protocol}
+	return newQueryAuditor(mapping, "c1", "10.0.0.9:50000", store), store
+}
+
+func allQueryLogs(store *QueryLogStore) []*models.QueryLog {
+	entries, _ := store.Query(models.QueryLogFilter{}, 1, 100)
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	return entries
+}
+
+func TestQueryAuditorPostgres(t *testing.T) {
+	a, store := newTestQueryAuditor(t, models.QueryAuditPostgres)
+
+	var stream []byte
+	stream = append(stream, pgStartup("user", "alice", "database", "shop")...)
+	stream = append(stream, pgMessage('p', "password")...)
+	stream = append(stream, pgMessage('Q', "ALTER USER bob PASSWORD 'hunter2'")...)
+	stream = append(stream, pgMessage('P', "s1", "SELECT * FROM orders WHERE id = $1 AND note <> 'this is a long note'")...)
+	stream = append(stream, pgMessage('X')...)
+	stream = append(stream, pgMessage('Q', "SELECT 'after terminate'")...)
+	// Byte by byte, so every message is split across reads.
+	for i := range stream {
+		a.Feed(stream[i : i+1])
+	}
+
+	entries := allQueryLogs(store)
+	if len(entries) != 3 {
+		t.Fatalf("got %d entries, want 3: %+v", len(entries), entries)
+	}
+	if e := entries[0]; e.Kind != models.QueryLogConnect || e.User != "alice" || e.Database != "shop" || e.MappingID != "db" || e.Workspace != "prod" {
+		t.Fatalf("connect = %+v", e)
+	}
+	if e := entries[1]; e.Kind != models.QueryLogQuery || e.Query != "ALTER USER bob PASSWORD '***'" || e.User != "alice" {
+		t.Fatalf("query = %+v", e)
+	}
+	e := entries[2]
+	if e.Kind != models.QueryLogPrepare || !e.Truncated || e.Bytes != 68 || e.Query != "SELECT * FROM orders WHERE id = " {
+		t.Fatalf("prepare = %+v", e)
+	}
+}
+
+func TestQueryAuditorPostgresTLS(t *testing.T) {
+	a, store := newTestQueryAuditor(t, models.QueryAuditPostgres)
+	ssl := binary.BigEndian.AppendUint32(binary.BigEndian.AppendUint32(nil, 8), pgSSLRequest)
+	a.Feed(ssl)
+	a.Feed([]byte{0x16, 0x03, 0x01, 0x02, 0x00})
+	a.Feed(pgMessage('Q', "SELECT 1"))
+
+	entries := allQueryLogs(store)
+	if len(entries) != 1 || entries[0].Kind != models.QueryLogTLS {
+		t.Fatalf("entries = %+v, want one tls entry", entries)
+	}
+}
+
+func TestQueryAuditorMySQL(t *testing.T) {
+	a, store := newTestQueryAuditor(t, models.QueryAuditMySQL)
+
+	caps := uint32(mysqlClientProtocol41 | mysqlClientSecureConnection | mysqlClientConnectWithDB)
+	handshake := binary.LittleEndian.AppendUint32(nil, caps)
+	handshake = append(handshake, make([]byte, 28)...)
+	handshake = append(handshake, "bob\x00"...)
+	handshake = append(handshake, 3, 'x', 'y', 'z')
+	handshake = append(handshake, "shop\x00"...)
+
+	var stream []byte
+	stream = append(stream, mysqlPacket(1, handshake)...)
+	stream = append(stream, mysqlPacket(3, []byte("auth switch response"))...)
+	stream = append(stream, mysqlPacket(0, append([]byte{mysqlComQuery}, "SET PASSWORD = 'x''y'"...))...)
+	stream = append(stream, mysqlPacket(0, append([]byte{mysqlComInitDB}, "billing"...))...)
+	stream = append(stream, mysqlPacket(0, append([]byte{mysqlComStmtPrepare}, "SELECT ?"...))...)
+	stream = append(stream, mysqlPacket(0, []byte{mysqlComQuit})...)
+	a.Feed(stream[:7])
+	a.Feed(stream[7:])
+
+	entries := allQueryLogs(store)
+	if len(entries) != 4 {
+		t.Fatalf("got %d entries, want 4: %+v", len(entries), entries)
+	}
+	if e := entries[0]; e.Kind != models.QueryLogConnect || e.User != "bob" || e.Database != "shop" {
+		t.Fatalf("connect = %+v", e)
+	}
+	if e := entries[1]; e.Kind != models.QueryLogQuery || e.Query != "SET PASSWORD = '***'" || e.Bytes != 21 {
+		t.Fatalf("query = %+v", e)
+	}
+	if e := entries[2]; e.Kind != models.QueryLogUse || e.Database != "billing" {
+		t.Fatalf("use = %+v", e)
+	}
+	if e := entries[3]; e.Kind != models.QueryLogPrepare || e.Query != "SELECT ?" || e.Database != "billing" {
+		t.Fatalf("prepare = %+v", e)
+	}
+}
+
+func TestQueryAuditorMySQLTLS(t *testing.T) {
+	a, store := newTestQueryAuditor(t, models.QueryAuditMySQL)
+	ssl := binary.LittleEndian.AppendUint32(nil, mysqlClientProtocol41|mysqlClientSSL)
+	a.Feed(mysqlPacket(1, append(ssl, make([]byte, 28)...)))
+	a.Feed(mysqlPacket(0, append([]byte{mysqlComQuery}, "SELECT 1"...)))
+
+	entries := allQueryLogs(store)
+	if len(entries) != 1 || entries[0].Kind != models.QueryLogTLS {
+		t.Fatalf("entries = %+v, want one tls entry", entries)
+	}
+}
+
+func TestRedactQuery(t *testing.T) {
+	cases := []struct {
+		query, mode, protocol, want string
+	}{
+		{"SELECT 'a' FROM t WHERE id = 42", QueryRedactNone, models.QueryAuditPostgres, "SELECT 'a' FROM t WHERE id = 42"},
+		{"SELECT 'a', t1.x FROM t1 WHERE id = 42 AND f > 1.5", QueryRedactLiterals, models.QueryAuditPostgres, "SELECT ?, t1.x FROM t1 WHERE id = ? AND f > ?"},
+		{`SELECT "Col" FROM t WHERE s = $1 AND b = $tag$x$tag$`, QueryRedactLiterals, models.QueryAuditPostgres, `SELECT "Col" FROM t WHERE s = $1 AND b = ?`},
+		{`SELECT "it's" FROM t -- 'comment'`, QueryRedactLiterals, models.QueryAuditMySQL, `SELECT ? FROM t -- 'comment'`},
+		{"CREATE USER u IDENTIFIED WITH caching_sha2_password BY 's3cr\\'et' x 'y'", QueryRedactSecrets, models.QueryAuditMySQL, "CREATE USER u IDENTIFIED WITH caching_sha2_password BY '***' x 'y'"},
+		{"CREATE ROLE r LOGIN PASSWORD 'p' VALID UNTIL '2030-01-01'", QueryRedactSecrets, models.QueryAuditPostgres, "CREATE ROLE r LOGIN PASSWORD '***' VALID UNTIL '2030-01-01'"},
+		{"INSERT INTO t VALUES ('unterminated", QueryRedactLiterals, models.QueryAuditPostgres, "INSERT INTO t VALUES (?"},
+	}
+	for _, tc := range cases {
+		if got := RedactQuery(tc.query, tc.mode, tc.protocol); got != tc.want {
+			t.Errorf("RedactQuery(%q, %s) = %q, want %q", tc.query, tc.mode, got, tc.want)
+		}
+	}
+}
+
+func TestQueryLogStoreClearWorkspace(t *testing.T) {
+	store := NewQueryLogStore(2)
+	for _, ws := range []string{"a", "b", "a"} {
+		store.Record(&models.QueryLog{Workspace: ws, Query: ws})
+	}
+	if _, total := store.Query(models.QueryLogFilter{}, 1, 10); total != 2 {
+		t.Fatalf("total = %d, want the limit of 2", total)
+	}
+	if removed := store.Clear("a"); removed != 1 {
+		t.Fatalf("removed %d, want 1", removed)
+	}
+	entries, _ := store.Query(models.QueryLogFilter{}, 1, 10)
+	if len(entries) != 1 || !strings.EqualFold(entries[0].Workspace, "b") {
+		t.Fatalf("entries = %+v", entries)
+	}
+}
@@ -0,0 +1,258 @@
+package core
+
+import (
+	"bastion/config"
+	"bastion/models"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Redaction modes of audited statements (QUERY_AUDIT_REDACT)
+const (
+	QueryRedactNone     = "none"     // statements are kept as sent
+	QueryRedactSecrets  = "secrets"  // string literals near PASSWORD, IDENTIFIED or SECRET become '***'
+	QueryRedactLiterals = "literals" // every string and number literal becomes ?
+)
+
+// QueryLogStore keeps the most recent audited database statements in a bounded ring, like
+// AccessLogStore.
+type QueryLogStore struct {
+	mu     sync.RWMutex
+	max    int
+	nextID int64
+	logs   []*models.QueryLog // oldest first
+}
+
+var QueryLogs *QueryLogStore
+
+func init() {
+	QueryLogs = NewQueryLogStore(config.Settings.QueryLogMax)
+}
+
+// NewQueryLogStore constructs a store keeping up to max entries; max <= 0 disables it.
+func NewQueryLogStore(max int) *QueryLogStore {
+	return &QueryLogStore{max: max}
+}
+
+// Enabled reports whether entries are kept.
+func (s *QueryLogStore) Enabled() bool {
+	return s.max > 0
+}
+
+// Record stores entry, assigning its ID, and evicts the oldest entries beyond the limit.
+func (s *QueryLogStore) Record(entry *models.QueryLog) {
+	if !s.Enabled() {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID++
+	entry.ID = s.nextID
+	s.logs = append(s.logs, entry)
+	if over := len(s.logs) - s.max; over > 0 {
+		copy(s.logs, s.logs[over:])
+		for i := len(s.logs) - over; i < len(s.logs); i++ {
+			s.logs[i] = nil
+		}
+		s.logs = s.logs[:len(s.logs)-over]
+	}
+}
+
+// Query returns a page of entries (latest first) matching filter, plus the total match count.
+func (s *QueryLogStore) Query(filter models.QueryLogFilter, page, pageSize int) ([]*models.QueryLog, int) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = 20
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	matched := make([]*models.QueryLog, 0)
+	for i := len(s.logs) - 1; i >= 0; i-- {
+		if filter.Matches(s.logs[i]) {
+			matched = append(matched, s.logs[i])
+		}
+	}
+
+	start := (page - 1) * pageSize
+	if start >= len(matched) {
+		return []*models.QueryLog{}, len(matched)
+	}
+	end := start + pageSize
+	if end > len(matched) {
+		end = len(matched)
+	}
+	return matched[start:end], len(matched)
+}
+
+// Clear removes the entries of workspace and returns how many were removed.
+func (s *QueryLogStore) Clear(workspace string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	kept := s.logs[:0]
+	for _, entry := range s.logs {
+		if entry.Workspace != workspace {
+			kept = append(kept, entry)
+		}
+	}
+	removed := len(s.logs) - len(kept)
+	for i := len(kept); i < len(s.logs); i++ {
+		s.logs[i] = nil
+	}
+	s.logs = kept
+	return removed
+}
+
+// ValidateQueryRedact checks a QUERY_AUDIT_REDACT mode.
+func ValidateQueryRedact(mode string) error {
+	switch mode {
+	case QueryRedactNone, QueryRedactSecrets, QueryRedactLiterals:
+		return nil
+	}
+	return fmt.Errorf("invalid query redaction %q: use %s, %s or %s", mode, QueryRedactNone, QueryRedactSecrets, QueryRedactLiterals)
+}
+
+// RedactQuery masks the literals of a SQL statement as mode says. Comments and identifiers are
+// kept; double quotes delimit strings in MySQL and identifiers in PostgreSQL, which also has
+// dollar-quoted strings. An unterminated literal (a truncated statement) is masked to the end.
+func RedactQuery(query, mode, protocol string) string {
+	if mode != QueryRedactSecrets && mode != QueryRedactLiterals {
+		return query
+	}
+	mysql := protocol == models.QueryAuditMySQL
+
+	var out strings.Builder
+	out.Grow(len(query))
+	var recent [4]string // last words, upper-cased
+	secretNear := func() bool {
+		for _, w := range recent {
+			if w == "PASSWORD" || w == "IDENTIFIED" || w == "SECRET" {
+				return true
+			}
+		}
+		return false
+	}
+	literal := func() {
+		if mode == QueryRedactLiterals {
+			out.WriteString("?")
+		} else {
+			out.WriteString("'***'")
+		}
+	}
+
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == '\'' || (c == '"' && mysql):
+			end := skipQuoted(query, i, c, mysql)
+			if mode == QueryRedactLiterals || secretNear() {
+				literal()
+			} else {
+				out.WriteString(query[i:end])
+			}
+			recent = [4]string{} // a secret keyword covers the first literal after it
+			i = end
+		case c == '"' || c == '`':
+			end := skipQuoted(query, i, c, false)
+			out.WriteString(query[i:end])
+			i = end
+		case c == '$' && !mysql && dollarTag(query[i:]) != "":
+			tag := dollarTag(query[i:])
+			end := len(query)
+			if j := strings.Index(query[i+len(tag):], tag); j >= 0 {
+				end = i + len(tag) + j + len(tag)
+			}
+			if mode == QueryRedactLiterals || secretNear() {
+				literal()
+			} else {
+				out.WriteString(query[i:end])
+			}
+			recent = [4]string{}
+			i = end
+		case c == '-' && strings.HasPrefix(query[i:], "--"), c == '#' && mysql:
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				end = len(query) - i
+			}
+			out.WriteString(query[i : i+end])
+			i += end
+		case c == '/' && strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				end = len(query)
+			} else {
+				end = i + 2 + end + 2
+			}
+			out.WriteString(query[i:end])
+			i = end
+		case isWordByte(c) && (c < '0' || c > '9'):
+			end := i
+			for end < len(query) && isWordByte(query[end]) {
+				end++
+			}
+			copy(recent[1:], recent[:len(recent)-1])
+			recent[0] = strings.ToUpper(query[i:end])
+			out.WriteString(query[i:end])
+			i = end
+		case c >= '0' && c <= '9':
+			end := i
+			for end < len(query) && (isWordByte(query[end]) || query[end] == '.') {
+				end++
+			}
+			if mode == QueryRedactLiterals {
+				out.WriteString("?")
+			} else {
+				out.WriteString(query[i:end])
+			}
+			i = end
+		default:
+			out.WriteByte(c)
+			i++
+		}
+	}
+	return out.String()
+}
+
+// skipQuoted returns the index after the literal or identifier opened by quote at query[start]:
+// a doubled quote is part of it, and with backslashes a backslash escapes the next byte.
+func skipQuoted(query string, start int, quote byte, backslashes bool) int {
+	for i := start + 1; i < len(query); i++ {
+		switch query[i] {
+		case '\\':
+			if backslashes {
+				i++
+			}
+		case quote:
+			if i+1 < len(query) && query[i+1] == quote {
+				i++
+				continue
+			}
+			return i + 1
+		}
+	}
+	return len(query)
+}
+
+// dollarTag returns the opening tag of a PostgreSQL dollar-quoted string at the start of s ($$ or
+// $name$), or "" (e.g. for a $1 parameter).
+func dollarTag(s string) string {
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '$':
+			return s[:i+1]
+		case c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (i > 1 && c >= '0' && c <= '9'):
+		default:
+			return ""
+		}
+	}
+	return ""
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c == '$' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c >= 0x80
+}
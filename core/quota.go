@@ -0,0 +1,198 @@
+package core
+
+import (
+	"bastion/config"
+	"bastion/models"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// MappingEventQuotaExceeded is recorded once per period when a mapping first hits its quota.
+const MappingEventQuotaExceeded = "quota_exceeded"
+
+// QuotaPolicy is a mapping's daily traffic quota. Traffic is counted by Usage; the quota is checked
+// when a client connects, so connections already open when it is used up keep running.
+type QuotaPolicy struct {
+	BytesPerDay int64  // 0 = no quota
+	Action      string // models.QuotaActionRefuse or models.QuotaActionThrottle
+	ThrottleBps int64  // per-connection rate once a throttle quota is used up
+}
+
+// NewQuotaPolicy resolves the mapping's quota, falling back to QUOTA_THROTTLE_BPS.
+func NewQuotaPolicy(mapping *models.Mapping) QuotaPolicy {
+	p := QuotaPolicy{Action: models.QuotaActionRefuse, ThrottleBps: int64(config.Settings.QuotaThrottleBps)}
+	if mapping == nil || mapping.QuotaBytesPerDay <= 0 {
+		return p
+	}
+	p.BytesPerDay = mapping.QuotaBytesPerDay
+	if mapping.QuotaAction == models.QuotaActionThrottle {
+		p.Action = models.QuotaActionThrottle
+	}
+	if mapping.QuotaThrottleBps > 0 {
+		p.ThrottleBps = mapping.QuotaThrottleBps
+	}
+	if p.ThrottleBps <= 0 {
+		p.Action = models.QuotaActionRefuse
+	}
+	return p
+}
+
+// ValidateQuota checks per-mapping quota settings.
+func ValidateQuota(bytesPerDay int64, action string, throttleBps int64) error {
+	if bytesPerDay < 0 {
+		return fmt.Errorf("quota_bytes_per_day must be >= 0")
+	}
+	switch action {
+	case "", models.QuotaActionRefuse, models.QuotaActionThrottle:
+	default:
+		return fmt.Errorf("invalid quota_action %q (use %s or %s)", action, models.QuotaActionRefuse, models.QuotaActionThrottle)
+	}
+	if throttleBps < 0 {
+		return fmt.Errorf("quota_throttle_bps must be >= 0")
+	}
+	return nil
+}
+
+// QuotaPeriod returns the bounds of the quota day containing now; days start at QUOTA_RESET_HOUR
+// local time.
+func QuotaPeriod(now time.Time) (start, reset time.Time) {
+	hour := config.Settings.QuotaResetHour
+	if hour < 0 || hour > 23 {
+		hour = 0
+	}
+	start = time.Date(now.Year(), now.Month(), now.Day(), hour, 0, 0, 0, now.Location())
+	if start.After(now) {
+		start = start.AddDate(0, 0, -1)
+	}
+	return start, start.AddDate(0, 0, 1)
+}
+
+// MappingQuota returns the quota state of mapping, or nil when it has no quota.
+func MappingQuota(mapping *models.Mapping) *models.MappingQuotaStatus {
+	return NewQuotaPolicy(mapping).status(mapping.Key(), time.Now())
+}
+
+func (p QuotaPolicy) status(mappingID string, now time.Time) *models.MappingQuotaStatus {
+	if p.BytesPerDay <= 0 {
+		return nil
+	}
+	start, reset := QuotaPeriod(now)
+	used := Usage.QuotaUsed(mappingID, start)
+	return &models.MappingQuotaStatus{
+		LimitBytes:  p.BytesPerDay,
+		UsedBytes:   used,
+		Exceeded:    used >= p.BytesPerDay,
+		Action:      p.Action,
+		PeriodStart: start,
+		ResetAt:     reset,
+	}
+}
+
+// QuotaStatus returns the state of the session's quota, or nil when its mapping has none.
+func (s *BaseSession) QuotaStatus() *models.MappingQuotaStatus {
+	return s.quota.status(s.Mapping.Key(), time.Now())
+}
+
+// admitQuota applies the mapping's quota to a newly accepted connection. Once it is used up the
+// connection is refused, or throttled; the returned conn must be used in place of conn.
+func (s *BaseSession) admitQuota(conn net.Conn, tag string) (net.Conn, bool) {
+	st := s.QuotaStatus()
+	if st == nil || !st.Exceeded {
+		return conn, true
+	}
+
+	if period := st.PeriodStart.UnixNano(); atomic.SwapInt64(&s.quotaNotified, period) != period {
+		log.Printf("[%s] Mapping %s used its quota of %d bytes; %s new connections until %s",
+			tag, s.Mapping.ID, st.LimitBytes, quotaActionVerb(st.Action), st.ResetAt.Format(time.RFC3339))
+		MappingEvents.Record(s.Mapping.Key(), MappingEventQuotaExceeded, st.Action,
+			fmt.Sprintf("%d of %d bytes used, resets at %s", st.UsedBytes, st.LimitBytes, st.ResetAt.Format(time.RFC3339)))
+	}
+
+	if st.Action == models.QuotaActionThrottle {
+		return newThrottledConn(conn, s.quota.ThrottleBps), true
+	}
+	s.emitEvent(EventLimitReject, tag, conn.RemoteAddr().String(), "", "quota exceeded")
+	if config.Settings.LogLevel == "DEBUG" {
+		log.Printf("[%s] Rejected client %s: quota exceeded", tag, conn.RemoteAddr().String())
+	}
+	_ = conn.Close()
+	return nil, false
+}
+
+func quotaActionVerb(action string) string {
+	if action == models.QuotaActionThrottle {
+		return "throttling"
+	}
+	return "refusing"
+}
+
+// throttledConn limits reads and writes to bps bytes per second each.
+type throttledConn struct {
+	net.Conn
+	bps   int64
+	read  throttlePacer
+	write throttlePacer
+}
+
+// throttlePacer spaces transfers so that they average out to the connection's rate.
+type throttlePacer struct {
+	mu   sync.Mutex
+	next time.Time
+}
+
+func newThrottledConn(conn net.Conn, bps int64) *throttledConn {
+	return &throttledConn{Conn: conn, bps: bps}
+}
+
+func (c *throttledConn) Read(p []byte) (int, error) {
+	if int64(len(p)) > c.bps {
+		p = p[:c.bps]
+	}
+	n, err := c.Conn.Read(p)
+	c.read.wait(n, c.bps)
+	return n, err
+}
+
+func (c *throttledConn) Write(p []byte) (int, error) {
+	written := 0
+	for written < len(p) {
+		chunk := p[written:]
+		if int64(len(chunk)) > c.bps {
+			chunk = chunk[:c.bps]
+		}
+		c.write.wait(len(chunk), c.bps)
+		n, err := c.Conn.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+func (c *throttledConn) CloseWrite() error {
+	if cw, ok := c.Conn.(interface{ CloseWrite() error }); ok {
+		return cw.CloseWrite()
+	}
+	return nil
+}
+
+// wait sleeps until n more bytes fit into the rate.
+func (p *throttlePacer) wait(n int, bps int64) {
+	if n <= 0 {
+		return
+	}
+	p.mu.Lock()
+	now := time.Now()
+	if p.next.Before(now) {
+		p.next = now
+	}
+	p.next = p.next.Add(time.Duration(int64(n) * int64(time.Second) / bps))
+	delay := p.next.Sub(now)
+	p.mu.Unlock()
+	time.Sleep(delay)
+}
@@ -0,0 +1,119 @@
+package core
+
+import (
+	"bastion/config"
+	"bastion/models"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestQuotaPeriod_ResetHour(t *testing.T) {
+	old := config.Settings.QuotaResetHour
+	t.Cleanup(func() { config.Settings.QuotaResetHour = old })
+	config.Settings.QuotaResetHour = 6
+
+	start, reset := QuotaPeriod(time.Date(2026, 3, 10, 5, 59, 0, 0, time.UTC))
+	if !start.Equal(time.Date(2026, 3, 9, 6, 0, 0, 0, time.UTC)) || !reset.Equal(time.Date(2026, 3, 10, 6, 0, 0, 0, time.UTC)) {
+		t.Fatalf("before reset hour: got %v - %v", start, reset)
+	}
+	start, _ = QuotaPeriod(time.Date(2026, 3, 10, 6, 0, 0, 0, time.UTC))
+	if !start.Equal(time.Date(2026, 3, 10, 6, 0, 0, 0, time.UTC)) {
+		t.Fatalf("at reset hour: got %v", start)
+	}
+}
+
+func TestNewQuotaPolicy(t *testing.T) {
+	old := config.Settings.QuotaThrottleBps
+	t.Cleanup(func() { config.Settings.QuotaThrottleBps = old })
+	config.Settings.QuotaThrottleBps = 1000
+
+	if p := NewQuotaPolicy(&models.Mapping{}); p.BytesPerDay != 0 {
+		t.Fatalf("expected no quota, got %+v", p)
+	}
+	p := NewQuotaPolicy(&models.Mapping{QuotaBytesPerDay: 1 << 30, QuotaAction: models.QuotaActionThrottle})
+	if p.Action != models.QuotaActionThrottle || p.ThrottleBps != 1000 {
+		t.Fatalf("expected global throttle rate, got %+v", p)
+	}
+	p = NewQuotaPolicy(&models.Mapping{QuotaBytesPerDay: 1 << 30, QuotaAction: models.QuotaActionThrottle, QuotaThrottleBps: 50})
+	if p.ThrottleBps != 50 {
+		t.Fatalf("expected mapping throttle rate, got %+v", p)
+	}
+
+	if err := ValidateQuota(-1, "", 0); err == nil {
+		t.Fatal("expected negative quota to be rejected")
+	}
+	if err := ValidateQuota(1, "drop", 0); err == nil {
+		t.Fatal("expected unknown action to be rejected")
+	}
+}
+
+func TestMappingUsageTracker_QuotaResetsEachPeriod(t *testing.T) {
+	tr := NewMappingUsageTracker()
+	src := &fakeUsageSource{}
+	tr.Started("db", src)
+
+	day1 := time.Date(2026, 3, 10, 12, 0, 0, 0, time.Local)
+	day2 := day1.AddDate(0, 0, 1)
+	start1, _ := QuotaPeriod(day1)
+	start2, _ := QuotaPeriod(day2)
+
+	src.stats = SessionStats{BytesUp: 100, BytesDown: 400}
+	tr.mu.Lock()
+	tr.collectLocked(day1)
+	tr.mu.Unlock()
+	if got := tr.QuotaUsed("db", start1); got != 500 {
+		t.Fatalf("expected 500 bytes in the first period, got %d", got)
+	}
+
+	src.stats = SessionStats{BytesUp: 150, BytesDown: 400}
+	tr.mu.Lock()
+	tr.collectLocked(day2)
+	tr.mu.Unlock()
+	if got := tr.QuotaUsed("db", start2); got != 50 {
+		t.Fatalf("expected 50 bytes in the second period, got %d", got)
+	}
+	if got := tr.QuotaUsed("db", start1); got != 0 {
+		t.Fatalf("expected the first period to be over, got %d", got)
+	}
+	if u := tr.Get("db"); u.BytesUp != 150 || u.BytesDown != 400 {
+		t.Fatalf("lifetime totals must not reset: %+v", u)
+	}
+}
+
+func TestAdmitQuota_RefuseAndThrottle(t *testing.T) {
+	oldUsage := Usage
+	t.Cleanup(func() { Usage = oldUsage })
+	Usage = NewMappingUsageTracker()
+
+	mapping := &models.Mapping{ID: "quota-test", QuotaBytesPerDay: 100}
+	s := newBaseSession(mapping, nil)
+	src := &fakeUsageSource{}
+	Usage.Started(mapping.Key(), src)
+
+	client, peer := net.Pipe()
+	defer peer.Close()
+	if _, ok := s.admitQuota(client, "TCP"); !ok {
+		t.Fatal("expected connection to be admitted under the quota")
+	}
+
+	src.stats = SessionStats{BytesDown: 100}
+	if _, ok := s.admitQuota(client, "TCP"); ok {
+		t.Fatal("expected connection to be refused once the quota is used")
+	}
+	if st := s.QuotaStatus(); st == nil || !st.Exceeded || st.UsedBytes != 100 {
+		t.Fatalf("unexpected quota status: %+v", st)
+	}
+
+	s.quota.Action = models.QuotaActionThrottle
+	s.quota.ThrottleBps = 10
+	client, peer2 := net.Pipe()
+	defer peer2.Close()
+	conn, ok := s.admitQuota(client, "TCP")
+	if !ok {
+		t.Fatal("expected throttled connection to be admitted")
+	}
+	if _, isThrottled := conn.(*throttledConn); !isThrottled {
+		t.Fatalf("expected a throttled conn, got %T", conn)
+	}
+}
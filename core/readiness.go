@@ -0,0 +1,162 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+)
+
+// Readiness probe timeouts
+const (
+	DefaultReadinessTimeout = 10 * time.Second
+	MaxReadinessTimeout     = 2 * time.Minute
+	readinessRetryInterval  = 250 * time.Millisecond
+)
+
+var errReadinessTimeout = errors.New("readiness timeout expired")
+
+// ReadinessReport is the outcome of ProbeReadiness: the steps of its last attempt.
+type ReadinessReport struct {
+	Ready      bool       `json:"ready"`
+	Attempts   int        `json:"attempts"`
+	DurationMS int64      `json:"duration_ms"`
+	Listener   DryRunStep `json:"listener"`
+	// Target is the remote of a tcp mapping or, for proxy mappings, the bastion chain; nil when a
+	// proxy mapping connects directly.
+	Target *DryRunStep `json:"target,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+// readinessProber is implemented by the sessions embedding BaseSession.
+type readinessProber interface {
+	probeReadiness(timeout time.Duration) (DryRunStep, *DryRunStep)
+}
+
+// ProbeReadiness checks that a started session is usable end to end, retrying until it is or timeout
+// passes: the local listener must accept a connection and the session's target must be reachable
+// through its own route. Tcp mappings dial their remote; proxy mappings, whose targets are chosen by
+// clients, open their bastion chain. Either way the pooled chain is warm for the first client.
+func ProbeReadiness(session Session, timeout time.Duration) *ReadinessReport {
+	report := &ReadinessReport{}
+	prober, ok := session.(readinessProber)
+	if !ok {
+		report.Error = "session does not support readiness probes"
+		return report
+	}
+
+	start := time.Now()
+	deadline := start.Add(timeout)
+	for {
+		report.Attempts++
+		report.Listener, report.Target = prober.probeReadiness(time.Until(deadline))
+		report.Ready = report.Listener.Status == DryRunOK && (report.Target == nil || report.Target.Status == DryRunOK)
+		if report.Ready || time.Until(deadline) <= readinessRetryInterval {
+			break
+		}
+		time.Sleep(readinessRetryInterval)
+	}
+	report.DurationMS = time.Since(start).Milliseconds()
+
+	switch {
+	case report.Ready:
+	case report.Target != nil && report.Target.Status == DryRunFailed:
+		report.Error = report.Target.Error
+	default:
+		report.Error = report.Listener.Error
+	}
+	return report
+}
+
+// probeReadiness runs one readiness attempt within timeout: the target first, so the pooled chain is
+// built by the probe rather than by the connection the listener check hands to the session.
+func (s *BaseSession) probeReadiness(timeout time.Duration) (DryRunStep, *DryRunStep) {
+	deadline := time.Now().Add(timeout)
+
+	var target *DryRunStep
+	switch {
+	case s.Mapping.Type == "tcp":
+		addr := net.JoinHostPort(s.Mapping.RemoteHost, strconv.Itoa(s.Mapping.RemotePort))
+		step := timedStep("target", addr, func() error {
+			remaining := time.Until(deadline)
+			if remaining <= 0 {
+				return errReadinessTimeout
+			}
+			conn, err := dialWithTimeout(remaining, func() (net.Conn, error) {
+				return s.dialRemote(addr, "readiness-probe")
+			})
+			if err == nil {
+				err = conn.Close()
+			}
+			return err
+		})
+		target = &step
+	case len(s.Bastions) > 0:
+		step := timedStep("chain", getBastionChainNames(s.Bastions), func() error {
+			return runWithTimeout(time.Until(deadline), func() error {
+				_, err := Pool.GetConnection(s.dialContext(), s.Bastions)
+				return err
+			})
+		})
+		target = &step
+	}
+
+	addr := s.readinessListenAddr()
+	listener := timedStep("listener", addr, func() error {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return errReadinessTimeout
+		}
+		conn, err := net.DialTimeout("tcp", addr, remaining)
+		if err == nil {
+			err = conn.Close()
+		}
+		return err
+	})
+	return listener, target
+}
+
+// readinessListenAddr returns the address clients of this host reach the listener at: the listen
+// host, or loopback when the mapping listens on every interface.
+func (s *BaseSession) readinessListenAddr() string {
+	host := s.Mapping.ListenHost()
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "127.0.0.1"
+		if ip != nil && ip.To4() == nil {
+			host = "::1"
+		}
+	}
+	return net.JoinHostPort(host, strconv.Itoa(s.LocalPort()))
+}
+
+// timedStep runs check and reports it as a dry-run style step.
+func timedStep(name, addr string, check func() error) DryRunStep {
+	step := DryRunStep{Name: name, Addr: addr, Status: DryRunOK}
+	start := time.Now()
+	err := check()
+	step.DurationMS = time.Since(start).Milliseconds()
+	if err != nil {
+		step.Status = DryRunFailed
+		step.Error = err.Error()
+	}
+	return step
+}
+
+// runWithTimeout runs f, giving up on it after timeout.
+func runWithTimeout(timeout time.Duration, f func() error) error {
+	if timeout <= 0 {
+		return errReadinessTimeout
+	}
+	done := make(chan error, 1)
+	go func() { done <- f() }()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+		return fmt.Errorf("timed out after %s", timeout)
+	}
+}
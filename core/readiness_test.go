@@ -0,0 +1,90 @@
+package core
+
+import (
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"bastion/models"
+)
+
+func TestProbeReadiness_TunnelReady(t *testing.T) {
+	remote, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer remote.Close()
+	go func() {
+		for {
+			conn, err := remote.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.Close()
+		}
+	}()
+
+	remotePort := remote.Addr().(*net.TCPAddr).Port
+	mapping := &models.Mapping{ID: "ready", LocalHost: "0.0.0.0", RemoteHost: "127.0.0.1", RemotePort: remotePort, Type: "tcp"}
+	session := NewTunnelSession(mapping, nil)
+	if err := session.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(session.Stop)
+
+	report := ProbeReadiness(session, time.Second)
+	if !report.Ready || report.Attempts != 1 || report.Error != "" {
+		t.Fatalf("expected a ready session, got %+v", report)
+	}
+	if report.Listener.Addr != net.JoinHostPort("127.0.0.1", strconv.Itoa(session.LocalPort())) {
+		t.Fatalf("listener probed at %s", report.Listener.Addr)
+	}
+	if report.Target == nil || report.Target.Status != DryRunOK {
+		t.Fatalf("expected the target to be dialed, got %+v", report.Target)
+	}
+}
+
+func TestProbeReadiness_TargetDown(t *testing.T) {
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	remotePort := closed.Addr().(*net.TCPAddr).Port
+	_ = closed.Close()
+
+	mapping := &models.Mapping{ID: "down", LocalHost: "127.0.0.1", RemoteHost: "127.0.0.1", RemotePort: remotePort, Type: "tcp"}
+	session := NewTunnelSession(mapping, nil)
+	if err := session.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(session.Stop)
+
+	report := ProbeReadiness(session, 600*time.Millisecond)
+	if report.Ready {
+		t.Fatalf("expected the probe to fail, got %+v", report)
+	}
+	if report.Attempts < 2 {
+		t.Fatalf("expected retries before giving up, got %d attempt(s)", report.Attempts)
+	}
+	if report.Listener.Status != DryRunOK || report.Target == nil || report.Target.Status != DryRunFailed {
+		t.Fatalf("expected only the target to fail, got %+v / %+v", report.Listener, report.Target)
+	}
+	if report.Error != report.Target.Error {
+		t.Fatalf("report error %q, want the target's %q", report.Error, report.Target.Error)
+	}
+}
+
+func TestProbeReadiness_DirectProxyChecksListenerOnly(t *testing.T) {
+	mapping := &models.Mapping{ID: "socks", LocalHost: "127.0.0.1", Type: "socks5"}
+	session := NewSocks5Session(mapping, nil)
+	if err := session.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(session.Stop)
+
+	report := ProbeReadiness(session, time.Second)
+	if !report.Ready || report.Target != nil {
+		t.Fatalf("expected a ready listener-only probe, got %+v", report)
+	}
+}
@@ -0,0 +1,63 @@
+package core
+
+import (
+	"context"
+	"net"
+	"sync"
+)
+
+// sessionConns holds the cancel funcs of the client connections a session is serving, so Stop can
+// abort them instead of waiting for each to finish on its own.
+type sessionConns struct {
+	mu      sync.Mutex
+	next    uint64
+	cancels map[uint64]context.CancelFunc
+	stopped bool
+}
+
+// trackConn registers a client connection being served. Canceling it (see abortConns) closes conn,
+// which aborts a handshake, a dial or a read blocked on it. The returned func unregisters it and
+// must be called when the connection is done.
+func (s *BaseSession) trackConn(conn net.Conn) func() {
+	ctx, cancel := context.WithCancel(s.dialContext())
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+
+	c := &s.conns
+	c.mu.Lock()
+	if c.stopped {
+		c.mu.Unlock()
+		cancel()
+		return func() {}
+	}
+	if c.cancels == nil {
+		c.cancels = make(map[uint64]context.CancelFunc)
+	}
+	id := c.next
+	c.next++
+	c.cancels[id] = cancel
+	c.mu.Unlock()
+
+	return func() {
+		stop()
+		cancel()
+		c.mu.Lock()
+		delete(c.cancels, id)
+		c.mu.Unlock()
+	}
+}
+
+// abortConns cancels every tracked connection and every one tracked from now on.
+func (s *BaseSession) abortConns() {
+	c := &s.conns
+	c.mu.Lock()
+	c.stopped = true
+	cancels := make([]context.CancelFunc, 0, len(c.cancels))
+	for _, cancel := range c.cancels {
+		cancels = append(cancels, cancel)
+	}
+	c.mu.Unlock()
+
+	for _, cancel := range cancels {
+		cancel()
+	}
+}
@@ -0,0 +1,103 @@
+package core
+
+import (
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"bastion/models"
+)
+
+// stopWithin stops the session and fails when it takes longer than d.
+func stopWithin(t *testing.T, stop func(), d time.Duration) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		stop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(d):
+		t.Fatalf("Stop took longer than %s", d)
+	}
+}
+
+// expectClosed fails unless the peer of conn closes it.
+func expectClosed(t *testing.T, conn net.Conn) {
+	t.Helper()
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Fatalf("expected the session to close the client connection, got %v", err)
+	}
+}
+
+func TestSession_StopAbortsSocks5Handshake(t *testing.T) {
+	mapping := &models.Mapping{ID: "socks", LocalHost: "127.0.0.1", Type: "socks5"}
+	session := NewSocks5Session(mapping, nil)
+	if err := session.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	// The client never sends its greeting, leaving the handshake blocked on a read.
+	conn, err := net.Dial("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(session.LocalPort())))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	waitForConns(t, &session.BaseSession, 1)
+
+	stopWithin(t, session.Stop, time.Second)
+	expectClosed(t, conn)
+}
+
+func TestSession_StopAbortsTransfer(t *testing.T) {
+	remote, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer remote.Close()
+	go func() {
+		for {
+			c, err := remote.Accept()
+			if err != nil {
+				return
+			}
+			defer c.Close() // idle: never writes
+		}
+	}()
+
+	port := remote.Addr().(*net.TCPAddr).Port
+	mapping := &models.Mapping{ID: "idle", LocalHost: "127.0.0.1", RemoteHost: "127.0.0.1", RemotePort: port, Type: "tcp"}
+	session := NewTunnelSession(mapping, nil)
+	if err := session.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	conn, err := net.Dial("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(session.LocalPort())))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	waitForConns(t, &session.BaseSession, 1)
+
+	stopWithin(t, session.Stop, time.Second)
+	expectClosed(t, conn)
+}
+
+func waitForConns(t *testing.T, s *BaseSession, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		s.conns.mu.Lock()
+		got := len(s.conns.cancels)
+		s.conns.mu.Unlock()
+		if got >= n {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("expected %d tracked connections", n)
+}
@@ -0,0 +1,69 @@
+package core
+
+import (
+	"sync/atomic"
+)
+
+// sessionResources counts what a session holds, for attributing process growth to a mapping.
+type sessionResources struct {
+	goroutines   int64 // live goroutines started by spawn
+	spawned      int64 // goroutines started since the session started
+	bufferBytes  int64 // forwarding buffers currently held
+	auditedBytes int64 // bytes fed to the HTTP parsers since start
+}
+
+// SessionResources is the resource usage attributed to one session. Go cannot account CPU time
+// per goroutine, so the traffic a session moves and parses stands in for its CPU share.
+type SessionResources struct {
+	Goroutines        int64 `json:"goroutines"`         // live goroutines of the session
+	GoroutinesSpawned int64 `json:"goroutines_spawned"` // started since the session started
+	Connections       int32 `json:"connections"`
+	BufferBytes       int64 `json:"buffer_bytes"` // forwarding buffers held
+	Parsers           int   `json:"parsers"`      // live HTTP audit parsers
+	ParserBytes       int64 `json:"parser_bytes"` // data buffered by the audit parsers
+	MemoryBytes       int64 `json:"memory_bytes"` // buffer_bytes + parser_bytes
+	BytesPerSec       int64 `json:"bytes_per_sec"`
+	AuditedBytes      int64 `json:"audited_bytes"`
+}
+
+// spawn runs f in a goroutine counted as the session's.
+func (s *BaseSession) spawn(f func()) {
+	atomic.AddInt64(&s.resources.goroutines, 1)
+	atomic.AddInt64(&s.resources.spawned, 1)
+	go func() {
+		defer atomic.AddInt64(&s.resources.goroutines, -1)
+		f()
+	}()
+}
+
+// newForwardBuffer takes a pooled forwarding buffer counted as held by the session.
+func (s *BaseSession) newForwardBuffer() *adaptiveBuffer {
+	return newAdaptiveBuffer(getForwardBufferPool()).trackHeld(&s.resources.bufferBytes)
+}
+
+// Resources reports the session's resource usage; bytes_per_sec is the 10s average up and down.
+func (s *BaseSession) Resources() SessionResources {
+	r := SessionResources{
+		Goroutines:        atomic.LoadInt64(&s.resources.goroutines),
+		GoroutinesSpawned: atomic.LoadInt64(&s.resources.spawned),
+		Connections:       atomic.LoadInt32(&s.activeConns),
+		BufferBytes:       atomic.LoadInt64(&s.resources.bufferBytes),
+		AuditedBytes:      atomic.LoadInt64(&s.resources.auditedBytes),
+	}
+
+	s.parserMu.Lock()
+	parsers := make([]*HTTPStreamParser, 0, len(s.httpParsers))
+	for _, p := range s.httpParsers {
+		parsers = append(parsers, p)
+	}
+	s.parserMu.Unlock()
+	r.Parsers = len(parsers)
+	for _, p := range parsers {
+		r.ParserBytes += int64(p.Buffered())
+	}
+
+	r.MemoryBytes = r.BufferBytes + r.ParserBytes
+	t := s.throughputStats()
+	r.BytesPerSec = t.UpBps10s + t.DownBps10s
+	return r
+}
@@ -0,0 +1,46 @@
+package core
+
+import (
+	"bastion/models"
+	"testing"
+	"time"
+)
+
+func TestBaseSession_Resources(t *testing.T) {
+	s := newBaseSession(&models.Mapping{ID: "res"}, nil)
+
+	release := make(chan struct{})
+	started := make(chan struct{}, 2)
+	for i := 0; i < 2; i++ {
+		s.spawn(func() {
+			started <- struct{}{}
+			<-release
+		})
+	}
+	<-started
+	<-started
+
+	buf := s.newForwardBuffer()
+	size := int64(len(buf.Bytes()))
+	s.httpParsers["c:request"] = NewHTTPStreamParser("c", "request")
+	s.httpParsers["c:request"].Feed([]byte("GET / HTTP/1.1\r\nHost: a\r\n"))
+
+	r := s.Resources()
+	if r.Goroutines != 2 || r.GoroutinesSpawned != 2 {
+		t.Fatalf("unexpected goroutine counts: %+v", r)
+	}
+	if r.BufferBytes != size || r.Parsers != 1 || r.ParserBytes == 0 || r.MemoryBytes != r.BufferBytes+r.ParserBytes {
+		t.Fatalf("unexpected memory accounting: %+v", r)
+	}
+
+	buf.Release()
+	close(release)
+	deadline := time.Now().Add(2 * time.Second)
+	for s.Resources().Goroutines != 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	r = s.Resources()
+	if r.Goroutines != 0 || r.GoroutinesSpawned != 2 || r.BufferBytes != 0 {
+		t.Fatalf("expected goroutines and buffers to be released: %+v", r)
+	}
+}
@@ -14,6 +14,11 @@ const (
 	socks5IPv4    = 0x01
 	socks5Domain  = 0x03
 	socks5IPv6    = 0x04
+
+	// Reply codes (RFC 1928 section 6)
+	socks5RepSucceeded  = 0x00
+	socks5RepFailure    = 0x01
+	socks5RepNotAllowed = 0x02 // connection not allowed by ruleset
 )
 
 // Socks5Handshake handles SOCKS5 handshakes
@@ -102,11 +107,14 @@ func (s *Socks5Handshake) Handshake(conn net.Conn) (string, int, error) {
 
 // SendReply sends the handshake result
 func (s *Socks5Handshake) SendReply(conn net.Conn, success bool) error {
-	rep := byte(0x00) // succeeded
-	if !success {
-		rep = 0x01 // general failure
+	if success {
+		return s.SendReplyCode(conn, socks5RepSucceeded)
 	}
+	return s.SendReplyCode(conn, socks5RepFailure)
+}
 
+// SendReplyCode sends a reply with the given REP code
+func (s *Socks5Handshake) SendReplyCode(conn net.Conn, rep byte) error {
 	// [VER, REP, RSV, ATYP, BND.ADDR(0.0.0.0), BND.PORT(0)]
 	reply := []byte{
 		socks5Version, rep, 0x00, socks5IPv4,
@@ -0,0 +1,119 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SSH algorithm lists a bastion can override (models.Bastion Ciphers, MACs and KeyExchanges).
+const (
+	SSHAlgorithmCiphers      = "ciphers"
+	SSHAlgorithmMACs         = "macs"
+	SSHAlgorithmKeyExchanges = "kex"
+)
+
+// sshAlgorithmSet is what golang.org/x/crypto/ssh implements for one list, and its default
+// preference, which mirror the library's unexported tables.
+type sshAlgorithmSet struct {
+	supported []string
+	defaults  []string
+}
+
+var sshAlgorithms = map[string]sshAlgorithmSet{
+	SSHAlgorithmCiphers: {
+		supported: []string{
+			"aes128-gcm@openssh.com", "aes256-gcm@openssh.com", "chacha20-poly1305@openssh.com",
+			"aes128-ctr", "aes192-ctr", "aes256-ctr",
+			"aes128-cbc", "3des-cbc", "arcfour256", "arcfour128", "arcfour",
+		},
+		defaults: []string{
+			"aes128-gcm@openssh.com", "aes256-gcm@openssh.com", "chacha20-poly1305@openssh.com",
+			"aes128-ctr", "aes192-ctr", "aes256-ctr",
+		},
+	},
+	SSHAlgorithmMACs: {
+		supported: []string{
+			"hmac-sha2-256-etm@openssh.com", "hmac-sha2-512-etm@openssh.com",
+			"hmac-sha2-256", "hmac-sha2-512", "hmac-sha1", "hmac-sha1-96",
+		},
+		defaults: []string{
+			"hmac-sha2-256-etm@openssh.com", "hmac-sha2-512-etm@openssh.com",
+			"hmac-sha2-256", "hmac-sha2-512", "hmac-sha1", "hmac-sha1-96",
+		},
+	},
+	SSHAlgorithmKeyExchanges: {
+		supported: []string{
+			"curve25519-sha256", "curve25519-sha256@libssh.org",
+			"ecdh-sha2-nistp256", "ecdh-sha2-nistp384", "ecdh-sha2-nistp521",
+			"diffie-hellman-group14-sha256", "diffie-hellman-group16-sha512", "diffie-hellman-group14-sha1",
+			"diffie-hellman-group1-sha1",
+			"diffie-hellman-group-exchange-sha256", "diffie-hellman-group-exchange-sha1",
+		},
+		defaults: []string{
+			"curve25519-sha256", "curve25519-sha256@libssh.org",
+			"ecdh-sha2-nistp256", "ecdh-sha2-nistp384", "ecdh-sha2-nistp521",
+			"diffie-hellman-group14-sha256", "diffie-hellman-group14-sha1",
+		},
+	},
+}
+
+// ParseSSHAlgorithms parses a comma-separated algorithm list of the given kind in the OpenSSH
+// syntax: the algorithms to offer in order of preference, or with a leading "+" the ones to append
+// to the defaults (e.g. "+aes128-cbc" for a legacy host). An empty list returns nil, which keeps
+// the defaults.
+func ParseSSHAlgorithms(kind, list string) ([]string, error) {
+	set, ok := sshAlgorithms[kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown SSH algorithm list: %s", kind)
+	}
+	list = strings.TrimSpace(list)
+	if list == "" {
+		return nil, nil
+	}
+
+	var algorithms []string
+	if strings.HasPrefix(list, "+") {
+		list = list[1:]
+		algorithms = append(algorithms, set.defaults...)
+	}
+	seen := make(map[string]bool, len(algorithms))
+	for _, a := range algorithms {
+		seen[a] = true
+	}
+	for _, a := range strings.Split(list, ",") {
+		a = strings.TrimSpace(a)
+		if a == "" || seen[a] {
+			continue
+		}
+		if !containsString(set.supported, a) {
+			return nil, fmt.Errorf("unsupported SSH %s algorithm %q (supported: %s)", kind, a, strings.Join(set.supported, ", "))
+		}
+		seen[a] = true
+		algorithms = append(algorithms, a)
+	}
+	if len(algorithms) == 0 {
+		return nil, fmt.Errorf("empty SSH %s list", kind)
+	}
+	return algorithms, nil
+}
+
+// ValidateSSHAlgorithms checks the algorithm overrides of a bastion.
+func ValidateSSHAlgorithms(ciphers, macs, keyExchanges string) error {
+	if _, err := ParseSSHAlgorithms(SSHAlgorithmCiphers, ciphers); err != nil {
+		return err
+	}
+	if _, err := ParseSSHAlgorithms(SSHAlgorithmMACs, macs); err != nil {
+		return err
+	}
+	_, err := ParseSSHAlgorithms(SSHAlgorithmKeyExchanges, keyExchanges)
+	return err
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
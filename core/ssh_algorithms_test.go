@@ -0,0 +1,48 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+
+	"bastion/models"
+)
+
+func TestParseSSHAlgorithms(t *testing.T) {
+	got, err := ParseSSHAlgorithms(SSHAlgorithmCiphers, " aes256-ctr, aes128-cbc ,")
+	if err != nil || !reflect.DeepEqual(got, []string{"aes256-ctr", "aes128-cbc"}) {
+		t.Fatalf("got %v, %v", got, err)
+	}
+
+	got, err = ParseSSHAlgorithms(SSHAlgorithmKeyExchanges, "+diffie-hellman-group1-sha1,curve25519-sha256")
+	if err != nil {
+		t.Fatalf("ParseSSHAlgorithms: %v", err)
+	}
+	defaults := sshAlgorithms[SSHAlgorithmKeyExchanges].defaults
+	if len(got) != len(defaults)+1 || got[len(got)-1] != "diffie-hellman-group1-sha1" {
+		t.Fatalf("expected the defaults plus group1, got %v", got)
+	}
+
+	if got, err := ParseSSHAlgorithms(SSHAlgorithmMACs, ""); err != nil || got != nil {
+		t.Fatalf("empty list must keep the defaults, got %v, %v", got, err)
+	}
+	if _, err := ParseSSHAlgorithms(SSHAlgorithmMACs, "hmac-md5"); err == nil {
+		t.Fatal("expected unsupported MAC rejected")
+	}
+	if _, err := ParseSSHAlgorithms(SSHAlgorithmCiphers, " , "); err == nil {
+		t.Fatal("expected empty list rejected")
+	}
+}
+
+func TestBastionClientConfig_Algorithms(t *testing.T) {
+	cfg, err := bastionClientConfig(models.Bastion{Name: "legacy", Username: "u", Password: "p", Ciphers: "3des-cbc", MACs: "hmac-sha1"})
+	if err != nil {
+		t.Fatalf("bastionClientConfig: %v", err)
+	}
+	if !reflect.DeepEqual(cfg.Ciphers, []string{"3des-cbc"}) || !reflect.DeepEqual(cfg.MACs, []string{"hmac-sha1"}) || cfg.KeyExchanges != nil {
+		t.Fatalf("unexpected algorithms: %v %v %v", cfg.Ciphers, cfg.MACs, cfg.KeyExchanges)
+	}
+
+	if _, err := bastionClientConfig(models.Bastion{Name: "bad", Username: "u", Password: "p", KeyExchanges: "nope"}); err == nil {
+		t.Fatal("expected unsupported key exchange rejected")
+	}
+}
@@ -0,0 +1,137 @@
+package core
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// SSHConfigHost is a concrete host entry parsed from an OpenSSH client config (~/.ssh/config).
+type SSHConfigHost struct {
+	Alias        string `json:"alias"`
+	HostName     string `json:"hostname"`
+	Port         int    `json:"port"`
+	User         string `json:"user,omitempty"`
+	IdentityFile string `json:"identity_file,omitempty"`
+	ProxyJump    string `json:"proxy_jump,omitempty"`
+}
+
+// DefaultSSHConfigPath returns the current user's ~/.ssh/config path, or "" when the home directory is unknown.
+func DefaultSSHConfigPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil || home == "" {
+		return ""
+	}
+	return filepath.Join(home, ".ssh", "config")
+}
+
+// LoadSSHConfig parses the ssh config file at path. A missing file yields no hosts and no error.
+func LoadSSHConfig(path string) ([]SSHConfigHost, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	return ParseSSHConfig(f)
+}
+
+// ParseSSHConfig extracts concrete Host entries. Wildcard/negated patterns and Match blocks are skipped;
+// Include directives are not followed. Options from "Host *" are not merged into other hosts.
+func ParseSSHConfig(r io.Reader) ([]SSHConfigHost, error) {
+	var (
+		hosts   []SSHConfigHost
+		current []int // indexes into hosts for the active Host block
+	)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, value := splitSSHConfigLine(line)
+		if key == "" {
+			continue
+		}
+
+		switch key {
+		case "host":
+			current = current[:0]
+			for _, alias := range strings.Fields(value) {
+				if strings.ContainsAny(alias, "*?!") {
+					continue
+				}
+				hosts = append(hosts, SSHConfigHost{Alias: alias, Port: 22})
+				current = append(current, len(hosts)-1)
+			}
+			continue
+		case "match":
+			current = current[:0]
+			continue
+		}
+
+		for _, idx := range current {
+			h := &hosts[idx]
+			switch key {
+			case "hostname":
+				if h.HostName == "" {
+					h.HostName = value
+				}
+			case "port":
+				if p, err := strconv.Atoi(value); err == nil && p > 0 && p <= 65535 {
+					h.Port = p
+				}
+			case "user":
+				if h.User == "" {
+					h.User = value
+				}
+			case "identityfile":
+				if h.IdentityFile == "" {
+					h.IdentityFile = expandHomePath(value)
+				}
+			case "proxyjump":
+				if h.ProxyJump == "" {
+					h.ProxyJump = value
+				}
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range hosts {
+		if hosts[i].HostName == "" {
+			hosts[i].HostName = hosts[i].Alias
+		}
+	}
+	return hosts, nil
+}
+
+// splitSSHConfigLine splits "Key value" or "Key=value" into a lowercased key and unquoted value.
+func splitSSHConfigLine(line string) (string, string) {
+	idx := strings.IndexAny(line, " \t=")
+	if idx < 0 {
+		return strings.ToLower(line), ""
+	}
+	key := strings.ToLower(line[:idx])
+	value := strings.TrimLeft(line[idx:], " \t=")
+	value = strings.Trim(strings.TrimSpace(value), `"`)
+	return key, value
+}
+
+func expandHomePath(p string) string {
+	if p == "~" || strings.HasPrefix(p, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, strings.TrimPrefix(p, "~"))
+		}
+	}
+	return p
+}
@@ -0,0 +1,53 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseSSHConfig(t *testing.T) {
+	cfg := `
+# comment
+Host *
+    User ignored-default
+
+Host jump prod-jump
+    HostName 10.0.0.5
+    Port=2222
+    User ops
+    IdentityFile /keys/id_ed25519
+
+Host web-*
+    User nobody
+
+Host plain
+    ProxyJump jump
+`
+	hosts, err := ParseSSHConfig(strings.NewReader(cfg))
+	if err != nil {
+		t.Fatalf("ParseSSHConfig: %v", err)
+	}
+	if len(hosts) != 3 {
+		t.Fatalf("expected 3 hosts, got %d: %+v", len(hosts), hosts)
+	}
+
+	for _, alias := range []string{"jump", "prod-jump"} {
+		var h *SSHConfigHost
+		for i := range hosts {
+			if hosts[i].Alias == alias {
+				h = &hosts[i]
+			}
+		}
+		if h == nil {
+			t.Fatalf("missing host %q", alias)
+		}
+		if h.HostName != "10.0.0.5" || h.Port != 2222 || h.User != "ops" || h.IdentityFile != "/keys/id_ed25519" {
+			t.Fatalf("unexpected host %q: %+v", alias, *h)
+		}
+	}
+
+	plain := hosts[2]
+	if plain.Alias != "plain" || plain.HostName != "plain" || plain.Port != 22 || plain.User != "" || plain.ProxyJump != "jump" {
+		t.Fatalf("unexpected plain host: %+v", plain)
+	}
+}
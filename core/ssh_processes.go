@@ -0,0 +1,284 @@
+package core
+
+import (
+	"bastion/models"
+	"fmt"
+	"net"
+	"os/user"
+	"strconv"
+	"strings"
+)
+
+// sshOptionsWithArg are the ssh client flags that take an argument (see ssh(1)).
+const sshOptionsWithArg = "BbcDEeFIiJLlmOoPpQRSWw"
+
+// sshCommandLine is the argv of a running process.
+type sshCommandLine struct {
+	pid  int
+	args []string
+}
+
+// ListSSHProcesses lists the running ssh clients that forward ports (-L, -D, -R), best effort:
+// /proc on Linux, ps on other Unix systems and PowerShell on Windows. Hosts are resolved through
+// the current user's ~/.ssh/config, and a missing user defaults to the current user.
+func ListSSHProcesses() ([]models.SSHProcess, DiagnosticsMeta) {
+	cmdlines, meta := listSSHCommandLines()
+
+	var hosts []SSHConfigHost
+	if path := DefaultSSHConfigPath(); path != "" {
+		hosts, _ = LoadSSHConfig(path)
+	}
+	defaultUser := ""
+	if u, err := user.Current(); err == nil {
+		defaultUser = u.Username
+		if i := strings.LastIndex(defaultUser, `\`); i >= 0 {
+			defaultUser = defaultUser[i+1:] // DOMAIN\user on Windows
+		}
+	}
+
+	processes := []models.SSHProcess{}
+	for _, cl := range cmdlines {
+		p, ok := ParseSSHCommand(cl.args, hosts, defaultUser)
+		if !ok {
+			continue
+		}
+		p.PID = cl.pid
+		processes = append(processes, p)
+	}
+	return processes, meta
+}
+
+// isSSHClient reports whether argv0 runs the OpenSSH client.
+func isSSHClient(argv0 string) bool {
+	if i := strings.LastIndexAny(argv0, `/\`); i >= 0 {
+		argv0 = argv0[i+1:]
+	}
+	return strings.TrimSuffix(strings.ToLower(argv0), ".exe") == "ssh"
+}
+
+// ParseSSHCommand parses the argv of an ssh client. ok is false unless args runs ssh with a
+// destination and at least one port forward. Forwards over Unix sockets are left out.
+func ParseSSHCommand(args []string, hosts []SSHConfigHost, defaultUser string) (p models.SSHProcess, ok bool) {
+	if len(args) == 0 || !isSSHClient(args[0]) {
+		return p, false
+	}
+	p.CommandLine = strings.Join(args, " ")
+
+	var (
+		destination, user, identity, jump, hostName string
+		port                                        int
+	)
+	option := func(flag byte, value string) {
+		switch flag {
+		case 'L':
+			p.Forwards = appendSSHForward(p.Forwards, models.SSHForwardLocal, value)
+		case 'D':
+			p.Forwards = appendSSHForward(p.Forwards, models.SSHForwardDynamic, value)
+		case 'R':
+			p.Forwards = appendSSHForward(p.Forwards, models.SSHForwardRemote, value)
+		case 'J':
+			jump = value
+		case 'i':
+			if identity == "" {
+				identity = expandHomePath(value)
+			}
+		case 'l':
+			user = value
+		case 'p':
+			port, _ = strconv.Atoi(value)
+		case 'o':
+			key, val := splitSSHConfigLine(value)
+			switch key {
+			case "localforward":
+				p.Forwards = appendSSHForward(p.Forwards, models.SSHForwardLocal, strings.Join(strings.Fields(val), ":"))
+			case "dynamicforward":
+				p.Forwards = appendSSHForward(p.Forwards, models.SSHForwardDynamic, val)
+			case "remoteforward":
+				p.Forwards = appendSSHForward(p.Forwards, models.SSHForwardRemote, strings.Join(strings.Fields(val), ":"))
+			case "proxyjump":
+				jump = val
+			case "identityfile":
+				if identity == "" {
+					identity = expandHomePath(val)
+				}
+			case "user":
+				user = val
+			case "port":
+				port, _ = strconv.Atoi(val)
+			case "hostname":
+				hostName = val
+			}
+		}
+	}
+
+args:
+	for i := 1; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			if i+1 < len(args) {
+				destination = args[i+1]
+			}
+			break args
+		case len(arg) > 1 && arg[0] == '-':
+			for j := 1; j < len(arg); j++ {
+				if strings.IndexByte(sshOptionsWithArg, arg[j]) < 0 {
+					continue
+				}
+				value := arg[j+1:]
+				if value == "" && i+1 < len(args) {
+					i++
+					value = args[i]
+				}
+				option(arg[j], value)
+				break
+			}
+		default:
+			destination = arg // what follows is the remote command
+			break args
+		}
+	}
+	if destination == "" || len(p.Forwards) == 0 {
+		return p, false
+	}
+
+	dest, err := parseSSHEndpoint(destination)
+	if err != nil {
+		return p, false
+	}
+	if user != "" {
+		dest.User = user
+	}
+	if port > 0 {
+		dest.Port = port
+	}
+	configJump := resolveSSHEndpoint(&dest, hosts, defaultUser)
+	if hostName != "" {
+		dest.Host = hostName
+	}
+	p.Destination = dest
+	if identity != "" {
+		p.Destination.IdentityFile = identity
+	}
+
+	if jump == "" {
+		jump = configJump
+	}
+	if jump != "" && !strings.EqualFold(jump, "none") {
+		for _, spec := range strings.Split(jump, ",") {
+			hop, err := parseSSHEndpoint(strings.TrimSpace(spec))
+			if err != nil {
+				continue
+			}
+			resolveSSHEndpoint(&hop, hosts, defaultUser)
+			p.JumpHosts = append(p.JumpHosts, hop)
+		}
+	}
+	return p, true
+}
+
+// parseSSHEndpoint parses [user@]host[:port] and ssh://[user@]host[:port]. The port is left 0
+// when not given.
+func parseSSHEndpoint(s string) (models.SSHEndpoint, error) {
+	var e models.SSHEndpoint
+	s = strings.TrimPrefix(s, "ssh://")
+	if i := strings.LastIndex(s, "@"); i >= 0 {
+		e.User, s = s[:i], s[i+1:]
+	}
+	if host, port, err := net.SplitHostPort(s); err == nil {
+		p, err := strconv.Atoi(port)
+		if err != nil || p < 1 || p > 65535 {
+			return e, fmt.Errorf("invalid port in %q", s)
+		}
+		s, e.Port = host, p
+	}
+	s = strings.Trim(s, "[]")
+	if s == "" {
+		return e, fmt.Errorf("missing host")
+	}
+	e.Host = s
+	return e, nil
+}
+
+// resolveSSHEndpoint fills in the HostName, Port, User and IdentityFile of an ssh config alias and
+// the defaults, returning the alias's ProxyJump.
+func resolveSSHEndpoint(e *models.SSHEndpoint, hosts []SSHConfigHost, defaultUser string) string {
+	proxyJump := ""
+	for _, h := range hosts {
+		if h.Alias != e.Host {
+			continue
+		}
+		e.Alias, e.Host = h.Alias, h.HostName
+		if e.Port == 0 {
+			e.Port = h.Port
+		}
+		if e.User == "" {
+			e.User = h.User
+		}
+		e.IdentityFile = h.IdentityFile
+		proxyJump = h.ProxyJump
+		break
+	}
+	if e.Port == 0 {
+		e.Port = 22
+	}
+	if e.User == "" {
+		e.User = defaultUser
+	}
+	return proxyJump
+}
+
+// appendSSHForward adds a forward spec; local and dynamic forwards that do not listen on a TCP
+// port (Unix sockets) are dropped.
+func appendSSHForward(forwards []models.SSHForward, kind, spec string) []models.SSHForward {
+	f := models.SSHForward{Kind: kind, Spec: spec}
+	if kind == models.SSHForwardRemote {
+		return append(forwards, f)
+	}
+
+	parts := splitForwardSpec(spec)
+	want := 2 // [bind:]port:host:hostport
+	if kind == models.SSHForwardDynamic {
+		want = 0 // [bind:]port
+	}
+	if len(parts) == want+2 {
+		f.BindAddress, parts = parts[0], parts[1:]
+	}
+	if len(parts) != want+1 {
+		return forwards
+	}
+	port, err := strconv.Atoi(parts[0])
+	if err != nil || port < 1 || port > 65535 {
+		return forwards
+	}
+	f.Port = port
+	if kind == models.SSHForwardLocal {
+		remotePort, err := strconv.Atoi(parts[2])
+		if err != nil || remotePort < 1 || remotePort > 65535 || parts[1] == "" {
+			return forwards
+		}
+		f.RemoteHost, f.RemotePort = parts[1], remotePort
+	}
+	return append(forwards, f)
+}
+
+// splitForwardSpec splits a forward spec on colons outside of [IPv6] brackets, which it strips.
+func splitForwardSpec(spec string) []string {
+	var parts []string
+	var cur strings.Builder
+	inBrackets := false
+	for _, r := range spec {
+		switch {
+		case r == '[':
+			inBrackets = true
+		case r == ']':
+			inBrackets = false
+		case r == ':' && !inBrackets:
+			parts = append(parts, cur.String())
+			cur.Reset()
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	return append(parts, cur.String())
+}
@@ -0,0 +1,62 @@
+package core
+
+import (
+	"bastion/models"
+	"reflect"
+	"testing"
+)
+
+func TestParseSSHCommand(t *testing.T) {
+	hosts := []SSHConfigHost{
+		{Alias: "prod", HostName: "10.0.0.5", Port: 2222, User: "deploy", IdentityFile: "/keys/prod", ProxyJump: "jump"},
+		{Alias: "jump", HostName: "jump.example.com", Port: 22, User: "ops"},
+	}
+
+	p, ok := ParseSSHCommand([]string{"/usr/bin/ssh", "-fN", "-L", "8080:db.internal:5432", "-L[::1]:9090:[fd00::2]:80", "-D", "*:1080", "-R", "9000:localhost:9000", "prod"}, hosts, "me")
+	if !ok {
+		t.Fatal("expected a forwarding ssh process")
+	}
+	wantForwards := []models.SSHForward{
+		{Kind: models.SSHForwardLocal, Port: 8080, RemoteHost: "db.internal", RemotePort: 5432, Spec: "8080:db.internal:5432"},
+		{Kind: models.SSHForwardLocal, BindAddress: "::1", Port: 9090, RemoteHost: "fd00::2", RemotePort: 80, Spec: "[::1]:9090:[fd00::2]:80"},
+		{Kind: models.SSHForwardDynamic, BindAddress: "*", Port: 1080, Spec: "*:1080"},
+		{Kind: models.SSHForwardRemote, Spec: "9000:localhost:9000"},
+	}
+	if !reflect.DeepEqual(p.Forwards, wantForwards) {
+		t.Fatalf("forwards = %+v", p.Forwards)
+	}
+	wantDest := models.SSHEndpoint{Alias: "prod", Host: "10.0.0.5", Port: 2222, User: "deploy", IdentityFile: "/keys/prod"}
+	if p.Destination != wantDest {
+		t.Fatalf("destination = %+v", p.Destination)
+	}
+	if len(p.JumpHosts) != 1 || p.JumpHosts[0].Host != "jump.example.com" || p.JumpHosts[0].User != "ops" {
+		t.Fatalf("jump hosts from ssh config = %+v", p.JumpHosts)
+	}
+
+	p, ok = ParseSSHCommand([]string{"ssh.exe", "-o", "LocalForward=5000 127.0.0.1:5000", "-p", "2200", "-J", "a@hop1:22,hop2", "-i", "/k", "ssh://bob@example.org", "sleep", "-L", "1:x:1"}, nil, "me")
+	if !ok {
+		t.Fatal("expected a forwarding ssh process")
+	}
+	if len(p.Forwards) != 1 || p.Forwards[0].Port != 5000 || p.Forwards[0].RemoteHost != "127.0.0.1" {
+		t.Fatalf("expected the -o LocalForward only (the rest is the remote command), got %+v", p.Forwards)
+	}
+	wantDest = models.SSHEndpoint{Host: "example.org", Port: 2200, User: "bob", IdentityFile: "/k"}
+	if p.Destination != wantDest {
+		t.Fatalf("destination = %+v", p.Destination)
+	}
+	wantJumps := []models.SSHEndpoint{{Host: "hop1", Port: 22, User: "a"}, {Host: "hop2", Port: 22, User: "me"}}
+	if !reflect.DeepEqual(p.JumpHosts, wantJumps) {
+		t.Fatalf("jump hosts = %+v", p.JumpHosts)
+	}
+
+	for _, args := range [][]string{
+		{"ssh", "host"}, // no forwards
+		{"ssh", "-L", "/tmp/sock:/run/sock", "host"}, // Unix sockets only
+		{"ssh", "-L", "8080:x:80"},                   // no destination
+		{"scp", "-L", "8080:x:80", "host"},
+	} {
+		if _, ok := ParseSSHCommand(args, nil, "me"); ok {
+			t.Fatalf("%q: expected no forwarding ssh process", args)
+		}
+	}
+}
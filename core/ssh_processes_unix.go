@@ -0,0 +1,74 @@
+//go:build !windows
+
+package core
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func listSSHCommandLines() ([]sshCommandLine, DiagnosticsMeta) {
+	if _, err := os.Stat("/proc/self/cmdline"); err == nil {
+		return listSSHCommandLinesProc()
+	}
+	return listSSHCommandLinesPs()
+}
+
+// listSSHCommandLinesProc reads /proc/<pid>/cmdline, which keeps arguments with spaces intact.
+func listSSHCommandLinesProc() ([]sshCommandLine, DiagnosticsMeta) {
+	dirs, err := filepath.Glob("/proc/[0-9]*")
+	if err != nil {
+		return nil, DiagnosticsMeta{Source: "proc", Error: err.Error()}
+	}
+	var out []sshCommandLine
+	for _, dir := range dirs {
+		pid, err := strconv.Atoi(filepath.Base(dir))
+		if err != nil {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, "cmdline"))
+		if err != nil || len(data) == 0 {
+			continue // exited, or not ours to read
+		}
+		args := strings.Split(string(bytes.TrimRight(data, "\x00")), "\x00")
+		if isSSHClient(args[0]) {
+			out = append(out, sshCommandLine{pid: pid, args: args})
+		}
+	}
+	return out, DiagnosticsMeta{Source: "proc"}
+}
+
+// listSSHCommandLinesPs runs ps, whose output loses the quoting of arguments; ssh forward specs
+// and destinations contain no spaces, so splitting on whitespace is good enough.
+func listSSHCommandLinesPs() ([]sshCommandLine, DiagnosticsMeta) {
+	path, err := exec.LookPath("ps")
+	if err != nil {
+		return nil, DiagnosticsMeta{Source: "ps", Error: "ps not found"}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	data, err := exec.CommandContext(ctx, path, "-axww", "-o", "pid=", "-o", "command=").Output()
+	if err != nil {
+		return nil, DiagnosticsMeta{Source: "ps", Error: err.Error()}
+	}
+
+	var out []sshCommandLine
+	for _, line := range scanLines(data) {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || !isSSHClient(fields[1]) {
+			continue
+		}
+		pid, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+		out = append(out, sshCommandLine{pid: pid, args: fields[1:]})
+	}
+	return out, DiagnosticsMeta{Source: "ps"}
+}
@@ -0,0 +1,43 @@
+//go:build windows
+
+package core
+
+import (
+	"context"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+// sshProcessQuery prints "<pid>\t<command line>" for every ssh.exe.
+const sshProcessQuery = "Get-CimInstance Win32_Process -Filter \"Name='ssh.exe'\" | ForEach-Object { \"$($_.ProcessId)`t$($_.CommandLine)\" }"
+
+func listSSHCommandLines() ([]sshCommandLine, DiagnosticsMeta) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	data, err := exec.CommandContext(ctx, "powershell", "-NoProfile", "-NonInteractive", "-Command", sshProcessQuery).Output()
+	if err != nil {
+		return nil, DiagnosticsMeta{Source: "powershell", Error: err.Error()}
+	}
+
+	var out []sshCommandLine
+	for _, line := range strings.Split(string(data), "\n") {
+		pidText, cmdline, ok := strings.Cut(strings.TrimSpace(line), "\t")
+		if !ok {
+			continue
+		}
+		pid, err := strconv.Atoi(pidText)
+		if err != nil {
+			continue
+		}
+		args, err := windows.DecomposeCommandLine(cmdline)
+		if err != nil || len(args) == 0 {
+			continue
+		}
+		out = append(out, sshCommandLine{pid: pid, args: args})
+	}
+	return out, DiagnosticsMeta{Source: "powershell"}
+}
@@ -0,0 +1,114 @@
+package core
+
+import (
+	"bastion/config"
+	"bastion/models"
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+// Bounds for how often a standby session checks whether its SSH chain went idle.
+const (
+	minStandbyCheckInterval = time.Second
+	maxStandbyCheckInterval = 10 * time.Second
+	defaultStandbyIdle      = 5 * time.Minute
+)
+
+// StandbyPolicy controls on-demand SSH chains: the listener stays bound, the chain is built by the
+// first client connection (as the pool always does) and closed again after Idle without connections.
+type StandbyPolicy struct {
+	Enabled bool
+	Idle    time.Duration
+}
+
+// NewStandbyPolicy resolves the mapping's standby settings, falling back to STANDBY_IDLE_SECONDS.
+func NewStandbyPolicy(mapping *models.Mapping) StandbyPolicy {
+	p := StandbyPolicy{Idle: time.Duration(config.Settings.StandbyIdleSeconds) * time.Second}
+	if mapping == nil || !mapping.Standby {
+		return p
+	}
+	p.Enabled = true
+	if mapping.StandbyIdleSeconds > 0 {
+		p.Idle = time.Duration(mapping.StandbyIdleSeconds) * time.Second
+	}
+	if p.Idle <= 0 {
+		p.Idle = defaultStandbyIdle
+	}
+	return p
+}
+
+// ValidateStandbyIdleSeconds checks the per-mapping standby idle override.
+func ValidateStandbyIdleSeconds(seconds int) error {
+	if seconds < 0 {
+		return fmt.Errorf("standby_idle_seconds must be >= 0")
+	}
+	return nil
+}
+
+// connOpened and connClosed count client connections (and the peak) and remember when the session was
+// last used.
+func (s *BaseSession) connOpened() {
+	s.notePeakConns(atomic.AddInt32(&s.activeConns, 1))
+	atomic.StoreInt64(&s.lastActivity, time.Now().UnixNano())
+}
+
+func (s *BaseSession) connClosed() {
+	atomic.StoreInt64(&s.lastActivity, time.Now().UnixNano())
+	atomic.AddInt32(&s.activeConns, -1)
+}
+
+// InStandby reports whether a standby session is waiting for a client before building its SSH chain.
+func (s *BaseSession) InStandby() bool {
+	return s.standby.Enabled && len(s.Bastions) > 0 && !Pool.HasConnection(s.Bastions)
+}
+
+// startStandbyWatcher closes the session's SSH chain once it has been idle for the standby timeout.
+// It is a no-op unless standby is enabled and the mapping goes through at least one bastion.
+func (s *BaseSession) startStandbyWatcher() {
+	if !s.standby.Enabled || len(s.Bastions) == 0 {
+		return
+	}
+	atomic.CompareAndSwapInt64(&s.lastActivity, 0, time.Now().UnixNano())
+
+	interval := s.standby.Idle / 4
+	if interval < minStandbyCheckInterval {
+		interval = minStandbyCheckInterval
+	}
+	if interval > maxStandbyCheckInterval {
+		interval = maxStandbyCheckInterval
+	}
+
+	s.wg.Add(1)
+	s.spawn(func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.stopChan:
+				return
+			case now := <-ticker.C:
+				s.releaseIdleChain(now)
+			}
+		}
+	})
+}
+
+// releaseIdleChain closes the pooled SSH chain if the session has had no connections for the
+// standby timeout. Chains still carrying connections of other mappings are left alone.
+func (s *BaseSession) releaseIdleChain(now time.Time) bool {
+	if atomic.LoadInt32(&s.activeConns) > 0 {
+		return false
+	}
+	idle := now.Sub(time.Unix(0, atomic.LoadInt64(&s.lastActivity)))
+	if idle < s.standby.Idle {
+		return false
+	}
+	if !Pool.ReleaseIdle(s.Bastions) {
+		return false
+	}
+	log.Printf("Standby mapping %s idle for %s: closed SSH chain %s", s.Mapping.Key(), idle.Truncate(time.Second), getBastionChainNames(s.Bastions))
+	return true
+}
@@ -0,0 +1,64 @@
+package core
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"bastion/config"
+	"bastion/models"
+)
+
+func TestNewStandbyPolicy(t *testing.T) {
+	old := config.Settings.StandbyIdleSeconds
+	t.Cleanup(func() { config.Settings.StandbyIdleSeconds = old })
+	config.Settings.StandbyIdleSeconds = 120
+
+	if p := NewStandbyPolicy(&models.Mapping{}); p.Enabled {
+		t.Fatalf("standby must be opt-in: %+v", p)
+	}
+	if p := NewStandbyPolicy(&models.Mapping{Standby: true}); !p.Enabled || p.Idle != 2*time.Minute {
+		t.Fatalf("expected global idle timeout: %+v", p)
+	}
+	if p := NewStandbyPolicy(&models.Mapping{Standby: true, StandbyIdleSeconds: 30}); p.Idle != 30*time.Second {
+		t.Fatalf("expected mapping override: %+v", p)
+	}
+}
+
+func TestStandbySession_ReleasesIdleChain(t *testing.T) {
+	oldPool := Pool
+	t.Cleanup(func() { Pool = oldPool })
+	Pool = NewSSHConnectionPool()
+	client := &fakeSSHClient{}
+	Pool.createChain = func(_ context.Context, _ []models.Bastion) (sshClient, error) { return client, nil }
+
+	mapping := &models.Mapping{ID: "db", Standby: true, StandbyIdleSeconds: 60}
+	s := newBaseSession(mapping, []models.Bastion{{Name: "b1"}})
+	if !s.InStandby() {
+		t.Fatalf("expected standby before the first client")
+	}
+
+	s.connOpened()
+	if _, err := Pool.GetConnection(context.Background(), s.Bastions); err != nil {
+		t.Fatalf("GetConnection: %v", err)
+	}
+	if s.InStandby() {
+		t.Fatalf("expected chain to be up after a client connected")
+	}
+
+	now := time.Now()
+	if s.releaseIdleChain(now.Add(time.Hour)) {
+		t.Fatalf("must not release while a client is connected")
+	}
+	s.connClosed()
+	if s.releaseIdleChain(now.Add(30 * time.Second)) {
+		t.Fatalf("must not release before the idle timeout")
+	}
+	if !s.releaseIdleChain(time.Unix(0, atomic.LoadInt64(&s.lastActivity)).Add(time.Minute)) {
+		t.Fatalf("expected idle chain to be released")
+	}
+	if !client.closed || !s.InStandby() {
+		t.Fatalf("expected chain closed and session back in standby")
+	}
+}
@@ -0,0 +1,211 @@
+package core
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// TargetAccessControl restricts the destinations a SOCKS5/HTTP/mixed proxy mapping may reach. Like
+// IPAccessControl, deny rules win and a non-empty allow list permits only what it matches.
+//
+// A rule is HOST[:PORTS]. HOST is "*" (any), a host name, "*.example.com" (any subdomain), an IP
+// address or a CIDR; IPv6 ones may be bracketed ("[2001:db8::]/32:443"). PORTS is a port, a range
+// ("8000-8999") or "*" (the default). Address rules only match targets given as addresses: a host
+// name is resolved on the far side of the chain, so deny internal names by name, or use an allow list.
+type TargetAccessControl struct {
+	allow []targetRule
+	deny  []targetRule
+}
+
+type targetRule struct {
+	raw    string
+	any    bool       // "*"
+	name   string     // exact host name, lower case
+	suffix string     // ".example.com" for "*.example.com"
+	ipNet  *net.IPNet // address or CIDR
+	portLo int
+	portHi int
+}
+
+// NewTargetAccessControl parses the target rules of a mapping. It returns nil when there are none.
+func NewTargetAccessControl(allowRules, denyRules []string) (*TargetAccessControl, error) {
+	parseAll := func(list []string) ([]targetRule, error) {
+		out := make([]targetRule, 0, len(list))
+		for _, raw := range list {
+			raw = strings.TrimSpace(raw)
+			if raw == "" {
+				continue
+			}
+			rule, err := parseTargetRule(raw)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, rule)
+		}
+		return out, nil
+	}
+
+	acl := &TargetAccessControl{}
+	var err error
+	if acl.allow, err = parseAll(allowRules); err != nil {
+		return nil, fmt.Errorf("invalid target_allow: %w", err)
+	}
+	if acl.deny, err = parseAll(denyRules); err != nil {
+		return nil, fmt.Errorf("invalid target_deny: %w", err)
+	}
+	if len(acl.allow) == 0 && len(acl.deny) == 0 {
+		return nil, nil
+	}
+	return acl, nil
+}
+
+// Allows reports whether host:port may be reached, and the rule that decided it ("" when the
+// target was allowed without an allow list, or denied for matching no allow rule).
+func (a *TargetAccessControl) Allows(host string, port int) (bool, string) {
+	if a == nil {
+		return true, ""
+	}
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	ip := net.ParseIP(host)
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+
+	for _, r := range a.deny {
+		if r.matches(host, ip, port) {
+			return false, r.raw
+		}
+	}
+	if len(a.allow) == 0 {
+		return true, ""
+	}
+	for _, r := range a.allow {
+		if r.matches(host, ip, port) {
+			return true, r.raw
+		}
+	}
+	return false, ""
+}
+
+// allowTarget applies the mapping's target rules to a proxy request and logs refusals.
+func (s *BaseSession) allowTarget(proto, clientAddr, host string, port int) bool {
+	allowed, rule := s.targetACL.Allows(host, port)
+	if allowed {
+		return true
+	}
+	target := net.JoinHostPort(host, strconv.Itoa(port))
+	reason := "not in target_allow"
+	if rule != "" {
+		reason = "target_deny " + rule
+	}
+	s.emitEvent(EventACLReject, proto, clientAddr, target, reason)
+	if rule != "" {
+		log.Printf("[%s] Target %s denied by rule %q for client %s", proto, target, rule, clientAddr)
+	} else {
+		log.Printf("[%s] Target %s not in the allow list for client %s", proto, target, clientAddr)
+	}
+	return false
+}
+
+func (r targetRule) matches(host string, ip net.IP, port int) bool {
+	if port < r.portLo || port > r.portHi {
+		return false
+	}
+	switch {
+	case r.any:
+		return true
+	case r.ipNet != nil:
+		return ip != nil && r.ipNet.Contains(ip)
+	case r.suffix != "":
+		return ip == nil && strings.HasSuffix(host, r.suffix)
+	default:
+		return ip == nil && host == r.name
+	}
+}
+
+func parseTargetRule(raw string) (targetRule, error) {
+	rule := targetRule{raw: raw, portLo: 1, portHi: 65535}
+
+	host, ports := raw, ""
+	switch {
+	case strings.HasPrefix(raw, "["):
+		end := strings.Index(raw, "]")
+		if end < 0 {
+			return rule, fmt.Errorf("invalid rule %q: missing ']'", raw)
+		}
+		host, ports = raw[1:end], raw[end+1:]
+		if bits, rest, ok := strings.Cut(ports, ":"); ok && strings.HasPrefix(bits, "/") {
+			host, ports = host+bits, ":"+rest // "[2001:db8::]/32:443"
+		} else if strings.HasPrefix(ports, "/") {
+			host, ports = host+ports, ""
+		}
+		if ports != "" && !strings.HasPrefix(ports, ":") {
+			return rule, fmt.Errorf("invalid rule %q: expected ':' after ']'", raw)
+		}
+		ports = strings.TrimPrefix(ports, ":")
+	case strings.Count(raw, ":") == 1:
+		host, ports, _ = strings.Cut(raw, ":")
+	}
+	// More than one ':' without brackets is an IPv6 address or CIDR without ports.
+
+	if ports != "" && ports != "*" {
+		lo, hi, isRange := strings.Cut(ports, "-")
+		if !isRange {
+			hi = lo
+		}
+		var errLo, errHi error
+		rule.portLo, errLo = strconv.Atoi(lo)
+		rule.portHi, errHi = strconv.Atoi(hi)
+		if errLo != nil || errHi != nil || rule.portLo < 1 || rule.portHi > 65535 || rule.portLo > rule.portHi {
+			return rule, fmt.Errorf("invalid rule %q: ports must be a port, a range like 8000-8999 or *", raw)
+		}
+	}
+
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	switch {
+	case host == "*":
+		rule.any = true
+	case strings.Contains(host, "/"):
+		_, ipNet, err := parseCIDROrIP(host)
+		if err != nil {
+			return rule, fmt.Errorf("invalid rule %q: %v", raw, err)
+		}
+		rule.ipNet = ipNet
+	case net.ParseIP(host) != nil:
+		_, ipNet, _ := parseCIDROrIP(host)
+		rule.ipNet = ipNet
+	case strings.HasPrefix(host, "*."):
+		rule.suffix = host[1:]
+		if !validTargetName(rule.suffix[1:]) {
+			return rule, fmt.Errorf("invalid rule %q: bad host name", raw)
+		}
+	default:
+		if !validTargetName(host) {
+			return rule, fmt.Errorf("invalid rule %q: bad host name", raw)
+		}
+		rule.name = host
+	}
+	return rule, nil
+}
+
+// validTargetName accepts host name characters; rules are matched, not resolved, so this only
+// catches typos such as stray spaces or wildcards in the middle.
+func validTargetName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, label := range strings.Split(name, ".") {
+		if label == "" {
+			return false
+		}
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,86 @@
+package core
+
+import "testing"
+
+func TestTargetAccessControl_Allows(t *testing.T) {
+	acl, err := NewTargetAccessControl(
+		[]string{"*.example.com:443", "api.internal:8000-8999", "10.0.0.0/8", "[2001:db8::]/32:443"},
+		[]string{"10.0.0.5", "admin.example.com", "*:22"},
+	)
+	if err != nil {
+		t.Fatalf("NewTargetAccessControl: %v", err)
+	}
+
+	cases := []struct {
+		host    string
+		port    int
+		allowed bool
+		rule    string
+	}{
+		{"www.example.com", 443, true, "*.example.com:443"},
+		{"WWW.Example.COM.", 443, true, "*.example.com:443"},
+		{"www.example.com", 80, false, ""},
+		{"example.com", 443, false, ""},
+		{"admin.example.com", 443, false, "admin.example.com"},
+		{"api.internal", 8080, true, "api.internal:8000-8999"},
+		{"api.internal", 9000, false, ""},
+		{"10.1.2.3", 80, true, "10.0.0.0/8"},
+		{"::ffff:10.1.2.3", 80, true, "10.0.0.0/8"},
+		{"10.0.0.5", 80, false, "10.0.0.5"},
+		{"10.1.2.3", 22, false, "*:22"},
+		{"192.168.1.1", 80, false, ""},
+		{"2001:db8::1", 443, true, "[2001:db8::]/32:443"},
+		{"2001:db8::1", 80, false, ""},
+	}
+	for _, tc := range cases {
+		allowed, rule := acl.Allows(tc.host, tc.port)
+		if allowed != tc.allowed || rule != tc.rule {
+			t.Errorf("Allows(%q, %d) = %v, %q; want %v, %q", tc.host, tc.port, allowed, rule, tc.allowed, tc.rule)
+		}
+	}
+}
+
+func TestTargetAccessControl_DenyOnly(t *testing.T) {
+	acl, err := NewTargetAccessControl(nil, []string{"169.254.169.254", "*.corp.local", "127.0.0.0/8", "::1"})
+	if err != nil {
+		t.Fatalf("NewTargetAccessControl: %v", err)
+	}
+	for _, host := range []string{"169.254.169.254", "db.corp.local", "127.0.0.1", "::1"} {
+		if allowed, _ := acl.Allows(host, 80); allowed {
+			t.Errorf("expected deny for %s", host)
+		}
+	}
+	for _, host := range []string{"example.com", "8.8.8.8", "corp.local"} {
+		if allowed, _ := acl.Allows(host, 80); !allowed {
+			t.Errorf("expected allow for %s", host)
+		}
+	}
+}
+
+func TestTargetAccessControl_NilAllowsAll(t *testing.T) {
+	acl, err := NewTargetAccessControl([]string{" "}, nil)
+	if err != nil || acl != nil {
+		t.Fatalf("expected nil acl for empty rules, got %v, %v", acl, err)
+	}
+	if allowed, _ := acl.Allows("anything", 1); !allowed {
+		t.Fatalf("expected allow when acl is nil")
+	}
+}
+
+func TestNewTargetAccessControl_Invalid(t *testing.T) {
+	for _, rule := range []string{
+		"host:0",
+		"host:70000",
+		"host:9000-8000",
+		"host:http",
+		"bad host",
+		"a*.example.com",
+		"10.0.0.0/33",
+		"[::1",
+		"[::1]443",
+	} {
+		if _, err := NewTargetAccessControl([]string{rule}, nil); err == nil {
+			t.Errorf("expected error for %q", rule)
+		}
+	}
+}
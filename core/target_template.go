@@ -0,0 +1,97 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// Target templates let a tcp mapping's remote host and port be resolved when it starts, so one
+// mapping definition can be promoted between environments without touching its immutable fields.
+// {{env "DB_HOST"}} reads an environment variable and {{setting "db_host"}} a stored variable (see
+// TargetVariableLookup); both take an optional default, as in {{env "DB_PORT" "5432"}}.
+
+// TargetVariableLookup returns a stored variable; ok is false when it is not set.
+type TargetVariableLookup func(name string) (value string, ok bool, err error)
+
+// IsTargetTemplate reports whether s contains template actions rather than a literal value.
+func IsTargetTemplate(s string) bool {
+	return strings.Contains(s, "{{")
+}
+
+// ValidateTargetTemplate checks the syntax of a target template of field.
+func ValidateTargetTemplate(field, text string) error {
+	_, err := parseTargetTemplate(field, text, nil)
+	return err
+}
+
+// ExpandTargetTemplate resolves the target template text of field; a literal value is returned as is.
+func ExpandTargetTemplate(field, text string, lookup TargetVariableLookup) (string, error) {
+	if !IsTargetTemplate(text) {
+		return text, nil
+	}
+	tmpl, err := parseTargetTemplate(field, text, lookup)
+	if err != nil {
+		return "", err
+	}
+	var out strings.Builder
+	if err := tmpl.Execute(&out, nil); err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", field, err)
+	}
+	value := strings.TrimSpace(out.String())
+	if value == "" {
+		return "", fmt.Errorf("failed to resolve %s: %q resolved to an empty value", field, text)
+	}
+	return value, nil
+}
+
+// ExpandTargetPort resolves a port template and checks the result is a valid port.
+func ExpandTargetPort(field, text string, lookup TargetVariableLookup) (int, error) {
+	value, err := ExpandTargetTemplate(field, text, lookup)
+	if err != nil {
+		return 0, err
+	}
+	port, err := strconv.Atoi(value)
+	if err != nil || port < 1 || port > 65535 {
+		return 0, fmt.Errorf("invalid %s: %q resolved to %q, not a port (1-65535)", field, text, value)
+	}
+	return port, nil
+}
+
+func parseTargetTemplate(field, text string, lookup TargetVariableLookup) (*template.Template, error) {
+	funcs := template.FuncMap{
+		"env": func(name string, def ...string) (string, error) {
+			if v, ok := os.LookupEnv(name); ok && v != "" {
+				return v, nil
+			}
+			return targetDefault("environment variable", name, def)
+		},
+		"setting": func(name string, def ...string) (string, error) {
+			if lookup == nil {
+				return "", fmt.Errorf("setting %q is not available", name)
+			}
+			v, ok, err := lookup(name)
+			if err != nil {
+				return "", err
+			}
+			if ok && v != "" {
+				return v, nil
+			}
+			return targetDefault("setting", name, def)
+		},
+	}
+	tmpl, err := template.New(field).Funcs(funcs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s template: %w", field, err)
+	}
+	return tmpl, nil
+}
+
+func targetDefault(kind, name string, def []string) (string, error) {
+	if len(def) > 0 {
+		return def[0], nil
+	}
+	return "", fmt.Errorf("%s %q is not set", kind, name)
+}
@@ -0,0 +1,63 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExpandTargetTemplate(t *testing.T) {
+	t.Setenv("BASTION_TEST_DB_HOST", "db.staging.internal")
+	vars := map[string]string{"db_port": "5433"}
+	lookup := func(name string) (string, bool, error) {
+		v, ok := vars[name]
+		return v, ok, nil
+	}
+
+	cases := []struct {
+		text, want string
+	}{
+		{"db.internal", "db.internal"},
+		{`{{env "BASTION_TEST_DB_HOST"}}`, "db.staging.internal"},
+		{`{{env "BASTION_TEST_UNSET" "localhost"}}`, "localhost"},
+		{`{{setting "db_port"}}`, "5433"},
+		{`{{setting "db_user" "app"}}`, "app"},
+		{`db-{{env "BASTION_TEST_DB_HOST"}}`, "db-db.staging.internal"},
+	}
+	for _, tc := range cases {
+		got, err := ExpandTargetTemplate("remote_host", tc.text, lookup)
+		if err != nil || got != tc.want {
+			t.Fatalf("ExpandTargetTemplate(%q) = %q, %v; want %q", tc.text, got, err, tc.want)
+		}
+	}
+
+	if _, err := ExpandTargetTemplate("remote_host", `{{env "BASTION_TEST_UNSET"}}`, lookup); err == nil || !strings.Contains(err.Error(), "BASTION_TEST_UNSET") {
+		t.Fatalf("expected unset variable error, got %v", err)
+	}
+	if _, err := ExpandTargetTemplate("remote_host", `{{setting "missing"}}`, lookup); err == nil {
+		t.Fatal("expected unset setting error")
+	}
+}
+
+func TestExpandTargetPort(t *testing.T) {
+	t.Setenv("BASTION_TEST_DB_PORT", "5432")
+
+	if port, err := ExpandTargetPort("remote_port_template", `{{env "BASTION_TEST_DB_PORT"}}`, nil); err != nil || port != 5432 {
+		t.Fatalf("expected 5432, got %d %v", port, err)
+	}
+	for _, text := range []string{`{{env "BASTION_TEST_UNSET" "http"}}`, `{{env "BASTION_TEST_UNSET" "70000"}}`} {
+		if _, err := ExpandTargetPort("remote_port_template", text, nil); err == nil {
+			t.Fatalf("expected %s rejected", text)
+		}
+	}
+}
+
+func TestValidateTargetTemplate(t *testing.T) {
+	if err := ValidateTargetTemplate("remote_host", `{{env "DB_HOST"}}`); err != nil {
+		t.Fatalf("ValidateTargetTemplate: %v", err)
+	}
+	for _, text := range []string{`{{env "DB_HOST"`, `{{lookup "DB_HOST"}}`} {
+		if err := ValidateTargetTemplate("remote_host", text); err == nil {
+			t.Fatalf("expected %s rejected", text)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package core
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// throughputWindow is the longest rolling rate; one sample of the byte counters is kept per second.
+const throughputWindow = 60
+
+// throughputInterval is how often a session samples its byte counters.
+const throughputInterval = time.Second
+
+// ThroughputStats are the recent transfer rates of a session in bytes per second, averaged over the
+// last 1, 10 and 60 seconds, and the peaks since it started: the highest 1s rates and the most
+// concurrent connections.
+type ThroughputStats struct {
+	UpBps1s     int64 `json:"up_bps_1s"`
+	UpBps10s    int64 `json:"up_bps_10s"`
+	UpBps60s    int64 `json:"up_bps_60s"`
+	DownBps1s   int64 `json:"down_bps_1s"`
+	DownBps10s  int64 `json:"down_bps_10s"`
+	DownBps60s  int64 `json:"down_bps_60s"`
+	PeakUpBps   int64 `json:"peak_up_bps"`
+	PeakDownBps int64 `json:"peak_down_bps"`
+	PeakConns   int32 `json:"peak_connections"`
+}
+
+// throughputSample is the session's byte counters at one point in time.
+type throughputSample struct {
+	at   time.Time
+	up   int64
+	down int64
+}
+
+// throughputMeter derives rolling rates from samples of the session's lifetime byte counters. The
+// forwarding goroutines only add to those atomic counters; the meter reads them once per interval,
+// so measuring adds nothing to the data path.
+type throughputMeter struct {
+	mu          sync.Mutex
+	samples     [throughputWindow + 1]throughputSample // ring buffer, oldest overwritten
+	count       int                                    // samples recorded (capped at len(samples))
+	next        int                                    // ring index of the next sample
+	peakUpBps   int64
+	peakDownBps int64
+}
+
+// record adds a sample of the cumulative counters and updates the peak 1s rates.
+func (m *throughputMeter) record(at time.Time, up, down int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.samples[m.next] = throughputSample{at: at, up: up, down: down}
+	m.next = (m.next + 1) % len(m.samples)
+	if m.count < len(m.samples) {
+		m.count++
+	}
+
+	upBps, downBps := m.rateLocked(1)
+	if upBps > m.peakUpBps {
+		m.peakUpBps = upBps
+	}
+	if downBps > m.peakDownBps {
+		m.peakDownBps = downBps
+	}
+}
+
+// rateLocked returns the rates between the latest sample and the one n samples before it (or the
+// oldest one kept, while the session is younger than that).
+func (m *throughputMeter) rateLocked(n int) (upBps, downBps int64) {
+	if n > m.count-1 {
+		n = m.count - 1
+	}
+	if n <= 0 {
+		return 0, 0
+	}
+	size := len(m.samples)
+	latest := m.samples[(m.next-1+size)%size]
+	earlier := m.samples[(m.next-1-n+2*size)%size]
+	elapsed := latest.at.Sub(earlier.at).Seconds()
+	if elapsed <= 0 {
+		return 0, 0
+	}
+	return int64(float64(latest.up-earlier.up) / elapsed), int64(float64(latest.down-earlier.down) / elapsed)
+}
+
+// stats returns the rolling rates and peaks (PeakConns is filled in by the session).
+func (m *throughputMeter) stats() ThroughputStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var t ThroughputStats
+	t.UpBps1s, t.DownBps1s = m.rateLocked(1)
+	t.UpBps10s, t.DownBps10s = m.rateLocked(10)
+	t.UpBps60s, t.DownBps60s = m.rateLocked(throughputWindow)
+	t.PeakUpBps, t.PeakDownBps = m.peakUpBps, m.peakDownBps
+	return t
+}
+
+// startThroughputSampler samples the session's byte counters every second until it stops.
+func (s *BaseSession) startThroughputSampler() {
+	s.throughput.record(time.Now(), atomic.LoadInt64(&s.bytesUp), atomic.LoadInt64(&s.bytesDown))
+
+	s.wg.Add(1)
+	s.spawn(func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(throughputInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.stopChan:
+				return
+			case now := <-ticker.C:
+				s.throughput.record(now, atomic.LoadInt64(&s.bytesUp), atomic.LoadInt64(&s.bytesDown))
+			}
+		}
+	})
+}
+
+// notePeakConns raises the session's peak connection count to conns if it is higher.
+func (s *BaseSession) notePeakConns(conns int32) {
+	for {
+		peak := atomic.LoadInt32(&s.peakConns)
+		if conns <= peak || atomic.CompareAndSwapInt32(&s.peakConns, peak, conns) {
+			return
+		}
+	}
+}
+
+// throughputStats returns the session's rolling rates and peaks.
+func (s *BaseSession) throughputStats() ThroughputStats {
+	t := s.throughput.stats()
+	t.PeakConns = atomic.LoadInt32(&s.peakConns)
+	return t
+}
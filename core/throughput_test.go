@@ -0,0 +1,66 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+func TestThroughputMeter_RollingRates(t *testing.T) {
+	var m throughputMeter
+	start := time.Unix(1000, 0)
+
+	if got := m.stats(); got != (ThroughputStats{}) {
+		t.Fatalf("empty meter: %+v", got)
+	}
+
+	// 100 B/s up for 5s, then a 1000 B/s burst, then idle.
+	var up, down int64
+	m.record(start, 0, 0)
+	for i := 1; i <= 5; i++ {
+		up += 100
+		m.record(start.Add(time.Duration(i)*time.Second), up, down)
+	}
+	up, down = up+1000, down+2000
+	m.record(start.Add(6*time.Second), up, down)
+
+	got := m.stats()
+	if got.UpBps1s != 1000 || got.DownBps1s != 2000 {
+		t.Fatalf("1s rates = %d/%d, want 1000/2000", got.UpBps1s, got.DownBps1s)
+	}
+	// Younger than 10s: the 10s and 60s rates average over the 6s recorded so far.
+	if got.UpBps10s != 250 || got.UpBps60s != 250 || got.DownBps10s != 333 {
+		t.Fatalf("window rates = %+v", got)
+	}
+
+	for i := 7; i <= 20; i++ {
+		m.record(start.Add(time.Duration(i)*time.Second), up, down)
+	}
+	got = m.stats()
+	if got.UpBps1s != 0 || got.UpBps10s != 0 || got.UpBps60s != 75 {
+		t.Fatalf("after idling: %+v", got)
+	}
+	if got.PeakUpBps != 1000 || got.PeakDownBps != 2000 {
+		t.Fatalf("peaks = %d/%d, want 1000/2000", got.PeakUpBps, got.PeakDownBps)
+	}
+
+	// The 60s window slides once the ring is full.
+	for i := 21; i <= 90; i++ {
+		m.record(start.Add(time.Duration(i)*time.Second), up, down)
+	}
+	if got = m.stats(); got.UpBps60s != 0 || got.PeakUpBps != 1000 {
+		t.Fatalf("after 90s: %+v", got)
+	}
+}
+
+func TestBaseSession_PeakConns(t *testing.T) {
+	var s BaseSession
+	s.connOpened()
+	s.connOpened()
+	s.connClosed()
+	s.connOpened()
+	s.connClosed()
+	s.connClosed()
+	if got := s.throughputStats().PeakConns; got != 2 {
+		t.Fatalf("peak connections = %d, want 2", got)
+	}
+}
@@ -0,0 +1,229 @@
+package core
+
+import (
+	"bastion/config"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/cryptobyte"
+)
+
+// maxClientHelloBytes bounds what a tlsSniffer buffers; ClientHellos are a few hundred bytes to a
+// few KiB (post-quantum key shares), anything beyond this is not worth holding on to.
+const maxClientHelloBytes = 64 << 10
+
+// TLSClientHello is what the ClientHello of a TLS connection reveals without decrypting it.
+type TLSClientHello struct {
+	ServerName string   // SNI; the public name when the client uses Encrypted Client Hello
+	ALPN       []string // offered application protocols, in the client's order
+	Version    uint16   // highest offered version (supported_versions, else the legacy version)
+}
+
+// VersionName returns the version as "TLS 1.3" (or hex when unknown).
+func (h *TLSClientHello) VersionName() string {
+	switch h.Version {
+	case 0x0300:
+		return "SSL 3.0"
+	case 0x0301:
+		return "TLS 1.0"
+	case 0x0302:
+		return "TLS 1.1"
+	case 0x0303:
+		return "TLS 1.2"
+	case 0x0304:
+		return "TLS 1.3"
+	}
+	return fmt.Sprintf("0x%04x", h.Version)
+}
+
+// tlsSniffer reassembles the first handshake message of a client stream from its TLS records and
+// parses it once it is complete. It gives up on the first byte that is not a TLS handshake record,
+// so non-TLS streams cost a single check.
+type tlsSniffer struct {
+	buf  []byte // unconsumed record bytes
+	hs   []byte // handshake bytes reassembled from the records so far
+	done bool
+
+	hello *TLSClientHello // set when done and the stream started with a ClientHello
+}
+
+// Feed consumes the next client bytes and reports whether sniffing is done.
+func (t *tlsSniffer) Feed(data []byte) bool {
+	if t.done {
+		return true
+	}
+	t.buf = append(t.buf, data...)
+	for len(t.buf) >= 5 {
+		// Record header: content type 22 (handshake), version 3.x, length
+		if t.buf[0] != 0x16 || t.buf[1] != 3 {
+			return t.finish(nil)
+		}
+		n := int(t.buf[3])<<8 | int(t.buf[4])
+		if len(t.buf) < 5+n {
+			break
+		}
+		t.hs = append(t.hs, t.buf[5:5+n]...)
+		t.buf = t.buf[5+n:]
+
+		if len(t.hs) >= 4 {
+			if t.hs[0] != 1 { // not a ClientHello
+				return t.finish(nil)
+			}
+			size := 4 + (int(t.hs[1])<<16 | int(t.hs[2])<<8 | int(t.hs[3]))
+			if size > maxClientHelloBytes {
+				return t.finish(nil)
+			}
+			if len(t.hs) >= size {
+				return t.finish(parseClientHello(t.hs[4:size]))
+			}
+		}
+	}
+	if len(t.buf)+len(t.hs) > maxClientHelloBytes {
+		return t.finish(nil)
+	}
+	return false
+}
+
+func (t *tlsSniffer) finish(hello *TLSClientHello) bool {
+	t.hello = hello
+	t.buf, t.hs = nil, nil
+	t.done = true
+	return true
+}
+
+// parseClientHello parses the body of a ClientHello handshake message, or returns nil when it is
+// malformed.
+func parseClientHello(body []byte) *TLSClientHello {
+	s := cryptobyte.String(body)
+	hello := &TLSClientHello{}
+	var sessionID, cipherSuites, compression cryptobyte.String
+	if !s.ReadUint16(&hello.Version) || !s.Skip(32) || // random
+		!s.ReadUint8LengthPrefixed(&sessionID) || !s.ReadUint16LengthPrefixed(&cipherSuites) ||
+		!s.ReadUint8LengthPrefixed(&compression) {
+		return nil
+	}
+	if s.Empty() {
+		return hello // no extensions
+	}
+	var extensions cryptobyte.String
+	if !s.ReadUint16LengthPrefixed(&extensions) {
+		return nil
+	}
+	for !extensions.Empty() {
+		var extType uint16
+		var ext cryptobyte.String
+		if !extensions.ReadUint16(&extType) || !extensions.ReadUint16LengthPrefixed(&ext) {
+			return nil
+		}
+		switch extType {
+		case 0: // server_name
+			var names cryptobyte.String
+			if !ext.ReadUint16LengthPrefixed(&names) {
+				return nil
+			}
+			for !names.Empty() {
+				var nameType uint8
+				var name cryptobyte.String
+				if !names.ReadUint8(&nameType) || !names.ReadUint16LengthPrefixed(&name) {
+					return nil
+				}
+				if nameType == 0 && hello.ServerName == "" {
+					hello.ServerName = printable(strings.TrimSuffix(string(name), "."))
+				}
+			}
+		case 16: // application_layer_protocol_negotiation
+			var protos cryptobyte.String
+			if !ext.ReadUint16LengthPrefixed(&protos) {
+				return nil
+			}
+			for !protos.Empty() {
+				var proto cryptobyte.String
+				if !protos.ReadUint8LengthPrefixed(&proto) {
+					return nil
+				}
+				hello.ALPN = append(hello.ALPN, printable(string(proto)))
+			}
+		case 43: // supported_versions
+			var versions cryptobyte.String
+			if !ext.ReadUint8LengthPrefixed(&versions) {
+				return nil
+			}
+			for !versions.Empty() {
+				var v uint16
+				if !versions.ReadUint16(&v) {
+					return nil
+				}
+				if v&0x0f0f != 0x0a0a && v > hello.Version { // skip GREASE values
+					hello.Version = v
+				}
+			}
+		}
+	}
+	return hello
+}
+
+// printable returns s, quoted when it has bytes other than printable ASCII (the ClientHello is
+// whatever the client sent, and ends up in log lines).
+func printable(s string) string {
+	for i := 0; i < len(s); i++ {
+		if s[i] < 0x21 || s[i] > 0x7e {
+			return strconv.Quote(s)
+		}
+	}
+	return s
+}
+
+// tlsSniffConn passes what the client sends through a tlsSniffer on its way to the target. Once the
+// sniffer is done, unwrapTCPConn sees through it so the rest of the stream can be spliced.
+type tlsSniffConn struct {
+	net.Conn
+	sniffer tlsSniffer
+	onHello func(*TLSClientHello)
+}
+
+// Sniffed reports whether the sniffer is done with the stream.
+func (c *tlsSniffConn) Sniffed() bool {
+	return c.sniffer.done
+}
+
+func (c *tlsSniffConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if n > 0 && !c.Sniffed() && c.sniffer.Feed(p[:n]) && c.sniffer.hello != nil {
+		c.onHello(c.sniffer.hello)
+	}
+	return n, err
+}
+
+func (c *tlsSniffConn) CloseWrite() error {
+	if cw, ok := c.Conn.(interface{ CloseWrite() error }); ok {
+		return cw.CloseWrite()
+	}
+	return nil
+}
+
+// sniffTLS wraps a client connection forwarded as an opaque stream (a tcp mapping, SOCKS5 or HTTP
+// CONNECT) to log the ClientHello of TLS traffic and export it as a tls_client_hello event. It
+// returns conn unchanged when TLS_SNI_LOG is off.
+func (s *BaseSession) sniffTLS(conn net.Conn, proto, clientAddr, target string) net.Conn {
+	if !config.Settings.TLSSNILog {
+		return conn
+	}
+	mappingID := s.Mapping.Key()
+	return &tlsSniffConn{Conn: conn, onHello: func(hello *TLSClientHello) {
+		log.Printf("[%s] TLS ClientHello: client=%s, target=%s, sni=%s, alpn=%s, version=%s, mapping_id=%s",
+			proto, clientAddr, target, hello.ServerName, strings.Join(hello.ALPN, ","), hello.VersionName(), mappingID)
+		EventExport.Emit(SecurityEvent{
+			Type:       EventTLSClientHello,
+			MappingID:  mappingID,
+			Protocol:   proto,
+			ClientAddr: clientAddr,
+			Target:     target,
+			SNI:        hello.ServerName,
+			ALPN:       hello.ALPN,
+			TLSVersion: hello.VersionName(),
+		})
+	}}
+}
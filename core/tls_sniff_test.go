@@ -0,0 +1,149 @@
+package core
+
+import (
+	"bytes"
+	"crypto/tls"
+	"io"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// clientHelloRecord returns the first TLS record crypto/tls sends for config.
+func clientHelloRecord(t *testing.T, config *tls.Config) []byte {
+	t.Helper()
+	client, server := net.Pipe()
+	defer server.Close()
+	go func() {
+		_ = tls.Client(client, config).Handshake()
+		client.Close()
+	}()
+
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(server, header); err != nil {
+		t.Fatalf("read record header: %v", err)
+	}
+	record := make([]byte, 5+(int(header[3])<<8|int(header[4])))
+	copy(record, header)
+	if _, err := io.ReadFull(server, record[5:]); err != nil {
+		t.Fatalf("read record: %v", err)
+	}
+	return record
+}
+
+func TestTLSSnifferClientHello(t *testing.T) {
+	record := clientHelloRecord(t, &tls.Config{ServerName: "db.example.com", NextProtos: []string{"h2", "http/1.1"}})
+
+	var sniffer tlsSniffer
+	for i := range record {
+		done := sniffer.Feed(record[i : i+1])
+		if done != (i == len(record)-1) {
+			t.Fatalf("done = %v after %d of %d bytes", done, i+1, len(record))
+		}
+	}
+	want := &TLSClientHello{ServerName: "db.example.com", ALPN: []string{"h2", "http/1.1"}, Version: tls.VersionTLS13}
+	if !reflect.DeepEqual(sniffer.hello, want) {
+		t.Fatalf("hello = %+v, want %+v", sniffer.hello, want)
+	}
+	if got := sniffer.hello.VersionName(); got != "TLS 1.3" {
+		t.Fatalf("VersionName = %q", got)
+	}
+}
+
+func TestTLSSnifferFragmentedRecords(t *testing.T) {
+	record := clientHelloRecord(t, &tls.Config{ServerName: "example.org", MaxVersion: tls.VersionTLS12})
+
+	// Split the handshake message over two records, as some clients do for large hellos.
+	body := record[5:]
+	var stream []byte
+	for _, part := range [][]byte{body[:3], body[3:]} {
+		stream = append(stream, 0x16, 3, 1, byte(len(part)>>8), byte(len(part)))
+		stream = append(stream, part...)
+	}
+
+	var sniffer tlsSniffer
+	if !sniffer.Feed(stream) {
+		t.Fatalf("sniffer not done")
+	}
+	if sniffer.hello == nil || sniffer.hello.ServerName != "example.org" || sniffer.hello.Version != tls.VersionTLS12 || sniffer.hello.ALPN != nil {
+		t.Fatalf("hello = %+v", sniffer.hello)
+	}
+}
+
+func TestTLSSnifferNotTLS(t *testing.T) {
+	for _, data := range [][]byte{
+		[]byte("GET / HTTP/1.1\r\n"),
+		[]byte("SSH-2.0-OpenSSH_9.6\r\n"),
+		{0x16, 3, 1, 0, 4, 2, 0, 0, 0}, // a handshake record, but a ServerHello
+	} {
+		var sniffer tlsSniffer
+		if !sniffer.Feed(data) || sniffer.hello != nil {
+			t.Fatalf("Feed(%q): done=%v hello=%+v, want done without hello", data, sniffer.done, sniffer.hello)
+		}
+	}
+
+	var sniffer tlsSniffer
+	if sniffer.Feed([]byte{0x16, 3}) {
+		t.Fatalf("done after a partial record header")
+	}
+}
+
+func TestPrintable(t *testing.T) {
+	if got := printable("example.com"); got != "example.com" {
+		t.Fatalf("printable = %q", got)
+	}
+	if got := printable("evil\nhost"); got != `"evil\nhost"` {
+		t.Fatalf("printable = %q", got)
+	}
+}
+
+func TestTLSSniffConnHandsOffToSplice(t *testing.T) {
+	clientSide, srcConn := tcpPair(t)
+	dstConn, remoteSide := tcpPair(t)
+
+	record := clientHelloRecord(t, &tls.Config{ServerName: "api.example.com"})
+	payload := append(append([]byte{}, record...), bytes.Repeat([]byte("x"), 1<<20)...)
+
+	hellos := make(chan *TLSClientHello, 1)
+	src := &tlsSniffConn{Conn: NewDeadlineConn(srcConn, time.Minute, time.Minute), onHello: func(h *TLSClientHello) { hellos <- h }}
+	if tcp, _, _ := unwrapTCPConn(src); tcp != nil {
+		t.Fatalf("a sniffing conn must not unwrap before the ClientHello")
+	}
+	if tcp, _, _ := unwrapTCPConn(writeSide(src)); tcp == nil {
+		t.Fatalf("the write side of a sniffing conn must unwrap")
+	}
+
+	s := &BaseSession{}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.copyFast(dstConn, src, "request", "test")
+	}()
+	go func() {
+		_, _ = clientSide.Write(payload[:len(record)])
+		time.Sleep(10 * time.Millisecond)
+		_, _ = clientSide.Write(payload[len(record):])
+		_ = clientSide.CloseWrite()
+	}()
+
+	got, err := io.ReadAll(remoteSide)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	<-done
+	if !bytes.Equal(got, payload) {
+		t.Fatalf("payload mismatch: got %d bytes, want %d", len(got), len(payload))
+	}
+	select {
+	case h := <-hellos:
+		if h.ServerName != "api.example.com" {
+			t.Fatalf("sni = %q", h.ServerName)
+		}
+	default:
+		t.Fatalf("no ClientHello reported")
+	}
+	if !src.Sniffed() {
+		t.Fatalf("sniffer not done")
+	}
+}
@@ -0,0 +1,146 @@
+package core
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+// upstreamProxyHandshakeTimeout bounds the CONNECT / SOCKS5 negotiation with the upstream proxy.
+const upstreamProxyHandshakeTimeout = 15 * time.Second
+
+// ParseUpstreamProxy validates an upstream proxy URL configured on a mapping.
+// An empty value returns (nil, nil), meaning no upstream proxy.
+func ParseUpstreamProxy(raw string) (*url.URL, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, nil
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid upstream_proxy: %q", raw)
+	}
+
+	switch strings.ToLower(u.Scheme) {
+	case "http", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("upstream_proxy must start with http:// or socks5(h)://")
+	}
+
+	if u.Port() == "" {
+		return nil, fmt.Errorf("upstream_proxy must include a port")
+	}
+
+	return u, nil
+}
+
+// RedactUpstreamProxy masks the password of an upstream proxy URL, as API reads show it.
+func RedactUpstreamProxy(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.User == nil {
+		return raw
+	}
+	if _, ok := u.User.Password(); !ok {
+		return raw
+	}
+	return u.Redacted()
+}
+
+// KeepUpstreamProxyPassword returns current when req is current as RedactUpstreamProxy shows it:
+// a read sent back unchanged keeps the password it does not show. Any other req is returned as is.
+func KeepUpstreamProxyPassword(req, current string) string {
+	if req != current && req == RedactUpstreamProxy(current) {
+		return current
+	}
+	return req
+}
+
+// dialFunc matches net.Dial and SSHConnectionPool.Dial (after binding the chain).
+type dialFunc func(network, addr string) (net.Conn, error)
+
+// Dial implements proxy.Dialer so a dialFunc can be used as the forward dialer of x/net/proxy.
+func (f dialFunc) Dial(network, addr string) (net.Conn, error) {
+	return f(network, addr)
+}
+
+// dialViaUpstreamProxy reaches target through the upstream proxy. The proxy itself is dialed via forward,
+// which is either a direct dialer or the SSH chain, so the proxy hop always sits after the chain.
+func dialViaUpstreamProxy(forward dialFunc, proxyURL *url.URL, target string) (net.Conn, error) {
+	switch strings.ToLower(proxyURL.Scheme) {
+	case "socks5", "socks5h":
+		pu := *proxyURL
+		pu.Scheme = "socks5"
+		dialer, err := proxy.FromURL(&pu, forward)
+		if err != nil {
+			return nil, fmt.Errorf("upstream proxy: %w", err)
+		}
+		conn, err := dialer.Dial("tcp", target)
+		if err != nil {
+			return nil, fmt.Errorf("upstream proxy %s: %w", proxyURL.Host, err)
+		}
+		return conn, nil
+	case "http":
+		return dialHTTPConnect(forward, proxyURL, target)
+	default:
+		return nil, fmt.Errorf("unsupported upstream proxy scheme: %s", proxyURL.Scheme)
+	}
+}
+
+// dialHTTPConnect opens a tunnel to target using an HTTP CONNECT request.
+func dialHTTPConnect(forward dialFunc, proxyURL *url.URL, target string) (net.Conn, error) {
+	conn, err := forward("tcp", proxyURL.Host)
+	if err != nil {
+		return nil, fmt.Errorf("upstream proxy %s: %w", proxyURL.Host, err)
+	}
+
+	_ = conn.SetDeadline(time.Now().Add(upstreamProxyHandshakeTimeout))
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: target},
+		Host:   target,
+		Header: make(http.Header),
+	}
+	if proxyURL.User != nil {
+		password, _ := proxyURL.User.Password()
+		cred := base64.StdEncoding.EncodeToString([]byte(proxyURL.User.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+cred)
+	}
+
+	if err := req.Write(conn); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("upstream proxy %s: write CONNECT: %w", proxyURL.Host, err)
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("upstream proxy %s: read CONNECT response: %w", proxyURL.Host, err)
+	}
+	_ = resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		_ = conn.Close()
+		return nil, fmt.Errorf("upstream proxy %s: CONNECT %s returned %s", proxyURL.Host, target, resp.Status)
+	}
+
+	_ = conn.SetDeadline(time.Time{})
+
+	// The proxy may have sent tunneled bytes right after the response headers.
+	if n := br.Buffered(); n > 0 {
+		buffered, _ := br.Peek(n)
+		prefix := make([]byte, n)
+		copy(prefix, buffered)
+		return newPrefixedConn(conn, prefix), nil
+	}
+	return conn, nil
+}
@@ -0,0 +1,216 @@
+package database
+
+import (
+	"bastion/models"
+	"database/sql/driver"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	sqlitedriver "github.com/glebarez/go-sqlite"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestParseDatabaseURL(t *testing.T) {
+	tests := []struct {
+		raw, backend, dsn string
+	}{
+		{"bastion.db", BackendSQLite, "bastion.db"},
+		{`C:\data\bastion.db`, BackendSQLite, `C:\data\bastion.db`},
+		{"file:bastion.db?cache=shared", BackendSQLite, "file:bastion.db?cache=shared"},
+		{"sqlite:///var/lib/bastion.db", BackendSQLite, "/var/lib/bastion.db"},
+		{"postgres://u:p@db:5432/bastion?sslmode=disable", BackendPostgres, "postgres://u:p@db:5432/bastion?sslmode=disable"},
+		{"postgresql://db/bastion", BackendPostgres, "postgresql://db/bastion"},
+		{"mysql://u:p@db/bastion", BackendMySQL, "u:p@tcp(db:3306)/bastion?charset=utf8mb4&parseTime=true"},
+		{"mysql://db:3307/bastion?parseTime=false", BackendMySQL, "tcp(db:3307)/bastion?charset=utf8mb4&parseTime=false"},
+	}
+	for _, tt := range tests {
+		backend, dsn, err := ParseDatabaseURL(tt.raw)
+		if err != nil || backend != tt.backend || dsn != tt.dsn {
+			t.Errorf("ParseDatabaseURL(%q) = %q, %q, %v; want %q, %q", tt.raw, backend, dsn, err, tt.backend, tt.dsn)
+		}
+	}
+
+	for _, raw := range []string{"mongodb://db/bastion", "mysql://db", "mysql:///bastion", "mysql://db/a/b", "mysql://a%3Ab:p@db/bastion"} {
+		if _, _, err := ParseDatabaseURL(raw); err == nil {
+			t.Errorf("ParseDatabaseURL(%q) succeeded, want an error", raw)
+		}
+	}
+}
+
+func TestMySQLDSN_Password(t *testing.T) {
+	// The driver splits user:password at the first ':' and takes the last '@' before the address,
+	// so a decoded password keeps its ':', '@' and '/'.
+	dsn, err := mysqlDSN("mysql://bastion:p%40ss%2Fw%3Ard@db/bastion?tls=skip-verify")
+	if err != nil {
+		t.Fatalf("mysqlDSN: %v", err)
+	}
+	if want := "bastion:p@ss/w:rd@tcp(db:3306)/bastion?charset=utf8mb4&parseTime=true&tls=skip-verify"; dsn != want {
+		t.Fatalf("dsn = %q, want %q", dsn, want)
+	}
+}
+
+func TestDialector_MissingDriver(t *testing.T) {
+	if _, ok := dialectors[BackendPostgres]; ok {
+		t.Skip("built with the postgres driver")
+	}
+	if _, err := dialector(BackendPostgres, "postgres://db/bastion"); err == nil || !strings.Contains(err.Error(), "-tags postgres") {
+		t.Fatalf("err = %v, want a rebuild hint", err)
+	}
+}
+
+func TestDisplayURL_MasksPassword(t *testing.T) {
+	if got := DisplayURL("postgres://bastion:s3cret@db/bastion"); strings.Contains(got, "s3cret") {
+		t.Fatalf("DisplayURL leaked the password: %s", got)
+	}
+	if got := DisplayURL("bastion.db"); got != "bastion.db" {
+		t.Fatalf("DisplayURL(path) = %q", got)
+	}
+}
+
+// namedDialector is SQLite posing as another backend, for the code paths that only look at the
+// dialector name.
+type namedDialector struct {
+	gorm.Dialector
+	name string
+}
+
+func (d namedDialector) Name() string { return d.name }
+
+func openNamedTestDB(t *testing.T, name string) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(namedDialector{Dialector: sqlite.Open(filepath.Join(t.TempDir(), "test.db")), name: name}, &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	return db
+}
+
+var (
+	registerLockFuncs sync.Once
+	heldLocksMu       sync.Mutex
+	heldLocks         = map[string]bool{}
+)
+
+// registerMySQLLockFuncs gives SQLite the GET_LOCK and RELEASE_LOCK of MySQL. GET_LOCK on a held
+// lock returns 0 right away, as MySQL does once its timeout expires.
+func registerMySQLLockFuncs(t *testing.T) {
+	t.Helper()
+	registerLockFuncs.Do(func() {
+		err := sqlitedriver.RegisterScalarFunction("GET_LOCK", 2, func(_ *sqlitedriver.FunctionContext, args []driver.Value) (driver.Value, error) {
+			heldLocksMu.Lock()
+			defer heldLocksMu.Unlock()
+			name := args[0].(string)
+			if heldLocks[name] {
+				return int64(0), nil
+			}
+			heldLocks[name] = true
+			return int64(1), nil
+		})
+		if err == nil {
+			err = sqlitedriver.RegisterScalarFunction("RELEASE_LOCK", 1, func(_ *sqlitedriver.FunctionContext, args []driver.Value) (driver.Value, error) {
+				heldLocksMu.Lock()
+				defer heldLocksMu.Unlock()
+				delete(heldLocks, args[0].(string))
+				return int64(1), nil
+			})
+		}
+		if err != nil {
+			t.Fatalf("register lock functions: %v", err)
+		}
+	})
+}
+
+func TestLockMigrations_MySQL(t *testing.T) {
+	registerMySQLLockFuncs(t)
+	db := openNamedTestDB(t, BackendMySQL)
+
+	unlock, err := lockMigrations(db)
+	if err != nil {
+		t.Fatalf("lockMigrations: %v", err)
+	}
+	if _, err := lockMigrations(db); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("second lockMigrations = %v, want a timeout while the lock is held", err)
+	}
+	unlock()
+	heldLocksMu.Lock()
+	held := heldLocks["bastion_migrations"]
+	heldLocksMu.Unlock()
+	if held {
+		t.Fatalf("lock still held after unlock")
+	}
+
+	// Migrate takes the lock too, and gives it back.
+	if err := Migrate(db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	unlock, err = lockMigrations(db)
+	if err != nil {
+		t.Fatalf("lockMigrations after Migrate: %v", err)
+	}
+	unlock()
+}
+
+func TestLockMigrations_RefusesSingleConnection(t *testing.T) {
+	for _, name := range []string{BackendPostgres, BackendMySQL} {
+		db := openNamedTestDB(t, name)
+		sqlDB, _ := db.DB()
+		sqlDB.SetMaxOpenConns(1)
+		if _, err := lockMigrations(db); err == nil || !strings.Contains(err.Error(), "DB_MAX_OPEN_CONNS") {
+			t.Errorf("%s: lockMigrations = %v, want a DB_MAX_OPEN_CONNS error", name, err)
+		}
+	}
+
+	db := openMigrationTestDB(t)
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	unlock, err := lockMigrations(db)
+	if err != nil {
+		t.Fatalf("SQLite needs no lock: %v", err)
+	}
+	unlock()
+}
+
+func TestMigrateWorkspaces_ServerBackendKeepsMappings(t *testing.T) {
+	type legacyBastion struct {
+		ID       uint   `gorm:"primaryKey"`
+		Name     string `gorm:"uniqueIndex;not null"`
+		Host     string `gorm:"not null"`
+		Username string `gorm:"not null"`
+	}
+	type legacyMapping struct {
+		ID        string `gorm:"primaryKey"`
+		LocalPort int    `gorm:"not null"`
+	}
+	db := openNamedTestDB(t, BackendPostgres)
+	if err := db.Table("bastions").AutoMigrate(&legacyBastion{}); err != nil {
+		t.Fatalf("legacy bastions: %v", err)
+	}
+	if err := db.Table("mappings").AutoMigrate(&legacyMapping{}); err != nil {
+		t.Fatalf("legacy mappings: %v", err)
+	}
+	db.Table("mappings").Create(&legacyMapping{ID: "db", LocalPort: 5432})
+
+	if err := db.Transaction(migrateWorkspaces); err != nil {
+		t.Fatalf("migrateWorkspaces: %v", err)
+	}
+
+	m := db.Migrator()
+	if !m.HasColumn(&models.Bastion{}, "Workspace") || m.HasIndex(&models.Bastion{}, "idx_bastions_name") || !m.HasIndex(&models.Bastion{}, "idx_bastions_workspace_name") {
+		t.Fatalf("bastions not scoped to a workspace")
+	}
+	// The SQLite rebuild is skipped: Postgres and MySQL get the (id, workspace) key from the baseline.
+	_, pkColumns, err := sqliteTableInfo(db, "mappings")
+	if err != nil || pkColumns != 1 || m.HasTable("mappings_pre_workspaces") {
+		t.Fatalf("mappings table was rebuilt on a server backend: pk columns=%d err=%v", pkColumns, err)
+	}
+	var count int64
+	if err := db.Table("mappings").Where("id = ?", "db").Count(&count).Error; err != nil || count != 1 {
+		t.Fatalf("mapping lost: count=%d err=%v", count, err)
+	}
+}
@@ -2,22 +2,37 @@ package database
 
 import (
 	"bastion/config"
-	"bastion/models"
+	"fmt"
 	"log"
 	"time"
 
-	"github.com/glebarez/sqlite"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
 
 var DB *gorm.DB
 
-// InitDB initializes and configures the package-level GORM SQLite database according to config.Settings, applies connection pool settings and optional SQLite PRAGMAs, runs automigrations for models.Bastion and models.Mapping, and assigns the resulting *gorm.DB to the package DB.
-// It returns an error if opening the database, obtaining the underlying sql.DB, or running the migrations fails.
+// InitDB opens the package-level database (see OpenDB) and applies pending schema migrations.
+// It returns an error if opening the database fails, if a migration fails, or if the database
+// schema is newer than this binary supports.
 func InitDB() error {
-	var err error
+	if err := OpenDB(); err != nil {
+		return err
+	}
+	if err := Migrate(DB); err != nil {
+		return err
+	}
 
+	log.Printf("Database initialized successfully (%s)", backend)
+	return nil
+}
+
+// OpenDB opens the package-level database selected by DATABASE_URL (SQLite, or Postgres/MySQL in
+// binaries built with their driver), applies the backend's connection pool settings and, for
+// SQLite, the optional PRAGMAs, and assigns the resulting *gorm.DB to DB without touching the
+// schema. It returns an error if DATABASE_URL is invalid or its driver is missing, if opening the
+// database fails, or if obtaining the underlying sql.DB fails.
+func OpenDB() error {
 	// Configure GORM log level
 	logLevel := logger.Silent
 	if config.Settings.LogLevel == "DEBUG" {
@@ -26,8 +41,22 @@ func InitDB() error {
 
 	logWriter := log.Writer()
 
-	dsn := buildSQLiteDSN(config.Settings.DatabaseURL, config.Settings)
-	DB, err = gorm.Open(sqlite.Open(dsn), &gorm.Config{
+	name, dsn, err := ParseDatabaseURL(config.Settings.DatabaseURL)
+	if err != nil {
+		return err
+	}
+	pool := currentPoolConfig(config.Settings, name)
+	if name == BackendSQLite {
+		dsn = buildSQLiteDSN(dsn, config.Settings)
+	} else if pool.maxOpenConns < 2 {
+		// Migrations hold their lock on a connection of its own (see lockMigrations).
+		return fmt.Errorf("DB_MAX_OPEN_CONNS must be at least 2 for %s databases, got %d", name, config.Settings.DBMaxOpenConns)
+	}
+	dialect, err := dialector(name, dsn)
+	if err != nil {
+		return err
+	}
+	DB, err = gorm.Open(dialect, &gorm.Config{
 		Logger: sqliteMetricsLogger{inner: logger.New(
 			log.New(logWriter, "\r\n", log.LstdFlags),
 			logger.Config{
@@ -45,7 +74,7 @@ func InitDB() error {
 		return err
 	}
 
-	pool := currentSQLitePoolConfig(config.Settings)
+	backend = name
 	sqlDB.SetMaxIdleConns(pool.maxIdleConns)
 	sqlDB.SetMaxOpenConns(pool.maxOpenConns)
 	sqlDB.SetConnMaxIdleTime(time.Duration(pool.maxIdleSec) * time.Second)
@@ -53,7 +82,7 @@ func InitDB() error {
 
 	// Apply PRAGMAs again as a best-effort startup initialization (useful for existing DB files).
 	// Connection URL parameters ensure PRAGMAs are applied for new connections too.
-	if config.Settings.SQLitePragmasEnabled {
+	if name == BackendSQLite && config.Settings.SQLitePragmasEnabled {
 		if config.Settings.SQLiteBusyTimeoutMS > 0 {
 			DB.Exec("PRAGMA busy_timeout = ?", config.Settings.SQLiteBusyTimeoutMS)
 		}
@@ -70,13 +99,6 @@ func InitDB() error {
 		}
 	}
 
-	// Auto-migrate database tables
-	err = DB.AutoMigrate(&models.Bastion{}, &models.Mapping{}, &models.AppSetting{})
-	if err != nil {
-		return err
-	}
-
-	log.Println("Database initialized successfully")
 	return nil
 }
 
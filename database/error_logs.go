@@ -0,0 +1,124 @@
+package database
+
+import (
+	"bastion/models"
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrorLogStore persists error logs in SQLite.
+type ErrorLogStore struct {
+	db *gorm.DB
+}
+
+// NewErrorLogStore constructs an error log store backed by db.
+func NewErrorLogStore(db *gorm.DB) *ErrorLogStore {
+	return &ErrorLogStore{db: db}
+}
+
+// Insert persists entry and fills in its ID.
+func (s *ErrorLogStore) Insert(entry *models.ErrorLog) error {
+	entry.ID = 0
+	return s.db.Create(entry).Error
+}
+
+// Query returns a page of error logs (latest first) matching filter, plus the total match count.
+func (s *ErrorLogStore) Query(filter models.ErrorLogFilter, page, pageSize int) ([]*models.ErrorLog, int, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = 20
+	}
+
+	q := s.db.Model(&models.ErrorLog{})
+	if len(filter.Levels) > 0 {
+		levels := make([]string, 0, len(filter.Levels))
+		for _, l := range filter.Levels {
+			levels = append(levels, strings.ToUpper(l))
+		}
+		q = q.Where("level IN ?", levels)
+	}
+	if filter.MinLevel != "" {
+		min := models.ErrorLogLevelRank(filter.MinLevel)
+		var levels []string
+		for _, l := range []string{"DEBUG", "INFO", "WARN", "ERROR", "FATAL"} {
+			if models.ErrorLogLevelRank(l) >= min {
+				levels = append(levels, l)
+			}
+		}
+		q = q.Where("level IN ?", levels)
+	}
+	if filter.Source != "" {
+		q = q.Where("LOWER(source) = LOWER(?)", filter.Source)
+	}
+	if filter.Since != nil {
+		q = q.Where("timestamp >= ?", *filter.Since)
+	}
+	if filter.Until != nil {
+		q = q.Where("timestamp <= ?", *filter.Until)
+	}
+	if filter.Query != "" {
+		like := "%" + filter.Query + "%"
+		q = q.Where("message LIKE ? OR detail LIKE ? OR context LIKE ?", like, like, like)
+	}
+
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	logs := make([]*models.ErrorLog, 0, pageSize)
+	if err := q.Order("id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&logs).Error; err != nil {
+		return nil, 0, err
+	}
+	return logs, int(total), nil
+}
+
+// Get returns a single error log, or nil when it does not exist.
+func (s *ErrorLogStore) Get(id int) (*models.ErrorLog, error) {
+	var entry models.ErrorLog
+	if err := s.db.First(&entry, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// Clear removes all persisted error logs.
+func (s *ErrorLogStore) Clear() error {
+	return s.db.Where("1 = 1").Delete(&models.ErrorLog{}).Error
+}
+
+// Prune removes entries older than olderThan (when non-zero) and keeps at most maxRows newest entries
+// (when maxRows > 0). It returns the number of deleted rows.
+func (s *ErrorLogStore) Prune(olderThan time.Time, maxRows int) (int64, error) {
+	var deleted int64
+
+	if !olderThan.IsZero() {
+		res := s.db.Where("timestamp < ?", olderThan).Delete(&models.ErrorLog{})
+		if res.Error != nil {
+			return deleted, res.Error
+		}
+		deleted += res.RowsAffected
+	}
+
+	if maxRows > 0 {
+		res := s.db.Exec(
+			// The derived table lets MySQL, which rejects LIMIT in IN subqueries, run it too.
+			"DELETE FROM error_logs WHERE id NOT IN (SELECT id FROM (SELECT id FROM error_logs ORDER BY id DESC LIMIT ?) AS kept)",
+			maxRows,
+		)
+		if res.Error != nil {
+			return deleted, res.Error
+		}
+		deleted += res.RowsAffected
+	}
+
+	return deleted, nil
+}
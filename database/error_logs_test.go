@@ -0,0 +1,99 @@
+package database
+
+import (
+	"bastion/models"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func openErrorLogTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	if err := db.AutoMigrate(&models.ErrorLog{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+func TestErrorLogStore_QueryFilters(t *testing.T) {
+	store := NewErrorLogStore(openErrorLogTestDB(t))
+	base := time.Now().Add(-time.Hour)
+
+	entries := []*models.ErrorLog{
+		{Timestamp: base, Level: "INFO", Source: "pool", Message: "connected"},
+		{Timestamp: base.Add(time.Minute), Level: "WARN", Source: "pool", Message: "slow keepalive"},
+		{Timestamp: base.Add(2 * time.Minute), Level: "ERROR", Source: "mapping", Message: "bind failed", Context: `{"port":8080}`},
+	}
+	for _, e := range entries {
+		if err := store.Insert(e); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+
+	logs, total, err := store.Query(models.ErrorLogFilter{MinLevel: "WARN"}, 1, 10)
+	if err != nil || total != 2 || len(logs) != 2 {
+		t.Fatalf("min_level: total=%d len=%d err=%v", total, len(logs), err)
+	}
+	if logs[0].Message != "bind failed" {
+		t.Fatalf("expected latest first, got %q", logs[0].Message)
+	}
+
+	_, total, _ = store.Query(models.ErrorLogFilter{Source: "POOL"}, 1, 10)
+	if total != 2 {
+		t.Fatalf("component: expected 2, got %d", total)
+	}
+
+	logs, total, _ = store.Query(models.ErrorLogFilter{Query: "8080"}, 1, 10)
+	if total != 1 || logs[0].Source != "mapping" {
+		t.Fatalf("text search: total=%d", total)
+	}
+
+	since := base.Add(30 * time.Second)
+	_, total, _ = store.Query(models.ErrorLogFilter{Since: &since, Levels: []string{"info", "warn"}}, 1, 10)
+	if total != 1 {
+		t.Fatalf("since+levels: expected 1, got %d", total)
+	}
+
+	logs, total, _ = store.Query(models.ErrorLogFilter{}, 2, 2)
+	if total != 3 || len(logs) != 1 || logs[0].Message != "connected" {
+		t.Fatalf("pagination: total=%d len=%d", total, len(logs))
+	}
+}
+
+func TestErrorLogStore_Prune(t *testing.T) {
+	store := NewErrorLogStore(openErrorLogTestDB(t))
+	now := time.Now()
+
+	for i := 0; i < 5; i++ {
+		ts := now.Add(-time.Duration(5-i) * time.Minute)
+		if i == 0 {
+			ts = now.Add(-48 * time.Hour)
+		}
+		if err := store.Insert(&models.ErrorLog{Timestamp: ts, Level: "ERROR", Source: "test"}); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+
+	deleted, err := store.Prune(now.Add(-24*time.Hour), 3)
+	if err != nil {
+		t.Fatalf("prune: %v", err)
+	}
+	if deleted != 2 {
+		t.Fatalf("expected 2 deleted, got %d", deleted)
+	}
+
+	_, total, _ := store.Query(models.ErrorLogFilter{}, 1, 10)
+	if total != 3 {
+		t.Fatalf("expected 3 remaining, got %d", total)
+	}
+}
@@ -0,0 +1,233 @@
+package database
+
+import (
+	"bastion/config"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// scheduledBackupPrefix marks files written (and rotated) by the backup scheduler; other files in the
+// backup directory are never deleted.
+const scheduledBackupPrefix = "bastion-"
+
+// ErrBackupExists indicates the backup target already exists.
+var ErrBackupExists = errors.New("backup file already exists")
+
+// BackupInfo describes a written backup file.
+type BackupInfo struct {
+	Path      string    `json:"path"`
+	SizeBytes int64     `json:"size_bytes"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// VacuumResult reports the database file size around a VACUUM.
+type VacuumResult struct {
+	SizeBefore int64 `json:"size_before"`
+	SizeAfter  int64 `json:"size_after"`
+	DurationMs int64 `json:"duration_ms"`
+}
+
+// IntegrityResult is the outcome of PRAGMA integrity_check (or quick_check).
+type IntegrityResult struct {
+	OK       bool     `json:"ok"`
+	Quick    bool     `json:"quick"`
+	Problems []string `json:"problems,omitempty"`
+}
+
+var (
+	backupSchedulerOnce sync.Once
+	// maintenanceMu serializes backups and VACUUM so they do not compete for the write lock.
+	maintenanceMu sync.Mutex
+)
+
+// ResolveBackupPath resolves a relative backup path against DB_BACKUP_DIR.
+func ResolveBackupPath(path string) string {
+	path = strings.TrimSpace(path)
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(config.Settings.DBBackupDir, path)
+}
+
+// Backup writes a consistent snapshot of db to path. The driver does not expose the sqlite3_backup_*
+// API, so the snapshot is taken with VACUUM INTO, which reads the database inside a single read
+// transaction while writers keep going. The file is written next to path and renamed into place, so
+// path never holds a partial backup. Postgres and MySQL databases are backed up with their own
+// tools (ErrUnsupportedBackend).
+func Backup(db *gorm.DB, path string) (*BackupInfo, error) {
+	if db == nil {
+		return nil, errors.New("database not initialized")
+	}
+	if err := requireSQLite(db); err != nil {
+		return nil, err
+	}
+	if path == "" {
+		return nil, errors.New("empty backup path")
+	}
+	if _, err := os.Stat(path); err == nil {
+		return nil, fmt.Errorf("%w: %s", ErrBackupExists, path)
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	// VACUUM INTO accepts an existing empty file, which lets CreateTemp pick a unique name.
+	tmp, err := os.CreateTemp(dir, ".backup-*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create backup file: %w", err)
+	}
+	tmpPath := tmp.Name()
+	_ = tmp.Close()
+	defer os.Remove(tmpPath)
+
+	maintenanceMu.Lock()
+	err = db.Exec("VACUUM INTO ?", tmpPath).Error
+	maintenanceMu.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("backup failed: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return nil, fmt.Errorf("failed to move backup into place: %w", err)
+	}
+
+	st, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	return &BackupInfo{Path: path, SizeBytes: st.Size(), CreatedAt: st.ModTime()}, nil
+}
+
+// Vacuum rebuilds the database file to reclaim free pages (SQLite only).
+func Vacuum(db *gorm.DB) (*VacuumResult, error) {
+	if db == nil {
+		return nil, errors.New("database not initialized")
+	}
+	if err := requireSQLite(db); err != nil {
+		return nil, err
+	}
+	maintenanceMu.Lock()
+	defer maintenanceMu.Unlock()
+
+	result := &VacuumResult{SizeBefore: databaseSize(db)}
+	start := time.Now()
+	if err := db.Exec("VACUUM").Error; err != nil {
+		return nil, fmt.Errorf("vacuum failed: %w", err)
+	}
+	result.DurationMs = time.Since(start).Milliseconds()
+	result.SizeAfter = databaseSize(db)
+	return result, nil
+}
+
+// IntegrityCheck runs PRAGMA integrity_check, or the cheaper quick_check when quick is set (SQLite
+// only).
+func IntegrityCheck(db *gorm.DB, quick bool) (*IntegrityResult, error) {
+	if db == nil {
+		return nil, errors.New("database not initialized")
+	}
+	if err := requireSQLite(db); err != nil {
+		return nil, err
+	}
+	pragma := "PRAGMA integrity_check"
+	if quick {
+		pragma = "PRAGMA quick_check"
+	}
+	var rows []string
+	if err := db.Raw(pragma).Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("integrity check failed: %w", err)
+	}
+
+	result := &IntegrityResult{Quick: quick}
+	if len(rows) == 1 && rows[0] == "ok" {
+		result.OK = true
+	} else {
+		result.Problems = rows
+	}
+	return result, nil
+}
+
+// databaseSize returns page_count * page_size, or 0 when unavailable.
+func databaseSize(db *gorm.DB) int64 {
+	var pageCount, pageSize int64
+	if err := db.Raw("PRAGMA page_count").Scan(&pageCount).Error; err != nil {
+		return 0
+	}
+	if err := db.Raw("PRAGMA page_size").Scan(&pageSize).Error; err != nil {
+		return 0
+	}
+	return pageCount * pageSize
+}
+
+// RunScheduledBackup writes a timestamped backup into dir and deletes the oldest scheduled backups
+// beyond keep (keep <= 0 keeps all of them).
+func RunScheduledBackup(db *gorm.DB, dir string, keep int, now time.Time) (*BackupInfo, error) {
+	name := scheduledBackupPrefix + now.Format("20060102-150405") + ".db"
+	info, err := Backup(db, filepath.Join(dir, name))
+	if err != nil {
+		return nil, err
+	}
+	if keep > 0 {
+		if err := rotateBackups(dir, keep); err != nil {
+			log.Printf("Failed to rotate database backups: %v", err)
+		}
+	}
+	return info, nil
+}
+
+// rotateBackups deletes the oldest scheduled backups in dir so that at most keep remain. The
+// timestamped names sort chronologically.
+func rotateBackups(dir string, keep int) error {
+	matches, err := filepath.Glob(filepath.Join(dir, scheduledBackupPrefix+"*.db"))
+	if err != nil {
+		return err
+	}
+	if len(matches) <= keep {
+		return nil
+	}
+	sort.Strings(matches)
+	for _, path := range matches[:len(matches)-keep] {
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StartBackupScheduler backs up DB every DB_BACKUP_INTERVAL_MINUTES into DB_BACKUP_DIR, keeping the
+// newest DB_BACKUP_KEEP files. It is a no-op when the interval is 0 and safe to call multiple times.
+// Postgres and MySQL databases are left to their own backup tools.
+func StartBackupScheduler() {
+	interval := time.Duration(config.Settings.DBBackupIntervalMinutes) * time.Minute
+	if interval <= 0 {
+		return
+	}
+	if Backend() != BackendSQLite {
+		log.Printf("DB_BACKUP_INTERVAL_MINUTES ignored: scheduled backups support SQLite only, back up the %s database with its own tools", Backend())
+		return
+	}
+	backupSchedulerOnce.Do(func() {
+		go func() {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for now := range ticker.C {
+				info, err := RunScheduledBackup(DB, config.Settings.DBBackupDir, config.Settings.DBBackupKeep, now)
+				if err != nil {
+					log.Printf("Scheduled database backup failed: %v", err)
+					continue
+				}
+				if config.Settings.LogLevel == "DEBUG" {
+					log.Printf("Database backed up to %s (%d bytes)", info.Path, info.SizeBytes)
+				}
+			}
+		}()
+	})
+}
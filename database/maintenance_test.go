@@ -0,0 +1,83 @@
+package database
+
+import (
+	"bastion/models"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestBackup_ProducesReadableSnapshot(t *testing.T) {
+	db := openErrorLogTestDB(t)
+	if err := NewErrorLogStore(db).Insert(&models.ErrorLog{Timestamp: time.Now(), Level: "ERROR", Message: "boom"}); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "nested", "snap.db")
+	info, err := Backup(db, path)
+	if err != nil {
+		t.Fatalf("backup: %v", err)
+	}
+	if info.Path != path || info.SizeBytes == 0 {
+		t.Fatalf("unexpected backup info: %+v", info)
+	}
+	if _, err := Backup(db, path); !errors.Is(err, ErrBackupExists) {
+		t.Fatalf("expected ErrBackupExists, got %v", err)
+	}
+
+	snap, err := gorm.Open(sqlite.Open(path), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open snapshot: %v", err)
+	}
+	var count int64
+	if err := snap.Model(&models.ErrorLog{}).Count(&count).Error; err != nil || count != 1 {
+		t.Fatalf("expected 1 row in snapshot, got %d (%v)", count, err)
+	}
+	result, err := IntegrityCheck(snap, false)
+	if err != nil || !result.OK {
+		t.Fatalf("expected snapshot to pass integrity_check: %+v %v", result, err)
+	}
+}
+
+func TestVacuumAndQuickCheck(t *testing.T) {
+	db := openErrorLogTestDB(t)
+	if _, err := Vacuum(db); err != nil {
+		t.Fatalf("vacuum: %v", err)
+	}
+	result, err := IntegrityCheck(db, true)
+	if err != nil || !result.OK || !result.Quick {
+		t.Fatalf("unexpected quick_check result: %+v %v", result, err)
+	}
+}
+
+func TestRunScheduledBackup_Rotates(t *testing.T) {
+	db := openErrorLogTestDB(t)
+	dir := t.TempDir()
+	other := filepath.Join(dir, "manual.db")
+	if err := os.WriteFile(other, []byte("x"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 4; i++ {
+		if _, err := RunScheduledBackup(db, dir, 2, base.Add(time.Duration(i)*time.Minute)); err != nil {
+			t.Fatalf("backup %d: %v", i, err)
+		}
+	}
+
+	matches, _ := filepath.Glob(filepath.Join(dir, scheduledBackupPrefix+"*.db"))
+	if len(matches) != 2 ||
+		filepath.Base(matches[0]) != "bastion-20240101-000200.db" ||
+		filepath.Base(matches[1]) != "bastion-20240101-000300.db" {
+		t.Fatalf("unexpected backups after rotation: %v", matches)
+	}
+	if _, err := os.Stat(other); err != nil {
+		t.Fatalf("rotation must not touch other files: %v", err)
+	}
+}
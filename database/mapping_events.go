@@ -0,0 +1,52 @@
+package database
+
+import (
+	"bastion/models"
+
+	"gorm.io/gorm"
+)
+
+// MappingEventStore persists per-mapping lifecycle events in SQLite.
+type MappingEventStore struct {
+	db *gorm.DB
+}
+
+// NewMappingEventStore constructs a mapping event store backed by db.
+func NewMappingEventStore(db *gorm.DB) *MappingEventStore {
+	return &MappingEventStore{db: db}
+}
+
+// Insert persists ev and trims the mapping's history to the newest keep events (keep <= 0 disables trimming).
+func (s *MappingEventStore) Insert(ev *models.MappingEvent, keep int) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(ev).Error; err != nil {
+			return err
+		}
+		if keep <= 0 {
+			return nil
+		}
+		return tx.Exec(
+			// The derived table lets MySQL, which rejects LIMIT in IN subqueries, run it too.
+			"DELETE FROM mapping_events WHERE mapping_id = ? AND id NOT IN (SELECT id FROM (SELECT id FROM mapping_events WHERE mapping_id = ? ORDER BY id DESC LIMIT ?) AS kept)",
+			ev.MappingID, ev.MappingID, keep,
+		).Error
+	})
+}
+
+// List returns the newest events of a mapping, latest first.
+func (s *MappingEventStore) List(mappingID string, limit int) ([]models.MappingEvent, error) {
+	events := make([]models.MappingEvent, 0)
+	q := s.db.Where("mapping_id = ?", mappingID).Order("id DESC")
+	if limit > 0 {
+		q = q.Limit(limit)
+	}
+	if err := q.Find(&events).Error; err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// DeleteByMapping removes all events of a mapping.
+func (s *MappingEventStore) DeleteByMapping(mappingID string) error {
+	return s.db.Where("mapping_id = ?", mappingID).Delete(&models.MappingEvent{}).Error
+}
@@ -0,0 +1,20 @@
+package database
+
+import (
+	"bastion/models"
+
+	"gorm.io/gorm"
+)
+
+// RenameMappingKey moves the lifecycle events and the usage totals of the mapping with runtime key
+// oldKey to newKey. Run it in the transaction that changes the mapping's ID.
+func RenameMappingKey(tx *gorm.DB, oldKey, newKey string) error {
+	if err := tx.Model(&models.MappingEvent{}).Where("mapping_id = ?", oldKey).Update("mapping_id", newKey).Error; err != nil {
+		return err
+	}
+	// A leftover row of a deleted mapping with the new ID would collide with the moved one.
+	if err := tx.Where("mapping_id = ?", newKey).Delete(&models.MappingUsage{}).Error; err != nil {
+		return err
+	}
+	return tx.Model(&models.MappingUsage{}).Where("mapping_id = ?", oldKey).Update("mapping_id", newKey).Error
+}
@@ -0,0 +1,33 @@
+package database
+
+import (
+	"bastion/models"
+	"testing"
+	"time"
+)
+
+func TestRenameMappingKey(t *testing.T) {
+	db := openMigrationTestDB(t)
+	if err := Migrate(db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	events := NewMappingEventStore(db)
+	if err := events.Insert(&models.MappingEvent{MappingID: "old", Timestamp: time.Now(), Type: "start"}, 0); err != nil {
+		t.Fatalf("insert event: %v", err)
+	}
+	usage := NewMappingUsageStore(db)
+	if err := usage.Save([]models.MappingUsage{{MappingID: "old", BytesUp: 5}, {MappingID: "new", BytesUp: 1}}); err != nil {
+		t.Fatalf("save usage: %v", err)
+	}
+
+	if err := RenameMappingKey(db, "old", "new"); err != nil {
+		t.Fatalf("RenameMappingKey: %v", err)
+	}
+	if list, _ := events.List("new", 0); len(list) != 1 {
+		t.Fatalf("expected the event moved, got %+v", list)
+	}
+	rows, err := usage.LoadAll()
+	if err != nil || len(rows) != 1 || rows[0].MappingID != "new" || rows[0].BytesUp != 5 {
+		t.Fatalf("expected only the moved usage row, got %+v %v", rows, err)
+	}
+}
@@ -0,0 +1,40 @@
+package database
+
+import (
+	"bastion/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MappingUsageStore persists lifetime per-mapping traffic counters in SQLite.
+type MappingUsageStore struct {
+	db *gorm.DB
+}
+
+// NewMappingUsageStore constructs a mapping usage store backed by db.
+func NewMappingUsageStore(db *gorm.DB) *MappingUsageStore {
+	return &MappingUsageStore{db: db}
+}
+
+// LoadAll returns every persisted usage row.
+func (s *MappingUsageStore) LoadAll() ([]models.MappingUsage, error) {
+	usages := make([]models.MappingUsage, 0)
+	if err := s.db.Find(&usages).Error; err != nil {
+		return nil, err
+	}
+	return usages, nil
+}
+
+// Save upserts usages (the tracker always writes absolute totals).
+func (s *MappingUsageStore) Save(usages []models.MappingUsage) error {
+	if len(usages) == 0 {
+		return nil
+	}
+	return s.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&usages).Error
+}
+
+// Delete removes the usage row of a mapping.
+func (s *MappingUsageStore) Delete(mappingID string) error {
+	return s.db.Where("mapping_id = ?", mappingID).Delete(&models.MappingUsage{}).Error
+}
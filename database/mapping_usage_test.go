@@ -0,0 +1,30 @@
+package database
+
+import (
+	"bastion/models"
+	"testing"
+)
+
+func TestMappingUsageStore_Upserts(t *testing.T) {
+	db := openMigrationTestDB(t)
+	if err := Migrate(db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	store := NewMappingUsageStore(db)
+
+	if err := store.Save([]models.MappingUsage{{MappingID: "db", BytesUp: 1, BytesDown: 2}}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if err := store.Save([]models.MappingUsage{{MappingID: "db", BytesUp: 10, BytesDown: 20}, {MappingID: "ws/db", BytesUp: 3}}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	usages, err := store.LoadAll()
+	if err != nil || len(usages) != 2 {
+		t.Fatalf("expected 2 rows, got %+v %v", usages, err)
+	}
+	for _, u := range usages {
+		if u.MappingID == "db" && (u.BytesUp != 10 || u.BytesDown != 20) {
+			t.Fatalf("expected upsert to overwrite totals: %+v", u)
+		}
+	}
+}
@@ -0,0 +1,457 @@
+package database
+
+import (
+	"bastion/models"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Migration is one versioned schema change. Migrations run in version order, each inside its own
+// transaction together with the schema_version row that records it.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx *gorm.DB) error
+}
+
+// migrations is the ordered schema history. Append new entries; never edit or renumber released
+// ones. Because the baseline creates tables from the current structs, later migrations that add
+// columns must tolerate the column already existing (see addColumnIfMissing).
+var migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "baseline",
+		// Brings databases created by AutoMigrate-era releases (and fresh ones) to the versioned baseline.
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&models.Bastion{}, &models.Mapping{}, &models.AppSetting{}, &models.ErrorLog{}, &models.MappingEvent{})
+		},
+	},
+	{
+		Version: 2,
+		Name:    "workspaces",
+		Up:      migrateWorkspaces,
+	},
+	{
+		Version: 3,
+		Name:    "mapping_usage",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&models.MappingUsage{})
+		},
+	},
+	{
+		Version: 4,
+		Name:    "mapping_standby",
+		Up: func(tx *gorm.DB) error {
+			if err := addColumnIfMissing(tx, &models.Mapping{}, "Standby"); err != nil {
+				return err
+			}
+			return addColumnIfMissing(tx, &models.Mapping{}, "StandbyIdleSeconds")
+		},
+	},
+	{
+		Version: 5,
+		Name:    "mapping_expose",
+		Up: func(tx *gorm.DB) error {
+			for _, field := range []string{"ExposeAddr", "ExposedBy", "ExposedAt"} {
+				if err := addColumnIfMissing(tx, &models.Mapping{}, field); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version: 6,
+		Name:    "notes_and_tags",
+		Up: func(tx *gorm.DB) error {
+			for _, model := range []interface{}{&models.Bastion{}, &models.Mapping{}} {
+				for _, field := range []string{"Description", "TagsJSON"} {
+					if err := addColumnIfMissing(tx, model, field); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version: 7,
+		Name:    "config_audit",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&models.ConfigAudit{})
+		},
+	},
+	{
+		Version: 8,
+		Name:    "optimistic_locking",
+		Up: func(tx *gorm.DB) error {
+			now := time.Now()
+			for _, model := range []interface{}{&models.Bastion{}, &models.Mapping{}} {
+				for _, field := range []string{"Version", "UpdatedAt"} {
+					if err := addColumnIfMissing(tx, model, field); err != nil {
+						return err
+					}
+				}
+				if err := tx.Model(model).Where("updated_at IS NULL").UpdateColumn("updated_at", now).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version: 9,
+		Name:    "mapping_listen_family",
+		Up: func(tx *gorm.DB) error {
+			return addColumnIfMissing(tx, &models.Mapping{}, "ListenFamily")
+		},
+	},
+	{
+		Version: 10,
+		Name:    "mapping_target_rules",
+		Up: func(tx *gorm.DB) error {
+			for _, field := range []string{"TargetAllowJSON", "TargetDenyJSON"} {
+				if err := addColumnIfMissing(tx, &models.Mapping{}, field); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version: 11,
+		Name:    "mapping_quotas",
+		Up: func(tx *gorm.DB) error {
+			for _, field := range []string{"QuotaBytesPerDay", "QuotaAction", "QuotaThrottleBps"} {
+				if err := addColumnIfMissing(tx, &models.Mapping{}, field); err != nil {
+					return err
+				}
+			}
+			for _, field := range []string{"QuotaBytes", "QuotaPeriodStart"} {
+				if err := addColumnIfMissing(tx, &models.MappingUsage{}, field); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version: 12,
+		Name:    "mapping_ftp_helper",
+		Up: func(tx *gorm.DB) error {
+			return addColumnIfMissing(tx, &models.Mapping{}, "FTPHelper")
+		},
+	},
+	{
+		Version: 13,
+		Name:    "mapping_tls",
+		Up: func(tx *gorm.DB) error {
+			for _, field := range []string{"TLSMode", "TLSCertFile", "TLSKeyFile", "TLSServerName", "TLSCAFile", "TLSInsecureSkipVerify"} {
+				if err := addColumnIfMissing(tx, &models.Mapping{}, field); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version: 14,
+		Name:    "bastion_algorithms",
+		Up: func(tx *gorm.DB) error {
+			for _, field := range []string{"Ciphers", "MACs", "KeyExchanges"} {
+				if err := addColumnIfMissing(tx, &models.Bastion{}, field); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version: 15,
+		Name:    "mapping_remote_port_template",
+		Up: func(tx *gorm.DB) error {
+			return addColumnIfMissing(tx, &models.Mapping{}, "RemotePortTemplate")
+		},
+	},
+	{
+		Version: 16,
+		Name:    "mapping_depends_on",
+		Up: func(tx *gorm.DB) error {
+			return addColumnIfMissing(tx, &models.Mapping{}, "DependsOnJSON")
+		},
+	},
+	{
+		Version: 17,
+		Name:    "mapping_dial_hold",
+		Up: func(tx *gorm.DB) error {
+			return addColumnIfMissing(tx, &models.Mapping{}, "DialHoldSeconds")
+		},
+	},
+	{
+		Version: 18,
+		Name:    "mapping_dial_happy_eyeballs",
+		Up: func(tx *gorm.DB) error {
+			return addColumnIfMissing(tx, &models.Mapping{}, "DialHappyEyeballs")
+		},
+	},
+	{
+		Version: 19,
+		Name:    "agents",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&models.Agent{})
+		},
+	},
+	{
+		Version: 20,
+		Name:    "mapping_k8s",
+		Up: func(tx *gorm.DB) error {
+			for _, field := range []string{"K8sKubeconfig", "K8sContext", "K8sNamespace"} {
+				if err := addColumnIfMissing(tx, &models.Mapping{}, field); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version: 21,
+		Name:    "mapping_query_audit",
+		Up: func(tx *gorm.DB) error {
+			return addColumnIfMissing(tx, &models.Mapping{}, "QueryAudit")
+		},
+	},
+	{
+		Version: 22,
+		Name:    "mapping_request_headers",
+		Up: func(tx *gorm.DB) error {
+			return addColumnIfMissing(tx, &models.Mapping{}, "RequestHeadersJSON")
+		},
+	},
+	{
+		Version: 23,
+		Name:    "agent_tokens",
+		Up: func(tx *gorm.DB) error {
+			if err := addColumnIfMissing(tx, &models.Agent{}, "TokenHash"); err != nil {
+				return err
+			}
+			if tx.Migrator().HasIndex(&models.Agent{}, "TokenHash") {
+				return nil
+			}
+			return tx.Migrator().CreateIndex(&models.Agent{}, "TokenHash")
+		},
+	},
+}
+
+// ErrSchemaTooNew indicates the database was migrated by a newer binary.
+var ErrSchemaTooNew = errors.New("database schema is newer than this binary supports")
+
+// schemaVersion is one applied migration.
+type schemaVersion struct {
+	Version   int       `gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"not null"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+func (schemaVersion) TableName() string { return "schema_version" }
+
+// AppliedMigration is a migration recorded in schema_version.
+type AppliedMigration struct {
+	Version   int       `json:"version"`
+	Name      string    `json:"name"`
+	AppliedAt time.Time `json:"applied_at"`
+}
+
+// PendingMigration is a known migration not yet applied.
+type PendingMigration struct {
+	Version int    `json:"version"`
+	Name    string `json:"name"`
+}
+
+// MigrationStatus compares the database schema with the migrations compiled into this binary.
+type MigrationStatus struct {
+	Current int                `json:"current"`
+	Latest  int                `json:"latest"`
+	Applied []AppliedMigration `json:"applied"`
+	Pending []PendingMigration `json:"pending"`
+	TooNew  bool               `json:"too_new"` // the database has versions this binary does not know
+}
+
+// LatestSchemaVersion returns the newest migration version known to this binary.
+func LatestSchemaVersion() int {
+	return latestVersion(migrations)
+}
+
+func latestVersion(list []Migration) int {
+	if len(list) == 0 {
+		return 0
+	}
+	return list[len(list)-1].Version
+}
+
+// Migrate applies all pending migrations. It refuses to touch a database whose schema version is
+// newer than the binary, so an accidental downgrade fails instead of corrupting data.
+func Migrate(db *gorm.DB) error {
+	return runMigrations(db, migrations)
+}
+
+func runMigrations(db *gorm.DB, list []Migration) error {
+	if db == nil {
+		return errors.New("database not initialized")
+	}
+	unlock, err := lockMigrations(db)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	status, err := migrationStatus(db, list)
+	if err != nil {
+		return err
+	}
+	if status.TooNew {
+		return fmt.Errorf("%w: database is at version %d, binary supports up to %d; upgrade bastion or restore a backup", ErrSchemaTooNew, status.Current, status.Latest)
+	}
+
+	for _, m := range list {
+		if m.Version <= status.Current {
+			continue
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := m.Up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&schemaVersion{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("migration %d (%s) failed: %w", m.Version, m.Name, err)
+		}
+		log.Printf("Applied database migration %d (%s)", m.Version, m.Name)
+	}
+	return nil
+}
+
+// GetMigrationStatus reports applied and pending migrations without changing the schema (apart from
+// creating an empty schema_version table).
+func GetMigrationStatus(db *gorm.DB) (*MigrationStatus, error) {
+	return migrationStatus(db, migrations)
+}
+
+func migrationStatus(db *gorm.DB, list []Migration) (*MigrationStatus, error) {
+	if db == nil {
+		return nil, errors.New("database not initialized")
+	}
+	if err := db.AutoMigrate(&schemaVersion{}); err != nil {
+		return nil, fmt.Errorf("failed to create schema_version table: %w", err)
+	}
+	var rows []schemaVersion
+	if err := db.Order("version").Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to read schema_version: %w", err)
+	}
+
+	status := &MigrationStatus{
+		Latest:  latestVersion(list),
+		Applied: make([]AppliedMigration, 0, len(rows)),
+		Pending: []PendingMigration{},
+	}
+	for _, r := range rows {
+		status.Applied = append(status.Applied, AppliedMigration{Version: r.Version, Name: r.Name, AppliedAt: r.AppliedAt})
+		if r.Version > status.Current {
+			status.Current = r.Version
+		}
+	}
+	for _, m := range list {
+		if m.Version > status.Current {
+			status.Pending = append(status.Pending, PendingMigration{Version: m.Version, Name: m.Name})
+		}
+	}
+	status.TooNew = status.Current > status.Latest
+	return status, nil
+}
+
+// migrateWorkspaces scopes bastion names and mapping IDs to a workspace: bastions get a
+// (workspace, name) unique index instead of a global one, and mappings are rebuilt with an
+// (id, workspace) primary key. Existing rows land in the default workspace.
+func migrateWorkspaces(tx *gorm.DB) error {
+	if err := addColumnIfMissing(tx, &models.Bastion{}, "Workspace"); err != nil {
+		return err
+	}
+	if tx.Migrator().HasIndex(&models.Bastion{}, "idx_bastions_name") {
+		if err := tx.Migrator().DropIndex(&models.Bastion{}, "idx_bastions_name"); err != nil {
+			return err
+		}
+	}
+	if !tx.Migrator().HasIndex(&models.Bastion{}, "idx_bastions_workspace_name") {
+		if err := tx.Migrator().CreateIndex(&models.Bastion{}, "idx_bastions_workspace_name"); err != nil {
+			return err
+		}
+	}
+
+	// Postgres and MySQL databases were created by the baseline with the (id, workspace) key.
+	if tx.Dialector.Name() != BackendSQLite {
+		return nil
+	}
+	// SQLite cannot change a primary key in place, so the mappings table is rebuilt.
+	oldColumns, pkColumns, err := sqliteTableInfo(tx, "mappings")
+	if err != nil {
+		return err
+	}
+	if pkColumns > 1 {
+		return nil
+	}
+	if err := tx.Exec("ALTER TABLE mappings RENAME TO mappings_pre_workspaces").Error; err != nil {
+		return err
+	}
+	if err := tx.Migrator().CreateTable(&models.Mapping{}); err != nil {
+		return err
+	}
+	newColumns, _, err := sqliteTableInfo(tx, "mappings")
+	if err != nil {
+		return err
+	}
+	keep := make(map[string]bool, len(newColumns))
+	for _, c := range newColumns {
+		keep[c] = true
+	}
+	copied := make([]string, 0, len(oldColumns))
+	for _, c := range oldColumns {
+		if keep[c] {
+			copied = append(copied, `"`+c+`"`)
+		}
+	}
+	cols := strings.Join(copied, ", ")
+	if err := tx.Exec("INSERT INTO mappings (" + cols + ") SELECT " + cols + " FROM mappings_pre_workspaces").Error; err != nil {
+		return err
+	}
+	return tx.Exec("DROP TABLE mappings_pre_workspaces").Error
+}
+
+// sqliteTableInfo returns the column names of table and how many of them form the primary key.
+func sqliteTableInfo(tx *gorm.DB, table string) (columns []string, pkColumns int, err error) {
+	var info []struct {
+		Name string
+		PK   int `gorm:"column:pk"`
+	}
+	if err := tx.Raw("SELECT name, pk FROM pragma_table_info(?)", table).Scan(&info).Error; err != nil {
+		return nil, 0, err
+	}
+	for _, c := range info {
+		columns = append(columns, c.Name)
+		if c.PK > 0 {
+			pkColumns++
+		}
+	}
+	return columns, pkColumns, nil
+}
+
+// addColumnIfMissing adds the column backing field of model unless it already exists.
+func addColumnIfMissing(tx *gorm.DB, model interface{}, field string) error {
+	if tx.Migrator().HasColumn(model, field) {
+		return nil
+	}
+	return tx.Migrator().AddColumn(model, field)
+}
@@ -0,0 +1,157 @@
+package database
+
+import (
+	"bastion/models"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func openMigrationTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	return db
+}
+
+func TestMigrate_FreshAndIdempotent(t *testing.T) {
+	db := openMigrationTestDB(t)
+	if err := Migrate(db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if !db.Migrator().HasTable(&models.Mapping{}) {
+		t.Fatalf("expected baseline to create tables")
+	}
+	if err := Migrate(db); err != nil {
+		t.Fatalf("second migrate: %v", err)
+	}
+
+	status, err := GetMigrationStatus(db)
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if status.Current != LatestSchemaVersion() || len(status.Applied) != len(migrations) || len(status.Pending) != 0 {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+}
+
+func TestMigrate_AppliesPendingAndRollsBackFailures(t *testing.T) {
+	type widget struct {
+		ID   uint
+		Name string
+	}
+	db := openMigrationTestDB(t)
+	list := []Migration{
+		{Version: 1, Name: "create widgets", Up: func(tx *gorm.DB) error { return tx.Migrator().CreateTable(&widget{}) }},
+	}
+	if err := runMigrations(db, list); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	type widgetV2 struct {
+		ID    uint
+		Name  string
+		Color string
+	}
+	boom := errors.New("boom")
+	list = append(list, Migration{Version: 2, Name: "add color", Up: func(tx *gorm.DB) error {
+		if err := addColumnIfMissing(tx.Table("widgets"), &widgetV2{}, "Color"); err != nil {
+			return err
+		}
+		return boom
+	}})
+	if err := runMigrations(db, list); !errors.Is(err, boom) {
+		t.Fatalf("expected failing migration error, got %v", err)
+	}
+	status, _ := migrationStatus(db, list)
+	if status.Current != 1 || len(status.Pending) != 1 {
+		t.Fatalf("failed migration must not be recorded: %+v", status)
+	}
+	if db.Table("widgets").Migrator().HasColumn(&widgetV2{}, "Color") {
+		t.Fatalf("failed migration must be rolled back")
+	}
+
+	list[1].Up = func(tx *gorm.DB) error { return addColumnIfMissing(tx.Table("widgets"), &widgetV2{}, "Color") }
+	if err := runMigrations(db, list); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if !db.Table("widgets").Migrator().HasColumn(&widgetV2{}, "Color") {
+		t.Fatalf("expected color column")
+	}
+}
+
+func TestMigrate_RefusesNewerSchema(t *testing.T) {
+	db := openMigrationTestDB(t)
+	if err := Migrate(db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	future := LatestSchemaVersion() + 1
+	if err := db.Create(&schemaVersion{Version: future, Name: "from the future"}).Error; err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	if err := Migrate(db); !errors.Is(err, ErrSchemaTooNew) {
+		t.Fatalf("expected ErrSchemaTooNew, got %v", err)
+	}
+	status, err := GetMigrationStatus(db)
+	if err != nil || !status.TooNew || status.Current != future {
+		t.Fatalf("unexpected status: %+v %v", status, err)
+	}
+}
+
+func TestMigrate_WorkspacesUpgradesLegacySchema(t *testing.T) {
+	type legacyBastion struct {
+		ID       uint   `gorm:"primaryKey"`
+		Name     string `gorm:"uniqueIndex;not null"`
+		Host     string `gorm:"not null"`
+		Username string `gorm:"not null"`
+	}
+	type legacyMapping struct {
+		ID        string `gorm:"primaryKey"`
+		LocalPort int    `gorm:"not null"`
+		ChainJSON string `gorm:"column:chain_json;default:'[]'"`
+	}
+	db := openMigrationTestDB(t)
+	if err := db.Table("bastions").AutoMigrate(&legacyBastion{}); err != nil {
+		t.Fatalf("legacy bastions: %v", err)
+	}
+	if err := db.Table("mappings").AutoMigrate(&legacyMapping{}); err != nil {
+		t.Fatalf("legacy mappings: %v", err)
+	}
+	db.Table("bastions").Create(&legacyBastion{Name: "jump", Host: "10.0.0.1", Username: "u"})
+	db.Table("mappings").Create(&legacyMapping{ID: "db", LocalPort: 5432, ChainJSON: `["jump"]`})
+
+	if err := Migrate(db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	var m models.Mapping
+	if err := db.First(&m, "id = ?", "db").Error; err != nil || m.Workspace != models.DefaultWorkspace || m.LocalPort != 5432 || m.ChainJSON != `["jump"]` {
+		t.Fatalf("legacy mapping not carried over: %+v %v", m, err)
+	}
+	if err := db.Create(&models.Mapping{ID: "db", Workspace: "client-b", LocalPort: 15432}).Error; err != nil {
+		t.Fatalf("same mapping ID in another workspace: %v", err)
+	}
+	if err := db.Create(&models.Mapping{ID: "db", Workspace: "client-b", LocalPort: 15433}).Error; err == nil {
+		t.Fatalf("expected duplicate mapping ID within a workspace to fail")
+	}
+
+	var b models.Bastion
+	if err := db.First(&b, "name = ?", "jump").Error; err != nil || b.Workspace != models.DefaultWorkspace {
+		t.Fatalf("legacy bastion not carried over: %+v %v", b, err)
+	}
+	if err := db.Create(&models.Bastion{Name: "jump", Workspace: "client-b", Host: "10.1.0.1", Username: "u"}).Error; err != nil {
+		t.Fatalf("same bastion name in another workspace: %v", err)
+	}
+	if err := db.Create(&models.Bastion{Name: "jump", Workspace: "client-b", Host: "10.1.0.2", Username: "u"}).Error; err == nil {
+		t.Fatalf("expected duplicate bastion name within a workspace to fail")
+	}
+}
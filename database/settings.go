@@ -58,3 +58,14 @@ func DeleteSetting(key string) error {
 
 	return DB.Where("key = ?", key).Delete(&models.AppSetting{}).Error
 }
+
+// ListSettings returns the settings whose key starts with prefix, ordered by key.
+func ListSettings(prefix string) ([]models.AppSetting, error) {
+	if DB == nil {
+		return nil, errors.New("database not initialized")
+	}
+
+	var settings []models.AppSetting
+	err := DB.Where("substr(key, 1, ?) = ?", len(prefix), prefix).Order("key").Find(&settings).Error
+	return settings, err
+}
@@ -5,7 +5,6 @@ import (
 	"errors"
 	"strings"
 	"sync/atomic"
-	"time"
 )
 
 var sqliteBusyErrors uint64
@@ -59,26 +58,3 @@ func SQLiteBusyErrorsTotal() uint64 {
 func SQLiteLockedErrorsTotal() uint64 {
 	return atomic.LoadUint64(&sqliteLockedErrors)
 }
-
-// SQLiteUp reports whether the package-level database is reachable by pinging its underlying sql.DB.
-// It returns `true` if the database responds to a ping, `false` otherwise. If the global DB is nil
-// or obtaining the underlying *sql.DB fails it returns `false`. If the provided context has no
-// deadline or the deadline has already expired, a 200ms timeout is used for the ping.
-func SQLiteUp(ctx context.Context) bool {
-	if DB == nil {
-		return false
-	}
-
-	sqlDB, err := DB.DB()
-	if err != nil {
-		return false
-	}
-
-	if deadline, ok := ctx.Deadline(); !ok || time.Until(deadline) <= 0 {
-		var cancel context.CancelFunc
-		ctx, cancel = context.WithTimeout(ctx, 200*time.Millisecond)
-		defer cancel()
-	}
-
-	return sqlDB.PingContext(ctx) == nil
-}
@@ -7,17 +7,17 @@ import (
 	"strings"
 )
 
-type sqlitePoolConfig struct {
+type poolConfig struct {
 	maxOpenConns int
 	maxIdleConns int
 	maxIdleSec   int
 	maxLifeSec   int
 }
 
-// sanitizeSQLitePoolConfig normalizes a sqlitePoolConfig, enforcing sensible bounds on its fields.
+// sanitizePoolConfig normalizes a poolConfig, enforcing sensible bounds on its fields.
 // It ensures maxOpenConns is at least 1, clamps maxIdleConns to the range [0, maxOpenConns],
 // and forces maxIdleSec and maxLifeSec to be at least 0. The sanitized config is returned.
-func sanitizeSQLitePoolConfig(cfg sqlitePoolConfig) sqlitePoolConfig {
+func sanitizePoolConfig(cfg poolConfig) poolConfig {
 	if cfg.maxOpenConns < 1 {
 		cfg.maxOpenConns = 1
 	}
@@ -74,13 +74,19 @@ func buildSQLiteDSN(dbPath string, settings *config.Config) string {
 	return base + "?" + encoded
 }
 
-// currentSQLitePoolConfig builds a sqlitePoolConfig from the provided Config's SQLite
-// connection and pool settings and enforces sane bounds.
-//
-// It reads SQLiteMaxOpenConns, SQLiteMaxIdleConns, SQLiteConnMaxIdleSec and
-// SQLiteConnMaxLifeSec from settings and returns the sanitized configuration.
-func currentSQLitePoolConfig(settings *config.Config) sqlitePoolConfig {
-	return sanitizeSQLitePoolConfig(sqlitePoolConfig{
+// currentPoolConfig builds the connection pool settings of backend from the provided Config and
+// enforces sane bounds: SQLiteMaxOpenConns, SQLiteMaxIdleConns, SQLiteConnMaxIdleSec and
+// SQLiteConnMaxLifeSec for SQLite, the DB* equivalents for Postgres and MySQL.
+func currentPoolConfig(settings *config.Config, backend string) poolConfig {
+	if backend != BackendSQLite {
+		return sanitizePoolConfig(poolConfig{
+			maxOpenConns: settings.DBMaxOpenConns,
+			maxIdleConns: settings.DBMaxIdleConns,
+			maxIdleSec:   settings.DBConnMaxIdleSec,
+			maxLifeSec:   settings.DBConnMaxLifeSec,
+		})
+	}
+	return sanitizePoolConfig(poolConfig{
 		maxOpenConns: settings.SQLiteMaxOpenConns,
 		maxIdleConns: settings.SQLiteMaxIdleConns,
 		maxIdleSec:   settings.SQLiteConnMaxIdleSec,
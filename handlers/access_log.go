@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"bastion/core"
+	"bastion/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// responseCodeContextKey holds the envelope code respondV2 answered with.
+const responseCodeContextKey = "response_code"
+
+const (
+	accessLogsPath            = "/api/v2/access-logs"
+	maxAccessLogPageSize      = 500
+	defaultAccessLogsPageSize = 50
+)
+
+// AccessLog records every /api request in core.AccessLogs once it has been answered. Reads of the
+// access log itself are left out so a polling viewer does not fill the log with its own requests.
+func AccessLog() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		path := c.Request.URL.Path
+		if !core.AccessLogs.Enabled() || !strings.HasPrefix(path, "/api/") || (path == accessLogsPath && c.Request.Method == "GET") {
+			c.Next()
+			return
+		}
+
+		start := time.Now()
+		c.Next()
+
+		core.AccessLogs.Record(&models.AccessLog{
+			Timestamp: start,
+			Method:    c.Request.Method,
+			Path:      path,
+			Query:     c.Request.URL.RawQuery,
+			Status:    c.Writer.Status(),
+			Code:      c.GetString(responseCodeContextKey),
+			LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+			ClientIP:  c.ClientIP(),
+			RequestID: c.GetString(requestIDContextKey),
+			Workspace: c.GetString(workspaceContextKey),
+			Bytes:     c.Writer.Size(),
+		})
+	}
+}
+
+// GetAccessLogsV2 lists recent API requests, latest first.
+// Query: method, path (substring), status, code, errors=true, client_ip, request_id, min_latency_ms,
+// since, until (unix seconds or RFC3339), page, page_size.
+func GetAccessLogsV2(c *gin.Context) {
+	page := 1
+	pageSize := defaultAccessLogsPageSize
+	if pageStr := c.Query("page"); pageStr != "" {
+		if p, err := strconv.Atoi(pageStr); err == nil && p > 0 {
+			page = p
+		}
+	}
+	if sizeStr := c.Query("page_size"); sizeStr != "" {
+		if s, err := strconv.Atoi(sizeStr); err == nil && s > 0 {
+			pageSize = s
+		}
+	}
+	if pageSize > maxAccessLogPageSize {
+		pageSize = maxAccessLogPageSize
+	}
+
+	filter := models.AccessLogFilter{
+		Method:    strings.TrimSpace(c.Query("method")),
+		Path:      strings.TrimSpace(c.Query("path")),
+		Code:      strings.TrimSpace(c.Query("code")),
+		ClientIP:  strings.TrimSpace(c.Query("client_ip")),
+		RequestID: strings.TrimSpace(c.Query("request_id")),
+	}
+	if statusStr := strings.TrimSpace(c.Query("status")); statusStr != "" {
+		status, err := strconv.Atoi(statusStr)
+		if err != nil || status < 100 || status > 599 {
+			errV2(c, CodeInvalidRequest, "Invalid status code", "invalid status")
+			return
+		}
+		filter.Status = status
+	}
+	if errorsStr := c.Query("errors"); errorsStr != "" {
+		errorsOnly, err := strconv.ParseBool(errorsStr)
+		if err != nil {
+			errV2(c, CodeInvalidRequest, "Invalid errors flag", "invalid errors")
+			return
+		}
+		filter.ErrorsOnly = errorsOnly
+	}
+	if latencyStr := strings.TrimSpace(c.Query("min_latency_ms")); latencyStr != "" {
+		latency, err := strconv.ParseFloat(latencyStr, 64)
+		if err != nil || latency < 0 {
+			errV2(c, CodeInvalidRequest, "Invalid min_latency_ms", "invalid min_latency_ms")
+			return
+		}
+		filter.MinLatencyMS = latency
+	}
+
+	var err error
+	if filter.Since, err = parseTimeParam(c.Query("since")); err != nil {
+		errV2(c, CodeInvalidRequest, "Invalid since timestamp", "invalid since")
+		return
+	}
+	if filter.Until, err = parseTimeParam(c.Query("until")); err != nil {
+		errV2(c, CodeInvalidRequest, "Invalid until timestamp", "invalid until")
+		return
+	}
+
+	entries, total := core.AccessLogs.Query(filter, page, pageSize)
+	okV2(c, gin.H{
+		"items":     entries,
+		"page":      page,
+		"page_size": pageSize,
+		"total":     total,
+		"enabled":   core.AccessLogs.Enabled(),
+	})
+}
+
+func ClearAccessLogsV2(c *gin.Context) {
+	core.AccessLogs.Clear()
+	okV2(c, gin.H{"ok": true})
+}
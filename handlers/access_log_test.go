@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"bastion/core"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAccessLog_RecordsEnvelopeCode(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	prev := core.AccessLogs
+	core.AccessLogs = core.NewAccessLogStore(10)
+	t.Cleanup(func() { core.AccessLogs = prev })
+
+	r := gin.New()
+	r.Use(RequestID(), AccessLog())
+	r.GET("/api/v2/mappings/:id", func(c *gin.Context) { errV2(c, CodeNotFound, "Mapping not found", "no such mapping") })
+	r.GET("/api/v2/access-logs", GetAccessLogsV2)
+	r.GET("/web/index.html", func(c *gin.Context) { c.String(http.StatusOK, "ui") })
+
+	for _, path := range []string{"/api/v2/mappings/db?x=1", "/api/v2/access-logs", "/web/index.html"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	if core.AccessLogs.Len() != 1 {
+		t.Fatalf("expected only the API request to be recorded, got %d entries", core.AccessLogs.Len())
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v2/access-logs?code=NOT_FOUND", nil))
+	var resp struct {
+		Data struct {
+			Items []struct {
+				Path      string `json:"path"`
+				Query     string `json:"query"`
+				Status    int    `json:"status"`
+				Code      string `json:"code"`
+				RequestID string `json:"request_id"`
+			} `json:"items"`
+			Total int `json:"total"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	page := resp.Data
+	if page.Total != 1 {
+		t.Fatalf("expected one NOT_FOUND entry, got %+v", page)
+	}
+	entry := page.Items[0]
+	if entry.Path != "/api/v2/mappings/db" || entry.Query != "x=1" || entry.Status != http.StatusOK || entry.Code != CodeNotFound || entry.RequestID == "" {
+		t.Fatalf("unexpected entry %+v", entry)
+	}
+}
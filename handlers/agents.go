@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"bastion/models"
+	"bastion/service"
+	"errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListAgentsV2 lists the agents that run workspaces of this instance, with their last report.
+func ListAgentsV2(c *gin.Context) {
+	agents, err := service.GlobalServices.Agents.List()
+	if err != nil {
+		errV2(c, CodeInternal, "Failed to list agents", err)
+		return
+	}
+	okV2(c, agents)
+}
+
+// ReportAgentV2 registers an agent, or refreshes it, with the state of the mappings it runs. The
+// agent sends it after every sync.
+func ReportAgentV2(c *gin.Context) {
+	var report models.AgentReport
+	if err := c.ShouldBindJSON(&report); err != nil {
+		errV2(c, CodeInvalidRequest, "Invalid agent report", err)
+		return
+	}
+
+	as, _ := c.Value(agentContextKey).(*models.Agent)
+	agent, err := service.GlobalServices.Agents.Report(report, c.RemoteIP(), as)
+	switch {
+	case err == nil:
+		okV2(c, agent)
+	case errors.Is(err, service.ErrInvalidAgentReport):
+		errV2(c, CodeInvalidRequest, "Invalid agent report", err)
+	case errors.Is(err, service.ErrAgentWorkspaceTaken):
+		errV2(c, CodeConflict, "Invalid agent report", err)
+	case errors.Is(err, service.ErrAgentTokenScope):
+		errV2(c, CodeUnauthorized, "Invalid agent report", err)
+	default:
+		errV2(c, CodeInternal, "Failed to save agent report", err)
+	}
+}
+
+// IssueAgentTokenV2 gives an agent a new token that only reports the agent and reads its
+// workspace, registering the agent for the workspace when it is new. The token is only shown in
+// this response.
+func IssueAgentTokenV2(c *gin.Context) {
+	var req models.AgentTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errV2(c, CodeInvalidRequest, "Invalid request", err)
+		return
+	}
+
+	token, err := service.GlobalServices.Agents.IssueToken(req)
+	switch {
+	case err == nil:
+		okV2(c, token)
+	case errors.Is(err, service.ErrInvalidAgentReport):
+		errV2(c, CodeInvalidRequest, "Failed to issue agent token", err)
+	case errors.Is(err, service.ErrAgentWorkspaceTaken):
+		errV2(c, CodeConflict, "Failed to issue agent token", err)
+	default:
+		errV2(c, CodeInternal, "Failed to issue agent token", err)
+	}
+}
+
+// DeleteAgentV2 forgets an agent; this instance auto-starts its workspace again.
+func DeleteAgentV2(c *gin.Context) {
+	err := service.GlobalServices.Agents.Delete(c.Param("name"))
+	switch {
+	case err == nil:
+		okV2(c, gin.H{"ok": true})
+	case errors.Is(err, service.ErrAgentNotFound):
+		errV2(c, CodeNotFound, "Agent not found", err)
+	default:
+		errV2(c, CodeInternal, "Failed to delete agent", err)
+	}
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"bastion/core"
+	"errors"
+	"io"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetAlertStatus returns alert targets summary and delivery counters
+func GetAlertStatus(c *gin.Context) {
+	okV2(c, core.AlerterInstance.Stats())
+}
+
+// TestAlert sends a test alert synchronously to all configured targets
+func TestAlert(c *gin.Context) {
+	var req struct {
+		Message string `json:"message"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		errV2(c, CodeInvalidRequest, "Invalid request", err)
+		return
+	}
+	if req.Message == "" {
+		req.Message = "Test alert from Bastion"
+	}
+
+	result, err := core.AlerterInstance.SendNow(core.AlertEvent{
+		Type:     core.AlertTest,
+		Severity: "INFO",
+		Message:  req.Message,
+	})
+	if err != nil {
+		errV2(c, CodeInvalidRequest, "No alert targets configured", err)
+		return
+	}
+	if len(result.Errors) > 0 && result.Webhooks == 0 && !result.Email {
+		errV2(c, CodeBadGateway, "Alert delivery failed", result)
+		return
+	}
+	okV2(c, result)
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The v1 API (/api) is deprecated in favour of /api/v2 and served only with --api-compat=v1.
+var (
+	apiV1DeprecatedAt = time.Date(2026, time.October, 15, 0, 0, 0, 0, time.UTC)
+	apiV1SunsetAt     = time.Date(2027, time.April, 15, 0, 0, 0, 0, time.UTC)
+)
+
+const apiV1ContextKey = "api_v1"
+
+// DeprecatedV1 marks the requests of the v1 routes, which are served by the v2 handlers, and
+// announces the deprecation on every response: Deprecation (RFC 9745), Sunset (RFC 8594) and a
+// successor-version Link to the /api/v2 equivalent.
+func DeprecatedV1() gin.HandlerFunc {
+	deprecation := "@" + strconv.FormatInt(apiV1DeprecatedAt.Unix(), 10)
+	sunset := apiV1SunsetAt.Format(http.TimeFormat)
+	return func(c *gin.Context) {
+		c.Set(apiV1ContextKey, true)
+		c.Header("Deprecation", deprecation)
+		c.Header("Sunset", sunset)
+		c.Header("Link", "<"+v2Path(c.Request.URL.Path)+`>; rel="successor-version"`)
+		c.Next()
+	}
+}
+
+// isV1 reports whether the request came in through a deprecated v1 route.
+func isV1(c *gin.Context) bool {
+	return c.GetBool(apiV1ContextKey)
+}
+
+// v2Path maps a v1 path to its /api/v2 equivalent.
+func v2Path(path string) string {
+	return "/api/v2" + strings.TrimPrefix(path, "/api")
+}
+
+// pageItemsKey names the items of a paged response: "data" as the v1 API always did, "items" on v2.
+func pageItemsKey(c *gin.Context) string {
+	if isV1(c) {
+		return "data"
+	}
+	return "items"
+}
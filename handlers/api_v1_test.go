@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestDeprecatedV1_RoutesThroughV2Handlers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	update := func(c *gin.Context) {
+		version, err := requireVersion(c, 0)
+		if err != nil {
+			return
+		}
+		okV2(c, gin.H{"version": version, pageItemsKey(c): []int{}})
+	}
+	r := gin.New()
+	r.Group("/api", DeprecatedV1()).PUT("/mappings/:id", update)
+	r.Group("/api/v2").PUT("/mappings/:id", update)
+
+	// v1: deprecation headers, optional version, legacy "data" key
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/api/mappings/m1", nil))
+	if got := w.Header().Get("Deprecation"); got != "@1792022400" {
+		t.Fatalf("Deprecation = %q", got)
+	}
+	if got := w.Header().Get("Sunset"); got != "Thu, 15 Apr 2027 00:00:00 GMT" {
+		t.Fatalf("Sunset = %q", got)
+	}
+	if got := w.Header().Get("Link"); got != `</api/v2/mappings/m1>; rel="successor-version"` {
+		t.Fatalf("Link = %q", got)
+	}
+	var resp struct {
+		Code string                     `json:"code"`
+		Data map[string]json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.Code != CodeOK || resp.Data["data"] == nil {
+		t.Fatalf("v1 response %s", w.Body.String())
+	}
+
+	// v2: no deprecation headers, version required
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/api/v2/mappings/m1", nil))
+	if w.Header().Get("Deprecation") != "" || w.Header().Get("Sunset") != "" {
+		t.Fatalf("v2 response carries deprecation headers: %v", w.Header())
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.Code != CodeInvalidRequest {
+		t.Fatalf("v2 update without version: code %s, want %s", resp.Code, CodeInvalidRequest)
+	}
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"bastion/models"
+	"bastion/service"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v3"
+)
+
+// maxApplyBodyBytes bounds the document of an apply request.
+const maxApplyBodyBytes = 4 << 20
+
+// ApplyV2 reconciles the request workspace with a YAML (or JSON) document declaring its bastions
+// and mappings; see service.MappingService.Apply. ?prune=true and ?dry_run=true turn on the
+// options of the same name. The response lists every change; when one fails nothing is changed and
+// the code is INVALID_REQUEST with the same result.
+func ApplyV2(c *gin.Context) {
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxApplyBodyBytes+1))
+	if err != nil {
+		errV2(c, CodeInvalidRequest, "Invalid request", err)
+		return
+	}
+	if len(body) > maxApplyBodyBytes {
+		errV2(c, CodeInvalidRequest, "Invalid request", fmt.Sprintf("document exceeds %d bytes", maxApplyBodyBytes))
+		return
+	}
+	req, err := decodeApplyRequest(body)
+	if err != nil {
+		errV2(c, CodeInvalidRequest, "Invalid apply document", err)
+		return
+	}
+	if c.Query("prune") == "true" {
+		req.Prune = true
+	}
+	if c.Query("dry_run") == "true" {
+		req.DryRun = true
+	}
+
+	result, err := scopedServices(c).Mapping.Apply(req)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidApplyRequest) {
+			errV2(c, CodeInvalidRequest, "Invalid apply request", err)
+			return
+		}
+		errV2(c, CodeInternal, "Failed to apply", err)
+		return
+	}
+	if result.Failed > 0 {
+		respondV2(c, CodeInvalidRequest, "Apply failed; nothing was changed", result)
+		return
+	}
+	okV2(c, result)
+}
+
+// decodeApplyRequest parses an apply document. YAML is converted to JSON first (JSON is valid
+// YAML), so both use the field names of the JSON API; unknown fields are rejected to catch typos.
+func decodeApplyRequest(body []byte) (models.ApplyRequest, error) {
+	var req models.ApplyRequest
+	var doc any
+	if err := yaml.Unmarshal(body, &doc); err != nil {
+		return req, err
+	}
+	if doc == nil {
+		return req, nil
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return req, fmt.Errorf("unsupported document: %w", err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		return req, err
+	}
+	return req, nil
+}
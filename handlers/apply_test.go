@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDecodeApplyRequest(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		bastions int
+		mappings int
+		prune    bool
+		wantErr  string
+	}{
+		{name: "empty", body: ""},
+		{
+			name: "yaml",
+			body: `
+bastions:
+  - name: jump
+    host: 10.0.0.1
+    username: ops
+mappings:
+  - id: db
+    local_port: 15432
+    remote_host: db.internal
+    remote_port: 5432
+    chain: [jump]
+prune: true
+`,
+			bastions: 1, mappings: 1, prune: true,
+		},
+		{name: "json", body: `{"mappings":[{"id":"a","local_port":1}]}`, mappings: 1},
+		{name: "unknown field", body: "mapings: []\n", wantErr: `unknown field "mapings"`},
+		{name: "wrong type", body: "mappings:\n  - local_port: abc\n", wantErr: "cannot unmarshal"},
+		{name: "invalid yaml", body: "bastions: [\n", wantErr: "yaml"},
+	}
+	for _, tt := range tests {
+		req, err := decodeApplyRequest([]byte(tt.body))
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("%s: err = %v, want %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if len(req.Bastions) != tt.bastions || len(req.Mappings) != tt.mappings || req.Prune != tt.prune {
+			t.Fatalf("%s: got %d bastions, %d mappings, prune %v", tt.name, len(req.Bastions), len(req.Mappings), req.Prune)
+		}
+	}
+}
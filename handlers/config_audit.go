@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"bastion/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	configAuditResources = []string{models.ConfigAuditBastion, models.ConfigAuditMapping}
+	configAuditActions   = []string{
+		models.ConfigAuditCreate, models.ConfigAuditUpdate, models.ConfigAuditDelete,
+		models.ConfigAuditStart, models.ConfigAuditStop, models.ConfigAuditExpose, models.ConfigAuditUnexpose,
+	}
+)
+
+const maxConfigAuditPageSize = 500
+
+// GetConfigAuditV2 lists configuration changes of the request workspace, latest first.
+// Query: resource, resource_id, action, actor, since, until (unix seconds or RFC3339), page, page_size.
+func GetConfigAuditV2(c *gin.Context) {
+	page := 1
+	pageSize := 50
+	if pageStr := c.Query("page"); pageStr != "" {
+		if p, err := strconv.Atoi(pageStr); err == nil && p > 0 {
+			page = p
+		}
+	}
+	if sizeStr := c.Query("page_size"); sizeStr != "" {
+		if s, err := strconv.Atoi(sizeStr); err == nil && s > 0 {
+			pageSize = s
+		}
+	}
+	if pageSize > maxConfigAuditPageSize {
+		pageSize = maxConfigAuditPageSize
+	}
+
+	filter := models.ConfigAuditFilter{
+		Resource:   strings.ToLower(strings.TrimSpace(c.Query("resource"))),
+		ResourceID: strings.TrimSpace(c.Query("resource_id")),
+		Action:     strings.ToLower(strings.TrimSpace(c.Query("action"))),
+		Actor:      strings.TrimSpace(c.Query("actor")),
+	}
+	if filter.Resource != "" && !containsValue(configAuditResources, filter.Resource) {
+		errV2(c, CodeInvalidRequest, "Invalid resource", "resource must be one of: "+strings.Join(configAuditResources, ", "))
+		return
+	}
+	if filter.Action != "" && !containsValue(configAuditActions, filter.Action) {
+		errV2(c, CodeInvalidRequest, "Invalid action", "action must be one of: "+strings.Join(configAuditActions, ", "))
+		return
+	}
+
+	var err error
+	if filter.Since, err = parseTimeParam(c.Query("since")); err != nil {
+		errV2(c, CodeInvalidRequest, "Invalid since timestamp", "invalid since")
+		return
+	}
+	if filter.Until, err = parseTimeParam(c.Query("until")); err != nil {
+		errV2(c, CodeInvalidRequest, "Invalid until timestamp", "invalid until")
+		return
+	}
+
+	entries, total, err := scopedServices(c).ConfigAudit.List(filter, page, pageSize)
+	if err != nil {
+		errV2(c, CodeInternal, "Failed to list config audit entries", err)
+		return
+	}
+	okV2(c, gin.H{
+		"items":     entries,
+		"page":      page,
+		"page_size": pageSize,
+		"total":     total,
+	})
+}
+
+// parseTimeParam parses unix seconds or an RFC3339 timestamp; empty values yield nil.
+func parseTimeParam(value string) (*time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+	if unix, err := strconv.ParseInt(value, 10, 64); err == nil {
+		tm := time.Unix(unix, 0)
+		return &tm, nil
+	}
+	tm, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+	return &tm, nil
+}
+
+func containsValue(list []string, v string) bool {
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"bastion/database"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BackupDatabaseV2 snapshots the database. With a path the file is saved on the server (relative
+// paths resolve against DB_BACKUP_DIR); without one it is streamed back as a download.
+func BackupDatabaseV2(c *gin.Context) {
+	var req struct {
+		Path string `json:"path"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		errV2(c, CodeInvalidRequest, "Invalid request", err)
+		return
+	}
+
+	if req.Path != "" {
+		info, err := database.Backup(database.DB, database.ResolveBackupPath(req.Path))
+		if err != nil {
+			if errors.Is(err, database.ErrBackupExists) {
+				errV2(c, CodeConflict, "Backup file already exists", err)
+				return
+			}
+			maintenanceError(c, "Backup failed", err)
+			return
+		}
+		okV2(c, info)
+		return
+	}
+
+	dir, err := os.MkdirTemp("", "bastion-backup-")
+	if err != nil {
+		errV2(c, CodeInternal, "Backup failed", err)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	name := "bastion-" + time.Now().Format("20060102-150405") + ".db"
+	info, err := database.Backup(database.DB, filepath.Join(dir, name))
+	if err != nil {
+		maintenanceError(c, "Backup failed", err)
+		return
+	}
+	c.Header("Content-Length", strconv.FormatInt(info.SizeBytes, 10))
+	c.FileAttachment(info.Path, name)
+}
+
+// VacuumDatabaseV2 runs VACUUM to reclaim free pages.
+func VacuumDatabaseV2(c *gin.Context) {
+	result, err := database.Vacuum(database.DB)
+	if err != nil {
+		maintenanceError(c, "Vacuum failed", err)
+		return
+	}
+	okV2(c, result)
+}
+
+// CheckDatabaseIntegrityV2 runs PRAGMA integrity_check (quick_check with ?quick=true).
+func CheckDatabaseIntegrityV2(c *gin.Context) {
+	quick, _ := strconv.ParseBool(c.Query("quick"))
+	result, err := database.IntegrityCheck(database.DB, quick)
+	if err != nil {
+		maintenanceError(c, "Integrity check failed", err)
+		return
+	}
+	okV2(c, result)
+}
+
+// maintenanceError reports a failed maintenance operation; the SQLite-only ones are a request error
+// on Postgres and MySQL.
+func maintenanceError(c *gin.Context, message string, err error) {
+	if errors.Is(err, database.ErrUnsupportedBackend) {
+		errV2(c, CodeInvalidRequest, message, err)
+		return
+	}
+	errV2(c, CodeInternal, message, err)
+}
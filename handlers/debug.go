@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"bastion/core"
+	"bastion/state"
+	"net/http/pprof"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DebugPprof serves the net/http/pprof profiles under /debug/pprof/. It is only registered with
+// --debug-endpoints, behind the admin token.
+func DebugPprof(c *gin.Context) {
+	switch strings.TrimPrefix(c.Param("name"), "/") {
+	case "cmdline":
+		pprof.Cmdline(c.Writer, c.Request)
+	case "profile":
+		pprof.Profile(c.Writer, c.Request)
+	case "symbol":
+		pprof.Symbol(c.Writer, c.Request)
+	case "trace":
+		pprof.Trace(c.Writer, c.Request)
+	default:
+		// The index, and named profiles such as /debug/pprof/goroutine?debug=2
+		pprof.Index(c.Writer, c.Request)
+	}
+}
+
+// GetGoroutinesV2 returns the stacks of all goroutines grouped by state and stack, largest groups
+// first. ?min_count=N drops smaller groups and ?q= keeps the groups whose state, stack or creator
+// contains the text (case-insensitive).
+func GetGoroutinesV2(c *gin.Context) {
+	minCount := 1
+	if v := c.Query("min_count"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			errV2(c, CodeInvalidRequest, "Invalid min_count", "min_count must be a positive integer")
+			return
+		}
+		minCount = n
+	}
+	q := strings.ToLower(strings.TrimSpace(c.Query("q")))
+
+	dump := core.DumpGoroutines()
+	groups := dump.Groups[:0]
+	for _, g := range dump.Groups {
+		if g.Count < minCount || (q != "" && !goroutineGroupMatches(g, q)) {
+			continue
+		}
+		groups = append(groups, g)
+	}
+	dump.Groups = groups
+	okV2(c, dump)
+}
+
+func goroutineGroupMatches(g core.GoroutineGroup, q string) bool {
+	if strings.Contains(strings.ToLower(g.State), q) || strings.Contains(strings.ToLower(g.CreatedBy), q) {
+		return true
+	}
+	for _, frame := range g.Stack {
+		if strings.Contains(strings.ToLower(frame), q) {
+			return true
+		}
+	}
+	return false
+}
+
+// Rankings of GetDebugTopV2.
+const (
+	debugTopMemory      = "memory"
+	debugTopCPU         = "cpu"
+	debugTopGoroutines  = "goroutines"
+	debugTopConnections = "connections"
+)
+
+// sessionTopItem is one running mapping in GetDebugTopV2.
+type sessionTopItem struct {
+	MappingID string `json:"mapping_id"`
+	core.SessionResources
+}
+
+// GetDebugTopV2 ranks the running mappings by their resource share, to find the tunnel behind a
+// growing process. ?sort= is memory (forwarding buffers plus audit parsers, default), cpu (traffic
+// moved, the stand-in for CPU), goroutines or connections; ?limit= defaults to 10.
+func GetDebugTopV2(c *gin.Context) {
+	sortBy := strings.ToLower(strings.TrimSpace(c.DefaultQuery("sort", debugTopMemory)))
+	var key func(r core.SessionResources) int64
+	switch sortBy {
+	case debugTopMemory:
+		key = func(r core.SessionResources) int64 { return r.MemoryBytes }
+	case debugTopCPU:
+		key = func(r core.SessionResources) int64 { return r.BytesPerSec }
+	case debugTopGoroutines:
+		key = func(r core.SessionResources) int64 { return r.Goroutines }
+	case debugTopConnections:
+		key = func(r core.SessionResources) int64 { return int64(r.Connections) }
+	default:
+		errV2(c, CodeInvalidRequest, "Invalid sort", "sort must be memory, cpu, goroutines or connections")
+		return
+	}
+	limit := 10
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			errV2(c, CodeInvalidRequest, "Invalid limit", "limit must be a positive integer")
+			return
+		}
+		limit = n
+	}
+
+	items := []sessionTopItem{}
+	state.Global.RLock()
+	for id, session := range state.Global.Sessions {
+		if r, ok := session.(interface{ Resources() core.SessionResources }); ok {
+			items = append(items, sessionTopItem{MappingID: id, SessionResources: r.Resources()})
+		}
+	}
+	state.Global.RUnlock()
+
+	sort.Slice(items, func(i, j int) bool {
+		ki, kj := key(items[i].SessionResources), key(items[j].SessionResources)
+		if ki != kj {
+			return ki > kj
+		}
+		return items[i].MappingID < items[j].MappingID
+	})
+	total := len(items)
+	if len(items) > limit {
+		items = items[:limit]
+	}
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	okV2(c, gin.H{
+		"sort":  sortBy,
+		"total": total,
+		"items": items,
+		"process": gin.H{
+			"goroutines":      runtime.NumGoroutine(),
+			"heap_alloc":      mem.HeapAlloc,
+			"sys":             mem.Sys,
+			"forward_buffers": core.ForwardBufferStats(),
+		},
+	})
+}
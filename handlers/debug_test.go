@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"bastion/core"
+	"bastion/state"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestGoroutineGroupMatches(t *testing.T) {
+	g := core.GoroutineGroup{
+		State:     "chan receive",
+		Stack:     []string{"bastion/core.(*BaseSession).acceptLoop /src/core/forwarder.go:180"},
+		CreatedBy: "bastion/core.(*TunnelSession).Start /src/core/forwarder.go:145",
+	}
+	for q, want := range map[string]bool{
+		"chan":          true,
+		"acceptloop":    true,
+		"tunnelsession": true,
+		"forwarder.go":  true,
+		"io wait":       false,
+		"sshsession":    false,
+	} {
+		if got := goroutineGroupMatches(g, q); got != want {
+			t.Fatalf("goroutineGroupMatches(%q) = %v, want %v", q, got, want)
+		}
+	}
+}
+
+type topTestSession struct {
+	res core.SessionResources
+}
+
+func (s *topTestSession) Start() error                     { return nil }
+func (s *topTestSession) Stop()                            {}
+func (s *topTestSession) GetStats() core.SessionStats      { return core.SessionStats{} }
+func (s *topTestSession) Resources() core.SessionResources { return s.res }
+
+func TestGetDebugTopV2(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	state.Global.AddSession("top-a", &topTestSession{res: core.SessionResources{MemoryBytes: 10, BytesPerSec: 500}})
+	state.Global.AddSession("top-b", &topTestSession{res: core.SessionResources{MemoryBytes: 900, BytesPerSec: 5}})
+	t.Cleanup(func() {
+		state.Global.RemoveAndStopSession("top-a")
+		state.Global.RemoveAndStopSession("top-b")
+	})
+
+	r := gin.New()
+	r.GET("/top", GetDebugTopV2)
+	top := func(query string) (string, []string) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/top?"+query, nil))
+		var resp struct {
+			Code string `json:"code"`
+			Data struct {
+				Items []struct {
+					MappingID string `json:"mapping_id"`
+				} `json:"items"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%s: decode: %v", query, err)
+		}
+		var ids []string
+		for _, item := range resp.Data.Items {
+			ids = append(ids, item.MappingID)
+		}
+		return resp.Code, ids
+	}
+
+	if code, ids := top(""); code != CodeOK || len(ids) != 2 || ids[0] != "top-b" {
+		t.Fatalf("expected memory ranking, got %s %v", code, ids)
+	}
+	if _, ids := top("sort=cpu&limit=1"); len(ids) != 1 || ids[0] != "top-a" {
+		t.Fatalf("expected cpu ranking limited to one, got %v", ids)
+	}
+	if code, _ := top("sort=disk"); code != CodeInvalidRequest {
+		t.Fatalf("expected an unknown sort to be rejected, got %s", code)
+	}
+}
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bastion/config"
+	"bastion/core"
+	"bastion/database"
+	"bastion/models"
+	"bastion/version"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Limits of what the diagnostics bundle copies from the log files and the error log.
+const (
+	diagnosticsLogTailBytes = 2 << 20
+	diagnosticsErrorLogs    = 500
+)
+
+// diagnosticsMapping is a mapping as listed in the diagnostics bundle, with its JSON-encoded lists decoded.
+type diagnosticsMapping struct {
+	models.Mapping
+	Chain       []string `json:"chain"`
+	AllowCIDRs  []string `json:"allow_cidrs,omitempty"`
+	DenyCIDRs   []string `json:"deny_cidrs,omitempty"`
+	TargetAllow []string `json:"target_allow,omitempty"`
+	TargetDeny  []string `json:"target_deny,omitempty"`
+}
+
+// DiagnosticsBundleV2 downloads a zip for bug reports: version info, the redacted configuration,
+// bastions and mappings of all workspaces with their secrets stripped, a metrics snapshot, recent
+// error logs and the tail of the server log (and of its rotated predecessor).
+func DiagnosticsBundleV2(c *gin.Context) {
+	now := time.Now()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	addJSON := func(name string, v interface{}) error {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: now})
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+
+	var bastions []models.Bastion
+	var mappings []models.Mapping
+	if err := database.DB.Order("workspace, name").Find(&bastions).Error; err != nil {
+		errV2(c, CodeInternal, "Failed to load bastions", err)
+		return
+	}
+	if err := database.DB.Order("workspace, id").Find(&mappings).Error; err != nil {
+		errV2(c, CodeInternal, "Failed to load mappings", err)
+		return
+	}
+	errorLogs, _, err := core.ErrorLoggerInstance.QueryErrorLogs(models.ErrorLogFilter{}, 1, diagnosticsErrorLogs)
+	if err != nil {
+		errV2(c, CodeInternal, "Failed to query error logs", err)
+		return
+	}
+
+	files := []struct {
+		name string
+		v    interface{}
+	}{
+		{"version.json", gin.H{
+			"version":      version.Version,
+			"commit":       version.CommitHash,
+			"build_time":   version.BuildTime,
+			"go_version":   runtime.Version(),
+			"os":           runtime.GOOS,
+			"arch":         runtime.GOARCH,
+			"generated_at": now,
+		}},
+		{"config.json", config.Settings.Redacted()},
+		{"bastions.json", redactBastions(bastions)},
+		{"mappings.json", redactMappings(mappings)},
+		{"metrics.json", metricsV2()},
+		{"error_logs.json", errorLogs},
+	}
+	for _, f := range files {
+		if err := addJSON(f.name, f.v); err != nil {
+			errV2(c, CodeInternal, "Failed to build diagnostics bundle", err)
+			return
+		}
+	}
+
+	logs := []struct{ name, path string }{
+		{"logs/server.log", config.Settings.LogFilePath},
+		{"logs/server.log.1", config.Settings.LogFilePath + ".1"},
+	}
+	for _, l := range logs {
+		if err := addLogTail(zw, l.name, l.path, now); err != nil {
+			errV2(c, CodeInternal, "Failed to build diagnostics bundle", err)
+			return
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		errV2(c, CodeInternal, "Failed to build diagnostics bundle", err)
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="bastion-diagnostics-%s.zip"`, now.Format("20060102-150405")))
+	c.Data(http.StatusOK, "application/zip", buf.Bytes())
+}
+
+// addLogTail copies the last diagnosticsLogTailBytes of a log file; a missing file is skipped.
+func addLogTail(zw *zip.Writer, name, path string, modified time.Time) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if size := info.Size(); size > diagnosticsLogTailBytes {
+		if _, err := f.Seek(size-diagnosticsLogTailBytes, io.SeekStart); err != nil {
+			return err
+		}
+	}
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// redactBastions strips passwords and key passphrases; key paths stay, as they help diagnose auth.
+func redactBastions(bastions []models.Bastion) []models.Bastion {
+	for i := range bastions {
+		if bastions[i].Password != "" {
+			bastions[i].Password = config.RedactedValue
+		}
+		if bastions[i].PkeyPassphrase != "" {
+			bastions[i].PkeyPassphrase = config.RedactedValue
+		}
+	}
+	return bastions
+}
+
+// redactMappings strips credentials from upstream proxy URLs.
+func redactMappings(mappings []models.Mapping) []diagnosticsMapping {
+	out := make([]diagnosticsMapping, 0, len(mappings))
+	for _, m := range mappings {
+		if m.UpstreamProxy != "" {
+			m.UpstreamProxy = config.RedactURLList(m.UpstreamProxy)
+		}
+		out = append(out, diagnosticsMapping{
+			Mapping:     m,
+			Chain:       m.GetChain(),
+			AllowCIDRs:  m.GetAllowCIDRs(),
+			DenyCIDRs:   m.GetDenyCIDRs(),
+			TargetAllow: m.GetTargetAllow(),
+			TargetDeny:  m.GetTargetDeny(),
+		})
+	}
+	return out
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"bastion/config"
+	"bastion/models"
+	"testing"
+)
+
+func TestConfigRedacted(t *testing.T) {
+	cfg := &config.Config{
+		MetricsToken:      "secret-token",
+		AlertSMTPPassword: "hunter2",
+		AlertSMTPUsername: "ops",
+		AlertWebhookURLs:  "https://hooks.example.com/services/T000/B000/XXXX, http://alerts.local:8080",
+		EventSinkURL:      "syslog://siem.local:514",
+		DatabaseURL:       "./bastion.db",
+	}
+	got := cfg.Redacted()
+
+	want := map[string]interface{}{
+		"MetricsToken":      config.RedactedValue,
+		"AlertSMTPPassword": config.RedactedValue,
+		"AlertSMTPUsername": "ops",
+		"AlertWebhookURLs":  "https://hooks.example.com/" + config.RedactedValue + ",http://alerts.local:8080",
+		"EventSinkURL":      "syslog://siem.local:514",
+		"DatabaseURL":       "./bastion.db",
+		"EventSinkToken":    "",
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %v, want %v", k, got[k], v)
+		}
+	}
+}
+
+func TestRedactDefinitions(t *testing.T) {
+	bastions := redactBastions([]models.Bastion{{Name: "jump", Password: "pw", PkeyPath: "~/.ssh/id", PkeyPassphrase: "pp"}, {Name: "key-only"}})
+	if bastions[0].Password != config.RedactedValue || bastions[0].PkeyPassphrase != config.RedactedValue || bastions[0].PkeyPath != "~/.ssh/id" {
+		t.Fatalf("unexpected bastion %+v", bastions[0])
+	}
+	if bastions[1].Password != "" {
+		t.Fatalf("expected empty secrets to stay empty, got %q", bastions[1].Password)
+	}
+
+	m := models.Mapping{ID: "web", UpstreamProxy: "http://user:pw@proxy.local:3128"}
+	m.SetChain([]string{"jump"})
+	mappings := redactMappings([]models.Mapping{m})
+	if got := mappings[0].UpstreamProxy; got != "http://proxy.local:3128/"+config.RedactedValue {
+		t.Fatalf("unexpected upstream proxy %q", got)
+	}
+	if len(mappings[0].Chain) != 1 || mappings[0].Chain[0] != "jump" {
+		t.Fatalf("unexpected chain %v", mappings[0].Chain)
+	}
+}
@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"bastion/core"
+	"bastion/i18n"
+	"bastion/models"
+	"bastion/service"
+	"encoding/json"
+	"errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Error codes of error responses (data.error.code): the reason of the error, finer than the
+// envelope code and the same in both API versions. Errors without a specific reason use the
+// envelope code.
+const (
+	ErrCodeMalformedBody         = "MALFORMED_BODY"
+	ErrCodeValidationFailed      = "VALIDATION_FAILED"
+	ErrCodeMappingNotFound       = "MAPPING_NOT_FOUND"
+	ErrCodeMappingExists         = "MAPPING_ALREADY_EXISTS"
+	ErrCodeInvalidMappingID      = "INVALID_MAPPING_ID"
+	ErrCodeMappingRunning        = "MAPPING_RUNNING"
+	ErrCodeMappingAlreadyRunning = "MAPPING_ALREADY_RUNNING"
+	ErrCodeMappingNotRunning     = "MAPPING_NOT_RUNNING"
+	ErrCodeMappingRestartFailed  = "MAPPING_RESTART_FAILED"
+	ErrCodeInvalidDependencies   = "INVALID_DEPENDENCIES"
+	ErrCodeTargetUnresolved      = "TARGET_UNRESOLVED"
+	ErrCodeInvalidTargetVariable = "INVALID_TARGET_VARIABLE"
+	ErrCodeInvalidDryRunTarget   = "INVALID_DRY_RUN_TARGET"
+	ErrCodeExposeNotConfirmed    = "EXPOSE_NOT_CONFIRMED"
+	ErrCodeInvalidExposeAddr     = "INVALID_EXPOSE_ADDR"
+	ErrCodePortInUse             = "PORT_IN_USE"
+	ErrCodeVersionConflict       = "VERSION_CONFLICT"
+	ErrCodeBastionNotFound       = "BASTION_NOT_FOUND"
+	ErrCodeBastionInUse          = "BASTION_IN_USE"
+	ErrCodeInvalidCredentials    = "INVALID_CREDENTIALS"
+	ErrCodeCredentialsRejected   = "CREDENTIALS_REJECTED"
+	ErrCodeInvalidBenchmark      = "INVALID_BENCHMARK"
+	ErrCodeBenchmarkFailed       = "BENCHMARK_FAILED"
+	ErrCodeInvalidDockerRequest  = "INVALID_DOCKER_REQUEST"
+	ErrCodeDockerFailed          = "DOCKER_FAILED"
+	ErrCodeInvalidBulkRequest    = "INVALID_BULK_REQUEST"
+	ErrCodeInvalidApplyRequest   = "INVALID_APPLY_REQUEST"
+	ErrCodeSetupCompleted        = "SETUP_COMPLETED"
+	ErrCodeSetupStepOutOfOrder   = "SETUP_STEP_OUT_OF_ORDER"
+	ErrCodeSetupUnknownStep      = "SETUP_UNKNOWN_STEP"
+	ErrCodeHADisabled            = "HA_DISABLED"
+	ErrCodeHAPeerFailed          = "HA_PEER_FAILED"
+	ErrCodeAgentNotFound         = "AGENT_NOT_FOUND"
+	ErrCodeAgentWorkspaceTaken   = "AGENT_WORKSPACE_TAKEN"
+	ErrCodeInvalidAgentReport    = "INVALID_AGENT_REPORT"
+	ErrCodeAgentTokenScope       = "AGENT_TOKEN_SCOPE"
+)
+
+// ErrorCodeInfo describes one code of the error catalog.
+type ErrorCodeInfo struct {
+	Code     string `json:"code"`
+	Category string `json:"category"`        // envelope code of responses with this error
+	Field    string `json:"field,omitempty"` // request field the error is always about
+	Hint     string `json:"hint"`
+}
+
+// errorCatalog lists every error code: the specific ones first, then the envelope codes used for
+// errors without a specific reason.
+var errorCatalog = []ErrorCodeInfo{
+	{Code: ErrCodeMalformedBody, Category: CodeInvalidRequest, Hint: "Send a JSON body with the documented field types."},
+	{Code: ErrCodeValidationFailed, Category: CodeInvalidRequest, Hint: "Correct the value of the named field and retry."},
+	{Code: ErrCodeMappingNotFound, Category: CodeNotFound, Hint: "Check the mapping ID and the workspace of the request."},
+	{Code: ErrCodeMappingExists, Category: CodeConflict, Field: "id", Hint: "Choose another mapping ID, or update the existing mapping."},
+	{Code: ErrCodeInvalidMappingID, Category: CodeInvalidRequest, Field: "id", Hint: "Use a non-empty mapping ID without \"/\"."},
+	{Code: ErrCodeMappingRunning, Category: CodeConflict, Hint: "Stop the mapping first, or use force=true&restart=true where supported."},
+	{Code: ErrCodeMappingAlreadyRunning, Category: CodeConflict, Hint: "No action needed: the mapping is running."},
+	{Code: ErrCodeMappingNotRunning, Category: CodeConflict, Hint: "Start the mapping first."},
+	{Code: ErrCodeMappingRestartFailed, Category: CodeBadGateway, Hint: "The change was saved; fix the reported start error and start the mapping."},
+	{Code: ErrCodeInvalidDependencies, Category: CodeInvalidRequest, Field: "depends_on", Hint: "Remove self references, unknown mappings and cycles from depends_on."},
+	{Code: ErrCodeTargetUnresolved, Category: CodeInvalidRequest, Hint: "Set the target variables the mapping's target templates use."},
+	{Code: ErrCodeInvalidTargetVariable, Category: CodeInvalidRequest, Hint: "Use a non-empty value and a name of 1-64 letters, digits, '_', '-' or '.'."},
+	{Code: ErrCodeInvalidDryRunTarget, Category: CodeInvalidRequest, Field: "target", Hint: "Give the target as host:port; proxy mappings require one."},
+	{Code: ErrCodeExposeNotConfirmed, Category: CodeInvalidRequest, Field: "confirm", Hint: "Repeat the request with confirm=true to expose the mapping beyond localhost."},
+	{Code: ErrCodeInvalidExposeAddr, Category: CodeInvalidRequest, Field: "address", Hint: "Use an address of one of this host's interfaces."},
+	{Code: ErrCodePortInUse, Category: CodeResourceBusy, Field: "local_port", Hint: "Stop the process holding the port, or choose another local_port (0 picks a free one)."},
+	{Code: ErrCodeVersionConflict, Category: CodeConflict, Field: "version", Hint: "Reload the resource and apply your change to the current version."},
+	{Code: ErrCodeBastionNotFound, Category: CodeNotFound, Hint: "Check the bastion ID and the workspace of the request."},
+	{Code: ErrCodeBastionInUse, Category: CodeConflict, Hint: "Remove the bastion from the chains of the mappings using it first."},
+	{Code: ErrCodeInvalidCredentials, Category: CodeInvalidRequest, Hint: "Give a password or a private key path."},
+	{Code: ErrCodeCredentialsRejected, Category: CodeBadGateway, Hint: "Check the credentials against the bastion; nothing was changed."},
+	{Code: ErrCodeInvalidBenchmark, Category: CodeInvalidRequest, Hint: "Use bytes and pings within the documented limits and a host:port target."},
+	{Code: ErrCodeBenchmarkFailed, Category: CodeBadGateway, Hint: "See the failed step in the benchmark report; remote mode needs cat and head on the bastion."},
+	{Code: ErrCodeInvalidDockerRequest, Category: CodeInvalidRequest, Hint: "Use source cli or api, a unix:///path or tcp://host:port host and existing via bastions."},
+	{Code: ErrCodeDockerFailed, Category: CodeBadGateway, Hint: "See the failed hop or the docker error; the SSH user must be allowed to use Docker on the bastion."},
+	{Code: ErrCodeInvalidBulkRequest, Category: CodeInvalidRequest, Hint: "List at least one mapping, and no more than the documented maximum."},
+	{Code: ErrCodeInvalidApplyRequest, Category: CodeInvalidRequest, Hint: "Fix the request as described by the detail; nothing was changed."},
+	{Code: ErrCodeSetupCompleted, Category: CodeConflict, Hint: "Setup is done; manage bastions and mappings through the API."},
+	{Code: ErrCodeSetupStepOutOfOrder, Category: CodeConflict, Hint: "Complete the earlier setup steps first."},
+	{Code: ErrCodeSetupUnknownStep, Category: CodeNotFound, Hint: "Use one of the steps listed by the setup status."},
+	{Code: ErrCodeHADisabled, Category: CodeInvalidRequest, Hint: "Set HA_ROLE and HA_PEER_URL and restart to pair this instance with another."},
+	{Code: ErrCodeHAPeerFailed, Category: CodeBadGateway, Hint: "Check the peer's health and HA_PEER_TOKEN; this instance kept its role."},
+	{Code: ErrCodeAgentNotFound, Category: CodeNotFound, Hint: "Check the agent name against the list of agents."},
+	{Code: ErrCodeAgentWorkspaceTaken, Category: CodeConflict, Field: "workspace", Hint: "Set another AGENT_WORKSPACE on the agent, or delete the agent running the workspace."},
+	{Code: ErrCodeInvalidAgentReport, Category: CodeInvalidRequest, Hint: "Check AGENT_NAME and AGENT_WORKSPACE on the agent."},
+	{Code: ErrCodeAgentTokenScope, Category: CodeUnauthorized, Hint: "Agent tokens only report their agent and read its workspace; set AGENT_NAME and AGENT_WORKSPACE to those the token was issued for."},
+
+	{Code: CodeInvalidRequest, Category: CodeInvalidRequest, Hint: "Fix the request as described by the detail."},
+	{Code: CodeNotFound, Category: CodeNotFound, Hint: "Check the identifiers in the request."},
+	{Code: CodeConflict, Category: CodeConflict, Hint: "Reload the current state and retry."},
+	{Code: CodeUnauthorized, Category: CodeUnauthorized, Hint: "Authenticate and retry."},
+	{Code: CodeResourceBusy, Category: CodeResourceBusy, Hint: "Wait for the resource to be released, or free it, and retry."},
+	{Code: CodeRateLimited, Category: CodeRateLimited, Hint: "Slow down and retry later."},
+	{Code: CodeBadGateway, Category: CodeBadGateway, Hint: "Check the bastion chain and the target, e.g. with a dry run, and retry."},
+	{Code: CodeInternal, Category: CodeInternal, Hint: "Retry later; report the request ID if it persists."},
+}
+
+var errorCatalogByCode = func() map[string]ErrorCodeInfo {
+	byCode := make(map[string]ErrorCodeInfo, len(errorCatalog))
+	for _, info := range errorCatalog {
+		byCode[info.Code] = info
+	}
+	return byCode
+}()
+
+// APIError is data.error of error responses.
+type APIError struct {
+	Code  string `json:"code"`
+	Field string `json:"field,omitempty"`
+	Hint  string `json:"hint,omitempty"`
+}
+
+// classifyError returns the error code of err and the request field it is about, or "" when err
+// has no specific reason.
+func classifyError(err error) (code, field string) {
+	var (
+		fieldErr  *models.FieldError
+		portErr   *core.PortInUseError
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+	)
+	switch {
+	// First: it wraps the start error, which must not be mistaken for the request's.
+	case errors.Is(err, service.ErrMappingRestartFailed):
+		return ErrCodeMappingRestartFailed, ""
+	case errors.Is(err, service.ErrMappingNotFound):
+		return ErrCodeMappingNotFound, ""
+	case errors.Is(err, service.ErrMappingAlreadyExists):
+		return ErrCodeMappingExists, ""
+	case errors.Is(err, service.ErrInvalidMappingID):
+		return ErrCodeInvalidMappingID, ""
+	case errors.Is(err, service.ErrMappingRunning):
+		return ErrCodeMappingRunning, ""
+	case errors.Is(err, service.ErrMappingAlreadyRunning):
+		return ErrCodeMappingAlreadyRunning, ""
+	case errors.Is(err, service.ErrMappingNotRunning):
+		return ErrCodeMappingNotRunning, ""
+	case errors.Is(err, service.ErrInvalidDependencies):
+		return ErrCodeInvalidDependencies, ""
+	case errors.Is(err, service.ErrTargetUnresolved):
+		return ErrCodeTargetUnresolved, ""
+	case errors.Is(err, service.ErrInvalidTargetVariable):
+		return ErrCodeInvalidTargetVariable, ""
+	case errors.Is(err, service.ErrInvalidDryRunTarget):
+		return ErrCodeInvalidDryRunTarget, ""
+	case errors.Is(err, service.ErrExposeNotConfirmed):
+		return ErrCodeExposeNotConfirmed, ""
+	case errors.Is(err, service.ErrInvalidExposeAddr):
+		return ErrCodeInvalidExposeAddr, ""
+	case errors.As(err, &portErr):
+		return ErrCodePortInUse, ""
+	case errors.Is(err, service.ErrVersionConflict):
+		return ErrCodeVersionConflict, ""
+	case errors.Is(err, service.ErrBastionNotFound):
+		return ErrCodeBastionNotFound, ""
+	case errors.Is(err, service.ErrBastionInUse):
+		return ErrCodeBastionInUse, ""
+	case errors.Is(err, service.ErrInvalidCredentials):
+		return ErrCodeInvalidCredentials, ""
+	case errors.Is(err, service.ErrCredentialVerifyFailed):
+		return ErrCodeCredentialsRejected, ""
+	case errors.Is(err, service.ErrInvalidBenchmark):
+		return ErrCodeInvalidBenchmark, ""
+	case errors.Is(err, service.ErrBenchmarkFailed):
+		return ErrCodeBenchmarkFailed, ""
+	case errors.Is(err, service.ErrInvalidDockerRequest):
+		return ErrCodeInvalidDockerRequest, ""
+	case errors.Is(err, service.ErrDockerFailed):
+		return ErrCodeDockerFailed, ""
+	case errors.Is(err, service.ErrInvalidBulkRequest):
+		return ErrCodeInvalidBulkRequest, ""
+	case errors.Is(err, service.ErrInvalidApplyRequest):
+		return ErrCodeInvalidApplyRequest, ""
+	case errors.Is(err, service.ErrSetupCompleted):
+		return ErrCodeSetupCompleted, ""
+	case errors.Is(err, service.ErrSetupStepOutOfOrder):
+		return ErrCodeSetupStepOutOfOrder, ""
+	case errors.Is(err, service.ErrSetupUnknownStep):
+		return ErrCodeSetupUnknownStep, ""
+	case errors.Is(err, service.ErrHADisabled):
+		return ErrCodeHADisabled, ""
+	case errors.Is(err, service.ErrHAPeerFailed):
+		return ErrCodeHAPeerFailed, ""
+	case errors.Is(err, service.ErrAgentNotFound):
+		return ErrCodeAgentNotFound, ""
+	case errors.Is(err, service.ErrAgentWorkspaceTaken):
+		return ErrCodeAgentWorkspaceTaken, "workspace"
+	case errors.Is(err, service.ErrInvalidAgentReport):
+		return ErrCodeInvalidAgentReport, ""
+	case errors.Is(err, service.ErrAgentTokenScope):
+		return ErrCodeAgentTokenScope, ""
+	case errors.As(err, &fieldErr):
+		return ErrCodeValidationFailed, fieldErr.Field
+	case errors.As(err, &typeErr):
+		return ErrCodeMalformedBody, typeErr.Field
+	case errors.As(err, &syntaxErr):
+		return ErrCodeMalformedBody, ""
+	}
+	return "", ""
+}
+
+// apiError builds data.error for an error response: the specific code of err when it has one (its
+// category then replaces code, so both API versions report an error the same way), else code itself.
+func apiError(c *gin.Context, code string, err error) (string, *APIError) {
+	specific, field := "", ""
+	if err != nil {
+		specific, field = classifyError(err)
+	}
+	info, ok := errorCatalogByCode[specific]
+	if !ok {
+		info = errorCatalogByCode[code]
+		info.Code, info.Category = code, code
+	}
+	if field == "" {
+		field = info.Field
+	}
+	return info.Category, &APIError{Code: info.Code, Field: field, Hint: i18n.T(requestLanguage(c), info.Hint)}
+}
+
+// ListErrorCodesV2 returns the error catalog: every data.error.code with its envelope code, field
+// and remediation hint.
+func ListErrorCodesV2(c *gin.Context) {
+	lang := requestLanguage(c)
+	codes := make([]ErrorCodeInfo, len(errorCatalog))
+	for i, info := range errorCatalog {
+		info.Hint = i18n.T(lang, info.Hint)
+		codes[i] = info
+	}
+	okV2(c, codes)
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"bastion/i18n"
+	"bastion/models"
+	"bastion/service"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestErrV2_ClassifiesErrors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name       string
+		code       string
+		detail     any
+		wantCode   string
+		wantReason string
+		wantField  string
+	}{
+		{"sentinel refines the category", CodeBadGateway, fmt.Errorf("start: %w", service.ErrMappingNotFound), CodeNotFound, ErrCodeMappingNotFound, ""},
+		{"field error", CodeInvalidRequest, models.FieldErrorf("local_port", "invalid local_port 70000"), CodeInvalidRequest, ErrCodeValidationFailed, "local_port"},
+		{"catalog field", CodeInvalidRequest, fmt.Errorf("x: %w", service.ErrInvalidDependencies), CodeInvalidRequest, ErrCodeInvalidDependencies, "depends_on"},
+		{"malformed body", CodeInvalidRequest, &json.UnmarshalTypeError{Value: "string", Type: reflect.TypeOf(0), Field: "local_port"}, CodeInvalidRequest, ErrCodeMalformedBody, "local_port"},
+		{"unclassified error", CodeBadGateway, errors.New("ssh: handshake failed"), CodeBadGateway, CodeBadGateway, ""},
+		{"string detail", CodeConflict, "already there", CodeConflict, CodeConflict, ""},
+	}
+	for _, tt := range tests {
+		r := gin.New()
+		r.GET("/fail", func(c *gin.Context) { errV2(c, tt.code, "Failed", tt.detail) })
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/fail", nil))
+
+		var resp struct {
+			Code string `json:"code"`
+			Data struct {
+				Detail string   `json:"detail"`
+				Error  APIError `json:"error"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%s: decode: %v", tt.name, err)
+		}
+		if resp.Code != tt.wantCode || resp.Data.Error.Code != tt.wantReason || resp.Data.Error.Field != tt.wantField {
+			t.Fatalf("%s: got %s/%s field %q, want %s/%s field %q", tt.name, resp.Code, resp.Data.Error.Code, resp.Data.Error.Field, tt.wantCode, tt.wantReason, tt.wantField)
+		}
+		if resp.Data.Detail == "" || resp.Data.Error.Hint == "" {
+			t.Fatalf("%s: expected a detail and a hint, got %+v", tt.name, resp.Data)
+		}
+	}
+}
+
+func TestErrorCatalog_Complete(t *testing.T) {
+	seen := make(map[string]bool)
+	for _, info := range errorCatalog {
+		if seen[info.Code] {
+			t.Fatalf("duplicate error code %s", info.Code)
+		}
+		seen[info.Code] = true
+		if info.Hint == "" || i18n.T(i18n.Chinese, info.Hint) == info.Hint {
+			t.Fatalf("error code %s needs a translated hint", info.Code)
+		}
+	}
+	for _, code := range []string{CodeInvalidRequest, CodeNotFound, CodeConflict, CodeUnauthorized, CodeResourceBusy, CodeRateLimited, CodeBadGateway, CodeInternal} {
+		if !seen[code] {
+			t.Fatalf("envelope code %s missing from the catalog", code)
+		}
+	}
+}
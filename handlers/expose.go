@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"bastion/core"
+	"bastion/service"
+	"errors"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListInterfacesV2 lists local network interfaces and their addresses (candidates for expose).
+func ListInterfacesV2(c *gin.Context) {
+	ifaces, err := core.ListInterfaces()
+	if err != nil {
+		errV2(c, CodeInternal, "Failed to list interfaces", err)
+		return
+	}
+	okV2(c, ifaces)
+}
+
+// ExposeMappingV2 rebinds a mapping to a non-loopback interface address.
+// Body: {"address": "100.64.0.5", "confirm": true, "by": "alice"}; by is optional and recorded
+// together with the client IP.
+func ExposeMappingV2(c *gin.Context) {
+	var req struct {
+		Address string `json:"address" binding:"required"`
+		Confirm bool   `json:"confirm"`
+		By      string `json:"by"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errV2(c, CodeInvalidRequest, "Invalid request", err)
+		return
+	}
+
+	by := c.ClientIP()
+	if name := strings.TrimSpace(req.By); name != "" {
+		by = name + " (" + by + ")"
+	}
+
+	mapping, err := scopedServices(c).Mapping.Expose(c.Param("id"), strings.TrimSpace(req.Address), by, req.Confirm)
+	if err != nil {
+		respondRebindError(c, err)
+		return
+	}
+	okV2(c, mapping)
+}
+
+// UnexposeMappingV2 binds a mapping back to its local_host.
+func UnexposeMappingV2(c *gin.Context) {
+	mapping, err := scopedServices(c).Mapping.Unexpose(c.Param("id"))
+	if err != nil {
+		respondRebindError(c, err)
+		return
+	}
+	okV2(c, mapping)
+}
+
+func respondRebindError(c *gin.Context, err error) {
+	var portErr *core.PortInUseError
+	switch {
+	case errors.Is(err, service.ErrMappingNotFound):
+		errV2(c, CodeNotFound, "Mapping not found", err)
+	case errors.Is(err, service.ErrExposeNotConfirmed), errors.Is(err, service.ErrInvalidExposeAddr):
+		errV2(c, CodeInvalidRequest, "Invalid expose request", err)
+	case errors.As(err, &portErr):
+		respondV2(c, CodeResourceBusy, "Local address is already in use", portErr.Detail)
+	default:
+		errV2(c, CodeBadGateway, "Failed to restart mapping", err)
+	}
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"bastion/models"
+	"bastion/service"
+	"errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetHAStatusV2 returns this instance's role in its active/standby pair and the outcome of the
+// last health check of and configuration sync from the peer.
+func GetHAStatusV2(c *gin.Context) {
+	okV2(c, service.GlobalServices.HA.Status())
+}
+
+// HAFailoverV2 moves the active role to the other instance of the pair, whichever instance
+// receives the request.
+func HAFailoverV2(c *gin.Context) {
+	status, err := service.GlobalServices.HA.Failover(c.Request.Context())
+	respondHA(c, "Failover failed", status, err)
+}
+
+// HAPromoteV2 makes this instance the active one without asking the peer to step down; the peer
+// calls it during a failover.
+func HAPromoteV2(c *gin.Context) {
+	status, err := service.GlobalServices.HA.Promote("promoted by " + c.RemoteIP())
+	respondHA(c, "Failed to promote this instance", status, err)
+}
+
+// HADemoteV2 makes this instance the standby, stopping its mappings; the peer calls it during a
+// failover.
+func HADemoteV2(c *gin.Context) {
+	status, err := service.GlobalServices.HA.Demote("demoted by " + c.RemoteIP())
+	respondHA(c, "Failed to demote this instance", status, err)
+}
+
+func respondHA(c *gin.Context, message string, status models.HAStatus, err error) {
+	switch {
+	case err == nil:
+		okV2(c, status)
+	case errors.Is(err, service.ErrHADisabled):
+		errV2(c, CodeInvalidRequest, message, err)
+	default:
+		errV2(c, CodeBadGateway, message, err)
+	}
+}
@@ -1,7 +1,6 @@
 package handlers
 
 import (
-	"bastion/config"
 	"bastion/core"
 	"bastion/database"
 	"bastion/models"
@@ -9,13 +8,10 @@ import (
 	"bastion/state"
 	"bastion/version"
 	"bytes"
-	"crypto/rand"
-	"errors"
 	"fmt"
-	"math/big"
 	"net/http"
-	"regexp"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -33,442 +29,14 @@ type ShutdownManager struct {
 
 var shutdownMgr = &ShutdownManager{}
 
-// ListBastions lists all bastions
-func ListBastions(c *gin.Context) {
-	bastions, err := service.GlobalServices.Bastion.List()
-	if err != nil {
-		errV2(c, CodeInternal, "Internal error", err.Error())
-		return
+// listFilter reads the q and tag query parameters of the list endpoints; tag may be repeated or
+// comma-separated.
+func listFilter(c *gin.Context) models.ListFilter {
+	var tags []string
+	for _, v := range c.QueryArray("tag") {
+		tags = append(tags, strings.Split(v, ",")...)
 	}
-	okV2(c, bastions)
-}
-
-// CreateBastion creates a bastion host
-func CreateBastion(c *gin.Context) {
-	var req models.BastionCreate
-	if err := c.ShouldBindJSON(&req); err != nil {
-		errV2(c, CodeInvalidRequest, "Invalid request", err.Error())
-		return
-	}
-
-	bastion, err := service.GlobalServices.Bastion.Create(req)
-	if err != nil {
-		errV2(c, CodeInvalidRequest, "Invalid request", err.Error())
-		return
-	}
-
-	okV2(c, gin.H{"ok": true, "id": bastion.ID})
-}
-
-// UpdateBastion updates a bastion host
-func UpdateBastion(c *gin.Context) {
-	id := c.Param("id")
-	bastionID, err := strconv.ParseUint(id, 10, 32)
-	if err != nil {
-		errV2(c, CodeInvalidRequest, "Invalid request", "Invalid bastion ID")
-		return
-	}
-
-	var req models.BastionCreate
-	if err := c.ShouldBindJSON(&req); err != nil {
-		errV2(c, CodeInvalidRequest, "Invalid request", err.Error())
-		return
-	}
-
-	// Enforce immutability of bastion name: mappings reference bastions by name.
-	existingBastion, err := service.GlobalServices.Bastion.Get(uint(bastionID))
-	if err != nil {
-		errV2(c, CodeInvalidRequest, "Invalid request", err.Error())
-		return
-	}
-	if req.Name != "" && req.Name != existingBastion.Name {
-		errV2(c, CodeInvalidRequest, "Invalid request", "bastion name is immutable")
-		return
-	}
-	if req.Host != "" && req.Host != existingBastion.Host {
-		errV2(c, CodeInvalidRequest, "Invalid request", "bastion host is immutable")
-		return
-	}
-	if req.Port != 0 && req.Port != existingBastion.Port {
-		errV2(c, CodeInvalidRequest, "Invalid request", "bastion port is immutable")
-		return
-	}
-
-	// Disallow updates when referenced by any running mapping session.
-	state.Global.RLock()
-	running := make(map[string]bool, len(state.Global.Sessions))
-	for mappingID := range state.Global.Sessions {
-		running[mappingID] = true
-	}
-	state.Global.RUnlock()
-
-	_, runningMappings, _, checkErr := service.GlobalServices.Bastion.CheckInUse(existingBastion.Name, running)
-	if checkErr != nil {
-		errV2(c, CodeInternal, "Internal error", checkErr.Error())
-		return
-	}
-	if len(runningMappings) > 0 {
-		errV2(c, CodeConflict, "Conflict", gin.H{
-			"reason":           "bastion_in_use",
-			"running_mappings": runningMappings,
-		})
-		return
-	}
-
-	bastion, err := service.GlobalServices.Bastion.Update(uint(bastionID), req)
-	if err != nil {
-		errV2(c, CodeInvalidRequest, "Invalid request", err.Error())
-		return
-	}
-
-	okV2(c, gin.H{"ok": true, "id": bastion.ID})
-}
-
-// DeleteBastion deletes a bastion host
-func DeleteBastion(c *gin.Context) {
-	id := c.Param("id")
-	bastionID, err := strconv.ParseUint(id, 10, 32)
-	if err != nil {
-		errV2(c, CodeInvalidRequest, "Invalid request", "Invalid bastion ID")
-		return
-	}
-
-	if err := service.GlobalServices.Bastion.Delete(uint(bastionID)); err != nil {
-		errV2(c, CodeInvalidRequest, "Invalid request", err.Error())
-		return
-	}
-
-	okV2(c, gin.H{"ok": true})
-}
-
-// ListMappings lists all mappings
-func ListMappings(c *gin.Context) {
-	mappings, err := service.GlobalServices.Mapping.List()
-	if err != nil {
-		errV2(c, CodeInternal, "Internal error", err.Error())
-		return
-	}
-	okV2(c, mappings)
-}
-
-// CreateMapping creates a mapping
-func CreateMapping(c *gin.Context) {
-	var req models.MappingCreate
-	if err := c.ShouldBindJSON(&req); err != nil {
-		errV2(c, CodeInvalidRequest, "Invalid request", err.Error())
-		return
-	}
-
-	mapping, err := service.GlobalServices.Mapping.Create(req)
-	if err != nil {
-		if errors.Is(err, service.ErrMappingAlreadyExists) {
-			errV2(c, CodeConflict, "Conflict", "mapping already exists; use PUT /api/mappings/:id to update (stopped only)")
-			return
-		}
-		errV2(c, CodeInvalidRequest, "Invalid request", err.Error())
-		return
-	}
-
-	okV2(c, gin.H{"ok": true, "id": mapping.ID})
-}
-
-// UpdateMapping updates a mapping when it is not running.
-// Immutable fields: local/remote host/port and type.
-func UpdateMapping(c *gin.Context) {
-	id := c.Param("id")
-
-	var req models.MappingCreate
-	if err := c.ShouldBindJSON(&req); err != nil {
-		errV2(c, CodeInvalidRequest, "Invalid request", err.Error())
-		return
-	}
-
-	mapping, err := service.GlobalServices.Mapping.Update(id, req)
-	if err != nil {
-		if errors.Is(err, service.ErrMappingRunning) {
-			errV2(c, CodeConflict, "Conflict", "mapping is running; stop it before updating")
-			return
-		}
-		if errors.Is(err, service.ErrMappingNotFound) {
-			errV2(c, CodeNotFound, "Not found", err.Error())
-			return
-		}
-		errV2(c, CodeInvalidRequest, "Invalid request", err.Error())
-		return
-	}
-
-	okV2(c, gin.H{"ok": true, "id": mapping.ID})
-}
-
-// DeleteMapping deletes a mapping
-func DeleteMapping(c *gin.Context) {
-	id := c.Param("id")
-
-	// Disallow deleting an enabled (running) mapping to keep runtime state and DB data consistent.
-	if state.Global.SessionExists(id) {
-		errV2(c, CodeConflict, "Conflict", "mapping is running; stop it before deleting")
-		return
-	}
-
-	if err := service.GlobalServices.Mapping.Delete(id); err != nil {
-		errV2(c, CodeInternal, "Internal error", err.Error())
-		return
-	}
-
-	okV2(c, gin.H{"ok": true})
-}
-
-// StartMapping starts a mapping
-func StartMapping(c *gin.Context) {
-	id := c.Param("id")
-
-	if err := service.GlobalServices.Mapping.Start(id); err != nil {
-		// Return different status codes based on the error type
-		if errors.Is(err, service.ErrMappingAlreadyRunning) {
-			okV2(c, gin.H{"ok": true, "msg": "Already running"})
-		} else if errors.Is(err, service.ErrMappingNotFound) {
-			errV2(c, CodeNotFound, "Not found", err.Error())
-		} else {
-			errV2(c, CodeBadGateway, "Bad gateway", err.Error())
-		}
-		return
-	}
-
-	okV2(c, gin.H{"ok": true})
-}
-
-// StopMapping stops a mapping
-func StopMapping(c *gin.Context) {
-	id := c.Param("id")
-
-	if err := service.GlobalServices.Mapping.Stop(id); err != nil {
-		okV2(c, gin.H{"ok": true, "msg": "Session not found or already stopped"})
-		return
-	}
-
-	okV2(c, gin.H{"ok": true, "msg": "Session stopped"})
-}
-
-// GetStats returns mapping statistics
-func GetStats(c *gin.Context) {
-	statsMap := service.GlobalServices.Mapping.GetStats()
-
-	result := make(map[string]gin.H)
-	for id, s := range statsMap {
-		result[id] = gin.H{
-			"up_bytes":    s.BytesUp,
-			"down_bytes":  s.BytesDown,
-			"connections": s.ActiveConns,
-		}
-	}
-
-	okV2(c, result)
-}
-
-// GetHTTPLogs retrieves paginated HTTP logs
-func GetHTTPLogs(c *gin.Context) {
-	page := 1
-	pageSize := 20
-
-	if pageStr := c.Query("page"); pageStr != "" {
-		if p, err := strconv.Atoi(pageStr); err == nil && p > 0 {
-			page = p
-		}
-	}
-
-	if sizeStr := c.Query("page_size"); sizeStr != "" {
-		if s, err := strconv.Atoi(sizeStr); err == nil && s > 0 {
-			pageSize = s
-		}
-	}
-
-	filter := core.HTTPLogFilter{}
-
-	if q := strings.TrimSpace(c.Query("q")); q != "" {
-		filter.Query = q
-		if regexStr := c.Query("regex"); regexStr != "" {
-			useRegex, err := strconv.ParseBool(regexStr)
-			if err != nil {
-				errV2(c, CodeInvalidRequest, "Invalid request", "Invalid regex flag")
-				return
-			}
-			if useRegex {
-				re, err := regexp.Compile(q)
-				if err != nil {
-					errV2(c, CodeInvalidRequest, "Invalid request", "Invalid regex pattern")
-					return
-				}
-				filter.QueryRegex = re
-			}
-		}
-	}
-
-	if method := strings.TrimSpace(c.Query("method")); method != "" {
-		filter.Method = method
-	}
-	if host := strings.TrimSpace(c.Query("host")); host != "" {
-		filter.Host = host
-	}
-	if urlStr := strings.TrimSpace(c.Query("url")); urlStr != "" {
-		filter.URL = urlStr
-	}
-	if bastion := strings.TrimSpace(c.Query("bastion")); bastion != "" {
-		filter.Bastion = bastion
-	}
-	if localPortStr := strings.TrimSpace(c.Query("local_port")); localPortStr != "" {
-		p, err := strconv.Atoi(localPortStr)
-		if err != nil || p <= 0 || p > 65535 {
-			errV2(c, CodeInvalidRequest, "Invalid request", "Invalid local_port")
-			return
-		}
-		filter.LocalPort = &p
-	}
-	if statusStr := strings.TrimSpace(c.Query("status")); statusStr != "" {
-		code, err := strconv.Atoi(statusStr)
-		if err != nil || code < 0 {
-			errV2(c, CodeInvalidRequest, "Invalid request", "Invalid status code")
-			return
-		}
-		filter.StatusCode = code
-	}
-
-	parseTime := func(value string) (*time.Time, error) {
-		value = strings.TrimSpace(value)
-		if value == "" {
-			return nil, nil
-		}
-		if unix, err := strconv.ParseInt(value, 10, 64); err == nil {
-			tm := time.Unix(unix, 0)
-			return &tm, nil
-		}
-		tm, err := time.Parse(time.RFC3339, value)
-		if err != nil {
-			return nil, err
-		}
-		return &tm, nil
-	}
-
-	if sinceStr := c.Query("since"); sinceStr != "" {
-		tm, err := parseTime(sinceStr)
-		if err != nil {
-			errV2(c, CodeInvalidRequest, "Invalid request", "Invalid since timestamp")
-			return
-		}
-		filter.Since = tm
-	}
-	if untilStr := c.Query("until"); untilStr != "" {
-		tm, err := parseTime(untilStr)
-		if err != nil {
-			errV2(c, CodeInvalidRequest, "Invalid request", "Invalid until timestamp")
-			return
-		}
-		filter.Until = tm
-	}
-
-	logs, total := service.GlobalServices.Audit.QueryHTTPLogs(filter, page, pageSize)
-
-	okV2(c, gin.H{
-		"data":      logs,
-		"page":      page,
-		"page_size": pageSize,
-		"total":     total,
-	})
-}
-
-// GetHTTPLogDetail retrieves details for a single HTTP log entry.
-//
-// Optional query params:
-// - part=request_header|request_body|response_header|response_body
-// - decode=gzip (only for part=response_body)
-func GetHTTPLogDetail(c *gin.Context) {
-	idStr := c.Param("id")
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		errV2(c, CodeInvalidRequest, "Invalid request", "Invalid ID")
-		return
-	}
-
-	if partStr := strings.TrimSpace(c.Query("part")); partStr != "" {
-		part := core.HTTPLogPart(partStr)
-
-		opts := core.HTTPLogPartOptions{}
-		if decodeStr := strings.TrimSpace(c.Query("decode")); decodeStr != "" {
-			if !strings.EqualFold(decodeStr, "gzip") {
-				errV2(c, CodeInvalidRequest, "Invalid request", "Invalid decode value")
-				return
-			}
-			opts.DecodeGzip = true
-		}
-
-		result, err := service.GlobalServices.Audit.GetHTTPLogPart(id, part, opts)
-		if err != nil {
-			if errors.Is(err, core.ErrHTTPLogNotFound) {
-				errV2(c, CodeNotFound, "Not found", "Log not found")
-				return
-			}
-			if errors.Is(err, core.ErrInvalidHTTPLogPart) || errors.Is(err, core.ErrNotGzippedResponse) {
-				errV2(c, CodeInvalidRequest, "Invalid request", err.Error())
-				return
-			}
-			if errors.Is(err, core.ErrGzipDecodeNotAllowed) {
-				errV2(c, CodeInvalidRequest, "Invalid request", "decode is only supported for part=response_body")
-				return
-			}
-			errV2(c, CodeInternal, "Internal error", err.Error())
-			return
-		}
-
-		okV2(c, result)
-		return
-	}
-
-	log := service.GlobalServices.Audit.GetHTTPLogByID(id)
-	if log == nil {
-		errV2(c, CodeNotFound, "Not found", "Log not found")
-		return
-	}
-
-	okV2(c, log)
-}
-
-// ClearHTTPLogs removes all HTTP logs
-func ClearHTTPLogs(c *gin.Context) {
-	service.GlobalServices.Audit.ClearHTTPLogs()
-	okV2(c, gin.H{"ok": true, "message": "HTTP logs cleared"})
-}
-
-// HealthCheck health endpoint
-func HealthCheck(c *gin.Context) {
-	state.Global.RLock()
-	sessionCount := len(state.Global.Sessions)
-	state.Global.RUnlock()
-
-	// Check database connectivity
-	sqlDB, err := database.DB.DB()
-	dbHealthy := true
-	if err != nil {
-		dbHealthy = false
-	} else {
-		if err := sqlDB.Ping(); err != nil {
-			dbHealthy = false
-		}
-	}
-
-	health := gin.H{
-		"status":        "healthy",
-		"timestamp":     time.Now().Unix(),
-		"sessions":      sessionCount,
-		"db_healthy":    dbHealthy,
-		"audit_enabled": config.Settings.AuditEnabled,
-	}
-
-	if !dbHealthy {
-		health["status"] = "degraded"
-		respondV2(c, CodeInternal, "Service degraded", health)
-		return
-	}
-
-	okV2(c, health)
+	return models.ListFilter{Query: c.Query("q"), Tags: models.NormalizeTags(tags)}
 }
 
 type metricsSnapshot struct {
@@ -477,6 +45,8 @@ type metricsSnapshot struct {
 	totalConnections int32
 	totalBytesUp     int64
 	totalBytesDown   int64
+	sessions         map[string]core.SessionStats          // by session key
+	quotas           map[string]*models.MappingQuotaStatus // by session key, for sessions with a quota
 	httpLogCount     int
 	mem              runtime.MemStats
 }
@@ -487,9 +57,19 @@ func collectMetricsSnapshot() metricsSnapshot {
 
 	var totalConnections int32
 	var totalBytesUp, totalBytesDown int64
+	sessions := make(map[string]core.SessionStats, sessionCount)
+	quotas := make(map[string]*models.MappingQuotaStatus)
 
-	for _, session := range state.Global.Sessions {
+	for key, session := range state.Global.Sessions {
 		stats := session.GetStats()
+		sessions[key] = stats
+		if q, ok := session.(interface {
+			QuotaStatus() *models.MappingQuotaStatus
+		}); ok {
+			if st := q.QuotaStatus(); st != nil {
+				quotas[key] = st
+			}
+		}
 		totalConnections += stats.ActiveConns
 		totalBytesUp += stats.BytesUp
 		totalBytesDown += stats.BytesDown
@@ -507,52 +87,13 @@ func collectMetricsSnapshot() metricsSnapshot {
 		totalConnections: totalConnections,
 		totalBytesUp:     totalBytesUp,
 		totalBytesDown:   totalBytesDown,
+		sessions:         sessions,
+		quotas:           quotas,
 		httpLogCount:     httpLogCount,
 		mem:              mem,
 	}
 }
 
-// GetMetrics gathers system metrics
-func GetMetrics(c *gin.Context) {
-	s := collectMetricsSnapshot()
-
-	metrics := gin.H{
-		"timestamp": s.timestamp,
-		"audit": gin.H{
-			"queue_len":      service.GlobalServices.Audit.AuditQueueLen(),
-			"queue_capacity": service.GlobalServices.Audit.AuditQueueCap(),
-			"dropped_total":  service.GlobalServices.Audit.AuditDroppedTotal(),
-		},
-		"ssh_pool": gin.H{
-			"connections":        core.Pool.SSHPoolConnections(),
-			"active_conns":       core.Pool.SSHPoolActiveConns(),
-			"keepalive_failures": core.Pool.SSHKeepaliveFailuresTotal(),
-			"idle_closed_total":  core.Pool.SSHIdleClosedTotal(),
-		},
-		"sessions": gin.H{
-			"total":       s.sessionCount,
-			"connections": s.totalConnections,
-		},
-		"traffic": gin.H{
-			"bytes_up":   s.totalBytesUp,
-			"bytes_down": s.totalBytesDown,
-			"total":      s.totalBytesUp + s.totalBytesDown,
-		},
-		"http_logs": gin.H{
-			"total": s.httpLogCount,
-		},
-		"system": gin.H{
-			"goroutines":   runtime.NumGoroutine(),
-			"memory_alloc": s.mem.Alloc,
-			"memory_total": s.mem.TotalAlloc,
-			"memory_sys":   s.mem.Sys,
-			"gc_runs":      s.mem.NumGC,
-		},
-	}
-
-	okV2(c, metrics)
-}
-
 func promLabelEscape(s string) string {
 	s = strings.ReplaceAll(s, "\\", "\\\\")
 	s = strings.ReplaceAll(s, "\"", "\\\"")
@@ -561,7 +102,7 @@ func promLabelEscape(s string) string {
 }
 
 // GetPrometheusMetrics writes Prometheus-formatted metrics to the HTTP response for scraping.
-// It collects runtime and application metrics — including build info, SQLite connectivity and error counters, session and connection counts, traffic byte totals, HTTP log count, goroutine and memory statistics, and GC runs — and returns them using the Prometheus exposition content type.
+// It collects runtime and application metrics — including build info, database connectivity, SQLite error counters, session and connection counts, traffic byte totals, HTTP log count, goroutine and memory statistics, and GC runs — and returns them using the Prometheus exposition content type.
 func GetPrometheusMetrics(c *gin.Context) {
 	s := collectMetricsSnapshot()
 
@@ -577,12 +118,17 @@ func GetPrometheusMetrics(c *gin.Context) {
 		promLabelEscape(version.BuildTime),
 	)
 
-	buf.WriteString("# HELP bastion_sqlite_up SQLite connectivity (1=up, 0=down).\n")
-	buf.WriteString("# TYPE bastion_sqlite_up gauge\n")
-	if database.SQLiteUp(c.Request.Context()) {
-		buf.WriteString("bastion_sqlite_up 1\n")
-	} else {
-		buf.WriteString("bastion_sqlite_up 0\n")
+	dbUp := 0
+	if database.Up(c.Request.Context()) {
+		dbUp = 1
+	}
+	buf.WriteString("# HELP bastion_database_up Database connectivity (1=up, 0=down).\n")
+	buf.WriteString("# TYPE bastion_database_up gauge\n")
+	fmt.Fprintf(&buf, "bastion_database_up{backend=\"%s\"} %d\n", database.Backend(), dbUp)
+	if database.Backend() == database.BackendSQLite {
+		buf.WriteString("# HELP bastion_sqlite_up SQLite connectivity (1=up, 0=down).\n")
+		buf.WriteString("# TYPE bastion_sqlite_up gauge\n")
+		fmt.Fprintf(&buf, "bastion_sqlite_up %d\n", dbUp)
 	}
 
 	buf.WriteString("# HELP bastion_sqlite_busy_errors_total Total SQLite busy errors observed.\n")
@@ -609,10 +155,64 @@ func GetPrometheusMetrics(c *gin.Context) {
 	buf.WriteString("# TYPE bastion_http_audit_queue_len gauge\n")
 	fmt.Fprintf(&buf, "bastion_http_audit_queue_len %d\n", service.GlobalServices.Audit.AuditQueueLen())
 
+	buf.WriteString("# HELP bastion_http_audit_queue_high_water Highest number of buffered audit messages observed.\n")
+	buf.WriteString("# TYPE bastion_http_audit_queue_high_water gauge\n")
+	fmt.Fprintf(&buf, "bastion_http_audit_queue_high_water %d\n", service.GlobalServices.Audit.AuditQueueHighWater())
+
 	buf.WriteString("# HELP bastion_http_audit_dropped_total Total audit messages dropped due to backpressure.\n")
 	buf.WriteString("# TYPE bastion_http_audit_dropped_total counter\n")
 	fmt.Fprintf(&buf, "bastion_http_audit_dropped_total %d\n", service.GlobalServices.Audit.AuditDroppedTotal())
 
+	buf.WriteString("# HELP bastion_http_logs_retained_bytes Estimated bytes held by in-memory HTTP logs.\n")
+	buf.WriteString("# TYPE bastion_http_logs_retained_bytes gauge\n")
+	fmt.Fprintf(&buf, "bastion_http_logs_retained_bytes %d\n", service.GlobalServices.Audit.RetainedBytes())
+
+	buf.WriteString("# HELP bastion_http_logs_retained_bytes_budget Byte budget of in-memory HTTP logs (0 = unlimited).\n")
+	buf.WriteString("# TYPE bastion_http_logs_retained_bytes_budget gauge\n")
+	fmt.Fprintf(&buf, "bastion_http_logs_retained_bytes_budget %d\n", service.GlobalServices.Audit.RetainedBytesBudget())
+
+	buf.WriteString("# HELP bastion_http_logs_budget_evicted_total HTTP logs evicted to stay within the byte budget.\n")
+	buf.WriteString("# TYPE bastion_http_logs_budget_evicted_total counter\n")
+	fmt.Fprintf(&buf, "bastion_http_logs_budget_evicted_total %d\n", service.GlobalServices.Audit.BudgetEvictedTotal())
+
+	pairs := service.GlobalServices.Audit.HTTPPairStats()
+	buf.WriteString("# HELP bastion_http_pair_pending HTTP requests awaiting their response.\n")
+	buf.WriteString("# TYPE bastion_http_pair_pending gauge\n")
+	fmt.Fprintf(&buf, "bastion_http_pair_pending %d\n", pairs.Pending)
+
+	buf.WriteString("# HELP bastion_http_pair_mismatches_total HTTP requests logged without a response because a later response arrived.\n")
+	buf.WriteString("# TYPE bastion_http_pair_mismatches_total counter\n")
+	fmt.Fprintf(&buf, "bastion_http_pair_mismatches_total %d\n", pairs.Mismatches)
+
+	buf.WriteString("# HELP bastion_http_pair_orphans_total HTTP responses logged without their request.\n")
+	buf.WriteString("# TYPE bastion_http_pair_orphans_total counter\n")
+	fmt.Fprintf(&buf, "bastion_http_pair_orphans_total %d\n", pairs.Orphans)
+
+	buf.WriteString("# HELP bastion_http_pair_evicted_total HTTP requests logged without a response because their connection had too many pending.\n")
+	buf.WriteString("# TYPE bastion_http_pair_evicted_total counter\n")
+	fmt.Fprintf(&buf, "bastion_http_pair_evicted_total %d\n", pairs.Evicted)
+
+	buf.WriteString("# HELP bastion_http_pair_bodies_omitted_total HTTP responses logged without their body (AUDIT_SKIP_BODY_TYPES, AUDIT_SKIP_BODY_BYTES).\n")
+	buf.WriteString("# TYPE bastion_http_pair_bodies_omitted_total counter\n")
+	fmt.Fprintf(&buf, "bastion_http_pair_bodies_omitted_total %d\n", pairs.Omitted)
+
+	ev := core.EventExport.Stats()
+	buf.WriteString("# HELP bastion_event_export_queue_len Security events waiting for delivery to the event sink.\n")
+	buf.WriteString("# TYPE bastion_event_export_queue_len gauge\n")
+	fmt.Fprintf(&buf, "bastion_event_export_queue_len %d\n", ev.QueueLen)
+
+	buf.WriteString("# HELP bastion_event_export_sent_total Security events delivered to the event sink.\n")
+	buf.WriteString("# TYPE bastion_event_export_sent_total counter\n")
+	fmt.Fprintf(&buf, "bastion_event_export_sent_total %d\n", ev.Sent)
+
+	buf.WriteString("# HELP bastion_event_export_dropped_total Security events dropped because the queue was full.\n")
+	buf.WriteString("# TYPE bastion_event_export_dropped_total counter\n")
+	fmt.Fprintf(&buf, "bastion_event_export_dropped_total %d\n", ev.Dropped)
+
+	buf.WriteString("# HELP bastion_event_export_failed_total Security events given up after failed deliveries.\n")
+	buf.WriteString("# TYPE bastion_event_export_failed_total counter\n")
+	fmt.Fprintf(&buf, "bastion_event_export_failed_total %d\n", ev.Failed)
+
 	buf.WriteString("# HELP bastion_ssh_pool_connections Number of pooled SSH client connections.\n")
 	buf.WriteString("# TYPE bastion_ssh_pool_connections gauge\n")
 	fmt.Fprintf(&buf, "bastion_ssh_pool_connections %d\n", core.Pool.SSHPoolConnections())
@@ -621,6 +221,11 @@ func GetPrometheusMetrics(c *gin.Context) {
 	buf.WriteString("# TYPE bastion_ssh_pool_active_conns gauge\n")
 	fmt.Fprintf(&buf, "bastion_ssh_pool_active_conns %d\n", core.Pool.SSHPoolActiveConns())
 
+	buf.WriteString("# HELP bastion_ssh_pool_shared_prefixes Pooled SSH chains that other pooled chains are built on.\n")
+	buf.WriteString("# TYPE bastion_ssh_pool_shared_prefixes gauge\n")
+	fmt.Fprintf(&buf, "bastion_ssh_pool_shared_prefixes %d\n", core.Pool.SSHPoolSharedPrefixes())
+	writeSSHPoolLatencyMetrics(&buf, core.Pool.Chains())
+
 	buf.WriteString("# HELP bastion_ssh_pool_keepalive_failures_total Total pooled SSH keepalive probe failures.\n")
 	buf.WriteString("# TYPE bastion_ssh_pool_keepalive_failures_total counter\n")
 	fmt.Fprintf(&buf, "bastion_ssh_pool_keepalive_failures_total %d\n", core.Pool.SSHKeepaliveFailuresTotal())
@@ -629,6 +234,43 @@ func GetPrometheusMetrics(c *gin.Context) {
 	buf.WriteString("# TYPE bastion_ssh_pool_idle_closed_total counter\n")
 	fmt.Fprintf(&buf, "bastion_ssh_pool_idle_closed_total %d\n", core.Pool.SSHIdleClosedTotal())
 
+	buf.WriteString("# HELP bastion_ssh_chain_builds_in_progress SSH chains being built.\n")
+	buf.WriteString("# TYPE bastion_ssh_chain_builds_in_progress gauge\n")
+	fmt.Fprintf(&buf, "bastion_ssh_chain_builds_in_progress %d\n", core.Pool.SSHChainBuildsInProgress())
+
+	buf.WriteString("# HELP bastion_ssh_chain_builds_queued SSH chain builds and bastion connections waiting for a slot.\n")
+	buf.WriteString("# TYPE bastion_ssh_chain_builds_queued gauge\n")
+	fmt.Fprintf(&buf, "bastion_ssh_chain_builds_queued %d\n", core.Pool.SSHChainBuildsQueued())
+
+	buf.WriteString("# HELP bastion_ssh_chain_builds_queued_total Total SSH chain builds and bastion connections that waited for a slot.\n")
+	buf.WriteString("# TYPE bastion_ssh_chain_builds_queued_total counter\n")
+	fmt.Fprintf(&buf, "bastion_ssh_chain_builds_queued_total %d\n", core.Pool.SSHChainBuildsQueuedTotal())
+
+	buf.WriteString("# HELP bastion_ssh_chain_build_queue_timeouts_total Total SSH chain builds that timed out waiting for a slot.\n")
+	buf.WriteString("# TYPE bastion_ssh_chain_build_queue_timeouts_total counter\n")
+	fmt.Fprintf(&buf, "bastion_ssh_chain_build_queue_timeouts_total %d\n", core.Pool.SSHChainBuildQueueTimeoutsTotal())
+	buf.WriteString("# HELP bastion_ssh_channel_limit_hits_total Total channel opens refused by a pooled SSH client over its channel limit.\n")
+	buf.WriteString("# TYPE bastion_ssh_channel_limit_hits_total counter\n")
+	fmt.Fprintf(&buf, "bastion_ssh_channel_limit_hits_total %d\n", core.Pool.SSHChannelLimitHitsTotal())
+
+	fb := core.ForwardBufferStats()
+	buf.WriteString("# HELP bastion_forward_buffers_in_use Forwarding buffers currently checked out.\n")
+	buf.WriteString("# TYPE bastion_forward_buffers_in_use gauge\n")
+	fmt.Fprintf(&buf, "bastion_forward_buffers_in_use %d\n", fb.InUse)
+
+	buf.WriteString("# HELP bastion_forward_buffers_in_use_bytes Bytes held by checked-out forwarding buffers.\n")
+	buf.WriteString("# TYPE bastion_forward_buffers_in_use_bytes gauge\n")
+	fmt.Fprintf(&buf, "bastion_forward_buffers_in_use_bytes %d\n", fb.InUseBytes)
+
+	buf.WriteString("# HELP bastion_forward_buffers_allocs_total Forwarding buffer gets that allocated a new buffer.\n")
+	buf.WriteString("# TYPE bastion_forward_buffers_allocs_total counter\n")
+	fmt.Fprintf(&buf, "bastion_forward_buffers_allocs_total %d\n", fb.Allocs)
+
+	buf.WriteString("# HELP bastion_forward_buffers_resizes_total Adaptive forwarding buffer resizes.\n")
+	buf.WriteString("# TYPE bastion_forward_buffers_resizes_total counter\n")
+	fmt.Fprintf(&buf, "bastion_forward_buffers_resizes_total{direction=\"grow\"} %d\n", fb.Grows)
+	fmt.Fprintf(&buf, "bastion_forward_buffers_resizes_total{direction=\"shrink\"} %d\n", fb.Shrinks)
+
 	buf.WriteString("# HELP bastion_traffic_bytes_up_total Total uploaded bytes.\n")
 	buf.WriteString("# TYPE bastion_traffic_bytes_up_total counter\n")
 	fmt.Fprintf(&buf, "bastion_traffic_bytes_up_total %d\n", s.totalBytesUp)
@@ -637,10 +279,19 @@ func GetPrometheusMetrics(c *gin.Context) {
 	buf.WriteString("# TYPE bastion_traffic_bytes_down_total counter\n")
 	fmt.Fprintf(&buf, "bastion_traffic_bytes_down_total %d\n", s.totalBytesDown)
 
+	writeSessionThroughputMetrics(&buf, s.sessions)
+	writeQuotaMetrics(&buf, s.quotas)
+
 	buf.WriteString("# HELP bastion_http_logs_total Total HTTP audit log entries kept in memory.\n")
 	buf.WriteString("# TYPE bastion_http_logs_total gauge\n")
 	fmt.Fprintf(&buf, "bastion_http_logs_total %d\n", s.httpLogCount)
 
+	buf.WriteString("# HELP bastion_panics_recovered_total Panics recovered in forwarding goroutines and API handlers.\n")
+	buf.WriteString("# TYPE bastion_panics_recovered_total counter\n")
+	for _, p := range core.PanicsRecovered() {
+		fmt.Fprintf(&buf, "bastion_panics_recovered_total{component=\"%s\"} %d\n", promLabelEscape(p.Component), p.Count)
+	}
+
 	buf.WriteString("# HELP bastion_go_goroutines Number of goroutines.\n")
 	buf.WriteString("# TYPE bastion_go_goroutines gauge\n")
 	fmt.Fprintf(&buf, "bastion_go_goroutines %d\n", runtime.NumGoroutine())
@@ -660,94 +311,165 @@ func GetPrometheusMetrics(c *gin.Context) {
 	c.Data(http.StatusOK, "text/plain; version=0.0.4; charset=utf-8", buf.Bytes())
 }
 
-// GetErrorLogs returns recent error logs
-func GetErrorLogs(c *gin.Context) {
-	logs := core.ErrorLoggerInstance.GetErrorLogs()
-	okV2(c, logs)
-}
+// writeSessionThroughputMetrics writes the rolling rates and peaks of each running session, labeled
+// by its mapping key.
+func writeSessionThroughputMetrics(buf *bytes.Buffer, sessions map[string]core.SessionStats) {
+	keys := make([]string, 0, len(sessions))
+	for key := range sessions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	buf.WriteString("# HELP bastion_session_throughput_bytes_per_second Session transfer rate averaged over the window.\n")
+	buf.WriteString("# TYPE bastion_session_throughput_bytes_per_second gauge\n")
+	for _, key := range keys {
+		t, mapping := sessions[key].Throughput, promLabelEscape(key)
+		for _, r := range []struct {
+			direction, window string
+			bps               int64
+		}{
+			{"up", "1s", t.UpBps1s}, {"up", "10s", t.UpBps10s}, {"up", "60s", t.UpBps60s},
+			{"down", "1s", t.DownBps1s}, {"down", "10s", t.DownBps10s}, {"down", "60s", t.DownBps60s},
+		} {
+			fmt.Fprintf(buf, "bastion_session_throughput_bytes_per_second{mapping=\"%s\",direction=\"%s\",window=\"%s\"} %d\n", mapping, r.direction, r.window, r.bps)
+		}
+	}
 
-// ClearErrorLogs wipes error logs
-func ClearErrorLogs(c *gin.Context) {
-	core.ErrorLoggerInstance.ClearErrorLogs()
-	okV2(c, gin.H{"ok": true, "message": "Error logs cleared"})
-}
+	buf.WriteString("# HELP bastion_session_peak_throughput_bytes_per_second Highest 1s session transfer rate since the session started.\n")
+	buf.WriteString("# TYPE bastion_session_peak_throughput_bytes_per_second gauge\n")
+	for _, key := range keys {
+		t, mapping := sessions[key].Throughput, promLabelEscape(key)
+		fmt.Fprintf(buf, "bastion_session_peak_throughput_bytes_per_second{mapping=\"%s\",direction=\"up\"} %d\n", mapping, t.PeakUpBps)
+		fmt.Fprintf(buf, "bastion_session_peak_throughput_bytes_per_second{mapping=\"%s\",direction=\"down\"} %d\n", mapping, t.PeakDownBps)
+	}
 
-// GenerateShutdownCode creates a shutdown confirmation code
-func GenerateShutdownCode(c *gin.Context) {
-	shutdownMgr.mu.Lock()
-	defer shutdownMgr.mu.Unlock()
+	buf.WriteString("# HELP bastion_session_connections Active connections of a session.\n")
+	buf.WriteString("# TYPE bastion_session_connections gauge\n")
+	for _, key := range keys {
+		fmt.Fprintf(buf, "bastion_session_connections{mapping=\"%s\"} %d\n", promLabelEscape(key), sessions[key].ActiveConns)
+	}
 
-	// Generate a 6-digit random number
-	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
-	if err != nil {
-		errV2(c, CodeInternal, "Internal error", "Failed to generate code")
-		return
+	buf.WriteString("# HELP bastion_session_peak_connections Most concurrent connections since the session started.\n")
+	buf.WriteString("# TYPE bastion_session_peak_connections gauge\n")
+	for _, key := range keys {
+		fmt.Fprintf(buf, "bastion_session_peak_connections{mapping=\"%s\"} %d\n", promLabelEscape(key), sessions[key].Throughput.PeakConns)
 	}
+}
 
-	shutdownMgr.code = fmt.Sprintf("%06d", n.Int64())
-	shutdownMgr.expiresAt = time.Now().Add(5 * time.Minute) // 5-minute expiration
+// writeQuotaMetrics writes the daily quota and the traffic counted against it of each running session
+// that has one, labeled by its mapping key.
+func writeQuotaMetrics(buf *bytes.Buffer, quotas map[string]*models.MappingQuotaStatus) {
+	keys := make([]string, 0, len(quotas))
+	for key := range quotas {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
 
-	okV2(c, gin.H{
-		"code":       shutdownMgr.code,
-		"expires_at": shutdownMgr.expiresAt.Unix(),
-	})
-}
+	buf.WriteString("# HELP bastion_mapping_quota_bytes Daily traffic quota of a mapping.\n")
+	buf.WriteString("# TYPE bastion_mapping_quota_bytes gauge\n")
+	for _, key := range keys {
+		fmt.Fprintf(buf, "bastion_mapping_quota_bytes{mapping=\"%s\"} %d\n", promLabelEscape(key), quotas[key].LimitBytes)
+	}
 
-// VerifyAndShutdown validates the confirmation code and shuts the app down
-func VerifyAndShutdown(c *gin.Context) {
-	var req struct {
-		Code string `json:"code" binding:"required"`
+	buf.WriteString("# HELP bastion_mapping_quota_used_bytes Traffic counted against a mapping's quota in the current period.\n")
+	buf.WriteString("# TYPE bastion_mapping_quota_used_bytes gauge\n")
+	for _, key := range keys {
+		fmt.Fprintf(buf, "bastion_mapping_quota_used_bytes{mapping=\"%s\"} %d\n", promLabelEscape(key), quotas[key].UsedBytes)
 	}
 
-	if err := c.ShouldBindJSON(&req); err != nil {
-		errV2(c, CodeInvalidRequest, "Invalid request", "Invalid request")
-		return
+	buf.WriteString("# HELP bastion_mapping_quota_exceeded Whether a mapping used up its quota (1) in the current period.\n")
+	buf.WriteString("# TYPE bastion_mapping_quota_exceeded gauge\n")
+	for _, key := range keys {
+		exceeded := 0
+		if quotas[key].Exceeded {
+			exceeded = 1
+		}
+		fmt.Fprintf(buf, "bastion_mapping_quota_exceeded{mapping=\"%s\"} %d\n", promLabelEscape(key), exceeded)
 	}
+}
 
-	shutdownMgr.mu.RLock()
-	storedCode := shutdownMgr.code
-	expiresAt := shutdownMgr.expiresAt
-	shutdownMgr.mu.RUnlock()
+// parseErrorLogQuery parses error log filters and pagination from the query string.
+// paginated is false when no filter or paging parameter was supplied (legacy array response).
+// On invalid input it writes the error response and returns ok=false.
+func parseErrorLogQuery(c *gin.Context) (filter models.ErrorLogFilter, page, pageSize int, paginated, ok bool) {
+	page = 1
+	pageSize = 20
+
+	for _, key := range []string{"page", "page_size", "level", "min_level", "component", "since", "until", "q"} {
+		if _, exists := c.GetQuery(key); exists {
+			paginated = true
+			break
+		}
+	}
 
-	// Ensure a code was issued
-	if storedCode == "" {
-		errV2(c, CodeInvalidRequest, "Invalid request", "No shutdown code generated. Please generate one first.")
-		return
+	if pageStr := c.Query("page"); pageStr != "" {
+		if p, err := strconv.Atoi(pageStr); err == nil && p > 0 {
+			page = p
+		}
+	}
+	if sizeStr := c.Query("page_size"); sizeStr != "" {
+		if s, err := strconv.Atoi(sizeStr); err == nil && s > 0 {
+			pageSize = s
+		}
 	}
 
-	// Check expiration
-	if time.Now().After(expiresAt) {
-		shutdownMgr.mu.Lock()
-		shutdownMgr.code = ""
-		shutdownMgr.mu.Unlock()
-		errV2(c, CodeInvalidRequest, "Invalid request", "Shutdown code expired. Please generate a new one.")
-		return
+	if levels := strings.TrimSpace(c.Query("level")); levels != "" {
+		for _, level := range strings.Split(levels, ",") {
+			level = strings.ToUpper(strings.TrimSpace(level))
+			if level == "" {
+				continue
+			}
+			if models.ErrorLogLevelRank(level) < 0 {
+				errV2(c, CodeInvalidRequest, "Invalid level", "invalid level")
+				return filter, page, pageSize, paginated, false
+			}
+			filter.Levels = append(filter.Levels, level)
+		}
+	}
+	if minLevel := strings.TrimSpace(c.Query("min_level")); minLevel != "" {
+		if models.ErrorLogLevelRank(minLevel) < 0 {
+			errV2(c, CodeInvalidRequest, "Invalid min_level", "invalid min_level")
+			return filter, page, pageSize, paginated, false
+		}
+		filter.MinLevel = strings.ToUpper(minLevel)
 	}
+	filter.Source = strings.TrimSpace(c.Query("component"))
+	filter.Query = strings.TrimSpace(c.Query("q"))
 
-	// Validate the code value
-	if req.Code != storedCode {
-		errV2(c, CodeInvalidRequest, "Invalid request", "Invalid shutdown code")
-		return
+	parseTime := func(value string) (*time.Time, error) {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			return nil, nil
+		}
+		if unix, err := strconv.ParseInt(value, 10, 64); err == nil {
+			tm := time.Unix(unix, 0)
+			return &tm, nil
+		}
+		tm, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, err
+		}
+		return &tm, nil
 	}
 
-	// Clear the stored code
-	shutdownMgr.mu.Lock()
-	shutdownMgr.code = ""
-	shutdownMgr.mu.Unlock()
-
-	// Respond success
-	okV2(c, gin.H{"ok": true, "message": "Shutdown initiated"})
-
-	// Perform graceful shutdown in the background
-	go func() {
-		time.Sleep(500 * time.Millisecond) // Give clients time to receive the response
-		core.LogErrorWithDetail("System", "Shutdown requested via API", "User initiated shutdown with confirmation code")
-		// Send interrupt to trigger graceful shutdown
-		// Note: main.go must initialize the global shutdown channel
-		if shutdownChan != nil {
-			shutdownChan <- true
+	if sinceStr := c.Query("since"); sinceStr != "" {
+		tm, err := parseTime(sinceStr)
+		if err != nil {
+			errV2(c, CodeInvalidRequest, "Invalid since timestamp", "invalid since")
+			return filter, page, pageSize, paginated, false
 		}
-	}()
+		filter.Since = tm
+	}
+	if untilStr := c.Query("until"); untilStr != "" {
+		tm, err := parseTime(untilStr)
+		if err != nil {
+			errV2(c, CodeInvalidRequest, "Invalid until timestamp", "invalid until")
+			return filter, page, pageSize, paginated, false
+		}
+		filter.Until = tm
+	}
+
+	return filter, page, pageSize, paginated, true
 }
 
 // Global shutdown channel (must be initialized in main.go)
@@ -8,8 +8,10 @@ import (
 	"bastion/service"
 	"bastion/state"
 	"crypto/rand"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"math/big"
 	"net"
 	"net/http"
@@ -23,28 +25,28 @@ import (
 )
 
 func ListBastionsV2(c *gin.Context) {
-	bastions, err := service.GlobalServices.Bastion.List()
+	bastions, err := scopedServices(c).Bastion.Search(listFilter(c))
 	if err != nil {
-		errV2(c, CodeInternal, "Failed to list bastions", err.Error())
+		errV2(c, CodeInternal, "Failed to list bastions", err)
 		return
 	}
-	okV2(c, bastions)
+	respondList(c, bastions, "name")
 }
 
 func CreateBastionV2(c *gin.Context) {
 	var req models.BastionCreate
 	if err := c.ShouldBindJSON(&req); err != nil {
-		errV2(c, CodeInvalidRequest, "Invalid request", err.Error())
+		errV2(c, CodeInvalidRequest, "Invalid request", err)
 		return
 	}
 
-	bastion, err := service.GlobalServices.Bastion.Create(req)
+	bastion, err := scopedServices(c).Bastion.Create(req)
 	if err != nil {
-		errV2(c, CodeInvalidRequest, "Failed to create bastion", err.Error())
+		errV2(c, CodeInvalidRequest, "Failed to create bastion", err)
 		return
 	}
 
-	okV2(c, gin.H{"id": bastion.ID})
+	okV2(c, gin.H{"ok": true, "id": bastion.ID})
 }
 
 func UpdateBastionV2(c *gin.Context) {
@@ -57,13 +59,16 @@ func UpdateBastionV2(c *gin.Context) {
 
 	var req models.BastionCreate
 	if err := c.ShouldBindJSON(&req); err != nil {
-		errV2(c, CodeInvalidRequest, "Invalid request", err.Error())
+		errV2(c, CodeInvalidRequest, "Invalid request", err)
+		return
+	}
+	if req.Version, err = requireVersion(c, req.Version); err != nil {
 		return
 	}
 
-	existingBastion, err := service.GlobalServices.Bastion.Get(uint(bastionID))
+	existingBastion, err := scopedServices(c).Bastion.Get(uint(bastionID))
 	if err != nil {
-		errV2(c, CodeInvalidRequest, "Failed to load bastion", err.Error())
+		errV2(c, CodeInvalidRequest, "Failed to load bastion", err)
 		return
 	}
 	if req.Name != "" && req.Name != existingBastion.Name {
@@ -86,9 +91,9 @@ func UpdateBastionV2(c *gin.Context) {
 	}
 	state.Global.RUnlock()
 
-	_, runningMappings, _, checkErr := service.GlobalServices.Bastion.CheckInUse(existingBastion.Name, running)
+	_, runningMappings, _, checkErr := scopedServices(c).Bastion.CheckInUse(existingBastion.Name, running)
 	if checkErr != nil {
-		errV2(c, CodeInternal, "Failed to check bastion usage", checkErr.Error())
+		errV2(c, CodeInternal, "Failed to check bastion usage", checkErr)
 		return
 	}
 	if len(runningMappings) > 0 {
@@ -98,12 +103,18 @@ func UpdateBastionV2(c *gin.Context) {
 		return
 	}
 
-	bastion, err := service.GlobalServices.Bastion.Update(uint(bastionID), req)
+	bastion, err := scopedServices(c).Bastion.Update(uint(bastionID), req)
 	if err != nil {
-		errV2(c, CodeInvalidRequest, "Failed to update bastion", err.Error())
+		if errors.Is(err, service.ErrVersionConflict) {
+			current, _ := scopedServices(c).Bastion.Get(uint(bastionID))
+			respondVersionConflict(c, err, current)
+			return
+		}
+		errV2(c, CodeInvalidRequest, "Failed to update bastion", err)
 		return
 	}
-	okV2(c, gin.H{"id": bastion.ID})
+	setVersionHeader(c, bastion.Version)
+	okV2(c, gin.H{"ok": true, "id": bastion.ID, "version": bastion.Version})
 }
 
 func DeleteBastionV2(c *gin.Context) {
@@ -114,40 +125,152 @@ func DeleteBastionV2(c *gin.Context) {
 		return
 	}
 
-	if err := service.GlobalServices.Bastion.Delete(uint(bastionID)); err != nil {
-		errV2(c, CodeInvalidRequest, "Failed to delete bastion", err.Error())
+	if err := scopedServices(c).Bastion.Delete(uint(bastionID)); err != nil {
+		errV2(c, CodeInvalidRequest, "Failed to delete bastion", err)
 		return
 	}
 	okV2(c, gin.H{"ok": true})
 }
 
+// RotateBastionCredentialsV2 replaces a bastion's credentials after a live SSH test with them
+// succeeds. A rejected test responds BAD_GATEWAY with the test report and changes nothing.
+func RotateBastionCredentialsV2(c *gin.Context) {
+	bastionID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		errV2(c, CodeInvalidRequest, "Invalid bastion id", "invalid bastion id")
+		return
+	}
+
+	var req models.BastionCredentials
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errV2(c, CodeInvalidRequest, "Invalid request", err)
+		return
+	}
+	if req.Version, err = requestVersion(c, req.Version); err != nil {
+		errV2(c, CodeInvalidRequest, "Invalid version", err)
+		return
+	}
+
+	services := scopedServices(c)
+	rotation, err := services.Mapping.RotateBastionCredentials(uint(bastionID), req)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrCredentialVerifyFailed):
+			respondV2(c, CodeBadGateway, "Bastion rejected the new credentials", gin.H{
+				"detail":       err.Error(),
+				"verification": rotation.Verification,
+			})
+		case errors.Is(err, service.ErrVersionConflict):
+			current, _ := services.Bastion.Get(uint(bastionID))
+			respondVersionConflict(c, err, current)
+		case errors.Is(err, service.ErrInvalidCredentials):
+			errV2(c, CodeInvalidRequest, "Invalid credentials", err)
+		default:
+			errV2(c, CodeInvalidRequest, "Failed to rotate bastion credentials", err)
+		}
+		return
+	}
+	setVersionHeader(c, rotation.Version)
+	okV2(c, rotation)
+}
+
+// BenchmarkBastionV2 measures latency and throughput of the chain to a bastion. A failed run
+// responds BAD_GATEWAY with the partial report.
+func BenchmarkBastionV2(c *gin.Context) {
+	bastionID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		errV2(c, CodeInvalidRequest, "Invalid bastion id", "invalid bastion id")
+		return
+	}
+
+	var req models.BastionBenchmarkRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		errV2(c, CodeInvalidRequest, "Invalid request", err)
+		return
+	}
+
+	report, err := scopedServices(c).Mapping.BenchmarkBastion(uint(bastionID), req)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrBenchmarkFailed):
+			respondV2(c, CodeBadGateway, "Bastion benchmark failed", gin.H{
+				"detail":    err.Error(),
+				"benchmark": report,
+			})
+		case errors.Is(err, service.ErrInvalidBenchmark):
+			errV2(c, CodeInvalidRequest, "Invalid benchmark request", err)
+		default:
+			errV2(c, CodeInvalidRequest, "Failed to benchmark bastion", err)
+		}
+		return
+	}
+	okV2(c, report)
+}
+
+// DockerContainersV2 lists the Docker containers on a bastion host with a ready-to-create mapping
+// for each published port. A failed listing responds BAD_GATEWAY with the hops connected.
+func DockerContainersV2(c *gin.Context) {
+	bastionID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		errV2(c, CodeInvalidRequest, "Invalid bastion id", "invalid bastion id")
+		return
+	}
+
+	var req models.BastionDockerRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		errV2(c, CodeInvalidRequest, "Invalid request", err)
+		return
+	}
+
+	listing, err := scopedServices(c).Mapping.DockerContainers(uint(bastionID), req)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrDockerFailed):
+			respondV2(c, CodeBadGateway, "Docker discovery failed", gin.H{
+				"detail": err.Error(),
+				"docker": listing,
+			})
+		case errors.Is(err, service.ErrInvalidDockerRequest):
+			errV2(c, CodeInvalidRequest, "Invalid docker request", err)
+		default:
+			errV2(c, CodeInvalidRequest, "Failed to list containers", err)
+		}
+		return
+	}
+	okV2(c, listing)
+}
+
 func ListMappingsV2(c *gin.Context) {
-	mappings, err := service.GlobalServices.Mapping.List()
+	svc := scopedServices(c).Mapping
+	if c.Query("secrets") == "true" {
+		svc = svc.WithSecrets()
+	}
+	mappings, err := svc.Search(listFilter(c))
 	if err != nil {
-		errV2(c, CodeInternal, "Failed to list mappings", err.Error())
+		errV2(c, CodeInternal, "Failed to list mappings", err)
 		return
 	}
-	okV2(c, mappings)
+	respondList(c, mappings, "id")
 }
 
 func CreateMappingV2(c *gin.Context) {
 	var req models.MappingCreate
 	if err := c.ShouldBindJSON(&req); err != nil {
-		errV2(c, CodeInvalidRequest, "Invalid request", err.Error())
+		errV2(c, CodeInvalidRequest, "Invalid request", err)
 		return
 	}
 
-	mapping, err := service.GlobalServices.Mapping.Create(req)
+	mapping, err := scopedServices(c).Mapping.Create(req)
 	if err != nil {
 		if errors.Is(err, service.ErrMappingAlreadyExists) {
-			errV2(c, CodeConflict, "Mapping already exists", err.Error())
+			errV2(c, CodeConflict, "Mapping already exists", err)
 			return
 		}
-		errV2(c, CodeInvalidRequest, "Failed to create mapping", err.Error())
+		errV2(c, CodeInvalidRequest, "Failed to create mapping", err)
 		return
 	}
 
-	okV2(c, gin.H{"id": mapping.ID})
+	okV2(c, gin.H{"ok": true, "id": mapping.ID})
 }
 
 func UpdateMappingV2(c *gin.Context) {
@@ -155,36 +278,143 @@ func UpdateMappingV2(c *gin.Context) {
 
 	var req models.MappingCreate
 	if err := c.ShouldBindJSON(&req); err != nil {
-		errV2(c, CodeInvalidRequest, "Invalid request", err.Error())
+		errV2(c, CodeInvalidRequest, "Invalid request", err)
 		return
 	}
-
-	mapping, err := service.GlobalServices.Mapping.Update(id, req)
+	version, err := requireVersion(c, req.Version)
 	if err != nil {
+		return
+	}
+	req.Version = version
+
+	// ?force=true also allows changing the local/remote address and type; ?restart=true then
+	// applies the change to a running mapping by stopping and starting it.
+	var mapping *models.Mapping
+	if c.Query("force") == "true" {
+		mapping, err = scopedServices(c).Mapping.ForceUpdate(id, req, c.Query("restart") == "true")
+	} else {
+		mapping, err = scopedServices(c).Mapping.Update(id, req)
+	}
+	if errors.Is(err, service.ErrMappingRestartFailed) {
+		setVersionHeader(c, mapping.Version)
+		errV2(c, CodeBadGateway, "Mapping updated but failed to restart", err)
+		return
+	}
+	if err != nil {
+		if errors.Is(err, service.ErrVersionConflict) {
+			current, _ := scopedServices(c).Mapping.Read(id)
+			respondVersionConflict(c, err, current)
+			return
+		}
 		if errors.Is(err, service.ErrMappingRunning) {
-			errV2(c, CodeConflict, "Mapping is running", err.Error())
+			errV2(c, CodeConflict, "Mapping is running", err)
 			return
 		}
 		if errors.Is(err, service.ErrMappingNotFound) {
-			errV2(c, CodeNotFound, "Mapping not found", err.Error())
+			errV2(c, CodeNotFound, "Mapping not found", err)
 			return
 		}
-		errV2(c, CodeInvalidRequest, "Failed to update mapping", err.Error())
+		errV2(c, CodeInvalidRequest, "Failed to update mapping", err)
 		return
 	}
 
-	okV2(c, gin.H{"id": mapping.ID})
+	setVersionHeader(c, mapping.Version)
+	okV2(c, gin.H{"ok": true, "id": mapping.ID, "version": mapping.Version})
+}
+
+// RenameMappingV2 changes a mapping's ID, moving its history and usage totals with it; a running
+// mapping is restarted under the new ID. Body: {"id": "new-id"}, optionally with "version" (or
+// If-Match).
+func RenameMappingV2(c *gin.Context) {
+	var req struct {
+		ID      string `json:"id" binding:"required"`
+		Version int    `json:"version"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errV2(c, CodeInvalidRequest, "Invalid request", err)
+		return
+	}
+	version, err := requestVersion(c, req.Version)
+	if err != nil {
+		errV2(c, CodeInvalidRequest, "Invalid version", err)
+		return
+	}
+
+	mappingSvc := scopedServices(c).Mapping
+	mapping, err := mappingSvc.Rename(c.Param("id"), req.ID, version)
+	if errors.Is(err, service.ErrMappingRestartFailed) {
+		setVersionHeader(c, mapping.Version)
+		errV2(c, CodeBadGateway, "Mapping renamed but failed to restart", err)
+		return
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrMappingNotFound):
+			errV2(c, CodeNotFound, "Mapping not found", err)
+		case errors.Is(err, service.ErrMappingAlreadyExists):
+			errV2(c, CodeConflict, "Mapping already exists", err)
+		case errors.Is(err, service.ErrVersionConflict):
+			current, _ := mappingSvc.Read(c.Param("id"))
+			respondVersionConflict(c, err, current)
+		case errors.Is(err, service.ErrInvalidMappingID):
+			errV2(c, CodeInvalidRequest, "Invalid mapping id", err)
+		default:
+			errV2(c, CodeInternal, "Failed to rename mapping", err)
+		}
+		return
+	}
+
+	setVersionHeader(c, mapping.Version)
+	okV2(c, gin.H{"id": mapping.ID, "version": mapping.Version})
+}
+
+// BulkMappingsV2 creates or updates many mappings in one call. The body is
+// {"mappings":[...],"atomic":true} or a bare array of mappings (atomic with ?atomic=true). Each
+// item reports its own result; a rolled-back atomic request responds INVALID_REQUEST with the same
+// results.
+func BulkMappingsV2(c *gin.Context) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		errV2(c, CodeInvalidRequest, "Invalid request", err)
+		return
+	}
+	var req models.MappingBulkRequest
+	if trimmed := strings.TrimSpace(string(body)); strings.HasPrefix(trimmed, "[") {
+		err = json.Unmarshal(body, &req.Mappings)
+		req.Atomic = c.Query("atomic") == "true"
+	} else {
+		err = json.Unmarshal(body, &req)
+	}
+	if err != nil {
+		errV2(c, CodeInvalidRequest, "Invalid request", err)
+		return
+	}
+
+	resp, err := scopedServices(c).Mapping.Bulk(req)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidBulkRequest) {
+			errV2(c, CodeInvalidRequest, "Invalid bulk request", err)
+			return
+		}
+		errV2(c, CodeInternal, "Failed to apply mappings", err)
+		return
+	}
+	if !resp.Committed {
+		respondV2(c, CodeInvalidRequest, "Bulk request rolled back", resp)
+		return
+	}
+	okV2(c, resp)
 }
 
 func DeleteMappingV2(c *gin.Context) {
 	id := c.Param("id")
-	if state.Global.SessionExists(id) {
+	if scopedServices(c).Mapping.IsRunning(id) {
 		errV2(c, CodeConflict, "Mapping is running", "mapping is running")
 		return
 	}
 
-	if err := service.GlobalServices.Mapping.Delete(id); err != nil {
-		errV2(c, CodeInternal, "Failed to delete mapping", err.Error())
+	if err := scopedServices(c).Mapping.Delete(id); err != nil {
+		errV2(c, CodeInternal, "Failed to delete mapping", err)
 		return
 	}
 	okV2(c, gin.H{"ok": true})
@@ -192,14 +422,28 @@ func DeleteMappingV2(c *gin.Context) {
 
 func StartMappingV2(c *gin.Context) {
 	id := c.Param("id")
+	wait, err := startWaitTimeout(c)
+	if err != nil {
+		errV2(c, CodeInvalidRequest, "Invalid wait timeout", err)
+		return
+	}
 
-	if err := service.GlobalServices.Mapping.Start(id); err != nil {
+	mappingSvc := scopedServices(c).Mapping
+	if err := mappingSvc.Start(id); err != nil {
 		if errors.Is(err, service.ErrMappingAlreadyRunning) {
-			okV2(c, gin.H{"ok": true, "already_running": true})
+			respondStarted(c, mappingSvc, id, wait, gin.H{"ok": true, "already_running": true})
 			return
 		}
 		if errors.Is(err, service.ErrMappingNotFound) {
-			errV2(c, CodeNotFound, "Mapping not found", err.Error())
+			errV2(c, CodeNotFound, "Mapping not found", err)
+			return
+		}
+		if errors.Is(err, service.ErrTargetUnresolved) {
+			errV2(c, CodeInvalidRequest, "Mapping target could not be resolved", err)
+			return
+		}
+		if errors.Is(err, service.ErrInvalidDependencies) {
+			errV2(c, CodeInvalidRequest, "Mapping dependencies are invalid", err)
 			return
 		}
 
@@ -211,7 +455,7 @@ func StartMappingV2(c *gin.Context) {
 		var be *core.BastionError
 		if errors.As(err, &be) && be.Code == http.StatusConflict {
 			addr := ""
-			if m, getErr := service.GlobalServices.Mapping.Get(id); getErr == nil {
+			if m, getErr := mappingSvc.Get(id); getErr == nil {
 				addr = net.JoinHostPort(m.LocalHost, strconv.Itoa(m.LocalPort))
 			}
 			respondV2(c, CodeResourceBusy, "Local address is already in use", gin.H{
@@ -220,32 +464,158 @@ func StartMappingV2(c *gin.Context) {
 			})
 			return
 		}
-		// Keep status aligned with v1 (which uses 502 for other start failures)
-		errV2(c, CodeBadGateway, "Failed to start mapping", err.Error())
+		errV2(c, CodeBadGateway, "Failed to start mapping", err)
 		return
 	}
 
-	okV2(c, gin.H{"ok": true})
+	respondStarted(c, mappingSvc, id, wait, gin.H{"ok": true})
+}
+
+// startWaitTimeout parses the wait and timeout query parameters of a start request: how long to
+// probe the started mapping for readiness, or 0 without wait=true. The timeout is a duration ("10s")
+// or a number of seconds and defaults to core.DefaultReadinessTimeout.
+func startWaitTimeout(c *gin.Context) (time.Duration, error) {
+	waitStr := c.Query("wait")
+	if waitStr == "" {
+		return 0, nil
+	}
+	wait, err := strconv.ParseBool(waitStr)
+	if err != nil {
+		return 0, fmt.Errorf("invalid wait %q: %w", waitStr, err)
+	}
+	if !wait {
+		return 0, nil
+	}
+
+	timeoutStr := c.Query("timeout")
+	if timeoutStr == "" {
+		return core.DefaultReadinessTimeout, nil
+	}
+	timeout, err := time.ParseDuration(timeoutStr)
+	if err != nil {
+		secs, convErr := strconv.Atoi(timeoutStr)
+		if convErr != nil {
+			return 0, fmt.Errorf("invalid timeout %q: %w", timeoutStr, err)
+		}
+		timeout = time.Duration(secs) * time.Second
+	}
+	if timeout <= 0 || timeout > core.MaxReadinessTimeout {
+		return 0, fmt.Errorf("timeout must be positive and at most %s", core.MaxReadinessTimeout)
+	}
+	return timeout, nil
+}
+
+// respondStarted answers a successful start request. With a wait timeout, the mapping is probed
+// first and a mapping that stays unusable is reported as BAD_GATEWAY; it is left running.
+func respondStarted(c *gin.Context, mappingSvc *service.MappingService, id string, wait time.Duration, data gin.H) {
+	data["local_port"] = mappingSvc.RuntimePort(id)
+	if wait <= 0 {
+		okV2(c, data)
+		return
+	}
+
+	report, err := mappingSvc.WaitReady(id, wait)
+	if err != nil {
+		errV2(c, CodeBadGateway, "Mapping stopped before it was ready", err)
+		return
+	}
+	data["readiness"] = report
+	if !report.Ready {
+		respondV2(c, CodeBadGateway, "Mapping started but is not ready", data)
+		return
+	}
+	okV2(c, data)
+}
+
+// DryRunMappingV2 checks the bastion chain hop by hop (and optionally the target) without starting
+// the mapping. Body (optional): {"dial_target": true} and/or {"target": "host:port"}.
+func DryRunMappingV2(c *gin.Context) {
+	var req struct {
+		DialTarget bool   `json:"dial_target"`
+		Target     string `json:"target"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		errV2(c, CodeInvalidRequest, "Invalid request", err)
+		return
+	}
+
+	report, err := scopedServices(c).Mapping.DryRun(c.Param("id"), req.DialTarget, strings.TrimSpace(req.Target))
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrMappingNotFound):
+			errV2(c, CodeNotFound, "Mapping not found", err)
+		case errors.Is(err, service.ErrInvalidDryRunTarget):
+			errV2(c, CodeInvalidRequest, "Invalid dry-run target", err)
+		case errors.Is(err, service.ErrTargetUnresolved):
+			errV2(c, CodeInvalidRequest, "Mapping target could not be resolved", err)
+		default:
+			errV2(c, CodeInternal, "Dry run failed", err)
+		}
+		return
+	}
+	okV2(c, report)
+}
+
+func GetMappingEventsV2(c *gin.Context) {
+	id := c.Param("id")
+
+	limit := 0
+	if limitStr := c.Query("limit"); limitStr != "" {
+		l, err := strconv.Atoi(limitStr)
+		if err != nil || l <= 0 {
+			errV2(c, CodeInvalidRequest, "Invalid limit", "invalid limit")
+			return
+		}
+		limit = l
+	}
+
+	events, err := scopedServices(c).Mapping.Events(id, limit)
+	if err != nil {
+		if errors.Is(err, service.ErrMappingNotFound) {
+			errV2(c, CodeNotFound, "Mapping not found", err)
+			return
+		}
+		errV2(c, CodeInternal, "Failed to list mapping events", err)
+		return
+	}
+
+	okV2(c, events)
 }
 
 func StopMappingV2(c *gin.Context) {
 	id := c.Param("id")
-	if err := service.GlobalServices.Mapping.Stop(id); err != nil {
+	if err := scopedServices(c).Mapping.Stop(id); err != nil {
 		okV2(c, gin.H{"ok": true, "stopped": false, "reason": "not_found_or_already_stopped"})
 		return
 	}
 	okV2(c, gin.H{"ok": true, "stopped": true})
 }
 
+// StopAllMappingsV2 stops every running mapping in all workspaces at once (a kill switch) and
+// reports the result of each one.
+func StopAllMappingsV2(c *gin.Context) {
+	okV2(c, scopedServices(c).Mapping.StopAll())
+}
+
 func GetStatsV2(c *gin.Context) {
-	statsMap := service.GlobalServices.Mapping.GetStats()
+	mappingSvc := scopedServices(c).Mapping
+	statsMap := mappingSvc.GetStats()
 
 	result := make(map[string]gin.H)
 	for id, s := range statsMap {
+		usage := mappingSvc.Usage(id)
 		result[id] = gin.H{
-			"up_bytes":    s.BytesUp,
-			"down_bytes":  s.BytesDown,
-			"connections": s.ActiveConns,
+			"up_bytes":           s.BytesUp,
+			"down_bytes":         s.BytesDown,
+			"connections":        s.ActiveConns,
+			"local_port":         s.LocalPort,
+			"throughput":         s.Throughput,
+			"total_up_bytes":     usage.BytesUp,
+			"total_down_bytes":   usage.BytesDown,
+			"last_started_at":    usage.LastStartedAt,
+			"last_active_at":     usage.LastActiveAt,
+			"channel_limit_hits": s.ChannelLimitHits,
+			"last_dial_error":    s.LastDialError,
 		}
 	}
 
@@ -268,6 +638,87 @@ func GetHTTPLogsV2(c *gin.Context) {
 		}
 	}
 
+	filter, ok := httpLogFilterFromQuery(c)
+	if !ok {
+		return
+	}
+
+	logs, total := service.GlobalServices.Audit.QueryHTTPLogs(filter, page, pageSize)
+	items, ok := httpLogListItems(c, logs, filter)
+	if !ok {
+		return
+	}
+	okV2(c, gin.H{
+		pageItemsKey(c): items,
+		"page":          page,
+		"page_size":     pageSize,
+		"total":         total,
+	})
+}
+
+// httpLogWithMatches is a list item carrying the query matches of its log.
+type httpLogWithMatches struct {
+	*core.HTTPLog
+	Matches []core.HTTPLogMatch `json:"matches"`
+}
+
+// httpLogSummaryItem is a list item without request and response contents.
+type httpLogSummaryItem struct {
+	core.HTTPLogSummary
+	Matches []core.HTTPLogMatch `json:"matches,omitempty"`
+}
+
+// httpLogListItems applies the list options shared by the v1 and v2 list endpoints:
+// summary=true leaves out request and response contents, and matches=<n> adds up to n occurrences
+// of the q query per log with snippets for highlighting. It writes a v2 error and returns false
+// when an option is invalid.
+func httpLogListItems(c *gin.Context, logs []*core.HTTPLog, filter core.HTTPLogFilter) (interface{}, bool) {
+	summary := false
+	if summaryStr := strings.TrimSpace(c.Query("summary")); summaryStr != "" {
+		v, err := strconv.ParseBool(summaryStr)
+		if err != nil {
+			errV2(c, CodeInvalidRequest, "Invalid summary flag", "invalid summary flag")
+			return nil, false
+		}
+		summary = v
+	}
+	matches := 0
+	if matchesStr := strings.TrimSpace(c.Query("matches")); matchesStr != "" {
+		n, err := strconv.Atoi(matchesStr)
+		if err != nil || n < 0 || n > core.MaxHTTPLogMatches {
+			errV2(c, CodeInvalidRequest, "Invalid matches", fmt.Sprintf("matches must be between 0 and %d", core.MaxHTTPLogMatches))
+			return nil, false
+		}
+		matches = n
+	}
+	if filter.Query == "" {
+		matches = 0
+	}
+	if !summary && matches == 0 {
+		return logs, true
+	}
+
+	if summary {
+		items := make([]httpLogSummaryItem, 0, len(logs))
+		for _, l := range logs {
+			items = append(items, httpLogSummaryItem{HTTPLogSummary: l.Summary(), Matches: core.FindHTTPLogMatches(l, filter, matches)})
+		}
+		return items, true
+	}
+	items := make([]httpLogWithMatches, 0, len(logs))
+	for _, l := range logs {
+		m := core.FindHTTPLogMatches(l, filter, matches)
+		if m == nil {
+			m = []core.HTTPLogMatch{}
+		}
+		items = append(items, httpLogWithMatches{HTTPLog: l, Matches: m})
+	}
+	return items, true
+}
+
+// httpLogFilterFromQuery parses the HTTP log filter parameters shared by the list and stream
+// endpoints, writing a v2 error and returning false when one is invalid.
+func httpLogFilterFromQuery(c *gin.Context) (core.HTTPLogFilter, bool) {
 	filter := core.HTTPLogFilter{}
 
 	if q := strings.TrimSpace(c.Query("q")); q != "" {
@@ -276,13 +727,13 @@ func GetHTTPLogsV2(c *gin.Context) {
 			useRegex, err := strconv.ParseBool(regexStr)
 			if err != nil {
 				errV2(c, CodeInvalidRequest, "Invalid regex flag", "invalid regex flag")
-				return
+				return filter, false
 			}
 			if useRegex {
 				re, err := regexp.Compile(q)
 				if err != nil {
 					errV2(c, CodeInvalidRequest, "Invalid regex pattern", "invalid regex pattern")
-					return
+					return filter, false
 				}
 				filter.QueryRegex = re
 			}
@@ -305,7 +756,7 @@ func GetHTTPLogsV2(c *gin.Context) {
 		p, err := strconv.Atoi(localPortStr)
 		if err != nil || p <= 0 || p > 65535 {
 			errV2(c, CodeInvalidRequest, "Invalid local_port", "invalid local_port")
-			return
+			return filter, false
 		}
 		filter.LocalPort = &p
 	}
@@ -313,10 +764,20 @@ func GetHTTPLogsV2(c *gin.Context) {
 		code, err := strconv.Atoi(statusStr)
 		if err != nil || code < 0 {
 			errV2(c, CodeInvalidRequest, "Invalid status code", "invalid status")
-			return
+			return filter, false
 		}
 		filter.StatusCode = code
 	}
+	// header=<name> requires the header; header=<name>:<value> also matches its value.
+	for _, h := range c.QueryArray("header") {
+		name, value, _ := strings.Cut(h, ":")
+		name = strings.TrimSpace(name)
+		if name == "" {
+			errV2(c, CodeInvalidRequest, "Invalid header filter", "header must be <name> or <name>:<value>")
+			return filter, false
+		}
+		filter.Headers = append(filter.Headers, core.HTTPHeaderFilter{Name: name, Value: strings.TrimSpace(value)})
+	}
 
 	parseTime := func(value string) (*time.Time, error) {
 		value = strings.TrimSpace(value)
@@ -338,7 +799,7 @@ func GetHTTPLogsV2(c *gin.Context) {
 		tm, err := parseTime(sinceStr)
 		if err != nil {
 			errV2(c, CodeInvalidRequest, "Invalid since timestamp", "invalid since")
-			return
+			return filter, false
 		}
 		filter.Since = tm
 	}
@@ -346,18 +807,11 @@ func GetHTTPLogsV2(c *gin.Context) {
 		tm, err := parseTime(untilStr)
 		if err != nil {
 			errV2(c, CodeInvalidRequest, "Invalid until timestamp", "invalid until")
-			return
+			return filter, false
 		}
 		filter.Until = tm
 	}
-
-	logs, total := service.GlobalServices.Audit.QueryHTTPLogs(filter, page, pageSize)
-	okV2(c, gin.H{
-		"items":     logs,
-		"page":      page,
-		"page_size": pageSize,
-		"total":     total,
-	})
+	return filter, true
 }
 
 func respondHTTPLogPartV2(c *gin.Context, id int, partStr string) {
@@ -376,6 +830,14 @@ func respondHTTPLogPartV2(c *gin.Context, id int, partStr string) {
 		}
 		opts.DecodeGzip = true
 	}
+	if formatStr := c.Query("format"); formatStr != "" {
+		format, err := core.ParseHTTPLogBodyFormat(formatStr)
+		if err != nil {
+			errV2(c, CodeInvalidRequest, "Invalid format value", err)
+			return
+		}
+		opts.Format = format
+	}
 
 	result, err := service.GlobalServices.Audit.GetHTTPLogPart(id, part, opts)
 	if err != nil {
@@ -384,14 +846,14 @@ func respondHTTPLogPartV2(c *gin.Context, id int, partStr string) {
 			return
 		}
 		if errors.Is(err, core.ErrInvalidHTTPLogPart) || errors.Is(err, core.ErrNotGzippedResponse) {
-			errV2(c, CodeInvalidRequest, "Invalid request", err.Error())
+			errV2(c, CodeInvalidRequest, "Invalid request", err)
 			return
 		}
 		if errors.Is(err, core.ErrGzipDecodeNotAllowed) {
 			errV2(c, CodeInvalidRequest, "Invalid request", "decode is only supported for part=response_body")
 			return
 		}
-		errV2(c, CodeInternal, "Failed to fetch log detail", err.Error())
+		errV2(c, CodeInternal, "Failed to fetch log detail", err)
 		return
 	}
 
@@ -431,13 +893,154 @@ func GetHTTPLogDetailV2(c *gin.Context) {
 	okV2(c, log)
 }
 
+// AggregateHTTPLogsV2 groups HTTP logs by host, URL or status code. It takes the filter parameters
+// of GetHTTPLogsV2, plus range (a duration such as 15m or 1h limiting the logs to the most recent
+// ones) and limit (maximum number of groups, default 50).
+func AggregateHTTPLogsV2(c *gin.Context) {
+	filter, ok := httpLogFilterFromQuery(c)
+	if !ok {
+		return
+	}
+
+	groupBy := strings.ToLower(strings.TrimSpace(c.DefaultQuery("group_by", core.HTTPLogGroupByHost)))
+	if err := core.ValidateHTTPLogGroupBy(groupBy); err != nil {
+		errV2(c, CodeInvalidRequest, "Invalid group_by", err)
+		return
+	}
+
+	if rangeStr := strings.TrimSpace(c.Query("range")); rangeStr != "" {
+		d, err := time.ParseDuration(rangeStr)
+		if err != nil || d <= 0 {
+			errV2(c, CodeInvalidRequest, "Invalid range", "range must be a positive duration such as 15m or 1h")
+			return
+		}
+		since := time.Now().Add(-d)
+		if filter.Since == nil || filter.Since.Before(since) {
+			filter.Since = &since
+		}
+	}
+
+	limit := 50
+	if limitStr := strings.TrimSpace(c.Query("limit")); limitStr != "" {
+		l, err := strconv.Atoi(limitStr)
+		if err != nil || l < 0 {
+			errV2(c, CodeInvalidRequest, "Invalid limit", "invalid limit")
+			return
+		}
+		limit = l
+	}
+
+	result, err := service.GlobalServices.Audit.AggregateHTTPLogs(filter, groupBy, limit)
+	if err != nil {
+		errV2(c, CodeInternal, "Failed to aggregate logs", err)
+		return
+	}
+	okV2(c, result)
+}
+
+func CompareHTTPLogsV2(c *gin.Context) {
+	idA, errA := strconv.Atoi(strings.TrimSpace(c.Query("a")))
+	idB, errB := strconv.Atoi(strings.TrimSpace(c.Query("b")))
+	if errA != nil || errB != nil {
+		errV2(c, CodeInvalidRequest, "Invalid log ids", "a and b must be HTTP log ids")
+		return
+	}
+
+	diff, err := service.GlobalServices.Audit.CompareHTTPLogs(idA, idB)
+	if err != nil {
+		if errors.Is(err, core.ErrHTTPLogNotFound) {
+			errV2(c, CodeNotFound, "Log not found", "log not found")
+			return
+		}
+		errV2(c, CodeInternal, "Failed to compare logs", err)
+		return
+	}
+
+	okV2(c, diff)
+}
+
+func ReplayHTTPLogV2(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		errV2(c, CodeInvalidRequest, "Invalid id", "invalid id")
+		return
+	}
+
+	var req models.HTTPReplayRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		errV2(c, CodeInvalidRequest, "Invalid request", err)
+		return
+	}
+
+	result, err := scopedServices(c).Audit.ReplayHTTPLog(id, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, core.ErrHTTPLogNotFound):
+			errV2(c, CodeNotFound, "Log not found", "log not found")
+		case errors.Is(err, service.ErrMappingNotFound):
+			errV2(c, CodeNotFound, "Mapping not found", err)
+		case errors.Is(err, core.ErrHTTPReplayInvalidRequest):
+			errV2(c, CodeInvalidRequest, "Request cannot be replayed", err)
+		case errors.Is(err, core.ErrHTTPReplayUpstream):
+			errV2(c, CodeBadGateway, "Replay failed", err)
+		default:
+			errV2(c, CodeInternal, "Replay failed", err)
+		}
+		return
+	}
+
+	okV2(c, result)
+}
+
+func GetHTTPLogCurlV2(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		errV2(c, CodeInvalidRequest, "Invalid id", "invalid id")
+		return
+	}
+
+	result, err := service.GlobalServices.Audit.HTTPLogCurl(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, core.ErrHTTPLogNotFound):
+			errV2(c, CodeNotFound, "Log not found", "log not found")
+		case errors.Is(err, core.ErrHTTPLogRequestInvalid):
+			errV2(c, CodeInvalidRequest, "Request cannot be exported", err)
+		default:
+			errV2(c, CodeInternal, "Failed to export request", err)
+		}
+		return
+	}
+
+	okV2(c, result)
+}
+
 func ClearHTTPLogsV2(c *gin.Context) {
 	service.GlobalServices.Audit.ClearHTTPLogs()
 	okV2(c, gin.H{"ok": true})
 }
 
 func GetErrorLogsV2(c *gin.Context) {
-	okV2(c, core.ErrorLoggerInstance.GetErrorLogs())
+	filter, page, pageSize, paginated, ok := parseErrorLogQuery(c)
+	if !ok {
+		return
+	}
+	if !paginated {
+		okV2(c, core.ErrorLoggerInstance.GetErrorLogs())
+		return
+	}
+
+	logs, total, err := core.ErrorLoggerInstance.QueryErrorLogs(filter, page, pageSize)
+	if err != nil {
+		errV2(c, CodeInternal, "Failed to query error logs", err)
+		return
+	}
+	okV2(c, gin.H{
+		pageItemsKey(c): logs,
+		"page":          page,
+		"page_size":     pageSize,
+		"total":         total,
+	})
 }
 
 func ClearErrorLogsV2(c *gin.Context) {
@@ -513,22 +1116,22 @@ func HealthCheckV2(c *gin.Context) {
 	sessionCount := len(state.Global.Sessions)
 	state.Global.RUnlock()
 
-	sqlDB, err := database.DB.DB()
-	dbHealthy := true
-	if err != nil {
-		dbHealthy = false
-	} else if err := sqlDB.Ping(); err != nil {
-		dbHealthy = false
-	}
+	// A database server that stopped answering must not hang the health check.
+	dbHealthy := database.Up(c.Request.Context())
 
 	health := gin.H{
 		"status":        "healthy",
 		"timestamp":     time.Now().Unix(),
 		"sessions":      sessionCount,
 		"db_healthy":    dbHealthy,
+		"db_backend":    database.Backend(),
 		"audit_enabled": config.Settings.AuditEnabled,
 	}
 
+	if ha := service.GlobalServices.HA.Status(); ha.Enabled {
+		health["ha_role"] = ha.Role
+	}
+
 	if !dbHealthy {
 		health["status"] = "degraded"
 		respondV2(c, CodeInternal, "Service degraded", health)
@@ -538,23 +1141,47 @@ func HealthCheckV2(c *gin.Context) {
 	okV2(c, health)
 }
 
+// GetSelfCheckV2 verifies the database, log file, key files, autostart ports and first hops and
+// returns the pass/fail list; failed checks are part of the report, not an error.
+func GetSelfCheckV2(c *gin.Context) {
+	okV2(c, service.GlobalServices.SelfCheck.Run())
+}
+
 func GetMetricsV2(c *gin.Context) {
+	okV2(c, metricsV2())
+}
+
+// metricsV2 builds the JSON metrics of GET /api/v2/metrics (also part of the diagnostics bundle).
+func metricsV2() gin.H {
 	// reuse existing helper
 	s := collectMetricsSnapshot()
 
-	metrics := gin.H{
+	return gin.H{
 		"timestamp": s.timestamp,
 		"audit": gin.H{
-			"queue_len":      service.GlobalServices.Audit.AuditQueueLen(),
-			"queue_capacity": service.GlobalServices.Audit.AuditQueueCap(),
-			"dropped_total":  service.GlobalServices.Audit.AuditDroppedTotal(),
+			"queue_len":        service.GlobalServices.Audit.AuditQueueLen(),
+			"queue_capacity":   service.GlobalServices.Audit.AuditQueueCap(),
+			"queue_high_water": service.GlobalServices.Audit.AuditQueueHighWater(),
+			"workers":          service.GlobalServices.Audit.AuditWorkers(),
+			"dropped_total":    service.GlobalServices.Audit.AuditDroppedTotal(),
+			"retained_bytes":   service.GlobalServices.Audit.RetainedBytes(),
+			"retained_budget":  service.GlobalServices.Audit.RetainedBytesBudget(),
+			"budget_evicted":   service.GlobalServices.Audit.BudgetEvictedTotal(),
+			"pairing":          service.GlobalServices.Audit.HTTPPairStats(),
 		},
 		"ssh_pool": gin.H{
-			"connections":        core.Pool.SSHPoolConnections(),
-			"active_conns":       core.Pool.SSHPoolActiveConns(),
-			"keepalive_failures": core.Pool.SSHKeepaliveFailuresTotal(),
-			"idle_closed_total":  core.Pool.SSHIdleClosedTotal(),
+			"connections":                core.Pool.SSHPoolConnections(),
+			"active_conns":               core.Pool.SSHPoolActiveConns(),
+			"shared_prefixes":            core.Pool.SSHPoolSharedPrefixes(),
+			"keepalive_failures":         core.Pool.SSHKeepaliveFailuresTotal(),
+			"idle_closed_total":          core.Pool.SSHIdleClosedTotal(),
+			"builds_in_progress":         core.Pool.SSHChainBuildsInProgress(),
+			"builds_queued":              core.Pool.SSHChainBuildsQueued(),
+			"builds_queued_total":        core.Pool.SSHChainBuildsQueuedTotal(),
+			"build_queue_timeouts_total": core.Pool.SSHChainBuildQueueTimeoutsTotal(),
+			"channel_limit_hits_total":   core.Pool.SSHChannelLimitHitsTotal(),
 		},
+		"forward_buffers": core.ForwardBufferStats(),
 		"sessions": gin.H{
 			"total":       s.sessionCount,
 			"connections": s.totalConnections,
@@ -564,9 +1191,12 @@ func GetMetricsV2(c *gin.Context) {
 			"bytes_down": s.totalBytesDown,
 			"total":      s.totalBytesUp + s.totalBytesDown,
 		},
+		"quotas":       s.quotas,
+		"event_export": core.EventExport.Stats(),
 		"http_logs": gin.H{
 			"total": s.httpLogCount,
 		},
+		"panics_recovered": core.PanicsRecovered(),
 		"system": gin.H{
 			"goroutines":   runtime.NumGoroutine(),
 			"memory_alloc": s.mem.Alloc,
@@ -575,6 +1205,4 @@ func GetMetricsV2(c *gin.Context) {
 			"gc_runs":      s.mem.NumGC,
 		},
 	}
-
-	okV2(c, metrics)
 }
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"bastion/service"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// httpLogStreamKeepalive is how often an idle stream sends a comment so proxies and clients do not
+// time it out.
+const httpLogStreamKeepalive = 15 * time.Second
+
+// StreamHTTPLogsV2 follows newly captured HTTP logs as server-sent events. It accepts the filter
+// parameters of GetHTTPLogsV2 and sends an "http_log" event with the log summary for every match,
+// plus a "dropped" event when the client fell behind and summaries were skipped.
+func StreamHTTPLogsV2(c *gin.Context) {
+	filter, ok := httpLogFilterFromQuery(c)
+	if !ok {
+		return
+	}
+
+	sub := service.GlobalServices.Audit.SubscribeHTTPLogs(filter)
+	defer sub.Close()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	_, _ = io.WriteString(c.Writer, ": connected\n\n")
+	c.Writer.Flush()
+
+	keepalive := time.NewTicker(httpLogStreamKeepalive)
+	defer keepalive.Stop()
+	ctx := c.Request.Context()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-keepalive.C:
+			_, err := io.WriteString(w, ": keepalive\n\n")
+			return err == nil
+		case summary, ok := <-sub.C:
+			if !ok {
+				return false
+			}
+			if dropped := sub.TakeDropped(); dropped > 0 {
+				c.SSEvent("dropped", gin.H{"dropped": dropped})
+			}
+			c.SSEvent("http_log", summary)
+			return true
+		}
+	})
+}
@@ -0,0 +1,254 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxListPageSize bounds page_size on the v2 list endpoints.
+const maxListPageSize = 500
+
+// listQuery is the paging, sorting and field selection of a v2 list request.
+type listQuery struct {
+	page      int
+	pageSize  int
+	paginated bool // page or page_size was given: respond with {items, page, page_size, total}
+	sort      string
+	desc      bool
+	fields    []string
+}
+
+// listField is a JSON field of a list item type.
+type listField struct {
+	index    []int
+	sortable bool // scalars and timestamps; not slices or objects
+}
+
+// respondList answers a v2 list request with items, applying the query's sort (by a JSON field
+// name, defaultSort when absent), order (asc/desc), page/page_size and fields (comma-separated JSON
+// field names to keep). Ties keep the default order, so results are deterministic. Without page
+// or page_size the response stays a plain array.
+func respondList[T any](c *gin.Context, items []T, defaultSort string) {
+	fields := listFieldsOf(reflect.TypeOf((*T)(nil)).Elem())
+	q, err := parseListQuery(c, fields, defaultSort)
+	if err != nil {
+		errV2(c, CodeInvalidRequest, "Invalid list query", err)
+		return
+	}
+
+	sortListItems(items, fields[defaultSort].index, false)
+	if q.sort != defaultSort || q.desc {
+		sortListItems(items, fields[q.sort].index, q.desc)
+	}
+
+	total := len(items)
+	if q.paginated {
+		start := (q.page - 1) * q.pageSize
+		if start > total {
+			start = total
+		}
+		end := start + q.pageSize
+		if end > total {
+			end = total
+		}
+		items = items[start:end]
+	}
+
+	var data any = items
+	if len(q.fields) > 0 {
+		selected, err := selectListFields(items, fields, q.fields)
+		if err != nil {
+			errV2(c, CodeInternal, "Failed to select fields", err)
+			return
+		}
+		data = selected
+	}
+
+	if !q.paginated {
+		okV2(c, data)
+		return
+	}
+	okV2(c, gin.H{
+		"items":     data,
+		"page":      q.page,
+		"page_size": q.pageSize,
+		"total":     total,
+	})
+}
+
+func parseListQuery(c *gin.Context, fields map[string]listField, defaultSort string) (listQuery, error) {
+	q := listQuery{page: 1, pageSize: 20, sort: defaultSort}
+
+	if v, ok := c.GetQuery("page"); ok {
+		q.paginated = true
+		p, err := strconv.Atoi(v)
+		if err != nil || p < 1 {
+			return q, fmt.Errorf("invalid page %q", v)
+		}
+		q.page = p
+	}
+	if v, ok := c.GetQuery("page_size"); ok {
+		q.paginated = true
+		s, err := strconv.Atoi(v)
+		if err != nil || s < 1 || s > maxListPageSize {
+			return q, fmt.Errorf("invalid page_size %q: must be 1-%d", v, maxListPageSize)
+		}
+		q.pageSize = s
+	}
+
+	if v := strings.TrimSpace(c.Query("sort")); v != "" {
+		// "-name" is shorthand for sort=name&order=desc.
+		if strings.HasPrefix(v, "-") {
+			v = v[1:]
+			q.desc = true
+		}
+		f, ok := fields[v]
+		if !ok || !f.sortable {
+			return q, fmt.Errorf("cannot sort by %q", v)
+		}
+		q.sort = v
+	}
+	switch order := strings.ToLower(strings.TrimSpace(c.Query("order"))); order {
+	case "":
+	case "asc":
+		q.desc = false
+	case "desc":
+		q.desc = true
+	default:
+		return q, fmt.Errorf("invalid order %q: must be asc or desc", order)
+	}
+
+	if v := strings.TrimSpace(c.Query("fields")); v != "" {
+		for _, name := range strings.Split(v, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			if _, ok := fields[name]; !ok {
+				return q, fmt.Errorf("unknown field %q", name)
+			}
+			q.fields = append(q.fields, name)
+		}
+	}
+	return q, nil
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// listFieldsOf maps the JSON field names of struct type t to their fields.
+func listFieldsOf(t reflect.Type) map[string]listField {
+	fields := make(map[string]listField, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		ft := sf.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		sortable := ft == timeType
+		switch ft.Kind() {
+		case reflect.String, reflect.Bool,
+			reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.Float32, reflect.Float64:
+			sortable = true
+		}
+		fields[name] = listField{index: sf.Index, sortable: sortable}
+	}
+	return fields
+}
+
+func sortListItems[T any](items []T, index []int, desc bool) {
+	sort.SliceStable(items, func(i, j int) bool {
+		a := reflect.ValueOf(&items[i]).Elem().FieldByIndex(index)
+		b := reflect.ValueOf(&items[j]).Elem().FieldByIndex(index)
+		if desc {
+			return compareListValues(b, a) < 0
+		}
+		return compareListValues(a, b) < 0
+	})
+}
+
+// compareListValues orders two values of a sortable field; nil pointers sort first and strings
+// compare case-insensitively.
+func compareListValues(a, b reflect.Value) int {
+	if a.Kind() == reflect.Pointer {
+		switch {
+		case a.IsNil() && b.IsNil():
+			return 0
+		case a.IsNil():
+			return -1
+		case b.IsNil():
+			return 1
+		}
+		a, b = a.Elem(), b.Elem()
+	}
+	if a.Type() == timeType {
+		return a.Interface().(time.Time).Compare(b.Interface().(time.Time))
+	}
+	switch a.Kind() {
+	case reflect.String:
+		return strings.Compare(strings.ToLower(a.String()), strings.ToLower(b.String()))
+	case reflect.Bool:
+		switch {
+		case a.Bool() == b.Bool():
+			return 0
+		case b.Bool():
+			return -1
+		default:
+			return 1
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return cmpOrdered(a.Int(), b.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return cmpOrdered(a.Uint(), b.Uint())
+	case reflect.Float32, reflect.Float64:
+		return cmpOrdered(a.Float(), b.Float())
+	}
+	return 0
+}
+
+func cmpOrdered[T int64 | uint64 | float64](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// selectListFields keeps only the named JSON fields of each item. Fields are encoded on their
+// own, so omitempty ones are reported with their zero value rather than left out.
+func selectListFields[T any](items []T, fields map[string]listField, names []string) ([]map[string]json.RawMessage, error) {
+	out := make([]map[string]json.RawMessage, 0, len(items))
+	for i := range items {
+		item := reflect.ValueOf(&items[i]).Elem()
+		selected := make(map[string]json.RawMessage, len(names))
+		for _, name := range names {
+			data, err := json.Marshal(item.FieldByIndex(fields[name].index).Interface())
+			if err != nil {
+				return nil, err
+			}
+			selected[name] = data
+		}
+		out = append(out, selected)
+	}
+	return out, nil
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type listTestItem struct {
+	ID      string     `json:"id"`
+	Port    int        `json:"port"`
+	Running bool       `json:"running"`
+	Note    string     `json:"note,omitempty"`
+	Tags    []string   `json:"tags"`
+	SeenAt  *time.Time `json:"seen_at,omitempty"`
+	Secret  string     `json:"-"`
+}
+
+func TestRespondList(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	seen := time.Unix(1700000000, 0)
+	items := func() []listTestItem {
+		return []listTestItem{
+			{ID: "db", Port: 5432, Running: true, SeenAt: &seen},
+			{ID: "Cache", Port: 6379},
+			{ID: "api", Port: 8080, Running: true, Note: "x"},
+			{ID: "web", Port: 8080},
+		}
+	}
+	r := gin.New()
+	r.GET("/list", func(c *gin.Context) { respondList(c, items(), "id") })
+
+	get := func(query string) ResponseV2 {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/list?"+query, nil))
+		var resp ResponseV2
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%s: decode: %v", query, err)
+		}
+		return resp
+	}
+	ids := func(query string) []string {
+		resp := get(query)
+		if resp.Code != CodeOK {
+			t.Fatalf("%s: code %s", query, resp.Code)
+		}
+		data, _ := json.Marshal(resp.Data)
+		var page struct {
+			Items []map[string]any `json:"items"`
+		}
+		var list []map[string]any
+		if err := json.Unmarshal(data, &list); err != nil {
+			if err := json.Unmarshal(data, &page); err != nil {
+				t.Fatalf("%s: %v", query, err)
+			}
+			list = page.Items
+		}
+		out := make([]string, 0, len(list))
+		for _, item := range list {
+			out = append(out, item["id"].(string))
+		}
+		return out
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"api", "Cache", "db", "web"}},
+		{"order=desc", []string{"web", "db", "Cache", "api"}},
+		{"sort=port", []string{"db", "Cache", "api", "web"}},
+		{"sort=-port", []string{"api", "web", "Cache", "db"}},
+		{"sort=running&order=desc", []string{"api", "db", "Cache", "web"}},
+		{"sort=seen_at", []string{"api", "Cache", "web", "db"}},
+		{"page=2&page_size=3", []string{"web"}},
+		{"page=3&page_size=3", []string{}},
+		{"fields=id,note&sort=port", []string{"db", "Cache", "api", "web"}},
+	}
+	for _, tt := range tests {
+		got := ids(tt.query)
+		if len(got) != len(tt.want) {
+			t.Fatalf("%s: got %v, want %v", tt.query, got, tt.want)
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Fatalf("%s: got %v, want %v", tt.query, got, tt.want)
+			}
+		}
+	}
+
+	data, _ := json.Marshal(get("page=1&page_size=1&fields=id,note").Data)
+	if string(data) != `{"items":[{"id":"api","note":"x"}],"page":1,"page_size":1,"total":4}` {
+		t.Fatalf("paged fields response = %s", data)
+	}
+
+	for _, query := range []string{"sort=tags", "sort=secret", "sort=nope", "order=up", "fields=id,secret", "page=0", "page_size=501"} {
+		if resp := get(query); resp.Code != CodeInvalidRequest {
+			t.Fatalf("%s: code %s, want %s", query, resp.Code, CodeInvalidRequest)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"bastion/config"
+	"bastion/core"
+	"crypto/subtle"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MetricsAuth protects the metrics endpoints with their own bearer token and/or client IP
+// allowlist, so a Prometheus scraper does not need the admin token. A nil MetricsAuth means
+// neither METRICS_TOKEN nor METRICS_ALLOW is set.
+type MetricsAuth struct {
+	token string
+	allow *core.IPAccessControl
+}
+
+// NewMetricsAuth builds the metrics guard from METRICS_TOKEN and METRICS_ALLOW. It returns nil
+// when both are empty and an error for an invalid allowlist entry.
+func NewMetricsAuth() (*MetricsAuth, error) {
+	return newMetricsAuth(config.Settings.MetricsToken, config.Settings.MetricsAllow)
+}
+
+func newMetricsAuth(token, allow string) (*MetricsAuth, error) {
+	token = strings.TrimSpace(token)
+	var entries []string
+	for _, raw := range strings.Split(allow, ",") {
+		if raw = strings.TrimSpace(raw); raw != "" {
+			entries = append(entries, raw)
+		}
+	}
+	acl, err := core.NewIPAccessControl(entries, nil)
+	if err != nil {
+		return nil, fmt.Errorf("METRICS_ALLOW: %w", err)
+	}
+	if token == "" && acl == nil {
+		return nil, nil
+	}
+	return &MetricsAuth{token: token, allow: acl}, nil
+}
+
+// check returns the HTTP status rejecting the request, or 0 when it may read the metrics. Like
+// isLoopbackRequest it matches the allowlist against the socket peer address.
+func (m *MetricsAuth) check(c *gin.Context) (int, string) {
+	if m.allow != nil {
+		host, _, err := net.SplitHostPort(c.Request.RemoteAddr)
+		if err != nil {
+			host = c.Request.RemoteAddr
+		}
+		ip := net.ParseIP(host)
+		if ip == nil || !m.allow.Allows(ip) {
+			return http.StatusForbidden, "client address not allowed"
+		}
+	}
+	if m.token != "" {
+		auth := c.GetHeader("Authorization")
+		token := ""
+		if strings.HasPrefix(auth, "Bearer ") {
+			token = strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+		}
+		if token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(m.token)) != 1 {
+			return http.StatusUnauthorized, "missing or invalid metrics token"
+		}
+	}
+	return 0, ""
+}
+
+// Prometheus guards GET /metrics. Rejections use plain HTTP status codes, which is what scrapers
+// report. Without configuration the endpoint stays open.
+func (m *MetricsAuth) Prometheus() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if m == nil {
+			c.Next()
+			return
+		}
+		if status, detail := m.check(c); status != 0 {
+			emitAuthFailure(c, detail)
+			if status == http.StatusUnauthorized {
+				c.Header("WWW-Authenticate", `Bearer realm="metrics"`)
+			}
+			c.AbortWithStatusJSON(status, gin.H{"error": detail, "request_id": c.GetString(requestIDContextKey)})
+			return
+		}
+		c.Next()
+	}
+}
+
+// API guards GET /api/metrics and /api/v2/metrics. When configured it replaces the admin token
+// check for these routes; otherwise they keep requiring the admin token like the rest of the API.
+func (m *MetricsAuth) API() gin.HandlerFunc {
+	if m == nil {
+		return RequireAdminToken()
+	}
+	return func(c *gin.Context) {
+		if status, detail := m.check(c); status != 0 {
+			emitAuthFailure(c, detail)
+			errV2(c, CodeUnauthorized, "Metrics access denied", detail)
+			c.Abort()
+			return
+		}
+		c.Set(authContextKey, "metrics")
+		c.Next()
+	}
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMetricsAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	if m, err := newMetricsAuth("", " , "); err != nil || m != nil {
+		t.Fatalf("unconfigured: got %v, %v; want nil, nil", m, err)
+	}
+	if _, err := newMetricsAuth("", "10.0.0.0/8,not-an-ip"); err == nil {
+		t.Fatalf("expected error for invalid allowlist entry")
+	}
+
+	tests := []struct {
+		name   string
+		token  string
+		allow  string
+		remote string
+		auth   string
+		want   int
+	}{
+		{"token ok", "s3cret", "", "192.0.2.1:5000", "Bearer s3cret", http.StatusOK},
+		{"token missing", "s3cret", "", "192.0.2.1:5000", "", http.StatusUnauthorized},
+		{"token wrong", "s3cret", "", "192.0.2.1:5000", "Bearer nope", http.StatusUnauthorized},
+		{"allow cidr", "", "10.0.0.0/8", "10.1.2.3:5000", "", http.StatusOK},
+		{"allow single ip", "", "192.0.2.7", "192.0.2.7:5000", "", http.StatusOK},
+		{"not allowed", "", "10.0.0.0/8", "192.0.2.1:5000", "", http.StatusForbidden},
+		{"both ok", "s3cret", "10.0.0.0/8", "10.1.2.3:5000", "Bearer s3cret", http.StatusOK},
+		{"both, bad address", "s3cret", "10.0.0.0/8", "192.0.2.1:5000", "Bearer s3cret", http.StatusForbidden},
+		{"both, no token", "s3cret", "10.0.0.0/8", "10.1.2.3:5000", "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		m, err := newMetricsAuth(tt.token, tt.allow)
+		if err != nil {
+			t.Fatalf("%s: newMetricsAuth: %v", tt.name, err)
+		}
+		r := gin.New()
+		r.GET("/metrics", m.Prometheus(), func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		req.RemoteAddr = tt.remote
+		if tt.auth != "" {
+			req.Header.Set("Authorization", tt.auth)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tt.want {
+			t.Fatalf("%s: status = %d, want %d", tt.name, w.Code, tt.want)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"bastion/core"
+	"bastion/models"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	queryLogProtocols = []string{models.QueryAuditMySQL, models.QueryAuditPostgres}
+	queryLogKinds     = []string{models.QueryLogConnect, models.QueryLogQuery, models.QueryLogPrepare, models.QueryLogUse, models.QueryLogTLS}
+)
+
+const (
+	maxQueryLogPageSize      = 500
+	defaultQueryLogsPageSize = 50
+)
+
+// GetQueryLogsV2 lists the audited database statements of the request workspace, latest first.
+// Query: mapping_id, protocol, kind, user, database, client_ip, q (substring of the statement),
+// since, until (unix seconds or RFC3339), page, page_size.
+func GetQueryLogsV2(c *gin.Context) {
+	page := 1
+	pageSize := defaultQueryLogsPageSize
+	if pageStr := c.Query("page"); pageStr != "" {
+		if p, err := strconv.Atoi(pageStr); err == nil && p > 0 {
+			page = p
+		}
+	}
+	if sizeStr := c.Query("page_size"); sizeStr != "" {
+		if s, err := strconv.Atoi(sizeStr); err == nil && s > 0 {
+			pageSize = s
+		}
+	}
+	if pageSize > maxQueryLogPageSize {
+		pageSize = maxQueryLogPageSize
+	}
+
+	filter := models.QueryLogFilter{
+		Workspace: c.GetString(workspaceContextKey),
+		MappingID: strings.TrimSpace(c.Query("mapping_id")),
+		Protocol:  strings.ToLower(strings.TrimSpace(c.Query("protocol"))),
+		Kind:      strings.ToLower(strings.TrimSpace(c.Query("kind"))),
+		User:      strings.TrimSpace(c.Query("user")),
+		Database:  strings.TrimSpace(c.Query("database")),
+		ClientIP:  strings.TrimSpace(c.Query("client_ip")),
+		Query:     strings.TrimSpace(c.Query("q")),
+	}
+	if filter.Protocol != "" && !containsValue(queryLogProtocols, filter.Protocol) {
+		errV2(c, CodeInvalidRequest, "Invalid protocol", "protocol must be one of: "+strings.Join(queryLogProtocols, ", "))
+		return
+	}
+	if filter.Kind != "" && !containsValue(queryLogKinds, filter.Kind) {
+		errV2(c, CodeInvalidRequest, "Invalid kind", "kind must be one of: "+strings.Join(queryLogKinds, ", "))
+		return
+	}
+
+	var err error
+	if filter.Since, err = parseTimeParam(c.Query("since")); err != nil {
+		errV2(c, CodeInvalidRequest, "Invalid since timestamp", "invalid since")
+		return
+	}
+	if filter.Until, err = parseTimeParam(c.Query("until")); err != nil {
+		errV2(c, CodeInvalidRequest, "Invalid until timestamp", "invalid until")
+		return
+	}
+
+	entries, total := core.QueryLogs.Query(filter, page, pageSize)
+	okV2(c, gin.H{
+		"items":     entries,
+		"page":      page,
+		"page_size": pageSize,
+		"total":     total,
+		"enabled":   core.QueryLogs.Enabled(),
+	})
+}
+
+// ClearQueryLogsV2 removes the audited statements of the request workspace.
+func ClearQueryLogsV2(c *gin.Context) {
+	removed := core.QueryLogs.Clear(c.GetString(workspaceContextKey))
+	okV2(c, gin.H{"removed": removed})
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"bastion/config"
+	"bastion/core"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MutationRateLimit limits POST/PUT/DELETE API requests per client IP to API_RATE_PER_IP per
+// second (burst API_BURST_PER_IP), so a UI bug or script cannot flood the management API. Share
+// one instance between /api and /api/v2 so both count against the same budget.
+func MutationRateLimit() gin.HandlerFunc {
+	limiter := core.NewRateLimiter(config.Settings.APIRatePerIP, config.Settings.APIBurstPerIP)
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+		rateLimit(c, limiter)
+	}
+}
+
+// CodeRateLimit limits the endpoints that generate or consume the 6-digit confirmation codes
+// (shutdown, update, rollback) to API_CODE_RATE_PER_MINUTE requests per client IP, which keeps
+// the codes from being brute-forced within their lifetime.
+func CodeRateLimit() gin.HandlerFunc {
+	perMinute := config.Settings.APICodeRatePerMinute
+	limiter := core.NewRateLimiter(float64(perMinute)/60, perMinute)
+	return func(c *gin.Context) {
+		rateLimit(c, limiter)
+	}
+}
+
+// rateLimit admits the request or rejects it with RATE_LIMITED and a Retry-After header. Like
+// isLoopbackRequest it keys on the socket peer address, which forwarding headers cannot spoof.
+func rateLimit(c *gin.Context, limiter *core.ClientLimiter) {
+	if limiter == nil {
+		c.Next()
+		return
+	}
+	wait, ok := limiter.Allow(c.RemoteIP(), time.Now())
+	if ok {
+		c.Next()
+		return
+	}
+
+	seconds := int(math.Ceil(wait.Seconds()))
+	if config.Settings.LogLevel == "DEBUG" {
+		log.Printf("api: rate limited %s %s from %s (retry in %ds)", c.Request.Method, c.Request.URL.Path, c.RemoteIP(), seconds)
+	}
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	errV2(c, CodeRateLimited, "Too many requests", fmt.Sprintf("rate limit exceeded; retry in %ds", seconds))
+	c.Abort()
+}
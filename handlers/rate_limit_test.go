@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"bastion/config"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMutationRateLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	prevRate, prevBurst := config.Settings.APIRatePerIP, config.Settings.APIBurstPerIP
+	t.Cleanup(func() { config.Settings.APIRatePerIP, config.Settings.APIBurstPerIP = prevRate, prevBurst })
+	config.Settings.APIRatePerIP, config.Settings.APIBurstPerIP = 0.01, 2
+
+	r := gin.New()
+	r.Use(MutationRateLimit())
+	r.Any("/x", func(c *gin.Context) { okV2(c, nil) })
+
+	do := func(method, remote string) (string, string) {
+		req := httptest.NewRequest(method, "/x", nil)
+		req.RemoteAddr = remote
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		var resp ResponseV2
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return resp.Code, w.Header().Get("Retry-After")
+	}
+
+	for i := 0; i < 2; i++ {
+		if code, _ := do(http.MethodPost, "192.0.2.1:1000"); code != CodeOK {
+			t.Fatalf("burst request %d: code %s", i, code)
+		}
+	}
+	if code, retry := do(http.MethodPost, "192.0.2.1:1001"); code != CodeRateLimited || retry != "100" {
+		t.Fatalf("after burst: code %s retry-after %q, want %s and 100", code, retry, CodeRateLimited)
+	}
+	if code, _ := do(http.MethodGet, "192.0.2.1:1002"); code != CodeOK {
+		t.Fatalf("GET must not be limited, got %s", code)
+	}
+	if code, _ := do(http.MethodDelete, "192.0.2.2:1000"); code != CodeOK {
+		t.Fatalf("other client limited: %s", code)
+	}
+}
@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"bastion/core"
+	"io"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Recovery replaces gin.Recovery: a panic in a handler is recorded in the error log with its stack
+// and the request context (see core.RecordPanic) and answered with an INTERNAL_ERROR envelope.
+// Connections broken by the client are left to gin, which does not treat them as panics.
+func Recovery() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(io.Discard, func(c *gin.Context, value any) {
+		core.RecordPanic("api", value, map[string]interface{}{
+			"request_id": c.GetString(requestIDContextKey),
+			"method":     c.Request.Method,
+			"path":       c.Request.URL.Path,
+			"client_ip":  c.ClientIP(),
+		})
+		if !c.Writer.Written() {
+			respondV2(c, CodeInternal, "Internal server error", gin.H{})
+		}
+		c.Abort()
+	})
+}
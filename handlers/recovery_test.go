@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"bastion/core"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRecovery(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(RequestID(), Recovery())
+	r.GET("/boom", func(c *gin.Context) { panic("boom") })
+
+	before := apiPanics()
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/boom", nil))
+
+	var resp ResponseV2
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v (%s)", err, w.Body.String())
+	}
+	if resp.Code != CodeInternal || resp.RequestID == "" {
+		t.Fatalf("response: %+v", resp)
+	}
+	if got := apiPanics(); got != before+1 {
+		t.Fatalf("api panics = %d, want %d", got, before+1)
+	}
+}
+
+func apiPanics() uint64 {
+	for _, p := range core.PanicsRecovered() {
+		if p.Component == "api" {
+			return p.Count
+		}
+	}
+	return 0
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"bastion/core"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader carries the ID of an API call in both directions: a caller may send one to
+// correlate its own logs, and every response echoes the ID used.
+const RequestIDHeader = "X-Request-ID"
+
+const requestIDContextKey = "request_id"
+
+// maxRequestIDLength bounds caller-provided IDs; longer ones are replaced.
+const maxRequestIDLength = 128
+
+// RequestID assigns each request an ID, taken from a valid X-Request-ID header or generated, and
+// returns it in the X-Request-ID response header. The ID is also written to the gin access log,
+// error log entries and error envelopes.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		c.Set(requestIDContextKey, id)
+		c.Header(RequestIDHeader, id)
+		c.Next()
+	}
+}
+
+// validRequestID accepts the characters common ID formats (UUIDs, ULIDs, trace IDs) use, so a
+// caller-provided ID cannot inject anything into log lines.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-', r == '_', r == '.', r == ':', r == '/', r == '+', r == '=':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(b)
+}
+
+// LogFormatter is gin's default access log line with the request ID after the client IP.
+func LogFormatter(param gin.LogFormatterParams) string {
+	if param.Latency > time.Minute {
+		param.Latency = param.Latency.Truncate(time.Second)
+	}
+	id, _ := param.Keys[requestIDContextKey].(string)
+	if id == "" {
+		id = "-"
+	}
+	return fmt.Sprintf("[GIN] %v | %3d | %13v | %15s | %s | %-7s %#v\n%s",
+		param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+		param.StatusCode,
+		param.Latency,
+		param.ClientIP,
+		id,
+		param.Method,
+		param.Path,
+		param.ErrorMessage,
+	)
+}
+
+// logAPIError records server-side API failures in the error log with the request ID, so a failed
+// UI action can be found from the ID shown with the error. Client errors (invalid requests,
+// conflicts, ...) are not recorded.
+func logAPIError(c *gin.Context, code, message string, detail any) {
+	if code != CodeInternal && code != CodeBadGateway {
+		return
+	}
+	text := ""
+	switch d := detail.(type) {
+	case nil:
+	case string:
+		text = d
+	default:
+		if b, err := json.Marshal(d); err == nil {
+			text = string(b)
+		}
+	}
+	core.ErrorLoggerInstance.LogError("ERROR", "API", message, text, map[string]interface{}{
+		"request_id": c.GetString(requestIDContextKey),
+		"method":     c.Request.Method,
+		"path":       c.Request.URL.Path,
+		"code":       code,
+		"client_ip":  c.ClientIP(),
+	})
+}
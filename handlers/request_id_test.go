@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(RequestID())
+	r.GET("/fail", func(c *gin.Context) { errV2(c, CodeInvalidRequest, "Invalid request", "bad") })
+
+	tests := []struct {
+		name     string
+		header   string
+		keepSent bool
+	}{
+		{"generated", "", false},
+		{"caller uuid", "3f2b8c1e-7a4d-4c55-9a0e-0b6f1c2d3e4f", true},
+		{"caller trace id", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true},
+		{"unsafe characters", "abc\" injected=1", false},
+		{"too long", strings.Repeat("a", maxRequestIDLength+1), false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/fail", nil)
+		if tt.header != "" {
+			req.Header.Set(RequestIDHeader, tt.header)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		id := w.Header().Get(RequestIDHeader)
+		if id == "" || !validRequestID(id) {
+			t.Fatalf("%s: response ID %q is not valid", tt.name, id)
+		}
+		if tt.keepSent != (id == tt.header) {
+			t.Fatalf("%s: response ID = %q, sent %q", tt.name, id, tt.header)
+		}
+		var resp ResponseV2
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%s: decode: %v", tt.name, err)
+		}
+		if resp.RequestID != id {
+			t.Fatalf("%s: envelope request_id = %q, header %q", tt.name, resp.RequestID, id)
+		}
+	}
+}
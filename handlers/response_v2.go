@@ -1,15 +1,18 @@
 package handlers
 
 import (
+	"bastion/config"
+	"bastion/i18n"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 )
 
 type ResponseV2 struct {
-	Code    string `json:"code"`
-	Message string `json:"message"`
-	Data    any    `json:"data"`
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	Data      any    `json:"data"`
+	RequestID string `json:"request_id,omitempty"` // set on errors, see RequestID
 }
 
 const (
@@ -17,24 +20,54 @@ const (
 	CodeInvalidRequest = "INVALID_REQUEST"
 	CodeNotFound       = "NOT_FOUND"
 	CodeConflict       = "CONFLICT"
+	CodeUnauthorized   = "UNAUTHORIZED"
 	CodeResourceBusy   = "RESOURCE_BUSY"
+	CodeRateLimited    = "RATE_LIMITED"
 	CodeBadGateway     = "BAD_GATEWAY"
 	CodeInternal       = "INTERNAL_ERROR"
 )
 
 func respondV2(c *gin.Context, code, message string, data any) {
-	c.JSON(http.StatusOK, ResponseV2{Code: code, Message: message, Data: data})
+	c.Set(responseCodeContextKey, code)
+	resp := ResponseV2{Code: code, Message: i18n.T(requestLanguage(c), message), Data: data}
+	if code != CodeOK {
+		resp.RequestID = c.GetString(requestIDContextKey)
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// requestLanguage is the language of a response message: the lang query parameter, else
+// Accept-Language, else the configured language, else English.
+func requestLanguage(c *gin.Context) string {
+	if lang := i18n.Normalize(c.Query("lang")); lang != "" {
+		return lang
+	}
+	if lang := i18n.FromAcceptLanguage(c.GetHeader("Accept-Language")); lang != "" {
+		return lang
+	}
+	if lang := i18n.Normalize(config.Settings.Language); lang != "" {
+		return lang
+	}
+	return i18n.English
 }
 
 func okV2(c *gin.Context, data any) {
 	respondV2(c, CodeOK, "OK", data)
 }
 
+// errV2 responds with an error. A detail that is an error is sent as its text and classified in
+// data.error (see apiError), which may refine code.
 func errV2(c *gin.Context, code, message string, detail any) {
 	// Keep the envelope stable: put free-form details into `data.detail`.
 	payload := gin.H{}
+	err, _ := detail.(error)
+	if err != nil {
+		detail = err.Error()
+	}
 	if detail != nil {
 		payload["detail"] = detail
 	}
+	code, payload["error"] = apiError(c, code, err)
+	logAPIError(c, code, message, detail)
 	respondV2(c, code, message, payload)
 }
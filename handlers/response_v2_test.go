@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"bastion/config"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRespondV2_TranslatesMessage(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/fail", func(c *gin.Context) { errV2(c, CodeNotFound, "Mapping not found", nil) })
+
+	saved := config.Settings.Language
+	defer func() { config.Settings.Language = saved }()
+
+	tests := []struct {
+		name, query, acceptLanguage, configured, want string
+	}{
+		{"default", "", "", "", "Mapping not found"},
+		{"accept-language", "", "en-US;q=0.5, zh-CN", "", "映射不存在"},
+		{"unsupported accept-language", "", "fr-FR", "", "Mapping not found"},
+		{"configured", "", "", "zh", "映射不存在"},
+		{"accept-language over config", "", "en", "zh", "Mapping not found"},
+		{"query over accept-language", "?lang=zh", "en", "", "映射不存在"},
+	}
+	for _, tt := range tests {
+		config.Settings.Language = tt.configured
+		req := httptest.NewRequest(http.MethodGet, "/fail"+tt.query, nil)
+		if tt.acceptLanguage != "" {
+			req.Header.Set("Accept-Language", tt.acceptLanguage)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		var resp ResponseV2
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%s: decode: %v", tt.name, err)
+		}
+		if resp.Message != tt.want || resp.Code != CodeNotFound {
+			t.Fatalf("%s: got %s %q, want %q", tt.name, resp.Code, resp.Message, tt.want)
+		}
+	}
+}
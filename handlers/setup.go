@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"bastion/core"
+	"bastion/models"
+	"bastion/service"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetSetupStatus returns the first-run wizard state
+func GetSetupStatus(c *gin.Context) {
+	status, err := service.GlobalServices.Setup.Status()
+	if err != nil {
+		errV2(c, CodeInternal, "Failed to load setup status", err)
+		return
+	}
+	okV2(c, status)
+}
+
+// GetSetupSSHConfig lists hosts importable from ~/.ssh/config
+func GetSetupSSHConfig(c *gin.Context) {
+	hosts, err := service.GlobalServices.Setup.SSHConfigHosts()
+	if err != nil {
+		errV2(c, CodeInternal, "Failed to read ssh config", err)
+		return
+	}
+	okV2(c, hosts)
+}
+
+// ApplySetupStep applies or skips a wizard step
+func ApplySetupStep(c *gin.Context) {
+	var req models.SetupStepRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		errV2(c, CodeInvalidRequest, "Invalid request", err)
+		return
+	}
+
+	result, err := service.GlobalServices.Setup.ApplyStep(c.Param("step"), req)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrSetupUnknownStep):
+			errV2(c, CodeNotFound, "Unknown setup step", err)
+		case errors.Is(err, service.ErrSetupCompleted), errors.Is(err, service.ErrSetupStepOutOfOrder),
+			errors.Is(err, service.ErrMappingAlreadyExists):
+			errV2(c, CodeConflict, "Setup step rejected", err)
+		default:
+			errV2(c, CodeInvalidRequest, "Failed to apply setup step", err)
+		}
+		return
+	}
+	okV2(c, result)
+}
+
+// agentTokenRoutes are the routes an agent token reaches: the agent's report, and the bastions and
+// mappings of its workspace, which the agent copies.
+var agentTokenRoutes = map[string]bool{
+	"POST /api/v2/agents/report": true,
+	"GET /api/v2/bastions":       true,
+	"GET /api/v2/mappings":       true,
+}
+
+// RequireAdminToken rejects non-loopback API clients that do not present the admin token
+// configured during setup, or an agent token for the routes and workspace of its agent. Loopback
+// clients (the local Web UI and CLI) are always allowed. How the client was let in is stored in
+// the context for the configuration audit trail.
+func RequireAdminToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if isLoopbackRequest(c) {
+			c.Set(authContextKey, "loopback")
+			c.Next()
+			return
+		}
+		if !service.GlobalServices.Setup.AdminTokenRequired() {
+			c.Set(authContextKey, "none")
+			c.Next()
+			return
+		}
+
+		token := strings.TrimSpace(c.GetHeader("X-Admin-Token"))
+		if auth := c.GetHeader("Authorization"); token == "" && strings.HasPrefix(auth, "Bearer ") {
+			token = strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+		}
+		if token != "" && service.GlobalServices.Setup.VerifyAdminToken(token) {
+			c.Set(authContextKey, "admin_token")
+			c.Next()
+			return
+		}
+		if agent := service.GlobalServices.Agents.VerifyToken(token); agent != nil {
+			if !agentTokenRoutes[c.Request.Method+" "+c.FullPath()] || requestWorkspace(c) != agent.Workspace {
+				err := fmt.Errorf("%w: agent %s may only report and read workspace %s", service.ErrAgentTokenScope, agent.Name, agent.Workspace)
+				emitAuthFailure(c, err.Error())
+				errV2(c, CodeUnauthorized, "Admin token required", err)
+				c.Abort()
+				return
+			}
+			c.Set(authContextKey, "agent_token")
+			c.Set(agentContextKey, agent)
+			c.Next()
+			return
+		}
+		emitAuthFailure(c, "missing or invalid admin token")
+		errV2(c, CodeUnauthorized, "Admin token required", "missing or invalid admin token")
+		c.Abort()
+	}
+}
+
+// emitAuthFailure exports an auth_failure security event for a rejected API request.
+func emitAuthFailure(c *gin.Context, reason string) {
+	core.EventExport.Emit(core.SecurityEvent{
+		Type:       core.EventAuthFailure,
+		Protocol:   "API",
+		ClientAddr: c.Request.RemoteAddr,
+		Target:     c.Request.Method + " " + c.Request.URL.Path,
+		Reason:     reason,
+	})
+}
+
+// isLoopbackRequest uses the socket peer address (not X-Forwarded-For) so the check cannot be spoofed.
+func isLoopbackRequest(c *gin.Context) bool {
+	host, _, err := net.SplitHostPort(c.Request.RemoteAddr)
+	if err != nil {
+		host = c.Request.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
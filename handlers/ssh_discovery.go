@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"bastion/models"
+	"bastion/service"
+	"errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DiscoverSSHV2 lists the ssh clients forwarding ports on the server host, each with the bastions
+// and mappings that would replace it in the request workspace.
+func DiscoverSSHV2(c *gin.Context) {
+	discovery, err := scopedServices(c).Mapping.DiscoverSSHProcesses()
+	if err != nil {
+		errV2(c, CodeInternal, "Failed to discover ssh processes", err)
+		return
+	}
+	okV2(c, discovery)
+}
+
+// ImportSSHV2 converts discovered ssh processes (body: {"pids": [...], "dry_run": bool}) into
+// bastions and mappings. The response is an apply result; when a change fails nothing is changed
+// and the code is INVALID_REQUEST with the same result.
+func ImportSSHV2(c *gin.Context) {
+	var req models.SSHImportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errV2(c, CodeInvalidRequest, "Invalid request", err)
+		return
+	}
+	result, err := scopedServices(c).Mapping.ImportSSHProcesses(req)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidApplyRequest) {
+			errV2(c, CodeInvalidRequest, "Invalid import request", err)
+			return
+		}
+		errV2(c, CodeInternal, "Failed to import ssh processes", err)
+		return
+	}
+	if result.Failed > 0 {
+		respondV2(c, CodeInvalidRequest, "Apply failed; nothing was changed", result)
+		return
+	}
+	okV2(c, result)
+}
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"bastion/core"
+	"bastion/models"
+	"bytes"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetSSHPoolChainsV2 lists the pooled SSH chains with their connections and keepalive latency.
+func GetSSHPoolChainsV2(c *gin.Context) {
+	okV2(c, core.Pool.Chains())
+}
+
+// writeSSHPoolLatencyMetrics writes the keepalive round-trip times of the pooled chains that have
+// been probed.
+func writeSSHPoolLatencyMetrics(buf *bytes.Buffer, chains []models.SSHPoolChain) {
+	measured := make([]models.SSHPoolChain, 0, len(chains))
+	for _, ch := range chains {
+		if ch.Latency.Samples > 0 {
+			measured = append(measured, ch)
+		}
+	}
+
+	gauges := []struct {
+		name, help string
+		value      func(models.SSHLatency) float64
+	}{
+		{"bastion_ssh_pool_chain_rtt_seconds", "Latest SSH keepalive round-trip time of a pooled chain.", func(l models.SSHLatency) float64 { return l.CurrentMS }},
+		{"bastion_ssh_pool_chain_rtt_avg_seconds", "Average SSH keepalive round-trip time of a pooled chain over its recent probes.", func(l models.SSHLatency) float64 { return l.AvgMS }},
+		{"bastion_ssh_pool_chain_rtt_max_seconds", "Maximum SSH keepalive round-trip time of a pooled chain over its recent probes.", func(l models.SSHLatency) float64 { return l.MaxMS }},
+	}
+	for _, g := range gauges {
+		fmt.Fprintf(buf, "# HELP %s %s\n", g.name, g.help)
+		fmt.Fprintf(buf, "# TYPE %s gauge\n", g.name)
+		for _, ch := range measured {
+			fmt.Fprintf(buf, "%s{chain=\"%s\"} %g\n", g.name, promLabelEscape(ch.Key), g.value(ch.Latency)/1000)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"bastion/version"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// uiAssetsDir holds the bundler output, whose file names already carry a content hash.
+const uiAssetsDir = "assets/"
+
+// StaticAssets serves the embedded web UI under /web with content-hash ETags, so browsers
+// revalidate pages cheaply and never keep a stale copy after a self-update.
+type StaticAssets struct {
+	fileServer http.Handler
+	hashes     map[string]string // file path -> content hash
+	version    string            // hash over all files, changes whenever any file does
+}
+
+// NewStaticAssets hashes every file of fsys once; the embedded files never change while running.
+func NewStaticAssets(fsys fs.FS) (*StaticAssets, error) {
+	s := &StaticAssets{
+		fileServer: http.StripPrefix("/web", http.FileServer(http.FS(fsys))),
+		hashes:     make(map[string]string),
+	}
+	err := fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := fs.ReadFile(fsys, path)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(data)
+		s.hashes[path] = hex.EncodeToString(sum[:8])
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make([]string, 0, len(s.hashes))
+	for path := range s.hashes {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	h := sha256.New()
+	for _, path := range paths {
+		h.Write([]byte(path + "\x00" + s.hashes[path] + "\n"))
+	}
+	s.version = hex.EncodeToString(h.Sum(nil)[:8])
+	return s, nil
+}
+
+// Version identifies the embedded UI build.
+func (s *StaticAssets) Version() string {
+	return s.version
+}
+
+// Serve handles GET /web/*filepath. Hashed bundles are cached for good; pages and other files
+// must be revalidated, which costs a 304 while their ETag still matches.
+func (s *StaticAssets) Serve(c *gin.Context) {
+	name := strings.TrimPrefix(c.Param("filepath"), "/")
+	if name == "" || strings.HasSuffix(name, "/") {
+		name += "index.html"
+	}
+	if hash, ok := s.hashes[name]; ok {
+		c.Header("ETag", `"`+hash+`"`)
+		if strings.HasPrefix(name, uiAssetsDir) {
+			c.Header("Cache-Control", "public, max-age=31536000, immutable")
+		} else {
+			c.Header("Cache-Control", "no-cache")
+		}
+	}
+	s.fileServer.ServeHTTP(c.Writer, c.Request)
+}
+
+// UIVersionV2 returns the version of the embedded UI, which the frontend compares with the one
+// it was loaded with to offer a reload after the server was updated.
+func (s *StaticAssets) UIVersionV2(c *gin.Context) {
+	okV2(c, gin.H{
+		"ui_version":  s.version,
+		"app_version": version.Version,
+	})
+}
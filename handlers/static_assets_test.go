@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestStaticAssets_CacheHeaders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	fsys := fstest.MapFS{
+		"index.html":        {Data: []byte("<html>v1</html>")},
+		"assets/index-a.js": {Data: []byte("console.log(1)")},
+	}
+	s, err := NewStaticAssets(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := gin.New()
+	r.GET("/web/*filepath", s.Serve)
+
+	get := func(path, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("/web/", "")
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || etag == "" || w.Header().Get("Cache-Control") != "no-cache" {
+		t.Fatalf("index: code %d, etag %q, cache-control %q", w.Code, etag, w.Header().Get("Cache-Control"))
+	}
+	if w := get("/web/", etag); w.Code != http.StatusNotModified {
+		t.Fatalf("expected 304 for a matching ETag, got %d", w.Code)
+	}
+	w = get("/web/assets/index-a.js", "")
+	if w.Code != http.StatusOK || w.Header().Get("Cache-Control") != "public, max-age=31536000, immutable" {
+		t.Fatalf("asset: code %d, cache-control %q", w.Code, w.Header().Get("Cache-Control"))
+	}
+	if w := get("/web/missing.js", ""); w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", w.Code)
+	}
+
+	fsys["index.html"] = &fstest.MapFile{Data: []byte("<html>v2</html>")}
+	updated, err := NewStaticAssets(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Version() == s.Version() {
+		t.Fatal("expected the UI version to change with the content")
+	}
+}
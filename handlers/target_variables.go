@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"bastion/service"
+	"errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+type targetVariableRequest struct {
+	Value string `json:"value"`
+}
+
+// ListTargetVariablesV2 returns the variables mapping target templates read with {{setting "name"}}.
+func ListTargetVariablesV2(c *gin.Context) {
+	vars, err := service.ListTargetVariables()
+	if err != nil {
+		errV2(c, CodeInternal, "Failed to list target variables", err)
+		return
+	}
+	okV2(c, vars)
+}
+
+// SetTargetVariableV2 stores a target variable. Body: {"value": "..."}.
+func SetTargetVariableV2(c *gin.Context) {
+	var req targetVariableRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errV2(c, CodeInvalidRequest, "Invalid request", "invalid request")
+		return
+	}
+	if err := service.SetTargetVariable(c.Param("name"), req.Value); err != nil {
+		respondTargetVariableError(c, err, "Failed to save target variable")
+		return
+	}
+	okV2(c, gin.H{"ok": true})
+}
+
+// DeleteTargetVariableV2 removes a target variable.
+func DeleteTargetVariableV2(c *gin.Context) {
+	if err := service.DeleteTargetVariable(c.Param("name")); err != nil {
+		respondTargetVariableError(c, err, "Failed to delete target variable")
+		return
+	}
+	okV2(c, gin.H{"ok": true})
+}
+
+func respondTargetVariableError(c *gin.Context, err error, msg string) {
+	if errors.Is(err, service.ErrInvalidTargetVariable) {
+		errV2(c, CodeInvalidRequest, "Invalid target variable", err)
+		return
+	}
+	errV2(c, CodeInternal, msg, err)
+}
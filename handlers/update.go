@@ -4,7 +4,6 @@ import (
 	"archive/tar"
 	"archive/zip"
 	"bastion/database"
-	"bastion/version"
 	"compress/gzip"
 	"context"
 	"crypto/rand"
@@ -18,25 +17,25 @@ import (
 	"net/http"
 	"net/url"
 	"os"
-	"os/exec"
 	"path/filepath"
-	"runtime"
 	"strings"
 	"sync"
 	"time"
 
-	"github.com/gin-gonic/gin"
 	"golang.org/x/net/proxy"
 )
 
 const updateProxySettingKey = "update_proxy_url"
 
 type githubRelease struct {
-	TagName string `json:"tag_name"`
-	Name    string `json:"name"`
-	Body    string `json:"body"`
-	HTMLURL string `json:"html_url"`
-	Assets  []struct {
+	TagName     string    `json:"tag_name"`
+	Name        string    `json:"name"`
+	Body        string    `json:"body"`
+	HTMLURL     string    `json:"html_url"`
+	Prerelease  bool      `json:"prerelease"`
+	Draft       bool      `json:"draft"`
+	PublishedAt time.Time `json:"published_at"`
+	Assets      []struct {
 		Name               string `json:"name"`
 		BrowserDownloadURL string `json:"browser_download_url"`
 		Size               int64  `json:"size"`
@@ -61,14 +60,18 @@ type updateCheckResponse struct {
 	ReleaseURL      string `json:"release_url,omitempty"`
 	AssetName       string `json:"asset_name,omitempty"`
 	DownloadURL     string `json:"download_url,omitempty"`
+	Prerelease      bool   `json:"prerelease"`
+	Channel         string `json:"channel"`
+	PinnedTag       string `json:"pinned_tag,omitempty"`
 }
 
 type updateApplyResponse struct {
-	OK            bool   `json:"ok"`
-	TargetVersion string `json:"target_version"`
-	Message       string `json:"message"`
-	HelperPID     int    `json:"helper_pid,omitempty"`
-	HelperLogPath string `json:"helper_log_path,omitempty"`
+	OK            bool                `json:"ok"`
+	TargetVersion string              `json:"target_version"`
+	Message       string              `json:"message"`
+	HelperPID     int                 `json:"helper_pid,omitempty"`
+	HelperLogPath string              `json:"helper_log_path,omitempty"`
+	Verification  *updateVerification `json:"verification,omitempty"`
 }
 
 type updateGenerateCodeResponse struct {
@@ -102,293 +105,6 @@ type updateCodeManager struct {
 
 var updateMgr updateCodeManager
 
-// CheckUpdate checks GitHub latest release and selects a matching asset for the current OS/arch.
-func CheckUpdate(c *gin.Context) {
-	log.Printf("update: check requested (client=%s)", c.ClientIP())
-	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
-	defer cancel()
-
-	release, err := fetchLatestRelease(ctx)
-	if err != nil {
-		log.Printf("update: check fetch latest release failed: %v", err)
-		errV2(c, CodeBadGateway, "Bad gateway", err.Error())
-		return
-	}
-
-	assetName, downloadURL, err := selectReleaseAsset(release, runtime.GOOS, runtime.GOARCH)
-	if err != nil {
-		log.Printf("update: check select asset failed (tag=%s os=%s arch=%s): %v", release.TagName, runtime.GOOS, runtime.GOARCH, err)
-		errV2(c, CodeBadGateway, "Bad gateway", err.Error())
-		return
-	}
-
-	current := strings.TrimSpace(version.Version)
-	latest := strings.TrimSpace(release.TagName)
-
-	updateAvailable := isVersionNewer(latest, current)
-	logProxyEnv("update: check")
-	log.Printf(
-		"update: check result current=%s latest=%s available=%v asset=%s",
-		current, latest, updateAvailable, assetName,
-	)
-	okV2(c, updateCheckResponse{
-		CurrentVersion:  normalizeTag(current),
-		LatestVersion:   normalizeTag(latest),
-		UpdateAvailable: updateAvailable,
-		ReleaseURL:      release.HTMLURL,
-		AssetName:       assetName,
-		DownloadURL:     downloadURL,
-	})
-}
-
-// GenerateUpdateCode creates a short-lived confirmation code for applying an update.
-// A code is issued only when a newer GitHub "Latest Release" is available.
-func GenerateUpdateCode(c *gin.Context) {
-	log.Printf("update: generate code requested (client=%s)", c.ClientIP())
-	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
-	defer cancel()
-
-	release, err := fetchLatestRelease(ctx)
-	if err != nil {
-		log.Printf("update: generate code fetch latest release failed: %v", err)
-		errV2(c, CodeBadGateway, "Bad gateway", err.Error())
-		return
-	}
-
-	current := strings.TrimSpace(version.Version)
-	latest := strings.TrimSpace(release.TagName)
-	if !isVersionNewer(latest, current) {
-		log.Printf("update: generate code skipped (already up to date current=%s latest=%s)", current, latest)
-		errV2(c, CodeInvalidRequest, "Invalid request", "already up to date")
-		return
-	}
-
-	code, err := generateSixDigitCode()
-	if err != nil {
-		log.Printf("update: generate code failed: %v", err)
-		errV2(c, CodeInternal, "Internal error", err.Error())
-		return
-	}
-
-	expiresAt := time.Now().Add(5 * time.Minute)
-	updateMgr.mu.Lock()
-	updateMgr.code = code
-	updateMgr.expiresAt = expiresAt
-	updateMgr.mu.Unlock()
-
-	log.Printf("update: code generated (expires_at=%s)", expiresAt.UTC().Format(time.RFC3339))
-	okV2(c, updateGenerateCodeResponse{
-		Code:      code,
-		ExpiresAt: expiresAt.Unix(),
-	})
-}
-
-// GetUpdateProxy returns the current effective proxy configuration for update HTTP requests.
-// "System" proxy is detected via environment variables (HTTP_PROXY/HTTPS_PROXY/NO_PROXY/ALL_PROXY).
-func GetUpdateProxy(c *gin.Context) {
-	manual, _ := getManualUpdateProxyURL()
-	env := readProxyEnv()
-	effective, source := chooseEffectiveProxy(manual, env)
-
-	okV2(c, updateProxyResponse{
-		ManualProxy:    redactProxy(manual),
-		EnvHTTPProxy:   redactProxy(env.httpProxy),
-		EnvHTTPSProxy:  redactProxy(env.httpsProxy),
-		EnvAllProxy:    redactProxy(env.allProxy),
-		EnvNoProxy:     env.noProxy,
-		EffectiveProxy: redactProxy(effective),
-		Source:         source,
-	})
-}
-
-// SetUpdateProxy persists a manual proxy URL used by update HTTP requests. An empty value clears it.
-func SetUpdateProxy(c *gin.Context) {
-	var req updateProxyRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		errV2(c, CodeInvalidRequest, "Invalid request", "Invalid request")
-		return
-	}
-
-	value := strings.TrimSpace(req.ProxyURL)
-	if value == "" {
-		if err := database.DeleteSetting(updateProxySettingKey); err != nil {
-			errV2(c, CodeInternal, "Internal error", err.Error())
-			return
-		}
-		okV2(c, gin.H{"ok": true})
-		return
-	}
-
-	u, err := url.Parse(value)
-	if err != nil || u.Scheme == "" || u.Host == "" {
-		errV2(c, CodeInvalidRequest, "Invalid request", "invalid proxy url")
-		return
-	}
-	switch strings.ToLower(u.Scheme) {
-	case "http", "https", "socks5", "socks5h":
-	default:
-		errV2(c, CodeInvalidRequest, "Invalid request", "proxy url must start with http(s):// or socks5(h)://")
-		return
-	}
-
-	if err := database.SetSetting(updateProxySettingKey, value); err != nil {
-		errV2(c, CodeInternal, "Internal error", err.Error())
-		return
-	}
-	okV2(c, gin.H{"ok": true})
-}
-
-// ApplyUpdate downloads and installs the latest release asset, then restarts via a helper process.
-func ApplyUpdate(c *gin.Context) {
-	log.Printf("update: apply requested (client=%s)", c.ClientIP())
-	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Minute)
-	defer cancel()
-
-	var req updateApplyRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		log.Printf("update: apply invalid request: %v", err)
-		errV2(c, CodeInvalidRequest, "Invalid request", "Invalid request")
-		return
-	}
-	if shutdownChan == nil {
-		log.Printf("update: apply aborted (shutdown channel not set)")
-		errV2(c, CodeInternal, "Internal error", "shutdown channel is not initialized")
-		return
-	}
-	if err := verifyUpdateCode(req.Code); err != nil {
-		log.Printf("update: apply code verification failed: %v", err)
-		errV2(c, CodeInvalidRequest, "Invalid request", err.Error())
-		return
-	}
-
-	release, err := fetchLatestRelease(ctx)
-	if err != nil {
-		log.Printf("update: apply fetch latest release failed: %v", err)
-		errV2(c, CodeBadGateway, "Bad gateway", err.Error())
-		return
-	}
-
-	assetName, downloadURL, err := selectReleaseAsset(release, runtime.GOOS, runtime.GOARCH)
-	if err != nil {
-		log.Printf("update: apply select asset failed (tag=%s os=%s arch=%s): %v", release.TagName, runtime.GOOS, runtime.GOARCH, err)
-		errV2(c, CodeBadGateway, "Bad gateway", err.Error())
-		return
-	}
-
-	current := strings.TrimSpace(version.Version)
-	latest := strings.TrimSpace(release.TagName)
-	if !isVersionNewer(latest, current) {
-		log.Printf("update: apply aborted (already up to date current=%s latest=%s)", current, latest)
-		errV2(c, CodeInvalidRequest, "Invalid request", "already up to date")
-		return
-	}
-
-	exePath, err := os.Executable()
-	if err != nil {
-		log.Printf("update: apply os.Executable failed: %v", err)
-		errV2(c, CodeInternal, "Internal error", err.Error())
-		return
-	}
-	exePath, _ = filepath.Abs(exePath)
-
-	tmpDir, err := os.MkdirTemp("", "bastion-update-*")
-	if err != nil {
-		log.Printf("update: apply MkdirTemp failed: %v", err)
-		errV2(c, CodeInternal, "Internal error", err.Error())
-		return
-	}
-
-	archivePath := filepath.Join(tmpDir, filepath.Base(assetName))
-	logProxyEnv("update: apply")
-	log.Printf(
-		"update: apply downloading tag=%s asset=%s url=%s exe=%s tmp=%s archive=%s",
-		latest, assetName, downloadURL, exePath, tmpDir, archivePath,
-	)
-	if err := downloadFile(ctx, downloadURL, archivePath); err != nil {
-		log.Printf("update: apply download failed (dst=%s): %v", archivePath, err)
-		_ = os.RemoveAll(tmpDir)
-		errV2(c, CodeBadGateway, "Bad gateway", err.Error())
-		return
-	}
-
-	newBinPath, err := extractBinary(archivePath, tmpDir, runtime.GOOS)
-	if err != nil {
-		log.Printf("update: apply extract failed (archive=%s tmp=%s): %v", archivePath, tmpDir, err)
-		_ = os.RemoveAll(tmpDir)
-		errV2(c, CodeBadGateway, "Bad gateway", err.Error())
-		return
-	}
-	log.Printf("update: apply extracted binary=%s", newBinPath)
-
-	if runtime.GOOS != "windows" {
-		_ = os.Chmod(newBinPath, 0o755)
-	}
-
-	helperLogPath := filepath.Join(filepath.Dir(exePath), "bastion-update-helper.log")
-	if err := ensureWritableFile(helperLogPath); err != nil {
-		helperLogPath = filepath.Join(os.TempDir(), fmt.Sprintf("bastion-update-helper-%d.log", time.Now().UnixNano()))
-		if err2 := ensureWritableFile(helperLogPath); err2 != nil {
-			log.Printf("update: apply create helper log file failed (path=%s): %v", helperLogPath, err2)
-		}
-	}
-
-	helperArgs := []string{
-		"--self-update-helper",
-		"--target", exePath,
-		"--source", newBinPath,
-		"--parent-pid", fmt.Sprintf("%d", os.Getpid()),
-		"--cleanup", tmpDir,
-		"--helper-log", helperLogPath,
-		"--restart",
-		"--",
-	}
-	helperArgs = append(helperArgs, os.Args[1:]...)
-
-	cmd := exec.Command(exePath, helperArgs...)
-	if f, err := os.OpenFile(helperLogPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644); err == nil {
-		// Even if the helper fails to open its own log file, its stderr/stdout will still be captured here.
-		//
-		// Note: do not close `f` here. The parent process exits shortly after starting the helper, and closing
-		// the writer early would stop the stdout/stderr tee goroutines from writing.
-		cmd.Stdout = io.MultiWriter(os.Stdout, f)
-		cmd.Stderr = io.MultiWriter(os.Stderr, f)
-	} else {
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		log.Printf("update: apply open helper log file for stdout/stderr failed (path=%s): %v", helperLogPath, err)
-	}
-	if err := cmd.Start(); err != nil {
-		log.Printf("update: apply start helper failed: %v", err)
-		_ = os.RemoveAll(tmpDir)
-		errV2(c, CodeInternal, "Internal error", err.Error())
-		return
-	}
-	log.Printf("update: apply helper started (pid=%d) helper_log=%s", cmd.Process.Pid, helperLogPath)
-
-	okV2(c, updateApplyResponse{
-		OK:            true,
-		TargetVersion: normalizeTag(latest),
-		Message:       "update started; restarting",
-		HelperPID:     cmd.Process.Pid,
-		HelperLogPath: helperLogPath,
-	})
-
-	if f, ok := c.Writer.(http.Flusher); ok {
-		f.Flush()
-	}
-
-	go func() {
-		// Give the client time to receive and render the response before shutting down.
-		time.Sleep(5 * time.Second)
-		if shutdownChan != nil {
-			log.Printf("update: apply triggering shutdown via channel")
-			shutdownChan <- true
-			return
-		}
-		log.Printf("update: apply cannot shutdown (shutdown channel not set)")
-	}()
-}
-
 func ensureWritableFile(path string) error {
 	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
 		return err
@@ -402,29 +118,48 @@ func ensureWritableFile(path string) error {
 }
 
 func verifyUpdateCode(code string) error {
+	return updateMgr.verify(code)
+}
+
+// issue replaces any outstanding code with a new one valid for ttl.
+func (m *updateCodeManager) issue(ttl time.Duration) (string, time.Time, error) {
+	code, err := generateSixDigitCode()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	expiresAt := time.Now().Add(ttl)
+	m.mu.Lock()
+	m.code = code
+	m.expiresAt = expiresAt
+	m.mu.Unlock()
+	return code, expiresAt, nil
+}
+
+// verify consumes the outstanding code if code matches it and it has not expired.
+func (m *updateCodeManager) verify(code string) error {
 	code = strings.TrimSpace(code)
 
-	updateMgr.mu.RLock()
-	stored := updateMgr.code
-	expiresAt := updateMgr.expiresAt
-	updateMgr.mu.RUnlock()
+	m.mu.RLock()
+	stored := m.code
+	expiresAt := m.expiresAt
+	m.mu.RUnlock()
 
 	if stored == "" {
-		return errors.New("no update code generated; please generate one first")
+		return errors.New("no code generated; please generate one first")
 	}
 	if time.Now().After(expiresAt) {
-		updateMgr.mu.Lock()
-		updateMgr.code = ""
-		updateMgr.mu.Unlock()
-		return errors.New("update code expired; please generate a new one")
+		m.mu.Lock()
+		m.code = ""
+		m.mu.Unlock()
+		return errors.New("code expired; please generate a new one")
 	}
 	if code != stored {
-		return errors.New("invalid update code")
+		return errors.New("invalid code")
 	}
 
-	updateMgr.mu.Lock()
-	updateMgr.code = ""
-	updateMgr.mu.Unlock()
+	m.mu.Lock()
+	m.code = ""
+	m.mu.Unlock()
 	return nil
 }
 
@@ -451,18 +186,11 @@ func fetchLatestRelease(ctx context.Context) (*githubRelease, error) {
 		return cached, nil
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://api.github.com/repos/wildking996/bastion/releases/latest", nil)
+	req, err := newGitHubRequest(ctx, githubReleasesURL+"/latest", etag)
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Set("Accept", "application/vnd.github+json")
-	req.Header.Set("User-Agent", "bastion-self-update")
-	if etag != "" {
-		req.Header.Set("If-None-Match", etag)
-	}
-
-	if token := strings.TrimSpace(os.Getenv("GITHUB_TOKEN")); token != "" {
-		req.Header.Set("Authorization", "Bearer "+token)
+	if req.Header.Get("Authorization") != "" {
 		log.Printf("update: github auth enabled via env GITHUB_TOKEN")
 	}
 
@@ -527,7 +255,7 @@ func selectReleaseAsset(release *githubRelease, goos, goarch string) (assetName,
 		return "", "", errors.New("nil release")
 	}
 	if len(release.Assets) == 0 {
-		return "", "", errors.New("latest release has no assets")
+		return "", "", errors.New("release has no assets")
 	}
 
 	osToken := strings.ToLower(goos)
@@ -723,18 +451,44 @@ func extractTarGzBinary(path, tmpDir, goos string) (string, error) {
 }
 
 func isVersionNewer(latest, current string) bool {
-	lt := parseVersion(latest)
-	ct := parseVersion(current)
+	return compareVersions(latest, current) > 0
+}
 
+// compareVersions orders two version tags by major.minor.patch, then a release above its
+// pre-releases (v1.2.0-rc1 < v1.2.0), then pre-release suffixes lexically. Build metadata is ignored.
+func compareVersions(a, b string) int {
+	av := parseVersion(a)
+	bv := parseVersion(b)
 	for i := 0; i < 3; i++ {
-		if lt[i] > ct[i] {
-			return true
+		if av[i] > bv[i] {
+			return 1
 		}
-		if lt[i] < ct[i] {
-			return false
+		if av[i] < bv[i] {
+			return -1
 		}
 	}
-	return false
+
+	ap, bp := prereleaseSuffix(a), prereleaseSuffix(b)
+	switch {
+	case ap == bp:
+		return 0
+	case ap == "":
+		return 1
+	case bp == "":
+		return -1
+	}
+	return strings.Compare(ap, bp)
+}
+
+func prereleaseSuffix(v string) string {
+	v = strings.TrimPrefix(strings.TrimSpace(v), "v")
+	if i := strings.IndexByte(v, '+'); i >= 0 {
+		v = v[:i]
+	}
+	if i := strings.IndexByte(v, '-'); i >= 0 {
+		return v[i+1:]
+	}
+	return ""
 }
 
 func parseVersion(v string) [3]int {
@@ -905,7 +659,12 @@ func newUpdateHTTPClient(timeout time.Duration) *http.Client {
 	manual, _ := getManualUpdateProxyURL()
 	env := readProxyEnv()
 	effective, _ := chooseEffectiveProxy(manual, env)
+	return newUpdateHTTPClientVia(effective, timeout)
+}
 
+// newUpdateHTTPClientVia returns an update HTTP client using the proxy URL effective (http(s) or
+// socks5(h)); an empty or unsupported one falls back to the proxy environment variables.
+func newUpdateHTTPClientVia(effective string, timeout time.Duration) *http.Client {
 	base, okType := http.DefaultTransport.(*http.Transport)
 	var tr *http.Transport
 	if okType {
@@ -0,0 +1,383 @@
+package handlers
+
+import (
+	"bastion/database"
+	"bastion/version"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	updateChannelSettingKey   = "update_channel"
+	updatePinnedTagSettingKey = "update_pinned_tag"
+
+	// updateChannelStable follows GitHub's "Latest Release"; updateChannelBeta also offers pre-releases.
+	updateChannelStable = "stable"
+	updateChannelBeta   = "beta"
+
+	githubReleasesURL = "https://api.github.com/repos/wildking996/bastion/releases"
+	// recentReleasesPerPage is how many releases are listed and searched for pinned tags.
+	recentReleasesPerPage = 30
+)
+
+var updateChannels = []string{updateChannelStable, updateChannelBeta}
+
+var errReleaseNotFound = errors.New("release not found")
+
+type releaseListCache struct {
+	mu       sync.Mutex
+	releases []githubRelease
+	etag     string
+	fetched  time.Time
+}
+
+var recentReleasesCache releaseListCache
+
+type updateChannelRequest struct {
+	Channel string `json:"channel"`
+}
+
+type updatePinRequest struct {
+	Tag string `json:"tag"`
+}
+
+type updateChannelResponse struct {
+	Channel   string `json:"channel"`
+	PinnedTag string `json:"pinned_tag,omitempty"`
+}
+
+type updateReleaseInfo struct {
+	Tag         string     `json:"tag"`
+	Name        string     `json:"name,omitempty"`
+	Prerelease  bool       `json:"prerelease"`
+	PublishedAt *time.Time `json:"published_at,omitempty"`
+	ReleaseURL  string     `json:"release_url,omitempty"`
+	Changelog   string     `json:"changelog,omitempty"`
+	AssetName   string     `json:"asset_name,omitempty"` // empty when there is no build for this OS/arch
+	Current     bool       `json:"current"`
+	Pinned      bool       `json:"pinned"`
+	Newer       bool       `json:"newer"`
+}
+
+type updateReleasesResponse struct {
+	CurrentVersion string              `json:"current_version"`
+	Channel        string              `json:"channel"`
+	PinnedTag      string              `json:"pinned_tag,omitempty"`
+	Releases       []updateReleaseInfo `json:"releases"`
+}
+
+// GetUpdateChannel returns the update channel and the pinned release tag, if any.
+func GetUpdateChannel(c *gin.Context) {
+	okV2(c, updateChannelResponse{Channel: getUpdateChannel(), PinnedTag: getPinnedUpdateTag()})
+}
+
+// SetUpdateChannel selects the update channel: stable (GitHub "Latest Release") or beta (newest
+// release including pre-releases). A pinned tag still takes precedence.
+func SetUpdateChannel(c *gin.Context) {
+	var req updateChannelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errV2(c, CodeInvalidRequest, "Invalid request", "invalid request")
+		return
+	}
+	channel := strings.ToLower(strings.TrimSpace(req.Channel))
+	if !containsValue(updateChannels, channel) {
+		errV2(c, CodeInvalidRequest, "Invalid channel", "channel must be one of: "+strings.Join(updateChannels, ", "))
+		return
+	}
+	if err := database.SetSetting(updateChannelSettingKey, channel); err != nil {
+		errV2(c, CodeInternal, "Failed to save channel", err)
+		return
+	}
+	log.Printf("update: channel set to %s (client=%s)", channel, c.ClientIP())
+	okV2(c, updateChannelResponse{Channel: channel, PinnedTag: getPinnedUpdateTag()})
+}
+
+// PinUpdateRelease pins updates to a release tag (which may be older than the running version);
+// an empty tag clears the pin. The release must exist and have a build for this OS/arch.
+func PinUpdateRelease(c *gin.Context) {
+	var req updatePinRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errV2(c, CodeInvalidRequest, "Invalid request", "invalid request")
+		return
+	}
+
+	tag := strings.TrimSpace(req.Tag)
+	if tag == "" {
+		if err := database.DeleteSetting(updatePinnedTagSettingKey); err != nil {
+			errV2(c, CodeInternal, "Failed to clear pin", err)
+			return
+		}
+		log.Printf("update: pin cleared (client=%s)", c.ClientIP())
+		okV2(c, updateChannelResponse{Channel: getUpdateChannel()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+	release, err := fetchReleaseByTag(ctx, tag)
+	if err != nil {
+		if errors.Is(err, errReleaseNotFound) {
+			errV2(c, CodeNotFound, "Release not found", err)
+			return
+		}
+		errV2(c, CodeBadGateway, "Failed to fetch release", err)
+		return
+	}
+	if _, _, err := selectReleaseAsset(release, runtime.GOOS, runtime.GOARCH); err != nil {
+		errV2(c, CodeInvalidRequest, "Release has no asset for this platform", err)
+		return
+	}
+
+	if err := database.SetSetting(updatePinnedTagSettingKey, release.TagName); err != nil {
+		errV2(c, CodeInternal, "Failed to save pin", err)
+		return
+	}
+	log.Printf("update: pinned to %s (client=%s)", release.TagName, c.ClientIP())
+	okV2(c, updateChannelResponse{Channel: getUpdateChannel(), PinnedTag: release.TagName})
+}
+
+// ListUpdateReleasesV2 lists recent releases (newest first) with their changelogs, so users can
+// pick one to pin. Query: limit (default 10, at most 30).
+func ListUpdateReleasesV2(c *gin.Context) {
+	limit := 10
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
+			limit = l
+		}
+	}
+	if limit > recentReleasesPerPage {
+		limit = recentReleasesPerPage
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+	releases, err := fetchRecentReleases(ctx)
+	if err != nil {
+		log.Printf("update: list releases failed: %v", err)
+		errV2(c, CodeBadGateway, "Failed to fetch releases", err)
+		return
+	}
+
+	current := strings.TrimSpace(version.Version)
+	pinned := getPinnedUpdateTag()
+	items := make([]updateReleaseInfo, 0, limit)
+	for i := range releases {
+		if len(items) == limit {
+			break
+		}
+		r := &releases[i]
+		assetName, _, _ := selectReleaseAsset(r, runtime.GOOS, runtime.GOARCH)
+		info := updateReleaseInfo{
+			Tag:        r.TagName,
+			Name:       r.Name,
+			Prerelease: r.Prerelease,
+			ReleaseURL: r.HTMLURL,
+			Changelog:  r.Body,
+			AssetName:  assetName,
+			Current:    compareVersions(r.TagName, current) == 0,
+			Pinned:     pinned != "" && r.TagName == pinned,
+			Newer:      isVersionNewer(r.TagName, current),
+		}
+		if !r.PublishedAt.IsZero() {
+			published := r.PublishedAt
+			info.PublishedAt = &published
+		}
+		items = append(items, info)
+	}
+
+	okV2(c, updateReleasesResponse{
+		CurrentVersion: normalizeTag(current),
+		Channel:        getUpdateChannel(),
+		PinnedTag:      pinned,
+		Releases:       items,
+	})
+}
+
+func getUpdateChannel() string {
+	v, ok, err := database.GetSetting(updateChannelSettingKey)
+	v = strings.ToLower(strings.TrimSpace(v))
+	if err != nil || !ok || !containsValue(updateChannels, v) {
+		return updateChannelStable
+	}
+	return v
+}
+
+func getPinnedUpdateTag() string {
+	v, ok, err := database.GetSetting(updatePinnedTagSettingKey)
+	if err != nil || !ok {
+		return ""
+	}
+	return strings.TrimSpace(v)
+}
+
+// resolveUpdateTarget returns the release an update installs: the pinned release when a tag is
+// pinned, otherwise the newest release of the update channel.
+func resolveUpdateTarget(ctx context.Context) (release *githubRelease, pinned bool, err error) {
+	if tag := getPinnedUpdateTag(); tag != "" {
+		release, err = fetchReleaseByTag(ctx, tag)
+		return release, true, err
+	}
+	if getUpdateChannel() != updateChannelBeta {
+		release, err = fetchLatestRelease(ctx)
+		return release, false, err
+	}
+
+	releases, err := fetchRecentReleases(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	for i := range releases {
+		if release == nil || compareVersions(releases[i].TagName, release.TagName) > 0 {
+			release = &releases[i]
+		}
+	}
+	if release == nil {
+		return nil, false, errors.New("github has no releases")
+	}
+	return release, false, nil
+}
+
+// updateWanted reports whether target should replace the running version: a pinned release
+// whenever it differs (this is how downgrades are done), otherwise only a newer one.
+func updateWanted(target, current string, pinned bool) bool {
+	if pinned {
+		return compareVersions(target, current) != 0
+	}
+	return isVersionNewer(target, current)
+}
+
+// fetchRecentReleases lists the newest published releases (drafts excluded), newest first. Like
+// fetchLatestRelease it caches briefly and revalidates with the ETag.
+func fetchRecentReleases(ctx context.Context) ([]githubRelease, error) {
+	const ttl = 30 * time.Second
+	recentReleasesCache.mu.Lock()
+	cached := recentReleasesCache.releases
+	etag := recentReleasesCache.etag
+	fetched := recentReleasesCache.fetched
+	recentReleasesCache.mu.Unlock()
+
+	if cached != nil && time.Since(fetched) < ttl {
+		return cached, nil
+	}
+
+	req, err := newGitHubRequest(ctx, fmt.Sprintf("%s?per_page=%d", githubReleasesURL, recentReleasesPerPage), etag)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := newUpdateHTTPClient(10 * time.Second).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		recentReleasesCache.mu.Lock()
+		recentReleasesCache.fetched = time.Now()
+		recentReleasesCache.mu.Unlock()
+		return cached, nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 2048))
+		err := fmt.Errorf("github api error: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+		if resp.StatusCode == http.StatusForbidden && cached != nil {
+			log.Printf("update: github api forbidden, using cached releases due to rate limiting: %v", err)
+			return cached, nil
+		}
+		return nil, err
+	}
+
+	var all []githubRelease
+	if err := json.NewDecoder(resp.Body).Decode(&all); err != nil {
+		return nil, err
+	}
+	releases := make([]githubRelease, 0, len(all))
+	for _, r := range all {
+		if !r.Draft && r.TagName != "" {
+			releases = append(releases, r)
+		}
+	}
+	sort.SliceStable(releases, func(i, j int) bool {
+		return releases[i].PublishedAt.After(releases[j].PublishedAt)
+	})
+
+	if newEtag := strings.TrimSpace(resp.Header.Get("ETag")); newEtag != "" {
+		etag = newEtag
+	}
+	recentReleasesCache.mu.Lock()
+	recentReleasesCache.releases = releases
+	recentReleasesCache.etag = etag
+	recentReleasesCache.fetched = time.Now()
+	recentReleasesCache.mu.Unlock()
+	return releases, nil
+}
+
+// fetchReleaseByTag looks tag up among the recent releases and falls back to GitHub's by-tag API
+// for older ones.
+func fetchReleaseByTag(ctx context.Context, tag string) (*githubRelease, error) {
+	if releases, err := fetchRecentReleases(ctx); err == nil {
+		for i := range releases {
+			if strings.EqualFold(releases[i].TagName, tag) {
+				return &releases[i], nil
+			}
+		}
+	}
+
+	req, err := newGitHubRequest(ctx, githubReleasesURL+"/tags/"+url.PathEscape(tag), "")
+	if err != nil {
+		return nil, err
+	}
+	resp, err := newUpdateHTTPClient(10 * time.Second).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: %s", errReleaseNotFound, tag)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 2048))
+		return nil, fmt.Errorf("github api error: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	var release githubRelease
+	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
+		return nil, err
+	}
+	if release.TagName == "" || release.Draft {
+		return nil, fmt.Errorf("%w: %s", errReleaseNotFound, tag)
+	}
+	return &release, nil
+}
+
+// newGitHubRequest builds a GitHub API GET request, authenticated with GITHUB_TOKEN when set and
+// conditional on etag when non-empty.
+func newGitHubRequest(ctx context.Context, rawURL, etag string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("User-Agent", "bastion-self-update")
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	if token := strings.TrimSpace(os.Getenv("GITHUB_TOKEN")); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req, nil
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// updateProxyTestURL is probed by the proxy test; it is the host every update request goes to first.
+// It is a variable so tests can probe a local server.
+var updateProxyTestURL = "https://api.github.com/"
+
+type updateProxyTestRequest struct {
+	// ProxyURL, when set, is tested instead of the effective proxy without being saved.
+	ProxyURL string `json:"proxy_url"`
+}
+
+type updateProxyTestResponse struct {
+	URL        string `json:"url"`
+	ProxyUsed  string `json:"proxy_used"` // redacted proxy URL, or "direct"
+	Source     string `json:"source"`     // manual, env, none or request
+	StatusCode int    `json:"status_code,omitempty"`
+	Status     string `json:"status,omitempty"`
+	LatencyMS  int64  `json:"latency_ms"`
+	Error      string `json:"error,omitempty"`
+}
+
+// TestUpdateProxyV2 sends a HEAD request to api.github.com through the effective update proxy (or
+// the proxy_url of the body) and reports the proxy actually used, the response status and the
+// latency, so proxy settings can be validated before downloading an update.
+func TestUpdateProxyV2(c *gin.Context) {
+	var req updateProxyTestRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		errV2(c, CodeInvalidRequest, "Invalid request", err)
+		return
+	}
+
+	effective, source := "", ""
+	if candidate := strings.TrimSpace(req.ProxyURL); candidate != "" {
+		if err := validateUpdateProxyURL(candidate); err != nil {
+			errV2(c, CodeInvalidRequest, "Invalid proxy url", err)
+			return
+		}
+		effective, source = candidate, "request"
+	} else {
+		manual, _ := getManualUpdateProxyURL()
+		effective, source = chooseEffectiveProxy(manual, readProxyEnv())
+	}
+
+	result := probeUpdateProxy(c.Request.Context(), effective)
+	result.Source = source
+	log.Printf(
+		"update: proxy test proxy=%s source=%s status=%d latency_ms=%d err=%s (client=%s)",
+		result.ProxyUsed, source, result.StatusCode, result.LatencyMS, result.Error, c.ClientIP(),
+	)
+	if result.Error != "" {
+		errV2(c, CodeBadGateway, "Proxy test failed", result)
+		return
+	}
+	okV2(c, result)
+}
+
+// probeUpdateProxy sends a HEAD request to updateProxyTestURL with the update HTTP client for
+// effective and records which proxy the transport actually chose (NO_PROXY may bypass it).
+func probeUpdateProxy(ctx context.Context, effective string) updateProxyTestResponse {
+	result := updateProxyTestResponse{URL: updateProxyTestURL, ProxyUsed: "direct"}
+
+	client := newUpdateHTTPClientVia(effective, 15*time.Second)
+	if tr, ok := client.Transport.(*http.Transport); ok {
+		if tr.Proxy != nil {
+			choose := tr.Proxy
+			tr.Proxy = func(r *http.Request) (*url.URL, error) {
+				u, err := choose(r)
+				if u != nil {
+					result.ProxyUsed = redactProxy(u.String())
+				}
+				return u, err
+			}
+		} else if tr.DialContext != nil && effective != "" {
+			// socks5(h) proxies dial through the transport instead of Proxy.
+			result.ProxyUsed = redactProxy(effective)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, updateProxyTestURL, nil)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	req.Header.Set("User-Agent", "bastion-self-update")
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	result.LatencyMS = time.Since(start).Milliseconds()
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	_ = resp.Body.Close()
+	result.StatusCode = resp.StatusCode
+	result.Status = resp.Status
+	return result
+}
+
+func validateUpdateProxyURL(value string) error {
+	u, err := url.Parse(value)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return errors.New("invalid proxy url")
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https", "socks5", "socks5h":
+		return nil
+	default:
+		return errors.New("proxy url must start with http(s):// or socks5(h)://")
+	}
+}
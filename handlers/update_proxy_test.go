@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// useUpdateProxyTestTarget points the proxy test at a local TLS server trusted by the update
+// client until the test ends, and returns the server's host:port.
+func useUpdateProxyTestTarget(t *testing.T) string {
+	t.Helper()
+	target := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(target.Close)
+
+	base := http.DefaultTransport.(*http.Transport)
+	prevURL, prevTLS := updateProxyTestURL, base.TLSClientConfig
+	t.Cleanup(func() { updateProxyTestURL, base.TLSClientConfig = prevURL, prevTLS })
+	updateProxyTestURL = target.URL + "/"
+	base.TLSClientConfig = target.Client().Transport.(*http.Transport).TLSClientConfig
+	return target.Listener.Addr().String()
+}
+
+// connectProxy is an HTTP proxy that only tunnels CONNECT requests and remembers their targets.
+type connectProxy struct {
+	mu      sync.Mutex
+	targets []string
+}
+
+func (p *connectProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodConnect {
+		http.Error(w, "CONNECT only", http.StatusMethodNotAllowed)
+		return
+	}
+	p.mu.Lock()
+	p.targets = append(p.targets, r.Host)
+	p.mu.Unlock()
+
+	upstream, err := net.Dial("tcp", r.Host)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer upstream.Close()
+	conn, _, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	_, _ = io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+	go func() { _, _ = io.Copy(upstream, conn) }()
+	_, _ = io.Copy(conn, upstream)
+}
+
+func updateProxyTestRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/api/v2/update/proxy/test", TestUpdateProxyV2)
+	return r
+}
+
+func TestTestUpdateProxyV2_Reachable(t *testing.T) {
+	targetAddr := useUpdateProxyTestTarget(t)
+	proxy := &connectProxy{}
+	proxyServer := httptest.NewServer(proxy)
+	defer proxyServer.Close()
+
+	resp := serveV2(t, updateProxyTestRouter(), http.MethodPost, "/api/v2/update/proxy/test", "127.0.0.1:5000", nil,
+		`{"proxy_url":"`+proxyServer.URL+`"}`)
+	if resp.Code != CodeOK {
+		t.Fatalf("proxy test = %+v", resp)
+	}
+	var result updateProxyTestResponse
+	decodeTestData(t, resp, &result)
+	if result.ProxyUsed != proxyServer.URL || result.Source != "request" || result.StatusCode != http.StatusOK || result.Error != "" {
+		t.Fatalf("result = %+v", result)
+	}
+	proxy.mu.Lock()
+	defer proxy.mu.Unlock()
+	if len(proxy.targets) != 1 || proxy.targets[0] != targetAddr {
+		t.Fatalf("CONNECT targets = %v, want [%s]", proxy.targets, targetAddr)
+	}
+}
+
+func TestTestUpdateProxyV2_Refused(t *testing.T) {
+	useUpdateProxyTestTarget(t)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	proxyURL := "http://" + ln.Addr().String()
+	ln.Close()
+
+	resp := serveV2(t, updateProxyTestRouter(), http.MethodPost, "/api/v2/update/proxy/test", "127.0.0.1:5000", nil,
+		`{"proxy_url":"`+proxyURL+`"}`)
+	if resp.Code != CodeBadGateway || resp.Message != "Proxy test failed" {
+		t.Fatalf("proxy test = %+v", resp)
+	}
+	var data struct {
+		Detail updateProxyTestResponse `json:"detail"`
+		Error  struct {
+			Code string `json:"code"`
+		} `json:"error"`
+	}
+	decodeTestData(t, resp, &data)
+	if data.Error.Code != CodeBadGateway {
+		t.Fatalf("error code = %q, want %q", data.Error.Code, CodeBadGateway)
+	}
+	if data.Detail.ProxyUsed != proxyURL || data.Detail.StatusCode != 0 || !strings.Contains(data.Detail.Error, "connection refused") {
+		t.Fatalf("detail = %+v", data.Detail)
+	}
+}
+
+func TestTestUpdateProxyV2_InvalidURL(t *testing.T) {
+	useUpdateProxyTestTarget(t)
+	r := updateProxyTestRouter()
+	tests := []struct {
+		proxyURL string
+		detail   string
+	}{
+		{"proxy.internal:3128", "invalid proxy url"},
+		{"ftp://proxy.internal:21", "proxy url must start with http(s):// or socks5(h)://"},
+	}
+	for _, tt := range tests {
+		resp := serveV2(t, r, http.MethodPost, "/api/v2/update/proxy/test", "127.0.0.1:5000", nil, `{"proxy_url":"`+tt.proxyURL+`"}`)
+		if resp.Code != CodeInvalidRequest || resp.Message != "Invalid proxy url" {
+			t.Fatalf("%s: proxy test = %+v", tt.proxyURL, resp)
+		}
+		var data struct {
+			Detail string `json:"detail"`
+			Error  struct {
+				Code string `json:"code"`
+			} `json:"error"`
+		}
+		decodeTestData(t, resp, &data)
+		if data.Detail != tt.detail || data.Error.Code != CodeInvalidRequest {
+			t.Fatalf("%s: data = %+v", tt.proxyURL, data)
+		}
+	}
+}
@@ -0,0 +1,322 @@
+package handlers
+
+import (
+	"bastion/core"
+	"bastion/database"
+	"bastion/version"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// previousBinaryInfo describes the executable the self-update helper kept when it last replaced
+// the running one (by an update or a rollback). It is stored next to it as <exe>.prev.json.
+type previousBinaryInfo struct {
+	Version       string    `json:"version"`
+	SchemaVersion int       `json:"schema_version"` // newest database migration the executable knows
+	SavedAt       time.Time `json:"saved_at"`
+}
+
+type updateRollbackStatusResponse struct {
+	CurrentVersion  string     `json:"current_version"`
+	Available       bool       `json:"available"`
+	PreviousVersion string     `json:"previous_version,omitempty"`
+	SavedAt         *time.Time `json:"saved_at,omitempty"`
+	Reason          string     `json:"reason,omitempty"` // why a kept executable cannot be rolled back to
+}
+
+var rollbackMgr updateCodeManager
+
+// previousExecutablePath is where the helper keeps the replaced executable.
+func previousExecutablePath(exePath string) string {
+	return filepath.Join(filepath.Dir(exePath), filepath.Base(exePath)+".prev")
+}
+
+// SavePreviousBinaryInfo records the version and schema version of the executable kept at path.
+// The self-update helper calls it after a successful replacement.
+func SavePreviousBinaryInfo(path, previousVersion string, schemaVersion int) error {
+	data, err := json.MarshalIndent(previousBinaryInfo{
+		Version:       previousVersion,
+		SchemaVersion: schemaVersion,
+		SavedAt:       time.Now().UTC(),
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path+".json", data, 0o644)
+}
+
+// loadPreviousBinary returns the kept executable of exePath, or nil when there is none.
+func loadPreviousBinary(exePath string) (path string, info *previousBinaryInfo, err error) {
+	path = previousExecutablePath(exePath)
+	fi, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return path, nil, nil
+	}
+	if err != nil {
+		return path, nil, err
+	}
+
+	info = &previousBinaryInfo{Version: "unknown", SavedAt: fi.ModTime()}
+	if data, err := os.ReadFile(path + ".json"); err == nil {
+		if err := json.Unmarshal(data, info); err != nil {
+			log.Printf("update: invalid %s.json: %v", path, err)
+		}
+	}
+	return path, info, nil
+}
+
+// rollbackBlocker explains why info cannot be rolled back to: an older executable refuses to
+// start on a database migrated past the versions it knows.
+func rollbackBlocker(info *previousBinaryInfo) string {
+	if info.SchemaVersion == 0 || database.DB == nil {
+		return ""
+	}
+	status, err := database.GetMigrationStatus(database.DB)
+	if err != nil {
+		return ""
+	}
+	if status.Current > info.SchemaVersion {
+		return fmt.Sprintf(
+			"database schema is at version %d but %s supports up to %d; restore a database backup taken before the update first",
+			status.Current, info.Version, info.SchemaVersion,
+		)
+	}
+	return ""
+}
+
+// GetUpdateRollbackV2 reports whether a previous executable is kept for rollback.
+func GetUpdateRollbackV2(c *gin.Context) {
+	resp := updateRollbackStatusResponse{CurrentVersion: normalizeTag(strings.TrimSpace(version.Version))}
+	exePath, err := currentExecutable()
+	if err != nil {
+		errV2(c, CodeInternal, "Failed to locate executable", err)
+		return
+	}
+	_, info, err := loadPreviousBinary(exePath)
+	if err != nil {
+		errV2(c, CodeInternal, "Failed to read previous executable", err)
+		return
+	}
+	if info != nil {
+		savedAt := info.SavedAt
+		resp.PreviousVersion = normalizeTag(info.Version)
+		resp.SavedAt = &savedAt
+		resp.Reason = rollbackBlocker(info)
+		resp.Available = resp.Reason == ""
+	}
+	okV2(c, resp)
+}
+
+// GenerateRollbackCodeV2 creates a short-lived confirmation code for rolling back; one is issued
+// only when a previous executable is kept.
+func GenerateRollbackCodeV2(c *gin.Context) {
+	log.Printf("update: generate rollback code requested (client=%s)", c.ClientIP())
+	exePath, err := currentExecutable()
+	if err != nil {
+		errV2(c, CodeInternal, "Failed to locate executable", err)
+		return
+	}
+	_, info, err := loadPreviousBinary(exePath)
+	if err != nil {
+		errV2(c, CodeInternal, "Failed to read previous executable", err)
+		return
+	}
+	if info == nil {
+		errV2(c, CodeNotFound, "No previous version", "no previous executable is kept; rollback is possible after a self-update")
+		return
+	}
+	if reason := rollbackBlocker(info); reason != "" {
+		errV2(c, CodeConflict, "Rollback not possible", reason)
+		return
+	}
+
+	code, expiresAt, err := rollbackMgr.issue(5 * time.Minute)
+	if err != nil {
+		log.Printf("update: generate rollback code failed: %v", err)
+		errV2(c, CodeInternal, "Failed to generate code", err)
+		return
+	}
+	log.Printf("update: rollback code generated (expires_at=%s)", expiresAt.UTC().Format(time.RFC3339))
+	okV2(c, updateGenerateCodeResponse{Code: code, ExpiresAt: expiresAt.Unix()})
+}
+
+// RollbackUpdateV2 swaps the previous executable back in through the self-update helper and
+// restarts. The replaced executable is kept in turn, so a rollback can itself be rolled back.
+func RollbackUpdateV2(c *gin.Context) {
+	log.Printf("update: rollback requested (client=%s)", c.ClientIP())
+
+	var req updateApplyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errV2(c, CodeInvalidRequest, "Invalid request", "Invalid request")
+		return
+	}
+	if shutdownChan == nil {
+		log.Printf("update: rollback aborted (shutdown channel not set)")
+		errV2(c, CodeInternal, "Shutdown channel is not initialized", "shutdown channel is not initialized")
+		return
+	}
+	if err := rollbackMgr.verify(req.Code); err != nil {
+		log.Printf("update: rollback code verification failed: %v", err)
+		errV2(c, CodeInvalidRequest, "Invalid rollback code", err)
+		return
+	}
+
+	exePath, err := currentExecutable()
+	if err != nil {
+		errV2(c, CodeInternal, "Failed to locate executable", err)
+		return
+	}
+	prevPath, info, err := loadPreviousBinary(exePath)
+	if err != nil {
+		errV2(c, CodeInternal, "Failed to read previous executable", err)
+		return
+	}
+	if info == nil {
+		errV2(c, CodeNotFound, "No previous version", "no previous executable is kept")
+		return
+	}
+	if reason := rollbackBlocker(info); reason != "" {
+		errV2(c, CodeConflict, "Rollback not possible", reason)
+		return
+	}
+
+	// The helper consumes its source and moves the running executable to prevPath, so it installs
+	// a copy.
+	tmpDir, err := os.MkdirTemp("", "bastion-rollback-*")
+	if err != nil {
+		errV2(c, CodeInternal, "Failed to create temp dir", err)
+		return
+	}
+	sourcePath := filepath.Join(tmpDir, filepath.Base(exePath))
+	if err := copyFile(prevPath, sourcePath, 0o755); err != nil {
+		log.Printf("update: rollback copy previous executable failed: %v", err)
+		_ = os.RemoveAll(tmpDir)
+		errV2(c, CodeInternal, "Failed to copy previous executable", err)
+		return
+	}
+
+	cmd, helperLogPath, err := startUpdateHelper(exePath, sourcePath, tmpDir)
+	if err != nil {
+		log.Printf("update: rollback start helper failed: %v", err)
+		_ = os.RemoveAll(tmpDir)
+		errV2(c, CodeInternal, "Failed to start helper", err)
+		return
+	}
+	log.Printf("update: rollback helper started (pid=%d target=%s) helper_log=%s", cmd.Process.Pid, info.Version, helperLogPath)
+
+	okV2(c, updateApplyResponse{
+		OK:            true,
+		TargetVersion: normalizeTag(info.Version),
+		Message:       "rollback started; restarting",
+		HelperPID:     cmd.Process.Pid,
+		HelperLogPath: helperLogPath,
+	})
+	shutdownAfterResponse(c)
+}
+
+func currentExecutable() (string, error) {
+	exePath, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Abs(exePath)
+}
+
+// startUpdateHelper starts this executable in self-update helper mode to replace exePath with
+// sourcePath once the server has shut down, keep the replaced executable for rollback, remove
+// tmpDir and restart with the current arguments.
+func startUpdateHelper(exePath, sourcePath, tmpDir string) (*exec.Cmd, string, error) {
+	helperLogPath := filepath.Join(filepath.Dir(exePath), "bastion-update-helper.log")
+	if err := ensureWritableFile(helperLogPath); err != nil {
+		helperLogPath = filepath.Join(os.TempDir(), fmt.Sprintf("bastion-update-helper-%d.log", time.Now().UnixNano()))
+		if err2 := ensureWritableFile(helperLogPath); err2 != nil {
+			log.Printf("update: create helper log file failed (path=%s): %v", helperLogPath, err2)
+		}
+	}
+
+	if err := core.RotateLogFileIfLarge(helperLogPath, core.LogRotationFromSettings()); err != nil {
+		log.Printf("update: rotate helper log failed (path=%s): %v", helperLogPath, err)
+	}
+
+	helperArgs := []string{
+		"--self-update-helper",
+		"--target", exePath,
+		"--source", sourcePath,
+		"--previous", previousExecutablePath(exePath),
+		"--previous-version", strings.TrimSpace(version.Version),
+		"--previous-schema", strconv.Itoa(database.LatestSchemaVersion()),
+		"--parent-pid", fmt.Sprintf("%d", os.Getpid()),
+		"--cleanup", tmpDir,
+		"--helper-log", helperLogPath,
+		"--restart",
+		"--",
+	}
+	helperArgs = append(helperArgs, os.Args[1:]...)
+
+	cmd := exec.Command(exePath, helperArgs...)
+	if f, err := os.OpenFile(helperLogPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644); err == nil {
+		// Even if the helper fails to open its own log file, its stderr/stdout will still be captured here.
+		//
+		// Note: do not close `f` here. The parent process exits shortly after starting the helper, and closing
+		// the writer early would stop the stdout/stderr tee goroutines from writing.
+		cmd.Stdout = io.MultiWriter(os.Stdout, f)
+		cmd.Stderr = io.MultiWriter(os.Stderr, f)
+	} else {
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		log.Printf("update: open helper log file for stdout/stderr failed (path=%s): %v", helperLogPath, err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, helperLogPath, err
+	}
+	return cmd, helperLogPath, nil
+}
+
+// shutdownAfterResponse flushes the response and shuts the server down shortly after, so the
+// started helper can replace the executable.
+func shutdownAfterResponse(c *gin.Context) {
+	if f, ok := c.Writer.(http.Flusher); ok {
+		f.Flush()
+	}
+
+	go func() {
+		// Give the client time to receive and render the response before shutting down.
+		time.Sleep(5 * time.Second)
+		if shutdownChan != nil {
+			log.Printf("update: triggering shutdown via channel")
+			shutdownChan <- true
+			return
+		}
+		log.Printf("update: cannot shutdown (shutdown channel not set)")
+	}()
+}
+
+func copyFile(src, dst string, mode os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"bastion/config"
+	"bastion/core"
+	"bastion/database"
+	"bastion/version"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	updateStatusSettingKey = "update_status"
+
+	// minUpdateCheckInterval keeps a misconfigured interval from hammering the GitHub API.
+	minUpdateCheckInterval = 5 * time.Minute
+	// firstUpdateCheckDelay lets startup settle before the first scheduled check.
+	firstUpdateCheckDelay = time.Minute
+)
+
+// updateStatus is the persisted result of the last update check, scheduled or manual.
+type updateStatus struct {
+	CheckedAt       time.Time  `json:"checked_at"` // last attempt
+	CurrentVersion  string     `json:"current_version"`
+	LatestVersion   string     `json:"latest_version,omitempty"`
+	UpdateAvailable bool       `json:"update_available"`
+	Prerelease      bool       `json:"prerelease"`
+	ReleaseURL      string     `json:"release_url,omitempty"`
+	AssetName       string     `json:"asset_name,omitempty"`
+	Channel         string     `json:"channel"`
+	PinnedTag       string     `json:"pinned_tag,omitempty"`
+	Error           string     `json:"error,omitempty"` // the last check failed; the other fields are from the last success
+	ErrorAt         *time.Time `json:"error_at,omitempty"`
+	NotifiedVersion string     `json:"notified_version,omitempty"` // last version an update_available alert was fired for
+}
+
+type updateCheckerInfo struct {
+	Enabled         bool       `json:"enabled"`
+	IntervalMinutes int        `json:"interval_minutes"`
+	NextCheckAt     *time.Time `json:"next_check_at,omitempty"`
+}
+
+type updateStatusResponse struct {
+	Status  *updateStatus     `json:"status"` // null until the first check
+	Checker updateCheckerInfo `json:"checker"`
+}
+
+var (
+	updateStatusMu     sync.Mutex
+	updateCheckerOnce  sync.Once
+	updateCheckerMu    sync.Mutex
+	updateCheckerNext  time.Time
+	updateCheckerEvery time.Duration
+)
+
+// GetUpdateStatusV2 returns the persisted result of the last update check, so the UI can show an
+// "update available" notice without querying GitHub, and the background checker's schedule.
+func GetUpdateStatusV2(c *gin.Context) {
+	status, err := loadUpdateStatus()
+	if err != nil {
+		errV2(c, CodeInternal, "Failed to load update status", err)
+		return
+	}
+	if status != nil {
+		// The status may predate an update or rollback of this executable.
+		current := strings.TrimSpace(version.Version)
+		if status.CurrentVersion != normalizeTag(current) && status.LatestVersion != "" {
+			status.CurrentVersion = normalizeTag(current)
+			status.UpdateAvailable = updateWanted(status.LatestVersion, current, status.PinnedTag != "")
+		}
+	}
+
+	updateCheckerMu.Lock()
+	info := updateCheckerInfo{
+		Enabled:         updateCheckerEvery > 0,
+		IntervalMinutes: int(updateCheckerEvery / time.Minute),
+	}
+	if !updateCheckerNext.IsZero() {
+		next := updateCheckerNext
+		info.NextCheckAt = &next
+	}
+	updateCheckerMu.Unlock()
+
+	okV2(c, updateStatusResponse{Status: status, Checker: info})
+}
+
+// StartUpdateChecker checks for updates every UPDATE_CHECK_INTERVAL_MINUTES (at least 5) and
+// persists the result for GET /api/v2/update/status. A newly available version fires an
+// update_available alert once. Checks go through the release caches, so unchanged releases are
+// revalidated with the ETag. It is a no-op when the interval is 0 and safe to call multiple times.
+func StartUpdateChecker() {
+	interval := time.Duration(config.Settings.UpdateCheckIntervalMinutes) * time.Minute
+	if interval <= 0 {
+		return
+	}
+	if interval < minUpdateCheckInterval {
+		interval = minUpdateCheckInterval
+	}
+	updateCheckerOnce.Do(func() {
+		updateCheckerMu.Lock()
+		updateCheckerEvery = interval
+		updateCheckerNext = time.Now().Add(firstUpdateCheckDelay)
+		updateCheckerMu.Unlock()
+
+		go func() {
+			time.Sleep(firstUpdateCheckDelay)
+			for {
+				runScheduledUpdateCheck()
+				updateCheckerMu.Lock()
+				updateCheckerNext = time.Now().Add(interval)
+				updateCheckerMu.Unlock()
+				time.Sleep(interval)
+			}
+		}()
+	})
+}
+
+func runScheduledUpdateCheck() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	release, pinned, err := resolveUpdateTarget(ctx)
+	if err != nil {
+		log.Printf("update: scheduled check failed: %v", err)
+		recordUpdateCheckError(err)
+		return
+	}
+	status := newUpdateStatus(release, pinned)
+	log.Printf("update: scheduled check current=%s latest=%s available=%v", status.CurrentVersion, status.LatestVersion, status.UpdateAvailable)
+	recordUpdateStatus(status, true)
+}
+
+// newUpdateStatus describes release as the update target for the running version.
+func newUpdateStatus(release *githubRelease, pinned bool) *updateStatus {
+	current := strings.TrimSpace(version.Version)
+	latest := strings.TrimSpace(release.TagName)
+	assetName, _, _ := selectReleaseAsset(release, runtime.GOOS, runtime.GOARCH)
+	return &updateStatus{
+		CheckedAt:       time.Now().UTC(),
+		CurrentVersion:  normalizeTag(current),
+		LatestVersion:   normalizeTag(latest),
+		UpdateAvailable: assetName != "" && updateWanted(latest, current, pinned),
+		Prerelease:      release.Prerelease,
+		ReleaseURL:      release.HTMLURL,
+		AssetName:       assetName,
+		Channel:         getUpdateChannel(),
+		PinnedTag:       getPinnedUpdateTag(),
+	}
+}
+
+// recordUpdateStatus persists status. With notify, a version not notified before fires an
+// update_available alert.
+func recordUpdateStatus(status *updateStatus, notify bool) {
+	updateStatusMu.Lock()
+	defer updateStatusMu.Unlock()
+
+	if prev, err := loadUpdateStatus(); err == nil && prev != nil {
+		status.NotifiedVersion = prev.NotifiedVersion
+	}
+	if notify && status.UpdateAvailable && status.LatestVersion != status.NotifiedVersion {
+		core.AlerterInstance.Fire(core.AlertEvent{
+			Type:     core.AlertUpdateAvailable,
+			Severity: "INFO",
+			Key:      status.LatestVersion,
+			Message:  fmt.Sprintf("Bastion %s is available (running %s)", status.LatestVersion, status.CurrentVersion),
+			Fields: map[string]interface{}{
+				"current_version": status.CurrentVersion,
+				"latest_version":  status.LatestVersion,
+				"release_url":     status.ReleaseURL,
+				"channel":         status.Channel,
+				"prerelease":      status.Prerelease,
+			},
+		})
+		status.NotifiedVersion = status.LatestVersion
+	}
+	if err := saveUpdateStatus(status); err != nil {
+		log.Printf("update: save status failed: %v", err)
+	}
+}
+
+// recordUpdateCheckError keeps the last successful result and notes the failure.
+func recordUpdateCheckError(checkErr error) {
+	updateStatusMu.Lock()
+	defer updateStatusMu.Unlock()
+
+	status, err := loadUpdateStatus()
+	if err != nil || status == nil {
+		status = &updateStatus{
+			CurrentVersion: normalizeTag(strings.TrimSpace(version.Version)),
+			Channel:        getUpdateChannel(),
+			PinnedTag:      getPinnedUpdateTag(),
+		}
+	}
+	now := time.Now().UTC()
+	status.CheckedAt = now
+	status.Error = checkErr.Error()
+	status.ErrorAt = &now
+	if err := saveUpdateStatus(status); err != nil {
+		log.Printf("update: save status failed: %v", err)
+	}
+}
+
+func loadUpdateStatus() (*updateStatus, error) {
+	raw, ok, err := database.GetSetting(updateStatusSettingKey)
+	if err != nil || !ok || strings.TrimSpace(raw) == "" {
+		return nil, err
+	}
+	var status updateStatus
+	if err := json.Unmarshal([]byte(raw), &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+func saveUpdateStatus(status *updateStatus) error {
+	data, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+	return database.SetSetting(updateStatusSettingKey, string(data))
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"bastion/database"
+	"bastion/version"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestGetUpdateStatusV2_PersistsAcrossRestart(t *testing.T) {
+	prevDB, prevVersion := database.DB, version.Version
+	updateCheckerMu.Lock()
+	prevEvery, prevNext := updateCheckerEvery, updateCheckerNext
+	updateCheckerEvery, updateCheckerNext = 0, time.Time{}
+	updateCheckerMu.Unlock()
+	t.Cleanup(func() {
+		database.DB, version.Version = prevDB, prevVersion
+		updateCheckerMu.Lock()
+		updateCheckerEvery, updateCheckerNext = prevEvery, prevNext
+		updateCheckerMu.Unlock()
+	})
+	version.Version = "1.0.0"
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/api/v2/update/status", GetUpdateStatusV2)
+	getStatus := func() map[string]interface{} {
+		t.Helper()
+		resp := serveV2(t, r, http.MethodGet, "/api/v2/update/status", "127.0.0.1:5000", nil, "")
+		if resp.Code != CodeOK {
+			t.Fatalf("GET update status: %+v", resp)
+		}
+		var data map[string]interface{}
+		decodeTestData(t, resp, &data)
+		return data
+	}
+
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, sqlDB := openTestDB(t, path)
+	database.DB = db
+	if data := getStatus(); data["status"] != nil {
+		t.Fatalf("status before the first check = %v, want null", data["status"])
+	}
+
+	checkedAt := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	recordUpdateStatus(&updateStatus{
+		CheckedAt:       checkedAt,
+		CurrentVersion:  "v1.0.0",
+		LatestVersion:   "v1.1.0",
+		UpdateAvailable: true,
+		ReleaseURL:      "https://github.com/wildking996/bastion/releases/tag/v1.1.0",
+		AssetName:       "bastion_linux_amd64.tar.gz",
+		Channel:         "stable",
+	}, false)
+	// A later failed check keeps the last result and notes the error.
+	recordUpdateCheckError(errors.New("rate limited"))
+
+	// Restart: a new connection to the same database and a checker that has not been scheduled.
+	sqlDB.Close()
+	db, sqlDB = openTestDB(t, path)
+	defer sqlDB.Close()
+	database.DB = db
+
+	data := getStatus()
+	status, _ := data["status"].(map[string]interface{})
+	if status == nil {
+		t.Fatalf("status after restart = %v", data)
+	}
+	for field, want := range map[string]interface{}{
+		"current_version":  "v1.0.0",
+		"latest_version":   "v1.1.0",
+		"update_available": true,
+		"release_url":      "https://github.com/wildking996/bastion/releases/tag/v1.1.0",
+		"asset_name":       "bastion_linux_amd64.tar.gz",
+		"channel":          "stable",
+		"error":            "rate limited",
+	} {
+		if status[field] != want {
+			t.Errorf("status.%s = %v, want %v", field, status[field], want)
+		}
+	}
+	if at, _ := status["checked_at"].(string); at == "" || at == checkedAt.Format(time.RFC3339) {
+		t.Errorf("status.checked_at = %v, want the time of the failed check", status["checked_at"])
+	}
+	if status["error_at"] == nil {
+		t.Error("status.error_at missing")
+	}
+	checker, _ := data["checker"].(map[string]interface{})
+	if checker["enabled"] != false || checker["interval_minutes"] != float64(0) || checker["next_check_at"] != nil {
+		t.Errorf("checker = %v, want disabled", checker)
+	}
+
+	// After the executable was updated the stored status is compared with the running version.
+	version.Version = "1.1.0"
+	status, _ = getStatus()["status"].(map[string]interface{})
+	if status["current_version"] != "v1.1.0" || status["update_available"] != false {
+		t.Fatalf("status after updating = %v", status)
+	}
+}
@@ -2,6 +2,8 @@ package handlers
 
 import (
 	"encoding/json"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -38,6 +40,38 @@ func TestIsVersionNewer(t *testing.T) {
 	}
 }
 
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"v1.2.3", "1.2.3", 0},
+		{"v1.2.4", "v1.2.3", 1},
+		{"v1.2.3-rc1", "v1.2.3", -1},
+		{"v1.2.3", "v1.2.3-rc1", 1},
+		{"v1.2.3-rc2", "v1.2.3-rc1", 1},
+		{"v1.3.0-beta.1", "v1.2.9", 1},
+		{"v1.2.3+build5", "v1.2.3", 0},
+	}
+	for _, tt := range tests {
+		if got := compareVersions(tt.a, tt.b); got != tt.want {
+			t.Fatalf("compareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestUpdateWanted(t *testing.T) {
+	if updateWanted("v1.2.0", "v1.3.0", false) {
+		t.Fatalf("expected no downgrade without a pin")
+	}
+	if !updateWanted("v1.2.0", "v1.3.0", true) {
+		t.Fatalf("expected downgrade to the pinned release")
+	}
+	if updateWanted("v1.3.0", "v1.3.0", true) {
+		t.Fatalf("expected no update when already on the pinned release")
+	}
+}
+
 func TestSelectReleaseAsset(t *testing.T) {
 	raw := `{
   "tag_name": "v1.10.0",
@@ -74,3 +108,26 @@ func TestSelectReleaseAsset(t *testing.T) {
 		t.Fatalf("expected error for missing darwin asset")
 	}
 }
+
+func TestPreviousBinaryInfo(t *testing.T) {
+	exePath := filepath.Join(t.TempDir(), "bastion")
+	if _, info, err := loadPreviousBinary(exePath); err != nil || info != nil {
+		t.Fatalf("expected no previous executable, got %+v, %v", info, err)
+	}
+
+	prev := previousExecutablePath(exePath)
+	if err := os.WriteFile(prev, []byte("old"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if _, info, err := loadPreviousBinary(exePath); err != nil || info == nil || info.Version != "unknown" {
+		t.Fatalf("expected unknown previous version, got %+v, %v", info, err)
+	}
+
+	if err := SavePreviousBinaryInfo(prev, "v1.2.0", 7); err != nil {
+		t.Fatal(err)
+	}
+	path, info, err := loadPreviousBinary(exePath)
+	if err != nil || path != prev || info == nil || info.Version != "v1.2.0" || info.SchemaVersion != 7 {
+		t.Fatalf("loadPreviousBinary = %s, %+v, %v", path, info, err)
+	}
+}
@@ -4,13 +4,9 @@ import (
 	"bastion/database"
 	"bastion/version"
 	"context"
-	"fmt"
-	"io"
 	"log"
-	"net/http"
 	"net/url"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strings"
@@ -24,24 +20,25 @@ func CheckUpdateV2(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
 	defer cancel()
 
-	release, err := fetchLatestRelease(ctx)
+	release, pinned, err := resolveUpdateTarget(ctx)
 	if err != nil {
-		log.Printf("update: check fetch latest release failed: %v", err)
-		errV2(c, CodeBadGateway, "Failed to fetch latest release", err.Error())
+		log.Printf("update: check resolve release failed: %v", err)
+		errV2(c, CodeBadGateway, "Failed to fetch release", err)
 		return
 	}
 
 	assetName, downloadURL, err := selectReleaseAsset(release, runtime.GOOS, runtime.GOARCH)
 	if err != nil {
 		log.Printf("update: check select asset failed (tag=%s os=%s arch=%s): %v", release.TagName, runtime.GOOS, runtime.GOARCH, err)
-		errV2(c, CodeBadGateway, "Failed to select release asset", err.Error())
+		errV2(c, CodeBadGateway, "Failed to select release asset", err)
 		return
 	}
 
 	current := strings.TrimSpace(version.Version)
 	latest := strings.TrimSpace(release.TagName)
 
-	updateAvailable := isVersionNewer(latest, current)
+	updateAvailable := updateWanted(latest, current, pinned)
+	recordUpdateStatus(newUpdateStatus(release, pinned), false)
 	logProxyEnv("update: check")
 	log.Printf(
 		"update: check result current=%s latest=%s available=%v asset=%s",
@@ -55,6 +52,9 @@ func CheckUpdateV2(c *gin.Context) {
 		ReleaseURL:      release.HTMLURL,
 		AssetName:       assetName,
 		DownloadURL:     downloadURL,
+		Prerelease:      release.Prerelease,
+		Channel:         getUpdateChannel(),
+		PinnedTag:       getPinnedUpdateTag(),
 	})
 }
 
@@ -63,34 +63,28 @@ func GenerateUpdateCodeV2(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
 	defer cancel()
 
-	release, err := fetchLatestRelease(ctx)
+	release, pinned, err := resolveUpdateTarget(ctx)
 	if err != nil {
-		log.Printf("update: generate code fetch latest release failed: %v", err)
-		errV2(c, CodeBadGateway, "Failed to fetch latest release", err.Error())
+		log.Printf("update: generate code resolve release failed: %v", err)
+		errV2(c, CodeBadGateway, "Failed to fetch release", err)
 		return
 	}
 
 	current := strings.TrimSpace(version.Version)
 	latest := strings.TrimSpace(release.TagName)
-	if !isVersionNewer(latest, current) {
+	if !updateWanted(latest, current, pinned) {
 		log.Printf("update: generate code skipped (already up to date current=%s latest=%s)", current, latest)
 		errV2(c, CodeInvalidRequest, "Already up to date", "already up to date")
 		return
 	}
 
-	code, err := generateSixDigitCode()
+	code, expiresAt, err := updateMgr.issue(5 * time.Minute)
 	if err != nil {
 		log.Printf("update: generate code failed: %v", err)
-		errV2(c, CodeInternal, "Failed to generate code", err.Error())
+		errV2(c, CodeInternal, "Failed to generate code", err)
 		return
 	}
 
-	expiresAt := time.Now().Add(5 * time.Minute)
-	updateMgr.mu.Lock()
-	updateMgr.code = code
-	updateMgr.expiresAt = expiresAt
-	updateMgr.mu.Unlock()
-
 	log.Printf("update: code generated (expires_at=%s)", expiresAt.UTC().Format(time.RFC3339))
 	okV2(c, updateGenerateCodeResponse{
 		Code:      code,
@@ -124,7 +118,7 @@ func SetUpdateProxyV2(c *gin.Context) {
 	value := strings.TrimSpace(req.ProxyURL)
 	if value == "" {
 		if err := database.DeleteSetting(updateProxySettingKey); err != nil {
-			errV2(c, CodeInternal, "Failed to clear proxy", err.Error())
+			errV2(c, CodeInternal, "Failed to clear proxy", err)
 			return
 		}
 		okV2(c, gin.H{"ok": true})
@@ -144,7 +138,7 @@ func SetUpdateProxyV2(c *gin.Context) {
 	}
 
 	if err := database.SetSetting(updateProxySettingKey, value); err != nil {
-		errV2(c, CodeInternal, "Failed to save proxy", err.Error())
+		errV2(c, CodeInternal, "Failed to save proxy", err)
 		return
 	}
 	okV2(c, gin.H{"ok": true})
@@ -168,27 +162,27 @@ func ApplyUpdateV2(c *gin.Context) {
 	}
 	if err := verifyUpdateCode(req.Code); err != nil {
 		log.Printf("update: apply code verification failed: %v", err)
-		errV2(c, CodeInvalidRequest, "Invalid update code", err.Error())
+		errV2(c, CodeInvalidRequest, "Invalid update code", err)
 		return
 	}
 
-	release, err := fetchLatestRelease(ctx)
+	release, pinned, err := resolveUpdateTarget(ctx)
 	if err != nil {
-		log.Printf("update: apply fetch latest release failed: %v", err)
-		errV2(c, CodeBadGateway, "Failed to fetch latest release", err.Error())
+		log.Printf("update: apply resolve release failed: %v", err)
+		errV2(c, CodeBadGateway, "Failed to fetch release", err)
 		return
 	}
 
 	assetName, downloadURL, err := selectReleaseAsset(release, runtime.GOOS, runtime.GOARCH)
 	if err != nil {
 		log.Printf("update: apply select asset failed (tag=%s os=%s arch=%s): %v", release.TagName, runtime.GOOS, runtime.GOARCH, err)
-		errV2(c, CodeBadGateway, "Failed to select release asset", err.Error())
+		errV2(c, CodeBadGateway, "Failed to select release asset", err)
 		return
 	}
 
 	current := strings.TrimSpace(version.Version)
 	latest := strings.TrimSpace(release.TagName)
-	if !isVersionNewer(latest, current) {
+	if !updateWanted(latest, current, pinned) {
 		log.Printf("update: apply aborted (already up to date current=%s latest=%s)", current, latest)
 		errV2(c, CodeInvalidRequest, "Already up to date", "already up to date")
 		return
@@ -197,7 +191,7 @@ func ApplyUpdateV2(c *gin.Context) {
 	exePath, err := os.Executable()
 	if err != nil {
 		log.Printf("update: apply os.Executable failed: %v", err)
-		errV2(c, CodeInternal, "Failed to locate executable", err.Error())
+		errV2(c, CodeInternal, "Failed to locate executable", err)
 		return
 	}
 	exePath, _ = filepath.Abs(exePath)
@@ -205,7 +199,7 @@ func ApplyUpdateV2(c *gin.Context) {
 	tmpDir, err := os.MkdirTemp("", "bastion-update-*")
 	if err != nil {
 		log.Printf("update: apply MkdirTemp failed: %v", err)
-		errV2(c, CodeInternal, "Failed to create temp dir", err.Error())
+		errV2(c, CodeInternal, "Failed to create temp dir", err)
 		return
 	}
 
@@ -218,15 +212,24 @@ func ApplyUpdateV2(c *gin.Context) {
 	if err := downloadFile(ctx, downloadURL, archivePath); err != nil {
 		log.Printf("update: apply download failed (dst=%s): %v", archivePath, err)
 		_ = os.RemoveAll(tmpDir)
-		errV2(c, CodeBadGateway, "Failed to download update", err.Error())
+		errV2(c, CodeBadGateway, "Failed to download update", err)
+		return
+	}
+
+	verification, err := verifyReleaseAsset(ctx, release, assetName, archivePath)
+	if err != nil {
+		log.Printf("update: apply verification failed (asset=%s): %v", assetName, err)
+		_ = os.RemoveAll(tmpDir)
+		respondV2(c, CodeBadGateway, "Update verification failed", gin.H{"detail": err.Error(), "verification": verification})
 		return
 	}
+	log.Printf("update: apply verified asset=%s checksum=%s signature=%s", assetName, verification.Checksum, verification.Signature)
 
 	newBinPath, err := extractBinary(archivePath, tmpDir, runtime.GOOS)
 	if err != nil {
 		log.Printf("update: apply extract failed (archive=%s tmp=%s): %v", archivePath, tmpDir, err)
 		_ = os.RemoveAll(tmpDir)
-		errV2(c, CodeBadGateway, "Failed to extract update", err.Error())
+		errV2(c, CodeBadGateway, "Failed to extract update", err)
 		return
 	}
 	log.Printf("update: apply extracted binary=%s", newBinPath)
@@ -235,39 +238,11 @@ func ApplyUpdateV2(c *gin.Context) {
 		_ = os.Chmod(newBinPath, 0o755)
 	}
 
-	helperLogPath := filepath.Join(filepath.Dir(exePath), "bastion-update-helper.log")
-	if err := ensureWritableFile(helperLogPath); err != nil {
-		helperLogPath = filepath.Join(os.TempDir(), fmt.Sprintf("bastion-update-helper-%d.log", time.Now().UnixNano()))
-		if err2 := ensureWritableFile(helperLogPath); err2 != nil {
-			log.Printf("update: apply create helper log file failed (path=%s): %v", helperLogPath, err2)
-		}
-	}
-
-	helperArgs := []string{
-		"--self-update-helper",
-		"--target", exePath,
-		"--source", newBinPath,
-		"--parent-pid", fmt.Sprintf("%d", os.Getpid()),
-		"--cleanup", tmpDir,
-		"--helper-log", helperLogPath,
-		"--restart",
-		"--",
-	}
-	helperArgs = append(helperArgs, os.Args[1:]...)
-
-	cmd := exec.Command(exePath, helperArgs...)
-	if f, err := os.OpenFile(helperLogPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644); err == nil {
-		cmd.Stdout = io.MultiWriter(os.Stdout, f)
-		cmd.Stderr = io.MultiWriter(os.Stderr, f)
-	} else {
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		log.Printf("update: apply open helper log file for stdout/stderr failed (path=%s): %v", helperLogPath, err)
-	}
-	if err := cmd.Start(); err != nil {
+	cmd, helperLogPath, err := startUpdateHelper(exePath, newBinPath, tmpDir)
+	if err != nil {
 		log.Printf("update: apply start helper failed: %v", err)
 		_ = os.RemoveAll(tmpDir)
-		errV2(c, CodeInternal, "Failed to start helper", err.Error())
+		errV2(c, CodeInternal, "Failed to start helper", err)
 		return
 	}
 	log.Printf("update: apply helper started (pid=%d) helper_log=%s", cmd.Process.Pid, helperLogPath)
@@ -278,19 +253,8 @@ func ApplyUpdateV2(c *gin.Context) {
 		Message:       "update started; restarting",
 		HelperPID:     cmd.Process.Pid,
 		HelperLogPath: helperLogPath,
+		Verification:  verification,
 	})
 
-	if f, ok := c.Writer.(http.Flusher); ok {
-		f.Flush()
-	}
-
-	go func() {
-		time.Sleep(5 * time.Second)
-		if shutdownChan != nil {
-			log.Printf("update: apply triggering shutdown via channel")
-			shutdownChan <- true
-			return
-		}
-		log.Printf("update: apply cannot shutdown (shutdown channel not set)")
-	}()
+	shutdownAfterResponse(c)
 }
@@ -0,0 +1,266 @@
+package handlers
+
+import (
+	"bastion/config"
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+const (
+	verifyVerified      = "verified"
+	verifySkipped       = "skipped"        // no SHA256SUMS and UPDATE_REQUIRE_CHECKSUM=false
+	verifyNotConfigured = "not_configured" // no UPDATE_MINISIGN_PUBKEY
+	verifyFailed        = "failed"
+
+	// maxChecksumFileBytes bounds the SHA256SUMS and signature downloads.
+	maxChecksumFileBytes = 1 << 20
+)
+
+// updateVerification is the result of checking a downloaded release asset, reported by apply.
+type updateVerification struct {
+	Checksum       string `json:"checksum"`
+	ChecksumFile   string `json:"checksum_file,omitempty"`
+	SHA256         string `json:"sha256,omitempty"`
+	Signature      string `json:"signature"`
+	SignatureKeyID string `json:"signature_key_id,omitempty"`
+}
+
+// verifyReleaseAsset checks the downloaded asset at path against the release's SHA256SUMS and,
+// when UPDATE_MINISIGN_PUBKEY is set, the minisign signature of SHA256SUMS (SHA256SUMS.minisig).
+// The returned result describes every step, including the one that failed.
+func verifyReleaseAsset(ctx context.Context, release *githubRelease, assetName, path string) (*updateVerification, error) {
+	result := &updateVerification{Checksum: verifyFailed, Signature: verifyNotConfigured}
+
+	var pub *minisignPublicKey
+	if key := strings.TrimSpace(config.Settings.UpdateMinisignPublicKey); key != "" {
+		result.Signature = verifyFailed
+		parsed, err := parseMinisignPublicKey(key)
+		if err != nil {
+			return result, fmt.Errorf("UPDATE_MINISIGN_PUBKEY: %w", err)
+		}
+		pub = parsed
+	}
+
+	sumsName, sumsURL := findReleaseAsset(release, isChecksumAssetName)
+	if sumsName == "" {
+		if pub != nil {
+			return result, fmt.Errorf("release %s has no SHA256SUMS asset to verify the signature of", release.TagName)
+		}
+		if config.Settings.UpdateRequireChecksum {
+			return result, fmt.Errorf("release %s has no SHA256SUMS asset; set UPDATE_REQUIRE_CHECKSUM=false to install it unverified", release.TagName)
+		}
+		result.Checksum = verifySkipped
+		return result, nil
+	}
+	result.ChecksumFile = sumsName
+
+	sums, err := downloadBytes(ctx, sumsURL, maxChecksumFileBytes)
+	if err != nil {
+		return result, fmt.Errorf("download %s: %w", sumsName, err)
+	}
+
+	if pub != nil {
+		sigName, sigURL := findReleaseAsset(release, func(name string) bool { return strings.EqualFold(name, sumsName+".minisig") })
+		if sigName == "" {
+			return result, fmt.Errorf("release %s has no %s.minisig signature", release.TagName, sumsName)
+		}
+		sig, err := downloadBytes(ctx, sigURL, maxChecksumFileBytes)
+		if err != nil {
+			return result, fmt.Errorf("download %s: %w", sigName, err)
+		}
+		if err := pub.verify(sums, sig); err != nil {
+			return result, fmt.Errorf("%s: %w", sigName, err)
+		}
+		result.Signature = verifyVerified
+		result.SignatureKeyID = pub.keyIDString()
+	}
+
+	want, ok := lookupChecksum(sums, assetName)
+	if !ok {
+		return result, fmt.Errorf("%s has no entry for %s", sumsName, assetName)
+	}
+	got, err := fileSHA256(path)
+	if err != nil {
+		return result, err
+	}
+	result.SHA256 = got
+	if !strings.EqualFold(got, want) {
+		return result, fmt.Errorf("sha256 mismatch for %s: expected %s, got %s", assetName, want, got)
+	}
+	result.Checksum = verifyVerified
+	return result, nil
+}
+
+func isChecksumAssetName(name string) bool {
+	lower := strings.ToLower(name)
+	return lower == "sha256sums" || lower == "sha256sums.txt" || strings.HasSuffix(lower, "checksums.txt")
+}
+
+func findReleaseAsset(release *githubRelease, match func(name string) bool) (name, url string) {
+	for _, a := range release.Assets {
+		if match(a.Name) {
+			return a.Name, a.BrowserDownloadURL
+		}
+	}
+	return "", ""
+}
+
+// lookupChecksum finds the hex SHA-256 of name in sha256sum output ("<hex>  <name>", or
+// "<hex> *<name>" for binary mode).
+func lookupChecksum(sums []byte, name string) (string, bool) {
+	scanner := bufio.NewScanner(bytes.NewReader(sums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 || len(fields[0]) != sha256.Size*2 {
+			continue
+		}
+		file := strings.TrimPrefix(fields[1], "*")
+		if file == name || filepath.Base(file) == name {
+			if _, err := hex.DecodeString(fields[0]); err == nil {
+				return strings.ToLower(fields[0]), true
+			}
+		}
+	}
+	return "", false
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func downloadBytes(ctx context.Context, url string, limit int64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := newUpdateHTTPClient(30 * time.Second).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 2048))
+		return nil, fmt.Errorf("download error: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("file exceeds %d bytes", limit)
+	}
+	return data, nil
+}
+
+// minisignPublicKey is an Ed25519 key in minisign format: "Ed" || key ID (8 bytes) || key.
+type minisignPublicKey struct {
+	keyID [8]byte
+	key   ed25519.PublicKey
+}
+
+// parseMinisignPublicKey accepts the base64 key line or the whole minisign .pub file.
+func parseMinisignPublicKey(s string) (*minisignPublicKey, error) {
+	line := lastMinisignLine(s)
+	raw, err := base64.StdEncoding.DecodeString(line)
+	if err != nil || len(raw) != 2+8+ed25519.PublicKeySize {
+		return nil, errors.New("not a minisign public key")
+	}
+	if string(raw[:2]) != "Ed" {
+		return nil, fmt.Errorf("unsupported minisign key algorithm %q", raw[:2])
+	}
+	pub := &minisignPublicKey{key: ed25519.PublicKey(raw[10:])}
+	copy(pub.keyID[:], raw[2:10])
+	return pub, nil
+}
+
+// lastMinisignLine returns the last non-empty line that is not an "untrusted comment:".
+func lastMinisignLine(s string) string {
+	line := ""
+	for _, l := range strings.Split(s, "\n") {
+		l = strings.TrimSpace(l)
+		if l != "" && !strings.HasPrefix(l, "untrusted comment:") {
+			line = l
+		}
+	}
+	return line
+}
+
+func (p *minisignPublicKey) keyIDString() string {
+	// minisign prints key IDs as the little-endian 64-bit integer in hex.
+	id := make([]byte, len(p.keyID))
+	for i := range p.keyID {
+		id[i] = p.keyID[len(p.keyID)-1-i]
+	}
+	return strings.ToUpper(hex.EncodeToString(id))
+}
+
+// verify checks a minisign signature file over message: the signature line ("Ed" signs the
+// message, "ED" its BLAKE2b-512 hash) and the global signature over the trusted comment.
+func (p *minisignPublicKey) verify(message, sigFile []byte) error {
+	lines := make([]string, 0, 4)
+	for _, l := range strings.Split(string(sigFile), "\n") {
+		if l = strings.TrimRight(l, "\r"); l != "" {
+			lines = append(lines, l)
+		}
+	}
+	if len(lines) < 4 || !strings.HasPrefix(lines[0], "untrusted comment:") || !strings.HasPrefix(lines[2], "trusted comment: ") {
+		return errors.New("malformed minisign signature")
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[1]))
+	if err != nil || len(sig) != 2+8+ed25519.SignatureSize {
+		return errors.New("malformed minisign signature")
+	}
+	if !bytes.Equal(sig[2:10], p.keyID[:]) {
+		return errors.New("signed by a different key")
+	}
+
+	signed := message
+	switch string(sig[:2]) {
+	case "Ed":
+	case "ED":
+		sum := blake2b.Sum512(message)
+		signed = sum[:]
+	default:
+		return fmt.Errorf("unsupported minisign signature algorithm %q", sig[:2])
+	}
+	if !ed25519.Verify(p.key, signed, sig[10:]) {
+		return errors.New("invalid signature")
+	}
+
+	global, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[3]))
+	if err != nil || len(global) != ed25519.SignatureSize {
+		return errors.New("malformed minisign global signature")
+	}
+	trusted := strings.TrimPrefix(lines[2], "trusted comment: ")
+	if !ed25519.Verify(p.key, append(append([]byte{}, sig[10:]...), trusted...), global) {
+		return errors.New("invalid trusted comment signature")
+	}
+	return nil
+}
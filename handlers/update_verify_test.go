@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+func TestLookupChecksum(t *testing.T) {
+	sums := strings.Join([]string{
+		strings.Repeat("a", 64) + "  bastion-v1.2.0-linux-amd64.tar.gz",
+		strings.Repeat("B", 64) + " *dist/bastion-v1.2.0-windows-amd64.zip",
+		"garbage line",
+	}, "\n")
+
+	if got, ok := lookupChecksum([]byte(sums), "bastion-v1.2.0-linux-amd64.tar.gz"); !ok || got != strings.Repeat("a", 64) {
+		t.Fatalf("linux entry = %q, %v", got, ok)
+	}
+	if got, ok := lookupChecksum([]byte(sums), "bastion-v1.2.0-windows-amd64.zip"); !ok || got != strings.Repeat("b", 64) {
+		t.Fatalf("windows entry = %q, %v", got, ok)
+	}
+	if _, ok := lookupChecksum([]byte(sums), "bastion-v1.2.0-darwin-arm64.tar.gz"); ok {
+		t.Fatalf("expected no entry for darwin")
+	}
+}
+
+func TestMinisignVerify(t *testing.T) {
+	pubKey, privKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyID := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	pubFile := "untrusted comment: minisign public key\n" +
+		base64.StdEncoding.EncodeToString(append(append([]byte("Ed"), keyID...), pubKey...)) + "\n"
+	pub, err := parseMinisignPublicKey(pubFile)
+	if err != nil {
+		t.Fatalf("parseMinisignPublicKey: %v", err)
+	}
+	if got := pub.keyIDString(); got != "0807060504030201" {
+		t.Fatalf("keyIDString = %s", got)
+	}
+
+	message := []byte("checksums\n")
+	sign := func(alg string, msg []byte) []byte {
+		signed := msg
+		if alg == "ED" {
+			sum := blake2b.Sum512(msg)
+			signed = sum[:]
+		}
+		sig := ed25519.Sign(privKey, signed)
+		trusted := "timestamp:1700000000"
+		global := ed25519.Sign(privKey, append(append([]byte{}, sig...), trusted...))
+		return []byte("untrusted comment: signature\n" +
+			base64.StdEncoding.EncodeToString(append(append([]byte(alg), keyID...), sig...)) + "\n" +
+			"trusted comment: " + trusted + "\n" +
+			base64.StdEncoding.EncodeToString(global) + "\n")
+	}
+
+	for _, alg := range []string{"Ed", "ED"} {
+		if err := pub.verify(message, sign(alg, message)); err != nil {
+			t.Fatalf("verify %s: %v", alg, err)
+		}
+		if err := pub.verify([]byte("tampered\n"), sign(alg, message)); err == nil {
+			t.Fatalf("verify %s: expected error for tampered message", alg)
+		}
+	}
+
+	tampered := strings.Replace(string(sign("ED", message)), "timestamp:1700000000", "timestamp:1800000000", 1)
+	if err := pub.verify(message, []byte(tampered)); err == nil {
+		t.Fatalf("expected error for tampered trusted comment")
+	}
+}
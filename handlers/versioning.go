@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"bastion/models"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// requestVersion returns the version an update is based on, from the If-Match header (`3`, `"3"`
+// or `W/"3"`) or the version field of the body; both must agree when given. 0 means neither was
+// sent.
+func requestVersion(c *gin.Context, bodyVersion int) (int, error) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" {
+		return bodyVersion, nil
+	}
+	tag := strings.Trim(strings.TrimPrefix(header, "W/"), `"`)
+	version, err := strconv.Atoi(tag)
+	if err != nil || version < 1 {
+		return 0, models.FieldErrorf("version", "invalid If-Match %q: must be the resource version", header)
+	}
+	if bodyVersion != 0 && bodyVersion != version {
+		return 0, models.FieldErrorf("version", "If-Match version %d does not match body version %d", version, bodyVersion)
+	}
+	return version, nil
+}
+
+// requireVersion is requestVersion for the v2 API, where updates must name the version they are
+// based on; the deprecated v1 routes keep it optional. On invalid or missing versions it writes the
+// error response and returns an error.
+func requireVersion(c *gin.Context, bodyVersion int) (int, error) {
+	version, err := requestVersion(c, bodyVersion)
+	if err == nil && version < 1 && !isV1(c) {
+		err = models.FieldErrorf("version", "version required: send the version you last read as If-Match or in the version field")
+	}
+	if err != nil {
+		errV2(c, CodeInvalidRequest, "Invalid version", err)
+		return 0, err
+	}
+	return version, nil
+}
+
+// setVersionHeader sets the ETag of a response to the resource version, for use as If-Match.
+func setVersionHeader(c *gin.Context, version int) {
+	c.Header("ETag", strconv.Quote(strconv.Itoa(version)))
+}
+
+// respondVersionConflict reports a stale update together with the current resource.
+func respondVersionConflict(c *gin.Context, err error, current any) {
+	_, apiErr := apiError(c, CodeConflict, err)
+	respondV2(c, CodeConflict, "Version conflict", gin.H{"detail": err.Error(), "current": current, "error": apiErr})
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequestVersion(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		ifMatch string
+		body    int
+		want    int
+		wantErr bool
+	}{
+		{ifMatch: "", body: 0, want: 0},
+		{ifMatch: "", body: 4, want: 4},
+		{ifMatch: "3", body: 0, want: 3},
+		{ifMatch: `"3"`, body: 0, want: 3},
+		{ifMatch: `W/"3"`, body: 3, want: 3},
+		{ifMatch: `"3"`, body: 4, wantErr: true},
+		{ifMatch: `"abc"`, body: 0, wantErr: true},
+		{ifMatch: "0", body: 0, wantErr: true},
+	}
+	for _, tt := range tests {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("PUT", "/api/v2/mappings/m1", nil)
+		if tt.ifMatch != "" {
+			c.Request.Header.Set("If-Match", tt.ifMatch)
+		}
+		got, err := requestVersion(c, tt.body)
+		if tt.wantErr {
+			if err == nil {
+				t.Fatalf("requestVersion(%q, %d): expected error", tt.ifMatch, tt.body)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Fatalf("requestVersion(%q, %d) = %d, %v; want %d", tt.ifMatch, tt.body, got, err, tt.want)
+		}
+	}
+}
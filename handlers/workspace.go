@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"bastion/models"
+	"bastion/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WorkspaceHeader selects the workspace of an API request; the `workspace` query parameter takes
+// precedence. Requests without either use the default workspace.
+const WorkspaceHeader = "X-Bastion-Workspace"
+
+const workspaceContextKey = "workspace"
+
+// authContextKey holds how RequireAdminToken let the request in: loopback, admin_token,
+// agent_token or none (metrics when MetricsAuth did).
+const authContextKey = "auth"
+
+// agentContextKey holds the *models.Agent of a request let in with an agent token.
+const agentContextKey = "agent"
+
+// ResolveWorkspace stores the request workspace in the context and rejects invalid names.
+func ResolveWorkspace() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		workspace := requestWorkspace(c)
+		if err := models.ValidateWorkspaceName(workspace); err != nil {
+			errV2(c, CodeInvalidRequest, "Invalid workspace", err)
+			c.Abort()
+			return
+		}
+		c.Set(workspaceContextKey, workspace)
+		c.Next()
+	}
+}
+
+// requestWorkspace is the workspace a request selects, normalized but not validated.
+func requestWorkspace(c *gin.Context) string {
+	workspace := c.Query("workspace")
+	if workspace == "" {
+		workspace = c.GetHeader(WorkspaceHeader)
+	}
+	return models.NormalizeWorkspace(workspace)
+}
+
+// scopedServices returns the services scoped to the request workspace, recording configuration
+// changes as made by the request's client.
+func scopedServices(c *gin.Context) *service.Services {
+	return service.GlobalServices.InWorkspace(c.GetString(workspaceContextKey)).As(requestActor(c))
+}
+
+// requestActor identifies the client of a request in the configuration audit trail. Like
+// isLoopbackRequest it uses the socket peer address, which forwarding headers cannot spoof.
+func requestActor(c *gin.Context) service.Actor {
+	return service.Actor{Name: c.RemoteIP(), Auth: c.GetString(authContextKey)}
+}
+
+// ListWorkspacesV2 lists workspaces with their bastion/mapping/running counts.
+func ListWorkspacesV2(c *gin.Context) {
+	workspaces, err := service.GlobalServices.Workspace.List()
+	if err != nil {
+		errV2(c, CodeInternal, "Failed to list workspaces", err)
+		return
+	}
+	okV2(c, workspaces)
+}
@@ -0,0 +1,91 @@
+// Package i18n translates user-facing server and CLI messages. Messages are keyed by their English
+// text, so untranslated messages fall back to English unchanged.
+package i18n
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Supported languages.
+const (
+	English = "en"
+	Chinese = "zh"
+)
+
+// bundles maps a language to its translations by English message. English needs no bundle.
+var bundles = map[string]map[string]string{
+	Chinese: zhMessages,
+}
+
+// T translates msg into lang, returning msg when lang or the message has no translation.
+func T(lang, msg string) string {
+	if t, ok := bundles[lang][msg]; ok {
+		return t
+	}
+	return msg
+}
+
+// Tf translates the format string and formats it with args.
+func Tf(lang, format string, args ...interface{}) string {
+	return fmt.Sprintf(T(lang, format), args...)
+}
+
+// Normalize maps a language tag (zh-CN, zh_TW.UTF-8, en-US, ...) to a supported language, or
+// returns "" when it is not supported.
+func Normalize(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if i := strings.IndexAny(tag, "-_.@"); i >= 0 {
+		tag = tag[:i]
+	}
+	switch tag {
+	case English, Chinese:
+		return tag
+	}
+	return ""
+}
+
+// FromAcceptLanguage picks the supported language the Accept-Language header prefers most, or
+// "" when it names none.
+func FromAcceptLanguage(header string) string {
+	type choice struct {
+		lang string
+		q    float64
+	}
+	var choices []choice
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		lang := Normalize(tag)
+		if lang == "" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		if q > 0 {
+			choices = append(choices, choice{lang, q})
+		}
+	}
+	sort.SliceStable(choices, func(i, j int) bool { return choices[i].q > choices[j].q })
+	if len(choices) == 0 {
+		return ""
+	}
+	return choices[0].lang
+}
+
+// FromEnvironment returns the language of the process locale (LC_ALL, LC_MESSAGES, LANG), or ""
+// when it is unset or not supported.
+func FromEnvironment() string {
+	for _, key := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if v := os.Getenv(key); v != "" {
+			return Normalize(v)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,299 @@
+package i18n
+
+// zhMessages is the Chinese bundle.
+var zhMessages = map[string]string{
+	// API response messages
+	"Admin token required":                        "需要管理员令牌",
+	"Agent not found":                             "Agent 不存在",
+	"Alert delivery failed":                       "告警发送失败",
+	"Already up to date":                          "已是最新版本",
+	"Apply failed; nothing was changed":           "应用失败，未做任何更改",
+	"Backup failed":                               "备份失败",
+	"Backup file already exists":                  "备份文件已存在",
+	"Bad gateway":                                 "网关错误",
+	"Bastion benchmark failed":                    "堡垒机基准测试失败",
+	"Bastion is referenced by running mapping(s)": "堡垒机正被运行中的映射使用",
+	"Bastion rejected the new credentials":        "堡垒机拒绝了新凭据",
+	"Bulk request rolled back":                    "批量请求已回滚",
+	"Conflict":                                    "冲突",
+	"Docker discovery failed":                     "Docker 发现失败",
+	"Dry run failed":                              "试运行失败",
+	"Failed to aggregate logs":                    "日志聚合失败",
+	"Failed to apply":                             "应用失败",
+	"Failed to apply mappings":                    "应用映射失败",
+	"Failed to apply setup step":                  "应用初始化步骤失败",
+	"Failed to benchmark bastion":                 "堡垒机基准测试失败",
+	"Failed to build diagnostics bundle":          "生成诊断包失败",
+	"Failed to check bastion usage":               "检查堡垒机使用情况失败",
+	"Failed to clear pin":                         "清除版本固定失败",
+	"Failed to clear proxy":                       "清除代理失败",
+	"Failed to compare logs":                      "日志对比失败",
+	"Failed to copy previous executable":          "复制旧版本可执行文件失败",
+	"Failed to create bastion":                    "创建堡垒机失败",
+	"Failed to create mapping":                    "创建映射失败",
+	"Failed to create temp dir":                   "创建临时目录失败",
+	"Failed to delete agent":                      "删除 Agent 失败",
+	"Failed to delete bastion":                    "删除堡垒机失败",
+	"Failed to delete mapping":                    "删除映射失败",
+	"Failed to demote this instance":              "降级本实例失败",
+	"Failed to discover ssh processes":            "发现 SSH 进程失败",
+	"Failed to download update":                   "下载更新失败",
+	"Failed to export request":                    "导出请求失败",
+	"Failed to extract update":                    "解压更新失败",
+	"Failed to fetch log detail":                  "获取日志详情失败",
+	"Failed to fetch release":                     "获取发布信息失败",
+	"Failed to fetch releases":                    "获取发布列表失败",
+	"Failed to generate code":                     "生成验证码失败",
+	"Failed to import ssh processes":              "导入 SSH 进程失败",
+	"Failed to issue agent token":                 "签发 Agent 令牌失败",
+	"Failed to list agents":                       "获取 Agent 列表失败",
+	"Failed to list bastions":                     "获取堡垒机列表失败",
+	"Failed to list config audit entries":         "获取配置审计记录失败",
+	"Failed to list interfaces":                   "获取网络接口失败",
+	"Failed to list mapping events":               "获取映射事件失败",
+	"Failed to list mappings":                     "获取映射列表失败",
+	"Failed to list workspaces":                   "获取工作区列表失败",
+	"Failed to list containers":                   "获取容器列表失败",
+	"Failed to load bastion":                      "加载堡垒机失败",
+	"Failed to load bastions":                     "加载堡垒机失败",
+	"Failed to load mappings":                     "加载映射失败",
+	"Failed to load setup status":                 "加载初始化状态失败",
+	"Failed to load update status":                "加载更新状态失败",
+	"Failed to locate executable":                 "无法定位可执行文件",
+	"Failed to promote this instance":             "提升本实例失败",
+	"Failed to query error logs":                  "查询错误日志失败",
+	"Failed to read previous executable":          "读取旧版本可执行文件失败",
+	"Failed to read ssh config":                   "读取 SSH 配置失败",
+	"Failed to restart mapping":                   "重启映射失败",
+	"Failed to rotate bastion credentials":        "轮换堡垒机凭据失败",
+	"Failed to save agent report":                 "保存 Agent 上报失败",
+	"Failed to save channel":                      "保存更新通道失败",
+	"Failed to save pin":                          "保存版本固定失败",
+	"Failed to save proxy":                        "保存代理失败",
+	"Failed to select fields":                     "选择字段失败",
+	"Failed to select release asset":              "选择发布文件失败",
+	"Failed to start helper":                      "启动升级助手失败",
+	"Failed to start mapping":                     "启动映射失败",
+	"Failed to update bastion":                    "更新堡垒机失败",
+	"Failed to update mapping":                    "更新映射失败",
+	"Failover failed":                             "故障切换失败",
+	"Integrity check failed":                      "完整性检查失败",
+	"Internal error":                              "内部错误",
+	"Internal server error":                       "服务器内部错误",
+	"Invalid action":                              "无效的操作",
+	"Invalid agent report":                        "无效的 Agent 上报",
+	"Invalid apply document":                      "无效的应用文档",
+	"Invalid apply request":                       "无效的应用请求",
+	"Invalid bastion id":                          "无效的堡垒机 ID",
+	"Invalid benchmark request":                   "无效的基准测试请求",
+	"Invalid bulk request":                        "无效的批量请求",
+	"Invalid channel":                             "无效的更新通道",
+	"Invalid credentials":                         "无效的凭据",
+	"Invalid decode value":                        "无效的 decode 参数",
+	"Invalid docker request":                      "无效的 Docker 请求",
+	"Invalid dry-run target":                      "无效的试运行目标",
+	"Invalid errors flag":                         "无效的 errors 参数",
+	"Invalid expose request":                      "无效的暴露请求",
+	"Invalid format value":                        "无效的 format 参数",
+	"Invalid group_by":                            "无效的 group_by 参数",
+	"Invalid header filter":                       "无效的请求头过滤条件",
+	"Invalid import request":                      "无效的导入请求",
+	"Invalid id":                                  "无效的 ID",
+	"Invalid kind":                                "无效的 kind 参数",
+	"Invalid level":                               "无效的 level 参数",
+	"Invalid limit":                               "无效的 limit 参数",
+	"Invalid list query":                          "无效的列表查询",
+	"Invalid local_port":                          "无效的 local_port",
+	"Invalid log ids":                             "无效的日志 ID",
+	"Invalid matches":                             "无效的 matches 参数",
+	"Invalid min_count":                           "无效的 min_count 参数",
+	"Invalid min_latency_ms":                      "无效的 min_latency_ms 参数",
+	"Invalid min_level":                           "无效的 min_level 参数",
+	"Invalid part":                                "无效的 part 参数",
+	"Invalid protocol":                            "无效的 protocol 参数",
+	"Invalid proxy url":                           "无效的代理地址",
+	"Invalid range":                               "无效的范围",
+	"Invalid regex flag":                          "无效的 regex 参数",
+	"Invalid regex pattern":                       "无效的正则表达式",
+	"Invalid request":                             "无效的请求",
+	"Invalid resource":                            "无效的资源",
+	"Invalid rollback code":                       "无效的回滚验证码",
+	"Invalid shutdown code":                       "无效的关机验证码",
+	"Invalid since timestamp":                     "无效的 since 时间",
+	"Invalid sort":                                "无效的排序",
+	"Invalid status code":                         "无效的状态码",
+	"Invalid summary flag":                        "无效的 summary 参数",
+	"Invalid until timestamp":                     "无效的 until 时间",
+	"Invalid update code":                         "无效的更新验证码",
+	"Invalid version":                             "无效的版本",
+	"Invalid wait timeout":                        "无效的等待超时",
+	"Invalid workspace":                           "无效的工作区",
+	"Local address is already in use":             "本地地址已被占用",
+	"Log not found":                               "日志不存在",
+	"Mapping already exists":                      "映射已存在",
+	"Mapping dependencies are invalid":            "映射依赖无效",
+	"Mapping is running":                          "映射正在运行",
+	"Mapping not found":                           "映射不存在",
+	"Mapping started but is not ready":            "映射已启动但尚未就绪",
+	"Mapping stopped before it was ready":         "映射在就绪前已停止",
+	"Metrics access denied":                       "无权访问指标",
+	"No alert targets configured":                 "未配置告警目标",
+	"No previous version":                         "没有旧版本",
+	"No shutdown code generated":                  "尚未生成关机验证码",
+	"Not found":                                   "未找到",
+	"Proxy test failed":                           "代理测试失败",
+	"Release has no asset for this platform":      "该版本没有适用于当前平台的文件",
+	"Release not found":                           "未找到该版本",
+	"Replay failed":                               "重放失败",
+	"Request cannot be exported":                  "该请求无法导出",
+	"Request cannot be replayed":                  "该请求无法重放",
+	"Rollback not possible":                       "无法回滚",
+	"Service degraded":                            "服务降级",
+	"Setup step rejected":                         "初始化步骤被拒绝",
+	"Shutdown channel is not initialized":         "关机通道未初始化",
+	"Shutdown code expired":                       "关机验证码已过期",
+	"Too many requests":                           "请求过于频繁",
+	"Unknown setup step":                          "未知的初始化步骤",
+	"Update verification failed":                  "更新校验失败",
+	"Vacuum failed":                               "数据库整理失败",
+	"Version conflict":                            "版本冲突",
+	"bastion host is immutable":                   "堡垒机主机地址不可修改",
+	"bastion name is immutable":                   "堡垒机名称不可修改",
+	"bastion port is immutable":                   "堡垒机端口不可修改",
+
+	// CLI prompts
+	"Admin token: (g)enerate, (e)nter, (s)kip":                   "管理员令牌：(g)生成，(e)输入，(s)跳过",
+	"Aliases to import (space separated, 'all' or 'none')":       "要导入的别名（空格分隔，'all' 或 'none'）",
+	"Auth method (1=Password, 2=SSH Key)":                        "认证方式（1=密码，2=SSH 密钥）",
+	"Bastion chain (comma-separated names or numbers)":           "堡垒机链（逗号分隔的名称或编号）",
+	"Bastion chain (comma-separated names or numbers, optional)": "堡垒机链（逗号分隔的名称或编号，可选）",
+	"Bastion chain (comma-separated, empty to clear)":            "堡垒机链（逗号分隔，留空清除）",
+	"Bind address ('skip' keeps the current one)":                "绑定地址（'skip' 保留当前设置）",
+	"Clear all HTTP logs? (yes/no)":                              "清空所有 HTTP 日志？(yes/no)",
+	"Host":                                                       "主机",
+	"Host (required)":                                            "主机（必填）",
+	"Key Passphrase":                                             "私钥口令",
+	"Key Passphrase (optional)":                                  "私钥口令（可选）",
+	"Local Host":                                                 "本地主机",
+	"Local Port (1-65535, 0 = auto)":                             "本地端口（1-65535，0 = 自动）",
+	"Local Port (1-65535, 0 = auto, required)":                   "本地端口（1-65535，0 = 自动，必填）",
+	"Local Port (required, 0 = auto)":                            "本地端口（必填，0 = 自动）",
+	"Mapping ID":                                                 "映射 ID",
+	"Mapping ID (optional, will auto-generate)":                  "映射 ID（可选，留空自动生成）",
+	"Name": "名称",
+	"Name (optional, will auto-generate if empty)": "名称（可选，留空自动生成）",
+	"Password":                                "密码",
+	"Port":                                    "端口",
+	"Port (1-65535)":                          "端口（1-65535）",
+	"Remote Host":                             "远程主机",
+	"Remote Host (required)":                  "远程主机（必填）",
+	"Remote Port (1-65535)":                   "远程端口（1-65535）",
+	"Remote Port (1-65535, required)":         "远程端口（1-65535，必填）",
+	"Remote Port (required)":                  "远程端口（必填）",
+	"SSH Key Path":                            "SSH 私钥路径",
+	"Token (min 12 characters)":               "令牌（至少 12 个字符）",
+	"Type (1=TCP, 2=SOCKS5, 3=HTTP, 4=Mixed)": "类型（1=TCP，2=SOCKS5，3=HTTP，4=混合）",
+	"Username":                                "用户名",
+	"Username (required)":                     "用户名（必填）",
+	"Your choice":                             "请选择",
+	"\nSelect session to stop (number or port)": "\n选择要停止的会话（编号或端口）",
+	"\nYour choice": "\n请选择",
+
+	// CLI messages
+	"❌ Invalid host format! Please enter a valid IPv4/IPv6 address or domain name.": "❌ 主机格式无效！请输入有效的 IPv4/IPv6 地址或域名。",
+	"❌ Invalid port! Port must be between 1 and 65535.":                             "❌ 端口无效！端口必须在 1 到 65535 之间。",
+	"❌ Invalid port! Port must be between 1 and 65535, or 0 to pick one at start.":  "❌ 端口无效！端口必须在 1 到 65535 之间，或为 0 表示启动时自动选择。",
+	"❌ Invalid choice. Please try again.":                                           "❌ 选择无效，请重试。",
+	"❌ Remote Port is required!":                                                    "❌ 远程端口为必填项！",
+	"❌ Local Port is required!":                                                     "❌ 本地端口为必填项！",
+	"❌ Invalid username!":                                                           "❌ 用户名无效！",
+	"❌ Failed to stop %s: %v\n":                                                     "❌ 停止 %s 失败：%v\n",
+	"✓ Stopped %s\n":                                                                "✓ 已停止 %s\n",
+	"✓ Mapping started successfully!":                                               "✓ 映射已启动！",
+	"✓ Mapping stopped successfully!":                                               "✓ 映射已停止！",
+	"✓ Mapping deleted successfully!":                                               "✓ 映射已删除！",
+	"✓ Bastion deleted successfully!":                                               "✓ 堡垒机已删除！",
+	"✓ HTTP logs cleared successfully!":                                             "✓ HTTP 日志已清空！",
+	"\n✓ Mapping created successfully! ID: %s\n":                                    "\n✓ 映射创建成功！ID：%s\n",
+	"\n✓ Bastion created successfully! ID: %d\n":                                    "\n✓ 堡垒机创建成功！ID：%d\n",
+	"✓ Switched to workspace %s\n":                                                  "✓ 已切换到工作区 %s\n",
+	"\nOptions:":                                                                    "\n选项：",
+	"\nGoodbye!":                                                                    "\n再见！",
+	"\nGoodbye! (Sessions still running)":                                           "\n再见！（会话仍在运行）",
+	"\nAvailable Bastions:":                                                         "\n可用堡垒机：",
+	"\nStopping all sessions...":                                                    "\n正在停止所有会话...",
+	"\nAll sessions reviewed.":                                                      "\n所有会话已处理。",
+	"\n⚠ You have %d active session(s).\n":                                          "\n⚠ 当前有 %d 个活动会话。\n",
+	"\n⚠ Ctrl+C detected. Please use 'exit' or 'quit' command to exit gracefully.":          "\n⚠ 检测到 Ctrl+C。请使用 'exit' 或 'quit' 命令正常退出。",
+	"\nTip: Don't worry about mistakes, you can review and modify at the confirmation step": "\n提示：填错也没关系，可以在确认步骤中检查和修改",
+	"\nUse 'http show <id>' to view details\n":                                              "\n使用 'http show <id>' 查看详情\n",
+	"No HTTP logs available.":              "暂无 HTTP 日志。",
+	"No mappings configured.":              "尚未配置映射。",
+	"No mappings match the filter.":        "没有符合条件的映射。",
+	"No bastions configured.":              "尚未配置堡垒机。",
+	"No bastions match the filter.":        "没有符合条件的堡垒机。",
+	"No active sessions.":                  "没有活动会话。",
+	"Cancelled.":                           "已取消。",
+	"Exit cancelled.":                      "已取消退出。",
+	"Invalid choice. Exit cancelled.":      "选择无效，已取消退出。",
+	"Invalid selection. Please try again.": "选择无效，请重试。",
+	"Session not found. Please try again.": "未找到会话，请重试。",
+	"Re-run with --yes to confirm.":        "请加上 --yes 重新运行以确认。",
+	"Type 'help' for available commands":   "输入 'help' 查看可用命令",
+	"First run detected: type 'setup' to configure Bastion step by step": "检测到首次运行：输入 'setup' 逐步配置 Bastion",
+	"  0. Cancel (return to CLI)":                                        "  0. 取消（返回命令行）",
+	"  1. Exit directly (keep sessions running)":                         "  1. 直接退出（保持会话运行）",
+	"  2. Stop all sessions and exit":                                    "  2. 停止所有会话并退出",
+	"  3. Stop sessions individually":                                    "  3. 逐个停止会话",
+	"  - Press Enter to confirm and create":                              "  - 按回车确认并创建",
+	"  - Press Ctrl+C to abort":                                          "  - 按 Ctrl+C 放弃",
+	"     Press Ctrl+C anytime to cancel":                                "     随时按 Ctrl+C 取消",
+	"  - Enter field number (1-5) to modify":                             "  - 输入字段编号（1-5）进行修改",
+	"  - Enter field number (1-6) to modify":                             "  - 输入字段编号（1-6）进行修改",
+
+	// API error hints
+	"Agent tokens only report their agent and read its workspace; set AGENT_NAME and AGENT_WORKSPACE to those the token was issued for.": "Agent 令牌只能上报其 Agent 并读取其工作区；请将 AGENT_NAME 与 AGENT_WORKSPACE 设为签发令牌时的值。",
+	"Authenticate and retry.":                                                                            "请完成认证后重试。",
+	"Check AGENT_NAME and AGENT_WORKSPACE on the agent.":                                                 "请检查 Agent 上的 AGENT_NAME 与 AGENT_WORKSPACE。",
+	"Check the agent name against the list of agents.":                                                   "请对照 Agent 列表检查 Agent 名称。",
+	"Check the bastion ID and the workspace of the request.":                                             "请检查跳板机 ID 与请求的工作区。",
+	"Check the bastion chain and the target, e.g. with a dry run, and retry.":                            "请检查跳板链与目标（例如通过预检）后重试。",
+	"Check the credentials against the bastion; nothing was changed.":                                    "请核对跳板机的凭据；未做任何修改。",
+	"Check the identifiers in the request.":                                                              "请检查请求中的标识符。",
+	"Check the mapping ID and the workspace of the request.":                                             "请检查映射 ID 与请求的工作区。",
+	"Check the peer's health and HA_PEER_TOKEN; this instance kept its role.":                            "请检查对端实例的健康状态与 HA_PEER_TOKEN；本实例保持原角色。",
+	"Choose another mapping ID, or update the existing mapping.":                                         "请换用其他映射 ID，或更新已有映射。",
+	"Complete the earlier setup steps first.":                                                            "请先完成之前的初始化步骤。",
+	"Correct the value of the named field and retry.":                                                    "请修正所指字段的值后重试。",
+	"Fix the request as described by the detail.":                                                        "请按 detail 的说明修正请求。",
+	"Fix the request as described by the detail; nothing was changed.":                                   "请按 detail 的说明修正请求；未做任何修改。",
+	"Give a password or a private key path.":                                                             "请提供密码或私钥路径。",
+	"Give the target as host:port; proxy mappings require one.":                                          "请以 host:port 形式指定目标；代理类映射必须指定。",
+	"List at least one mapping, and no more than the documented maximum.":                                "请至少列出一个映射，且不超过文档规定的上限。",
+	"No action needed: the mapping is running.":                                                          "无需操作：映射正在运行。",
+	"Reload the current state and retry.":                                                                "请重新加载当前状态后重试。",
+	"Reload the resource and apply your change to the current version.":                                  "请重新加载资源，并基于当前版本重新修改。",
+	"Remove self references, unknown mappings and cycles from depends_on.":                               "请从 depends_on 中移除自身引用、未知映射与循环依赖。",
+	"Remove the bastion from the chains of the mappings using it first.":                                 "请先从使用该跳板机的映射链路中移除它。",
+	"Repeat the request with confirm=true to expose the mapping beyond localhost.":                       "如需将映射暴露到本机以外，请带 confirm=true 重新请求。",
+	"Retry later; report the request ID if it persists.":                                                 "请稍后重试；如持续出现请提供请求 ID。",
+	"Send a JSON body with the documented field types.":                                                  "请发送字段类型符合文档的 JSON 请求体。",
+	"Set HA_ROLE and HA_PEER_URL and restart to pair this instance with another.":                        "请设置 HA_ROLE 与 HA_PEER_URL 并重启，使本实例与另一实例组成主备。",
+	"Set another AGENT_WORKSPACE on the agent, or delete the agent running the workspace.":               "请在 Agent 上设置其他 AGENT_WORKSPACE，或删除正在运行该工作区的 Agent。",
+	"Set the target variables the mapping's target templates use.":                                       "请设置映射目标模板所用的目标变量。",
+	"See the failed hop or the docker error; the SSH user must be allowed to use Docker on the bastion.": "请查看失败的跳点或 docker 错误；SSH 用户须有权在跳板机上使用 Docker。",
+	"See the failed step in the benchmark report; remote mode needs cat and head on the bastion.":        "请查看基准测试报告中失败的步骤；remote 模式要求跳板机上有 cat 与 head。",
+	"Setup is done; manage bastions and mappings through the API.":                                       "初始化已完成；请通过 API 管理跳板机与映射。",
+	"Slow down and retry later.":                                                                         "请降低请求频率，稍后重试。",
+	"Start the mapping first.":                                                                           "请先启动映射。",
+	"Stop the mapping first, or use force=true&restart=true where supported.":                            "请先停止映射，或在支持时使用 force=true&restart=true。",
+	"Stop the process holding the port, or choose another local_port (0 picks a free one).":              "请停止占用该端口的进程，或换用其他 local_port（0 表示自动选择空闲端口）。",
+	"The change was saved; fix the reported start error and start the mapping.":                          "变更已保存；请修复报告的启动错误后启动映射。",
+	"Use a non-empty mapping ID without \"/\".":                                                          "请使用非空且不含 \"/\" 的映射 ID。",
+	"Use a non-empty value and a name of 1-64 letters, digits, '_', '-' or '.'.":                         "请使用非空的值，名称为 1-64 个字母、数字、'_'、'-' 或 '.'。",
+	"Use bytes and pings within the documented limits and a host:port target.":                           "请使 bytes 与 pings 在文档规定的范围内，并以 host:port 形式指定目标。",
+	"Use an address of one of this host's interfaces.":                                                   "请使用本机某个网卡的地址。",
+	"Use one of the steps listed by the setup status.":                                                   "请使用初始化状态中列出的步骤。",
+	"Use source cli or api, a unix:///path or tcp://host:port host and existing via bastions.":           "请将 source 设为 cli 或 api，以 unix:///path 或 tcp://host:port 形式指定 host，并在 via 中使用已存在的跳板机。",
+	"Wait for the resource to be released, or free it, and retry.":                                       "请等待资源释放或主动释放后重试。",
+}
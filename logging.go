@@ -1,31 +1,23 @@
 package main
 
 import (
+	"bastion/core"
 	"fmt"
+	"io"
 	"log"
-	"os"
 )
 
-// setupLogging configures file-only logging with a single rotated history file.
-// It returns the opened log file so callers can close it on shutdown.
-func setupLogging(path string) (*os.File, error) {
+// setupLogging configures file-only logging. The previous run's log is rotated away at startup and
+// the file rotates by size while running (see core.LogRotation). It returns the log file so
+// callers can close it on shutdown.
+func setupLogging(path string) (io.Closer, error) {
 	if path == "" {
 		return nil, fmt.Errorf("log file path is empty")
 	}
 
-	// Remove existing history to keep only one backup
-	_ = os.Remove(path + ".1")
-
-	// Rotate current log to .1 if present
-	if _, err := os.Stat(path); err == nil {
-		if err := os.Rename(path, path+".1"); err != nil {
-			return nil, fmt.Errorf("failed to rotate existing log: %w", err)
-		}
-	}
-
-	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	f, err := core.OpenRotatingFile(path, core.LogRotationFromSettings(), true)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open log file %s: %w", path, err)
+		return nil, err
 	}
 
 	log.SetOutput(f)
@@ -21,6 +21,7 @@ import (
 	"os/signal"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
@@ -44,6 +45,11 @@ func main() {
 	// Load environment variables and parse CLI flags
 	config.ParseFlags()
 
+	// The self-check runs before logging is set up so it does not rotate the log it checks.
+	if config.Settings.SelfCheck {
+		os.Exit(printSelfCheck())
+	}
+
 	logFile, err := setupLogging(config.Settings.LogFilePath)
 	if err != nil {
 		log.Fatalf("Failed to set up logging: %v", err)
@@ -64,6 +70,10 @@ func main() {
 		return
 	}
 
+	if config.Settings.MigrateStatus {
+		os.Exit(printMigrationStatus())
+	}
+
 	// Configure log format
 	log.SetFlags(log.Ldate | log.Ltime | log.Lshortfile)
 	log.Println("System starting up...")
@@ -73,8 +83,44 @@ func main() {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
 
+	// Persist error logs and prune them according to retention settings.
+	core.ErrorLoggerInstance.SetStore(database.NewErrorLogStore(database.DB))
+	core.ErrorLoggerInstance.StartRetention()
+
+	// Periodic database snapshots (no-op unless DB_BACKUP_INTERVAL_MINUTES is set).
+	database.StartBackupScheduler()
+
+	// Persist per-mapping start/stop/failure history.
+	core.MappingEvents.SetStore(database.NewMappingEventStore(database.DB))
+
+	// Keep lifetime per-mapping traffic totals across restarts.
+	core.Usage.SetStore(database.NewMappingUsageStore(database.DB))
+	core.Usage.StartFlusher(time.Duration(config.Settings.UsageFlushIntervalSeconds) * time.Second)
+
 	// Start auditor
+	if err := core.AuditorInstance.SetTrustedProxies(config.Settings.AuditTrustedProxies); err != nil {
+		log.Fatalf("Invalid audit settings: %v", err)
+	}
+	if err := core.ValidateGlobalAuditSampleRate(config.Settings.AuditSampleRate); err != nil {
+		log.Fatalf("Invalid audit settings: %v", err)
+	}
 	core.AuditorInstance.Start()
+	if err := core.ValidateQueryRedact(config.Settings.QueryAuditRedact); err != nil {
+		log.Fatalf("Invalid QUERY_AUDIT_REDACT: %v", err)
+	}
+
+	// Start alert delivery (no-op until webhook/SMTP targets are configured)
+	core.AlerterInstance.Start()
+
+	// Start security event export (no-op unless EVENT_SINK_URL is set)
+	if err := core.EventExport.Start(); err != nil {
+		log.Printf("Security event export disabled: %v", err)
+	}
+
+	// Advertise exposed mappings on the LAN (no-op unless MDNS_ADVERTISE is set)
+	if err := core.MDNS.Start(); err != nil {
+		log.Printf("mDNS advertisement disabled: %v", err)
+	}
 
 	// Start SSH pool housekeeping (keepalive probes and idle reclamation).
 	core.Pool.StartHousekeeping()
@@ -82,11 +128,28 @@ func main() {
 	// Initialize services
 	service.InitServices(database.DB, state.Global, core.AuditorInstance)
 
+	// Join the active/standby pair (no-op unless HA_ROLE is set); a standby leaves the
+	// autostart mappings to the active until it takes over.
+	if err := service.GlobalServices.HA.Start(); err != nil {
+		log.Fatalf("Invalid high availability settings: %v", err)
+	}
+
+	// Run a workspace of a central instance (no-op unless AGENT_CENTRAL_URL is set); the agent
+	// starts the mappings it runs itself once it has synced them.
+	if err := service.GlobalServices.Agent.Start(); err != nil {
+		log.Fatalf("Invalid agent settings: %v", err)
+	}
+
 	// Auto-start mappings marked for autostart
-	if err := service.GlobalServices.Mapping.StartAutoStartMappings(); err != nil {
-		log.Printf("Warning: Failed to start auto-start mappings: %v", err)
+	if service.GlobalServices.HA.Active() && !service.GlobalServices.Agent.Enabled() {
+		if err := service.GlobalServices.Mapping.StartAutoStartMappings(); err != nil {
+			log.Printf("Warning: Failed to start auto-start mappings: %v", err)
+		}
 	}
 
+	// Background update checks (no-op unless UPDATE_CHECK_INTERVAL_MINUTES is set).
+	handlers.StartUpdateChecker()
+
 	// Start goroutine monitor
 	go monitorGoroutines()
 
@@ -102,15 +165,16 @@ func main() {
 	// Disable Gin color logs to avoid ANSI issues on Windows terminals
 	gin.DisableConsoleColor()
 
-	// Create router
-	r := gin.Default()
+	// Create router; every request gets an X-Request-ID that the access log includes
+	r := gin.New()
+	r.Use(handlers.RequestID(), gin.LoggerWithFormatter(handlers.LogFormatter), handlers.AccessLog(), handlers.Recovery())
 
 	// CORS middleware
 	r.Use(cors.New(cors.Config{
 		AllowAllOrigins:  true,
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
 		AllowHeaders:     []string{"*"},
-		ExposeHeaders:    []string{"Content-Length"},
+		ExposeHeaders:    []string{"Content-Length", "ETag", handlers.RequestIDHeader, "Deprecation", "Sunset", "Link"},
 		AllowCredentials: true,
 	}))
 
@@ -119,109 +183,254 @@ func main() {
 	if err != nil {
 		log.Fatalf("Failed to create static file system: %v", err)
 	}
-	r.StaticFS("/web", http.FS(staticFS))
+	staticAssets, err := handlers.NewStaticAssets(staticFS)
+	if err != nil {
+		log.Fatalf("Failed to hash static files: %v", err)
+	}
+	r.GET("/web/*filepath", staticAssets.Serve)
+	r.HEAD("/web/*filepath", staticAssets.Serve)
 
 	// Root path redirect
 	r.GET("/", func(c *gin.Context) {
 		c.Redirect(http.StatusMovedPermanently, "/web/index.html")
 	})
 
-	// Prometheus metrics (exposition format)
-	r.GET("/metrics", handlers.GetPrometheusMetrics)
+	switch config.Settings.APICompat {
+	case config.APICompatV1, config.APICompatNone:
+	default:
+		log.Fatalf("Invalid API compatibility %q: must be %s or %s", config.Settings.APICompat, config.APICompatV1, config.APICompatNone)
+	}
+
+	// Metrics routes have their own token/allowlist (METRICS_TOKEN, METRICS_ALLOW) so scrapers
+	// do not need the admin token; without it /api metrics keep requiring the admin token.
+	metricsAuth, err := handlers.NewMetricsAuth()
+	if err != nil {
+		log.Fatalf("Invalid metrics access settings: %v", err)
+	}
+	r.GET("/metrics", metricsAuth.Prometheus(), handlers.GetPrometheusMetrics)
+	r.GET("/api/v2/metrics", metricsAuth.API(), handlers.GetMetricsV2)
+	if config.Settings.APICompat == config.APICompatV1 {
+		r.GET("/api/metrics", handlers.DeprecatedV1(), metricsAuth.API(), handlers.GetMetricsV2)
+	}
+
+	// Go profiling routes, only with --debug-endpoints
+	if config.Settings.DebugEndpoints {
+		debug := r.Group("/debug/pprof", handlers.RequireAdminToken())
+		debug.GET("/*name", handlers.DebugPprof)
+		debug.POST("/symbol", handlers.DebugPprof)
+	}
+
+	// Rate limits are shared by /api and /api/v2 (API_RATE_PER_IP, API_CODE_RATE_PER_MINUTE)
+	mutationLimit := handlers.MutationRateLimit()
+	codeLimit := handlers.CodeRateLimit()
+
+	// Deprecated v1 routes, served by the v2 handlers, only with --api-compat=v1
+	if config.Settings.APICompat == config.APICompatV1 {
+		api := r.Group("/api")
+		api.Use(handlers.DeprecatedV1(), mutationLimit, handlers.RequireAdminToken(), handlers.ResolveWorkspace())
+
+		// First-run setup wizard routes
+		api.GET("/setup", handlers.GetSetupStatus)
+		api.GET("/setup/ssh-config", handlers.GetSetupSSHConfig)
+		api.POST("/setup/steps/:step", handlers.ApplySetupStep)
 
-	// API routes
-	api := r.Group("/api")
-	{
 		// Bastion routes
-		api.GET("/bastions", handlers.ListBastions)
-		api.POST("/bastions", handlers.CreateBastion)
-		api.PUT("/bastions/:id", handlers.UpdateBastion)
-		api.DELETE("/bastions/:id", handlers.DeleteBastion)
+		api.GET("/bastions", handlers.ListBastionsV2)
+		api.POST("/bastions", handlers.CreateBastionV2)
+		api.PUT("/bastions/:id", handlers.UpdateBastionV2)
+		api.DELETE("/bastions/:id", handlers.DeleteBastionV2)
 
 		// Mapping routes
-		api.GET("/mappings", handlers.ListMappings)
-		api.POST("/mappings", handlers.CreateMapping)
-		api.PUT("/mappings/:id", handlers.UpdateMapping)
-		api.DELETE("/mappings/:id", handlers.DeleteMapping)
-		api.POST("/mappings/:id/start", handlers.StartMapping)
-		api.POST("/mappings/:id/stop", handlers.StopMapping)
+		api.GET("/mappings", handlers.ListMappingsV2)
+		api.POST("/mappings", handlers.CreateMappingV2)
+		api.PUT("/mappings/:id", handlers.UpdateMappingV2)
+		api.DELETE("/mappings/:id", handlers.DeleteMappingV2)
+		api.POST("/mappings/:id/start", handlers.StartMappingV2)
+		api.POST("/mappings/:id/stop", handlers.StopMappingV2)
+		api.GET("/mappings/:id/events", handlers.GetMappingEventsV2)
 
 		// Stats routes
-		api.GET("/stats", handlers.GetStats)
+		api.GET("/stats", handlers.GetStatsV2)
 
 		// HTTP log routes
-		api.GET("/http-logs", handlers.GetHTTPLogs)
-		api.GET("/http-logs/:id", handlers.GetHTTPLogDetail)
-		api.DELETE("/http-logs", handlers.ClearHTTPLogs)
+		api.GET("/http-logs", handlers.GetHTTPLogsV2)
+		api.GET("/http-logs/:id", handlers.GetHTTPLogDetailV2)
+		api.DELETE("/http-logs", handlers.ClearHTTPLogsV2)
 
 		// Error log routes
-		api.GET("/error-logs", handlers.GetErrorLogs)
-		api.DELETE("/error-logs", handlers.ClearErrorLogs)
+		api.GET("/error-logs", handlers.GetErrorLogsV2)
+		api.DELETE("/error-logs", handlers.ClearErrorLogsV2)
+
+		// Alerting routes
+		api.GET("/alerts", handlers.GetAlertStatus)
+		api.POST("/alerts/test", handlers.TestAlert)
 
 		// System shutdown routes
-		api.POST("/shutdown/generate-code", handlers.GenerateShutdownCode)
-		api.POST("/shutdown/verify", handlers.VerifyAndShutdown)
+		api.POST("/shutdown/generate-code", codeLimit, handlers.GenerateShutdownCodeV2)
+		api.POST("/shutdown/verify", codeLimit, handlers.VerifyAndShutdownV2)
 
-		// Health and metrics routes
-		api.GET("/health", handlers.HealthCheck)
-		api.GET("/metrics", handlers.GetMetrics)
+		// Health route (metrics are registered above with their own guard)
+		api.GET("/health", handlers.HealthCheckV2)
 
 		// Self-update routes
-		api.GET("/update/check", handlers.CheckUpdate)
-		api.GET("/update/proxy", handlers.GetUpdateProxy)
-		api.POST("/update/proxy", handlers.SetUpdateProxy)
-		api.POST("/update/generate-code", handlers.GenerateUpdateCode)
-		api.POST("/update/apply", handlers.ApplyUpdate)
+		api.GET("/update/check", handlers.CheckUpdateV2)
+		api.GET("/update/proxy", handlers.GetUpdateProxyV2)
+		api.POST("/update/proxy", handlers.SetUpdateProxyV2)
+		api.POST("/update/generate-code", codeLimit, handlers.GenerateUpdateCodeV2)
+		api.POST("/update/apply", codeLimit, handlers.ApplyUpdateV2)
+		api.GET("/update/channel", handlers.GetUpdateChannel)
+		api.POST("/update/channel", handlers.SetUpdateChannel)
+		api.POST("/update/pin", handlers.PinUpdateRelease)
 	}
 
 	// API v2 routes
 	apiV2 := r.Group("/api/v2")
+	apiV2.Use(mutationLimit, handlers.RequireAdminToken(), handlers.ResolveWorkspace())
 	{
+		// First-run setup wizard routes
+		apiV2.GET("/setup", handlers.GetSetupStatus)
+		apiV2.GET("/setup/ssh-config", handlers.GetSetupSSHConfig)
+		apiV2.POST("/setup/steps/:step", handlers.ApplySetupStep)
+
+		// Workspace routes (bastions and mappings are scoped by the X-Bastion-Workspace header or ?workspace=)
+		apiV2.GET("/workspaces", handlers.ListWorkspacesV2)
+
+		// Network interfaces
+		apiV2.GET("/interfaces", handlers.ListInterfacesV2)
+
+		// Error catalog (data.error.code of error responses)
+		apiV2.GET("/error-codes", handlers.ListErrorCodesV2)
+
 		// Bastion routes
 		apiV2.GET("/bastions", handlers.ListBastionsV2)
 		apiV2.POST("/bastions", handlers.CreateBastionV2)
 		apiV2.PUT("/bastions/:id", handlers.UpdateBastionV2)
 		apiV2.DELETE("/bastions/:id", handlers.DeleteBastionV2)
+		apiV2.POST("/bastions/:id/rotate-credentials", handlers.RotateBastionCredentialsV2)
+		apiV2.POST("/bastions/:id/benchmark", handlers.BenchmarkBastionV2)
+		apiV2.GET("/bastions/:id/docker/containers", handlers.DockerContainersV2)
 
 		// Mapping routes
 		apiV2.GET("/mappings", handlers.ListMappingsV2)
 		apiV2.POST("/mappings", handlers.CreateMappingV2)
+		apiV2.POST("/mappings/bulk", handlers.BulkMappingsV2)
+		apiV2.POST("/mappings/stop-all", handlers.StopAllMappingsV2)
 		apiV2.PUT("/mappings/:id", handlers.UpdateMappingV2)
 		apiV2.DELETE("/mappings/:id", handlers.DeleteMappingV2)
+		apiV2.POST("/mappings/:id/rename", handlers.RenameMappingV2)
 		apiV2.POST("/mappings/:id/start", handlers.StartMappingV2)
 		apiV2.POST("/mappings/:id/stop", handlers.StopMappingV2)
+		apiV2.POST("/mappings/:id/dry-run", handlers.DryRunMappingV2)
+		apiV2.POST("/mappings/:id/expose", handlers.ExposeMappingV2)
+		apiV2.DELETE("/mappings/:id/expose", handlers.UnexposeMappingV2)
+		apiV2.GET("/mappings/:id/events", handlers.GetMappingEventsV2)
+
+		// Variables read by mapping target templates
+		apiV2.GET("/target-variables", handlers.ListTargetVariablesV2)
+		apiV2.PUT("/target-variables/:name", handlers.SetTargetVariableV2)
+		apiV2.DELETE("/target-variables/:name", handlers.DeleteTargetVariableV2)
+
+		// Declarative apply of bastions and mappings
+		apiV2.POST("/apply", handlers.ApplyV2)
+		// Conversion of running `ssh -L/-D` processes into bastions and mappings
+		apiV2.GET("/discovery/ssh", handlers.DiscoverSSHV2)
+		apiV2.POST("/discovery/ssh/import", handlers.ImportSSHV2)
 
 		// Stats routes
 		apiV2.GET("/stats", handlers.GetStatsV2)
+		apiV2.GET("/pool/chains", handlers.GetSSHPoolChainsV2)
 
 		// HTTP log routes
 		apiV2.GET("/http-logs", handlers.GetHTTPLogsV2)
+		apiV2.GET("/http-logs/compare", handlers.CompareHTTPLogsV2)
+		apiV2.GET("/http-logs/stream", handlers.StreamHTTPLogsV2)
+		apiV2.GET("/http-logs/aggregate", handlers.AggregateHTTPLogsV2)
 		apiV2.GET("/http-logs/:id", handlers.GetHTTPLogDetailV2)
 		apiV2.GET("/http-logs/:id/parts/:part", handlers.GetHTTPLogPartV2)
+		apiV2.POST("/http-logs/:id/replay", handlers.ReplayHTTPLogV2)
+		apiV2.GET("/http-logs/:id/curl", handlers.GetHTTPLogCurlV2)
 		apiV2.DELETE("/http-logs", handlers.ClearHTTPLogsV2)
 
 		// Error log routes
 		apiV2.GET("/error-logs", handlers.GetErrorLogsV2)
 		apiV2.DELETE("/error-logs", handlers.ClearErrorLogsV2)
 
+		// API access log routes
+		apiV2.GET("/access-logs", handlers.GetAccessLogsV2)
+		apiV2.DELETE("/access-logs", handlers.ClearAccessLogsV2)
+
+		// Database query audit log
+		apiV2.GET("/query-logs", handlers.GetQueryLogsV2)
+		apiV2.DELETE("/query-logs", handlers.ClearQueryLogsV2)
+
+		// Configuration audit trail
+		apiV2.GET("/config-audit", handlers.GetConfigAuditV2)
+
+		// Database maintenance routes
+		apiV2.POST("/db/backup", handlers.BackupDatabaseV2)
+		apiV2.POST("/db/vacuum", handlers.VacuumDatabaseV2)
+		apiV2.GET("/db/integrity", handlers.CheckDatabaseIntegrityV2)
+
+		// Alerting routes
+		apiV2.GET("/alerts", handlers.GetAlertStatus)
+		apiV2.POST("/alerts/test", handlers.TestAlert)
+
 		// System shutdown routes
-		apiV2.POST("/shutdown/generate-code", handlers.GenerateShutdownCodeV2)
-		apiV2.POST("/shutdown/verify", handlers.VerifyAndShutdownV2)
+		apiV2.POST("/shutdown/generate-code", codeLimit, handlers.GenerateShutdownCodeV2)
+		apiV2.POST("/shutdown/verify", codeLimit, handlers.VerifyAndShutdownV2)
 
-		// Health and metrics routes
+		// Health route (metrics are registered above with their own guard)
 		apiV2.GET("/health", handlers.HealthCheckV2)
-		apiV2.GET("/metrics", handlers.GetMetricsV2)
+
+		// Active/standby pair routes
+		apiV2.GET("/ha", handlers.GetHAStatusV2)
+		apiV2.POST("/ha/failover", handlers.HAFailoverV2)
+		apiV2.POST("/ha/promote", handlers.HAPromoteV2)
+		apiV2.POST("/ha/demote", handlers.HADemoteV2)
+
+		// Agent routes
+		apiV2.GET("/agents", handlers.ListAgentsV2)
+		apiV2.POST("/agents", handlers.IssueAgentTokenV2)
+		apiV2.POST("/agents/report", handlers.ReportAgentV2)
+		apiV2.DELETE("/agents/:name", handlers.DeleteAgentV2)
+
+		// Goroutine dump for leak investigations
+		apiV2.GET("/debug/goroutines", handlers.GetGoroutinesV2)
+		// Running mappings ranked by memory, traffic or goroutines
+		apiV2.GET("/debug/top", handlers.GetDebugTopV2)
+		// Environment self-check for support requests
+		apiV2.GET("/selfcheck", handlers.GetSelfCheckV2)
+		// Zip of logs, redacted config, definitions and metrics for bug reports
+		apiV2.POST("/diagnostics/bundle", handlers.DiagnosticsBundleV2)
+
+		// Embedded UI build, polled by the frontend to offer a reload after an update
+		apiV2.GET("/ui/version", staticAssets.UIVersionV2)
 
 		// Self-update routes
 		apiV2.GET("/update/check", handlers.CheckUpdateV2)
 		apiV2.GET("/update/proxy", handlers.GetUpdateProxyV2)
 		apiV2.POST("/update/proxy", handlers.SetUpdateProxyV2)
-		apiV2.POST("/update/generate-code", handlers.GenerateUpdateCodeV2)
-		apiV2.POST("/update/apply", handlers.ApplyUpdateV2)
+		apiV2.POST("/update/proxy/test", handlers.TestUpdateProxyV2)
+		apiV2.POST("/update/generate-code", codeLimit, handlers.GenerateUpdateCodeV2)
+		apiV2.POST("/update/apply", codeLimit, handlers.ApplyUpdateV2)
+		apiV2.GET("/update/status", handlers.GetUpdateStatusV2)
+		apiV2.GET("/update/rollback", handlers.GetUpdateRollbackV2)
+		apiV2.POST("/update/rollback/generate-code", codeLimit, handlers.GenerateRollbackCodeV2)
+		apiV2.POST("/update/rollback", codeLimit, handlers.RollbackUpdateV2)
+		apiV2.GET("/update/channel", handlers.GetUpdateChannel)
+		apiV2.POST("/update/channel", handlers.SetUpdateChannel)
+		apiV2.POST("/update/pin", handlers.PinUpdateRelease)
+		apiV2.GET("/update/releases", handlers.ListUpdateReleasesV2)
+	}
+
+	// Resolve bind address: flag/env first, then the setup wizard choice
+	bindAddr := config.Settings.BindAddress
+	if bindAddr == "" {
+		bindAddr = service.ResolveBindAddress("0.0.0.0")
 	}
 
 	// Find an available port
-	port := findAvailablePort(config.Settings.Port)
+	port := findAvailablePort(bindAddr, config.Settings.Port)
 	if port != config.Settings.Port {
 		log.Printf("Default port %d is busy. Switched to %d", config.Settings.Port, port)
 	}
@@ -231,16 +440,23 @@ func main() {
 	handlers.SetShutdownChannel(shutdownChan)
 
 	// Create HTTP server
-	addr := fmt.Sprintf("0.0.0.0:%d", port)
+	addr := net.JoinHostPort(bindAddr, strconv.Itoa(port))
 	srv := &http.Server{
 		Addr:    addr,
 		Handler: r,
 	}
 
+	// Loopback clients skip the admin token, so prefer it for the local browser when reachable
+	browseHost := "127.0.0.1"
+	if ip := net.ParseIP(bindAddr); ip != nil && !ip.IsUnspecified() && !ip.IsLoopback() {
+		browseHost = bindAddr
+	}
+	browseURL := fmt.Sprintf("http://%s/", net.JoinHostPort(browseHost, strconv.Itoa(port)))
+
 	// Start server in a goroutine
 	go func() {
-		log.Printf("Server starting on http://127.0.0.1:%d", port)
-		log.Printf("Open browser at: http://127.0.0.1:%d/", port)
+		log.Printf("Server starting on %s (bind %s)", browseURL, addr)
+		log.Printf("Open browser at: %s", browseURL)
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("Failed to start server: %v", err)
 		}
@@ -249,7 +465,7 @@ func main() {
 	// Optionally open browser automatically
 	go func() {
 		time.Sleep(1500 * time.Millisecond)
-		openBrowser(fmt.Sprintf("http://127.0.0.1:%d/", port))
+		openBrowser(browseURL)
 	}()
 
 	// Wait for OS interrupt or API-triggered shutdown
@@ -282,6 +498,15 @@ func main() {
 		state.Global.RemoveAndStopSession(id)
 	}
 
+	// Withdraw the remaining mDNS advertisements
+	core.MDNS.Stop()
+
+	// Save the final traffic readings before the database closes
+	core.Usage.Flush()
+
+	// Deliver the remaining security events
+	core.EventExport.Stop(5 * time.Second)
+
 	// Close all SSH connections
 	core.Pool.CloseAll()
 
@@ -341,6 +566,9 @@ func runSelfUpdateHelper(args []string) {
 	source := ""
 	cleanup := ""
 	helperLog := ""
+	previous := ""
+	previousVersion := ""
+	previousSchema := 0
 	parentPID := 0
 	restart := false
 	var restartArgs []string
@@ -374,6 +602,21 @@ func runSelfUpdateHelper(args []string) {
 				helperLog = args[i+1]
 				i++
 			}
+		case "--previous":
+			if i+1 < len(args) {
+				previous = args[i+1]
+				i++
+			}
+		case "--previous-version":
+			if i+1 < len(args) {
+				previousVersion = args[i+1]
+				i++
+			}
+		case "--previous-schema":
+			if i+1 < len(args) {
+				previousSchema = parseInt(args[i+1])
+				i++
+			}
 		case "--parent-pid":
 			if i+1 < len(args) {
 				parentPID = parseInt(args[i+1])
@@ -388,7 +631,7 @@ func runSelfUpdateHelper(args []string) {
 	}
 
 	appendHelperLogLine(helperLog, fmt.Sprintf("update-helper: argv=%v", os.Args))
-	appendHelperLogLine(helperLog, fmt.Sprintf("update-helper: parsed target=%q source=%q cleanup=%q parent_pid=%d restart=%v", target, source, cleanup, parentPID, restart))
+	appendHelperLogLine(helperLog, fmt.Sprintf("update-helper: parsed target=%q source=%q previous=%q cleanup=%q parent_pid=%d restart=%v", target, source, previous, cleanup, parentPID, restart))
 
 	if target == "" || source == "" {
 		appendHelperLogLine(helperLog, "update-helper: missing --target/--source, exiting")
@@ -413,9 +656,15 @@ func runSelfUpdateHelper(args []string) {
 	deadline := time.Now().Add(5 * time.Minute)
 	lastLog := time.Time{}
 	for {
-		if err := applyExecutableUpdate(target, source); err == nil {
+		if err := applyExecutableUpdate(target, source, previous); err == nil {
 			log.Printf("update-helper: replaced successfully")
 			appendHelperLogLine(helperLog, "update-helper: replaced successfully")
+			if previous != "" {
+				if err := handlers.SavePreviousBinaryInfo(previous, previousVersion, previousSchema); err != nil {
+					log.Printf("update-helper: save previous executable info failed: %v", err)
+				}
+				appendHelperLogLine(helperLog, fmt.Sprintf("update-helper: kept previous executable %s (version=%s)", previous, previousVersion))
+			}
 			break
 		} else if time.Since(lastLog) > 2*time.Second {
 			log.Printf("update-helper: replace failed (will retry): %v", err)
@@ -482,7 +731,7 @@ func setupHelperLogging(explicitPath, target string) func() {
 		if strings.TrimSpace(path) == "" {
 			continue
 		}
-		f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		f, err := core.OpenRotatingFile(path, core.LogRotationFromSettings(), false)
 		if err != nil {
 			continue
 		}
@@ -495,7 +744,9 @@ func setupHelperLogging(explicitPath, target string) func() {
 	return nil
 }
 
-func applyExecutableUpdate(target, source string) error {
+// applyExecutableUpdate replaces target with source. With a non-empty previous, the replaced
+// executable is moved there (for rollback) instead of being deleted.
+func applyExecutableUpdate(target, source, previous string) error {
 	fp, err := os.Open(source)
 	if err != nil {
 		return err
@@ -508,8 +759,9 @@ func applyExecutableUpdate(target, source string) error {
 	}
 
 	err = update.Apply(fp, update.Options{
-		TargetPath: target,
-		TargetMode: mode,
+		TargetPath:  target,
+		TargetMode:  mode,
+		OldSavePath: previous,
 	})
 	if err != nil {
 		if rerr := update.RollbackError(err); rerr != nil {
@@ -551,10 +803,10 @@ func appendHelperLogLine(path, msg string) {
 	_ = f.Close()
 }
 
-// findAvailablePort searches for an available port
-func findAvailablePort(startPort int) int {
+// findAvailablePort searches for an available port on host
+func findAvailablePort(host string, startPort int) int {
 	for port := startPort; port < startPort+100; port++ {
-		addr := fmt.Sprintf("0.0.0.0:%d", port)
+		addr := net.JoinHostPort(host, strconv.Itoa(port))
 		listener, err := net.Listen("tcp", addr)
 		if err == nil {
 			listener.Close()
@@ -604,6 +856,74 @@ func runCommand(name string, args ...string) error {
 	return nil
 }
 
+// printMigrationStatus prints applied and pending schema migrations and returns the exit code:
+// 0 when up to date, 1 when migrations are pending, 2 on error or a schema newer than the binary.
+func printMigrationStatus() int {
+	if err := database.OpenDB(); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to open database: %v\n", err)
+		return 2
+	}
+	defer database.CloseDB()
+
+	status, err := database.GetMigrationStatus(database.DB)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read migration status: %v\n", err)
+		return 2
+	}
+
+	fmt.Printf("Database: %s (%s)\n", database.DisplayURL(config.Settings.DatabaseURL), database.Backend())
+	fmt.Printf("Schema version: %d (binary supports %d)\n", status.Current, status.Latest)
+	for _, m := range status.Applied {
+		fmt.Printf("  [applied] %3d %-30s %s\n", m.Version, m.Name, m.AppliedAt.Format(time.RFC3339))
+	}
+	for _, m := range status.Pending {
+		fmt.Printf("  [pending] %3d %s\n", m.Version, m.Name)
+	}
+
+	switch {
+	case status.TooNew:
+		fmt.Println("The database schema is newer than this binary; upgrade bastion or restore a backup.")
+		return 2
+	case len(status.Pending) > 0:
+		fmt.Println("Pending migrations are applied on the next start.")
+		return 1
+	}
+	fmt.Println("Up to date.")
+	return 0
+}
+
+// printSelfCheck runs the self-checks against the configured database and prints the report. The
+// exit code is 0 when every check passed, 1 when some failed and 2 when the database cannot be opened.
+func printSelfCheck() int {
+	log.SetOutput(io.Discard)
+	if err := database.OpenDB(); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to open database: %v\n", err)
+		return 2
+	}
+	defer database.CloseDB()
+
+	report := service.NewSelfCheckService(database.DB, state.Global).Run()
+	for _, item := range report.Items {
+		status := "pass"
+		if !item.OK {
+			status = "FAIL"
+		}
+		line := fmt.Sprintf("  [%s] %-9s %s", status, item.Check, item.Target)
+		if item.Subject != "" {
+			line += " (" + item.Subject + ")"
+		}
+		if item.Detail != "" {
+			line += ": " + item.Detail
+		}
+		fmt.Println(line)
+	}
+	fmt.Printf("%d passed, %d failed\n", report.Passed, report.Failed)
+	if !report.OK {
+		return 1
+	}
+	return 0
+}
+
 // monitorGoroutines tracks goroutine count to prevent leaks
 func monitorGoroutines() {
 	ticker := time.NewTicker(time.Duration(config.Settings.GoroutineMonitorIntervalSeconds) * time.Second)
@@ -612,7 +932,13 @@ func monitorGoroutines() {
 	for range ticker.C {
 		count := runtime.NumGoroutine()
 		if count > config.Settings.GoroutineWarnThreshold {
-			log.Printf("WARNING: High goroutine count detected: %d", count)
+			log.Printf("WARNING: High goroutine count detected: %d (grouped stacks: GET /api/v2/debug/goroutines)", count)
+			core.AlerterInstance.Fire(core.AlertEvent{
+				Type:     core.AlertGoroutineWarning,
+				Severity: "WARN",
+				Message:  fmt.Sprintf("High goroutine count detected: %d", count),
+				Fields:   map[string]interface{}{"goroutines": count, "threshold": config.Settings.GoroutineWarnThreshold},
+			})
 		} else if config.Settings.LogLevel == "DEBUG" {
 			log.Printf("Current goroutine count: %d", count)
 		}
@@ -626,12 +952,19 @@ func mainCLI() {
 
 	// Fetch server address
 	serverURL := config.Settings.CLIServer
+	oneShot := config.Settings.CLIExec != ""
 
-	fmt.Printf("Bastion V3 CLI - Connecting to %s\n", serverURL)
+	if !oneShot {
+		fmt.Printf("Bastion V3 CLI - Connecting to %s\n", serverURL)
+	}
 
 	// Create HTTP client CLI instance
 	cliInstance, err := cli.NewCLIHttp(serverURL)
 	if err != nil {
+		if oneShot {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(cli.ExitFailed)
+		}
 		fmt.Printf("Error: %v\n", err)
 		fmt.Println("\nTips:")
 		fmt.Println("  1. Make sure the Bastion server is running:")
@@ -641,6 +974,10 @@ func mainCLI() {
 		os.Exit(1)
 	}
 
+	if oneShot {
+		os.Exit(cliInstance.Exec(config.Settings.CLIExec))
+	}
+
 	// Start CLI loop (readline handles Ctrl+C automatically)
 	cliInstance.Start()
 }
@@ -0,0 +1,75 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// AccessLog is one management API request as seen by the access log middleware. Status is the
+// HTTP status; Code is the envelope code of JSON responses (API errors are sent with status 200).
+type AccessLog struct {
+	ID        int64     `json:"id"`
+	Timestamp time.Time `json:"timestamp"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	Query     string    `json:"query,omitempty"`
+	Status    int       `json:"status"`
+	Code      string    `json:"code,omitempty"`
+	LatencyMS float64   `json:"latency_ms"`
+	ClientIP  string    `json:"client_ip"`
+	RequestID string    `json:"request_id,omitempty"`
+	Workspace string    `json:"workspace,omitempty"`
+	Bytes     int       `json:"bytes"`
+}
+
+// AccessLogFilter narrows access log queries. Zero values mean "no constraint".
+type AccessLogFilter struct {
+	Method       string  // exact, case-insensitive
+	Path         string  // case-insensitive substring of the path
+	Status       int     // exact HTTP status
+	Code         string  // envelope code, exact, case-insensitive
+	ErrorsOnly   bool    // a non-OK envelope code or a status >= 400
+	ClientIP     string  // exact
+	RequestID    string  // exact
+	MinLatencyMS float64 // at least this slow
+	Since        *time.Time
+	Until        *time.Time
+}
+
+// Matches reports whether entry satisfies the filter.
+func (f AccessLogFilter) Matches(entry *AccessLog) bool {
+	if entry == nil {
+		return false
+	}
+	if f.Method != "" && !strings.EqualFold(entry.Method, f.Method) {
+		return false
+	}
+	if f.Path != "" && !strings.Contains(strings.ToLower(entry.Path), strings.ToLower(f.Path)) {
+		return false
+	}
+	if f.Status != 0 && entry.Status != f.Status {
+		return false
+	}
+	if f.Code != "" && !strings.EqualFold(entry.Code, f.Code) {
+		return false
+	}
+	if f.ErrorsOnly && entry.Status < 400 && (entry.Code == "" || entry.Code == "OK") {
+		return false
+	}
+	if f.ClientIP != "" && entry.ClientIP != f.ClientIP {
+		return false
+	}
+	if f.RequestID != "" && entry.RequestID != f.RequestID {
+		return false
+	}
+	if entry.LatencyMS < f.MinLatencyMS {
+		return false
+	}
+	if f.Since != nil && entry.Timestamp.Before(*f.Since) {
+		return false
+	}
+	if f.Until != nil && entry.Timestamp.After(*f.Until) {
+		return false
+	}
+	return true
+}
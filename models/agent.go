@@ -0,0 +1,105 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Agent is a headless instance that runs the mappings of one workspace of this (central) instance
+// and reports their state back (AGENT_CENTRAL_URL on the agent).
+type Agent struct {
+	Name      string `gorm:"primaryKey;size:255" json:"name"`
+	Workspace string `gorm:"uniqueIndex;not null" json:"workspace"`
+	Address   string `json:"address"` // client IP of the last report
+	// ReportJSON holds the last report; Report mirrors it.
+	ReportJSON   string       `gorm:"column:report_json" json:"-"`
+	Report       *AgentReport `gorm:"-" json:"report,omitempty"`
+	RegisteredAt time.Time    `json:"registered_at"`
+	LastSeenAt   time.Time    `json:"last_seen_at"`
+	// Online is true while reports arrive: the last one is less than three intervals old.
+	Online bool `gorm:"-" json:"online"`
+	// TokenHash is the SHA-256 of the agent's token, which only reports the agent and reads its
+	// workspace; HasToken mirrors whether it is set.
+	TokenHash string `gorm:"column:token_sha256;index" json:"-"`
+	HasToken  bool   `gorm:"-" json:"has_token"`
+}
+
+// AgentTokenRequest names the agent a token is issued to and the workspace it may run.
+type AgentTokenRequest struct {
+	Name      string `json:"name"`
+	Workspace string `json:"workspace"`
+}
+
+// AgentToken is an issued agent token; the central instance only keeps its hash.
+type AgentToken struct {
+	Agent *Agent `json:"agent"`
+	Token string `json:"token"`
+}
+
+// BeforeSave encodes Report into ReportJSON.
+func (a *Agent) BeforeSave(tx *gorm.DB) error {
+	if a.Report == nil {
+		a.ReportJSON = ""
+		return nil
+	}
+	data, err := json.Marshal(a.Report)
+	if err != nil {
+		return err
+	}
+	a.ReportJSON = string(data)
+	return nil
+}
+
+// AfterFind decodes ReportJSON into Report and derives Online.
+func (a *Agent) AfterFind(tx *gorm.DB) error {
+	a.Report = nil
+	if a.ReportJSON != "" {
+		var report AgentReport
+		if err := json.Unmarshal([]byte(a.ReportJSON), &report); err != nil {
+			return err
+		}
+		a.Report = &report
+	}
+	a.Online = a.online(time.Now())
+	a.HasToken = a.TokenHash != ""
+	return nil
+}
+
+func (a *Agent) online(now time.Time) bool {
+	interval := 15 * time.Second
+	if a.Report != nil && a.Report.IntervalSeconds > 0 {
+		interval = time.Duration(a.Report.IntervalSeconds) * time.Second
+	}
+	return now.Sub(a.LastSeenAt) < 3*interval
+}
+
+// AgentReport is what an agent sends the central instance after every sync.
+type AgentReport struct {
+	Name            string    `json:"name"`
+	Workspace       string    `json:"workspace"`
+	Hostname        string    `json:"hostname"`
+	Version         string    `json:"version"`
+	Platform        string    `json:"platform"` // GOOS/GOARCH
+	StartedAt       time.Time `json:"started_at"`
+	IntervalSeconds int       `json:"interval_seconds"`
+	DBHealthy       bool      `json:"db_healthy"`
+	// SyncError is why the agent could not apply the workspace's configuration; it keeps running
+	// the previous one.
+	SyncError string               `json:"sync_error,omitempty"`
+	Mappings  []AgentMappingStatus `json:"mappings"`
+}
+
+// AgentMappingStatus is the state of one mapping on an agent.
+type AgentMappingStatus struct {
+	ID          string `json:"id"`
+	Running     bool   `json:"running"`
+	State       string `json:"state"`
+	LocalPort   int    `json:"local_port"` // the bound port while running
+	Connections int32  `json:"connections"`
+	BytesUp     int64  `json:"up_bytes"`
+	BytesDown   int64  `json:"down_bytes"`
+	// Error is why the agent could not start or stop the mapping as declared.
+	Error string `json:"error,omitempty"`
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAgentOnline(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name     string
+		lastSeen time.Duration
+		interval int
+		want     bool
+	}{
+		{"fresh report", 10 * time.Second, 0, true},
+		{"default interval, two missed", 44 * time.Second, 0, true},
+		{"default interval, three missed", 45 * time.Second, 0, false},
+		{"reported interval", 80 * time.Second, 30, true},
+		{"reported interval, three missed", 90 * time.Second, 30, false},
+	}
+	for _, tt := range tests {
+		agent := Agent{LastSeenAt: now.Add(-tt.lastSeen)}
+		if tt.interval > 0 {
+			agent.Report = &AgentReport{IntervalSeconds: tt.interval}
+		}
+		if got := agent.online(now); got != tt.want {
+			t.Errorf("%s: online = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -9,3 +9,11 @@ type AppSetting struct {
 	Value     string    `gorm:"type:text" json:"value"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
+
+// TargetVariable is a value mapping target templates read with {{setting "name"}}, stored as an
+// AppSetting.
+type TargetVariable struct {
+	Name      string    `json:"name"`
+	Value     string    `json:"value"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,45 @@
+package models
+
+// ApplyRequest declares the bastions and mappings a workspace should have (POST /api/v2/apply,
+// `bastion apply -f`). Applying it creates what is missing and updates what differs; with Prune it
+// also deletes the bastions and mappings it does not declare.
+type ApplyRequest struct {
+	Bastions []BastionCreate `json:"bastions"`
+	Mappings []MappingCreate `json:"mappings"`
+	Prune    bool            `json:"prune"`
+	// DryRun reports the changes without making them.
+	DryRun bool `json:"dry_run"`
+}
+
+// Apply actions
+const (
+	ApplyActionCreate    = "create"
+	ApplyActionUpdate    = "update"
+	ApplyActionDelete    = "delete"
+	ApplyActionUnchanged = "unchanged"
+	ApplyActionFailed    = "failed"
+)
+
+// ApplyChange is the planned change of one bastion or mapping
+type ApplyChange struct {
+	Resource string `json:"resource"` // bastion, mapping
+	Name     string `json:"name"`     // bastion name or mapping ID
+	Action   string `json:"action"`
+	// Diff lists the changed fields of an update, with secrets masked.
+	Diff  []ConfigChange `json:"diff,omitempty"`
+	Error string         `json:"error,omitempty"`
+}
+
+// ApplyResult is the diff between a workspace and an ApplyRequest. Changes lists bastions before
+// mappings, in request order, followed by the deletions of a prune.
+type ApplyResult struct {
+	DryRun    bool          `json:"dry_run"`
+	Prune     bool          `json:"prune"`
+	Committed bool          `json:"committed"` // false for dry runs and for applies with a failed change
+	Created   int           `json:"created"`
+	Updated   int           `json:"updated"`
+	Deleted   int           `json:"deleted"`
+	Unchanged int           `json:"unchanged"`
+	Failed    int           `json:"failed"`
+	Changes   []ApplyChange `json:"changes"`
+}
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Resources and actions recorded in the configuration audit trail.
+const (
+	ConfigAuditBastion = "bastion"
+	ConfigAuditMapping = "mapping"
+
+	ConfigAuditCreate   = "create"
+	ConfigAuditUpdate   = "update"
+	ConfigAuditDelete   = "delete"
+	ConfigAuditStart    = "start"
+	ConfigAuditStop     = "stop"
+	ConfigAuditExpose   = "expose"
+	ConfigAuditUnexpose = "unexpose"
+	ConfigAuditRename   = "rename"
+	ConfigAuditRotate   = "rotate"
+)
+
+// ConfigAudit records one change to a bastion or mapping: who made it, when, and the resource
+// before and after the change. Secrets are masked before they are recorded.
+type ConfigAudit struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	Timestamp  time.Time `gorm:"index" json:"timestamp"`
+	Workspace  string    `gorm:"size:64;index" json:"workspace"`
+	Resource   string    `gorm:"size:16;index" json:"resource"`     // bastion, mapping
+	ResourceID string    `gorm:"size:255;index" json:"resource_id"` // mapping ID or bastion name
+	Action     string    `gorm:"size:16;index" json:"action"`       // create, update, delete, start, stop, expose, unexpose, rename, rotate
+	Actor      string    `gorm:"size:255;index" json:"actor"`       // client IP, CLI user or "system"
+	Auth       string    `gorm:"size:16" json:"auth"`               // loopback, admin_token, none, cli, system
+	BeforeJSON string    `gorm:"column:before_json;type:text" json:"-"`
+	AfterJSON  string    `gorm:"column:after_json;type:text" json:"-"`
+	DiffJSON   string    `gorm:"column:diff_json;type:text" json:"-"`
+
+	// Before, After and Diff mirror the JSON columns; they are decoded after loading.
+	Before json.RawMessage `gorm:"-" json:"before"`
+	After  json.RawMessage `gorm:"-" json:"after"`
+	Diff   []ConfigChange  `gorm:"-" json:"diff"`
+}
+
+// ConfigChange is one field that differs between the before and after snapshots.
+type ConfigChange struct {
+	Field  string      `json:"field"`
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// AfterFind GORM hook - decode the JSON columns
+func (a *ConfigAudit) AfterFind(tx *gorm.DB) error {
+	a.Before = rawJSON(a.BeforeJSON)
+	a.After = rawJSON(a.AfterJSON)
+	a.Diff = []ConfigChange{}
+	if a.DiffJSON != "" {
+		_ = json.Unmarshal([]byte(a.DiffJSON), &a.Diff)
+	}
+	return nil
+}
+
+func rawJSON(data string) json.RawMessage {
+	if data == "" {
+		return json.RawMessage("null")
+	}
+	return json.RawMessage(data)
+}
+
+// ConfigAuditFilter narrows configuration audit queries. Zero values mean "no constraint".
+type ConfigAuditFilter struct {
+	Resource   string
+	ResourceID string
+	Action     string
+	Actor      string // exact match
+	Since      *time.Time
+	Until      *time.Time
+}
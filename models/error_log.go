@@ -1,15 +1,80 @@
 package models
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
 // ErrorLog model for error logs
 type ErrorLog struct {
-	ID        int       `json:"id"`
-	Timestamp time.Time `json:"timestamp"`
-	Level     string    `json:"level"`   // ERROR, WARN, FATAL
-	Source    string    `json:"source"`  // Error source (module name)
-	Message   string    `json:"message"` // Error message
-	Detail    string    `json:"detail"`  // Detailed information
-	Stack     string    `json:"stack"`   // Stack trace
-	Context   string    `json:"context"` // Context information (JSON format)
+	ID        int       `gorm:"primaryKey;autoIncrement" json:"id"`
+	Timestamp time.Time `gorm:"index" json:"timestamp"`
+	Level     string    `gorm:"size:16;index" json:"level"`  // DEBUG, INFO, WARN, ERROR, FATAL
+	Source    string    `gorm:"size:64;index" json:"source"` // Error source (module/component name)
+	Message   string    `json:"message"`                     // Error message
+	Detail    string    `gorm:"type:text" json:"detail"`     // Detailed information
+	Stack     string    `gorm:"type:text" json:"stack"`      // Stack trace
+	Context   string    `gorm:"type:text" json:"context"`    // Context information (JSON format)
+}
+
+// Error log severity levels, lowest first.
+var errorLogLevels = []string{"DEBUG", "INFO", "WARN", "ERROR", "FATAL"}
+
+// ErrorLogLevelRank returns the severity rank of level (higher is more severe), or -1 if unknown.
+func ErrorLogLevelRank(level string) int {
+	level = strings.ToUpper(strings.TrimSpace(level))
+	for i, l := range errorLogLevels {
+		if l == level {
+			return i
+		}
+	}
+	return -1
+}
+
+// ErrorLogFilter narrows error log queries. Zero values mean "no constraint".
+type ErrorLogFilter struct {
+	Levels   []string // exact level match (any of)
+	MinLevel string   // minimum severity, e.g. WARN matches WARN/ERROR/FATAL
+	Source   string   // component tag (exact, case-insensitive)
+	Query    string   // case-insensitive substring over message/detail/context
+	Since    *time.Time
+	Until    *time.Time
+}
+
+// Matches reports whether entry satisfies the filter (used by the in-memory fallback).
+func (f ErrorLogFilter) Matches(entry *ErrorLog) bool {
+	if entry == nil {
+		return false
+	}
+	if len(f.Levels) > 0 {
+		matched := false
+		for _, l := range f.Levels {
+			if strings.EqualFold(l, entry.Level) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if f.MinLevel != "" && ErrorLogLevelRank(entry.Level) < ErrorLogLevelRank(f.MinLevel) {
+		return false
+	}
+	if f.Source != "" && !strings.EqualFold(entry.Source, f.Source) {
+		return false
+	}
+	if f.Since != nil && entry.Timestamp.Before(*f.Since) {
+		return false
+	}
+	if f.Until != nil && entry.Timestamp.After(*f.Until) {
+		return false
+	}
+	if f.Query != "" {
+		q := strings.ToLower(f.Query)
+		return strings.Contains(strings.ToLower(entry.Message), q) ||
+			strings.Contains(strings.ToLower(entry.Detail), q) ||
+			strings.Contains(strings.ToLower(entry.Context), q)
+	}
+	return true
 }
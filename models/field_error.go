@@ -0,0 +1,23 @@
+package models
+
+import "fmt"
+
+// FieldError is a validation error about one request field, named by its JSON key, so API error
+// responses can point at the field to fix.
+type FieldError struct {
+	Field string
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// FieldErrorf formats a validation error about field.
+func FieldErrorf(field, format string, args ...interface{}) error {
+	return &FieldError{Field: field, Err: fmt.Errorf(format, args...)}
+}
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// Roles of an instance of an active/standby pair (HA_ROLE).
+const (
+	HARoleActive  = "active"  // runs the autostart mappings
+	HARoleStandby = "standby" // mirrors the active's configuration and takes over when it disappears
+)
+
+// HAStatus is the state of this instance in its active/standby pair.
+type HAStatus struct {
+	Enabled bool   `json:"enabled"`
+	Role    string `json:"role,omitempty"`
+	PeerURL string `json:"peer_url,omitempty"`
+
+	// The last health check of the peer; only the standby checks.
+	PeerHealthy    bool       `json:"peer_healthy"`
+	PeerRole       string     `json:"peer_role,omitempty"`
+	LastCheckAt    *time.Time `json:"last_check_at,omitempty"`
+	LastCheckError string     `json:"last_check_error,omitempty"`
+	FailedChecks   int        `json:"failed_checks"` // consecutive failed checks
+
+	// The last configuration sync from the active; only the standby syncs.
+	LastSyncAt    *time.Time `json:"last_sync_at,omitempty"`
+	LastSyncError string     `json:"last_sync_error,omitempty"`
+
+	// When and why this instance last changed its role.
+	RoleChangedAt *time.Time `json:"role_changed_at,omitempty"`
+	RoleReason    string     `json:"role_reason,omitempty"`
+}
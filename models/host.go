@@ -0,0 +1,94 @@
+package models
+
+import (
+	"net"
+	"strings"
+)
+
+// Listen families of a mapping (Mapping.ListenFamily).
+const (
+	ListenFamilyAuto = ""     // bind local_host as given
+	ListenFamilyDual = "dual" // bind local_host in both IPv4 and IPv6 (e.g. 127.0.0.1 and ::1)
+	ListenFamilyIPv4 = "ipv4" // IPv4 only
+	ListenFamilyIPv6 = "ipv6" // IPv6 only
+)
+
+// maxHostLen bounds host names (the DNS limit).
+const maxHostLen = 253
+
+// NormalizeHost trims a host and removes the brackets of an IPv6 literal, "[::1]" becoming "::1".
+// Addresses are stored without brackets and joined with net.JoinHostPort when a port is added.
+func NormalizeHost(host string) string {
+	host = strings.TrimSpace(host)
+	if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+		host = host[1 : len(host)-1]
+	}
+	return host
+}
+
+// ValidateHost checks a normalized host: an IPv4 or IPv6 address (link-local ones may carry a
+// "%zone") or a host name. field names the value in the error.
+func ValidateHost(field, host string) error {
+	if host == "" {
+		return FieldErrorf(field, "invalid %s: must not be empty", field)
+	}
+	if ip, _ := splitHostZone(host); net.ParseIP(ip) != nil {
+		return nil
+	}
+	if strings.Contains(host, ":") {
+		return FieldErrorf(field, "invalid %s %q: not a valid IPv6 address (give the port separately)", field, host)
+	}
+	if len(host) > maxHostLen {
+		return FieldErrorf(field, "invalid %s %q: must be at most %d characters", field, host, maxHostLen)
+	}
+	labels := strings.Split(strings.TrimSuffix(host, "."), ".")
+	if strings.Trim(labels[len(labels)-1], "0123456789") == "" {
+		// "300.1.2.3", "10.0.0": numeric but not an address
+		return FieldErrorf(field, "invalid %s %q: not a valid IP address or host name", field, host)
+	}
+	for _, label := range labels {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return FieldErrorf(field, "invalid %s %q: not a valid IP address or host name", field, host)
+		}
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+				return FieldErrorf(field, "invalid %s %q: not a valid IP address or host name", field, host)
+			}
+		}
+	}
+	return nil
+}
+
+// ValidateListenFamily checks a mapping's listen family against its local host: ipv4 and ipv6 need
+// a host of that family (or a name), and dual needs a host that has a counterpart in the other
+// family: a loopback or unspecified address, or a name.
+func ValidateListenFamily(family, localHost string) error {
+	ip := net.ParseIP(localHost)
+	switch family {
+	case ListenFamilyAuto:
+		return nil
+	case ListenFamilyIPv4:
+		if ip != nil && ip.To4() == nil {
+			return FieldErrorf("listen_family", "invalid listen_family %q: local_host %s is not an IPv4 address", family, localHost)
+		}
+	case ListenFamilyIPv6:
+		if ip != nil && ip.To4() != nil {
+			return FieldErrorf("listen_family", "invalid listen_family %q: local_host %s is not an IPv6 address", family, localHost)
+		}
+	case ListenFamilyDual:
+		if ip != nil && !ip.IsLoopback() && !ip.IsUnspecified() {
+			return FieldErrorf("listen_family", "invalid listen_family %q: local_host %s only exists in one family (use a loopback or unspecified address, or a host name)", family, localHost)
+		}
+	default:
+		return FieldErrorf("listen_family", "invalid listen_family %q: must be empty, dual, ipv4 or ipv6", family)
+	}
+	return nil
+}
+
+// splitHostZone splits "fe80::1%eth0" into the address and the zone.
+func splitHostZone(host string) (addr, zone string) {
+	if i := strings.LastIndexByte(host, '%'); i > 0 {
+		return host[:i], host[i+1:]
+	}
+	return host, ""
+}
@@ -0,0 +1,10 @@
+package models
+
+// HTTPReplayRequest is the payload for replaying a logged HTTP request.
+type HTTPReplayRequest struct {
+	// MappingID selects the mapping whose route is used; empty uses the mapping that logged the request
+	// (or, when it no longer exists, the bastion chain recorded on the log).
+	MappingID     string            `json:"mapping_id,omitempty"`
+	SetHeaders    map[string]string `json:"set_headers,omitempty"`
+	RemoveHeaders []string          `json:"remove_headers,omitempty"`
+}
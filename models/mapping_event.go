@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// MappingEvent records a lifecycle event of a mapping (start, stop, failures).
+type MappingEvent struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	MappingID string    `gorm:"size:255;index" json:"mapping_id"`
+	Timestamp time.Time `gorm:"index" json:"timestamp"`
+	Type      string    `gorm:"size:32" json:"type"` // start, start_failed, stop, dial_failed
+	Reason    string    `json:"reason"`
+	Detail    string    `gorm:"type:text" json:"detail,omitempty"`
+}
@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// MappingUsage holds the lifetime traffic counters of a mapping, keyed by its runtime key
+// (see Mapping.Key), so totals survive session restarts and daemon restarts.
+type MappingUsage struct {
+	MappingID     string     `gorm:"primaryKey;size:255" json:"mapping_id"`
+	BytesUp       int64      `json:"bytes_up"`
+	BytesDown     int64      `json:"bytes_down"`
+	LastStartedAt *time.Time `json:"last_started_at,omitempty"`
+	LastActiveAt  *time.Time `json:"last_active_at,omitempty"` // last flush that saw traffic or open connections
+	// Traffic (up and down) counted against the mapping's quota since QuotaPeriodStart
+	QuotaBytes       int64      `json:"quota_bytes"`
+	QuotaPeriodStart *time.Time `json:"quota_period_start,omitempty"`
+}
+
+// Quota actions: what happens to new connections once a mapping's quota is used up
+const (
+	QuotaActionRefuse   = "refuse"
+	QuotaActionThrottle = "throttle"
+)
+
+// MappingQuotaStatus is the state of a mapping's traffic quota in the current period
+type MappingQuotaStatus struct {
+	LimitBytes  int64     `json:"limit_bytes"`
+	UsedBytes   int64     `json:"used_bytes"`
+	Exceeded    bool      `json:"exceeded"`
+	Action      string    `json:"action"`
+	PeriodStart time.Time `json:"period_start"`
+	ResetAt     time.Time `json:"reset_at"`
+}
@@ -2,8 +2,10 @@ package models
 
 import (
 	"encoding/json"
-	"fmt"
+	"net"
+	"strconv"
 	"strings"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -11,39 +13,162 @@ import (
 // Bastion model
 type Bastion struct {
 	ID             uint   `gorm:"primaryKey" json:"id"`
-	Name           string `gorm:"uniqueIndex;not null" json:"name"`
+	Workspace      string `gorm:"uniqueIndex:idx_bastions_workspace_name;not null;default:'default'" json:"workspace"`
+	Name           string `gorm:"uniqueIndex:idx_bastions_workspace_name;not null" json:"name"`
 	Host           string `gorm:"not null" json:"host"`
 	Port           int    `gorm:"default:22" json:"port"`
 	Username       string `gorm:"not null" json:"username"`
 	Password       string `json:"password,omitempty"`
 	PkeyPath       string `json:"pkey_path,omitempty"`
 	PkeyPassphrase string `json:"pkey_passphrase,omitempty"`
+	// Algorithm overrides in the OpenSSH syntax (comma-separated, "+" appends to the defaults, see
+	// core.ParseSSHAlgorithms); empty keeps the defaults.
+	Ciphers      string `gorm:"column:ciphers" json:"ciphers,omitempty"`
+	MACs         string `gorm:"column:macs" json:"macs,omitempty"`
+	KeyExchanges string `gorm:"column:key_exchanges" json:"key_exchanges,omitempty"`
+	Description  string `gorm:"column:description" json:"description,omitempty"`
+	// TagsJSON stores the tags as JSON (see GetTags/SetTags); the API shows them as "tags".
+	TagsJSON string `gorm:"column:tags_json;default:'[]'" json:"-"`
+
+	// Version is incremented on every update; updates may require it to match (optimistic locking).
+	Version   int       `gorm:"column:version;not null;default:1" json:"version"`
+	UpdatedAt time.Time `gorm:"column:updated_at" json:"updated_at"`
 }
 
 // BastionCreate request payload for creating a bastion host
 type BastionCreate struct {
-	Name           string `json:"name"`
-	Host           string `json:"host" binding:"required"`
-	Port           int    `json:"port"`
-	Username       string `json:"username" binding:"required"`
+	Name           string   `json:"name"`
+	Host           string   `json:"host" binding:"required"`
+	Port           int      `json:"port"`
+	Username       string   `json:"username" binding:"required"`
+	Password       string   `json:"password"`
+	PkeyPath       string   `json:"pkey_path"`
+	PkeyPassphrase string   `json:"pkey_passphrase"`
+	Ciphers        string   `json:"ciphers,omitempty"`
+	MACs           string   `json:"macs,omitempty"`
+	KeyExchanges   string   `json:"key_exchanges,omitempty"`
+	Description    string   `json:"description"`
+	Tags           []string `json:"tags"`
+	// Version is the version the client last read; updates fail with a conflict when it is stale
+	// (0 skips the check).
+	Version int `json:"version,omitempty"`
+}
+
+// Normalize trims whitespace from input fields
+func (b *BastionCreate) Normalize() {
+	b.Name = strings.TrimSpace(b.Name)
+	b.Host = NormalizeHost(b.Host)
+	b.Username = strings.TrimSpace(b.Username)
+	b.Password = strings.TrimSpace(b.Password)
+	b.PkeyPath = strings.TrimSpace(b.PkeyPath)
+	b.PkeyPassphrase = strings.TrimSpace(b.PkeyPassphrase)
+	b.Ciphers = strings.TrimSpace(b.Ciphers)
+	b.MACs = strings.TrimSpace(b.MACs)
+	b.KeyExchanges = strings.TrimSpace(b.KeyExchanges)
+	b.Description = strings.TrimSpace(b.Description)
+	b.Tags = NormalizeTags(b.Tags)
+}
+
+// BastionCredentials request payload for rotating the credentials of a bastion. The password and
+// key fields replace the current ones as a set; an empty username keeps the current one.
+type BastionCredentials struct {
+	Username       string `json:"username"`
 	Password       string `json:"password"`
 	PkeyPath       string `json:"pkey_path"`
 	PkeyPassphrase string `json:"pkey_passphrase"`
+	// Via names the bastions the live test reaches the bastion through; empty derives them from
+	// the chain of a mapping that uses it (none when it is first in every chain).
+	Via []string `json:"via,omitempty"`
+	// Recycle closes the pooled chains through the bastion and restarts the running mappings that
+	// use it, so they reconnect with the new credentials.
+	Recycle bool `json:"recycle"`
+	// Version is the version the client last read (0 skips the check).
+	Version int `json:"version,omitempty"`
 }
 
 // Normalize trims whitespace from input fields
-func (b *BastionCreate) Normalize() {
-	b.Name = strings.TrimSpace(b.Name)
-	b.Host = strings.TrimSpace(b.Host)
+func (b *BastionCredentials) Normalize() {
 	b.Username = strings.TrimSpace(b.Username)
 	b.Password = strings.TrimSpace(b.Password)
 	b.PkeyPath = strings.TrimSpace(b.PkeyPath)
 	b.PkeyPassphrase = strings.TrimSpace(b.PkeyPassphrase)
+	via := make([]string, 0, len(b.Via))
+	for _, name := range b.Via {
+		if name = strings.TrimSpace(name); name != "" {
+			via = append(via, name)
+		}
+	}
+	b.Via = via
 }
 
+// BastionBenchmarkRequest request payload for measuring the speed of the chain to a bastion.
+// Zero values use the defaults of core.Benchmark.
+type BastionBenchmarkRequest struct {
+	// Bytes is the amount of data sent each way.
+	Bytes int64 `json:"bytes"`
+	// Pings is the number of latency samples.
+	Pings int `json:"pings"`
+	// Target is an echo endpoint (host:port) reached through the bastion; empty runs the transfers
+	// against cat and /dev/urandom on the bastion itself.
+	Target string `json:"target,omitempty"`
+	// Via names the bastions the benchmark reaches the bastion through; empty derives them from
+	// the chain of a mapping that uses it (none when it is first in every chain).
+	Via []string `json:"via,omitempty"`
+}
+
+// Normalize trims whitespace from input fields
+func (b *BastionBenchmarkRequest) Normalize() {
+	b.Target = strings.TrimSpace(b.Target)
+	via := make([]string, 0, len(b.Via))
+	for _, name := range b.Via {
+		if name = strings.TrimSpace(name); name != "" {
+			via = append(via, name)
+		}
+	}
+	b.Via = via
+}
+
+// BastionDockerRequest selects how the Docker containers on a bastion host are listed.
+type BastionDockerRequest struct {
+	// Source is "cli" (default) to run docker ps on the bastion, or "api" to query the Docker
+	// Engine API at Host through the chain.
+	Source string `json:"source" form:"source"`
+	// Host is the Docker Engine API address as seen from the bastion, unix:///path or
+	// tcp://host:port (default unix:///var/run/docker.sock).
+	Host string `json:"host" form:"host"`
+	// All includes stopped containers.
+	All bool `json:"all" form:"all"`
+	// Via names the bastions the bastion is reached through, like BastionBenchmarkRequest.Via.
+	Via []string `json:"via" form:"via"`
+}
+
+// Normalize trims whitespace from input fields and splits comma-separated via names
+func (b *BastionDockerRequest) Normalize() {
+	b.Source = strings.ToLower(strings.TrimSpace(b.Source))
+	b.Host = strings.TrimSpace(b.Host)
+	var via []string
+	for _, v := range b.Via {
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				via = append(via, name)
+			}
+		}
+	}
+	b.Via = via
+}
+
+// TLS modes of a tcp mapping (Mapping.TLSMode).
+const (
+	TLSModeNone      = ""          // forward bytes as they are
+	TLSModeTerminate = "terminate" // serve TLS to clients, forward plaintext to the remote
+	TLSModeOriginate = "originate" // accept plaintext from clients, speak TLS to the remote
+	TLSModeReencrypt = "reencrypt" // terminate and originate: TLS on both sides, plaintext in between
+)
+
 // Mapping port mapping model
 type Mapping struct {
 	ID         string `gorm:"primaryKey" json:"id"`
+	Workspace  string `gorm:"primaryKey;default:'default'" json:"workspace"`
 	LocalHost  string `gorm:"default:'127.0.0.1'" json:"local_host"`
 	LocalPort  int    `gorm:"not null" json:"local_port"`
 	RemoteHost string `json:"remote_host"`
@@ -51,8 +176,106 @@ type Mapping struct {
 	ChainJSON  string `gorm:"column:chain_json;default:'[]'" json:"-"`
 	AllowJSON  string `gorm:"column:allow_cidrs_json;default:'[]'" json:"-"`
 	DenyJSON   string `gorm:"column:deny_cidrs_json;default:'[]'" json:"-"`
-	Type       string `gorm:"default:'tcp'" json:"type"`
-	AutoStart  bool   `gorm:"default:false" json:"auto_start"`
+
+	// RemoteHost may be a target template ({{env "DB_HOST"}}, see core.ExpandTargetTemplate) and
+	// RemotePortTemplate replaces RemotePort with one; both are resolved when the mapping starts.
+	RemotePortTemplate string `gorm:"column:remote_port_template" json:"remote_port_template,omitempty"`
+
+	// DependsOnJSON lists, as JSON, the IDs of mappings in the same workspace that must be running
+	// before this one starts (see MappingService.Start and StartAutoStartMappings).
+	DependsOnJSON string `gorm:"column:depends_on_json;default:'[]'" json:"-"`
+
+	// Destination rules of proxy mappings (see core.TargetAccessControl), as JSON string lists.
+	TargetAllowJSON string `gorm:"column:target_allow_json;default:'[]'" json:"-"`
+	TargetDenyJSON  string `gorm:"column:target_deny_json;default:'[]'" json:"-"`
+	// Header rules of http/mixed mappings for forwarded requests (see core.HeaderRewriter), as a
+	// JSON string list.
+	RequestHeadersJSON string `gorm:"column:request_headers_json;default:'[]'" json:"-"`
+	Type               string `gorm:"default:'tcp'" json:"type"`
+	AutoStart          bool   `gorm:"default:false" json:"auto_start"`
+	// UpstreamProxy is an optional http:// or socks5:// proxy dialed after the bastion chain.
+	UpstreamProxy string `gorm:"column:upstream_proxy" json:"upstream_proxy,omitempty"`
+	// Per-client-IP limits: 0 uses the global default, -1 disables the limit.
+	MaxConnsPerIP  int     `gorm:"column:max_conns_per_ip;default:0" json:"max_conns_per_ip,omitempty"`
+	ConnRatePerIP  float64 `gorm:"column:conn_rate_per_ip;default:0" json:"conn_rate_per_ip,omitempty"`
+	ConnBurstPerIP int     `gorm:"column:conn_burst_per_ip;default:0" json:"conn_burst_per_ip,omitempty"`
+	// Dial policy overrides: zero values use the global defaults.
+	DialTimeoutSeconds int    `gorm:"column:dial_timeout_seconds;default:0" json:"dial_timeout_seconds,omitempty"`
+	DialRetries        int    `gorm:"column:dial_retries;default:0" json:"dial_retries,omitempty"`
+	DialRetryDelayMS   int    `gorm:"column:dial_retry_delay_ms;default:0" json:"dial_retry_delay_ms,omitempty"`
+	DialBackoff        string `gorm:"column:dial_backoff" json:"dial_backoff,omitempty"`
+	// DialHoldSeconds holds clients while a bastion chain that is down is re-established (0 uses the
+	// global DIAL_HOLD_SECONDS, -1 disables).
+	DialHoldSeconds int `gorm:"column:dial_hold_seconds;default:0" json:"dial_hold_seconds,omitempty"`
+	// DialHappyEyeballs resolves the remote host locally and races its addresses through the chain
+	// (also enabled for every mapping by DIAL_HAPPY_EYEBALLS).
+	DialHappyEyeballs bool `gorm:"column:dial_happy_eyeballs;default:false" json:"dial_happy_eyeballs,omitempty"`
+
+	// Audit overrides: AuditDisabled turns auditing off for this mapping; AuditSampleRate (0..1) is the
+	// fraction of connections audited, 0 uses the global AUDIT_SAMPLE_RATE.
+	AuditDisabled   bool    `gorm:"column:audit_disabled;default:false" json:"audit_disabled,omitempty"`
+	AuditSampleRate float64 `gorm:"column:audit_sample_rate;default:0" json:"audit_sample_rate,omitempty"`
+
+	// Standby keeps the listener bound but builds the SSH chain only when a client connects, and
+	// closes it after StandbyIdleSeconds without connections (0 uses the global STANDBY_IDLE_SECONDS).
+	Standby            bool `gorm:"column:standby;default:false" json:"standby,omitempty"`
+	StandbyIdleSeconds int  `gorm:"column:standby_idle_seconds;default:0" json:"standby_idle_seconds,omitempty"`
+
+	// QuotaBytesPerDay caps the traffic (up and down) of a day starting at QUOTA_RESET_HOUR; 0 means
+	// no quota. Once it is used up new connections are refused, or with QuotaAction "throttle" admitted
+	// at QuotaThrottleBps bytes per second each (0 uses the global QUOTA_THROTTLE_BPS).
+	QuotaBytesPerDay int64  `gorm:"column:quota_bytes_per_day;default:0" json:"quota_bytes_per_day,omitempty"`
+	QuotaAction      string `gorm:"column:quota_action" json:"quota_action,omitempty"`
+	QuotaThrottleBps int64  `gorm:"column:quota_throttle_bps;default:0" json:"quota_throttle_bps,omitempty"`
+
+	// ExposeAddr, when set, binds the listener to this non-loopback interface address (LAN,
+	// Tailscale, ...) instead of LocalHost. It is only changed through the expose endpoint, which
+	// requires confirmation; ExposedBy and ExposedAt record who enabled it.
+	ExposeAddr string     `gorm:"column:expose_addr" json:"expose_addr,omitempty"`
+	ExposedBy  string     `gorm:"column:exposed_by" json:"exposed_by,omitempty"`
+	ExposedAt  *time.Time `gorm:"column:exposed_at" json:"exposed_at,omitempty"`
+	// ListenFamily selects the address families the listener binds (ListenFamilyAuto, Dual, IPv4
+	// or IPv6); dual adds a second listener, e.g. on ::1 next to 127.0.0.1.
+	ListenFamily string `gorm:"column:listen_family" json:"listen_family,omitempty"`
+	// FTPHelper (tcp mappings only) rewrites the server's PASV/EPSV replies and forwards each data
+	// connection through the same chain, so passive FTP works without extra mappings.
+	FTPHelper bool `gorm:"column:ftp_helper;default:false" json:"ftp_helper,omitempty"`
+
+	// TLSMode (tcp mappings only) terminates TLS from clients with TLSCertFile/TLSKeyFile, and/or
+	// originates TLS toward the remote, verified against TLSCAFile (system roots when empty) for
+	// TLSServerName (RemoteHost when empty) unless TLSInsecureSkipVerify is set.
+	TLSMode               string `gorm:"column:tls_mode" json:"tls_mode,omitempty"`
+	TLSCertFile           string `gorm:"column:tls_cert_file" json:"tls_cert_file,omitempty"`
+	TLSKeyFile            string `gorm:"column:tls_key_file" json:"tls_key_file,omitempty"`
+	TLSServerName         string `gorm:"column:tls_server_name" json:"tls_server_name,omitempty"`
+	TLSCAFile             string `gorm:"column:tls_ca_file" json:"tls_ca_file,omitempty"`
+	TLSInsecureSkipVerify bool   `gorm:"column:tls_insecure_skip_verify;default:false" json:"tls_insecure_skip_verify,omitempty"`
+
+	// K8sKubeconfig, K8sContext and K8sNamespace (k8s mappings only) select the cluster whose API
+	// server port-forwards to RemoteHost (pod/NAME or svc/NAME) port RemotePort; empty values use
+	// $KUBECONFIG or ~/.kube/config, its current context and the context's namespace.
+	K8sKubeconfig string `gorm:"column:k8s_kubeconfig" json:"k8s_kubeconfig,omitempty"`
+	K8sContext    string `gorm:"column:k8s_context" json:"k8s_context,omitempty"`
+	K8sNamespace  string `gorm:"column:k8s_namespace" json:"k8s_namespace,omitempty"`
+
+	// QueryAudit (tcp and k8s mappings only), QueryAuditMySQL or QueryAuditPostgres, records the
+	// logins and statements of the mapping's clients in the query log (see core.QueryLogs).
+	QueryAudit string `gorm:"column:query_audit" json:"query_audit,omitempty"`
+
+	Description string `gorm:"column:description" json:"description,omitempty"`
+	TagsJSON    string `gorm:"column:tags_json;default:'[]'" json:"-"`
+
+	// Version is incremented on every update; updates may require it to match (optimistic locking).
+	Version   int       `gorm:"column:version;not null;default:1" json:"version"`
+	UpdatedAt time.Time `gorm:"column:updated_at" json:"updated_at"`
+}
+
+// ListenHost returns the address the mapping's listener binds to.
+func (m *Mapping) ListenHost() string {
+	if m.ExposeAddr != "" {
+		return m.ExposeAddr
+	}
+	return m.LocalHost
 }
 
 // GetChain returns the chain as a slice
@@ -96,26 +319,216 @@ func (m *Mapping) SetDenyCIDRs(cidrs []string) {
 	m.DenyJSON = string(data)
 }
 
+func (m *Mapping) GetTargetAllow() []string {
+	var rules []string
+	if m.TargetAllowJSON != "" {
+		_ = json.Unmarshal([]byte(m.TargetAllowJSON), &rules)
+	}
+	return rules
+}
+
+func (m *Mapping) SetTargetAllow(rules []string) {
+	data, _ := json.Marshal(rules)
+	m.TargetAllowJSON = string(data)
+}
+
+func (m *Mapping) GetTargetDeny() []string {
+	var rules []string
+	if m.TargetDenyJSON != "" {
+		_ = json.Unmarshal([]byte(m.TargetDenyJSON), &rules)
+	}
+	return rules
+}
+
+func (m *Mapping) SetTargetDeny(rules []string) {
+	data, _ := json.Marshal(rules)
+	m.TargetDenyJSON = string(data)
+}
+
+func (m *Mapping) GetRequestHeaders() []string {
+	var rules []string
+	if m.RequestHeadersJSON != "" {
+		_ = json.Unmarshal([]byte(m.RequestHeadersJSON), &rules)
+	}
+	return rules
+}
+
+func (m *Mapping) SetRequestHeaders(rules []string) {
+	data, _ := json.Marshal(rules)
+	m.RequestHeadersJSON = string(data)
+}
+
+// GetDependsOn returns the IDs of the mappings this one depends on
+func (m *Mapping) GetDependsOn() []string {
+	var ids []string
+	if m.DependsOnJSON != "" {
+		_ = json.Unmarshal([]byte(m.DependsOnJSON), &ids)
+	}
+	return ids
+}
+
+// SetDependsOn stores the dependency IDs as JSON
+func (m *Mapping) SetDependsOn(ids []string) {
+	data, _ := json.Marshal(ids)
+	m.DependsOnJSON = string(data)
+}
+
+// GetTags returns the tags as a slice
+func (m *Mapping) GetTags() []string {
+	return decodeTags(m.TagsJSON)
+}
+
+// SetTags stores the tags slice as JSON
+func (m *Mapping) SetTags(tags []string) {
+	m.TagsJSON = encodeTags(tags)
+}
+
 // MappingCreate request payload for creating a mapping
 type MappingCreate struct {
 	ID         string   `json:"id"`
 	LocalHost  string   `json:"local_host"`
-	LocalPort  int      `json:"local_port" binding:"required"`
+	LocalPort  int      `json:"local_port" binding:"min=0,max=65535"` // 0 picks a free port at start
 	RemoteHost string   `json:"remote_host"`
 	RemotePort int      `json:"remote_port"`
 	Chain      []string `json:"chain"`
 	AllowCIDRs []string `json:"allow_cidrs"`
 	DenyCIDRs  []string `json:"deny_cidrs"`
-	Type       string   `json:"type"`
-	AutoStart  bool     `json:"auto_start"`
+
+	// RemotePortTemplate, e.g. {{env "DB_PORT"}}, is resolved at start instead of RemotePort.
+	RemotePortTemplate string `json:"remote_port_template"`
+
+	// DependsOn names mappings of the same workspace to start before this one.
+	DependsOn []string `json:"depends_on"`
+
+	// TargetAllow and TargetDeny restrict the destinations of socks5/http/mixed mappings.
+	TargetAllow []string `json:"target_allow"`
+	TargetDeny  []string `json:"target_deny"`
+	Type        string   `json:"type"`
+	AutoStart   bool     `json:"auto_start"`
+
+	// RequestHeaders ("Name: value" sets, "-Name" strips) rewrite the plain HTTP requests of
+	// http/mixed mappings.
+	RequestHeaders []string `json:"request_headers"`
+
+	UpstreamProxy string `json:"upstream_proxy"`
+
+	MaxConnsPerIP  int     `json:"max_conns_per_ip"`
+	ConnRatePerIP  float64 `json:"conn_rate_per_ip"`
+	ConnBurstPerIP int     `json:"conn_burst_per_ip"`
+
+	DialTimeoutSeconds int    `json:"dial_timeout_seconds"`
+	DialRetries        int    `json:"dial_retries"`
+	DialRetryDelayMS   int    `json:"dial_retry_delay_ms"`
+	DialBackoff        string `json:"dial_backoff"`
+	DialHoldSeconds    int    `json:"dial_hold_seconds"`
+	DialHappyEyeballs  bool   `json:"dial_happy_eyeballs"`
+
+	AuditDisabled   bool    `json:"audit_disabled"`
+	AuditSampleRate float64 `json:"audit_sample_rate"`
+
+	Standby            bool `json:"standby"`
+	StandbyIdleSeconds int  `json:"standby_idle_seconds"`
+
+	QuotaBytesPerDay int64  `json:"quota_bytes_per_day"`
+	QuotaAction      string `json:"quota_action"`
+	QuotaThrottleBps int64  `json:"quota_throttle_bps"`
+
+	ListenFamily string `json:"listen_family"`
+
+	FTPHelper bool `json:"ftp_helper"`
+
+	TLSMode               string `json:"tls_mode"`
+	TLSCertFile           string `json:"tls_cert_file"`
+	TLSKeyFile            string `json:"tls_key_file"`
+	TLSServerName         string `json:"tls_server_name"`
+	TLSCAFile             string `json:"tls_ca_file"`
+	TLSInsecureSkipVerify bool   `json:"tls_insecure_skip_verify"`
+
+	K8sKubeconfig string `json:"k8s_kubeconfig"`
+	K8sContext    string `json:"k8s_context"`
+	K8sNamespace  string `json:"k8s_namespace"`
+
+	QueryAudit string `json:"query_audit"`
+
+	Description string   `json:"description"`
+	Tags        []string `json:"tags"`
+	// Version is the version the client last read; updates fail with a conflict when it is stale
+	// (0 skips the check).
+	Version int `json:"version,omitempty"`
+}
+
+// MappingBulkRequest creates or updates many mappings in one call
+type MappingBulkRequest struct {
+	Mappings []MappingCreate `json:"mappings"`
+	// Atomic applies all items in one transaction: when any item fails, none is applied.
+	Atomic bool `json:"atomic"`
+}
+
+// Bulk item actions
+const (
+	BulkActionCreated    = "created"
+	BulkActionUpdated    = "updated"
+	BulkActionFailed     = "failed"
+	BulkActionRolledBack = "rolled_back" // succeeded, but an atomic request was rolled back
+)
+
+// MappingBulkResult is the outcome of one item of a bulk request, in request order
+type MappingBulkResult struct {
+	Index   int    `json:"index"`
+	ID      string `json:"id,omitempty"`
+	Action  string `json:"action"`
+	Version int    `json:"version,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// MappingBulkResponse summarizes a bulk request
+type MappingBulkResponse struct {
+	Atomic    bool                `json:"atomic"`
+	Committed bool                `json:"committed"` // false when an atomic request was rolled back
+	Created   int                 `json:"created"`
+	Updated   int                 `json:"updated"`
+	Failed    int                 `json:"failed"`
+	Results   []MappingBulkResult `json:"results"`
+}
+
+// MappingStopResult is the outcome of stopping one running mapping of a stop-all request
+type MappingStopResult struct {
+	Workspace  string `json:"workspace"`
+	ID         string `json:"id"`
+	Stopped    bool   `json:"stopped"`
+	DurationMS int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+}
+
+// MappingStopAllResponse summarizes a stop-all request; results are ordered by workspace and ID
+type MappingStopAllResponse struct {
+	Stopped int                 `json:"stopped"`
+	Failed  int                 `json:"failed"`
+	Results []MappingStopResult `json:"results"`
 }
 
 // Normalize trims whitespace from input fields
 func (m *MappingCreate) Normalize() {
 	m.ID = strings.TrimSpace(m.ID)
-	m.LocalHost = strings.TrimSpace(m.LocalHost)
-	m.RemoteHost = strings.TrimSpace(m.RemoteHost)
+	m.LocalHost = NormalizeHost(m.LocalHost)
+	m.RemoteHost = NormalizeHost(m.RemoteHost)
+	m.RemotePortTemplate = strings.TrimSpace(m.RemotePortTemplate)
+	m.ListenFamily = strings.ToLower(strings.TrimSpace(m.ListenFamily))
 	m.Type = strings.TrimSpace(m.Type)
+	m.UpstreamProxy = strings.TrimSpace(m.UpstreamProxy)
+	m.DialBackoff = strings.ToLower(strings.TrimSpace(m.DialBackoff))
+	m.QuotaAction = strings.ToLower(strings.TrimSpace(m.QuotaAction))
+	m.TLSMode = strings.ToLower(strings.TrimSpace(m.TLSMode))
+	m.TLSCertFile = strings.TrimSpace(m.TLSCertFile)
+	m.TLSKeyFile = strings.TrimSpace(m.TLSKeyFile)
+	m.TLSServerName = strings.TrimSpace(m.TLSServerName)
+	m.TLSCAFile = strings.TrimSpace(m.TLSCAFile)
+	m.K8sKubeconfig = strings.TrimSpace(m.K8sKubeconfig)
+	m.K8sContext = strings.TrimSpace(m.K8sContext)
+	m.K8sNamespace = strings.TrimSpace(m.K8sNamespace)
+	m.QueryAudit = strings.ToLower(strings.TrimSpace(m.QueryAudit))
+	m.Description = strings.TrimSpace(m.Description)
+	m.Tags = NormalizeTags(m.Tags)
 
 	for i, name := range m.Chain {
 		m.Chain[i] = strings.TrimSpace(name)
@@ -134,27 +547,136 @@ func (m *MappingCreate) Normalize() {
 	}
 	m.AllowCIDRs = normalizeCIDRs(m.AllowCIDRs)
 	m.DenyCIDRs = normalizeCIDRs(m.DenyCIDRs)
+	m.TargetAllow = normalizeCIDRs(m.TargetAllow)
+	m.TargetDeny = normalizeCIDRs(m.TargetDeny)
+	m.RequestHeaders = normalizeCIDRs(m.RequestHeaders)
+	m.DependsOn = normalizeCIDRs(m.DependsOn)
 }
 
 // MappingRead response model for reading mappings
 type MappingRead struct {
-	ID         string   `json:"id"`
-	LocalHost  string   `json:"local_host"`
-	LocalPort  int      `json:"local_port"`
-	RemoteHost string   `json:"remote_host"`
-	RemotePort int      `json:"remote_port"`
-	Chain      []string `json:"chain"`
-	AllowCIDRs []string `json:"allow_cidrs"`
-	DenyCIDRs  []string `json:"deny_cidrs"`
-	Type       string   `json:"type"`
-	AutoStart  bool     `json:"auto_start"`
-	Running    bool     `json:"running"`
+	ID          string   `json:"id"`
+	Workspace   string   `json:"workspace"`
+	LocalHost   string   `json:"local_host"`
+	LocalPort   int      `json:"local_port"`
+	RemoteHost  string   `json:"remote_host"`
+	RemotePort  int      `json:"remote_port"`
+	Chain       []string `json:"chain"`
+	AllowCIDRs  []string `json:"allow_cidrs"`
+	DenyCIDRs   []string `json:"deny_cidrs"`
+	TargetAllow []string `json:"target_allow"`
+	TargetDeny  []string `json:"target_deny"`
+	Type        string   `json:"type"`
+	AutoStart   bool     `json:"auto_start"`
+	Running     bool     `json:"running"`
+
+	RemotePortTemplate string `json:"remote_port_template,omitempty"`
+
+	DependsOn []string `json:"depends_on,omitempty"`
+
+	RequestHeaders []string `json:"request_headers,omitempty"`
+
+	UpstreamProxy string `json:"upstream_proxy,omitempty"`
+
+	MaxConnsPerIP  int     `json:"max_conns_per_ip,omitempty"`
+	ConnRatePerIP  float64 `json:"conn_rate_per_ip,omitempty"`
+	ConnBurstPerIP int     `json:"conn_burst_per_ip,omitempty"`
+
+	DialTimeoutSeconds int    `json:"dial_timeout_seconds,omitempty"`
+	DialRetries        int    `json:"dial_retries,omitempty"`
+	DialRetryDelayMS   int    `json:"dial_retry_delay_ms,omitempty"`
+	DialBackoff        string `json:"dial_backoff,omitempty"`
+	DialHoldSeconds    int    `json:"dial_hold_seconds,omitempty"`
+	DialHappyEyeballs  bool   `json:"dial_happy_eyeballs,omitempty"`
+
+	AuditDisabled   bool    `json:"audit_disabled,omitempty"`
+	AuditSampleRate float64 `json:"audit_sample_rate,omitempty"`
+
+	Standby            bool `json:"standby,omitempty"`
+	StandbyIdleSeconds int  `json:"standby_idle_seconds,omitempty"`
+
+	QuotaBytesPerDay int64  `json:"quota_bytes_per_day,omitempty"`
+	QuotaAction      string `json:"quota_action,omitempty"`
+	QuotaThrottleBps int64  `json:"quota_throttle_bps,omitempty"`
+	// Quota is the state of the traffic quota in the current period (nil without a quota)
+	Quota *MappingQuotaStatus `json:"quota,omitempty"`
+	// State is "stopped", "running", or "standby" (listener bound, SSH chain not connected)
+	State string `json:"state"`
+	// RuntimePort is the port the running session is bound to (the OS-assigned one when LocalPort is 0)
+	RuntimePort int `json:"runtime_port,omitempty"`
+
+	ExposeAddr string     `json:"expose_addr,omitempty"`
+	ExposedBy  string     `json:"exposed_by,omitempty"`
+	ExposedAt  *time.Time `json:"exposed_at,omitempty"`
+
+	ListenFamily string `json:"listen_family,omitempty"`
+
+	FTPHelper bool `json:"ftp_helper,omitempty"`
+
+	TLSMode               string `json:"tls_mode,omitempty"`
+	TLSCertFile           string `json:"tls_cert_file,omitempty"`
+	TLSKeyFile            string `json:"tls_key_file,omitempty"`
+	TLSServerName         string `json:"tls_server_name,omitempty"`
+	TLSCAFile             string `json:"tls_ca_file,omitempty"`
+	TLSInsecureSkipVerify bool   `json:"tls_insecure_skip_verify,omitempty"`
+
+	K8sKubeconfig string `json:"k8s_kubeconfig,omitempty"`
+	K8sContext    string `json:"k8s_context,omitempty"`
+	K8sNamespace  string `json:"k8s_namespace,omitempty"`
+
+	QueryAudit string `json:"query_audit,omitempty"`
+
+	Description string   `json:"description,omitempty"`
+	Tags        []string `json:"tags"`
+
+	Version   int       `json:"version"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// Lifetime traffic across restarts (see MappingUsage)
+	TotalBytesUp   int64      `json:"total_bytes_up"`
+	TotalBytesDown int64      `json:"total_bytes_down"`
+	LastStartedAt  *time.Time `json:"last_started_at,omitempty"`
+	LastActiveAt   *time.Time `json:"last_active_at,omitempty"`
 }
 
 // BeforeCreate GORM hook - auto-generate name when missing
 func (b *Bastion) BeforeCreate(tx *gorm.DB) error {
 	if strings.TrimSpace(b.Name) == "" {
-		b.Name = fmt.Sprintf("%s:%d", strings.TrimSpace(b.Host), b.Port)
+		b.Name = net.JoinHostPort(strings.TrimSpace(b.Host), strconv.Itoa(b.Port))
+	}
+	return nil
+}
+
+// GetTags returns the tags as a slice
+func (b *Bastion) GetTags() []string {
+	return decodeTags(b.TagsJSON)
+}
+
+// SetTags stores the tags slice as JSON
+func (b *Bastion) SetTags(tags []string) {
+	b.TagsJSON = encodeTags(tags)
+}
+
+// bastionFields has the fields of Bastion without its JSON methods.
+type bastionFields Bastion
+
+// MarshalJSON encodes the bastion with its tags as a list.
+func (b Bastion) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		bastionFields
+		Tags []string `json:"tags"`
+	}{bastionFields(b), b.GetTags()})
+}
+
+// UnmarshalJSON decodes a bastion encoded by MarshalJSON.
+func (b *Bastion) UnmarshalJSON(data []byte) error {
+	v := struct {
+		*bastionFields
+		Tags []string `json:"tags"`
+	}{bastionFields: (*bastionFields)(b)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
 	}
+	b.SetTags(v.Tags)
 	return nil
 }
@@ -0,0 +1,100 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Query audit protocols of tcp and k8s mappings (Mapping.QueryAudit)
+const (
+	QueryAuditMySQL    = "mysql"
+	QueryAuditPostgres = "postgres"
+)
+
+// Query log entry kinds
+const (
+	QueryLogConnect = "connect" // the client logged in (User and Database are set)
+	QueryLogQuery   = "query"   // a simple query (MySQL COM_QUERY, PostgreSQL Query)
+	QueryLogPrepare = "prepare" // a prepared statement (MySQL COM_STMT_PREPARE, PostgreSQL Parse)
+	QueryLogUse     = "use"     // the default database changed (MySQL COM_INIT_DB)
+	QueryLogTLS     = "tls"     // the client switched to TLS; the rest of the connection is not audited
+)
+
+// QueryLog is one statement a client sent to a database through a mapping with query auditing.
+// Query is redacted as QUERY_AUDIT_REDACT says and cut at QUERY_AUDIT_MAX_BYTES (Truncated);
+// Bytes is the size of the statement as sent.
+type QueryLog struct {
+	ID         int64     `json:"id"`
+	Timestamp  time.Time `json:"timestamp"`
+	Workspace  string    `json:"workspace"`
+	MappingID  string    `json:"mapping_id"`
+	Protocol   string    `json:"protocol"`
+	ConnID     string    `json:"conn_id"`
+	ClientAddr string    `json:"client_addr"`
+	User       string    `json:"user,omitempty"`
+	Database   string    `json:"database,omitempty"`
+	Kind       string    `json:"kind"`
+	Query      string    `json:"query,omitempty"`
+	Bytes      int       `json:"bytes"`
+	Truncated  bool      `json:"truncated,omitempty"`
+}
+
+// QueryLogFilter narrows query log queries. Zero values mean "no constraint".
+type QueryLogFilter struct {
+	Workspace string // exact
+	MappingID string // exact
+	Protocol  string // exact
+	Kind      string // exact
+	User      string // exact
+	Database  string // exact
+	ClientIP  string // exact host of ClientAddr
+	Query     string // case-insensitive substring of the statement
+	Since     *time.Time
+	Until     *time.Time
+}
+
+// Matches reports whether entry satisfies the filter.
+func (f QueryLogFilter) Matches(entry *QueryLog) bool {
+	if entry == nil {
+		return false
+	}
+	if f.Workspace != "" && entry.Workspace != f.Workspace {
+		return false
+	}
+	if f.MappingID != "" && entry.MappingID != f.MappingID {
+		return false
+	}
+	if f.Protocol != "" && entry.Protocol != f.Protocol {
+		return false
+	}
+	if f.Kind != "" && entry.Kind != f.Kind {
+		return false
+	}
+	if f.User != "" && entry.User != f.User {
+		return false
+	}
+	if f.Database != "" && entry.Database != f.Database {
+		return false
+	}
+	if f.ClientIP != "" && clientAddrHost(entry.ClientAddr) != f.ClientIP {
+		return false
+	}
+	if f.Query != "" && !strings.Contains(strings.ToLower(entry.Query), strings.ToLower(f.Query)) {
+		return false
+	}
+	if f.Since != nil && entry.Timestamp.Before(*f.Since) {
+		return false
+	}
+	if f.Until != nil && entry.Timestamp.After(*f.Until) {
+		return false
+	}
+	return true
+}
+
+// clientAddrHost returns the host of a host:port client address.
+func clientAddrHost(addr string) string {
+	if i := strings.LastIndex(addr, ":"); i >= 0 {
+		return strings.Trim(addr[:i], "[]")
+	}
+	return addr
+}
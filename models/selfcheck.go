@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// Self-check categories (SelfCheckItem.Check).
+const (
+	SelfCheckDatabase = "database"  // the database accepts writes
+	SelfCheckLogFile  = "log_file"  // LOG_FILE can be appended to
+	SelfCheckKeyFile  = "key_file"  // a bastion private key or mapping TLS file is readable
+	SelfCheckPort     = "port"      // the listen port of an autostart mapping is free
+	SelfCheckFirstHop = "first_hop" // the first hop of a mapping resolves and accepts TCP
+)
+
+// SelfCheckItem is the result of one self-check.
+type SelfCheckItem struct {
+	Check      string `json:"check"`
+	Target     string `json:"target"`            // what was checked: a path, listen address or host:port
+	Subject    string `json:"subject,omitempty"` // the bastion or mapping it belongs to
+	OK         bool   `json:"ok"`
+	Detail     string `json:"detail,omitempty"` // the error, or a note on a pass
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// SelfCheckReport lists the self-checks of an installation, for diagnosing it and for support requests.
+type SelfCheckReport struct {
+	OK        bool            `json:"ok"` // true when every check passed
+	Passed    int             `json:"passed"`
+	Failed    int             `json:"failed"`
+	CheckedAt time.Time       `json:"checked_at"`
+	Items     []SelfCheckItem `json:"items"`
+}
@@ -0,0 +1,61 @@
+package models
+
+// Setup wizard steps, in order.
+const (
+	SetupStepImportSSHConfig = "import_ssh_config"
+	SetupStepBastion         = "bastion"
+	SetupStepMapping         = "mapping"
+	SetupStepAdminToken      = "admin_token"
+	SetupStepBindAddress     = "bind_address"
+	SetupStepDone            = "done"
+)
+
+// SetupSteps lists the first-run wizard steps in the order they must be applied.
+var SetupSteps = []string{
+	SetupStepImportSSHConfig,
+	SetupStepBastion,
+	SetupStepMapping,
+	SetupStepAdminToken,
+	SetupStepBindAddress,
+}
+
+// SetupStatus describes the first-run wizard state.
+type SetupStatus struct {
+	Needed        bool     `json:"needed"`    // true when the wizard should be shown
+	Completed     bool     `json:"completed"` // true once the final step was applied or skipped
+	Step          string   `json:"step"`      // current step (SetupStepDone when completed)
+	Steps         []string `json:"steps"`
+	BastionCount  int64    `json:"bastion_count"`
+	MappingCount  int64    `json:"mapping_count"`
+	AdminTokenSet bool     `json:"admin_token_set"`
+	BindAddress   string   `json:"bind_address,omitempty"`
+}
+
+// SetupStepRequest is the payload for applying a wizard step. Only the fields of the given step are used.
+type SetupStepRequest struct {
+	Skip bool `json:"skip"`
+
+	// import_ssh_config: aliases from ~/.ssh/config to import as bastions (empty imports all)
+	Hosts []string `json:"hosts,omitempty"`
+
+	// bastion / mapping
+	Bastion *BastionCreate `json:"bastion,omitempty"`
+	Mapping *MappingCreate `json:"mapping,omitempty"`
+
+	// admin_token: either an explicit token or generate_token=true
+	AdminToken    string `json:"admin_token,omitempty"`
+	GenerateToken bool   `json:"generate_token,omitempty"`
+
+	// bind_address: IP address for the management server (applied on next start)
+	BindAddress string `json:"bind_address,omitempty"`
+}
+
+// SetupStepResult is returned after a wizard step is applied.
+type SetupStepResult struct {
+	Status           SetupStatus `json:"status"`
+	ImportedBastions []string    `json:"imported_bastions,omitempty"`
+	BastionID        uint        `json:"bastion_id,omitempty"`
+	MappingID        string      `json:"mapping_id,omitempty"`
+	AdminToken       string      `json:"admin_token,omitempty"` // only returned when generated
+	RestartRequired  bool        `json:"restart_required,omitempty"`
+}
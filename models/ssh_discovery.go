@@ -0,0 +1,60 @@
+package models
+
+// SSH forward kinds
+const (
+	SSHForwardLocal   = "local"   // -L [bind:]port:host:hostport
+	SSHForwardDynamic = "dynamic" // -D [bind:]port
+	SSHForwardRemote  = "remote"  // -R, which Bastion cannot replace
+)
+
+// SSHForward is one port forward of an ssh command line.
+type SSHForward struct {
+	Kind        string `json:"kind"`
+	BindAddress string `json:"bind_address,omitempty"`
+	Port        int    `json:"port"`
+	RemoteHost  string `json:"remote_host,omitempty"`
+	RemotePort  int    `json:"remote_port,omitempty"`
+	Spec        string `json:"spec"` // as written on the command line
+}
+
+// SSHEndpoint is the destination or a jump host of an ssh command line, resolved through
+// ~/.ssh/config when it names a Host alias.
+type SSHEndpoint struct {
+	Alias        string `json:"alias,omitempty"` // ssh config Host the command named
+	Host         string `json:"host"`
+	Port         int    `json:"port"`
+	User         string `json:"user,omitempty"`
+	IdentityFile string `json:"identity_file,omitempty"`
+}
+
+// SSHProcess is a running ssh client that forwards ports.
+type SSHProcess struct {
+	PID         int           `json:"pid"`
+	CommandLine string        `json:"command_line"`
+	Destination SSHEndpoint   `json:"destination"`
+	JumpHosts   []SSHEndpoint `json:"jump_hosts,omitempty"` // -J / ProxyJump, first hop first
+	Forwards    []SSHForward  `json:"forwards"`
+}
+
+// SSHProcessCandidate is a discovered ssh process with the bastions and mappings that replace it.
+type SSHProcessCandidate struct {
+	SSHProcess
+	// Bastions lists the bastions of the chain that do not exist yet; existing ones are reused.
+	Bastions []BastionCreate `json:"bastions"`
+	Mappings []MappingCreate `json:"mappings"`
+	// Notes explain forwards and hosts that cannot be converted.
+	Notes []string `json:"notes,omitempty"`
+}
+
+// SSHDiscovery lists the ssh processes found on the server host (GET /api/v2/discovery/ssh).
+type SSHDiscovery struct {
+	Processes []SSHProcessCandidate `json:"processes"`
+	Source    string                `json:"source"` // how processes were listed: proc, ps, powershell
+	Error     string                `json:"error,omitempty"`
+}
+
+// SSHImportRequest selects the discovered ssh processes to convert (POST /api/v2/discovery/ssh/import).
+type SSHImportRequest struct {
+	PIDs   []int `json:"pids"` // empty converts all
+	DryRun bool  `json:"dry_run"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// SSHLatency summarizes the keepalive round-trip times of a pooled SSH chain, in milliseconds.
+type SSHLatency struct {
+	CurrentMS  float64    `json:"current_ms"`
+	AvgMS      float64    `json:"avg_ms"`
+	MaxMS      float64    `json:"max_ms"`
+	Samples    int        `json:"samples"`
+	HistoryMS  []float64  `json:"history_ms"` // oldest first
+	MeasuredAt *time.Time `json:"measured_at,omitempty"`
+}
+
+// SSHPoolChain is one pooled SSH chain (GET /api/v2/pool/chains).
+type SSHPoolChain struct {
+	Key         string     `json:"key"` // bastion keys joined by "->"
+	CreatedAt   time.Time  `json:"created_at"`
+	LastUsedAt  time.Time  `json:"last_used_at"`
+	ActiveConns int        `json:"active_conns"`
+	Dependents  int        `json:"dependents"` // pooled chains built on this one
+	Latency     SSHLatency `json:"latency"`
+}
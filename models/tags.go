@@ -0,0 +1,111 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Limits for the free-text notes of bastions and mappings.
+const (
+	maxDescriptionLen = 1000
+	maxTagLen         = 64
+	maxTags           = 32
+)
+
+// NormalizeTags trims tags and drops empty and duplicate (case-insensitive) ones, keeping order.
+func NormalizeTags(tags []string) []string {
+	out := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		key := strings.ToLower(tag)
+		if tag == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		out = append(out, tag)
+	}
+	return out
+}
+
+// ValidateNotes checks a description and normalized tags. Commas are rejected in tags because the
+// CLI and the tag query parameter use them as separators.
+func ValidateNotes(description string, tags []string) error {
+	if len(description) > maxDescriptionLen {
+		return fmt.Errorf("invalid description: must be at most %d characters", maxDescriptionLen)
+	}
+	if len(tags) > maxTags {
+		return fmt.Errorf("invalid tags: at most %d tags are allowed", maxTags)
+	}
+	for _, tag := range tags {
+		if len(tag) > maxTagLen {
+			return fmt.Errorf("invalid tag %q: must be at most %d characters", tag, maxTagLen)
+		}
+		if strings.Contains(tag, ",") {
+			return fmt.Errorf("invalid tag %q: ',' is not allowed", tag)
+		}
+	}
+	return nil
+}
+
+func encodeTags(tags []string) string {
+	if len(tags) == 0 {
+		return "[]"
+	}
+	data, _ := json.Marshal(tags)
+	return string(data)
+}
+
+func decodeTags(data string) []string {
+	tags := []string{}
+	if data != "" {
+		_ = json.Unmarshal([]byte(data), &tags)
+	}
+	return tags
+}
+
+// ListFilter narrows bastion and mapping lists. Query matches a case-insensitive substring of the
+// item's identifying fields, description or tags; every entry of Tags must be one of its tags
+// (compared case-insensitively).
+type ListFilter struct {
+	Query string
+	Tags  []string
+}
+
+// Empty reports whether the filter matches everything.
+func (f ListFilter) Empty() bool {
+	return strings.TrimSpace(f.Query) == "" && len(f.Tags) == 0
+}
+
+// Match reports whether an item with tags and the given searchable fields passes the filter.
+func (f ListFilter) Match(tags []string, fields ...string) bool {
+	for _, want := range f.Tags {
+		found := false
+		for _, tag := range tags {
+			if strings.EqualFold(tag, want) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	q := strings.ToLower(strings.TrimSpace(f.Query))
+	if q == "" {
+		return true
+	}
+	for _, field := range fields {
+		if strings.Contains(strings.ToLower(field), q) {
+			return true
+		}
+	}
+	for _, tag := range tags {
+		if strings.Contains(strings.ToLower(tag), q) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+package models
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBastionTagsJSON(t *testing.T) {
+	var b Bastion
+	b.Name = "jump"
+	b.SetTags([]string{"prod", "eu"})
+	data, err := json.Marshal(b)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if !strings.Contains(string(data), `"tags":["prod","eu"]`) || strings.Contains(string(data), "tags_json") {
+		t.Fatalf("marshal = %s", data)
+	}
+
+	var decoded Bastion
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if decoded.Name != "jump" || !reflect.DeepEqual(decoded.GetTags(), []string{"prod", "eu"}) {
+		t.Fatalf("unmarshal = %+v", decoded)
+	}
+	if err := json.Unmarshal([]byte(`{"name":"bare"}`), &decoded); err != nil || len(decoded.GetTags()) != 0 {
+		t.Fatalf("unmarshal without tags = %+v, %v", decoded.GetTags(), err)
+	}
+}
+
+func TestNormalizeTags(t *testing.T) {
+	tests := []struct {
+		in   []string
+		want []string
+	}{
+		{nil, []string{}},
+		{[]string{" prod ", "eu"}, []string{"prod", "eu"}},
+		{[]string{"Prod", "prod", "PROD", "eu"}, []string{"Prod", "eu"}},
+		{[]string{"", "  ", "db"}, []string{"db"}},
+		{[]string{"EU", " eu", "Db", "db "}, []string{"EU", "Db"}},
+	}
+	for _, tt := range tests {
+		if got := NormalizeTags(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("NormalizeTags(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestValidateNotes(t *testing.T) {
+	manyTags := make([]string, maxTags+1)
+	for i := range manyTags {
+		manyTags[i] = "t" + strings.Repeat("x", i)
+	}
+	tests := []struct {
+		name        string
+		description string
+		tags        []string
+		wantErr     bool
+	}{
+		{"empty", "", nil, false},
+		{"within limits", strings.Repeat("d", maxDescriptionLen), []string{strings.Repeat("t", maxTagLen)}, false},
+		{"long description", strings.Repeat("d", maxDescriptionLen+1), nil, true},
+		{"long tag", "", []string{strings.Repeat("t", maxTagLen+1)}, true},
+		{"too many tags", "", manyTags, true},
+		{"comma in tag", "", []string{"a,b"}, true},
+	}
+	for _, tt := range tests {
+		if err := ValidateNotes(tt.description, tt.tags); (err != nil) != tt.wantErr {
+			t.Errorf("%s: ValidateNotes = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestListFilterMatch(t *testing.T) {
+	tags := []string{"Prod", "eu"}
+	fields := []string{"jump-1", "10.0.0.1", "Primary jump host"}
+	tests := []struct {
+		name   string
+		filter ListFilter
+		want   bool
+	}{
+		{"empty", ListFilter{}, true},
+		{"query in a field", ListFilter{Query: "JUMP-"}, true},
+		{"query in the description", ListFilter{Query: " primary "}, true},
+		{"query in a tag", ListFilter{Query: "pro"}, true},
+		{"query missing", ListFilter{Query: "staging"}, false},
+		{"tag, other case", ListFilter{Tags: []string{"prod"}}, true},
+		{"all tags", ListFilter{Tags: []string{"prod", "EU"}}, true},
+		{"one tag missing", ListFilter{Tags: []string{"prod", "us"}}, false},
+		{"tag is not a substring match", ListFilter{Tags: []string{"pro"}}, false},
+		{"tag and query", ListFilter{Query: "10.0", Tags: []string{"eu"}}, true},
+		{"tag matches, query not", ListFilter{Query: "staging", Tags: []string{"eu"}}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.filter.Match(tags, fields...); got != tt.want {
+			t.Errorf("%s: Match = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
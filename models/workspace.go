@@ -0,0 +1,70 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultWorkspace holds bastions and mappings created without an explicit workspace.
+const DefaultWorkspace = "default"
+
+// maxWorkspaceNameLen bounds workspace names (they are part of runtime keys and log lines).
+const maxWorkspaceNameLen = 64
+
+// WorkspaceSummary describes one workspace and how much it contains.
+type WorkspaceSummary struct {
+	Name     string `json:"name"`
+	Bastions int64  `json:"bastions"`
+	Mappings int64  `json:"mappings"`
+	Running  int    `json:"running"`
+}
+
+// NormalizeWorkspace trims name and maps an empty name to DefaultWorkspace.
+func NormalizeWorkspace(name string) string {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return DefaultWorkspace
+	}
+	return name
+}
+
+// ValidateWorkspaceName accepts letters, digits, '-', '_' and '.' (no '/', which separates runtime keys).
+func ValidateWorkspaceName(name string) error {
+	if name == "" || len(name) > maxWorkspaceNameLen {
+		return fmt.Errorf("invalid workspace %q: must be 1-%d characters", name, maxWorkspaceNameLen)
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.') {
+			return fmt.Errorf("invalid workspace %q: only letters, digits, '-', '_' and '.' are allowed", name)
+		}
+	}
+	return nil
+}
+
+// WorkspaceKey qualifies name (a mapping ID or bastion name) with its workspace for use in
+// daemon-wide runtime state: sessions, the SSH pool, events and audit logs. Names in the default
+// workspace stay unqualified so existing keys and logs keep their meaning.
+func WorkspaceKey(workspace, name string) string {
+	if workspace == "" || workspace == DefaultWorkspace {
+		return name
+	}
+	return workspace + "/" + name
+}
+
+// SplitWorkspaceKey reverses WorkspaceKey.
+func SplitWorkspaceKey(key string) (workspace, name string) {
+	if ws, rest, ok := strings.Cut(key, "/"); ok {
+		return ws, rest
+	}
+	return DefaultWorkspace, key
+}
+
+// Key returns the daemon-wide runtime key of the mapping.
+func (m *Mapping) Key() string {
+	return WorkspaceKey(m.Workspace, m.ID)
+}
+
+// Key returns the daemon-wide key of the bastion (used by the SSH pool).
+func (b *Bastion) Key() string {
+	return WorkspaceKey(b.Workspace, b.Name)
+}
@@ -0,0 +1,264 @@
+package service
+
+import (
+	"bastion/bastionclient"
+	"bastion/config"
+	"bastion/core"
+	"bastion/database"
+	"bastion/models"
+	"bastion/version"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"time"
+)
+
+// agentSyncActor is recorded in the configuration audit trail for changes copied from the central
+// instance.
+var agentSyncActor = Actor{Name: "agent-sync", Auth: "system"}
+
+// AgentRunner runs this instance as an agent of a central instance (AGENT_CENTRAL_URL). Every
+// AGENT_INTERVAL_SECONDS it copies the bastions and mappings of its workspace there into the same
+// workspace here, runs exactly the mappings marked auto-start and reports their state back, so the
+// central Web UI manages the agent's tunnels.
+type AgentRunner struct {
+	mapping   *MappingService
+	central   *bastionclient.Client
+	name      string
+	workspace string
+	interval  time.Duration
+	startedAt time.Time
+
+	// State of the sync loop. errors holds why mappings could not be started or stopped as
+	// declared, by mapping ID; the last failures are kept so a central instance that is down is
+	// logged once.
+	errors                     map[string]string
+	lastSyncErr, lastReportErr string
+}
+
+// NewAgentRunner creates the agent runner of settings; it does nothing until Start.
+func NewAgentRunner(mapping *MappingService, settings *config.Config) *AgentRunner {
+	a := &AgentRunner{
+		mapping:   mapping,
+		name:      settings.AgentName,
+		workspace: settings.AgentWorkspace,
+		interval:  time.Duration(settings.AgentIntervalSec) * time.Second,
+		startedAt: time.Now(),
+	}
+	if a.interval <= 0 {
+		a.interval = 15 * time.Second
+	}
+	if a.name == "" {
+		a.name, _ = os.Hostname()
+	}
+	if a.workspace == "" {
+		a.workspace = a.name
+	}
+	if settings.AgentCentralURL != "" {
+		a.central = bastionclient.New(settings.AgentCentralURL)
+		a.central.AdminToken = settings.AgentToken
+	}
+	return a
+}
+
+// Enabled reports whether this instance is an agent.
+func (a *AgentRunner) Enabled() bool {
+	return a.central != nil
+}
+
+// Start validates the agent settings and starts syncing with the central instance. It is a no-op
+// unless AGENT_CENTRAL_URL is set.
+func (a *AgentRunner) Start() error {
+	if !a.Enabled() {
+		return nil
+	}
+	if a.name == "" {
+		return errors.New("AGENT_NAME is required: the host name is unknown")
+	}
+	if err := models.ValidateWorkspaceName(a.workspace); err != nil {
+		return fmt.Errorf("AGENT_WORKSPACE: %w", err)
+	}
+	log.Printf("Agent mode: running workspace %s of %s as agent %s", a.workspace, a.central.BaseURL, a.name)
+	go a.run()
+	return nil
+}
+
+func (a *AgentRunner) run() {
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+	for {
+		a.syncOnce()
+		<-ticker.C
+	}
+}
+
+// syncOnce applies the central configuration, runs the declared mappings and reports them.
+func (a *AgentRunner) syncOnce() {
+	ctx, cancel := context.WithTimeout(context.Background(), haSyncTimeout)
+	defer cancel()
+
+	declared, syncErr := a.sync(ctx)
+	a.logChange(&a.lastSyncErr, syncErr, "failed to sync workspace "+a.workspace+" from "+a.central.BaseURL, "synced workspace "+a.workspace)
+	a.reconcile(declared)
+	reportErr := a.report(ctx, syncErr)
+	a.logChange(&a.lastReportErr, reportErr, "failed to report to "+a.central.BaseURL, "reporting to "+a.central.BaseURL)
+}
+
+// logChange logs err when it differs from the last one, and the recovery when it is gone.
+func (a *AgentRunner) logChange(last *string, err error, failure, recovery string) {
+	msg := ""
+	if err != nil {
+		msg = err.Error()
+	}
+	if msg == *last {
+		return
+	}
+	switch {
+	case err != nil:
+		log.Printf("Agent mode: %s: %v", failure, err)
+	case *last != "":
+		log.Printf("Agent mode: %s again", recovery)
+	}
+	*last = msg
+}
+
+// sync applies the central configuration of the workspace here and returns the declared mapping
+// IDs (nil when it could not be read). Running mappings cannot be changed or deleted, so the ones
+// the change needs are stopped and the change is applied again; reconcile restarts them.
+func (a *AgentRunner) sync(ctx context.Context) (map[string]bool, error) {
+	central := *a.central
+	central.Workspace = a.workspace
+	req, err := remoteConfig(ctx, &central)
+	if err != nil {
+		return nil, err
+	}
+	declared := make(map[string]bool, len(req.Mappings))
+	for _, m := range req.Mappings {
+		declared[m.ID] = true
+	}
+	if len(req.Bastions) == 0 && len(req.Mappings) == 0 {
+		// An apply cannot prune to nothing; reconcile stops the mappings instead.
+		return declared, nil
+	}
+
+	svc := a.mapping.InWorkspace(a.workspace).As(agentSyncActor)
+	result, err := svc.Apply(req)
+	if err != nil {
+		return declared, err
+	}
+	if result.Failed > 0 && a.stopBlocking(svc, result) {
+		if result, err = svc.Apply(req); err != nil {
+			return declared, err
+		}
+	}
+	for _, change := range result.Changes {
+		if change.Action == models.ApplyActionFailed {
+			return declared, fmt.Errorf("%s %s: %s (nothing was changed)", change.Resource, change.Name, change.Error)
+		}
+	}
+	return declared, nil
+}
+
+// stopBlocking stops the running mappings whose change failed and reports whether it stopped any.
+func (a *AgentRunner) stopBlocking(svc *MappingService, result *models.ApplyResult) bool {
+	stopped := false
+	for _, change := range result.Changes {
+		if change.Action != models.ApplyActionFailed || change.Resource != models.ConfigAuditMapping || !svc.IsRunning(change.Name) {
+			continue
+		}
+		if err := svc.Stop(change.Name); err == nil {
+			stopped = true
+		}
+	}
+	return stopped
+}
+
+// reconcile runs exactly the mappings of the workspace marked auto-start (and declared, when the
+// central configuration could be read), starting them in dependency order.
+func (a *AgentRunner) reconcile(declared map[string]bool) {
+	svc := a.mapping.InWorkspace(a.workspace).As(agentSyncActor)
+	mappings, err := svc.List()
+	if err != nil {
+		log.Printf("Agent mode: %v", err)
+		return
+	}
+
+	failures := make(map[string]string)
+	deps := make(map[string][]string)
+	for _, m := range mappings {
+		switch {
+		case m.AutoStart && (declared == nil || declared[m.ID]):
+			deps[m.ID] = m.DependsOn
+		case m.Running:
+			if err := svc.Stop(m.ID); err != nil && !errors.Is(err, ErrMappingNotRunning) {
+				failures[m.ID] = err.Error()
+			}
+		}
+	}
+
+	order, _ := core.OrderByDependencies(deps)
+	ordered := make(map[string]bool, len(order))
+	failed := make(map[string]bool)
+	for _, id := range order {
+		ordered[id] = true
+		if dep := firstFailed(deps[id], failed); dep != "" {
+			failed[id] = true
+			failures[id] = fmt.Sprintf("dependency %s did not start", dep)
+			continue
+		}
+		if err := svc.Start(id); err != nil && !errors.Is(err, ErrMappingAlreadyRunning) {
+			failed[id] = true
+			failures[id] = err.Error()
+		}
+	}
+	for id := range deps {
+		if !ordered[id] {
+			failures[id] = "it is part of or depends on a dependency cycle"
+		}
+	}
+
+	a.errors = failures
+}
+
+// report sends the state of the workspace's mappings to the central instance.
+func (a *AgentRunner) report(ctx context.Context, syncErr error) error {
+	svc := a.mapping.InWorkspace(a.workspace)
+	mappings, err := svc.List()
+	if err != nil {
+		return err
+	}
+	stats := svc.GetStats()
+
+	hostname, _ := os.Hostname()
+	report := models.AgentReport{
+		Name:            a.name,
+		Workspace:       a.workspace,
+		Hostname:        hostname,
+		Version:         version.GetFullVersion(),
+		Platform:        runtime.GOOS + "/" + runtime.GOARCH,
+		StartedAt:       a.startedAt,
+		IntervalSeconds: int(a.interval / time.Second),
+		DBHealthy:       database.Up(ctx),
+		Mappings:        make([]models.AgentMappingStatus, 0, len(mappings)),
+	}
+	if syncErr != nil {
+		report.SyncError = syncErr.Error()
+	}
+	for _, m := range mappings {
+		status := models.AgentMappingStatus{ID: m.ID, Running: m.Running, State: m.State, Error: a.errors[m.ID]}
+		if s, ok := stats[m.ID]; ok {
+			status.LocalPort = s.LocalPort
+			status.Connections = s.ActiveConns
+			status.BytesUp, status.BytesDown = s.BytesUp, s.BytesDown
+		}
+		report.Mappings = append(report.Mappings, status)
+	}
+
+	central := *a.central
+	central.Workspace = a.workspace
+	_, err = central.ReportAgent(ctx, report)
+	return err
+}
@@ -0,0 +1,169 @@
+package service
+
+import (
+	"bastion/models"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Agent registry errors
+var (
+	ErrAgentNotFound       = errors.New("agent not found")
+	ErrAgentWorkspaceTaken = errors.New("workspace is run by another agent")
+	ErrInvalidAgentReport  = errors.New("invalid agent report")
+	ErrAgentTokenScope     = errors.New("not allowed with an agent token")
+)
+
+// AgentService keeps the registry of the agents that run workspaces of this central instance. An
+// agent registers with its first report; the mappings of its workspace are then left to it, so
+// this instance no longer auto-starts them.
+type AgentService struct {
+	db *gorm.DB
+}
+
+// NewAgentService constructs an agent registry
+func NewAgentService(db *gorm.DB) *AgentService {
+	return &AgentService{db: db}
+}
+
+// Report registers the agent of report, or refreshes it, from the client address. A workspace is
+// run by one agent at a time; an agent may move to another workspace. as is the agent whose token
+// the report came with (nil for the admin token), which may only report as itself.
+func (s *AgentService) Report(report models.AgentReport, address string, as *models.Agent) (*models.Agent, error) {
+	report.Name = strings.TrimSpace(report.Name)
+	report.Workspace = models.NormalizeWorkspace(report.Workspace)
+	if err := validateAgent(report.Name, report.Workspace); err != nil {
+		return nil, err
+	}
+	if as != nil && (report.Name != as.Name || report.Workspace != as.Workspace) {
+		return nil, wrapSentinel(fmt.Sprintf("the token of agent %s runs workspace %s", as.Name, as.Workspace), ErrAgentTokenScope)
+	}
+
+	agent, registered, err := s.save(report.Name, report.Workspace, func(agent *models.Agent) {
+		agent.Address = address
+		agent.Report = &report
+		agent.LastSeenAt = time.Now()
+	})
+	if err != nil {
+		return nil, err
+	}
+	if registered {
+		log.Printf("Agent %s registered from %s for workspace %s", agent.Name, address, agent.Workspace)
+	}
+	agent.Online = true
+	return agent, nil
+}
+
+// IssueToken gives an agent a new token, replacing its previous one. The token only reports the
+// agent and reads the bastions and mappings of its workspace. An agent that is not registered yet
+// is registered for the workspace, which this instance stops auto-starting. Only the hash of the
+// token is kept.
+func (s *AgentService) IssueToken(req models.AgentTokenRequest) (*models.AgentToken, error) {
+	name := strings.TrimSpace(req.Name)
+	workspace := models.NormalizeWorkspace(req.Workspace)
+	if err := validateAgent(name, workspace); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, fmt.Errorf("failed to generate token: %w", err)
+	}
+	token := hex.EncodeToString(buf)
+
+	agent, _, err := s.save(name, workspace, func(agent *models.Agent) {
+		agent.TokenHash = hashAdminToken(token)
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Agent %s was issued a token for workspace %s", agent.Name, agent.Workspace)
+	agent.HasToken = true
+	return &models.AgentToken{Agent: agent, Token: token}, nil
+}
+
+// VerifyToken returns the agent token was issued to, or nil.
+func (s *AgentService) VerifyToken(token string) *models.Agent {
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return nil
+	}
+	var agent models.Agent
+	if err := s.db.Where("token_sha256 = ?", hashAdminToken(token)).First(&agent).Error; err != nil {
+		return nil
+	}
+	return &agent
+}
+
+func validateAgent(name, workspace string) error {
+	if name == "" {
+		return wrapSentinel("agent name is required", ErrInvalidAgentReport)
+	}
+	if err := models.ValidateWorkspaceName(workspace); err != nil {
+		return wrapSentinel(err.Error(), ErrInvalidAgentReport)
+	}
+	return nil
+}
+
+// save registers agent name for workspace, or moves it there, after update changed it. It reports
+// whether the agent is new, and fails with ErrAgentWorkspaceTaken when another agent runs the
+// workspace.
+func (s *AgentService) save(name, workspace string, update func(*models.Agent)) (*models.Agent, bool, error) {
+	var agent models.Agent
+	registered := false
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var other models.Agent
+		err := tx.Where("workspace = ? AND name <> ?", workspace, name).First(&other).Error
+		if err == nil {
+			return wrapSentinel(fmt.Sprintf("workspace %s is run by agent %s", workspace, other.Name), ErrAgentWorkspaceTaken)
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		err = tx.Where("name = ?", name).First(&agent).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			agent = models.Agent{Name: name, RegisteredAt: time.Now()}
+			registered = true
+		case err != nil:
+			return err
+		}
+		agent.Workspace = workspace
+		update(&agent)
+		return tx.Save(&agent).Error
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	agent.HasToken = agent.TokenHash != ""
+	return &agent, registered, nil
+}
+
+// List returns the registered agents by name.
+func (s *AgentService) List() ([]models.Agent, error) {
+	var agents []models.Agent
+	if err := s.db.Order("name").Find(&agents).Error; err != nil {
+		return nil, fmt.Errorf("failed to list agents: %w", err)
+	}
+	return agents, nil
+}
+
+// Delete forgets an agent; its workspace is auto-started here again. An agent that still runs
+// registers again with its next report.
+func (s *AgentService) Delete(name string) error {
+	result := s.db.Where("name = ?", name).Delete(&models.Agent{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete agent: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return wrapSentinel(fmt.Sprintf("agent not found: %s", name), ErrAgentNotFound)
+	}
+	return nil
+}
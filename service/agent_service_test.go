@@ -0,0 +1,103 @@
+package service
+
+import (
+	"bastion/models"
+	"errors"
+	"testing"
+)
+
+func TestAgentService_ReportWorkspaceTaken(t *testing.T) {
+	agents := newTestServices(t).Agents
+
+	agent, err := agents.Report(models.AgentReport{Name: " laptop ", Workspace: " edge "}, "10.0.0.1", nil)
+	if err != nil {
+		t.Fatalf("first report: %v", err)
+	}
+	if agent.Name != "laptop" || agent.Workspace != "edge" || agent.Address != "10.0.0.1" || !agent.Online {
+		t.Fatalf("agent = %+v", agent)
+	}
+
+	if _, err := agents.Report(models.AgentReport{Name: "server", Workspace: "edge"}, "10.0.0.2", nil); !errors.Is(err, ErrAgentWorkspaceTaken) {
+		t.Fatalf("report for a taken workspace: err = %v, want ErrAgentWorkspaceTaken", err)
+	}
+	if _, err := agents.Report(models.AgentReport{Name: "", Workspace: "edge"}, "10.0.0.2", nil); !errors.Is(err, ErrInvalidAgentReport) {
+		t.Fatalf("report without a name: err = %v, want ErrInvalidAgentReport", err)
+	}
+
+	// An agent may move to another workspace, which frees its previous one.
+	if _, err := agents.Report(models.AgentReport{Name: "laptop", Workspace: "lab"}, "10.0.0.1", nil); err != nil {
+		t.Fatalf("move to another workspace: %v", err)
+	}
+	if _, err := agents.Report(models.AgentReport{Name: "server", Workspace: "edge"}, "10.0.0.2", nil); err != nil {
+		t.Fatalf("report for the freed workspace: %v", err)
+	}
+	list, err := agents.List()
+	if err != nil || len(list) != 2 {
+		t.Fatalf("List = %+v, %v", list, err)
+	}
+}
+
+func TestAgentService_Token(t *testing.T) {
+	agents := newTestServices(t).Agents
+
+	issued, err := agents.IssueToken(models.AgentTokenRequest{Name: "laptop", Workspace: "edge"})
+	if err != nil {
+		t.Fatalf("IssueToken: %v", err)
+	}
+	if issued.Token == "" || !issued.Agent.HasToken || issued.Agent.Workspace != "edge" {
+		t.Fatalf("issued = %+v", issued)
+	}
+	if _, err := agents.IssueToken(models.AgentTokenRequest{Name: "server", Workspace: "edge"}); !errors.Is(err, ErrAgentWorkspaceTaken) {
+		t.Fatalf("token for a taken workspace: err = %v, want ErrAgentWorkspaceTaken", err)
+	}
+
+	as := agents.VerifyToken(issued.Token)
+	if as == nil || as.Name != "laptop" || !as.HasToken {
+		t.Fatalf("VerifyToken = %+v", as)
+	}
+	if agents.VerifyToken("") != nil || agents.VerifyToken("not-a-token") != nil {
+		t.Fatal("VerifyToken accepted an invalid token")
+	}
+
+	if _, err := agents.Report(models.AgentReport{Name: "laptop", Workspace: "edge"}, "10.0.0.1", as); err != nil {
+		t.Fatalf("report as the token's agent: %v", err)
+	}
+	for _, report := range []models.AgentReport{{Name: "server", Workspace: "lab"}, {Name: "laptop", Workspace: "lab"}} {
+		if _, err := agents.Report(report, "10.0.0.1", as); !errors.Is(err, ErrAgentTokenScope) {
+			t.Fatalf("report %+v with the token: err = %v, want ErrAgentTokenScope", report, err)
+		}
+	}
+
+	// A new token replaces the previous one.
+	reissued, err := agents.IssueToken(models.AgentTokenRequest{Name: "laptop", Workspace: "edge"})
+	if err != nil {
+		t.Fatalf("reissue: %v", err)
+	}
+	if agents.VerifyToken(issued.Token) != nil || agents.VerifyToken(reissued.Token) == nil {
+		t.Fatal("the reissued token did not replace the previous one")
+	}
+}
+
+func TestMappingService_AutoStartSkipsAgentWorkspaces(t *testing.T) {
+	svc := newTestServices(t)
+	for _, workspace := range []string{models.DefaultWorkspace, "edge"} {
+		req := models.MappingCreate{ID: "proxy", LocalHost: "127.0.0.1", Type: "socks5", AutoStart: true}
+		if _, err := svc.Mapping.InWorkspace(workspace).Create(req); err != nil {
+			t.Fatalf("create mapping in %s: %v", workspace, err)
+		}
+	}
+	if _, err := svc.Agents.Report(models.AgentReport{Name: "laptop", Workspace: "edge"}, "10.0.0.1", nil); err != nil {
+		t.Fatalf("Report: %v", err)
+	}
+
+	if err := svc.Mapping.StartAutoStartMappings(); err != nil {
+		t.Fatalf("StartAutoStartMappings: %v", err)
+	}
+	t.Cleanup(func() { svc.Mapping.StopAll() })
+	if !svc.Mapping.InWorkspace(models.DefaultWorkspace).IsRunning("proxy") {
+		t.Fatal("auto-start mapping of the default workspace is not running")
+	}
+	if svc.Mapping.InWorkspace("edge").IsRunning("proxy") {
+		t.Fatal("auto-start mapping of the agent's workspace was started")
+	}
+}
@@ -0,0 +1,285 @@
+package service
+
+import (
+	"bastion/models"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+
+	"gorm.io/gorm"
+)
+
+// MaxApplyItems bounds the bastions plus mappings of one apply request.
+const MaxApplyItems = 1000
+
+// ErrInvalidApplyRequest is returned for apply requests that cannot be planned at all.
+var ErrInvalidApplyRequest = errors.New("invalid apply request")
+
+// errApplyRolledBack rolls back the transaction of a dry run or of a failed apply.
+var errApplyRolledBack = errors.New("apply rolled back")
+
+// Apply reconciles the workspace with req: declared bastions (matched by name) and mappings
+// (matched by ID, as Create would assign it) are created when missing and updated when they
+// differ; with req.Prune the undeclared ones are deleted. Changes follow the rules of Create,
+// Update and Delete, so running mappings can be neither updated nor pruned.
+//
+// All changes run in one transaction that is rolled back when any of them fails, and always for a
+// dry run, so the returned changes are the exact plan either way.
+func (s *MappingService) Apply(req models.ApplyRequest) (*models.ApplyResult, error) {
+	if n := len(req.Bastions) + len(req.Mappings); n > MaxApplyItems {
+		return nil, wrapSentinel(fmt.Sprintf("too many bastions and mappings: %d (max %d)", n, MaxApplyItems), ErrInvalidApplyRequest)
+	}
+	if req.Prune && len(req.Bastions) == 0 && len(req.Mappings) == 0 {
+		return nil, wrapSentinel("refusing to prune: the request declares no bastions or mappings", ErrInvalidApplyRequest)
+	}
+
+	result := &models.ApplyResult{DryRun: req.DryRun, Prune: req.Prune, Changes: []models.ApplyChange{}}
+	var deferred []func()
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.inTx(tx, &deferred).apply(req, result); err != nil {
+			return err
+		}
+		if result.Failed > 0 || req.DryRun {
+			return errApplyRolledBack
+		}
+		return nil
+	})
+	switch {
+	case err == nil:
+		result.Committed = true
+		for _, fn := range deferred {
+			fn()
+		}
+	case errors.Is(err, errApplyRolledBack):
+	default:
+		return nil, fmt.Errorf("failed to apply: %w", err)
+	}
+	return result, nil
+}
+
+// apply plans and makes the changes of req, adding them to result. Only failures to load the
+// current state are returned; failed changes are recorded in result.
+func (s *MappingService) apply(req models.ApplyRequest, result *models.ApplyResult) error {
+	bastions, err := s.bastionSvc.List()
+	if err != nil {
+		return err
+	}
+	var mappings []models.Mapping
+	if err := s.scoped().Order("id").Find(&mappings).Error; err != nil {
+		return fmt.Errorf("failed to list mappings: %w", err)
+	}
+
+	currentBastions := make(map[string]*models.Bastion, len(bastions))
+	for i := range bastions {
+		currentBastions[bastions[i].Name] = &bastions[i]
+	}
+	currentMappings := make(map[string]*models.Mapping, len(mappings))
+	for i := range mappings {
+		currentMappings[mappings[i].ID] = &mappings[i]
+	}
+
+	declaredBastions := make(map[string]bool, len(req.Bastions))
+	for _, b := range req.Bastions {
+		addApplyChange(result, s.applyBastion(b, currentBastions, declaredBastions))
+	}
+
+	// Chains may name the declared bastions, and the current ones unless they are pruned.
+	chainBastions := make(map[string]bool, len(declaredBastions)+len(bastions))
+	for name := range declaredBastions {
+		chainBastions[name] = true
+	}
+	if !req.Prune {
+		for name := range currentBastions {
+			chainBastions[name] = true
+		}
+	}
+
+	declaredMappings := make(map[string]bool, len(req.Mappings))
+	for _, m := range req.Mappings {
+		addApplyChange(result, s.applyMapping(m, currentMappings, declaredMappings, chainBastions))
+	}
+
+	if req.Prune {
+		// Mappings first: a bastion cannot be deleted while a mapping uses it.
+		for i := range mappings {
+			if !declaredMappings[mappings[i].ID] {
+				addApplyChange(result, s.pruneMapping(mappings[i].ID))
+			}
+		}
+		for i := range bastions {
+			if !declaredBastions[bastions[i].Name] {
+				addApplyChange(result, s.pruneBastion(&bastions[i]))
+			}
+		}
+	}
+	return nil
+}
+
+// applyBastion creates or updates one declared bastion. declared collects the names handled so
+// far, so a bastion cannot be declared twice.
+func (s *MappingService) applyBastion(req models.BastionCreate, current map[string]*models.Bastion, declared map[string]bool) models.ApplyChange {
+	// Same defaults as BastionService.Create, which names the bastion before it can be matched.
+	req.Normalize()
+	if req.Port == 0 {
+		req.Port = 22
+	}
+	if req.Name == "" {
+		req.Name = net.JoinHostPort(req.Host, strconv.Itoa(req.Port))
+	}
+
+	change := models.ApplyChange{Resource: models.ConfigAuditBastion, Name: req.Name}
+	fail := func(err error) models.ApplyChange {
+		change.Action, change.Error = models.ApplyActionFailed, err.Error()
+		return change
+	}
+	if req.Host == "" || req.Username == "" {
+		return fail(errors.New("host and username are required"))
+	}
+	if declared[req.Name] {
+		return fail(fmt.Errorf("bastion %q is declared twice", req.Name))
+	}
+	declared[req.Name] = true
+
+	bastion, ok := current[req.Name]
+	if !ok {
+		if _, err := s.bastionSvc.Create(req); err != nil {
+			return fail(err)
+		}
+		change.Action = models.ApplyActionCreate
+		return change
+	}
+
+	if req.Host != bastion.Host || req.Port != bastion.Port {
+		return fail(fmt.Errorf("host and port are immutable: %s is declared, %s exists",
+			net.JoinHostPort(req.Host, strconv.Itoa(req.Port)), net.JoinHostPort(bastion.Host, strconv.Itoa(bastion.Port))))
+	}
+	desired := *bastion
+	setBastionFields(&desired, req)
+	if change.Diff = applyDiff(bastionSnapshot(bastion), bastionSnapshot(&desired)); len(change.Diff) == 0 {
+		change.Action = models.ApplyActionUnchanged
+		return change
+	}
+	if _, err := s.bastionSvc.Update(bastion.ID, req); err != nil {
+		return fail(err)
+	}
+	change.Action = models.ApplyActionUpdate
+	return change
+}
+
+// applyMapping creates or updates one declared mapping. declared collects the IDs handled so far;
+// chainBastions holds the bastion names a chain may use.
+func (s *MappingService) applyMapping(req models.MappingCreate, current map[string]*models.Mapping, declared, chainBastions map[string]bool) models.ApplyChange {
+	change := models.ApplyChange{Resource: models.ConfigAuditMapping, Name: req.ID}
+	fail := func(err error) models.ApplyChange {
+		change.Action, change.Error = models.ApplyActionFailed, err.Error()
+		return change
+	}
+
+	req.Normalize()
+	if req.ID == "" && req.LocalPort == 0 {
+		// Create would pick a random ID, which no later apply could match.
+		return fail(errors.New("id is required for mappings with local_port 0"))
+	}
+	id, err := newMappingID(req)
+	if err != nil {
+		return fail(err)
+	}
+	change.Name, req.ID = id, id
+	if declared[id] {
+		return fail(fmt.Errorf("mapping %q is declared twice", id))
+	}
+	declared[id] = true
+	for _, name := range req.Chain {
+		if !chainBastions[name] {
+			return fail(fmt.Errorf("chain uses bastion %q, which is neither declared nor kept", name))
+		}
+	}
+
+	mapping, ok := current[id]
+	if !ok {
+		if _, err := s.Create(req); err != nil {
+			return fail(err)
+		}
+		change.Action = models.ApplyActionCreate
+		return change
+	}
+
+	if err := checkMappingImmutable(id, mapping, req); err != nil {
+		return fail(err)
+	}
+	desired := *mapping
+	setMappingFields(&desired, req)
+	if change.Diff = applyDiff(mappingSnapshot(mapping), mappingSnapshot(&desired)); len(change.Diff) == 0 {
+		change.Action = models.ApplyActionUnchanged
+		return change
+	}
+	if _, err := s.Update(id, req); err != nil {
+		return fail(err)
+	}
+	change.Action = models.ApplyActionUpdate
+	return change
+}
+
+// pruneMapping deletes an undeclared mapping; running ones are kept, as by DELETE /api/v2/mappings/:id.
+func (s *MappingService) pruneMapping(id string) models.ApplyChange {
+	change := models.ApplyChange{Resource: models.ConfigAuditMapping, Name: id, Action: models.ApplyActionDelete}
+	err := ErrMappingRunning
+	if !s.IsRunning(id) {
+		err = s.Delete(id)
+	}
+	if err != nil {
+		change.Action, change.Error = models.ApplyActionFailed, err.Error()
+	}
+	return change
+}
+
+// pruneBastion deletes an undeclared bastion, which fails while a mapping still uses it.
+func (s *MappingService) pruneBastion(bastion *models.Bastion) models.ApplyChange {
+	change := models.ApplyChange{Resource: models.ConfigAuditBastion, Name: bastion.Name, Action: models.ApplyActionDelete}
+	if err := s.bastionSvc.Delete(bastion.ID); err != nil {
+		change.Action, change.Error = models.ApplyActionFailed, err.Error()
+	}
+	return change
+}
+
+func addApplyChange(result *models.ApplyResult, change models.ApplyChange) {
+	switch change.Action {
+	case models.ApplyActionCreate:
+		result.Created++
+	case models.ApplyActionUpdate:
+		result.Updated++
+	case models.ApplyActionDelete:
+		result.Deleted++
+	case models.ApplyActionUnchanged:
+		result.Unchanged++
+	case models.ApplyActionFailed:
+		result.Failed++
+	}
+	result.Changes = append(result.Changes, change)
+}
+
+// applyDiff lists the fields an update changes, like the configuration audit trail: secrets are
+// masked, and the version (bumped by every update) is left out. Missing and empty lists are equal.
+func applyDiff(before, after map[string]interface{}) []models.ConfigChange {
+	var changes []models.ConfigChange
+	for _, change := range diffSnapshots(before, after) {
+		if change.Field == "version" || (isEmptyList(change.Before) && isEmptyList(change.After)) {
+			continue
+		}
+		change.Before = redactValue(change.Field, change.Before)
+		change.After = redactValue(change.Field, change.After)
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+func isEmptyList(v interface{}) bool {
+	switch list := v.(type) {
+	case nil:
+		return true
+	case []interface{}:
+		return len(list) == 0
+	}
+	return false
+}
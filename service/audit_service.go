@@ -2,16 +2,25 @@ package service
 
 import (
 	"bastion/core"
+	"bastion/models"
+	"errors"
 )
 
 // AuditService handles audit log business logic
 type AuditService struct {
-	auditor *core.Auditor
+	auditor    *core.Auditor
+	mappingSvc *MappingService
 }
 
 // NewAuditService constructs an audit service
-func NewAuditService(auditor *core.Auditor) *AuditService {
-	return &AuditService{auditor: auditor}
+func NewAuditService(auditor *core.Auditor, mappingSvc *MappingService) *AuditService {
+	return &AuditService{auditor: auditor, mappingSvc: mappingSvc}
+}
+
+// InWorkspace returns a copy of the service whose explicit mapping references (such as a replay
+// mapping_id) are resolved in workspace. HTTP logs themselves are daemon-wide.
+func (s *AuditService) InWorkspace(workspace string) *AuditService {
+	return &AuditService{auditor: s.auditor, mappingSvc: s.mappingSvc.InWorkspace(workspace)}
 }
 
 // GetHTTPLogs returns paginated HTTP logs
@@ -24,6 +33,17 @@ func (s *AuditService) QueryHTTPLogs(filter core.HTTPLogFilter, page, pageSize i
 	return s.auditor.QueryHTTPLogs(filter, page, pageSize)
 }
 
+// AggregateHTTPLogs groups the HTTP logs matching filter by host, URL or status code.
+func (s *AuditService) AggregateHTTPLogs(filter core.HTTPLogFilter, groupBy string, limit int) (*core.HTTPLogAggregate, error) {
+	return s.auditor.AggregateHTTPLogs(filter, groupBy, limit)
+}
+
+// SubscribeHTTPLogs follows newly captured HTTP logs matching filter. The caller must Close the
+// subscription.
+func (s *AuditService) SubscribeHTTPLogs(filter core.HTTPLogFilter) *core.HTTPLogSubscription {
+	return s.auditor.SubscribeHTTPLogs(filter)
+}
+
 // GetHTTPLogByID retrieves a single HTTP log by ID
 func (s *AuditService) GetHTTPLogByID(id int) *core.HTTPLog {
 	return s.auditor.GetHTTPLogByID(id)
@@ -34,6 +54,81 @@ func (s *AuditService) GetHTTPLogPart(id int, part core.HTTPLogPart, opts core.H
 	return s.auditor.GetHTTPLogPart(id, part, opts)
 }
 
+// CompareHTTPLogs returns a structured diff of two HTTP logs.
+func (s *AuditService) CompareHTTPLogs(idA, idB int) (*core.HTTPLogDiff, error) {
+	return s.auditor.CompareHTTPLogs(idA, idB)
+}
+
+// ReplayHTTPLog re-sends a logged request through a mapping's route and records the new exchange.
+func (s *AuditService) ReplayHTTPLog(id int, req models.HTTPReplayRequest) (*core.HTTPReplayResult, error) {
+	original := s.auditor.GetHTTPLogByID(id)
+	if original == nil {
+		return nil, core.ErrHTTPLogNotFound
+	}
+
+	var (
+		mapping *models.Mapping
+		err     error
+	)
+	if req.MappingID != "" {
+		mapping, err = s.mappingSvc.Get(req.MappingID)
+	} else {
+		// Log entries carry the runtime key, which names the workspace of the logging mapping.
+		mapping, err = s.mappingSvc.getByKey(original.MappingID)
+	}
+
+	chain := original.BastionChain
+	switch {
+	case err == nil:
+		chain = mapping.GetChain()
+	case req.MappingID == "" && errors.Is(err, ErrMappingNotFound):
+		// The logging mapping is gone: route by the Host header via the chain recorded on the log.
+		workspace, id := models.SplitWorkspaceKey(original.MappingID)
+		mapping = &models.Mapping{ID: id, Workspace: workspace, LocalPort: original.LocalPort, Type: "http"}
+	default:
+		return nil, err
+	}
+
+	var bastions []models.Bastion
+	if len(chain) > 0 {
+		if bastions, err = s.mappingSvc.InWorkspace(mapping.Workspace).resolveChain(chain); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.auditor.ReplayHTTPLog(id, mapping, bastions, core.HTTPReplayOptions{
+		SetHeaders:    req.SetHeaders,
+		RemoveHeaders: req.RemoveHeaders,
+	})
+}
+
+// HTTPLogCurl renders a logged request as a curl command through its mapping's local endpoint.
+func (s *AuditService) HTTPLogCurl(id int) (*core.HTTPLogCurl, error) {
+	httpLog := s.auditor.GetHTTPLogByID(id)
+	if httpLog == nil {
+		return nil, core.ErrHTTPLogNotFound
+	}
+
+	mapping, err := s.mappingSvc.getByKey(httpLog.MappingID)
+	if err != nil {
+		if !errors.Is(err, ErrMappingNotFound) {
+			return nil, err
+		}
+		mapping = nil
+	}
+	if mapping != nil && mapping.LocalPort == 0 {
+		// Auto-port mapping: use the port it is bound to now, else the one recorded on the log.
+		port := s.mappingSvc.InWorkspace(mapping.Workspace).RuntimePort(mapping.ID)
+		if port == 0 {
+			port = httpLog.LocalPort
+		}
+		bound := *mapping
+		bound.LocalPort = port
+		mapping = &bound
+	}
+	return core.RenderHTTPLogCurl(httpLog, mapping)
+}
+
 // AuditQueueLen returns the current audit queue length.
 func (s *AuditService) AuditQueueLen() int {
 	return s.auditor.AuditQueueLen()
@@ -44,11 +139,41 @@ func (s *AuditService) AuditQueueCap() int {
 	return s.auditor.AuditQueueCap()
 }
 
+// AuditQueueHighWater returns the highest audit queue length observed.
+func (s *AuditService) AuditQueueHighWater() int {
+	return s.auditor.AuditQueueHighWater()
+}
+
+// AuditWorkers returns the number of running audit workers.
+func (s *AuditService) AuditWorkers() int {
+	return s.auditor.AuditWorkers()
+}
+
 // AuditDroppedTotal returns the total count of dropped audit messages.
 func (s *AuditService) AuditDroppedTotal() uint64 {
 	return s.auditor.AuditDroppedTotal()
 }
 
+// RetainedBytes returns the estimated bytes held by in-memory HTTP logs.
+func (s *AuditService) RetainedBytes() int64 {
+	return s.auditor.RetainedBytes()
+}
+
+// RetainedBytesBudget returns the byte budget of in-memory HTTP logs, 0 when unlimited.
+func (s *AuditService) RetainedBytesBudget() int64 {
+	return s.auditor.RetainedBytesBudget()
+}
+
+// BudgetEvictedTotal returns how many HTTP logs were evicted to stay within the byte budget.
+func (s *AuditService) BudgetEvictedTotal() uint64 {
+	return s.auditor.BudgetEvictedTotal()
+}
+
+// HTTPPairStats returns the request/response pairing counters.
+func (s *AuditService) HTTPPairStats() core.HTTPPairStats {
+	return s.auditor.HTTPPairStats()
+}
+
 // ClearHTTPLogs removes all HTTP logs
 func (s *AuditService) ClearHTTPLogs() {
 	s.auditor.ClearHTTPLogs()
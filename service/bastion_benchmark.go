@@ -0,0 +1,76 @@
+package service
+
+import (
+	"bastion/core"
+	"bastion/models"
+	"errors"
+	"fmt"
+	"net"
+)
+
+var ErrInvalidBenchmark = errors.New("invalid benchmark request")
+var ErrBenchmarkFailed = errors.New("benchmark failed")
+
+// BenchmarkBastion measures the chain to bastion id: connect time per hop, latency and throughput
+// (see core.Benchmark). The chain is the one of req.Via, or that of a mapping using the bastion,
+// connected afresh. A failed run returns the report along with an error wrapping ErrBenchmarkFailed.
+func (s *MappingService) BenchmarkBastion(id uint, req models.BastionBenchmarkRequest) (*core.BenchmarkReport, error) {
+	bastion, err := s.bastionSvc.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	req.Normalize()
+	if req.Bytes == 0 {
+		req.Bytes = core.DefaultBenchmarkBytes
+	}
+	if req.Bytes < 0 || req.Bytes > core.MaxBenchmarkBytes {
+		return nil, wrapSentinel(fmt.Sprintf("bytes must be between 1 and %d", core.MaxBenchmarkBytes), ErrInvalidBenchmark)
+	}
+	if req.Pings == 0 {
+		req.Pings = core.DefaultBenchmarkPings
+	}
+	if req.Pings < 0 || req.Pings > core.MaxBenchmarkPings {
+		return nil, wrapSentinel(fmt.Sprintf("pings must be between 1 and %d", core.MaxBenchmarkPings), ErrInvalidBenchmark)
+	}
+	if req.Target != "" {
+		if _, _, err := net.SplitHostPort(req.Target); err != nil {
+			return nil, wrapSentinel(fmt.Sprintf("invalid target %q: %v", req.Target, err), ErrInvalidBenchmark)
+		}
+	}
+
+	chain, err := s.chainTo(bastion, req.Via, ErrInvalidBenchmark)
+	if err != nil {
+		return nil, err
+	}
+
+	report := core.Benchmark(chain, core.BenchmarkOptions{Bytes: req.Bytes, Pings: req.Pings, Target: req.Target})
+	if !report.OK {
+		return report, wrapSentinel(fmt.Sprintf("benchmark through %s failed", report.Route), ErrBenchmarkFailed)
+	}
+	return report, nil
+}
+
+// chainTo resolves the chain that reaches bastion, ending with it: the bastions named by via, or
+// those before it in the chain of a mapping using it. Invalid via names are reported wrapping
+// invalid.
+func (s *MappingService) chainTo(bastion *models.Bastion, via []string, invalid error) ([]models.Bastion, error) {
+	if len(via) == 0 {
+		var err error
+		if via, err = s.rotationVia(bastion.Name); err != nil {
+			return nil, err
+		}
+	}
+	for _, name := range via {
+		if name == bastion.Name {
+			return nil, wrapSentinel(fmt.Sprintf("bastion %q cannot be reached through itself", name), invalid)
+		}
+	}
+	chain, err := s.resolveChain(via)
+	if err != nil {
+		if errors.Is(err, errBastionChainQuery) {
+			return nil, err
+		}
+		return nil, wrapSentinel(err.Error(), invalid)
+	}
+	return append(chain, *bastion), nil
+}
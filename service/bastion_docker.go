@@ -0,0 +1,85 @@
+package service
+
+import (
+	"bastion/core"
+	"bastion/models"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+)
+
+var ErrInvalidDockerRequest = errors.New("invalid docker request")
+var ErrDockerFailed = errors.New("docker discovery failed")
+
+// DockerContainers lists the Docker containers on the host of bastion id (see
+// core.ListDockerContainers), reached through the chain of req.Via or of a mapping using the
+// bastion. Every published port carries a tcp mapping to it through the chain, ready to be created.
+// A failed listing is returned along with an error wrapping ErrDockerFailed.
+func (s *MappingService) DockerContainers(id uint, req models.BastionDockerRequest) (*core.DockerListing, error) {
+	bastion, err := s.bastionSvc.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	req.Normalize()
+	switch req.Source {
+	case "":
+		req.Source = core.DockerSourceCLI
+	case core.DockerSourceCLI, core.DockerSourceAPI:
+	default:
+		return nil, wrapSentinel(fmt.Sprintf("source must be %s or %s", core.DockerSourceCLI, core.DockerSourceAPI), ErrInvalidDockerRequest)
+	}
+	if req.Host != "" {
+		if req.Source != core.DockerSourceAPI {
+			return nil, wrapSentinel("host requires source "+core.DockerSourceAPI, ErrInvalidDockerRequest)
+		}
+		if _, _, err := core.ParseDockerHost(req.Host); err != nil {
+			return nil, wrapSentinel(err.Error(), ErrInvalidDockerRequest)
+		}
+	}
+
+	chain, err := s.chainTo(bastion, req.Via, ErrInvalidDockerRequest)
+	if err != nil {
+		return nil, err
+	}
+	listing, err := core.ListDockerContainers(chain, core.DockerOptions{Source: req.Source, Host: req.Host, All: req.All})
+	if err != nil {
+		return listing, wrapSentinel(fmt.Sprintf("listing containers through %s failed: %v", listing.Route, err), ErrDockerFailed)
+	}
+
+	names := make([]string, len(chain))
+	for i, b := range chain {
+		names[i] = b.Name
+	}
+	// Ports published on every address are reached on the Docker host: the bastion itself, or the
+	// host of a tcp:// Docker API.
+	dockerHost := "127.0.0.1"
+	if network, addr, _ := core.ParseDockerHost(listing.Host); network == "tcp" {
+		dockerHost, _, _ = net.SplitHostPort(addr)
+	}
+	for i := range listing.Containers {
+		c := &listing.Containers[i]
+		for j := range c.Ports {
+			p := &c.Ports[j]
+			if p.HostPort == 0 || p.Protocol != "tcp" {
+				continue
+			}
+			remoteHost := p.HostIP
+			if remoteHost == "" {
+				remoteHost = dockerHost
+			}
+			p.Mapping = &models.MappingCreate{
+				ID:          c.Name + "-" + strconv.Itoa(p.HostPort),
+				LocalHost:   "127.0.0.1",
+				LocalPort:   p.HostPort,
+				RemoteHost:  remoteHost,
+				RemotePort:  p.HostPort,
+				Chain:       names,
+				Type:        "tcp",
+				Description: fmt.Sprintf("Docker container %s (%s) port %d/%s on %s", c.Name, c.Image, p.ContainerPort, p.Protocol, bastion.Name),
+				Tags:        []string{"docker"},
+			}
+		}
+	}
+	return listing, nil
+}
@@ -0,0 +1,172 @@
+package service
+
+import (
+	"bastion/core"
+	"bastion/models"
+	"errors"
+	"fmt"
+)
+
+var ErrInvalidCredentials = errors.New("invalid credentials")
+var ErrCredentialVerifyFailed = errors.New("credential verification failed")
+
+// BastionRotation is the outcome of a bastion credential rotation.
+type BastionRotation struct {
+	ID      uint `json:"id"`
+	Version int  `json:"version"`
+	// Verification is the live SSH test of the new credentials, through the via bastions.
+	Verification *core.DryRunReport `json:"verification"`
+	// RecycledChains are the pool keys of the closed chains; RestartedMappings the mappings
+	// restarted with the new credentials and RestartErrors those that failed to start again.
+	RecycledChains    []string          `json:"recycled_chains"`
+	RestartedMappings []string          `json:"restarted_mappings"`
+	RestartErrors     map[string]string `json:"restart_errors,omitempty"`
+}
+
+// RotateBastionCredentials replaces the credentials of bastion id once a live SSH connection with
+// them succeeds, and records the rotation in the configuration audit trail. Nothing is saved when
+// the test fails: the error wraps ErrCredentialVerifyFailed and the result holds the test report.
+// Running mappings keep their connections; they pick up the new credentials when they restart,
+// which req.Recycle does right away.
+func (s *MappingService) RotateBastionCredentials(id uint, req models.BastionCredentials) (*BastionRotation, error) {
+	bastion, err := s.bastionSvc.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	req.Normalize()
+	if req.Password == "" && req.PkeyPath == "" {
+		return nil, wrapSentinel("a password or private key path is required", ErrInvalidCredentials)
+	}
+	if req.Version != 0 && req.Version != bastion.Version {
+		return nil, wrapSentinel(fmt.Sprintf("version conflict: rotating version %d, current version is %d", req.Version, bastion.Version), ErrVersionConflict)
+	}
+
+	via := req.Via
+	if len(via) == 0 {
+		if via, err = s.rotationVia(bastion.Name); err != nil {
+			return nil, err
+		}
+	}
+	for _, name := range via {
+		if name == bastion.Name {
+			return nil, wrapSentinel(fmt.Sprintf("bastion %q cannot be reached through itself", name), ErrInvalidCredentials)
+		}
+	}
+	chain, err := s.resolveChain(via)
+	if err != nil {
+		if errors.Is(err, errBastionChainQuery) {
+			return nil, err
+		}
+		return nil, wrapSentinel(err.Error(), ErrInvalidCredentials)
+	}
+
+	var through []models.Mapping
+	if req.Recycle {
+		if through, err = s.mappingsThrough(bastion.Name); err != nil {
+			return nil, err
+		}
+	}
+
+	rotated := *bastion
+	if req.Username != "" {
+		rotated.Username = req.Username
+	}
+	rotated.Password = req.Password
+	rotated.PkeyPath = req.PkeyPath
+	rotated.PkeyPassphrase = req.PkeyPassphrase
+
+	report := core.DryRun(&models.Mapping{Workspace: s.Workspace()}, append(chain, rotated), "")
+	report.MappingID = ""
+	result := &BastionRotation{ID: bastion.ID, Version: bastion.Version, Verification: report}
+	if !report.OK {
+		return result, wrapSentinel(fmt.Sprintf("new credentials of bastion %q were rejected; nothing was changed", bastion.Name), ErrCredentialVerifyFailed)
+	}
+
+	if err := saveVersioned(s.db, &rotated, &rotated.Version, req.Version); err != nil {
+		if errors.Is(err, ErrVersionConflict) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to update bastion: %w", err)
+	}
+	s.bastionSvc.recordChange(models.ConfigAuditRotate, rotated.Name, bastion, &rotated)
+	result.Version = rotated.Version
+
+	result.RecycledChains, result.RestartedMappings = []string{}, []string{}
+	if req.Recycle {
+		s.recycleBastion(&rotated, through, result)
+	}
+	return result, nil
+}
+
+// rotationVia returns the bastions before name in the chain of a mapping that uses it, preferring
+// the shortest such prefix, so the live test connects the way the mappings do.
+func (s *MappingService) rotationVia(name string) ([]string, error) {
+	mappings, err := s.mappingsThrough(name)
+	if err != nil {
+		return nil, err
+	}
+	var via []string
+	for n, m := range mappings {
+		chain := m.GetChain()
+		for i, hop := range chain {
+			if hop == name && (n == 0 || i < len(via)) {
+				via = chain[:i]
+				break
+			}
+		}
+	}
+	return via, nil
+}
+
+// mappingsThrough lists the mappings whose chain contains the bastion name.
+func (s *MappingService) mappingsThrough(name string) ([]models.Mapping, error) {
+	var candidates []models.Mapping
+	if err := s.scoped().Where("chain_json LIKE ?", "%\""+name+"\"%").Order("id").Find(&candidates).Error; err != nil {
+		return nil, fmt.Errorf("failed to query mappings: %w", err)
+	}
+	mappings := make([]models.Mapping, 0, len(candidates))
+	for _, m := range candidates {
+		for _, hop := range m.GetChain() {
+			if hop == name {
+				mappings = append(mappings, m)
+				break
+			}
+		}
+	}
+	return mappings, nil
+}
+
+// recycleBastion stops the running mappings through bastion, closes its pooled chains and starts
+// the mappings again, so every connection through it uses its current credentials.
+func (s *MappingService) recycleBastion(bastion *models.Bastion, mappings []models.Mapping, result *BastionRotation) {
+	var stopped []string
+	for _, m := range mappings {
+		if !s.state.SessionExists(m.Key()) {
+			continue
+		}
+		if err := s.stop(m.ID); err != nil {
+			if !errors.Is(err, ErrMappingNotRunning) {
+				result.restartFailed(m.ID, err)
+			}
+			continue
+		}
+		stopped = append(stopped, m.ID)
+	}
+
+	result.RecycledChains = core.Pool.RemoveConnectionsWithBastion(bastion.Key())
+
+	for _, id := range stopped {
+		if err := s.start(id); err != nil {
+			result.restartFailed(id, err)
+			continue
+		}
+		result.RestartedMappings = append(result.RestartedMappings, id)
+	}
+}
+
+func (r *BastionRotation) restartFailed(id string, err error) {
+	if r.RestartErrors == nil {
+		r.RestartErrors = make(map[string]string)
+	}
+	r.RestartErrors[id] = err.Error()
+}
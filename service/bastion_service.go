@@ -1,37 +1,111 @@
 package service
 
 import (
+	"bastion/core"
 	"bastion/models"
+	"errors"
 	"fmt"
+	"net"
+	"strconv"
 
 	"gorm.io/gorm"
 )
 
+var ErrBastionNotFound = errors.New("bastion not found")
+var ErrBastionInUse = errors.New("bastion is in use")
+
 // BastionService handles bastion business logic
 type BastionService struct {
-	db *gorm.DB
+	db          *gorm.DB
+	configAudit *ConfigAuditService
+	workspace   string // see InWorkspace; empty means the default workspace
+	actor       Actor  // see As; recorded in the configuration audit trail
+
+	// deferred collects the configuration audit entries of a service bound to a transaction (see
+	// inTx) until it commits.
+	deferred *[]func()
 }
 
 // NewBastionService constructs a bastion service
-func NewBastionService(db *gorm.DB) *BastionService {
-	return &BastionService{db: db}
+func NewBastionService(db *gorm.DB, configAudit *ConfigAuditService) *BastionService {
+	return &BastionService{db: db, configAudit: configAudit}
+}
+
+// Workspace returns the workspace the service operates on.
+func (s *BastionService) Workspace() string {
+	return models.NormalizeWorkspace(s.workspace)
+}
+
+// InWorkspace returns a copy of the service scoped to workspace.
+func (s *BastionService) InWorkspace(workspace string) *BastionService {
+	return &BastionService{db: s.db, configAudit: s.configAudit, workspace: workspace, actor: s.actor}
 }
 
-// List lists all bastions
+// As returns a copy of the service that records its changes as made by actor.
+func (s *BastionService) As(actor Actor) *BastionService {
+	scoped := *s
+	scoped.actor = actor
+	return &scoped
+}
+
+// recordChange adds a bastion change to the configuration audit trail.
+func (s *BastionService) recordChange(action, name string, before, after *models.Bastion) {
+	workspace, actor := s.Workspace(), s.actor
+	beforeSnap, afterSnap := bastionSnapshot(before), bastionSnapshot(after)
+	record := func() {
+		s.configAudit.Record(workspace, models.ConfigAuditBastion, name, action, actor, beforeSnap, afterSnap)
+	}
+	if s.deferred != nil {
+		*s.deferred = append(*s.deferred, record)
+		return
+	}
+	record()
+}
+
+// inTx returns a copy of the service whose queries run in tx, collecting its audit entries in
+// *deferred (see MappingService.inTx).
+func (s *BastionService) inTx(tx *gorm.DB, deferred *[]func()) *BastionService {
+	scoped := *s
+	scoped.db = tx
+	scoped.deferred = deferred
+	return &scoped
+}
+
+// scoped restricts a query to the service's workspace.
+func (s *BastionService) scoped() *gorm.DB {
+	return s.db.Where("workspace = ?", s.Workspace())
+}
+
+// List lists all bastions, ordered by name
 func (s *BastionService) List() ([]models.Bastion, error) {
 	var bastions []models.Bastion
-	if err := s.db.Find(&bastions).Error; err != nil {
+	if err := s.scoped().Order("name").Find(&bastions).Error; err != nil {
 		return nil, fmt.Errorf("failed to list bastions: %w", err)
 	}
 	return bastions, nil
 }
 
+// Search lists the bastions matching filter (by name, host, username, description or tags)
+func (s *BastionService) Search(filter models.ListFilter) ([]models.Bastion, error) {
+	bastions, err := s.List()
+	if err != nil || filter.Empty() {
+		return bastions, err
+	}
+	matched := make([]models.Bastion, 0, len(bastions))
+	for _, b := range bastions {
+		if filter.Match(b.GetTags(), b.Name, b.Host, b.Username, b.Description) {
+			matched = append(matched, b)
+		}
+	}
+	return matched, nil
+}
+
 // Get fetches a bastion by ID
 func (s *BastionService) Get(id uint) (*models.Bastion, error) {
 	var bastion models.Bastion
-	if err := s.db.First(&bastion, id).Error; err != nil {
+	if err := s.scoped().First(&bastion, id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			return nil, fmt.Errorf("bastion not found: %d", id)
+			return nil, wrapSentinel(fmt.Sprintf("bastion not found: %d", id), ErrBastionNotFound)
 		}
 		return nil, fmt.Errorf("failed to get bastion: %w", err)
 	}
@@ -42,9 +116,19 @@ func (s *BastionService) Get(id uint) (*models.Bastion, error) {
 func (s *BastionService) Create(req models.BastionCreate) (*models.Bastion, error) {
 	// Normalize inputs
 	req.Normalize()
+	if err := models.ValidateHost("host", req.Host); err != nil {
+		return nil, err
+	}
+	if err := models.ValidateNotes(req.Description, req.Tags); err != nil {
+		return nil, err
+	}
+	if err := core.ValidateSSHAlgorithms(req.Ciphers, req.MACs, req.KeyExchanges); err != nil {
+		return nil, err
+	}
 
 	// Build bastion model
 	bastion := models.Bastion{
+		Workspace:      s.Workspace(),
 		Name:           req.Name,
 		Host:           req.Host,
 		Port:           req.Port,
@@ -52,7 +136,13 @@ func (s *BastionService) Create(req models.BastionCreate) (*models.Bastion, erro
 		Password:       req.Password,
 		PkeyPath:       req.PkeyPath,
 		PkeyPassphrase: req.PkeyPassphrase,
+		Ciphers:        req.Ciphers,
+		MACs:           req.MACs,
+		KeyExchanges:   req.KeyExchanges,
+		Description:    req.Description,
+		Version:        1,
 	}
+	bastion.SetTags(req.Tags)
 
 	// Apply defaults
 	if bastion.Port == 0 {
@@ -60,13 +150,14 @@ func (s *BastionService) Create(req models.BastionCreate) (*models.Bastion, erro
 	}
 
 	if bastion.Name == "" {
-		bastion.Name = fmt.Sprintf("%s:%d", bastion.Host, bastion.Port)
+		bastion.Name = net.JoinHostPort(bastion.Host, strconv.Itoa(bastion.Port))
 	}
 
 	// Persist to database
 	if err := s.db.Create(&bastion).Error; err != nil {
 		return nil, fmt.Errorf("failed to create bastion: %w", err)
 	}
+	s.recordChange(models.ConfigAuditCreate, bastion.Name, nil, &bastion)
 
 	return &bastion, nil
 }
@@ -81,21 +172,42 @@ func (s *BastionService) Update(id uint, req models.BastionCreate) (*models.Bast
 
 	// Normalize inputs
 	req.Normalize()
+	if err := models.ValidateNotes(req.Description, req.Tags); err != nil {
+		return nil, err
+	}
+	if err := core.ValidateSSHAlgorithms(req.Ciphers, req.MACs, req.KeyExchanges); err != nil {
+		return nil, err
+	}
+	before := *bastion
 
-	// Update fields (name/host/port are immutable because mappings reference bastions by name)
-	bastion.Username = req.Username
-	bastion.Password = req.Password
-	bastion.PkeyPath = req.PkeyPath
-	bastion.PkeyPassphrase = req.PkeyPassphrase
+	setBastionFields(bastion, req)
 
-	// Persist updates
-	if err := s.db.Save(bastion).Error; err != nil {
+	// Persist updates (req.Version, when set, must still be current)
+	if err := saveVersioned(s.db, bastion, &bastion.Version, req.Version); err != nil {
+		if errors.Is(err, ErrVersionConflict) {
+			return nil, err
+		}
 		return nil, fmt.Errorf("failed to update bastion: %w", err)
 	}
+	s.recordChange(models.ConfigAuditUpdate, bastion.Name, &before, bastion)
 
 	return bastion, nil
 }
 
+// setBastionFields copies the fields an update may change from req to bastion (name/host/port are
+// immutable because mappings reference bastions by name).
+func setBastionFields(bastion *models.Bastion, req models.BastionCreate) {
+	bastion.Username = req.Username
+	bastion.Password = req.Password
+	bastion.PkeyPath = req.PkeyPath
+	bastion.PkeyPassphrase = req.PkeyPassphrase
+	bastion.Ciphers = req.Ciphers
+	bastion.MACs = req.MACs
+	bastion.KeyExchanges = req.KeyExchanges
+	bastion.Description = req.Description
+	bastion.SetTags(req.Tags)
+}
+
 // Delete removes a bastion
 func (s *BastionService) Delete(id uint) error {
 	// Look up bastion
@@ -107,27 +219,29 @@ func (s *BastionService) Delete(id uint) error {
 	// Ensure no mappings reference it
 	var count int64
 	s.db.Model(&models.Mapping{}).
-		Where("chain_json LIKE ?", "%\""+bastion.Name+"\"%").
+		Where("workspace = ? AND chain_json LIKE ?", s.Workspace(), "%\""+bastion.Name+"\"%").
 		Count(&count)
 
 	if count > 0 {
-		return fmt.Errorf("cannot delete bastion '%s': used by %d mapping(s)", bastion.Name, count)
+		return wrapSentinel(fmt.Sprintf("cannot delete bastion '%s': used by %d mapping(s)", bastion.Name, count), ErrBastionInUse)
 	}
 
 	// Delete bastion
 	if err := s.db.Delete(bastion).Error; err != nil {
 		return fmt.Errorf("failed to delete bastion: %w", err)
 	}
+	s.recordChange(models.ConfigAuditDelete, bastion.Name, bastion, nil)
 
 	return nil
 }
 
-// CheckInUse checks whether a bastion is referenced (including running sessions)
+// CheckInUse checks whether a bastion is referenced (including running sessions).
+// runningSessions is keyed by mapping runtime key (see models.Mapping.Key).
 func (s *BastionService) CheckInUse(bastionName string, runningSessions map[string]bool) (inUse bool, runningMappings []string, totalMappings int64, err error) {
 	// Count all mappings that reference the bastion
 	var count int64
 	s.db.Model(&models.Mapping{}).
-		Where("chain_json LIKE ?", "%\""+bastionName+"\"%").
+		Where("workspace = ? AND chain_json LIKE ?", s.Workspace(), "%\""+bastionName+"\"%").
 		Count(&count)
 
 	if count == 0 {
@@ -136,13 +250,13 @@ func (s *BastionService) CheckInUse(bastionName string, runningSessions map[stri
 
 	// Check for running sessions
 	var mappings []models.Mapping
-	if err := s.db.Where("chain_json LIKE ?", "%\""+bastionName+"\"%").Find(&mappings).Error; err != nil {
+	if err := s.db.Where("workspace = ? AND chain_json LIKE ?", s.Workspace(), "%\""+bastionName+"\"%").Find(&mappings).Error; err != nil {
 		return false, nil, 0, fmt.Errorf("failed to query mappings: %w", err)
 	}
 
 	var runningMappingIDs []string
 	for _, mapping := range mappings {
-		if runningSessions[mapping.ID] {
+		if runningSessions[mapping.Key()] {
 			chainNames := mapping.GetChain()
 			for _, name := range chainNames {
 				if name == bastionName {
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"bastion/models"
+	"reflect"
+	"testing"
+)
+
+func TestBastionService_Search(t *testing.T) {
+	svc := newTestServices(t).Bastion
+	for _, req := range []models.BastionCreate{
+		{Name: "jump-eu", Host: "10.0.0.1", Username: "ops", Description: "Frankfurt", Tags: []string{"Prod", "eu", "prod", " "}},
+		{Name: "jump-us", Host: "10.0.1.1", Username: "ops", Tags: []string{"prod", "us"}},
+		{Name: "lab", Host: "192.168.1.1", Username: "dev", Description: "bench"},
+	} {
+		if _, err := svc.Create(req); err != nil {
+			t.Fatalf("Create %s: %v", req.Name, err)
+		}
+	}
+	if _, err := svc.Create(models.BastionCreate{Name: "bad", Host: "10.0.2.1", Username: "ops", Tags: []string{"a,b"}}); err == nil {
+		t.Fatal("Create with a comma in a tag succeeded")
+	}
+
+	tests := []struct {
+		name   string
+		filter models.ListFilter
+		want   []string
+	}{
+		{"all", models.ListFilter{}, []string{"jump-eu", "jump-us", "lab"}},
+		{"query by name", models.ListFilter{Query: "JUMP"}, []string{"jump-eu", "jump-us"}},
+		{"query by host", models.ListFilter{Query: "192.168"}, []string{"lab"}},
+		{"query by description", models.ListFilter{Query: "frankfurt"}, []string{"jump-eu"}},
+		{"query by tag", models.ListFilter{Query: "us"}, []string{"jump-us"}},
+		{"tag", models.ListFilter{Tags: []string{"PROD"}}, []string{"jump-eu", "jump-us"}},
+		{"tags", models.ListFilter{Tags: []string{"prod", "eu"}}, []string{"jump-eu"}},
+		{"tag and query", models.ListFilter{Query: "10.0.1", Tags: []string{"prod"}}, []string{"jump-us"}},
+		{"no match", models.ListFilter{Tags: []string{"staging"}}, []string{}},
+	}
+	for _, tt := range tests {
+		bastions, err := svc.Search(tt.filter)
+		if err != nil {
+			t.Fatalf("%s: Search: %v", tt.name, err)
+		}
+		names := []string{}
+		for _, b := range bastions {
+			names = append(names, b.Name)
+		}
+		if !reflect.DeepEqual(names, tt.want) {
+			t.Errorf("%s: Search = %v, want %v", tt.name, names, tt.want)
+		}
+	}
+
+	// Tags are stored normalized.
+	bastions, _ := svc.Search(models.ListFilter{Query: "jump-eu"})
+	if got := bastions[0].GetTags(); !reflect.DeepEqual(got, []string{"Prod", "eu"}) {
+		t.Fatalf("stored tags = %q", got)
+	}
+}
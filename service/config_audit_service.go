@@ -0,0 +1,242 @@
+package service
+
+import (
+	"bastion/models"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"reflect"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Actor identifies who changes the configuration, for the configuration audit trail.
+type Actor struct {
+	Name string // client IP, CLI user or "system"
+	Auth string // how the actor was let in: loopback, admin_token, none, cli, system
+}
+
+// SystemActor is recorded for changes the server makes on its own (auto-start) and for services
+// that were not given an actor.
+var SystemActor = Actor{Name: "system", Auth: "system"}
+
+// ConfigAuditService records and lists configuration changes of bastions and mappings.
+type ConfigAuditService struct {
+	db        *gorm.DB
+	workspace string // see InWorkspace; empty means the default workspace
+}
+
+// NewConfigAuditService constructs a configuration audit service
+func NewConfigAuditService(db *gorm.DB) *ConfigAuditService {
+	return &ConfigAuditService{db: db}
+}
+
+// Workspace returns the workspace the service lists entries of.
+func (s *ConfigAuditService) Workspace() string {
+	return models.NormalizeWorkspace(s.workspace)
+}
+
+// InWorkspace returns a copy of the service scoped to workspace.
+func (s *ConfigAuditService) InWorkspace(workspace string) *ConfigAuditService {
+	return &ConfigAuditService{db: s.db, workspace: workspace}
+}
+
+// Record stores a change of a resource in workspace. before is nil for creations and after is nil
+// for deletions. Failures are logged: an audit problem must not undo a change already applied.
+func (s *ConfigAuditService) Record(workspace, resource, resourceID, action string, actor Actor, before, after map[string]interface{}) {
+	if s == nil {
+		return
+	}
+	if actor.Name == "" {
+		actor = SystemActor
+	}
+
+	diff := diffSnapshots(before, after)
+	redactSnapshot(before)
+	redactSnapshot(after)
+	for i := range diff {
+		diff[i].Before = redactValue(diff[i].Field, diff[i].Before)
+		diff[i].After = redactValue(diff[i].Field, diff[i].After)
+	}
+
+	entry := models.ConfigAudit{
+		Timestamp:  time.Now(),
+		Workspace:  models.NormalizeWorkspace(workspace),
+		Resource:   resource,
+		ResourceID: resourceID,
+		Action:     action,
+		Actor:      actor.Name,
+		Auth:       actor.Auth,
+		BeforeJSON: encodeSnapshot(before),
+		AfterJSON:  encodeSnapshot(after),
+		DiffJSON:   encodeSnapshot(diff),
+	}
+	if err := s.db.Create(&entry).Error; err != nil {
+		log.Printf("failed to record config audit entry (%s %s %s): %v", action, resource, resourceID, err)
+	}
+}
+
+// List returns a page of the workspace's entries (latest first) matching filter, plus the total match count.
+func (s *ConfigAuditService) List(filter models.ConfigAuditFilter, page, pageSize int) ([]models.ConfigAudit, int, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = 20
+	}
+
+	q := s.db.Model(&models.ConfigAudit{}).Where("workspace = ?", s.Workspace())
+	if filter.Resource != "" {
+		q = q.Where("resource = ?", filter.Resource)
+	}
+	if filter.ResourceID != "" {
+		q = q.Where("resource_id = ?", filter.ResourceID)
+	}
+	if filter.Action != "" {
+		q = q.Where("action = ?", filter.Action)
+	}
+	if filter.Actor != "" {
+		q = q.Where("actor = ?", filter.Actor)
+	}
+	if filter.Since != nil {
+		q = q.Where("timestamp >= ?", *filter.Since)
+	}
+	if filter.Until != nil {
+		q = q.Where("timestamp <= ?", *filter.Until)
+	}
+
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count config audit entries: %w", err)
+	}
+
+	entries := make([]models.ConfigAudit, 0, pageSize)
+	if err := q.Order("id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&entries).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to list config audit entries: %w", err)
+	}
+	return entries, int(total), nil
+}
+
+// bastionSnapshot returns the audited fields of a bastion (nil for nil).
+func bastionSnapshot(b *models.Bastion) map[string]interface{} {
+	if b == nil {
+		return nil
+	}
+	return toSnapshot(b)
+}
+
+// mappingSnapshot returns the audited fields of a mapping (nil for nil), with its JSON-encoded
+// columns expanded.
+func mappingSnapshot(m *models.Mapping) map[string]interface{} {
+	if m == nil {
+		return nil
+	}
+	snap := toSnapshot(m)
+	if snap == nil {
+		return nil
+	}
+	snap["chain"] = toSnapshotValue(m.GetChain())
+	snap["allow_cidrs"] = toSnapshotValue(m.GetAllowCIDRs())
+	snap["deny_cidrs"] = toSnapshotValue(m.GetDenyCIDRs())
+	snap["target_allow"] = toSnapshotValue(m.GetTargetAllow())
+	snap["target_deny"] = toSnapshotValue(m.GetTargetDeny())
+	snap["request_headers"] = toSnapshotValue(m.GetRequestHeaders())
+	snap["depends_on"] = toSnapshotValue(m.GetDependsOn())
+	snap["tags"] = toSnapshotValue(m.GetTags())
+	return snap
+}
+
+// runningSnapshot is the state recorded around start and stop.
+func runningSnapshot(running bool) map[string]interface{} {
+	return map[string]interface{}{"running": running}
+}
+
+// toSnapshot converts v to its generic JSON form so snapshots compare the way they are stored.
+func toSnapshot(v interface{}) map[string]interface{} {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var snap map[string]interface{}
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil
+	}
+	// The entry's own timestamp says when the change happened.
+	delete(snap, "updated_at")
+	return snap
+}
+
+func toSnapshotValue(v interface{}) interface{} {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var out interface{}
+	_ = json.Unmarshal(data, &out)
+	return out
+}
+
+// diffSnapshots lists the fields that differ between before and after, sorted by field name.
+// Creations and deletions have no diff: their one snapshot already holds every field.
+func diffSnapshots(before, after map[string]interface{}) []models.ConfigChange {
+	if before == nil || after == nil {
+		return []models.ConfigChange{}
+	}
+	fields := make(map[string]bool, len(before)+len(after))
+	for k := range before {
+		fields[k] = true
+	}
+	for k := range after {
+		fields[k] = true
+	}
+	names := make([]string, 0, len(fields))
+	for k := range fields {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	changes := make([]models.ConfigChange, 0)
+	for _, name := range names {
+		if !reflect.DeepEqual(before[name], after[name]) {
+			changes = append(changes, models.ConfigChange{Field: name, Before: before[name], After: after[name]})
+		}
+	}
+	return changes
+}
+
+// secretFields are masked in snapshots and diffs; a change still shows up because diffs are
+// computed before masking.
+var secretFields = map[string]bool{"password": true, "pkey_passphrase": true}
+
+func redactSnapshot(snap map[string]interface{}) {
+	for k, v := range snap {
+		snap[k] = redactValue(k, v)
+	}
+}
+
+func redactValue(field string, v interface{}) interface{} {
+	s, ok := v.(string)
+	if !ok || s == "" {
+		return v
+	}
+	if secretFields[field] {
+		return "***"
+	}
+	if field == "upstream_proxy" {
+		if u, err := url.Parse(s); err == nil {
+			return u.Redacted()
+		}
+	}
+	return v
+}
+
+func encodeSnapshot(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil || string(data) == "null" {
+		return ""
+	}
+	return string(data)
+}
@@ -0,0 +1,399 @@
+package service
+
+import (
+	"bastion/bastionclient"
+	"bastion/config"
+	"bastion/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// ErrHADisabled is returned by the role changes of an instance that is not one of a pair.
+var ErrHADisabled = errors.New("high availability is not enabled")
+
+// ErrHAPeerFailed is returned when a failover cannot hand the active role over; this instance
+// keeps its role.
+var ErrHAPeerFailed = errors.New("the peer refused the failover")
+
+// haSyncActor is recorded in the configuration audit trail for changes copied from the active.
+var haSyncActor = Actor{Name: "ha-sync", Auth: "system"}
+
+// haSyncTimeout bounds one configuration sync from the active.
+const haSyncTimeout = time.Minute
+
+// HAService runs this instance as one of an active/standby pair (HA_ROLE, HA_PEER_URL). Only the
+// active runs the autostart mappings. The standby copies the active's bastions and mappings every
+// HA_SYNC_INTERVAL_SECONDS and checks its health endpoint every HA_CHECK_INTERVAL_SECONDS; after
+// HA_FAILOVER_THRESHOLD failed checks in a row it takes over. Failover swaps the roles on demand.
+type HAService struct {
+	mapping *MappingService
+	peer    *bastionclient.Client
+
+	checkInterval time.Duration
+	syncInterval  time.Duration
+	threshold     int
+
+	mu     sync.Mutex
+	status models.HAStatus
+	stop   chan struct{} // closed to end the standby loop; nil while active
+}
+
+// NewHAService creates the HA service of settings; it does nothing until Start.
+func NewHAService(mapping *MappingService, settings *config.Config) *HAService {
+	s := &HAService{
+		mapping:       mapping,
+		checkInterval: time.Duration(settings.HACheckIntervalSec) * time.Second,
+		syncInterval:  time.Duration(settings.HASyncIntervalSec) * time.Second,
+		threshold:     settings.HAFailoverAfter,
+	}
+	if s.checkInterval <= 0 {
+		s.checkInterval = 5 * time.Second
+	}
+	if s.threshold < 1 {
+		s.threshold = 1
+	}
+	if settings.HARole == "" {
+		return s
+	}
+
+	s.status = models.HAStatus{Enabled: true, Role: settings.HARole, PeerURL: settings.HAPeerURL}
+	s.peer = bastionclient.New(settings.HAPeerURL)
+	s.peer.AdminToken = settings.HAPeerToken
+	return s
+}
+
+// Start joins the pair: a standby starts checking and syncing from the active, and an active whose
+// peer took over while it was down (the peer reports itself active) rejoins as the standby. An
+// active that cannot reach its peer starts as the active and keeps asking the peer until it
+// answers, so two instances that both came up active while unable to reach each other settle once
+// the network is back: until then both run the mappings. It is a no-op unless HA_ROLE is set.
+func (s *HAService) Start() error {
+	if !s.status.Enabled {
+		return nil
+	}
+	if s.status.Role != models.HARoleActive && s.status.Role != models.HARoleStandby {
+		return fmt.Errorf("HA_ROLE must be %s or %s, got %q", models.HARoleActive, models.HARoleStandby, s.status.Role)
+	}
+	if s.peer.BaseURL == "" {
+		return errors.New("HA_ROLE requires HA_PEER_URL")
+	}
+
+	if s.status.Role == models.HARoleActive {
+		health, err := s.peerHealth()
+		switch {
+		case err != nil:
+			log.Printf("High availability: the peer %s cannot be reached (%v); running as active until it answers", s.status.PeerURL, err)
+			go s.watchPeer()
+		case health.HARole == models.HARoleActive:
+			s.mu.Lock()
+			s.setRoleLocked(models.HARoleStandby, haRejoinReason)
+			s.mu.Unlock()
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.status.Role == models.HARoleStandby {
+		s.startStandbyLocked()
+	}
+	log.Printf("High availability: running as %s, peer %s", s.status.Role, s.status.PeerURL)
+	return nil
+}
+
+// haRejoinReason is the role change of an active that finds its peer took over.
+const haRejoinReason = "the peer took over while this instance was down"
+
+// watchPeer asks the peer an active could not reach at startup for its role until it answers, and
+// rejoins as the standby when the peer is active too. It ends once this instance is no longer
+// active.
+func (s *HAService) watchPeer() {
+	ticker := time.NewTicker(s.checkInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if !s.Active() {
+			return
+		}
+		health, err := s.peerHealth()
+		if err != nil {
+			continue
+		}
+		if health.HARole == models.HARoleActive {
+			_, _ = s.Demote(haRejoinReason)
+		}
+		return
+	}
+}
+
+// peerHealth asks the peer for its health, giving up after a check interval.
+func (s *HAService) peerHealth() (*bastionclient.Health, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.checkInterval)
+	defer cancel()
+	return s.peer.GetHealth(ctx)
+}
+
+// Active reports whether this instance runs the autostart mappings: it is the active of its pair,
+// or not one of a pair.
+func (s *HAService) Active() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return !s.status.Enabled || s.status.Role == models.HARoleActive
+}
+
+// Status returns the state of this instance in its pair.
+func (s *HAService) Status() models.HAStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status
+}
+
+// Promote makes this instance the active one: it stops checking the peer and starts the autostart
+// mappings in the background. It does not ask the peer to step down; Failover does.
+func (s *HAService) Promote(reason string) (models.HAStatus, error) {
+	s.mu.Lock()
+	if !s.status.Enabled {
+		s.mu.Unlock()
+		return models.HAStatus{}, ErrHADisabled
+	}
+	if s.status.Role == models.HARoleActive {
+		defer s.mu.Unlock()
+		return s.status, nil
+	}
+	s.stopStandbyLocked()
+	s.setRoleLocked(models.HARoleActive, reason)
+	status := s.status
+	s.mu.Unlock()
+
+	log.Printf("High availability: this instance is now active (%s)", reason)
+	go func() {
+		if err := s.mapping.StartAutoStartMappings(); err != nil {
+			log.Printf("Warning: Failed to start auto-start mappings: %v", err)
+		}
+	}()
+	return status, nil
+}
+
+// Demote makes this instance the standby: it stops every running mapping and starts checking and
+// syncing from the peer.
+func (s *HAService) Demote(reason string) (models.HAStatus, error) {
+	s.mu.Lock()
+	if !s.status.Enabled {
+		s.mu.Unlock()
+		return models.HAStatus{}, ErrHADisabled
+	}
+	if s.status.Role == models.HARoleStandby {
+		defer s.mu.Unlock()
+		return s.status, nil
+	}
+	s.setRoleLocked(models.HARoleStandby, reason)
+	s.startStandbyLocked()
+	status := s.status
+	s.mu.Unlock()
+
+	stopped := s.mapping.StopAll()
+	log.Printf("High availability: this instance is now standby (%s); stopped %d mappings", reason, stopped.Stopped)
+	return status, nil
+}
+
+// Failover moves the active role to the other instance. The active stops its mappings, becomes
+// the standby and asks the peer to take over, becoming active again if the peer fails to. The
+// standby asks the peer to step down and takes over; a peer that cannot be reached is taken for
+// gone, but one that refuses keeps the roles as they are.
+func (s *HAService) Failover(ctx context.Context) (models.HAStatus, error) {
+	status := s.Status()
+	if !status.Enabled {
+		return models.HAStatus{}, ErrHADisabled
+	}
+
+	if status.Role == models.HARoleActive {
+		if _, err := s.Demote("manual failover to the peer"); err != nil {
+			return models.HAStatus{}, err
+		}
+		if _, err := s.peer.HAPromote(ctx); err != nil {
+			_, _ = s.Promote("failover reverted: the peer did not take over")
+			return models.HAStatus{}, wrapSentinel("the peer did not take over: "+err.Error(), ErrHAPeerFailed)
+		}
+		return s.Status(), nil
+	}
+
+	reason := "manual failover"
+	if _, err := s.peer.HADemote(ctx); err != nil {
+		var apiErr *bastionclient.APIError
+		if errors.As(err, &apiErr) {
+			return models.HAStatus{}, wrapSentinel("the peer refused to step down: "+err.Error(), ErrHAPeerFailed)
+		}
+		reason = "manual failover; the peer could not be reached: " + err.Error()
+	}
+	return s.Promote(reason)
+}
+
+func (s *HAService) setRoleLocked(role, reason string) {
+	now := time.Now()
+	s.status.Role = role
+	s.status.RoleChangedAt = &now
+	s.status.RoleReason = reason
+	s.status.FailedChecks = 0
+}
+
+func (s *HAService) startStandbyLocked() {
+	if s.stop != nil {
+		return
+	}
+	s.stop = make(chan struct{})
+	go s.runStandby(s.stop)
+}
+
+func (s *HAService) stopStandbyLocked() {
+	if s.stop != nil {
+		close(s.stop)
+		s.stop = nil
+	}
+}
+
+// runStandby checks and syncs from the active until stop is closed.
+func (s *HAService) runStandby(stop <-chan struct{}) {
+	check := time.NewTicker(s.checkInterval)
+	defer check.Stop()
+
+	var syncTick <-chan time.Time
+	if s.syncInterval > 0 {
+		ticker := time.NewTicker(s.syncInterval)
+		defer ticker.Stop()
+		syncTick = ticker.C
+		s.sync()
+	}
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-check.C:
+			s.check(stop)
+		case <-syncTick:
+			s.sync()
+		}
+	}
+}
+
+// check probes the active's health endpoint and takes over after threshold failures in a row. A
+// degraded active (its database is down) counts as failed.
+func (s *HAService) check(stop <-chan struct{}) {
+	health, err := s.peerHealth()
+
+	now := time.Now()
+	s.mu.Lock()
+	select {
+	case <-stop:
+		// Promoted while the check ran.
+		s.mu.Unlock()
+		return
+	default:
+	}
+	s.status.LastCheckAt = &now
+	if err == nil {
+		s.status.PeerHealthy, s.status.PeerRole = true, health.HARole
+		s.status.LastCheckError, s.status.FailedChecks = "", 0
+		s.mu.Unlock()
+		return
+	}
+	s.status.PeerHealthy, s.status.PeerRole = false, ""
+	s.status.LastCheckError = err.Error()
+	s.status.FailedChecks++
+	failed := s.status.FailedChecks
+	s.mu.Unlock()
+
+	if failed >= s.threshold {
+		_, _ = s.Promote(fmt.Sprintf("the active failed %d health checks in a row: %v", failed, err))
+	}
+}
+
+// sync copies the configuration of the active and records the outcome in the status.
+func (s *HAService) sync() {
+	err := s.syncFromPeer()
+	now := time.Now()
+	s.mu.Lock()
+	s.status.LastSyncAt = &now
+	s.status.LastSyncError = ""
+	if err != nil {
+		s.status.LastSyncError = err.Error()
+	}
+	s.mu.Unlock()
+	if err != nil {
+		log.Printf("High availability: failed to sync the configuration from %s: %v", s.peer.BaseURL, err)
+	}
+}
+
+// syncFromPeer applies the bastions and mappings of every workspace of the active here, pruning
+// the ones the active no longer has. Workspaces the active has emptied are left alone, as an apply
+// cannot prune to nothing.
+func (s *HAService) syncFromPeer() error {
+	ctx, cancel := context.WithTimeout(context.Background(), haSyncTimeout)
+	defer cancel()
+
+	workspaces, err := s.peer.ListWorkspaces(ctx)
+	if err != nil {
+		return err
+	}
+	for _, ws := range workspaces {
+		peer := *s.peer
+		peer.Workspace = ws.Name
+		req, err := remoteConfig(ctx, &peer)
+		if err != nil {
+			return fmt.Errorf("workspace %s: %w", ws.Name, err)
+		}
+		if len(req.Bastions) == 0 && len(req.Mappings) == 0 {
+			continue
+		}
+		result, err := s.mapping.InWorkspace(ws.Name).As(haSyncActor).Apply(req)
+		if err != nil {
+			return fmt.Errorf("workspace %s: %w", ws.Name, err)
+		}
+		for _, change := range result.Changes {
+			if change.Action == models.ApplyActionFailed {
+				return fmt.Errorf("workspace %s: %s %s: %s (nothing was changed)", ws.Name, change.Resource, change.Name, change.Error)
+			}
+		}
+	}
+	return nil
+}
+
+// remoteConfig reads the bastions and mappings of remote's workspace as the apply request that
+// reproduces them. The list responses share the JSON fields of the create requests; mappings are
+// read with their upstream proxy passwords.
+func remoteConfig(ctx context.Context, remote *bastionclient.Client) (models.ApplyRequest, error) {
+	req := models.ApplyRequest{Prune: true}
+	bastions, err := remote.ListBastions(ctx)
+	if err != nil {
+		return req, err
+	}
+	mappings, err := remote.ListMappingsWithSecrets(ctx)
+	if err != nil {
+		return req, err
+	}
+	if err := convertJSON(bastions, &req.Bastions); err != nil {
+		return req, err
+	}
+	if err := convertJSON(mappings, &req.Mappings); err != nil {
+		return req, err
+	}
+	// Versions count the remote's updates, not ours.
+	for i := range req.Bastions {
+		req.Bastions[i].Version = 0
+	}
+	for i := range req.Mappings {
+		req.Mappings[i].Version = 0
+	}
+	return req, nil
+}
+
+func convertJSON(from, to interface{}) error {
+	data, err := json.Marshal(from)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, to)
+}
@@ -0,0 +1,317 @@
+package service
+
+import (
+	"bastion/bastionclient"
+	"bastion/config"
+	"bastion/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeHAPeer is the other instance of a pair: its health, role changes and configuration.
+type fakeHAPeer struct {
+	mu       sync.Mutex
+	role     string
+	down     bool // the health endpoint answers 503, as a degraded instance does
+	refuse   bool // promote and demote fail
+	bastions map[string][]models.Bastion
+	mappings map[string][]models.MappingRead
+}
+
+func newFakeHAPeer(t *testing.T, role string) (*fakeHAPeer, *httptest.Server) {
+	t.Helper()
+	peer := &fakeHAPeer{role: role, bastions: map[string][]models.Bastion{}, mappings: map[string][]models.MappingRead{}}
+	server := httptest.NewServer(peer)
+	t.Cleanup(server.Close)
+	return peer, server
+}
+
+func (p *fakeHAPeer) set(fn func(p *fakeHAPeer)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	fn(p)
+}
+
+func (p *fakeHAPeer) currentRole() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.role
+}
+
+func (p *fakeHAPeer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	workspace := models.NormalizeWorkspace(r.Header.Get(bastionclient.WorkspaceHeader))
+
+	switch r.URL.Path {
+	case "/api/v2/health":
+		if p.down {
+			writeTestEnvelope(w, http.StatusServiceUnavailable, "SERVICE_UNAVAILABLE", struct{}{})
+			return
+		}
+		writeTestEnvelope(w, http.StatusOK, "OK", bastionclient.Health{Status: "ok", DBHealthy: true, HARole: p.role})
+	case "/api/v2/ha/promote", "/api/v2/ha/demote":
+		if p.refuse {
+			writeTestEnvelope(w, http.StatusConflict, "CONFLICT", struct{}{})
+			return
+		}
+		p.role = models.HARoleActive
+		if strings.HasSuffix(r.URL.Path, "demote") {
+			p.role = models.HARoleStandby
+		}
+		writeTestEnvelope(w, http.StatusOK, "OK", models.HAStatus{Enabled: true, Role: p.role})
+	case "/api/v2/workspaces":
+		workspaces := []models.WorkspaceSummary{{Name: models.DefaultWorkspace}}
+		for name := range p.mappings {
+			if name != models.DefaultWorkspace {
+				workspaces = append(workspaces, models.WorkspaceSummary{Name: name})
+			}
+		}
+		writeTestEnvelope(w, http.StatusOK, "OK", workspaces)
+	case "/api/v2/bastions":
+		writeTestEnvelope(w, http.StatusOK, "OK", append([]models.Bastion{}, p.bastions[workspace]...))
+	case "/api/v2/mappings":
+		if r.URL.Query().Get("secrets") != "true" {
+			// The standby would copy masked upstream proxy passwords.
+			writeTestEnvelope(w, http.StatusOK, "INVALID_REQUEST", nil)
+			return
+		}
+		writeTestEnvelope(w, http.StatusOK, "OK", append([]models.MappingRead{}, p.mappings[workspace]...))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func writeTestEnvelope(w http.ResponseWriter, status int, code string, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"code": code, "message": code, "data": data})
+}
+
+// newTestHAService returns the HA service of a fresh database running as role with peerURL as its
+// peer. Its checks time out after an hour and only run when a test calls them.
+func newTestHAService(t *testing.T, role, peerURL string) (*HAService, *Services) {
+	t.Helper()
+	settings := *config.Settings
+	settings.HARole, settings.HAPeerURL = role, peerURL
+	settings.HAFailoverAfter, settings.HASyncIntervalSec = 3, 0
+	svc := newTestServices(t)
+	ha := NewHAService(svc.Mapping, &settings)
+	ha.checkInterval = time.Hour
+	t.Cleanup(func() {
+		ha.mu.Lock()
+		ha.stopStandbyLocked()
+		ha.mu.Unlock()
+	})
+	return ha, svc
+}
+
+func TestHAService_PromotesAfterThreshold(t *testing.T) {
+	peer, server := newFakeHAPeer(t, models.HARoleActive)
+	ha, _ := newTestHAService(t, models.HARoleStandby, server.URL)
+	stop := make(chan struct{})
+
+	peer.set(func(p *fakeHAPeer) { p.down = true })
+	ha.check(stop)
+	ha.check(stop)
+	if status := ha.Status(); status.Role != models.HARoleStandby || status.FailedChecks != 2 || status.PeerHealthy {
+		t.Fatalf("after 2 failed checks: %+v", status)
+	}
+
+	// A healthy answer starts the count over.
+	peer.set(func(p *fakeHAPeer) { p.down = false })
+	ha.check(stop)
+	if status := ha.Status(); status.FailedChecks != 0 || !status.PeerHealthy || status.PeerRole != models.HARoleActive {
+		t.Fatalf("after a healthy check: %+v", status)
+	}
+
+	peer.set(func(p *fakeHAPeer) { p.down = true })
+	for i := 0; i < 3; i++ {
+		ha.check(stop)
+	}
+	status := ha.Status()
+	if status.Role != models.HARoleActive || !ha.Active() || !strings.Contains(status.RoleReason, "3 health checks") {
+		t.Fatalf("after 3 failed checks: %+v", status)
+	}
+}
+
+func TestHAService_FailoverFromStandby(t *testing.T) {
+	t.Run("peer refuses", func(t *testing.T) {
+		peer, server := newFakeHAPeer(t, models.HARoleActive)
+		peer.refuse = true
+		ha, _ := newTestHAService(t, models.HARoleStandby, server.URL)
+
+		if _, err := ha.Failover(context.Background()); !errors.Is(err, ErrHAPeerFailed) {
+			t.Fatalf("Failover = %v, want ErrHAPeerFailed", err)
+		}
+		if ha.Active() || peer.currentRole() != models.HARoleActive {
+			t.Fatalf("roles changed: this=%s peer=%s", ha.Status().Role, peer.currentRole())
+		}
+	})
+
+	t.Run("peer unreachable", func(t *testing.T) {
+		server := httptest.NewServer(http.NotFoundHandler())
+		server.Close()
+		ha, _ := newTestHAService(t, models.HARoleStandby, server.URL)
+
+		status, err := ha.Failover(context.Background())
+		if err != nil {
+			t.Fatalf("Failover: %v", err)
+		}
+		if status.Role != models.HARoleActive || !strings.Contains(status.RoleReason, "could not be reached") {
+			t.Fatalf("status = %+v", status)
+		}
+	})
+
+	t.Run("peer steps down", func(t *testing.T) {
+		peer, server := newFakeHAPeer(t, models.HARoleActive)
+		ha, _ := newTestHAService(t, models.HARoleStandby, server.URL)
+
+		status, err := ha.Failover(context.Background())
+		if err != nil {
+			t.Fatalf("Failover: %v", err)
+		}
+		if status.Role != models.HARoleActive || peer.currentRole() != models.HARoleStandby {
+			t.Fatalf("this=%s peer=%s, want active and standby", status.Role, peer.currentRole())
+		}
+	})
+}
+
+func TestHAService_FailoverFromActive(t *testing.T) {
+	peer, server := newFakeHAPeer(t, models.HARoleStandby)
+	peer.refuse = true
+	ha, _ := newTestHAService(t, models.HARoleActive, server.URL)
+
+	if _, err := ha.Failover(context.Background()); !errors.Is(err, ErrHAPeerFailed) {
+		t.Fatalf("Failover = %v, want ErrHAPeerFailed", err)
+	}
+	status := ha.Status()
+	if status.Role != models.HARoleActive || !strings.Contains(status.RoleReason, "failover reverted") {
+		t.Fatalf("status = %+v, want the active role back", status)
+	}
+	ha.mu.Lock()
+	checking := ha.stop != nil
+	ha.mu.Unlock()
+	if checking {
+		t.Fatalf("the reverted active still runs the standby loop")
+	}
+
+	peer.set(func(p *fakeHAPeer) { p.refuse = false })
+	status, err := ha.Failover(context.Background())
+	if err != nil {
+		t.Fatalf("Failover: %v", err)
+	}
+	if status.Role != models.HARoleStandby || peer.currentRole() != models.HARoleActive {
+		t.Fatalf("this=%s peer=%s, want standby and active", status.Role, peer.currentRole())
+	}
+}
+
+func TestHAService_StartRejoins(t *testing.T) {
+	t.Run("peer active", func(t *testing.T) {
+		_, server := newFakeHAPeer(t, models.HARoleActive)
+		ha, _ := newTestHAService(t, models.HARoleActive, server.URL)
+		if err := ha.Start(); err != nil {
+			t.Fatalf("Start: %v", err)
+		}
+		if status := ha.Status(); status.Role != models.HARoleStandby || status.RoleReason != haRejoinReason {
+			t.Fatalf("status = %+v, want rejoined as standby", status)
+		}
+	})
+
+	t.Run("peer standby", func(t *testing.T) {
+		_, server := newFakeHAPeer(t, models.HARoleStandby)
+		ha, _ := newTestHAService(t, models.HARoleActive, server.URL)
+		if err := ha.Start(); err != nil {
+			t.Fatalf("Start: %v", err)
+		}
+		if !ha.Active() {
+			t.Fatalf("status = %+v, want active", ha.Status())
+		}
+	})
+
+	t.Run("peer unreachable at startup", func(t *testing.T) {
+		peer, server := newFakeHAPeer(t, models.HARoleActive)
+		peer.down = true
+		ha, _ := newTestHAService(t, models.HARoleActive, server.URL)
+		ha.checkInterval = 10 * time.Millisecond
+		if err := ha.Start(); err != nil {
+			t.Fatalf("Start: %v", err)
+		}
+		if !ha.Active() {
+			t.Fatalf("status = %+v, want active while the peer is down", ha.Status())
+		}
+
+		peer.set(func(p *fakeHAPeer) { p.down = false })
+		deadline := time.Now().Add(5 * time.Second)
+		for ha.Active() {
+			if time.Now().After(deadline) {
+				t.Fatalf("still active after the peer came back as active")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		if status := ha.Status(); status.RoleReason != haRejoinReason {
+			t.Fatalf("status = %+v", status)
+		}
+	})
+}
+
+func TestHAService_SyncFromPeer(t *testing.T) {
+	peer, server := newFakeHAPeer(t, models.HARoleActive)
+	ha, svc := newTestHAService(t, models.HARoleStandby, server.URL)
+
+	if _, err := svc.Mapping.Create(models.MappingCreate{ID: "old", LocalPort: 15001, RemoteHost: "10.0.0.9", RemotePort: 22}); err != nil {
+		t.Fatalf("create mapping: %v", err)
+	}
+	jump := models.Bastion{Name: "jump", Host: "10.0.0.1", Port: 22, Username: "ops"}
+	db := models.MappingRead{ID: "db", LocalHost: "127.0.0.1", LocalPort: 15432, RemoteHost: "db.internal", RemotePort: 5432, Chain: []string{"jump"}, Type: "tcp"}
+
+	// A failed item rolls the whole sync back.
+	broken := db
+	broken.Chain = []string{"missing"}
+	peer.set(func(p *fakeHAPeer) {
+		p.bastions[models.DefaultWorkspace] = []models.Bastion{jump}
+		p.mappings[models.DefaultWorkspace] = []models.MappingRead{broken}
+	})
+	if err := ha.syncFromPeer(); err == nil || !strings.Contains(err.Error(), "nothing was changed") {
+		t.Fatalf("syncFromPeer = %v, want a failed item", err)
+	}
+	if ids := testMappingIDs(t, svc.Mapping); strings.Join(ids, ",") != "old" {
+		t.Fatalf("mappings after a failed sync = %v, want [old]", ids)
+	}
+	if bastions, _ := svc.Bastion.List(); len(bastions) != 0 {
+		t.Fatalf("bastions after a failed sync = %+v", bastions)
+	}
+
+	peer.set(func(p *fakeHAPeer) { p.mappings[models.DefaultWorkspace] = []models.MappingRead{db} })
+	ha.sync()
+	if status := ha.Status(); status.LastSyncAt == nil || status.LastSyncError != "" {
+		t.Fatalf("status = %+v", status)
+	}
+	if ids := testMappingIDs(t, svc.Mapping); strings.Join(ids, ",") != "db" {
+		t.Fatalf("mappings after sync = %v, want [db] with old pruned", ids)
+	}
+	m, err := svc.Mapping.Get("db")
+	if err != nil || m.RemoteHost != "db.internal" || strings.Join(m.GetChain(), ",") != "jump" {
+		t.Fatalf("synced mapping = %+v, %v", m, err)
+	}
+}
+
+func testMappingIDs(t *testing.T, mapping *MappingService) []string {
+	t.Helper()
+	mappings, err := mapping.List()
+	if err != nil {
+		t.Fatalf("list mappings: %v", err)
+	}
+	ids := make([]string, 0, len(mappings))
+	for _, m := range mappings {
+		ids = append(ids, m.ID)
+	}
+	return ids
+}
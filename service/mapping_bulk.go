@@ -0,0 +1,139 @@
+package service
+
+import (
+	"bastion/models"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// MaxBulkMappings bounds the number of items of one bulk request.
+const MaxBulkMappings = 500
+
+// ErrInvalidBulkRequest is returned for empty or oversized bulk requests.
+var ErrInvalidBulkRequest = errors.New("invalid bulk request")
+
+// errBulkItemFailed rolls back an atomic bulk transaction.
+var errBulkItemFailed = errors.New("bulk item failed")
+
+// inTx returns a copy of the service (and of its bastion service) whose queries run in tx. Their
+// configuration audit entries and runtime cleanups are collected in *deferred, because the audit
+// store writes through its own connection and a rollback must not lose runtime state: run them
+// once the transaction has committed.
+func (s *MappingService) inTx(tx *gorm.DB, deferred *[]func()) *MappingService {
+	scoped := *s
+	scoped.db = tx
+	scoped.bastionSvc = s.bastionSvc.inTx(tx, deferred)
+	scoped.deferred = deferred
+	return &scoped
+}
+
+// Bulk creates the mappings of req that do not exist and updates those that do, matched by ID (an
+// item without one gets the ID Create would give it). Creates and updates follow the rules of
+// Create and Update, so running mappings cannot be updated. Items are applied in order and each
+// reports its own result. With req.Atomic all items run in one transaction that is rolled back
+// when any of them fails.
+func (s *MappingService) Bulk(req models.MappingBulkRequest) (*models.MappingBulkResponse, error) {
+	if len(req.Mappings) == 0 {
+		return nil, wrapSentinel("no mappings given", ErrInvalidBulkRequest)
+	}
+	if len(req.Mappings) > MaxBulkMappings {
+		return nil, wrapSentinel(fmt.Sprintf("too many mappings: %d (max %d)", len(req.Mappings), MaxBulkMappings), ErrInvalidBulkRequest)
+	}
+
+	resp := &models.MappingBulkResponse{Atomic: req.Atomic, Results: make([]models.MappingBulkResult, len(req.Mappings))}
+	apply := func(svc *MappingService) bool {
+		seen := make(map[string]int, len(req.Mappings))
+		ok := true
+		for i, item := range req.Mappings {
+			resp.Results[i] = svc.bulkItem(i, item, seen)
+			if resp.Results[i].Action == models.BulkActionFailed {
+				ok = false
+			}
+		}
+		return ok
+	}
+
+	if !req.Atomic {
+		apply(s)
+		resp.Committed = true
+	} else {
+		var deferred []func()
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			if !apply(s.inTx(tx, &deferred)) {
+				return errBulkItemFailed
+			}
+			return nil
+		})
+		switch {
+		case err == nil:
+			resp.Committed = true
+			for _, record := range deferred {
+				record()
+			}
+		case errors.Is(err, errBulkItemFailed):
+			for i := range resp.Results {
+				if resp.Results[i].Action != models.BulkActionFailed {
+					resp.Results[i].Action = models.BulkActionRolledBack
+					resp.Results[i].Version = 0
+				}
+			}
+		default:
+			return nil, fmt.Errorf("failed to apply mappings: %w", err)
+		}
+	}
+
+	for _, r := range resp.Results {
+		switch r.Action {
+		case models.BulkActionCreated:
+			resp.Created++
+		case models.BulkActionUpdated:
+			resp.Updated++
+		case models.BulkActionFailed:
+			resp.Failed++
+		}
+	}
+	return resp, nil
+}
+
+// bulkItem creates or updates one item of a bulk request. seen maps the IDs handled so far to
+// their index, so an ID cannot be changed twice in one request.
+func (s *MappingService) bulkItem(index int, req models.MappingCreate, seen map[string]int) models.MappingBulkResult {
+	result := models.MappingBulkResult{Index: index, Action: models.BulkActionFailed}
+	fail := func(err error) models.MappingBulkResult {
+		result.Error = err.Error()
+		return result
+	}
+
+	req.Normalize()
+	id, err := newMappingID(req)
+	if err != nil {
+		return fail(err)
+	}
+	result.ID = id
+	if prev, dup := seen[id]; dup {
+		return fail(fmt.Errorf("duplicate mapping id %q (also item %d)", id, prev))
+	}
+	seen[id] = index
+	req.ID = id
+
+	_, err = s.Get(id)
+	switch {
+	case err == nil:
+		mapping, err := s.Update(id, req)
+		if err != nil {
+			return fail(err)
+		}
+		result.Action, result.Version = models.BulkActionUpdated, mapping.Version
+	case errors.Is(err, ErrMappingNotFound):
+		mapping, err := s.Create(req)
+		if err != nil {
+			return fail(err)
+		}
+		result.Action, result.Version = models.BulkActionCreated, mapping.Version
+	default:
+		return fail(err)
+	}
+	return result
+}
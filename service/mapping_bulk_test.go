@@ -0,0 +1,67 @@
+package service
+
+import (
+	"bastion/config"
+	"bastion/models"
+	"testing"
+)
+
+func TestMappingService_BulkAtomicRollsBack(t *testing.T) {
+	// The transaction holds the only connection of a default pool: recording the audit entries
+	// before it ends would deadlock.
+	prev := config.Settings.SQLiteMaxOpenConns
+	t.Cleanup(func() { config.Settings.SQLiteMaxOpenConns = prev })
+	config.Settings.SQLiteMaxOpenConns = 1
+	svc := newTestServices(t)
+	existing, err := svc.Mapping.Create(models.MappingCreate{ID: "db", LocalPort: 15432, RemoteHost: "db.internal", RemotePort: 5432})
+	if err != nil {
+		t.Fatalf("create existing mapping: %v", err)
+	}
+	_, auditBefore, err := svc.ConfigAudit.List(models.ConfigAuditFilter{}, 1, 1)
+	if err != nil {
+		t.Fatalf("list config audit: %v", err)
+	}
+
+	resp, err := svc.Mapping.Bulk(models.MappingBulkRequest{Atomic: true, Mappings: []models.MappingCreate{
+		{ID: "web", LocalPort: 18080, RemoteHost: "web.internal", RemotePort: 80},
+		{ID: "bad", LocalPort: 70000, RemoteHost: "bad.internal", RemotePort: 80},
+		{ID: "db", LocalPort: 15432, RemoteHost: "db.internal", RemotePort: 5432, AutoStart: true},
+	}})
+	if err != nil {
+		t.Fatalf("Bulk: %v", err)
+	}
+	if resp.Committed || resp.Failed != 1 || resp.Created != 0 || resp.Updated != 0 {
+		t.Fatalf("response = %+v", resp)
+	}
+	for i, want := range []string{models.BulkActionRolledBack, models.BulkActionFailed, models.BulkActionRolledBack} {
+		if r := resp.Results[i]; r.Action != want || (want == models.BulkActionRolledBack && r.Version != 0) {
+			t.Fatalf("result %d = %+v, want %s", i, r, want)
+		}
+	}
+
+	if _, err := svc.Mapping.Get("web"); err == nil {
+		t.Fatal("created mapping of the rolled back request was persisted")
+	}
+	db, err := svc.Mapping.Get("db")
+	if err != nil {
+		t.Fatalf("get existing mapping: %v", err)
+	}
+	if db.AutoStart || db.Version != existing.Version {
+		t.Fatalf("update of the rolled back request was persisted: %+v", db)
+	}
+	if _, auditAfter, _ := svc.ConfigAudit.List(models.ConfigAuditFilter{}, 1, 1); auditAfter != auditBefore {
+		t.Fatalf("config audit entries = %d, want %d: the rolled back request was recorded", auditAfter, auditBefore)
+	}
+
+	// Without the failing item the same request commits and is recorded.
+	resp, err = svc.Mapping.Bulk(models.MappingBulkRequest{Atomic: true, Mappings: []models.MappingCreate{
+		{ID: "web", LocalPort: 18080, RemoteHost: "web.internal", RemotePort: 80},
+		{ID: "db", LocalPort: 15432, RemoteHost: "db.internal", RemotePort: 5432, AutoStart: true},
+	}})
+	if err != nil || !resp.Committed || resp.Created != 1 || resp.Updated != 1 {
+		t.Fatalf("Bulk = %+v, %v", resp, err)
+	}
+	if _, auditAfter, _ := svc.ConfigAudit.List(models.ConfigAuditFilter{}, 1, 1); auditAfter != auditBefore+2 {
+		t.Fatalf("config audit entries = %d, want %d", auditAfter, auditBefore+2)
+	}
+}
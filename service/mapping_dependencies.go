@@ -0,0 +1,148 @@
+package service
+
+import (
+	"bastion/core"
+	"bastion/models"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrInvalidDependencies is returned for depends_on lists that name the mapping itself, an unknown
+// mapping (when it starts) or form a cycle.
+var ErrInvalidDependencies = errors.New("invalid mapping dependencies")
+
+// dependencyGraph returns the depends_on lists of the workspace's mappings, by mapping ID.
+func (s *MappingService) dependencyGraph() (map[string][]string, error) {
+	var rows []models.Mapping
+	if err := s.scoped().Select("id", "depends_on_json").Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to query mapping dependencies: %w", err)
+	}
+	graph := make(map[string][]string, len(rows))
+	for _, m := range rows {
+		graph[m.ID] = m.GetDependsOn()
+	}
+	return graph, nil
+}
+
+// dependencyClosure returns the part of graph reachable from id. With strict set, a dependency
+// missing from graph is an error.
+func dependencyClosure(graph map[string][]string, id string, strict bool) (map[string][]string, error) {
+	closure := make(map[string][]string)
+	pending := []string{id}
+	for len(pending) > 0 {
+		cur := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if _, seen := closure[cur]; seen {
+			continue
+		}
+		closure[cur] = graph[cur]
+		for _, dep := range graph[cur] {
+			if _, ok := graph[dep]; !ok {
+				if strict {
+					return nil, wrapSentinel(fmt.Sprintf("mapping %q depends on unknown mapping %q", cur, dep), ErrInvalidDependencies)
+				}
+				continue
+			}
+			pending = append(pending, dep)
+		}
+	}
+	return closure, nil
+}
+
+// validateDependsOn checks the depends_on list of mapping id before it is saved: it may not name
+// the mapping itself or close a cycle with the workspace's other mappings. Unknown IDs are accepted
+// (the mapping may be created later) and rejected when the mapping starts.
+func (s *MappingService) validateDependsOn(id string, deps []string) error {
+	if len(deps) == 0 {
+		return nil
+	}
+	seen := make(map[string]bool, len(deps))
+	for _, dep := range deps {
+		switch {
+		case dep == id:
+			return wrapSentinel(fmt.Sprintf("mapping %q cannot depend on itself", id), ErrInvalidDependencies)
+		case strings.Contains(dep, "/"):
+			return wrapSentinel(fmt.Sprintf("invalid dependency %q: dependencies must be in the same workspace", dep), ErrInvalidDependencies)
+		case seen[dep]:
+			return wrapSentinel(fmt.Sprintf("dependency %q is listed twice", dep), ErrInvalidDependencies)
+		}
+		seen[dep] = true
+	}
+
+	graph, err := s.dependencyGraph()
+	if err != nil {
+		return err
+	}
+	graph[id] = deps
+	closure, _ := dependencyClosure(graph, id, false)
+	if _, err := core.OrderByDependencies(closure); err != nil {
+		return wrapSentinel(err.Error(), ErrInvalidDependencies)
+	}
+	return nil
+}
+
+// startDependencies starts the stopped mappings that mapping depends on, directly or not, in
+// dependency order. Each start is audited as by Start.
+func (s *MappingService) startDependencies(mapping *models.Mapping) error {
+	if len(mapping.GetDependsOn()) == 0 {
+		return nil
+	}
+	graph, err := s.dependencyGraph()
+	if err != nil {
+		return err
+	}
+	closure, err := dependencyClosure(graph, mapping.ID, true)
+	if err != nil {
+		return err
+	}
+	order, err := core.OrderByDependencies(closure)
+	if err != nil {
+		return wrapSentinel(err.Error(), ErrInvalidDependencies)
+	}
+
+	for _, dep := range order {
+		if dep == mapping.ID || s.state.SessionExists(s.key(dep)) {
+			continue
+		}
+		if err := s.start(dep); err != nil {
+			if errors.Is(err, ErrMappingAlreadyRunning) {
+				continue
+			}
+			// Not wrapped: the dependency's own error types must not describe this mapping.
+			return fmt.Errorf("dependency %q of mapping %q failed to start: %v", dep, mapping.ID, err)
+		}
+		s.recordChange(models.ConfigAuditStart, dep, runningSnapshot(false), runningSnapshot(true))
+	}
+	return nil
+}
+
+// renameDependency replaces oldID by newID in the depends_on lists of the workspace's mappings,
+// bumping their version, when a mapping is renamed.
+func renameDependency(tx *gorm.DB, workspace, oldID, newID string) error {
+	var dependents []models.Mapping
+	if err := tx.Where("workspace = ? AND depends_on_json LIKE ?", workspace, "%\""+oldID+"\"%").Find(&dependents).Error; err != nil {
+		return err
+	}
+	for _, m := range dependents {
+		deps := m.GetDependsOn()
+		changed := false
+		for i, dep := range deps {
+			if dep == oldID {
+				deps[i], changed = newID, true
+			}
+		}
+		if !changed {
+			continue
+		}
+		m.SetDependsOn(deps)
+		if err := tx.Model(&models.Mapping{}).Where("workspace = ? AND id = ?", workspace, m.ID).
+			Updates(map[string]interface{}{"depends_on_json": m.DependsOnJSON, "version": gorm.Expr("version + 1"), "updated_at": time.Now()}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"bastion/core"
+	"bastion/database"
+	"bastion/models"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrInvalidMappingID = errors.New("invalid mapping id")
+
+// Rename changes the ID of mapping id to newID, for instance when a generated host:port ID no
+// longer describes the mapping. Its configuration, lifecycle events and lifetime traffic totals
+// move with it in one transaction; a running mapping is stopped first and started again under the
+// new ID. version, when not 0, must match the mapping's current version.
+func (s *MappingService) Rename(id, newID string, version int) (*models.Mapping, error) {
+	newID = strings.TrimSpace(newID)
+	if newID == "" {
+		return nil, wrapSentinel("new mapping id must not be empty", ErrInvalidMappingID)
+	}
+	if strings.Contains(newID, "/") {
+		return nil, wrapSentinel(fmt.Sprintf("invalid mapping id %q: '/' is not allowed", newID), ErrInvalidMappingID)
+	}
+
+	mapping, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if newID == id {
+		return mapping, nil
+	}
+	if version > 0 && version != mapping.Version {
+		return nil, wrapSentinel(fmt.Sprintf("version conflict: renaming version %d, current version is %d", version, mapping.Version), ErrVersionConflict)
+	}
+	if _, err := s.Get(newID); err == nil {
+		return nil, ErrMappingAlreadyExists
+	} else if !errors.Is(err, ErrMappingNotFound) {
+		return nil, err
+	}
+
+	running := s.state.SessionExists(s.key(id))
+	if running {
+		if err := s.stop(id); err != nil && !errors.Is(err, ErrMappingNotRunning) {
+			return nil, err
+		}
+	}
+	// Persist pending totals under the old key, so the rows moved below are complete.
+	core.Usage.Flush()
+
+	before := mappingSnapshot(mapping)
+	oldKey, newKey := s.key(id), s.key(newID)
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.Mapping{}).
+			Where("workspace = ? AND id = ? AND version = ?", s.Workspace(), id, mapping.Version).
+			Updates(map[string]interface{}{"id": newID, "version": mapping.Version + 1, "updated_at": time.Now()})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return wrapSentinel("version conflict: modified concurrently, reload and retry", ErrVersionConflict)
+		}
+		if err := renameDependency(tx, s.Workspace(), id, newID); err != nil {
+			return err
+		}
+		return database.RenameMappingKey(tx, oldKey, newKey)
+	})
+	if err != nil {
+		if running {
+			_ = s.start(id)
+		}
+		if errors.Is(err, ErrVersionConflict) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to rename mapping: %w", err)
+	}
+
+	core.Usage.Rename(oldKey, newKey)
+	core.MappingEvents.Rename(oldKey, newKey)
+	core.MappingEvents.Record(newKey, core.MappingEventRenamed, "renamed from "+id, "")
+
+	renamed, err := s.Get(newID)
+	if err != nil {
+		return nil, err
+	}
+	s.recordChange(models.ConfigAuditRename, newID, before, mappingSnapshot(renamed))
+
+	if running {
+		if err := s.start(newID); err != nil {
+			return renamed, wrapSentinel("mapping renamed but failed to restart: "+err.Error(), ErrMappingRestartFailed)
+		}
+	}
+	return renamed, nil
+}
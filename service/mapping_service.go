@@ -4,10 +4,18 @@ import (
 	"bastion/core"
 	"bastion/models"
 	"bastion/state"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -17,6 +25,11 @@ var ErrMappingRunning = errors.New("mapping is running")
 var ErrMappingNotFound = errors.New("mapping not found")
 var ErrMappingAlreadyRunning = errors.New("mapping is already running")
 var ErrMappingNotRunning = errors.New("mapping is not running")
+var ErrInvalidDryRunTarget = errors.New("invalid dry-run target")
+var ErrExposeNotConfirmed = errors.New("expose not confirmed")
+var ErrInvalidExposeAddr = errors.New("invalid expose address")
+var ErrTargetUnresolved = errors.New("mapping target unresolved")
+var ErrMappingRestartFailed = errors.New("mapping restart failed")
 
 type sentinelError struct {
 	msg      string
@@ -37,60 +50,248 @@ func wrapSentinel(msg string, sentinel error) error {
 
 // MappingService handles mapping business logic
 type MappingService struct {
-	db         *gorm.DB
-	state      *state.AppState
-	bastionSvc *BastionService
+	db          *gorm.DB
+	state       *state.AppState
+	bastionSvc  *BastionService
+	configAudit *ConfigAuditService
+	workspace   string // see InWorkspace; empty means the default workspace
+	actor       Actor  // see As; recorded in the configuration audit trail
+	secrets     bool   // see WithSecrets
+
+	// deferred collects the side effects (audit entries, forgotten runtime state) of a service
+	// bound to a transaction (see inTx) until it commits.
+	deferred *[]func()
 }
 
 // NewMappingService constructs a mapping service
-func NewMappingService(db *gorm.DB, appState *state.AppState, bastionSvc *BastionService) *MappingService {
+func NewMappingService(db *gorm.DB, appState *state.AppState, bastionSvc *BastionService, configAudit *ConfigAuditService) *MappingService {
 	return &MappingService{
-		db:         db,
-		state:      appState,
-		bastionSvc: bastionSvc,
+		db:          db,
+		state:       appState,
+		bastionSvc:  bastionSvc,
+		configAudit: configAudit,
 	}
 }
 
+// Workspace returns the workspace the service operates on.
+func (s *MappingService) Workspace() string {
+	return models.NormalizeWorkspace(s.workspace)
+}
+
+// InWorkspace returns a copy of the service scoped to workspace. Mapping IDs passed to the copy are
+// resolved within that workspace; runtime state (sessions, events) is keyed by models.WorkspaceKey.
+func (s *MappingService) InWorkspace(workspace string) *MappingService {
+	return &MappingService{
+		db:          s.db,
+		state:       s.state,
+		bastionSvc:  s.bastionSvc.InWorkspace(workspace),
+		configAudit: s.configAudit,
+		workspace:   workspace,
+		actor:       s.actor,
+		secrets:     s.secrets,
+	}
+}
+
+// WithSecrets returns a copy of the service whose reads show the upstream proxy passwords they
+// otherwise mask, for the clients that copy the configuration (HA standby, agents).
+func (s *MappingService) WithSecrets() *MappingService {
+	scoped := *s
+	scoped.secrets = true
+	return &scoped
+}
+
+// As returns a copy of the service that records its changes as made by actor.
+func (s *MappingService) As(actor Actor) *MappingService {
+	scoped := *s
+	scoped.bastionSvc = s.bastionSvc.As(actor)
+	scoped.actor = actor
+	return &scoped
+}
+
+// recordChange adds a mapping change to the configuration audit trail.
+func (s *MappingService) recordChange(action, id string, before, after map[string]interface{}) {
+	workspace, actor := s.Workspace(), s.actor
+	s.onCommit(func() {
+		s.configAudit.Record(workspace, models.ConfigAuditMapping, id, action, actor, before, after)
+	})
+}
+
+// onCommit runs fn now, or once the transaction of a service bound by inTx has committed.
+func (s *MappingService) onCommit(fn func()) {
+	if s.deferred != nil {
+		*s.deferred = append(*s.deferred, fn)
+		return
+	}
+	fn()
+}
+
+// scoped restricts a query to the service's workspace.
+func (s *MappingService) scoped() *gorm.DB {
+	return s.db.Where("workspace = ?", s.Workspace())
+}
+
+// key returns the runtime key of mapping id in the service's workspace.
+func (s *MappingService) key(id string) string {
+	return models.WorkspaceKey(s.Workspace(), id)
+}
+
 // List returns all mappings (including runtime status)
 func (s *MappingService) List() ([]models.MappingRead, error) {
 	var mappings []models.Mapping
-	if err := s.db.Find(&mappings).Error; err != nil {
+	if err := s.scoped().Order("id").Find(&mappings).Error; err != nil {
 		return nil, fmt.Errorf("failed to list mappings: %w", err)
 	}
 
 	// Collect running sessions
 	s.state.RLock()
-	runningIDs := make(map[string]bool)
-	for id := range s.state.Sessions {
-		runningIDs[id] = true
+	sessions := make(map[string]core.Session, len(s.state.Sessions))
+	for id, session := range s.state.Sessions {
+		sessions[id] = session
 	}
 	s.state.RUnlock()
 
 	// Build response objects
 	result := make([]models.MappingRead, len(mappings))
 	for i, m := range mappings {
-		result[i] = models.MappingRead{
-			ID:         m.ID,
-			LocalHost:  m.LocalHost,
-			LocalPort:  m.LocalPort,
-			RemoteHost: m.RemoteHost,
-			RemotePort: m.RemotePort,
-			Chain:      m.GetChain(),
-			AllowCIDRs: m.GetAllowCIDRs(),
-			DenyCIDRs:  m.GetDenyCIDRs(),
-			Type:       m.Type,
-			AutoStart:  m.AutoStart,
-			Running:    runningIDs[m.ID],
-		}
+		result[i] = s.read(m, sessions[m.Key()])
 	}
 
 	return result, nil
 }
 
+// read builds the response object of m; session is its running session, if any.
+func (s *MappingService) read(m models.Mapping, session core.Session) models.MappingRead {
+	read := models.MappingRead{
+		ID:          m.ID,
+		Workspace:   m.Workspace,
+		LocalHost:   m.LocalHost,
+		LocalPort:   m.LocalPort,
+		RemoteHost:  m.RemoteHost,
+		RemotePort:  m.RemotePort,
+		Chain:       m.GetChain(),
+		AllowCIDRs:  m.GetAllowCIDRs(),
+		DenyCIDRs:   m.GetDenyCIDRs(),
+		TargetAllow: m.GetTargetAllow(),
+		TargetDeny:  m.GetTargetDeny(),
+		Type:        m.Type,
+		AutoStart:   m.AutoStart,
+		Running:     session != nil,
+		State:       mappingState(session),
+
+		RemotePortTemplate: m.RemotePortTemplate,
+
+		DependsOn: m.GetDependsOn(),
+
+		RequestHeaders: m.GetRequestHeaders(),
+
+		UpstreamProxy: m.UpstreamProxy,
+
+		MaxConnsPerIP:  m.MaxConnsPerIP,
+		ConnRatePerIP:  m.ConnRatePerIP,
+		ConnBurstPerIP: m.ConnBurstPerIP,
+
+		DialTimeoutSeconds: m.DialTimeoutSeconds,
+		DialRetries:        m.DialRetries,
+		DialRetryDelayMS:   m.DialRetryDelayMS,
+		DialBackoff:        m.DialBackoff,
+		DialHoldSeconds:    m.DialHoldSeconds,
+		DialHappyEyeballs:  m.DialHappyEyeballs,
+
+		AuditDisabled:   m.AuditDisabled,
+		AuditSampleRate: m.AuditSampleRate,
+
+		Standby:            m.Standby,
+		StandbyIdleSeconds: m.StandbyIdleSeconds,
+
+		QuotaBytesPerDay: m.QuotaBytesPerDay,
+		QuotaAction:      m.QuotaAction,
+		QuotaThrottleBps: m.QuotaThrottleBps,
+
+		ListenFamily: m.ListenFamily,
+
+		FTPHelper: m.FTPHelper,
+
+		TLSMode:               m.TLSMode,
+		TLSCertFile:           m.TLSCertFile,
+		TLSKeyFile:            m.TLSKeyFile,
+		TLSServerName:         m.TLSServerName,
+		TLSCAFile:             m.TLSCAFile,
+		TLSInsecureSkipVerify: m.TLSInsecureSkipVerify,
+
+		K8sKubeconfig: m.K8sKubeconfig,
+		K8sContext:    m.K8sContext,
+		K8sNamespace:  m.K8sNamespace,
+
+		QueryAudit: m.QueryAudit,
+
+		ExposeAddr: m.ExposeAddr,
+		ExposedBy:  m.ExposedBy,
+		ExposedAt:  m.ExposedAt,
+
+		Description: m.Description,
+		Tags:        m.GetTags(),
+
+		Version:   m.Version,
+		UpdatedAt: m.UpdatedAt,
+	}
+	if !s.secrets {
+		read.UpstreamProxy = core.RedactUpstreamProxy(read.UpstreamProxy)
+	}
+	if session != nil {
+		read.RuntimePort = session.GetStats().LocalPort
+	}
+	usage := s.Usage(m.ID)
+	read.TotalBytesUp = usage.BytesUp
+	read.TotalBytesDown = usage.BytesDown
+	read.LastStartedAt = usage.LastStartedAt
+	read.LastActiveAt = usage.LastActiveAt
+	read.Quota = core.MappingQuota(&m)
+	return read
+}
+
+// Read returns the response object of a mapping, including its runtime status
+func (s *MappingService) Read(id string) (*models.MappingRead, error) {
+	mapping, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	session, _ := s.state.GetSession(mapping.Key())
+	read := s.read(*mapping, session)
+	return &read, nil
+}
+
+// Search lists the mappings matching filter (by ID, addresses, chain, description or tags)
+func (s *MappingService) Search(filter models.ListFilter) ([]models.MappingRead, error) {
+	mappings, err := s.List()
+	if err != nil || filter.Empty() {
+		return mappings, err
+	}
+	matched := make([]models.MappingRead, 0, len(mappings))
+	for _, m := range mappings {
+		fields := append([]string{m.ID, m.LocalHost, m.RemoteHost, m.Description}, m.Chain...)
+		if filter.Match(m.Tags, fields...) {
+			matched = append(matched, m)
+		}
+	}
+	return matched, nil
+}
+
+// mappingState describes a mapping's runtime session: "stopped" without one, "standby" while a
+// standby session waits for a client to build its SSH chain, otherwise "running".
+func mappingState(session core.Session) string {
+	if session == nil {
+		return "stopped"
+	}
+	if sb, ok := session.(interface{ InStandby() bool }); ok && sb.InStandby() {
+		return "standby"
+	}
+	return "running"
+}
+
 // Get fetches a mapping by ID
 func (s *MappingService) Get(id string) (*models.Mapping, error) {
 	var mapping models.Mapping
-	if err := s.db.First(&mapping, "id = ?", id).Error; err != nil {
+	if err := s.scoped().First(&mapping, "id = ?", id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return nil, wrapSentinel(fmt.Sprintf("mapping not found: %s", id), ErrMappingNotFound)
 		}
@@ -106,11 +307,11 @@ func (s *MappingService) GetWithStatus(id string) (*models.Mapping, bool, *core.
 		return nil, false, nil, err
 	}
 
-	running := s.state.SessionExists(id)
+	running := s.state.SessionExists(s.key(id))
 	var stats *core.SessionStats
 
 	if running {
-		if session, exists := s.state.GetSession(id); exists {
+		if session, exists := s.state.GetSession(s.key(id)); exists {
 			s := session.GetStats()
 			stats = &s
 		}
@@ -125,10 +326,13 @@ func (s *MappingService) Create(req models.MappingCreate) (*models.Mapping, erro
 	// Normalize inputs
 	req.Normalize()
 
-	// Generate ID
-	id := req.ID
-	if id == "" {
-		id = fmt.Sprintf("%s:%d", req.LocalHost, req.LocalPort)
+	if req.LocalPort < 0 || req.LocalPort > 65535 {
+		return nil, models.FieldErrorf("local_port", "invalid local_port %d: must be 0-65535 (0 picks a free port at start)", req.LocalPort)
+	}
+
+	id, err := newMappingID(req)
+	if err != nil {
+		return nil, err
 	}
 
 	// Apply defaults
@@ -141,14 +345,30 @@ func (s *MappingService) Create(req models.MappingCreate) (*models.Mapping, erro
 	}
 
 	switch req.Type {
-	case "tcp", "socks5", "http", "mixed":
+	case "tcp", "k8s", "socks5", "http", "mixed":
 	default:
-		return nil, fmt.Errorf("invalid mapping type: %s", req.Type)
+		return nil, models.FieldErrorf("type", "invalid mapping type: %s", req.Type)
+	}
+	if err := models.ValidateHost("local_host", req.LocalHost); err != nil {
+		return nil, err
+	}
+	if req.Type == "tcp" {
+		if err := validateRemoteTarget(req.RemoteHost, req.RemotePortTemplate); err != nil {
+			return nil, err
+		}
+		if req.RemotePortTemplate != "" && req.RemotePort != 0 {
+			return nil, models.FieldErrorf("remote_port_template", "remote_port and remote_port_template are mutually exclusive")
+		}
+	} else if req.RemotePortTemplate != "" {
+		return nil, models.FieldErrorf("remote_port_template", "remote_port_template only applies to tcp mappings")
+	}
+	if err := models.ValidateListenFamily(req.ListenFamily, req.LocalHost); err != nil {
+		return nil, err
 	}
 
 	// Ensure mapping does not already exist (no upsert)
 	var existing models.Mapping
-	if err := s.db.First(&existing, "id = ?", id).Error; err == nil {
+	if err := s.scoped().First(&existing, "id = ?", id).Error; err == nil {
 		return nil, ErrMappingAlreadyExists
 	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
 		return nil, fmt.Errorf("failed to check mapping existence: %w", err)
@@ -156,15 +376,60 @@ func (s *MappingService) Create(req models.MappingCreate) (*models.Mapping, erro
 
 	// Create a new mapping
 	mapping := models.Mapping{
-		ID:        id,
-		LocalHost: req.LocalHost,
-		LocalPort: req.LocalPort,
-		Type:      req.Type,
-		AutoStart: req.AutoStart,
+		ID:            id,
+		Workspace:     s.Workspace(),
+		LocalHost:     req.LocalHost,
+		LocalPort:     req.LocalPort,
+		Type:          req.Type,
+		AutoStart:     req.AutoStart,
+		UpstreamProxy: req.UpstreamProxy,
+
+		MaxConnsPerIP:  req.MaxConnsPerIP,
+		ConnRatePerIP:  req.ConnRatePerIP,
+		ConnBurstPerIP: req.ConnBurstPerIP,
+
+		DialTimeoutSeconds: req.DialTimeoutSeconds,
+		DialRetries:        req.DialRetries,
+		DialRetryDelayMS:   req.DialRetryDelayMS,
+		DialBackoff:        req.DialBackoff,
+		DialHoldSeconds:    req.DialHoldSeconds,
+		DialHappyEyeballs:  req.DialHappyEyeballs,
+
+		AuditDisabled:   req.AuditDisabled,
+		AuditSampleRate: req.AuditSampleRate,
+
+		Standby:            req.Standby,
+		StandbyIdleSeconds: req.StandbyIdleSeconds,
+
+		QuotaBytesPerDay: req.QuotaBytesPerDay,
+		QuotaAction:      req.QuotaAction,
+		QuotaThrottleBps: req.QuotaThrottleBps,
+
+		ListenFamily: req.ListenFamily,
+
+		FTPHelper: req.FTPHelper,
+
+		TLSMode:               req.TLSMode,
+		TLSCertFile:           req.TLSCertFile,
+		TLSKeyFile:            req.TLSKeyFile,
+		TLSServerName:         req.TLSServerName,
+		TLSCAFile:             req.TLSCAFile,
+		TLSInsecureSkipVerify: req.TLSInsecureSkipVerify,
+
+		K8sKubeconfig: req.K8sKubeconfig,
+		K8sContext:    req.K8sContext,
+		K8sNamespace:  req.K8sNamespace,
+
+		QueryAudit: req.QueryAudit,
+
+		Description: req.Description,
+		Version:     1,
 	}
-	if req.Type == "tcp" {
+	mapping.SetTags(req.Tags)
+	if req.Type == "tcp" || req.Type == "k8s" {
 		mapping.RemoteHost = req.RemoteHost
 		mapping.RemotePort = req.RemotePort
+		mapping.RemotePortTemplate = req.RemotePortTemplate
 	} else {
 		mapping.RemoteHost = "0.0.0.0"
 		mapping.RemotePort = 0
@@ -172,24 +437,108 @@ func (s *MappingService) Create(req models.MappingCreate) (*models.Mapping, erro
 	mapping.SetChain(req.Chain)
 	mapping.SetAllowCIDRs(req.AllowCIDRs)
 	mapping.SetDenyCIDRs(req.DenyCIDRs)
+	mapping.SetTargetAllow(req.TargetAllow)
+	mapping.SetTargetDeny(req.TargetDeny)
+	mapping.SetDependsOn(req.DependsOn)
+	mapping.SetRequestHeaders(req.RequestHeaders)
 
 	if _, err := core.NewIPAccessControl(req.AllowCIDRs, req.DenyCIDRs); err != nil {
 		return nil, err
 	}
+	if err := s.validateDependsOn(id, req.DependsOn); err != nil {
+		return nil, err
+	}
+	if err := validateTargetRules(req.Type, req.TargetAllow, req.TargetDeny); err != nil {
+		return nil, err
+	}
+	if err := validateRequestHeaders(req.Type, req.RequestHeaders); err != nil {
+		return nil, err
+	}
+	if err := validateFTPHelper(req.Type, req.FTPHelper); err != nil {
+		return nil, err
+	}
+	if err := validateK8s(&mapping); err != nil {
+		return nil, err
+	}
+	if err := core.ValidateQueryAudit(req.Type, req.QueryAudit); err != nil {
+		return nil, models.FieldErrorf("query_audit", "%v", err)
+	}
+	if _, err := core.NewMappingTLS(&mapping); err != nil {
+		return nil, err
+	}
+	if _, err := core.ParseUpstreamProxy(req.UpstreamProxy); err != nil {
+		return nil, err
+	}
+	if err := core.ValidateClientLimits(req.MaxConnsPerIP, req.ConnRatePerIP, req.ConnBurstPerIP); err != nil {
+		return nil, err
+	}
+	if err := core.ValidateDialPolicy(req.DialTimeoutSeconds, req.DialRetries, req.DialRetryDelayMS, req.DialHoldSeconds, req.DialBackoff); err != nil {
+		return nil, err
+	}
+	if err := core.ValidateAuditSampleRate(req.AuditSampleRate); err != nil {
+		return nil, err
+	}
+	if err := core.ValidateStandbyIdleSeconds(req.StandbyIdleSeconds); err != nil {
+		return nil, err
+	}
+	if err := core.ValidateQuota(req.QuotaBytesPerDay, req.QuotaAction, req.QuotaThrottleBps); err != nil {
+		return nil, err
+	}
+	if err := models.ValidateNotes(req.Description, req.Tags); err != nil {
+		return nil, err
+	}
 
 	// Persist to database
 	if err := s.db.Create(&mapping).Error; err != nil {
 		return nil, fmt.Errorf("failed to create mapping: %w", err)
 	}
+	s.recordChange(models.ConfigAuditCreate, mapping.ID, nil, mappingSnapshot(&mapping))
 
 	return &mapping, nil
 }
 
+// newMappingID returns the ID a created mapping gets: req.ID, else local_host:local_port, else
+// (for auto-port mappings) a random local_host:auto-xxxxxxxx.
+func newMappingID(req models.MappingCreate) (string, error) {
+	id := req.ID
+	if id == "" && req.LocalPort == 0 {
+		// host:0 would collide for every auto-port mapping
+		buf := make([]byte, 4)
+		if _, err := rand.Read(buf); err != nil {
+			return "", fmt.Errorf("failed to generate mapping id: %w", err)
+		}
+		host := req.LocalHost
+		if host == "" {
+			host = "127.0.0.1"
+		}
+		id = net.JoinHostPort(host, "auto-"+hex.EncodeToString(buf))
+	} else if id == "" {
+		id = net.JoinHostPort(req.LocalHost, strconv.Itoa(req.LocalPort))
+	}
+	if strings.Contains(id, "/") {
+		return "", fmt.Errorf("invalid mapping id %q: '/' is not allowed", id)
+	}
+	return id, nil
+}
+
 // Update updates a mapping when it is not running.
 // Immutable fields: local/remote host/port and type.
 func (s *MappingService) Update(id string, req models.MappingCreate) (*models.Mapping, error) {
-	// Disallow updates while running
-	if s.state.SessionExists(id) {
+	return s.update(id, req, false, false)
+}
+
+// ForceUpdate updates a mapping like Update but may also change its local and remote address and
+// its type (see setMappingAddress); the ID stays the same, so history and references are kept. A
+// running mapping is refused unless restart is set, in which case it is stopped and started again
+// with the new configuration.
+func (s *MappingService) ForceUpdate(id string, req models.MappingCreate, restart bool) (*models.Mapping, error) {
+	return s.update(id, req, true, restart)
+}
+
+func (s *MappingService) update(id string, req models.MappingCreate, force, restart bool) (*models.Mapping, error) {
+	// Disallow updates while running, unless the mapping may be restarted
+	running := s.state.SessionExists(s.key(id))
+	if running && !(force && restart) {
 		return nil, ErrMappingRunning
 	}
 
@@ -201,73 +550,313 @@ func (s *MappingService) Update(id string, req models.MappingCreate) (*models.Ma
 		return nil, err
 	}
 
+	before := mappingSnapshot(mapping)
+	if force {
+		if req.ID != "" && req.ID != id {
+			return nil, fmt.Errorf("mapping id is immutable")
+		}
+		if err := setMappingAddress(mapping, req); err != nil {
+			return nil, err
+		}
+	} else if err := checkMappingImmutable(id, mapping, req); err != nil {
+		return nil, err
+	}
+	setMappingFields(mapping, req)
+
+	if err := models.ValidateListenFamily(req.ListenFamily, mapping.LocalHost); err != nil {
+		return nil, err
+	}
+
+	if _, err := core.NewIPAccessControl(req.AllowCIDRs, req.DenyCIDRs); err != nil {
+		return nil, err
+	}
+	if err := s.validateDependsOn(id, req.DependsOn); err != nil {
+		return nil, err
+	}
+	if err := validateTargetRules(mapping.Type, req.TargetAllow, req.TargetDeny); err != nil {
+		return nil, err
+	}
+	if err := validateRequestHeaders(mapping.Type, req.RequestHeaders); err != nil {
+		return nil, err
+	}
+	if err := validateFTPHelper(mapping.Type, req.FTPHelper); err != nil {
+		return nil, err
+	}
+	if err := validateK8s(mapping); err != nil {
+		return nil, err
+	}
+	if err := core.ValidateQueryAudit(mapping.Type, req.QueryAudit); err != nil {
+		return nil, models.FieldErrorf("query_audit", "%v", err)
+	}
+	if _, err := core.NewMappingTLS(mapping); err != nil {
+		return nil, err
+	}
+	if _, err := core.ParseUpstreamProxy(req.UpstreamProxy); err != nil {
+		return nil, err
+	}
+	if err := core.ValidateClientLimits(req.MaxConnsPerIP, req.ConnRatePerIP, req.ConnBurstPerIP); err != nil {
+		return nil, err
+	}
+	if err := core.ValidateDialPolicy(req.DialTimeoutSeconds, req.DialRetries, req.DialRetryDelayMS, req.DialHoldSeconds, req.DialBackoff); err != nil {
+		return nil, err
+	}
+	if err := core.ValidateAuditSampleRate(req.AuditSampleRate); err != nil {
+		return nil, err
+	}
+	if err := core.ValidateStandbyIdleSeconds(req.StandbyIdleSeconds); err != nil {
+		return nil, err
+	}
+	if err := core.ValidateQuota(req.QuotaBytesPerDay, req.QuotaAction, req.QuotaThrottleBps); err != nil {
+		return nil, err
+	}
+	if err := models.ValidateNotes(req.Description, req.Tags); err != nil {
+		return nil, err
+	}
+
+	if err := saveVersioned(s.db, mapping, &mapping.Version, req.Version); err != nil {
+		if errors.Is(err, ErrVersionConflict) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to update mapping: %w", err)
+	}
+	s.recordChange(models.ConfigAuditUpdate, mapping.ID, before, mappingSnapshot(mapping))
+
+	if running {
+		if err := s.stop(id); err != nil && !errors.Is(err, ErrMappingNotRunning) {
+			return nil, err
+		}
+		if err := s.start(id); err != nil {
+			return mapping, wrapSentinel("mapping updated but failed to restart: "+err.Error(), ErrMappingRestartFailed)
+		}
+	}
+	return mapping, nil
+}
+
+// setMappingAddress copies the local and remote address and the type of a forced update from req to
+// mapping, validating them as Create does. Empty local_host and type and a zero (omitted) local_port
+// keep the current values.
+func setMappingAddress(mapping *models.Mapping, req models.MappingCreate) error {
+	if req.LocalPort < 0 || req.LocalPort > 65535 {
+		return models.FieldErrorf("local_port", "invalid local_port %d: must be 0-65535 (0 keeps the current port)", req.LocalPort)
+	}
+	if req.LocalHost != "" {
+		if err := models.ValidateHost("local_host", req.LocalHost); err != nil {
+			return err
+		}
+		mapping.LocalHost = req.LocalHost
+	}
+	if req.LocalPort != 0 {
+		mapping.LocalPort = req.LocalPort
+	}
+
+	if req.Type != "" {
+		switch req.Type {
+		case "tcp", "k8s", "socks5", "http", "mixed":
+		default:
+			return models.FieldErrorf("type", "invalid mapping type: %s", req.Type)
+		}
+		mapping.Type = req.Type
+	}
+
+	if mapping.Type == "k8s" {
+		if req.RemotePortTemplate != "" {
+			return models.FieldErrorf("remote_port_template", "remote_port_template only applies to tcp mappings")
+		}
+		mapping.RemoteHost, mapping.RemotePort, mapping.RemotePortTemplate = req.RemoteHost, req.RemotePort, ""
+		return nil
+	}
+	if mapping.Type != "tcp" {
+		if req.RemotePortTemplate != "" {
+			return models.FieldErrorf("remote_port_template", "remote_port_template only applies to tcp mappings")
+		}
+		mapping.RemoteHost, mapping.RemotePort, mapping.RemotePortTemplate = "0.0.0.0", 0, ""
+		return nil
+	}
+	if req.RemoteHost == "" || (req.RemotePort == 0 && req.RemotePortTemplate == "") {
+		return models.FieldErrorf("remote_host", "remote_host and remote_port are required for tcp mapping update")
+	}
+	if req.RemotePortTemplate != "" && req.RemotePort != 0 {
+		return models.FieldErrorf("remote_port_template", "remote_port and remote_port_template are mutually exclusive")
+	}
+	if err := validateRemoteTarget(req.RemoteHost, req.RemotePortTemplate); err != nil {
+		return err
+	}
+	mapping.RemoteHost, mapping.RemotePort, mapping.RemotePortTemplate = req.RemoteHost, req.RemotePort, req.RemotePortTemplate
+	return nil
+}
+
+// checkMappingImmutable rejects an update of mapping id that changes its identity, addresses or type.
+func checkMappingImmutable(id string, mapping *models.Mapping, req models.MappingCreate) error {
 	// ID must match (mapping identity is stable)
 	if req.ID != "" && req.ID != id {
-		return nil, fmt.Errorf("mapping id is immutable")
+		return fmt.Errorf("mapping id is immutable")
 	}
 
 	// Enforce immutable address fields (both local and remote)
 	if req.LocalHost != "" && req.LocalHost != mapping.LocalHost {
-		return nil, fmt.Errorf("local_host is immutable")
+		return fmt.Errorf("local_host is immutable")
 	}
 	if req.LocalPort != 0 && req.LocalPort != mapping.LocalPort {
-		return nil, fmt.Errorf("local_port is immutable")
+		return fmt.Errorf("local_port is immutable")
 	}
 
 	// Type is immutable (changing it changes runtime semantics)
 	if req.Type != "" && req.Type != mapping.Type {
-		return nil, fmt.Errorf("type is immutable")
+		return fmt.Errorf("type is immutable")
 	}
 
 	// Remote host/port are immutable as well (only meaningful for TCP; still enforce if provided).
 	if req.RemoteHost != "" && req.RemoteHost != mapping.RemoteHost {
-		return nil, fmt.Errorf("remote_host is immutable")
+		return fmt.Errorf("remote_host is immutable")
 	}
 	if req.RemotePort != 0 && req.RemotePort != mapping.RemotePort {
-		return nil, fmt.Errorf("remote_port is immutable")
+		return fmt.Errorf("remote_port is immutable")
+	}
+	if req.RemotePortTemplate != mapping.RemotePortTemplate {
+		return fmt.Errorf("remote_port_template is immutable")
 	}
 
-	// For TCP mappings, require remote host/port to be present so we can enforce immutability.
-	if mapping.Type == "tcp" {
-		if req.RemoteHost == "" || req.RemotePort == 0 {
-			return nil, fmt.Errorf("remote_host and remote_port are required for tcp mapping update")
+	// For TCP and k8s mappings, require remote host/port to be present so we can enforce immutability.
+	if mapping.Type == "tcp" || mapping.Type == "k8s" {
+		if req.RemoteHost == "" || (req.RemotePort == 0 && req.RemotePortTemplate == "") {
+			return models.FieldErrorf("remote_host", "remote_host and remote_port are required for %s mapping update", mapping.Type)
 		}
 	}
+	return nil
+}
 
-	// Allowed updates
-	mapping.AutoStart = req.AutoStart
-	mapping.SetChain(req.Chain)
-	mapping.SetAllowCIDRs(req.AllowCIDRs)
-	mapping.SetDenyCIDRs(req.DenyCIDRs)
+// validateTargetRules checks the destination rules of a mapping. tcp and k8s mappings have a fixed
+// destination, so only proxy mappings may have them.
+func validateTargetRules(mappingType string, allow, deny []string) error {
+	if (mappingType == "tcp" || mappingType == "k8s") && (len(allow) > 0 || len(deny) > 0) {
+		return fmt.Errorf("target_allow and target_deny only apply to socks5, http and mixed mappings")
+	}
+	_, err := core.NewTargetAccessControl(allow, deny)
+	return err
+}
 
-	if _, err := core.NewIPAccessControl(req.AllowCIDRs, req.DenyCIDRs); err != nil {
-		return nil, err
+// validateRequestHeaders checks the header rules of http and mixed mappings, the only ones that
+// forward plain HTTP requests.
+func validateRequestHeaders(mappingType string, rules []string) error {
+	if len(rules) > 0 && mappingType != "http" && mappingType != "mixed" {
+		return models.FieldErrorf("request_headers", "request_headers only apply to http and mixed mappings")
+	}
+	if _, err := core.NewHeaderRewriter(rules); err != nil {
+		return models.FieldErrorf("request_headers", "%v", err)
 	}
+	return nil
+}
 
-	if err := s.db.Save(mapping).Error; err != nil {
-		return nil, fmt.Errorf("failed to update mapping: %w", err)
+// validateFTPHelper rejects the FTP helper on proxy mappings, which have no fixed FTP server.
+func validateFTPHelper(mappingType string, enabled bool) error {
+	if enabled && mappingType != "tcp" {
+		return models.FieldErrorf("ftp_helper", "ftp_helper only applies to tcp mappings")
 	}
+	return nil
+}
 
-	return mapping, nil
+// validateK8s checks the pod or service and port of a k8s mapping, and rejects the k8s settings on
+// other types. The kubeconfig is only read when the mapping starts.
+func validateK8s(mapping *models.Mapping) error {
+	if mapping.Type != "k8s" {
+		if mapping.K8sKubeconfig != "" || mapping.K8sContext != "" || mapping.K8sNamespace != "" {
+			return models.FieldErrorf("k8s_kubeconfig", "k8s_kubeconfig, k8s_context and k8s_namespace only apply to k8s mappings")
+		}
+		return nil
+	}
+	if _, err := core.ParseK8sTarget(mapping.RemoteHost); err != nil {
+		return models.FieldErrorf("remote_host", "%v", err)
+	}
+	if mapping.RemotePort < 1 || mapping.RemotePort > 65535 {
+		return models.FieldErrorf("remote_port", "invalid remote_port %d: k8s mappings need the pod or service port (1-65535)", mapping.RemotePort)
+	}
+	if mapping.K8sNamespace != "" {
+		if err := core.ValidateK8sNamespace(mapping.K8sNamespace); err != nil {
+			return models.FieldErrorf("k8s_namespace", "%v", err)
+		}
+	}
+	return nil
+}
+
+// setMappingFields copies the fields an update may change from req to mapping.
+func setMappingFields(mapping *models.Mapping, req models.MappingCreate) {
+	mapping.AutoStart = req.AutoStart
+	mapping.SetChain(req.Chain)
+	mapping.SetAllowCIDRs(req.AllowCIDRs)
+	mapping.SetDenyCIDRs(req.DenyCIDRs)
+	mapping.SetTargetAllow(req.TargetAllow)
+	mapping.SetTargetDeny(req.TargetDeny)
+	mapping.SetDependsOn(req.DependsOn)
+	mapping.SetRequestHeaders(req.RequestHeaders)
+	mapping.UpstreamProxy = core.KeepUpstreamProxyPassword(req.UpstreamProxy, mapping.UpstreamProxy)
+	mapping.MaxConnsPerIP = req.MaxConnsPerIP
+	mapping.ConnRatePerIP = req.ConnRatePerIP
+	mapping.ConnBurstPerIP = req.ConnBurstPerIP
+	mapping.DialTimeoutSeconds = req.DialTimeoutSeconds
+	mapping.DialRetries = req.DialRetries
+	mapping.DialRetryDelayMS = req.DialRetryDelayMS
+	mapping.DialBackoff = req.DialBackoff
+	mapping.DialHoldSeconds = req.DialHoldSeconds
+	mapping.DialHappyEyeballs = req.DialHappyEyeballs
+	mapping.AuditDisabled = req.AuditDisabled
+	mapping.AuditSampleRate = req.AuditSampleRate
+	mapping.Standby = req.Standby
+	mapping.StandbyIdleSeconds = req.StandbyIdleSeconds
+	mapping.QuotaBytesPerDay = req.QuotaBytesPerDay
+	mapping.QuotaAction = req.QuotaAction
+	mapping.QuotaThrottleBps = req.QuotaThrottleBps
+	mapping.ListenFamily = req.ListenFamily
+	mapping.FTPHelper = req.FTPHelper
+	mapping.TLSMode = req.TLSMode
+	mapping.TLSCertFile = req.TLSCertFile
+	mapping.TLSKeyFile = req.TLSKeyFile
+	mapping.TLSServerName = req.TLSServerName
+	mapping.TLSCAFile = req.TLSCAFile
+	mapping.TLSInsecureSkipVerify = req.TLSInsecureSkipVerify
+	mapping.K8sKubeconfig = req.K8sKubeconfig
+	mapping.K8sContext = req.K8sContext
+	mapping.K8sNamespace = req.K8sNamespace
+	mapping.QueryAudit = req.QueryAudit
+	mapping.Description = req.Description
+	mapping.SetTags(req.Tags)
 }
 
 // Delete removes a mapping (stopping it first if running)
 func (s *MappingService) Delete(id string) error {
+	existing, _ := s.Get(id)
+
 	// Stop session if running
-	s.state.RemoveAndStopSession(id)
+	s.state.RemoveAndStopSession(s.key(id))
 
 	// Delete mapping
-	if err := s.db.Delete(&models.Mapping{}, "id = ?", id).Error; err != nil {
+	if err := s.scoped().Delete(&models.Mapping{}, "id = ?", id).Error; err != nil {
 		return fmt.Errorf("failed to delete mapping: %w", err)
 	}
+	key := s.key(id)
+	s.onCommit(func() {
+		core.MappingEvents.Forget(key)
+		core.Usage.Forget(key)
+	})
+	if existing != nil {
+		s.recordChange(models.ConfigAuditDelete, id, mappingSnapshot(existing), nil)
+	}
 
 	return nil
 }
 
 // Start starts a mapping session
 func (s *MappingService) Start(id string) error {
+	if err := s.start(id); err != nil {
+		return err
+	}
+	s.recordChange(models.ConfigAuditStart, id, runningSnapshot(false), runningSnapshot(true))
+	return nil
+}
+
+// start starts a mapping session without recording it in the configuration audit trail.
+func (s *MappingService) start(id string) error {
 	// Ensure not already running
-	if s.state.SessionExists(id) {
+	if s.state.SessionExists(s.key(id)) {
 		return wrapSentinel("mapping is already running", ErrMappingAlreadyRunning)
 	}
 
@@ -277,36 +866,36 @@ func (s *MappingService) Start(id string) error {
 		return err
 	}
 
+	// Start the mappings it depends on first, e.g. the local tunnel its bastion is reached through
+	if err := s.startDependencies(mapping); err != nil {
+		core.MappingEvents.Record(mapping.Key(), core.MappingEventStartFailed, "dependency not started", err.Error())
+		return err
+	}
+
 	// Build bastion chain
 	chainNames := mapping.GetChain()
 	var bastions []models.Bastion
 
 	// Lookup bastions if a chain is provided
 	if len(chainNames) > 0 {
-		// Query bastions in batch
-		var allBastions []models.Bastion
-		if err := s.db.Where("name IN ?", chainNames).Find(&allBastions).Error; err != nil {
-			return fmt.Errorf("failed to query bastions: %w", err)
-		}
-
-		// Build name -> bastion map
-		bastionMap := make(map[string]models.Bastion)
-		for _, b := range allBastions {
-			bastionMap[b.Name] = b
-		}
-
-		// Build ordered bastion list according to chain
-		bastions = make([]models.Bastion, 0, len(chainNames))
-		for _, name := range chainNames {
-			bastion, exists := bastionMap[name]
-			if !exists {
-				return fmt.Errorf("bastion '%s' in chain not found", name)
+		bastions, err = s.resolveChain(chainNames)
+		if err != nil {
+			if !errors.Is(err, errBastionChainQuery) {
+				core.MappingEvents.Record(mapping.Key(), core.MappingEventStartFailed, "bastion chain invalid", err.Error())
 			}
-			bastions = append(bastions, bastion)
+			return err
 		}
 	}
 	// If no bastions configured, empty slice indicates direct connection
 
+	// Resolve target templates, so a promoted mapping picks up this environment's target
+	resolved, err := resolveMappingTarget(mapping)
+	if err != nil {
+		core.MappingEvents.Record(mapping.Key(), core.MappingEventStartFailed, "target unresolved", err.Error())
+		return wrapSentinel(err.Error(), ErrTargetUnresolved)
+	}
+	mapping = resolved
+
 	// Create session
 	var session core.Session
 	switch mapping.Type {
@@ -316,6 +905,8 @@ func (s *MappingService) Start(id string) error {
 		session = core.NewHTTPProxySession(mapping, bastions)
 	case "mixed":
 		session = core.NewMixedProxySession(mapping, bastions)
+	case "k8s":
+		session = core.NewK8sSession(mapping, bastions)
 	default:
 		session = core.NewTunnelSession(mapping, bastions)
 	}
@@ -325,15 +916,15 @@ func (s *MappingService) Start(id string) error {
 		var portErr *core.PortInUseError
 		if errors.As(err, &portErr) {
 			var mappingsWithPort []models.Mapping
-			if dbErr := s.db.Where("local_port = ? AND id != ?", mapping.LocalPort, mapping.ID).Find(&mappingsWithPort).Error; dbErr == nil {
+			if dbErr := s.db.Where("local_port = ? AND NOT (workspace = ? AND id = ?)", mapping.LocalPort, mapping.Workspace, mapping.ID).Find(&mappingsWithPort).Error; dbErr == nil {
 				conflicts := make([]core.PortConflict, 0, len(mappingsWithPort))
 				for _, m := range mappingsWithPort {
 					conflicts = append(conflicts, core.PortConflict{
-						MappingID: m.ID,
+						MappingID: m.Key(),
 						LocalHost: m.LocalHost,
 						LocalPort: m.LocalPort,
 						Type:      m.Type,
-						Running:   s.state.SessionExists(m.ID),
+						Running:   s.state.SessionExists(m.Key()),
 					})
 				}
 				portErr.Detail.InternalConflicts = conflicts
@@ -344,9 +935,9 @@ func (s *MappingService) Start(id string) error {
 			}
 
 			if b, marshalErr := json.Marshal(portErr.Detail); marshalErr == nil {
-				log.Printf("mapping start failed (port in use): mapping_id=%s addr=%s detail=%s", mapping.ID, portErr.Detail.Attempt.Addr, string(b))
+				log.Printf("mapping start failed (port in use): mapping_id=%s addr=%s detail=%s", mapping.Key(), portErr.Detail.Attempt.Addr, string(b))
 			} else {
-				log.Printf("mapping start failed (port in use): mapping_id=%s addr=%s", mapping.ID, portErr.Detail.Attempt.Addr)
+				log.Printf("mapping start failed (port in use): mapping_id=%s addr=%s", mapping.Key(), portErr.Detail.Attempt.Addr)
 			}
 
 			core.LogErrorWithContext(
@@ -354,74 +945,367 @@ func (s *MappingService) Start(id string) error {
 				"Mapping start failed: port is already in use",
 				portErr.Detail.ListenError,
 				map[string]interface{}{
-					"mapping_id":  mapping.ID,
+					"mapping_id":  mapping.Key(),
 					"port_in_use": portErr.Detail,
 				},
 			)
 		}
+		core.MappingEvents.Record(mapping.Key(), core.MappingEventStartFailed, "session start failed", err.Error())
+		core.AlerterInstance.Fire(core.AlertEvent{
+			Type:     core.AlertMappingStartFailed,
+			Severity: "ERROR",
+			Key:      mapping.Key(),
+			Message:  fmt.Sprintf("Mapping %s failed to start", mapping.Key()),
+			Detail:   err.Error(),
+			Fields:   map[string]interface{}{"mapping_id": mapping.Key(), "type": mapping.Type, "chain": chainNames},
+		})
 		return fmt.Errorf("failed to start session: %w", err)
 	}
 
+	if mapping.ExposeAddr != "" {
+		log.Printf("WARNING: mapping %s is exposed on %s (enabled by %s)", mapping.Key(), net.JoinHostPort(mapping.ExposeAddr, strconv.Itoa(session.GetStats().LocalPort)), mapping.ExposedBy)
+	}
+
 	// Add to state
-	s.state.AddSession(id, session)
+	s.state.AddSession(mapping.Key(), session)
+	core.Usage.Started(mapping.Key(), session)
+	core.MappingEvents.Record(mapping.Key(), core.MappingEventStart, "started", "")
 
 	return nil
 }
 
+// DryRun checks whether a mapping could start without binding its port or registering a session.
+// With dialTarget, tcp mappings also dial remote_host:remote_port; proxy mappings have no fixed
+// target, so one must be given explicitly (target always overrides the mapping's remote).
+func (s *MappingService) DryRun(id string, dialTarget bool, target string) (*core.DryRunReport, error) {
+	mapping, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if target == "" && dialTarget {
+		if mapping, err = resolveMappingTarget(mapping); err != nil {
+			return nil, wrapSentinel(err.Error(), ErrTargetUnresolved)
+		}
+		if mapping.Type != "" && mapping.Type != "tcp" {
+			return nil, wrapSentinel(fmt.Sprintf("target (host:port) is required to dial through a %s mapping", mapping.Type), ErrInvalidDryRunTarget)
+		}
+		target = net.JoinHostPort(mapping.RemoteHost, strconv.Itoa(mapping.RemotePort))
+	}
+	if target != "" {
+		if _, _, err := net.SplitHostPort(target); err != nil {
+			return nil, wrapSentinel(fmt.Sprintf("invalid target %q: %v", target, err), ErrInvalidDryRunTarget)
+		}
+	}
+
+	var bastions []models.Bastion
+	if chainNames := mapping.GetChain(); len(chainNames) > 0 {
+		bastions, err = s.resolveChain(chainNames)
+		if err != nil {
+			if errors.Is(err, errBastionChainQuery) {
+				return nil, err
+			}
+			return &core.DryRunReport{MappingID: mapping.Key(), Error: err.Error(), Hops: []core.DryRunStep{}}, nil
+		}
+	}
+
+	return core.DryRun(mapping, bastions, target), nil
+}
+
+// Expose rebinds a mapping's listener to addr, a non-loopback local interface address, so other
+// machines on that network can reach it. confirm must be set; by records who enabled it. A running
+// mapping is restarted on the new address.
+func (s *MappingService) Expose(id, addr, by string, confirm bool) (*models.Mapping, error) {
+	if !confirm {
+		return nil, wrapSentinel("exposing a mapping beyond localhost requires confirm=true", ErrExposeNotConfirmed)
+	}
+	mapping, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := core.ValidateExposeAddr(addr); err != nil {
+		return nil, wrapSentinel(err.Error(), ErrInvalidExposeAddr)
+	}
+
+	before := mappingSnapshot(mapping)
+	now := time.Now()
+	mapping.ExposeAddr = addr
+	mapping.ExposedBy = by
+	mapping.ExposedAt = &now
+	if err := s.rebind(mapping); err != nil {
+		return nil, err
+	}
+	core.MappingEvents.Record(mapping.Key(), core.MappingEventExposed, "exposed on "+addr, "by "+by)
+	s.recordChange(models.ConfigAuditExpose, mapping.ID, before, mappingSnapshot(mapping))
+	return mapping, nil
+}
+
+// Unexpose binds a mapping back to its local_host, restarting it when running.
+func (s *MappingService) Unexpose(id string) (*models.Mapping, error) {
+	mapping, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if mapping.ExposeAddr == "" {
+		return mapping, nil
+	}
+
+	before := mappingSnapshot(mapping)
+	mapping.ExposeAddr = ""
+	mapping.ExposedBy = ""
+	mapping.ExposedAt = nil
+	if err := s.rebind(mapping); err != nil {
+		return nil, err
+	}
+	core.MappingEvents.Record(mapping.Key(), core.MappingEventUnexposed, "bound to "+mapping.LocalHost, "")
+	s.recordChange(models.ConfigAuditUnexpose, mapping.ID, before, mappingSnapshot(mapping))
+	return mapping, nil
+}
+
+// rebind saves mapping's listen address and restarts its session if it is running.
+func (s *MappingService) rebind(mapping *models.Mapping) error {
+	if err := saveVersioned(s.db, mapping, &mapping.Version, 0); err != nil {
+		return fmt.Errorf("failed to update mapping: %w", err)
+	}
+	if !s.state.SessionExists(mapping.Key()) {
+		return nil
+	}
+	if err := s.stop(mapping.ID); err != nil && !errors.Is(err, ErrMappingNotRunning) {
+		return err
+	}
+	return s.start(mapping.ID)
+}
+
+var errBastionChainQuery = errors.New("failed to query bastions")
+
+// resolveChain loads the bastions named by chainNames, in chain order.
+func (s *MappingService) resolveChain(chainNames []string) ([]models.Bastion, error) {
+	// Query bastions in batch
+	var allBastions []models.Bastion
+	if err := s.scoped().Where("name IN ?", chainNames).Find(&allBastions).Error; err != nil {
+		return nil, fmt.Errorf("%w: %v", errBastionChainQuery, err)
+	}
+
+	// Build name -> bastion map
+	bastionMap := make(map[string]models.Bastion)
+	for _, b := range allBastions {
+		bastionMap[b.Name] = b
+	}
+
+	// Build ordered bastion list according to chain
+	bastions := make([]models.Bastion, 0, len(chainNames))
+	for _, name := range chainNames {
+		bastion, exists := bastionMap[name]
+		if !exists {
+			return nil, fmt.Errorf("bastion '%s' in chain not found", name)
+		}
+		bastions = append(bastions, bastion)
+	}
+	return bastions, nil
+}
+
 // Stop stops a mapping session
 func (s *MappingService) Stop(id string) error {
-	if !s.state.SessionExists(id) {
+	if err := s.stop(id); err != nil {
+		return err
+	}
+	s.recordChange(models.ConfigAuditStop, id, runningSnapshot(true), runningSnapshot(false))
+	return nil
+}
+
+// stop stops a mapping session without recording it in the configuration audit trail.
+func (s *MappingService) stop(id string) error {
+	if !s.state.SessionExists(s.key(id)) {
 		return wrapSentinel("mapping is not running", ErrMappingNotRunning)
 	}
 
-	s.state.RemoveAndStopSession(id)
+	s.state.RemoveAndStopSession(s.key(id))
+	core.Usage.Stopped(s.key(id))
+	core.MappingEvents.Record(s.key(id), core.MappingEventStop, "stopped", "")
 	return nil
 }
 
-// GetStats returns stats for all sessions
+// StopAll stops every running mapping, in all workspaces, concurrently (a kill switch, e.g. before
+// joining an untrusted network). Each stop is audited as by Stop and reports its own result.
+func (s *MappingService) StopAll() *models.MappingStopAllResponse {
+	s.state.RLock()
+	keys := make([]string, 0, len(s.state.Sessions))
+	for key := range s.state.Sessions {
+		keys = append(keys, key)
+	}
+	s.state.RUnlock()
+
+	results := make([]models.MappingStopResult, len(keys))
+	var wg sync.WaitGroup
+	for i, key := range keys {
+		wg.Add(1)
+		go func(i int, key string) {
+			defer wg.Done()
+			workspace, id := models.SplitWorkspaceKey(key)
+			result := models.MappingStopResult{Workspace: workspace, ID: id}
+			start := time.Now()
+			// A mapping stopped meanwhile by someone else counts as stopped.
+			if err := s.InWorkspace(workspace).Stop(id); err != nil && !errors.Is(err, ErrMappingNotRunning) {
+				result.Error = err.Error()
+			} else {
+				result.Stopped = true
+			}
+			result.DurationMS = time.Since(start).Milliseconds()
+			results[i] = result
+		}(i, key)
+	}
+	wg.Wait()
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Workspace != results[j].Workspace {
+			return results[i].Workspace < results[j].Workspace
+		}
+		return results[i].ID < results[j].ID
+	})
+	resp := &models.MappingStopAllResponse{Results: results}
+	for _, r := range results {
+		if r.Stopped {
+			resp.Stopped++
+		} else {
+			resp.Failed++
+		}
+	}
+	return resp
+}
+
+// Events returns the most recent lifecycle events of a mapping, latest first.
+func (s *MappingService) Events(id string, limit int) ([]models.MappingEvent, error) {
+	if _, err := s.Get(id); err != nil {
+		return nil, err
+	}
+	events, err := core.MappingEvents.List(s.key(id), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list mapping events: %w", err)
+	}
+	return events, nil
+}
+
+// GetStats returns stats for the sessions of the service's workspace, keyed by mapping ID
 func (s *MappingService) GetStats() map[string]core.SessionStats {
 	s.state.RLock()
 	defer s.state.RUnlock()
 
 	stats := make(map[string]core.SessionStats)
-	for id, session := range s.state.Sessions {
-		stats[id] = session.GetStats()
+	for key, session := range s.state.Sessions {
+		if ws, id := models.SplitWorkspaceKey(key); ws == s.Workspace() {
+			stats[id] = session.GetStats()
+		}
 	}
 
 	return stats
 }
 
-// GetSessionIDs lists all running session IDs
+// GetSessionIDs lists the running mapping IDs of the service's workspace
 func (s *MappingService) GetSessionIDs() []string {
 	s.state.RLock()
 	defer s.state.RUnlock()
 
 	ids := make([]string, 0, len(s.state.Sessions))
-	for id := range s.state.Sessions {
-		ids = append(ids, id)
+	for key := range s.state.Sessions {
+		if ws, id := models.SplitWorkspaceKey(key); ws == s.Workspace() {
+			ids = append(ids, id)
+		}
 	}
 
 	return ids
 }
 
+// Usage returns the lifetime traffic totals of a mapping
+func (s *MappingService) Usage(id string) models.MappingUsage {
+	return core.Usage.Get(s.key(id))
+}
+
+// RuntimePort returns the port a running mapping is bound to, or 0 when it is not running
+func (s *MappingService) RuntimePort(id string) int {
+	if session, exists := s.state.GetSession(s.key(id)); exists {
+		return session.GetStats().LocalPort
+	}
+	return 0
+}
+
+// WaitReady probes a running mapping end to end until it can carry a client connection or timeout
+// passes (see core.ProbeReadiness). The mapping is left running either way.
+func (s *MappingService) WaitReady(id string, timeout time.Duration) (*core.ReadinessReport, error) {
+	session, exists := s.state.GetSession(s.key(id))
+	if !exists {
+		return nil, wrapSentinel("mapping is not running", ErrMappingNotRunning)
+	}
+	return core.ProbeReadiness(session, timeout), nil
+}
+
 // IsRunning checks whether a mapping is running
 func (s *MappingService) IsRunning(id string) bool {
-	return s.state.SessionExists(id)
+	return s.state.SessionExists(s.key(id))
 }
 
-// StartAutoStartMappings starts all mappings marked as auto-start
+// StartAutoStartMappings starts all mappings marked as auto-start, in every workspace but those
+// run by agents. Within a workspace, mappings start after the mappings they depend on; a mapping
+// whose dependency failed or that is part of a dependency cycle is skipped.
 func (s *MappingService) StartAutoStartMappings() error {
 	var mappings []models.Mapping
-	if err := s.db.Where("auto_start = ?", true).Find(&mappings).Error; err != nil {
+	agentWorkspaces := s.db.Model(&models.Agent{}).Select("workspace")
+	if err := s.db.Where("auto_start = ? AND workspace NOT IN (?)", true, agentWorkspaces).Order("workspace, id").Find(&mappings).Error; err != nil {
 		return fmt.Errorf("failed to query auto-start mappings: %w", err)
 	}
 
-	for _, mapping := range mappings {
-		if err := s.Start(mapping.ID); err != nil {
-			// Log error but continue with other mappings
-			fmt.Printf("Failed to auto-start mapping %s: %v\n", mapping.ID, err)
+	byWorkspace := make(map[string]map[string][]string)
+	var workspaces []string
+	for _, m := range mappings {
+		if byWorkspace[m.Workspace] == nil {
+			byWorkspace[m.Workspace] = make(map[string][]string)
+			workspaces = append(workspaces, m.Workspace)
+		}
+		byWorkspace[m.Workspace][m.ID] = m.GetDependsOn()
+	}
+
+	for _, workspace := range workspaces {
+		deps := byWorkspace[workspace]
+		order, err := core.OrderByDependencies(deps)
+		if err != nil {
+			fmt.Printf("Failed to order auto-start mappings of workspace %s: %v\n", workspace, err)
+		}
+		svc := s.InWorkspace(workspace)
+		failed := make(map[string]bool)
+		ordered := make(map[string]bool, len(order))
+		for _, id := range order {
+			ordered[id] = true
+			if dep := firstFailed(deps[id], failed); dep != "" {
+				failed[id] = true
+				fmt.Printf("Skipped auto-start of mapping %s: dependency %s did not start\n", models.WorkspaceKey(workspace, id), dep)
+				continue
+			}
+			if err := svc.Start(id); err != nil && !errors.Is(err, ErrMappingAlreadyRunning) {
+				// Log error but continue with other mappings
+				failed[id] = true
+				fmt.Printf("Failed to auto-start mapping %s: %v\n", models.WorkspaceKey(workspace, id), err)
+			}
+		}
+		for _, m := range mappings {
+			if m.Workspace == workspace && !ordered[m.ID] {
+				fmt.Printf("Skipped auto-start of mapping %s: it is part of or depends on a dependency cycle\n", m.Key())
+			}
 		}
 	}
 
 	return nil
 }
+
+func firstFailed(ids []string, failed map[string]bool) string {
+	for _, id := range ids {
+		if failed[id] {
+			return id
+		}
+	}
+	return ""
+}
+
+// getByKey loads a mapping by its runtime key, whatever the service's workspace.
+func (s *MappingService) getByKey(key string) (*models.Mapping, error) {
+	workspace, id := models.SplitWorkspaceKey(key)
+	return s.InWorkspace(workspace).Get(id)
+}
@@ -0,0 +1,303 @@
+package service
+
+import (
+	"bastion/config"
+	"bastion/core"
+	"bastion/database"
+	"bastion/models"
+	"bastion/state"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// selfCheckDialTimeout bounds the DNS lookup and the TCP connect of each first hop.
+const selfCheckDialTimeout = 5 * time.Second
+
+// errSelfCheckRollback undoes the database write probe.
+var errSelfCheckRollback = errors.New("self-check rollback")
+
+// SelfCheckService verifies the environment the daemon depends on (GET /api/v2/selfcheck, --selfcheck).
+type SelfCheckService struct {
+	db       *gorm.DB
+	appState *state.AppState
+}
+
+// NewSelfCheckService constructs a self-check service
+func NewSelfCheckService(db *gorm.DB, appState *state.AppState) *SelfCheckService {
+	return &SelfCheckService{db: db, appState: appState}
+}
+
+// Run performs every check across all workspaces and returns the report. Failed checks are items
+// of the report; Run itself does not fail.
+func (s *SelfCheckService) Run() models.SelfCheckReport {
+	report := models.SelfCheckReport{CheckedAt: time.Now()}
+	report.Items = append(report.Items, s.checkDatabase(), checkLogFile(config.Settings.LogFilePath))
+
+	var bastions []models.Bastion
+	var mappings []models.Mapping
+	if err := s.db.Find(&bastions).Error; err != nil {
+		report.Items = append(report.Items, models.SelfCheckItem{Check: models.SelfCheckDatabase, Target: "bastions", Detail: err.Error()})
+	}
+	if err := s.db.Find(&mappings).Error; err != nil {
+		report.Items = append(report.Items, models.SelfCheckItem{Check: models.SelfCheckDatabase, Target: "mappings", Detail: err.Error()})
+	}
+
+	report.Items = append(report.Items, checkKeyFiles(bastions, mappings)...)
+	for i := range mappings {
+		if mappings[i].AutoStart {
+			report.Items = append(report.Items, s.checkPort(&mappings[i]))
+		}
+	}
+	report.Items = append(report.Items, checkFirstHops(bastions, mappings)...)
+
+	for _, item := range report.Items {
+		if item.OK {
+			report.Passed++
+		} else {
+			report.Failed++
+		}
+	}
+	report.OK = report.Failed == 0
+	return report
+}
+
+// checkDatabase writes inside a transaction that is rolled back, so nothing is left behind. MySQL
+// commits DDL implicitly, so there the empty probe table is created up front and kept.
+func (s *SelfCheckService) checkDatabase() models.SelfCheckItem {
+	item := models.SelfCheckItem{Check: models.SelfCheckDatabase, Target: database.DisplayURL(config.Settings.DatabaseURL)}
+	start := time.Now()
+	const createProbe = "CREATE TABLE IF NOT EXISTS selfcheck_probe (id INTEGER)"
+	var err error
+	if s.db.Dialector.Name() == database.BackendMySQL {
+		err = s.db.Exec(createProbe).Error
+	}
+	if err == nil {
+		err = s.db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec(createProbe).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec("INSERT INTO selfcheck_probe (id) VALUES (1)").Error; err != nil {
+				return err
+			}
+			return errSelfCheckRollback
+		})
+	}
+	item.DurationMs = time.Since(start).Milliseconds()
+	if errors.Is(err, errSelfCheckRollback) {
+		item.OK = true
+	} else if err != nil {
+		item.Detail = err.Error()
+	}
+	return item
+}
+
+// checkLogFile opens an existing log file for appending, or creates a scratch file next to a
+// missing one, without rotating or truncating anything.
+func checkLogFile(path string) (item models.SelfCheckItem) {
+	item = models.SelfCheckItem{Check: models.SelfCheckLogFile, Target: path}
+	start := time.Now()
+	defer func() { item.DurationMs = time.Since(start).Milliseconds() }()
+	if path == "" {
+		item.Detail = "LOG_FILE is empty"
+		return item
+	}
+
+	if _, err := os.Stat(path); err == nil {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+		if err != nil {
+			item.Detail = err.Error()
+			return item
+		}
+		_ = f.Close()
+	} else {
+		f, err := os.CreateTemp(filepath.Dir(path), ".selfcheck-*")
+		if err != nil {
+			item.Detail = err.Error()
+			return item
+		}
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		item.Detail = "not created yet; its directory is writable"
+	}
+	item.OK = true
+	return item
+}
+
+// checkKeyFiles reads the private keys of the bastions and the TLS files of the mappings.
+func checkKeyFiles(bastions []models.Bastion, mappings []models.Mapping) []models.SelfCheckItem {
+	var items []models.SelfCheckItem
+	check := func(path, subject string) {
+		if path == "" {
+			return
+		}
+		item := models.SelfCheckItem{Check: models.SelfCheckKeyFile, Target: path, Subject: subject}
+		start := time.Now()
+		if strings.HasPrefix(path, "~/") {
+			if home, err := os.UserHomeDir(); err == nil {
+				path = filepath.Join(home, path[2:])
+			}
+		}
+		if data, err := os.ReadFile(path); err != nil {
+			item.Detail = err.Error()
+		} else if len(data) == 0 {
+			item.Detail = "file is empty"
+		} else {
+			item.OK = true
+		}
+		item.DurationMs = time.Since(start).Milliseconds()
+		items = append(items, item)
+	}
+
+	for _, b := range bastions {
+		check(b.PkeyPath, "bastion "+b.Key())
+	}
+	for _, m := range mappings {
+		for _, path := range []string{m.TLSCertFile, m.TLSKeyFile, m.TLSCAFile} {
+			check(path, "mapping "+m.Key())
+		}
+		if m.Type == "k8s" {
+			kubeconfig := m.K8sKubeconfig
+			if kubeconfig == "" {
+				kubeconfig = core.DefaultKubeconfigPath()
+			}
+			check(kubeconfig, "mapping "+m.Key())
+		}
+	}
+	return items
+}
+
+// checkPort binds the listen address of an autostart mapping; a running mapping holds its own port.
+func (s *SelfCheckService) checkPort(mapping *models.Mapping) models.SelfCheckItem {
+	item := models.SelfCheckItem{
+		Check:   models.SelfCheckPort,
+		Target:  net.JoinHostPort(mapping.ListenHost(), strconv.Itoa(mapping.LocalPort)),
+		Subject: "mapping " + mapping.Key(),
+	}
+	if s.appState != nil && s.appState.SessionExists(mapping.Key()) {
+		item.OK = true
+		item.Detail = "in use by the running mapping"
+		return item
+	}
+
+	start := time.Now()
+	err := core.ProbeListen(mapping)
+	item.DurationMs = time.Since(start).Milliseconds()
+	if err == nil {
+		item.OK = true
+		return item
+	}
+	item.Detail = err.Error()
+	var inUse *core.PortInUseError
+	if errors.As(err, &inUse) {
+		var owners []string
+		for _, o := range inUse.Detail.Owners {
+			owners = append(owners, fmt.Sprintf("%s (pid %d)", o.Name, o.PID))
+		}
+		if len(owners) > 0 {
+			item.Detail += " by " + strings.Join(owners, ", ")
+		}
+		if len(inUse.Detail.Hints) > 0 {
+			item.Detail += ". " + inUse.Detail.Hints[0]
+		}
+	}
+	return item
+}
+
+// checkFirstHops resolves and connects to the first hop of every mapping: the first bastion of its
+// chain, else its upstream proxy, else its remote. Each address is checked once, concurrently.
+func checkFirstHops(bastions []models.Bastion, mappings []models.Mapping) []models.SelfCheckItem {
+	byKey := make(map[string]*models.Bastion, len(bastions))
+	for i := range bastions {
+		byKey[bastions[i].Key()] = &bastions[i]
+	}
+
+	var items []models.SelfCheckItem
+	seen := make(map[string]int) // address to index in items
+	for _, m := range mappings {
+		var addr, subject string
+		chain := m.GetChain()
+		switch {
+		case len(chain) > 0:
+			b := byKey[models.WorkspaceKey(m.Workspace, chain[0])]
+			if b == nil {
+				items = append(items, models.SelfCheckItem{
+					Check: models.SelfCheckFirstHop, Target: chain[0], Subject: "mapping " + m.Key(),
+					Detail: "bastion not found",
+				})
+				continue
+			}
+			addr, subject = net.JoinHostPort(b.Host, strconv.Itoa(b.Port)), "bastion "+b.Key()
+		case m.UpstreamProxy != "":
+			u, err := core.ParseUpstreamProxy(m.UpstreamProxy)
+			if err != nil {
+				continue // rejected when the mapping is saved
+			}
+			addr, subject = u.Host, "mapping "+m.Key()+" upstream proxy"
+		case m.RemoteHost != "" && (m.RemotePort > 0 || m.RemotePortTemplate != ""):
+			target, err := resolveMappingTarget(&m)
+			if err != nil {
+				items = append(items, models.SelfCheckItem{
+					Check: models.SelfCheckFirstHop, Target: m.RemoteHost, Subject: "mapping " + m.Key(),
+					Detail: err.Error(),
+				})
+				continue
+			}
+			addr, subject = net.JoinHostPort(target.RemoteHost, strconv.Itoa(target.RemotePort)), "mapping "+m.Key()
+		default:
+			continue // a proxy mapping without chain dials its clients' targets directly
+		}
+		if _, ok := seen[addr]; ok {
+			continue
+		}
+		seen[addr] = len(items)
+		items = append(items, models.SelfCheckItem{Check: models.SelfCheckFirstHop, Target: addr, Subject: subject})
+	}
+
+	var wg sync.WaitGroup
+	for _, i := range seen {
+		wg.Add(1)
+		go func(item *models.SelfCheckItem) {
+			defer wg.Done()
+			start := time.Now()
+			item.Detail, item.OK = probeFirstHop(item.Target)
+			item.DurationMs = time.Since(start).Milliseconds()
+		}(&items[i])
+	}
+	wg.Wait()
+	return items
+}
+
+// probeFirstHop resolves addr's host and opens a TCP connection to it.
+func probeFirstHop(addr string) (string, bool) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err.Error(), false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), selfCheckDialTimeout)
+	defer cancel()
+	ips, err := net.DefaultResolver.LookupHost(ctx, host)
+	if err != nil {
+		return "DNS: " + err.Error(), false
+	}
+
+	dialer := net.Dialer{Timeout: selfCheckDialTimeout}
+	conn, err := dialer.Dial("tcp", net.JoinHostPort(ips[0], port))
+	if err != nil {
+		return "TCP: " + err.Error(), false
+	}
+	_ = conn.Close()
+	if ip := ips[0]; ip != host {
+		return "resolved to " + ip, true
+	}
+	return "", true
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"bastion/config"
+	"bastion/models"
+	"net"
+	"path/filepath"
+	"testing"
+)
+
+// useSelfCheckLogFile points LOG_FILE into a temporary directory until the test ends.
+func useSelfCheckLogFile(t *testing.T) {
+	t.Helper()
+	prev := config.Settings.LogFilePath
+	t.Cleanup(func() { config.Settings.LogFilePath = prev })
+	config.Settings.LogFilePath = filepath.Join(t.TempDir(), "bastion.log")
+}
+
+// failedChecks returns the items of report that did not pass.
+func failedChecks(report models.SelfCheckReport) []models.SelfCheckItem {
+	var failed []models.SelfCheckItem
+	for _, item := range report.Items {
+		if !item.OK {
+			failed = append(failed, item)
+		}
+	}
+	return failed
+}
+
+func TestSelfCheckService_PortInUse(t *testing.T) {
+	useSelfCheckLogFile(t)
+	svc := newTestServices(t)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	port := ln.Addr().(*net.TCPAddr).Port
+	mapping := models.Mapping{ID: "web", Workspace: models.DefaultWorkspace, Type: "socks5", LocalHost: "127.0.0.1", LocalPort: port, AutoStart: true}
+	if err := svc.SelfCheck.db.Create(&mapping).Error; err != nil {
+		t.Fatalf("create mapping: %v", err)
+	}
+
+	report := svc.SelfCheck.Run()
+	failed := failedChecks(report)
+	if report.OK || report.Failed != 1 || len(failed) != 1 || failed[0].Check != models.SelfCheckPort {
+		t.Fatalf("report = %+v, want only the port check failed", report)
+	}
+	if failed[0].Detail == "" || failed[0].Subject != "mapping "+mapping.Key() {
+		t.Fatalf("port item = %+v", failed[0])
+	}
+
+	// Once the port is free the same installation passes.
+	ln.Close()
+	if report := svc.SelfCheck.Run(); !report.OK || report.Failed != 0 {
+		t.Fatalf("report with the port free = %+v", report)
+	}
+}
+
+func TestSelfCheckService_DatabaseDown(t *testing.T) {
+	useSelfCheckLogFile(t)
+	svc := newTestServices(t)
+	if report := svc.SelfCheck.Run(); !report.OK {
+		t.Fatalf("report of a healthy installation = %+v", report)
+	}
+
+	sqlDB, err := svc.SelfCheck.db.DB()
+	if err != nil {
+		t.Fatalf("sql db: %v", err)
+	}
+	sqlDB.Close()
+
+	report := svc.SelfCheck.Run()
+	if report.OK || report.Failed == 0 {
+		t.Fatalf("report with the database closed = %+v", report)
+	}
+	for _, item := range failedChecks(report) {
+		if item.Check != models.SelfCheckDatabase || item.Detail == "" {
+			t.Fatalf("unexpected failed item %+v", item)
+		}
+	}
+	if report.Items[0].Check != models.SelfCheckDatabase || report.Items[0].OK {
+		t.Fatalf("database item = %+v, want failed", report.Items[0])
+	}
+}
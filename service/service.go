@@ -1,7 +1,9 @@
 package service
 
 import (
+	"bastion/config"
 	"bastion/core"
+	"bastion/models"
 	"bastion/state"
 
 	"gorm.io/gorm"
@@ -9,9 +11,16 @@ import (
 
 // Services is the global service container
 type Services struct {
-	Bastion *BastionService
-	Mapping *MappingService
-	Audit   *AuditService
+	Bastion     *BastionService
+	Mapping     *MappingService
+	Audit       *AuditService
+	ConfigAudit *ConfigAuditService
+	Setup       *SetupService
+	Workspace   *WorkspaceService
+	SelfCheck   *SelfCheckService
+	HA          *HAService
+	Agents      *AgentService
+	Agent       *AgentRunner
 }
 
 // GlobalServices is the global service instance
@@ -19,13 +28,52 @@ var GlobalServices *Services
 
 // InitServices initializes all services
 func InitServices(db *gorm.DB, appState *state.AppState, auditor *core.Auditor) {
-	bastionSvc := NewBastionService(db)
-	mappingSvc := NewMappingService(db, appState, bastionSvc)
-	auditSvc := NewAuditService(auditor)
-
-	GlobalServices = &Services{
-		Bastion: bastionSvc,
-		Mapping: mappingSvc,
-		Audit:   auditSvc,
+	GlobalServices = NewServices(db, appState, auditor)
+}
+
+// NewServices creates the services of db, with HA and the agent configured from config.Settings.
+func NewServices(db *gorm.DB, appState *state.AppState, auditor *core.Auditor) *Services {
+	configAuditSvc := NewConfigAuditService(db)
+	bastionSvc := NewBastionService(db, configAuditSvc)
+	mappingSvc := NewMappingService(db, appState, bastionSvc, configAuditSvc)
+	auditSvc := NewAuditService(auditor, mappingSvc)
+	setupSvc := NewSetupService(db, bastionSvc, mappingSvc)
+	workspaceSvc := NewWorkspaceService(db, appState)
+
+	return &Services{
+		Bastion:     bastionSvc,
+		Mapping:     mappingSvc,
+		Audit:       auditSvc,
+		ConfigAudit: configAuditSvc,
+		Setup:       setupSvc,
+		Workspace:   workspaceSvc,
+		SelfCheck:   NewSelfCheckService(db, appState),
+		HA:          NewHAService(mappingSvc, config.Settings),
+		Agents:      NewAgentService(db),
+		Agent:       NewAgentRunner(mappingSvc, config.Settings),
+	}
+}
+
+// InWorkspace returns the services with bastion, mapping, audit and config audit lookups scoped to workspace.
+// The global services operate on the default workspace.
+func (s *Services) InWorkspace(workspace string) *Services {
+	workspace = models.NormalizeWorkspace(workspace)
+	if workspace == s.Mapping.Workspace() {
+		return s
 	}
+	scoped := *s
+	scoped.Bastion = s.Bastion.InWorkspace(workspace)
+	scoped.Mapping = s.Mapping.InWorkspace(workspace)
+	scoped.Audit = s.Audit.InWorkspace(workspace)
+	scoped.ConfigAudit = s.ConfigAudit.InWorkspace(workspace)
+	return &scoped
+}
+
+// As returns the services with bastion and mapping changes recorded as made by actor in the
+// configuration audit trail. Services without an actor record changes as SystemActor.
+func (s *Services) As(actor Actor) *Services {
+	scoped := *s
+	scoped.Bastion = s.Bastion.As(actor)
+	scoped.Mapping = s.Mapping.As(actor)
+	return &scoped
 }
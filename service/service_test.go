@@ -0,0 +1,42 @@
+package service
+
+import (
+	"bastion/config"
+	"bastion/core"
+	"bastion/database"
+	"bastion/state"
+	"path/filepath"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newTestServices returns the services of a fresh, migrated SQLite database with the pool of a
+// default daemon (SQLITE_MAX_OPEN_CONNS). The database is also database.DB until the test ends.
+func newTestServices(t *testing.T) *Services {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("sql db: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(config.Settings.SQLiteMaxOpenConns)
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	prev := database.DB
+	database.DB = db
+	t.Cleanup(func() {
+		database.DB = prev
+		sqlDB.Close()
+	})
+	return NewServices(db, &state.AppState{Sessions: make(map[string]core.Session)}, nil)
+}
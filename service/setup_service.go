@@ -0,0 +1,368 @@
+package service
+
+import (
+	"bastion/core"
+	"bastion/database"
+	"bastion/models"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+var ErrSetupCompleted = errors.New("setup already completed")
+var ErrSetupStepOutOfOrder = errors.New("setup step out of order")
+var ErrSetupUnknownStep = errors.New("unknown setup step")
+
+// Persisted setting keys used by the first-run wizard.
+const (
+	setupStepSettingKey      = "setup.step"
+	setupCompletedSettingKey = "setup.completed"
+
+	// AdminTokenSettingKey stores the SHA-256 of the admin token required for non-loopback API clients.
+	AdminTokenSettingKey = "server.admin_token_sha256"
+	// BindAddressSettingKey stores the management server bind address chosen during setup.
+	BindAddressSettingKey = "server.bind_address"
+)
+
+const minAdminTokenLength = 12
+
+// SetupService drives the first-run wizard as a persisted state machine.
+type SetupService struct {
+	db         *gorm.DB
+	bastionSvc *BastionService
+	mappingSvc *MappingService
+
+	mu             sync.Mutex
+	tokenHash      string
+	tokenHashValid bool
+}
+
+// NewSetupService constructs a setup service
+func NewSetupService(db *gorm.DB, bastionSvc *BastionService, mappingSvc *MappingService) *SetupService {
+	return &SetupService{db: db, bastionSvc: bastionSvc, mappingSvc: mappingSvc}
+}
+
+// Status returns the current wizard state.
+func (s *SetupService) Status() (models.SetupStatus, error) {
+	status := models.SetupStatus{Steps: models.SetupSteps}
+
+	if err := s.db.Model(&models.Bastion{}).Count(&status.BastionCount).Error; err != nil {
+		return status, fmt.Errorf("failed to count bastions: %w", err)
+	}
+	if err := s.db.Model(&models.Mapping{}).Count(&status.MappingCount).Error; err != nil {
+		return status, fmt.Errorf("failed to count mappings: %w", err)
+	}
+
+	completed, _, err := database.GetSetting(setupCompletedSettingKey)
+	if err != nil {
+		return status, fmt.Errorf("failed to load setup state: %w", err)
+	}
+	step, started, err := database.GetSetting(setupStepSettingKey)
+	if err != nil {
+		return status, fmt.Errorf("failed to load setup state: %w", err)
+	}
+	hash, _, err := database.GetSetting(AdminTokenSettingKey)
+	if err != nil {
+		return status, fmt.Errorf("failed to load admin token: %w", err)
+	}
+	bind, _, err := database.GetSetting(BindAddressSettingKey)
+	if err != nil {
+		return status, fmt.Errorf("failed to load bind address: %w", err)
+	}
+
+	status.Completed = completed == "true"
+	status.AdminTokenSet = hash != ""
+	status.BindAddress = bind
+
+	switch {
+	case status.Completed:
+		status.Step = models.SetupStepDone
+	case started && setupStepIndex(step) >= 0:
+		status.Step = step
+		status.Needed = true
+	default:
+		status.Step = models.SetupSteps[0]
+		// Only offer the wizard on an empty database; upgraded installs are left alone.
+		status.Needed = status.BastionCount == 0 && status.MappingCount == 0
+	}
+
+	return status, nil
+}
+
+// SSHConfigHosts returns importable hosts from the current user's ~/.ssh/config.
+func (s *SetupService) SSHConfigHosts() ([]core.SSHConfigHost, error) {
+	path := core.DefaultSSHConfigPath()
+	if path == "" {
+		return []core.SSHConfigHost{}, nil
+	}
+	hosts, err := core.LoadSSHConfig(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ssh config: %w", err)
+	}
+	if hosts == nil {
+		hosts = []core.SSHConfigHost{}
+	}
+	return hosts, nil
+}
+
+// ApplyStep applies (or skips) a wizard step. Steps must be applied in order; an earlier step
+// may be re-applied without moving the wizard backwards.
+func (s *SetupService) ApplyStep(step string, req models.SetupStepRequest) (*models.SetupStepResult, error) {
+	idx := setupStepIndex(step)
+	if idx < 0 {
+		return nil, wrapSentinel(fmt.Sprintf("unknown setup step: %s", step), ErrSetupUnknownStep)
+	}
+
+	status, err := s.Status()
+	if err != nil {
+		return nil, err
+	}
+	if status.Completed {
+		return nil, ErrSetupCompleted
+	}
+	if current := setupStepIndex(status.Step); idx > current {
+		return nil, wrapSentinel(fmt.Sprintf("setup step %s must be completed before %s", status.Step, step), ErrSetupStepOutOfOrder)
+	}
+
+	result := &models.SetupStepResult{}
+	if !req.Skip {
+		switch step {
+		case models.SetupStepImportSSHConfig:
+			err = s.importSSHConfig(req.Hosts, result)
+		case models.SetupStepBastion:
+			err = s.createBastion(req.Bastion, result)
+		case models.SetupStepMapping:
+			err = s.createMapping(req.Mapping, result)
+		case models.SetupStepAdminToken:
+			err = s.setAdminToken(req.AdminToken, req.GenerateToken, result)
+		case models.SetupStepBindAddress:
+			err = s.setBindAddress(req.BindAddress, result)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if idx == setupStepIndex(status.Step) {
+		if err := s.advance(idx); err != nil {
+			return nil, err
+		}
+	}
+
+	result.Status, err = s.Status()
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// advance moves the wizard past step idx, marking it completed after the last step.
+func (s *SetupService) advance(idx int) error {
+	if idx+1 < len(models.SetupSteps) {
+		if err := database.SetSetting(setupStepSettingKey, models.SetupSteps[idx+1]); err != nil {
+			return fmt.Errorf("failed to save setup state: %w", err)
+		}
+		return nil
+	}
+	if err := database.SetSetting(setupCompletedSettingKey, "true"); err != nil {
+		return fmt.Errorf("failed to save setup state: %w", err)
+	}
+	if err := database.DeleteSetting(setupStepSettingKey); err != nil {
+		return fmt.Errorf("failed to save setup state: %w", err)
+	}
+	return nil
+}
+
+func (s *SetupService) importSSHConfig(aliases []string, result *models.SetupStepResult) error {
+	hosts, err := s.SSHConfigHosts()
+	if err != nil {
+		return err
+	}
+
+	wanted := make(map[string]bool, len(aliases))
+	for _, a := range aliases {
+		if a = strings.TrimSpace(a); a != "" {
+			wanted[a] = true
+		}
+	}
+
+	// Validate the selection before creating anything.
+	if len(wanted) > 0 {
+		found := make(map[string]bool, len(hosts))
+		for _, h := range hosts {
+			found[h.Alias] = true
+		}
+		var missing []string
+		for a := range wanted {
+			if !found[a] {
+				missing = append(missing, a)
+			}
+		}
+		if len(missing) > 0 {
+			return fmt.Errorf("hosts not found in ssh config: %s", strings.Join(missing, ", "))
+		}
+	}
+
+	existing, err := s.bastionSvc.List()
+	if err != nil {
+		return err
+	}
+	names := make(map[string]bool, len(existing))
+	for _, b := range existing {
+		names[b.Name] = true
+	}
+
+	for _, h := range hosts {
+		if len(wanted) > 0 && !wanted[h.Alias] {
+			continue
+		}
+		if names[h.Alias] || h.User == "" {
+			// Bastions require a username; hosts without one must be added manually.
+			continue
+		}
+		b, err := s.bastionSvc.Create(models.BastionCreate{
+			Name:     h.Alias,
+			Host:     h.HostName,
+			Port:     h.Port,
+			Username: h.User,
+			PkeyPath: h.IdentityFile,
+		})
+		if err != nil {
+			return err
+		}
+		names[b.Name] = true
+		result.ImportedBastions = append(result.ImportedBastions, b.Name)
+	}
+	return nil
+}
+
+func (s *SetupService) createBastion(req *models.BastionCreate, result *models.SetupStepResult) error {
+	if req == nil {
+		return errors.New("bastion is required (or set skip=true)")
+	}
+	req.Normalize()
+	if req.Host == "" || req.Username == "" {
+		return errors.New("bastion host and username are required")
+	}
+	b, err := s.bastionSvc.Create(*req)
+	if err != nil {
+		return err
+	}
+	result.BastionID = b.ID
+	return nil
+}
+
+func (s *SetupService) createMapping(req *models.MappingCreate, result *models.SetupStepResult) error {
+	if req == nil {
+		return errors.New("mapping is required (or set skip=true)")
+	}
+	m, err := s.mappingSvc.Create(*req)
+	if err != nil {
+		return err
+	}
+	result.MappingID = m.ID
+	return nil
+}
+
+func (s *SetupService) setAdminToken(token string, generate bool, result *models.SetupStepResult) error {
+	token = strings.TrimSpace(token)
+	if generate {
+		buf := make([]byte, 24)
+		if _, err := rand.Read(buf); err != nil {
+			return fmt.Errorf("failed to generate token: %w", err)
+		}
+		token = hex.EncodeToString(buf)
+		result.AdminToken = token
+	}
+	if len(token) < minAdminTokenLength {
+		return fmt.Errorf("admin token must be at least %d characters", minAdminTokenLength)
+	}
+
+	hash := hashAdminToken(token)
+	if err := database.SetSetting(AdminTokenSettingKey, hash); err != nil {
+		return fmt.Errorf("failed to save admin token: %w", err)
+	}
+
+	s.mu.Lock()
+	s.tokenHash = hash
+	s.tokenHashValid = true
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *SetupService) setBindAddress(addr string, result *models.SetupStepResult) error {
+	addr = strings.TrimSpace(addr)
+	if net.ParseIP(addr) == nil {
+		return fmt.Errorf("invalid bind address: %q", addr)
+	}
+	if err := database.SetSetting(BindAddressSettingKey, addr); err != nil {
+		return fmt.Errorf("failed to save bind address: %w", err)
+	}
+	result.RestartRequired = true
+	return nil
+}
+
+// AdminTokenRequired reports whether non-loopback clients must present the admin token.
+// It fails closed: when the setting cannot be loaded the token is considered required.
+func (s *SetupService) AdminTokenRequired() bool {
+	hash, err := s.loadTokenHash()
+	return err != nil || hash != ""
+}
+
+// VerifyAdminToken checks token against the configured admin token.
+func (s *SetupService) VerifyAdminToken(token string) bool {
+	hash, err := s.loadTokenHash()
+	if err != nil || hash == "" {
+		return false
+	}
+	got := hashAdminToken(strings.TrimSpace(token))
+	return subtle.ConstantTimeCompare([]byte(got), []byte(hash)) == 1
+}
+
+func (s *SetupService) loadTokenHash() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.tokenHashValid {
+		hash, _, err := database.GetSetting(AdminTokenSettingKey)
+		if err != nil {
+			return "", err
+		}
+		s.tokenHash = hash
+		s.tokenHashValid = true
+	}
+	return s.tokenHash, nil
+}
+
+func hashAdminToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func setupStepIndex(step string) int {
+	if step == models.SetupStepDone {
+		return len(models.SetupSteps)
+	}
+	for i, s := range models.SetupSteps {
+		if s == step {
+			return i
+		}
+	}
+	return -1
+}
+
+// ResolveBindAddress returns the bind address persisted by the setup wizard, or fallback when unset.
+func ResolveBindAddress(fallback string) string {
+	v, ok, err := database.GetSetting(BindAddressSettingKey)
+	if err != nil || !ok || v == "" {
+		return fallback
+	}
+	return v
+}
@@ -0,0 +1,188 @@
+package service
+
+import (
+	"bastion/core"
+	"bastion/models"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// DiscoverSSHProcesses lists the ssh clients forwarding ports on this host with the bastions and
+// mappings that would replace them in the workspace. Bastions are matched to existing ones by host,
+// port and username; mappings whose ID already exists are left out.
+func (s *MappingService) DiscoverSSHProcesses() (*models.SSHDiscovery, error) {
+	processes, meta := core.ListSSHProcesses()
+	bastions, err := s.bastionSvc.List()
+	if err != nil {
+		return nil, err
+	}
+	var mappings []models.Mapping
+	if err := s.scoped().Select("id").Find(&mappings).Error; err != nil {
+		return nil, fmt.Errorf("failed to list mappings: %w", err)
+	}
+	mappingIDs := make(map[string]bool, len(mappings))
+	for _, m := range mappings {
+		mappingIDs[m.ID] = true
+	}
+
+	planner := newSSHImportPlanner(bastions)
+	discovery := &models.SSHDiscovery{
+		Processes: make([]models.SSHProcessCandidate, 0, len(processes)),
+		Source:    meta.Source,
+		Error:     meta.Error,
+	}
+	for _, p := range processes {
+		discovery.Processes = append(discovery.Processes, planner.plan(p, mappingIDs))
+	}
+	return discovery, nil
+}
+
+// ImportSSHProcesses applies the bastions and mappings of the selected discovered ssh processes
+// (all when req.PIDs is empty), in one transaction as Apply does. The mappings are not started:
+// the ssh process still holds the local port until it is stopped.
+func (s *MappingService) ImportSSHProcesses(req models.SSHImportRequest) (*models.ApplyResult, error) {
+	discovery, err := s.DiscoverSSHProcesses()
+	if err != nil {
+		return nil, err
+	}
+	selected := make(map[int]bool, len(req.PIDs))
+	for _, pid := range req.PIDs {
+		selected[pid] = true
+	}
+
+	apply := models.ApplyRequest{DryRun: req.DryRun}
+	declared := make(map[string]bool)
+	found := make(map[int]bool)
+	for _, p := range discovery.Processes {
+		if len(selected) > 0 && !selected[p.PID] {
+			continue
+		}
+		found[p.PID] = true
+		for _, b := range p.Bastions {
+			// Processes using the same new bastion plan it identically.
+			if !declared[b.Name] {
+				declared[b.Name] = true
+				apply.Bastions = append(apply.Bastions, b)
+			}
+		}
+		apply.Mappings = append(apply.Mappings, p.Mappings...)
+	}
+	var missing []string
+	for _, pid := range req.PIDs {
+		if !found[pid] {
+			missing = append(missing, strconv.Itoa(pid))
+		}
+	}
+	if len(missing) > 0 {
+		return nil, wrapSentinel("no forwarding ssh process with pid "+strings.Join(missing, ", "), ErrInvalidApplyRequest)
+	}
+	if len(apply.Bastions)+len(apply.Mappings) == 0 {
+		return nil, wrapSentinel("nothing to import", ErrInvalidApplyRequest)
+	}
+	return s.Apply(apply)
+}
+
+// sshImportPlanner names the bastions of discovered ssh processes consistently across processes.
+type sshImportPlanner struct {
+	existing []models.Bastion
+	names    map[string]bool                 // existing and planned bastion names
+	planned  map[string]models.BastionCreate // by user@host:port
+}
+
+func newSSHImportPlanner(existing []models.Bastion) *sshImportPlanner {
+	p := &sshImportPlanner{
+		existing: existing,
+		names:    make(map[string]bool, len(existing)),
+		planned:  make(map[string]models.BastionCreate),
+	}
+	for _, b := range existing {
+		p.names[b.Name] = true
+	}
+	return p
+}
+
+// bastion returns the name of the bastion for e, and the bastion to create when none exists.
+func (p *sshImportPlanner) bastion(e models.SSHEndpoint) (string, *models.BastionCreate) {
+	for _, b := range p.existing {
+		if strings.EqualFold(b.Host, e.Host) && b.Port == e.Port && b.Username == e.User {
+			return b.Name, nil
+		}
+	}
+	hostPort := net.JoinHostPort(e.Host, strconv.Itoa(e.Port))
+	key := e.User + "@" + hostPort
+	if b, ok := p.planned[key]; ok {
+		return b.Name, &b
+	}
+
+	// The ssh config alias, else host:port as Create names bastions, else user@host:port.
+	name := e.Alias
+	if name == "" || p.names[name] {
+		name = hostPort
+	}
+	if p.names[name] {
+		name = key
+	}
+	for n := 2; p.names[name]; n++ {
+		name = fmt.Sprintf("%s-%d", key, n)
+	}
+	p.names[name] = true
+	b := models.BastionCreate{Name: name, Host: e.Host, Port: e.Port, Username: e.User, PkeyPath: e.IdentityFile}
+	p.planned[key] = b
+	return name, &b
+}
+
+// plan converts one ssh process. Local forwards become tcp mappings and dynamic ones socks5
+// mappings, both through the jump hosts and the destination.
+func (p *sshImportPlanner) plan(proc models.SSHProcess, mappingIDs map[string]bool) models.SSHProcessCandidate {
+	c := models.SSHProcessCandidate{SSHProcess: proc, Bastions: []models.BastionCreate{}, Mappings: []models.MappingCreate{}}
+
+	var chain []string
+	for _, e := range append(append([]models.SSHEndpoint{}, proc.JumpHosts...), proc.Destination) {
+		if e.User == "" {
+			c.Notes = append(c.Notes, fmt.Sprintf("no user known for %s; add the bastion manually", e.Host))
+			return c
+		}
+		name, create := p.bastion(e)
+		if create != nil {
+			c.Bastions = append(c.Bastions, *create)
+			if create.PkeyPath == "" {
+				c.Notes = append(c.Notes, fmt.Sprintf("bastion %s has no key file; set its password or key after importing", name))
+			}
+		}
+		chain = append(chain, name)
+	}
+
+	for _, f := range proc.Forwards {
+		m := models.MappingCreate{LocalHost: sshBindHost(f.BindAddress), LocalPort: f.Port, Chain: chain}
+		switch f.Kind {
+		case models.SSHForwardLocal:
+			m.Type, m.RemoteHost, m.RemotePort = "tcp", f.RemoteHost, f.RemotePort
+		case models.SSHForwardDynamic:
+			m.Type = "socks5"
+		default:
+			c.Notes = append(c.Notes, fmt.Sprintf("remote forward -R %s is not supported", f.Spec))
+			continue
+		}
+		m.ID = net.JoinHostPort(m.LocalHost, strconv.Itoa(m.LocalPort))
+		if mappingIDs[m.ID] {
+			c.Notes = append(c.Notes, fmt.Sprintf("mapping %s already exists", m.ID))
+			continue
+		}
+		c.Mappings = append(c.Mappings, m)
+	}
+	return c
+}
+
+// sshBindHost maps an ssh bind address to a mapping local host: none or localhost means loopback
+// and "*" all interfaces.
+func sshBindHost(bind string) string {
+	switch bind {
+	case "", "localhost":
+		return "127.0.0.1"
+	case "*":
+		return "0.0.0.0"
+	}
+	return bind
+}
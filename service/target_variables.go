@@ -0,0 +1,117 @@
+package service
+
+import (
+	"bastion/core"
+	"bastion/database"
+	"bastion/models"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrInvalidTargetVariable = errors.New("invalid target variable")
+
+// targetVariableSettingPrefix namespaces target variables among the persisted settings, so
+// templates cannot read other settings such as the admin token hash.
+const targetVariableSettingPrefix = "target_var."
+
+// ListTargetVariables returns the variables target templates can read with {{setting "name"}}.
+func ListTargetVariables() ([]models.TargetVariable, error) {
+	settings, err := database.ListSettings(targetVariableSettingPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list target variables: %w", err)
+	}
+	vars := make([]models.TargetVariable, len(settings))
+	for i, s := range settings {
+		vars[i] = models.TargetVariable{
+			Name:      strings.TrimPrefix(s.Key, targetVariableSettingPrefix),
+			Value:     s.Value,
+			UpdatedAt: s.UpdatedAt,
+		}
+	}
+	return vars, nil
+}
+
+// SetTargetVariable stores a target variable. Running mappings keep the target they started with.
+func SetTargetVariable(name, value string) error {
+	if err := validateTargetVariableName(name); err != nil {
+		return err
+	}
+	if strings.TrimSpace(value) == "" {
+		return wrapSentinel("target variable value must not be empty", ErrInvalidTargetVariable)
+	}
+	return database.SetSetting(targetVariableSettingPrefix+name, value)
+}
+
+// DeleteTargetVariable removes a target variable if it exists.
+func DeleteTargetVariable(name string) error {
+	if err := validateTargetVariableName(name); err != nil {
+		return err
+	}
+	return database.DeleteSetting(targetVariableSettingPrefix + name)
+}
+
+func validateTargetVariableName(name string) error {
+	if name == "" || len(name) > 64 {
+		return wrapSentinel("target variable name must be 1-64 characters", ErrInvalidTargetVariable)
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-' || r == '.') {
+			return wrapSentinel(fmt.Sprintf("invalid target variable name %q: use letters, digits, '_', '-' and '.'", name), ErrInvalidTargetVariable)
+		}
+	}
+	return nil
+}
+
+func lookupTargetVariable(name string) (string, bool, error) {
+	if err := validateTargetVariableName(name); err != nil {
+		return "", false, err
+	}
+	return database.GetSetting(targetVariableSettingPrefix + name)
+}
+
+// validateRemoteTarget checks the remote of a tcp mapping: a host, or a target template checked
+// for syntax only, and a port template if any.
+func validateRemoteTarget(host, portTemplate string) error {
+	if core.IsTargetTemplate(host) {
+		if err := core.ValidateTargetTemplate("remote_host", host); err != nil {
+			return &models.FieldError{Field: "remote_host", Err: err}
+		}
+	} else if host != "" {
+		if err := models.ValidateHost("remote_host", host); err != nil {
+			return err
+		}
+	}
+	if portTemplate != "" {
+		if err := core.ValidateTargetTemplate("remote_port_template", portTemplate); err != nil {
+			return &models.FieldError{Field: "remote_port_template", Err: err}
+		}
+	}
+	return nil
+}
+
+// resolveMappingTarget returns mapping with its target templates resolved, or mapping itself when
+// it has none.
+func resolveMappingTarget(mapping *models.Mapping) (*models.Mapping, error) {
+	if !core.IsTargetTemplate(mapping.RemoteHost) && mapping.RemotePortTemplate == "" {
+		return mapping, nil
+	}
+	resolved := *mapping
+	host, err := core.ExpandTargetTemplate("remote_host", mapping.RemoteHost, lookupTargetVariable)
+	if err != nil {
+		return nil, err
+	}
+	host = models.NormalizeHost(host)
+	if err := models.ValidateHost("remote_host", host); err != nil {
+		return nil, fmt.Errorf("%s resolved to an invalid host: %w", mapping.RemoteHost, err)
+	}
+	resolved.RemoteHost = host
+	if mapping.RemotePortTemplate != "" {
+		port, err := core.ExpandTargetPort("remote_port_template", mapping.RemotePortTemplate, lookupTargetVariable)
+		if err != nil {
+			return nil, err
+		}
+		resolved.RemotePort = port
+	}
+	return &resolved, nil
+}
@@ -0,0 +1,38 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// ErrVersionConflict is returned when an update was based on a stale version of a bastion or mapping.
+var ErrVersionConflict = errors.New("version conflict")
+
+// saveVersioned saves row (a loaded *models.Bastion or *models.Mapping whose Version field is
+// version) and increments its version. With expected > 0 the caller's version must match the
+// loaded one. The increment is conditional on the stored version, so a row changed by another
+// writer since it was loaded is never overwritten.
+func saveVersioned(db *gorm.DB, row interface{}, version *int, expected int) error {
+	loaded := *version
+	if expected > 0 && expected != loaded {
+		return wrapSentinel(fmt.Sprintf("version conflict: updating version %d, current version is %d", expected, loaded), ErrVersionConflict)
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(row).Where("version = ?", loaded).UpdateColumn("version", loaded+1)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return wrapSentinel("version conflict: modified concurrently, reload and retry", ErrVersionConflict)
+		}
+		*version = loaded + 1
+		return tx.Save(row).Error
+	})
+	if err != nil {
+		*version = loaded
+	}
+	return err
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"bastion/models"
+	"bastion/state"
+	"fmt"
+	"sort"
+
+	"gorm.io/gorm"
+)
+
+// WorkspaceService lists the workspaces that hold bastions or mappings. Workspaces have no row of
+// their own: one exists as soon as something is created in it.
+type WorkspaceService struct {
+	db    *gorm.DB
+	state *state.AppState
+}
+
+// NewWorkspaceService constructs a workspace service
+func NewWorkspaceService(db *gorm.DB, appState *state.AppState) *WorkspaceService {
+	return &WorkspaceService{db: db, state: appState}
+}
+
+// List returns every workspace with its bastion, mapping and running session counts. The default
+// workspace is always included.
+func (s *WorkspaceService) List() ([]models.WorkspaceSummary, error) {
+	type count struct {
+		Workspace string
+		N         int64
+	}
+	summaries := map[string]*models.WorkspaceSummary{
+		models.DefaultWorkspace: {Name: models.DefaultWorkspace},
+	}
+	get := func(name string) *models.WorkspaceSummary {
+		if summaries[name] == nil {
+			summaries[name] = &models.WorkspaceSummary{Name: name}
+		}
+		return summaries[name]
+	}
+
+	var bastions, mappings []count
+	if err := s.db.Model(&models.Bastion{}).Select("workspace, COUNT(*) AS n").Group("workspace").Scan(&bastions).Error; err != nil {
+		return nil, fmt.Errorf("failed to count bastions: %w", err)
+	}
+	if err := s.db.Model(&models.Mapping{}).Select("workspace, COUNT(*) AS n").Group("workspace").Scan(&mappings).Error; err != nil {
+		return nil, fmt.Errorf("failed to count mappings: %w", err)
+	}
+	for _, c := range bastions {
+		get(c.Workspace).Bastions = c.N
+	}
+	for _, c := range mappings {
+		get(c.Workspace).Mappings = c.N
+	}
+
+	s.state.RLock()
+	for key := range s.state.Sessions {
+		workspace, _ := models.SplitWorkspaceKey(key)
+		get(workspace).Running++
+	}
+	s.state.RUnlock()
+
+	result := make([]models.WorkspaceSummary, 0, len(summaries))
+	for _, summary := range summaries {
+		result = append(result, *summary)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result, nil
+}
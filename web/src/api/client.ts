@@ -3,11 +3,13 @@ import type { AxiosError } from "axios";
 import { ElMessage } from "element-plus";
 
 import { i18n } from "@/plugins/i18n";
+import { DEFAULT_WORKSPACE, useAppStore } from "@/store/app";
 
 type ApiV2Envelope = {
   code: string;
   message: string;
   data: any;
+  request_id?: string;
 };
 
 function isApiV2Envelope(value: any): value is ApiV2Envelope {
@@ -76,9 +78,16 @@ function formatV2Error(envelope: ApiV2Envelope): string {
   const localized = apiCodeMessage(envelope.code, data);
   const base = localized || (envelope.message || "").trim() || t("apiError.UNKNOWN");
 
-  if (envelope.code === "RESOURCE_BUSY") return base;
-  if (detail) return `${base}: ${detail}`;
-  return base;
+  let msg = base;
+  if (envelope.code !== "RESOURCE_BUSY" && detail) msg = `${base}: ${detail}`;
+  // Specific error codes (data.error.code) come with a remediation hint.
+  const apiErr = data?.error;
+  if (apiErr?.code && apiErr.code !== envelope.code && typeof apiErr.hint === "string" && apiErr.hint) {
+    msg += ` (${apiErr.hint})`;
+  }
+  // The request ID locates the failed call in the server logs.
+  if (envelope.request_id) msg += ` ${t("apiError.REQUEST_ID", { id: envelope.request_id })}`;
+  return msg;
 }
 
 function formatError(err: unknown): string {
@@ -110,6 +119,15 @@ export const api = axios.create({
   timeout: 15000,
 });
 
+// Every request is scoped to the workspace selected in the top bar.
+api.interceptors.request.use((config) => {
+  const workspace = useAppStore().workspace;
+  if (workspace && workspace !== DEFAULT_WORKSPACE) {
+    config.headers.set("X-Bastion-Workspace", workspace);
+  }
+  return config;
+});
+
 api.interceptors.response.use(
   (res) => {
     if (isApiV2Envelope(res.data)) {
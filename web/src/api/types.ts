@@ -1,5 +1,6 @@
 export type Bastion = {
   id: number;
+  workspace: string;
   name: string;
   host: string;
   port: number;
@@ -7,6 +8,13 @@ export type Bastion = {
   password?: string;
   pkey_path?: string;
   pkey_passphrase?: string;
+  ciphers?: string;
+  macs?: string;
+  key_exchanges?: string;
+  description?: string;
+  tags: string[];
+  version: number;
+  updated_at: string;
 };
 
 export type BastionCreate = {
@@ -17,20 +25,78 @@ export type BastionCreate = {
   password?: string;
   pkey_path?: string;
   pkey_passphrase?: string;
+  ciphers?: string;
+  macs?: string;
+  key_exchanges?: string;
+  description?: string;
+  tags?: string[];
 };
 
 export type MappingRead = {
   id: string;
+  workspace: string;
   local_host: string;
   local_port: number;
   remote_host: string;
   remote_port: number;
+  remote_port_template?: string;
+  depends_on?: string[];
   chain: string[];
   allow_cidrs: string[];
   deny_cidrs: string[];
+  target_allow?: string[];
+  target_deny?: string[];
   type: "tcp" | "socks5" | "http" | string;
   auto_start: boolean;
   running: boolean;
+  upstream_proxy?: string;
+  max_conns_per_ip?: number;
+  conn_rate_per_ip?: number;
+  conn_burst_per_ip?: number;
+  dial_timeout_seconds?: number;
+  dial_retries?: number;
+  dial_retry_delay_ms?: number;
+  dial_backoff?: "fixed" | "exponential" | string;
+  dial_hold_seconds?: number;
+  dial_happy_eyeballs?: boolean;
+  audit_disabled?: boolean;
+  audit_sample_rate?: number;
+  standby?: boolean;
+  standby_idle_seconds?: number;
+  listen_family?: "" | "dual" | "ipv4" | "ipv6";
+  ftp_helper?: boolean;
+  tls_mode?: "" | "terminate" | "originate" | "reencrypt";
+  tls_cert_file?: string;
+  tls_key_file?: string;
+  tls_server_name?: string;
+  tls_ca_file?: string;
+  tls_insecure_skip_verify?: boolean;
+  quota_bytes_per_day?: number;
+  quota_action?: "refuse" | "throttle" | string;
+  quota_throttle_bps?: number;
+  quota?: MappingQuotaStatus;
+  state: "stopped" | "running" | "standby";
+  runtime_port?: number;
+  expose_addr?: string;
+  exposed_by?: string;
+  exposed_at?: string;
+  description?: string;
+  tags: string[];
+  version: number;
+  updated_at: string;
+  total_bytes_up: number;
+  total_bytes_down: number;
+  last_started_at?: string;
+  last_active_at?: string;
+};
+
+export type MappingQuotaStatus = {
+  limit_bytes: number;
+  used_bytes: number;
+  exceeded: boolean;
+  action: "refuse" | "throttle" | string;
+  period_start: string;
+  reset_at: string;
 };
 
 export type MappingCreate = {
@@ -39,11 +105,89 @@ export type MappingCreate = {
   local_port: number;
   remote_host?: string;
   remote_port?: number;
+  remote_port_template?: string;
+  depends_on?: string[];
   chain?: string[];
   allow_cidrs?: string[];
   deny_cidrs?: string[];
+  target_allow?: string[];
+  target_deny?: string[];
   type?: string;
   auto_start?: boolean;
+  upstream_proxy?: string;
+  max_conns_per_ip?: number;
+  conn_rate_per_ip?: number;
+  conn_burst_per_ip?: number;
+  dial_timeout_seconds?: number;
+  dial_retries?: number;
+  dial_retry_delay_ms?: number;
+  dial_backoff?: "fixed" | "exponential" | string;
+  dial_hold_seconds?: number;
+  dial_happy_eyeballs?: boolean;
+  audit_disabled?: boolean;
+  audit_sample_rate?: number;
+  standby?: boolean;
+  standby_idle_seconds?: number;
+  listen_family?: "" | "dual" | "ipv4" | "ipv6";
+  ftp_helper?: boolean;
+  tls_mode?: "" | "terminate" | "originate" | "reencrypt";
+  tls_cert_file?: string;
+  tls_key_file?: string;
+  tls_server_name?: string;
+  tls_ca_file?: string;
+  tls_insecure_skip_verify?: boolean;
+  quota_bytes_per_day?: number;
+  quota_action?: "refuse" | "throttle" | string;
+  quota_throttle_bps?: number;
+  description?: string;
+  tags?: string[];
+};
+
+export type SetupStep =
+  | "import_ssh_config"
+  | "bastion"
+  | "mapping"
+  | "admin_token"
+  | "bind_address"
+  | "done";
+
+export type SetupStatus = {
+  needed: boolean;
+  completed: boolean;
+  step: SetupStep;
+  steps: SetupStep[];
+  bastion_count: number;
+  mapping_count: number;
+  admin_token_set: boolean;
+  bind_address?: string;
+};
+
+export type SSHConfigHost = {
+  alias: string;
+  hostname: string;
+  port: number;
+  user?: string;
+  identity_file?: string;
+  proxy_jump?: string;
+};
+
+export type SetupStepRequest = {
+  skip?: boolean;
+  hosts?: string[];
+  bastion?: BastionCreate;
+  mapping?: MappingCreate;
+  admin_token?: string;
+  generate_token?: boolean;
+  bind_address?: string;
+};
+
+export type SetupStepResult = {
+  status: SetupStatus;
+  imported_bastions?: string[];
+  bastion_id?: number;
+  mapping_id?: string;
+  admin_token?: string;
+  restart_required?: boolean;
 };
 
 export type UpdateCheckResponse = {
@@ -53,6 +197,36 @@ export type UpdateCheckResponse = {
   release_url?: string;
   asset_name?: string;
   download_url?: string;
+  prerelease: boolean;
+  channel: UpdateChannel;
+  pinned_tag?: string;
+};
+
+export type UpdateChannel = "stable" | "beta";
+
+export type UpdateChannelResponse = {
+  channel: UpdateChannel;
+  pinned_tag?: string;
+};
+
+export type UpdateRelease = {
+  tag: string;
+  name?: string;
+  prerelease: boolean;
+  published_at?: string;
+  release_url?: string;
+  changelog?: string;
+  asset_name?: string;
+  current: boolean;
+  pinned: boolean;
+  newer: boolean;
+};
+
+export type UpdateReleasesResponse = {
+  current_version: string;
+  channel: UpdateChannel;
+  pinned_tag?: string;
+  releases: UpdateRelease[];
 };
 
 export type UpdateProxyResponse = {
@@ -65,12 +239,62 @@ export type UpdateProxyResponse = {
   source: "manual" | "env" | "none" | string;
 };
 
+export type UpdateProxyTestResponse = {
+  url: string;
+  proxy_used: string;
+  source: "manual" | "env" | "none" | "request" | string;
+  status_code?: number;
+  status?: string;
+  latency_ms: number;
+  error?: string;
+};
+
 export type UpdateApplyResponse = {
   ok: boolean;
   target_version: string;
   message: string;
   helper_pid?: number;
   helper_log_path?: string;
+  verification?: UpdateVerification;
+};
+
+export type UpdateStatus = {
+  checked_at: string;
+  current_version: string;
+  latest_version?: string;
+  update_available: boolean;
+  prerelease: boolean;
+  release_url?: string;
+  asset_name?: string;
+  channel: UpdateChannel;
+  pinned_tag?: string;
+  error?: string;
+  error_at?: string;
+};
+
+export type UpdateStatusResponse = {
+  status: UpdateStatus | null;
+  checker: {
+    enabled: boolean;
+    interval_minutes: number;
+    next_check_at?: string;
+  };
+};
+
+export type UpdateRollbackStatus = {
+  current_version: string;
+  available: boolean;
+  previous_version?: string;
+  saved_at?: string;
+  reason?: string;
+};
+
+export type UpdateVerification = {
+  checksum: "verified" | "skipped" | "failed";
+  checksum_file?: string;
+  sha256?: string;
+  signature: "verified" | "not_configured" | "failed";
+  signature_key_id?: string;
 };
 
 export type HTTPLog = {
@@ -89,6 +313,34 @@ export type HTTPLog = {
   resp_size: number;
   is_gzipped: boolean;
   duration_ms: number;
+  ttfb_ms: number;
+  ttlb_ms: number;
+  request_seq: number;
+  connection_reused: boolean;
+  orphan?: boolean; // response logged without its request
+  client_ip?: string; // original client (behind AUDIT_TRUSTED_PROXIES, from forwarding headers)
+  proxy_ip?: string; // the trusted proxy when client_ip came from its headers
+  // Header maps keyed by lowercase name.
+  request_headers?: Record<string, string[]>;
+  response_headers?: Record<string, string[]>;
+};
+
+// Occurrence of the q keyword in a log (list endpoint with matches=<n>).
+export type HTTPLogMatch = {
+  field: "url" | "request" | "response" | "response_decoded";
+  offset: number;
+  length: number;
+  snippet: string;
+  match_start: number;
+  match_end: number;
+};
+
+// Paged response of the v2 list endpoints (when page or page_size is given).
+export type ListPage<T> = {
+  items: T[];
+  page: number;
+  page_size: number;
+  total: number;
 };
 
 export type HTTPLogsPageResponse = {
@@ -102,6 +354,80 @@ export type HTTPLogPartResult = {
   data: string;
   truncated: boolean;
   truncated_reason?: string;
+  content_type?: string;
+  charset?: string;
+  binary?: boolean;
+  encoding?: "base64";
+  format?: "json" | "xml";
+};
+
+export type HTTPLogDiffSide = {
+  id: number;
+  timestamp: string;
+  mapping_id: string;
+  local_port: number;
+  method: string;
+  url: string;
+  host: string;
+  status_code: number;
+  duration_ms: number;
+};
+
+export type HTTPHeaderDiff = {
+  name: string;
+  change: "added" | "removed" | "changed";
+  a?: string;
+  b?: string;
+};
+
+export type HTTPBodyDiff = {
+  equal: boolean;
+  a_size: number;
+  b_size: number;
+  binary?: boolean;
+  diff?: string;
+  truncated: boolean;
+  truncated_reason?: string;
+};
+
+export type HTTPLogDiff = {
+  a: HTTPLogDiffSide;
+  b: HTTPLogDiffSide;
+  fields: { field: string; a: string; b: string }[];
+  request_headers: HTTPHeaderDiff[];
+  response_headers: HTTPHeaderDiff[];
+  request_body: HTTPBodyDiff;
+  response_body: HTTPBodyDiff;
+};
+
+export type HTTPReplayRequest = {
+  mapping_id?: string;
+  set_headers?: Record<string, string>;
+  remove_headers?: string[];
+};
+
+export type HTTPReplayResult = {
+  original_id: number;
+  replay_id: number;
+  mapping_id: string;
+  target: string;
+  route: string;
+  status_code: number;
+  duration_ms: number;
+};
+
+export type HTTPLogCurl = {
+  command: string;
+  body_truncated: boolean;
+};
+
+export type MappingEvent = {
+  id: number;
+  mapping_id: string;
+  timestamp: string;
+  type: "start" | "stop" | "start_failed" | "dial_failed" | string;
+  reason: string;
+  detail?: string;
 };
 
 export type ErrorLog = {
@@ -115,10 +441,77 @@ export type ErrorLog = {
   context: string;
 };
 
+export type NetInterface = {
+  name: string;
+  addrs: string[];
+  up: boolean;
+  loopback: boolean;
+};
+
+export type ThroughputStats = {
+  up_bps_1s: number;
+  up_bps_10s: number;
+  up_bps_60s: number;
+  down_bps_1s: number;
+  down_bps_10s: number;
+  down_bps_60s: number;
+  peak_up_bps: number;
+  peak_down_bps: number;
+  peak_connections: number;
+};
+
 export type StatsSnapshot = {
   up_bytes: number;
   down_bytes: number;
   connections: number;
+  local_port: number;
+  throughput?: ThroughputStats;
+  total_up_bytes: number;
+  total_down_bytes: number;
+  last_started_at?: string;
+  last_active_at?: string;
 };
 
 export type StatsMap = Record<string, StatsSnapshot>;
+
+export type DBBackupInfo = {
+  path: string;
+  size_bytes: number;
+  created_at: string;
+};
+
+export type DBVacuumResult = {
+  size_before: number;
+  size_after: number;
+  duration_ms: number;
+};
+
+export type DBIntegrityResult = {
+  ok: boolean;
+  quick: boolean;
+  problems?: string[];
+};
+
+export type WorkspaceSummary = {
+  name: string;
+  bastions: number;
+  mappings: number;
+  running: number;
+};
+
+export type DryRunStep = {
+  name?: string;
+  addr: string;
+  status: "ok" | "failed" | "skipped";
+  duration_ms: number;
+  error?: string;
+};
+
+export type DryRunReport = {
+  mapping_id: string;
+  ok: boolean;
+  route: string;
+  error?: string;
+  hops: DryRunStep[];
+  target?: DryRunStep;
+};
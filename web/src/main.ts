@@ -9,6 +9,7 @@ import * as Icons from "@element-plus/icons-vue";
 
 import App from "@/App.vue";
 import { i18n, syncI18nWithStore } from "@/plugins/i18n";
+import { watchUiVersion } from "@/plugins/uiVersion";
 import { router } from "@/router";
 import { useAppStore } from "@/store/app";
 import "@/styles/index.css";
@@ -42,3 +43,5 @@ for (const [key, component] of Object.entries(Icons)) {
 }
 
 app.mount("#app");
+
+watchUiVersion();
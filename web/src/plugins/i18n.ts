@@ -17,15 +17,20 @@ const messages = {
       refresh: "刷新",
       shutdown: "关闭服务",
       language: "语言",
+      workspace: "工作区",
+      workspacePlaceholder: "选择或输入工作区",
     },
     menuToggle: "展开/收起侧边栏",
     toast: {
       shutdownInitiated: "已发起关闭",
       updateStarted: "已开始更新",
+      rollbackStarted: "已开始回滚",
       themeDark: "已切换为暗黑",
       themeLight: "已切换为浅色",
       languageSwitchedToZh: "已切换为中文",
       languageSwitchedToEn: "已切换为英文",
+      workspaceSwitched: "已切换到工作区 {name}",
+      workspaceInvalid: "工作区名称只能包含字母、数字、-、_ 和 .",
     },
     apiError: {
       INVALID_REQUEST: "请求参数错误",
@@ -37,11 +42,13 @@ const messages = {
       RESOURCE_BUSY_PID_ADDR: "资源忙（端口占用）：{addr}（PID {pid}）",
       RESOURCE_BUSY_OWNER: "资源忙（端口占用）：PID {pid}，{exe}",
       RESOURCE_BUSY_PID: "资源忙（端口占用）：PID {pid}",
+      RATE_LIMITED: "请求过于频繁，请稍后再试",
       BAD_GATEWAY: "网关错误",
       INTERNAL_ERROR: "服务器内部错误",
       NETWORK: "网络异常",
       TIMEOUT: "请求超时",
       UNKNOWN: "请求失败",
+      REQUEST_ID: "（请求 ID：{id}）",
     },
 
     common: {
@@ -64,6 +71,11 @@ const messages = {
       no: "否",
       on: "开",
       off: "关",
+      description: "描述",
+      tags: "标签",
+      filterPlaceholder: "搜索名称、地址、描述或标签",
+      filterTags: "按标签筛选",
+      versionConflict: "该条目已被其他人修改，已载入最新内容，请确认后重新保存",
     },
     theme: {
       light: "浅色",
@@ -114,6 +126,31 @@ const messages = {
       manualProxy: "手动代理（持久化）",
       effectiveProxy: "生效代理",
       source: "来源",
+      channel: "更新通道",
+      channelStable: "稳定版",
+      channelBeta: "测试版（含预发布）",
+      pinnedTag: "固定版本",
+      notPinned: "未固定",
+      unpin: "取消固定",
+      releases: "最近版本",
+      loadReleases: "加载版本列表",
+      releaseTag: "版本",
+      releasePublished: "发布时间",
+      releaseChangelog: "更新日志",
+      prerelease: "预发布",
+      currentRelease: "当前",
+      newerRelease: "较新",
+      noAsset: "无本平台资源",
+      pin: "固定",
+      pinned: "已固定",
+      rollback: "回滚",
+      lastChecked: "上次检查",
+      testProxy: "测试",
+      proxyTestOk: "经 {proxy} 访问 GitHub 成功：HTTP {status}，耗时 {latency} ms",
+      autoCheckEvery: "每 {minutes} 分钟自动检查",
+      verification: "更新包校验",
+      checksum: "校验和",
+      signature: "签名",
     },
     bastions: {
       title: "跳板机",
@@ -124,6 +161,10 @@ const messages = {
       password: "密码",
       pkeyPath: "私钥路径",
       pkeyPassphrase: "私钥口令",
+      ciphers: "加密算法",
+      macs: "MAC 算法",
+      keyExchanges: "密钥交换算法",
+      algorithmsPlaceholder: "默认；逗号分隔，以 + 开头则追加到默认列表",
     },
     mappings: {
       title: "映射",
@@ -135,24 +176,73 @@ const messages = {
       },
       localHost: "本地主机",
       localPort: "本地端口",
+      localPortAuto: "自动",
+      localPortAutoHint: "0 = 启动时自动分配空闲端口",
+      listenFamily: "监听协议族",
+      listenFamilies: {
+        auto: "按本地主机",
+        dual: "双栈（IPv4 + IPv6）",
+        ipv4: "仅 IPv4",
+        ipv6: "仅 IPv6",
+      },
       remoteHost: "远端主机",
       remotePort: "远端端口",
+      remotePortTemplate: "远端端口模板",
+      forceEdit: "修改地址",
+      forceEditHint: "允许修改类型与本地/远端地址，运行中的映射会重启",
+      remoteTemplateHint: "可使用模板（env / setting 函数），启动时解析",
+      ftpHelper: "FTP 助手",
+      ftpHelperHint: "改写 PASV/EPSV 应答，数据连接经同一跳板链转发",
+      tlsMode: "TLS",
+      tlsModes: {
+        none: "不处理",
+        terminate: "本地终止 TLS（明文转发）",
+        originate: "向远端发起 TLS",
+        reencrypt: "终止并重新加密",
+      },
+      tlsCertFile: "证书文件",
+      tlsKeyFile: "私钥文件",
+      tlsServerName: "SNI 主机名",
+      tlsCaFile: "CA 文件",
+      tlsCaFileHint: "留空使用系统根证书",
+      tlsInsecure: "跳过证书校验",
       id: "ID",
       type: "类型",
       local: "本地",
       remote: "远端",
       chain: "跳板链",
+      dependsOn: "依赖映射",
+      dependsOnHint: "先于本映射启动的映射（同一工作区）",
       allowCidrs: "允许 CIDR",
       denyCidrs: "拒绝 CIDR",
+      targetAllow: "允许目标",
+      targetDeny: "拒绝目标",
       autoStart: "自启",
       running: "运行中",
+      standby: "待命",
+      quotaExceeded: "超出配额",
+      exposed: "已暴露",
+      exposedBy: "由 {by} 于 {at} 开启",
+      exposedBanner: "以下映射已暴露到非本机网络，同网段的其他机器可以访问：{ids}",
+      expose: "暴露",
+      unexpose: "取消暴露",
+      exposeTitle: "暴露映射 {id}",
+      exposeWarning: "映射将监听所选网卡地址（局域网 / Tailscale 等），该网络中的其他机器都能连接此端口。",
+      exposeAddress: "网卡地址",
+      exposeConfirm: "我了解风险，确认暴露",
       start: "启动",
       stop: "停止",
       copyModify: "复制修改",
       trafficChart: "流量图",
       trafficUp: "上行",
       trafficDown: "下行",
+      trafficTotalUp: "累计上行",
+      trafficTotalDown: "累计下行",
+      lastStartedAt: "最近启动",
       trafficConnections: "连接数",
+      trafficRate: "当前速率（10 秒）",
+      trafficPeakRate: "峰值速率",
+      trafficPeakConnections: "峰值连接数",
     },
     httpLogs: {
       title: "HTTP 日志",
@@ -164,8 +254,10 @@ const messages = {
         host: "Host",
         url: "URL",
         duration: "耗时(ms)",
+        ttfb: "首字节(ms)",
       },
       q: "关键字",
+      live: "实时",
       request: "请求",
       response: "响应",
       pair: "请求/响应",
@@ -173,6 +265,7 @@ const messages = {
       body: "Body",
       decodeGzip: "解压 gzip（仅响应 Body）",
       truncated: "内容被截断：{reason}",
+      binaryBody: "[二进制内容：{type}，base64 编码 {size} 字符]\n{data}",
     },
     errorLogs: {
       title: "错误日志",
@@ -188,7 +281,12 @@ const messages = {
       shutdownAlert: "此操作会关闭 Bastion 服务进程，请确认。",
       updateTitle: "应用更新确认",
       updateAlert: "此操作会下载并替换当前可执行文件，然后重启。",
+      rollbackTitle: "回滚确认",
+      rollbackAlert: "此操作会将可执行文件换回上一个版本 {version}，然后重启。",
       deleteTitle: "确认删除？",
+      uiReloadTitle: "界面已更新",
+      uiReloadAlert: "服务端已提供新版本的界面，刷新页面以加载。",
+      uiReload: "刷新",
     },
   },
   en: {
@@ -205,15 +303,20 @@ const messages = {
       refresh: "Refresh",
       shutdown: "Shutdown",
       language: "Language",
+      workspace: "Workspace",
+      workspacePlaceholder: "Select or type a workspace",
     },
     menuToggle: "Toggle sidebar",
     toast: {
       shutdownInitiated: "Shutdown initiated",
       updateStarted: "Update started",
+      rollbackStarted: "Rollback started",
       themeDark: "Switched to dark",
       themeLight: "Switched to light",
       languageSwitchedToZh: "Switched to Chinese",
       languageSwitchedToEn: "Switched to English",
+      workspaceSwitched: "Switched to workspace {name}",
+      workspaceInvalid: "Workspace names may only contain letters, digits, '-', '_' and '.'",
     },
     apiError: {
       INVALID_REQUEST: "Invalid request",
@@ -225,11 +328,13 @@ const messages = {
       RESOURCE_BUSY_PID_ADDR: "Resource busy (port in use): {addr} (PID {pid})",
       RESOURCE_BUSY_OWNER: "Resource busy (port in use): PID {pid}, {exe}",
       RESOURCE_BUSY_PID: "Resource busy (port in use): PID {pid}",
+      RATE_LIMITED: "Too many requests, please retry shortly",
       BAD_GATEWAY: "Bad gateway",
       INTERNAL_ERROR: "Internal server error",
       NETWORK: "Network error",
       TIMEOUT: "Request timeout",
       UNKNOWN: "Request failed",
+      REQUEST_ID: "(request ID: {id})",
     },
 
     common: {
@@ -252,6 +357,11 @@ const messages = {
       no: "No",
       on: "On",
       off: "Off",
+      description: "Description",
+      tags: "Tags",
+      filterPlaceholder: "Search name, address, description or tags",
+      filterTags: "Filter by tags",
+      versionConflict: "This item was changed elsewhere. The latest version has been loaded; review it and save again",
     },
     theme: {
       light: "Light",
@@ -302,6 +412,31 @@ const messages = {
       manualProxy: "Manual proxy (persisted)",
       effectiveProxy: "Effective proxy",
       source: "Source",
+      channel: "Update channel",
+      channelStable: "Stable",
+      channelBeta: "Beta (includes pre-releases)",
+      pinnedTag: "Pinned release",
+      notPinned: "Not pinned",
+      unpin: "Unpin",
+      releases: "Recent releases",
+      loadReleases: "Load releases",
+      releaseTag: "Release",
+      releasePublished: "Published",
+      releaseChangelog: "Changelog",
+      prerelease: "Pre-release",
+      currentRelease: "Current",
+      newerRelease: "Newer",
+      noAsset: "No build for this platform",
+      pin: "Pin",
+      pinned: "Pinned",
+      rollback: "Roll back",
+      lastChecked: "Last checked",
+      testProxy: "Test",
+      proxyTestOk: "Reached GitHub via {proxy}: HTTP {status} in {latency} ms",
+      autoCheckEvery: "checked automatically every {minutes} min",
+      verification: "Update verification",
+      checksum: "Checksum",
+      signature: "Signature",
     },
     bastions: {
       title: "Bastions",
@@ -312,6 +447,10 @@ const messages = {
       password: "Password",
       pkeyPath: "Private key path",
       pkeyPassphrase: "Key passphrase",
+      ciphers: "Ciphers",
+      macs: "MACs",
+      keyExchanges: "Key exchanges",
+      algorithmsPlaceholder: "Default; comma-separated, a leading + appends to the defaults",
     },
     mappings: {
       title: "Mappings",
@@ -323,24 +462,73 @@ const messages = {
       },
       localHost: "Local host",
       localPort: "Local port",
+      localPortAuto: "auto",
+      localPortAutoHint: "0 = pick a free port at start",
+      listenFamily: "Listen family",
+      listenFamilies: {
+        auto: "As local host",
+        dual: "Dual-stack (IPv4 + IPv6)",
+        ipv4: "IPv4 only",
+        ipv6: "IPv6 only",
+      },
       remoteHost: "Remote host",
       remotePort: "Remote port",
+      remotePortTemplate: "Remote port template",
+      forceEdit: "Change address",
+      forceEditHint: "Allow changing the type and local/remote address; a running mapping is restarted",
+      remoteTemplateHint: "May be a template (env / setting functions), resolved at start",
+      ftpHelper: "FTP helper",
+      ftpHelperHint: "Rewrite PASV/EPSV replies and forward data connections through the same chain",
+      tlsMode: "TLS",
+      tlsModes: {
+        none: "None",
+        terminate: "Terminate locally (forward plaintext)",
+        originate: "Originate toward remote",
+        reencrypt: "Terminate and re-encrypt",
+      },
+      tlsCertFile: "Certificate file",
+      tlsKeyFile: "Key file",
+      tlsServerName: "SNI server name",
+      tlsCaFile: "CA file",
+      tlsCaFileHint: "Empty uses the system roots",
+      tlsInsecure: "Skip verification",
       id: "ID",
       type: "Type",
       local: "Local",
       remote: "Remote",
       chain: "Bastion chain",
+      dependsOn: "Depends on",
+      dependsOnHint: "Mappings of this workspace to start first",
       allowCidrs: "Allow CIDR",
       denyCidrs: "Deny CIDR",
+      targetAllow: "Allowed targets",
+      targetDeny: "Denied targets",
       autoStart: "Auto-start",
       running: "Running",
+      standby: "Standby",
+      quotaExceeded: "Over quota",
+      exposed: "Exposed",
+      exposedBy: "Enabled by {by} at {at}",
+      exposedBanner: "These mappings are exposed beyond this machine and reachable from other hosts on that network: {ids}",
+      expose: "Expose",
+      unexpose: "Unexpose",
+      exposeTitle: "Expose mapping {id}",
+      exposeWarning: "The mapping will listen on the selected interface address (LAN, Tailscale, ...), so any machine on that network can connect to it.",
+      exposeAddress: "Interface address",
+      exposeConfirm: "I understand the risk and want to expose it",
       start: "Start",
       stop: "Stop",
       copyModify: "Copy modify",
       trafficChart: "Traffic chart",
       trafficUp: "Up",
       trafficDown: "Down",
+      trafficTotalUp: "Lifetime up",
+      trafficTotalDown: "Lifetime down",
+      lastStartedAt: "Last started",
       trafficConnections: "Connections",
+      trafficRate: "Rate (10s)",
+      trafficPeakRate: "Peak rate",
+      trafficPeakConnections: "Peak connections",
     },
     httpLogs: {
       title: "HTTP Logs",
@@ -352,8 +540,10 @@ const messages = {
         host: "Host",
         url: "URL",
         duration: "ms",
+        ttfb: "TTFB ms",
       },
       q: "Keyword",
+      live: "Live",
       request: "Request",
       response: "Response",
       pair: "Pair",
@@ -361,6 +551,7 @@ const messages = {
       body: "Body",
       decodeGzip: "Decode gzip (response body only)",
       truncated: "Truncated: {reason}",
+      binaryBody: "[binary content: {type}, {size} base64 characters]\n{data}",
     },
     errorLogs: {
       title: "Error Logs",
@@ -376,7 +567,12 @@ const messages = {
       shutdownAlert: "This will stop the Bastion process. Please confirm.",
       updateTitle: "Confirm update",
       updateAlert: "This will download, replace the binary, and restart.",
+      rollbackTitle: "Confirm rollback",
+      rollbackAlert: "This will swap the binary back to the previous version {version} and restart.",
       deleteTitle: "Confirm delete?",
+      uiReloadTitle: "UI updated",
+      uiReloadAlert: "The server provides a newer version of this UI. Reload the page to use it.",
+      uiReload: "Reload",
     },
   },
 };
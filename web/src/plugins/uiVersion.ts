@@ -0,0 +1,56 @@
+import axios from "axios";
+import { ElMessageBox } from "element-plus";
+
+import { i18n } from "@/plugins/i18n";
+
+const POLL_INTERVAL_MS = 5 * 60 * 1000;
+
+let loadedVersion = "";
+let prompting = false;
+
+// fetchUiVersion bypasses the shared api client so a server restarting after an update does not
+// raise error toasts.
+async function fetchUiVersion(): Promise<string> {
+  try {
+    const res = await axios.get("/api/v2/ui/version", { timeout: 5000 });
+    const version = res.data?.code === "OK" ? res.data.data?.ui_version : "";
+    return typeof version === "string" ? version : "";
+  } catch {
+    return "";
+  }
+}
+
+async function check() {
+  const version = await fetchUiVersion();
+  if (!version) return;
+  if (!loadedVersion) {
+    loadedVersion = version;
+    return;
+  }
+  if (version === loadedVersion || prompting) return;
+
+  prompting = true;
+  const t = i18n.global.t;
+  try {
+    await ElMessageBox.confirm(String(t("dialogs.uiReloadAlert")), String(t("dialogs.uiReloadTitle")), {
+      type: "info",
+      confirmButtonText: String(t("dialogs.uiReload")),
+    });
+    window.location.reload();
+  } catch {
+    // Dismissed: ask again only once the UI changes once more.
+    loadedVersion = version;
+  } finally {
+    prompting = false;
+  }
+}
+
+// watchUiVersion offers a reload when the server embeds a newer UI than the one loaded, e.g.
+// after a self-update. It checks on start, when the tab becomes visible and periodically.
+export function watchUiVersion() {
+  void check();
+  document.addEventListener("visibilitychange", () => {
+    if (document.visibilityState === "visible") void check();
+  });
+  window.setInterval(() => void check(), POLL_INTERVAL_MS);
+}
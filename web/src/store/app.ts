@@ -5,17 +5,20 @@ export type AppTheme = "light" | "dark";
 
 const LS_KEY = "bastion_admin_settings_v1";
 
+export const DEFAULT_WORKSPACE = "default";
+
 type Persisted = {
   sidebarCollapsed: boolean;
   language: AppLanguage;
   theme: AppTheme;
   manualUpdateProxy?: string;
+  workspace: string;
 };
 
 function loadPersisted(): Persisted {
   try {
     const raw = localStorage.getItem(LS_KEY);
-    if (!raw) return { sidebarCollapsed: false, language: "zh", theme: "light" };
+    if (!raw) return { sidebarCollapsed: false, language: "zh", theme: "light", workspace: DEFAULT_WORKSPACE };
     const parsed = JSON.parse(raw) as Partial<Persisted>;
 
     const theme: AppTheme = parsed.theme === "dark" ? "dark" : "light";
@@ -26,9 +29,13 @@ function loadPersisted(): Persisted {
       theme,
       manualUpdateProxy:
         typeof parsed.manualUpdateProxy === "string" ? parsed.manualUpdateProxy : undefined,
+      workspace:
+        typeof parsed.workspace === "string" && parsed.workspace !== ""
+          ? parsed.workspace
+          : DEFAULT_WORKSPACE,
     };
   } catch {
-    return { sidebarCollapsed: false, language: "zh", theme: "light" };
+    return { sidebarCollapsed: false, language: "zh", theme: "light", workspace: DEFAULT_WORKSPACE };
   }
 }
 
@@ -70,5 +77,9 @@ export const useAppStore = defineStore("app", {
       this.manualUpdateProxy = undefined;
       savePersisted(this.$state);
     },
+    setWorkspace(name: string) {
+      this.workspace = name || DEFAULT_WORKSPACE;
+      savePersisted(this.$state);
+    },
   },
 });
@@ -19,3 +19,8 @@ export function formatDateTime(value: string | number | Date): string {
   const ss = String(d.getSeconds()).padStart(2, "0");
   return `${yyyy}-${mm}-${dd} ${hh}:${mi}:${ss}`;
 }
+
+// formatHostPort joins a host and port, bracketing IPv6 literals ("[::1]:8080").
+export function formatHostPort(host: string, port: number | string): string {
+  return host.includes(":") ? `[${host}]:${port}` : `${host}:${port}`;
+}